@@ -0,0 +1,115 @@
+package stowry
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// AccessTracker batches download counts in memory and periodically flushes
+// them to the metadata repository, keeping database writes off the request path.
+//
+// Counts recorded since the last flush are lost if the process crashes.
+type AccessTracker struct {
+	repo     MetaDataRepo
+	interval time.Duration
+
+	mu      sync.Mutex
+	pending map[string]*AccessRecord
+}
+
+// NewAccessTracker creates an AccessTracker that flushes to repo every interval.
+// A non-positive interval defaults to 5 seconds.
+func NewAccessTracker(repo MetaDataRepo, interval time.Duration) *AccessTracker {
+	if interval <= 0 {
+		interval = 5 * time.Second
+	}
+	return &AccessTracker{
+		repo:     repo,
+		interval: interval,
+		pending:  make(map[string]*AccessRecord),
+	}
+}
+
+// Record counts one download of path. It never blocks on the database.
+func (t *AccessTracker) Record(path string) {
+	now := time.Now().UTC()
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	rec, ok := t.pending[path]
+	if !ok {
+		rec = &AccessRecord{Path: path}
+		t.pending[path] = rec
+	}
+	rec.Count++
+	rec.LastAccessedAt = now
+}
+
+// Flush writes all pending counts to the repository. On failure the counts
+// are merged back so the next flush retries them.
+func (t *AccessTracker) Flush(ctx context.Context) error {
+	t.mu.Lock()
+	if len(t.pending) == 0 {
+		t.mu.Unlock()
+		return nil
+	}
+	batch := t.pending
+	t.pending = make(map[string]*AccessRecord, len(batch))
+	t.mu.Unlock()
+
+	records := make([]AccessRecord, 0, len(batch))
+	for _, rec := range batch {
+		records = append(records, *rec)
+	}
+
+	if err := t.repo.RecordAccess(ctx, records); err != nil {
+		t.requeue(records)
+		return fmt.Errorf("flush access records: %w", err)
+	}
+
+	return nil
+}
+
+func (t *AccessTracker) requeue(records []AccessRecord) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for _, r := range records {
+		rec, ok := t.pending[r.Path]
+		if !ok {
+			rec = &AccessRecord{Path: r.Path}
+			t.pending[r.Path] = rec
+		}
+		rec.Count += r.Count
+		if r.LastAccessedAt.After(rec.LastAccessedAt) {
+			rec.LastAccessedAt = r.LastAccessedAt
+		}
+	}
+}
+
+// Run flushes pending counts every interval until ctx is cancelled, then
+// performs a final flush bounded by timeout.
+func (t *AccessTracker) Run(ctx context.Context, timeout time.Duration) {
+	ticker := time.NewTicker(t.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			flushCtx, cancel := context.WithTimeout(context.Background(), timeout)
+			if err := t.Flush(flushCtx); err != nil {
+				slog.Error("final access flush failed", "err", err)
+			}
+			cancel()
+			return
+		case <-ticker.C:
+			if err := t.Flush(ctx); err != nil {
+				slog.Warn("access flush failed", "err", err)
+			}
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package stowry_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sagarc03/stowry"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func recordsByPath(records []stowry.AccessRecord) map[string]int64 {
+	counts := make(map[string]int64, len(records))
+	for _, r := range records {
+		counts[r.Path] = r.Count
+	}
+	return counts
+}
+
+func TestAccessTracker_Flush(t *testing.T) {
+	t.Run("batches counts per path", func(t *testing.T) {
+		repo := new(SpyMetaDataRepo)
+		tracker := stowry.NewAccessTracker(repo, time.Hour)
+		ctx := context.Background()
+
+		tracker.Record("a.txt")
+		tracker.Record("a.txt")
+		tracker.Record("b.txt")
+
+		repo.AssertNotCalled(t, "RecordAccess")
+
+		repo.On("RecordAccess", ctx, mock.MatchedBy(func(records []stowry.AccessRecord) bool {
+			counts := recordsByPath(records)
+			return len(counts) == 2 && counts["a.txt"] == 2 && counts["b.txt"] == 1
+		})).Return(nil).Once()
+
+		require.NoError(t, tracker.Flush(ctx))
+		repo.AssertExpectations(t)
+	})
+
+	t.Run("empty flush skips repo", func(t *testing.T) {
+		repo := new(SpyMetaDataRepo)
+		tracker := stowry.NewAccessTracker(repo, time.Hour)
+
+		require.NoError(t, tracker.Flush(context.Background()))
+		repo.AssertNotCalled(t, "RecordAccess")
+	})
+
+	t.Run("failed flush is retried", func(t *testing.T) {
+		repo := new(SpyMetaDataRepo)
+		tracker := stowry.NewAccessTracker(repo, time.Hour)
+		ctx := context.Background()
+
+		tracker.Record("a.txt")
+		repo.On("RecordAccess", ctx, mock.Anything).Return(errors.New("db down")).Once()
+		assert.Error(t, tracker.Flush(ctx))
+
+		tracker.Record("a.txt")
+		repo.On("RecordAccess", ctx, mock.MatchedBy(func(records []stowry.AccessRecord) bool {
+			return recordsByPath(records)["a.txt"] == 2
+		})).Return(nil).Once()
+		require.NoError(t, tracker.Flush(ctx))
+
+		repo.AssertExpectations(t)
+	})
+}
+
+func TestAccessTracker_Run_FlushesOnStop(t *testing.T) {
+	repo := new(SpyMetaDataRepo)
+	tracker := stowry.NewAccessTracker(repo, time.Hour)
+	tracker.Record("a.txt")
+
+	repo.On("RecordAccess", mock.Anything, mock.Anything).Return(nil).Once()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		tracker.Run(ctx, time.Second)
+		close(done)
+	}()
+	cancel()
+	<-done
+
+	repo.AssertExpectations(t)
+}
+
+// BenchmarkAccessTracker_Record guards the GET hot path: recording must stay a
+// cheap in-memory operation under contention.
+func BenchmarkAccessTracker_Record(b *testing.B) {
+	tracker := stowry.NewAccessTracker(new(SpyMetaDataRepo), time.Hour)
+	paths := []string{"index.html", "app.js", "style.css", "logo.svg"}
+
+	var mu sync.Mutex
+	i := 0
+	b.RunParallel(func(pb *testing.PB) {
+		mu.Lock()
+		path := paths[i%len(paths)]
+		i++
+		mu.Unlock()
+		for pb.Next() {
+			tracker.Record(path)
+		}
+	})
+}
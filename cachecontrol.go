@@ -0,0 +1,32 @@
+package stowry
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// MaxCacheControlBytes is the longest Cache-Control value, in bytes, that is
+// stored with an object.
+const MaxCacheControlBytes = 256
+
+// NormalizeCacheControl returns the Cache-Control value to store with an
+// object, with surrounding spaces trimmed. The value is otherwise stored as
+// given; its directives are not checked.
+//
+// Returns:
+//   - string: The value to store, or "" for none
+//   - error: ErrInvalidCacheControl (which also matches ErrInvalidInput) when
+//     the value is not valid UTF-8, contains control characters or is longer
+//     than MaxCacheControlBytes
+func NormalizeCacheControl(value string) (string, error) {
+	value = strings.TrimSpace(value)
+	if !utf8.ValidString(value) || strings.ContainsFunc(value, unicode.IsControl) {
+		return "", fmt.Errorf("%w: %q", ErrInvalidCacheControl, value)
+	}
+	if len(value) > MaxCacheControlBytes {
+		return "", fmt.Errorf("%w: %d bytes (max %d)", ErrInvalidCacheControl, len(value), MaxCacheControlBytes)
+	}
+	return value, nil
+}
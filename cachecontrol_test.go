@@ -0,0 +1,33 @@
+package stowry_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/sagarc03/stowry"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNormalizeCacheControl(t *testing.T) {
+	got, err := stowry.NormalizeCacheControl("  public, max-age=31536000, immutable ")
+	require.NoError(t, err)
+	assert.Equal(t, "public, max-age=31536000, immutable", got)
+
+	got, err = stowry.NormalizeCacheControl("")
+	require.NoError(t, err)
+	assert.Empty(t, got)
+
+	_, err = stowry.NormalizeCacheControl(strings.Repeat("a", stowry.MaxCacheControlBytes))
+	assert.NoError(t, err)
+
+	for name, value := range map[string]string{
+		"too long":      strings.Repeat("a", stowry.MaxCacheControlBytes+1),
+		"header split":  "no-cache\r\nSet-Cookie: x=1",
+		"invalid utf-8": "max-age=\xff",
+	} {
+		_, err := stowry.NormalizeCacheControl(value)
+		assert.ErrorIs(t, err, stowry.ErrInvalidCacheControl, name)
+		assert.ErrorIs(t, err, stowry.ErrInvalidInput, name)
+	}
+}
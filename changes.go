@@ -0,0 +1,299 @@
+package stowry
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// ChangeKind is the kind of metadata change recorded in the change feed.
+type ChangeKind string
+
+const (
+	ChangeCreate ChangeKind = "create" // An object was created or overwritten
+	ChangeDelete ChangeKind = "delete" // An object was deleted
+)
+
+// Change is one entry of the change feed.
+type Change struct {
+	Seq  int64      `json:"seq"`
+	Kind ChangeKind `json:"kind"`
+	Path string     `json:"path"`
+	ETag string     `json:"etag"`
+	Size int64      `json:"file_size_bytes"`
+	At   time.Time  `json:"at"`
+	// PreviousETag is the ETag of the object a create overwrote; empty when
+	// the create made a new object or restored a deleted one, and for
+	// objects written in a batch or stage commit.
+	PreviousETag string `json:"previous_etag,omitempty"`
+	// Actor identifies who made the change, such as the access key of a
+	// signed request; empty for changes made without authentication or by
+	// the server itself.
+	Actor string `json:"actor,omitempty"`
+}
+
+// actorKey is the context key for the actor recorded with changes.
+type actorKey struct{}
+
+// WithActor returns a context whose metadata changes are recorded as made
+// by actor.
+func WithActor(ctx context.Context, actor string) context.Context {
+	return context.WithValue(ctx, actorKey{}, actor)
+}
+
+// ActorFromContext returns the actor set by WithActor, or "" when there is
+// none.
+func ActorFromContext(ctx context.Context) string {
+	actor, _ := ctx.Value(actorKey{}).(string)
+	return actor
+}
+
+// ChangeQuery selects entries of the change feed.
+type ChangeQuery struct {
+	PathPrefix string
+	Since      int64 // Return changes after this sequence. Negative starts at the latest change.
+	Limit      int   // Maximum changes returned (default: DefaultChangeLimit)
+}
+
+// ChangeBatch is a page of the change feed.
+type ChangeBatch struct {
+	Changes []Change `json:"changes"`
+	Next    int64    `json:"next"` // Pass as Since to continue after this batch
+}
+
+// HistoryQuery selects the changes of a single path.
+type HistoryQuery struct {
+	Path  string
+	After int64 // Return changes after this sequence; 0 starts at the oldest retained change
+	Limit int   // Maximum changes returned (default: DefaultChangeLimit)
+}
+
+// History is a page of the changes of one path, oldest first.
+type History struct {
+	Path    string   `json:"path"`
+	Changes []Change `json:"changes"`
+	Next    int64    `json:"next,omitempty"` // Pass as After for the next page; 0 on the last page
+}
+
+// DefaultChangeLimit is the page size of a ChangeQuery without a Limit.
+const DefaultChangeLimit = 100
+
+// ChangePruneInterval is how often RunChangePruning deletes expired changes.
+const ChangePruneInterval = time.Hour
+
+// DefaultChangePollInterval is how often WatchChanges re-reads the feed while
+// waiting, to notice changes made by other processes sharing the database.
+const DefaultChangePollInterval = time.Second
+
+// ChangeFeed is an optional MetaDataRepo extension that records every
+// create and delete in an ordered feed. Changes are recorded in the same
+// transaction as the metadata change, so the feed never reports a change
+// that did not happen. The service checks for it with a type assertion.
+type ChangeFeed interface {
+	// Changes returns up to q.Limit changes with a sequence above q.Since
+	// under q.PathPrefix, oldest first. Next is the sequence of the last
+	// change when the limit was reached, otherwise the highest sequence in the
+	// feed, so changes outside the prefix are not scanned again. A negative
+	// Since returns no changes and the highest sequence.
+	//
+	// Returns:
+	//   - ChangeBatch: The changes and the cursor to continue from
+	//   - error: ErrCursorExpired if changes after q.Since were pruned or
+	//     q.Since is beyond the feed, or other database errors
+	Changes(ctx context.Context, q ChangeQuery) (ChangeBatch, error)
+
+	// PruneChanges deletes changes recorded before the cutoff. The newest
+	// change is always kept so the feed can tell live cursors from expired
+	// ones.
+	//
+	// Returns:
+	//   - int64: Number of changes deleted
+	//   - error: Any database error
+	PruneChanges(ctx context.Context, before time.Time) (int64, error)
+}
+
+// ChangeHistory is an optional ChangeFeed extension that reads the changes
+// of one path without scanning the rest of the feed. History only reaches
+// back as far as change feed retention.
+type ChangeHistory interface {
+	// History returns up to q.Limit changes of q.Path with a sequence above
+	// q.After, oldest first. Next is the sequence of the last change when
+	// more follow, otherwise 0.
+	//
+	// Returns:
+	//   - History: The changes and the cursor to continue from
+	//   - error: Any database error. A path without retained changes is an
+	//     empty history, not ErrNotFound.
+	History(ctx context.Context, q HistoryQuery) (History, error)
+}
+
+// changeSignal wakes WatchChanges callers when this service changed
+// metadata. The zero value is ready to use.
+type changeSignal struct {
+	mu sync.Mutex
+	ch chan struct{}
+}
+
+// wait returns a channel that is closed by the next notify.
+func (c *changeSignal) wait() <-chan struct{} {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.ch == nil {
+		c.ch = make(chan struct{})
+	}
+	return c.ch
+}
+
+// notify wakes every waiter.
+func (c *changeSignal) notify() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.ch != nil {
+		close(c.ch)
+		c.ch = nil
+	}
+}
+
+// changeFeed returns the repository's change feed.
+func (s *StowryService) changeFeed() (ChangeFeed, error) {
+	feed, ok := s.repo.(ChangeFeed)
+	if !ok {
+		return nil, fmt.Errorf("metadata repository does not record changes: %w", ErrNotSupported)
+	}
+	return feed, nil
+}
+
+// WatchChanges returns the changes after q.Since. When there are none it
+// waits up to wait for new ones, returning an empty batch with the current
+// cursor on timeout. Writes through this service wake waiters at once;
+// writes by other processes are noticed within DefaultChangePollInterval.
+//
+// Parameters:
+//   - ctx: Context for cancellation and timeout
+//   - q: Prefix, cursor and page size. A negative Since waits for changes
+//     after the current latest one.
+//   - wait: How long to wait for a change. Zero returns immediately.
+//
+// Returns:
+//   - ChangeBatch: The changes and the cursor to continue from
+//   - error: ErrNotSupported without a change feed, ErrCursorExpired, or any
+//     database error
+func (s *StowryService) WatchChanges(ctx context.Context, q ChangeQuery, wait time.Duration) (ChangeBatch, error) {
+	feed, err := s.changeFeed()
+	if err != nil {
+		return ChangeBatch{}, fmt.Errorf("watch changes: %w", err)
+	}
+	if q.Limit <= 0 {
+		q.Limit = DefaultChangeLimit
+	}
+
+	var timeout <-chan time.Time
+	if wait > 0 {
+		timer := time.NewTimer(wait)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+	var poll *time.Ticker
+
+	for {
+		// Taken before reading so a change between the read and the wait
+		// still wakes us
+		signal := s.changes.wait()
+
+		batch, err := feed.Changes(ctx, q)
+		if err != nil {
+			return ChangeBatch{}, fmt.Errorf("watch changes: %w", err)
+		}
+		if len(batch.Changes) > 0 || timeout == nil {
+			return batch, nil
+		}
+		q.Since = batch.Next
+
+		if poll == nil {
+			poll = time.NewTicker(DefaultChangePollInterval)
+			defer poll.Stop()
+		}
+
+		select {
+		case <-signal:
+		case <-poll.C:
+		case <-timeout:
+			return batch, nil
+		case <-ctx.Done():
+			return ChangeBatch{}, fmt.Errorf("watch changes: %w", ctx.Err())
+		}
+	}
+}
+
+// History returns the recorded changes of path, oldest first.
+//
+// Parameters:
+//   - ctx: Context for cancellation and timeout
+//   - q: Path, cursor and page size
+//
+// Returns:
+//   - History: The changes and the cursor to continue from
+//   - error: ErrInvalidInput for an invalid path, ErrNotSupported when the
+//     repository cannot read a path's history, or any database error
+func (s *StowryService) History(ctx context.Context, q HistoryQuery) (History, error) {
+	if !IsValidPath(q.Path) {
+		return History{}, fmt.Errorf("history: %w", ErrInvalidInput)
+	}
+	hist, ok := s.repo.(ChangeHistory)
+	if !ok {
+		return History{}, fmt.Errorf("history: metadata repository does not record history: %w", ErrNotSupported)
+	}
+	if q.Limit <= 0 {
+		q.Limit = DefaultChangeLimit
+	}
+
+	h, err := hist.History(ctx, q)
+	if err != nil {
+		return History{}, fmt.Errorf("history: %w", err)
+	}
+	return h, nil
+}
+
+// PruneChanges deletes change feed entries recorded before the cutoff.
+//
+// Returns:
+//   - int64: Number of changes deleted
+//   - error: ErrNotSupported without a change feed, or any database error
+func (s *StowryService) PruneChanges(ctx context.Context, before time.Time) (int64, error) {
+	feed, err := s.changeFeed()
+	if err != nil {
+		return 0, fmt.Errorf("prune changes: %w", err)
+	}
+
+	n, err := feed.PruneChanges(ctx, before)
+	if err != nil {
+		return 0, fmt.Errorf("prune changes: %w", err)
+	}
+	return n, nil
+}
+
+// RunChangePruning deletes changes older than retention right away and then
+// every ChangePruneInterval until ctx is cancelled. Failures are logged and
+// retried on the next tick.
+func (s *StowryService) RunChangePruning(ctx context.Context, retention time.Duration) {
+	ticker := time.NewTicker(ChangePruneInterval)
+	defer ticker.Stop()
+
+	for {
+		if n, err := s.PruneChanges(ctx, time.Now().Add(-retention)); err != nil {
+			if ctx.Err() == nil {
+				slog.Warn("change feed prune failed", "err", err)
+			}
+		} else if n > 0 {
+			slog.Debug("change feed pruned", "changes", n)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,148 @@
+package stowry_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/sagarc03/stowry"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// SpyChangeFeedRepo is a SpyMetaDataRepo that also implements
+// stowry.ChangeFeed and stowry.ChangeHistory.
+type SpyChangeFeedRepo struct {
+	SpyMetaDataRepo
+}
+
+func (s *SpyChangeFeedRepo) Changes(ctx context.Context, q stowry.ChangeQuery) (stowry.ChangeBatch, error) {
+	args := s.Called(ctx, q)
+	return args.Get(0).(stowry.ChangeBatch), args.Error(1)
+}
+
+func (s *SpyChangeFeedRepo) PruneChanges(ctx context.Context, before time.Time) (int64, error) {
+	args := s.Called(ctx, before)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (s *SpyChangeFeedRepo) History(ctx context.Context, q stowry.HistoryQuery) (stowry.History, error) {
+	args := s.Called(ctx, q)
+	return args.Get(0).(stowry.History), args.Error(1)
+}
+
+func newChangeFeedService(t *testing.T) (*stowry.StowryService, *SpyChangeFeedRepo) {
+	t.Helper()
+	repo := new(SpyChangeFeedRepo)
+	service, err := stowry.NewStowryService(repo, new(SpyFileStorage), stowry.ServiceConfig{Mode: stowry.ModeStore})
+	require.NoError(t, err)
+	return service, repo
+}
+
+func TestStowryService_WatchChanges(t *testing.T) {
+	ctx := context.Background()
+	created := stowry.Change{Seq: 8, Kind: stowry.ChangeCreate, Path: "docs/a.txt"}
+
+	t.Run("returns pending changes at once", func(t *testing.T) {
+		service, repo := newChangeFeedService(t)
+		repo.On("Changes", mock.Anything, stowry.ChangeQuery{PathPrefix: "docs/", Since: 7, Limit: stowry.DefaultChangeLimit}).
+			Return(stowry.ChangeBatch{Changes: []stowry.Change{created}, Next: 8}, nil)
+
+		batch, err := service.WatchChanges(ctx, stowry.ChangeQuery{PathPrefix: "docs/", Since: 7}, time.Minute)
+		require.NoError(t, err)
+		assert.Equal(t, []stowry.Change{created}, batch.Changes)
+		assert.Equal(t, int64(8), batch.Next)
+	})
+
+	t.Run("wakes on a change through the service", func(t *testing.T) {
+		service, repo := newChangeFeedService(t)
+		repo.On("Changes", mock.Anything, stowry.ChangeQuery{Since: -1, Limit: 10}).
+			Return(stowry.ChangeBatch{Changes: []stowry.Change{}, Next: 7}, nil).Once()
+		repo.On("Changes", mock.Anything, stowry.ChangeQuery{Since: 7, Limit: 10}).
+			Return(stowry.ChangeBatch{Changes: []stowry.Change{created}, Next: 8}, nil).Once()
+		repo.On("Delete", mock.Anything, "docs/a.txt").Return(nil)
+
+		go func() {
+			time.Sleep(50 * time.Millisecond)
+			_ = service.Delete(ctx, "docs/a.txt")
+		}()
+
+		start := time.Now()
+		batch, err := service.WatchChanges(ctx, stowry.ChangeQuery{Since: -1, Limit: 10}, time.Minute)
+		require.NoError(t, err)
+		assert.Equal(t, []stowry.Change{created}, batch.Changes)
+		assert.Less(t, time.Since(start), stowry.DefaultChangePollInterval, "woken before the next poll")
+	})
+
+	t.Run("times out with the current cursor", func(t *testing.T) {
+		service, repo := newChangeFeedService(t)
+		repo.On("Changes", mock.Anything, mock.Anything).Return(stowry.ChangeBatch{Changes: []stowry.Change{}, Next: 7}, nil)
+
+		batch, err := service.WatchChanges(ctx, stowry.ChangeQuery{Since: 7}, 20*time.Millisecond)
+		require.NoError(t, err)
+		assert.Empty(t, batch.Changes)
+		assert.Equal(t, int64(7), batch.Next)
+	})
+
+	t.Run("stops when the context is cancelled", func(t *testing.T) {
+		service, repo := newChangeFeedService(t)
+		repo.On("Changes", mock.Anything, mock.Anything).Return(stowry.ChangeBatch{Changes: []stowry.Change{}, Next: 7}, nil)
+
+		cancelled, cancel := context.WithTimeout(ctx, 20*time.Millisecond)
+		defer cancel()
+		_, err := service.WatchChanges(cancelled, stowry.ChangeQuery{Since: 7}, time.Minute)
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+	})
+
+	t.Run("expired cursor", func(t *testing.T) {
+		service, repo := newChangeFeedService(t)
+		repo.On("Changes", mock.Anything, mock.Anything).Return(stowry.ChangeBatch{}, stowry.ErrCursorExpired)
+
+		_, err := service.WatchChanges(ctx, stowry.ChangeQuery{Since: 1}, time.Minute)
+		assert.ErrorIs(t, err, stowry.ErrCursorExpired)
+	})
+
+	t.Run("repository without a change feed", func(t *testing.T) {
+		service, _, _ := NewStowryService(t)
+
+		_, err := service.WatchChanges(ctx, stowry.ChangeQuery{}, 0)
+		assert.ErrorIs(t, err, stowry.ErrNotSupported)
+		_, err = service.PruneChanges(ctx, time.Now())
+		assert.ErrorIs(t, err, stowry.ErrNotSupported)
+	})
+}
+
+func TestStowryService_History(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("defaults the page size", func(t *testing.T) {
+		service, repo := newChangeFeedService(t)
+		want := stowry.History{Path: "a.txt", Changes: []stowry.Change{{Seq: 3, Kind: stowry.ChangeDelete, Path: "a.txt"}}}
+		repo.On("History", mock.Anything, stowry.HistoryQuery{Path: "a.txt", After: 2, Limit: stowry.DefaultChangeLimit}).Return(want, nil)
+
+		got, err := service.History(ctx, stowry.HistoryQuery{Path: "a.txt", After: 2})
+		require.NoError(t, err)
+		assert.Equal(t, want, got)
+	})
+
+	t.Run("invalid path", func(t *testing.T) {
+		service, repo := newChangeFeedService(t)
+
+		_, err := service.History(ctx, stowry.HistoryQuery{Path: "../a.txt"})
+		assert.ErrorIs(t, err, stowry.ErrInvalidInput)
+		repo.AssertNotCalled(t, "History", mock.Anything, mock.Anything)
+	})
+
+	t.Run("repository without history", func(t *testing.T) {
+		service, _, _ := NewStowryService(t)
+
+		_, err := service.History(ctx, stowry.HistoryQuery{Path: "a.txt"})
+		assert.ErrorIs(t, err, stowry.ErrNotSupported)
+	})
+}
+
+func TestActorFromContext(t *testing.T) {
+	assert.Empty(t, stowry.ActorFromContext(context.Background()))
+	assert.Equal(t, "AKALICE", stowry.ActorFromContext(stowry.WithActor(context.Background(), "AKALICE")))
+}
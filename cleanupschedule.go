@@ -0,0 +1,106 @@
+package stowry
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+// DefaultCleanupBatchSize is the number of pending items a scheduled cleanup
+// lists at a time when CleanupSchedule.BatchSize is not set.
+const DefaultCleanupBatchSize = 100
+
+// DefaultCleanupMaxBackoff is the longest RunCleanup waits after consecutive
+// failed runs when CleanupSchedule.MaxBackoff is not set.
+const DefaultCleanupMaxBackoff = 24 * time.Hour
+
+// CleanupSchedule configures RunCleanup.
+type CleanupSchedule struct {
+	Interval time.Duration // Time between runs
+	// Retention is how long after its delete an object is kept before it is
+	// purged, so it can still be restored or inspected. 0 purges it at the
+	// next run.
+	Retention time.Duration
+	BatchSize int // Pending items listed at a time (default: DefaultCleanupBatchSize)
+	// MaxBackoff caps the wait after consecutive failed runs, which doubles
+	// with each failure (default: DefaultCleanupMaxBackoff). It never
+	// shortens the wait below Interval.
+	MaxBackoff time.Duration
+}
+
+// Options returns the TombstoneWithReport options of a scheduled run at now:
+// objects deleted more than Retention before now, listed BatchSize at a time,
+// carrying on past items that fail.
+func (c CleanupSchedule) Options(now time.Time) TombstoneOptions {
+	batchSize := c.BatchSize
+	if batchSize <= 0 {
+		batchSize = DefaultCleanupBatchSize
+	}
+	return TombstoneOptions{
+		Query:           ListQuery{Limit: batchSize, DeletedBefore: now.Add(-c.Retention)},
+		ContinueOnError: true,
+	}
+}
+
+// Delay returns the wait before the next run after failures consecutive
+// failed runs.
+func (c CleanupSchedule) Delay(failures int) time.Duration {
+	maxBackoff := c.MaxBackoff
+	if maxBackoff <= 0 {
+		maxBackoff = DefaultCleanupMaxBackoff
+	}
+	maxBackoff = max(maxBackoff, c.Interval)
+
+	d := c.Interval
+	for range failures {
+		if d >= maxBackoff/2 {
+			return maxBackoff
+		}
+		d *= 2
+	}
+	return d
+}
+
+// RunCleanup purges soft-deleted objects right away and then every
+// schedule.Interval until ctx is cancelled, which also stops a run in
+// progress. Each run is a TombstoneWithReport with schedule.Options, so items
+// that fail are deferred and retried by a later run. A run that fails as a
+// whole is logged, and the wait before the next one doubles with each
+// consecutive failure, up to schedule.MaxBackoff.
+func (s *StowryService) RunCleanup(ctx context.Context, schedule CleanupSchedule) {
+	var failures int
+	for {
+		report, err := s.TombstoneWithReport(ctx, schedule.Options(time.Now()))
+		switch {
+		case ctx.Err() != nil:
+			return
+		case err != nil:
+			failures++
+			slog.Warn("scheduled cleanup failed", "err", err, "cleaned", report.Cleaned,
+				"consecutive_failures", failures, "retry_in", schedule.Delay(failures))
+		default:
+			failures = 0
+			level := slog.LevelDebug
+			if report.Cleaned > 0 || report.Failed > 0 || report.AbortedUploads > 0 {
+				level = slog.LevelInfo
+			}
+			slog.Log(ctx, level, "scheduled cleanup complete",
+				"cleaned", report.Cleaned,
+				"bytes_reclaimed", report.BytesReclaimed,
+				"already_missing", report.Skipped,
+				"mismatched", report.Mismatched,
+				"failed", report.Failed,
+				"deferred", report.Deferred,
+				"aborted_uploads", report.AbortedUploads,
+			)
+		}
+
+		timer := time.NewTimer(schedule.Delay(failures))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+	}
+}
@@ -0,0 +1,102 @@
+package stowry_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sagarc03/stowry"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestCleanupSchedule_Options(t *testing.T) {
+	now := time.Date(2026, 3, 4, 12, 0, 0, 0, time.UTC)
+
+	opts := stowry.CleanupSchedule{Interval: time.Hour, Retention: 72 * time.Hour}.Options(now)
+	assert.Equal(t, now.Add(-72*time.Hour), opts.Query.DeletedBefore)
+	assert.Equal(t, stowry.DefaultCleanupBatchSize, opts.Query.Limit)
+	assert.True(t, opts.ContinueOnError)
+	assert.False(t, opts.DryRun)
+
+	opts = stowry.CleanupSchedule{Interval: time.Hour, BatchSize: 25}.Options(now)
+	assert.Equal(t, now, opts.Query.DeletedBefore)
+	assert.Equal(t, 25, opts.Query.Limit)
+}
+
+func TestCleanupSchedule_Delay(t *testing.T) {
+	schedule := stowry.CleanupSchedule{Interval: time.Minute, MaxBackoff: 5 * time.Minute}
+
+	assert.Equal(t, time.Minute, schedule.Delay(0))
+	assert.Equal(t, 2*time.Minute, schedule.Delay(1))
+	assert.Equal(t, 4*time.Minute, schedule.Delay(2))
+	assert.Equal(t, 5*time.Minute, schedule.Delay(3))
+	assert.Equal(t, 5*time.Minute, schedule.Delay(100))
+
+	t.Run("max backoff below interval", func(t *testing.T) {
+		schedule := stowry.CleanupSchedule{Interval: time.Hour, MaxBackoff: time.Minute}
+		assert.Equal(t, time.Hour, schedule.Delay(3))
+	})
+
+	t.Run("default max backoff", func(t *testing.T) {
+		schedule := stowry.CleanupSchedule{Interval: time.Hour}
+		assert.Equal(t, stowry.DefaultCleanupMaxBackoff, schedule.Delay(10))
+	})
+}
+
+func TestStowryService_RunCleanup(t *testing.T) {
+	t.Run("purges items past retention until cancelled", func(t *testing.T) {
+		service, repo, storage := NewStowryService(t)
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		id := uuid.New()
+
+		var cutoff time.Time
+		repo.On("ListPendingCleanup", mock.Anything, mock.Anything).
+			Run(func(args mock.Arguments) { cutoff = args.Get(1).(stowry.ListQuery).DeletedBefore }).
+			Return(stowry.ListResult{Items: []stowry.MetaData{{ID: id, Path: "old.txt", FileSizeBytes: 5}}}, nil).Once()
+		storage.On("Delete", mock.Anything, "old.txt").Return(nil)
+		repo.On("MarkCleanedUp", mock.Anything, id).Run(func(mock.Arguments) { cancel() }).Return(nil)
+
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			service.RunCleanup(ctx, stowry.CleanupSchedule{Interval: time.Hour, Retention: 72 * time.Hour})
+		}()
+
+		select {
+		case <-done:
+		case <-time.After(5 * time.Second):
+			t.Fatal("RunCleanup did not stop after cancel")
+		}
+		assert.WithinDuration(t, time.Now().Add(-72*time.Hour), cutoff, time.Minute)
+		storage.AssertExpectations(t)
+	})
+
+	t.Run("keeps running after a failed run", func(t *testing.T) {
+		service, repo, _ := NewStowryService(t)
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		repo.On("ListPendingCleanup", mock.Anything, mock.Anything).
+			Return(stowry.ListResult{}, errors.New("database is down")).Once()
+		repo.On("ListPendingCleanup", mock.Anything, mock.Anything).
+			Run(func(mock.Arguments) { cancel() }).
+			Return(stowry.ListResult{}, nil).Once()
+
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			service.RunCleanup(ctx, stowry.CleanupSchedule{Interval: time.Millisecond, MaxBackoff: 10 * time.Millisecond})
+		}()
+
+		select {
+		case <-done:
+		case <-time.After(5 * time.Second):
+			t.Fatal("RunCleanup did not retry")
+		}
+		repo.AssertNumberOfCalls(t, "ListPendingCleanup", 2)
+	})
+}
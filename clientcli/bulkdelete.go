@@ -0,0 +1,134 @@
+package clientcli
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+)
+
+// MaxDeleteBatch is the most paths Delete sends in one bulk delete request,
+// unless the server's capabilities allow fewer.
+const MaxDeleteBatch = 1000
+
+// bulkDeleteThreshold is the fewest paths Delete sends as a bulk delete
+// request; fewer are deleted with a DELETE each.
+const bulkDeleteThreshold = 5
+
+// errBulkDeleteUnsupported reports a server without POST /?delete, so
+// Delete falls back to a DELETE for each path.
+var errBulkDeleteUnsupported = errors.New("server does not support bulk delete")
+
+// serverDeleteResponse mirrors the response to POST /?delete.
+type serverDeleteResponse struct {
+	Results []struct {
+		Path    string `json:"path"`
+		Status  string `json:"status"`
+		Error   string `json:"error"`
+		Message string `json:"message"`
+	} `json:"results"`
+}
+
+// deleteMany deletes paths with one POST /?delete per batch. It returns
+// errBulkDeleteUnsupported, without results, when the server does not take
+// the first batch.
+func (c *Client) deleteMany(ctx context.Context, paths []string) ([]DeleteResult, error) {
+	batchSize := MaxDeleteBatch
+	if n := c.capabilities(ctx).Limits.MaxDeletePaths; n > 0 && n < batchSize {
+		batchSize = n
+	}
+
+	results := make([]DeleteResult, 0, len(paths))
+	for batch := range slices.Chunk(paths, batchSize) {
+		if err := ctx.Err(); err != nil {
+			return results, err
+		}
+
+		batchResults, err := c.deleteBatch(ctx, batch)
+		if errors.Is(err, errBulkDeleteUnsupported) && len(results) == 0 {
+			return nil, err
+		}
+		if err != nil {
+			for _, p := range batch {
+				results = append(results, DeleteResult{Path: p, Err: err})
+			}
+			continue
+		}
+		results = append(results, batchResults...)
+	}
+	return results, nil
+}
+
+func (c *Client) deleteBatch(ctx context.Context, paths []string) ([]DeleteResult, error) {
+	// The server takes object keys, which have no leading slash
+	keys := make([]string, len(paths))
+	for i, p := range paths {
+		keys[i] = strings.TrimPrefix(normalizePath(p), "/")
+	}
+	defer func() {
+		for _, k := range keys {
+			c.Purge(k)
+		}
+	}()
+	payload, err := json.Marshal(keys)
+	if err != nil {
+		return nil, fmt.Errorf("encode paths: %w", err)
+	}
+
+	presignURL := c.presign(http.MethodPost, "/", url.Values{"delete": {""}}, DefaultExpires)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, presignURL, bytes.NewReader(payload))
+	if err != nil {
+		return nil, fmt.Errorf("create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("do request: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("read response: %w", err)
+	}
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound, http.StatusMethodNotAllowed, http.StatusNotImplemented:
+		return nil, errBulkDeleteUnsupported
+	default:
+		return nil, parseServerError(resp.StatusCode, body)
+	}
+
+	var deleted serverDeleteResponse
+	if err := json.Unmarshal(body, &deleted); err != nil {
+		return nil, fmt.Errorf("parse response: %w", err)
+	}
+	if len(deleted.Results) != len(paths) {
+		return nil, fmt.Errorf("parse response: %d results for %d paths", len(deleted.Results), len(paths))
+	}
+
+	// Results come in the order of the request; report the paths as given
+	results := make([]DeleteResult, len(paths))
+	for i, p := range paths {
+		r := deleted.Results[i]
+		results[i] = DeleteResult{Path: p}
+		switch r.Status {
+		case "deleted":
+			results[i].Deleted = true
+		case "not_found":
+			// As a DELETE of a missing object is answered
+			results[i].Err = &APIError{StatusCode: http.StatusNotFound, Body: r.Status}
+		default:
+			results[i].Err = fmt.Errorf("%s: %s", r.Error, r.Message)
+		}
+	}
+	return results, nil
+}
@@ -0,0 +1,139 @@
+package clientcli_test
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/sagarc03/stowry/clientcli"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClient_Delete_Bulk(t *testing.T) {
+	paths := []string{"/a.txt", "b.txt", "missing.txt", "bad.txt", "c.txt"}
+
+	// bulkServer answers POST /?delete like the server, and single DELETEs
+	// with 204, recording each request as "METHOD /path?query".
+	type bulkServer struct {
+		mu       sync.Mutex
+		requests []string
+		batches  [][]string
+	}
+	newServer := func(t *testing.T, bulk bool) (*bulkServer, *clientcli.Client) {
+		t.Helper()
+		bs := &bulkServer{}
+		server := httptest.NewServer(withoutCapabilities(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			bs.mu.Lock()
+			defer bs.mu.Unlock()
+			bs.requests = append(bs.requests, r.Method+" "+r.URL.Path)
+
+			switch {
+			case r.Method == http.MethodDelete:
+				w.WriteHeader(http.StatusNoContent)
+			case bulk && r.Method == http.MethodPost && r.URL.Query().Has("delete"):
+				var keys []string
+				if err := json.NewDecoder(r.Body).Decode(&keys); err != nil {
+					w.WriteHeader(http.StatusBadRequest)
+					return
+				}
+				bs.batches = append(bs.batches, keys)
+				results := make([]map[string]string, len(keys))
+				for i, k := range keys {
+					switch k {
+					case "missing.txt":
+						results[i] = map[string]string{"path": k, "status": "not_found"}
+					case "bad.txt":
+						results[i] = map[string]string{"path": k, "status": "error", "error": "internal_error", "message": "Internal server error"}
+					default:
+						results[i] = map[string]string{"path": k, "status": "deleted"}
+					}
+				}
+				_ = json.NewEncoder(w).Encode(map[string]any{"results": results})
+			default:
+				w.WriteHeader(http.StatusMethodNotAllowed)
+			}
+		})))
+		t.Cleanup(server.Close)
+
+		client, err := clientcli.New(&clientcli.Config{Endpoint: server.URL})
+		require.NoError(t, err)
+		return bs, client
+	}
+
+	t.Run("deletes in one request", func(t *testing.T) {
+		bs, client := newServer(t, true)
+
+		results, err := client.Delete(t.Context(), clientcli.DeleteOptions{Paths: paths})
+		require.NoError(t, err)
+
+		assert.Equal(t, []string{"POST /"}, bs.requests)
+		assert.Equal(t, [][]string{{"a.txt", "b.txt", "missing.txt", "bad.txt", "c.txt"}}, bs.batches)
+		require.Len(t, results, len(paths))
+		assert.Equal(t, "/a.txt", results[0].Path, "paths are reported as given")
+		assert.True(t, results[0].Deleted)
+		assert.True(t, results[4].Deleted)
+		assert.ErrorIs(t, results[2].Err, clientcli.ErrNotFound)
+		assert.ErrorContains(t, results[3].Err, "Internal server error")
+		assert.True(t, clientcli.HasDeleteErrors(results))
+	})
+
+	t.Run("batches many paths", func(t *testing.T) {
+		bs, client := newServer(t, true)
+		many := make([]string, clientcli.MaxDeleteBatch+1)
+		for i := range many {
+			many[i] = fmt.Sprintf("dir/%d.txt", i)
+		}
+
+		results, err := client.Delete(t.Context(), clientcli.DeleteOptions{Paths: many})
+		require.NoError(t, err)
+
+		assert.Len(t, results, len(many))
+		require.Len(t, bs.batches, 2)
+		assert.Len(t, bs.batches[0], clientcli.MaxDeleteBatch)
+		assert.False(t, clientcli.HasDeleteErrors(results))
+	})
+
+	t.Run("few paths are deleted one by one", func(t *testing.T) {
+		bs, client := newServer(t, true)
+
+		results, err := client.Delete(t.Context(), clientcli.DeleteOptions{Paths: []string{"a.txt", "b.txt"}})
+		require.NoError(t, err)
+
+		assert.Equal(t, []string{"DELETE /a.txt", "DELETE /b.txt"}, bs.requests)
+		assert.False(t, clientcli.HasDeleteErrors(results))
+	})
+
+	t.Run("old server falls back to single deletes", func(t *testing.T) {
+		bs, client := newServer(t, false)
+
+		results, err := client.Delete(t.Context(), clientcli.DeleteOptions{Paths: paths})
+		require.NoError(t, err)
+
+		require.Len(t, bs.requests, len(paths)+1)
+		assert.Equal(t, "POST /", bs.requests[0])
+		assert.False(t, clientcli.HasDeleteErrors(results))
+	})
+
+	t.Run("server error fails the batch", func(t *testing.T) {
+		server := httptest.NewServer(withoutCapabilities(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			w.WriteHeader(http.StatusUnauthorized)
+		})))
+		defer server.Close()
+		client, err := clientcli.New(&clientcli.Config{Endpoint: server.URL})
+		require.NoError(t, err)
+
+		results, err := client.Delete(t.Context(), clientcli.DeleteOptions{Paths: paths})
+		require.NoError(t, err)
+
+		require.Len(t, results, len(paths))
+		for _, r := range results {
+			var apiErr *clientcli.APIError
+			assert.True(t, errors.As(r.Err, &apiErr), r.Path)
+		}
+	})
+}
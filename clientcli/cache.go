@@ -0,0 +1,291 @@
+package clientcli
+
+import (
+	"bytes"
+	"container/list"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultCacheMaxEntries is the number of objects a response cache holds
+	// when CacheConfig.MaxEntries is not set.
+	DefaultCacheMaxEntries = 1000
+
+	// DefaultCacheMaxBytes is the total body size a response cache holds when
+	// CacheConfig.MaxBytes is not set (32 MiB).
+	DefaultCacheMaxBytes = 32 << 20
+)
+
+// CacheConfig configures the response cache enabled by WithCache.
+type CacheConfig struct {
+	MaxEntries int   // 0 uses DefaultCacheMaxEntries
+	MaxBytes   int64 // 0 uses DefaultCacheMaxBytes; larger bodies are not cached
+	// TTL is how long a cached body is served without asking the server.
+	// Once it passes, the next Fetch revalidates with If-None-Match. 0
+	// revalidates on every Fetch.
+	TTL time.Duration
+}
+
+// CacheStats reports response cache activity.
+type CacheStats struct {
+	Hits        int64 `json:"hits"`        // Fetches answered from the cache, including after a 304
+	Misses      int64 `json:"misses"`      // Fetches that downloaded the body
+	Revalidated int64 `json:"revalidated"` // Hits confirmed by a 304 Not Modified
+	Entries     int   `json:"entries"`
+	Bytes       int64 `json:"bytes"`
+}
+
+// WithCache keeps the bodies returned by Fetch in an in-memory LRU cache
+// keyed by path. Cached entries are revalidated with If-None-Match, so an
+// unchanged object costs a 304 instead of a download. Uploads and deletes
+// made through the client purge the paths they touch.
+func WithCache(cfg CacheConfig) Option {
+	return func(c *Client) {
+		c.cache = newResponseCache(cfg)
+	}
+}
+
+// Fetch reads the whole object at remotePath into memory. With WithCache it
+// serves the cached body while it is fresh and revalidates it afterwards;
+// DownloadResult.Cached reports whether the body came from the cache.
+// Config.VerifyDownloads checks downloaded bodies against their ETag. An
+// object stored with a Content-Encoding is returned decoded.
+func (c *Client) Fetch(ctx context.Context, remotePath string) ([]byte, *DownloadResult, error) {
+	if remotePath == "" {
+		return nil, nil, fmt.Errorf("fetch: %w", ErrEmptyPath)
+	}
+	remotePath = normalizePath(remotePath)
+	key := strings.TrimPrefix(remotePath, "/")
+
+	entry, fresh := c.cache.lookup(key)
+	if fresh {
+		return entry.response()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.signer.PresignGet(remotePath, DefaultExpires), http.NoBody)
+	if err != nil {
+		return nil, nil, fmt.Errorf("create request: %w", err)
+	}
+	req.Header.Set("Accept-Encoding", acceptEncoding)
+	if entry != nil {
+		req.Header.Set("If-None-Match", entry.etagHeader)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, nil, fmt.Errorf("do request: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	switch {
+	case resp.StatusCode == http.StatusNotModified && entry != nil:
+		c.cache.revalidated(key, entry)
+		return entry.response()
+	case resp.StatusCode != http.StatusOK:
+		body, _ := io.ReadAll(resp.Body)
+		c.cache.purge(key)
+		return nil, nil, parseServerError(resp.StatusCode, body)
+	}
+
+	etagHeader := resp.Header.Get("ETag")
+	etag := strings.Trim(strings.TrimPrefix(etagHeader, "W/"), `"`)
+
+	body, err := responseContent(resp, etag, c.config.VerifyDownloads, false)
+	if err != nil {
+		return nil, nil, fmt.Errorf("fetch %s: %w", key, err)
+	}
+	content, err := io.ReadAll(body)
+	if err != nil {
+		return nil, nil, fmt.Errorf("fetch %s: %w", key, err)
+	}
+
+	result := DownloadResult{
+		RemotePath:      key,
+		ETag:            etag,
+		ContentType:     resp.Header.Get("Content-Type"),
+		ContentEncoding: storedEncoding(resp),
+		Size:            int64(len(content)),
+	}
+	c.cache.store(key, etagHeader, content, result)
+
+	return content, &result, nil
+}
+
+// Purge removes path from the response cache. It does nothing when the
+// client has no cache.
+func (c *Client) Purge(path string) {
+	c.cache.purge(strings.TrimPrefix(normalizePath(path), "/"))
+}
+
+// CacheStats returns the response cache counters, or zero values when the
+// client has no cache.
+func (c *Client) CacheStats() CacheStats {
+	return c.cache.stats()
+}
+
+// responseCache is an LRU of response bodies bounded by entry count and total
+// size. A nil *responseCache caches nothing, so callers need not check
+// whether caching is enabled.
+type responseCache struct {
+	maxEntries int
+	maxBytes   int64
+	ttl        time.Duration
+	now        func() time.Time
+
+	mu      sync.Mutex
+	lru     *list.List // front is most recently used
+	entries map[string]*list.Element
+	bytes   int64
+	counts  CacheStats
+}
+
+type cacheEntry struct {
+	key        string
+	etagHeader string // ETag as sent by the server, echoed in If-None-Match
+	body       []byte
+	result     DownloadResult
+	freshUntil time.Time
+}
+
+func newResponseCache(cfg CacheConfig) *responseCache {
+	if cfg.MaxEntries <= 0 {
+		cfg.MaxEntries = DefaultCacheMaxEntries
+	}
+	if cfg.MaxBytes <= 0 {
+		cfg.MaxBytes = DefaultCacheMaxBytes
+	}
+	return &responseCache{
+		maxEntries: cfg.MaxEntries,
+		maxBytes:   cfg.MaxBytes,
+		ttl:        cfg.TTL,
+		now:        time.Now,
+		lru:        list.New(),
+		entries:    make(map[string]*list.Element),
+	}
+}
+
+// response returns a copy of the cached body, so callers cannot modify the
+// cache.
+func (e *cacheEntry) response() ([]byte, *DownloadResult, error) {
+	result := e.result
+	result.Cached = true
+	return bytes.Clone(e.body), &result, nil
+}
+
+// lookup returns the entry for key and whether it may be served without
+// revalidation. A fresh entry counts as a hit.
+func (rc *responseCache) lookup(key string) (*cacheEntry, bool) {
+	if rc == nil {
+		return nil, false
+	}
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+
+	el, ok := rc.entries[key]
+	if !ok {
+		return nil, false
+	}
+	rc.lru.MoveToFront(el)
+	entry := el.Value.(*cacheEntry)
+	if rc.now().Before(entry.freshUntil) {
+		rc.counts.Hits++
+		return entry, true
+	}
+	return entry, false
+}
+
+// revalidated records a 304 for entry and restarts its TTL.
+func (rc *responseCache) revalidated(key string, entry *cacheEntry) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+
+	rc.counts.Hits++
+	rc.counts.Revalidated++
+	if el, ok := rc.entries[key]; ok && el.Value == entry {
+		entry.freshUntil = rc.now().Add(rc.ttl)
+	}
+}
+
+// store caches body under key, evicting least recently used entries to stay
+// within bounds. Responses without an ETag or larger than maxBytes are not
+// cached. Every call counts as a miss.
+func (rc *responseCache) store(key, etagHeader string, body []byte, result DownloadResult) {
+	if rc == nil {
+		return
+	}
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+
+	rc.counts.Misses++
+	rc.remove(key)
+	if etagHeader == "" || int64(len(body)) > rc.maxBytes {
+		return
+	}
+
+	entry := &cacheEntry{
+		key:        key,
+		etagHeader: etagHeader,
+		body:       bytes.Clone(body),
+		result:     result,
+		freshUntil: rc.now().Add(rc.ttl),
+	}
+	rc.entries[key] = rc.lru.PushFront(entry)
+	rc.bytes += int64(len(body))
+
+	for rc.lru.Len() > rc.maxEntries || rc.bytes > rc.maxBytes {
+		rc.remove(rc.lru.Back().Value.(*cacheEntry).key)
+	}
+}
+
+func (rc *responseCache) purge(key string) {
+	if rc == nil {
+		return
+	}
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	rc.remove(key)
+}
+
+// purgePrefix drops every key starting with prefix.
+func (rc *responseCache) purgePrefix(prefix string) {
+	if rc == nil {
+		return
+	}
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	for key := range rc.entries {
+		if strings.HasPrefix(key, prefix) {
+			rc.remove(key)
+		}
+	}
+}
+
+// remove drops key from the cache. The caller holds rc.mu.
+func (rc *responseCache) remove(key string) {
+	el, ok := rc.entries[key]
+	if !ok {
+		return
+	}
+	rc.lru.Remove(el)
+	delete(rc.entries, key)
+	rc.bytes -= int64(len(el.Value.(*cacheEntry).body))
+}
+
+func (rc *responseCache) stats() CacheStats {
+	if rc == nil {
+		return CacheStats{}
+	}
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+
+	stats := rc.counts
+	stats.Entries = rc.lru.Len()
+	stats.Bytes = rc.bytes
+	return stats
+}
@@ -0,0 +1,231 @@
+package clientcli_test
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sagarc03/stowry/clientcli"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// objectServer serves objects from memory with ETags and 304 responses, and
+// counts full and conditional GETs.
+type objectServer struct {
+	mu          sync.Mutex
+	objects     map[string]string
+	downloads   int
+	notModified int
+}
+
+func (s *objectServer) set(path, content string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.objects[path] = content
+}
+
+func (s *objectServer) counts() (downloads, notModified int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.downloads, s.notModified
+}
+
+func (s *objectServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	path := strings.TrimPrefix(r.URL.Path, "/")
+	switch r.Method {
+	case http.MethodDelete:
+		delete(s.objects, path)
+		w.WriteHeader(http.StatusNoContent)
+		return
+	case http.MethodGet:
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	content, ok := s.objects[path]
+	if !ok {
+		http.Error(w, `{"error":"not_found"}`, http.StatusNotFound)
+		return
+	}
+	sum := sha256.Sum256([]byte(content))
+	etag := `"` + hex.EncodeToString(sum[:]) + `"`
+	if r.Header.Get("If-None-Match") == etag {
+		s.notModified++
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	s.downloads++
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write([]byte(content))
+}
+
+func newCachingClient(t *testing.T, cfg clientcli.CacheConfig) (*clientcli.Client, *objectServer) {
+	t.Helper()
+	objects := &objectServer{objects: map[string]string{}}
+	server := httptest.NewServer(objects)
+	t.Cleanup(server.Close)
+
+	client, err := clientcli.New(&clientcli.Config{Endpoint: server.URL, AccessKey: "k", SecretKey: "s"}, clientcli.WithCache(cfg))
+	require.NoError(t, err)
+	return client, objects
+}
+
+func TestClient_Fetch_Cache(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("fresh entries are served without a request", func(t *testing.T) {
+		client, objects := newCachingClient(t, clientcli.CacheConfig{TTL: time.Hour})
+		objects.set("config/app.json", `{"v":1}`)
+
+		for range 3 {
+			body, result, err := client.Fetch(ctx, "config/app.json")
+			require.NoError(t, err)
+			assert.Equal(t, `{"v":1}`, string(body))
+			assert.Equal(t, "config/app.json", result.RemotePath)
+		}
+
+		downloads, notModified := objects.counts()
+		assert.Equal(t, 1, downloads)
+		assert.Zero(t, notModified)
+		assert.Equal(t, clientcli.CacheStats{Hits: 2, Misses: 1, Entries: 1, Bytes: 7}, client.CacheStats())
+	})
+
+	t.Run("expired entries are revalidated", func(t *testing.T) {
+		client, objects := newCachingClient(t, clientcli.CacheConfig{})
+		objects.set("config/app.json", `{"v":1}`)
+
+		_, first, err := client.Fetch(ctx, "config/app.json")
+		require.NoError(t, err)
+		assert.False(t, first.Cached)
+
+		body, second, err := client.Fetch(ctx, "config/app.json")
+		require.NoError(t, err)
+		assert.Equal(t, `{"v":1}`, string(body))
+		assert.True(t, second.Cached)
+		assert.Equal(t, first.ETag, second.ETag)
+
+		objects.set("config/app.json", `{"v":2}`)
+		body, third, err := client.Fetch(ctx, "config/app.json")
+		require.NoError(t, err)
+		assert.Equal(t, `{"v":2}`, string(body))
+		assert.False(t, third.Cached)
+
+		downloads, notModified := objects.counts()
+		assert.Equal(t, 2, downloads)
+		assert.Equal(t, 1, notModified)
+		stats := client.CacheStats()
+		assert.Equal(t, int64(1), stats.Hits)
+		assert.Equal(t, int64(1), stats.Revalidated)
+		assert.Equal(t, int64(2), stats.Misses)
+	})
+
+	t.Run("purge forces a download", func(t *testing.T) {
+		client, objects := newCachingClient(t, clientcli.CacheConfig{TTL: time.Hour})
+		objects.set("a.txt", "a")
+
+		_, _, err := client.Fetch(ctx, "a.txt")
+		require.NoError(t, err)
+		client.Purge("/a.txt")
+		_, result, err := client.Fetch(ctx, "a.txt")
+		require.NoError(t, err)
+
+		assert.False(t, result.Cached)
+		downloads, _ := objects.counts()
+		assert.Equal(t, 2, downloads)
+	})
+
+	t.Run("delete purges the path", func(t *testing.T) {
+		client, objects := newCachingClient(t, clientcli.CacheConfig{TTL: time.Hour})
+		objects.set("a.txt", "a")
+
+		_, _, err := client.Fetch(ctx, "a.txt")
+		require.NoError(t, err)
+		_, err = client.Delete(ctx, clientcli.DeleteOptions{Paths: []string{"a.txt"}})
+		require.NoError(t, err)
+
+		_, _, err = client.Fetch(ctx, "a.txt")
+		require.ErrorIs(t, err, clientcli.ErrNotFound)
+		assert.Zero(t, client.CacheStats().Entries)
+	})
+
+	t.Run("least recently used entries are evicted", func(t *testing.T) {
+		client, objects := newCachingClient(t, clientcli.CacheConfig{MaxEntries: 2, TTL: time.Hour})
+		for _, path := range []string{"a", "b", "c"} {
+			objects.set(path, path)
+		}
+
+		for _, path := range []string{"a", "b", "a", "c"} {
+			_, _, err := client.Fetch(ctx, path)
+			require.NoError(t, err)
+		}
+		_, a, err := client.Fetch(ctx, "a")
+		require.NoError(t, err)
+		_, b, err := client.Fetch(ctx, "b")
+		require.NoError(t, err)
+
+		assert.True(t, a.Cached)
+		assert.False(t, b.Cached, "b was least recently used when c was added")
+		assert.Equal(t, 2, client.CacheStats().Entries)
+	})
+
+	t.Run("max bytes bounds the cache", func(t *testing.T) {
+		client, objects := newCachingClient(t, clientcli.CacheConfig{MaxBytes: 10, TTL: time.Hour})
+		objects.set("small", "12345")
+		objects.set("large", "12345678901")
+
+		for _, path := range []string{"small", "large", "large"} {
+			_, _, err := client.Fetch(ctx, path)
+			require.NoError(t, err)
+		}
+
+		downloads, _ := objects.counts()
+		assert.Equal(t, 3, downloads, "a body over max bytes is never cached")
+		assert.Equal(t, int64(5), client.CacheStats().Bytes)
+	})
+
+	t.Run("cached bodies are copies", func(t *testing.T) {
+		client, objects := newCachingClient(t, clientcli.CacheConfig{TTL: time.Hour})
+		objects.set("a.txt", "abc")
+
+		body, _, err := client.Fetch(ctx, "a.txt")
+		require.NoError(t, err)
+		body[0] = 'x'
+
+		body, _, err = client.Fetch(ctx, "a.txt")
+		require.NoError(t, err)
+		assert.Equal(t, "abc", string(body))
+	})
+}
+
+func TestClient_Fetch_WithoutCache(t *testing.T) {
+	objects := &objectServer{objects: map[string]string{"a.txt": "abc"}}
+	server := httptest.NewServer(objects)
+	defer server.Close()
+
+	client, err := clientcli.New(&clientcli.Config{Endpoint: server.URL, AccessKey: "k", SecretKey: "s"})
+	require.NoError(t, err)
+
+	for range 2 {
+		body, result, err := client.Fetch(context.Background(), "a.txt")
+		require.NoError(t, err)
+		assert.Equal(t, "abc", string(body))
+		assert.False(t, result.Cached)
+	}
+
+	downloads, _ := objects.counts()
+	assert.Equal(t, 2, downloads)
+	assert.Equal(t, clientcli.CacheStats{}, client.CacheStats())
+	client.Purge("a.txt")
+}
@@ -0,0 +1,184 @@
+package clientcli
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+)
+
+// capabilitiesPath mirrors the path of the server's capability document.
+const capabilitiesPath = "/.well-known/stowry"
+
+// DefaultCapabilitiesTTL is how long a capability document is reused before
+// the server is asked again.
+const DefaultCapabilitiesTTL = 5 * time.Minute
+
+// Capabilities mirrors the server's capability document: what the server
+// accepts, so the client can pick its requests instead of trying them.
+type Capabilities struct {
+	Version            string              `json:"version,omitempty"`
+	Mode               string              `json:"mode"`
+	Limits             CapabilityLimits    `json:"limits"`
+	PathLimits         *PathLimits         `json:"path_limits,omitempty"`
+	Features           []string            `json:"features"`
+	ListFormats        []string            `json:"list_formats"`
+	ConditionalHeaders map[string][]string `json:"conditional_headers,omitempty"`
+	Compression        []string            `json:"compression"`
+	IfMatchComparison  string              `json:"if_match_comparison,omitempty"`
+	ListDisabled       bool                `json:"list_disabled,omitempty"`
+
+	// Offline is set when the server could not be asked, because it
+	// predates the document or did not answer, and the conservative
+	// defaults of DefaultCapabilities stand in for it. Offline capabilities
+	// rule nothing out: the client tries each feature and falls back as it
+	// would without a document.
+	Offline bool `json:"offline,omitempty"`
+	// FetchedAt is when the document was fetched, or the defaults chosen.
+	FetchedAt time.Time `json:"fetched_at"`
+}
+
+// CapabilityLimits mirrors the request size limits of the capability
+// document. A zero limit means there is none.
+type CapabilityLimits struct {
+	MaxUploadSize        int64 `json:"max_upload_size"`
+	MaxListLimit         int   `json:"max_list_limit"`
+	MaxExportRows        int   `json:"max_export_rows"`
+	MaxStatPaths         int   `json:"max_stat_paths"`
+	MaxDeletePaths       int   `json:"max_delete_paths"`
+	MaxFilenameBytes     int   `json:"max_filename_bytes"`
+	MaxContentTypeLength int   `json:"max_content_type_length"`
+}
+
+// PathLimits mirrors the limits the server checks object paths against.
+type PathLimits struct {
+	MaxSegmentBytes int `json:"max_segment_bytes"`
+	MaxKeyBytes     int `json:"max_key_bytes"`
+	MaxDepth        int `json:"max_depth"`
+}
+
+// DefaultCapabilities returns the conservative capabilities assumed for a
+// server that could not be asked: no optional features, JSON listing only,
+// and the batch sizes every server accepts.
+func DefaultCapabilities() Capabilities {
+	return Capabilities{
+		Limits: CapabilityLimits{
+			MaxListLimit: 1000,
+			MaxStatPaths: MaxStatBatch,
+		},
+		Features:    []string{},
+		ListFormats: []string{"json"},
+		Compression: []string{},
+		Offline:     true,
+		FetchedAt:   time.Now(),
+	}
+}
+
+// Supports reports whether the server lists feature, such as "stage",
+// "delta", "stat" or "range", as enabled.
+func (c Capabilities) Supports(feature string) bool {
+	return slices.Contains(c.Features, feature)
+}
+
+// rulesOut reports whether the document says the server lacks feature.
+// Offline capabilities rule nothing out.
+func (c Capabilities) rulesOut(feature string) bool {
+	return !c.Offline && !c.Supports(feature)
+}
+
+// capabilityCache holds the documents fetched in this process, per
+// endpoint, so every Client for a server shares them.
+var capabilityCache = struct {
+	sync.Mutex
+	entries map[string]Capabilities
+}{entries: map[string]Capabilities{}}
+
+// WithCapabilitiesTTL sets how long a capability document is reused. 0 uses
+// DefaultCapabilitiesTTL; a negative TTL asks the server every time.
+func WithCapabilitiesTTL(ttl time.Duration) Option {
+	return func(c *Client) {
+		c.capabilitiesTTL = ttl
+	}
+}
+
+// Capabilities returns the server's capability document, fetched from
+// /.well-known/stowry and cached per endpoint for the capabilities TTL.
+//
+// It always returns capabilities: when the server cannot be asked, it
+// returns DefaultCapabilities with Offline set, together with the reason.
+// The defaults are cached like a fetched document, so a server without the
+// document is not asked again before the TTL passes.
+func (c *Client) Capabilities(ctx context.Context) (Capabilities, error) {
+	ttl := c.capabilitiesTTL
+	if ttl == 0 {
+		ttl = DefaultCapabilitiesTTL
+	}
+
+	capabilityCache.Lock()
+	cached, ok := capabilityCache.entries[c.config.Endpoint]
+	capabilityCache.Unlock()
+	if ok && time.Since(cached.FetchedAt) < ttl {
+		return cached, nil
+	}
+
+	caps, err := c.fetchCapabilities(ctx)
+	if err != nil {
+		caps = DefaultCapabilities()
+		err = fmt.Errorf("capabilities: %w", err)
+	}
+
+	capabilityCache.Lock()
+	capabilityCache.entries[c.config.Endpoint] = caps
+	capabilityCache.Unlock()
+	return caps, err
+}
+
+// capabilities returns the capabilities that gate optional features,
+// falling back to the defaults silently.
+func (c *Client) capabilities(ctx context.Context) Capabilities {
+	caps, _ := c.Capabilities(ctx)
+	return caps
+}
+
+func (c *Client) fetchCapabilities(ctx context.Context) (Capabilities, error) {
+	presignURL := c.presign(http.MethodGet, capabilitiesPath, nil, DefaultExpires)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, presignURL, http.NoBody)
+	if err != nil {
+		return Capabilities{}, fmt.Errorf("create request: %w", err)
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return Capabilities{}, fmt.Errorf("do request: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return Capabilities{}, fmt.Errorf("read response: %w", err)
+	}
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound, http.StatusMethodNotAllowed:
+		return Capabilities{}, ErrCapabilitiesUnsupported
+	default:
+		return Capabilities{}, parseServerError(resp.StatusCode, body)
+	}
+
+	var caps Capabilities
+	if err := json.Unmarshal(body, &caps); err != nil {
+		return Capabilities{}, fmt.Errorf("parse response: %w", err)
+	}
+	// A static site may serve an unrelated object at the path
+	if caps.Mode == "" {
+		return Capabilities{}, ErrCapabilitiesUnsupported
+	}
+	caps.Offline = false
+	caps.FetchedAt = time.Now()
+	return caps, nil
+}
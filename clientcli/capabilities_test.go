@@ -0,0 +1,289 @@
+package clientcli_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sagarc03/stowry/clientcli"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// withoutCapabilities makes next answer like a server that predates the
+// capability document.
+func withoutCapabilities(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/.well-known/stowry" {
+			http.NotFound(w, r)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// capabilityServer serves document at /.well-known/stowry and everything
+// else with next, recording each request as "METHOD /path".
+type capabilityServer struct {
+	document string
+	next     http.Handler
+
+	mu       sync.Mutex
+	requests []string
+}
+
+func (s *capabilityServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	s.requests = append(s.requests, r.Method+" "+r.URL.Path)
+	s.mu.Unlock()
+
+	if r.URL.Path == "/.well-known/stowry" {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(s.document))
+		return
+	}
+	if s.next == nil {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	s.next.ServeHTTP(w, r)
+}
+
+func (s *capabilityServer) recorded() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.requests...)
+}
+
+func newCapabilityClient(t *testing.T, s http.Handler, opts ...clientcli.Option) *clientcli.Client {
+	t.Helper()
+	server := httptest.NewServer(s)
+	t.Cleanup(server.Close)
+	client, err := clientcli.New(&clientcli.Config{Endpoint: server.URL}, opts...)
+	require.NoError(t, err)
+	return client
+}
+
+const storeCapabilities = `{
+	"version": "1.4.0",
+	"mode": "store",
+	"limits": {"max_upload_size": 1048576, "max_list_limit": 1000, "max_export_rows": 0, "max_stat_paths": 1000, "max_filename_bytes": 255, "max_content_type_length": 256},
+	"path_limits": {"max_segment_bytes": 255, "max_key_bytes": 1024, "max_depth": 64},
+	"features": ["range", "stage", "delta", "stat"],
+	"list_formats": ["json", "csv"],
+	"conditional_headers": {"GET": ["If-None-Match"], "PUT": ["If-Match"]},
+	"compression": ["gzip"],
+	"if_match_comparison": "strong"
+}`
+
+func TestClient_Capabilities(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("fetches and caches the document", func(t *testing.T) {
+		fake := &capabilityServer{document: storeCapabilities}
+		client := newCapabilityClient(t, fake)
+
+		caps, err := client.Capabilities(ctx)
+		require.NoError(t, err)
+		assert.False(t, caps.Offline)
+		assert.Equal(t, "1.4.0", caps.Version)
+		assert.Equal(t, "store", caps.Mode)
+		assert.Equal(t, int64(1<<20), caps.Limits.MaxUploadSize)
+		assert.Equal(t, 1024, caps.PathLimits.MaxKeyBytes)
+		assert.True(t, caps.Supports("stage"))
+		assert.False(t, caps.Supports("watch"))
+		assert.Equal(t, []string{"json", "csv"}, caps.ListFormats)
+
+		_, err = client.Capabilities(ctx)
+		require.NoError(t, err)
+		assert.Len(t, fake.recorded(), 1, "the second call is answered from the cache")
+	})
+
+	t.Run("expired documents are fetched again", func(t *testing.T) {
+		fake := &capabilityServer{document: storeCapabilities}
+		client := newCapabilityClient(t, fake, clientcli.WithCapabilitiesTTL(-1))
+
+		for range 2 {
+			_, err := client.Capabilities(ctx)
+			require.NoError(t, err)
+		}
+		assert.Len(t, fake.recorded(), 2)
+	})
+
+	t.Run("server without the document", func(t *testing.T) {
+		var requests int
+		client := newCapabilityClient(t, withoutCapabilities(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			requests++
+		})))
+
+		caps, err := client.Capabilities(ctx)
+		require.ErrorIs(t, err, clientcli.ErrCapabilitiesUnsupported)
+		assert.True(t, caps.Offline)
+		assert.Equal(t, clientcli.MaxStatBatch, caps.Limits.MaxStatPaths)
+		assert.Equal(t, []string{"json"}, caps.ListFormats)
+		assert.Empty(t, caps.Features)
+
+		caps, err = client.Capabilities(ctx)
+		require.NoError(t, err, "the defaults are cached")
+		assert.True(t, caps.Offline)
+		assert.Zero(t, requests)
+	})
+
+	t.Run("unrelated object at the path", func(t *testing.T) {
+		client := newCapabilityClient(t, &capabilityServer{document: `{"title": "not a capability document"}`})
+
+		caps, err := client.Capabilities(ctx)
+		require.ErrorIs(t, err, clientcli.ErrCapabilitiesUnsupported)
+		assert.True(t, caps.Offline)
+	})
+
+	t.Run("unreachable server", func(t *testing.T) {
+		server := httptest.NewServer(http.NotFoundHandler())
+		server.Close()
+		client, err := clientcli.New(&clientcli.Config{Endpoint: server.URL})
+		require.NoError(t, err)
+
+		caps, err := client.Capabilities(ctx)
+		require.Error(t, err)
+		assert.True(t, caps.Offline)
+	})
+}
+
+func TestClient_CapabilityGating(t *testing.T) {
+	ctx := context.Background()
+	// without returns the store document minus the features named
+	without := func(features ...string) string {
+		var doc map[string]any
+		require.NoError(t, json.Unmarshal([]byte(storeCapabilities), &doc))
+		var kept []string
+		for _, f := range doc["features"].([]any) {
+			drop := false
+			for _, name := range features {
+				drop = drop || f == name
+			}
+			if !drop {
+				kept = append(kept, f.(string))
+			}
+		}
+		doc["features"] = kept
+		out, err := json.Marshal(doc)
+		require.NoError(t, err)
+		return string(out)
+	}
+
+	t.Run("atomic upload without staging is a plain upload", func(t *testing.T) {
+		fake := &capabilityServer{document: without("stage"), next: uploadEchoHandler(t)}
+		client := newCapabilityClient(t, fake)
+
+		dir := writeStageFiles(t, "a.txt")
+		_, err := client.Upload(ctx, clientcli.UploadOptions{LocalPath: filepath.Join(dir, "a.txt"), RemotePath: "a.txt", Atomic: true})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"GET /.well-known/stowry", "PUT /a.txt"}, fake.recorded())
+	})
+
+	t.Run("atomic upload with staging", func(t *testing.T) {
+		fake := &capabilityServer{document: storeCapabilities, next: &stageServer{}}
+		client := newCapabilityClient(t, fake)
+
+		dir := writeStageFiles(t, "a.txt")
+		_, err := client.Upload(ctx, clientcli.UploadOptions{LocalPath: filepath.Join(dir, "a.txt"), RemotePath: "a.txt", Atomic: true})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"GET /.well-known/stowry", "POST /stage", "PUT /a.txt", "POST /stage/" + testStageID + "/commit"}, fake.recorded())
+	})
+
+	t.Run("delta upload without delta skips the probe", func(t *testing.T) {
+		fake := &capabilityServer{document: without("delta"), next: uploadEchoHandler(t)}
+		client := newCapabilityClient(t, fake)
+
+		localPath := filepath.Join(t.TempDir(), "app.db")
+		require.NoError(t, os.WriteFile(localPath, []byte("content"), 0o600))
+		_, err := client.Upload(ctx, clientcli.UploadOptions{LocalPath: localPath, RemotePath: "app.db", Delta: true})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"GET /.well-known/stowry", "PUT /app.db"}, fake.recorded())
+	})
+
+	t.Run("bulk stat without stat", func(t *testing.T) {
+		fake := &capabilityServer{document: without("stat")}
+		client := newCapabilityClient(t, fake)
+
+		_, err := client.StatMany(ctx, []string{"a.txt"})
+		require.ErrorIs(t, err, clientcli.ErrStatUnsupported)
+		assert.Equal(t, []string{"GET /.well-known/stowry"}, fake.recorded())
+	})
+
+	t.Run("bulk stat batches by the server's limit", func(t *testing.T) {
+		var batches []int
+		doc := bytes.Replace([]byte(storeCapabilities), []byte(`"max_stat_paths": 1000`), []byte(`"max_stat_paths": 2`), 1)
+		fake := &capabilityServer{document: string(doc), next: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var paths []string
+			_ = json.NewDecoder(r.Body).Decode(&paths)
+			batches = append(batches, len(paths))
+			_ = json.NewEncoder(w).Encode(map[string]any{"objects": map[string]any{}})
+		})}
+		client := newCapabilityClient(t, fake)
+
+		found, err := client.StatMany(ctx, []string{"a", "b", "c"})
+		require.NoError(t, err)
+		assert.Len(t, found, 3)
+		assert.Equal(t, []int{2, 1}, batches)
+	})
+
+	t.Run("resume without range starts over", func(t *testing.T) {
+		var ranges []string
+		fake := &capabilityServer{document: without("range"), next: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ranges = append(ranges, r.Header.Get("Range"))
+			w.Header().Set("ETag", `"etag"`)
+			_, _ = w.Write([]byte("whole object"))
+		})}
+		client := newCapabilityClient(t, fake)
+
+		localPath := filepath.Join(t.TempDir(), "object.bin")
+		require.NoError(t, os.WriteFile(localPath+clientcli.PartialSuffix, []byte("whole"), 0o600))
+		require.NoError(t, os.WriteFile(localPath+clientcli.PartialETagSuffix, []byte("etag"), 0o600))
+
+		result, _, err := client.Download(ctx, clientcli.DownloadOptions{RemotePath: "object.bin", LocalPath: localPath, Resume: true})
+		require.NoError(t, err)
+		assert.Zero(t, result.ResumedFrom)
+		assert.Equal(t, []string{""}, ranges, "no Range is sent")
+		got, err := os.ReadFile(localPath)
+		require.NoError(t, err)
+		assert.Equal(t, "whole object", string(got))
+	})
+
+	t.Run("csv export without csv listing", func(t *testing.T) {
+		doc := bytes.Replace([]byte(storeCapabilities), []byte(`"list_formats": ["json", "csv"]`), []byte(`"list_formats": ["json"]`), 1)
+		fake := &capabilityServer{document: string(doc)}
+		client := newCapabilityClient(t, fake)
+
+		err := client.ExportCSV(ctx, clientcli.ListOptions{}, new(bytes.Buffer))
+		require.ErrorIs(t, err, clientcli.ErrCSVExportUnsupported)
+		assert.Len(t, fake.recorded(), 1)
+	})
+
+	t.Run("csv export with listing disabled", func(t *testing.T) {
+		doc := bytes.Replace([]byte(storeCapabilities), []byte(`"list_formats": ["json", "csv"]`), []byte(`"list_formats": [], "list_disabled": true`), 1)
+		fake := &capabilityServer{document: string(doc)}
+		client := newCapabilityClient(t, fake)
+
+		err := client.ExportCSV(ctx, clientcli.ListOptions{}, new(bytes.Buffer))
+		require.ErrorIs(t, err, clientcli.ErrListDisabled)
+		assert.Len(t, fake.recorded(), 1)
+	})
+
+	t.Run("offline defaults keep trying", func(t *testing.T) {
+		fake := &stageServer{}
+		client := newCapabilityClient(t, withoutCapabilities(fake), clientcli.WithCapabilitiesTTL(time.Hour))
+
+		dir := writeStageFiles(t, "a.txt")
+		_, err := client.Upload(ctx, clientcli.UploadOptions{LocalPath: filepath.Join(dir, "a.txt"), RemotePath: "a.txt", Atomic: true})
+		require.NoError(t, err)
+		assert.Equal(t, "POST /stage", fake.requests[0])
+	})
+}
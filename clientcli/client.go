@@ -2,11 +2,16 @@ package clientcli
 
 import (
 	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"hash"
 	"io"
 	"io/fs"
+	"maps"
 	"mime"
 	"net/http"
 	"net/url"
@@ -14,9 +19,14 @@ import (
 	"path/filepath"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/sagarc03/stowry-go"
+	"github.com/sagarc03/stowry/progress"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	"golang.org/x/sync/errgroup"
 )
 
 const (
@@ -27,17 +37,25 @@ const (
 	DefaultExpires = 900
 )
 
+// uploadETagHeader mirrors the header carrying the server-computed SHA-256 of
+// an upload.
+const uploadETagHeader = "X-Stowry-ETag"
+
 // Client performs operations against a Stowry server.
 type Client struct {
 	config     *Config
 	httpClient *http.Client
 	signer     *stowry.Client
+	cache      *responseCache // nil unless WithCache
+
+	capabilitiesTTL time.Duration // 0 uses DefaultCapabilitiesTTL
 }
 
 // Option configures a Client.
 type Option func(*Client)
 
-// WithHTTPClient sets a custom HTTP client.
+// WithHTTPClient sets a custom HTTP client. Its transport is used as is, so
+// the TLS settings of the Config do not apply to it.
 func WithHTTPClient(client *http.Client) Option {
 	return func(c *Client) {
 		c.httpClient = client
@@ -51,25 +69,76 @@ func WithTimeout(timeout time.Duration) Option {
 	}
 }
 
-// New creates a new Client with the given config and options.
+// WithTracePropagation adds W3C traceparent headers to outgoing requests
+// using the global OpenTelemetry propagator, so server spans join the trace
+// in each request's context. It has no effect until the caller installs a
+// propagator and tracer provider. Apply it after WithHTTPClient.
+func WithTracePropagation() Option {
+	return func(c *Client) {
+		base := c.httpClient.Transport
+		if base == nil {
+			base = http.DefaultTransport
+		}
+		client := *c.httpClient
+		client.Transport = propagatingTransport{base: base}
+		c.httpClient = &client
+	}
+}
+
+// propagatingTransport injects trace context headers into each request.
+type propagatingTransport struct {
+	base http.RoundTripper
+}
+
+func (t propagatingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	otel.GetTextMapPropagator().Inject(req.Context(), propagation.HeaderCarrier(req.Header))
+	return t.base.RoundTrip(req)
+}
+
+// New creates a new Client with the given config and options. Requests use
+// the transport from NewTransport, which fails when the TLS files of the
+// config cannot be loaded.
 func New(cfg *Config, opts ...Option) (*Client, error) {
 	if cfg == nil {
 		return nil, ErrConfigRequired
 	}
 
+	transport, err := NewTransport(cfg)
+	if err != nil {
+		return nil, err
+	}
+
 	// Apply defaults
 	cfg = cfg.WithDefaults()
 
 	// Normalize endpoint URL (remove trailing slash)
 	endpoint := strings.TrimSuffix(cfg.Endpoint, "/")
 
+	timeout := DefaultTimeout
+	if cfg.Timeout > 0 {
+		timeout = cfg.Timeout
+	}
+
 	c := &Client{
 		config: &Config{
-			Endpoint:  endpoint,
-			AccessKey: cfg.AccessKey,
-			SecretKey: cfg.SecretKey,
+			Endpoint:          endpoint,
+			AccessKey:         cfg.AccessKey,
+			SecretKey:         cfg.SecretKey,
+			DefaultPrefix:     cfg.DefaultPrefix,
+			UploadConcurrency: cfg.UploadConcurrency,
+			VerifyDownloads:   cfg.VerifyDownloads,
+			Timeout:           timeout,
+			ContentTypes:      normalizeContentTypes(cfg.ContentTypes),
+
+			CACertFile:         cfg.CACertFile,
+			ClientCertFile:     cfg.ClientCertFile,
+			ClientKeyFile:      cfg.ClientKeyFile,
+			InsecureSkipVerify: cfg.InsecureSkipVerify,
+
+			Regions: maps.Clone(cfg.Regions),
 		},
-		httpClient: &http.Client{Timeout: DefaultTimeout},
+		httpClient: &http.Client{Timeout: timeout, Transport: transport},
 		signer:     stowry.NewClient(endpoint, cfg.AccessKey, cfg.SecretKey),
 	}
 
@@ -77,28 +146,69 @@ func New(cfg *Config, opts ...Option) (*Client, error) {
 	for _, opt := range opts {
 		opt(c)
 	}
+	c.httpClient = withRegions(c.httpClient, endpoint, c.config.Regions)
 
 	return c, nil
 }
 
 // Upload uploads file(s) to the server.
 // For recursive uploads, walks directory and preserves relative paths.
+// With opts.PathTemplate, each file's remote path is the expanded template;
+// UploadResult.RemotePath reports it.
 func (c *Client) Upload(ctx context.Context, opts UploadOptions) ([]UploadResult, error) {
 	if opts.LocalPath == "" {
 		return nil, fmt.Errorf("upload: %w", ErrEmptyPath)
 	}
+
+	var tmpl *pathTemplate
+	if opts.PathTemplate != "" {
+		var err error
+		if tmpl, err = parsePathTemplate(opts.PathTemplate); err != nil {
+			return nil, fmt.Errorf("upload: %w", err)
+		}
+	}
+	filter, err := NewPathFilter(opts.Filter)
+	if err != nil {
+		return nil, fmt.Errorf("upload: %w", err)
+	}
+	opts.filter = filter
+	// One timestamp per call, so a recursive upload lands under one date
+	now := time.Now().UTC()
+
+	if opts.Atomic {
+		return c.uploadAtomic(ctx, opts, tmpl, now)
+	}
+	return c.upload(ctx, opts, tmpl, now)
+}
+
+// upload runs a single or recursive upload with a parsed template.
+func (c *Client) upload(ctx context.Context, opts UploadOptions, tmpl *pathTemplate, now time.Time) ([]UploadResult, error) {
 	if opts.Recursive {
-		return c.uploadRecursive(ctx, opts)
+		return c.uploadRecursive(ctx, opts, tmpl, now)
 	}
-	result, err := c.uploadSingle(ctx, opts.LocalPath, opts.RemotePath, opts.ContentType)
+	if tmpl != nil {
+		remotePath, err := tmpl.remotePath(opts.LocalPath, filepath.Base(opts.LocalPath), now)
+		if err != nil {
+			return nil, err
+		}
+		opts.RemotePath = remotePath
+	}
+	result, err := c.uploadSingle(ctx, opts)
 	if err != nil {
 		return nil, err
 	}
 	return []UploadResult{result}, nil
 }
 
-// uploadRecursive walks a directory and uploads all files.
-func (c *Client) uploadRecursive(ctx context.Context, opts UploadOptions) ([]UploadResult, error) {
+// uploadRecursive walks a directory and uploads all files, running up to
+// opts.Concurrency uploads at once (falling back to the config's
+// UploadConcurrency, then 1). Results keep walk order. A non-nil tmpl
+// names each file instead of opts.RemotePath.
+//
+// When ctx is cancelled, or with opts.FailFast a file fails, no further
+// uploads start and the results so far are returned with the error; the
+// files FailFast cancels are Skipped with SkipReasonAborted.
+func (c *Client) uploadRecursive(ctx context.Context, opts UploadOptions, tmpl *pathTemplate, now time.Time) ([]UploadResult, error) {
 	info, err := os.Stat(opts.LocalPath)
 	if err != nil {
 		return nil, fmt.Errorf("stat local path: %w", err)
@@ -106,36 +216,66 @@ func (c *Client) uploadRecursive(ctx context.Context, opts UploadOptions) ([]Upl
 
 	if !info.IsDir() {
 		// Not a directory, just upload single file
-		result, uploadErr := c.uploadSingle(ctx, opts.LocalPath, opts.RemotePath, opts.ContentType)
+		if tmpl != nil {
+			remotePath, tmplErr := tmpl.remotePath(opts.LocalPath, filepath.Base(opts.LocalPath), now)
+			if tmplErr != nil {
+				return nil, tmplErr
+			}
+			opts.RemotePath = remotePath
+		}
+		result, uploadErr := c.uploadSingle(ctx, opts)
 		if uploadErr != nil {
 			return nil, uploadErr
 		}
 		return []UploadResult{result}, nil
 	}
 
-	var results []UploadResult
+	concurrency := opts.Concurrency
+	if concurrency <= 0 {
+		concurrency = max(c.config.UploadConcurrency, 1)
+	}
+
+	var g errgroup.Group
+	g.SetLimit(concurrency)
+
+	// With FailFast the first failure cancels the uploads still running and
+	// stops the walk from scheduling more
+	uploadCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	var (
+		abortOnce sync.Once
+		abortErr  error
+	)
+
+	tracker := progress.New("upload", opts.Progress)
+	defer tracker.Finish()
+
+	// Each upload writes only its own slot, so the walk can keep appending
+	var slots []*UploadResult
 	baseDir := opts.LocalPath
 	remotePrefix := strings.TrimSuffix(opts.RemotePath, "/")
 
 	walkErr := filepath.WalkDir(baseDir, func(path string, d fs.DirEntry, fileErr error) error {
 		if fileErr != nil {
+			// Workspaces cleaned while the upload runs lose whole directories
+			if !opts.Strict && path != baseDir && errors.Is(fileErr, fs.ErrNotExist) {
+				return nil
+			}
 			return fileErr
 		}
 
 		// Check context cancellation
-		if ctxErr := ctx.Err(); ctxErr != nil {
+		if ctxErr := uploadCtx.Err(); ctxErr != nil {
 			return ctxErr
 		}
 
-		// Skip directories
-		if d.IsDir() {
-			return nil
-		}
-
 		// Calculate relative path
 		relPath, relErr := filepath.Rel(baseDir, path)
 		if relErr != nil {
-			results = append(results, UploadResult{
+			if d.IsDir() {
+				return nil
+			}
+			slots = append(slots, &UploadResult{
 				LocalPath: path,
 				Err:       fmt.Errorf("calculate relative path: %w", relErr),
 			})
@@ -144,29 +284,112 @@ func (c *Client) uploadRecursive(ctx context.Context, opts UploadOptions) ([]Upl
 
 		// Convert to forward slashes for remote path
 		relPath = filepath.ToSlash(relPath)
+
+		if d.IsDir() {
+			if relPath != "." && opts.filter.SkipDir(relPath) {
+				if opts.Skipped != nil {
+					opts.Skipped(relPath + "/")
+				}
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !opts.filter.Match(relPath) {
+			if opts.Skipped != nil {
+				opts.Skipped(relPath)
+			}
+			return nil
+		}
+
 		remotePath := remotePrefix + "/" + relPath
 
-		result, uploadErr := c.uploadSingle(ctx, path, remotePath, "")
-		if uploadErr != nil {
-			result = UploadResult{
-				LocalPath:  path,
-				RemotePath: remotePath,
-				Err:        uploadErr,
+		slot := &UploadResult{LocalPath: path, RemotePath: remotePath}
+		slots = append(slots, slot)
+
+		fileOpts := opts
+		fileOpts.LocalPath = path
+		fileOpts.RemotePath = remotePath
+		fileOpts.ContentType = ""
+
+		// A file removed since the walk found it is skipped unless Strict,
+		// as are the files FailFast cancels
+		fail := func(err error) {
+			if !opts.Strict && errors.Is(err, fs.ErrNotExist) {
+				slot.Skipped, slot.SkipReason = true, SkipReasonRemoved
+				tracker.Add(slot.RemotePath, 0)
+				return
+			}
+			if uploadCtx.Err() != nil && ctx.Err() == nil {
+				slot.Skipped, slot.SkipReason = true, SkipReasonAborted
+				tracker.Add(slot.RemotePath, 0)
+				return
+			}
+			slot.Err = err
+			tracker.Fail(slot.RemotePath)
+			if opts.FailFast {
+				abortOnce.Do(func() {
+					abortErr = fmt.Errorf("%w: %s: %w", ErrUploadAborted, slot.RemotePath, err)
+					cancel()
+				})
 			}
 		}
-		results = append(results, result)
+
+		// Blocks while the limit is reached, bounding the walk as well
+		g.Go(func() error {
+			if ctxErr := uploadCtx.Err(); ctxErr != nil {
+				fail(ctxErr)
+				return nil
+			}
+			if tmpl != nil {
+				// Expanded here so {sha256} hashing runs in parallel
+				expanded, tmplErr := tmpl.remotePath(path, relPath, now)
+				if tmplErr != nil {
+					fail(tmplErr)
+					return nil
+				}
+				slot.RemotePath, fileOpts.RemotePath = expanded, expanded
+			}
+			result, uploadErr := c.uploadSingle(uploadCtx, fileOpts)
+			if uploadErr != nil {
+				fail(uploadErr)
+				return nil
+			}
+			*slot = result
+			tracker.Add(result.RemotePath, result.Size)
+			return nil
+		})
 		return nil
 	})
+	if walkErr == nil {
+		tracker.SetTotal(int64(len(slots)))
+	}
 
+	_ = g.Wait()
+
+	results := make([]UploadResult, len(slots))
+	for i, slot := range slots {
+		results[i] = *slot
+	}
+
+	if abortErr != nil {
+		return results, abortErr
+	}
 	if walkErr != nil {
 		return results, fmt.Errorf("walk directory: %w", walkErr)
 	}
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return results, fmt.Errorf("upload: %w", ctxErr)
+	}
 
 	return results, nil
 }
 
-// uploadSingle uploads a single file to the server.
-func (c *Client) uploadSingle(ctx context.Context, localPath, remotePath, contentType string) (UploadResult, error) {
+// uploadSingle uploads the single file opts.LocalPath to opts.RemotePath. With
+// opts.Delta it sends only changed blocks when the server supports it; uploads
+// into a stage are always sent in full.
+func (c *Client) uploadSingle(ctx context.Context, opts UploadOptions) (UploadResult, error) {
+	localPath, remotePath, contentType := opts.LocalPath, opts.RemotePath, opts.ContentType
+
 	// Open the file
 	file, err := os.Open(localPath) //#nosec G304 -- localPath is user-provided input
 	if err != nil {
@@ -182,21 +405,49 @@ func (c *Client) uploadSingle(ctx context.Context, localPath, remotePath, conten
 
 	// Auto-detect content type if not provided
 	if contentType == "" {
-		contentType = detectContentType(localPath)
+		contentType = c.contentTypeFor(localPath)
 	}
 
 	// Normalize remote path
 	remotePath = normalizePath(remotePath)
+	defer c.Purge(remotePath)
+
+	if opts.Delta && opts.stage == "" && !c.capabilities(ctx).rulesOut("delta") {
+		meta, sent, deltaErr := c.uploadDelta(ctx, file, info.Size(), remotePath, contentType, opts.ContentEncoding, opts.BlockSize)
+		if deltaErr == nil {
+			result := uploadResult(localPath, meta)
+			result.BytesSent = sent
+			return result, nil
+		}
+		if !errors.Is(deltaErr, errDeltaUnsupported) {
+			return UploadResult{}, deltaErr
+		}
+	}
 
 	// Generate presigned URL
 	presignURL := c.signer.PresignPut(remotePath, DefaultExpires)
 
-	// Create request with file as body (streaming, no memory copy)
-	req, err := http.NewRequestWithContext(ctx, http.MethodPut, presignURL, file)
+	// Create request with file as body (streaming, no memory copy). An empty
+	// file is sent as NoBody: a zero ContentLength with a body means unknown
+	// length to net/http and would be sent chunked.
+	// The content is hashed as it streams, to check against the server's ETag
+	sum := sha256.New()
+	var reqBody io.Reader = io.TeeReader(file, sum)
+	if info.Size() == 0 {
+		reqBody = http.NoBody
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, presignURL, reqBody)
 	if err != nil {
 		return UploadResult{}, fmt.Errorf("create request: %w", err)
 	}
 	req.Header.Set("Content-Type", contentType)
+	if opts.ContentEncoding != "" {
+		req.Header.Set("Content-Encoding", opts.ContentEncoding)
+	}
+	req.Header.Set(filenameHeader, url.PathEscape(uploadFilename(opts)))
+	if opts.stage != "" {
+		req.Header.Set(stageHeader, opts.stage)
+	}
 	req.ContentLength = info.Size()
 
 	// Execute request
@@ -220,36 +471,99 @@ func (c *Client) uploadSingle(ctx context.Context, localPath, remotePath, conten
 	if err := json.Unmarshal(body, &meta); err != nil {
 		return UploadResult{}, fmt.Errorf("parse response: %w", err)
 	}
+	meta.region = resp.Header.Get(regionHeader)
 
+	// Servers that predate the header are not checked
+	serverETag := resp.Header.Get(uploadETagHeader)
+	if serverETag == "" {
+		serverETag = resp.Trailer.Get(uploadETagHeader)
+	}
+	if local := hex.EncodeToString(sum.Sum(nil)); serverETag != "" && serverETag != local {
+		return UploadResult{}, fmt.Errorf("upload %s: %w: local sha256 %s, server etag %s", strings.TrimPrefix(remotePath, "/"), ErrUploadChecksumMismatch, local, serverETag)
+	}
+
+	return uploadResult(localPath, meta), nil
+}
+
+// HasUploadErrors returns true if any upload failed.
+func HasUploadErrors(results []UploadResult) bool {
+	for _, r := range results {
+		if r.Err != nil {
+			return true
+		}
+	}
+	return false
+}
+
+func uploadResult(localPath string, meta serverMetaData) UploadResult {
 	return UploadResult{
-		LocalPath:   localPath,
-		RemotePath:  meta.Path,
-		ID:          meta.ID,
-		ContentType: meta.ContentType,
-		ETag:        meta.ETag,
-		Size:        meta.FileSizeBytes,
-		CreatedAt:   meta.CreatedAt,
-		UpdatedAt:   meta.UpdatedAt,
-	}, nil
+		LocalPath:       localPath,
+		RemotePath:      meta.Path,
+		ID:              meta.ID,
+		ContentType:     meta.ContentType,
+		ContentEncoding: meta.ContentEncoding,
+		Filename:        meta.Filename,
+		ETag:            meta.ETag,
+		Size:            meta.FileSizeBytes,
+		CreatedAt:       meta.CreatedAt,
+		UpdatedAt:       meta.UpdatedAt,
+		Region:          meta.region,
+	}
 }
 
 // Download downloads a file from the server.
 // If opts.LocalPath is "-", the content is returned via the io.ReadCloser and must be closed by the caller.
 // Otherwise, the content is written to the file and the io.ReadCloser is nil.
+//
+// With opts.Resume the content is written to the local path with
+// PartialSuffix and renamed once complete. A download interrupted earlier
+// continues from the partial file's end, with If-Range carrying the ETag of
+// the response it started from, so a replaced object restarts from the
+// beginning instead of mixing two versions.
 func (c *Client) Download(ctx context.Context, opts DownloadOptions) (*DownloadResult, io.ReadCloser, error) {
 	if opts.RemotePath == "" {
 		return nil, nil, fmt.Errorf("download: %w", ErrEmptyPath)
 	}
+	if opts.Recursive {
+		return nil, nil, fmt.Errorf("download: %w", ErrRecursiveDownload)
+	}
+	if opts.Resume && opts.LocalPath == "-" {
+		return nil, nil, fmt.Errorf("download: %w", ErrResumeStdout)
+	}
 	remotePath := normalizePath(opts.RemotePath)
 
+	// Determine local path
+	localPath := opts.LocalPath
+	if localPath == "" {
+		// Derive from remote path
+		localPath = filepath.Base(remotePath)
+	}
+
+	var partial partialDownload
+	if opts.Resume {
+		partial = loadPartial(localPath)
+		if partial.resumable() && c.capabilities(ctx).rulesOut("range") {
+			// Starting over replaces the partial file
+			partial = partialDownload{localPath: localPath}
+		}
+	}
+
 	// Generate presigned URL
 	presignURL := c.signer.PresignGet(remotePath, DefaultExpires)
+	if opts.Deleted {
+		presignURL = c.presign(http.MethodGet, remotePath, url.Values{"deleted": {"true"}}, DefaultExpires)
+	}
 
 	// Create request
 	req, err := http.NewRequestWithContext(ctx, http.MethodGet, presignURL, http.NoBody)
 	if err != nil {
 		return nil, nil, fmt.Errorf("create request: %w", err)
 	}
+	req.Header.Set("Accept-Encoding", acceptEncoding)
+	if partial.resumable() {
+		req.Header.Set("Range", "bytes="+strconv.FormatInt(partial.size, 10)+"-")
+		req.Header.Set("If-Range", `"`+partial.etag+`"`)
+	}
 
 	// Execute request
 	resp, err := c.httpClient.Do(req)
@@ -257,34 +571,66 @@ func (c *Client) Download(ctx context.Context, opts DownloadOptions) (*DownloadR
 		return nil, nil, fmt.Errorf("do request: %w", err)
 	}
 
-	if resp.StatusCode != http.StatusOK {
+	resumed := resp.StatusCode == http.StatusPartialContent && partial.resumable()
+	if resp.StatusCode == http.StatusRequestedRangeNotSatisfiable && partial.resumable() ||
+		resumed && !partial.continuedBy(resp, opts.Raw) {
+		// The partial file does not fit the object; start over
+		_ = resp.Body.Close()
+		if err := partial.remove(); err != nil {
+			return nil, nil, fmt.Errorf("download: %w", err)
+		}
+		return c.Download(ctx, opts)
+	}
+	if resp.StatusCode != http.StatusOK && !resumed {
 		body, _ := io.ReadAll(resp.Body)
 		_ = resp.Body.Close()
 		return nil, nil, parseServerError(resp.StatusCode, body)
 	}
 
-	// Extract metadata from headers
-	etag := strings.Trim(resp.Header.Get("ETag"), `"`)
+	// Extract metadata from headers. A weak ETag from response compression
+	// still carries the SHA-256 of the stored content.
+	etag := responseETag(resp)
 	contentType := resp.Header.Get("Content-Type")
+	verify := opts.Verify || c.config.VerifyDownloads
 
 	result := &DownloadResult{
-		RemotePath:  strings.TrimPrefix(remotePath, "/"),
-		ETag:        etag,
-		ContentType: contentType,
-		Size:        resp.ContentLength,
+		RemotePath:      strings.TrimPrefix(remotePath, "/"),
+		ETag:            etag,
+		ContentType:     contentType,
+		ContentEncoding: storedEncoding(resp),
+		Size:            resp.ContentLength,
+	}
+	if resp.Header.Get("Content-Encoding") != "" && (result.ContentEncoding == "" || !opts.Raw) {
+		// The decoded length is not known until the body is read
+		result.Size = -1
+	}
+
+	if opts.Deleted {
+		// An older server ignores ?deleted and serves the live object
+		if resp.Header.Get("X-Stowry-Deleted") != "true" {
+			_ = resp.Body.Close()
+			return nil, nil, fmt.Errorf("download: %w", ErrDeletedUnsupported)
+		}
+		if deletedAt, err := http.ParseTime(resp.Header.Get("X-Stowry-Deleted-At")); err == nil {
+			result.DeletedAt = &deletedAt
+		}
+	}
+
+	var content io.ReadCloser
+	if resumed {
+		content, err = partial.content(resp, etag, verify)
+	} else {
+		content, err = responseContent(resp, etag, verify, opts.Raw)
+	}
+	if err != nil {
+		_ = resp.Body.Close()
+		return nil, nil, fmt.Errorf("download %s: %w", result.RemotePath, err)
 	}
 
 	// If stdout requested, return the body for the caller to handle
 	if opts.LocalPath == "-" {
 		result.LocalPath = "-"
-		return result, resp.Body, nil
-	}
-
-	// Determine local path
-	localPath := opts.LocalPath
-	if localPath == "" {
-		// Derive from remote path
-		localPath = filepath.Base(remotePath)
+		return result, content, nil
 	}
 	result.LocalPath = localPath
 
@@ -292,41 +638,95 @@ func (c *Client) Download(ctx context.Context, opts DownloadOptions) (*DownloadR
 	dir := filepath.Dir(localPath)
 	if dir != "" && dir != "." {
 		if mkdirErr := os.MkdirAll(dir, 0o750); mkdirErr != nil {
-			_ = resp.Body.Close()
+			_ = content.Close()
 			return nil, nil, fmt.Errorf("create directory: %w", mkdirErr)
 		}
 	}
 
 	// Create the file
-	file, createErr := os.Create(localPath) //#nosec G304 -- localPath is user-provided input
+	var (
+		file      *os.File
+		createErr error
+	)
+	switch {
+	case resumed:
+		file, createErr = partial.openAppend()
+		result.ResumedFrom = partial.size
+	case opts.Resume:
+		file, createErr = partial.create(resp, opts.Raw)
+	default:
+		file, createErr = os.Create(localPath) //#nosec G304 -- localPath is user-provided input
+	}
 	if createErr != nil {
-		_ = resp.Body.Close()
+		_ = content.Close()
 		return nil, nil, fmt.Errorf("create file: %w", createErr)
 	}
 
 	// Copy content to file
-	written, copyErr := io.Copy(file, resp.Body)
-	_ = resp.Body.Close()
+	written, copyErr := io.Copy(file, content)
+	_ = content.Close()
 	if copyErr != nil {
 		_ = file.Close()
+		if errors.Is(copyErr, ErrChecksumMismatch) {
+			_ = os.Remove(file.Name())
+			_ = partial.remove()
+			return nil, nil, fmt.Errorf("download %s: %w", result.RemotePath, copyErr)
+		}
 		return nil, nil, fmt.Errorf("write file: %w", copyErr)
 	}
 
 	if closeErr := file.Close(); closeErr != nil {
 		return nil, nil, fmt.Errorf("close file: %w", closeErr)
 	}
+	if opts.Resume {
+		if err := partial.finish(); err != nil {
+			return nil, nil, fmt.Errorf("download %s: %w", result.RemotePath, err)
+		}
+	}
 
-	result.Size = written
+	result.Size = result.ResumedFrom + written
 	return result, nil, nil
 }
 
+// verifyingReader hashes content as it is read and reports
+// ErrChecksumMismatch instead of io.EOF when the SHA-256 does not match etag.
+type verifyingReader struct {
+	io.ReadCloser
+	hash hash.Hash
+	etag string
+}
+
+func newVerifyingReader(rc io.ReadCloser, etag string) *verifyingReader {
+	return &verifyingReader{ReadCloser: rc, hash: sha256.New(), etag: etag}
+}
+
+func (v *verifyingReader) Read(p []byte) (int, error) {
+	n, err := v.ReadCloser.Read(p)
+	v.hash.Write(p[:n])
+	if errors.Is(err, io.EOF) {
+		if got := hex.EncodeToString(v.hash.Sum(nil)); got != v.etag {
+			return n, fmt.Errorf("%w: got sha256 %s, server etag %q", ErrChecksumMismatch, got, v.etag)
+		}
+	}
+	return n, err
+}
+
 // Delete deletes one or more files from the server.
-// Continues on error, collecting results for all paths.
+// Continues on error, collecting results for all paths. A handful of paths
+// or more are sent in bulk delete requests, falling back to a DELETE each
+// on servers without bulk delete. Purges are always sent one at a time.
 func (c *Client) Delete(ctx context.Context, opts DeleteOptions) ([]DeleteResult, error) {
 	if len(opts.Paths) == 0 {
 		return nil, ErrNoPaths
 	}
 
+	if !opts.Purge && len(opts.Paths) >= bulkDeleteThreshold && !c.capabilities(ctx).rulesOut("bulk_delete") {
+		results, err := c.deleteMany(ctx, opts.Paths)
+		if !errors.Is(err, errBulkDeleteUnsupported) {
+			return results, err
+		}
+	}
+
 	results := make([]DeleteResult, 0, len(opts.Paths))
 
 	for _, path := range opts.Paths {
@@ -335,7 +735,12 @@ func (c *Client) Delete(ctx context.Context, opts DeleteOptions) ([]DeleteResult
 			return results, err
 		}
 
-		result := c.deleteSingle(ctx, path)
+		var result DeleteResult
+		if opts.Purge {
+			result = c.purgeSingle(ctx, path)
+		} else {
+			result = c.deleteSingle(ctx, path)
+		}
 		results = append(results, result)
 	}
 
@@ -345,6 +750,7 @@ func (c *Client) Delete(ctx context.Context, opts DeleteOptions) ([]DeleteResult
 // deleteSingle deletes a single file from the server.
 func (c *Client) deleteSingle(ctx context.Context, path string) DeleteResult {
 	remotePath := normalizePath(path)
+	defer c.Purge(remotePath)
 
 	// Generate presigned URL
 	presignURL := c.signer.PresignDelete(remotePath, DefaultExpires)
@@ -386,6 +792,35 @@ func (c *Client) deleteSingle(ctx context.Context, path string) DeleteResult {
 	}
 }
 
+// purgeSingle purges a single file from the server.
+func (c *Client) purgeSingle(ctx context.Context, path string) DeleteResult {
+	remotePath := normalizePath(path)
+	defer c.Purge(remotePath)
+
+	presignURL := c.presign(http.MethodDelete, remotePath, url.Values{"purge": {"1"}}, DefaultExpires)
+	req, err := http.NewRequestWithContext(ctx, http.MethodDelete, presignURL, http.NoBody)
+	if err != nil {
+		return DeleteResult{Path: path, Err: fmt.Errorf("create request: %w", err)}
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return DeleteResult{Path: path, Err: fmt.Errorf("do request: %w", err)}
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		return DeleteResult{Path: path, Err: parseServerError(resp.StatusCode, body)}
+	}
+
+	var purged serverMetaData
+	if err := json.Unmarshal(body, &purged); err != nil {
+		return DeleteResult{Path: path, Err: fmt.Errorf("parse response: %w", err)}
+	}
+	return DeleteResult{Path: path, Deleted: true, Purged: true, ETag: purged.ETag, Size: purged.FileSizeBytes}
+}
+
 // HasDeleteErrors returns true if any delete operation failed.
 func HasDeleteErrors(results []DeleteResult) bool {
 	for _, r := range results {
@@ -398,6 +833,7 @@ func HasDeleteErrors(results []DeleteResult) bool {
 
 // List lists objects on the server (store mode only).
 // If opts.All is true, paginates through all results.
+// Returns ErrListDisabled when the server has listing turned off.
 func (c *Client) List(ctx context.Context, opts ListOptions) (*ListResult, error) {
 	if opts.All {
 		return c.listAll(ctx, opts)
@@ -417,6 +853,12 @@ func (c *Client) listPage(ctx context.Context, opts ListOptions) (*ListResult, e
 
 	// Generate presigned URL
 	presignURL := c.presignList(opts.Prefix, limit, opts.Cursor, DefaultExpires)
+	if opts.IncludeBackend {
+		presignURL += "&include=backend"
+	}
+	if opts.Deleted {
+		presignURL += "&deleted=true"
+	}
 
 	// Create request
 	req, err := http.NewRequestWithContext(ctx, http.MethodGet, presignURL, http.NoBody)
@@ -437,7 +879,7 @@ func (c *Client) listPage(ctx context.Context, opts ListOptions) (*ListResult, e
 	}
 
 	if resp.StatusCode != http.StatusOK {
-		return nil, parseServerError(resp.StatusCode, body)
+		return nil, parseListError(resp.StatusCode, body)
 	}
 
 	// Parse response
@@ -449,15 +891,7 @@ func (c *Client) listPage(ctx context.Context, opts ListOptions) (*ListResult, e
 	// Convert to client types
 	items := make([]ObjectInfo, len(serverResult.Items))
 	for i, item := range serverResult.Items {
-		items[i] = ObjectInfo{
-			ID:          item.ID,
-			Path:        item.Path,
-			ContentType: item.ContentType,
-			ETag:        item.ETag,
-			Size:        item.FileSizeBytes,
-			CreatedAt:   item.CreatedAt,
-			UpdatedAt:   item.UpdatedAt,
-		}
+		items[i] = item.objectInfo()
 	}
 
 	return &ListResult{
@@ -466,11 +900,28 @@ func (c *Client) listPage(ctx context.Context, opts ListOptions) (*ListResult, e
 	}, nil
 }
 
+// parseListError converts a failed list response to an error, ErrListDisabled
+// when the server has listing turned off.
+func parseListError(statusCode int, body []byte) error {
+	if statusCode == http.StatusForbidden {
+		var resp struct {
+			Error string `json:"error"`
+		}
+		if json.Unmarshal(body, &resp) == nil && resp.Error == "list_disabled" {
+			return ErrListDisabled
+		}
+	}
+	return parseServerError(statusCode, body)
+}
+
 // listAll fetches all pages of results.
 func (c *Client) listAll(ctx context.Context, opts ListOptions) (*ListResult, error) {
 	var allItems []ObjectInfo
 	cursor := opts.Cursor
 
+	tracker := progress.New("list", opts.Progress)
+	defer tracker.Finish()
+
 	for {
 		// Check context cancellation
 		if err := ctx.Err(); err != nil {
@@ -482,6 +933,9 @@ func (c *Client) listAll(ctx context.Context, opts ListOptions) (*ListResult, er
 			Limit:  opts.Limit,
 			Cursor: cursor,
 			All:    false, // Prevent recursion
+
+			IncludeBackend: opts.IncludeBackend,
+			Deleted:        opts.Deleted,
 		}
 
 		page, err := c.listPage(ctx, pageOpts)
@@ -490,6 +944,9 @@ func (c *Client) listAll(ctx context.Context, opts ListOptions) (*ListResult, er
 		}
 
 		allItems = append(allItems, page.Items...)
+		for _, item := range page.Items {
+			tracker.Add(item.Path, item.Size)
+		}
 
 		if page.NextCursor == "" {
 			break
@@ -515,31 +972,69 @@ func (r *ListResult) TotalSize() int64 {
 // presignList generates a presigned URL for list operations.
 // This is implemented manually since stowry-go doesn't have PresignList.
 func (c *Client) presignList(prefix string, limit int, cursor string, expires int) string {
+	query := url.Values{}
+	if prefix != "" {
+		query.Set("prefix", prefix)
+	}
+	if limit > 0 {
+		query.Set("limit", strconv.Itoa(limit))
+	}
+	if cursor != "" {
+		query.Set("cursor", cursor)
+	}
+
+	return c.presign(http.MethodGet, "/", query, expires)
+}
+
+// presign generates a presigned URL for any method, adding query to the
+// signature parameters. stowry-go only presigns GET, PUT and DELETE.
+func (c *Client) presign(method, path string, query url.Values, expires int) string {
 	if expires <= 0 {
 		expires = DefaultExpires
 	}
 
 	timestamp := time.Now().Unix()
-	path := "/"
-	sig := stowry.Sign(c.config.SecretKey, http.MethodGet, path, timestamp, int64(expires))
+	sig := signStowry(c.config.SecretKey, method, path, timestamp, int64(expires), query)
 
-	query := url.Values{}
+	if query == nil {
+		query = url.Values{}
+	}
 	query.Set(stowry.StowryCredentialParam, c.config.AccessKey)
 	query.Set(stowry.StowryDateParam, strconv.FormatInt(timestamp, 10))
 	query.Set(stowry.StowryExpiresParam, strconv.Itoa(expires))
 	query.Set(stowry.StowrySignatureParam, sig)
 
-	if prefix != "" {
-		query.Set("prefix", prefix)
-	}
-	if limit > 0 {
-		query.Set("limit", strconv.Itoa(limit))
+	return c.config.Endpoint + path + "?" + query.Encode()
+}
+
+// operationParams are the query parameters, by method and in alphabetical
+// order, that the server's Stowry signature covers along with the method
+// and path, so a presigned URL cannot be replayed as another operation.
+var operationParams = map[string][]string{
+	http.MethodDelete: {"dry_run", "force", "prefix", "purge", "uploadId"},
+	http.MethodPost:   {"rename-to", "restore", "uploadId", "uploads"},
+	http.MethodPut:    {"partNumber", "uploadId"},
+}
+
+// signStowry computes a Stowry native signature for method and path that
+// also covers the operation parameters in query. Without any it equals
+// stowry.Sign; otherwise one {NAME}:{VALUE} line per value is appended to
+// the string to sign.
+func signStowry(secretKey, method, path string, timestamp, expires int64, query url.Values) string {
+	var lines []string
+	for _, name := range operationParams[method] {
+		for _, value := range query[name] {
+			lines = append(lines, name+":"+value)
+		}
 	}
-	if cursor != "" {
-		query.Set("cursor", cursor)
+	if len(lines) == 0 {
+		return stowry.Sign(secretKey, method, path, timestamp, expires)
 	}
 
-	return c.config.Endpoint + path + "?" + query.Encode()
+	stringToSign := fmt.Sprintf("%s\n%s\n%d\n%d\n%s", method, path, timestamp, expires, strings.Join(lines, "\n"))
+	mac := hmac.New(sha256.New, []byte(secretKey))
+	mac.Write([]byte(stringToSign))
+	return hex.EncodeToString(mac.Sum(nil))
 }
 
 // normalizePath ensures path has leading slash and no trailing slash.
@@ -587,6 +1082,32 @@ func NormalizeLocalToRemotePath(localPath string) string {
 	return path
 }
 
+// contentTypeFor returns the configured content type for the file's extension,
+// falling back to detectContentType.
+func (c *Client) contentTypeFor(path string) string {
+	if contentType, ok := c.config.ContentTypes[strings.ToLower(filepath.Ext(path))]; ok {
+		return contentType
+	}
+	return detectContentType(path)
+}
+
+// normalizeContentTypes lowercases extension keys and adds the leading dot,
+// so "WASM" and ".wasm" both match app.wasm.
+func normalizeContentTypes(m map[string]string) map[string]string {
+	if len(m) == 0 {
+		return nil
+	}
+	out := make(map[string]string, len(m))
+	for ext, contentType := range m {
+		ext = strings.ToLower(ext)
+		if !strings.HasPrefix(ext, ".") {
+			ext = "." + ext
+		}
+		out[ext] = contentType
+	}
+	return out
+}
+
 // detectContentType returns MIME type based on file extension.
 func detectContentType(path string) string {
 	ext := filepath.Ext(path)
@@ -2,19 +2,34 @@ package clientcli_test
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"fmt"
 	"io"
+	"io/fs"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"os"
 	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/google/uuid"
+	stowrycore "github.com/sagarc03/stowry"
+	"github.com/sagarc03/stowry-go"
 	"github.com/sagarc03/stowry/clientcli"
+	"github.com/sagarc03/stowry/keybackend"
+	"github.com/sagarc03/stowry/progress"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
 )
 
 func TestNew(t *testing.T) {
@@ -109,6 +124,79 @@ func TestClient_Upload(t *testing.T) {
 		assert.Nil(t, result.Err)
 	})
 
+	t.Run("empty file is sent with Content-Length 0", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, int64(0), r.ContentLength)
+			assert.Empty(t, r.TransferEncoding, "not chunked")
+			w.Header().Set("Content-Type", "application/json")
+			_ = json.NewEncoder(w).Encode(map[string]any{"path": "markers/.keep", "file_size_bytes": 0})
+		}))
+		defer server.Close()
+
+		localPath := filepath.Join(t.TempDir(), ".keep")
+		require.NoError(t, os.WriteFile(localPath, nil, 0o600))
+
+		client, err := clientcli.New(&clientcli.Config{Endpoint: server.URL, AccessKey: "k", SecretKey: "s"})
+		require.NoError(t, err)
+
+		results, err := client.Upload(context.Background(), clientcli.UploadOptions{LocalPath: localPath, RemotePath: "markers/.keep"})
+		require.NoError(t, err)
+		require.Len(t, results, 1)
+		assert.Zero(t, results[0].Size)
+	})
+
+	t.Run("server etag header is verified", func(t *testing.T) {
+		content := []byte("streamed content")
+		sum := sha256.Sum256(content)
+		local := hex.EncodeToString(sum[:])
+
+		tests := []struct {
+			name       string
+			serverETag string
+			trailer    bool
+			wantErr    bool
+		}{
+			{name: "match", serverETag: local},
+			{name: "match in trailer", serverETag: local, trailer: true},
+			{name: "mismatch", serverETag: strings.Repeat("0", 64), wantErr: true},
+			{name: "mismatch in trailer", serverETag: strings.Repeat("0", 64), trailer: true, wantErr: true},
+			{name: "header absent", serverETag: ""},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					_, _ = io.Copy(io.Discard, r.Body)
+					w.Header().Set("Content-Type", "application/json")
+					if tt.trailer {
+						w.Header().Set("Trailer", "X-Stowry-ETag")
+					} else if tt.serverETag != "" {
+						w.Header().Set("X-Stowry-ETag", tt.serverETag)
+					}
+					_ = json.NewEncoder(w).Encode(map[string]any{"path": "f.bin", "etag": tt.serverETag})
+					if tt.trailer {
+						w.Header().Set("X-Stowry-ETag", tt.serverETag)
+					}
+				}))
+				defer server.Close()
+
+				localPath := filepath.Join(t.TempDir(), "f.bin")
+				require.NoError(t, os.WriteFile(localPath, content, 0o600))
+				client, err := clientcli.New(&clientcli.Config{Endpoint: server.URL, AccessKey: "k", SecretKey: "s"})
+				require.NoError(t, err)
+
+				_, err = client.Upload(context.Background(), clientcli.UploadOptions{LocalPath: localPath, RemotePath: "f.bin"})
+
+				if !tt.wantErr {
+					assert.NoError(t, err)
+					return
+				}
+				require.ErrorIs(t, err, clientcli.ErrUploadChecksumMismatch)
+				assert.Contains(t, err.Error(), "local sha256 "+local)
+				assert.Contains(t, err.Error(), "server etag "+tt.serverETag)
+			})
+		}
+	})
+
 	t.Run("upload error", func(t *testing.T) {
 		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			w.WriteHeader(http.StatusInternalServerError)
@@ -295,6 +383,51 @@ func TestClient_Delete(t *testing.T) {
 		})
 		assert.Error(t, err)
 	})
+
+	t.Run("purge sends one DELETE ?purge per path", func(t *testing.T) {
+		var purged []string
+		verifier := stowrycore.NewStowrySignatureVerifier(keybackend.NewMapSecretStore(map[string]string{"test-key": "test-secret"}))
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, http.MethodDelete, r.Method)
+			assert.Equal(t, "1", r.URL.Query().Get("purge"))
+			assert.NoError(t, verifier.Verify(r), "the signature covers the purge flag")
+			purged = append(purged, r.URL.Path)
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = fmt.Fprintf(w, `{"path":%q,"etag":"abc123","file_size_bytes":42}`, r.URL.Path[1:])
+		}))
+		defer server.Close()
+
+		client, err := clientcli.New(&clientcli.Config{Endpoint: server.URL, AccessKey: "test-key", SecretKey: "test-secret"})
+		require.NoError(t, err)
+
+		paths := []string{"a.txt", "b.txt", "c.txt", "d.txt", "e.txt"}
+		results, err := client.Delete(context.Background(), clientcli.DeleteOptions{Paths: paths, Purge: true})
+		require.NoError(t, err)
+		require.Len(t, results, 5)
+
+		assert.Equal(t, []string{"/a.txt", "/b.txt", "/c.txt", "/d.txt", "/e.txt"}, purged, "purges are never sent in bulk")
+		assert.True(t, results[0].Deleted)
+		assert.True(t, results[0].Purged)
+		assert.Equal(t, "abc123", results[0].ETag)
+		assert.Equal(t, int64(42), results[0].Size)
+	})
+
+	t.Run("purge not found", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`{"error": "not_found"}`))
+		}))
+		defer server.Close()
+
+		client, err := clientcli.New(&clientcli.Config{Endpoint: server.URL, AccessKey: "test-key", SecretKey: "test-secret"})
+		require.NoError(t, err)
+
+		results, err := client.Delete(context.Background(), clientcli.DeleteOptions{Paths: []string{"missing.txt"}, Purge: true})
+		require.NoError(t, err)
+		require.Len(t, results, 1)
+		assert.False(t, results[0].Purged)
+		assert.Error(t, results[0].Err)
+	})
 }
 
 func TestClient_List(t *testing.T) {
@@ -353,6 +486,31 @@ func TestClient_List(t *testing.T) {
 		assert.Equal(t, int64(300), result.TotalSize())
 	})
 
+	t.Run("list with backends", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, "backend", r.URL.Query().Get("include"))
+
+			resp := map[string]any{
+				"items": []map[string]any{
+					{"path": "archive/2020.tar", "storage_backend": "archive/"},
+					{"path": "index.html"},
+				},
+			}
+			w.Header().Set("Content-Type", "application/json")
+			_ = json.NewEncoder(w).Encode(resp)
+		}))
+		defer server.Close()
+
+		client, err := clientcli.New(&clientcli.Config{Endpoint: server.URL, AccessKey: "test-key", SecretKey: "test-secret"})
+		require.NoError(t, err)
+
+		result, err := client.List(context.Background(), clientcli.ListOptions{IncludeBackend: true})
+		require.NoError(t, err)
+		require.Len(t, result.Items, 2)
+		assert.Equal(t, "archive/", result.Items[0].StorageBackend)
+		assert.Empty(t, result.Items[1].StorageBackend)
+	})
+
 	t.Run("list with prefix", func(t *testing.T) {
 		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			assert.Equal(t, "images/", r.URL.Query().Get("prefix"))
@@ -378,6 +536,43 @@ func TestClient_List(t *testing.T) {
 		})
 		require.NoError(t, err)
 	})
+	t.Run("listing disabled", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusForbidden)
+			_, _ = w.Write([]byte(`{"error":"list_disabled","message":"listing is disabled on this server"}`))
+		}))
+		defer server.Close()
+
+		client, err := clientcli.New(&clientcli.Config{
+			Endpoint:  server.URL,
+			AccessKey: "test-key",
+			SecretKey: "test-secret",
+		})
+		require.NoError(t, err)
+
+		_, err = client.List(context.Background(), clientcli.ListOptions{All: true})
+		assert.ErrorIs(t, err, clientcli.ErrListDisabled)
+	})
+
+	t.Run("other forbidden responses", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusForbidden)
+			_, _ = w.Write([]byte(`{"error":"forbidden","message":"forbidden"}`))
+		}))
+		defer server.Close()
+
+		client, err := clientcli.New(&clientcli.Config{
+			Endpoint:  server.URL,
+			AccessKey: "test-key",
+			SecretKey: "test-secret",
+		})
+		require.NoError(t, err)
+
+		_, err = client.List(context.Background(), clientcli.ListOptions{})
+		assert.ErrorIs(t, err, clientcli.ErrForbidden)
+		assert.NotErrorIs(t, err, clientcli.ErrListDisabled)
+	})
 }
 
 func TestHasDeleteErrors(t *testing.T) {
@@ -460,6 +655,34 @@ func TestNew_Options(t *testing.T) {
 	})
 }
 
+func TestClient_WithTracePropagation(t *testing.T) {
+	prev := otel.GetTextMapPropagator()
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	t.Cleanup(func() { otel.SetTextMapPropagator(prev) })
+
+	var traceparent string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		traceparent = r.Header.Get("traceparent")
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	client, err := clientcli.New(&clientcli.Config{Endpoint: server.URL}, clientcli.WithTracePropagation())
+	require.NoError(t, err)
+
+	traceID, _ := trace.TraceIDFromHex("4bf92f3577b34da6a3ce929d0e0e4736")
+	spanID, _ := trace.SpanIDFromHex("00f067aa0ba902b7")
+	ctx := trace.ContextWithSpanContext(context.Background(), trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    traceID,
+		SpanID:     spanID,
+		TraceFlags: trace.FlagsSampled,
+	}))
+
+	_, err = client.Delete(ctx, clientcli.DeleteOptions{Paths: []string{"a.txt"}})
+	require.NoError(t, err)
+	assert.Equal(t, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", traceparent)
+}
+
 func TestAPIError_Is(t *testing.T) {
 	t.Run("matches same status code", func(t *testing.T) {
 		err := &clientcli.APIError{StatusCode: 404, Body: "not found"}
@@ -691,3 +914,475 @@ func TestClient_Upload_Recursive(t *testing.T) {
 		assert.Len(t, results, 1)
 	})
 }
+
+func TestClient_Download_Verify(t *testing.T) {
+	content := []byte("verified content")
+	sum := sha256.Sum256(content)
+	goodETag := hex.EncodeToString(sum[:])
+
+	newServer := func(etag string) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			w.Header().Set("ETag", etag)
+			w.Header().Set("Content-Type", "text/plain")
+			_, _ = w.Write(content)
+		}))
+	}
+
+	t.Run("matching etag", func(t *testing.T) {
+		server := newServer(`"` + goodETag + `"`)
+		defer server.Close()
+
+		client, err := clientcli.New(&clientcli.Config{Endpoint: server.URL, VerifyDownloads: true})
+		require.NoError(t, err)
+
+		localPath := filepath.Join(t.TempDir(), "file.txt")
+		_, _, err = client.Download(context.Background(), clientcli.DownloadOptions{RemotePath: "file.txt", LocalPath: localPath})
+		require.NoError(t, err)
+	})
+
+	t.Run("weak etag from compression", func(t *testing.T) {
+		server := newServer(`W/"` + goodETag + `"`)
+		defer server.Close()
+
+		client, err := clientcli.New(&clientcli.Config{Endpoint: server.URL})
+		require.NoError(t, err)
+
+		localPath := filepath.Join(t.TempDir(), "file.txt")
+		_, _, err = client.Download(context.Background(), clientcli.DownloadOptions{RemotePath: "file.txt", LocalPath: localPath, Verify: true})
+		require.NoError(t, err)
+	})
+
+	t.Run("mismatch removes the file", func(t *testing.T) {
+		server := newServer(`"0000"`)
+		defer server.Close()
+
+		client, err := clientcli.New(&clientcli.Config{Endpoint: server.URL, VerifyDownloads: true})
+		require.NoError(t, err)
+
+		localPath := filepath.Join(t.TempDir(), "file.txt")
+		_, _, err = client.Download(context.Background(), clientcli.DownloadOptions{RemotePath: "file.txt", LocalPath: localPath})
+		assert.ErrorIs(t, err, clientcli.ErrChecksumMismatch)
+		assert.NoFileExists(t, localPath)
+	})
+
+	t.Run("mismatch on stdout surfaces at EOF", func(t *testing.T) {
+		server := newServer(`"0000"`)
+		defer server.Close()
+
+		client, err := clientcli.New(&clientcli.Config{Endpoint: server.URL})
+		require.NoError(t, err)
+
+		_, reader, err := client.Download(context.Background(), clientcli.DownloadOptions{RemotePath: "file.txt", LocalPath: "-", Verify: true})
+		require.NoError(t, err)
+		defer func() { _ = reader.Close() }()
+
+		_, err = io.ReadAll(reader)
+		assert.ErrorIs(t, err, clientcli.ErrChecksumMismatch)
+	})
+
+	t.Run("not verified by default", func(t *testing.T) {
+		server := newServer(`"0000"`)
+		defer server.Close()
+
+		client, err := clientcli.New(&clientcli.Config{Endpoint: server.URL})
+		require.NoError(t, err)
+
+		localPath := filepath.Join(t.TempDir(), "file.txt")
+		_, _, err = client.Download(context.Background(), clientcli.DownloadOptions{RemotePath: "file.txt", LocalPath: localPath})
+		require.NoError(t, err)
+	})
+}
+
+func TestClient_Download_Deleted(t *testing.T) {
+	t.Run("requests and reports the deleted object", func(t *testing.T) {
+		var query url.Values
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			query = r.URL.Query()
+			w.Header().Set("ETag", `"abc"`)
+			w.Header().Set("X-Stowry-Deleted", "true")
+			w.Header().Set("X-Stowry-Deleted-At", "Mon, 15 Jan 2024 10:30:00 GMT")
+			_, _ = w.Write([]byte("old content"))
+		}))
+		defer server.Close()
+
+		client, err := clientcli.New(&clientcli.Config{Endpoint: server.URL, AccessKey: "ak", SecretKey: "sk"})
+		require.NoError(t, err)
+
+		localPath := filepath.Join(t.TempDir(), "file.txt")
+		result, _, err := client.Download(context.Background(), clientcli.DownloadOptions{RemotePath: "file.txt", LocalPath: localPath, Deleted: true})
+		require.NoError(t, err)
+
+		assert.Equal(t, "true", query.Get("deleted"))
+		assert.NotEmpty(t, query.Get(stowry.StowrySignatureParam))
+		require.NotNil(t, result.DeletedAt)
+		assert.Equal(t, time.Date(2024, 1, 15, 10, 30, 0, 0, time.UTC), *result.DeletedAt)
+		data, err := os.ReadFile(localPath)
+		require.NoError(t, err)
+		assert.Equal(t, "old content", string(data))
+	})
+
+	t.Run("server serving the live object", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			_, _ = w.Write([]byte("live content"))
+		}))
+		defer server.Close()
+
+		client, err := clientcli.New(&clientcli.Config{Endpoint: server.URL, AccessKey: "ak", SecretKey: "sk"})
+		require.NoError(t, err)
+
+		localPath := filepath.Join(t.TempDir(), "file.txt")
+		_, _, err = client.Download(context.Background(), clientcli.DownloadOptions{RemotePath: "file.txt", LocalPath: localPath, Deleted: true})
+		assert.ErrorIs(t, err, clientcli.ErrDeletedUnsupported)
+		assert.NoFileExists(t, localPath)
+	})
+
+	t.Run("gone after cleanup", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusGone)
+			_, _ = w.Write([]byte(`{"error":"gone","message":"Object was deleted and its content has been cleaned up"}`))
+		}))
+		defer server.Close()
+
+		client, err := clientcli.New(&clientcli.Config{Endpoint: server.URL, AccessKey: "ak", SecretKey: "sk"})
+		require.NoError(t, err)
+
+		_, _, err = client.Download(context.Background(), clientcli.DownloadOptions{RemotePath: "file.txt", LocalPath: "-", Deleted: true})
+		var apiErr *clientcli.APIError
+		require.ErrorAs(t, err, &apiErr)
+		assert.Equal(t, http.StatusGone, apiErr.StatusCode)
+	})
+}
+
+// uploadEchoHandler answers uploads with the received path and content type.
+func uploadEchoHandler(t *testing.T) http.HandlerFunc {
+	t.Helper()
+	return func(w http.ResponseWriter, r *http.Request) {
+		resp := map[string]any{
+			"id":              uuid.New().String(),
+			"path":            r.URL.Path,
+			"content_type":    r.Header.Get("Content-Type"),
+			"etag":            "etag",
+			"file_size_bytes": r.ContentLength,
+			"created_at":      time.Now().Format(time.RFC3339),
+			"updated_at":      time.Now().Format(time.RFC3339),
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(resp)
+	}
+}
+
+func TestClient_Upload_ContentTypeOverrides(t *testing.T) {
+	server := httptest.NewServer(uploadEchoHandler(t))
+	defer server.Close()
+
+	tmpDir := t.TempDir()
+	wasmPath := filepath.Join(tmpDir, "app.WASM")
+	require.NoError(t, os.WriteFile(wasmPath, []byte("wasm"), 0o600))
+
+	client, err := clientcli.New(&clientcli.Config{
+		Endpoint:     server.URL,
+		ContentTypes: map[string]string{"wasm": "application/wasm"},
+	})
+	require.NoError(t, err)
+
+	results, err := client.Upload(context.Background(), clientcli.UploadOptions{LocalPath: wasmPath, RemotePath: "app.wasm"})
+	require.NoError(t, err)
+	assert.Equal(t, "application/wasm", results[0].ContentType)
+
+	// An explicit content type still wins
+	results, err = client.Upload(context.Background(), clientcli.UploadOptions{LocalPath: wasmPath, RemotePath: "app.wasm", ContentType: "application/octet-stream"})
+	require.NoError(t, err)
+	assert.Equal(t, "application/octet-stream", results[0].ContentType)
+}
+
+func TestClient_Upload_Recursive_Concurrency(t *testing.T) {
+	var inFlight, peak atomic.Int32
+	release := make(chan struct{})
+	echo := uploadEchoHandler(t)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := inFlight.Add(1)
+		for {
+			p := peak.Load()
+			if n <= p || peak.CompareAndSwap(p, n) {
+				break
+			}
+		}
+		<-release
+		inFlight.Add(-1)
+		echo(w, r)
+	}))
+	defer server.Close()
+
+	tmpDir := t.TempDir()
+	var want []string
+	for i := range 6 {
+		name := fmt.Sprintf("file%d.txt", i)
+		require.NoError(t, os.WriteFile(filepath.Join(tmpDir, name), []byte("content"), 0o600))
+		want = append(want, "/uploads/"+name)
+	}
+
+	client, err := clientcli.New(&clientcli.Config{Endpoint: server.URL, UploadConcurrency: 3})
+	require.NoError(t, err)
+
+	done := make(chan []clientcli.UploadResult)
+	go func() {
+		results, uploadErr := client.Upload(context.Background(), clientcli.UploadOptions{
+			LocalPath:  tmpDir,
+			RemotePath: "uploads",
+			Recursive:  true,
+		})
+		assert.NoError(t, uploadErr)
+		done <- results
+	}()
+
+	require.Eventually(t, func() bool { return inFlight.Load() == 3 }, 5*time.Second, time.Millisecond)
+	close(release)
+	results := <-done
+
+	assert.Equal(t, int32(3), peak.Load())
+	got := make([]string, len(results))
+	for i := range results {
+		require.NoError(t, results[i].Err)
+		got[i] = results[i].RemotePath
+	}
+	assert.Equal(t, want, got, "results keep walk order")
+}
+
+func TestClient_Upload_Recursive_Failures(t *testing.T) {
+	// One upload at a time, so the walk is at file3 when file2 fails
+	setup := func(t *testing.T, handle func(w http.ResponseWriter, r *http.Request) bool) (*clientcli.Client, string, *atomic.Int32) {
+		t.Helper()
+		tmpDir := t.TempDir()
+		for i := range 6 {
+			require.NoError(t, os.WriteFile(filepath.Join(tmpDir, fmt.Sprintf("file%d.txt", i)), []byte("content"), 0o600))
+		}
+
+		var requests atomic.Int32
+		echo := uploadEchoHandler(t)
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requests.Add(1)
+			if !handle(w, r) {
+				echo(w, r)
+			}
+		}))
+		t.Cleanup(server.Close)
+
+		client, err := clientcli.New(&clientcli.Config{Endpoint: server.URL, UploadConcurrency: 1})
+		require.NoError(t, err)
+		return client, tmpDir, &requests
+	}
+	failFile2 := func(w http.ResponseWriter, r *http.Request) bool {
+		if r.URL.Path != "/uploads/file2.txt" {
+			return false
+		}
+		w.WriteHeader(http.StatusInternalServerError)
+		return true
+	}
+
+	t.Run("continues after a failure by default", func(t *testing.T) {
+		client, tmpDir, requests := setup(t, failFile2)
+
+		results, err := client.Upload(context.Background(), clientcli.UploadOptions{
+			LocalPath:  tmpDir,
+			RemotePath: "uploads",
+			Recursive:  true,
+		})
+		require.NoError(t, err)
+		require.Len(t, results, 6)
+		assert.Error(t, results[2].Err)
+		assert.NoError(t, results[5].Err)
+		assert.Equal(t, int32(6), requests.Load())
+	})
+
+	t.Run("fail fast stops at the first failure", func(t *testing.T) {
+		client, tmpDir, requests := setup(t, failFile2)
+
+		results, err := client.Upload(context.Background(), clientcli.UploadOptions{
+			LocalPath:  tmpDir,
+			RemotePath: "uploads",
+			Recursive:  true,
+			FailFast:   true,
+		})
+		require.ErrorIs(t, err, clientcli.ErrUploadAborted)
+		assert.ErrorContains(t, err, "uploads/file2.txt")
+		assert.Equal(t, int32(3), requests.Load())
+
+		require.Len(t, results, 4, "the walk stops after the upload it was waiting to start")
+		assert.NoError(t, results[0].Err)
+		assert.NoError(t, results[1].Err)
+		assert.Error(t, results[2].Err)
+		assert.NoError(t, results[3].Err)
+		assert.True(t, results[3].Skipped)
+		assert.Equal(t, clientcli.SkipReasonAborted, results[3].SkipReason)
+	})
+
+	t.Run("cancellation returns the results so far", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		client, tmpDir, requests := setup(t, func(_ http.ResponseWriter, r *http.Request) bool {
+			if r.URL.Path == "/uploads/file1.txt" {
+				cancel()
+			}
+			return false
+		})
+
+		results, err := client.Upload(ctx, clientcli.UploadOptions{
+			LocalPath:  tmpDir,
+			RemotePath: "uploads",
+			Recursive:  true,
+		})
+		require.ErrorIs(t, err, context.Canceled)
+		assert.NotErrorIs(t, err, clientcli.ErrUploadAborted)
+		assert.Equal(t, int32(2), requests.Load())
+		require.NotEmpty(t, results)
+		assert.Less(t, len(results), 6)
+		assert.NoError(t, results[0].Err)
+		assert.Equal(t, "/uploads/file0.txt", results[0].RemotePath)
+	})
+}
+
+func TestClient_Upload_Recursive_RemovedDuringUpload(t *testing.T) {
+	// With one upload at a time, the walk has listed b.txt and sub/ but not
+	// opened them while a.txt is sent; the server removes both then
+	setup := func(t *testing.T) (*clientcli.Client, string) {
+		t.Helper()
+		tmpDir := t.TempDir()
+		require.NoError(t, os.WriteFile(filepath.Join(tmpDir, "a.txt"), []byte("a"), 0o600))
+		require.NoError(t, os.WriteFile(filepath.Join(tmpDir, "b.txt"), []byte("b"), 0o600))
+		require.NoError(t, os.MkdirAll(filepath.Join(tmpDir, "sub"), 0o750))
+		require.NoError(t, os.WriteFile(filepath.Join(tmpDir, "sub", "c.txt"), []byte("c"), 0o600))
+
+		echo := uploadEchoHandler(t)
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == "/uploads/a.txt" {
+				assert.NoError(t, os.Remove(filepath.Join(tmpDir, "b.txt")))
+				assert.NoError(t, os.RemoveAll(filepath.Join(tmpDir, "sub")))
+			}
+			echo(w, r)
+		}))
+		t.Cleanup(server.Close)
+
+		client, err := clientcli.New(&clientcli.Config{Endpoint: server.URL, UploadConcurrency: 1})
+		require.NoError(t, err)
+		return client, tmpDir
+	}
+
+	t.Run("skips removed files and directories", func(t *testing.T) {
+		client, tmpDir := setup(t)
+
+		results, err := client.Upload(context.Background(), clientcli.UploadOptions{
+			LocalPath:  tmpDir,
+			RemotePath: "uploads",
+			Recursive:  true,
+		})
+		require.NoError(t, err)
+		require.Len(t, results, 2)
+		assert.NoError(t, results[0].Err)
+		assert.False(t, results[0].Skipped)
+		assert.NoError(t, results[1].Err)
+		assert.True(t, results[1].Skipped)
+		assert.Equal(t, clientcli.SkipReasonRemoved, results[1].SkipReason)
+		assert.False(t, clientcli.HasUploadErrors(results))
+	})
+
+	t.Run("strict fails them", func(t *testing.T) {
+		client, tmpDir := setup(t)
+
+		results, err := client.Upload(context.Background(), clientcli.UploadOptions{
+			LocalPath:  tmpDir,
+			RemotePath: "uploads",
+			Recursive:  true,
+			Strict:     true,
+		})
+		assert.ErrorIs(t, err, fs.ErrNotExist, "the removed directory fails the walk")
+		require.Len(t, results, 2)
+		assert.ErrorIs(t, results[1].Err, fs.ErrNotExist)
+		assert.False(t, results[1].Skipped)
+	})
+}
+
+func TestClient_Upload_Recursive_Progress(t *testing.T) {
+	newDir := func(t *testing.T, n int) string {
+		t.Helper()
+		dir := t.TempDir()
+		for i := range n {
+			require.NoError(t, os.WriteFile(filepath.Join(dir, fmt.Sprintf("file%d.txt", i)), []byte("content"), 0o600))
+		}
+		return dir
+	}
+
+	t.Run("reports every file", func(t *testing.T) {
+		server := httptest.NewServer(uploadEchoHandler(t))
+		defer server.Close()
+		client, err := clientcli.New(&clientcli.Config{Endpoint: server.URL, UploadConcurrency: 2})
+		require.NoError(t, err)
+
+		var mu sync.Mutex
+		var last progress.Event
+		_, err = client.Upload(context.Background(), clientcli.UploadOptions{
+			LocalPath:  newDir(t, 3),
+			RemotePath: "uploads",
+			Recursive:  true,
+			Progress: func(e progress.Event) {
+				mu.Lock()
+				defer mu.Unlock()
+				last = e
+			},
+		})
+		require.NoError(t, err)
+
+		assert.True(t, last.Done)
+		assert.Equal(t, "upload", last.Operation)
+		assert.Equal(t, int64(3), last.Processed)
+		assert.Equal(t, int64(3), last.Total)
+		assert.Equal(t, int64(3*len("content")), last.Bytes)
+	})
+
+	t.Run("stops when cancelled from progress", func(t *testing.T) {
+		server := httptest.NewServer(uploadEchoHandler(t))
+		defer server.Close()
+		client, err := clientcli.New(&clientcli.Config{Endpoint: server.URL})
+		require.NoError(t, err)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		results, err := client.Upload(ctx, clientcli.UploadOptions{
+			LocalPath:  newDir(t, 5),
+			RemotePath: "uploads",
+			Recursive:  true,
+			Progress:   func(progress.Event) { cancel() },
+		})
+
+		assert.ErrorIs(t, err, context.Canceled)
+		assert.Less(t, len(results), 5)
+	})
+}
+
+func TestClient_Upload_DeltaFallsBackToPut(t *testing.T) {
+	echo := uploadEchoHandler(t)
+	var methods []string
+	server := httptest.NewServer(withoutCapabilities(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		methods = append(methods, r.Method)
+		if r.Method == http.MethodHead {
+			// An older server: the object exists but no Accept-Patch
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+		echo(w, r)
+	})))
+	defer server.Close()
+
+	localPath := filepath.Join(t.TempDir(), "app.db")
+	require.NoError(t, os.WriteFile(localPath, []byte("content"), 0o600))
+
+	client, err := clientcli.New(&clientcli.Config{Endpoint: server.URL})
+	require.NoError(t, err)
+
+	results, err := client.Upload(context.Background(), clientcli.UploadOptions{LocalPath: localPath, RemotePath: "app.db", Delta: true})
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	assert.Zero(t, results[0].BytesSent)
+	assert.Equal(t, []string{http.MethodHead, http.MethodPut}, methods)
+}
@@ -2,8 +2,11 @@ package clientcli
 
 import (
 	"fmt"
+	"maps"
 	"os"
 	"path/filepath"
+	"strings"
+	"time"
 
 	"gopkg.in/yaml.v3"
 )
@@ -18,6 +21,49 @@ type Profile struct {
 	AccessKey string `yaml:"access_key,omitempty"`
 	SecretKey string `yaml:"secret_key,omitempty"`
 	Default   bool   `yaml:"default,omitempty"`
+
+	// Options are defaults for this profile's operations. Omitted from the
+	// file when unset, so profiles written before it existed round-trip unchanged.
+	Options ProfileOptions `yaml:"options,omitempty"`
+}
+
+// ProfileOptions holds per-profile defaults. Environment variables and
+// command-line flags take precedence over them.
+type ProfileOptions struct {
+	// DefaultPrefix is prepended to every remote path, e.g. "team-a/".
+	DefaultPrefix string `yaml:"default_prefix,omitempty"`
+	// UploadConcurrency is the number of parallel uploads for recursive uploads.
+	UploadConcurrency int `yaml:"upload_concurrency,omitempty"`
+	// VerifyDownloads checks each download against the server's SHA-256 ETag.
+	VerifyDownloads bool `yaml:"verify_downloads,omitempty"`
+	// Timeout is the HTTP client timeout, e.g. "2m".
+	Timeout time.Duration `yaml:"timeout,omitempty"`
+	// ContentTypes maps file extensions (".wasm") to the content type sent on
+	// upload, ahead of the built-in detection.
+	ContentTypes map[string]string `yaml:"content_types,omitempty"`
+
+	// CACertFile is a PEM bundle of CAs trusted for the endpoint, in addition
+	// to the system roots, e.g. an internal corporate CA.
+	CACertFile string `yaml:"ca_cert_file,omitempty"`
+	// ClientCertFile and ClientKeyFile are a PEM certificate and key presented
+	// for mutual TLS. Both or neither must be set.
+	ClientCertFile string `yaml:"client_cert_file,omitempty"`
+	ClientKeyFile  string `yaml:"client_key_file,omitempty"`
+	// InsecureSkipVerify disables verification of the server certificate.
+	// Only for testing: it makes the connection open to interception.
+	InsecureSkipVerify bool `yaml:"insecure_skip_verify,omitempty"`
+
+	// Regions maps the regions of a multi-region deployment to their own
+	// endpoints, for requests pinned to a region; see PinRegion.
+	Regions map[string]string `yaml:"regions,omitempty"`
+}
+
+// IsZero reports whether no option is set.
+func (o ProfileOptions) IsZero() bool {
+	return o.DefaultPrefix == "" && o.UploadConcurrency == 0 && !o.VerifyDownloads &&
+		o.Timeout == 0 && len(o.ContentTypes) == 0 && o.CACertFile == "" &&
+		o.ClientCertFile == "" && o.ClientKeyFile == "" && !o.InsecureSkipVerify &&
+		len(o.Regions) == 0
 }
 
 // ConfigFile holds the full config file structure with multiple profiles.
@@ -180,12 +226,29 @@ type Config struct {
 	Endpoint  string
 	AccessKey string
 	SecretKey string
+
+	DefaultPrefix     string
+	UploadConcurrency int
+	VerifyDownloads   bool
+	Timeout           time.Duration
+	ContentTypes      map[string]string // extension -> content type
+
+	// TLS settings for the endpoint; see ProfileOptions
+	CACertFile         string
+	ClientCertFile     string
+	ClientKeyFile      string
+	InsecureSkipVerify bool
+
+	Regions map[string]string // region -> endpoint; see PinRegion
 }
 
 // Validate checks if required fields are set.
 // Use WithDefaults() to get a config with default values applied.
 func (c *Config) Validate() error {
 	// Validation only - no mutation
+	if (c.ClientCertFile == "") != (c.ClientKeyFile == "") {
+		return ErrClientCertIncomplete
+	}
 	return nil
 }
 
@@ -216,9 +279,21 @@ func ConfigFromProfile(p *Profile) *Config {
 		return &Config{}
 	}
 	return &Config{
-		Endpoint:  p.Endpoint,
-		AccessKey: p.AccessKey,
-		SecretKey: p.SecretKey,
+		Endpoint:          p.Endpoint,
+		AccessKey:         p.AccessKey,
+		SecretKey:         p.SecretKey,
+		DefaultPrefix:     p.Options.DefaultPrefix,
+		UploadConcurrency: p.Options.UploadConcurrency,
+		VerifyDownloads:   p.Options.VerifyDownloads,
+		Timeout:           p.Options.Timeout,
+		ContentTypes:      maps.Clone(p.Options.ContentTypes),
+
+		CACertFile:         p.Options.CACertFile,
+		ClientCertFile:     p.Options.ClientCertFile,
+		ClientKeyFile:      p.Options.ClientKeyFile,
+		InsecureSkipVerify: p.Options.InsecureSkipVerify,
+
+		Regions: maps.Clone(p.Options.Regions),
 	}
 }
 
@@ -242,6 +317,8 @@ func ConfigPathFromEnv() string {
 }
 
 // MergeConfig merges multiple configs, with later configs taking precedence.
+// Zero values do not override; VerifyDownloads and InsecureSkipVerify can
+// only be switched on, and ContentTypes and Regions are merged per key.
 // Empty strings in later configs do not override non-empty values in earlier configs.
 func MergeConfig(configs ...*Config) *Config {
 	result := &Config{}
@@ -258,6 +335,53 @@ func MergeConfig(configs ...*Config) *Config {
 		if cfg.SecretKey != "" {
 			result.SecretKey = cfg.SecretKey
 		}
+		if cfg.DefaultPrefix != "" {
+			result.DefaultPrefix = cfg.DefaultPrefix
+		}
+		if cfg.UploadConcurrency > 0 {
+			result.UploadConcurrency = cfg.UploadConcurrency
+		}
+		if cfg.VerifyDownloads {
+			result.VerifyDownloads = true
+		}
+		if cfg.Timeout > 0 {
+			result.Timeout = cfg.Timeout
+		}
+		for ext, contentType := range cfg.ContentTypes {
+			if result.ContentTypes == nil {
+				result.ContentTypes = make(map[string]string)
+			}
+			result.ContentTypes[ext] = contentType
+		}
+		if cfg.CACertFile != "" {
+			result.CACertFile = cfg.CACertFile
+		}
+		if cfg.ClientCertFile != "" {
+			result.ClientCertFile = cfg.ClientCertFile
+		}
+		if cfg.ClientKeyFile != "" {
+			result.ClientKeyFile = cfg.ClientKeyFile
+		}
+		if cfg.InsecureSkipVerify {
+			result.InsecureSkipVerify = true
+		}
+		for region, endpoint := range cfg.Regions {
+			if result.Regions == nil {
+				result.Regions = make(map[string]string)
+			}
+			result.Regions[region] = endpoint
+		}
 	}
 	return result
 }
+
+// RemotePath joins the default prefix in front of a remote path. Paths are
+// returned without a leading slash.
+func (c *Config) RemotePath(p string) string {
+	p = strings.TrimPrefix(p, "/")
+	prefix := strings.Trim(c.DefaultPrefix, "/")
+	if prefix == "" {
+		return p
+	}
+	return prefix + "/" + p
+}
@@ -4,6 +4,7 @@ import (
 	"os"
 	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/sagarc03/stowry/clientcli"
 	"github.com/stretchr/testify/assert"
@@ -311,6 +312,75 @@ func TestLoadConfigFile_Errors(t *testing.T) {
 	})
 }
 
+func TestLoadConfigFile_WithoutOptions_RoundTrip(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "config.yaml")
+
+	// A file written before profile options existed
+	content := `profiles:
+    - name: a
+      endpoint: http://x
+      access_key: k
+      secret_key: s
+      default: true
+    - name: b
+      endpoint: http://y
+`
+	require.NoError(t, os.WriteFile(configPath, []byte(content), 0o600))
+
+	cfg, err := clientcli.LoadConfigFile(configPath)
+	require.NoError(t, err)
+	assert.True(t, cfg.Profiles[0].Options.IsZero())
+
+	require.NoError(t, cfg.Save(configPath))
+	saved, err := os.ReadFile(configPath)
+	require.NoError(t, err)
+	assert.Equal(t, content, string(saved))
+}
+
+func TestLoadConfigFile_WithOptions(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "config.yaml")
+
+	content := `profiles:
+  - name: team
+    endpoint: https://stowry.example.com
+    options:
+      default_prefix: team-a/
+      upload_concurrency: 8
+      verify_downloads: true
+      timeout: 2m
+      content_types:
+        .wasm: application/wasm
+      ca_cert_file: /etc/ssl/corp-ca.pem
+      client_cert_file: /etc/ssl/client.pem
+      client_key_file: /etc/ssl/client-key.pem
+      insecure_skip_verify: true
+      regions:
+        eu-west-1: https://eu.files.example.com
+`
+	require.NoError(t, os.WriteFile(configPath, []byte(content), 0o600))
+
+	cfg, err := clientcli.LoadConfigFile(configPath)
+	require.NoError(t, err)
+
+	opts := cfg.Profiles[0].Options
+	assert.Equal(t, "team-a/", opts.DefaultPrefix)
+	assert.Equal(t, 8, opts.UploadConcurrency)
+	assert.True(t, opts.VerifyDownloads)
+	assert.Equal(t, 2*time.Minute, opts.Timeout)
+	assert.Equal(t, map[string]string{".wasm": "application/wasm"}, opts.ContentTypes)
+	assert.Equal(t, "/etc/ssl/corp-ca.pem", opts.CACertFile)
+	assert.Equal(t, "/etc/ssl/client.pem", opts.ClientCertFile)
+	assert.Equal(t, "/etc/ssl/client-key.pem", opts.ClientKeyFile)
+	assert.True(t, opts.InsecureSkipVerify)
+	assert.Equal(t, map[string]string{"eu-west-1": "https://eu.files.example.com"}, opts.Regions)
+
+	// Saving keeps the options readable
+	require.NoError(t, cfg.Save(configPath))
+	reloaded, err := clientcli.LoadConfigFile(configPath)
+	require.NoError(t, err)
+	assert.Equal(t, opts, reloaded.Profiles[0].Options)
+}
+
 func TestConfig_Validate(t *testing.T) {
 	t.Run("valid config", func(t *testing.T) {
 		cfg := &clientcli.Config{Endpoint: "http://localhost:5708"}
@@ -325,6 +395,12 @@ func TestConfig_Validate(t *testing.T) {
 		assert.NoError(t, err)
 		assert.Equal(t, "", cfg.Endpoint) // Validate no longer mutates
 	})
+
+	t.Run("client certificate needs its key", func(t *testing.T) {
+		assert.ErrorIs(t, (&clientcli.Config{ClientCertFile: "/c.pem"}).Validate(), clientcli.ErrClientCertIncomplete)
+		assert.ErrorIs(t, (&clientcli.Config{ClientKeyFile: "/k.pem"}).Validate(), clientcli.ErrClientCertIncomplete)
+		assert.NoError(t, (&clientcli.Config{ClientCertFile: "/c.pem", ClientKeyFile: "/k.pem"}).Validate())
+	})
 }
 
 func TestConfig_WithDefaults(t *testing.T) {
@@ -401,6 +477,48 @@ func TestConfigFromProfile(t *testing.T) {
 		assert.Equal(t, "secret", cfg.SecretKey)
 	})
 
+	t.Run("carries profile options", func(t *testing.T) {
+		p := &clientcli.Profile{
+			Name: "test",
+			Options: clientcli.ProfileOptions{
+				DefaultPrefix:     "team/",
+				UploadConcurrency: 4,
+				VerifyDownloads:   true,
+				Timeout:           time.Minute,
+				ContentTypes:      map[string]string{".wasm": "application/wasm"},
+			},
+		}
+
+		cfg := clientcli.ConfigFromProfile(p)
+		assert.Equal(t, "team/", cfg.DefaultPrefix)
+		assert.Equal(t, 4, cfg.UploadConcurrency)
+		assert.True(t, cfg.VerifyDownloads)
+		assert.Equal(t, time.Minute, cfg.Timeout)
+		assert.Equal(t, map[string]string{".wasm": "application/wasm"}, cfg.ContentTypes)
+
+		// The config owns its map
+		cfg.ContentTypes[".js"] = "text/javascript"
+		assert.Len(t, p.Options.ContentTypes, 1)
+	})
+
+	t.Run("carries TLS options", func(t *testing.T) {
+		p := &clientcli.Profile{
+			Name: "test",
+			Options: clientcli.ProfileOptions{
+				CACertFile:         "/ca.pem",
+				ClientCertFile:     "/client.pem",
+				ClientKeyFile:      "/client-key.pem",
+				InsecureSkipVerify: true,
+			},
+		}
+
+		cfg := clientcli.ConfigFromProfile(p)
+		assert.Equal(t, "/ca.pem", cfg.CACertFile)
+		assert.Equal(t, "/client.pem", cfg.ClientCertFile)
+		assert.Equal(t, "/client-key.pem", cfg.ClientKeyFile)
+		assert.True(t, cfg.InsecureSkipVerify)
+	})
+
 	t.Run("nil profile returns empty config", func(t *testing.T) {
 		cfg := clientcli.ConfigFromProfile(nil)
 		assert.Equal(t, "", cfg.Endpoint)
@@ -452,6 +570,47 @@ func TestMergeConfig(t *testing.T) {
 			},
 			expected: &clientcli.Config{Endpoint: "http://a.com", AccessKey: "key2"},
 		},
+		{
+			name: "flags override profile options",
+			configs: []*clientcli.Config{
+				{DefaultPrefix: "team/", UploadConcurrency: 4, Timeout: time.Minute},
+				{},
+				{DefaultPrefix: "other/", Timeout: 5 * time.Second},
+			},
+			expected: &clientcli.Config{DefaultPrefix: "other/", UploadConcurrency: 4, Timeout: 5 * time.Second},
+		},
+		{
+			name: "verify downloads is sticky",
+			configs: []*clientcli.Config{
+				{VerifyDownloads: true},
+				{VerifyDownloads: false},
+			},
+			expected: &clientcli.Config{VerifyDownloads: true},
+		},
+		{
+			name: "TLS options override and insecure skip verify is sticky",
+			configs: []*clientcli.Config{
+				{CACertFile: "/a.pem", InsecureSkipVerify: true},
+				{CACertFile: "/b.pem", ClientCertFile: "/c.pem", ClientKeyFile: "/k.pem"},
+			},
+			expected: &clientcli.Config{CACertFile: "/b.pem", ClientCertFile: "/c.pem", ClientKeyFile: "/k.pem", InsecureSkipVerify: true},
+		},
+		{
+			name: "content types merge per extension",
+			configs: []*clientcli.Config{
+				{ContentTypes: map[string]string{".wasm": "application/wasm", ".js": "text/javascript"}},
+				{ContentTypes: map[string]string{".js": "application/javascript"}},
+			},
+			expected: &clientcli.Config{ContentTypes: map[string]string{".wasm": "application/wasm", ".js": "application/javascript"}},
+		},
+		{
+			name: "regions merge per region",
+			configs: []*clientcli.Config{
+				{Regions: map[string]string{"eu-west-1": "https://eu.old.example.com", "us-east-1": "https://us.example.com"}},
+				{Regions: map[string]string{"eu-west-1": "https://eu.example.com"}},
+			},
+			expected: &clientcli.Config{Regions: map[string]string{"eu-west-1": "https://eu.example.com", "us-east-1": "https://us.example.com"}},
+		},
 	}
 
 	for _, tt := range tests {
@@ -462,6 +621,28 @@ func TestMergeConfig(t *testing.T) {
 	}
 }
 
+func TestConfig_RemotePath(t *testing.T) {
+	tests := []struct {
+		name   string
+		prefix string
+		path   string
+		want   string
+	}{
+		{name: "no prefix", prefix: "", path: "/a/b.txt", want: "a/b.txt"},
+		{name: "prefix", prefix: "team-a", path: "b.txt", want: "team-a/b.txt"},
+		{name: "prefix slashes trimmed", prefix: "/team-a/", path: "/b.txt", want: "team-a/b.txt"},
+		{name: "empty path lists prefix", prefix: "team-a/", path: "", want: "team-a/"},
+		{name: "trailing slash kept", prefix: "team-a", path: "images/", want: "team-a/images/"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &clientcli.Config{DefaultPrefix: tt.prefix}
+			assert.Equal(t, tt.want, cfg.RemotePath(tt.path))
+		})
+	}
+}
+
 func TestConfigFromEnv(t *testing.T) {
 	// Save original values
 	origEndpoint := os.Getenv("STOWRY_ENDPOINT")
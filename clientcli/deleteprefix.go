@@ -0,0 +1,128 @@
+package clientcli
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// DeletePrefixOptions configures a DeletePrefix call.
+type DeletePrefixOptions struct {
+	// Prefix selects the objects to delete, matched as in List:
+	// "builds/2023/" is a directory, "builds/2023" also matches
+	// "builds/2023-old.zip"
+	Prefix string
+	// DryRun only reports the objects that would be deleted
+	DryRun bool
+	// Force allows an empty Prefix, which deletes every object
+	Force bool
+}
+
+// DeletePrefixResult is the outcome of a DeletePrefix call.
+type DeletePrefixResult struct {
+	Prefix string `json:"prefix"`
+	DryRun bool   `json:"dry_run"`
+	// Deleted counts the objects deleted, or that would be with DryRun
+	Deleted int `json:"deleted"`
+	// Paths are the objects that would be deleted; only set with DryRun,
+	// and left out by a server with listing disabled. The server lists only
+	// the first of them, with PathsTruncated set when there are more.
+	Paths          []string `json:"paths,omitempty"`
+	PathsTruncated bool     `json:"paths_truncated,omitempty"`
+	// Errors are the objects the server failed to delete
+	Errors []DeleteResult `json:"-"`
+}
+
+// serverDeletePrefixResponse mirrors the response to DELETE /?prefix.
+type serverDeletePrefixResponse struct {
+	Deleted        int      `json:"deleted"`
+	Paths          []string `json:"paths"`
+	PathsTruncated bool     `json:"paths_truncated"`
+	Errors         []struct {
+		Path    string `json:"path"`
+		Error   string `json:"error"`
+		Message string `json:"message"`
+	} `json:"errors"`
+}
+
+// DeletePrefix deletes every object under opts.Prefix with a single request
+// to the server (store mode only, with write credentials), which pages
+// through the objects itself. Objects that fail to delete are reported in
+// the result, not as an error.
+//
+// It returns ErrEmptyPath for an empty prefix without opts.Force, and
+// ErrDeletePrefixUnsupported when the server cannot delete a prefix,
+// without a request when its capabilities say so.
+func (c *Client) DeletePrefix(ctx context.Context, opts DeletePrefixOptions) (*DeletePrefixResult, error) {
+	if opts.Prefix == "" && !opts.Force {
+		return nil, fmt.Errorf("delete prefix: %w", ErrEmptyPath)
+	}
+	if c.capabilities(ctx).rulesOut("delete_prefix") {
+		return nil, fmt.Errorf("delete prefix: %w", ErrDeletePrefixUnsupported)
+	}
+
+	query := url.Values{"prefix": {opts.Prefix}}
+	if opts.DryRun {
+		query.Set("dry_run", "true")
+	}
+	if opts.Force {
+		query.Set("force", "true")
+	}
+	if !opts.DryRun {
+		defer c.cache.purgePrefix(strings.TrimPrefix(opts.Prefix, "/"))
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodDelete, c.presign(http.MethodDelete, "/", query, DefaultExpires), http.NoBody)
+	if err != nil {
+		return nil, fmt.Errorf("create request: %w", err)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("do request: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("read response: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, parseDeletePrefixError(resp.StatusCode, body)
+	}
+
+	var deleted serverDeletePrefixResponse
+	if err := json.Unmarshal(body, &deleted); err != nil {
+		return nil, fmt.Errorf("parse response: %w", err)
+	}
+
+	result := &DeletePrefixResult{Prefix: opts.Prefix, DryRun: opts.DryRun, Deleted: deleted.Deleted, Paths: deleted.Paths, PathsTruncated: deleted.PathsTruncated}
+	for _, e := range deleted.Errors {
+		result.Errors = append(result.Errors, DeleteResult{Path: e.Path, Err: fmt.Errorf("%s: %s", e.Error, e.Message)})
+	}
+	return result, nil
+}
+
+// parseDeletePrefixError converts a failed delete prefix response to an
+// error, ErrDeletePrefixUnsupported when the server cannot delete a prefix.
+// Older servers take DELETE / for an object with an empty path and reject it
+// as invalid_path.
+func parseDeletePrefixError(statusCode int, body []byte) error {
+	switch statusCode {
+	case http.StatusMethodNotAllowed, http.StatusNotImplemented:
+		return ErrDeletePrefixUnsupported
+	case http.StatusBadRequest:
+		var resp struct {
+			Error string `json:"error"`
+		}
+		if json.Unmarshal(body, &resp) == nil && resp.Error == "invalid_path" {
+			return ErrDeletePrefixUnsupported
+		}
+	}
+	return parseServerError(statusCode, body)
+}
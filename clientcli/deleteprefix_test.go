@@ -0,0 +1,130 @@
+package clientcli_test
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	stowrycore "github.com/sagarc03/stowry"
+	"github.com/sagarc03/stowry/clientcli"
+	"github.com/sagarc03/stowry/keybackend"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClient_DeletePrefix(t *testing.T) {
+	t.Run("sends one request and parses the summary", func(t *testing.T) {
+		server := httptest.NewServer(withoutCapabilities(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, http.MethodDelete, r.Method)
+			assert.Equal(t, "/", r.URL.Path)
+			assert.Equal(t, "builds/2023/", r.URL.Query().Get("prefix"))
+			assert.False(t, r.URL.Query().Has("dry_run"))
+			assert.False(t, r.URL.Query().Has("force"))
+			_, _ = w.Write([]byte(`{"prefix":"builds/2023/","dry_run":false,"deleted":2,` +
+				`"errors":[{"path":"builds/2023/c.zip","error":"internal_error","message":"Internal server error"}]}`))
+		})))
+		defer server.Close()
+
+		client, err := clientcli.New(&clientcli.Config{Endpoint: server.URL})
+		require.NoError(t, err)
+
+		result, err := client.DeletePrefix(t.Context(), clientcli.DeletePrefixOptions{Prefix: "builds/2023/"})
+		require.NoError(t, err)
+		assert.Equal(t, 2, result.Deleted)
+		assert.False(t, result.DryRun)
+		require.Len(t, result.Errors, 1)
+		assert.Equal(t, "builds/2023/c.zip", result.Errors[0].Path)
+		assert.ErrorContains(t, result.Errors[0].Err, "internal_error")
+	})
+
+	t.Run("dry run", func(t *testing.T) {
+		verifier := stowrycore.NewStowrySignatureVerifier(keybackend.NewMapSecretStore(map[string]string{"test-key": "test-secret"}))
+		server := httptest.NewServer(withoutCapabilities(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, "true", r.URL.Query().Get("dry_run"))
+			assert.NoError(t, verifier.Verify(r), "the signature covers the prefix and dry run")
+			_, _ = w.Write([]byte(`{"prefix":"a/","dry_run":true,"deleted":3,"paths":["a/x.txt"],"paths_truncated":true}`))
+		})))
+		defer server.Close()
+
+		client, err := clientcli.New(&clientcli.Config{Endpoint: server.URL, AccessKey: "test-key", SecretKey: "test-secret"})
+		require.NoError(t, err)
+
+		result, err := client.DeletePrefix(t.Context(), clientcli.DeletePrefixOptions{Prefix: "a/", DryRun: true})
+		require.NoError(t, err)
+		assert.True(t, result.DryRun)
+		assert.Equal(t, []string{"a/x.txt"}, result.Paths)
+		assert.True(t, result.PathsTruncated)
+	})
+
+	t.Run("empty prefix", func(t *testing.T) {
+		client, err := clientcli.New(&clientcli.Config{Endpoint: "http://localhost"})
+		require.NoError(t, err)
+
+		_, err = client.DeletePrefix(t.Context(), clientcli.DeletePrefixOptions{})
+		assert.ErrorIs(t, err, clientcli.ErrEmptyPath)
+	})
+
+	t.Run("empty prefix with force", func(t *testing.T) {
+		server := httptest.NewServer(withoutCapabilities(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, "true", r.URL.Query().Get("force"))
+			_, _ = w.Write([]byte(`{"prefix":"","dry_run":false,"deleted":3}`))
+		})))
+		defer server.Close()
+
+		client, err := clientcli.New(&clientcli.Config{Endpoint: server.URL})
+		require.NoError(t, err)
+
+		result, err := client.DeletePrefix(t.Context(), clientcli.DeletePrefixOptions{Force: true})
+		require.NoError(t, err)
+		assert.Equal(t, 3, result.Deleted)
+	})
+
+	t.Run("unauthorized", func(t *testing.T) {
+		server := httptest.NewServer(withoutCapabilities(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			w.WriteHeader(http.StatusUnauthorized)
+			_, _ = w.Write([]byte(`{"error":"unauthorized","message":"Unauthorized"}`))
+		})))
+		defer server.Close()
+
+		client, err := clientcli.New(&clientcli.Config{Endpoint: server.URL})
+		require.NoError(t, err)
+
+		_, err = client.DeletePrefix(t.Context(), clientcli.DeletePrefixOptions{Prefix: "a/"})
+		var apiErr *clientcli.APIError
+		require.True(t, errors.As(err, &apiErr))
+		assert.Equal(t, http.StatusUnauthorized, apiErr.StatusCode)
+	})
+
+	t.Run("server without delete_prefix in its capabilities", func(t *testing.T) {
+		fake := &capabilityServer{document: storeCapabilities}
+		client := newCapabilityClient(t, fake)
+
+		_, err := client.DeletePrefix(t.Context(), clientcli.DeletePrefixOptions{Prefix: "a/"})
+		require.ErrorIs(t, err, clientcli.ErrDeletePrefixUnsupported)
+		assert.Equal(t, []string{"GET /.well-known/stowry"}, fake.recorded())
+	})
+
+	for name, tc := range map[string]struct {
+		status int
+		body   string
+	}{
+		"older server rejecting the empty path": {http.StatusBadRequest, `{"error":"invalid_path","message":"Invalid path"}`},
+		"server in static mode":                 {http.StatusMethodNotAllowed, ""},
+		"service without prefix deletes":        {http.StatusNotImplemented, `{"error":"not_implemented","message":"Not implemented"}`},
+	} {
+		t.Run(name, func(t *testing.T) {
+			server := httptest.NewServer(withoutCapabilities(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+				w.WriteHeader(tc.status)
+				_, _ = w.Write([]byte(tc.body))
+			})))
+			defer server.Close()
+
+			client, err := clientcli.New(&clientcli.Config{Endpoint: server.URL})
+			require.NoError(t, err)
+
+			_, err = client.DeletePrefix(t.Context(), clientcli.DeletePrefixOptions{Prefix: "a/"})
+			assert.ErrorIs(t, err, clientcli.ErrDeletePrefixUnsupported)
+		})
+	}
+}
@@ -0,0 +1,225 @@
+package clientcli
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// deltaContentType is the PATCH body type a server lists in Accept-Patch when
+// it supports delta uploads.
+const deltaContentType = "application/vnd.stowry.delta"
+
+// errDeltaUnsupported makes uploadDelta fall back to a plain PUT.
+var errDeltaUnsupported = errors.New("delta upload not supported")
+
+// serverSignature mirrors the block signature returned by GET ?signature.
+type serverSignature struct {
+	Version   int      `json:"version"`
+	ETag      string   `json:"etag"`
+	Size      int64    `json:"size"`
+	BlockSize int      `json:"block_size"`
+	Blocks    []string `json:"blocks"`
+}
+
+// deltaManifest mirrors the manifest line of a delta upload body.
+type deltaManifest struct {
+	BaseETag        string    `json:"base_etag"`
+	Size            int64     `json:"size"`
+	ContentType     string    `json:"content_type,omitempty"`
+	ContentEncoding string    `json:"content_encoding,omitempty"`
+	Ops             []patchOp `json:"ops"`
+}
+
+type patchOp struct {
+	Source string `json:"source"`
+	Offset int64  `json:"offset,omitempty"`
+	Length int64  `json:"length"`
+}
+
+// uploadDelta uploads only the blocks of file that differ from the stored
+// object. It returns errDeltaUnsupported when the server does not offer delta
+// uploads, the object does not exist yet, or no block can be reused.
+func (c *Client) uploadDelta(ctx context.Context, file *os.File, size int64, remotePath, contentType, contentEncoding string, blockSize int) (serverMetaData, int64, error) {
+	supported, err := c.deltaSupported(ctx, remotePath)
+	if err != nil || !supported {
+		return serverMetaData{}, 0, errors.Join(errDeltaUnsupported, err)
+	}
+
+	sig, err := c.fetchSignature(ctx, remotePath, blockSize)
+	if err != nil {
+		return serverMetaData{}, 0, err
+	}
+
+	manifest, dataSize, err := buildDeltaManifest(file, size, sig)
+	if err != nil {
+		return serverMetaData{}, 0, err
+	}
+	if dataSize == size && size > 0 {
+		// Nothing to reuse; a plain PUT is cheaper
+		return serverMetaData{}, 0, errDeltaUnsupported
+	}
+	manifest.ContentType = contentType
+	manifest.ContentEncoding = contentEncoding
+
+	line, err := json.Marshal(manifest)
+	if err != nil {
+		return serverMetaData{}, 0, fmt.Errorf("encode delta manifest: %w", err)
+	}
+	line = append(line, '\n')
+
+	readers := []io.Reader{bytes.NewReader(line)}
+	var pos int64
+	for _, op := range manifest.Ops {
+		if op.Source == "data" {
+			readers = append(readers, io.NewSectionReader(file, pos, op.Length))
+		}
+		pos += op.Length
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPatch, c.presign(http.MethodPatch, remotePath, nil, DefaultExpires), io.MultiReader(readers...))
+	if err != nil {
+		return serverMetaData{}, 0, fmt.Errorf("create request: %w", err)
+	}
+	req.Header.Set("Content-Type", deltaContentType)
+	req.ContentLength = int64(len(line)) + dataSize
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return serverMetaData{}, 0, fmt.Errorf("do request: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return serverMetaData{}, 0, fmt.Errorf("read response: %w", err)
+	}
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusMethodNotAllowed, http.StatusUnsupportedMediaType, http.StatusPreconditionFailed:
+		// Unsupported after all, or the object changed since the signature
+		return serverMetaData{}, 0, errDeltaUnsupported
+	default:
+		return serverMetaData{}, 0, parseServerError(resp.StatusCode, body)
+	}
+
+	var meta serverMetaData
+	if err := json.Unmarshal(body, &meta); err != nil {
+		return serverMetaData{}, 0, fmt.Errorf("parse response: %w", err)
+	}
+	meta.region = resp.Header.Get(regionHeader)
+	return meta, req.ContentLength, nil
+}
+
+// deltaSupported checks with HEAD whether the object exists and the server
+// accepts delta PATCH bodies for it.
+func (c *Client) deltaSupported(ctx context.Context, remotePath string) (bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, c.presign(http.MethodHead, remotePath, nil, DefaultExpires), http.NoBody)
+	if err != nil {
+		return false, fmt.Errorf("create request: %w", err)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return false, fmt.Errorf("do request: %w", err)
+	}
+	_ = resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return false, nil
+	}
+	for _, v := range strings.Split(resp.Header.Get("Accept-Patch"), ",") {
+		if strings.EqualFold(strings.TrimSpace(v), deltaContentType) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (c *Client) fetchSignature(ctx context.Context, remotePath string, blockSize int) (serverSignature, error) {
+	query := url.Values{}
+	query.Set("signature", "")
+	if blockSize > 0 {
+		query.Set("block_size", strconv.Itoa(blockSize))
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.presign(http.MethodGet, remotePath, query, DefaultExpires), http.NoBody)
+	if err != nil {
+		return serverSignature{}, fmt.Errorf("create request: %w", err)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return serverSignature{}, fmt.Errorf("do request: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return serverSignature{}, fmt.Errorf("read response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return serverSignature{}, parseServerError(resp.StatusCode, body)
+	}
+
+	var sig serverSignature
+	if err := json.Unmarshal(body, &sig); err != nil {
+		return serverSignature{}, fmt.Errorf("parse signature: %w", err)
+	}
+	if sig.Version != 1 || sig.BlockSize <= 0 {
+		return serverSignature{}, errDeltaUnsupported
+	}
+	return sig, nil
+}
+
+// buildDeltaManifest hashes file in the signature's block size and reuses
+// every block whose hash matches the stored block at the same index. Adjacent
+// ops of the same kind are merged. It returns the manifest and the number of
+// bytes that must be sent.
+func buildDeltaManifest(file io.ReaderAt, size int64, sig serverSignature) (deltaManifest, int64, error) {
+	m := deltaManifest{BaseETag: sig.ETag, Size: size, Ops: []patchOp{}}
+	bs := int64(sig.BlockSize)
+	buf := make([]byte, bs)
+	var dataSize int64
+
+	for i, off := int64(0), int64(0); off < size; i, off = i+1, off+bs {
+		n, err := file.ReadAt(buf[:min(bs, size-off)], off)
+		if err != nil && !errors.Is(err, io.EOF) {
+			return deltaManifest{}, 0, fmt.Errorf("read block %d: %w", i, err)
+		}
+		sum := sha256.Sum256(buf[:n])
+
+		op := patchOp{Source: "data", Length: int64(n)}
+		if i < int64(len(sig.Blocks)) && sig.Blocks[i] == hex.EncodeToString(sum[:]) {
+			op = patchOp{Source: "base", Offset: off, Length: int64(n)}
+		} else {
+			dataSize += int64(n)
+		}
+		m.Ops = appendPatchOp(m.Ops, op)
+	}
+
+	return m, dataSize, nil
+}
+
+// appendPatchOp appends op, extending the last op when op continues it.
+func appendPatchOp(ops []patchOp, op patchOp) []patchOp {
+	if len(ops) > 0 {
+		last := &ops[len(ops)-1]
+		if last.Source == op.Source && (op.Source == "data" || last.Offset+last.Length == op.Offset) {
+			last.Length += op.Length
+			return ops
+		}
+	}
+	return append(ops, op)
+}
@@ -40,6 +40,19 @@
 //	cfg := clientcli.ConfigFromProfile(profile)
 //	client, err := clientcli.New(cfg)
 //
+// # Response Caching
+//
+// Services that read the same small objects repeatedly can cache them. Fetch
+// serves a cached body until TTL passes, then revalidates it with
+// If-None-Match so an unchanged object costs a 304 instead of a download:
+//
+//	client, err := clientcli.New(cfg, clientcli.WithCache(clientcli.CacheConfig{
+//		MaxEntries: 100,
+//		TTL:        10 * time.Second,
+//	}))
+//
+//	body, result, err := client.Fetch(ctx, "config/app.json")
+//
 // # Output Formatting
 //
 // Use formatters for human-readable or JSON output:
@@ -0,0 +1,138 @@
+package clientcli
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/sync/errgroup"
+)
+
+// DefaultDownloadConcurrency is how many files a Recursive download fetches
+// at once when DownloadOptions.Concurrency is 0.
+const DefaultDownloadConcurrency = 4
+
+// DownloadAll downloads opts.RemotePath like Download, writing it to a local
+// file, or with opts.Recursive every object under it.
+//
+// A recursive download lists the objects under the RemotePath prefix, which
+// is treated as a directory ("builds/2023" does not match
+// "builds/2023-old.zip"), and writes each below opts.LocalPath at its path
+// relative to the prefix, creating directories as needed. LocalPath defaults
+// to the prefix's last segment. Up to opts.Concurrency files are fetched at
+// once. A local file that already has an object's size and SHA-256 is not
+// fetched again and is reported as Skipped. Files that fail are reported with
+// Err set; results keep list order.
+//
+// It returns ErrRecursiveUnsupported when the server is not in store mode,
+// ErrListDisabled when it has listing turned off and ErrDownloadAllStdout
+// when LocalPath is "-".
+func (c *Client) DownloadAll(ctx context.Context, opts DownloadOptions) ([]DownloadResult, error) {
+	if opts.LocalPath == "-" {
+		return nil, fmt.Errorf("download: %w", ErrDownloadAllStdout)
+	}
+	if !opts.Recursive {
+		result, _, err := c.Download(ctx, opts)
+		if err != nil {
+			return nil, err
+		}
+		return []DownloadResult{*result}, nil
+	}
+
+	if caps := c.capabilities(ctx); !caps.Offline && caps.Mode != "store" {
+		return nil, fmt.Errorf("download: %w", ErrRecursiveUnsupported)
+	}
+
+	prefix := strings.TrimPrefix(opts.RemotePath, "/")
+	if prefix != "" && !strings.HasSuffix(prefix, "/") {
+		prefix += "/"
+	}
+	baseDir := opts.LocalPath
+	if baseDir == "" {
+		baseDir = filepath.Base(strings.TrimSuffix(prefix, "/"))
+	}
+
+	listed, err := c.List(ctx, ListOptions{Prefix: prefix, All: true, Deleted: opts.Deleted})
+	if err != nil {
+		return nil, fmt.Errorf("download: %w", err)
+	}
+
+	concurrency := opts.Concurrency
+	if concurrency <= 0 {
+		concurrency = DefaultDownloadConcurrency
+	}
+
+	var g errgroup.Group
+	g.SetLimit(concurrency)
+
+	// Each download writes only its own slot
+	results := make([]DownloadResult, len(listed.Items))
+	for i := range listed.Items {
+		item := &listed.Items[i]
+		slot := &results[i]
+		slot.RemotePath = item.Path
+
+		relPath := strings.TrimPrefix(item.Path, prefix)
+		if !filepath.IsLocal(filepath.FromSlash(relPath)) {
+			slot.Err = fmt.Errorf("download %s: path leaves %s", item.Path, baseDir)
+			continue
+		}
+		localPath := filepath.Join(baseDir, filepath.FromSlash(relPath))
+		slot.LocalPath = localPath
+
+		g.Go(func() error {
+			if ctxErr := ctx.Err(); ctxErr != nil {
+				slot.Err = ctxErr
+				return nil
+			}
+			if localMatches(localPath, item, opts.Raw) {
+				slot.ETag, slot.ContentType, slot.Size = item.ETag, item.ContentType, item.Size
+				slot.Skipped, slot.SkipReason = true, SkipReasonUnchanged
+				return nil
+			}
+
+			fileOpts := opts
+			fileOpts.RemotePath = item.Path
+			fileOpts.LocalPath = localPath
+			fileOpts.Recursive = false
+			result, _, downloadErr := c.Download(ctx, fileOpts)
+			if downloadErr != nil {
+				slot.Err = downloadErr
+				return nil
+			}
+			*slot = *result
+			return nil
+		})
+	}
+
+	_ = g.Wait()
+	return results, nil
+}
+
+// localMatches reports whether the file at localPath already holds item's
+// content: the same size and a SHA-256 equal to its ETag. Objects stored
+// with a Content-Encoding are decoded on download unless raw, so their local
+// file cannot be compared and never matches.
+func localMatches(localPath string, item *ObjectInfo, raw bool) bool {
+	if item.ContentEncoding != "" && !raw {
+		return false
+	}
+	info, err := os.Stat(localPath)
+	if err != nil || !info.Mode().IsRegular() || info.Size() != item.Size {
+		return false
+	}
+	sum, err := hashFile(localPath)
+	return err == nil && sum == item.ETag
+}
+
+// HasDownloadErrors returns true if any file of a download failed.
+func HasDownloadErrors(results []DownloadResult) bool {
+	for i := range results {
+		if results[i].Err != nil {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,157 @@
+package clientcli_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/sagarc03/stowry/clientcli"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// treeServer serves a list of objects and their content, recording the
+// paths downloaded.
+type treeServer struct {
+	objects map[string]string
+
+	mu         sync.Mutex
+	downloaded []string
+}
+
+func (s *treeServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == "/" {
+		prefix := r.URL.Query().Get("prefix")
+		var items []map[string]any
+		for _, path := range []string{"builds/2023/a.zip", "builds/2023/nested/b.zip", "builds/2023-old.zip"} {
+			if content, ok := s.objects[path]; ok && strings.HasPrefix(path, prefix) {
+				items = append(items, map[string]any{"path": path, "etag": sha256Hex([]byte(content)), "file_size_bytes": len(content)})
+			}
+		}
+		_ = json.NewEncoder(w).Encode(map[string]any{"items": items})
+		return
+	}
+
+	path := strings.TrimPrefix(r.URL.Path, "/")
+	content, ok := s.objects[path]
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	s.mu.Lock()
+	s.downloaded = append(s.downloaded, path)
+	s.mu.Unlock()
+	w.Header().Set("ETag", `"`+sha256Hex([]byte(content))+`"`)
+	_, _ = w.Write([]byte(content))
+}
+
+func TestClient_DownloadAll(t *testing.T) {
+	objects := map[string]string{
+		"builds/2023/a.zip":        "aaa",
+		"builds/2023/nested/b.zip": "bbbb",
+		"builds/2023-old.zip":      "old",
+	}
+
+	t.Run("downloads the prefix as a directory", func(t *testing.T) {
+		fake := &treeServer{objects: objects}
+		server := httptest.NewServer(withoutCapabilities(fake))
+		defer server.Close()
+		client, err := clientcli.New(&clientcli.Config{Endpoint: server.URL})
+		require.NoError(t, err)
+		dir := filepath.Join(t.TempDir(), "out")
+
+		results, err := client.DownloadAll(t.Context(), clientcli.DownloadOptions{
+			RemotePath: "builds/2023", LocalPath: dir, Recursive: true, Concurrency: 2,
+		})
+		require.NoError(t, err)
+		require.Len(t, results, 2)
+		assert.False(t, clientcli.HasDownloadErrors(results))
+		assert.Equal(t, "builds/2023/a.zip", results[0].RemotePath)
+		assert.Equal(t, filepath.Join(dir, "a.zip"), results[0].LocalPath)
+		assert.Equal(t, filepath.Join(dir, "nested", "b.zip"), results[1].LocalPath)
+
+		got, err := os.ReadFile(filepath.Join(dir, "nested", "b.zip"))
+		require.NoError(t, err)
+		assert.Equal(t, "bbbb", string(got))
+		assert.NoFileExists(t, filepath.Join(dir, "..", "2023-old.zip"))
+	})
+
+	t.Run("skips files that are up to date", func(t *testing.T) {
+		fake := &treeServer{objects: objects}
+		server := httptest.NewServer(withoutCapabilities(fake))
+		defer server.Close()
+		client, err := clientcli.New(&clientcli.Config{Endpoint: server.URL})
+		require.NoError(t, err)
+		dir := t.TempDir()
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "a.zip"), []byte("aaa"), 0o600))
+		require.NoError(t, os.MkdirAll(filepath.Join(dir, "nested"), 0o750))
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "nested", "b.zip"), []byte("xxxx"), 0o600))
+
+		results, err := client.DownloadAll(t.Context(), clientcli.DownloadOptions{
+			RemotePath: "builds/2023/", LocalPath: dir, Recursive: true,
+		})
+		require.NoError(t, err)
+		require.Len(t, results, 2)
+		assert.True(t, results[0].Skipped)
+		assert.Equal(t, clientcli.SkipReasonUnchanged, results[0].SkipReason)
+		assert.False(t, results[1].Skipped, "same size, different content")
+		assert.Equal(t, []string{"builds/2023/nested/b.zip"}, fake.downloaded)
+
+		got, err := os.ReadFile(filepath.Join(dir, "nested", "b.zip"))
+		require.NoError(t, err)
+		assert.Equal(t, "bbbb", string(got))
+	})
+
+	t.Run("reports files that fail", func(t *testing.T) {
+		fake := &treeServer{objects: objects}
+		server := httptest.NewServer(withoutCapabilities(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == "/builds/2023/a.zip" {
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+			fake.ServeHTTP(w, r)
+		})))
+		defer server.Close()
+		client, err := clientcli.New(&clientcli.Config{Endpoint: server.URL})
+		require.NoError(t, err)
+
+		results, err := client.DownloadAll(t.Context(), clientcli.DownloadOptions{
+			RemotePath: "builds/2023/", LocalPath: t.TempDir(), Recursive: true,
+		})
+		require.NoError(t, err)
+		require.Len(t, results, 2)
+		assert.True(t, clientcli.HasDownloadErrors(results))
+		require.Error(t, results[0].Err)
+		assert.NoError(t, results[1].Err)
+	})
+
+	t.Run("server not in store mode", func(t *testing.T) {
+		fake := &capabilityServer{document: strings.Replace(storeCapabilities, `"mode": "store"`, `"mode": "static"`, 1)}
+		client := newCapabilityClient(t, fake)
+
+		_, err := client.DownloadAll(t.Context(), clientcli.DownloadOptions{RemotePath: "site/", LocalPath: t.TempDir(), Recursive: true})
+		require.ErrorIs(t, err, clientcli.ErrRecursiveUnsupported)
+		assert.Equal(t, []string{"GET /.well-known/stowry"}, fake.recorded())
+	})
+
+	t.Run("stdout", func(t *testing.T) {
+		client, err := clientcli.New(&clientcli.Config{Endpoint: "http://localhost"})
+		require.NoError(t, err)
+
+		_, err = client.DownloadAll(t.Context(), clientcli.DownloadOptions{RemotePath: "a/", LocalPath: "-", Recursive: true})
+		assert.ErrorIs(t, err, clientcli.ErrDownloadAllStdout)
+	})
+
+	t.Run("download rejects recursive", func(t *testing.T) {
+		client, err := clientcli.New(&clientcli.Config{Endpoint: "http://localhost"})
+		require.NoError(t, err)
+
+		_, _, err = client.Download(t.Context(), clientcli.DownloadOptions{RemotePath: "a/", Recursive: true})
+		assert.ErrorIs(t, err, clientcli.ErrRecursiveDownload)
+	})
+}
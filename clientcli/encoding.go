@@ -0,0 +1,74 @@
+package clientcli
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// acceptEncoding is sent on full downloads. Setting it explicitly stops
+// net/http from decoding gzip responses itself, which would hide whether the
+// server compressed the response or stored the object encoded.
+const acceptEncoding = "gzip"
+
+// storedEncoding returns the Content-Encoding an object is stored with, as
+// reported by a response for it. A weak ETag alongside Content-Encoding marks
+// compression the server applied to this response, which is not stored.
+func storedEncoding(resp *http.Response) string {
+	coding := strings.ToLower(strings.TrimSpace(resp.Header.Get("Content-Encoding")))
+	if coding == "" || coding == "identity" || strings.HasPrefix(resp.Header.Get("ETag"), "W/") {
+		return ""
+	}
+	return coding
+}
+
+// responseContent returns the body of a GET response decoded to the object
+// content, or with raw, to the bytes as stored. With verify the SHA-256 ETag
+// is checked against the stored bytes, which for an object stored encoded
+// are the encoded ones.
+func responseContent(resp *http.Response, etag string, verify, raw bool) (io.ReadCloser, error) {
+	coding := strings.ToLower(strings.TrimSpace(resp.Header.Get("Content-Encoding")))
+	if coding == "" || coding == "identity" {
+		if verify {
+			return newVerifyingReader(resp.Body, etag), nil
+		}
+		return resp.Body, nil
+	}
+	if coding != "gzip" && coding != "x-gzip" {
+		return nil, fmt.Errorf("unsupported content encoding %q", coding)
+	}
+
+	stored := storedEncoding(resp) != ""
+	if stored && raw {
+		if verify {
+			return newVerifyingReader(resp.Body, etag), nil
+		}
+		return resp.Body, nil
+	}
+
+	var body io.Reader = resp.Body
+	if stored && verify {
+		body = newVerifyingReader(resp.Body, etag)
+	}
+	zr, err := gzip.NewReader(body)
+	if err != nil {
+		return nil, fmt.Errorf("decode %s response: %w", coding, err)
+	}
+	decoded := io.ReadCloser(decodedBody{Reader: zr, body: resp.Body})
+	if !stored && verify {
+		decoded = newVerifyingReader(decoded, etag)
+	}
+	return decoded, nil
+}
+
+// decodedBody reads decoded content and closes the response body under it.
+type decodedBody struct {
+	io.Reader
+	body io.Closer
+}
+
+func (d decodedBody) Close() error {
+	return d.body.Close()
+}
@@ -0,0 +1,147 @@
+package clientcli_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/sagarc03/stowry/clientcli"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func gzipContent(t *testing.T, content []byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	_, err := zw.Write(content)
+	require.NoError(t, err)
+	require.NoError(t, zw.Close())
+	return buf.Bytes()
+}
+
+func sha256Hex(b []byte) string {
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
+
+// encodedServer serves body with Content-Encoding gzip and etag, recording
+// the Accept-Encoding of each request.
+func encodedServer(t *testing.T, body []byte, etag string) (*clientcli.Client, *string) {
+	t.Helper()
+	var accept string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		accept = r.Header.Get("Accept-Encoding")
+		w.Header().Set("ETag", etag)
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Content-Encoding", "gzip")
+		_, _ = w.Write(body)
+	}))
+	t.Cleanup(server.Close)
+
+	client, err := clientcli.New(&clientcli.Config{Endpoint: server.URL, VerifyDownloads: true})
+	require.NoError(t, err)
+	return client, &accept
+}
+
+func TestClient_Download_StoredEncoding(t *testing.T) {
+	plain := []byte(`{"events":[1,2,3]}`)
+	stored := gzipContent(t, plain)
+	ctx := context.Background()
+
+	t.Run("decoded and verified against the stored bytes", func(t *testing.T) {
+		client, accept := encodedServer(t, stored, `"`+sha256Hex(stored)+`"`)
+
+		localPath := filepath.Join(t.TempDir(), "events.json")
+		result, _, err := client.Download(ctx, clientcli.DownloadOptions{RemotePath: "events.json", LocalPath: localPath})
+		require.NoError(t, err)
+
+		got, err := os.ReadFile(localPath)
+		require.NoError(t, err)
+		assert.Equal(t, plain, got)
+		assert.Equal(t, "gzip", *accept)
+		assert.Equal(t, "gzip", result.ContentEncoding)
+		assert.Equal(t, int64(len(plain)), result.Size)
+	})
+
+	t.Run("raw keeps the stored bytes", func(t *testing.T) {
+		client, _ := encodedServer(t, stored, `"`+sha256Hex(stored)+`"`)
+
+		_, reader, err := client.Download(ctx, clientcli.DownloadOptions{RemotePath: "events.json", LocalPath: "-", Raw: true})
+		require.NoError(t, err)
+		defer func() { _ = reader.Close() }()
+
+		got, err := io.ReadAll(reader)
+		require.NoError(t, err)
+		assert.Equal(t, stored, got)
+	})
+
+	t.Run("corrupted stored bytes", func(t *testing.T) {
+		client, _ := encodedServer(t, stored, `"`+sha256Hex(plain)+`"`)
+
+		localPath := filepath.Join(t.TempDir(), "events.json")
+		_, _, err := client.Download(ctx, clientcli.DownloadOptions{RemotePath: "events.json", LocalPath: localPath})
+		assert.ErrorIs(t, err, clientcli.ErrChecksumMismatch)
+		assert.NoFileExists(t, localPath)
+	})
+
+	t.Run("response compression is verified decoded", func(t *testing.T) {
+		client, _ := encodedServer(t, stored, `W/"`+sha256Hex(plain)+`"`)
+
+		localPath := filepath.Join(t.TempDir(), "events.json")
+		result, _, err := client.Download(ctx, clientcli.DownloadOptions{RemotePath: "events.json", LocalPath: localPath, Raw: true})
+		require.NoError(t, err)
+		assert.Empty(t, result.ContentEncoding)
+
+		got, err := os.ReadFile(localPath)
+		require.NoError(t, err)
+		assert.Equal(t, plain, got)
+	})
+}
+
+func TestClient_Fetch_StoredEncoding(t *testing.T) {
+	plain := []byte(`{"a":1}`)
+	stored := gzipContent(t, plain)
+	client, _ := encodedServer(t, stored, `"`+sha256Hex(stored)+`"`)
+
+	content, result, err := client.Fetch(context.Background(), "a.json")
+	require.NoError(t, err)
+	assert.Equal(t, plain, content)
+	assert.Equal(t, "gzip", result.ContentEncoding)
+}
+
+func TestClient_Upload_ContentEncoding(t *testing.T) {
+	stored := gzipContent(t, []byte("hello"))
+	var header string
+	var body []byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header = r.Header.Get("Content-Encoding")
+		body, _ = io.ReadAll(r.Body)
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"path":"a.txt","content_encoding":"gzip","file_size_bytes":` + strconv.Itoa(len(body)) + `}`))
+	}))
+	defer server.Close()
+
+	client, err := clientcli.New(&clientcli.Config{Endpoint: server.URL, AccessKey: "k", SecretKey: "s"})
+	require.NoError(t, err)
+
+	localPath := filepath.Join(t.TempDir(), "a.txt.gz")
+	require.NoError(t, os.WriteFile(localPath, stored, 0o600))
+
+	results, err := client.Upload(context.Background(), clientcli.UploadOptions{LocalPath: localPath, RemotePath: "a.txt", ContentEncoding: "gzip"})
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	require.NoError(t, results[0].Err)
+	assert.Equal(t, "gzip", header)
+	assert.Equal(t, stored, body, "the file is sent as is")
+	assert.Equal(t, "gzip", results[0].ContentEncoding)
+}
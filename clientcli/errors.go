@@ -14,10 +14,101 @@ var (
 	ErrAccessKeyRequired = errors.New("access key is required")
 	ErrSecretKeyRequired = errors.New("secret key is required")
 	ErrConfigRequired    = errors.New("config is required")
+
+	ErrClientCertIncomplete = errors.New("client certificate and key files must be set together")
+	ErrNoCACertificates     = errors.New("no CA certificates found")
 )
 
 // Errors for input validation.
 var (
-	ErrNoPaths   = errors.New("no paths provided")
-	ErrEmptyPath = errors.New("path is required")
+	ErrNoPaths              = errors.New("no paths provided")
+	ErrEmptyPath            = errors.New("path is required")
+	ErrInvalidPathTemplate  = errors.New("invalid path template")
+	ErrInvalidFilterPattern = errors.New("invalid filter pattern")
+)
+
+// ErrListDisabled is returned by List, ExportCSV and Watch when the server has
+// listing turned off (server.disable_list). Objects can still be read by path,
+// and StatMany checks many known paths at once.
+var ErrListDisabled = errors.New("listing is disabled on this server")
+
+// Errors for CSV list export.
+var (
+	ErrCSVExportUnsupported = errors.New("server does not support CSV export")
+	ErrExportTruncated      = errors.New("export truncated by the server row limit")
+	ErrExportIncomplete     = errors.New("export ended early because of a server error")
+)
+
+// Errors for watching the change feed.
+var (
+	ErrWatchUnsupported = errors.New("server does not support watching changes")
+	ErrCursorExpired    = errors.New("watch cursor expired: changes after it were pruned")
 )
+
+// ErrHistoryUnsupported is returned by History when the server does not
+// serve per-path history.
+var ErrHistoryUnsupported = errors.New("server does not support object history")
+
+// ErrMoveUnsupported is returned by Move when the server cannot move
+// objects.
+var ErrMoveUnsupported = errors.New("server does not support moving objects")
+
+// ErrDeletePrefixUnsupported is returned by DeletePrefix when the server
+// cannot delete a prefix.
+var ErrDeletePrefixUnsupported = errors.New("server does not support deleting a prefix")
+
+// ErrRestoreUnsupported is returned by Restore when the server cannot restore
+// deleted objects.
+var ErrRestoreUnsupported = errors.New("server does not support restoring objects")
+
+// ErrSyncUnsupported is returned by Sync when the server cannot list objects
+// because it is not in store mode.
+var ErrSyncUnsupported = errors.New("sync needs a server in store mode")
+
+// ErrDeletedUnsupported is returned by a download of a deleted object when the
+// server does not serve deleted objects.
+var ErrDeletedUnsupported = errors.New("server does not support downloading deleted objects")
+
+// ErrResumeStdout is returned by a resumable download to stdout, which has
+// no partial file to continue.
+var ErrResumeStdout = errors.New("resume needs a local file, not stdout")
+
+// Errors for DownloadAll and recursive downloads.
+var (
+	ErrRecursiveDownload    = errors.New("recursive downloads return several results; use DownloadAll")
+	ErrDownloadAllStdout    = errors.New("download needs a local path, not stdout")
+	ErrRecursiveUnsupported = errors.New("recursive downloads need a server in store mode")
+)
+
+// ErrChecksumMismatch is returned by a verified download whose content does
+// not hash to the server's ETag.
+var ErrChecksumMismatch = errors.New("download checksum mismatch")
+
+// ErrUploadChecksumMismatch is returned when the server's ETag for an upload
+// differs from the SHA-256 of the content sent, meaning it was corrupted in
+// transit.
+var ErrUploadChecksumMismatch = errors.New("upload checksum mismatch")
+
+// ErrUploadAborted is returned by a FailFast upload stopped by a failed file.
+// The error also carries the file's path and error.
+var ErrUploadAborted = errors.New("upload aborted after a failure")
+
+// ErrAtomicUploadFailed is returned by an atomic upload in which some file
+// failed. The stage is discarded, so no file of the upload was committed.
+var ErrAtomicUploadFailed = errors.New("atomic upload failed; nothing was committed")
+
+// Errors for ranged reads.
+var (
+	// ErrObjectChanged is returned by a RangeReader whose object was replaced
+	// or deleted after it was opened. The error is an *ObjectChangedError.
+	ErrObjectChanged    = errors.New("object changed while reading")
+	ErrRangeUnsupported = errors.New("server does not support range requests")
+)
+
+// ErrStatUnsupported is returned by StatMany when the server has no bulk stat
+// endpoint; callers can fall back to Stat for each path.
+var ErrStatUnsupported = errors.New("server does not support bulk stat")
+
+// ErrCapabilitiesUnsupported is returned by Capabilities, with the default
+// capabilities, when the server does not serve a capability document.
+var ErrCapabilitiesUnsupported = errors.New("server does not serve a capability document")
@@ -0,0 +1,144 @@
+package clientcli
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/sagarc03/stowry/progress"
+)
+
+// exportStatusTrailer mirrors the trailer the server sets after a CSV export.
+const exportStatusTrailer = "X-Stowry-Export-Status"
+
+var listCSVHeader = []string{"path", "size", "content_type", "etag", "created_at", "updated_at"}
+
+// ExportCSV streams every object under opts.Prefix as CSV into w, starting at
+// opts.Cursor. The server paginates internally, so opts.Limit and opts.All are
+// ignored.
+//
+// It returns ErrCSVExportUnsupported without writing anything when the server
+// predates CSV export; callers can fall back to List and WriteListCSV. It
+// returns ErrListDisabled when the server has listing turned off. When the
+// server stops early, the rows received are written and ErrExportTruncated or
+// ErrExportIncomplete is returned. Both errors are returned without a
+// request when the server's capabilities rule the export out.
+func (c *Client) ExportCSV(ctx context.Context, opts ListOptions, w io.Writer) error {
+	caps := c.capabilities(ctx)
+	switch {
+	case !caps.Offline && caps.ListDisabled:
+		return ErrListDisabled
+	case !caps.Offline && !slices.Contains(caps.ListFormats, "csv"):
+		return ErrCSVExportUnsupported
+	}
+
+	presignURL := c.presignList(opts.Prefix, 0, opts.Cursor, DefaultExpires) + "&format=csv"
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, presignURL, http.NoBody)
+	if err != nil {
+		return fmt.Errorf("create request: %w", err)
+	}
+	req.Header.Set("Accept", "text/csv")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("do request: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return parseListError(resp.StatusCode, body)
+	}
+
+	// Older servers ignore format=csv and answer with a JSON page
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if mediaType != "text/csv" {
+		return ErrCSVExportUnsupported
+	}
+
+	if tracker := progress.New("export", opts.Progress); tracker != nil {
+		defer tracker.Finish()
+		w = &exportProgressWriter{w: w, tracker: tracker}
+	}
+
+	if _, err := io.Copy(w, resp.Body); err != nil {
+		return fmt.Errorf("read response: %w", err)
+	}
+
+	switch resp.Trailer.Get(exportStatusTrailer) {
+	case "truncated":
+		return ErrExportTruncated
+	case "error":
+		return ErrExportIncomplete
+	}
+	return nil
+}
+
+// exportProgressWriter reports each CSV row passing through to w, skipping
+// the header. The path is the row's first field.
+type exportProgressWriter struct {
+	w       io.Writer
+	tracker *progress.Tracker
+	line    []byte // partial row carried over from the previous write
+	header  bool   // header row has been seen
+}
+
+func (pw *exportProgressWriter) Write(p []byte) (int, error) {
+	n, err := pw.w.Write(p)
+	rest := p[:n]
+	for {
+		i := bytes.IndexByte(rest, '\n')
+		if i < 0 {
+			break
+		}
+		pw.line = append(pw.line, rest[:i+1]...)
+		rest = rest[i+1:]
+		if pw.header {
+			pw.tracker.Add(csvFirstField(pw.line), int64(len(pw.line)))
+		}
+		pw.header = true
+		pw.line = pw.line[:0]
+	}
+	pw.line = append(pw.line, rest...)
+	return n, err
+}
+
+// csvFirstField returns the first field of a CSV row.
+func csvFirstField(row []byte) string {
+	if !bytes.HasPrefix(row, []byte(`"`)) {
+		field, _, _ := bytes.Cut(row, []byte(","))
+		return string(field)
+	}
+	record, err := csv.NewReader(bytes.NewReader(row)).Read()
+	if err != nil || len(record) == 0 {
+		return ""
+	}
+	return record[0]
+}
+
+// WriteListCSV renders items in the same CSV layout as the server export.
+func WriteListCSV(w io.Writer, items []ObjectInfo) error {
+	cw := csv.NewWriter(w)
+	_ = cw.Write(listCSVHeader)
+	for i := range items {
+		item := &items[i]
+		_ = cw.Write([]string{
+			item.Path,
+			strconv.FormatInt(item.Size, 10),
+			item.ContentType,
+			item.ETag,
+			item.CreatedAt.UTC().Format(time.RFC3339Nano),
+			item.UpdatedAt.UTC().Format(time.RFC3339Nano),
+		})
+	}
+	cw.Flush()
+	return cw.Error()
+}
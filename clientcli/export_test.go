@@ -0,0 +1,128 @@
+package clientcli_test
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/sagarc03/stowry/clientcli"
+	"github.com/sagarc03/stowry/progress"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newExportClient(t *testing.T, handler http.HandlerFunc) *clientcli.Client {
+	t.Helper()
+	server := httptest.NewServer(withoutCapabilities(handler))
+	t.Cleanup(server.Close)
+
+	client, err := clientcli.New(&clientcli.Config{
+		Endpoint:  server.URL,
+		AccessKey: "test-key",
+		SecretKey: "test-secret",
+	})
+	require.NoError(t, err)
+	return client
+}
+
+func csvExportHandler(status string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+		w.Header().Set("Trailer", "X-Stowry-Export-Status")
+		_, _ = io.WriteString(w, "path,size\ninvoices/a.pdf,10\n")
+		w.Header().Set("X-Stowry-Export-Status", status)
+	}
+}
+
+func TestClient_ExportCSV(t *testing.T) {
+	t.Run("streams server csv", func(t *testing.T) {
+		client := newExportClient(t, func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, "csv", r.URL.Query().Get("format"))
+			assert.Equal(t, "invoices/", r.URL.Query().Get("prefix"))
+			csvExportHandler("complete")(w, r)
+		})
+
+		var out bytes.Buffer
+		err := client.ExportCSV(context.Background(), clientcli.ListOptions{Prefix: "invoices/"}, &out)
+		require.NoError(t, err)
+		assert.Equal(t, "path,size\ninvoices/a.pdf,10\n", out.String())
+	})
+
+	t.Run("reports rows as progress", func(t *testing.T) {
+		client := newExportClient(t, func(w http.ResponseWriter, _ *http.Request) {
+			w.Header().Set("Content-Type", "text/csv")
+			_, _ = io.WriteString(w, "path,size\ninvoices/a.pdf,10\n\"invoices/b,c.pdf\",")
+			w.(http.Flusher).Flush()
+			_, _ = io.WriteString(w, "20\n")
+		})
+
+		var last progress.Event
+		opts := clientcli.ListOptions{Progress: func(e progress.Event) { last = e }}
+		err := client.ExportCSV(context.Background(), opts, io.Discard)
+		require.NoError(t, err)
+
+		assert.True(t, last.Done)
+		assert.Equal(t, "export", last.Operation)
+		assert.Equal(t, "invoices/b,c.pdf", last.Path)
+		assert.Equal(t, int64(2), last.Processed, "header is not counted")
+		assert.Equal(t, int64(len("invoices/a.pdf,10\n\"invoices/b,c.pdf\",20\n")), last.Bytes)
+	})
+
+	t.Run("reports truncation", func(t *testing.T) {
+		client := newExportClient(t, csvExportHandler("truncated"))
+
+		var out bytes.Buffer
+		err := client.ExportCSV(context.Background(), clientcli.ListOptions{}, &out)
+		assert.ErrorIs(t, err, clientcli.ErrExportTruncated)
+		assert.NotEmpty(t, out.String())
+	})
+
+	t.Run("reports mid-stream error", func(t *testing.T) {
+		client := newExportClient(t, csvExportHandler("error"))
+
+		err := client.ExportCSV(context.Background(), clientcli.ListOptions{}, io.Discard)
+		assert.ErrorIs(t, err, clientcli.ErrExportIncomplete)
+	})
+
+	t.Run("old server returns json", func(t *testing.T) {
+		client := newExportClient(t, func(w http.ResponseWriter, _ *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = io.WriteString(w, `{"items":[]}`)
+		})
+
+		var out bytes.Buffer
+		err := client.ExportCSV(context.Background(), clientcli.ListOptions{}, &out)
+		assert.ErrorIs(t, err, clientcli.ErrCSVExportUnsupported)
+		assert.Empty(t, out.String())
+	})
+	t.Run("listing disabled", func(t *testing.T) {
+		client := newExportClient(t, func(w http.ResponseWriter, _ *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusForbidden)
+			_, _ = io.WriteString(w, `{"error":"list_disabled","message":"listing is disabled on this server"}`)
+		})
+
+		var out bytes.Buffer
+		err := client.ExportCSV(context.Background(), clientcli.ListOptions{}, &out)
+		assert.ErrorIs(t, err, clientcli.ErrListDisabled)
+		assert.Empty(t, out.String())
+	})
+}
+
+func TestWriteListCSV(t *testing.T) {
+	ts := time.Date(2024, 3, 31, 12, 0, 0, 0, time.UTC)
+	items := []clientcli.ObjectInfo{
+		{Path: `a,"b".pdf`, Size: 10, ContentType: "application/pdf", ETag: "e1", CreatedAt: ts, UpdatedAt: ts},
+	}
+
+	var out bytes.Buffer
+	require.NoError(t, clientcli.WriteListCSV(&out, items))
+	assert.Equal(t,
+		"path,size,content_type,etag,created_at,updated_at\n"+
+			`"a,""b"".pdf",10,application/pdf,e1,2024-03-31T12:00:00Z,2024-03-31T12:00:00Z`+"\n",
+		out.String())
+}
@@ -0,0 +1,29 @@
+package clientcli
+
+import (
+	"mime"
+	"net/http"
+	"path/filepath"
+)
+
+// filenameHeader carries the original filename of an upload, percent-encoded.
+const filenameHeader = "X-Stowry-Filename"
+
+// uploadFilename returns the original filename recorded for the upload of
+// opts.LocalPath: opts.Filename, or the local file's base name.
+func uploadFilename(opts UploadOptions) string {
+	if opts.Filename != "" {
+		return opts.Filename
+	}
+	return filepath.Base(opts.LocalPath)
+}
+
+// responseFilename returns the filename of the Content-Disposition of a
+// download, or "" when the object has no original filename.
+func responseFilename(resp *http.Response) string {
+	_, params, err := mime.ParseMediaType(resp.Header.Get("Content-Disposition"))
+	if err != nil {
+		return ""
+	}
+	return params["filename"]
+}
@@ -0,0 +1,173 @@
+package clientcli
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+// FilterRule includes or excludes the paths matching Pattern.
+//
+// Patterns follow .gitignore: a pattern without a slash, such as "*.map" or
+// "node_modules", matches a file or directory name at any depth; one with a
+// slash, such as "dist/**" or "/build", is relative to the root being
+// walked. "*", "?" and "[...]" match within a path segment, as path.Match,
+// and a "**" segment matches any number of segments. A trailing slash
+// matches directories only. A rule matching a directory applies to every
+// file below it.
+type FilterRule struct {
+	Pattern string
+	Exclude bool
+}
+
+// PathFilter decides which paths below a root a recursive operation
+// includes. Rules are evaluated in order and the last one matching a path
+// wins. Paths no rule matches are included, unless the first rule is an
+// include, so that "--include 'dist/**'" alone selects only dist/.
+//
+// A nil *PathFilter includes every path.
+type PathFilter struct {
+	rules            []compiledRule
+	includeByDefault bool
+}
+
+type compiledRule struct {
+	segments []string
+	dirOnly  bool
+	exclude  bool
+}
+
+// NewPathFilter compiles rules, failing on the first malformed pattern with
+// an error wrapping ErrInvalidFilterPattern. No rules returns a nil filter.
+func NewPathFilter(rules []FilterRule) (*PathFilter, error) {
+	if len(rules) == 0 {
+		return nil, nil
+	}
+
+	f := &PathFilter{includeByDefault: rules[0].Exclude}
+	for _, rule := range rules {
+		compiled, err := compileRule(rule)
+		if err != nil {
+			return nil, err
+		}
+		f.rules = append(f.rules, compiled)
+	}
+	return f, nil
+}
+
+func compileRule(rule FilterRule) (compiledRule, error) {
+	pattern := rule.Pattern
+	c := compiledRule{exclude: rule.Exclude}
+	if strings.HasSuffix(pattern, "/") {
+		c.dirOnly = true
+		pattern = strings.TrimSuffix(pattern, "/")
+	}
+	anchored := strings.Contains(pattern, "/")
+	pattern = strings.TrimPrefix(pattern, "/")
+	if pattern == "" {
+		return compiledRule{}, fmt.Errorf("%w: %q matches nothing", ErrInvalidFilterPattern, rule.Pattern)
+	}
+
+	if !anchored {
+		c.segments = append(c.segments, "**")
+	}
+	for segment := range strings.SplitSeq(pattern, "/") {
+		if segment == "" {
+			return compiledRule{}, fmt.Errorf("%w: %q has an empty segment", ErrInvalidFilterPattern, rule.Pattern)
+		}
+		if _, err := path.Match(segment, ""); err != nil {
+			return compiledRule{}, fmt.Errorf("%w: %q: %w", ErrInvalidFilterPattern, rule.Pattern, err)
+		}
+		c.segments = append(c.segments, segment)
+	}
+	return c, nil
+}
+
+// Match reports whether the file at relPath, a slash-separated path below
+// the root such as "assets/app.js", is included.
+func (f *PathFilter) Match(relPath string) bool {
+	if f == nil {
+		return true
+	}
+	i := f.lastMatch(strings.Split(relPath, "/"), false)
+	if i < 0 {
+		return f.includeByDefault
+	}
+	return !f.rules[i].exclude
+}
+
+// SkipDir reports whether nothing below the directory at relPath can be
+// included, so a walk can skip it whole: an exclude rule matches the
+// directory and no later include rule can match below it.
+func (f *PathFilter) SkipDir(relPath string) bool {
+	if f == nil {
+		return false
+	}
+	segments := strings.Split(relPath, "/")
+	i := f.lastMatch(segments, true)
+	if i < 0 || !f.rules[i].exclude {
+		return false
+	}
+	for _, later := range f.rules[i+1:] {
+		if !later.exclude && matchesBelow(later.segments, segments) {
+			return false
+		}
+	}
+	return true
+}
+
+// lastMatch returns the index of the last rule matching the path or one of
+// the directories it is in, or -1.
+func (f *PathFilter) lastMatch(segments []string, isDir bool) int {
+	for i := len(f.rules) - 1; i >= 0; i-- {
+		rule := f.rules[i]
+		end := len(segments)
+		if rule.dirOnly && !isDir {
+			end-- // only the directories the file is in
+		}
+		for n := 1; n <= end; n++ {
+			if matchSegments(rule.segments, segments[:n]) {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// matchesBelow reports whether pattern segments may match a path below the
+// directory dir. It errs towards true when a "**" makes it unclear.
+func matchesBelow(pattern, dir []string) bool {
+	for len(pattern) > 0 {
+		if len(dir) == 0 || pattern[0] == "**" {
+			return true
+		}
+		if ok, _ := path.Match(pattern[0], dir[0]); !ok {
+			return false
+		}
+		pattern, dir = pattern[1:], dir[1:]
+	}
+	return false
+}
+
+// matchSegments matches a path, split into segments, against pattern
+// segments, where "**" matches zero or more path segments.
+func matchSegments(pattern, name []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			for i := len(name); i >= 0; i-- {
+				if matchSegments(pattern[1:], name[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(name) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(pattern[0], name[0]); !ok {
+			return false
+		}
+		pattern, name = pattern[1:], name[1:]
+	}
+	return len(name) == 0
+}
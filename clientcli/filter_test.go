@@ -0,0 +1,152 @@
+package clientcli_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/sagarc03/stowry/clientcli"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPathFilter_Match(t *testing.T) {
+	exclude := func(pattern string) clientcli.FilterRule {
+		return clientcli.FilterRule{Pattern: pattern, Exclude: true}
+	}
+	include := func(pattern string) clientcli.FilterRule {
+		return clientcli.FilterRule{Pattern: pattern}
+	}
+
+	tests := []struct {
+		name  string
+		rules []clientcli.FilterRule
+		path  string
+		want  bool
+	}{
+		{"no rules", nil, "a/b.txt", true},
+		{"name at the root", []clientcli.FilterRule{exclude(".DS_Store")}, ".DS_Store", false},
+		{"name at any depth", []clientcli.FilterRule{exclude(".DS_Store")}, "img/icons/.DS_Store", false},
+		{"glob on the name", []clientcli.FilterRule{exclude("*.map")}, "dist/js/app.js.map", false},
+		{"glob leaves others", []clientcli.FilterRule{exclude("*.map")}, "dist/js/app.js", true},
+		{"star stays in its segment", []clientcli.FilterRule{exclude("dist/*.js")}, "dist/js/app.js", true},
+		{"anchored path", []clientcli.FilterRule{exclude("dist/*.js")}, "dist/app.js", false},
+		{"anchored path not below the root", []clientcli.FilterRule{exclude("dist/*.js")}, "src/dist/app.js", true},
+		{"leading slash anchors", []clientcli.FilterRule{exclude("/build")}, "build/out.bin", false},
+		{"leading slash anchors, deeper", []clientcli.FilterRule{exclude("/build")}, "src/build/out.bin", true},
+		{"directory applies below it", []clientcli.FilterRule{exclude("node_modules")}, "web/node_modules/react/index.js", false},
+		{"double star in the middle", []clientcli.FilterRule{exclude("docs/**/draft-*")}, "docs/a/b/draft-1.md", false},
+		{"double star matches no segment", []clientcli.FilterRule{exclude("docs/**/draft-*")}, "docs/draft-1.md", false},
+		{"trailing slash matches directories", []clientcli.FilterRule{exclude("cache/")}, "cache/x", false},
+		{"trailing slash skips files", []clientcli.FilterRule{exclude("cache/")}, "cache", true},
+		{"question mark and class", []clientcli.FilterRule{exclude("v?.[0-9]")}, "v1.2", false},
+		{"first include excludes the rest", []clientcli.FilterRule{include("dist/**")}, "src/main.go", false},
+		{"first include keeps its matches", []clientcli.FilterRule{include("dist/**")}, "dist/js/app.js", true},
+		{"last match wins", []clientcli.FilterRule{include("dist/**"), exclude("*.map")}, "dist/app.js.map", false},
+		{"later include re-includes", []clientcli.FilterRule{exclude("*.map"), include("keep.map")}, "vendor/keep.map", true},
+		{"earlier include is overridden", []clientcli.FilterRule{include("keep.map"), exclude("*.map")}, "keep.map", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := clientcli.NewPathFilter(tt.rules)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, f.Match(tt.path))
+		})
+	}
+}
+
+func TestPathFilter_SkipDir(t *testing.T) {
+	f, err := clientcli.NewPathFilter([]clientcli.FilterRule{
+		{Pattern: "node_modules", Exclude: true},
+		{Pattern: "assets", Exclude: true},
+		{Pattern: "assets/logo.svg"},
+	})
+	require.NoError(t, err)
+
+	assert.True(t, f.SkipDir("web/node_modules"))
+	assert.False(t, f.SkipDir("assets"), "a later include can match below it")
+	assert.False(t, f.Match("assets/style.css"))
+	assert.True(t, f.Match("assets/logo.svg"))
+	assert.False(t, f.SkipDir("src"))
+
+	var none *clientcli.PathFilter
+	assert.False(t, none.SkipDir("node_modules"))
+}
+
+func TestNewPathFilter_Invalid(t *testing.T) {
+	for _, pattern := range []string{"", "/", "a//b", "[a-"} {
+		t.Run(pattern, func(t *testing.T) {
+			_, err := clientcli.NewPathFilter([]clientcli.FilterRule{{Pattern: pattern, Exclude: true}})
+			assert.ErrorIs(t, err, clientcli.ErrInvalidFilterPattern)
+		})
+	}
+}
+
+func TestClient_Upload_Filter(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{
+		"index.html",
+		".DS_Store",
+		"dist/app.js",
+		"dist/app.js.map",
+		"dist/img/.DS_Store",
+		"dist/img/logo.png",
+		"node_modules/react/index.js",
+		"src/main.ts",
+	} {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+		require.NoError(t, os.WriteFile(path, []byte(name), 0o644))
+	}
+
+	t.Run("excludes", func(t *testing.T) {
+		client, uploaded := newEchoUploadServer(t)
+		var skipped []string
+		results, err := client.Upload(context.Background(), clientcli.UploadOptions{
+			LocalPath:  dir,
+			RemotePath: "site",
+			Recursive:  true,
+			Filter: []clientcli.FilterRule{
+				{Pattern: "*.map", Exclude: true},
+				{Pattern: ".DS_Store", Exclude: true},
+				{Pattern: "node_modules/", Exclude: true},
+			},
+			Skipped: func(relPath string) { skipped = append(skipped, relPath) },
+		})
+
+		require.NoError(t, err)
+		assert.Len(t, results, 4)
+		assert.Equal(t, []string{"site/dist/app.js", "site/dist/img/logo.png", "site/index.html", "site/src/main.ts"}, uploaded())
+		assert.ElementsMatch(t, []string{".DS_Store", "dist/app.js.map", "dist/img/.DS_Store", "node_modules/"}, skipped)
+	})
+
+	t.Run("include first selects", func(t *testing.T) {
+		client, uploaded := newEchoUploadServer(t)
+		_, err := client.Upload(context.Background(), clientcli.UploadOptions{
+			LocalPath:  dir,
+			RemotePath: "site",
+			Recursive:  true,
+			Filter: []clientcli.FilterRule{
+				{Pattern: "dist/**"},
+				{Pattern: "*.map", Exclude: true},
+				{Pattern: ".DS_Store", Exclude: true},
+			},
+		})
+
+		require.NoError(t, err)
+		assert.Equal(t, []string{"site/dist/app.js", "site/dist/img/logo.png"}, uploaded())
+	})
+
+	t.Run("invalid pattern fails before uploading", func(t *testing.T) {
+		client, uploaded := newEchoUploadServer(t)
+		_, err := client.Upload(context.Background(), clientcli.UploadOptions{
+			LocalPath: dir,
+			Recursive: true,
+			Filter:    []clientcli.FilterRule{{Pattern: "*.map", Exclude: true}, {Pattern: "[", Exclude: true}},
+		})
+
+		require.ErrorIs(t, err, clientcli.ErrInvalidFilterPattern)
+		assert.Empty(t, uploaded())
+	})
+}
@@ -0,0 +1,93 @@
+package clientcli
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+// HistoryOptions selects a page of an object's history.
+type HistoryOptions struct {
+	RemotePath string
+	After      int64 // Return changes after this sequence; 0 starts at the oldest retained change
+	Limit      int   // Maximum changes per page (server default: 100)
+}
+
+// HistoryResult is a page of an object's history, oldest first.
+type HistoryResult struct {
+	Path    string   `json:"path"`
+	Changes []Change `json:"changes"`
+	Next    int64    `json:"next,omitempty"` // Pass as HistoryOptions.After to continue; 0 on the last page
+}
+
+// serverHistory mirrors the JSON response to GET /{path}?history.
+type serverHistory struct {
+	Path    string          `json:"path"`
+	Changes *[]serverChange `json:"changes"`
+	Next    int64           `json:"next"`
+}
+
+// History returns a page of the creates and deletes recorded for
+// opts.RemotePath (store mode only, with write credentials). History reaches
+// back as far as the server's change retention.
+//
+// It returns ErrHistoryUnsupported when the server does not serve history,
+// without a request when its capabilities say so.
+func (c *Client) History(ctx context.Context, opts HistoryOptions) (*HistoryResult, error) {
+	if opts.RemotePath == "" {
+		return nil, fmt.Errorf("history: %w", ErrEmptyPath)
+	}
+	if c.capabilities(ctx).rulesOut("history") {
+		return nil, fmt.Errorf("history: %w", ErrHistoryUnsupported)
+	}
+
+	query := url.Values{}
+	query.Set("history", "")
+	if opts.After > 0 {
+		query.Set("after", strconv.FormatInt(opts.After, 10))
+	}
+	if opts.Limit > 0 {
+		query.Set("limit", strconv.Itoa(opts.Limit))
+	}
+
+	remotePath := normalizePath(opts.RemotePath)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.presign(http.MethodGet, remotePath, query, DefaultExpires), http.NoBody)
+	if err != nil {
+		return nil, fmt.Errorf("create request: %w", err)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("do request: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("read response: %w", err)
+	}
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotImplemented:
+		return nil, ErrHistoryUnsupported
+	default:
+		return nil, parseServerError(resp.StatusCode, body)
+	}
+
+	// Servers without history ignore ?history and answer with the object
+	var history serverHistory
+	if err := json.Unmarshal(body, &history); err != nil || history.Changes == nil {
+		return nil, ErrHistoryUnsupported
+	}
+
+	result := &HistoryResult{Path: history.Path, Changes: make([]Change, len(*history.Changes)), Next: history.Next}
+	for i, change := range *history.Changes {
+		result.Changes[i] = change.change()
+	}
+	return result, nil
+}
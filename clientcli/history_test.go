@@ -0,0 +1,78 @@
+package clientcli_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/sagarc03/stowry/clientcli"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClient_History(t *testing.T) {
+	t.Run("sends the cursor and parses changes", func(t *testing.T) {
+		server := httptest.NewServer(withoutCapabilities(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			q := r.URL.Query()
+			assert.Equal(t, "/docs/a.txt", r.URL.Path)
+			assert.True(t, q.Has("history"))
+			assert.Equal(t, "7", q.Get("after"))
+			assert.Equal(t, "2", q.Get("limit"))
+			_ = json.NewEncoder(w).Encode(map[string]any{
+				"path": "docs/a.txt",
+				"changes": []map[string]any{
+					{"seq": 8, "kind": "create", "path": "docs/a.txt", "etag": "new", "file_size_bytes": 3, "at": "2026-01-02T03:04:05Z", "previous_etag": "old", "actor": "AKALICE"},
+					{"seq": 9, "kind": "delete", "path": "docs/a.txt", "etag": "new", "file_size_bytes": 3, "at": "2026-01-02T03:04:06Z"},
+				},
+				"next": 9,
+			})
+		})))
+		defer server.Close()
+
+		client, err := clientcli.New(&clientcli.Config{Endpoint: server.URL})
+		require.NoError(t, err)
+
+		result, err := client.History(t.Context(), clientcli.HistoryOptions{RemotePath: "docs/a.txt", After: 7, Limit: 2})
+		require.NoError(t, err)
+		assert.Equal(t, "docs/a.txt", result.Path)
+		assert.Equal(t, int64(9), result.Next)
+		require.Len(t, result.Changes, 2)
+		assert.Equal(t, clientcli.Change{
+			Seq: 8, Kind: "create", Path: "docs/a.txt", ETag: "new", Size: 3,
+			At: time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC), PreviousETag: "old", Actor: "AKALICE",
+		}, result.Changes[0])
+		assert.Empty(t, result.Changes[1].Actor)
+	})
+
+	t.Run("server without history in its capabilities", func(t *testing.T) {
+		fake := &capabilityServer{document: storeCapabilities}
+		client := newCapabilityClient(t, fake)
+
+		_, err := client.History(t.Context(), clientcli.HistoryOptions{RemotePath: "a.txt"})
+		require.ErrorIs(t, err, clientcli.ErrHistoryUnsupported)
+		assert.Equal(t, []string{"GET /.well-known/stowry"}, fake.recorded())
+	})
+
+	t.Run("older server serves the object instead", func(t *testing.T) {
+		server := httptest.NewServer(withoutCapabilities(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			_, _ = w.Write([]byte("plain content"))
+		})))
+		defer server.Close()
+
+		client, err := clientcli.New(&clientcli.Config{Endpoint: server.URL})
+		require.NoError(t, err)
+
+		_, err = client.History(t.Context(), clientcli.HistoryOptions{RemotePath: "a.txt"})
+		assert.ErrorIs(t, err, clientcli.ErrHistoryUnsupported)
+	})
+
+	t.Run("empty path", func(t *testing.T) {
+		client, err := clientcli.New(&clientcli.Config{Endpoint: "http://localhost:5708"})
+		require.NoError(t, err)
+
+		_, err = client.History(t.Context(), clientcli.HistoryOptions{})
+		assert.ErrorIs(t, err, clientcli.ErrEmptyPath)
+	})
+}
@@ -0,0 +1,69 @@
+package clientcli
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// MoveResult is the outcome of a move: the path the object was moved from
+// and the object at its new path.
+type MoveResult struct {
+	From string `json:"from"`
+	ObjectInfo
+}
+
+// Move renames the object at src to dst on the server (store mode only, with
+// write credentials), keeping its ID and content. It fails with an APIError
+// of status 404 when src has no object, and 409 when dst already has one.
+//
+// It returns ErrMoveUnsupported when the server cannot move objects,
+// without a request when its capabilities say so.
+func (c *Client) Move(ctx context.Context, src, dst string) (*MoveResult, error) {
+	if src == "" || dst == "" {
+		return nil, fmt.Errorf("move: %w", ErrEmptyPath)
+	}
+	if c.capabilities(ctx).rulesOut("move") {
+		return nil, fmt.Errorf("move: %w", ErrMoveUnsupported)
+	}
+
+	srcPath, dstPath := normalizePath(src), normalizePath(dst)
+	defer c.Purge(srcPath)
+	defer c.Purge(dstPath)
+
+	// The server takes the new path as an object key, without a leading slash
+	query := url.Values{"rename-to": {strings.TrimPrefix(dstPath, "/")}}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.presign(http.MethodPost, srcPath, query, DefaultExpires), http.NoBody)
+	if err != nil {
+		return nil, fmt.Errorf("create request: %w", err)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("do request: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("read response: %w", err)
+	}
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusMethodNotAllowed, http.StatusNotImplemented:
+		return nil, ErrMoveUnsupported
+	default:
+		return nil, parseServerError(resp.StatusCode, body)
+	}
+
+	var moved serverMetaData
+	if err := json.Unmarshal(body, &moved); err != nil {
+		return nil, fmt.Errorf("parse response: %w", err)
+	}
+	return &MoveResult{From: src, ObjectInfo: moved.objectInfo()}, nil
+}
@@ -0,0 +1,87 @@
+package clientcli_test
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	stowrycore "github.com/sagarc03/stowry"
+	"github.com/sagarc03/stowry/clientcli"
+	"github.com/sagarc03/stowry/keybackend"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClient_Move(t *testing.T) {
+	t.Run("posts the new path and parses the object", func(t *testing.T) {
+		verifier := stowrycore.NewStowrySignatureVerifier(keybackend.NewMapSecretStore(map[string]string{"test-key": "test-secret"}))
+		server := httptest.NewServer(withoutCapabilities(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, http.MethodPost, r.Method)
+			assert.Equal(t, "/uploads/tmp/foo.jpg", r.URL.Path)
+			assert.Equal(t, "images/foo.jpg", r.URL.Query().Get("rename-to"))
+			assert.NoError(t, verifier.Verify(r), "the signature covers the destination")
+			_ = json.NewEncoder(w).Encode(map[string]any{
+				"path": "images/foo.jpg", "content_type": "image/jpeg", "etag": "abc", "file_size_bytes": 3,
+			})
+		})))
+		defer server.Close()
+
+		client, err := clientcli.New(&clientcli.Config{Endpoint: server.URL, AccessKey: "test-key", SecretKey: "test-secret"})
+		require.NoError(t, err)
+
+		result, err := client.Move(t.Context(), "uploads/tmp/foo.jpg", "/images/foo.jpg")
+		require.NoError(t, err)
+		assert.Equal(t, "uploads/tmp/foo.jpg", result.From)
+		assert.Equal(t, "images/foo.jpg", result.Path)
+		assert.Equal(t, "abc", result.ETag)
+		assert.Equal(t, int64(3), result.Size)
+	})
+
+	t.Run("destination exists", func(t *testing.T) {
+		server := httptest.NewServer(withoutCapabilities(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			w.WriteHeader(http.StatusConflict)
+			_, _ = w.Write([]byte(`{"error":"already_exists","message":"An object already exists at the destination path"}`))
+		})))
+		defer server.Close()
+
+		client, err := clientcli.New(&clientcli.Config{Endpoint: server.URL})
+		require.NoError(t, err)
+
+		_, err = client.Move(t.Context(), "a.txt", "b.txt")
+		var apiErr *clientcli.APIError
+		require.True(t, errors.As(err, &apiErr))
+		assert.Equal(t, http.StatusConflict, apiErr.StatusCode)
+	})
+
+	t.Run("server without move in its capabilities", func(t *testing.T) {
+		fake := &capabilityServer{document: storeCapabilities}
+		client := newCapabilityClient(t, fake)
+
+		_, err := client.Move(t.Context(), "a.txt", "b.txt")
+		require.ErrorIs(t, err, clientcli.ErrMoveUnsupported)
+		assert.Equal(t, []string{"GET /.well-known/stowry"}, fake.recorded())
+	})
+
+	t.Run("older server", func(t *testing.T) {
+		server := httptest.NewServer(withoutCapabilities(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		})))
+		defer server.Close()
+
+		client, err := clientcli.New(&clientcli.Config{Endpoint: server.URL})
+		require.NoError(t, err)
+
+		_, err = client.Move(t.Context(), "a.txt", "b.txt")
+		assert.ErrorIs(t, err, clientcli.ErrMoveUnsupported)
+	})
+
+	t.Run("empty path", func(t *testing.T) {
+		client, err := clientcli.New(&clientcli.Config{Endpoint: "http://localhost"})
+		require.NoError(t, err)
+
+		_, err = client.Move(t.Context(), "a.txt", "")
+		assert.ErrorIs(t, err, clientcli.ErrEmptyPath)
+	})
+}
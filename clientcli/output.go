@@ -4,15 +4,26 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"maps"
+	"slices"
+	"strconv"
 	"strings"
+	"time"
 )
 
 // Formatter formats results for output.
 type Formatter interface {
 	FormatUpload(w io.Writer, results []UploadResult) error
 	FormatDownload(w io.Writer, result *DownloadResult) error
+	FormatDownloads(w io.Writer, results []DownloadResult) error
 	FormatDelete(w io.Writer, results []DeleteResult) error
+	FormatDeletePrefix(w io.Writer, result *DeletePrefixResult) error
+	FormatSync(w io.Writer, result *SyncResult) error
+	FormatMove(w io.Writer, result *MoveResult) error
+	FormatRestore(w io.Writer, result *ObjectInfo) error
 	FormatList(w io.Writer, result *ListResult) error
+	FormatCapabilities(w io.Writer, endpoint string, caps Capabilities) error
+	FormatHistory(w io.Writer, result *HistoryResult) error
 	FormatError(w io.Writer, err error) error
 	FormatProfileList(w io.Writer, profiles []Profile, defaultName string, showSecrets bool) error
 	FormatProfileShow(w io.Writer, profile Profile, isDefault, showSecrets bool) error
@@ -29,6 +40,9 @@ func NewFormatter(jsonOutput, quiet bool) Formatter {
 // HumanFormatter outputs human-readable text.
 type HumanFormatter struct {
 	Quiet bool
+	// ShowBackend adds a BACKEND column to lists, for results fetched with
+	// ListOptions.IncludeBackend.
+	ShowBackend bool
 }
 
 // FormatUpload formats upload results as human-readable text.
@@ -39,9 +53,21 @@ func (f *HumanFormatter) FormatUpload(w io.Writer, results []UploadResult) error
 			_, _ = fmt.Fprintf(w, "Error: %s - %v\n", r.LocalPath, r.Err)
 			continue
 		}
+		if r.Skipped {
+			if !f.Quiet {
+				_, _ = fmt.Fprintf(w, "Skipped: %s (%s)\n", r.LocalPath, r.SkipReason)
+			}
+			continue
+		}
 		if !f.Quiet {
 			_, _ = fmt.Fprintf(w, "Uploaded: %s (%s)\n", r.RemotePath, formatSize(r.Size))
 			_, _ = fmt.Fprintf(w, "  ETag: %s\n", r.ETag)
+			if r.BytesSent > 0 {
+				_, _ = fmt.Fprintf(w, "  Delta: sent %s\n", formatSize(r.BytesSent))
+			}
+			if r.Region != "" {
+				_, _ = fmt.Fprintf(w, "  Region: %s\n", r.Region)
+			}
 		}
 	}
 	return nil
@@ -56,6 +82,33 @@ func (f *HumanFormatter) FormatDownload(w io.Writer, result *DownloadResult) err
 			_, _ = fmt.Fprintf(w, "Downloaded: %s -> %s (%s)\n", result.RemotePath, result.LocalPath, formatSize(result.Size))
 		}
 		_, _ = fmt.Fprintf(w, "  ETag: %s\n", result.ETag)
+		if result.ResumedFrom > 0 {
+			_, _ = fmt.Fprintf(w, "  Resumed at: %s\n", formatSize(result.ResumedFrom))
+		}
+		if result.DeletedAt != nil {
+			_, _ = fmt.Fprintf(w, "  Deleted: %s\n", result.DeletedAt.Format(time.RFC3339))
+		}
+	}
+	return nil
+}
+
+// FormatDownloads formats the results of a recursive download as
+// human-readable text.
+func (f *HumanFormatter) FormatDownloads(w io.Writer, results []DownloadResult) error {
+	for i := range results {
+		r := &results[i]
+		if r.Err != nil {
+			_, _ = fmt.Fprintf(w, "Error: %s - %v\n", r.RemotePath, r.Err)
+			continue
+		}
+		if f.Quiet {
+			continue
+		}
+		if r.Skipped {
+			_, _ = fmt.Fprintf(w, "Skipped: %s (%s)\n", r.LocalPath, r.SkipReason)
+			continue
+		}
+		_, _ = fmt.Fprintf(w, "Downloaded: %s -> %s (%s)\n", r.RemotePath, r.LocalPath, formatSize(r.Size))
 	}
 	return nil
 }
@@ -68,9 +121,80 @@ func (f *HumanFormatter) FormatDelete(w io.Writer, results []DeleteResult) error
 			_, _ = fmt.Fprintf(w, "Error: %s - %v\n", r.Path, r.Err)
 			continue
 		}
-		if !f.Quiet {
-			_, _ = fmt.Fprintf(w, "Deleted: %s\n", r.Path)
+		if f.Quiet {
+			continue
 		}
+		if r.Purged {
+			_, _ = fmt.Fprintf(w, "Purged: %s (%d bytes, ETag %s)\n", r.Path, r.Size, r.ETag)
+			continue
+		}
+		_, _ = fmt.Fprintf(w, "Deleted: %s\n", r.Path)
+	}
+	return nil
+}
+
+// FormatDeletePrefix formats a delete prefix result as human-readable text.
+func (f *HumanFormatter) FormatDeletePrefix(w io.Writer, result *DeletePrefixResult) error {
+	for i := range result.Errors {
+		_, _ = fmt.Fprintf(w, "Error: %s - %v\n", result.Errors[i].Path, result.Errors[i].Err)
+	}
+	if f.Quiet {
+		return nil
+	}
+	if result.DryRun {
+		for _, path := range result.Paths {
+			_, _ = fmt.Fprintf(w, "Would delete: %s\n", path)
+		}
+		if result.PathsTruncated {
+			_, _ = fmt.Fprintf(w, "... and %d more\n", result.Deleted-len(result.Paths))
+		}
+		_, _ = fmt.Fprintf(w, "Would delete %d object(s) under %q\n", result.Deleted, result.Prefix)
+		return nil
+	}
+	_, _ = fmt.Fprintf(w, "Deleted %d object(s) under %q\n", result.Deleted, result.Prefix)
+	return nil
+}
+
+// FormatSync formats a sync result as human-readable text.
+func (f *HumanFormatter) FormatSync(w io.Writer, result *SyncResult) error {
+	for i := range result.Errored {
+		r := &result.Errored[i]
+		_, _ = fmt.Fprintf(w, "Error: %s - %v\n", r.RemotePath, r.Err)
+	}
+	if f.Quiet {
+		return nil
+	}
+
+	upload, remove := "Uploaded", "Deleted"
+	if result.DryRun {
+		upload, remove = "Would upload", "Would delete"
+	}
+	for i := range result.Uploaded {
+		r := &result.Uploaded[i]
+		_, _ = fmt.Fprintf(w, "%s: %s -> %s (%s, %s)\n", upload, r.LocalPath, r.RemotePath, r.Reason, formatSize(r.Size))
+	}
+	for i := range result.Deleted {
+		_, _ = fmt.Fprintf(w, "%s: %s\n", remove, result.Deleted[i].RemotePath)
+	}
+	_, _ = fmt.Fprintf(w, "%d uploaded, %d unchanged, %d deleted, %d failed\n",
+		len(result.Uploaded), len(result.Skipped), len(result.Deleted), len(result.Errored))
+	return nil
+}
+
+// FormatMove formats a move result as human-readable text.
+func (f *HumanFormatter) FormatMove(w io.Writer, result *MoveResult) error {
+	if !f.Quiet {
+		_, _ = fmt.Fprintf(w, "Moved: %s -> %s\n", result.From, result.Path)
+		_, _ = fmt.Fprintf(w, "  ETag: %s\n", result.ETag)
+	}
+	return nil
+}
+
+// FormatRestore formats a restored object as human-readable text.
+func (f *HumanFormatter) FormatRestore(w io.Writer, result *ObjectInfo) error {
+	if !f.Quiet {
+		_, _ = fmt.Fprintf(w, "Restored: %s\n", result.Path)
+		_, _ = fmt.Fprintf(w, "  ETag: %s\n", result.ETag)
 	}
 	return nil
 }
@@ -93,9 +217,36 @@ func (f *HumanFormatter) FormatList(w io.Writer, result *ListResult) error {
 		maxPathLen = 60
 	}
 
+	// The FILENAME column is shown only when some object has one, and
+	// DELETED only in lists of deleted objects
+	showFilename := slices.ContainsFunc(result.Items, func(item ObjectInfo) bool { return item.Filename != "" })
+	showDeleted := slices.ContainsFunc(result.Items, func(item ObjectInfo) bool { return item.DeletedAt != nil })
+
+	backendLen := 0
+	if f.ShowBackend {
+		backendLen = len("BACKEND")
+		for i := range result.Items {
+			backendLen = max(backendLen, len(backendName(result.Items[i].StorageBackend)))
+		}
+	}
+
 	// Print header
-	_, _ = fmt.Fprintf(w, "%-*s  %10s  %s\n", maxPathLen, "PATH", "SIZE", "UPDATED")
-	_, _ = fmt.Fprintf(w, "%s  %s  %s\n", strings.Repeat("-", maxPathLen), strings.Repeat("-", 10), strings.Repeat("-", 19))
+	header := fmt.Sprintf("%-*s  %10s  %-19s", maxPathLen, "PATH", "SIZE", "UPDATED")
+	rule := strings.Repeat("-", maxPathLen) + "  " + strings.Repeat("-", 10) + "  " + strings.Repeat("-", 19)
+	if showDeleted {
+		header += fmt.Sprintf("  %-19s", "DELETED")
+		rule += "  " + strings.Repeat("-", 19)
+	}
+	if backendLen > 0 {
+		header += fmt.Sprintf("  %-*s", backendLen, "BACKEND")
+		rule += "  " + strings.Repeat("-", backendLen)
+	}
+	if showFilename {
+		header += "  FILENAME"
+		rule += "  " + strings.Repeat("-", 8)
+	}
+	_, _ = fmt.Fprintln(w, strings.TrimRight(header, " "))
+	_, _ = fmt.Fprintln(w, rule)
 
 	// Print items
 	for i := range result.Items {
@@ -104,12 +255,26 @@ func (f *HumanFormatter) FormatList(w io.Writer, result *ListResult) error {
 		if len(path) > maxPathLen {
 			path = path[:maxPathLen-3] + "..."
 		}
-		_, _ = fmt.Fprintf(w, "%-*s  %10s  %s\n",
+		line := fmt.Sprintf("%-*s  %10s  %s",
 			maxPathLen,
 			path,
 			formatSize(item.Size),
 			item.UpdatedAt.Format("2006-01-02 15:04:05"),
 		)
+		if showDeleted {
+			deleted := ""
+			if item.DeletedAt != nil {
+				deleted = item.DeletedAt.Format("2006-01-02 15:04:05")
+			}
+			line += fmt.Sprintf("  %-19s", deleted)
+		}
+		if backendLen > 0 {
+			line += fmt.Sprintf("  %-*s", backendLen, backendName(item.StorageBackend))
+		}
+		if showFilename && item.Filename != "" {
+			line += "  " + item.Filename
+		}
+		_, _ = fmt.Fprintln(w, strings.TrimRight(line, " "))
 	}
 
 	// Print summary
@@ -122,6 +287,65 @@ func (f *HumanFormatter) FormatList(w io.Writer, result *ListResult) error {
 	return nil
 }
 
+// backendName is how the BACKEND column shows the storage backend of an
+// object; objects on the default backend report none.
+func backendName(backend string) string {
+	if backend == "" {
+		return "(default)"
+	}
+	return backend
+}
+
+// FormatHistory formats an object's history as a human-readable table,
+// oldest change first.
+func (f *HumanFormatter) FormatHistory(w io.Writer, result *HistoryResult) error {
+	if len(result.Changes) == 0 {
+		_, _ = fmt.Fprintln(w, "No recorded changes")
+		return nil
+	}
+
+	_, _ = fmt.Fprintf(w, "%-8s  %-19s  %-11s  %10s  %-12s  %-12s  %s\n", "SEQ", "TIME", "EVENT", "SIZE", "ETAG", "PREVIOUS", "ACTOR")
+	_, _ = fmt.Fprintln(w, strings.Join([]string{
+		strings.Repeat("-", 8), strings.Repeat("-", 19), strings.Repeat("-", 11), strings.Repeat("-", 10),
+		strings.Repeat("-", 12), strings.Repeat("-", 12), strings.Repeat("-", 5),
+	}, "  "))
+	for _, c := range result.Changes {
+		line := fmt.Sprintf("%-8d  %-19s  %-11s  %10s  %-12s  %-12s  %s",
+			c.Seq,
+			c.At.Format("2006-01-02 15:04:05"),
+			historyEvent(c),
+			formatSize(c.Size),
+			shortETag(c.ETag),
+			shortETag(c.PreviousETag),
+			c.Actor,
+		)
+		_, _ = fmt.Fprintln(w, strings.TrimRight(line, " "))
+	}
+	return nil
+}
+
+// historyEvent names what a change did to its object. Creates without a
+// previous ETag may still have overwritten an object in a batch or staged
+// upload, which records none.
+func historyEvent(c Change) string {
+	switch {
+	case c.Kind == "delete":
+		return "deleted"
+	case c.PreviousETag != "":
+		return "overwritten"
+	default:
+		return "created"
+	}
+}
+
+// shortETag abbreviates an ETag for tables; full ETags are in --json output.
+func shortETag(etag string) string {
+	if len(etag) > 12 {
+		return etag[:12]
+	}
+	return etag
+}
+
 // FormatError formats an error as human-readable text.
 func (f *HumanFormatter) FormatError(w io.Writer, err error) error {
 	_, _ = fmt.Fprintf(w, "Error: %v\n", err)
@@ -139,10 +363,15 @@ func (f *JSONFormatter) FormatUpload(w io.Writer, results []UploadResult) error
 		RemotePath  string `json:"remote_path"`
 		ID          string `json:"id,omitempty"`
 		ContentType string `json:"content_type,omitempty"`
+		Filename    string `json:"filename,omitempty"`
 		ETag        string `json:"etag,omitempty"`
 		Size        int64  `json:"size_bytes,omitempty"`
 		CreatedAt   string `json:"created_at,omitempty"`
 		UpdatedAt   string `json:"updated_at,omitempty"`
+		BytesSent   int64  `json:"bytes_sent,omitempty"`
+		Region      string `json:"region,omitempty"`
+		Skipped     bool   `json:"skipped,omitempty"`
+		SkipReason  string `json:"skip_reason,omitempty"`
 		Error       string `json:"error,omitempty"`
 	}
 
@@ -153,15 +382,22 @@ func (f *JSONFormatter) FormatUpload(w io.Writer, results []UploadResult) error
 			LocalPath:  r.LocalPath,
 			RemotePath: r.RemotePath,
 		}
-		if r.Err != nil {
+		switch {
+		case r.Err != nil:
 			jr.Error = r.Err.Error()
-		} else {
+		case r.Skipped:
+			jr.Skipped = true
+			jr.SkipReason = r.SkipReason
+		default:
 			jr.ID = r.ID.String()
 			jr.ContentType = r.ContentType
+			jr.Filename = r.Filename
 			jr.ETag = r.ETag
 			jr.Size = r.Size
 			jr.CreatedAt = r.CreatedAt.Format("2006-01-02T15:04:05Z07:00")
 			jr.UpdatedAt = r.UpdatedAt.Format("2006-01-02T15:04:05Z07:00")
+			jr.BytesSent = r.BytesSent
+			jr.Region = r.Region
 		}
 		output[i] = jr
 	}
@@ -174,12 +410,34 @@ func (f *JSONFormatter) FormatDownload(w io.Writer, result *DownloadResult) erro
 	return writeJSON(w, result)
 }
 
+// FormatDownloads formats the results of a recursive download as JSON.
+func (f *JSONFormatter) FormatDownloads(w io.Writer, results []DownloadResult) error {
+	// Convert errors to strings for JSON output
+	type jsonResult struct {
+		DownloadResult
+		Error string `json:"error,omitempty"`
+	}
+
+	output := make([]jsonResult, len(results))
+	for i := range results {
+		output[i].DownloadResult = results[i]
+		if results[i].Err != nil {
+			output[i].Error = results[i].Err.Error()
+		}
+	}
+
+	return writeJSON(w, output)
+}
+
 // FormatDelete formats delete results as JSON.
 func (f *JSONFormatter) FormatDelete(w io.Writer, results []DeleteResult) error {
 	// Convert errors to strings for JSON output
 	type jsonResult struct {
 		Path    string `json:"path"`
 		Deleted bool   `json:"deleted"`
+		Purged  bool   `json:"purged,omitempty"`
+		ETag    string `json:"etag,omitempty"`
+		Size    int64  `json:"size_bytes,omitempty"`
 		Error   string `json:"error,omitempty"`
 	}
 
@@ -193,6 +451,9 @@ func (f *JSONFormatter) FormatDelete(w io.Writer, results []DeleteResult) error
 		jr := jsonResult{
 			Path:    r.Path,
 			Deleted: r.Deleted,
+			Purged:  r.Purged,
+			ETag:    r.ETag,
+			Size:    r.Size,
 		}
 		if r.Err != nil {
 			jr.Error = r.Err.Error()
@@ -203,11 +464,81 @@ func (f *JSONFormatter) FormatDelete(w io.Writer, results []DeleteResult) error
 	return writeJSON(w, output)
 }
 
+// FormatDeletePrefix formats a delete prefix result as JSON.
+func (f *JSONFormatter) FormatDeletePrefix(w io.Writer, result *DeletePrefixResult) error {
+	type jsonError struct {
+		Path  string `json:"path"`
+		Error string `json:"error"`
+	}
+
+	output := struct {
+		*DeletePrefixResult
+		Errors []jsonError `json:"errors,omitempty"`
+	}{
+		DeletePrefixResult: result,
+	}
+	for _, r := range result.Errors {
+		output.Errors = append(output.Errors, jsonError{Path: r.Path, Error: r.Err.Error()})
+	}
+
+	return writeJSON(w, output)
+}
+
+// FormatSync formats a sync result as JSON.
+func (f *JSONFormatter) FormatSync(w io.Writer, result *SyncResult) error {
+	// Convert errors to strings for JSON output
+	type jsonError struct {
+		LocalPath  string `json:"local_path,omitempty"`
+		RemotePath string `json:"remote_path"`
+		Error      string `json:"error"`
+	}
+
+	output := struct {
+		*SyncResult
+		Errored []jsonError `json:"errored"`
+	}{
+		SyncResult: result,
+		Errored:    make([]jsonError, len(result.Errored)),
+	}
+	for i, r := range result.Errored {
+		output.Errored[i] = jsonError{LocalPath: r.LocalPath, RemotePath: r.RemotePath, Error: r.Err.Error()}
+	}
+
+	return writeJSON(w, output)
+}
+
+// FormatMove formats a move result as JSON.
+func (f *JSONFormatter) FormatMove(w io.Writer, result *MoveResult) error {
+	return writeJSON(w, result)
+}
+
+// FormatRestore formats a restored object as JSON.
+func (f *JSONFormatter) FormatRestore(w io.Writer, result *ObjectInfo) error {
+	return writeJSON(w, result)
+}
+
 // FormatList formats list results as JSON.
 func (f *JSONFormatter) FormatList(w io.Writer, result *ListResult) error {
 	return writeJSON(w, result)
 }
 
+// FormatCapabilities formats a server's capabilities as JSON.
+func (f *JSONFormatter) FormatCapabilities(w io.Writer, endpoint string, caps Capabilities) error {
+	output := struct {
+		Endpoint string `json:"endpoint"`
+		Capabilities
+	}{
+		Endpoint:     endpoint,
+		Capabilities: caps,
+	}
+	return writeJSON(w, output)
+}
+
+// FormatHistory formats an object's history as JSON.
+func (f *JSONFormatter) FormatHistory(w io.Writer, result *HistoryResult) error {
+	return writeJSON(w, result)
+}
+
 // FormatError formats an error as JSON.
 func (f *JSONFormatter) FormatError(w io.Writer, err error) error {
 	output := struct {
@@ -248,6 +579,61 @@ func formatSize(bytes int64) string {
 	}
 }
 
+// FormatCapabilities formats a server's capabilities as human-readable text.
+func (f *HumanFormatter) FormatCapabilities(w io.Writer, endpoint string, caps Capabilities) error {
+	orNone := func(list []string) string {
+		if len(list) == 0 {
+			return "none"
+		}
+		return strings.Join(list, ", ")
+	}
+	limit := func(n int64, format func(int64) string) string {
+		if n == 0 {
+			return "unlimited"
+		}
+		return format(n)
+	}
+	count := func(n int64) string { return strconv.FormatInt(n, 10) }
+
+	_, _ = fmt.Fprintf(w, "Endpoint:      %s\n", endpoint)
+	if caps.Offline {
+		_, _ = fmt.Fprintln(w, "Capabilities:  not reported by the server; conservative defaults")
+	}
+	if caps.Version != "" {
+		_, _ = fmt.Fprintf(w, "Version:       %s\n", caps.Version)
+	}
+	if caps.Mode != "" {
+		_, _ = fmt.Fprintf(w, "Mode:          %s\n", caps.Mode)
+	}
+	_, _ = fmt.Fprintf(w, "Features:      %s\n", orNone(caps.Features))
+	listFormats := orNone(caps.ListFormats)
+	if caps.ListDisabled {
+		listFormats = "disabled"
+	}
+	_, _ = fmt.Fprintf(w, "List formats:  %s\n", listFormats)
+	_, _ = fmt.Fprintf(w, "Compression:   %s\n", orNone(caps.Compression))
+	if caps.IfMatchComparison != "" {
+		_, _ = fmt.Fprintf(w, "If-Match:      %s comparison\n", caps.IfMatchComparison)
+	}
+
+	_, _ = fmt.Fprintln(w, "Limits:")
+	_, _ = fmt.Fprintf(w, "  max_upload_size:         %s\n", limit(caps.Limits.MaxUploadSize, formatSize))
+	_, _ = fmt.Fprintf(w, "  max_list_limit:          %s\n", limit(int64(caps.Limits.MaxListLimit), count))
+	_, _ = fmt.Fprintf(w, "  max_export_rows:         %s\n", limit(int64(caps.Limits.MaxExportRows), count))
+	_, _ = fmt.Fprintf(w, "  max_stat_paths:          %s\n", limit(int64(caps.Limits.MaxStatPaths), count))
+	_, _ = fmt.Fprintf(w, "  max_delete_paths:        %s\n", limit(int64(caps.Limits.MaxDeletePaths), count))
+	if !caps.Offline {
+		_, _ = fmt.Fprintf(w, "  max_filename_bytes:      %d\n", caps.Limits.MaxFilenameBytes)
+		_, _ = fmt.Fprintf(w, "  max_content_type_length: %d\n", caps.Limits.MaxContentTypeLength)
+	}
+	if pl := caps.PathLimits; pl != nil {
+		_, _ = fmt.Fprintf(w, "  max_key_bytes:           %d\n", pl.MaxKeyBytes)
+		_, _ = fmt.Fprintf(w, "  max_segment_bytes:       %d\n", pl.MaxSegmentBytes)
+		_, _ = fmt.Fprintf(w, "  max_depth:               %d\n", pl.MaxDepth)
+	}
+	return nil
+}
+
 // FormatProfileList formats a list of profiles as human-readable text.
 func (f *HumanFormatter) FormatProfileList(w io.Writer, profiles []Profile, defaultName string, showSecrets bool) error {
 	// Calculate column widths
@@ -308,6 +694,40 @@ func (f *HumanFormatter) FormatProfileShow(w io.Writer, profile Profile, isDefau
 	_, _ = fmt.Fprintf(w, "Endpoint:   %s\n", profile.Endpoint)
 	_, _ = fmt.Fprintf(w, "Access Key: %s\n", maskSecret(profile.AccessKey, showSecrets))
 	_, _ = fmt.Fprintf(w, "Secret Key: %s\n", maskSecret(profile.SecretKey, showSecrets))
+
+	opts := profile.Options
+	if opts.IsZero() {
+		return nil
+	}
+	_, _ = fmt.Fprintln(w, "Options:")
+	if opts.DefaultPrefix != "" {
+		_, _ = fmt.Fprintf(w, "  default_prefix:     %s\n", opts.DefaultPrefix)
+	}
+	if opts.UploadConcurrency != 0 {
+		_, _ = fmt.Fprintf(w, "  upload_concurrency: %d\n", opts.UploadConcurrency)
+	}
+	if opts.VerifyDownloads {
+		_, _ = fmt.Fprintf(w, "  verify_downloads:   true\n")
+	}
+	if opts.Timeout != 0 {
+		_, _ = fmt.Fprintf(w, "  timeout:            %s\n", opts.Timeout)
+	}
+	for _, ext := range slices.Sorted(maps.Keys(opts.ContentTypes)) {
+		_, _ = fmt.Fprintf(w, "  content_type %s: %s\n", ext, opts.ContentTypes[ext])
+	}
+	if opts.CACertFile != "" {
+		_, _ = fmt.Fprintf(w, "  ca_cert_file:       %s\n", opts.CACertFile)
+	}
+	if opts.ClientCertFile != "" {
+		_, _ = fmt.Fprintf(w, "  client_cert_file:   %s\n", opts.ClientCertFile)
+		_, _ = fmt.Fprintf(w, "  client_key_file:    %s\n", opts.ClientKeyFile)
+	}
+	if opts.InsecureSkipVerify {
+		_, _ = fmt.Fprintf(w, "  insecure_skip_verify: true (server certificate is NOT verified)\n")
+	}
+	for _, region := range slices.Sorted(maps.Keys(opts.Regions)) {
+		_, _ = fmt.Fprintf(w, "  region %s: %s\n", region, opts.Regions[region])
+	}
 	return nil
 }
 
@@ -349,18 +769,53 @@ func (f *JSONFormatter) FormatProfileList(w io.Writer, profiles []Profile, defau
 
 // FormatProfileShow formats a single profile as JSON.
 func (f *JSONFormatter) FormatProfileShow(w io.Writer, profile Profile, isDefault, showSecrets bool) error {
+	type jsonOptions struct {
+		DefaultPrefix     string            `json:"default_prefix,omitempty"`
+		UploadConcurrency int               `json:"upload_concurrency,omitempty"`
+		VerifyDownloads   bool              `json:"verify_downloads,omitempty"`
+		Timeout           string            `json:"timeout,omitempty"`
+		ContentTypes      map[string]string `json:"content_types,omitempty"`
+
+		CACertFile         string `json:"ca_cert_file,omitempty"`
+		ClientCertFile     string `json:"client_cert_file,omitempty"`
+		ClientKeyFile      string `json:"client_key_file,omitempty"`
+		InsecureSkipVerify bool   `json:"insecure_skip_verify,omitempty"`
+
+		Regions map[string]string `json:"regions,omitempty"`
+	}
+
 	output := struct {
-		Name      string `json:"name"`
-		Endpoint  string `json:"endpoint"`
-		AccessKey string `json:"access_key"`
-		SecretKey string `json:"secret_key"`
-		Default   bool   `json:"default"`
+		Name      string       `json:"name"`
+		Endpoint  string       `json:"endpoint"`
+		AccessKey string       `json:"access_key"`
+		SecretKey string       `json:"secret_key"`
+		Default   bool         `json:"default"`
+		Options   *jsonOptions `json:"options,omitempty"`
 	}{
 		Name:     profile.Name,
 		Endpoint: profile.Endpoint,
 		Default:  isDefault,
 	}
 
+	if opts := profile.Options; !opts.IsZero() {
+		output.Options = &jsonOptions{
+			DefaultPrefix:     opts.DefaultPrefix,
+			UploadConcurrency: opts.UploadConcurrency,
+			VerifyDownloads:   opts.VerifyDownloads,
+			ContentTypes:      opts.ContentTypes,
+
+			CACertFile:         opts.CACertFile,
+			ClientCertFile:     opts.ClientCertFile,
+			ClientKeyFile:      opts.ClientKeyFile,
+			InsecureSkipVerify: opts.InsecureSkipVerify,
+
+			Regions: opts.Regions,
+		}
+		if opts.Timeout != 0 {
+			output.Options.Timeout = opts.Timeout.String()
+		}
+	}
+
 	if showSecrets {
 		output.AccessKey = profile.AccessKey
 		output.SecretKey = profile.SecretKey
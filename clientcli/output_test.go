@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"encoding/json"
 	"errors"
+	"strings"
 	"testing"
 	"time"
 
@@ -73,6 +74,17 @@ func TestHumanFormatter_FormatUpload(t *testing.T) {
 		assert.Contains(t, output, "Error: local.txt - upload failed")
 	})
 
+	t.Run("skipped", func(t *testing.T) {
+		formatter := &clientcli.HumanFormatter{}
+		results := []clientcli.UploadResult{
+			{LocalPath: "build/tmp.o", RemotePath: "out/tmp.o", Skipped: true, SkipReason: clientcli.SkipReasonRemoved},
+		}
+
+		var buf bytes.Buffer
+		require.NoError(t, formatter.FormatUpload(&buf, results))
+		assert.Equal(t, "Skipped: build/tmp.o (removed during upload)\n", buf.String())
+	})
+
 	t.Run("quiet mode", func(t *testing.T) {
 		formatter := &clientcli.HumanFormatter{Quiet: true}
 		results := []clientcli.UploadResult{
@@ -109,6 +121,85 @@ func TestHumanFormatter_FormatDownload(t *testing.T) {
 	assert.Contains(t, output, "ETag: etag123")
 }
 
+func TestFormatter_FormatDownloads(t *testing.T) {
+	results := []clientcli.DownloadResult{
+		{RemotePath: "builds/a.zip", LocalPath: "out/a.zip", Size: 2048},
+		{RemotePath: "builds/b.zip", LocalPath: "out/b.zip", Skipped: true, SkipReason: clientcli.SkipReasonUnchanged},
+		{RemotePath: "builds/c.zip", LocalPath: "out/c.zip", Err: errors.New("server error")},
+	}
+
+	t.Run("human", func(t *testing.T) {
+		var buf bytes.Buffer
+		require.NoError(t, (&clientcli.HumanFormatter{}).FormatDownloads(&buf, results))
+
+		output := buf.String()
+		assert.Contains(t, output, "Downloaded: builds/a.zip -> out/a.zip (2.0 KB)")
+		assert.Contains(t, output, "Skipped: out/b.zip ("+clientcli.SkipReasonUnchanged+")")
+		assert.Contains(t, output, "Error: builds/c.zip - server error")
+	})
+
+	t.Run("json", func(t *testing.T) {
+		var buf bytes.Buffer
+		require.NoError(t, (&clientcli.JSONFormatter{}).FormatDownloads(&buf, results))
+
+		var output []map[string]any
+		require.NoError(t, json.Unmarshal(buf.Bytes(), &output))
+		require.Len(t, output, 3)
+		assert.Equal(t, "out/a.zip", output[0]["local_path"])
+		assert.Equal(t, true, output[1]["skipped"])
+		assert.Equal(t, "server error", output[2]["error"])
+	})
+}
+
+func TestFormatter_FormatSync(t *testing.T) {
+	result := &clientcli.SyncResult{
+		LocalDir:     "dist",
+		RemotePrefix: "site",
+		Uploaded:     []clientcli.SyncItem{{LocalPath: "dist/index.html", RemotePath: "site/index.html", Size: 2048, Reason: clientcli.SyncReasonChanged}},
+		Skipped:      []clientcli.SyncItem{{LocalPath: "dist/app.js", RemotePath: "site/app.js"}},
+		Deleted:      []clientcli.SyncItem{{RemotePath: "site/old.html"}},
+		Errored:      []clientcli.SyncItem{{LocalPath: "dist/big.bin", RemotePath: "site/big.bin", Err: errors.New("server error")}},
+	}
+
+	t.Run("human", func(t *testing.T) {
+		var buf bytes.Buffer
+		require.NoError(t, (&clientcli.HumanFormatter{}).FormatSync(&buf, result))
+
+		output := buf.String()
+		assert.Contains(t, output, "Uploaded: dist/index.html -> site/index.html (changed, 2.0 KB)")
+		assert.Contains(t, output, "Deleted: site/old.html")
+		assert.Contains(t, output, "Error: site/big.bin - server error")
+		assert.Contains(t, output, "1 uploaded, 1 unchanged, 1 deleted, 1 failed")
+	})
+
+	t.Run("human dry run", func(t *testing.T) {
+		dryRun := *result
+		dryRun.DryRun = true
+		var buf bytes.Buffer
+		require.NoError(t, (&clientcli.HumanFormatter{}).FormatSync(&buf, &dryRun))
+
+		assert.Contains(t, buf.String(), "Would upload: dist/index.html -> site/index.html")
+		assert.Contains(t, buf.String(), "Would delete: site/old.html")
+	})
+
+	t.Run("json", func(t *testing.T) {
+		var buf bytes.Buffer
+		require.NoError(t, (&clientcli.JSONFormatter{}).FormatSync(&buf, result))
+
+		var output struct {
+			RemotePrefix string               `json:"remote_prefix"`
+			Uploaded     []clientcli.SyncItem `json:"uploaded"`
+			Errored      []map[string]string  `json:"errored"`
+		}
+		require.NoError(t, json.Unmarshal(buf.Bytes(), &output))
+		assert.Equal(t, "site", output.RemotePrefix)
+		require.Len(t, output.Uploaded, 1)
+		assert.Equal(t, clientcli.SyncReasonChanged, output.Uploaded[0].Reason)
+		require.Len(t, output.Errored, 1)
+		assert.Equal(t, "server error", output.Errored[0]["error"])
+	})
+}
+
 func TestHumanFormatter_FormatDelete(t *testing.T) {
 	formatter := &clientcli.HumanFormatter{}
 	results := []clientcli.DeleteResult{
@@ -157,6 +248,63 @@ func TestHumanFormatter_FormatList(t *testing.T) {
 		assert.Contains(t, output, "2 object(s)")
 		assert.Contains(t, output, "3.0 KB total")
 		assert.Contains(t, output, `--cursor "cursor123"`)
+		assert.NotContains(t, output, "FILENAME")
+	})
+
+	t.Run("filename column", func(t *testing.T) {
+		formatter := &clientcli.HumanFormatter{}
+		updated := time.Date(2024, 1, 15, 10, 30, 0, 0, time.UTC)
+		result := &clientcli.ListResult{
+			Items: []clientcli.ObjectInfo{
+				{Path: "uploads/3f2a9c1d.pdf", Size: 1024, Filename: "Q3 report.pdf", UpdatedAt: updated},
+				{Path: "uploads/plain.txt", Size: 10, UpdatedAt: updated},
+			},
+		}
+
+		var buf bytes.Buffer
+		require.NoError(t, formatter.FormatList(&buf, result))
+
+		lines := strings.Split(buf.String(), "\n")
+		assert.Contains(t, lines[0], "UPDATED              FILENAME")
+		assert.True(t, strings.HasSuffix(lines[2], "2024-01-15 10:30:00  Q3 report.pdf"), lines[2])
+		assert.True(t, strings.HasSuffix(lines[3], "2024-01-15 10:30:00"), lines[3])
+	})
+
+	t.Run("backend column", func(t *testing.T) {
+		formatter := &clientcli.HumanFormatter{ShowBackend: true}
+		updated := time.Date(2024, 1, 15, 10, 30, 0, 0, time.UTC)
+		result := &clientcli.ListResult{
+			Items: []clientcli.ObjectInfo{
+				{Path: "archive/2020.tar", Size: 1024, StorageBackend: "archive/", UpdatedAt: updated},
+				{Path: "index.html", Size: 10, UpdatedAt: updated},
+			},
+		}
+
+		var buf bytes.Buffer
+		require.NoError(t, formatter.FormatList(&buf, result))
+
+		lines := strings.Split(buf.String(), "\n")
+		assert.True(t, strings.HasSuffix(lines[0], "UPDATED              BACKEND"), lines[0])
+		assert.True(t, strings.HasSuffix(lines[2], "2024-01-15 10:30:00  archive/"), lines[2])
+		assert.True(t, strings.HasSuffix(lines[3], "2024-01-15 10:30:00  (default)"), lines[3])
+	})
+
+	t.Run("deleted column", func(t *testing.T) {
+		formatter := &clientcli.HumanFormatter{}
+		updated := time.Date(2024, 1, 15, 10, 30, 0, 0, time.UTC)
+		deleted := time.Date(2024, 2, 1, 8, 0, 0, 0, time.UTC)
+		result := &clientcli.ListResult{
+			Items: []clientcli.ObjectInfo{
+				{Path: "docs/old.txt", Size: 10, UpdatedAt: updated, DeletedAt: &deleted},
+			},
+		}
+
+		var buf bytes.Buffer
+		require.NoError(t, formatter.FormatList(&buf, result))
+
+		lines := strings.Split(buf.String(), "\n")
+		assert.True(t, strings.HasSuffix(lines[0], "UPDATED              DELETED"), lines[0])
+		assert.True(t, strings.HasSuffix(lines[2], "2024-01-15 10:30:00  2024-02-01 08:00:00"), lines[2])
 	})
 
 	t.Run("empty list", func(t *testing.T) {
@@ -203,6 +351,25 @@ func TestJSONFormatter_FormatUpload(t *testing.T) {
 	assert.Equal(t, "local.txt", output[0]["local_path"])
 	assert.Equal(t, "remote.txt", output[0]["remote_path"])
 	assert.Equal(t, id.String(), output[0]["id"])
+	assert.NotContains(t, output[0], "skipped")
+}
+
+func TestJSONFormatter_FormatUpload_Skipped(t *testing.T) {
+	formatter := &clientcli.JSONFormatter{}
+	results := []clientcli.UploadResult{
+		{LocalPath: "build/tmp.o", RemotePath: "out/tmp.o", Skipped: true, SkipReason: clientcli.SkipReasonRemoved},
+	}
+
+	var buf bytes.Buffer
+	require.NoError(t, formatter.FormatUpload(&buf, results))
+
+	var output []map[string]any
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &output))
+	require.Len(t, output, 1)
+	assert.Equal(t, true, output[0]["skipped"])
+	assert.Equal(t, "removed during upload", output[0]["skip_reason"])
+	assert.NotContains(t, output[0], "id")
+	assert.NotContains(t, output[0], "error")
 }
 
 func TestJSONFormatter_FormatDelete(t *testing.T) {
@@ -228,6 +395,64 @@ func TestJSONFormatter_FormatDelete(t *testing.T) {
 	assert.Equal(t, "not found", output["results"][1]["error"])
 }
 
+func TestFormatter_FormatDeletePrefix(t *testing.T) {
+	t.Run("human", func(t *testing.T) {
+		var buf bytes.Buffer
+		require.NoError(t, (&clientcli.HumanFormatter{}).FormatDeletePrefix(&buf, &clientcli.DeletePrefixResult{
+			Prefix:  "builds/",
+			Deleted: 2,
+			Errors:  []clientcli.DeleteResult{{Path: "builds/c.zip", Err: errors.New("internal_error")}},
+		}))
+
+		assert.Contains(t, buf.String(), "Error: builds/c.zip - internal_error")
+		assert.Contains(t, buf.String(), `Deleted 2 object(s) under "builds/"`)
+	})
+
+	t.Run("human dry run", func(t *testing.T) {
+		var buf bytes.Buffer
+		require.NoError(t, (&clientcli.HumanFormatter{}).FormatDeletePrefix(&buf, &clientcli.DeletePrefixResult{
+			Prefix: "builds/", DryRun: true, Deleted: 1, Paths: []string{"builds/a.zip"},
+		}))
+
+		assert.Contains(t, buf.String(), "Would delete: builds/a.zip")
+		assert.Contains(t, buf.String(), `Would delete 1 object(s) under "builds/"`)
+		assert.NotContains(t, buf.String(), "more")
+	})
+
+	t.Run("human dry run with truncated paths", func(t *testing.T) {
+		var buf bytes.Buffer
+		require.NoError(t, (&clientcli.HumanFormatter{}).FormatDeletePrefix(&buf, &clientcli.DeletePrefixResult{
+			Prefix: "builds/", DryRun: true, Deleted: 5, Paths: []string{"builds/a.zip", "builds/b.zip"}, PathsTruncated: true,
+		}))
+
+		assert.Contains(t, buf.String(), "... and 3 more")
+		assert.Contains(t, buf.String(), `Would delete 5 object(s) under "builds/"`)
+	})
+
+	t.Run("json", func(t *testing.T) {
+		var buf bytes.Buffer
+		require.NoError(t, (&clientcli.JSONFormatter{}).FormatDeletePrefix(&buf, &clientcli.DeletePrefixResult{
+			Prefix:  "builds/",
+			Deleted: 2,
+			Errors:  []clientcli.DeleteResult{{Path: "builds/c.zip", Err: errors.New("internal_error")}},
+		}))
+
+		var output struct {
+			Prefix  string `json:"prefix"`
+			Deleted int    `json:"deleted"`
+			Errors  []struct {
+				Path  string `json:"path"`
+				Error string `json:"error"`
+			} `json:"errors"`
+		}
+		require.NoError(t, json.Unmarshal(buf.Bytes(), &output))
+		assert.Equal(t, "builds/", output.Prefix)
+		assert.Equal(t, 2, output.Deleted)
+		require.Len(t, output.Errors, 1)
+		assert.Equal(t, "internal_error", output.Errors[0].Error)
+	})
+}
+
 func TestJSONFormatter_FormatError(t *testing.T) {
 	formatter := &clientcli.JSONFormatter{}
 
@@ -242,6 +467,86 @@ func TestJSONFormatter_FormatError(t *testing.T) {
 	assert.Equal(t, "test error", output["error"])
 }
 
+func TestFormatter_FormatHistory(t *testing.T) {
+	at := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	result := &clientcli.HistoryResult{Path: "a.txt", Changes: []clientcli.Change{
+		{Seq: 1, Kind: "create", Path: "a.txt", ETag: "0123456789abcdef", Size: 2048, At: at, Actor: "AKALICE"},
+		{Seq: 5, Kind: "create", Path: "a.txt", ETag: "fedcba9876543210", Size: 10, At: at, PreviousETag: "0123456789abcdef", Actor: "AKBOB"},
+		{Seq: 9, Kind: "delete", Path: "a.txt", ETag: "fedcba9876543210", Size: 10, At: at},
+	}}
+
+	t.Run("human", func(t *testing.T) {
+		var buf bytes.Buffer
+		require.NoError(t, (&clientcli.HumanFormatter{}).FormatHistory(&buf, result))
+
+		lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+		require.Len(t, lines, 5)
+		assert.Equal(t, []string{"SEQ", "TIME", "EVENT", "SIZE", "ETAG", "PREVIOUS", "ACTOR"}, strings.Fields(lines[0]))
+		assert.Equal(t, []string{"1", "2026-01-02", "03:04:05", "created", "2.0", "KB", "0123456789ab", "AKALICE"}, strings.Fields(lines[2]))
+		assert.Equal(t, []string{"5", "2026-01-02", "03:04:05", "overwritten", "10", "B", "fedcba987654", "0123456789ab", "AKBOB"}, strings.Fields(lines[3]))
+		assert.Equal(t, []string{"9", "2026-01-02", "03:04:05", "deleted", "10", "B", "fedcba987654"}, strings.Fields(lines[4]))
+	})
+
+	t.Run("human without changes", func(t *testing.T) {
+		var buf bytes.Buffer
+		require.NoError(t, (&clientcli.HumanFormatter{}).FormatHistory(&buf, &clientcli.HistoryResult{Path: "a.txt"}))
+		assert.Equal(t, "No recorded changes\n", buf.String())
+	})
+
+	t.Run("json keeps full etags", func(t *testing.T) {
+		var buf bytes.Buffer
+		require.NoError(t, (&clientcli.JSONFormatter{}).FormatHistory(&buf, result))
+
+		var output clientcli.HistoryResult
+		require.NoError(t, json.Unmarshal(buf.Bytes(), &output))
+		assert.Equal(t, *result, output)
+	})
+}
+
+func TestFormatter_FormatCapabilities(t *testing.T) {
+	caps := clientcli.Capabilities{
+		Version:     "1.4.0",
+		Mode:        "store",
+		Limits:      clientcli.CapabilityLimits{MaxUploadSize: 10 << 20, MaxListLimit: 1000, MaxStatPaths: 1000},
+		PathLimits:  &clientcli.PathLimits{MaxSegmentBytes: 255, MaxKeyBytes: 1024, MaxDepth: 64},
+		Features:    []string{"range", "stage"},
+		ListFormats: []string{"json", "csv"},
+	}
+
+	t.Run("human", func(t *testing.T) {
+		var buf bytes.Buffer
+		require.NoError(t, (&clientcli.HumanFormatter{}).FormatCapabilities(&buf, "http://localhost:5708", caps))
+
+		out := buf.String()
+		assert.Contains(t, out, "Version:       1.4.0")
+		assert.Contains(t, out, "Features:      range, stage")
+		assert.Contains(t, out, "Compression:   none")
+		assert.Contains(t, out, "max_upload_size:         10.0 MB")
+		assert.Contains(t, out, "max_export_rows:         unlimited")
+		assert.Contains(t, out, "max_key_bytes:           1024")
+		assert.NotContains(t, out, "conservative defaults")
+	})
+
+	t.Run("human offline", func(t *testing.T) {
+		var buf bytes.Buffer
+		require.NoError(t, (&clientcli.HumanFormatter{}).FormatCapabilities(&buf, "http://localhost:5708", clientcli.DefaultCapabilities()))
+
+		assert.Contains(t, buf.String(), "conservative defaults")
+		assert.Contains(t, buf.String(), "Features:      none")
+	})
+
+	t.Run("json", func(t *testing.T) {
+		var buf bytes.Buffer
+		require.NoError(t, (&clientcli.JSONFormatter{}).FormatCapabilities(&buf, "http://localhost:5708", caps))
+
+		var output map[string]any
+		require.NoError(t, json.Unmarshal(buf.Bytes(), &output))
+		assert.Equal(t, "http://localhost:5708", output["endpoint"])
+		assert.Equal(t, "store", output["mode"])
+		assert.Equal(t, []any{"range", "stage"}, output["features"])
+	})
+}
+
 func TestHumanFormatter_FormatProfileList(t *testing.T) {
 	formatter := &clientcli.HumanFormatter{}
 	profiles := []clientcli.Profile{
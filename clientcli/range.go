@@ -0,0 +1,359 @@
+package clientcli
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+const (
+	// DefaultRangeBlockSize is the size of the blocks a RangeReader fetches
+	// and caches when RangeOptions.BlockSize is not set (256 KiB).
+	DefaultRangeBlockSize = 256 << 10
+
+	// DefaultRangeCacheBlocks is the number of blocks a RangeReader caches
+	// when RangeOptions.CacheBlocks is not set.
+	DefaultRangeCacheBlocks = 16
+)
+
+// RangeOptions configures OpenRange.
+type RangeOptions struct {
+	RemotePath  string
+	BlockSize   int64 // Bytes per ranged GET unit and cache entry (default: DefaultRangeBlockSize)
+	ReadAhead   int   // Blocks fetched past the ones a read needs, in the same request
+	CacheBlocks int   // Blocks kept in the LRU cache (default: DefaultRangeCacheBlocks)
+}
+
+// ObjectChangedError reports an object that was replaced or deleted while a
+// RangeReader was reading it. It wraps ErrObjectChanged.
+type ObjectChangedError struct {
+	Path    string
+	ETag    string // ETag when the reader was opened
+	Current string // ETag now; empty if the object was deleted
+}
+
+func (e *ObjectChangedError) Error() string {
+	if e.Current == "" {
+		return fmt.Sprintf("%s: %s was deleted", ErrObjectChanged, e.Path)
+	}
+	return fmt.Sprintf("%s: %s changed from etag %s to %s", ErrObjectChanged, e.Path, e.ETag, e.Current)
+}
+
+func (e *ObjectChangedError) Unwrap() error {
+	return ErrObjectChanged
+}
+
+// Stat returns the metadata of the object at remotePath from a HEAD request.
+// ID and CreatedAt are not set, since HEAD does not report them.
+func (c *Client) Stat(ctx context.Context, remotePath string) (*ObjectInfo, error) {
+	if remotePath == "" {
+		return nil, fmt.Errorf("stat: %w", ErrEmptyPath)
+	}
+	remotePath = normalizePath(remotePath)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, c.presign(http.MethodHead, remotePath, nil, DefaultExpires), http.NoBody)
+	if err != nil {
+		return nil, fmt.Errorf("create request: %w", err)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("do request: %w", err)
+	}
+	_ = resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, parseServerError(resp.StatusCode, nil)
+	}
+
+	info := &ObjectInfo{
+		Path:            strings.TrimPrefix(remotePath, "/"),
+		ContentType:     resp.Header.Get("Content-Type"),
+		ContentEncoding: storedEncoding(resp),
+		Filename:        responseFilename(resp),
+		ETag:            responseETag(resp),
+		Size:            resp.ContentLength,
+	}
+	if modTime, err := http.ParseTime(resp.Header.Get("Last-Modified")); err == nil {
+		info.UpdatedAt = modTime
+	}
+	return info, nil
+}
+
+// OpenRange opens the object at opts.RemotePath for random access without
+// downloading it. Reads issue ranged GETs for the blocks they cover, which
+// are kept in a small LRU cache, so reading the footer of a large file costs
+// one request for the footer alone. An object stored with a Content-Encoding
+// is read as its encoded bytes.
+//
+// Every request sends If-Range with the ETag from opening, so a reader never
+// mixes versions: once the object is replaced or deleted, reads fail with an
+// *ObjectChangedError. Requests use ctx, which must stay valid until the
+// reader is closed.
+func (c *Client) OpenRange(ctx context.Context, opts RangeOptions) (*RangeReader, error) {
+	info, err := c.Stat(ctx, opts.RemotePath)
+	if err != nil {
+		return nil, fmt.Errorf("open range: %w", err)
+	}
+	if info.Size < 0 {
+		return nil, fmt.Errorf("open range %s: server did not report the object size", info.Path)
+	}
+
+	if opts.BlockSize <= 0 {
+		opts.BlockSize = DefaultRangeBlockSize
+	}
+	if opts.CacheBlocks <= 0 {
+		opts.CacheBlocks = DefaultRangeCacheBlocks
+	}
+
+	return &RangeReader{
+		client:    c,
+		ctx:       ctx,
+		info:      *info,
+		blockSize: opts.BlockSize,
+		maxRun:    int64(opts.CacheBlocks),
+		readAhead: int64(max(opts.ReadAhead, 0)),
+		cache:     newBlockCache(opts.CacheBlocks),
+	}, nil
+}
+
+// RangeReader reads an object with ranged GETs. It implements io.ReaderAt
+// and io.ReadSeekCloser. ReadAt is safe for concurrent use; Read and Seek
+// share one offset.
+type RangeReader struct {
+	client    *Client
+	ctx       context.Context
+	info      ObjectInfo
+	blockSize int64
+	maxRun    int64 // most blocks fetched in one request, so a run never evicts itself
+	readAhead int64
+	cache     *blockCache
+	closed    atomic.Bool
+
+	mu     sync.Mutex
+	offset int64
+}
+
+// Info returns the object metadata from when the reader was opened.
+func (r *RangeReader) Info() ObjectInfo {
+	return r.info
+}
+
+// Size returns the object size in bytes.
+func (r *RangeReader) Size() int64 {
+	return r.info.Size
+}
+
+// ReadAt reads len(p) bytes at off, fetching the blocks that are not cached.
+func (r *RangeReader) ReadAt(p []byte, off int64) (int, error) {
+	if r.closed.Load() {
+		return 0, fs.ErrClosed
+	}
+	if off < 0 {
+		return 0, fmt.Errorf("read %s: negative offset %d", r.info.Path, off)
+	}
+	if off >= r.info.Size {
+		return 0, io.EOF
+	}
+
+	want := min(int64(len(p)), r.info.Size-off)
+	lastNeeded := (off + want - 1) / r.blockSize
+
+	var n int64
+	for n < want {
+		pos := off + n
+		idx := pos / r.blockSize
+
+		block, ok := r.cache.get(idx)
+		if !ok {
+			var err error
+			if block, err = r.fetch(idx, lastNeeded); err != nil {
+				return int(n), err
+			}
+		}
+		n += int64(copy(p[n:want], block[pos-idx*r.blockSize:]))
+	}
+
+	if want < int64(len(p)) {
+		return int(n), io.EOF
+	}
+	return int(n), nil
+}
+
+// Read reads from the current offset.
+func (r *RangeReader) Read(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if len(p) == 0 {
+		return 0, nil
+	}
+	n, err := r.ReadAt(p, r.offset)
+	r.offset += int64(n)
+	if n > 0 && errors.Is(err, io.EOF) {
+		err = nil
+	}
+	return n, err
+}
+
+// Seek sets the offset for the next Read. io.SeekEnd is relative to the size
+// from when the reader was opened.
+func (r *RangeReader) Seek(offset int64, whence int) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += r.offset
+	case io.SeekEnd:
+		offset += r.info.Size
+	default:
+		return 0, fmt.Errorf("seek %s: invalid whence %d", r.info.Path, whence)
+	}
+	if offset < 0 {
+		return 0, fmt.Errorf("seek %s: negative position %d", r.info.Path, offset)
+	}
+	r.offset = offset
+	return offset, nil
+}
+
+// Close releases the cached blocks. Later reads fail with fs.ErrClosed.
+func (r *RangeReader) Close() error {
+	r.closed.Store(true)
+	r.cache.clear()
+	return nil
+}
+
+// fetch gets block first and the blocks after it, through lastNeeded plus
+// the read-ahead, in one ranged GET, caches them and returns the first.
+func (r *RangeReader) fetch(first, lastNeeded int64) ([]byte, error) {
+	lastBlock := (r.info.Size - 1) / r.blockSize
+	last := min(lastNeeded+r.readAhead, first+r.maxRun-1, lastBlock)
+
+	start := first * r.blockSize
+	end := min((last+1)*r.blockSize, r.info.Size) // exclusive
+
+	body, err := r.get(start, end)
+	if err != nil {
+		return nil, err
+	}
+
+	for idx := first; idx <= last; idx++ {
+		from := (idx - first) * r.blockSize
+		r.cache.put(idx, body[from:min(from+r.blockSize, int64(len(body)))])
+	}
+	return body[:min(r.blockSize, int64(len(body)))], nil
+}
+
+// get reads bytes [start, end) of the object version the reader was opened on.
+func (r *RangeReader) get(start, end int64) ([]byte, error) {
+	remotePath := "/" + r.info.Path
+	req, err := http.NewRequestWithContext(r.ctx, http.MethodGet, r.client.signer.PresignGet(remotePath, DefaultExpires), http.NoBody)
+	if err != nil {
+		return nil, fmt.Errorf("create request: %w", err)
+	}
+	req.Header.Set("Range", "bytes="+strconv.FormatInt(start, 10)+"-"+strconv.FormatInt(end-1, 10))
+	req.Header.Set("If-Range", `"`+r.info.ETag+`"`)
+
+	resp, err := r.client.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("do request: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return nil, &ObjectChangedError{Path: r.info.Path, ETag: r.info.ETag}
+	case resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusPartialContent:
+		body, _ := io.ReadAll(resp.Body)
+		return nil, parseServerError(resp.StatusCode, body)
+	}
+
+	// If-Range answers a changed object with the whole new version
+	if current := responseETag(resp); current != r.info.ETag {
+		return nil, &ObjectChangedError{Path: r.info.Path, ETag: r.info.ETag, Current: current}
+	}
+	if resp.StatusCode != http.StatusPartialContent {
+		return nil, fmt.Errorf("read %s: %w", r.info.Path, ErrRangeUnsupported)
+	}
+
+	body := make([]byte, end-start)
+	if _, err := io.ReadFull(resp.Body, body); err != nil {
+		return nil, fmt.Errorf("read %s bytes %d-%d: %w", r.info.Path, start, end-1, err)
+	}
+	return body, nil
+}
+
+// responseETag returns the ETag of resp without quotes. A weak ETag from
+// response compression still carries the SHA-256 of the stored content.
+func responseETag(resp *http.Response) string {
+	return strings.Trim(strings.TrimPrefix(resp.Header.Get("ETag"), "W/"), `"`)
+}
+
+// blockCache is an LRU of object blocks keyed by block index.
+type blockCache struct {
+	capacity int
+
+	mu      sync.Mutex
+	lru     *list.List // front is most recently used
+	entries map[int64]*list.Element
+}
+
+type cachedBlock struct {
+	idx  int64
+	data []byte
+}
+
+func newBlockCache(capacity int) *blockCache {
+	return &blockCache{
+		capacity: capacity,
+		lru:      list.New(),
+		entries:  make(map[int64]*list.Element),
+	}
+}
+
+func (bc *blockCache) get(idx int64) ([]byte, bool) {
+	bc.mu.Lock()
+	defer bc.mu.Unlock()
+
+	el, ok := bc.entries[idx]
+	if !ok {
+		return nil, false
+	}
+	bc.lru.MoveToFront(el)
+	return el.Value.(*cachedBlock).data, true
+}
+
+func (bc *blockCache) put(idx int64, data []byte) {
+	bc.mu.Lock()
+	defer bc.mu.Unlock()
+
+	if el, ok := bc.entries[idx]; ok {
+		el.Value.(*cachedBlock).data = data
+		bc.lru.MoveToFront(el)
+		return
+	}
+	bc.entries[idx] = bc.lru.PushFront(&cachedBlock{idx: idx, data: data})
+	for bc.lru.Len() > bc.capacity {
+		oldest := bc.lru.Back()
+		bc.lru.Remove(oldest)
+		delete(bc.entries, oldest.Value.(*cachedBlock).idx)
+	}
+}
+
+func (bc *blockCache) clear() {
+	bc.mu.Lock()
+	defer bc.mu.Unlock()
+
+	bc.lru.Init()
+	clear(bc.entries)
+}
@@ -0,0 +1,269 @@
+package clientcli_test
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sagarc03/stowry/clientcli"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// rangeServer serves one object version at a time with http.ServeContent,
+// which answers Range and If-Range like the stowry server, and records the
+// Range header of every GET.
+type rangeServer struct {
+	mu      sync.Mutex
+	content []byte
+	etag    string
+	deleted bool
+	ranges  []string
+}
+
+func (s *rangeServer) replace(content []byte, etag string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.content, s.etag = content, etag
+}
+
+func (s *rangeServer) delete() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.deleted = true
+}
+
+func (s *rangeServer) requests() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.ranges...)
+}
+
+func (s *rangeServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if r.Method == http.MethodGet {
+		s.ranges = append(s.ranges, r.Header.Get("Range"))
+	}
+	if s.deleted {
+		http.Error(w, `{"error":"not_found"}`, http.StatusNotFound)
+		return
+	}
+	w.Header().Set("ETag", `"`+s.etag+`"`)
+	w.Header().Set("Content-Type", "application/octet-stream")
+	http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(s.content))
+}
+
+// testContent returns n bytes that differ at every offset modulo 251.
+func testContent(n int) []byte {
+	b := make([]byte, n)
+	for i := range b {
+		b[i] = byte(i % 251)
+	}
+	return b
+}
+
+func newRangeClient(t *testing.T, content []byte) (*clientcli.Client, *rangeServer) {
+	t.Helper()
+	objects := &rangeServer{content: content, etag: "v1"}
+	server := httptest.NewServer(objects)
+	t.Cleanup(server.Close)
+
+	client, err := clientcli.New(&clientcli.Config{Endpoint: server.URL, AccessKey: "k", SecretKey: "s"})
+	require.NoError(t, err)
+	return client, objects
+}
+
+func TestClient_Stat(t *testing.T) {
+	client, _ := newRangeClient(t, testContent(1000))
+
+	info, err := client.Stat(context.Background(), "data/file.parquet")
+
+	require.NoError(t, err)
+	assert.Equal(t, "data/file.parquet", info.Path)
+	assert.Equal(t, int64(1000), info.Size)
+	assert.Equal(t, "v1", info.ETag)
+	assert.Equal(t, "application/octet-stream", info.ContentType)
+}
+
+func TestRangeReader_ReadAt(t *testing.T) {
+	content := testContent(1000)
+	ctx := context.Background()
+
+	t.Run("footer costs one ranged GET", func(t *testing.T) {
+		client, server := newRangeClient(t, content)
+		r, err := client.OpenRange(ctx, clientcli.RangeOptions{RemotePath: "f", BlockSize: 100})
+		require.NoError(t, err)
+		defer func() { _ = r.Close() }()
+
+		footer := make([]byte, 64)
+		n, err := r.ReadAt(footer, r.Size()-64)
+
+		require.NoError(t, err)
+		assert.Equal(t, 64, n)
+		assert.Equal(t, content[936:], footer)
+		assert.Equal(t, []string{"bytes=900-999"}, server.requests())
+	})
+
+	t.Run("cached blocks are not fetched again", func(t *testing.T) {
+		client, server := newRangeClient(t, content)
+		r, err := client.OpenRange(ctx, clientcli.RangeOptions{RemotePath: "f", BlockSize: 100})
+		require.NoError(t, err)
+
+		buf := make([]byte, 150)
+		_, err = r.ReadAt(buf, 120) // blocks 1 and 2
+		require.NoError(t, err)
+		assert.Equal(t, content[120:270], buf)
+
+		_, err = r.ReadAt(buf[:50], 250) // block 2 again
+		require.NoError(t, err)
+		assert.Equal(t, content[250:300], buf[:50])
+
+		assert.Equal(t, []string{"bytes=100-299"}, server.requests())
+	})
+
+	t.Run("read-ahead extends the request", func(t *testing.T) {
+		client, server := newRangeClient(t, content)
+		r, err := client.OpenRange(ctx, clientcli.RangeOptions{RemotePath: "f", BlockSize: 100, ReadAhead: 2})
+		require.NoError(t, err)
+
+		buf := make([]byte, 10)
+		for _, off := range []int64{0, 150, 250} {
+			_, err = r.ReadAt(buf, off)
+			require.NoError(t, err)
+			assert.Equal(t, content[off:off+10], buf)
+		}
+
+		assert.Equal(t, []string{"bytes=0-299"}, server.requests())
+	})
+
+	t.Run("least recently used blocks are evicted", func(t *testing.T) {
+		client, server := newRangeClient(t, content)
+		r, err := client.OpenRange(ctx, clientcli.RangeOptions{RemotePath: "f", BlockSize: 100, CacheBlocks: 2})
+		require.NoError(t, err)
+
+		buf := make([]byte, 1)
+		for _, off := range []int64{0, 100, 0, 200, 0, 100} {
+			_, err = r.ReadAt(buf, off)
+			require.NoError(t, err)
+		}
+
+		assert.Equal(t, []string{"bytes=0-99", "bytes=100-199", "bytes=200-299", "bytes=100-199"}, server.requests())
+	})
+
+	t.Run("short read at the end", func(t *testing.T) {
+		client, _ := newRangeClient(t, content)
+		r, err := client.OpenRange(ctx, clientcli.RangeOptions{RemotePath: "f", BlockSize: 100})
+		require.NoError(t, err)
+
+		buf := make([]byte, 100)
+		n, err := r.ReadAt(buf, 950)
+		assert.ErrorIs(t, err, io.EOF)
+		assert.Equal(t, 50, n)
+		assert.Equal(t, content[950:], buf[:n])
+
+		_, err = r.ReadAt(buf, 1000)
+		assert.ErrorIs(t, err, io.EOF)
+	})
+}
+
+func TestRangeReader_ReadSeek(t *testing.T) {
+	content := testContent(1000)
+	client, server := newRangeClient(t, content)
+	r, err := client.OpenRange(context.Background(), clientcli.RangeOptions{RemotePath: "f", BlockSize: 256})
+	require.NoError(t, err)
+
+	pos, err := r.Seek(-100, io.SeekEnd)
+	require.NoError(t, err)
+	assert.Equal(t, int64(900), pos)
+
+	tail, err := io.ReadAll(r)
+	require.NoError(t, err)
+	assert.Equal(t, content[900:], tail)
+
+	_, err = r.Seek(0, io.SeekStart)
+	require.NoError(t, err)
+	all, err := io.ReadAll(r)
+	require.NoError(t, err)
+	assert.Equal(t, content, all)
+
+	// io.ReadAll reads 512 bytes first, spanning blocks 0 and 1; block 3 is
+	// still cached from the tail read
+	assert.Equal(t, []string{"bytes=768-999", "bytes=0-511", "bytes=512-767"}, server.requests())
+
+	_, err = r.Seek(-1, io.SeekStart)
+	assert.Error(t, err)
+
+	require.NoError(t, r.Close())
+	_, err = r.Read(make([]byte, 1))
+	assert.ErrorIs(t, err, fs.ErrClosed)
+}
+
+func TestRangeReader_ObjectChanged(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("replaced", func(t *testing.T) {
+		client, server := newRangeClient(t, testContent(1000))
+		r, err := client.OpenRange(ctx, clientcli.RangeOptions{RemotePath: "f", BlockSize: 100})
+		require.NoError(t, err)
+
+		buf := make([]byte, 10)
+		_, err = r.ReadAt(buf, 0)
+		require.NoError(t, err)
+
+		server.replace([]byte(strings.Repeat("x", 1000)), "v2")
+
+		_, err = r.ReadAt(buf, 500)
+		assert.ErrorIs(t, err, clientcli.ErrObjectChanged)
+		var changed *clientcli.ObjectChangedError
+		require.ErrorAs(t, err, &changed)
+		assert.Equal(t, clientcli.ObjectChangedError{Path: "f", ETag: "v1", Current: "v2"}, *changed)
+
+		// Cached blocks of the opened version are still served
+		_, err = r.ReadAt(buf, 0)
+		assert.NoError(t, err)
+	})
+
+	t.Run("deleted", func(t *testing.T) {
+		client, server := newRangeClient(t, testContent(1000))
+		r, err := client.OpenRange(ctx, clientcli.RangeOptions{RemotePath: "f", BlockSize: 100})
+		require.NoError(t, err)
+
+		server.delete()
+
+		_, err = r.ReadAt(make([]byte, 10), 0)
+		var changed *clientcli.ObjectChangedError
+		require.ErrorAs(t, err, &changed)
+		assert.Empty(t, changed.Current)
+		assert.ErrorContains(t, err, "f was deleted")
+	})
+}
+
+func TestRangeReader_RangeUnsupported(t *testing.T) {
+	content := testContent(100)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("ETag", `"v1"`)
+		w.Header().Set("Content-Length", strconv.Itoa(len(content)))
+		if r.Method == http.MethodGet {
+			_, _ = w.Write(content)
+		}
+	}))
+	defer server.Close()
+
+	client, err := clientcli.New(&clientcli.Config{Endpoint: server.URL, AccessKey: "k", SecretKey: "s"})
+	require.NoError(t, err)
+	r, err := client.OpenRange(context.Background(), clientcli.RangeOptions{RemotePath: "f"})
+	require.NoError(t, err)
+
+	_, err = r.ReadAt(make([]byte, 10), 0)
+	assert.ErrorIs(t, err, clientcli.ErrRangeUnsupported)
+}
@@ -0,0 +1,106 @@
+package clientcli
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// Headers of multi-region servers; see the server's http.RegionHeader.
+const (
+	regionHeader       = "X-Stowry-Region"
+	expectRegionHeader = "X-Stowry-Expect-Region"
+)
+
+type regionKey struct{}
+
+// PinRegion returns a context whose requests must be served by region, such
+// as the UploadResult.Region of an earlier upload, so a read sees the write
+// even before other regions have a replica. Each request goes to the
+// configured endpoint with X-Stowry-Expect-Region; when a server in another
+// region answers it with 421 Misdirected Request, it is retried once against
+// the region's endpoint from Config.Regions. Without one, or for an upload
+// whose body cannot be sent again, the 421 is returned as an *APIError.
+// An empty region leaves requests unpinned.
+func PinRegion(ctx context.Context, region string) context.Context {
+	return context.WithValue(ctx, regionKey{}, region)
+}
+
+// PinnedRegion returns the region ctx pins requests to, or "" when none.
+func PinnedRegion(ctx context.Context) string {
+	region, _ := ctx.Value(regionKey{}).(string)
+	return region
+}
+
+// regionTransport sends requests pinned with PinRegion and retries those a
+// server in another region refused against the region's own endpoint.
+type regionTransport struct {
+	base     http.RoundTripper
+	endpoint string            // Config.Endpoint, which request URLs start with
+	regions  map[string]string // region -> endpoint
+}
+
+// withRegions returns a copy of client whose transport honors PinRegion.
+func withRegions(client *http.Client, endpoint string, regions map[string]string) *http.Client {
+	base := client.Transport
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	c := *client
+	c.Transport = regionTransport{base: base, endpoint: endpoint, regions: regions}
+	return &c
+}
+
+func (t regionTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	region := PinnedRegion(req.Context())
+	if region == "" {
+		return t.base.RoundTrip(req)
+	}
+
+	pinned := req.Clone(req.Context())
+	pinned.Header.Set(expectRegionHeader, region)
+	resp, err := t.base.RoundTrip(pinned)
+	if err != nil || resp.StatusCode != http.StatusMisdirectedRequest {
+		return resp, err
+	}
+
+	retry, ok := t.redirect(pinned, t.regions[region])
+	if !ok {
+		return resp, nil
+	}
+	_, _ = io.Copy(io.Discard, resp.Body)
+	_ = resp.Body.Close()
+	return t.base.RoundTrip(retry)
+}
+
+// redirect returns req sent to endpoint instead of t.endpoint, or false when
+// there is nowhere else to send it or its body cannot be sent again.
+func (t regionTransport) redirect(req *http.Request, endpoint string) (*http.Request, bool) {
+	endpoint = strings.TrimSuffix(endpoint, "/")
+	target := req.URL.String()
+	if endpoint == "" || endpoint == t.endpoint || !strings.HasPrefix(target, t.endpoint) {
+		return nil, false
+	}
+	u, err := url.Parse(endpoint + strings.TrimPrefix(target, t.endpoint))
+	if err != nil {
+		return nil, false
+	}
+
+	// Signatures cover the path, not the host, so they stay valid
+	retry := req.Clone(req.Context())
+	retry.URL = u
+	retry.Host = ""
+	if req.Body != nil && req.Body != http.NoBody {
+		if req.GetBody == nil {
+			return nil, false
+		}
+		body, err := req.GetBody()
+		if err != nil {
+			return nil, false
+		}
+		retry.Body = body
+	}
+	return retry, true
+}
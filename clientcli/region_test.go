@@ -0,0 +1,201 @@
+package clientcli_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sagarc03/stowry/clientcli"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// regionServer is a fake server in one region of a multi-region
+// deployment. It answers 421 to requests expecting another region.
+type regionServer struct {
+	*httptest.Server
+	region     string
+	requests   atomic.Int32
+	misdirects atomic.Int32
+	expected   atomic.Value // X-Stowry-Expect-Region of the last request
+}
+
+func newRegionServer(t *testing.T, region string) *regionServer {
+	t.Helper()
+	s := &regionServer{region: region}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.requests.Add(1)
+		s.expected.Store(r.Header.Get("X-Stowry-Expect-Region"))
+		w.Header().Set("X-Stowry-Region", region)
+		if expect := r.Header.Get("X-Stowry-Expect-Region"); expect != "" && expect != region {
+			s.misdirects.Add(1)
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusMisdirectedRequest)
+			_, _ = w.Write([]byte(`{"error":"misdirected_request","message":"wrong region"}`))
+			return
+		}
+		assert.NotEmpty(t, r.URL.Query().Get("X-Stowry-Signature"), "request is signed")
+
+		switch r.Method {
+		case http.MethodPut:
+			body, _ := io.ReadAll(r.Body)
+			now := time.Now().UTC()
+			_ = json.NewEncoder(w).Encode(map[string]any{
+				"id": uuid.New().String(), "path": r.URL.Path[1:], "content_type": "text/plain",
+				"etag": "abc", "file_size_bytes": len(body), "created_at": now, "updated_at": now,
+			})
+		case http.MethodGet:
+			w.Header().Set("Content-Type", "text/plain")
+			_, _ = w.Write([]byte("from " + region))
+		default:
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func TestClient_Regions(t *testing.T) {
+	newClient := func(t *testing.T, endpoint string, regions map[string]string) *clientcli.Client {
+		t.Helper()
+		client, err := clientcli.New(&clientcli.Config{
+			Endpoint:  endpoint,
+			AccessKey: "test-key",
+			SecretKey: "test-secret",
+			Regions:   regions,
+		})
+		require.NoError(t, err)
+		return client
+	}
+	download := func(t *testing.T, ctx context.Context, client *clientcli.Client) (string, error) {
+		t.Helper()
+		_, body, err := client.Download(ctx, clientcli.DownloadOptions{RemotePath: "a.txt", LocalPath: "-"})
+		if err != nil {
+			return "", err
+		}
+		defer func() { _ = body.Close() }()
+		content, err := io.ReadAll(body)
+		require.NoError(t, err)
+		return string(content), nil
+	}
+
+	t.Run("upload records the acknowledging region", func(t *testing.T) {
+		eu := newRegionServer(t, "eu-west-1")
+		client := newClient(t, eu.URL, nil)
+		local := filepath.Join(t.TempDir(), "a.txt")
+		require.NoError(t, os.WriteFile(local, []byte("hello"), 0o600))
+
+		results, err := client.Upload(context.Background(), clientcli.UploadOptions{LocalPath: local, RemotePath: "a.txt"})
+
+		require.NoError(t, err)
+		require.Len(t, results, 1)
+		assert.Equal(t, "eu-west-1", results[0].Region)
+	})
+
+	t.Run("unpinned requests expect no region", func(t *testing.T) {
+		us := newRegionServer(t, "us-east-1")
+		client := newClient(t, us.URL, nil)
+
+		content, err := download(t, context.Background(), client)
+
+		require.NoError(t, err)
+		assert.Equal(t, "from us-east-1", content)
+		assert.Empty(t, us.expected.Load())
+	})
+
+	t.Run("pinned request served by the endpoint in that region", func(t *testing.T) {
+		eu := newRegionServer(t, "eu-west-1")
+		client := newClient(t, eu.URL, nil)
+
+		content, err := download(t, clientcli.PinRegion(context.Background(), "eu-west-1"), client)
+
+		require.NoError(t, err)
+		assert.Equal(t, "from eu-west-1", content)
+		assert.Equal(t, "eu-west-1", eu.expected.Load())
+		assert.Zero(t, eu.misdirects.Load())
+	})
+
+	t.Run("misdirected request is retried against the region's endpoint", func(t *testing.T) {
+		us := newRegionServer(t, "us-east-1") // where the configured endpoint routes
+		eu := newRegionServer(t, "eu-west-1")
+		client := newClient(t, us.URL, map[string]string{"eu-west-1": eu.URL + "/"})
+
+		content, err := download(t, clientcli.PinRegion(context.Background(), "eu-west-1"), client)
+
+		require.NoError(t, err)
+		assert.Equal(t, "from eu-west-1", content)
+		assert.Equal(t, int32(1), us.misdirects.Load())
+		assert.Equal(t, int32(1), eu.requests.Load())
+		assert.Equal(t, "eu-west-1", eu.expected.Load())
+	})
+
+	t.Run("misdirected delete is retried", func(t *testing.T) {
+		us := newRegionServer(t, "us-east-1")
+		eu := newRegionServer(t, "eu-west-1")
+		client := newClient(t, us.URL, map[string]string{"eu-west-1": eu.URL})
+
+		results, err := client.Delete(clientcli.PinRegion(context.Background(), "eu-west-1"), clientcli.DeleteOptions{Paths: []string{"a.txt"}})
+
+		require.NoError(t, err)
+		require.Len(t, results, 1)
+		require.NoError(t, results[0].Err)
+		assert.Equal(t, int32(1), us.misdirects.Load())
+		assert.Equal(t, int32(1), eu.requests.Load())
+	})
+
+	t.Run("misdirected request without a region endpoint fails", func(t *testing.T) {
+		us := newRegionServer(t, "us-east-1")
+		client := newClient(t, us.URL, nil)
+
+		_, err := download(t, clientcli.PinRegion(context.Background(), "eu-west-1"), client)
+
+		var apiErr *clientcli.APIError
+		require.True(t, errors.As(err, &apiErr), "got %v", err)
+		assert.Equal(t, http.StatusMisdirectedRequest, apiErr.StatusCode)
+		assert.Equal(t, int32(1), us.requests.Load())
+	})
+
+	t.Run("a region endpoint that refuses too is not retried again", func(t *testing.T) {
+		us := newRegionServer(t, "us-east-1")
+		stale := newRegionServer(t, "ap-south-1") // configured for eu-west-1 by mistake
+		client := newClient(t, us.URL, map[string]string{"eu-west-1": stale.URL})
+
+		_, err := download(t, clientcli.PinRegion(context.Background(), "eu-west-1"), client)
+
+		require.ErrorIs(t, err, &clientcli.APIError{StatusCode: http.StatusMisdirectedRequest})
+		assert.Equal(t, int32(1), us.requests.Load())
+		assert.Equal(t, int32(1), stale.requests.Load())
+	})
+
+	t.Run("upload then pinned read in one run", func(t *testing.T) {
+		eu := newRegionServer(t, "eu-west-1") // the endpoint routed the upload here
+		us := newRegionServer(t, "us-east-1")
+		uploader := newClient(t, eu.URL, nil)
+		local := filepath.Join(t.TempDir(), "a.txt")
+		require.NoError(t, os.WriteFile(local, []byte("hello"), 0o600))
+		results, err := uploader.Upload(context.Background(), clientcli.UploadOptions{LocalPath: local, RemotePath: "a.txt"})
+		require.NoError(t, err)
+
+		// The next request lands in another region and is sent back
+		reader := newClient(t, us.URL, map[string]string{"eu-west-1": eu.URL, "us-east-1": us.URL})
+		content, err := download(t, clientcli.PinRegion(context.Background(), results[0].Region), reader)
+
+		require.NoError(t, err)
+		assert.Equal(t, "from eu-west-1", content)
+		assert.Equal(t, int32(1), us.misdirects.Load())
+	})
+}
+
+func TestPinnedRegion(t *testing.T) {
+	assert.Empty(t, clientcli.PinnedRegion(context.Background()))
+	assert.Equal(t, "eu-west-1", clientcli.PinnedRegion(clientcli.PinRegion(context.Background(), "eu-west-1")))
+}
@@ -0,0 +1,61 @@
+package clientcli
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+)
+
+// Restore undoes the delete of the object at path on the server (store mode
+// only, with write credentials), as long as it was not cleaned up yet. The
+// object comes back with its ID and content. It fails with an APIError of
+// status 404 when path has no deleted object, 410 when it was cleaned up,
+// and 409 when path has an active object again.
+//
+// It returns ErrRestoreUnsupported when the server cannot restore objects,
+// without a request when its capabilities say so.
+func (c *Client) Restore(ctx context.Context, path string) (*ObjectInfo, error) {
+	if path == "" {
+		return nil, fmt.Errorf("restore: %w", ErrEmptyPath)
+	}
+	if c.capabilities(ctx).rulesOut("restore") {
+		return nil, fmt.Errorf("restore: %w", ErrRestoreUnsupported)
+	}
+
+	remotePath := normalizePath(path)
+	defer c.Purge(remotePath)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.presign(http.MethodPost, remotePath, url.Values{"restore": {"1"}}, DefaultExpires), http.NoBody)
+	if err != nil {
+		return nil, fmt.Errorf("create request: %w", err)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("do request: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("read response: %w", err)
+	}
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusMethodNotAllowed, http.StatusNotImplemented:
+		return nil, ErrRestoreUnsupported
+	default:
+		return nil, parseServerError(resp.StatusCode, body)
+	}
+
+	var restored serverMetaData
+	if err := json.Unmarshal(body, &restored); err != nil {
+		return nil, fmt.Errorf("parse response: %w", err)
+	}
+	info := restored.objectInfo()
+	return &info, nil
+}
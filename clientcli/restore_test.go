@@ -0,0 +1,103 @@
+package clientcli_test
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	stowrycore "github.com/sagarc03/stowry"
+	"github.com/sagarc03/stowry/clientcli"
+	"github.com/sagarc03/stowry/keybackend"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClient_Restore(t *testing.T) {
+	t.Run("posts restore and parses the object", func(t *testing.T) {
+		verifier := stowrycore.NewStowrySignatureVerifier(keybackend.NewMapSecretStore(map[string]string{"test-key": "test-secret"}))
+		server := httptest.NewServer(withoutCapabilities(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, http.MethodPost, r.Method)
+			assert.Equal(t, "/docs/report.pdf", r.URL.Path)
+			assert.True(t, r.URL.Query().Has("restore"))
+			assert.NoError(t, verifier.Verify(r), "the signature covers the restore flag")
+			_ = json.NewEncoder(w).Encode(map[string]any{
+				"path": "docs/report.pdf", "content_type": "application/pdf", "etag": "abc", "file_size_bytes": 3,
+			})
+		})))
+		defer server.Close()
+
+		client, err := clientcli.New(&clientcli.Config{Endpoint: server.URL, AccessKey: "test-key", SecretKey: "test-secret"})
+		require.NoError(t, err)
+
+		result, err := client.Restore(t.Context(), "docs/report.pdf")
+		require.NoError(t, err)
+		assert.Equal(t, "docs/report.pdf", result.Path)
+		assert.Equal(t, "abc", result.ETag)
+		assert.Equal(t, int64(3), result.Size)
+	})
+
+	t.Run("cleaned up", func(t *testing.T) {
+		server := httptest.NewServer(withoutCapabilities(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			w.WriteHeader(http.StatusGone)
+			_, _ = w.Write([]byte(`{"error":"gone","message":"Object is gone"}`))
+		})))
+		defer server.Close()
+
+		client, err := clientcli.New(&clientcli.Config{Endpoint: server.URL})
+		require.NoError(t, err)
+
+		_, err = client.Restore(t.Context(), "a.txt")
+		var apiErr *clientcli.APIError
+		require.True(t, errors.As(err, &apiErr))
+		assert.Equal(t, http.StatusGone, apiErr.StatusCode)
+	})
+
+	t.Run("server without restore in its capabilities", func(t *testing.T) {
+		fake := &capabilityServer{document: storeCapabilities}
+		client := newCapabilityClient(t, fake)
+
+		_, err := client.Restore(t.Context(), "a.txt")
+		require.ErrorIs(t, err, clientcli.ErrRestoreUnsupported)
+		assert.Equal(t, []string{"GET /.well-known/stowry"}, fake.recorded())
+	})
+
+	t.Run("older server", func(t *testing.T) {
+		server := httptest.NewServer(withoutCapabilities(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		})))
+		defer server.Close()
+
+		client, err := clientcli.New(&clientcli.Config{Endpoint: server.URL})
+		require.NoError(t, err)
+
+		_, err = client.Restore(t.Context(), "a.txt")
+		assert.ErrorIs(t, err, clientcli.ErrRestoreUnsupported)
+	})
+
+	t.Run("empty path", func(t *testing.T) {
+		client, err := clientcli.New(&clientcli.Config{Endpoint: "http://localhost"})
+		require.NoError(t, err)
+
+		_, err = client.Restore(t.Context(), "")
+		assert.ErrorIs(t, err, clientcli.ErrEmptyPath)
+	})
+}
+
+func TestClient_ListDeleted(t *testing.T) {
+	server := httptest.NewServer(withoutCapabilities(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "true", r.URL.Query().Get("deleted"))
+		_, _ = w.Write([]byte(`{"items":[{"path":"a.txt","etag":"abc","file_size_bytes":1,"deleted_at":"2026-03-01T12:00:00Z"}]}`))
+	})))
+	defer server.Close()
+
+	client, err := clientcli.New(&clientcli.Config{Endpoint: server.URL})
+	require.NoError(t, err)
+
+	result, err := client.List(t.Context(), clientcli.ListOptions{Deleted: true, All: true})
+	require.NoError(t, err)
+	require.Len(t, result.Items, 1)
+	require.NotNil(t, result.Items[0].DeletedAt)
+	assert.Equal(t, 2026, result.Items[0].DeletedAt.Year())
+}
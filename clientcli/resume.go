@@ -0,0 +1,150 @@
+package clientcli
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// A resumable download writes to the local path with PartialSuffix until it
+// completes. The ETag of the response it started from is kept next to it with
+// PartialETagSuffix and sent in If-Range when the download is resumed.
+const (
+	PartialSuffix     = ".partial"
+	PartialETagSuffix = ".partial.etag"
+)
+
+// partialDownload is the state an interrupted resumable download left behind.
+type partialDownload struct {
+	localPath string
+	size      int64  // bytes in the partial file
+	etag      string // ETag of the response the partial file came from
+}
+
+// loadPartial returns the partial download of localPath. Without both the
+// partial file and its ETag it is not resumable.
+func loadPartial(localPath string) partialDownload {
+	p := partialDownload{localPath: localPath}
+
+	info, err := os.Stat(p.path())
+	if err != nil || !info.Mode().IsRegular() {
+		return p
+	}
+	etag, err := os.ReadFile(p.etagPath())
+	if err != nil {
+		return p
+	}
+
+	p.size = info.Size()
+	p.etag = strings.TrimSpace(string(etag))
+	return p
+}
+
+func (p partialDownload) path() string     { return p.localPath + PartialSuffix }
+func (p partialDownload) etagPath() string { return p.localPath + PartialETagSuffix }
+
+// resumable reports whether the download can continue from the partial file.
+func (p partialDownload) resumable() bool {
+	return p.size > 0 && p.etag != ""
+}
+
+// continuedBy reports whether resp, a 206 response to the resume request,
+// continues the partial file: same ETag, starting at its end, in the form
+// the partial file was written in.
+func (p partialDownload) continuedBy(resp *http.Response, raw bool) bool {
+	if responseETag(resp) != p.etag {
+		return false
+	}
+	// Only stored bytes are resumable; a partial file of an encoded object
+	// was written with raw
+	if storedEncoding(resp) != "" && !raw {
+		return false
+	}
+	start, ok := contentRangeStart(resp.Header.Get("Content-Range"))
+	return ok && start == p.size
+}
+
+// content returns the body of resp, a response continuing the partial file.
+// With verify the SHA-256 check covers the bytes already in the partial file.
+func (p partialDownload) content(resp *http.Response, etag string, verify bool) (io.ReadCloser, error) {
+	if !verify {
+		return resp.Body, nil
+	}
+
+	v := newVerifyingReader(resp.Body, etag)
+	f, err := os.Open(p.path())
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = f.Close() }()
+	if _, err := io.Copy(v.hash, f); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// create starts a new partial file for resp and records its ETag. A response
+// decoded from a stored Content-Encoding is not recorded, since ranges
+// address the stored bytes; interrupting it restarts the download.
+func (p partialDownload) create(resp *http.Response, raw bool) (*os.File, error) {
+	if err := removeIfExists(p.etagPath()); err != nil {
+		return nil, err
+	}
+	f, err := os.Create(p.path()) //#nosec G304 -- localPath is user-provided input
+	if err != nil {
+		return nil, err
+	}
+
+	etag := responseETag(resp)
+	if etag != "" && (storedEncoding(resp) == "" || raw) {
+		if err := os.WriteFile(p.etagPath(), []byte(etag+"\n"), 0o600); err != nil {
+			_ = f.Close()
+			return nil, err
+		}
+	}
+	return f, nil
+}
+
+// openAppend opens the partial file to continue writing it.
+func (p partialDownload) openAppend() (*os.File, error) {
+	return os.OpenFile(p.path(), os.O_WRONLY|os.O_APPEND, 0) //#nosec G304 -- localPath is user-provided input
+}
+
+// finish moves the complete partial file to the local path.
+func (p partialDownload) finish() error {
+	if err := os.Rename(p.path(), p.localPath); err != nil {
+		return err
+	}
+	return removeIfExists(p.etagPath())
+}
+
+// remove deletes the partial file and its ETag.
+func (p partialDownload) remove() error {
+	return errors.Join(removeIfExists(p.path()), removeIfExists(p.etagPath()))
+}
+
+func removeIfExists(path string) error {
+	if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// contentRangeStart returns the first byte position of a Content-Range
+// header such as "bytes 100-199/200".
+func contentRangeStart(header string) (int64, bool) {
+	spec, ok := strings.CutPrefix(header, "bytes ")
+	if !ok {
+		return 0, false
+	}
+	first, _, ok := strings.Cut(spec, "-")
+	if !ok {
+		return 0, false
+	}
+	start, err := strconv.ParseInt(first, 10, 64)
+	return start, err == nil
+}
@@ -0,0 +1,229 @@
+package clientcli_test
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sagarc03/stowry/clientcli"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// resumeServer serves one object with Range and If-Range support, and can
+// abort a response after a number of bytes to interrupt a download.
+type resumeServer struct {
+	mu        sync.Mutex
+	content   []byte
+	abortAt   int  // abort full responses after this many bytes; 0 = never
+	noRanges  bool // ignore Range, like a server without range support
+	ranges    []string
+	ifRanges  []string
+	responses []int
+}
+
+func (s *resumeServer) set(content string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.content = []byte(content)
+}
+
+func (s *resumeServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	content, abortAt, noRanges := s.content, s.abortAt, s.noRanges
+	s.ranges = append(s.ranges, r.Header.Get("Range"))
+	s.ifRanges = append(s.ifRanges, r.Header.Get("If-Range"))
+	s.mu.Unlock()
+
+	sum := sha256.Sum256(content)
+	w.Header().Set("ETag", `"`+hex.EncodeToString(sum[:])+`"`)
+	w.Header().Set("Content-Type", "application/octet-stream")
+
+	if noRanges {
+		r.Header.Del("Range")
+	}
+	rec := &statusRecorder{ResponseWriter: w, abortAt: abortAt}
+	http.ServeContent(rec, r, "", time.Time{}, bytes.NewReader(content))
+
+	s.mu.Lock()
+	s.responses = append(s.responses, rec.status)
+	s.mu.Unlock()
+}
+
+type statusRecorder struct {
+	http.ResponseWriter
+	status  int
+	abortAt int
+	written int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *statusRecorder) Write(p []byte) (int, error) {
+	if r.abortAt > 0 && r.status == http.StatusOK && r.written+len(p) > r.abortAt {
+		_, _ = r.ResponseWriter.Write(p[:r.abortAt-r.written])
+		r.ResponseWriter.(http.Flusher).Flush()
+		panic(http.ErrAbortHandler)
+	}
+	r.written += len(p)
+	return r.ResponseWriter.Write(p)
+}
+
+func etagOf(content string) string {
+	sum := sha256.Sum256([]byte(content))
+	return hex.EncodeToString(sum[:])
+}
+
+func TestClient_Download_Resume(t *testing.T) {
+	const v1 = "0123456789abcdefghijklmnopqrstuvwxyz"
+	const v2 = "the object was replaced with this new version"
+
+	setup := func(t *testing.T, verify bool) (*resumeServer, *clientcli.Client, string) {
+		t.Helper()
+		rs := &resumeServer{content: []byte(v1)}
+		server := httptest.NewServer(withoutCapabilities(rs))
+		t.Cleanup(server.Close)
+
+		client, err := clientcli.New(&clientcli.Config{
+			Endpoint:        server.URL,
+			AccessKey:       "test-key",
+			SecretKey:       "test-secret",
+			VerifyDownloads: verify,
+		})
+		require.NoError(t, err)
+		return rs, client, filepath.Join(t.TempDir(), "file.bin")
+	}
+	download := func(client *clientcli.Client, localPath string) (*clientcli.DownloadResult, error) {
+		result, _, err := client.Download(context.Background(), clientcli.DownloadOptions{
+			RemotePath: "file.bin",
+			LocalPath:  localPath,
+			Resume:     true,
+		})
+		return result, err
+	}
+	writePartial := func(t *testing.T, localPath, content, etag string) {
+		t.Helper()
+		require.NoError(t, os.WriteFile(localPath+clientcli.PartialSuffix, []byte(content), 0o600))
+		require.NoError(t, os.WriteFile(localPath+clientcli.PartialETagSuffix, []byte(etag+"\n"), 0o600))
+	}
+	assertComplete := func(t *testing.T, localPath, want string) {
+		t.Helper()
+		got, err := os.ReadFile(localPath)
+		require.NoError(t, err)
+		assert.Equal(t, want, string(got))
+		assert.NoFileExists(t, localPath+clientcli.PartialSuffix)
+		assert.NoFileExists(t, localPath+clientcli.PartialETagSuffix)
+	}
+
+	t.Run("fresh download", func(t *testing.T) {
+		rs, client, localPath := setup(t, true)
+
+		result, err := download(client, localPath)
+		require.NoError(t, err)
+		assert.Zero(t, result.ResumedFrom)
+		assert.Equal(t, int64(len(v1)), result.Size)
+		assertComplete(t, localPath, v1)
+		assert.Equal(t, []string{""}, rs.ranges)
+	})
+
+	t.Run("interrupted download resumes", func(t *testing.T) {
+		rs, client, localPath := setup(t, true)
+		rs.abortAt = 10
+
+		_, err := download(client, localPath)
+		require.Error(t, err)
+		partial, err := os.ReadFile(localPath + clientcli.PartialSuffix)
+		require.NoError(t, err)
+		assert.Equal(t, v1[:10], string(partial))
+		assert.NoFileExists(t, localPath)
+
+		rs.abortAt = 0
+		result, err := download(client, localPath)
+		require.NoError(t, err)
+		assert.Equal(t, int64(10), result.ResumedFrom)
+		assert.Equal(t, int64(len(v1)), result.Size)
+		assertComplete(t, localPath, v1)
+		assert.Equal(t, "bytes=10-", rs.ranges[1])
+		assert.Equal(t, `"`+etagOf(v1)+`"`, rs.ifRanges[1])
+		assert.Equal(t, []int{http.StatusPartialContent}, rs.responses, "the aborted response is not recorded")
+	})
+
+	t.Run("replaced object restarts", func(t *testing.T) {
+		rs, client, localPath := setup(t, true)
+		writePartial(t, localPath, v1[:10], etagOf(v1))
+		rs.set(v2)
+
+		result, err := download(client, localPath)
+		require.NoError(t, err)
+		assert.Zero(t, result.ResumedFrom)
+		assertComplete(t, localPath, v2)
+		assert.Equal(t, []int{http.StatusOK}, rs.responses)
+	})
+
+	t.Run("server without ranges restarts", func(t *testing.T) {
+		rs, client, localPath := setup(t, true)
+		writePartial(t, localPath, v1[:10], etagOf(v1))
+		rs.noRanges = true
+
+		result, err := download(client, localPath)
+		require.NoError(t, err)
+		assert.Zero(t, result.ResumedFrom)
+		assert.Equal(t, int64(len(v1)), result.Size)
+		assertComplete(t, localPath, v1)
+		assert.Equal(t, "bytes=10-", rs.ranges[0])
+		assert.Equal(t, []int{http.StatusOK}, rs.responses, "the full body replaces the partial file")
+	})
+
+	t.Run("complete partial file", func(t *testing.T) {
+		rs, client, localPath := setup(t, false)
+		writePartial(t, localPath, v1, etagOf(v1))
+
+		_, err := download(client, localPath)
+		require.NoError(t, err)
+		assertComplete(t, localPath, v1)
+		assert.Equal(t, []int{http.StatusRequestedRangeNotSatisfiable, http.StatusOK}, rs.responses)
+	})
+
+	t.Run("partial file without etag starts over", func(t *testing.T) {
+		rs, client, localPath := setup(t, false)
+		require.NoError(t, os.WriteFile(localPath+clientcli.PartialSuffix, []byte("garbage"), 0o600))
+
+		_, err := download(client, localPath)
+		require.NoError(t, err)
+		assertComplete(t, localPath, v1)
+		assert.Equal(t, []string{""}, rs.ranges)
+	})
+
+	t.Run("verify covers the partial bytes", func(t *testing.T) {
+		_, client, localPath := setup(t, true)
+		writePartial(t, localPath, "XXXXXXXXXX", etagOf(v1))
+
+		_, err := download(client, localPath)
+		require.ErrorIs(t, err, clientcli.ErrChecksumMismatch)
+		assert.NoFileExists(t, localPath)
+		assert.NoFileExists(t, localPath+clientcli.PartialSuffix)
+		assert.NoFileExists(t, localPath+clientcli.PartialETagSuffix)
+	})
+
+	t.Run("stdout cannot resume", func(t *testing.T) {
+		_, client, _ := setup(t, false)
+
+		_, _, err := client.Download(context.Background(), clientcli.DownloadOptions{
+			RemotePath: "file.bin",
+			LocalPath:  "-",
+			Resume:     true,
+		})
+		assert.ErrorIs(t, err, clientcli.ErrResumeStdout)
+	})
+}
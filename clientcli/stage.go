@@ -0,0 +1,154 @@
+package clientcli
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// stageHeader names the stage a PUT uploads into.
+const stageHeader = "X-Stowry-Stage"
+
+// errStagingUnsupported makes an atomic upload fall back to a plain upload.
+var errStagingUnsupported = errors.New("staged uploads not supported")
+
+// serverStageCommit mirrors the response to a stage commit.
+type serverStageCommit struct {
+	ID      string           `json:"id"`
+	Objects []serverMetaData `json:"objects"`
+}
+
+// uploadAtomic uploads every file into a new stage and commits the stage only
+// when all of them succeeded; otherwise the stage is discarded and
+// ErrAtomicUploadFailed is returned with the per-file results. Servers
+// without staging get a plain upload.
+func (c *Client) uploadAtomic(ctx context.Context, opts UploadOptions, tmpl *pathTemplate, now time.Time) ([]UploadResult, error) {
+	if c.capabilities(ctx).rulesOut("stage") {
+		return c.upload(ctx, opts, tmpl, now)
+	}
+	stage, err := c.createStage(ctx)
+	if errors.Is(err, errStagingUnsupported) {
+		return c.upload(ctx, opts, tmpl, now)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("upload: %w", err)
+	}
+
+	opts.stage = stage
+	results, err := c.upload(ctx, opts, tmpl, now)
+	if err == nil && HasUploadErrors(results) {
+		err = ErrAtomicUploadFailed
+	}
+	if err != nil {
+		// The uploads' context may be cancelled already
+		discardCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		if discardErr := c.discardStage(discardCtx, stage); discardErr != nil {
+			return results, fmt.Errorf("upload: %w (discard stage %s: %w)", err, stage, discardErr)
+		}
+		return results, fmt.Errorf("upload: %w", err)
+	}
+
+	committed, err := c.commitStage(ctx, stage)
+	if err != nil {
+		return results, fmt.Errorf("upload: %w", err)
+	}
+
+	byPath := make(map[string]serverMetaData, len(committed))
+	for _, meta := range committed {
+		byPath[meta.Path] = meta
+	}
+	for i := range results {
+		meta, ok := byPath[results[i].RemotePath]
+		if !ok {
+			continue
+		}
+		results[i].ID = meta.ID
+		results[i].CreatedAt = meta.CreatedAt
+		results[i].UpdatedAt = meta.UpdatedAt
+		c.Purge(meta.Path)
+	}
+	return results, nil
+}
+
+// createStage opens a stage and returns its ID, or errStagingUnsupported
+// when the server does not offer staged uploads.
+func (c *Client) createStage(ctx context.Context) (string, error) {
+	body, status, err := c.stageRequest(ctx, http.MethodPost, "/stage")
+	if err != nil {
+		return "", fmt.Errorf("create stage: %w", err)
+	}
+
+	switch status {
+	case http.StatusCreated:
+	case http.StatusNotFound, http.StatusMethodNotAllowed, http.StatusNotImplemented:
+		return "", errStagingUnsupported
+	default:
+		return "", fmt.Errorf("create stage: %w", parseServerError(status, body))
+	}
+
+	var stage struct {
+		ID string `json:"id"`
+	}
+	if err := json.Unmarshal(body, &stage); err != nil {
+		return "", fmt.Errorf("create stage: parse response: %w", err)
+	}
+	if stage.ID == "" {
+		return "", fmt.Errorf("create stage: response has no stage id")
+	}
+	return stage.ID, nil
+}
+
+// commitStage commits a stage and returns the committed objects.
+func (c *Client) commitStage(ctx context.Context, stage string) ([]serverMetaData, error) {
+	body, status, err := c.stageRequest(ctx, http.MethodPost, "/stage/"+stage+"/commit")
+	if err != nil {
+		return nil, fmt.Errorf("commit stage %s: %w", stage, err)
+	}
+	if status != http.StatusOK {
+		return nil, fmt.Errorf("commit stage %s: %w", stage, parseServerError(status, body))
+	}
+
+	var commit serverStageCommit
+	if err := json.Unmarshal(body, &commit); err != nil {
+		return nil, fmt.Errorf("commit stage %s: parse response: %w", stage, err)
+	}
+	return commit.Objects, nil
+}
+
+// discardStage deletes a stage and everything uploaded into it.
+func (c *Client) discardStage(ctx context.Context, stage string) error {
+	body, status, err := c.stageRequest(ctx, http.MethodDelete, "/stage/"+stage)
+	if err != nil {
+		return err
+	}
+	if status != http.StatusNoContent {
+		return parseServerError(status, body)
+	}
+	return nil
+}
+
+// stageRequest sends a bodiless signed request and returns the response body
+// and status.
+func (c *Client) stageRequest(ctx context.Context, method, path string) ([]byte, int, error) {
+	req, err := http.NewRequestWithContext(ctx, method, c.presign(method, path, nil, DefaultExpires), http.NoBody)
+	if err != nil {
+		return nil, 0, fmt.Errorf("create request: %w", err)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, 0, fmt.Errorf("do request: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, 0, fmt.Errorf("read response: %w", err)
+	}
+	return body, resp.StatusCode, nil
+}
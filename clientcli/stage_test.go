@@ -0,0 +1,141 @@
+package clientcli_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/sagarc03/stowry/clientcli"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testStageID = "0b9a6c1e-5f1d-4c1b-8f0e-2d9f4a7c3b21"
+
+// stageServer fakes the staging endpoints. PUTs to a path in failPaths are
+// rejected.
+type stageServer struct {
+	mu        sync.Mutex
+	staged    []string
+	requests  []string
+	failPaths map[string]bool
+}
+
+func (s *stageServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.requests = append(s.requests, r.Method+" "+r.URL.Path)
+
+	switch {
+	case r.Method == http.MethodPost && r.URL.Path == "/stage":
+		w.WriteHeader(http.StatusCreated)
+		_ = json.NewEncoder(w).Encode(map[string]string{"id": testStageID})
+	case r.Method == http.MethodPost && r.URL.Path == "/stage/"+testStageID+"/commit":
+		objects := []map[string]any{}
+		for _, path := range s.staged {
+			objects = append(objects, map[string]any{"id": uuid.NewString(), "path": path, "etag": "etag"})
+		}
+		_ = json.NewEncoder(w).Encode(map[string]any{"id": testStageID, "objects": objects})
+	case r.Method == http.MethodDelete && r.URL.Path == "/stage/"+testStageID:
+		w.WriteHeader(http.StatusNoContent)
+	case r.Method == http.MethodPut:
+		if r.Header.Get("X-Stowry-Stage") != testStageID {
+			http.Error(w, "not staged", http.StatusBadRequest)
+			return
+		}
+		if s.failPaths[r.URL.Path] {
+			w.WriteHeader(http.StatusInsufficientStorage)
+			_ = json.NewEncoder(w).Encode(map[string]string{"error": "insufficient_storage", "message": "full"})
+			return
+		}
+		s.staged = append(s.staged, r.URL.Path)
+		_ = json.NewEncoder(w).Encode(map[string]any{"path": r.URL.Path, "etag": "etag", "file_size_bytes": r.ContentLength})
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func writeStageFiles(t *testing.T, names ...string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for _, name := range names {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(name), 0o600))
+	}
+	return dir
+}
+
+func TestClient_Upload_Atomic(t *testing.T) {
+	t.Run("commits after all files are staged", func(t *testing.T) {
+		fake := &stageServer{}
+		server := httptest.NewServer(withoutCapabilities(fake))
+		defer server.Close()
+
+		client, err := clientcli.New(&clientcli.Config{Endpoint: server.URL})
+		require.NoError(t, err)
+
+		dir := writeStageFiles(t, "a.txt", "b.txt")
+		results, err := client.Upload(context.Background(), clientcli.UploadOptions{
+			LocalPath: dir, RemotePath: "site", Recursive: true, Atomic: true,
+		})
+		require.NoError(t, err)
+		require.Len(t, results, 2)
+		for _, r := range results {
+			assert.NotEqual(t, uuid.Nil, r.ID, "IDs come from the commit")
+		}
+		assert.Equal(t, "POST /stage", fake.requests[0])
+		assert.Equal(t, "POST /stage/"+testStageID+"/commit", fake.requests[len(fake.requests)-1])
+	})
+
+	t.Run("discards the stage when a file fails", func(t *testing.T) {
+		fake := &stageServer{failPaths: map[string]bool{"/site/b.txt": true}}
+		server := httptest.NewServer(withoutCapabilities(fake))
+		defer server.Close()
+
+		client, err := clientcli.New(&clientcli.Config{Endpoint: server.URL})
+		require.NoError(t, err)
+
+		dir := writeStageFiles(t, "a.txt", "b.txt")
+		results, err := client.Upload(context.Background(), clientcli.UploadOptions{
+			LocalPath: dir, RemotePath: "site", Recursive: true, Atomic: true,
+		})
+		require.ErrorIs(t, err, clientcli.ErrAtomicUploadFailed)
+		assert.True(t, clientcli.HasUploadErrors(results))
+		assert.Equal(t, "DELETE /stage/"+testStageID, fake.requests[len(fake.requests)-1])
+		for _, req := range fake.requests {
+			assert.False(t, strings.HasSuffix(req, "/commit"), "nothing is committed")
+		}
+	})
+
+	t.Run("falls back to a plain upload without staging", func(t *testing.T) {
+		var methods []string
+		echo := uploadEchoHandler(t)
+		server := httptest.NewServer(withoutCapabilities(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			methods = append(methods, r.Method)
+			if r.Method == http.MethodPost {
+				w.WriteHeader(http.StatusMethodNotAllowed)
+				return
+			}
+			assert.Empty(t, r.Header.Get("X-Stowry-Stage"))
+			echo(w, r)
+		})))
+		defer server.Close()
+
+		client, err := clientcli.New(&clientcli.Config{Endpoint: server.URL})
+		require.NoError(t, err)
+
+		dir := writeStageFiles(t, "a.txt")
+		results, err := client.Upload(context.Background(), clientcli.UploadOptions{
+			LocalPath: filepath.Join(dir, "a.txt"), RemotePath: "a.txt", Atomic: true,
+		})
+		require.NoError(t, err)
+		require.Len(t, results, 1)
+		assert.Equal(t, []string{http.MethodPost, http.MethodPut}, methods)
+	})
+}
@@ -0,0 +1,99 @@
+package clientcli
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+)
+
+// MaxStatBatch is the most paths StatMany sends in one request, unless the
+// server's capabilities allow fewer; the server rejects more.
+const MaxStatBatch = 1000
+
+// serverStatResponse mirrors the response to POST /?stat.
+type serverStatResponse struct {
+	Objects map[string]*serverMetaData `json:"objects"`
+}
+
+// StatMany fetches the metadata of many objects with one request per
+// MaxStatBatch paths, rather than one Stat (HEAD) request per path. The result maps
+// each path, as given, to its object, or to nil when none exists. Returns
+// ErrStatUnsupported for servers without the bulk stat endpoint, without a
+// request when their capabilities say so.
+func (c *Client) StatMany(ctx context.Context, paths []string) (map[string]*ObjectInfo, error) {
+	caps := c.capabilities(ctx)
+	if caps.rulesOut("stat") {
+		return nil, fmt.Errorf("stat many: %w", ErrStatUnsupported)
+	}
+	batchSize := MaxStatBatch
+	if n := caps.Limits.MaxStatPaths; n > 0 && n < batchSize {
+		batchSize = n
+	}
+
+	result := make(map[string]*ObjectInfo, len(paths))
+	for batch := range slices.Chunk(paths, batchSize) {
+		if err := c.statBatch(ctx, batch, result); err != nil {
+			return nil, fmt.Errorf("stat many: %w", err)
+		}
+	}
+	return result, nil
+}
+
+func (c *Client) statBatch(ctx context.Context, paths []string, result map[string]*ObjectInfo) error {
+	// The server takes object keys, which have no leading slash
+	keys := make([]string, len(paths))
+	for i, p := range paths {
+		keys[i] = strings.TrimPrefix(normalizePath(p), "/")
+	}
+	payload, err := json.Marshal(keys)
+	if err != nil {
+		return fmt.Errorf("encode paths: %w", err)
+	}
+
+	presignURL := c.presign(http.MethodPost, "/", url.Values{"stat": {""}}, DefaultExpires)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, presignURL, bytes.NewReader(payload))
+	if err != nil {
+		return fmt.Errorf("create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("do request: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("read response: %w", err)
+	}
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound, http.StatusMethodNotAllowed, http.StatusNotImplemented:
+		return ErrStatUnsupported
+	default:
+		return parseServerError(resp.StatusCode, body)
+	}
+
+	var stat serverStatResponse
+	if err := json.Unmarshal(body, &stat); err != nil {
+		return fmt.Errorf("parse response: %w", err)
+	}
+
+	for i, p := range paths {
+		var info *ObjectInfo
+		if meta := stat.Objects[keys[i]]; meta != nil {
+			obj := meta.objectInfo()
+			info = &obj
+		}
+		result[p] = info
+	}
+	return nil
+}
@@ -0,0 +1,90 @@
+package clientcli_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/sagarc03/stowry/clientcli"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClient_StatMany(t *testing.T) {
+	t.Run("batches paths and reports missing as nil", func(t *testing.T) {
+		var requests atomic.Int32
+		server := httptest.NewServer(withoutCapabilities(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requests.Add(1)
+			if r.Method != http.MethodPost || r.URL.Path != "/" || !r.URL.Query().Has("stat") {
+				w.WriteHeader(http.StatusMethodNotAllowed)
+				return
+			}
+
+			var paths []string
+			if err := json.NewDecoder(r.Body).Decode(&paths); err != nil || len(paths) > clientcli.MaxStatBatch {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			objects := map[string]any{}
+			for _, p := range paths {
+				if p == "missing.txt" {
+					objects[p] = nil
+					continue
+				}
+				objects[p] = map[string]any{"path": p, "etag": "etag-" + p, "file_size_bytes": 4}
+			}
+			_ = json.NewEncoder(w).Encode(map[string]any{"objects": objects})
+		})))
+		defer server.Close()
+
+		client, err := clientcli.New(&clientcli.Config{Endpoint: server.URL})
+		require.NoError(t, err)
+
+		paths := []string{"/a.txt", "missing.txt"}
+		for i := range clientcli.MaxStatBatch {
+			paths = append(paths, fmt.Sprintf("dir/%d.txt", i))
+		}
+
+		result, err := client.StatMany(t.Context(), paths)
+		require.NoError(t, err)
+
+		assert.Equal(t, int32(2), requests.Load())
+		assert.Len(t, result, len(paths))
+		require.NotNil(t, result["/a.txt"])
+		assert.Equal(t, "a.txt", result["/a.txt"].Path)
+		assert.Equal(t, "etag-a.txt", result["/a.txt"].ETag)
+		assert.Equal(t, int64(4), result["/a.txt"].Size)
+		assert.Nil(t, result["missing.txt"])
+		require.NotNil(t, result["dir/999.txt"])
+	})
+
+	t.Run("old server is unsupported", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		}))
+		defer server.Close()
+
+		client, err := clientcli.New(&clientcli.Config{Endpoint: server.URL})
+		require.NoError(t, err)
+
+		_, err = client.StatMany(t.Context(), []string{"a.txt"})
+		assert.ErrorIs(t, err, clientcli.ErrStatUnsupported)
+	})
+
+	t.Run("server error", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			w.WriteHeader(http.StatusBadRequest)
+			_ = json.NewEncoder(w).Encode(map[string]string{"error": "too_many_paths", "message": "too many"})
+		}))
+		defer server.Close()
+
+		client, err := clientcli.New(&clientcli.Config{Endpoint: server.URL})
+		require.NoError(t, err)
+
+		_, err = client.StatMany(t.Context(), []string{"a.txt"})
+		assert.ErrorContains(t, err, "too many")
+	})
+}
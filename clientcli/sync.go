@@ -0,0 +1,281 @@
+package clientcli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/sync/errgroup"
+)
+
+// Reasons a file of a Sync is uploaded, in SyncItem.Reason.
+const (
+	SyncReasonNew     = "new"
+	SyncReasonChanged = "changed"
+)
+
+// SyncOptions configures a Sync call.
+type SyncOptions struct {
+	LocalDir     string
+	RemotePrefix string // empty syncs the whole server
+	// Delete removes the remote objects under RemotePrefix that have no
+	// local file, except those Filter leaves out.
+	Delete bool
+	// DryRun only plans the sync: the result lists what would be uploaded
+	// and deleted, and nothing is sent.
+	DryRun bool
+	// Filter selects the files by their path below LocalDir, and the remote
+	// objects by their path below RemotePrefix; see PathFilter. Objects it
+	// leaves out are neither uploaded nor deleted.
+	Filter      []FilterRule
+	Concurrency int // parallel uploads; 0 uses Config.UploadConcurrency
+}
+
+// SyncItem is a file or object of a Sync.
+type SyncItem struct {
+	LocalPath  string `json:"local_path,omitempty"`
+	RemotePath string `json:"remote_path"`
+	Size       int64  `json:"size_bytes"`
+	// Reason is why a file is uploaded: SyncReasonNew or SyncReasonChanged
+	Reason string `json:"reason,omitempty"`
+	Err    error  `json:"-"`
+}
+
+// SyncResult reports what a Sync did, or with SyncOptions.DryRun would do.
+// Each list keeps the order of the local walk, then of the remote listing.
+type SyncResult struct {
+	LocalDir     string     `json:"local_dir"`
+	RemotePrefix string     `json:"remote_prefix"`
+	DryRun       bool       `json:"dry_run"`
+	Uploaded     []SyncItem `json:"uploaded"`
+	Skipped      []SyncItem `json:"skipped"` // unchanged files
+	Deleted      []SyncItem `json:"deleted"`
+	Errored      []SyncItem `json:"-"`
+}
+
+// Sync makes the objects under opts.RemotePrefix match the files in
+// opts.LocalDir, one way. Files without an object at their path, or whose
+// size or SHA-256 differs from the object's size or ETag, are uploaded, up
+// to opts.Concurrency at once; unchanged files are skipped without being
+// read unless their sizes match. With opts.Delete, objects with no local
+// file are deleted afterwards, in bulk where the server allows it.
+//
+// Local paths map to remote paths through NormalizeLocalToRemotePath, so
+// Windows separators and a leading "./" do not matter. The prefix is a
+// directory: "site" syncs "site/index.html", never "site-old/index.html".
+// Objects stored with a Content-Encoding hash differently from their local
+// file and are always uploaded again.
+//
+// When the server has listing turned off, the objects at the paths of the
+// local files are fetched with StatMany instead. Objects without a local
+// file cannot be found that way, so opts.Delete then fails with
+// ErrListDisabled before anything is sent.
+//
+// Files that fail are reported in SyncResult.Errored without stopping the
+// others. It returns ErrSyncUnsupported when the server is not in store
+// mode.
+func (c *Client) Sync(ctx context.Context, opts SyncOptions) (*SyncResult, error) {
+	if opts.LocalDir == "" {
+		return nil, fmt.Errorf("sync: %w", ErrEmptyPath)
+	}
+	filter, err := NewPathFilter(opts.Filter)
+	if err != nil {
+		return nil, fmt.Errorf("sync: %w", err)
+	}
+	info, err := os.Stat(opts.LocalDir)
+	if err != nil {
+		return nil, fmt.Errorf("sync: %w", err)
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("sync: %s is not a directory", opts.LocalDir)
+	}
+	if caps := c.capabilities(ctx); !caps.Offline && caps.Mode != "store" {
+		return nil, fmt.Errorf("sync: %w", ErrSyncUnsupported)
+	}
+
+	prefix := NormalizeLocalToRemotePath(opts.RemotePrefix)
+	listPrefix := prefix
+	if listPrefix != "" {
+		listPrefix += "/"
+	}
+
+	listed, err := c.List(ctx, ListOptions{Prefix: listPrefix, All: true})
+	listDisabled := errors.Is(err, ErrListDisabled)
+	switch {
+	case listDisabled && opts.Delete:
+		return nil, fmt.Errorf("sync: deleting needs a remote listing: %w", err)
+	case err != nil && !listDisabled:
+		return nil, fmt.Errorf("sync: %w", err)
+	}
+
+	result := &SyncResult{
+		LocalDir:     opts.LocalDir,
+		RemotePrefix: prefix,
+		DryRun:       opts.DryRun,
+		Uploaded:     []SyncItem{},
+		Skipped:      []SyncItem{},
+		Deleted:      []SyncItem{},
+	}
+	local := make(map[string]bool)
+	var files []SyncItem
+
+	walkErr := filepath.WalkDir(opts.LocalDir, func(path string, d fs.DirEntry, fileErr error) error {
+		if fileErr != nil {
+			return fileErr
+		}
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return ctxErr
+		}
+
+		relPath, relErr := filepath.Rel(opts.LocalDir, path)
+		if relErr != nil {
+			return relErr
+		}
+		relPath = NormalizeLocalToRemotePath(relPath)
+
+		if d.IsDir() {
+			if path != opts.LocalDir && filter.SkipDir(relPath) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !filter.Match(relPath) {
+			return nil
+		}
+
+		remotePath := listPrefix + relPath
+		local[remotePath] = true
+		item := SyncItem{LocalPath: path, RemotePath: remotePath}
+
+		fileInfo, statErr := os.Stat(path)
+		if statErr != nil {
+			item.Err = statErr
+			result.Errored = append(result.Errored, item)
+			return nil
+		}
+		item.Size = fileInfo.Size()
+		files = append(files, item)
+		return nil
+	})
+	if walkErr != nil {
+		return nil, fmt.Errorf("sync: walk directory: %w", walkErr)
+	}
+
+	var remote map[string]*ObjectInfo
+	if listDisabled {
+		paths := make([]string, len(files))
+		for i := range files {
+			paths[i] = files[i].RemotePath
+		}
+		if remote, err = c.StatMany(ctx, paths); err != nil {
+			return nil, fmt.Errorf("sync: %w", err)
+		}
+	} else {
+		remote = make(map[string]*ObjectInfo, len(listed.Items))
+		for i := range listed.Items {
+			remote[listed.Items[i].Path] = &listed.Items[i]
+		}
+	}
+
+	var pending []SyncItem
+	for _, item := range files {
+		obj := remote[item.RemotePath]
+		switch {
+		case obj == nil:
+			item.Reason = SyncReasonNew
+		case obj.Size == item.Size && obj.ContentEncoding == "":
+			sum, hashErr := hashFile(item.LocalPath)
+			if hashErr != nil {
+				item.Err = hashErr
+				result.Errored = append(result.Errored, item)
+				continue
+			}
+			if sum == obj.ETag {
+				result.Skipped = append(result.Skipped, item)
+				continue
+			}
+			item.Reason = SyncReasonChanged
+		default:
+			item.Reason = SyncReasonChanged
+		}
+		pending = append(pending, item)
+	}
+
+	var stale []SyncItem
+	if opts.Delete {
+		for i := range listed.Items {
+			obj := &listed.Items[i]
+			if local[obj.Path] || !filter.Match(strings.TrimPrefix(obj.Path, listPrefix)) {
+				continue
+			}
+			stale = append(stale, SyncItem{RemotePath: obj.Path, Size: obj.Size})
+		}
+	}
+
+	if opts.DryRun {
+		result.Uploaded = append(result.Uploaded, pending...)
+		result.Deleted = append(result.Deleted, stale...)
+		return result, nil
+	}
+
+	c.syncUploads(ctx, opts, pending)
+	for _, item := range pending {
+		if item.Err != nil {
+			result.Errored = append(result.Errored, item)
+			continue
+		}
+		result.Uploaded = append(result.Uploaded, item)
+	}
+
+	if len(stale) > 0 {
+		paths := make([]string, len(stale))
+		for i := range stale {
+			paths[i] = stale[i].RemotePath
+		}
+		deleted, deleteErr := c.Delete(ctx, DeleteOptions{Paths: paths})
+		if deleteErr != nil {
+			return result, fmt.Errorf("sync: %w", deleteErr)
+		}
+		for i := range deleted {
+			item := stale[i]
+			// An object someone else deleted first is gone all the same
+			if deleted[i].Err != nil && !errors.Is(deleted[i].Err, ErrNotFound) {
+				item.Err = deleted[i].Err
+				result.Errored = append(result.Errored, item)
+				continue
+			}
+			result.Deleted = append(result.Deleted, item)
+		}
+	}
+
+	return result, nil
+}
+
+// syncUploads uploads items in place, running up to opts.Concurrency at
+// once, and records each failure in its item's Err.
+func (c *Client) syncUploads(ctx context.Context, opts SyncOptions, items []SyncItem) {
+	concurrency := opts.Concurrency
+	if concurrency <= 0 {
+		concurrency = max(c.config.UploadConcurrency, 1)
+	}
+
+	var g errgroup.Group
+	g.SetLimit(concurrency)
+	for i := range items {
+		item := &items[i]
+		g.Go(func() error {
+			uploaded, err := c.uploadSingle(ctx, UploadOptions{LocalPath: item.LocalPath, RemotePath: item.RemotePath})
+			if err != nil {
+				item.Err = err
+				return nil
+			}
+			item.Size = uploaded.Size
+			return nil
+		})
+	}
+	_ = g.Wait()
+}
@@ -0,0 +1,269 @@
+package clientcli_test
+
+import (
+	"encoding/json"
+	"io"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/sagarc03/stowry/clientcli"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// memServer is a store-mode server keeping objects in memory, recording the
+// writes it receives as "METHOD path". With listDisabled it answers listing
+// as a server with disable_list does, and serves bulk stat.
+type memServer struct {
+	mu           sync.Mutex
+	objects      map[string]string
+	writes       []string
+	listDisabled bool
+}
+
+func (s *memServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	path := strings.TrimPrefix(r.URL.Path, "/")
+	switch {
+	case r.Method == http.MethodGet && path == "" && s.listDisabled:
+		w.WriteHeader(http.StatusForbidden)
+		_, _ = io.WriteString(w, `{"error":"list_disabled","message":"listing is disabled on this server"}`)
+	case r.Method == http.MethodPost && r.URL.Query().Has("stat"):
+		var paths []string
+		_ = json.NewDecoder(r.Body).Decode(&paths)
+		objects := map[string]any{}
+		for _, p := range paths {
+			objects[p] = nil
+			if content, ok := s.objects[p]; ok {
+				objects[p] = map[string]any{"path": p, "etag": sha256Hex([]byte(content)), "file_size_bytes": len(content)}
+			}
+		}
+		_ = json.NewEncoder(w).Encode(map[string]any{"objects": objects})
+	case r.Method == http.MethodGet && path == "":
+		prefix := r.URL.Query().Get("prefix")
+		items := []map[string]any{}
+		for _, p := range slices.Sorted(maps.Keys(s.objects)) {
+			if strings.HasPrefix(p, prefix) {
+				items = append(items, map[string]any{"path": p, "etag": sha256Hex([]byte(s.objects[p])), "file_size_bytes": len(s.objects[p])})
+			}
+		}
+		_ = json.NewEncoder(w).Encode(map[string]any{"items": items})
+	case r.Method == http.MethodPut:
+		body, _ := io.ReadAll(r.Body)
+		s.objects[path] = string(body)
+		s.writes = append(s.writes, "PUT "+path)
+		_ = json.NewEncoder(w).Encode(map[string]any{"path": path, "etag": sha256Hex(body), "file_size_bytes": len(body)})
+	case r.Method == http.MethodDelete:
+		s.writes = append(s.writes, "DELETE "+path)
+		if _, ok := s.objects[path]; !ok {
+			http.NotFound(w, r)
+			return
+		}
+		delete(s.objects, path)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func (s *memServer) recorded() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return slices.Sorted(slices.Values(s.writes))
+}
+
+func newSyncClient(t *testing.T, objects map[string]string) (*clientcli.Client, *memServer) {
+	t.Helper()
+	fake := &memServer{objects: objects}
+	server := httptest.NewServer(withoutCapabilities(fake))
+	t.Cleanup(server.Close)
+	client, err := clientcli.New(&clientcli.Config{Endpoint: server.URL})
+	require.NoError(t, err)
+	return client, fake
+}
+
+// writeTree creates files under a new temporary directory.
+func writeTree(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o750))
+		require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	}
+	return dir
+}
+
+func remotePaths(items []clientcli.SyncItem) []string {
+	paths := make([]string, len(items))
+	for i := range items {
+		paths[i] = items[i].RemotePath
+	}
+	return paths
+}
+
+func TestClient_Sync(t *testing.T) {
+	local := map[string]string{
+		"index.html":     "<h1>new</h1>",
+		"app.js":         "same",
+		"css/site.css":   "body{}",
+		"app.js.map":     "{}",
+		"img/logo.png":   "png",
+		"img/banner.png": "png!",
+	}
+	remote := map[string]string{
+		"site/index.html":     "<h1>old</h1>",
+		"site/app.js":         "same",
+		"site/img/banner.png": "png?",
+		"site/old.html":       "gone",
+		"site/keep.js.map":    "{}",
+		"site-old/index.html": "other prefix",
+	}
+
+	t.Run("uploads new and changed files", func(t *testing.T) {
+		client, fake := newSyncClient(t, maps.Clone(remote))
+		dir := writeTree(t, local)
+
+		result, err := client.Sync(t.Context(), clientcli.SyncOptions{LocalDir: dir, RemotePrefix: "site", Concurrency: 3})
+		require.NoError(t, err)
+
+		assert.ElementsMatch(t, []string{"site/index.html", "site/css/site.css", "site/app.js.map", "site/img/logo.png", "site/img/banner.png"},
+			remotePaths(result.Uploaded))
+		assert.Equal(t, []string{"site/app.js"}, remotePaths(result.Skipped))
+		assert.Empty(t, result.Deleted)
+		assert.Empty(t, result.Errored)
+		for _, item := range result.Uploaded {
+			if item.RemotePath == "site/index.html" || item.RemotePath == "site/img/banner.png" {
+				assert.Equal(t, clientcli.SyncReasonChanged, item.Reason, item.RemotePath)
+			} else {
+				assert.Equal(t, clientcli.SyncReasonNew, item.Reason, item.RemotePath)
+			}
+		}
+		assert.Equal(t, "<h1>new</h1>", fake.objects["site/index.html"])
+		assert.Equal(t, "gone", fake.objects["site/old.html"], "kept without --delete")
+	})
+
+	t.Run("deletes remote objects without a local file", func(t *testing.T) {
+		client, fake := newSyncClient(t, maps.Clone(remote))
+		dir := writeTree(t, local)
+
+		result, err := client.Sync(t.Context(), clientcli.SyncOptions{
+			LocalDir: dir, RemotePrefix: "./site/", Delete: true,
+			Filter: []clientcli.FilterRule{{Pattern: "*.map", Exclude: true}},
+		})
+		require.NoError(t, err)
+
+		assert.Equal(t, []string{"site/old.html"}, remotePaths(result.Deleted))
+		assert.NotContains(t, remotePaths(result.Uploaded), "site/app.js.map")
+		assert.Contains(t, fake.objects, "site/keep.js.map", "excluded objects are not deleted")
+		assert.Contains(t, fake.objects, "site-old/index.html", "the prefix is a directory")
+		assert.NotContains(t, fake.objects, "site/old.html")
+	})
+
+	t.Run("dry run plans without writing", func(t *testing.T) {
+		client, fake := newSyncClient(t, maps.Clone(remote))
+		dir := writeTree(t, local)
+
+		result, err := client.Sync(t.Context(), clientcli.SyncOptions{LocalDir: dir, RemotePrefix: "site", Delete: true, DryRun: true})
+		require.NoError(t, err)
+
+		assert.True(t, result.DryRun)
+		assert.Len(t, result.Uploaded, 5)
+		assert.ElementsMatch(t, []string{"site/old.html", "site/keep.js.map"}, remotePaths(result.Deleted))
+		assert.Empty(t, fake.recorded())
+	})
+
+	t.Run("second run changes nothing", func(t *testing.T) {
+		client, fake := newSyncClient(t, maps.Clone(remote))
+		dir := writeTree(t, local)
+
+		_, err := client.Sync(t.Context(), clientcli.SyncOptions{LocalDir: dir, RemotePrefix: "site", Delete: true})
+		require.NoError(t, err)
+		writes := len(fake.recorded())
+
+		result, err := client.Sync(t.Context(), clientcli.SyncOptions{LocalDir: dir, RemotePrefix: "site", Delete: true})
+		require.NoError(t, err)
+		assert.Empty(t, result.Uploaded)
+		assert.Empty(t, result.Deleted)
+		assert.Len(t, result.Skipped, len(local))
+		assert.Len(t, fake.recorded(), writes)
+	})
+
+	t.Run("reports files that fail", func(t *testing.T) {
+		fake := &memServer{objects: map[string]string{}}
+		server := httptest.NewServer(withoutCapabilities(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == "/site/app.js" {
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+			fake.ServeHTTP(w, r)
+		})))
+		defer server.Close()
+		client, err := clientcli.New(&clientcli.Config{Endpoint: server.URL})
+		require.NoError(t, err)
+
+		result, err := client.Sync(t.Context(), clientcli.SyncOptions{LocalDir: writeTree(t, local), RemotePrefix: "site"})
+		require.NoError(t, err)
+		require.Len(t, result.Errored, 1)
+		assert.Equal(t, "site/app.js", result.Errored[0].RemotePath)
+		assert.Len(t, result.Uploaded, len(local)-1)
+	})
+
+	t.Run("stats the local paths when listing is disabled", func(t *testing.T) {
+		client, fake := newSyncClient(t, maps.Clone(remote))
+		fake.listDisabled = true
+		dir := writeTree(t, local)
+
+		result, err := client.Sync(t.Context(), clientcli.SyncOptions{LocalDir: dir, RemotePrefix: "site"})
+		require.NoError(t, err)
+
+		assert.ElementsMatch(t, []string{"site/index.html", "site/css/site.css", "site/app.js.map", "site/img/logo.png", "site/img/banner.png"},
+			remotePaths(result.Uploaded))
+		assert.Equal(t, []string{"site/app.js"}, remotePaths(result.Skipped))
+		assert.Empty(t, result.Errored)
+	})
+
+	t.Run("delete is refused when listing is disabled", func(t *testing.T) {
+		client, fake := newSyncClient(t, maps.Clone(remote))
+		fake.listDisabled = true
+
+		_, err := client.Sync(t.Context(), clientcli.SyncOptions{LocalDir: writeTree(t, local), RemotePrefix: "site", Delete: true})
+		require.ErrorIs(t, err, clientcli.ErrListDisabled)
+		assert.Empty(t, fake.recorded(), "nothing is sent")
+	})
+
+	t.Run("server not in store mode", func(t *testing.T) {
+		fake := &capabilityServer{document: strings.Replace(storeCapabilities, `"mode": "store"`, `"mode": "spa"`, 1)}
+		client := newCapabilityClient(t, fake)
+
+		_, err := client.Sync(t.Context(), clientcli.SyncOptions{LocalDir: t.TempDir(), RemotePrefix: "site"})
+		require.ErrorIs(t, err, clientcli.ErrSyncUnsupported)
+	})
+
+	t.Run("malformed exclude pattern", func(t *testing.T) {
+		client, err := clientcli.New(&clientcli.Config{Endpoint: "http://localhost"})
+		require.NoError(t, err)
+
+		_, err = client.Sync(t.Context(), clientcli.SyncOptions{
+			LocalDir: t.TempDir(), Filter: []clientcli.FilterRule{{Pattern: "[", Exclude: true}},
+		})
+		assert.ErrorIs(t, err, clientcli.ErrInvalidFilterPattern)
+	})
+
+	t.Run("local directory required", func(t *testing.T) {
+		client, err := clientcli.New(&clientcli.Config{Endpoint: "http://localhost"})
+		require.NoError(t, err)
+
+		_, err = client.Sync(t.Context(), clientcli.SyncOptions{RemotePrefix: "site"})
+		assert.ErrorIs(t, err, clientcli.ErrEmptyPath)
+	})
+}
@@ -0,0 +1,166 @@
+package clientcli
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Default layouts for {date} and {time} without an explicit layout.
+const (
+	DefaultTemplateDateLayout = "2006/01/02"
+	DefaultTemplateTimeLayout = "150405"
+)
+
+// supportedPlaceholders is listed in template errors.
+const supportedPlaceholders = "{date[:layout]}, {time[:layout]}, {sha256[:n]}, {filename}, {ext}, {relpath}"
+
+// pathTemplate is a parsed remote path template such as
+// "logs/{date:2006/01/02}/host-{sha256:8}{ext}".
+type pathTemplate struct {
+	parts    []templatePart
+	needHash bool
+}
+
+// templatePart is literal text, or a placeholder when name is set.
+type templatePart struct {
+	literal string
+	name    string
+	arg     string // layout for date/time
+	n       int    // hex characters for sha256
+}
+
+// templateVars holds the values placeholders expand to for one file.
+type templateVars struct {
+	now     time.Time
+	relPath string // slash-separated path below the upload root
+	sha256  string // hex; only computed when the template needs it
+}
+
+// parsePathTemplate parses s, rejecting unknown placeholders and malformed
+// arguments. Errors wrap ErrInvalidPathTemplate.
+func parsePathTemplate(s string) (*pathTemplate, error) {
+	t := &pathTemplate{}
+	rest := s
+	for rest != "" {
+		open := strings.IndexByte(rest, '{')
+		if open < 0 {
+			if strings.Contains(rest, "}") {
+				return nil, fmt.Errorf("%w: unmatched } in %q", ErrInvalidPathTemplate, s)
+			}
+			t.parts = append(t.parts, templatePart{literal: rest})
+			break
+		}
+		if strings.Contains(rest[:open], "}") {
+			return nil, fmt.Errorf("%w: unmatched } in %q", ErrInvalidPathTemplate, s)
+		}
+		if open > 0 {
+			t.parts = append(t.parts, templatePart{literal: rest[:open]})
+		}
+
+		end := strings.IndexByte(rest[open:], '}')
+		if end < 0 {
+			return nil, fmt.Errorf("%w: unclosed { in %q", ErrInvalidPathTemplate, s)
+		}
+		part, err := parsePlaceholder(rest[open+1 : open+end])
+		if err != nil {
+			return nil, err
+		}
+		if part.name == "sha256" {
+			t.needHash = true
+		}
+		t.parts = append(t.parts, part)
+		rest = rest[open+end+1:]
+	}
+	return t, nil
+}
+
+func parsePlaceholder(body string) (templatePart, error) {
+	name, arg, hasArg := strings.Cut(body, ":")
+	part := templatePart{name: name, arg: arg}
+
+	switch name {
+	case "date", "time":
+		switch {
+		case !hasArg && name == "date":
+			part.arg = DefaultTemplateDateLayout
+		case !hasArg:
+			part.arg = DefaultTemplateTimeLayout
+		case arg == "":
+			return templatePart{}, fmt.Errorf("%w: {%s} has an empty layout", ErrInvalidPathTemplate, body)
+		}
+	case "sha256":
+		part.n = sha256.Size * 2
+		if hasArg {
+			n, err := strconv.Atoi(arg)
+			if err != nil || n < 1 || n > sha256.Size*2 {
+				return templatePart{}, fmt.Errorf("%w: {%s} length must be 1 to 64", ErrInvalidPathTemplate, body)
+			}
+			part.n = n
+		}
+	case "filename", "ext", "relpath":
+		if hasArg {
+			return templatePart{}, fmt.Errorf("%w: {%s} takes no argument", ErrInvalidPathTemplate, body)
+		}
+	default:
+		return templatePart{}, fmt.Errorf("%w: unknown placeholder {%s}; supported: %s", ErrInvalidPathTemplate, body, supportedPlaceholders)
+	}
+	return part, nil
+}
+
+// expand renders the template for one file.
+func (t *pathTemplate) expand(v templateVars) string {
+	var sb strings.Builder
+	name := path.Base(v.relPath)
+	for _, p := range t.parts {
+		switch p.name {
+		case "":
+			sb.WriteString(p.literal)
+		case "date", "time":
+			sb.WriteString(v.now.Format(p.arg))
+		case "sha256":
+			sb.WriteString(v.sha256[:p.n])
+		case "filename":
+			sb.WriteString(name)
+		case "ext":
+			sb.WriteString(path.Ext(name))
+		case "relpath":
+			sb.WriteString(v.relPath)
+		}
+	}
+	return sb.String()
+}
+
+// remotePath expands the template for the file at localPath, hashing it first
+// when the template uses {sha256}.
+func (t *pathTemplate) remotePath(localPath, relPath string, now time.Time) (string, error) {
+	v := templateVars{now: now, relPath: relPath}
+	if t.needHash {
+		sum, err := hashFile(localPath)
+		if err != nil {
+			return "", err
+		}
+		v.sha256 = sum
+	}
+	return t.expand(v), nil
+}
+
+func hashFile(localPath string) (string, error) {
+	f, err := os.Open(localPath) //#nosec G304 -- localPath is user-provided input
+	if err != nil {
+		return "", fmt.Errorf("open file: %w", err)
+	}
+	defer func() { _ = f.Close() }()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", fmt.Errorf("hash file: %w", err)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
@@ -0,0 +1,133 @@
+package clientcli_test
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sagarc03/stowry/clientcli"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newEchoUploadServer accepts PUTs and records the uploaded paths.
+func newEchoUploadServer(t *testing.T) (*clientcli.Client, func() []string) {
+	t.Helper()
+	var (
+		mu    sync.Mutex
+		paths []string
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		paths = append(paths, strings.TrimPrefix(r.URL.Path, "/"))
+		mu.Unlock()
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]any{"path": strings.TrimPrefix(r.URL.Path, "/"), "etag": "e"})
+	}))
+	t.Cleanup(server.Close)
+
+	client, err := clientcli.New(&clientcli.Config{Endpoint: server.URL, AccessKey: "k", SecretKey: "s"})
+	require.NoError(t, err)
+
+	return client, func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		sorted := append([]string(nil), paths...)
+		sort.Strings(sorted)
+		return sorted
+	}
+}
+
+func TestClient_Upload_PathTemplate(t *testing.T) {
+	content := []byte("log line\n")
+	sum := sha256.Sum256(content)
+	hash := hex.EncodeToString(sum[:])
+
+	t.Run("single file", func(t *testing.T) {
+		client, uploaded := newEchoUploadServer(t)
+		localFile := filepath.Join(t.TempDir(), "app.log.gz")
+		require.NoError(t, os.WriteFile(localFile, content, 0o644))
+
+		today := time.Now().UTC().Format("2006/01/02")
+		results, err := client.Upload(context.Background(), clientcli.UploadOptions{
+			LocalPath:    localFile,
+			PathTemplate: "logs/{date:2006/01/02}/host-{sha256:8}{ext}",
+		})
+
+		require.NoError(t, err)
+		require.Len(t, results, 1)
+		want := "logs/" + today + "/host-" + hash[:8] + ".gz"
+		assert.Equal(t, want, results[0].RemotePath)
+		assert.Equal(t, []string{want}, uploaded())
+	})
+
+	t.Run("filename and full hash", func(t *testing.T) {
+		client, uploaded := newEchoUploadServer(t)
+		localFile := filepath.Join(t.TempDir(), "report.csv")
+		require.NoError(t, os.WriteFile(localFile, content, 0o644))
+
+		_, err := client.Upload(context.Background(), clientcli.UploadOptions{
+			LocalPath:    localFile,
+			PathTemplate: "by-hash/{sha256}/{filename}",
+		})
+
+		require.NoError(t, err)
+		assert.Equal(t, []string{"by-hash/" + hash + "/report.csv"}, uploaded())
+	})
+
+	t.Run("recursive with relpath", func(t *testing.T) {
+		client, uploaded := newEchoUploadServer(t)
+		dir := t.TempDir()
+		require.NoError(t, os.MkdirAll(filepath.Join(dir, "sub"), 0o755))
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "a.txt"), content, 0o644))
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "sub", "b.txt"), content, 0o644))
+
+		results, err := client.Upload(context.Background(), clientcli.UploadOptions{
+			LocalPath:    dir,
+			Recursive:    true,
+			Concurrency:  2,
+			PathTemplate: "backup/{relpath}.{sha256:4}",
+		})
+
+		require.NoError(t, err)
+		require.Len(t, results, 2)
+		assert.Equal(t, []string{"backup/a.txt." + hash[:4], "backup/sub/b.txt." + hash[:4]}, uploaded())
+	})
+
+	errorTests := []struct {
+		name     string
+		template string
+		wantErr  string
+	}{
+		{name: "unknown placeholder", template: "x/{host}", wantErr: "supported: {date[:layout]}"},
+		{name: "unclosed brace", template: "x/{date", wantErr: "unclosed {"},
+		{name: "unmatched brace", template: "x/date}", wantErr: "unmatched }"},
+		{name: "hash length too long", template: "{sha256:65}", wantErr: "1 to 64"},
+		{name: "hash length not a number", template: "{sha256:abc}", wantErr: "1 to 64"},
+		{name: "argument on filename", template: "{filename:x}", wantErr: "takes no argument"},
+		{name: "empty layout", template: "{date:}", wantErr: "empty layout"},
+	}
+	for _, tt := range errorTests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, uploaded := newEchoUploadServer(t)
+			localFile := filepath.Join(t.TempDir(), "f.txt")
+			require.NoError(t, os.WriteFile(localFile, content, 0o644))
+
+			_, err := client.Upload(context.Background(), clientcli.UploadOptions{LocalPath: localFile, PathTemplate: tt.template})
+
+			require.ErrorIs(t, err, clientcli.ErrInvalidPathTemplate)
+			assert.Contains(t, err.Error(), tt.wantErr)
+			assert.Empty(t, uploaded(), "nothing is uploaded for an invalid template")
+		})
+	}
+}
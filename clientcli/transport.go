@@ -0,0 +1,70 @@
+package clientcli
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"os"
+)
+
+// NewTransport returns the HTTP transport for cfg: a clone of
+// http.DefaultTransport, so requests go through the proxy named by
+// HTTP_PROXY or HTTPS_PROXY unless NO_PROXY matches the endpoint, with the
+// TLS settings of cfg applied.
+func NewTransport(cfg *Config) (*http.Transport, error) {
+	if cfg == nil {
+		return nil, ErrConfigRequired
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = http.ProxyFromEnvironment
+
+	if cfg.CACertFile == "" && cfg.ClientCertFile == "" && !cfg.InsecureSkipVerify {
+		return transport, nil
+	}
+
+	tlsConfig := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		InsecureSkipVerify: cfg.InsecureSkipVerify, //#nosec G402 -- opt-in per profile, warned about by the CLI
+	}
+
+	if cfg.CACertFile != "" {
+		pool, err := loadCACertPool(cfg.CACertFile)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	if cfg.ClientCertFile != "" {
+		cert, err := tls.LoadX509KeyPair(cfg.ClientCertFile, cfg.ClientKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("load client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	transport.TLSClientConfig = tlsConfig
+	return transport, nil
+}
+
+// loadCACertPool returns the system roots plus the PEM certificates in path.
+func loadCACertPool(path string) (*x509.CertPool, error) {
+	data, err := os.ReadFile(path) //#nosec G304 -- path is the user's configured CA bundle
+	if err != nil {
+		return nil, fmt.Errorf("read CA certificates: %w", err)
+	}
+
+	pool, err := x509.SystemCertPool()
+	if err != nil || pool == nil {
+		pool = x509.NewCertPool()
+	}
+	if !pool.AppendCertsFromPEM(data) {
+		return nil, fmt.Errorf("%w in %s", ErrNoCACertificates, path)
+	}
+	return pool, nil
+}
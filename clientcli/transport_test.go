@@ -0,0 +1,204 @@
+package clientcli_test
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"log"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/sagarc03/stowry/clientcli"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testCA is a throwaway certificate authority for TLS tests.
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	pem  []byte
+}
+
+func newTestCA(t *testing.T) *testCA {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "stowry test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+
+	return &testCA{cert: cert, key: key, pem: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})}
+}
+
+// issue returns a certificate signed by the CA for 127.0.0.1, usable by a
+// server or a client, and its PEM certificate and key.
+func (ca *testCA) issue(t *testing.T, serial int64) (tls.Certificate, []byte, []byte) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: "127.0.0.1"},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.cert, &key.PublicKey, ca.key)
+	require.NoError(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+	pair, err := tls.X509KeyPair(certPEM, keyPEM)
+	require.NoError(t, err)
+	return pair, certPEM, keyPEM
+}
+
+func writeTempFile(t *testing.T, name string, data []byte) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	require.NoError(t, os.WriteFile(path, data, 0o600))
+	return path
+}
+
+// newTLSServer starts an object server with a certificate from ca. With
+// clientCAs set, it requires a client certificate signed by them.
+func newTLSServer(t *testing.T, ca *testCA, clientCAs *x509.CertPool) *httptest.Server {
+	t.Helper()
+	serverCert, _, _ := ca.issue(t, 2)
+
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("ETag", `"v1"`)
+		w.Header().Set("Content-Length", "0")
+	}))
+	// Rejected handshakes are expected; keep them out of the test output
+	server.Config.ErrorLog = log.New(io.Discard, "", 0)
+	server.TLS = &tls.Config{Certificates: []tls.Certificate{serverCert}, MinVersion: tls.VersionTLS12}
+	if clientCAs != nil {
+		server.TLS.ClientCAs = clientCAs
+		server.TLS.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	server.StartTLS()
+	t.Cleanup(server.Close)
+	return server
+}
+
+func statWith(t *testing.T, cfg *clientcli.Config) error {
+	t.Helper()
+	client, err := clientcli.New(cfg)
+	require.NoError(t, err)
+	_, err = client.Stat(context.Background(), "file.txt")
+	return err
+}
+
+func TestClient_TLS(t *testing.T) {
+	ca := newTestCA(t)
+	server := newTLSServer(t, ca, nil)
+	caFile := writeTempFile(t, "ca.pem", ca.pem)
+
+	t.Run("unknown CA is rejected", func(t *testing.T) {
+		err := statWith(t, &clientcli.Config{Endpoint: server.URL})
+
+		var unknown x509.UnknownAuthorityError
+		assert.ErrorAs(t, err, &unknown)
+	})
+
+	t.Run("ca_cert_file trusts the CA", func(t *testing.T) {
+		err := statWith(t, &clientcli.Config{Endpoint: server.URL, CACertFile: caFile})
+		assert.NoError(t, err)
+	})
+
+	t.Run("insecure_skip_verify skips verification", func(t *testing.T) {
+		err := statWith(t, &clientcli.Config{Endpoint: server.URL, InsecureSkipVerify: true})
+		assert.NoError(t, err)
+	})
+
+	t.Run("ca_cert_file of another CA is rejected", func(t *testing.T) {
+		otherFile := writeTempFile(t, "other.pem", newTestCA(t).pem)
+
+		err := statWith(t, &clientcli.Config{Endpoint: server.URL, CACertFile: otherFile})
+		assert.Error(t, err)
+	})
+}
+
+func TestClient_MutualTLS(t *testing.T) {
+	ca := newTestCA(t)
+	clientCAs := x509.NewCertPool()
+	clientCAs.AddCert(ca.cert)
+	server := newTLSServer(t, ca, clientCAs)
+	caFile := writeTempFile(t, "ca.pem", ca.pem)
+
+	_, certPEM, keyPEM := ca.issue(t, 3)
+	certFile := writeTempFile(t, "client.pem", certPEM)
+	keyFile := writeTempFile(t, "client-key.pem", keyPEM)
+
+	err := statWith(t, &clientcli.Config{Endpoint: server.URL, CACertFile: caFile})
+	assert.Error(t, err, "server requires a client certificate")
+
+	err = statWith(t, &clientcli.Config{Endpoint: server.URL, CACertFile: caFile, ClientCertFile: certFile, ClientKeyFile: keyFile})
+	assert.NoError(t, err)
+}
+
+func TestNewTransport(t *testing.T) {
+	t.Run("uses the proxy environment", func(t *testing.T) {
+		transport, err := clientcli.NewTransport(&clientcli.Config{})
+		require.NoError(t, err)
+		assert.NotNil(t, transport.Proxy)
+	})
+
+	t.Run("keeps the proxy with TLS options", func(t *testing.T) {
+		transport, err := clientcli.NewTransport(&clientcli.Config{InsecureSkipVerify: true})
+		require.NoError(t, err)
+		assert.NotNil(t, transport.Proxy)
+		assert.True(t, transport.TLSClientConfig.InsecureSkipVerify)
+	})
+
+	t.Run("verifies by default", func(t *testing.T) {
+		transport, err := clientcli.NewTransport(&clientcli.Config{CACertFile: writeTempFile(t, "ca.pem", newTestCA(t).pem)})
+		require.NoError(t, err)
+		assert.False(t, transport.TLSClientConfig.InsecureSkipVerify)
+	})
+
+	t.Run("missing CA file", func(t *testing.T) {
+		_, err := clientcli.New(&clientcli.Config{CACertFile: filepath.Join(t.TempDir(), "missing.pem")})
+		assert.ErrorIs(t, err, os.ErrNotExist)
+	})
+
+	t.Run("CA file without certificates", func(t *testing.T) {
+		_, err := clientcli.NewTransport(&clientcli.Config{CACertFile: writeTempFile(t, "ca.pem", []byte("not a certificate"))})
+		assert.ErrorIs(t, err, clientcli.ErrNoCACertificates)
+	})
+
+	t.Run("client certificate without key", func(t *testing.T) {
+		_, err := clientcli.NewTransport(&clientcli.Config{ClientCertFile: "client.pem"})
+		assert.ErrorIs(t, err, clientcli.ErrClientCertIncomplete)
+	})
+}
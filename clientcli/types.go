@@ -4,6 +4,8 @@ import (
 	"time"
 
 	"github.com/google/uuid"
+
+	"github.com/sagarc03/stowry/progress"
 )
 
 // UploadOptions configures an upload operation.
@@ -11,7 +13,54 @@ type UploadOptions struct {
 	LocalPath   string
 	RemotePath  string
 	ContentType string // optional, auto-detect if empty
+	// ContentEncoding declares the files as already encoded, e.g. "gzip" for
+	// pre-compressed JSON. The server stores them as sent and decodes them
+	// only for clients that do not accept the encoding.
+	ContentEncoding string
+	// Filename is recorded on the server as the original filename of each
+	// file, which downloads are named after. Empty records the local file's
+	// base name.
+	Filename    string
 	Recursive   bool
+	Concurrency int // parallel uploads when Recursive; 0 uses Config.UploadConcurrency
+	// Delta sends only the blocks that differ from the stored object, falling
+	// back to a full upload when the server or object does not allow it.
+	Delta     bool
+	BlockSize int // delta block size in bytes; 0 uses the server default
+	// PathTemplate, when set, replaces RemotePath with a template expanded per
+	// file before upload, e.g. "logs/{date}/host-{sha256:8}{ext}". Supported
+	// placeholders: {date[:layout]} and {time[:layout]} (Go layouts, UTC),
+	// {sha256[:n]} (first n hex characters of the content hash), {filename},
+	// {ext} (with its dot) and {relpath} (path below LocalPath when
+	// Recursive, otherwise the file name).
+	PathTemplate string
+	// Atomic uploads every file into a server-side stage and commits them
+	// together, so either all files become visible or none do. Servers
+	// without staged uploads get a plain upload.
+	Atomic bool
+	// Filter selects the files of a Recursive upload by their path below
+	// LocalPath; see PathFilter. A malformed pattern fails the upload before
+	// anything is sent.
+	Filter []FilterRule
+	// Skipped, if set, is called during a Recursive upload with the path
+	// below LocalPath of each file Filter leaves out, and of each directory
+	// it leaves out whole, with a trailing slash.
+	Skipped func(relPath string)
+	// Progress, if set, receives an event as each file of a Recursive upload
+	// finishes. The total is known once the directory walk is complete.
+	Progress progress.Func
+	// Strict fails the files of a Recursive upload that are removed between
+	// the directory walk and their upload, and the walk when a directory is
+	// removed while it runs. By default such files are reported as Skipped
+	// and vanished directories are passed over.
+	Strict bool
+	// FailFast stops a Recursive upload at the first file that fails,
+	// cancelling the uploads still running, and returns ErrUploadAborted.
+	// By default every file is attempted.
+	FailFast bool
+
+	stage  string      // stage ID the files are uploaded into; set by Atomic
+	filter *PathFilter // compiled Filter
 }
 
 // UploadResult represents the result of uploading a single file.
@@ -20,17 +69,54 @@ type UploadResult struct {
 	RemotePath  string    `json:"remote_path"`
 	ID          uuid.UUID `json:"id"`
 	ContentType string    `json:"content_type"`
-	ETag        string    `json:"etag"`
-	Size        int64     `json:"size_bytes"`
-	CreatedAt   time.Time `json:"created_at"`
-	UpdatedAt   time.Time `json:"updated_at"`
-	Err         error     `json:"-"` // nil on success
+	// ContentEncoding is the coding the object is stored with; Size and ETag
+	// describe the encoded bytes.
+	ContentEncoding string    `json:"content_encoding,omitempty"`
+	Filename        string    `json:"filename,omitempty"` // Original filename recorded with the object
+	ETag            string    `json:"etag"`
+	Size            int64     `json:"size_bytes"`
+	CreatedAt       time.Time `json:"created_at"`
+	UpdatedAt       time.Time `json:"updated_at"`
+	BytesSent       int64     `json:"bytes_sent,omitempty"` // request body size of a delta upload; 0 for a full upload
+	// Region is the region of the server that acknowledged the upload, empty
+	// when it names none. Pass it to PinRegion to read the upload back from
+	// that region.
+	Region string `json:"region,omitempty"`
+	// Skipped is set for a file of a Recursive upload that was not sent,
+	// with SkipReason saying why; Err is nil.
+	Skipped    bool   `json:"skipped,omitempty"`
+	SkipReason string `json:"skip_reason,omitempty"`
+	Err        error  `json:"-"` // nil on success
 }
 
+// SkipReasonRemoved is the UploadResult.SkipReason of a file removed between
+// the directory walk and its upload.
+const SkipReasonRemoved = "removed during upload"
+
+// SkipReasonAborted is the UploadResult.SkipReason of a file a FailFast
+// upload did not send, or cancelled, after another file failed.
+const SkipReasonAborted = "aborted after an earlier failure"
+
 // DownloadOptions configures a download operation.
 type DownloadOptions struct {
 	RemotePath string
 	LocalPath  string // empty = derive from remote, "-" = stdout
+	// Verify checks the content against the SHA-256 ETag. Config.VerifyDownloads
+	// turns it on for every download.
+	Verify bool
+	// Deleted downloads the soft-deleted version of the object, which needs
+	// write access. The server answers 410 once it has been cleaned up.
+	Deleted bool
+	// Raw keeps an object stored with a Content-Encoding encoded instead of
+	// decoding it, so the content hashes to the ETag.
+	Raw bool
+	// Resume continues an interrupted download from its partial file, or
+	// keeps one if this download is interrupted. See Client.Download.
+	Resume bool
+	// Recursive downloads every object under the RemotePath prefix into the
+	// LocalPath directory with Client.DownloadAll. Store mode only.
+	Recursive   bool
+	Concurrency int // parallel downloads when Recursive; 0 uses DefaultDownloadConcurrency
 }
 
 // DownloadResult represents the result of downloading a file.
@@ -39,19 +125,47 @@ type DownloadResult struct {
 	LocalPath   string `json:"local_path"`
 	ETag        string `json:"etag"`
 	ContentType string `json:"content_type"`
-	Size        int64  `json:"size_bytes"`
+	// ContentEncoding is the coding the object is stored with. The content
+	// is still encoded only with DownloadOptions.Raw.
+	ContentEncoding string `json:"content_encoding,omitempty"`
+	Size            int64  `json:"size_bytes"`
+	Cached          bool   `json:"cached,omitempty"` // Fetch served the body from the response cache
+	// ResumedFrom is how many bytes of a resumed download were already in
+	// its partial file; 0 when it started from the beginning.
+	ResumedFrom int64 `json:"resumed_from,omitempty"`
+	// DeletedAt is when the object was deleted, for downloads with
+	// DownloadOptions.Deleted.
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
+	// Skipped is set for a file of a Recursive download that was not
+	// fetched, with SkipReason saying why; Err is nil.
+	Skipped    bool   `json:"skipped,omitempty"`
+	SkipReason string `json:"skip_reason,omitempty"`
+	Err        error  `json:"-"` // nil on success
 }
 
+// SkipReasonUnchanged is the DownloadResult.SkipReason of a local file that
+// already has the object's size and SHA-256.
+const SkipReasonUnchanged = "local file is up to date"
+
 // DeleteOptions configures a delete operation.
 type DeleteOptions struct {
 	Paths []string
+	// Purge removes each file's content and metadata at once instead of
+	// soft-deleting it, sending a DELETE ?purge for every path. The server
+	// requires write credentials for it even when writes are public.
+	Purge bool
 }
 
 // DeleteResult represents the result of deleting a single file.
 type DeleteResult struct {
 	Path    string `json:"path"`
 	Deleted bool   `json:"deleted"`
-	Err     error  `json:"-"` // nil on success
+	// Purged is set when the file was purged; ETag and Size then describe
+	// the object the server removed.
+	Purged bool   `json:"purged,omitempty"`
+	ETag   string `json:"etag,omitempty"`
+	Size   int64  `json:"size_bytes,omitempty"`
+	Err    error  `json:"-"` // nil on success
 }
 
 // ListOptions configures a list operation.
@@ -60,6 +174,16 @@ type ListOptions struct {
 	Limit  int
 	Cursor string
 	All    bool // auto-paginate through all results
+	// IncludeBackend asks the server for the storage backend of each object,
+	// reported in ObjectInfo.StorageBackend.
+	IncludeBackend bool
+	// Deleted lists the soft-deleted objects that can still be restored
+	// instead of the active ones, with ObjectInfo.DeletedAt set. It needs
+	// write credentials.
+	Deleted bool
+	// Progress, if set, receives an event as objects arrive with All and in
+	// ExportCSV.
+	Progress progress.Func
 }
 
 // ListResult contains paginated list results.
@@ -73,22 +197,58 @@ type ObjectInfo struct {
 	ID          uuid.UUID `json:"id"`
 	Path        string    `json:"path"`
 	ContentType string    `json:"content_type"`
-	ETag        string    `json:"etag"`
-	Size        int64     `json:"size_bytes"`
-	CreatedAt   time.Time `json:"created_at"`
-	UpdatedAt   time.Time `json:"updated_at"`
+	// ContentEncoding is the coding the object is stored with; Size and ETag
+	// describe the encoded bytes and DecodedSize, if known, the content.
+	ContentEncoding string `json:"content_encoding,omitempty"`
+	ETag            string `json:"etag"`
+	Size            int64  `json:"size_bytes"`
+	DecodedSize     *int64 `json:"decoded_size_bytes,omitempty"`
+	// Filename is the original filename the object was uploaded with, if any.
+	Filename string `json:"filename,omitempty"`
+	// StorageBackend is the storage backend holding the object, reported
+	// with ListOptions.IncludeBackend; empty for the default backend.
+	StorageBackend string    `json:"storage_backend,omitempty"`
+	CreatedAt      time.Time `json:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at"`
+	// DeletedAt is when the object was deleted, for lists with
+	// ListOptions.Deleted.
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
 }
 
 // serverMetaData mirrors the JSON response from the server.
 // Used for unmarshaling server responses.
 type serverMetaData struct {
-	ID            uuid.UUID `json:"id"`
-	Path          string    `json:"path"`
-	ContentType   string    `json:"content_type"`
-	ETag          string    `json:"etag"`
-	FileSizeBytes int64     `json:"file_size_bytes"`
-	CreatedAt     time.Time `json:"created_at"`
-	UpdatedAt     time.Time `json:"updated_at"`
+	ID               uuid.UUID  `json:"id"`
+	Path             string     `json:"path"`
+	ContentType      string     `json:"content_type"`
+	ContentEncoding  string     `json:"content_encoding,omitempty"`
+	ETag             string     `json:"etag"`
+	FileSizeBytes    int64      `json:"file_size_bytes"`
+	DecodedSizeBytes *int64     `json:"decoded_size_bytes,omitempty"`
+	Filename         string     `json:"filename,omitempty"`
+	StorageBackend   string     `json:"storage_backend,omitempty"`
+	CreatedAt        time.Time  `json:"created_at"`
+	UpdatedAt        time.Time  `json:"updated_at"`
+	DeletedAt        *time.Time `json:"deleted_at,omitempty"`
+
+	region string // X-Stowry-Region of the response, for uploads
+}
+
+func (m serverMetaData) objectInfo() ObjectInfo {
+	return ObjectInfo{
+		ID:              m.ID,
+		Path:            m.Path,
+		ContentType:     m.ContentType,
+		ContentEncoding: m.ContentEncoding,
+		ETag:            m.ETag,
+		Size:            m.FileSizeBytes,
+		DecodedSize:     m.DecodedSizeBytes,
+		Filename:        m.Filename,
+		StorageBackend:  m.StorageBackend,
+		CreatedAt:       m.CreatedAt,
+		UpdatedAt:       m.UpdatedAt,
+		DeletedAt:       m.DeletedAt,
+	}
 }
 
 // serverListResult mirrors the JSON response from the server for list operations.
@@ -0,0 +1,179 @@
+package clientcli
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// DefaultWatchTimeout is how long the server waits for a change before
+// answering an empty watch.
+const DefaultWatchTimeout = 25 * time.Second
+
+// watchResponseMargin keeps the server's wait below the HTTP client timeout.
+const watchResponseMargin = 5 * time.Second
+
+// WatchOptions configures a watch of the change feed.
+type WatchOptions struct {
+	Prefix  string
+	Since   int64         // Cursor to continue from. Negative starts at the current position.
+	Limit   int           // Maximum changes per response (server default: 100)
+	Timeout time.Duration // How long the server waits for a change (default: DefaultWatchTimeout). Negative does not wait.
+}
+
+// Change is one create or delete from the server's change feed.
+type Change struct {
+	Seq  int64     `json:"seq"`
+	Kind string    `json:"kind"` // "create" or "delete"
+	Path string    `json:"path"`
+	ETag string    `json:"etag"`
+	Size int64     `json:"size_bytes"`
+	At   time.Time `json:"at"`
+	// PreviousETag is the ETag a create overwrote; empty for new objects
+	// and for servers that do not record it.
+	PreviousETag string `json:"previous_etag,omitempty"`
+	// Actor is the access key or token subject that made the change; empty
+	// when the server did not record one.
+	Actor string `json:"actor,omitempty"`
+}
+
+// WatchResult is one response of the change feed.
+type WatchResult struct {
+	Changes []Change `json:"changes"`
+	Next    int64    `json:"next"` // Pass as WatchOptions.Since to continue
+}
+
+// serverChange mirrors one change in the server's JSON responses.
+type serverChange struct {
+	Seq           int64     `json:"seq"`
+	Kind          string    `json:"kind"`
+	Path          string    `json:"path"`
+	ETag          string    `json:"etag"`
+	FileSizeBytes int64     `json:"file_size_bytes"`
+	At            time.Time `json:"at"`
+	PreviousETag  string    `json:"previous_etag"`
+	Actor         string    `json:"actor"`
+}
+
+func (s serverChange) change() Change {
+	return Change{
+		Seq:          s.Seq,
+		Kind:         s.Kind,
+		Path:         s.Path,
+		ETag:         s.ETag,
+		Size:         s.FileSizeBytes,
+		At:           s.At,
+		PreviousETag: s.PreviousETag,
+		Actor:        s.Actor,
+	}
+}
+
+// serverChangeBatch mirrors the JSON response to GET /?watch.
+type serverChangeBatch struct {
+	Changes []serverChange `json:"changes"`
+	Next    *int64         `json:"next"`
+}
+
+// Watch returns the changes after opts.Since, waiting up to opts.Timeout for
+// one when there are none yet (store mode only). An empty result still
+// carries the cursor to continue from.
+//
+// It returns ErrCursorExpired when the server has pruned changes after the
+// cursor, ErrWatchUnsupported when the server has no change feed, and
+// ErrListDisabled when it has listing, and with it the feed, turned off.
+func (c *Client) Watch(ctx context.Context, opts WatchOptions) (*WatchResult, error) {
+	query := url.Values{}
+	query.Set("watch", "")
+	if opts.Prefix != "" {
+		query.Set("prefix", opts.Prefix)
+	}
+	if opts.Since >= 0 {
+		query.Set("since", strconv.FormatInt(opts.Since, 10))
+	}
+	if opts.Limit > 0 {
+		query.Set("limit", strconv.Itoa(opts.Limit))
+	}
+	query.Set("timeout", strconv.Itoa(int(c.watchTimeout(opts.Timeout).Seconds())))
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.presign(http.MethodGet, "/", query, DefaultExpires), http.NoBody)
+	if err != nil {
+		return nil, fmt.Errorf("create request: %w", err)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("do request: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("read response: %w", err)
+	}
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusGone:
+		return nil, ErrCursorExpired
+	case http.StatusNotImplemented:
+		return nil, ErrWatchUnsupported
+	default:
+		return nil, parseListError(resp.StatusCode, body)
+	}
+
+	var batch serverChangeBatch
+	if err := json.Unmarshal(body, &batch); err != nil {
+		return nil, fmt.Errorf("parse response: %w", err)
+	}
+	// Older servers ignore ?watch and answer with a list page
+	if batch.Next == nil {
+		return nil, ErrWatchUnsupported
+	}
+
+	result := &WatchResult{Changes: make([]Change, len(batch.Changes)), Next: *batch.Next}
+	for i, change := range batch.Changes {
+		result.Changes[i] = change.change()
+	}
+	return result, nil
+}
+
+// Follow calls fn for every change after opts.Since until ctx is cancelled
+// or fn returns an error, long-polling the server between batches.
+func (c *Client) Follow(ctx context.Context, opts WatchOptions, fn func(Change) error) error {
+	for {
+		result, err := c.Watch(ctx, opts)
+		if err != nil {
+			if ctxErr := ctx.Err(); ctxErr != nil {
+				return ctxErr
+			}
+			return err
+		}
+
+		for _, change := range result.Changes {
+			if err := fn(change); err != nil {
+				return err
+			}
+		}
+		opts.Since = result.Next
+	}
+}
+
+// watchTimeout returns how long the server should wait, kept below the HTTP
+// client timeout so an empty watch is answered before the client gives up.
+func (c *Client) watchTimeout(timeout time.Duration) time.Duration {
+	if timeout < 0 {
+		return 0
+	}
+	if timeout == 0 {
+		timeout = DefaultWatchTimeout
+	}
+	if limit := c.httpClient.Timeout; limit > 0 && timeout > limit-watchResponseMargin {
+		timeout = max(time.Second, limit-watchResponseMargin)
+	}
+	return timeout
+}
@@ -0,0 +1,122 @@
+package clientcli_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/sagarc03/stowry/clientcli"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClient_Watch(t *testing.T) {
+	t.Run("sends the cursor and parses changes", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			q := r.URL.Query()
+			assert.True(t, q.Has("watch"))
+			assert.Equal(t, "docs/", q.Get("prefix"))
+			assert.Equal(t, "7", q.Get("since"))
+			assert.Equal(t, "10", q.Get("timeout"))
+			_ = json.NewEncoder(w).Encode(map[string]any{
+				"changes": []map[string]any{{"seq": 8, "kind": "delete", "path": "docs/a.txt", "etag": "abc", "file_size_bytes": 3, "at": "2026-01-02T03:04:05Z"}},
+				"next":    8,
+			})
+		}))
+		defer server.Close()
+
+		client, err := clientcli.New(&clientcli.Config{Endpoint: server.URL})
+		require.NoError(t, err)
+
+		result, err := client.Watch(context.Background(), clientcli.WatchOptions{Prefix: "docs/", Since: 7, Timeout: 10 * time.Second})
+		require.NoError(t, err)
+		assert.Equal(t, int64(8), result.Next)
+		require.Len(t, result.Changes, 1)
+		assert.Equal(t, clientcli.Change{
+			Seq: 8, Kind: "delete", Path: "docs/a.txt", ETag: "abc", Size: 3,
+			At: time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC),
+		}, result.Changes[0])
+	})
+
+	t.Run("server wait stays below the client timeout", func(t *testing.T) {
+		var timeouts []string
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			timeouts = append(timeouts, r.URL.Query().Get("timeout"))
+			assert.False(t, r.URL.Query().Has("since"), "no cursor starts at the current position")
+			_, _ = w.Write([]byte(`{"changes":[],"next":3}`))
+		}))
+		defer server.Close()
+
+		client, err := clientcli.New(&clientcli.Config{Endpoint: server.URL, Timeout: 20 * time.Second})
+		require.NoError(t, err)
+
+		for _, timeout := range []time.Duration{0, time.Hour, -1} {
+			_, err = client.Watch(context.Background(), clientcli.WatchOptions{Since: -1, Timeout: timeout})
+			require.NoError(t, err)
+		}
+		assert.Equal(t, []string{"15", "15", "0"}, timeouts)
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		tests := []struct {
+			name    string
+			status  int
+			body    string
+			wantErr error
+		}{
+			{name: "expired cursor", status: http.StatusGone, body: `{"error":"cursor_expired"}`, wantErr: clientcli.ErrCursorExpired},
+			{name: "no change feed", status: http.StatusNotImplemented, body: `{"error":"not_supported"}`, wantErr: clientcli.ErrWatchUnsupported},
+			{name: "older server lists instead", status: http.StatusOK, body: `{"items":[]}`, wantErr: clientcli.ErrWatchUnsupported},
+			{name: "listing disabled", status: http.StatusForbidden, body: `{"error":"list_disabled"}`, wantErr: clientcli.ErrListDisabled},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					w.WriteHeader(tt.status)
+					_, _ = w.Write([]byte(tt.body))
+				}))
+				defer server.Close()
+
+				client, err := clientcli.New(&clientcli.Config{Endpoint: server.URL})
+				require.NoError(t, err)
+
+				_, err = client.Watch(context.Background(), clientcli.WatchOptions{})
+				assert.ErrorIs(t, err, tt.wantErr)
+			})
+		}
+	})
+}
+
+func TestClient_Follow(t *testing.T) {
+	var sinces []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		sinces = append(sinces, r.URL.Query().Get("since"))
+		switch len(sinces) {
+		case 1:
+			_, _ = w.Write([]byte(`{"changes":[],"next":4}`))
+		default:
+			_, _ = w.Write([]byte(`{"changes":[{"seq":5,"kind":"create","path":"a"},{"seq":6,"kind":"create","path":"b"}],"next":6}`))
+		}
+	}))
+	defer server.Close()
+
+	client, err := clientcli.New(&clientcli.Config{Endpoint: server.URL})
+	require.NoError(t, err)
+
+	errStop := errors.New("stop")
+	var seen []int64
+	err = client.Follow(context.Background(), clientcli.WatchOptions{Since: 2}, func(c clientcli.Change) error {
+		seen = append(seen, c.Seq)
+		if c.Seq == 6 {
+			return errStop
+		}
+		return nil
+	})
+	assert.ErrorIs(t, err, errStop)
+	assert.Equal(t, []int64{5, 6}, seen)
+	assert.Equal(t, []string{"2", "4"}, sinces, "continues from each response's cursor")
+}
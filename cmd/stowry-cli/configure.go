@@ -4,9 +4,11 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"maps"
 	"net/http"
 	"net/url"
 	"os"
+	"path/filepath"
 	"strings"
 	"time"
 
@@ -46,6 +48,14 @@ You will be prompted for:
   - Secret key
   - Whether to set as default
 
+For https endpoints you are also asked for a CA certificate file, a PEM
+bundle trusted in addition to the system roots.
+
+Profile options are set with flags: --default-prefix, --timeout,
+--upload-concurrency, --verify-downloads, --content-type ext=type and the
+TLS flags --ca-cert-file, --client-cert-file, --client-key-file and
+--insecure-skip-verify.
+
 The endpoint connection will be tested before saving (use --skip-test to skip).
 
 Use 'configure update' to modify an existing profile.`,
@@ -66,6 +76,15 @@ You will be prompted for:
   - Secret key
   - Whether to set as default
 
+For https endpoints you are also asked for a CA certificate file; an empty
+answer uses the system roots only.
+
+Profile options keep their current values unless changed with flags:
+--default-prefix, --timeout, --upload-concurrency, --verify-downloads,
+--content-type ext=type (an empty type removes the override) and the TLS
+flags --ca-cert-file, --client-cert-file, --client-key-file and
+--insecure-skip-verify.
+
 The endpoint connection will be tested before saving (use --skip-test to skip).
 
 Use 'configure add' to create a new profile.`,
@@ -102,6 +121,15 @@ Secrets are hidden by default; use --show-secrets to reveal them.`,
 var (
 	showSecrets bool
 	skipTest    bool
+
+	optUploadConcurrency int
+	optVerifyDownloads   bool
+	optContentTypes      map[string]string
+
+	optCACertFile         string
+	optClientCertFile     string
+	optClientKeyFile      string
+	optInsecureSkipVerify bool
 )
 
 func init() {
@@ -116,6 +144,130 @@ func init() {
 	configureListCmd.Flags().BoolVar(&showSecrets, "show-secrets", false, "show secret values")
 	configureAddCmd.Flags().BoolVar(&skipTest, "skip-test", false, "skip connection test")
 	configureUpdateCmd.Flags().BoolVar(&skipTest, "skip-test", false, "skip connection test")
+
+	// Profile options; --default-prefix and --timeout are the global flags
+	for _, cmd := range []*cobra.Command{configureAddCmd, configureUpdateCmd} {
+		cmd.Flags().IntVar(&optUploadConcurrency, "upload-concurrency", 0, "store default upload concurrency in the profile")
+		cmd.Flags().BoolVar(&optVerifyDownloads, "verify-downloads", false, "store verify_downloads in the profile")
+		cmd.Flags().StringToStringVar(&optContentTypes, "content-type", nil, "store a content type override, e.g. .wasm=application/wasm (empty type removes it)")
+		cmd.Flags().StringVar(&optCACertFile, "ca-cert-file", "", "store a PEM CA bundle trusted for the endpoint (empty removes it)")
+		cmd.Flags().StringVar(&optClientCertFile, "client-cert-file", "", "store a PEM client certificate for mutual TLS")
+		cmd.Flags().StringVar(&optClientKeyFile, "client-key-file", "", "store the PEM key of the client certificate")
+		cmd.Flags().BoolVar(&optInsecureSkipVerify, "insecure-skip-verify", false, "store insecure_skip_verify: do not verify the server certificate (testing only)")
+	}
+}
+
+// applyOptionFlags copies the option flags set on the command line into opts.
+// Unset flags leave opts untouched, so update keeps existing options.
+func applyOptionFlags(cmd *cobra.Command, opts *clientcli.ProfileOptions) error {
+	flags := cmd.Flags()
+	if flags.Changed("default-prefix") {
+		opts.DefaultPrefix = defaultPrefix
+	}
+	if flags.Changed("timeout") {
+		if timeout < 0 {
+			return errors.New("timeout must not be negative")
+		}
+		opts.Timeout = timeout
+	}
+	if flags.Changed("upload-concurrency") {
+		if optUploadConcurrency < 0 {
+			return errors.New("upload concurrency must not be negative")
+		}
+		opts.UploadConcurrency = optUploadConcurrency
+	}
+	if flags.Changed("verify-downloads") {
+		opts.VerifyDownloads = optVerifyDownloads
+	}
+	if flags.Changed("content-type") {
+		opts.ContentTypes = maps.Clone(opts.ContentTypes)
+		for ext, contentType := range optContentTypes {
+			if contentType == "" {
+				delete(opts.ContentTypes, ext)
+				continue
+			}
+			if opts.ContentTypes == nil {
+				opts.ContentTypes = make(map[string]string)
+			}
+			opts.ContentTypes[ext] = contentType
+		}
+		if len(opts.ContentTypes) == 0 {
+			opts.ContentTypes = nil
+		}
+	}
+	for _, f := range []struct {
+		flag  string
+		value string
+		dest  *string
+	}{
+		{"ca-cert-file", optCACertFile, &opts.CACertFile},
+		{"client-cert-file", optClientCertFile, &opts.ClientCertFile},
+		{"client-key-file", optClientKeyFile, &opts.ClientKeyFile},
+	} {
+		if !flags.Changed(f.flag) {
+			continue
+		}
+		path, err := absPath(f.value)
+		if err != nil {
+			return fmt.Errorf("--%s: %w", f.flag, err)
+		}
+		*f.dest = path
+	}
+	if flags.Changed("insecure-skip-verify") {
+		opts.InsecureSkipVerify = optInsecureSkipVerify
+	}
+	if (opts.ClientCertFile == "") != (opts.ClientKeyFile == "") {
+		return clientcli.ErrClientCertIncomplete
+	}
+	return nil
+}
+
+// absPath makes a TLS file path absolute, so the profile works from any
+// directory. An empty path stays empty.
+func absPath(path string) (string, error) {
+	if path == "" {
+		return "", nil
+	}
+	return filepath.Abs(path)
+}
+
+// promptTLSOptions asks for a CA certificate file for https endpoints, unless
+// --ca-cert-file was given, and warns when the profile skips verification.
+func promptTLSOptions(cmd *cobra.Command, endpointURL string, opts *clientcli.ProfileOptions) error {
+	if strings.HasPrefix(endpointURL, "https://") && !cmd.Flags().Changed("ca-cert-file") {
+		caPrompt := promptui.Prompt{
+			Label:    "CA Certificate File (empty for system roots)",
+			Default:  opts.CACertFile,
+			Validate: validateCACertFile,
+		}
+		caFile, err := caPrompt.Run()
+		if err != nil {
+			return err
+		}
+		if opts.CACertFile, err = absPath(caFile); err != nil {
+			return err
+		}
+	}
+
+	if opts.InsecureSkipVerify {
+		warnInsecureSkipVerify(os.Stderr, endpointURL)
+	}
+	return nil
+}
+
+// validateCACertFile checks that a CA certificate file, when given, exists.
+func validateCACertFile(input string) error {
+	if input == "" {
+		return nil
+	}
+	info, err := os.Stat(input)
+	if err != nil {
+		return err
+	}
+	if info.IsDir() {
+		return errors.New("CA certificate file is a directory")
+	}
+	return nil
 }
 
 func runConfigureList(_ *cobra.Command, _ []string) error {
@@ -153,10 +305,15 @@ func runConfigureList(_ *cobra.Command, _ []string) error {
 	return formatter.FormatProfileList(os.Stdout, cfg.Profiles, defaultName, showSecrets)
 }
 
-func runConfigureAdd(_ *cobra.Command, args []string) error {
+func runConfigureAdd(cmd *cobra.Command, args []string) error {
 	name := args[0]
 	configPath := getConfigPath()
 
+	var options clientcli.ProfileOptions
+	if err := applyOptionFlags(cmd, &options); err != nil {
+		return err
+	}
+
 	// Load existing config or create new
 	cfg, err := clientcli.LoadConfigFile(configPath)
 	if err != nil {
@@ -203,6 +360,10 @@ func runConfigureAdd(_ *cobra.Command, args []string) error {
 		return handlePromptError(err)
 	}
 
+	if err := promptTLSOptions(cmd, endpointURL, &options); err != nil {
+		return handlePromptError(err)
+	}
+
 	// Prompt for default
 	setAsDefault := false
 	if len(cfg.Profiles) == 0 {
@@ -220,7 +381,7 @@ func runConfigureAdd(_ *cobra.Command, args []string) error {
 	// Test connection (unless skipped)
 	if !skipTest {
 		fmt.Print("Testing connection... ")
-		if connErr := testServerConnection(endpointURL); connErr != nil {
+		if connErr := testServerConnection(endpointURL, options); connErr != nil {
 			fmt.Println("FAILED")
 			fmt.Printf("Warning: Could not connect to server: %v\n", connErr)
 
@@ -244,6 +405,7 @@ func runConfigureAdd(_ *cobra.Command, args []string) error {
 		AccessKey: accessKeyVal,
 		SecretKey: secretKeyVal,
 		Default:   setAsDefault,
+		Options:   options,
 	}
 
 	// If setting as default, clear default from others
@@ -356,9 +518,15 @@ func runConfigureShow(_ *cobra.Command, args []string) error {
 	return formatter.FormatProfileShow(os.Stdout, *p, isDefault, showSecrets)
 }
 
-// testServerConnection tests if the server is reachable.
-// It sends a GET request to the root path and considers any HTTP response as success.
-func testServerConnection(endpointURL string) error {
+// testServerConnection tests if the server is reachable with the TLS
+// settings of opts. It sends a GET request to the root path and considers any
+// HTTP response as success.
+func testServerConnection(endpointURL string, opts clientcli.ProfileOptions) error {
+	transport, err := clientcli.NewTransport(clientcli.ConfigFromProfile(&clientcli.Profile{Endpoint: endpointURL, Options: opts}))
+	if err != nil {
+		return err
+	}
+
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
@@ -368,7 +536,8 @@ func testServerConnection(endpointURL string) error {
 	}
 
 	client := &http.Client{
-		Timeout: 5 * time.Second,
+		Timeout:   5 * time.Second,
+		Transport: transport,
 	}
 
 	resp, err := client.Do(req)
@@ -421,7 +590,7 @@ func maskSecretForPrompt(secret string) string {
 	return secret[:4] + "..." + secret[len(secret)-4:]
 }
 
-func runConfigureUpdate(_ *cobra.Command, args []string) error {
+func runConfigureUpdate(cmd *cobra.Command, args []string) error {
 	name := args[0]
 	configPath := getConfigPath()
 
@@ -445,6 +614,11 @@ func runConfigureUpdate(_ *cobra.Command, args []string) error {
 		return err
 	}
 
+	options := existingProfile.Options
+	if err := applyOptionFlags(cmd, &options); err != nil {
+		return err
+	}
+
 	// Prompt for endpoint URL (show current value as default)
 	endpointPrompt := promptui.Prompt{
 		Label:    "Endpoint URL",
@@ -480,6 +654,10 @@ func runConfigureUpdate(_ *cobra.Command, args []string) error {
 		secretKeyVal = existingProfile.SecretKey
 	}
 
+	if err := promptTLSOptions(cmd, endpointURL, &options); err != nil {
+		return handlePromptError(err)
+	}
+
 	// Prompt for default (only if not already default)
 	setAsDefault := existingProfile.Default
 	if !existingProfile.Default {
@@ -495,7 +673,7 @@ func runConfigureUpdate(_ *cobra.Command, args []string) error {
 	// Test connection (unless skipped)
 	if !skipTest {
 		fmt.Print("Testing connection... ")
-		if connErr := testServerConnection(endpointURL); connErr != nil {
+		if connErr := testServerConnection(endpointURL, options); connErr != nil {
 			fmt.Println("FAILED")
 			fmt.Printf("Warning: Could not connect to server: %v\n", connErr)
 
@@ -519,6 +697,7 @@ func runConfigureUpdate(_ *cobra.Command, args []string) error {
 		AccessKey: accessKeyVal,
 		SecretKey: secretKeyVal,
 		Default:   setAsDefault,
+		Options:   options,
 	}
 
 	// If setting as default, clear default from others
@@ -2,35 +2,81 @@ package main
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"os"
+	"strings"
 
+	"github.com/manifoldco/promptui"
 	"github.com/sagarc03/stowry/clientcli"
 	"github.com/spf13/cobra"
 )
 
 var deleteCmd = &cobra.Command{
-	Use:   "delete <remote-path> [remote-path...]",
+	Use:   "delete <remote-path> [remote-path...] | --recursive <prefix>",
 	Short: "Delete files from the server",
 	Long: `Delete one or more files from the server.
 
 Works in all server modes (store, static, spa).
 
+Deleted files are kept on the server until its cleanup removes them. Use
+--purge to remove the content and metadata at once instead, for example when
+content must be taken down for legal reasons. Purging needs write
+credentials even on servers with public writes, and only works in "store"
+mode.
+
+Use --recursive to delete every file under a directory prefix in a single
+request; the server pages through the files itself. It asks for
+confirmation unless --yes is given, and --dry-run lists the files that would
+be deleted without deleting them. Recursive deletes need write credentials
+and only work in "store" mode.
+
 Examples:
   stowry-cli delete path/file.txt
   stowry-cli delete old/a.txt old/b.txt old/c.txt
-  stowry-cli delete -q temp/file.txt`,
+  stowry-cli delete -q temp/file.txt
+  stowry-cli delete --purge legal/takedown.pdf
+  stowry-cli delete --recursive --dry-run builds/2023/
+  stowry-cli delete --recursive --yes builds/2023/`,
 	Args: cobra.MinimumNArgs(1),
 	RunE: runDelete,
 }
 
+var (
+	deletePurge     bool
+	deleteRecursive bool
+	deleteDryRun    bool
+	deleteYes       bool
+)
+
+func init() {
+	deleteCmd.Flags().BoolVar(&deletePurge, "purge", false, "remove the content and metadata at once instead of soft-deleting")
+	deleteCmd.Flags().BoolVarP(&deleteRecursive, "recursive", "r", false, "delete every file under the given prefix")
+	deleteCmd.Flags().BoolVar(&deleteDryRun, "dry-run", false, "with --recursive, list the files that would be deleted")
+	deleteCmd.Flags().BoolVarP(&deleteYes, "yes", "y", false, "with --recursive, delete without asking for confirmation")
+}
+
 func runDelete(_ *cobra.Command, args []string) error {
-	client, err := getClient()
+	if deleteRecursive {
+		return runDeletePrefix(args)
+	}
+	if deleteDryRun || deleteYes {
+		return errors.New("--dry-run and --yes only apply with --recursive")
+	}
+
+	client, cfg, err := getClient(nil)
 	if err != nil {
 		return err
 	}
 
+	paths := make([]string, len(args))
+	for i, arg := range args {
+		paths[i] = cfg.RemotePath(arg)
+	}
+
 	opts := clientcli.DeleteOptions{
-		Paths: args,
+		Paths: paths,
+		Purge: deletePurge,
 	}
 
 	results, err := client.Delete(context.Background(), opts)
@@ -51,6 +97,60 @@ func runDelete(_ *cobra.Command, args []string) error {
 	return nil
 }
 
+// runDeletePrefix deletes every file under a prefix with one request.
+func runDeletePrefix(args []string) error {
+	if deletePurge {
+		return errors.New("--purge cannot be combined with --recursive")
+	}
+	if len(args) != 1 {
+		return errors.New("--recursive takes exactly one prefix")
+	}
+
+	client, cfg, err := getClient(nil)
+	if err != nil {
+		return err
+	}
+
+	// The prefix names a directory, so builds/2023 leaves builds/2023-old.zip
+	prefix := cfg.RemotePath(args[0])
+	if prefix == "" {
+		return errors.New("refusing to delete every file; give a non-empty prefix")
+	}
+	if !strings.HasSuffix(prefix, "/") {
+		prefix += "/"
+	}
+
+	if !deleteDryRun && !deleteYes {
+		prompt := promptui.Prompt{
+			Label:     fmt.Sprintf("Delete every file under '%s'", prefix),
+			IsConfirm: true,
+		}
+		if _, promptErr := prompt.Run(); promptErr != nil {
+			fmt.Println("Cancelled.")
+			return nil //nolint:nilerr // User cancelled, not an error
+		}
+	}
+
+	result, err := client.DeletePrefix(context.Background(), clientcli.DeletePrefixOptions{
+		Prefix: prefix,
+		DryRun: deleteDryRun,
+	})
+	if err != nil {
+		return handleError(os.Stderr, err)
+	}
+
+	formatter := getFormatter()
+	if err := formatter.FormatDeletePrefix(os.Stdout, result); err != nil {
+		return err
+	}
+
+	if len(result.Errors) > 0 {
+		return &exitError{code: 1}
+	}
+
+	return nil
+}
+
 // exitError is returned when we want to exit with a specific code
 // but don't want cobra to print an error message.
 type exitError struct {
@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"errors"
 	"io"
 	"os"
 	"path/filepath"
@@ -11,12 +12,19 @@ import (
 )
 
 var (
-	downloadOutput string
-	downloadStdout bool
+	downloadOutput  string
+	downloadStdout  bool
+	downloadVerify  bool
+	downloadDeleted bool
+	downloadRaw     bool
+	downloadResume  bool
+
+	downloadRecursive   bool
+	downloadConcurrency int
 )
 
 var downloadCmd = &cobra.Command{
-	Use:   "download <remote-path> [local-path]",
+	Use:   "download <remote-path|prefix> [local-path]",
 	Short: "Download a file from the server",
 	Long: `Download a file from the server.
 
@@ -25,11 +33,35 @@ The download behavior depends on the server mode:
   - static: Returns the file, tries path/index.html for directories, or 404
   - spa:    Returns the file, or falls back to /index.html for missing paths
 
+With --deleted, a store-mode server returns the soft-deleted version of the
+file instead. This needs a write key and fails with 410 once cleanup has
+removed the content.
+
+Files uploaded with a Content-Encoding such as gzip are decompressed as they
+are downloaded. --raw writes the stored, still compressed bytes instead,
+which are what the ETag hashes.
+
+With --resume the file is written to <local-path>.partial and renamed once
+complete. Running the same command again after an interruption continues
+where it stopped, unless the object was replaced in the meantime, in which
+case it downloads the new version from the beginning.
+
+With --recursive (store mode only) every file under the remote prefix is
+downloaded into the local directory, named after the prefix by default,
+keeping the directory structure below the prefix. Files whose local copy
+already has the same size and SHA-256 are skipped, so running the command
+again only fetches what changed.
+
 Examples:
   stowry-cli download path/file.txt
   stowry-cli download path/file.txt ./local-file.txt
   stowry-cli download --stdout config.json | jq .
-  stowry-cli download -o ./output.txt path/file.txt`,
+  stowry-cli download -o ./output.txt path/file.txt
+  stowry-cli download --deleted -o ./restored.txt path/file.txt
+  stowry-cli download --raw events.json ./events.json.gz
+  stowry-cli download --resume backups/db.tar ./db.tar
+  stowry-cli download --recursive builds/2023/ ./builds-2023
+  stowry-cli download -r --concurrency 8 assets/`,
 	Args: cobra.RangeArgs(1, 2),
 	RunE: runDownload,
 }
@@ -37,9 +69,15 @@ Examples:
 func init() {
 	downloadCmd.Flags().StringVarP(&downloadOutput, "output", "o", "", "output file path")
 	downloadCmd.Flags().BoolVar(&downloadStdout, "stdout", false, "write to stdout")
+	downloadCmd.Flags().BoolVar(&downloadVerify, "verify", false, "check content against the SHA-256 ETag (profile: options.verify_downloads)")
+	downloadCmd.Flags().BoolVar(&downloadDeleted, "deleted", false, "download the soft-deleted version of the file")
+	downloadCmd.Flags().BoolVar(&downloadRaw, "raw", false, "keep a file stored with a Content-Encoding encoded")
+	downloadCmd.Flags().BoolVar(&downloadResume, "resume", false, "continue an interrupted download from its .partial file")
+	downloadCmd.Flags().BoolVarP(&downloadRecursive, "recursive", "r", false, "download every file under the remote prefix")
+	downloadCmd.Flags().IntVar(&downloadConcurrency, "concurrency", clientcli.DefaultDownloadConcurrency, "parallel downloads with --recursive")
 }
 
-func runDownload(_ *cobra.Command, args []string) error {
+func runDownload(cmd *cobra.Command, args []string) error {
 	remotePath := args[0]
 
 	// Determine local path
@@ -51,22 +89,39 @@ func runDownload(_ *cobra.Command, args []string) error {
 		localPath = downloadOutput
 	}
 	if downloadStdout {
+		if downloadRecursive {
+			return errors.New("--stdout cannot be used with --recursive")
+		}
 		localPath = "-"
 	}
 
 	// If no local path specified, derive from remote
-	if localPath == "" {
+	if localPath == "" && !downloadRecursive {
 		localPath = filepath.Base(remotePath)
 	}
 
-	client, err := getClient()
+	client, cfg, err := getClient(func(cfg *clientcli.Config) {
+		// An explicit --verify=false overrides the profile option
+		if cmd.Flags().Changed("verify") {
+			cfg.VerifyDownloads = downloadVerify
+		}
+	})
 	if err != nil {
 		return err
 	}
 
 	opts := clientcli.DownloadOptions{
-		RemotePath: remotePath,
+		RemotePath: cfg.RemotePath(remotePath),
 		LocalPath:  localPath,
+		Deleted:    downloadDeleted,
+		Raw:        downloadRaw,
+		Resume:     downloadResume,
+	}
+
+	if downloadRecursive {
+		opts.Recursive = true
+		opts.Concurrency = downloadConcurrency
+		return runDownloadRecursive(client, opts)
 	}
 
 	result, reader, err := client.Download(context.Background(), opts)
@@ -93,3 +148,22 @@ func runDownload(_ *cobra.Command, args []string) error {
 	formatter := getFormatter()
 	return formatter.FormatDownload(os.Stdout, result)
 }
+
+// runDownloadRecursive downloads every file under a prefix.
+func runDownloadRecursive(client *clientcli.Client, opts clientcli.DownloadOptions) error {
+	results, err := client.DownloadAll(context.Background(), opts)
+	if err != nil {
+		return handleError(os.Stderr, err)
+	}
+
+	formatter := getFormatter()
+	if err := formatter.FormatDownloads(os.Stdout, results); err != nil {
+		return err
+	}
+
+	if clientcli.HasDownloadErrors(results) {
+		return &exitError{code: 1}
+	}
+
+	return nil
+}
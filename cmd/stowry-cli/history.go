@@ -0,0 +1,66 @@
+package main
+
+import (
+	"context"
+	"os"
+
+	"github.com/sagarc03/stowry/clientcli"
+	"github.com/spf13/cobra"
+)
+
+var historyAfter int64
+
+var historyCmd = &cobra.Command{
+	Use:   "history <path>",
+	Short: "Show the recorded changes of an object",
+	Long: `Show the creates, overwrites and deletes recorded for an object, oldest
+first, with when they happened and the access key that made them.
+
+History reaches back as far as the server's change retention
+(service.changes_retention). Writing to a path after it was deleted shows as
+a new create. Use --json for machine-readable output; pass the last "seq" to
+--after to print only later changes.
+
+NOTE: This command only works when the server is running in "store" mode
+and needs write credentials.
+
+Examples:
+  stowry-cli history images/logo.png
+  stowry-cli history images/logo.png --json
+  stowry-cli history images/logo.png --after 1042`,
+	Args: cobra.ExactArgs(1),
+	RunE: runHistory,
+}
+
+func init() {
+	historyCmd.Flags().Int64Var(&historyAfter, "after", 0, "show only changes after this seq")
+}
+
+func runHistory(_ *cobra.Command, args []string) error {
+	client, cfg, err := getClient(nil)
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	opts := clientcli.HistoryOptions{RemotePath: cfg.RemotePath(args[0]), After: historyAfter}
+
+	var result *clientcli.HistoryResult
+	for {
+		page, err := client.History(ctx, opts)
+		if err != nil {
+			return handleError(os.Stderr, err)
+		}
+		if result == nil {
+			result = page
+		} else {
+			result.Changes = append(result.Changes, page.Changes...)
+		}
+		if page.Next == 0 {
+			break
+		}
+		opts.After = page.Next
+	}
+
+	return getFormatter().FormatHistory(os.Stdout, result)
+}
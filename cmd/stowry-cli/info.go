@@ -0,0 +1,49 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/sagarc03/stowry/clientcli"
+	"github.com/spf13/cobra"
+)
+
+var infoCmd = &cobra.Command{
+	Use:   "info",
+	Short: "Show what the server supports",
+	Long: `Show the server's capability document: its version and mode, the optional
+features it has enabled, the list formats it serves and the limits it checks
+requests against.
+
+The other commands read the same document to decide, for example, whether
+--atomic can stage uploads or --delta can send only changed blocks. A server
+that predates the document is shown with the conservative defaults the
+commands assume for it.
+
+Examples:
+  stowry-cli info
+  stowry-cli info --json`,
+	Args: cobra.NoArgs,
+	RunE: runInfo,
+}
+
+func runInfo(_ *cobra.Command, _ []string) error {
+	client, cfg, err := getClient(nil)
+	if err != nil {
+		return err
+	}
+
+	caps, err := client.Capabilities(context.Background())
+	switch {
+	case errors.Is(err, clientcli.ErrCapabilitiesUnsupported):
+		if !quiet {
+			_, _ = fmt.Fprintln(os.Stderr, "The server does not report its capabilities; showing the defaults assumed for it")
+		}
+	case err != nil:
+		return handleError(os.Stderr, err)
+	}
+
+	return getFormatter().FormatCapabilities(os.Stdout, cfg.Endpoint, caps)
+}
@@ -2,6 +2,8 @@ package main
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"os"
 
 	"github.com/sagarc03/stowry/clientcli"
@@ -13,6 +15,10 @@ var (
 	listLimit  int
 	listAll    bool
 	listCursor string
+	listOutput string
+
+	listShowBackend bool
+	listDeleted     bool
 )
 
 var listCmd = &cobra.Command{
@@ -28,7 +34,21 @@ Examples:
   stowry-cli list images/
   stowry-cli list --prefix documents/ --limit 10
   stowry-cli list --all
-  stowry-cli list --cursor "eyJwYXRoIjoi..."`,
+  stowry-cli list --cursor "eyJwYXRoIjoi..."
+  stowry-cli list invoices/ --output csv > invoices.csv
+  stowry-cli list archive/ --show-backend
+  stowry-cli list --deleted docs/
+
+With --output csv, every object under the prefix is written as CSV. The
+server streams the export when it supports it; otherwise all pages are
+fetched and rendered locally.
+
+With --show-backend, each object's storage backend is listed: the prefix of
+the storage route it was written to, or (default).
+
+With --deleted, the deleted files that can still be restored with
+"stowry-cli restore" are listed instead, with the time each was deleted.
+This needs write credentials.`,
 	Args: cobra.MaximumNArgs(1),
 	RunE: runList,
 }
@@ -38,6 +58,9 @@ func init() {
 	listCmd.Flags().IntVarP(&listLimit, "limit", "l", 100, "max results per page (max: 1000)")
 	listCmd.Flags().BoolVar(&listAll, "all", false, "fetch all pages")
 	listCmd.Flags().StringVar(&listCursor, "cursor", "", "pagination cursor")
+	listCmd.Flags().StringVarP(&listOutput, "output", "o", "", "output format: csv (default: table, or JSON with --json)")
+	listCmd.Flags().BoolVar(&listShowBackend, "show-backend", false, "show the storage backend of each object")
+	listCmd.Flags().BoolVar(&listDeleted, "deleted", false, "list deleted objects that can still be restored")
 }
 
 func runList(_ *cobra.Command, args []string) error {
@@ -47,16 +70,34 @@ func runList(_ *cobra.Command, args []string) error {
 		prefix = args[0]
 	}
 
-	client, err := getClient()
+	if listOutput != "" && listOutput != "csv" {
+		return fmt.Errorf("unsupported output format %q (supported: csv)", listOutput)
+	}
+	if listDeleted && listOutput == "csv" {
+		return errors.New("--deleted cannot be combined with --output csv")
+	}
+
+	client, cfg, err := getClient(nil)
 	if err != nil {
 		return err
 	}
+	prefix = cfg.RemotePath(prefix)
+
+	if listOutput == "csv" {
+		return runListCSV(client, prefix)
+	}
 
 	opts := clientcli.ListOptions{
 		Prefix: prefix,
 		Limit:  listLimit,
 		Cursor: listCursor,
 		All:    listAll,
+
+		IncludeBackend: listShowBackend,
+		Deleted:        listDeleted,
+	}
+	if listAll {
+		opts.Progress = getProgress()
 	}
 
 	result, err := client.List(context.Background(), opts)
@@ -65,5 +106,30 @@ func runList(_ *cobra.Command, args []string) error {
 	}
 
 	formatter := getFormatter()
+	if human, ok := formatter.(*clientcli.HumanFormatter); ok {
+		human.ShowBackend = listShowBackend
+	}
 	return formatter.FormatList(os.Stdout, result)
 }
+
+// runListCSV writes every object under prefix to stdout as CSV, preferring
+// the server-side export and rendering locally for servers without it.
+func runListCSV(client *clientcli.Client, prefix string) error {
+	ctx := context.Background()
+	opts := clientcli.ListOptions{Prefix: prefix, Cursor: listCursor, Progress: getProgress()}
+
+	err := client.ExportCSV(ctx, opts, os.Stdout)
+	if errors.Is(err, clientcli.ErrCSVExportUnsupported) {
+		opts.Limit = 1000
+		opts.All = true
+		result, listErr := client.List(ctx, opts)
+		if listErr != nil {
+			return handleError(os.Stderr, listErr)
+		}
+		return clientcli.WriteListCSV(os.Stdout, result.Items)
+	}
+	if err != nil {
+		return handleError(os.Stderr, err)
+	}
+	return nil
+}
@@ -5,8 +5,10 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"time"
 
 	"github.com/sagarc03/stowry/clientcli"
+	"github.com/sagarc03/stowry/progress"
 	"github.com/spf13/cobra"
 )
 
@@ -20,6 +22,9 @@ var (
 	secretKey  string
 	jsonOutput bool
 	quiet      bool
+
+	defaultPrefix string
+	timeout       time.Duration
 )
 
 var rootCmd = &cobra.Command{
@@ -37,6 +42,7 @@ Commands work differently depending on server mode:
   - download: Works in all modes (behavior varies by mode)
   - delete:   Works in all modes (store, static, spa)
   - list:     Only works in store mode
+  - sync:     Only works in store mode
 
 Download behavior by server mode:
   - store:  Returns file or 404
@@ -50,13 +56,21 @@ func init() {
 	rootCmd.PersistentFlags().StringVarP(&endpoint, "endpoint", "e", "", "endpoint URL override (env: STOWRY_ENDPOINT)")
 	rootCmd.PersistentFlags().StringVarP(&accessKey, "access-key", "a", "", "access key override (env: STOWRY_ACCESS_KEY)")
 	rootCmd.PersistentFlags().StringVarP(&secretKey, "secret-key", "k", "", "secret key override (env: STOWRY_SECRET_KEY)")
+	rootCmd.PersistentFlags().StringVar(&defaultPrefix, "default-prefix", "", "remote prefix prepended to every remote path (profile: options.default_prefix)")
+	rootCmd.PersistentFlags().DurationVar(&timeout, "timeout", 0, "HTTP timeout, e.g. 2m (profile: options.timeout, default: 30s)")
 	rootCmd.PersistentFlags().BoolVar(&jsonOutput, "json", false, "output as JSON")
 	rootCmd.PersistentFlags().BoolVarP(&quiet, "quiet", "q", false, "suppress non-essential output")
 
 	rootCmd.AddCommand(uploadCmd)
 	rootCmd.AddCommand(downloadCmd)
+	rootCmd.AddCommand(syncCmd)
 	rootCmd.AddCommand(deleteCmd)
+	rootCmd.AddCommand(moveCmd)
+	rootCmd.AddCommand(restoreCmd)
 	rootCmd.AddCommand(listCmd)
+	rootCmd.AddCommand(watchCmd)
+	rootCmd.AddCommand(historyCmd)
+	rootCmd.AddCommand(infoCmd)
 	rootCmd.AddCommand(configureCmd)
 }
 
@@ -67,8 +81,9 @@ func main() {
 }
 
 // buildConfig resolves configuration from profiles, env vars, and flags.
+// Profile options (default prefix, timeout, ...) follow the same order.
 // Precedence (highest to lowest):
-// 1. CLI flags (--endpoint, --access-key, --secret-key)
+// 1. CLI flags (--endpoint, --access-key, --secret-key, --default-prefix, --timeout)
 // 2. Environment variables (STOWRY_ENDPOINT, STOWRY_ACCESS_KEY, STOWRY_SECRET_KEY)
 // 3. Selected profile (--profile or STOWRY_PROFILE)
 // 4. Default profile from config file
@@ -118,9 +133,11 @@ func buildConfig() (*clientcli.Config, error) {
 
 	// 3. Load from flags
 	flagCfg := &clientcli.Config{
-		Endpoint:  endpoint,
-		AccessKey: accessKey,
-		SecretKey: secretKey,
+		Endpoint:      endpoint,
+		AccessKey:     accessKey,
+		SecretKey:     secretKey,
+		DefaultPrefix: defaultPrefix,
+		Timeout:       timeout,
 	}
 	configs = append(configs, flagCfg)
 
@@ -133,14 +150,49 @@ func getFormatter() clientcli.Formatter {
 	return clientcli.NewFormatter(jsonOutput, quiet)
 }
 
-// getClient creates and returns a configured client.
-func getClient() (*clientcli.Client, error) {
+// getProgress returns how long-running commands report progress on stderr:
+// ndjson events with --json, a live status line on a terminal, and nothing
+// otherwise or with --quiet.
+func getProgress() progress.Func {
+	switch {
+	case quiet:
+		return nil
+	case jsonOutput:
+		return progress.JSON(os.Stderr)
+	case progress.IsTerminal(os.Stderr):
+		return progress.Terminal(os.Stderr)
+	}
+	return nil
+}
+
+// getClient creates a configured client. It also returns the resolved config
+// so commands can apply profile options such as the default prefix.
+// adjust, when non-nil, applies command flags that override profile options.
+func getClient(adjust func(*clientcli.Config)) (*clientcli.Client, *clientcli.Config, error) {
 	cfg, err := buildConfig()
 	if err != nil {
-		return nil, err
+		return nil, nil, err
+	}
+	if adjust != nil {
+		adjust(cfg)
 	}
 
-	return clientcli.New(cfg)
+	if cfg.InsecureSkipVerify {
+		warnInsecureSkipVerify(os.Stderr, cfg.Endpoint)
+	}
+
+	client, err := clientcli.New(cfg)
+	if err != nil {
+		return nil, nil, err
+	}
+	return client, cfg, nil
+}
+
+// warnInsecureSkipVerify warns that the server certificate of endpoint is not
+// verified. It is printed even with --quiet.
+func warnInsecureSkipVerify(w io.Writer, endpoint string) {
+	_, _ = fmt.Fprintf(w, "WARNING: TLS certificate verification is disabled for %s (insecure_skip_verify).\n", endpoint)
+	_, _ = fmt.Fprintln(w, "WARNING: Anyone on the network path can read and alter this traffic. Use ca_cert_file instead.")
 }
 
 // getConfigPath returns the config file path to use.
@@ -169,6 +221,8 @@ func handleError(w io.Writer, err error) error {
 	case errors.Is(err, clientcli.ErrUnauthorized):
 		_, _ = fmt.Fprintln(w, "Authentication failed: invalid or missing credentials")
 		_, _ = fmt.Fprintln(w, "Check your access key and secret key, or run 'stowry-cli configure add' to set up a profile")
+	case errors.Is(err, clientcli.ErrListDisabled):
+		_, _ = fmt.Fprintln(w, "Listing is disabled on this server: objects can still be read by path")
 	case errors.Is(err, clientcli.ErrForbidden):
 		_, _ = fmt.Fprintln(w, "Access denied: you don't have permission to perform this operation")
 	case errors.Is(err, clientcli.ErrNotFound):
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"context"
+	"os"
+
+	"github.com/spf13/cobra"
+)
+
+var moveCmd = &cobra.Command{
+	Use:   "move <src> <dst>",
+	Short: "Move or rename a file on the server",
+	Long: `Move a file to another path on the server, without downloading and
+uploading it again. The object keeps its ID and content; the old path no
+longer resolves once the move is done.
+
+The move fails if an object already exists at <dst>; delete it first to
+replace it.
+
+NOTE: This command only works when the server is running in "store" mode
+and needs write credentials.
+
+Examples:
+  stowry-cli move uploads/tmp/foo.jpg images/foo.jpg
+  stowry-cli move reports/draft.pdf reports/final.pdf --json`,
+	Args: cobra.ExactArgs(2),
+	RunE: runMove,
+}
+
+func runMove(_ *cobra.Command, args []string) error {
+	client, cfg, err := getClient(nil)
+	if err != nil {
+		return err
+	}
+
+	result, err := client.Move(context.Background(), cfg.RemotePath(args[0]), cfg.RemotePath(args[1]))
+	if err != nil {
+		return handleError(os.Stderr, err)
+	}
+
+	return getFormatter().FormatMove(os.Stdout, result)
+}
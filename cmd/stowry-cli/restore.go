@@ -0,0 +1,42 @@
+package main
+
+import (
+	"context"
+	"os"
+
+	"github.com/spf13/cobra"
+)
+
+var restoreCmd = &cobra.Command{
+	Use:   "restore <path>",
+	Short: "Restore a deleted file on the server",
+	Long: `Restore a file that was deleted, as long as the server has not cleaned it
+up yet. The object comes back with its ID and content.
+
+The restore fails if an object was uploaded to <path> since the delete. Use
+"stowry-cli list --deleted" to find the files that can still be restored.
+
+NOTE: This command only works when the server is running in "store" mode
+and needs write credentials.
+
+Examples:
+  stowry-cli restore docs/report.pdf
+  stowry-cli list --deleted docs/
+  stowry-cli restore docs/report.pdf --json`,
+	Args: cobra.ExactArgs(1),
+	RunE: runRestore,
+}
+
+func runRestore(_ *cobra.Command, args []string) error {
+	client, cfg, err := getClient(nil)
+	if err != nil {
+		return err
+	}
+
+	result, err := client.Restore(context.Background(), cfg.RemotePath(args[0]))
+	if err != nil {
+		return handleError(os.Stderr, err)
+	}
+
+	return getFormatter().FormatRestore(os.Stdout, result)
+}
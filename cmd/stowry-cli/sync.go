@@ -0,0 +1,77 @@
+package main
+
+import (
+	"context"
+	"os"
+
+	"github.com/sagarc03/stowry/clientcli"
+	"github.com/spf13/cobra"
+)
+
+var (
+	syncDelete      bool
+	syncDryRun      bool
+	syncConcurrency int
+	syncFilters     []clientcli.FilterRule
+)
+
+var syncCmd = &cobra.Command{
+	Use:   "sync <local-dir> <remote-prefix>",
+	Short: "Upload the changes of a local directory to a remote prefix",
+	Long: `Make the files under a remote prefix match a local directory, one way.
+
+Only store mode is supported, since the remote prefix is listed to find what
+changed. Files that are new, or whose size or SHA-256 differs from the remote
+object, are uploaded; unchanged files are skipped. With --delete, remote
+objects under the prefix that have no local file are deleted afterwards.
+
+--dry-run prints the plan without uploading or deleting anything.
+
+--exclude leaves out files by their path below the local directory, with
+.gitignore-style patterns. Remote objects matching an --exclude are never
+deleted.
+
+Examples:
+  stowry-cli sync ./dist site
+  stowry-cli sync --dry-run --delete ./dist site
+  stowry-cli sync --delete --exclude '*.map' --concurrency 8 ./dist site`,
+	Args: cobra.ExactArgs(2),
+	RunE: runSync,
+}
+
+func init() {
+	syncCmd.Flags().BoolVar(&syncDelete, "delete", false, "delete remote objects that have no local file")
+	syncCmd.Flags().BoolVar(&syncDryRun, "dry-run", false, "print the plan without changing anything")
+	syncCmd.Flags().IntVar(&syncConcurrency, "concurrency", 0, "parallel uploads (profile: options.upload_concurrency, default: 1)")
+	syncCmd.Flags().Var(&filterFlag{rules: &syncFilters, exclude: true}, "exclude", "skip files matching a pattern (repeatable)")
+}
+
+func runSync(_ *cobra.Command, args []string) error {
+	client, cfg, err := getClient(nil)
+	if err != nil {
+		return err
+	}
+
+	result, err := client.Sync(context.Background(), clientcli.SyncOptions{
+		LocalDir:     args[0],
+		RemotePrefix: cfg.RemotePath(clientcli.NormalizeLocalToRemotePath(args[1])),
+		Delete:       syncDelete,
+		DryRun:       syncDryRun,
+		Filter:       syncFilters,
+		Concurrency:  syncConcurrency,
+	})
+	if err != nil {
+		return handleError(os.Stderr, err)
+	}
+
+	formatter := getFormatter()
+	if err := formatter.FormatSync(os.Stdout, result); err != nil {
+		return err
+	}
+
+	if len(result.Errored) > 0 {
+		return &exitError{code: 1}
+	}
+
+	return nil
+}
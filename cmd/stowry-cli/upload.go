@@ -2,7 +2,11 @@ package main
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"os"
+	"os/signal"
+	"syscall"
 
 	"github.com/sagarc03/stowry/clientcli"
 	"github.com/spf13/cobra"
@@ -11,6 +15,17 @@ import (
 var (
 	uploadRecursive   bool
 	uploadContentType string
+	uploadEncoding    string
+	uploadConcurrency int
+	uploadDelta       bool
+	uploadBlockSize   int
+	uploadTemplate    string
+	uploadAtomic      bool
+	uploadFilename    string
+	uploadFilters     []clientcli.FilterRule
+	uploadVerbose     bool
+	uploadStrict      bool
+	uploadFailFast    bool
 )
 
 var uploadCmd = &cobra.Command{
@@ -30,7 +45,58 @@ Examples:
   stowry-cli upload ./images/photo.jpg
   stowry-cli upload -r ./images/
   stowry-cli upload ./file.txt custom/path.txt
-  stowry-cli upload -r ./local/images/ remote/media/`,
+  stowry-cli upload -r ./local/images/ remote/media/
+  stowry-cli upload --delta ./backup.db backups/app.db
+  stowry-cli upload --template 'logs/{date}/host-{sha256:8}{ext}' ./app.log.gz
+  stowry-cli upload -r --template 'snapshots/{date:2006-01-02}/{relpath}' ./out/
+  stowry-cli upload -r --atomic ./dist/ site/
+  stowry-cli upload --content-encoding gzip -t application/json ./events.json.gz events.json
+  stowry-cli upload --filename 'Q3 report.pdf' ./report.pdf uploads/3f2a9c1d.pdf
+
+With --delta, only the blocks that differ from the existing object are sent.
+Files that do not exist on the server yet, or servers without delta support,
+fall back to a full upload.
+
+With --atomic (store mode), the files are uploaded into a server-side stage
+and committed together: either every file becomes visible or, if any upload
+fails, none does. Servers without staged uploads get a plain upload.
+
+--content-encoding gzip uploads files that are already gzip-compressed. The
+server stores them as sent, checks that they decode, and serves them with
+Content-Encoding: gzip, decoding them only for clients that do not accept
+gzip. The ETag is the SHA-256 of the compressed bytes.
+
+--template names each uploaded file instead of remote-path. Placeholders:
+  {date[:layout]}  upload date in UTC, Go layout (default 2006/01/02)
+  {time[:layout]}  upload time in UTC, Go layout (default 150405)
+  {sha256[:n]}     first n hex characters of the content hash (default all 64)
+  {filename}       local file name, e.g. app.log.gz
+  {ext}            extension including the dot, e.g. .gz
+  {relpath}        path below the directory with -r, otherwise the file name
+The profile's default prefix is prepended as usual.
+
+Each file's local name is recorded as its original filename, which the
+server names downloads after. --filename records another name instead.
+
+--exclude and --include select the files of a recursive upload by their
+path below the directory, with .gitignore-style patterns. They are evaluated
+in the order given and the last match wins. Files no pattern matches are
+uploaded, unless the first pattern is an --include:
+  --exclude '*.map' --exclude .DS_Store   everything but source maps and .DS_Store
+  --include 'dist/**' --exclude '*.map'   only dist/, without its source maps
+A pattern without a slash matches a name at any depth, "**" matches any
+number of directories, and a trailing slash matches directories only.
+Skipped files are counted on stderr; --verbose lists them.
+
+Files removed between the directory walk and their upload, as when a CI
+workspace is cleaned concurrently, are reported as skipped rather than
+failed, and directories removed during the walk are passed over. --strict
+fails them instead.
+
+A recursive upload attempts every file and reports those that failed.
+--fail-fast stops at the first failure instead, cancelling the uploads
+still running. Interrupting the command also stops it, after listing the
+files uploaded so far.`,
 	Args: cobra.RangeArgs(1, 2),
 	RunE: runUpload,
 }
@@ -38,11 +104,52 @@ Examples:
 func init() {
 	uploadCmd.Flags().BoolVarP(&uploadRecursive, "recursive", "r", false, "upload directory recursively")
 	uploadCmd.Flags().StringVarP(&uploadContentType, "content-type", "t", "", "override content-type")
+	uploadCmd.Flags().StringVar(&uploadEncoding, "content-encoding", "", "declare the files as already encoded (gzip)")
+	uploadCmd.Flags().IntVar(&uploadConcurrency, "concurrency", 0, "parallel uploads with --recursive (profile: options.upload_concurrency, default: 1)")
+	uploadCmd.Flags().BoolVar(&uploadFailFast, "fail-fast", false, "stop a --recursive upload at the first failure")
+	uploadCmd.Flags().BoolVar(&uploadDelta, "delta", false, "send only blocks that changed since the stored version")
+	uploadCmd.Flags().IntVar(&uploadBlockSize, "block-size", 0, "delta block size in bytes (default: server default, 65536)")
+	uploadCmd.Flags().StringVar(&uploadTemplate, "template", "", "remote path template expanded per file (replaces remote-path)")
+	uploadCmd.Flags().BoolVar(&uploadAtomic, "atomic", false, "commit all files together or none of them")
+	uploadCmd.Flags().StringVar(&uploadFilename, "filename", "", "original filename to record (default: the local file name)")
+	uploadCmd.Flags().Var(&filterFlag{rules: &uploadFilters, exclude: true}, "exclude", "skip files matching a pattern with --recursive (repeatable)")
+	uploadCmd.Flags().Var(&filterFlag{rules: &uploadFilters}, "include", "upload files matching a pattern with --recursive (repeatable)")
+	uploadCmd.Flags().BoolVar(&uploadVerbose, "verbose", false, "list the files skipped by --exclude and --include")
+	uploadCmd.Flags().BoolVar(&uploadStrict, "strict", false, "fail files and directories removed during a recursive upload")
+}
+
+// filterFlag appends to a rule list shared by --include and --exclude, so
+// the rules keep the order they were given in.
+type filterFlag struct {
+	rules   *[]clientcli.FilterRule
+	exclude bool
+}
+
+func (f *filterFlag) String() string { return "" }
+
+func (f *filterFlag) Type() string { return "pattern" }
+
+func (f *filterFlag) Set(pattern string) error {
+	*f.rules = append(*f.rules, clientcli.FilterRule{Pattern: pattern, Exclude: f.exclude})
+	return nil
 }
 
 func runUpload(_ *cobra.Command, args []string) error {
 	localPath := args[0]
 
+	if uploadTemplate != "" && len(args) > 1 {
+		return errors.New("--template replaces remote-path; pass only one of them")
+	}
+	if uploadFilename != "" && uploadRecursive {
+		return errors.New("--filename names a single file; it cannot be used with --recursive")
+	}
+	if len(uploadFilters) > 0 && !uploadRecursive {
+		return errors.New("--exclude and --include select files of a directory; use them with --recursive")
+	}
+	if uploadFailFast && !uploadRecursive {
+		return errors.New("--fail-fast applies to the files of a directory; use it with --recursive")
+	}
+
 	// Derive remote path from local path if not specified
 	remotePath := ""
 	if len(args) > 1 {
@@ -51,27 +158,63 @@ func runUpload(_ *cobra.Command, args []string) error {
 		remotePath = clientcli.NormalizeLocalToRemotePath(localPath)
 	}
 
-	client, err := getClient()
+	client, cfg, err := getClient(nil)
 	if err != nil {
 		return err
 	}
 
 	opts := clientcli.UploadOptions{
-		LocalPath:   localPath,
-		RemotePath:  remotePath,
-		ContentType: uploadContentType,
-		Recursive:   uploadRecursive,
+		LocalPath:       localPath,
+		RemotePath:      cfg.RemotePath(remotePath),
+		ContentType:     uploadContentType,
+		ContentEncoding: uploadEncoding,
+		Filename:        uploadFilename,
+		Recursive:       uploadRecursive,
+		Concurrency:     uploadConcurrency,
+		Delta:           uploadDelta,
+		BlockSize:       uploadBlockSize,
+		Atomic:          uploadAtomic,
+		Filter:          uploadFilters,
+		Strict:          uploadStrict,
+		FailFast:        uploadFailFast,
+	}
+	skipped := 0
+	opts.Skipped = func(relPath string) {
+		skipped++
+		if uploadVerbose && !quiet {
+			_, _ = fmt.Fprintf(os.Stderr, "Skipped: %s\n", relPath)
+		}
 	}
+	if uploadRecursive {
+		opts.Progress = getProgress()
+	}
+	if uploadTemplate != "" {
+		opts.PathTemplate = cfg.RemotePath(uploadTemplate)
+	}
+
+	formatter := getFormatter()
 
-	results, err := client.Upload(context.Background(), opts)
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	results, err := client.Upload(ctx, opts)
+	if err != nil && len(results) > 0 {
+		// Show which files were uploaded and which failed before the error
+		_ = formatter.FormatUpload(os.Stdout, results)
+	}
 	if err != nil {
 		return handleError(os.Stderr, err)
 	}
 
-	formatter := getFormatter()
 	if err := formatter.FormatUpload(os.Stdout, results); err != nil {
 		return err
 	}
+	if skipped > 0 && !quiet {
+		_, _ = fmt.Fprintf(os.Stderr, "Skipped %d path(s) matching --exclude/--include\n", skipped)
+	}
+	if removed := countSkipped(results, clientcli.SkipReasonRemoved); removed > 0 && !quiet {
+		_, _ = fmt.Fprintf(os.Stderr, "Skipped %d file(s) removed during upload\n", removed)
+	}
 
 	// Check for any errors in results
 	for i := range results {
@@ -82,3 +225,14 @@ func runUpload(_ *cobra.Command, args []string) error {
 
 	return nil
 }
+
+// countSkipped returns the number of results skipped for reason.
+func countSkipped(results []clientcli.UploadResult, reason string) int {
+	n := 0
+	for i := range results {
+		if results[i].Skipped && results[i].SkipReason == reason {
+			n++
+		}
+	}
+	return n
+}
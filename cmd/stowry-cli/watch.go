@@ -0,0 +1,102 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/sagarc03/stowry/clientcli"
+	"github.com/spf13/cobra"
+)
+
+var (
+	watchSince int64
+	watchLimit int
+	watchOnce  bool
+)
+
+var watchCmd = &cobra.Command{
+	Use:   "watch [prefix]",
+	Short: "Print changes under a prefix as they happen",
+	Long: `Print creates and deletes under a prefix as newline-delimited JSON, one
+change per line, until interrupted.
+
+Each line carries a "seq" cursor. Pass the last one to --since to continue
+after a restart without missing changes; without --since, only changes made
+after the command starts are printed. A cursor older than the server's
+change retention is rejected.
+
+NOTE: This command only works when the server is running in "store" mode.
+
+Examples:
+  stowry-cli watch
+  stowry-cli watch images/
+  stowry-cli watch images/ --since 1042
+  stowry-cli watch --since 0 --once`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: runWatch,
+}
+
+func init() {
+	watchCmd.Flags().Int64Var(&watchSince, "since", -1, "print changes after this cursor (default: only new changes)")
+	watchCmd.Flags().IntVarP(&watchLimit, "limit", "l", 0, "max changes per request (max: 1000)")
+	watchCmd.Flags().BoolVar(&watchOnce, "once", false, "print the changes already recorded and exit")
+}
+
+func runWatch(_ *cobra.Command, args []string) error {
+	prefix := ""
+	if len(args) > 0 {
+		prefix = args[0]
+	}
+
+	client, cfg, err := getClient(nil)
+	if err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	opts := clientcli.WatchOptions{
+		Prefix: cfg.RemotePath(prefix),
+		Since:  watchSince,
+		Limit:  watchLimit,
+	}
+	enc := json.NewEncoder(os.Stdout)
+
+	if watchOnce {
+		opts.Timeout = -1
+		for {
+			result, err := client.Watch(ctx, opts)
+			if err != nil {
+				return handleError(os.Stderr, err)
+			}
+			for _, change := range result.Changes {
+				if err := enc.Encode(change); err != nil {
+					return err
+				}
+			}
+			if len(result.Changes) == 0 {
+				return nil
+			}
+			opts.Since = result.Next
+		}
+	}
+
+	last := watchSince
+	err = client.Follow(ctx, opts, func(change clientcli.Change) error {
+		last = change.Seq
+		return enc.Encode(change)
+	})
+	if errors.Is(err, context.Canceled) {
+		if !quiet && last >= 0 {
+			_, _ = fmt.Fprintf(os.Stderr, "resume with --since %d\n", last)
+		}
+		return nil
+	}
+	return handleError(os.Stderr, err)
+}
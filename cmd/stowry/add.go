@@ -2,11 +2,13 @@ package main
 
 import (
 	"fmt"
+	"io"
 	"io/fs"
 	"log/slog"
 	"mime"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
 
 	"github.com/spf13/cobra"
@@ -14,7 +16,6 @@ import (
 	"github.com/sagarc03/stowry"
 	"github.com/sagarc03/stowry/config"
 	"github.com/sagarc03/stowry/database"
-	"github.com/sagarc03/stowry/filesystem"
 )
 
 var addCmd = &cobra.Command{
@@ -37,7 +38,10 @@ Examples:
   stowry add -r /path/to/assets
 
   # Skip existing files
-  stowry add --no-clobber /path/to/file.txt`,
+  stowry add --no-clobber /path/to/file.txt
+
+Metadata is registered in batches (see --batch-size); if a batch fails,
+the files written for that batch are removed again.`,
 	Args: cobra.MinimumNArgs(1),
 	RunE: runAdd,
 }
@@ -47,6 +51,7 @@ var (
 	addRecursive bool
 	addNoClobber bool
 	addQuiet     bool
+	addBatchSize int
 )
 
 func init() {
@@ -54,6 +59,7 @@ func init() {
 	addCmd.Flags().BoolVarP(&addRecursive, "recursive", "r", false, "recursively add directories")
 	addCmd.Flags().BoolVarP(&addNoClobber, "no-clobber", "n", false, "skip existing files instead of overwriting")
 	addCmd.Flags().BoolVarP(&addQuiet, "quiet", "q", false, "suppress per-file output")
+	addCmd.Flags().IntVar(&addBatchSize, "batch-size", stowry.DefaultBatchSize, "number of files whose metadata is written per batch")
 	rootCmd.AddCommand(addCmd)
 }
 
@@ -87,20 +93,13 @@ func runAdd(cmd *cobra.Command, args []string) error {
 
 	repo := db.GetRepo()
 
-	_, err = os.Stat(cfg.Storage.Path)
-	if os.IsNotExist(err) {
-		return fmt.Errorf("storage directory does not exist: %s", cfg.Storage.Path)
-	}
-
-	root, err := os.OpenRoot(cfg.Storage.Path)
+	storage, closeStorage, err := openStorage(cfg, false, nil)
 	if err != nil {
-		return fmt.Errorf("open storage root: %w", err)
+		return err
 	}
-	defer func() { _ = root.Close() }()
+	defer closeStorage()
 
-	storage := filesystem.NewFileStorage(root)
-
-	serviceCfg := stowry.ServiceConfig{Mode: stowry.ModeStore}
+	serviceCfg := stowry.ServiceConfig{Mode: stowry.ModeStore, PathLimits: cfg.Service.PathLimits}
 	service, err := stowry.NewStowryService(repo, storage, serviceCfg)
 	if err != nil {
 		return fmt.Errorf("create service: %w", err)
@@ -121,11 +120,17 @@ func runAdd(cmd *cobra.Command, args []string) error {
 		return nil
 	}
 
+	if addBatchSize <= 0 {
+		return fmt.Errorf("--batch-size must be positive")
+	}
+
 	added := 0
 	skipped := 0
 
+	// Check if files exist when --no-clobber is set
+	objs := make([]stowry.CreateObject, 0, len(files))
+	sources := make(map[string]string, len(files))
 	for _, entry := range files {
-		// Check if file exists when --no-clobber is set
 		if addNoClobber {
 			_, getErr := repo.Get(ctx, entry.destPath)
 			if getErr == nil {
@@ -137,29 +142,27 @@ func runAdd(cmd *cobra.Command, args []string) error {
 			}
 		}
 
-		// Open source file
-		f, openErr := os.Open(entry.sourcePath)
-		if openErr != nil {
-			return fmt.Errorf("open %s: %w", entry.sourcePath, openErr)
-		}
-
-		contentType := detectContentType(entry.sourcePath)
-
-		obj := stowry.CreateObject{
+		objs = append(objs, stowry.CreateObject{
 			Path:        entry.destPath,
-			ContentType: contentType,
-		}
+			ContentType: detectContentType(entry.sourcePath),
+		})
+		sources[entry.destPath] = entry.sourcePath
+	}
 
-		_, createErr := service.Create(ctx, obj, f)
-		_ = f.Close()
+	openSource := func(obj stowry.CreateObject) (io.ReadCloser, error) {
+		return os.Open(sources[obj.Path])
+	}
 
-		if createErr != nil {
-			return fmt.Errorf("add %s: %w", entry.destPath, createErr)
+	for batch := range slices.Chunk(objs, addBatchSize) {
+		if _, createErr := service.CreateMany(ctx, batch, openSource); createErr != nil {
+			return fmt.Errorf("add: %w", createErr)
 		}
 
-		added++
+		added += len(batch)
 		if !addQuiet {
-			slog.Info("added", "path", entry.destPath, "content_type", contentType)
+			for _, obj := range batch {
+				slog.Info("added", "path", obj.Path, "content_type", obj.ContentType)
+			}
 		}
 	}
 
@@ -0,0 +1,209 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/sagarc03/stowry"
+	"github.com/sagarc03/stowry/config"
+	"github.com/sagarc03/stowry/database"
+	stowryhttp "github.com/sagarc03/stowry/http"
+	"github.com/sagarc03/stowry/keybackend"
+)
+
+var adminCmd = &cobra.Command{
+	Use:   "admin",
+	Short: "Administer the server's access keys",
+}
+
+var adminKeysCmd = &cobra.Command{
+	Use:   "keys",
+	Short: "List, rotate and revoke access keys",
+	Long: `Rotate an access key without breaking its clients:
+
+  1. "admin keys rotate <id>" adds a new key to auth.keys.extra_file and
+     marks the old one deprecated. The old key keeps working; the server
+     logs a warning each time it is used.
+  2. Move the clients to the new key.
+  3. "admin keys revoke <id>" removes the old key once "admin keys list"
+     shows it unused for auth.keys.revoke_window seconds.
+
+A running server records key use when auth.keys.track_usage is set,
+flushing it every auth.keys.usage_flush_interval seconds, so the last used
+time shown here may trail by that long.`,
+}
+
+var adminKeysListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List access keys with their use and rotation status",
+	RunE:  runAdminKeysList,
+}
+
+var adminKeysRotateCmd = &cobra.Command{
+	Use:   "rotate <id>",
+	Short: "Create a replacement for an access key and deprecate it",
+	Long: `Create a new access key, add it to auth.keys.extra_file and mark <id>
+as deprecated and replaced by it. The new secret key is printed once and
+stored nowhere but the extra keys file.`,
+	Args: cobra.ExactArgs(1),
+	RunE: runAdminKeysRotate,
+}
+
+var adminKeysRevokeCmd = &cobra.Command{
+	Use:   "revoke <id>",
+	Short: "Remove an access key from the extra keys file",
+	Long: `Remove <id> from auth.keys.extra_file and record it as revoked. It
+refuses a key used within auth.keys.revoke_window seconds unless --force
+is given. Keys from auth.keys.inline or auth.keys.file must be removed from
+the configuration by hand.`,
+	Args: cobra.ExactArgs(1),
+	RunE: runAdminKeysRevoke,
+}
+
+var (
+	adminKeysListOutput  string
+	adminKeysRevokeForce bool
+)
+
+func init() {
+	adminKeysListCmd.Flags().StringVar(&adminKeysListOutput, "output", "table", "output format: table or json")
+	adminKeysRevokeCmd.Flags().BoolVar(&adminKeysRevokeForce, "force", false, "revoke even if the key was used within the revoke window")
+	adminKeysCmd.AddCommand(adminKeysListCmd)
+	adminKeysCmd.AddCommand(adminKeysRotateCmd)
+	adminKeysCmd.AddCommand(adminKeysRevokeCmd)
+	adminCmd.AddCommand(adminKeysCmd)
+	rootCmd.AddCommand(adminCmd)
+}
+
+// openAccessKeyRepo connects to the database and returns its access key
+// records. Only metadata is read, so no storage is opened.
+func openAccessKeyRepo(cmd *cobra.Command) (stowry.AccessKeyRepo, *config.Config, func(), error) {
+	cfg, err := config.FromContext(cmd.Context())
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	ctx := cmd.Context()
+
+	db, err := database.Connect(ctx, cfg.Database)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("connect database: %w", err)
+	}
+
+	if err = db.Ping(ctx); err != nil {
+		_ = db.Close()
+		return nil, nil, nil, fmt.Errorf("ping database: %w", err)
+	}
+
+	if err = db.Validate(ctx); err != nil {
+		_ = db.Close()
+		return nil, nil, nil, fmt.Errorf("validate database schema: %w", err)
+	}
+
+	repo, ok := db.GetRepo().(stowry.AccessKeyRepo)
+	if !ok {
+		_ = db.Close()
+		return nil, nil, nil, fmt.Errorf("metadata repository does not record access keys: %w", stowry.ErrNotSupported)
+	}
+	return repo, cfg, func() { _ = db.Close() }, nil
+}
+
+func runAdminKeysList(cmd *cobra.Command, args []string) error {
+	if adminKeysListOutput != "table" && adminKeysListOutput != "json" {
+		return fmt.Errorf("--output must be table or json, got %q", adminKeysListOutput)
+	}
+
+	repo, cfg, closeDB, err := openAccessKeyRepo(cmd)
+	if err != nil {
+		return err
+	}
+	defer closeDB()
+
+	store, err := keybackend.NewSecretStore(cfg.Auth.Keys)
+	if err != nil {
+		return fmt.Errorf("load keys: %w", err)
+	}
+	keys, err := repo.ListAccessKeys(cmd.Context())
+	if err != nil {
+		return err
+	}
+	var configured []string
+	if lister, ok := store.(interface{ AccessKeys() []string }); ok {
+		configured = lister.AccessKeys()
+	}
+	keys = stowry.MergeAccessKeys(keys, configured)
+
+	out := cmd.OutOrStdout()
+	if adminKeysListOutput == "json" {
+		entries := make([]stowryhttp.AccessKeyEntry, len(keys))
+		for i, k := range keys {
+			entries[i] = stowryhttp.AccessKeyEntry{AccessKeyInfo: k, Status: k.Status()}
+		}
+		enc := json.NewEncoder(out)
+		enc.SetIndent("", "  ")
+		return enc.Encode(stowryhttp.AccessKeysResponse{Keys: entries})
+	}
+
+	tw := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(tw, "ACCESS KEY\tSTATUS\tCREATED\tLAST USED\tREQUESTS\tREPLACED BY")
+	for _, k := range keys {
+		_, _ = fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\n", k.AccessKey, k.Status(),
+			formatKeyTime(k.CreatedAt), formatKeyTime(k.LastUsedAt), strconv.FormatInt(k.RequestCount, 10), k.ReplacedBy)
+	}
+	return tw.Flush()
+}
+
+// formatKeyTime formats an access key time for the key table, "-" when
+// unset.
+func formatKeyTime(t *time.Time) string {
+	if t == nil {
+		return "-"
+	}
+	return t.UTC().Format(time.RFC3339)
+}
+
+func runAdminKeysRotate(cmd *cobra.Command, args []string) error {
+	repo, cfg, closeDB, err := openAccessKeyRepo(cmd)
+	if err != nil {
+		return err
+	}
+	defer closeDB()
+
+	rotator, err := keybackend.NewKeyRotator(cfg.Auth.Keys, repo)
+	if err != nil {
+		return err
+	}
+	pair, err := rotator.Rotate(cmd.Context(), args[0])
+	if err != nil {
+		return err
+	}
+
+	slog.Info("access key rotated", "deprecated", args[0], "replacement", pair.AccessKey, "extra_file", cfg.Auth.Keys.ExtraFile)
+	_, err = fmt.Fprintf(cmd.OutOrStdout(), "access_key: %s\nsecret_key: %s\n", pair.AccessKey, pair.SecretKey)
+	return err
+}
+
+func runAdminKeysRevoke(cmd *cobra.Command, args []string) error {
+	repo, cfg, closeDB, err := openAccessKeyRepo(cmd)
+	if err != nil {
+		return err
+	}
+	defer closeDB()
+
+	rotator, err := keybackend.NewKeyRotator(cfg.Auth.Keys, repo)
+	if err != nil {
+		return err
+	}
+	if err := rotator.Revoke(cmd.Context(), args[0], adminKeysRevokeForce); err != nil {
+		return err
+	}
+
+	slog.Info("access key revoked", "access_key", args[0], "forced", adminKeysRevokeForce)
+	return nil
+}
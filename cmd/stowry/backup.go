@@ -0,0 +1,151 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/sagarc03/stowry"
+	"github.com/sagarc03/stowry/config"
+	"github.com/sagarc03/stowry/database"
+)
+
+var backupCmd = &cobra.Command{
+	Use:   "backup",
+	Short: "Write a consistent snapshot of the SQLite database",
+	Long: `Write a consistent snapshot of the SQLite metadata database while the
+server keeps running.
+
+Copying the database file while the server writes to it can produce a
+corrupt backup. This command uses SQLite's VACUUM INTO, which reads a
+consistent view of the database without blocking writers for long.
+
+PostgreSQL databases are not backed up by stowry; use pg_dump.`,
+	Example: `  stowry backup --output /backups/stowry-$(date +%F).db`,
+	RunE:    runBackup,
+}
+
+var restoreCmd = &cobra.Command{
+	Use:   "restore <snapshot>",
+	Short: "Replace the SQLite database with a snapshot",
+	Long: `Replace the SQLite metadata database with a snapshot made by
+"stowry backup".
+
+The snapshot must pass an integrity check and hold the metadata table. A
+snapshot from an older release is brought up to date by the next migration.
+
+The server must be stopped first. Restore refuses to run while something
+listens on the configured server address, unless --force is given.
+
+PostgreSQL databases are not restored by stowry; use pg_restore.`,
+	Args: cobra.ExactArgs(1),
+	RunE: runRestore,
+}
+
+var (
+	backupOutput string
+	restoreForce bool
+)
+
+func init() {
+	backupCmd.Flags().StringVarP(&backupOutput, "output", "o", "", "snapshot file to write; must not exist")
+	_ = backupCmd.MarkFlagRequired("output")
+	restoreCmd.Flags().BoolVar(&restoreForce, "force", false, "restore even if a server appears to be running")
+	rootCmd.AddCommand(backupCmd)
+	rootCmd.AddCommand(restoreCmd)
+}
+
+// postgresBackupHint explains how to back up the PostgreSQL tables instead.
+func postgresBackupHint(tables stowry.Tables) string {
+	return fmt.Sprintf("stowry does not back up postgres databases; use pg_dump, for example:\n"+
+		"  pg_dump --format=custom --table='%s*' --file=stowry.dump \"$DSN\"\n"+
+		"and restore with pg_restore", tables.MetaData)
+}
+
+func runBackup(cmd *cobra.Command, args []string) error {
+	cfg, err := config.FromContext(cmd.Context())
+	if err != nil {
+		return err
+	}
+
+	ctx := cmd.Context()
+
+	db, err := database.Connect(ctx, cfg.Database)
+	if err != nil {
+		return fmt.Errorf("connect database: %w", err)
+	}
+	defer func() { _ = db.Close() }()
+
+	backuper, ok := db.(database.Backuper)
+	if !ok {
+		return errors.New(postgresBackupHint(cfg.Database.Tables))
+	}
+
+	if err = db.Ping(ctx); err != nil {
+		return fmt.Errorf("ping database: %w", err)
+	}
+
+	start := time.Now()
+	if err := backuper.Backup(ctx, backupOutput); err != nil {
+		return err
+	}
+
+	info, err := os.Stat(backupOutput)
+	if err != nil {
+		return fmt.Errorf("backup: %w", err)
+	}
+	slog.Info("backup complete", "output", backupOutput, "size_bytes", info.Size(), "duration", time.Since(start))
+	return nil
+}
+
+func runRestore(cmd *cobra.Command, args []string) error {
+	cfg, err := config.FromContext(cmd.Context())
+	if err != nil {
+		return err
+	}
+
+	ctx := cmd.Context()
+	snapshot := args[0]
+
+	if cfg.Database.Type != "sqlite" {
+		return errors.New(postgresBackupHint(cfg.Database.Tables))
+	}
+
+	if addr, running := serverRunning(cfg.Server); running {
+		if !restoreForce {
+			return fmt.Errorf("a server is listening on %s; stop it before restoring, or pass --force", addr)
+		}
+		slog.Warn("restoring while a server is listening", "addr", addr)
+	}
+
+	if err := database.Restore(ctx, cfg.Database, snapshot); err != nil {
+		return err
+	}
+
+	slog.Info("restore complete", "snapshot", snapshot, "database", cfg.Database.DSN)
+	return nil
+}
+
+// serverRunning reports whether something accepts connections on the
+// configured server address. It cannot tell which database that server
+// uses, so it errs on the side of refusing.
+func serverRunning(server config.ServerConfig) (string, bool) {
+	host := server.Host
+	if host == "" {
+		host = "127.0.0.1"
+	}
+	addr := net.JoinHostPort(host, strconv.Itoa(server.Port))
+
+	conn, err := net.DialTimeout("tcp", addr, 500*time.Millisecond)
+	if err != nil {
+		return addr, false
+	}
+	_ = conn.Close()
+	return addr, true
+}
@@ -3,14 +3,13 @@ package main
 import (
 	"fmt"
 	"log/slog"
-	"os"
+	"time"
 
 	"github.com/spf13/cobra"
 
 	"github.com/sagarc03/stowry"
 	"github.com/sagarc03/stowry/config"
 	"github.com/sagarc03/stowry/database"
-	"github.com/sagarc03/stowry/filesystem"
 )
 
 var cleanupCmd = &cobra.Command{
@@ -23,14 +22,54 @@ but not yet physically removed from storage. It:
   1. Deletes the physical file from storage
   2. Marks the metadata entry as cleaned up
 
-Run this periodically to reclaim storage space from deleted files.`,
+A file whose size no longer matches the deleted object, for example because
+the path was written again after the delete, is kept and reported as
+mismatched. Use --verify-hash to compare the SHA-256 of every file as well,
+and --force to mark mismatched entries cleaned up anyway (the file is still
+kept).
+
+A file that cannot be removed is reported and skipped, and cleanup moves on
+to the next. Entries that failed are left alone for an hour, so a broken path
+is not retried on every run; --retry-deferred retries them now. Cleanup
+exits non-zero when any entry failed. Use --strict to stop at the first
+failure instead.
+
+Use --older-than to only remove files deleted longer ago than a duration,
+keeping recent deletes around a while longer, and --prefix to only remove
+files under a path prefix. The server's scheduled cleanup
+(service.cleanup_interval) runs the same way with service.cleanup_retention
+as --older-than.
+
+Cleanup also aborts multipart uploads started more than --multipart-expiry
+ago (7 days by default) and never completed, removing their parts.
+
+Run this periodically to reclaim storage space from deleted files.
+Use --dry-run to see how many files and bytes would be removed.`,
 	RunE: runCleanup,
 }
 
-var cleanupLimit int
+var (
+	cleanupLimit      int
+	cleanupDryRun     bool
+	cleanupVerifyHash bool
+	cleanupForce      bool
+	cleanupStrict     bool
+	cleanupRetry      bool
+	cleanupMultipart  time.Duration
+	cleanupOlderThan  time.Duration
+	cleanupPrefix     string
+)
 
 func init() {
 	cleanupCmd.Flags().IntVar(&cleanupLimit, "limit", 100, "maximum number of files to clean up per batch")
+	cleanupCmd.Flags().BoolVar(&cleanupDryRun, "dry-run", false, "report what would be cleaned up without deleting anything")
+	cleanupCmd.Flags().BoolVar(&cleanupVerifyHash, "verify-hash", false, "compare the SHA-256 of each file with the deleted object before removing it")
+	cleanupCmd.Flags().BoolVar(&cleanupForce, "force", false, "mark entries whose file no longer matches as cleaned up, keeping the file")
+	cleanupCmd.Flags().BoolVar(&cleanupStrict, "strict", false, "stop at the first entry that fails instead of skipping it")
+	cleanupCmd.Flags().BoolVar(&cleanupRetry, "retry-deferred", false, "also retry entries deferred after an earlier failure")
+	cleanupCmd.Flags().DurationVar(&cleanupMultipart, "multipart-expiry", stowry.DefaultMultipartExpiry, "abort incomplete multipart uploads started longer ago than this")
+	cleanupCmd.Flags().DurationVar(&cleanupOlderThan, "older-than", 0, "only clean up files deleted longer ago than this (0 = all)")
+	cleanupCmd.Flags().StringVar(&cleanupPrefix, "prefix", "", "only clean up files under this path prefix")
 	rootCmd.AddCommand(cleanupCmd)
 }
 
@@ -59,18 +98,15 @@ func runCleanup(cmd *cobra.Command, args []string) error {
 
 	repo := db.GetRepo()
 
-	_, err = os.Stat(cfg.Storage.Path)
-	if os.IsNotExist(err) {
-		return fmt.Errorf("storage directory does not exist: %s", cfg.Storage.Path)
-	}
-
-	root, err := os.OpenRoot(cfg.Storage.Path)
+	storage, closeStorage, err := openStorage(cfg, false, nil)
 	if err != nil {
-		return fmt.Errorf("open storage root: %w", err)
+		return err
 	}
-	defer func() { _ = root.Close() }()
+	defer closeStorage()
 
-	storage := filesystem.NewFileStorage(root)
+	if err := bindInstance(ctx, cfg, repo, false); err != nil {
+		return err
+	}
 
 	serviceCfg := stowry.ServiceConfig{Mode: stowry.ModeStore}
 	service, err := stowry.NewStowryService(repo, storage, serviceCfg)
@@ -78,13 +114,49 @@ func runCleanup(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("create service: %w", err)
 	}
 
-	slog.Info("starting cleanup", "limit", cleanupLimit)
+	query := stowry.ListQuery{Limit: cleanupLimit, PathPrefix: cleanupPrefix}
+	if cleanupOlderThan > 0 {
+		query.DeletedBefore = time.Now().Add(-cleanupOlderThan)
+	}
 
-	cleaned, err := service.Tombstone(ctx, stowry.ListQuery{Limit: cleanupLimit})
+	slog.Info("starting cleanup", "limit", cleanupLimit, "prefix", cleanupPrefix, "older_than", cleanupOlderThan,
+		"dry_run", cleanupDryRun, "verify_hash", cleanupVerifyHash, "force", cleanupForce, "strict", cleanupStrict)
+
+	report, err := service.TombstoneWithReport(ctx, stowry.TombstoneOptions{
+		Query:           query,
+		DryRun:          cleanupDryRun,
+		VerifyHash:      cleanupVerifyHash,
+		Force:           cleanupForce,
+		Progress:        progressReporter(),
+		ContinueOnError: !cleanupStrict,
+		RetryDeferred:   cleanupRetry,
+		MultipartExpiry: cleanupMultipart,
+	})
 	if err != nil {
 		return fmt.Errorf("tombstone: %w", err)
 	}
 
-	slog.Info("cleanup complete", "files_cleaned", cleaned)
+	for _, e := range report.Errors {
+		slog.Error("cleanup failed", "path", e.Path, "error", e.Err)
+	}
+
+	if cleanupDryRun {
+		slog.Info("cleanup dry run complete", "files_to_clean", report.Cleaned, "bytes_to_reclaim", report.BytesReclaimed,
+			"mismatched", report.Mismatched, "failed", report.Failed, "deferred", report.Deferred,
+			"uploads_to_abort", report.AbortedUploads)
+	} else {
+		slog.Info("cleanup complete",
+			"files_cleaned", report.Cleaned,
+			"bytes_reclaimed", report.BytesReclaimed,
+			"already_missing", report.Skipped,
+			"mismatched", report.Mismatched,
+			"failed", report.Failed,
+			"deferred", report.Deferred,
+			"aborted_uploads", report.AbortedUploads,
+		)
+	}
+	if report.Failed > 0 {
+		return fmt.Errorf("cleanup: %d files failed", report.Failed)
+	}
 	return nil
 }
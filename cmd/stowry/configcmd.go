@@ -0,0 +1,61 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/sagarc03/stowry/config"
+)
+
+var configCmd = &cobra.Command{
+	Use:   "config",
+	Short: "Inspect server configuration",
+}
+
+var configValidateCmd = &cobra.Command{
+	Use:   "validate",
+	Short: "Validate configuration and run security checks",
+	Long: `Load the configuration, validate it, and report insecure settings.
+
+Checks include public write access on a non-loopback address, short or
+reused secret keys, private auth over plain HTTP, and CORS allowing any
+origin with credentials. Findings are warnings unless raised to errors
+with security.rules or --strict-security. Exits non-zero on any error.`,
+	RunE:         runConfigValidate,
+	SilenceUsage: true,
+}
+
+func init() {
+	configValidateCmd.Flags().Bool("strict-security", false, "treat security warnings as errors (env: STOWRY_SECURITY_STRICT)")
+	configCmd.AddCommand(configValidateCmd)
+	rootCmd.AddCommand(configCmd)
+}
+
+func runConfigValidate(cmd *cobra.Command, args []string) error {
+	cfg, err := config.FromContext(cmd.Context())
+	if err != nil {
+		return err
+	}
+
+	findings := cfg.LintSecurity()
+	out := cmd.OutOrStdout()
+
+	if len(findings) == 0 {
+		_, _ = fmt.Fprintln(out, "Configuration is valid. No security findings.")
+		return nil
+	}
+
+	errCount := 0
+	for _, f := range findings {
+		if f.Severity == config.SeverityError {
+			errCount++
+		}
+		_, _ = fmt.Fprintf(out, "%-7s %-26s %s\n", f.Severity, f.Rule, f.Message)
+	}
+
+	if errCount > 0 {
+		return fmt.Errorf("%d security error(s)", errCount)
+	}
+	return nil
+}
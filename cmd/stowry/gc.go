@@ -0,0 +1,137 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/sagarc03/stowry"
+	"github.com/sagarc03/stowry/config"
+	"github.com/sagarc03/stowry/database"
+)
+
+var gcCmd = &cobra.Command{
+	Use:   "gc",
+	Short: "Find files in storage that have no metadata",
+	Long: `Find orphaned files: files in storage that no metadata entry references,
+live or soft-deleted. Orphans are left behind by crashes between writing a file
+and recording it, by manual copies into the storage directory, and by
+metadata that was removed without its file.
+
+By default gc only reports orphans. Use --quarantine to move them under
+--quarantine-prefix for inspection, or --delete to remove them.
+
+Files modified within --min-age are skipped so uploads that are still being
+recorded are never flagged.`,
+	RunE: runGC,
+}
+
+var (
+	gcMinAge           time.Duration
+	gcDelete           bool
+	gcQuarantine       bool
+	gcQuarantinePrefix string
+	gcBatchSize        int
+)
+
+func init() {
+	gcCmd.Flags().DurationVar(&gcMinAge, "min-age", 24*time.Hour, "skip files modified more recently than this")
+	gcCmd.Flags().BoolVar(&gcDelete, "delete", false, "delete orphaned files")
+	gcCmd.Flags().BoolVar(&gcQuarantine, "quarantine", false, "move orphaned files under --quarantine-prefix")
+	gcCmd.Flags().StringVar(&gcQuarantinePrefix, "quarantine-prefix", stowry.DefaultQuarantinePrefix, "storage prefix that quarantined files are moved under")
+	gcCmd.Flags().IntVar(&gcBatchSize, "batch-size", stowry.DefaultBatchSize, "number of paths checked against metadata per batch")
+	gcCmd.MarkFlagsMutuallyExclusive("delete", "quarantine")
+	rootCmd.AddCommand(gcCmd)
+}
+
+func runGC(cmd *cobra.Command, args []string) error {
+	cfg, err := config.FromContext(cmd.Context())
+	if err != nil {
+		return err
+	}
+
+	ctx := cmd.Context()
+
+	if gcMinAge < 0 {
+		return fmt.Errorf("--min-age must not be negative")
+	}
+
+	db, err := database.Connect(ctx, cfg.Database)
+	if err != nil {
+		return fmt.Errorf("connect database: %w", err)
+	}
+	defer func() { _ = db.Close() }()
+
+	if err = db.Ping(ctx); err != nil {
+		return fmt.Errorf("ping database: %w", err)
+	}
+
+	// GC expects tables to already exist - just validate
+	if err = db.Validate(ctx); err != nil {
+		return fmt.Errorf("validate database schema: %w", err)
+	}
+
+	repo := db.GetRepo()
+
+	storage, closeStorage, err := openStorage(cfg, false, nil)
+	if err != nil {
+		return err
+	}
+	defer closeStorage()
+
+	if err := bindInstance(ctx, cfg, repo, false); err != nil {
+		return err
+	}
+
+	serviceCfg := stowry.ServiceConfig{Mode: stowry.ModeStore, BatchSize: gcBatchSize}
+	service, err := stowry.NewStowryService(repo, storage, serviceCfg)
+	if err != nil {
+		return fmt.Errorf("create service: %w", err)
+	}
+
+	action := stowry.OrphanActionReport
+	switch {
+	case gcDelete:
+		action = stowry.OrphanActionDelete
+	case gcQuarantine:
+		action = stowry.OrphanActionQuarantine
+	}
+
+	slog.Info("starting gc", "action", action, "min_age", gcMinAge)
+
+	report, err := service.CollectOrphans(ctx, stowry.OrphanOptions{
+		Action:           action,
+		MinAge:           gcMinAge,
+		QuarantinePrefix: gcQuarantinePrefix,
+		Progress:         progressReporter(),
+	})
+	if err != nil {
+		return fmt.Errorf("gc: %w", err)
+	}
+
+	if action == stowry.OrphanActionReport {
+		for _, path := range report.Paths {
+			slog.Info("orphan", "path", path)
+		}
+	}
+	for _, e := range report.Errors {
+		slog.Warn("gc failed", "path", e.Path, "error", e.Err)
+	}
+
+	slog.Info("gc complete",
+		"action", action,
+		"scanned", report.Scanned,
+		"too_recent", report.TooRecent,
+		"orphans", report.Orphans,
+		"bytes", report.Bytes,
+		"handled", report.Handled,
+		"failed", len(report.Errors),
+	)
+
+	if len(report.Errors) > 0 {
+		return fmt.Errorf("gc: %d file(s) could not be handled", len(report.Errors))
+	}
+	return nil
+}
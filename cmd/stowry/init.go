@@ -3,14 +3,12 @@ package main
 import (
 	"fmt"
 	"log/slog"
-	"os"
 
 	"github.com/spf13/cobra"
 
 	"github.com/sagarc03/stowry"
 	"github.com/sagarc03/stowry/config"
 	"github.com/sagarc03/stowry/database"
-	"github.com/sagarc03/stowry/filesystem"
 )
 
 var initCmd = &cobra.Command{
@@ -24,7 +22,14 @@ with entries for all existing files. This is useful when:
 	RunE: runInit,
 }
 
+var (
+	initBatchSize int
+	initRebind    bool
+)
+
 func init() {
+	initCmd.Flags().IntVar(&initBatchSize, "batch-size", stowry.DefaultBatchSize, "number of metadata entries written per batch")
+	initCmd.Flags().BoolVar(&initRebind, "rebind", false, "pair the database and storage directory under a new instance ID when they belong to different instances")
 	rootCmd.AddCommand(initCmd)
 }
 
@@ -36,6 +41,10 @@ func runInit(cmd *cobra.Command, args []string) error {
 
 	ctx := cmd.Context()
 
+	if initBatchSize <= 0 {
+		return fmt.Errorf("--batch-size must be positive")
+	}
+
 	db, err := database.Connect(ctx, cfg.Database)
 	if err != nil {
 		return fmt.Errorf("connect database: %w", err)
@@ -57,20 +66,17 @@ func runInit(cmd *cobra.Command, args []string) error {
 
 	repo := db.GetRepo()
 
-	_, err = os.Stat(cfg.Storage.Path)
-	if os.IsNotExist(err) {
-		return fmt.Errorf("storage directory does not exist: %s", cfg.Storage.Path)
-	}
-
-	root, err := os.OpenRoot(cfg.Storage.Path)
+	storage, closeStorage, err := openStorage(cfg, false, nil)
 	if err != nil {
-		return fmt.Errorf("open storage root: %w", err)
+		return err
 	}
-	defer func() { _ = root.Close() }()
+	defer closeStorage()
 
-	storage := filesystem.NewFileStorage(root)
+	if err := bindInstance(ctx, cfg, repo, initRebind); err != nil {
+		return err
+	}
 
-	serviceCfg := stowry.ServiceConfig{Mode: stowry.ModeStore}
+	serviceCfg := stowry.ServiceConfig{Mode: stowry.ModeStore, BatchSize: initBatchSize}
 	service, err := stowry.NewStowryService(repo, storage, serviceCfg)
 	if err != nil {
 		return fmt.Errorf("create service: %w", err)
@@ -78,7 +84,7 @@ func runInit(cmd *cobra.Command, args []string) error {
 
 	slog.Info("scanning storage directory", "path", cfg.Storage.Path)
 
-	if err := service.Populate(ctx); err != nil {
+	if err := service.PopulateWithOptions(ctx, stowry.PopulateOptions{Progress: progressReporter()}); err != nil {
 		return fmt.Errorf("populate: %w", err)
 	}
 
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"github.com/sagarc03/stowry"
+	"github.com/sagarc03/stowry/config"
+	"github.com/sagarc03/stowry/filesystem"
+)
+
+// bindInstance checks that the database and the storage directory belong
+// to the same instance, pairing them on first start. With rebind set, a
+// mismatch is resolved by pairing them under a new instance ID. The storage
+// directory must exist. Storage routes are not checked.
+func bindInstance(ctx context.Context, cfg *config.Config, repo stowry.MetaDataRepo, rebind bool) error {
+	instanceRepo, ok := repo.(stowry.InstanceRepo)
+	if !ok {
+		return nil
+	}
+
+	root, err := openStorageRoot(cfg.Storage.Path, false)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = root.Close() }()
+
+	binding, err := stowry.BindInstance(ctx, instanceRepo, filesystem.NewFileStorage(root), rebind)
+	// A wrapped repository may forward InstanceRepo to one that has none
+	if errors.Is(err, stowry.ErrNotSupported) {
+		return nil
+	}
+	var mismatch *stowry.InstanceMismatchError
+	if errors.As(err, &mismatch) {
+		return fmt.Errorf(`%w
+
+The storage directory (%s) and the %s database were not set up together.
+Serving or cleaning up this pair could lose files the other side still
+references. Check that storage.path and database.dsn point at the same
+instance. If they belong together, pair them with --rebind on stowry serve
+or stowry init`, err, cfg.Storage.Path, cfg.Database.Type)
+	}
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case binding.Created:
+		slog.Info("created instance", "instance_id", binding.ID)
+	case binding.Rebound:
+		slog.Warn("rebound database and storage to a new instance", "instance_id", binding.ID)
+	default:
+		slog.Debug("instance matches", "instance_id", binding.ID)
+	}
+	return nil
+}
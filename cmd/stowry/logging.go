@@ -9,8 +9,12 @@ import (
 	"github.com/lmittmann/tint"
 
 	"github.com/sagarc03/stowry/config"
+	"github.com/sagarc03/stowry/supportbundle"
 )
 
+// errorLog keeps the recent errors logged, for support bundles.
+var errorLog *supportbundle.ErrorLog
+
 func setupLogging(cfg *config.Config) {
 	level := parseLevel(cfg.Log.Level)
 
@@ -20,7 +24,8 @@ func setupLogging(cfg *config.Config) {
 		TimeFormat: "15:04:05.000",
 	})
 
-	logger := slog.New(h)
+	errorLog = supportbundle.NewErrorLog(h, supportbundle.DefaultErrorLogSize)
+	logger := slog.New(errorLog)
 	slog.SetDefault(logger)
 
 	log.SetFlags(0)
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+
+	"github.com/spf13/cobra"
+
+	"github.com/sagarc03/stowry/config"
+	"github.com/sagarc03/stowry/database"
+)
+
+var migrateCmd = &cobra.Command{
+	Use:   "migrate",
+	Short: "Apply pending database migrations",
+	Long: `Compare the database schema with the one this release expects and
+apply the migrations that resolve the difference, in one transaction.
+
+Steps that can lose data or reject existing rows, such as narrowing a
+column's type or adding NOT NULL, are destructive and refused unless
+--allow-destructive is given. Steps that need a table rebuild cannot be
+automated and are printed for you to run by hand.
+
+Use --dry-run to print the pending migrations as SQL without running them,
+for example before starting a server with database.migrate_mode set to
+skip.`,
+	Example: `  stowry migrate --dry-run > pending.sql
+  stowry migrate --allow-destructive`,
+	RunE: runMigrate,
+}
+
+var (
+	migrateDryRun           bool
+	migrateAllowDestructive bool
+)
+
+func init() {
+	migrateCmd.Flags().BoolVar(&migrateDryRun, "dry-run", false, "print the pending migrations as SQL without running them")
+	migrateCmd.Flags().BoolVar(&migrateAllowDestructive, "allow-destructive", false, "apply migrations that can lose data")
+	rootCmd.AddCommand(migrateCmd)
+}
+
+func runMigrate(cmd *cobra.Command, args []string) error {
+	cfg, err := config.FromContext(cmd.Context())
+	if err != nil {
+		return err
+	}
+
+	ctx := cmd.Context()
+
+	db, err := database.Connect(ctx, cfg.Database)
+	if err != nil {
+		return fmt.Errorf("connect database: %w", err)
+	}
+	defer func() { _ = db.Close() }()
+
+	if err = db.Ping(ctx); err != nil {
+		return fmt.Errorf("ping database: %w", err)
+	}
+
+	steps, err := db.Plan(ctx)
+	if err != nil {
+		return err
+	}
+	if len(steps) == 0 {
+		slog.Info("database schema is up to date")
+		return nil
+	}
+
+	if migrateDryRun {
+		fmt.Fprint(cmd.OutOrStdout(), database.RenderSQL(steps))
+		return nil
+	}
+
+	allowDestructive := migrateAllowDestructive || cfg.Database.AllowDestructiveMigrations
+	applied, err := database.PrepareSchema(ctx, db, database.MigrateAuto, allowDestructive)
+	if err != nil {
+		fmt.Fprint(cmd.OutOrStdout(), database.RenderSQL(steps))
+		return fmt.Errorf("migrate database: %w", err)
+	}
+
+	slog.Info("applied database migrations", "steps", len(applied))
+	return nil
+}
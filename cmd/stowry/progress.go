@@ -0,0 +1,17 @@
+package main
+
+import (
+	"log/slog"
+	"os"
+
+	"github.com/sagarc03/stowry/progress"
+)
+
+// progressReporter renders progress of a long-running command as a live
+// status line when stderr is a terminal, and as periodic log lines otherwise.
+func progressReporter() progress.Func {
+	if progress.IsTerminal(os.Stderr) {
+		return progress.Terminal(os.Stderr)
+	}
+	return progress.Logger(slog.Default(), progress.DefaultLogInterval)
+}
@@ -5,14 +5,12 @@ import (
 	"errors"
 	"fmt"
 	"log/slog"
-	"os"
 
 	"github.com/spf13/cobra"
 
 	"github.com/sagarc03/stowry"
 	"github.com/sagarc03/stowry/config"
 	"github.com/sagarc03/stowry/database"
-	"github.com/sagarc03/stowry/filesystem"
 )
 
 var removeCmd = &cobra.Command{
@@ -74,18 +72,11 @@ func runRemove(cmd *cobra.Command, args []string) error {
 
 	repo := db.GetRepo()
 
-	_, err = os.Stat(cfg.Storage.Path)
-	if os.IsNotExist(err) {
-		return fmt.Errorf("storage directory does not exist: %s", cfg.Storage.Path)
-	}
-
-	root, err := os.OpenRoot(cfg.Storage.Path)
+	storage, closeStorage, err := openStorage(cfg, false, nil)
 	if err != nil {
-		return fmt.Errorf("open storage root: %w", err)
+		return err
 	}
-	defer func() { _ = root.Close() }()
-
-	storage := filesystem.NewFileStorage(root)
+	defer closeStorage()
 
 	serviceCfg := stowry.ServiceConfig{Mode: stowry.ModeStore}
 	service, err := stowry.NewStowryService(repo, storage, serviceCfg)
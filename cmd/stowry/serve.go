@@ -2,11 +2,15 @@ package main
 
 import (
 	"context"
+	"errors"
+	"expvar"
 	"fmt"
 	"log/slog"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
+	"strconv"
 	"syscall"
 	"time"
 
@@ -15,9 +19,11 @@ import (
 	"github.com/sagarc03/stowry"
 	"github.com/sagarc03/stowry/config"
 	"github.com/sagarc03/stowry/database"
-	"github.com/sagarc03/stowry/filesystem"
 	stowryhttp "github.com/sagarc03/stowry/http"
+	"github.com/sagarc03/stowry/http/jwtauth"
 	"github.com/sagarc03/stowry/keybackend"
+	"github.com/sagarc03/stowry/progress"
+	"github.com/sagarc03/stowry/tracing"
 )
 
 var serveCmd = &cobra.Command{
@@ -30,6 +36,12 @@ var serveCmd = &cobra.Command{
 func init() {
 	serveCmd.Flags().Int("port", 5708, "HTTP server port")
 	serveCmd.Flags().String("mode", "store", "server mode (store, static, spa)")
+	serveCmd.Flags().Bool("disable-list", false, "answer GET / listing with 403 in store mode (env: STOWRY_SERVER_DISABLE_LIST)")
+	serveCmd.Flags().Bool("strict-security", false, "fail startup on any security warning (env: STOWRY_SECURITY_STRICT)")
+	serveCmd.Flags().Bool("rebind", false, "pair the database and storage directory under a new instance ID when they belong to different instances")
+	serveCmd.Flags().Bool("auto-migrate", false, "apply pending migrations when the schema is out of date (default: true for sqlite, env: STOWRY_DATABASE_AUTO_MIGRATE)")
+	serveCmd.Flags().String("migrate-mode", "", "how startup handles pending migrations: auto, check or skip (default: from --auto-migrate, env: STOWRY_DATABASE_MIGRATE_MODE)")
+	serveCmd.Flags().Bool("allow-destructive-migrations", false, "let auto mode apply migrations that can lose data (env: STOWRY_DATABASE_ALLOW_DESTRUCTIVE_MIGRATIONS)")
 
 	rootCmd.AddCommand(serveCmd)
 }
@@ -40,9 +52,29 @@ func runServe(cmd *cobra.Command, args []string) error {
 		return err
 	}
 
+	if err := config.LogSecurityFindings(cfg.LintSecurity()); err != nil {
+		return err
+	}
+
 	ctx, cancel := context.WithCancel(cmd.Context())
 	defer cancel()
 
+	tracingCfg := cfg.Observability.Tracing
+	shutdownTracing, err := tracing.Setup(ctx, tracingCfg)
+	if err != nil {
+		return fmt.Errorf("setup tracing: %w", err)
+	}
+	defer func() {
+		flushCtx, flushCancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer flushCancel()
+		if err := shutdownTracing(flushCtx); err != nil {
+			slog.Warn("flush traces", "err", err)
+		}
+	}()
+	if tracingCfg.Enabled {
+		slog.Info("tracing enabled", "endpoint", tracingCfg.Endpoint, "sampling_ratio", tracingCfg.SamplingRatio)
+	}
+
 	db, err := database.Connect(ctx, cfg.Database)
 	if err != nil {
 		return fmt.Errorf("connect database: %w", err)
@@ -53,27 +85,78 @@ func runServe(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("ping database: %w", err)
 	}
 
-	if err = db.Validate(ctx); err != nil {
+	// Listen before migrating and populating, so health checks pass while
+	// they run; the gate answers 503 until the server is ready
+	gate := stowryhttp.NewStartupGate()
+	servers, err := stowryhttp.Listen(cfg.Server.EffectiveListeners(), func() *http.Server {
+		return &http.Server{
+			Handler:      gate,
+			ReadTimeout:  30 * time.Second,
+			WriteTimeout: 30 * time.Second,
+			IdleTimeout:  120 * time.Second,
+		}
+	})
+	if err != nil {
+		return err
+	}
+	addrs := servers.URLs()
+	serveErr := make(chan error, 1)
+	go func() { serveErr <- servers.Serve() }()
+	defer func() { _ = servers.Close() }()
+	slog.Info("starting server", "addrs", addrs, "mode", cfg.Server.Mode)
+
+	go func() {
+		sigCh := make(chan os.Signal, 1)
+		signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
+		<-sigCh
+
+		slog.Info("shutting down server...")
+		shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer shutdownCancel()
+
+		if err := servers.Shutdown(shutdownCtx); err != nil {
+			slog.Error("server shutdown error", "err", err)
+		}
+		cancel()
+	}()
+
+	startupDone := make(chan struct{})
+	openEarly := make(chan struct{})
+	if cfg.Server.StartupTimeout > 0 {
+		go watchStartup(ctx, cfg.Server, startupDone, openEarly, cancel)
+	}
+
+	gate.SetTask(stowryhttp.StartupMigrating)
+	applied, err := database.PrepareSchema(ctx, db, cfg.Database.StartupMigrateMode(), cfg.Database.AllowDestructiveMigrations)
+	if pending := (*database.PendingMigrationsError)(nil); errors.As(err, &pending) {
+		fmt.Fprint(cmd.OutOrStdout(), database.RenderSQL(pending.Steps))
+	}
+	if err != nil {
 		return fmt.Errorf("validate database schema: %w", err)
 	}
+	if len(applied) > 0 {
+		slog.Info("applied pending database migrations", "steps", len(applied))
+	}
+	gate.SetTask(stowryhttp.StartupStarting)
 
 	repo := db.GetRepo()
 	slog.Info("connected to database", "type", cfg.Database.Type)
 
-	// 0o700: owner-only access. For Kubernetes deployments with shared access needs,
-	// use fsGroup in securityContext and pre-create the directory with 0o750.
-	err = os.MkdirAll(cfg.Storage.Path, 0o700)
-	if err != nil {
-		return fmt.Errorf("create storage directory: %w", err)
+	var wrapStorage func(stowry.FileStorage) stowry.FileStorage
+	if tracingCfg.Enabled {
+		repo = tracing.WrapRepo(repo)
+		wrapStorage = tracing.WrapStorage
 	}
-
-	root, err := os.OpenRoot(cfg.Storage.Path)
+	storage, closeStorage, err := openStorage(cfg, true, wrapStorage)
 	if err != nil {
-		return fmt.Errorf("open storage root: %w", err)
+		return err
 	}
-	defer func() { _ = root.Close() }()
+	defer closeStorage()
 
-	storage := filesystem.NewFileStorage(root)
+	rebind, _ := cmd.Flags().GetBool("rebind")
+	if err := bindInstance(ctx, cfg, repo, rebind); err != nil {
+		return err
+	}
 
 	mode, err := stowry.ParseServerMode(cfg.Server.Mode)
 	if err != nil {
@@ -83,15 +166,121 @@ func runServe(cmd *cobra.Command, args []string) error {
 	serviceCfg := stowry.ServiceConfig{
 		Mode:           mode,
 		CleanupTimeout: time.Duration(cfg.Service.CleanupTimeout) * time.Second,
+		PathLimits:     cfg.Service.PathLimits,
+		IndexFiles:     cfg.Server.Static.IndexFiles,
+		SPAIndex:       cfg.Server.SPA.Index,
+		WriteIntents:   cfg.Service.WriteIntents,
+		RepoCheckSize:  cfg.Service.RepoCheckSize,
+		ReadVerify: stowry.ReadVerifyConfig{
+			Mode:       stowry.ReadVerifyMode(cfg.Storage.VerifyReads),
+			SampleRate: cfg.Storage.VerifyReadsSampleRate,
+			Quarantine: cfg.Storage.VerifyReadsQuarantine,
+		},
 	}
+
+	var accessTracker *stowry.AccessTracker
+	var trackerDone chan struct{}
+	if cfg.Metrics.TrackAccess {
+		accessTracker = stowry.NewAccessTracker(repo, time.Duration(cfg.Metrics.AccessFlushInterval)*time.Second)
+
+		trackerDone = make(chan struct{})
+		go func() {
+			defer close(trackerDone)
+			accessTracker.Run(ctx, serviceCfg.CleanupTimeout)
+		}()
+		slog.Info("access tracking enabled", "flush_interval_seconds", cfg.Metrics.AccessFlushInterval)
+	}
+
+	var metrics *stowryhttp.Metrics
+	if cfg.Server.Metrics {
+		metrics = stowryhttp.NewMetrics()
+		serviceCfg.Metrics = metrics
+	}
+
+	serviceCfg.ObjectLimiter, err = stowry.NewObjectLimiter(repo, cfg.Service.Limits, time.Duration(cfg.Service.LimitsReconcileInterval)*time.Second)
+	if err != nil {
+		return err
+	}
+	if serviceCfg.ObjectLimiter != nil {
+		go serviceCfg.ObjectLimiter.Run(ctx)
+		slog.Info("object limits enabled", "limits", len(cfg.Service.Limits), "reconcile_interval_seconds", cfg.Service.LimitsReconcileInterval)
+	}
+
+	if serviceCfg.ReadVerify.Mode != stowry.ReadVerifyOff {
+		slog.Info("download verification enabled", "mode", serviceCfg.ReadVerify.Mode,
+			"sample_rate", serviceCfg.ReadVerify.SampleRate, "quarantine", serviceCfg.ReadVerify.Quarantine)
+	}
+
+	serviceCfg.ContentTypes, err = stowry.NewContentTypePolicy(cfg.Service.ContentTypes)
+	if err != nil {
+		return err
+	}
+	if serviceCfg.ContentTypes != nil {
+		slog.Info("content type rules enabled", "rules", len(cfg.Service.ContentTypes))
+	}
+
 	service, err := stowry.NewStowryService(repo, storage, serviceCfg)
 	if err != nil {
 		return fmt.Errorf("create service: %w", err)
 	}
 
-	store, err := keybackend.NewSecretStore(cfg.Auth.Keys)
-	if err != nil {
-		return fmt.Errorf("create secret store: %w", err)
+	if _, ok := repo.(stowry.IntentRepo); ok {
+		if err := recoverIntents(ctx, service, time.Duration(cfg.Service.IntentRecoveryAge)*time.Second); err != nil {
+			return err
+		}
+		if cfg.Service.WriteIntents {
+			slog.Info("write intent log enabled", "recovery_age_seconds", cfg.Service.IntentRecoveryAge)
+		}
+	}
+
+	if cfg.Service.ChangesRetention > 0 {
+		go service.RunChangePruning(ctx, time.Duration(cfg.Service.ChangesRetention)*time.Second)
+	}
+	if cfg.Service.CleanupInterval > 0 {
+		go service.RunCleanup(ctx, stowry.CleanupSchedule{
+			Interval:  time.Duration(cfg.Service.CleanupInterval) * time.Second,
+			Retention: time.Duration(cfg.Service.CleanupRetention) * time.Second,
+			BatchSize: cfg.Service.CleanupBatchSize,
+		})
+		slog.Info("scheduled cleanup enabled", "interval_seconds", cfg.Service.CleanupInterval,
+			"retention_seconds", cfg.Service.CleanupRetention, "batch_size", cfg.Service.CleanupBatchSize)
+	}
+	if cfg.Service.UsageSnapshotInterval > 0 {
+		go service.RunUsageSnapshots(ctx, time.Duration(cfg.Service.UsageSnapshotInterval)*time.Second)
+		slog.Info("usage snapshots enabled", "interval_seconds", cfg.Service.UsageSnapshotInterval)
+	}
+
+	var store stowry.SecretStore
+	if cfg.Auth.Keys.ExtraFile != "" {
+		watcher, err := keybackend.NewKeysWatcher(cfg.Auth.Keys)
+		if err != nil {
+			return fmt.Errorf("create secret store: %w", err)
+		}
+		go watcher.Run(ctx)
+		store = watcher.Store()
+		slog.Info("watching extra keys file", "path", cfg.Auth.Keys.ExtraFile, "interval_seconds", cfg.Auth.Keys.ExtraFileInterval)
+	} else {
+		store, err = keybackend.NewSecretStore(cfg.Auth.Keys)
+		if err != nil {
+			return fmt.Errorf("create secret store: %w", err)
+		}
+	}
+
+	var keyUse *stowry.KeyUseTracker
+	var keyUseDone chan struct{}
+	if keyRepo, ok := repo.(stowry.AccessKeyRepo); ok && cfg.Auth.Keys.TrackUsage {
+		var configured func() []string
+		if lister, ok := store.(interface{ AccessKeys() []string }); ok {
+			configured = lister.AccessKeys
+		}
+		keyUse = stowry.NewKeyUseTracker(keyRepo, configured, time.Duration(cfg.Auth.Keys.UsageFlushInterval)*time.Second)
+
+		keyUseDone = make(chan struct{})
+		go func() {
+			defer close(keyUseDone)
+			keyUse.Run(ctx, serviceCfg.CleanupTimeout)
+		}()
+		slog.Info("access key usage tracking enabled", "flush_interval_seconds", cfg.Auth.Keys.UsageFlushInterval)
 	}
 
 	authCfg := stowry.AuthConfig{
@@ -109,46 +298,304 @@ func runServe(cmd *cobra.Command, args []string) error {
 		}
 	}
 
+	var authenticators []stowryhttp.Authenticator
+	if cfg.Auth.JWT.Enabled {
+		jwt, err := jwtauth.New(cfg.Auth.JWT)
+		if err != nil {
+			return fmt.Errorf("create jwt authenticator: %w", err)
+		}
+		authenticators = append(authenticators, jwt)
+		// Static and SPA sites are public unless people can sign in to them
+		if mode != stowry.ModeStore && cfg.Auth.Read != "public" {
+			readVerifier = verifier
+		}
+		slog.Info("jwt authentication enabled", "issuer", cfg.Auth.JWT.Issuer, "audience", cfg.Auth.JWT.Audience)
+	}
+
 	handlerConfig := stowryhttp.HandlerConfig{
-		Mode:          mode,
-		ReadVerifier:  readVerifier,
-		WriteVerifier: writeVerifier,
-		CORS:          cfg.CORS,
-		MaxUploadSize: cfg.Server.MaxUploadSize,
-		ErrorDocument: cfg.Server.ErrorDocument,
+		Mode:               mode,
+		Version:            version,
+		ReadVerifier:       readVerifier,
+		WriteVerifier:      writeVerifier,
+		PurgeVerifier:      verifier, // Purges are signed even with auth.write public
+		Authenticators:     authenticators,
+		CORS:               cfg.CORS,
+		Compression:        cfg.Compression,
+		SecurityHeaders:    cfg.Server.SecurityHeaders,
+		SPA:                cfg.Server.SPA,
+		SiteFiles:          cfg.Server.SiteFiles,
+		MaxUploadSize:      cfg.Server.MaxUploadSize,
+		ErrorDocument:      cfg.Server.ErrorDocument,
+		ListExportMaxRows:  cfg.Server.ListExportMaxRows,
+		MaxDeletePaths:     cfg.Server.MaxDeletePaths,
+		Tracing:            tracingCfg.Enabled,
+		RedirectsFile:      cfg.Server.RedirectsFile,
+		MaxWatchTimeout:    time.Duration(cfg.Server.MaxWatchTimeout) * time.Second,
+		ContentDisposition: cfg.Server.ContentDisposition,
+		IfMatchComparison:  cfg.Server.IfMatchComparison,
+		Region:             cfg.Server.Region,
+		AccessTracker:      accessTracker,
+	}
+	if keyUse != nil {
+		handlerConfig.AccessKeys = keyUse
+	}
+
+	handlerConfig.Presigner, err = stowryhttp.NewPresigner(cfg.Auth.Presign, store)
+	if err != nil {
+		return err
+	}
+	if handlerConfig.Presigner != nil {
+		if mode != stowry.ModeStore {
+			slog.Warn("presign endpoint is only served in store mode", "mode", mode)
+			handlerConfig.Presigner = nil
+		} else {
+			slog.Info("presign endpoint enabled", "signing_key", cfg.Auth.Presign.SigningKey, "rate_limit", cfg.Auth.Presign.RateLimit)
+		}
 	}
 
-	handler := stowryhttp.NewHandler(&handlerConfig, service)
+	// The stats are only reachable on the expvar listener, so there is no
+	// point recording without one
+	if lc := cfg.Metrics.Latency; lc.Enabled && cfg.Server.ExpvarAddr != "" {
+		handlerConfig.Latency = stowryhttp.NewLatencyTracker(
+			time.Duration(lc.Window)*time.Second,
+			lc.Windows,
+			time.Duration(lc.SLOP99)*time.Millisecond,
+		)
+		slog.Info("latency tracking enabled", "window_seconds", lc.Window, "windows", lc.Windows, "slo_p99_ms", lc.SLOP99)
+	}
 
-	addr := fmt.Sprintf(":%d", cfg.Server.Port)
+	if metrics != nil {
+		handlerConfig.Metrics = metrics
+		if cfg.Server.MetricsPort == 0 {
+			handlerConfig.MetricsPath = cfg.Server.MetricsPath
+			slog.Info("metrics enabled", "path", cfg.Server.MetricsPath)
+		}
+	}
 
-	server := &http.Server{
-		Addr:         addr,
-		Handler:      handler.Router(),
-		ReadTimeout:  30 * time.Second,
-		WriteTimeout: 30 * time.Second,
-		IdleTimeout:  120 * time.Second,
+	if cfg.Server.BackupEndpoint {
+		backuper, ok := db.(database.Backuper)
+		if !ok {
+			return fmt.Errorf("backup endpoint: %s databases cannot be backed up online; use pg_dump instead", cfg.Database.Type)
+		}
+		if mode != stowry.ModeStore {
+			slog.Warn("backup endpoint is only served in store mode", "mode", mode)
+		} else {
+			handlerConfig.Backup = backuper
+			slog.Info("backup endpoint enabled")
+		}
 	}
 
-	go func() {
-		sigCh := make(chan os.Signal, 1)
-		signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
-		<-sigCh
+	if cfg.Server.SupportBundleEndpoint {
+		if mode != stowry.ModeStore {
+			slog.Warn("support bundle endpoint is only served in store mode", "mode", mode)
+		} else {
+			handlerConfig.SupportBundle = &supportBundler{cfg: cfg, db: db, errorLog: errorLog}
+			slog.Info("support bundle endpoint enabled")
+		}
+	}
 
-		slog.Info("shutting down server...")
-		shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), 30*time.Second)
-		defer shutdownCancel()
+	if cfg.Server.DisableList {
+		if mode != stowry.ModeStore {
+			slog.Warn("server.disable_list only applies to store mode", "mode", mode)
+		} else {
+			handlerConfig.DisableList = true
+			slog.Info("object listing disabled")
+		}
+	}
 
-		if err := server.Shutdown(shutdownCtx); err != nil {
-			slog.Error("server shutdown error", "err", err)
+	if cfg.Server.AdminJobs {
+		if mode != stowry.ModeStore {
+			slog.Warn("admin jobs are only served in store mode", "mode", mode)
+		} else {
+			handlerConfig.Maintenance = service
+			handlerConfig.Jobs = stowryhttp.NewJobManager(ctx)
+			slog.Info("admin jobs enabled")
+		}
+	}
+
+	if len(cfg.Server.Redirects) > 0 {
+		handlerConfig.Redirects, err = stowryhttp.NewRedirects(cfg.Server.Redirects)
+		if err != nil {
+			return err
+		}
+	}
+
+	concurrencyWait := time.Duration(cfg.Server.ConcurrencyWaitTimeout) * time.Second
+	handlerConfig.WriteLimiter = stowryhttp.NewConcurrencyLimiter("write", int64(cfg.Server.MaxConcurrentWrites), concurrencyWait)
+	handlerConfig.ReadLimiter = stowryhttp.NewConcurrencyLimiter("read", int64(cfg.Server.MaxConcurrentReads), concurrencyWait)
+
+	if cfg.Server.Precompressed {
+		if mode == stowry.ModeStore {
+			slog.Warn("server.precompressed only applies to static and spa modes", "mode", mode)
+		} else {
+			handlerConfig.Precompressed = true
+			slog.Info("pre-compressed assets enabled")
+		}
+	}
+
+	if len(cfg.Server.CacheRules) > 0 {
+		handlerConfig.CacheRules, err = stowryhttp.NewCacheRules(cfg.Server.CacheRules)
+		if err != nil {
+			return err
 		}
+	}
+	if cfg.Server.ImmutableAssets {
+		handlerConfig.CachePolicy, err = stowryhttp.NewCachePolicy(cfg.Server.ImmutablePattern)
+		if err != nil {
+			return err
+		}
+	}
+
+	var httpService stowryhttp.Service = service
+	if tracingCfg.Enabled {
+		httpService = tracing.WrapService(service)
+	}
+	var handlerOpts []stowryhttp.Option
+	if cfg.Auth.JWT.Enabled {
+		handlerOpts = append(handlerOpts, stowryhttp.WithFeatures("jwt"))
+	}
+	handler, err := stowryhttp.New(&handlerConfig, httpService, handlerOpts...)
+	if err != nil {
+		return err
+	}
+
+	if cfg.Service.PopulateOnStart {
+		if err := populateOnStart(ctx, service, gate, openEarly); err != nil {
+			return err
+		}
+	}
+	close(startupDone)
+	gate.Open(handler.Router())
+	slog.Info("server ready", "addrs", addrs, "mode", mode)
+
+	if cfg.Server.ExpvarAddr != "" {
+		go serveExpvar(ctx, cfg.Server.ExpvarAddr, handlerConfig.Latency)
+	}
+	if metrics != nil && cfg.Server.MetricsPort != 0 {
+		addr := net.JoinHostPort(cfg.Server.Host, strconv.Itoa(cfg.Server.MetricsPort))
+		go serveMetrics(ctx, addr, cfg.Server.MetricsPath, metrics)
+	}
+
+	if err := <-serveErr; err != nil && err != http.ErrServerClosed {
+		return fmt.Errorf("server error: %w", err)
+	}
+
+	// Wait for the final access flushes before the database is closed
+	cancel()
+	if trackerDone != nil {
+		<-trackerDone
+	}
+	if keyUseDone != nil {
+		<-keyUseDone
+	}
+
+	return nil
+}
+
+// watchStartup applies server.startup_timeout: unless startupDone is closed
+// first, it either closes openEarly, so populate finishes in the background,
+// or cancels startup through cancel.
+func watchStartup(ctx context.Context, cfg config.ServerConfig, startupDone <-chan struct{}, openEarly chan<- struct{}, cancel context.CancelFunc) {
+	timer := time.NewTimer(time.Duration(cfg.StartupTimeout) * time.Second)
+	defer timer.Stop()
+
+	select {
+	case <-startupDone:
+	case <-ctx.Done():
+	case <-timer.C:
+		if cfg.StartupTimeoutAction == "open" {
+			slog.Warn("startup tasks still running after the startup timeout; serving requests once migrations are done",
+				"timeout_seconds", cfg.StartupTimeout)
+			close(openEarly)
+			return
+		}
+		slog.Error("startup tasks did not finish within the startup timeout; stopping", "timeout_seconds", cfg.StartupTimeout)
 		cancel()
+	}
+}
+
+// populateOnStart records every storage file in the metadata, reporting its
+// progress through gate. It returns early, leaving populate running, when
+// openEarly is closed.
+func populateOnStart(ctx context.Context, service *stowry.StowryService, gate *stowryhttp.StartupGate, openEarly <-chan struct{}) error {
+	gate.SetTask(stowryhttp.StartupPopulating)
+	logProgress := progress.Logger(slog.Default(), progress.DefaultLogInterval)
+	populated := make(chan error, 1)
+	go func() {
+		populated <- service.PopulateWithOptions(ctx, stowry.PopulateOptions{Progress: func(e progress.Event) {
+			gate.Progress(e)
+			logProgress(e)
+		}})
 	}()
 
-	slog.Info("starting server", "addr", addr, "mode", mode)
-	if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-		return fmt.Errorf("server error: %w", err)
+	select {
+	case err := <-populated:
+		if err != nil {
+			return fmt.Errorf("populate on start: %w", err)
+		}
+		slog.Info("populated metadata from storage")
+	case <-openEarly:
+		go func() {
+			if err := <-populated; err != nil {
+				slog.Error("populate on start", "err", err)
+				return
+			}
+			slog.Info("populated metadata from storage")
+		}()
 	}
+	return nil
+}
 
+// recoverIntents reconciles the uploads an earlier run left between storage
+// and metadata, before any request can write the same paths.
+func recoverIntents(ctx context.Context, service *stowry.StowryService, minAge time.Duration) error {
+	report, err := service.RecoverIntents(ctx, minAge)
+	if err != nil {
+		return fmt.Errorf("recover write intents: %w", err)
+	}
+	if report.Scanned > 0 {
+		slog.Warn("recovered interrupted uploads", "intents", report.Scanned, "not_written", report.NotWritten,
+			"committed", report.Committed, "completed", report.Completed, "removed", report.Removed)
+	}
 	return nil
 }
+
+// serveExpvar exposes /debug/vars, and /stats/latency when latency is
+// non-nil, on its own listener so runtime counters never share the public
+// router. It stops when ctx is cancelled.
+func serveExpvar(ctx context.Context, addr string, latency *stowryhttp.LatencyTracker) {
+	mux := http.NewServeMux()
+	mux.Handle("/debug/vars", expvar.Handler())
+	if latency != nil {
+		mux.Handle("GET /stats/latency", latency)
+	}
+
+	server := &http.Server{Addr: addr, Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		<-ctx.Done()
+		_ = server.Close()
+	}()
+
+	slog.Info("starting expvar server", "addr", addr)
+	if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		slog.Error("expvar server error", "err", err)
+	}
+}
+
+// serveMetrics serves metrics at path on a listener of its own, so scrapes do
+// not share the public listeners.
+func serveMetrics(ctx context.Context, addr, path string, metrics *stowryhttp.Metrics) {
+	mux := http.NewServeMux()
+	mux.Handle("GET "+path, metrics)
+
+	server := &http.Server{Addr: addr, Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		<-ctx.Done()
+		_ = server.Close()
+	}()
+
+	slog.Info("starting metrics server", "addr", addr, "path", path)
+	if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		slog.Error("metrics server error", "err", err)
+	}
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+
+	"github.com/sagarc03/stowry"
+	"github.com/sagarc03/stowry/config"
+	"github.com/sagarc03/stowry/filesystem"
+)
+
+// openStorage opens the storage directory and every storage route in cfg.
+// With create set, missing directories are created; otherwise they must
+// exist. wrap, when not nil, decorates each backend before routing. The
+// returned function closes the opened directories.
+func openStorage(cfg *config.Config, create bool, wrap func(stowry.FileStorage) stowry.FileStorage) (stowry.FileStorage, func(), error) {
+	var roots []*os.Root
+	closeAll := func() {
+		for _, root := range roots {
+			_ = root.Close()
+		}
+	}
+
+	open := func(path, tempDir string) (stowry.FileStorage, error) {
+		root, err := openStorageRoot(path, create)
+		if err != nil {
+			return nil, err
+		}
+		roots = append(roots, root)
+
+		opts, err := fileStorageOptions(tempDir)
+		if err != nil {
+			return nil, err
+		}
+		var storage stowry.FileStorage = filesystem.NewFileStorage(root, opts...)
+		if wrap != nil {
+			storage = wrap(storage)
+		}
+		return storage, nil
+	}
+
+	storage, err := open(cfg.Storage.Path, cfg.Storage.TempDir)
+	if err != nil {
+		closeAll()
+		return nil, nil, err
+	}
+	if len(cfg.Storage.Routes) == 0 {
+		return storage, closeAll, nil
+	}
+
+	routes := make([]stowry.StorageRoute, 0, len(cfg.Storage.Routes))
+	for _, route := range cfg.Storage.Routes {
+		routeStorage, err := open(route.Path, route.TempDir)
+		if err != nil {
+			closeAll()
+			return nil, nil, fmt.Errorf("storage route %s: %w", route.Prefix, err)
+		}
+		routes = append(routes, stowry.StorageRoute{Prefix: route.Prefix, Storage: routeStorage})
+		slog.Info("storage route", "prefix", route.Prefix, "path", route.Path)
+	}
+
+	routed, err := stowry.NewRoutedStorage(storage, routes)
+	if err != nil {
+		closeAll()
+		return nil, nil, err
+	}
+	return routed, closeAll, nil
+}
+
+// openStorageRoot opens the directory at path, creating it first when
+// create is set.
+func openStorageRoot(path string, create bool) (*os.Root, error) {
+	if create {
+		// 0o700: owner-only access. For Kubernetes deployments with shared access needs,
+		// use fsGroup in securityContext and pre-create the directory with 0o750.
+		if err := os.MkdirAll(path, 0o700); err != nil {
+			return nil, fmt.Errorf("create storage directory: %w", err)
+		}
+	} else if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("storage directory does not exist: %s", path)
+	}
+
+	root, err := os.OpenRoot(path)
+	if err != nil {
+		return nil, fmt.Errorf("open storage root: %w", err)
+	}
+	return root, nil
+}
+
+// fileStorageOptions builds filesystem options for a storage directory,
+// creating the upload temp directory when one is configured.
+func fileStorageOptions(tempDir string) ([]filesystem.Option, error) {
+	if tempDir == "" {
+		return nil, nil
+	}
+	if err := os.MkdirAll(tempDir, 0o700); err != nil {
+		return nil, fmt.Errorf("create storage temp directory: %w", err)
+	}
+	return []filesystem.Option{filesystem.WithTempDir(tempDir)}, nil
+}
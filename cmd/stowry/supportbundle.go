@@ -0,0 +1,294 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"runtime"
+	"runtime/debug"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/sagarc03/stowry"
+	"github.com/sagarc03/stowry/config"
+	"github.com/sagarc03/stowry/database"
+	"github.com/sagarc03/stowry/filesystem"
+	stowryhttp "github.com/sagarc03/stowry/http"
+	"github.com/sagarc03/stowry/supportbundle"
+)
+
+var supportBundleCmd = &cobra.Command{
+	Use:   "support-bundle",
+	Short: "Write a support bundle to attach to bug reports",
+	Long: `Write a support bundle: a gzipped tar of JSON files describing this
+deployment, to attach to bug reports.
+
+The bundle holds the effective configuration and where each setting came
+from, version and build information, schema validation, database and
+storage health, disk usage, object counts and the security lint findings.
+Access keys, secret keys, presign keys and the database password are
+redacted everywhere in the bundle.
+
+stowry-support/manifest.json records the bundle format version and every
+section, with the reason a section was skipped or the error collecting it.
+A section that fails does not fail the bundle.
+
+The capabilities document and the server's recent errors are only known to
+a running server; with server.support_bundle_endpoint enabled, it serves a
+bundle including them at GET /admin/support-bundle.`,
+	Example: `  stowry support-bundle --output bundle.tar.gz`,
+	RunE:    runSupportBundle,
+}
+
+var supportBundleOutput string
+
+func init() {
+	supportBundleCmd.Flags().StringVarP(&supportBundleOutput, "output", "o", "", "bundle file to write; must not exist")
+	_ = supportBundleCmd.MarkFlagRequired("output")
+	rootCmd.AddCommand(supportBundleCmd)
+}
+
+func runSupportBundle(cmd *cobra.Command, args []string) error {
+	cfg, err := config.FromContext(cmd.Context())
+	if err != nil {
+		return err
+	}
+
+	ctx := cmd.Context()
+
+	// A bundle is most needed when the database is unreachable, so a
+	// failed connection is reported in the bundle instead
+	bundler := &supportBundler{cfg: cfg}
+	bundler.db, bundler.dbErr = database.Connect(ctx, cfg.Database)
+	if bundler.dbErr == nil {
+		defer func() { _ = bundler.db.Close() }()
+	}
+
+	f, err := os.OpenFile(supportBundleOutput, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+	if err != nil {
+		return fmt.Errorf("support bundle: %w", err)
+	}
+	if err := bundler.write(ctx, f, nil); err != nil {
+		_ = f.Close()
+		_ = os.Remove(supportBundleOutput)
+		return err
+	}
+	if err := f.Close(); err != nil {
+		_ = os.Remove(supportBundleOutput)
+		return fmt.Errorf("support bundle: %w", err)
+	}
+
+	slog.Info("support bundle written", "output", supportBundleOutput)
+	return nil
+}
+
+// supportBundler collects support bundles for the support-bundle command
+// and, through WriteSupportBundle, for GET /admin/support-bundle.
+type supportBundler struct {
+	cfg   *config.Config
+	db    database.Database
+	dbErr error // Why db is nil
+	// errorLog holds the server's recent errors; nil outside the server
+	errorLog *supportbundle.ErrorLog
+}
+
+// WriteSupportBundle implements stowryhttp.SupportBundler.
+func (b *supportBundler) WriteSupportBundle(ctx context.Context, w io.Writer, capabilities stowryhttp.Capabilities) error {
+	return b.write(ctx, w, &capabilities)
+}
+
+// write writes a bundle to w. capabilities is nil outside the server.
+func (b *supportBundler) write(ctx context.Context, w io.Writer, capabilities *stowryhttp.Capabilities) error {
+	sections := []supportbundle.Section{
+		{Name: "version", Collect: b.collectVersion},
+		{Name: "config", Collect: b.collectConfig},
+		{Name: "schema", Collect: b.collectSchema},
+		{Name: "health", Collect: b.collectHealth},
+		{Name: "disk", Collect: b.collectDisk},
+		{Name: "counts", Collect: b.collectCounts},
+		{Name: "capabilities", Collect: func(context.Context) (any, error) {
+			if capabilities == nil {
+				return nil, supportbundle.Skip("known to a running server only; fetch GET /admin/support-bundle")
+			}
+			return capabilities, nil
+		}},
+		{Name: "errors", Collect: func(context.Context) (any, error) {
+			if b.errorLog == nil {
+				return nil, supportbundle.Skip("kept by a running server only; fetch GET /admin/support-bundle")
+			}
+			return b.errorLog.Entries(), nil
+		}},
+		{Name: "logs", Collect: func(context.Context) (any, error) {
+			return nil, supportbundle.Skip("stowry logs to stdout only; attach the output of the service manager, such as journalctl -u stowry")
+		}},
+	}
+	return supportbundle.Write(ctx, w, supportbundle.NewRedactor(b.cfg.Secrets()...), sections)
+}
+
+func (b *supportBundler) metadataDB() (database.Database, error) {
+	if b.db == nil {
+		return nil, fmt.Errorf("connect database: %w", b.dbErr)
+	}
+	return b.db, nil
+}
+
+func (b *supportBundler) collectVersion(context.Context) (any, error) {
+	info := map[string]any{
+		"version":    version,
+		"go_version": runtime.Version(),
+		"os":         runtime.GOOS,
+		"arch":       runtime.GOARCH,
+	}
+	if build, ok := debug.ReadBuildInfo(); ok {
+		settings := make(map[string]string)
+		for _, s := range build.Settings {
+			settings[s.Key] = s.Value
+		}
+		info["module_version"] = build.Main.Version
+		info["build_settings"] = settings
+	}
+	return info, nil
+}
+
+func (b *supportBundler) collectConfig(context.Context) (any, error) {
+	return map[string]any{
+		"values":            b.cfg.Redacted(),
+		"sources":           b.cfg.Sources(),
+		"security_findings": b.cfg.LintSecurity(),
+	}, nil
+}
+
+func (b *supportBundler) collectSchema(ctx context.Context) (any, error) {
+	db, err := b.metadataDB()
+	if err != nil {
+		return nil, err
+	}
+
+	err = db.Validate(ctx)
+	var diff *database.SchemaDiff
+	switch {
+	case err == nil:
+		return map[string]any{"valid": true}, nil
+	case !errors.As(err, &diff):
+		return nil, err
+	}
+
+	schema := map[string]any{"valid": false, "differences": err.Error()}
+	if steps, err := db.Plan(ctx); err != nil {
+		schema["plan_error"] = err.Error()
+	} else {
+		schema["plan"] = database.RenderSQL(steps)
+	}
+	return schema, nil
+}
+
+// healthCheck is the result of one health check.
+type healthCheck struct {
+	Name      string `json:"name"`
+	OK        bool   `json:"ok"`
+	LatencyMS int64  `json:"latency_ms"`
+	Error     string `json:"error,omitempty"`
+}
+
+func (b *supportBundler) collectHealth(ctx context.Context) (any, error) {
+	check := func(name string, fn func() error) healthCheck {
+		start := time.Now()
+		err := fn()
+		result := healthCheck{Name: name, OK: err == nil, LatencyMS: time.Since(start).Milliseconds()}
+		if err != nil {
+			result.Error = err.Error()
+		}
+		return result
+	}
+
+	checks := []healthCheck{check("database", func() error {
+		db, err := b.metadataDB()
+		if err != nil {
+			return err
+		}
+		ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+		defer cancel()
+		return db.Ping(ctx)
+	})}
+	for _, dir := range b.storageDirs() {
+		checks = append(checks, check("storage "+dir.Path, func() error {
+			f, err := os.Open(dir.Path)
+			if err != nil {
+				return err
+			}
+			defer func() { _ = f.Close() }()
+			if _, err := f.Readdirnames(1); err != nil && !errors.Is(err, io.EOF) {
+				return err
+			}
+			return nil
+		}))
+	}
+	return checks, nil
+}
+
+// storageDir is a directory stowry stores or stages objects in.
+type storageDir struct {
+	Path string `json:"path"`
+	Role string `json:"role"` // "storage", "temp" or "route <prefix>"
+}
+
+func (b *supportBundler) storageDirs() []storageDir {
+	storage := b.cfg.Storage
+	dirs := []storageDir{{Path: storage.Path, Role: "storage"}}
+	if storage.TempDir != "" {
+		dirs = append(dirs, storageDir{Path: storage.TempDir, Role: "temp"})
+	}
+	for _, route := range storage.Routes {
+		dirs = append(dirs, storageDir{Path: route.Path, Role: "route " + route.Prefix})
+		if route.TempDir != "" {
+			dirs = append(dirs, storageDir{Path: route.TempDir, Role: "temp " + route.Prefix})
+		}
+	}
+	return dirs
+}
+
+func (b *supportBundler) collectDisk(context.Context) (any, error) {
+	type diskUsage struct {
+		storageDir
+		*filesystem.DiskUsage
+		Error string `json:"error,omitempty"`
+	}
+
+	var usage []diskUsage
+	for _, dir := range b.storageDirs() {
+		entry := diskUsage{storageDir: dir}
+		if u, ok := filesystem.StatDisk(dir.Path); ok {
+			entry.DiskUsage = &u
+		} else {
+			entry.Error = "disk usage unknown: the directory is missing or the platform does not report it"
+		}
+		usage = append(usage, entry)
+	}
+	return usage, nil
+}
+
+func (b *supportBundler) collectCounts(ctx context.Context) (any, error) {
+	db, err := b.metadataDB()
+	if err != nil {
+		return nil, err
+	}
+
+	repo := db.GetRepo()
+	active, err := repo.CountPrefix(ctx, "")
+	if err != nil {
+		return nil, fmt.Errorf("count objects: %w", err)
+	}
+	counts := map[string]any{"active_objects": active}
+	if usageRepo, ok := repo.(stowry.BackendUsageRepo); ok {
+		if backends, err := usageRepo.BackendUsage(ctx); err != nil {
+			counts["backends_error"] = err.Error()
+		} else {
+			counts["backends"] = backends
+		}
+	}
+	return counts, nil
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/sagarc03/stowry"
+	"github.com/sagarc03/stowry/config"
+	"github.com/sagarc03/stowry/database"
+)
+
+var usageCmd = &cobra.Command{
+	Use:   "usage",
+	Short: "Record and report per-prefix usage",
+	Long: `Record snapshots of the objects and bytes under each top-level prefix,
+and report what each prefix added and deleted between two snapshots, for
+chargeback.
+
+The server takes snapshots every service.usage_snapshot_interval seconds
+when it is set; use "usage snapshot" to take one by hand, for example from
+cron.`,
+}
+
+var usageSnapshotCmd = &cobra.Command{
+	Use:   "snapshot",
+	Short: "Take a usage snapshot now",
+	RunE:  runUsageSnapshot,
+}
+
+var usageReportCmd = &cobra.Command{
+	Use:   "report",
+	Short: "Report per-prefix usage over a period",
+	Long: `Compare the latest snapshots taken at or before --from and --to, and
+print one row per prefix: its objects and bytes at the end of the period,
+and the objects and bytes added and deleted during it.
+
+Added counts come from the change feed, so a snapshot that ran after
+changes were pruned misses some; the report says so when it is incomplete.
+Keep service.changes_retention longer than the snapshot interval.`,
+	Example: `  stowry usage report --from 2026-09-01T00:00:00Z --to 2026-10-01T00:00:00Z
+  stowry usage report --from 2026-09-01T00:00:00Z --output json`,
+	RunE: runUsageReport,
+}
+
+var (
+	usageReportFrom   string
+	usageReportTo     string
+	usageReportOutput string
+)
+
+func init() {
+	usageReportCmd.Flags().StringVar(&usageReportFrom, "from", "", "start of the period, RFC 3339 (required)")
+	usageReportCmd.Flags().StringVar(&usageReportTo, "to", "", "end of the period, RFC 3339 (default now)")
+	usageReportCmd.Flags().StringVar(&usageReportOutput, "output", "csv", "output format: csv or json")
+	_ = usageReportCmd.MarkFlagRequired("from")
+	usageCmd.AddCommand(usageSnapshotCmd)
+	usageCmd.AddCommand(usageReportCmd)
+	rootCmd.AddCommand(usageCmd)
+}
+
+// openUsageService connects to the database and returns a service over it.
+// Usage only reads metadata, so no storage is opened.
+func openUsageService(cmd *cobra.Command) (*stowry.StowryService, func(), error) {
+	cfg, err := config.FromContext(cmd.Context())
+	if err != nil {
+		return nil, nil, err
+	}
+
+	ctx := cmd.Context()
+
+	db, err := database.Connect(ctx, cfg.Database)
+	if err != nil {
+		return nil, nil, fmt.Errorf("connect database: %w", err)
+	}
+
+	if err = db.Ping(ctx); err != nil {
+		_ = db.Close()
+		return nil, nil, fmt.Errorf("ping database: %w", err)
+	}
+
+	if err = db.Validate(ctx); err != nil {
+		_ = db.Close()
+		return nil, nil, fmt.Errorf("validate database schema: %w", err)
+	}
+
+	service, err := stowry.NewStowryService(db.GetRepo(), nil, stowry.ServiceConfig{Mode: stowry.ModeStore})
+	if err != nil {
+		_ = db.Close()
+		return nil, nil, fmt.Errorf("create service: %w", err)
+	}
+	return service, func() { _ = db.Close() }, nil
+}
+
+func runUsageSnapshot(cmd *cobra.Command, args []string) error {
+	service, closeDB, err := openUsageService(cmd)
+	if err != nil {
+		return err
+	}
+	defer closeDB()
+
+	snapshot, err := service.TakeUsageSnapshot(cmd.Context())
+	if err != nil {
+		return err
+	}
+	if !snapshot.Complete {
+		slog.Warn("usage snapshot missed pruned changes; its added counts are too low", "snapshot", snapshot.ID)
+	}
+	slog.Info("usage snapshot taken", "snapshot", snapshot.ID, "taken_at", snapshot.TakenAt, "prefixes", len(snapshot.Prefixes))
+	return nil
+}
+
+func runUsageReport(cmd *cobra.Command, args []string) error {
+	if usageReportOutput != "csv" && usageReportOutput != "json" {
+		return fmt.Errorf("--output must be csv or json, got %q", usageReportOutput)
+	}
+	from, err := time.Parse(time.RFC3339, usageReportFrom)
+	if err != nil {
+		return fmt.Errorf("--from: %w", err)
+	}
+	to := time.Now()
+	if usageReportTo != "" {
+		if to, err = time.Parse(time.RFC3339, usageReportTo); err != nil {
+			return fmt.Errorf("--to: %w", err)
+		}
+	}
+
+	service, closeDB, err := openUsageService(cmd)
+	if err != nil {
+		return err
+	}
+	defer closeDB()
+
+	report, err := service.UsageReport(cmd.Context(), from, to)
+	if err != nil {
+		return err
+	}
+	if !report.Complete {
+		slog.Warn("usage report is incomplete: a snapshot in the period missed pruned changes")
+	}
+
+	out := cmd.OutOrStdout()
+	if usageReportOutput == "json" {
+		enc := json.NewEncoder(out)
+		enc.SetIndent("", "  ")
+		return enc.Encode(report)
+	}
+	return report.WriteCSV(out)
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"github.com/spf13/cobra"
+
+	"github.com/sagarc03/stowry"
+	"github.com/sagarc03/stowry/config"
+	"github.com/sagarc03/stowry/database"
+)
+
+var verifyCmd = &cobra.Command{
+	Use:   "verify",
+	Short: "Check stored files against their metadata",
+	Long: `Check that every object has its file in storage, of the recorded size.
+
+With --deep, the SHA-256 of every file is compared with the object's ETag as
+well, the same comparison storage.verify_reads makes on downloads. This reads
+every file in full.
+
+With --quarantine, mismatched objects are soft-deleted and flagged as
+quarantined: they are no longer served, and cleanup keeps their files for
+inspection. Uploading to the path again clears the flag.
+
+Nothing else is changed. The command fails if any object is missing,
+mismatched or could not be read.`,
+	RunE: runVerify,
+}
+
+var (
+	verifyPrefix     string
+	verifyDeep       bool
+	verifyQuarantine bool
+)
+
+func init() {
+	verifyCmd.Flags().StringVar(&verifyPrefix, "prefix", "", "only check objects whose path starts with this prefix")
+	verifyCmd.Flags().BoolVar(&verifyDeep, "deep", false, "compare the SHA-256 of each file with the object's ETag")
+	verifyCmd.Flags().BoolVar(&verifyQuarantine, "quarantine", false, "quarantine objects whose file does not match")
+	rootCmd.AddCommand(verifyCmd)
+}
+
+func runVerify(cmd *cobra.Command, args []string) error {
+	cfg, err := config.FromContext(cmd.Context())
+	if err != nil {
+		return err
+	}
+
+	ctx := cmd.Context()
+
+	db, err := database.Connect(ctx, cfg.Database)
+	if err != nil {
+		return fmt.Errorf("connect database: %w", err)
+	}
+	defer func() { _ = db.Close() }()
+
+	if err = db.Ping(ctx); err != nil {
+		return fmt.Errorf("ping database: %w", err)
+	}
+
+	// Verify expects tables to already exist - just validate
+	if err = db.Validate(ctx); err != nil {
+		return fmt.Errorf("validate database schema: %w", err)
+	}
+
+	repo := db.GetRepo()
+
+	storage, closeStorage, err := openStorage(cfg, false, nil)
+	if err != nil {
+		return err
+	}
+	defer closeStorage()
+
+	if err := bindInstance(ctx, cfg, repo, false); err != nil {
+		return err
+	}
+
+	service, err := stowry.NewStowryService(repo, storage, stowry.ServiceConfig{Mode: stowry.ModeStore})
+	if err != nil {
+		return fmt.Errorf("create service: %w", err)
+	}
+
+	slog.Info("starting verify", "prefix", verifyPrefix, "deep", verifyDeep, "quarantine", verifyQuarantine)
+
+	report, err := service.Verify(ctx, stowry.VerifyOptions{
+		Query:      stowry.ListQuery{PathPrefix: verifyPrefix},
+		VerifyHash: verifyDeep,
+		Quarantine: verifyQuarantine,
+		Progress:   progressReporter(),
+	})
+	if err != nil {
+		return fmt.Errorf("verify: %w", err)
+	}
+
+	for _, path := range report.Missing {
+		slog.Error("file missing", "path", path)
+	}
+	for _, path := range report.Mismatched {
+		slog.Error("file does not match", "path", path)
+	}
+	for _, verr := range report.Errors {
+		slog.Error("file could not be checked", "path", verr.Path, "error", verr.Err)
+	}
+
+	slog.Info("verify complete",
+		"checked", report.Checked,
+		"bytes", report.Bytes,
+		"missing", len(report.Missing),
+		"mismatched", len(report.Mismatched),
+		"quarantined", len(report.Quarantined),
+		"errors", len(report.Errors),
+	)
+	if !report.OK() {
+		return errors.New("verify found problems")
+	}
+	return nil
+}
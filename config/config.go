@@ -5,6 +5,10 @@ import (
 	"errors"
 	"fmt"
 	"log/slog"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
 	"strings"
 
 	"github.com/go-playground/validator/v10"
@@ -14,7 +18,9 @@ import (
 	"github.com/sagarc03/stowry"
 	"github.com/sagarc03/stowry/database"
 	stowryhttp "github.com/sagarc03/stowry/http"
+	"github.com/sagarc03/stowry/http/jwtauth"
 	"github.com/sagarc03/stowry/keybackend"
+	"github.com/sagarc03/stowry/tracing"
 )
 
 // configKey is the context key for storing the loaded configuration.
@@ -37,31 +43,231 @@ func FromContext(ctx context.Context) (*Config, error) {
 
 // Config is the root configuration struct for stowry.
 type Config struct {
-	Server   ServerConfig          `mapstructure:"server"`
-	Service  ServiceConfig         `mapstructure:"service"`
-	Database database.Config       `mapstructure:"database"`
-	Storage  StorageConfig         `mapstructure:"storage"`
-	Auth     AuthConfig            `mapstructure:"auth"`
-	CORS     stowryhttp.CORSConfig `mapstructure:"cors"`
-	Log      LogConfig             `mapstructure:"log"`
+	Server        ServerConfig                 `mapstructure:"server"`
+	Service       ServiceConfig                `mapstructure:"service"`
+	Database      database.Config              `mapstructure:"database"`
+	Storage       StorageConfig                `mapstructure:"storage"`
+	Auth          AuthConfig                   `mapstructure:"auth"`
+	CORS          stowryhttp.CORSConfig        `mapstructure:"cors"`
+	Compression   stowryhttp.CompressionConfig `mapstructure:"compression"`
+	Metrics       MetricsConfig                `mapstructure:"metrics"`
+	Observability ObservabilityConfig          `mapstructure:"observability"`
+	Security      SecurityConfig               `mapstructure:"security"`
+	Log           LogConfig                    `mapstructure:"log"`
+
+	sources map[string]Source // Set by LoadWithOptions, see Sources
 }
 
 // ServerConfig holds HTTP server configuration.
 type ServerConfig struct {
-	Port          int    `mapstructure:"port" validate:"required,min=1,max=65535"`
-	Mode          string `mapstructure:"mode" validate:"required,oneof=store static spa"`
-	MaxUploadSize int64  `mapstructure:"max_upload_size" validate:"min=0"`
-	ErrorDocument string `mapstructure:"error_document"`
+	Host string `mapstructure:"host"` // Listen address. Empty listens on all interfaces.
+	Port int    `mapstructure:"port" validate:"required,min=1,max=65535"`
+	// Listeners are the addresses the server listens on at once, all serving
+	// the same handler. When set, Host and Port are ignored.
+	Listeners     []stowryhttp.ListenerConfig `mapstructure:"listeners"`
+	Mode          string                      `mapstructure:"mode" validate:"required,oneof=store static spa"`
+	MaxUploadSize int64                       `mapstructure:"max_upload_size" validate:"min=0"`
+	ErrorDocument string                      `mapstructure:"error_document"`
+	// ImmutableAssets caches content-hashed filenames for a year in static and
+	// SPA modes; other files are served with Cache-Control: no-cache.
+	ImmutableAssets  bool   `mapstructure:"immutable_assets"`
+	ImmutablePattern string `mapstructure:"immutable_pattern"` // Regex for hashed filenames. Empty uses the default.
+	// CacheRules set Cache-Control by path glob in every mode; the first
+	// match wins. They take precedence over ImmutableAssets, and a
+	// Cache-Control uploaded with an object over them.
+	CacheRules []stowryhttp.CacheRule `mapstructure:"cache_rules"`
+	// Precompressed serves a stored foo.js.br or foo.js.gz in place of foo.js
+	// to clients accepting that coding, in static and SPA modes.
+	Precompressed bool `mapstructure:"precompressed"`
+	// ListExportMaxRows caps the rows of a CSV list export. 0 means no cap.
+	ListExportMaxRows int `mapstructure:"list_export_max_rows" validate:"min=0"`
+	// MaxDeletePaths caps the paths of a POST /?delete bulk delete.
+	MaxDeletePaths int `mapstructure:"max_delete_paths" validate:"min=1"`
+	// DisableList turns off GET / listing in store mode: it answers 403
+	// list_disabled to every client, authenticated or not.
+	DisableList bool `mapstructure:"disable_list"`
+	// MaxConcurrentWrites caps in-flight uploads. Requests beyond the cap wait
+	// up to ConcurrencyWaitTimeout seconds, then get 503. 0 means no cap.
+	MaxConcurrentWrites int `mapstructure:"max_concurrent_writes" validate:"min=0"`
+	// MaxConcurrentReads caps in-flight GET/HEAD requests. 0 means no cap.
+	MaxConcurrentReads     int `mapstructure:"max_concurrent_reads" validate:"min=0"`
+	ConcurrencyWaitTimeout int `mapstructure:"concurrency_wait_timeout" validate:"min=0"` // seconds
+	// ExpvarAddr serves /debug/vars (including concurrency counters) on a
+	// separate listener. Empty disables it.
+	ExpvarAddr string `mapstructure:"expvar_addr"`
+	// Metrics serves Prometheus metrics at MetricsPath: request counts,
+	// durations and in-flight requests, authentication failures, bytes
+	// transferred, storage call durations and cleanup outcomes.
+	Metrics     bool   `mapstructure:"metrics"`
+	MetricsPath string `mapstructure:"metrics_path" validate:"startswith=/"`
+	// MetricsPort serves the metrics on a separate listener on Host instead
+	// of the public listeners. 0 serves them on the public listeners.
+	MetricsPort int `mapstructure:"metrics_port" validate:"min=0,max=65535"`
+	// Redirects are evaluated before object lookup in static and SPA modes,
+	// ahead of the rules in RedirectsFile.
+	Redirects []stowryhttp.RedirectRule `mapstructure:"redirects"`
+	// RedirectsFile names an object with _redirects-format rules. Empty disables it.
+	RedirectsFile string `mapstructure:"redirects_file"`
+	// MaxWatchTimeout caps how long a change feed watch waits for changes.
+	MaxWatchTimeout int `mapstructure:"max_watch_timeout" validate:"min=1"` // seconds
+	// StartupTimeout bounds how long startup tasks, migrations and
+	// service.populate_on_start, may hold the server in maintenance. 0 means
+	// no bound.
+	StartupTimeout int `mapstructure:"startup_timeout" validate:"min=0"` // seconds
+	// StartupTimeoutAction is what happens when StartupTimeout passes:
+	// "exit" stops the server, "open" serves requests as soon as migrations
+	// are done and lets populate finish in the background.
+	StartupTimeoutAction string `mapstructure:"startup_timeout_action" validate:"oneof=exit open"`
+	// BackupEndpoint serves POST /admin/backup, a snapshot of the SQLite
+	// metadata database, to clients with write access.
+	BackupEndpoint bool `mapstructure:"backup_endpoint"`
+	// AdminJobs serves POST /admin/populate, /admin/cleanup and /admin/verify,
+	// which run maintenance as background jobs, and GET and DELETE
+	// /admin/jobs/{id} to clients with write access.
+	AdminJobs bool `mapstructure:"admin_jobs"`
+	// SupportBundleEndpoint serves GET /admin/support-bundle, the bundle
+	// "stowry support-bundle" writes plus the server's recent errors, to
+	// clients with write access.
+	SupportBundleEndpoint bool `mapstructure:"support_bundle_endpoint"`
+	// ContentDisposition is how objects uploaded with an original filename
+	// are downloaded: "inline" or "attachment".
+	ContentDisposition string `mapstructure:"content_disposition" validate:"oneof=inline attachment"`
+	// IfMatchComparison is how an upload's If-Match is compared with the
+	// stored ETag: "strong", or "weak" to accept the W/ ETags some proxies
+	// send back after re-encoding a download.
+	IfMatchComparison string `mapstructure:"if_match_comparison" validate:"oneof=strong weak"`
+	// Region identifies this server's region in multi-region deployments,
+	// such as "eu-west-1". It is sent as X-Stowry-Region with every response,
+	// and requests expecting another region get 421. Empty disables both.
+	Region string `mapstructure:"region" validate:"max=63"`
+	// SecurityHeaders sets X-Content-Type-Options on every response and CSP,
+	// frame and referrer headers on pages served in static and SPA modes.
+	SecurityHeaders stowryhttp.SecurityHeadersConfig `mapstructure:"security_headers"`
+	// Static sets the directory index documents of static mode.
+	Static StaticConfig `mapstructure:"static"`
+	// SPA sets the index SPA mode falls back to, what it serves before that
+	// is uploaded, and the paths that never fall back to it.
+	SPA stowryhttp.SPAConfig `mapstructure:"spa"`
+	// SiteFiles sets the built-in /robots.txt and /favicon.ico of static
+	// and SPA modes.
+	SiteFiles stowryhttp.SiteFilesConfig `mapstructure:"site_files"`
+}
+
+// EffectiveListeners returns Listeners, or a single listener on Host and
+// Port when none are configured.
+func (c ServerConfig) EffectiveListeners() []stowryhttp.ListenerConfig {
+	if len(c.Listeners) > 0 {
+		return c.Listeners
+	}
+	return []stowryhttp.ListenerConfig{{Addr: net.JoinHostPort(c.Host, strconv.Itoa(c.Port))}}
+}
+
+// StaticConfig configures static mode.
+type StaticConfig struct {
+	// IndexFiles are the documents served for a directory URL, tried in
+	// order, such as [index.html, index.htm].
+	IndexFiles []string `mapstructure:"index_files"`
+}
+
+// Validate reports an empty list of index files or one that is not a plain
+// file name.
+func (c StaticConfig) Validate() error {
+	if err := stowry.ValidateIndexFiles(c.IndexFiles); err != nil {
+		return fmt.Errorf("static: %w", err)
+	}
+	return nil
 }
 
 // ServiceConfig holds service-level configuration.
 type ServiceConfig struct {
 	CleanupTimeout int `mapstructure:"cleanup_timeout" validate:"min=1"`
+	// CleanupInterval is how often soft-deleted objects are purged in the
+	// background. 0 leaves them to "stowry cleanup".
+	CleanupInterval int `mapstructure:"cleanup_interval" validate:"min=0"` // seconds
+	// CleanupRetention is how long after its delete an object is kept before
+	// the background cleanup purges it.
+	CleanupRetention int `mapstructure:"cleanup_retention" validate:"min=0"` // seconds
+	// CleanupBatchSize is how many pending objects the background cleanup
+	// lists at a time.
+	CleanupBatchSize int `mapstructure:"cleanup_batch_size" validate:"min=1"`
+	// Limits cap the number of active objects under path prefixes.
+	Limits []stowry.ObjectLimit `mapstructure:"limits"`
+	// LimitsReconcileInterval is how often cached limit counts are recounted.
+	LimitsReconcileInterval int `mapstructure:"limits_reconcile_interval" validate:"min=1"` // seconds
+	// ContentTypes restrict the content types that may be uploaded under path
+	// prefixes.
+	ContentTypes []stowry.ContentTypeRule `mapstructure:"content_types"`
+	// ChangesRetention is how long change feed entries are kept. 0 keeps them
+	// forever.
+	ChangesRetention int `mapstructure:"changes_retention" validate:"min=0"` // seconds
+	// PathLimits bound the length and depth of object paths, checked before
+	// an upload is read.
+	PathLimits stowry.PathLimits `mapstructure:"path_limits"`
+	// UsageSnapshotInterval is how often per-prefix usage snapshots are
+	// taken for chargeback reports. 0 takes none automatically.
+	UsageSnapshotInterval int `mapstructure:"usage_snapshot_interval" validate:"min=0"` // seconds
+	// PopulateOnStart records every storage file in the metadata at startup,
+	// as "stowry init" does, while the server answers 503.
+	PopulateOnStart bool `mapstructure:"populate_on_start"`
+	// WriteIntents records each upload in a write intent log until its
+	// metadata is committed, so uploads cut short by a crash are reconciled
+	// at the next start instead of leaving orphans.
+	WriteIntents bool `mapstructure:"write_intents"`
+	// IntentRecoveryAge is how old an intent must be to be reconciled at
+	// startup, so uploads in progress on other instances are left alone.
+	IntentRecoveryAge int `mapstructure:"intent_recovery_age" validate:"min=0"` // seconds
+	// RepoCheckSize is the upload size from which the server checks that
+	// the database answers before storing the body, so large uploads fail
+	// at once while it is down. -1 disables the check.
+	RepoCheckSize int64 `mapstructure:"repo_check_size" validate:"min=-1"` // bytes
 }
 
 // StorageConfig holds file storage configuration.
 type StorageConfig struct {
-	Path string `mapstructure:"path" validate:"required"`
+	Path    string `mapstructure:"path" validate:"required"`
+	TempDir string `mapstructure:"temp_dir"` // Staging directory for uploads. Empty uses the storage path.
+	// Routes store objects under a path prefix in another directory. The
+	// longest matching prefix wins; other objects stay in Path.
+	Routes []StorageRoute `mapstructure:"routes"`
+	// VerifyReads checks downloads against their ETag after sending them:
+	// off, sample or always. It detects corruption, it does not prevent it.
+	VerifyReads           string  `mapstructure:"verify_reads" validate:"oneof=off sample always"`
+	VerifyReadsSampleRate float64 `mapstructure:"verify_reads_sample_rate" validate:"gt=0,lte=1"` // Fraction of downloads checked in sample mode
+	VerifyReadsQuarantine bool    `mapstructure:"verify_reads_quarantine"`                        // Quarantine objects found corrupted
+}
+
+// StorageRoute stores objects whose path starts with Prefix in Path.
+type StorageRoute struct {
+	Prefix  string `mapstructure:"prefix"`
+	Type    string `mapstructure:"type"` // Storage backend. Empty means filesystem, the only type.
+	Path    string `mapstructure:"path"`
+	TempDir string `mapstructure:"temp_dir"` // Staging directory for uploads. Empty uses Path.
+}
+
+// Validate reports the first invalid storage route: a missing or duplicate
+// prefix, an unknown type, or a missing path or one already in use.
+func (c StorageConfig) Validate() error {
+	prefixes := make(map[string]bool, len(c.Routes))
+	paths := map[string]bool{filepath.Clean(c.Path): true}
+	for i, route := range c.Routes {
+		switch {
+		case route.Prefix == "":
+			return fmt.Errorf("storage route %d: prefix is required", i+1)
+		case strings.HasPrefix(route.Prefix, "/"):
+			return fmt.Errorf("storage route %d (%s): prefix must not start with /", i+1, route.Prefix)
+		case prefixes[route.Prefix]:
+			return fmt.Errorf("storage route %d (%s): duplicate prefix", i+1, route.Prefix)
+		case route.Type != "" && route.Type != "filesystem":
+			return fmt.Errorf("storage route %d (%s): unknown type %q (want filesystem)", i+1, route.Prefix, route.Type)
+		case route.Path == "":
+			return fmt.Errorf("storage route %d (%s): path is required", i+1, route.Prefix)
+		case paths[filepath.Clean(route.Path)]:
+			return fmt.Errorf("storage route %d (%s): path %s is already used by another storage", i+1, route.Prefix, route.Path)
+		}
+		prefixes[route.Prefix] = true
+		paths[filepath.Clean(route.Path)] = true
+	}
+	return nil
 }
 
 // AuthConfig holds authentication configuration.
@@ -70,6 +276,36 @@ type AuthConfig struct {
 	Write string                `mapstructure:"write" validate:"required,oneof=public private"`
 	AWS   stowry.AWSConfig      `mapstructure:"aws"`
 	Keys  keybackend.KeysConfig `mapstructure:"keys"`
+	// Presign enables POST /presign for backends without a Stowry SDK.
+	Presign stowryhttp.PresignConfig `mapstructure:"presign"`
+	// JWT accepts bearer tokens from an OpenID Connect provider on private
+	// routes, ahead of signed requests.
+	JWT jwtauth.Config `mapstructure:"jwt"`
+}
+
+// MetricsConfig holds usage tracking configuration.
+type MetricsConfig struct {
+	TrackAccess         bool `mapstructure:"track_access"`
+	AccessFlushInterval int  `mapstructure:"access_flush_interval" validate:"min=1"` // seconds
+	// Latency records per-route request latency for /stats/latency on the
+	// expvar listener.
+	Latency LatencyConfig `mapstructure:"latency"`
+}
+
+// LatencyConfig configures per-route latency tracking. The stats cover the
+// last Windows windows of Window seconds each.
+type LatencyConfig struct {
+	Enabled bool `mapstructure:"enabled"`
+	Window  int  `mapstructure:"window" validate:"min=1"` // seconds
+	Windows int  `mapstructure:"windows" validate:"min=1"`
+	// SLOP99 is the p99 latency in milliseconds above which the server is
+	// reported as degraded. 0 disables the check.
+	SLOP99 int `mapstructure:"slo_p99" validate:"min=0"`
+}
+
+// ObservabilityConfig holds request tracing configuration.
+type ObservabilityConfig struct {
+	Tracing tracing.Config `mapstructure:"tracing"`
 }
 
 // LogConfig holds logging configuration.
@@ -84,59 +320,223 @@ var flagToViperKey = map[string]string{
 	"storage-path": "storage.path",
 	"port":         "server.port",
 	"mode":         "server.mode",
+	"disable-list": "server.disable_list",
+
+	"strict-security": "security.strict",
+	"auto-migrate":    "database.auto_migrate",
+	"migrate-mode":    "database.migrate_mode",
+
+	"allow-destructive-migrations": "database.allow_destructive_migrations",
+}
+
+// flagKey returns the viper key of the flag named name: its custom mapping
+// if it has one, otherwise the flag name as-is.
+func flagKey(name string) string {
+	if mapped, ok := flagToViperKey[name]; ok {
+		return mapped
+	}
+	return name
 }
 
 // bindFlags binds CLI flags to viper keys with custom name mapping.
 func bindFlags(v *viper.Viper, flags *pflag.FlagSet) {
 	flags.VisitAll(func(f *pflag.Flag) {
-		// Use custom mapping if it exists, otherwise use flag name as-is
-		viperKey := f.Name
-		if mapped, ok := flagToViperKey[viperKey]; ok {
-			viperKey = mapped
-		}
-
 		// Only bind if the flag was explicitly set
 		if f.Changed {
-			_ = v.BindPFlag(viperKey, f)
+			_ = v.BindPFlag(flagKey(f.Name), f)
 		}
 	})
 }
 
 // setDefaults configures default values on the viper instance.
 func setDefaults(v *viper.Viper) {
+	v.SetDefault("server.host", "")
 	v.SetDefault("server.port", 5708)
 	v.SetDefault("server.mode", "store")
 	v.SetDefault("server.max_upload_size", 0) // 0 means no limit
-
-	v.SetDefault("service.cleanup_timeout", 30) // seconds
+	v.SetDefault("server.immutable_assets", false)
+	v.SetDefault("server.immutable_pattern", stowryhttp.DefaultImmutablePattern)
+	v.SetDefault("server.precompressed", false)
+	v.SetDefault("server.list_export_max_rows", 100000)
+	v.SetDefault("server.max_delete_paths", stowry.DefaultMaxDeletePaths)
+	v.SetDefault("server.disable_list", false)
+	v.SetDefault("server.max_concurrent_writes", 0)    // 0 means no limit
+	v.SetDefault("server.max_concurrent_reads", 0)     // 0 means no limit
+	v.SetDefault("server.concurrency_wait_timeout", 5) // seconds
+	v.SetDefault("server.error_document", "")
+	v.SetDefault("server.expvar_addr", "")
+	v.SetDefault("server.metrics", false)
+	v.SetDefault("server.metrics_path", stowryhttp.DefaultMetricsPath)
+	v.SetDefault("server.metrics_port", 0) // 0 serves on the public listeners
+	v.SetDefault("server.redirects_file", stowryhttp.DefaultRedirectsFile)
+	v.SetDefault("server.max_watch_timeout", 60) // seconds
+	v.SetDefault("server.startup_timeout", 0)    // 0 means no bound
+	v.SetDefault("server.startup_timeout_action", "exit")
+	v.SetDefault("server.backup_endpoint", false)
+	v.SetDefault("server.admin_jobs", false)
+	v.SetDefault("server.support_bundle_endpoint", false)
+	v.SetDefault("server.content_disposition", stowryhttp.DispositionInline)
+	v.SetDefault("server.if_match_comparison", stowryhttp.ETagStrong)
+	v.SetDefault("server.region", "")
+	v.SetDefault("server.security_headers.nosniff", true)
+	v.SetDefault("server.security_headers.enabled", false)
+	v.SetDefault("server.security_headers.content_security_policy", "")
+	v.SetDefault("server.security_headers.referrer_policy", stowryhttp.DefaultReferrerPolicy)
+	v.SetDefault("server.security_headers.frame_ancestors", []string{"'self'"})
+	v.SetDefault("server.security_headers.hsts_max_age", 0) // 0 disables HSTS
+	v.SetDefault("server.security_headers.hsts_include_subdomains", false)
+	v.SetDefault("server.static.index_files", []string{stowry.SPAIndexPath})
+	v.SetDefault("server.spa.index", stowry.SPAIndexPath)
+	v.SetDefault("server.spa.missing_index", stowryhttp.MissingIndexError)
+	v.SetDefault("server.spa.wait", stowryhttp.DefaultSPAWait) // seconds
+	v.SetDefault("server.spa.no_fallback_paths", stowryhttp.DefaultSPANoFallbackPaths)
+	v.SetDefault("server.site_files.robots_txt", stowryhttp.DefaultRobotsTxt)
+	v.SetDefault("server.site_files.favicon", "")
+
+	v.SetDefault("service.cleanup_timeout", 30)  // seconds
+	v.SetDefault("service.cleanup_interval", 0)  // seconds, 0 disables
+	v.SetDefault("service.cleanup_retention", 0) // seconds
+	v.SetDefault("service.cleanup_batch_size", stowry.DefaultCleanupBatchSize)
+	v.SetDefault("service.limits_reconcile_interval", 300) // seconds
+	v.SetDefault("service.changes_retention", 604800)      // seconds (7 days)
+	v.SetDefault("service.path_limits.max_segment_bytes", stowry.DefaultMaxSegmentBytes)
+	v.SetDefault("service.path_limits.max_key_bytes", stowry.DefaultMaxKeyBytes)
+	v.SetDefault("service.path_limits.max_depth", stowry.DefaultMaxDepth)
+	v.SetDefault("service.usage_snapshot_interval", 0) // seconds, 0 disables
+	v.SetDefault("service.populate_on_start", false)
+	v.SetDefault("service.write_intents", true)
+	v.SetDefault("service.intent_recovery_age", 300) // seconds
+	v.SetDefault("service.repo_check_size", stowry.DefaultRepoCheckSize)
 
 	v.SetDefault("database.type", "sqlite")
 	v.SetDefault("database.dsn", "stowry.db")
 	v.SetDefault("database.tables.meta_data", "stowry_metadata")
+	v.SetDefault("database.auto_migrate", nil) // unset: backend default, see database.Config.MigrateOnStartup
+	v.SetDefault("database.migrate_mode", "")  // unset: from auto_migrate, see database.Config.StartupMigrateMode
+	v.SetDefault("database.allow_destructive_migrations", false)
+	v.SetDefault("database.id_scheme", string(stowry.IDSchemeUUIDv4))
 
 	v.SetDefault("storage.path", "./data")
+	v.SetDefault("storage.temp_dir", "")
+	v.SetDefault("storage.verify_reads", "off")
+	v.SetDefault("storage.verify_reads_sample_rate", stowry.DefaultReadVerifySampleRate)
+	v.SetDefault("storage.verify_reads_quarantine", false)
 
 	v.SetDefault("auth.read", "public")
 	v.SetDefault("auth.write", "public")
 	v.SetDefault("auth.aws.region", "us-east-1")
 	v.SetDefault("auth.aws.service", "s3")
+	v.SetDefault("auth.keys.file", "")
+	v.SetDefault("auth.keys.extra_file", "")
+	v.SetDefault("auth.keys.extra_file_interval", 2) // seconds
+	v.SetDefault("auth.keys.track_usage", true)
+	v.SetDefault("auth.keys.usage_flush_interval", 5) // seconds
+	v.SetDefault("auth.keys.revoke_window", 86400)    // seconds
+	v.SetDefault("auth.presign.enabled", false)
+	v.SetDefault("auth.presign.signing_key", "")
+	v.SetDefault("auth.presign.base_url", "")
+	v.SetDefault("auth.presign.max_expires", stowryhttp.DefaultPresignMaxExpires) // seconds
+	v.SetDefault("auth.presign.rate_limit", 600)                                  // per key per minute
+	v.SetDefault("auth.jwt.enabled", false)
+	v.SetDefault("auth.jwt.issuer", "")
+	v.SetDefault("auth.jwt.audience", "")
+	v.SetDefault("auth.jwt.jwks_url", "") // discovered from the issuer
+	v.SetDefault("auth.jwt.cookie", "")
+	v.SetDefault("auth.jwt.subject_claim", jwtauth.DefaultSubjectClaim)
+	v.SetDefault("auth.jwt.permissions_claim", "")
+	v.SetDefault("auth.jwt.default_permissions", []string{"read"})
+	v.SetDefault("auth.jwt.leeway", 60)                         // seconds
+	v.SetDefault("auth.jwt.cache_ttl", jwtauth.DefaultCacheTTL) // seconds
+	v.SetDefault("auth.jwt.min_refresh_interval", 60)           // seconds
+
+	v.SetDefault("cors.enabled", false)
+	v.SetDefault("cors.allow_credentials", false)
+	v.SetDefault("cors.max_age", 0)
+
+	v.SetDefault("compression.enabled", false)
+	v.SetDefault("compression.min_size", 1024)
+
+	v.SetDefault("metrics.track_access", false)
+	v.SetDefault("metrics.access_flush_interval", 5) // seconds
+	v.SetDefault("metrics.latency.enabled", true)
+	v.SetDefault("metrics.latency.window", 60) // seconds
+	v.SetDefault("metrics.latency.windows", 5)
+	v.SetDefault("metrics.latency.slo_p99", 0) // milliseconds; 0 disables the check
+
+	v.SetDefault("observability.tracing.enabled", false)
+	v.SetDefault("observability.tracing.endpoint", "") // OTEL_EXPORTER_OTLP_* env, then localhost:4318
+	v.SetDefault("observability.tracing.insecure", false)
+	v.SetDefault("observability.tracing.sampling_ratio", 1.0)
+	v.SetDefault("observability.tracing.service_name", "stowry")
+
+	v.SetDefault("security.strict", false)
 
 	v.SetDefault("log.level", "info")
 }
 
+// DefaultEnvPrefix is the prefix of the environment variables Load reads,
+// as in STOWRY_SERVER_PORT.
+const DefaultEnvPrefix = "STOWRY"
+
+// LoadOptions configures LoadWithOptions. The zero value loads like
+// Load(nil, nil).
+type LoadOptions struct {
+	// Files are config files merged left to right. Empty uses the
+	// comma-separated <EnvPrefix>_CONFIG, then ./config.yaml if it exists.
+	Files []string
+
+	// EnvPrefix is the prefix of environment variables, without the
+	// trailing underscore (default: DefaultEnvPrefix).
+	EnvPrefix string
+
+	// FlagSet, if set, supplies command-line flags. Only flags the user
+	// changed are used. Flags of the stowry command map to their keys, such
+	// as --db-dsn to database.dsn; other flags bind to the key of their name.
+	FlagSet *pflag.FlagSet
+
+	// Defaults replace built-in defaults, keyed by config key such as
+	// "server.port". Config files, the environment and flags still override
+	// them.
+	Defaults map[string]any
+}
+
 // Load reads configuration and returns a validated Config struct.
 // Order of precedence (highest to lowest): flags > env > config files > defaults
 //
+// Lists such as auth keys and CORS origins can be set from the environment as
+// JSON or indexed variables (see envList); a list from the environment
+// replaces the one from config files.
+//
 // Parameters:
-//   - configFiles: list of config file paths (later files override earlier ones)
+//   - configFiles: list of config file paths (later files override earlier ones).
+//     Empty uses the comma-separated STOWRY_CONFIG, then ./config.yaml.
 //   - flags: cobra flag set for flag binding (can be nil)
 func Load(configFiles []string, flags *pflag.FlagSet) (*Config, error) {
+	return LoadWithOptions(LoadOptions{Files: configFiles, FlagSet: flags})
+}
+
+// LoadWithOptions reads configuration as described by opts and returns a
+// validated Config. Each call uses its own viper instance, so concurrent
+// loads do not affect each other.
+func LoadWithOptions(opts LoadOptions) (*Config, error) {
+	prefix := opts.EnvPrefix
+	if prefix == "" {
+		prefix = DefaultEnvPrefix
+	}
+
 	v := viper.New()
 
 	// 1. Set defaults
 	setDefaults(v)
+	for key, value := range opts.Defaults {
+		v.SetDefault(key, value)
+	}
 
-	// 2. Read config files
+	// 2. Read config files, from <prefix>_CONFIG when none are given
+	configFiles := opts.Files
+	if len(configFiles) == 0 {
+		configFiles = configFilesFromEnv(prefix)
+	}
 	if len(configFiles) > 0 {
 		v.SetConfigFile(configFiles[0])
 		if err := v.ReadInConfig(); err != nil {
@@ -163,31 +563,139 @@ func Load(configFiles []string, flags *pflag.FlagSet) (*Config, error) {
 	}
 
 	// 3. Bind environment variables
-	v.SetEnvPrefix("STOWRY")
+	v.SetEnvPrefix(prefix)
 	v.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))
 	v.AutomaticEnv()
+	envKeys, err := bindEnvLists(v, prefix, os.Environ())
+	if err != nil {
+		return nil, fmt.Errorf("read environment: %w", err)
+	}
 
 	// 4. Bind flags (if provided)
-	if flags != nil {
-		bindFlags(v, flags)
+	if opts.FlagSet != nil {
+		bindFlags(v, opts.FlagSet)
 	}
 
 	// 5. Unmarshal into Config struct
+	expandListenerAddrs(v)
 	var cfg Config
 	if err := v.Unmarshal(&cfg); err != nil {
 		return nil, fmt.Errorf("unmarshal config: %w", err)
 	}
 
-	// 6. Validate using go-playground/validator
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	cfg.sources = keySources(v, prefix, envKeys, opts.FlagSet)
+	return &cfg, nil
+}
+
+// Validate runs the checks Load applies to every loaded Config, so a Config
+// built in code gets the same ones. Start from a loaded Config, for example
+// from LoadWithOptions with no files, since the zero Config is not valid.
+func (c *Config) Validate() error {
+	// 1. Validate struct tags using go-playground/validator
 	validate := validator.New()
-	if err := validate.Struct(&cfg); err != nil {
-		return nil, fmt.Errorf("validate config: %w", err)
+	if err := validate.Struct(c); err != nil {
+		return fmt.Errorf("validate config: %w", err)
 	}
 
-	// 7. Validate database table names
-	if err := cfg.Database.Tables.Validate(); err != nil {
-		return nil, fmt.Errorf("validate config: %w", err)
+	// 2. Validate database table names
+	if err := c.Database.Tables.Validate(); err != nil {
+		return fmt.Errorf("validate config: %w", err)
 	}
 
-	return &cfg, nil
+	// 3. Validate compression encodings against those compiled in
+	if err := c.Compression.Validate(); err != nil {
+		return fmt.Errorf("validate config: %w", err)
+	}
+
+	// 4. Validate the immutable asset pattern and cache rules
+	if c.Server.ImmutableAssets {
+		if _, err := stowryhttp.NewCachePolicy(c.Server.ImmutablePattern); err != nil {
+			return fmt.Errorf("validate config: %w", err)
+		}
+	}
+	if _, err := stowryhttp.NewCacheRules(c.Server.CacheRules); err != nil {
+		return fmt.Errorf("validate config: %w", err)
+	}
+
+	// 5. Validate redirect rules
+	if _, err := stowryhttp.NewRedirects(c.Server.Redirects); err != nil {
+		return fmt.Errorf("validate config: %w", err)
+	}
+
+	// 6. Validate per-prefix object limits
+	if err := stowry.ValidateObjectLimits(c.Service.Limits); err != nil {
+		return fmt.Errorf("validate config: %w", err)
+	}
+
+	// 7. Validate per-prefix content type rules
+	if err := stowry.ValidateContentTypeRules(c.Service.ContentTypes); err != nil {
+		return fmt.Errorf("validate config: %w", err)
+	}
+
+	// 8. Validate the presign endpoint
+	if err := c.Auth.Presign.Validate(); err != nil {
+		return fmt.Errorf("validate config: %w", err)
+	}
+
+	// 9. Validate storage routes
+	if err := c.Storage.Validate(); err != nil {
+		return fmt.Errorf("validate config: %w", err)
+	}
+
+	// 10. Validate security headers
+	if err := c.Server.SecurityHeaders.Validate(); err != nil {
+		return fmt.Errorf("validate config: %w", err)
+	}
+
+	// 11. Validate static and SPA settings
+	if err := c.Server.Static.Validate(); err != nil {
+		return fmt.Errorf("validate config: %w", err)
+	}
+	if err := c.Server.SPA.Validate(); err != nil {
+		return fmt.Errorf("validate config: %w", err)
+	}
+	if err := c.Server.SiteFiles.Validate(); err != nil {
+		return fmt.Errorf("validate config: %w", err)
+	}
+
+	// 12. Validate path limits
+	if err := c.Service.PathLimits.Validate(); err != nil {
+		return fmt.Errorf("validate config: %w", err)
+	}
+
+	// 13. Validate the JWT authenticator
+	if err := c.Auth.JWT.Validate(); err != nil {
+		return fmt.Errorf("validate config: %w", err)
+	}
+
+	// 14. Validate listen addresses
+	if err := stowryhttp.ValidateListeners(c.Server.Listeners); err != nil {
+		return fmt.Errorf("validate config: server.listeners: %w", err)
+	}
+
+	return nil
+}
+
+// expandListenerAddrs rewrites listeners given as bare addresses, such as
+// "[::1]:5708", to the object form {addr: "[::1]:5708"}.
+func expandListenerAddrs(v *viper.Viper) {
+	items, ok := v.Get("server.listeners").([]any)
+	if !ok {
+		return
+	}
+	expanded := make([]any, len(items))
+	changed := false
+	for i, item := range items {
+		expanded[i] = item
+		if addr, ok := item.(string); ok {
+			expanded[i] = map[string]any{"addr": addr}
+			changed = true
+		}
+	}
+	if changed {
+		v.Set("server.listeners", expanded)
+	}
 }
@@ -2,15 +2,20 @@ package config_test
 
 import (
 	"context"
+	"fmt"
 	"os"
 	"path/filepath"
+	"sync"
 	"testing"
 
 	"github.com/spf13/pflag"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
+	"github.com/sagarc03/stowry"
 	"github.com/sagarc03/stowry/config"
+	"github.com/sagarc03/stowry/database"
+	stowryhttp "github.com/sagarc03/stowry/http"
 )
 
 func TestLoad_Defaults(t *testing.T) {
@@ -24,10 +29,15 @@ func TestLoad_Defaults(t *testing.T) {
 	assert.Equal(t, "stowry.db", cfg.Database.DSN)
 	assert.Equal(t, "stowry_metadata", cfg.Database.Tables.MetaData)
 	assert.Equal(t, "./data", cfg.Storage.Path)
+	assert.Equal(t, "off", cfg.Storage.VerifyReads)
 	assert.Equal(t, "public", cfg.Auth.Read)
 	assert.Equal(t, "public", cfg.Auth.Write)
 	assert.Equal(t, "us-east-1", cfg.Auth.AWS.Region)
 	assert.Equal(t, "s3", cfg.Auth.AWS.Service)
+	assert.Equal(t, 2, cfg.Auth.Keys.ExtraFileInterval)
+	assert.True(t, cfg.Auth.Keys.TrackUsage)
+	assert.Equal(t, 5, cfg.Auth.Keys.UsageFlushInterval)
+	assert.Equal(t, 86400, cfg.Auth.Keys.RevokeWindow)
 	assert.Equal(t, "info", cfg.Log.Level)
 }
 
@@ -303,6 +313,702 @@ cors:
 	assert.Equal(t, 600, cfg.CORS.MaxAge)
 }
 
+func TestLoad_WithCompression(t *testing.T) {
+	tmpDir := t.TempDir()
+	configPath := filepath.Join(tmpDir, "config.yaml")
+
+	configContent := `
+compression:
+  enabled: true
+  encodings:
+    - gzip
+  levels:
+    gzip: 9
+  min_size: 256
+`
+	err := os.WriteFile(configPath, []byte(configContent), 0o644)
+	require.NoError(t, err)
+
+	cfg, err := config.Load([]string{configPath}, nil)
+	require.NoError(t, err)
+
+	assert.True(t, cfg.Compression.Enabled)
+	assert.Equal(t, []string{"gzip"}, cfg.Compression.Encodings)
+	assert.Equal(t, map[string]int{"gzip": 9}, cfg.Compression.Levels)
+	assert.Equal(t, int64(256), cfg.Compression.MinSize)
+}
+
+func TestLoad_ValidationError_UnsupportedCompression(t *testing.T) {
+	tmpDir := t.TempDir()
+	configPath := filepath.Join(tmpDir, "config.yaml")
+
+	configContent := `
+compression:
+  enabled: true
+  encodings:
+    - lzma
+`
+	err := os.WriteFile(configPath, []byte(configContent), 0o644)
+	require.NoError(t, err)
+
+	_, err = config.Load([]string{configPath}, nil)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "lzma")
+}
+
+func TestLoad_Tracing(t *testing.T) {
+	t.Run("disabled by default", func(t *testing.T) {
+		cfg, err := config.Load(nil, nil)
+		require.NoError(t, err)
+
+		assert.False(t, cfg.Observability.Tracing.Enabled)
+		assert.Equal(t, 1.0, cfg.Observability.Tracing.SamplingRatio)
+		assert.Equal(t, "stowry", cfg.Observability.Tracing.ServiceName)
+	})
+
+	t.Run("from config file", func(t *testing.T) {
+		configPath := filepath.Join(t.TempDir(), "config.yaml")
+		configContent := `
+observability:
+  tracing:
+    enabled: true
+    endpoint: collector:4318
+    insecure: true
+    sampling_ratio: 0.25
+`
+		require.NoError(t, os.WriteFile(configPath, []byte(configContent), 0o644))
+
+		cfg, err := config.Load([]string{configPath}, nil)
+		require.NoError(t, err)
+
+		assert.True(t, cfg.Observability.Tracing.Enabled)
+		assert.Equal(t, "collector:4318", cfg.Observability.Tracing.Endpoint)
+		assert.True(t, cfg.Observability.Tracing.Insecure)
+		assert.Equal(t, 0.25, cfg.Observability.Tracing.SamplingRatio)
+	})
+
+	t.Run("sampling ratio out of range", func(t *testing.T) {
+		configPath := filepath.Join(t.TempDir(), "config.yaml")
+		configContent := `
+observability:
+  tracing:
+    sampling_ratio: 1.5
+`
+		require.NoError(t, os.WriteFile(configPath, []byte(configContent), 0o644))
+
+		_, err := config.Load([]string{configPath}, nil)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "SamplingRatio")
+	})
+}
+
+func TestLoad_Redirects(t *testing.T) {
+	t.Run("default file", func(t *testing.T) {
+		cfg, err := config.Load(nil, nil)
+		require.NoError(t, err)
+
+		assert.Equal(t, "_redirects", cfg.Server.RedirectsFile)
+		assert.Empty(t, cfg.Server.Redirects)
+	})
+
+	t.Run("rules from config file", func(t *testing.T) {
+		configPath := filepath.Join(t.TempDir(), "config.yaml")
+		configContent := `
+server:
+  mode: static
+  redirects:
+    - from: /old
+      to: /new
+      status: 308
+    - from: /app/*
+      to: /app/index.html
+      status: 200
+`
+		require.NoError(t, os.WriteFile(configPath, []byte(configContent), 0o644))
+
+		cfg, err := config.Load([]string{configPath}, nil)
+		require.NoError(t, err)
+
+		assert.Equal(t, []stowryhttp.RedirectRule{
+			{From: "/old", To: "/new", Status: 308},
+			{From: "/app/*", To: "/app/index.html", Status: 200},
+		}, cfg.Server.Redirects)
+	})
+
+	t.Run("invalid rule", func(t *testing.T) {
+		configPath := filepath.Join(t.TempDir(), "config.yaml")
+		configContent := `
+server:
+  redirects:
+    - from: /old
+      to: /new
+      status: 418
+`
+		require.NoError(t, os.WriteFile(configPath, []byte(configContent), 0o644))
+
+		_, err := config.Load([]string{configPath}, nil)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "unsupported status 418")
+	})
+}
+
+func TestLoad_ChangeFeed(t *testing.T) {
+	t.Run("defaults", func(t *testing.T) {
+		cfg, err := config.Load(nil, nil)
+		require.NoError(t, err)
+
+		assert.Equal(t, 604800, cfg.Service.ChangesRetention)
+		assert.Equal(t, 60, cfg.Server.MaxWatchTimeout)
+	})
+
+	t.Run("from config file", func(t *testing.T) {
+		configPath := filepath.Join(t.TempDir(), "config.yaml")
+		configContent := `
+server:
+  max_watch_timeout: 20
+service:
+  changes_retention: 0
+`
+		require.NoError(t, os.WriteFile(configPath, []byte(configContent), 0o644))
+
+		cfg, err := config.Load([]string{configPath}, nil)
+		require.NoError(t, err)
+
+		assert.Equal(t, 0, cfg.Service.ChangesRetention)
+		assert.Equal(t, 20, cfg.Server.MaxWatchTimeout)
+	})
+
+	t.Run("zero watch timeout", func(t *testing.T) {
+		configPath := filepath.Join(t.TempDir(), "config.yaml")
+		require.NoError(t, os.WriteFile(configPath, []byte("server:\n  max_watch_timeout: 0\n"), 0o644))
+
+		_, err := config.Load([]string{configPath}, nil)
+		assert.Error(t, err)
+	})
+}
+
+func TestLoad_IfMatchComparison(t *testing.T) {
+	t.Run("defaults to strong", func(t *testing.T) {
+		cfg, err := config.Load(nil, nil)
+		require.NoError(t, err)
+
+		assert.Equal(t, "strong", cfg.Server.IfMatchComparison)
+	})
+
+	t.Run("weak from config file", func(t *testing.T) {
+		configPath := filepath.Join(t.TempDir(), "config.yaml")
+		require.NoError(t, os.WriteFile(configPath, []byte("server:\n  if_match_comparison: weak\n"), 0o644))
+
+		cfg, err := config.Load([]string{configPath}, nil)
+		require.NoError(t, err)
+
+		assert.Equal(t, "weak", cfg.Server.IfMatchComparison)
+	})
+
+	t.Run("unknown comparison", func(t *testing.T) {
+		configPath := filepath.Join(t.TempDir(), "config.yaml")
+		require.NoError(t, os.WriteFile(configPath, []byte("server:\n  if_match_comparison: lenient\n"), 0o644))
+
+		_, err := config.Load([]string{configPath}, nil)
+		assert.Error(t, err)
+	})
+}
+
+func TestLoad_DisableList(t *testing.T) {
+	t.Run("defaults to enabled listing", func(t *testing.T) {
+		cfg, err := config.Load(nil, nil)
+		require.NoError(t, err)
+
+		assert.False(t, cfg.Server.DisableList)
+	})
+
+	t.Run("from flag", func(t *testing.T) {
+		flags := pflag.NewFlagSet("test", pflag.ContinueOnError)
+		flags.Bool("disable-list", false, "disable list")
+		require.NoError(t, flags.Set("disable-list", "true"))
+
+		cfg, err := config.Load(nil, flags)
+		require.NoError(t, err)
+
+		assert.True(t, cfg.Server.DisableList)
+	})
+
+	t.Run("from environment", func(t *testing.T) {
+		t.Setenv("STOWRY_SERVER_DISABLE_LIST", "true")
+
+		cfg, err := config.Load(nil, nil)
+		require.NoError(t, err)
+
+		assert.True(t, cfg.Server.DisableList)
+	})
+}
+
+func TestLoad_Startup(t *testing.T) {
+	t.Run("defaults", func(t *testing.T) {
+		cfg, err := config.Load(nil, nil)
+		require.NoError(t, err)
+
+		assert.False(t, cfg.Service.PopulateOnStart)
+		assert.True(t, cfg.Service.WriteIntents)
+		assert.Equal(t, 300, cfg.Service.IntentRecoveryAge)
+		assert.Equal(t, int64(stowry.DefaultRepoCheckSize), cfg.Service.RepoCheckSize)
+		assert.Equal(t, 0, cfg.Server.StartupTimeout)
+		assert.Equal(t, "exit", cfg.Server.StartupTimeoutAction)
+	})
+
+	t.Run("from config file", func(t *testing.T) {
+		configPath := filepath.Join(t.TempDir(), "config.yaml")
+		configContent := `
+server:
+  startup_timeout: 600
+  startup_timeout_action: open
+service:
+  populate_on_start: true
+  write_intents: false
+  intent_recovery_age: 60
+  repo_check_size: -1
+`
+		require.NoError(t, os.WriteFile(configPath, []byte(configContent), 0o644))
+
+		cfg, err := config.Load([]string{configPath}, nil)
+		require.NoError(t, err)
+
+		assert.True(t, cfg.Service.PopulateOnStart)
+		assert.False(t, cfg.Service.WriteIntents)
+		assert.Equal(t, 60, cfg.Service.IntentRecoveryAge)
+		assert.Equal(t, int64(-1), cfg.Service.RepoCheckSize)
+		assert.Equal(t, 600, cfg.Server.StartupTimeout)
+		assert.Equal(t, "open", cfg.Server.StartupTimeoutAction)
+	})
+
+	t.Run("unknown timeout action", func(t *testing.T) {
+		configPath := filepath.Join(t.TempDir(), "config.yaml")
+		require.NoError(t, os.WriteFile(configPath, []byte("server:\n  startup_timeout_action: retry\n"), 0o644))
+
+		_, err := config.Load([]string{configPath}, nil)
+		assert.Error(t, err)
+	})
+}
+
+func TestLoad_AutoMigrate(t *testing.T) {
+	t.Run("defaults by backend", func(t *testing.T) {
+		cfg, err := config.Load(nil, nil)
+		require.NoError(t, err)
+		assert.Nil(t, cfg.Database.AutoMigrate)
+		assert.True(t, cfg.Database.MigrateOnStartup(), "sqlite migrates by default")
+
+		t.Setenv("STOWRY_DATABASE_TYPE", "postgres")
+		cfg, err = config.Load(nil, nil)
+		require.NoError(t, err)
+		assert.False(t, cfg.Database.MigrateOnStartup(), "postgres does not migrate by default")
+	})
+
+	t.Run("from config file", func(t *testing.T) {
+		configPath := filepath.Join(t.TempDir(), "config.yaml")
+		require.NoError(t, os.WriteFile(configPath, []byte("database:\n  type: postgres\n  auto_migrate: true\n"), 0o644))
+
+		cfg, err := config.Load([]string{configPath}, nil)
+		require.NoError(t, err)
+		assert.True(t, cfg.Database.MigrateOnStartup())
+	})
+
+	t.Run("from environment", func(t *testing.T) {
+		t.Setenv("STOWRY_DATABASE_AUTO_MIGRATE", "false")
+
+		cfg, err := config.Load(nil, nil)
+		require.NoError(t, err)
+		assert.False(t, cfg.Database.MigrateOnStartup())
+	})
+
+	t.Run("from flag", func(t *testing.T) {
+		flags := pflag.NewFlagSet("test", pflag.ContinueOnError)
+		flags.Bool("auto-migrate", false, "auto migrate")
+		require.NoError(t, flags.Set("auto-migrate", "true"))
+		t.Setenv("STOWRY_DATABASE_TYPE", "postgres")
+
+		cfg, err := config.Load(nil, flags)
+		require.NoError(t, err)
+		assert.True(t, cfg.Database.MigrateOnStartup())
+	})
+}
+
+func TestLoad_MigrateMode(t *testing.T) {
+	t.Run("defaults from auto_migrate", func(t *testing.T) {
+		t.Setenv("STOWRY_DATABASE_TYPE", "postgres")
+
+		cfg, err := config.Load(nil, nil)
+		require.NoError(t, err)
+		assert.Equal(t, database.MigrateSkip, cfg.Database.StartupMigrateMode())
+		assert.False(t, cfg.Database.AllowDestructiveMigrations)
+	})
+
+	t.Run("from config file", func(t *testing.T) {
+		configPath := filepath.Join(t.TempDir(), "config.yaml")
+		require.NoError(t, os.WriteFile(configPath, []byte("database:\n  migrate_mode: check\n  allow_destructive_migrations: true\n"), 0o644))
+
+		cfg, err := config.Load([]string{configPath}, nil)
+		require.NoError(t, err)
+		assert.Equal(t, database.MigrateCheck, cfg.Database.StartupMigrateMode())
+		assert.True(t, cfg.Database.AllowDestructiveMigrations)
+	})
+
+	t.Run("from flags", func(t *testing.T) {
+		flags := pflag.NewFlagSet("test", pflag.ContinueOnError)
+		flags.String("migrate-mode", "", "migrate mode")
+		flags.Bool("allow-destructive-migrations", false, "allow destructive migrations")
+		require.NoError(t, flags.Set("migrate-mode", "auto"))
+		require.NoError(t, flags.Set("allow-destructive-migrations", "true"))
+		t.Setenv("STOWRY_DATABASE_TYPE", "postgres")
+
+		cfg, err := config.Load(nil, flags)
+		require.NoError(t, err)
+		assert.Equal(t, database.MigrateAuto, cfg.Database.StartupMigrateMode())
+		assert.True(t, cfg.Database.AllowDestructiveMigrations)
+	})
+
+	t.Run("invalid mode", func(t *testing.T) {
+		t.Setenv("STOWRY_DATABASE_MIGRATE_MODE", "sometimes")
+
+		_, err := config.Load(nil, nil)
+		assert.ErrorContains(t, err, "MigrateMode")
+	})
+}
+
+func TestLoad_IDScheme(t *testing.T) {
+	t.Run("defaults to uuidv4", func(t *testing.T) {
+		cfg, err := config.Load(nil, nil)
+		require.NoError(t, err)
+		assert.Equal(t, stowry.IDSchemeUUIDv4, cfg.Database.IDScheme)
+	})
+
+	t.Run("from config file", func(t *testing.T) {
+		configPath := filepath.Join(t.TempDir(), "config.yaml")
+		require.NoError(t, os.WriteFile(configPath, []byte("database:\n  id_scheme: ulid-as-uuid\n"), 0o644))
+
+		cfg, err := config.Load([]string{configPath}, nil)
+		require.NoError(t, err)
+		assert.Equal(t, stowry.IDSchemeULID, cfg.Database.IDScheme)
+	})
+
+	t.Run("invalid scheme", func(t *testing.T) {
+		t.Setenv("STOWRY_DATABASE_ID_SCHEME", "snowflake")
+
+		_, err := config.Load(nil, nil)
+		assert.ErrorContains(t, err, "IDScheme")
+	})
+}
+
+func TestLoad_ObjectLimits(t *testing.T) {
+	t.Run("defaults", func(t *testing.T) {
+		cfg, err := config.Load(nil, nil)
+		require.NoError(t, err)
+
+		assert.Empty(t, cfg.Service.Limits)
+		assert.Equal(t, 300, cfg.Service.LimitsReconcileInterval)
+	})
+
+	t.Run("limits from config file", func(t *testing.T) {
+		configPath := filepath.Join(t.TempDir(), "config.yaml")
+		configContent := `
+service:
+  limits_reconcile_interval: 60
+  limits:
+    - prefix: cache/
+      max_objects: 100000
+      action: reject
+    - prefix: logs/
+      max_objects: 5000
+      action: warn
+`
+		require.NoError(t, os.WriteFile(configPath, []byte(configContent), 0o644))
+
+		cfg, err := config.Load([]string{configPath}, nil)
+		require.NoError(t, err)
+
+		assert.Equal(t, []stowry.ObjectLimit{
+			{Prefix: "cache/", MaxObjects: 100000, Action: stowry.LimitActionReject},
+			{Prefix: "logs/", MaxObjects: 5000, Action: stowry.LimitActionWarn},
+		}, cfg.Service.Limits)
+		assert.Equal(t, 60, cfg.Service.LimitsReconcileInterval)
+	})
+
+	invalid := []struct {
+		name    string
+		limits  string
+		wantErr string
+	}{
+		{
+			name:    "unknown action",
+			limits:  "    - prefix: cache/\n      max_objects: 10\n      action: block\n",
+			wantErr: `invalid action "block"`,
+		},
+		{
+			name:    "zero max objects",
+			limits:  "    - prefix: cache/\n      max_objects: 0\n",
+			wantErr: "max_objects must be at least 1",
+		},
+		{
+			name:    "duplicate prefix",
+			limits:  "    - prefix: cache/\n      max_objects: 10\n    - prefix: cache/\n      max_objects: 20\n",
+			wantErr: "duplicate prefix",
+		},
+	}
+	for _, tt := range invalid {
+		t.Run(tt.name, func(t *testing.T) {
+			configPath := filepath.Join(t.TempDir(), "config.yaml")
+			require.NoError(t, os.WriteFile(configPath, []byte("service:\n  limits:\n"+tt.limits), 0o644))
+
+			_, err := config.Load([]string{configPath}, nil)
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.wantErr)
+		})
+	}
+}
+
+func TestLoad_PathLimits(t *testing.T) {
+	t.Run("defaults", func(t *testing.T) {
+		cfg, err := config.Load(nil, nil)
+		require.NoError(t, err)
+
+		assert.Equal(t, stowry.DefaultPathLimits(), cfg.Service.PathLimits)
+	})
+
+	t.Run("from config file and environment", func(t *testing.T) {
+		configPath := filepath.Join(t.TempDir(), "config.yaml")
+		configContent := `
+service:
+  path_limits:
+    max_segment_bytes: 143
+    max_depth: 16
+`
+		require.NoError(t, os.WriteFile(configPath, []byte(configContent), 0o644))
+		t.Setenv("STOWRY_SERVICE_PATH_LIMITS_MAX_KEY_BYTES", "512")
+
+		cfg, err := config.Load([]string{configPath}, nil)
+		require.NoError(t, err)
+
+		assert.Equal(t, stowry.PathLimits{MaxSegmentBytes: 143, MaxKeyBytes: 512, MaxDepth: 16}, cfg.Service.PathLimits)
+	})
+
+	t.Run("negative limit", func(t *testing.T) {
+		configPath := filepath.Join(t.TempDir(), "config.yaml")
+		require.NoError(t, os.WriteFile(configPath, []byte("service:\n  path_limits:\n    max_depth: -1\n"), 0o644))
+
+		_, err := config.Load([]string{configPath}, nil)
+		assert.ErrorContains(t, err, "max_depth must not be negative")
+	})
+}
+
+func TestLoad_ContentTypeRules(t *testing.T) {
+	t.Run("rules from config file", func(t *testing.T) {
+		configPath := filepath.Join(t.TempDir(), "config.yaml")
+		configContent := `
+service:
+  content_types:
+    - prefix: uploads/
+      deny: [text/html, image/svg+xml]
+    - prefix: avatars/
+      allow: [image/*]
+`
+		require.NoError(t, os.WriteFile(configPath, []byte(configContent), 0o644))
+
+		cfg, err := config.Load([]string{configPath}, nil)
+		require.NoError(t, err)
+
+		assert.Equal(t, []stowry.ContentTypeRule{
+			{Prefix: "uploads/", Deny: []string{"text/html", "image/svg+xml"}},
+			{Prefix: "avatars/", Allow: []string{"image/*"}},
+		}, cfg.Service.ContentTypes)
+	})
+
+	invalid := []struct {
+		name    string
+		rules   string
+		wantErr string
+	}{
+		{
+			name:    "invalid pattern",
+			rules:   "    - prefix: a/\n      allow: [html]\n",
+			wantErr: `invalid pattern "html"`,
+		},
+		{
+			name:    "no patterns",
+			rules:   "    - prefix: a/\n",
+			wantErr: "allow or deny is required",
+		},
+	}
+	for _, tt := range invalid {
+		t.Run(tt.name, func(t *testing.T) {
+			configPath := filepath.Join(t.TempDir(), "config.yaml")
+			require.NoError(t, os.WriteFile(configPath, []byte("service:\n  content_types:\n"+tt.rules), 0o644))
+
+			_, err := config.Load([]string{configPath}, nil)
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.wantErr)
+		})
+	}
+}
+
+func TestLoad_StorageRoutes(t *testing.T) {
+	t.Run("routes from config file", func(t *testing.T) {
+		configPath := filepath.Join(t.TempDir(), "config.yaml")
+		configContent := `
+storage:
+  path: /srv/slow
+  routes:
+    - prefix: uploads/
+      path: /srv/nvme
+      temp_dir: /srv/nvme/.tmp
+    - prefix: uploads/archive/
+      type: filesystem
+      path: /srv/archive
+`
+		require.NoError(t, os.WriteFile(configPath, []byte(configContent), 0o644))
+
+		cfg, err := config.Load([]string{configPath}, nil)
+		require.NoError(t, err)
+
+		assert.Equal(t, []config.StorageRoute{
+			{Prefix: "uploads/", Path: "/srv/nvme", TempDir: "/srv/nvme/.tmp"},
+			{Prefix: "uploads/archive/", Type: "filesystem", Path: "/srv/archive"},
+		}, cfg.Storage.Routes)
+	})
+
+	t.Run("routes from environment", func(t *testing.T) {
+		t.Setenv("STOWRY_STORAGE_ROUTES_0_PREFIX", "hot/")
+		t.Setenv("STOWRY_STORAGE_ROUTES_0_PATH", "/srv/nvme")
+
+		cfg, err := config.Load(nil, nil)
+		require.NoError(t, err)
+		assert.Equal(t, []config.StorageRoute{{Prefix: "hot/", Path: "/srv/nvme"}}, cfg.Storage.Routes)
+	})
+
+	invalid := []struct {
+		name    string
+		routes  string
+		wantErr string
+	}{
+		{name: "missing prefix", routes: "    - path: /srv/a\n", wantErr: "storage route 1: prefix is required"},
+		{name: "leading slash", routes: "    - prefix: /a/\n      path: /srv/a\n", wantErr: "prefix must not start with /"},
+		{name: "missing path", routes: "    - prefix: a/\n", wantErr: "storage route 1 (a/): path is required"},
+		{name: "unknown type", routes: "    - prefix: a/\n      type: s3\n      path: /srv/a\n", wantErr: `unknown type "s3"`},
+		{
+			name:    "duplicate prefix",
+			routes:  "    - prefix: a/\n      path: /srv/a\n    - prefix: a/\n      path: /srv/b\n",
+			wantErr: "storage route 2 (a/): duplicate prefix",
+		},
+		{name: "storage path reused", routes: "    - prefix: a/\n      path: /srv/data/\n", wantErr: "path /srv/data/ is already used"},
+		{
+			name:    "route path reused",
+			routes:  "    - prefix: a/\n      path: /srv/a\n    - prefix: b/\n      path: /srv/a\n",
+			wantErr: "storage route 2 (b/): path /srv/a is already used",
+		},
+	}
+	for _, tt := range invalid {
+		t.Run(tt.name, func(t *testing.T) {
+			configPath := filepath.Join(t.TempDir(), "config.yaml")
+			require.NoError(t, os.WriteFile(configPath, []byte("storage:\n  path: /srv/data\n  routes:\n"+tt.routes), 0o644))
+
+			_, err := config.Load([]string{configPath}, nil)
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.wantErr)
+		})
+	}
+}
+
+func TestLoad_Presign(t *testing.T) {
+	t.Run("defaults", func(t *testing.T) {
+		cfg, err := config.Load(nil, nil)
+		require.NoError(t, err)
+
+		assert.False(t, cfg.Auth.Presign.Enabled)
+		assert.Equal(t, int64(3600), cfg.Auth.Presign.MaxExpires)
+		assert.Equal(t, 600, cfg.Auth.Presign.RateLimit)
+	})
+
+	t.Run("from config file", func(t *testing.T) {
+		configPath := filepath.Join(t.TempDir(), "config.yaml")
+		configContent := `
+auth:
+  presign:
+    enabled: true
+    signing_key: SIGNER
+    keys: [BACKEND]
+    base_url: https://files.example.com
+`
+		require.NoError(t, os.WriteFile(configPath, []byte(configContent), 0o644))
+
+		cfg, err := config.Load([]string{configPath}, nil)
+		require.NoError(t, err)
+
+		assert.True(t, cfg.Auth.Presign.Enabled)
+		assert.Equal(t, "SIGNER", cfg.Auth.Presign.SigningKey)
+		assert.Equal(t, []string{"BACKEND"}, cfg.Auth.Presign.Keys)
+		assert.Equal(t, "https://files.example.com", cfg.Auth.Presign.BaseURL)
+	})
+
+	t.Run("enabled without signing key", func(t *testing.T) {
+		configPath := filepath.Join(t.TempDir(), "config.yaml")
+		require.NoError(t, os.WriteFile(configPath, []byte("auth:\n  presign:\n    enabled: true\n"), 0o644))
+
+		_, err := config.Load([]string{configPath}, nil)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "signing_key is required")
+	})
+}
+
+func TestLoad_JWT(t *testing.T) {
+	t.Run("defaults", func(t *testing.T) {
+		cfg, err := config.Load(nil, nil)
+		require.NoError(t, err)
+
+		assert.False(t, cfg.Auth.JWT.Enabled)
+		assert.Equal(t, "sub", cfg.Auth.JWT.SubjectClaim)
+		assert.Equal(t, []stowryhttp.Permission{stowryhttp.PermissionRead}, cfg.Auth.JWT.DefaultPermissions)
+		assert.Equal(t, 60, cfg.Auth.JWT.Leeway)
+		assert.Equal(t, 3600, cfg.Auth.JWT.CacheTTL)
+		assert.Equal(t, 60, cfg.Auth.JWT.MinRefreshInterval)
+	})
+
+	t.Run("from config file", func(t *testing.T) {
+		configPath := filepath.Join(t.TempDir(), "config.yaml")
+		configContent := `
+auth:
+  jwt:
+    enabled: true
+    issuer: https://login.example.com
+    audience: stowry
+    cookie: session
+    permissions_claim: groups
+    permissions:
+      editors: [read, write]
+    default_permissions: []
+`
+		require.NoError(t, os.WriteFile(configPath, []byte(configContent), 0o644))
+
+		cfg, err := config.Load([]string{configPath}, nil)
+		require.NoError(t, err)
+
+		jwt := cfg.Auth.JWT
+		assert.True(t, jwt.Enabled)
+		assert.Equal(t, "https://login.example.com", jwt.Issuer)
+		assert.Equal(t, "stowry", jwt.Audience)
+		assert.Equal(t, "session", jwt.Cookie)
+		assert.Equal(t, "groups", jwt.PermissionsClaim)
+		assert.Equal(t, map[string][]stowryhttp.Permission{"editors": {"read", "write"}}, jwt.Permissions)
+		assert.NotNil(t, jwt.DefaultPermissions, "an empty list grants nothing by default")
+		assert.Empty(t, jwt.DefaultPermissions)
+	})
+
+	t.Run("enabled without audience", func(t *testing.T) {
+		configPath := filepath.Join(t.TempDir(), "config.yaml")
+		require.NoError(t, os.WriteFile(configPath, []byte("auth:\n  jwt:\n    enabled: true\n    issuer: https://login.example.com\n"), 0o644))
+
+		_, err := config.Load([]string{configPath}, nil)
+		assert.ErrorContains(t, err, "audience is required")
+	})
+}
+
 func TestLoad_EnvironmentVariables(t *testing.T) {
 	// Set environment variables
 	t.Setenv("STOWRY_SERVER_PORT", "9090")
@@ -424,3 +1130,462 @@ log:
 	assert.Contains(t, err.Error(), "validate config")
 	assert.Contains(t, err.Error(), "invalid metadata table name")
 }
+
+func TestLoad_ImmutableAssets(t *testing.T) {
+	tmpDir := t.TempDir()
+	configPath := filepath.Join(tmpDir, "config.yaml")
+
+	configContent := `
+server:
+  mode: static
+  immutable_assets: true
+`
+	err := os.WriteFile(configPath, []byte(configContent), 0o644)
+	require.NoError(t, err)
+
+	cfg, err := config.Load([]string{configPath}, nil)
+	require.NoError(t, err)
+
+	assert.True(t, cfg.Server.ImmutableAssets)
+	assert.Equal(t, stowryhttp.DefaultImmutablePattern, cfg.Server.ImmutablePattern)
+}
+
+func TestLoad_ValidationError_InvalidImmutablePattern(t *testing.T) {
+	tmpDir := t.TempDir()
+	configPath := filepath.Join(tmpDir, "config.yaml")
+
+	configContent := `
+server:
+  immutable_assets: true
+  immutable_pattern: "[unclosed"
+`
+	err := os.WriteFile(configPath, []byte(configContent), 0o644)
+	require.NoError(t, err)
+
+	_, err = config.Load([]string{configPath}, nil)
+	assert.Error(t, err)
+}
+
+func TestLoad_CacheRules(t *testing.T) {
+	tmpDir := t.TempDir()
+	configPath := filepath.Join(tmpDir, "config.yaml")
+
+	configContent := `
+server:
+  mode: spa
+  cache_rules:
+    - pattern: index.html
+      cache_control: no-cache
+    - pattern: /assets/**
+      cache_control: public, max-age=31536000, immutable
+`
+	require.NoError(t, os.WriteFile(configPath, []byte(configContent), 0o644))
+
+	cfg, err := config.Load([]string{configPath}, nil)
+	require.NoError(t, err)
+	assert.Equal(t, []stowryhttp.CacheRule{
+		{Pattern: "index.html", CacheControl: "no-cache"},
+		{Pattern: "/assets/**", CacheControl: "public, max-age=31536000, immutable"},
+	}, cfg.Server.CacheRules)
+
+	for name, rule := range map[string]string{
+		"malformed pattern":     "    - pattern: \"[\"\n      cache_control: no-cache\n",
+		"missing cache control": "    - pattern: index.html\n",
+	} {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "config.yaml")
+			require.NoError(t, os.WriteFile(path, []byte("server:\n  cache_rules:\n"+rule), 0o644))
+
+			_, err := config.Load([]string{path}, nil)
+			assert.ErrorContains(t, err, "cache rule 1")
+		})
+	}
+}
+
+func TestLoad_SecurityHeaders(t *testing.T) {
+	t.Run("defaults", func(t *testing.T) {
+		cfg, err := config.Load([]string{filepath.Join(t.TempDir(), "missing.yaml")}, nil)
+		require.NoError(t, err)
+
+		assert.True(t, cfg.Server.SecurityHeaders.NoSniff)
+		assert.False(t, cfg.Server.SecurityHeaders.Enabled)
+		assert.Equal(t, stowryhttp.DefaultReferrerPolicy, cfg.Server.SecurityHeaders.ReferrerPolicy)
+		assert.Equal(t, []string{"'self'"}, cfg.Server.SecurityHeaders.FrameAncestors)
+	})
+
+	t.Run("overrides from config file", func(t *testing.T) {
+		configPath := filepath.Join(t.TempDir(), "config.yaml")
+		configContent := `
+server:
+  security_headers:
+    enabled: true
+    content_security_policy: "default-src 'self'"
+    overrides:
+      - path: /widget/*
+        frame_ancestors: ["https://partner.example.com"]
+`
+		require.NoError(t, os.WriteFile(configPath, []byte(configContent), 0o644))
+
+		cfg, err := config.Load([]string{configPath}, nil)
+		require.NoError(t, err)
+
+		assert.Equal(t, []stowryhttp.SecurityHeadersOverride{
+			{Path: "/widget/*", FrameAncestors: []string{"https://partner.example.com"}},
+		}, cfg.Server.SecurityHeaders.Overrides)
+	})
+
+	t.Run("malformed policy", func(t *testing.T) {
+		configPath := filepath.Join(t.TempDir(), "config.yaml")
+		configContent := `
+server:
+  security_headers:
+    enabled: true
+    content_security_policy: "default-src 'self; script-src 'none'"
+`
+		require.NoError(t, os.WriteFile(configPath, []byte(configContent), 0o644))
+
+		_, err := config.Load([]string{configPath}, nil)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "unbalanced quotes")
+	})
+}
+
+func TestLoad_SPA(t *testing.T) {
+	t.Run("defaults", func(t *testing.T) {
+		cfg, err := config.Load([]string{filepath.Join(t.TempDir(), "missing.yaml")}, nil)
+		require.NoError(t, err)
+
+		assert.Equal(t, stowryhttp.SPAConfig{Index: "index.html", MissingIndex: stowryhttp.MissingIndexError, Wait: stowryhttp.DefaultSPAWait, NoFallbackPaths: stowryhttp.DefaultSPANoFallbackPaths}, cfg.Server.SPA)
+	})
+
+	t.Run("from config file", func(t *testing.T) {
+		configPath := filepath.Join(t.TempDir(), "config.yaml")
+		configContent := `
+server:
+  mode: spa
+  spa:
+    index: app.html
+    missing_index: wait
+    wait: 3
+    no_fallback_paths: [/robots.txt, /.well-known/*, /ads.txt]
+`
+		require.NoError(t, os.WriteFile(configPath, []byte(configContent), 0o644))
+
+		cfg, err := config.Load([]string{configPath}, nil)
+		require.NoError(t, err)
+		assert.Equal(t, stowryhttp.SPAConfig{
+			Index:           "app.html",
+			MissingIndex:    stowryhttp.MissingIndexWait,
+			Wait:            3,
+			NoFallbackPaths: []string{"/robots.txt", "/.well-known/*", "/ads.txt"},
+		}, cfg.Server.SPA)
+	})
+
+	t.Run("no fallback paths from the environment", func(t *testing.T) {
+		t.Setenv("STOWRY_SERVER_SPA_NO_FALLBACK_PATHS", "/robots.txt,/sitemap.xml")
+		cfg, err := config.Load([]string{filepath.Join(t.TempDir(), "missing.yaml")}, nil)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"/robots.txt", "/sitemap.xml"}, cfg.Server.SPA.NoFallbackPaths)
+	})
+
+	t.Run("invalid no fallback path", func(t *testing.T) {
+		configPath := filepath.Join(t.TempDir(), "config.yaml")
+		require.NoError(t, os.WriteFile(configPath, []byte("server:\n  spa:\n    no_fallback_paths: [\"/assets/*.js\"]\n"), 0o644))
+
+		_, err := config.Load([]string{configPath}, nil)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), `invalid no fallback path "/assets/*.js"`)
+	})
+
+	t.Run("index with a slash", func(t *testing.T) {
+		configPath := filepath.Join(t.TempDir(), "config.yaml")
+		require.NoError(t, os.WriteFile(configPath, []byte("server:\n  spa:\n    index: dist/app.html\n"), 0o644))
+
+		_, err := config.Load([]string{configPath}, nil)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), `spa: index: "dist/app.html" is not a file name`)
+	})
+
+	t.Run("unknown behavior", func(t *testing.T) {
+		configPath := filepath.Join(t.TempDir(), "config.yaml")
+		configContent := `
+server:
+  spa:
+    missing_index: retry
+`
+		require.NoError(t, os.WriteFile(configPath, []byte(configContent), 0o644))
+
+		_, err := config.Load([]string{configPath}, nil)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), `invalid missing index behavior "retry"`)
+	})
+}
+
+func TestLoad_StaticIndexFiles(t *testing.T) {
+	t.Run("defaults", func(t *testing.T) {
+		cfg, err := config.Load([]string{filepath.Join(t.TempDir(), "missing.yaml")}, nil)
+		require.NoError(t, err)
+
+		assert.Equal(t, []string{"index.html"}, cfg.Server.Static.IndexFiles)
+	})
+
+	t.Run("from config file", func(t *testing.T) {
+		configPath := filepath.Join(t.TempDir(), "config.yaml")
+		configContent := `
+server:
+  mode: static
+  static:
+    index_files: [index.htm, default.html]
+`
+		require.NoError(t, os.WriteFile(configPath, []byte(configContent), 0o644))
+
+		cfg, err := config.Load([]string{configPath}, nil)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"index.htm", "default.html"}, cfg.Server.Static.IndexFiles)
+	})
+
+	for name, list := range map[string]string{
+		"empty list":     "[]",
+		"entry is path":  "[index.html, docs/index.html]",
+		"entry is empty": `["", index.html]`,
+	} {
+		t.Run(name, func(t *testing.T) {
+			configPath := filepath.Join(t.TempDir(), "config.yaml")
+			configContent := "server:\n  static:\n    index_files: " + list + "\n"
+			require.NoError(t, os.WriteFile(configPath, []byte(configContent), 0o644))
+
+			_, err := config.Load([]string{configPath}, nil)
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), "static: index files")
+		})
+	}
+}
+
+func TestLoad_Latency(t *testing.T) {
+	t.Run("defaults", func(t *testing.T) {
+		cfg, err := config.Load([]string{filepath.Join(t.TempDir(), "missing.yaml")}, nil)
+		require.NoError(t, err)
+
+		assert.Equal(t, config.LatencyConfig{Enabled: true, Window: 60, Windows: 5}, cfg.Metrics.Latency)
+	})
+
+	t.Run("from config file", func(t *testing.T) {
+		configPath := filepath.Join(t.TempDir(), "config.yaml")
+		configContent := `
+metrics:
+  latency:
+    window: 30
+    windows: 10
+    slo_p99: 250
+`
+		require.NoError(t, os.WriteFile(configPath, []byte(configContent), 0o644))
+
+		cfg, err := config.Load([]string{configPath}, nil)
+		require.NoError(t, err)
+
+		assert.Equal(t, config.LatencyConfig{Enabled: true, Window: 30, Windows: 10, SLOP99: 250}, cfg.Metrics.Latency)
+	})
+
+	t.Run("zero window", func(t *testing.T) {
+		configPath := filepath.Join(t.TempDir(), "config.yaml")
+		require.NoError(t, os.WriteFile(configPath, []byte("metrics:\n  latency:\n    window: 0\n"), 0o644))
+
+		_, err := config.Load([]string{configPath}, nil)
+		assert.Error(t, err)
+	})
+}
+
+func TestLoad_ConcurrencyLimits(t *testing.T) {
+	tmpDir := t.TempDir()
+	configPath := filepath.Join(tmpDir, "config.yaml")
+
+	configContent := `
+server:
+  max_concurrent_writes: 8
+`
+	err := os.WriteFile(configPath, []byte(configContent), 0o644)
+	require.NoError(t, err)
+
+	cfg, err := config.Load([]string{configPath}, nil)
+	require.NoError(t, err)
+
+	assert.Equal(t, 8, cfg.Server.MaxConcurrentWrites)
+	assert.Equal(t, 0, cfg.Server.MaxConcurrentReads)
+	assert.Equal(t, 5, cfg.Server.ConcurrencyWaitTimeout)
+	assert.Empty(t, cfg.Server.ExpvarAddr)
+}
+
+func TestLoad_ValidationError_NegativeConcurrency(t *testing.T) {
+	tmpDir := t.TempDir()
+	configPath := filepath.Join(tmpDir, "config.yaml")
+
+	configContent := `
+server:
+  max_concurrent_writes: -1
+`
+	err := os.WriteFile(configPath, []byte(configContent), 0o644)
+	require.NoError(t, err)
+
+	_, err = config.Load([]string{configPath}, nil)
+	assert.Error(t, err)
+}
+
+func TestLoadWithOptions(t *testing.T) {
+	t.Run("zero options load defaults", func(t *testing.T) {
+		cfg, err := config.LoadWithOptions(config.LoadOptions{})
+		require.NoError(t, err)
+		assert.Equal(t, 5708, cfg.Server.Port)
+	})
+
+	t.Run("env prefix", func(t *testing.T) {
+		t.Setenv("STOWRY_SERVER_PORT", "9090")
+		t.Setenv("EMBED_SERVER_PORT", "7070")
+		t.Setenv("EMBED_CORS_ALLOWED_ORIGINS", "https://a.example")
+
+		cfg, err := config.LoadWithOptions(config.LoadOptions{EnvPrefix: "EMBED"})
+		require.NoError(t, err)
+		assert.Equal(t, 7070, cfg.Server.Port)
+		assert.Equal(t, []string{"https://a.example"}, cfg.CORS.AllowedOrigins)
+	})
+
+	t.Run("env prefix config files", func(t *testing.T) {
+		configPath := filepath.Join(t.TempDir(), "embed.yaml")
+		require.NoError(t, os.WriteFile(configPath, []byte("server:\n  port: 6060\n"), 0o600))
+		t.Setenv("EMBED_CONFIG", configPath)
+
+		cfg, err := config.LoadWithOptions(config.LoadOptions{EnvPrefix: "EMBED"})
+		require.NoError(t, err)
+		assert.Equal(t, 6060, cfg.Server.Port)
+	})
+
+	t.Run("defaults are overridden by files", func(t *testing.T) {
+		configPath := filepath.Join(t.TempDir(), "config.yaml")
+		require.NoError(t, os.WriteFile(configPath, []byte("server:\n  port: 6060\n"), 0o600))
+
+		cfg, err := config.LoadWithOptions(config.LoadOptions{
+			Files:    []string{configPath},
+			Defaults: map[string]any{"server.port": 7000, "storage.path": "/srv/objects"},
+		})
+		require.NoError(t, err)
+		assert.Equal(t, 6060, cfg.Server.Port)
+		assert.Equal(t, "/srv/objects", cfg.Storage.Path)
+	})
+
+	t.Run("flag set", func(t *testing.T) {
+		flags := pflag.NewFlagSet("embed", pflag.ContinueOnError)
+		flags.String("storage-path", "", "")
+		require.NoError(t, flags.Set("storage-path", "/flags"))
+
+		cfg, err := config.LoadWithOptions(config.LoadOptions{FlagSet: flags})
+		require.NoError(t, err)
+		assert.Equal(t, "/flags", cfg.Storage.Path)
+	})
+
+	t.Run("invalid defaults fail validation", func(t *testing.T) {
+		_, err := config.LoadWithOptions(config.LoadOptions{Defaults: map[string]any{"server.mode": "bogus"}})
+		assert.ErrorContains(t, err, "validate config")
+	})
+}
+
+func TestLoadWithOptions_Concurrent(t *testing.T) {
+	dir := t.TempDir()
+	files := map[int]string{}
+	for _, port := range []int{6001, 6002} {
+		path := filepath.Join(dir, fmt.Sprintf("config-%d.yaml", port))
+		content := fmt.Sprintf("server:\n  port: %d\nstorage:\n  path: /data/%d\n", port, port)
+		require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+		files[port] = path
+	}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 100)
+	for i := range 50 {
+		port := 6001 + i%2
+		wg.Go(func() {
+			cfg, err := config.LoadWithOptions(config.LoadOptions{Files: []string{files[port]}})
+			switch {
+			case err != nil:
+				errs <- err
+			case cfg.Server.Port != port || cfg.Storage.Path != fmt.Sprintf("/data/%d", port):
+				errs <- fmt.Errorf("load of %d got port %d and path %s", port, cfg.Server.Port, cfg.Storage.Path)
+			}
+		})
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		t.Error(err)
+	}
+}
+
+func TestConfig_Validate(t *testing.T) {
+	cfg, err := config.Load(nil, nil)
+	require.NoError(t, err)
+	require.NoError(t, cfg.Validate())
+
+	built := *cfg
+	built.Server.Port = 0
+	assert.ErrorContains(t, built.Validate(), "validate config")
+
+	built = *cfg
+	built.Server.SPA.MissingIndex = "retry"
+	assert.ErrorContains(t, built.Validate(), "invalid missing index behavior")
+
+	assert.Error(t, (&config.Config{}).Validate(), "the zero Config is not valid")
+}
+
+func TestLoad_Listeners(t *testing.T) {
+	t.Run("port maps to one listener", func(t *testing.T) {
+		cfg, err := config.Load([]string{writeConfig(t, "server:\n  host: 127.0.0.1\n  port: 7070\n")}, nil)
+		require.NoError(t, err)
+		assert.Empty(t, cfg.Server.Listeners)
+		assert.Equal(t, []stowryhttp.ListenerConfig{{Addr: "127.0.0.1:7070"}}, cfg.Server.EffectiveListeners())
+
+		cfg, err = config.Load([]string{writeConfig(t, "server:\n  host: \"::1\"\n")}, nil)
+		require.NoError(t, err)
+		assert.Equal(t, []stowryhttp.ListenerConfig{{Addr: "[::1]:5708"}}, cfg.Server.EffectiveListeners())
+	})
+
+	t.Run("addresses and objects", func(t *testing.T) {
+		cfg, err := config.Load([]string{writeConfig(t, `
+server:
+  port: 7070
+  listeners:
+    - "[::1]:5708"
+    - 127.0.0.1:5708
+    - addr: 0.0.0.0:8443
+      tls_cert_file: /etc/stowry/cert.pem
+      tls_key_file: /etc/stowry/key.pem
+`)}, nil)
+		require.NoError(t, err)
+		want := []stowryhttp.ListenerConfig{
+			{Addr: "[::1]:5708"},
+			{Addr: "127.0.0.1:5708"},
+			{Addr: "0.0.0.0:8443", TLSCertFile: "/etc/stowry/cert.pem", TLSKeyFile: "/etc/stowry/key.pem"},
+		}
+		assert.Equal(t, want, cfg.Server.Listeners)
+		assert.Equal(t, want, cfg.Server.EffectiveListeners(), "port is ignored")
+	})
+
+	t.Run("from the environment", func(t *testing.T) {
+		t.Setenv("STOWRY_SERVER_LISTENERS_0_ADDR", "[::1]:5708")
+		t.Setenv("STOWRY_SERVER_LISTENERS_1_ADDR", "127.0.0.1:5708")
+
+		cfg, err := config.Load(nil, nil)
+		require.NoError(t, err)
+		assert.Equal(t, []stowryhttp.ListenerConfig{{Addr: "[::1]:5708"}, {Addr: "127.0.0.1:5708"}}, cfg.Server.Listeners)
+	})
+
+	for name, listeners := range map[string]string{
+		"missing port":     `["127.0.0.1"]`,
+		"unbracketed ipv6": `["::1:5708"]`,
+		"duplicate":        `["127.0.0.1:5708", "127.0.0.1:5708"]`,
+		"key without cert": `[{addr: ":8443", tls_key_file: key.pem}]`,
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := config.Load([]string{writeConfig(t, "server:\n  listeners: "+listeners+"\n")}, nil)
+			assert.ErrorContains(t, err, "server.listeners")
+		})
+	}
+}
@@ -25,28 +25,57 @@
 //	// Retrieve later
 //	cfg, err = config.FromContext(ctx)
 //
+// # Programmatic Use
+//
+// Programs embedding Stowry call LoadWithOptions, which needs no cobra
+// command. LoadOptions selects the files, the environment variable prefix,
+// an optional flag set and default overrides. Each call builds its own viper
+// instance, so concurrent loads in one process do not interfere:
+//
+//	cfg, err := config.LoadWithOptions(config.LoadOptions{
+//	    Files:     []string{"/etc/myapp/stowry.yaml"},
+//	    EnvPrefix: "MYAPP_STOWRY",
+//	    Defaults:  map[string]any{"storage.path": "/srv/objects"},
+//	})
+//
+// Config.Validate applies the checks of Load to a Config built or changed in
+// code.
+//
 // # Environment Variables
 //
-// All config keys map to environment variables with STOWRY_ prefix:
+// All config keys map to environment variables with STOWRY_ prefix
+// (LoadOptions.EnvPrefix changes it):
 //   - server.port → STOWRY_SERVER_PORT
 //   - database.type → STOWRY_DATABASE_TYPE
 //   - auth.read → STOWRY_AUTH_READ
 //
+// Lists take a JSON value or indexed variables, and replace the list from
+// config files instead of merging with it:
+//   - STOWRY_AUTH_KEYS='[{"access_key":"AK","secret_key":"SK"}]'
+//   - STOWRY_AUTH_KEYS_0_ACCESS_KEY=AK and STOWRY_AUTH_KEYS_0_SECRET_KEY=SK
+//   - STOWRY_CORS_ALLOWED_ORIGINS=https://a.example,https://b.example
+//
+// STOWRY_CONFIG lists config files, comma-separated, when no --config flag
+// is given.
+//
 // # Configuration Structure
 //
 // The Config struct contains:
-//   - Server: port, mode (store/static/spa), and max_upload_size
-//   - Service: cleanup_timeout for background operations
+//   - Server: host, port or listeners, mode (store/static/spa), max_upload_size, immutable asset caching, and concurrency limits
+//   - Service: cleanup_timeout for background operations and the cleanup_interval schedule
 //   - Database: type, DSN, and table names
-//   - Storage: file storage path
+//   - Storage: file storage path and optional upload temp_dir
 //   - Auth: access control (read/write), AWS settings, and keys
 //   - CORS: cross-origin resource sharing settings
+//   - Compression: response compression encodings, levels, and minimum size
+//   - Metrics: opt-in download count and last-accessed tracking
+//   - Security: severity of startup security checks (see LintSecurity)
 //   - Log: logging level
 //
 // # Validation
 //
 // Configuration is validated using struct tags:
-//   - Port must be 1-65535
+//   - Port must be 1-65535; each listener must be host:port, IPv6 in brackets
 //   - Mode must be store, static, or spa
 //   - Auth read/write must be public or private
 //   - Log level must be debug, info, warn, or error
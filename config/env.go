@@ -0,0 +1,221 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"reflect"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/viper"
+
+	"github.com/sagarc03/stowry"
+	stowryhttp "github.com/sagarc03/stowry/http"
+	"github.com/sagarc03/stowry/keybackend"
+)
+
+// configFilesEnv names the variable, after the prefix, holding a
+// comma-separated list of config files, used when no --config flag is given.
+const configFilesEnv = "CONFIG"
+
+// envList is a list-valued config key that can be set from the environment,
+// either as one variable or as indexed variables:
+//
+//	STOWRY_AUTH_KEYS='[{"access_key":"AK1","secret_key":"SK1"}]'
+//	STOWRY_AUTH_KEYS_0_ACCESS_KEY=AK1
+//	STOWRY_AUTH_KEYS_0_SECRET_KEY=SK1
+//
+// String lists take a JSON array or a comma-separated value, or STOWRY_X_0,
+// STOWRY_X_1 and so on. A list from the environment replaces the list from
+// config files rather than merging with it.
+type envList struct {
+	key  string       // viper key
+	env  string       // variable name after the prefix, e.g. AUTH_KEYS
+	elem reflect.Type // element struct for lists of objects; nil for string lists
+}
+
+var envLists = []envList{
+	{key: "auth.keys.inline", env: "AUTH_KEYS", elem: reflect.TypeFor[keybackend.KeyPair]()},
+	{key: "auth.presign.keys", env: "AUTH_PRESIGN_KEYS"},
+	{key: "auth.jwt.default_permissions", env: "AUTH_JWT_DEFAULT_PERMISSIONS"},
+	{key: "cors.allowed_origins", env: "CORS_ALLOWED_ORIGINS"},
+	{key: "cors.allowed_methods", env: "CORS_ALLOWED_METHODS"},
+	{key: "cors.allowed_headers", env: "CORS_ALLOWED_HEADERS"},
+	{key: "cors.exposed_headers", env: "CORS_EXPOSED_HEADERS"},
+	{key: "compression.encodings", env: "COMPRESSION_ENCODINGS"},
+	{key: "compression.types", env: "COMPRESSION_TYPES"},
+	{key: "server.cache_rules", env: "SERVER_CACHE_RULES", elem: reflect.TypeFor[stowryhttp.CacheRule]()},
+	{key: "server.listeners", env: "SERVER_LISTENERS", elem: reflect.TypeFor[stowryhttp.ListenerConfig]()},
+	{key: "server.redirects", env: "SERVER_REDIRECTS", elem: reflect.TypeFor[stowryhttp.RedirectRule]()},
+	{key: "server.security_headers.frame_ancestors", env: "SERVER_SECURITY_HEADERS_FRAME_ANCESTORS"},
+	{key: "server.security_headers.overrides", env: "SERVER_SECURITY_HEADERS_OVERRIDES", elem: reflect.TypeFor[stowryhttp.SecurityHeadersOverride]()},
+	{key: "server.spa.no_fallback_paths", env: "SERVER_SPA_NO_FALLBACK_PATHS"},
+	{key: "service.limits", env: "SERVICE_LIMITS", elem: reflect.TypeFor[stowry.ObjectLimit]()},
+	{key: "service.content_types", env: "SERVICE_CONTENT_TYPES", elem: reflect.TypeFor[stowry.ContentTypeRule]()},
+	{key: "storage.routes", env: "STORAGE_ROUTES", elem: reflect.TypeFor[StorageRoute]()},
+}
+
+// configFilesFromEnv returns the files listed in <prefix>_CONFIG.
+func configFilesFromEnv(prefix string) []string {
+	var files []string
+	for _, f := range strings.Split(os.Getenv(prefix+"_"+configFilesEnv), ",") {
+		if f = strings.TrimSpace(f); f != "" {
+			files = append(files, f)
+		}
+	}
+	return files
+}
+
+// bindEnvLists sets every list in envLists that the environment provides
+// under prefix, and returns their keys.
+func bindEnvLists(v *viper.Viper, prefix string, environ []string) ([]string, error) {
+	vars := make(map[string]string, len(environ))
+	for _, kv := range environ {
+		if name, value, ok := strings.Cut(kv, "="); ok && strings.HasPrefix(name, prefix+"_") {
+			vars[name] = value
+		}
+	}
+
+	var keys []string
+	for _, l := range envLists {
+		l.env = prefix + "_" + l.env
+		value, err := l.parse(vars)
+		if err != nil {
+			return nil, err
+		}
+		if value != nil {
+			v.Set(l.key, value)
+			keys = append(keys, l.key)
+		}
+	}
+	return keys, nil
+}
+
+// parse returns the list set in vars, or nil when neither form is present.
+func (l envList) parse(vars map[string]string) (any, error) {
+	whole, hasWhole := vars[l.env]
+	indexed, err := l.indexed(vars)
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case hasWhole && len(indexed) > 0:
+		return nil, fmt.Errorf("%s: set either %s or %s_<n>..., not both", l.env, l.env, l.env)
+	case hasWhole && l.elem == nil:
+		return parseStringList(l.env, whole)
+	case hasWhole:
+		var items []map[string]any
+		if err := json.Unmarshal([]byte(whole), &items); err != nil {
+			return nil, fmt.Errorf("%s: must be a JSON array of objects: %w", l.env, err)
+		}
+		for i, item := range items {
+			for field := range item {
+				if err := l.checkField(field); err != nil {
+					return nil, fmt.Errorf("%s: item %d: %w", l.env, i, err)
+				}
+			}
+		}
+		return items, nil
+	case len(indexed) > 0:
+		return indexed, nil
+	}
+	return nil, nil
+}
+
+// indexed collects the STOWRY_X_<n>[_FIELD] variables of l. Indices must
+// run from 0 without gaps.
+func (l envList) indexed(vars map[string]string) ([]any, error) {
+	byIndex := make(map[int]map[string]string)
+	for name, value := range vars {
+		rest, ok := strings.CutPrefix(name, l.env+"_")
+		if !ok {
+			continue
+		}
+		digits, field, _ := strings.Cut(rest, "_")
+		index, err := strconv.Atoi(digits)
+		if err != nil || index < 0 {
+			// Another key sharing the prefix, such as STOWRY_AUTH_KEYS_FILE
+			continue
+		}
+
+		switch {
+		case l.elem == nil && field != "":
+			return nil, fmt.Errorf("%s: %s is a list of strings and takes no field name", name, l.env)
+		case l.elem != nil && field == "":
+			return nil, fmt.Errorf("%s: missing field name, e.g. %s_%d_%s", name, l.env, index, strings.ToUpper(l.fields()[0]))
+		}
+		field = strings.ToLower(field)
+		if l.elem != nil {
+			if err := l.checkField(field); err != nil {
+				return nil, fmt.Errorf("%s: %w", name, err)
+			}
+		}
+
+		if byIndex[index] == nil {
+			byIndex[index] = make(map[string]string)
+		}
+		byIndex[index][field] = value
+	}
+
+	items := make([]any, len(byIndex))
+	for i := range items {
+		fields, ok := byIndex[i]
+		if !ok {
+			return nil, fmt.Errorf("%s: index %d is missing; indices must start at 0 without gaps", l.env, i)
+		}
+		if l.elem == nil {
+			items[i] = fields[""]
+			continue
+		}
+		item := make(map[string]any, len(fields))
+		for field, value := range fields {
+			item[field] = value
+		}
+		items[i] = item
+	}
+	return items, nil
+}
+
+// checkField reports an error unless field is a mapstructure key of l.elem.
+func (l envList) checkField(field string) error {
+	fields := l.fields()
+	if !slices.Contains(fields, field) {
+		return fmt.Errorf("unknown field %q (want %s)", field, strings.Join(fields, ", "))
+	}
+	return nil
+}
+
+// fields returns the sorted mapstructure keys of l.elem.
+func (l envList) fields() []string {
+	var fields []string
+	for i := range l.elem.NumField() {
+		if tag, _, _ := strings.Cut(l.elem.Field(i).Tag.Get("mapstructure"), ","); tag != "" && tag != "-" {
+			fields = append(fields, tag)
+		}
+	}
+	sort.Strings(fields)
+	return fields
+}
+
+// parseStringList accepts a JSON array of strings or a comma-separated value.
+func parseStringList(env, value string) ([]string, error) {
+	if strings.HasPrefix(strings.TrimSpace(value), "[") {
+		var items []string
+		if err := json.Unmarshal([]byte(value), &items); err != nil {
+			return nil, fmt.Errorf("%s: must be a JSON array of strings or a comma-separated list: %w", env, err)
+		}
+		return items, nil
+	}
+
+	items := []string{}
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items, nil
+}
@@ -0,0 +1,194 @@
+package config_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sagarc03/stowry"
+	"github.com/sagarc03/stowry/config"
+	"github.com/sagarc03/stowry/keybackend"
+)
+
+func writeConfig(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+	return path
+}
+
+func TestLoad_EnvLists(t *testing.T) {
+	fileKeys := writeConfig(t, `
+auth:
+  keys:
+    inline:
+      - access_key: FILEKEY
+        secret_key: filesecret
+cors:
+  allowed_origins: ["https://file.example"]
+`)
+
+	t.Run("keys as JSON", func(t *testing.T) {
+		t.Setenv("STOWRY_AUTH_KEYS", `[{"access_key":"AK1","secret_key":"SK1"},{"access_key":"AK2","secret_key":"SK2"}]`)
+
+		cfg, err := config.Load(nil, nil)
+		require.NoError(t, err)
+
+		assert.Equal(t, []keybackend.KeyPair{{AccessKey: "AK1", SecretKey: "SK1"}, {AccessKey: "AK2", SecretKey: "SK2"}}, cfg.Auth.Keys.Inline)
+	})
+
+	t.Run("keys as indexed variables", func(t *testing.T) {
+		t.Setenv("STOWRY_AUTH_KEYS_0_ACCESS_KEY", "AK1")
+		t.Setenv("STOWRY_AUTH_KEYS_0_SECRET_KEY", "SK1")
+		t.Setenv("STOWRY_AUTH_KEYS_1_ACCESS_KEY", "AK2")
+		t.Setenv("STOWRY_AUTH_KEYS_1_SECRET_KEY", "SK2")
+		t.Setenv("STOWRY_AUTH_KEYS_FILE", "/run/secrets/keys.json")
+		t.Setenv("STOWRY_AUTH_KEYS_EXTRA_FILE", "/run/secrets/pending.json")
+
+		cfg, err := config.Load(nil, nil)
+		require.NoError(t, err)
+
+		assert.Equal(t, []keybackend.KeyPair{{AccessKey: "AK1", SecretKey: "SK1"}, {AccessKey: "AK2", SecretKey: "SK2"}}, cfg.Auth.Keys.Inline)
+		assert.Equal(t, "/run/secrets/keys.json", cfg.Auth.Keys.File, "STOWRY_AUTH_KEYS_FILE is not an indexed key")
+		assert.Equal(t, "/run/secrets/pending.json", cfg.Auth.Keys.ExtraFile)
+	})
+
+	t.Run("env replaces the file list", func(t *testing.T) {
+		t.Setenv("STOWRY_AUTH_KEYS_0_ACCESS_KEY", "ENVKEY")
+		t.Setenv("STOWRY_AUTH_KEYS_0_SECRET_KEY", "envsecret")
+		t.Setenv("STOWRY_CORS_ALLOWED_ORIGINS", "https://a.example, https://b.example")
+
+		cfg, err := config.Load([]string{fileKeys}, nil)
+		require.NoError(t, err)
+
+		assert.Equal(t, []keybackend.KeyPair{{AccessKey: "ENVKEY", SecretKey: "envsecret"}}, cfg.Auth.Keys.Inline)
+		assert.Equal(t, []string{"https://a.example", "https://b.example"}, cfg.CORS.AllowedOrigins)
+	})
+
+	t.Run("file list is kept without env", func(t *testing.T) {
+		cfg, err := config.Load([]string{fileKeys}, nil)
+		require.NoError(t, err)
+
+		assert.Equal(t, []keybackend.KeyPair{{AccessKey: "FILEKEY", SecretKey: "filesecret"}}, cfg.Auth.Keys.Inline)
+		assert.Equal(t, []string{"https://file.example"}, cfg.CORS.AllowedOrigins)
+	})
+
+	t.Run("string lists", func(t *testing.T) {
+		t.Setenv("STOWRY_CORS_ALLOWED_METHODS", `["GET","PUT"]`)
+		t.Setenv("STOWRY_CORS_ALLOWED_HEADERS_0", "Authorization")
+		t.Setenv("STOWRY_CORS_ALLOWED_HEADERS_1", "Content-Type")
+		t.Setenv("STOWRY_AUTH_PRESIGN_KEYS", "backend")
+
+		cfg, err := config.Load(nil, nil)
+		require.NoError(t, err)
+
+		assert.Equal(t, []string{"GET", "PUT"}, cfg.CORS.AllowedMethods)
+		assert.Equal(t, []string{"Authorization", "Content-Type"}, cfg.CORS.AllowedHeaders)
+		assert.Equal(t, []string{"backend"}, cfg.Auth.Presign.Keys)
+	})
+
+	t.Run("limits are decoded and validated", func(t *testing.T) {
+		t.Setenv("STOWRY_SERVICE_LIMITS_0_PREFIX", "cache/")
+		t.Setenv("STOWRY_SERVICE_LIMITS_0_MAX_OBJECTS", "100")
+
+		cfg, err := config.Load(nil, nil)
+		require.NoError(t, err)
+		assert.Equal(t, []stowry.ObjectLimit{{Prefix: "cache/", MaxObjects: 100}}, cfg.Service.Limits)
+
+		t.Setenv("STOWRY_SERVICE_LIMITS_0_MAX_OBJECTS", "0")
+		_, err = config.Load(nil, nil)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "max_objects must be at least 1")
+	})
+
+	errorTests := []struct {
+		name    string
+		env     map[string]string
+		wantErr string
+	}{
+		{
+			name:    "malformed JSON",
+			env:     map[string]string{"STOWRY_AUTH_KEYS": `[{"access_key":"AK1"`},
+			wantErr: "STOWRY_AUTH_KEYS: must be a JSON array of objects",
+		},
+		{
+			name:    "JSON object instead of array",
+			env:     map[string]string{"STOWRY_AUTH_KEYS": `{"access_key":"AK1","secret_key":"SK1"}`},
+			wantErr: "STOWRY_AUTH_KEYS: must be a JSON array of objects",
+		},
+		{
+			name:    "unknown JSON field",
+			env:     map[string]string{"STOWRY_AUTH_KEYS": `[{"access_key":"AK1","secret":"SK1"}]`},
+			wantErr: `item 0: unknown field "secret" (want access_key, secret_key)`,
+		},
+		{
+			name:    "unknown indexed field",
+			env:     map[string]string{"STOWRY_AUTH_KEYS_0_SECRET": "SK1"},
+			wantErr: `STOWRY_AUTH_KEYS_0_SECRET: unknown field "secret"`,
+		},
+		{
+			name:    "indexed object without field",
+			env:     map[string]string{"STOWRY_AUTH_KEYS_0": "AK1"},
+			wantErr: "missing field name, e.g. STOWRY_AUTH_KEYS_0_ACCESS_KEY",
+		},
+		{
+			name:    "index gap",
+			env:     map[string]string{"STOWRY_AUTH_KEYS_0_ACCESS_KEY": "AK1", "STOWRY_AUTH_KEYS_2_ACCESS_KEY": "AK2"},
+			wantErr: "STOWRY_AUTH_KEYS: index 1 is missing",
+		},
+		{
+			name:    "both forms",
+			env:     map[string]string{"STOWRY_AUTH_KEYS": `[]`, "STOWRY_AUTH_KEYS_0_ACCESS_KEY": "AK1"},
+			wantErr: "set either STOWRY_AUTH_KEYS or STOWRY_AUTH_KEYS_<n>..., not both",
+		},
+		{
+			name:    "malformed string list",
+			env:     map[string]string{"STOWRY_CORS_ALLOWED_ORIGINS": `["https://a.example"`},
+			wantErr: "STOWRY_CORS_ALLOWED_ORIGINS: must be a JSON array of strings or a comma-separated list",
+		},
+		{
+			name:    "field on string list",
+			env:     map[string]string{"STOWRY_CORS_ALLOWED_ORIGINS_0_URL": "https://a.example"},
+			wantErr: "takes no field name",
+		},
+	}
+	for _, tt := range errorTests {
+		t.Run(tt.name, func(t *testing.T) {
+			for k, v := range tt.env {
+				t.Setenv(k, v)
+			}
+
+			_, err := config.Load(nil, nil)
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), "read environment")
+			assert.Contains(t, err.Error(), tt.wantErr)
+		})
+	}
+}
+
+func TestLoad_ConfigFilesFromEnv(t *testing.T) {
+	base := writeConfig(t, "server:\n  port: 7000\n  mode: static\n")
+	override := writeConfig(t, "server:\n  port: 7001\n")
+
+	t.Run("comma-separated files merge left to right", func(t *testing.T) {
+		t.Setenv("STOWRY_CONFIG", base+", "+override)
+
+		cfg, err := config.Load(nil, nil)
+		require.NoError(t, err)
+
+		assert.Equal(t, 7001, cfg.Server.Port)
+		assert.Equal(t, "static", cfg.Server.Mode)
+	})
+
+	t.Run("explicit files take precedence", func(t *testing.T) {
+		t.Setenv("STOWRY_CONFIG", override)
+
+		cfg, err := config.Load([]string{base}, nil)
+		require.NoError(t, err)
+
+		assert.Equal(t, 7000, cfg.Server.Port)
+	})
+}
@@ -18,6 +18,19 @@ func ExampleLoad() {
 	// Output: Port: 5708, Mode: store
 }
 
+func ExampleLoadWithOptions() {
+	// Load without cobra, with a different default
+	cfg, err := config.LoadWithOptions(config.LoadOptions{
+		EnvPrefix: "EXAMPLE_STOWRY",
+		Defaults:  map[string]any{"server.port": 8080},
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Printf("Port: %d\n", cfg.Server.Port)
+	// Output: Port: 8080
+}
+
 func ExampleWithContext() {
 	cfg, _ := config.Load(nil, nil)
 
@@ -0,0 +1,4 @@
+package config
+
+// RedactDSN exposes redactDSN to tests.
+var RedactDSN = redactDSN
@@ -0,0 +1,200 @@
+package config
+
+import (
+	"fmt"
+	"log/slog"
+	"net"
+	"slices"
+	"strings"
+
+	stowryhttp "github.com/sagarc03/stowry/http"
+	"github.com/sagarc03/stowry/keybackend"
+)
+
+// Severity is the importance of a security finding.
+type Severity string
+
+const (
+	SeverityOff     Severity = "off"
+	SeverityWarning Severity = "warning"
+	SeverityError   Severity = "error"
+)
+
+// Security lint rule identifiers. Use them as keys in security.rules to
+// override a rule's severity.
+const (
+	RulePublicWrite          = "public-write"
+	RuleWeakSecret           = "weak-secret"
+	RuleSecretEqualsKey      = "secret-equals-access-key"
+	RulePlaintextCredentials = "plaintext-credentials"
+	RuleCORSWildcardCreds    = "cors-wildcard-credentials"
+	RulePublicBackup         = "public-backup"
+	RulePublicAdminJobs      = "public-admin-jobs"
+	RulePublicSupportBundle  = "public-support-bundle"
+)
+
+// minSecretKeyLength is the shortest secret key accepted without a finding.
+const minSecretKeyLength = 16
+
+// SecurityConfig controls the startup security lint.
+type SecurityConfig struct {
+	// Strict treats every warning as an error.
+	Strict bool `mapstructure:"strict"`
+	// Rules overrides the severity of individual rules (off, warning, error).
+	Rules map[string]string `mapstructure:"rules" validate:"dive,oneof=off warning error"`
+}
+
+// SecurityFinding is a single result of the security lint.
+type SecurityFinding struct {
+	Rule     string   `json:"rule"`
+	Severity Severity `json:"severity"`
+	Message  string   `json:"message"`
+}
+
+// LintSecurity checks the configuration for insecure combinations. Every rule
+// reports a warning by default; security.rules raises or silences individual
+// rules, and security.strict promotes all warnings to errors.
+func (c *Config) LintSecurity() []SecurityFinding {
+	var findings []SecurityFinding
+	add := func(rule, format string, args ...any) {
+		severity := c.ruleSeverity(rule)
+		if severity == SeverityOff {
+			return
+		}
+		findings = append(findings, SecurityFinding{
+			Rule:     rule,
+			Severity: severity,
+			Message:  fmt.Sprintf(format, args...),
+		})
+	}
+
+	exposed := c.exposedListeners(false)
+
+	if len(exposed) > 0 && c.Server.Mode == "store" && c.Auth.Write == "public" {
+		add(RulePublicWrite, "write access is public and the server listens on %s", describeListeners(exposed))
+	}
+
+	if c.Server.BackupEndpoint && c.Server.Mode == "store" && c.Auth.Write == "public" {
+		add(RulePublicBackup, "the backup endpoint is enabled and write access is public, so anyone can download the metadata database")
+	}
+
+	if c.Server.AdminJobs && c.Server.Mode == "store" && c.Auth.Write == "public" {
+		add(RulePublicAdminJobs, "admin jobs are enabled and write access is public, so anyone can start cleanup of soft-deleted objects")
+	}
+
+	if c.Server.SupportBundleEndpoint && c.Server.Mode == "store" && c.Auth.Write == "public" {
+		add(RulePublicSupportBundle, "the support bundle endpoint is enabled and write access is public, so anyone can read the redacted configuration and recent errors")
+	}
+
+	for _, key := range c.lintKeys() {
+		if len(key.SecretKey) < minSecretKeyLength {
+			add(RuleWeakSecret, "secret key for access key %q is shorter than %d characters", key.AccessKey, minSecretKeyLength)
+		}
+		if key.SecretKey == key.AccessKey {
+			add(RuleSecretEqualsKey, "secret key for access key %q equals the access key", key.AccessKey)
+		}
+	}
+
+	// Listeners without a certificate speak plain HTTP; TLS must be
+	// terminated by a proxy in front of them
+	if plain := c.exposedListeners(true); len(plain) > 0 && (c.Auth.Read == "private" || c.Auth.Write == "private") {
+		add(RulePlaintextCredentials, "auth is private but the server speaks plain HTTP on %s; terminate TLS in front of it, set a listener certificate or listen on loopback", describeListeners(plain))
+	}
+
+	if c.CORS.Enabled && c.CORS.AllowCredentials && slices.Contains(c.CORS.AllowedOrigins, "*") {
+		add(RuleCORSWildcardCreds, "CORS allows any origin (*) together with credentials")
+	}
+
+	return findings
+}
+
+// LogSecurityFindings writes each finding as a structured log entry and
+// returns an error when any finding has error severity.
+func LogSecurityFindings(findings []SecurityFinding) error {
+	errCount := 0
+	for _, f := range findings {
+		if f.Severity == SeverityError {
+			errCount++
+			slog.Error("security check failed", "rule", f.Rule, "message", f.Message)
+			continue
+		}
+		slog.Warn("security check", "rule", f.Rule, "message", f.Message)
+	}
+
+	if errCount > 0 {
+		return fmt.Errorf("security lint: %d error(s); see log for details", errCount)
+	}
+	return nil
+}
+
+func (c *Config) ruleSeverity(rule string) Severity {
+	severity := SeverityWarning
+	if override, ok := c.Security.Rules[rule]; ok {
+		severity = Severity(override)
+	}
+	if c.Security.Strict && severity == SeverityWarning {
+		severity = SeverityError
+	}
+	return severity
+}
+
+// lintKeys returns inline keys plus keys from the keys file and the extra keys
+// file. A file that cannot be read is skipped here; loading it at startup
+// reports the error.
+func (c *Config) lintKeys() []keybackend.KeyPair {
+	keys := slices.Clone(c.Auth.Keys.Inline)
+	for _, path := range []string{c.Auth.Keys.File, c.Auth.Keys.ExtraFile} {
+		if path == "" {
+			continue
+		}
+		fileKeys, err := keybackend.LoadKeysFromFile(path)
+		if err != nil {
+			continue
+		}
+		start := len(keys)
+		for accessKey, secretKey := range fileKeys {
+			keys = append(keys, keybackend.KeyPair{AccessKey: accessKey, SecretKey: secretKey})
+		}
+		slices.SortFunc(keys[start:], func(a, b keybackend.KeyPair) int {
+			return strings.Compare(a.AccessKey, b.AccessKey)
+		})
+	}
+	return keys
+}
+
+// exposedListeners returns the listeners that accept connections from other
+// hosts; with plainOnly, only those serving plain HTTP.
+func (c *Config) exposedListeners(plainOnly bool) []stowryhttp.ListenerConfig {
+	var exposed []stowryhttp.ListenerConfig
+	for _, l := range c.Server.EffectiveListeners() {
+		if isLoopbackHost(l.Host()) || (plainOnly && l.TLS()) {
+			continue
+		}
+		exposed = append(exposed, l)
+	}
+	return exposed
+}
+
+// describeListeners names listeners for a finding, such as "all interfaces
+// port 5708, 10.0.0.5 port 8080".
+func describeListeners(listeners []stowryhttp.ListenerConfig) string {
+	descriptions := make([]string, len(listeners))
+	for i, l := range listeners {
+		host, port, _ := net.SplitHostPort(l.Addr)
+		if host == "" {
+			host = "all interfaces"
+		}
+		descriptions[i] = fmt.Sprintf("%s port %s", host, port)
+	}
+	return strings.Join(descriptions, ", ")
+}
+
+// isLoopbackHost reports whether host only accepts local connections.
+// An empty host binds every interface and is not loopback.
+func isLoopbackHost(host string) bool {
+	if strings.EqualFold(host, "localhost") {
+		return true
+	}
+	ip := net.ParseIP(strings.Trim(host, "[]"))
+	return ip != nil && ip.IsLoopback()
+}
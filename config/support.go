@@ -0,0 +1,196 @@
+package config
+
+import (
+	"maps"
+	"net/url"
+	"os"
+	"reflect"
+	"regexp"
+	"strings"
+
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+
+	"github.com/sagarc03/stowry/supportbundle"
+)
+
+// Source is where a configuration value was set.
+type Source string
+
+const (
+	SourceDefault Source = "default"
+	SourceFile    Source = "file"
+	SourceEnv     Source = "env"
+	SourceFlag    Source = "flag"
+)
+
+// Sources returns where each configuration key was set, keyed like
+// "server.port", following the precedence of Load: a key set both in a file
+// and the environment reports SourceEnv. It is empty for a Config that was
+// not loaded.
+func (c *Config) Sources() map[string]Source {
+	return maps.Clone(c.sources)
+}
+
+// keySources works out the Source of every configuration key v knows.
+// envKeys are the list keys bindEnvLists set.
+func keySources(v *viper.Viper, prefix string, envKeys []string, flags *pflag.FlagSet) map[string]Source {
+	sections := make(map[string]bool)
+	t := reflect.TypeFor[Config]()
+	for i := range t.NumField() {
+		if tag := t.Field(i).Tag.Get("mapstructure"); tag != "" {
+			sections[tag] = true
+		}
+	}
+
+	sources := make(map[string]Source)
+	for _, key := range v.AllKeys() {
+		// Flags of other commands, such as --config, bind to keys outside
+		// the configuration
+		if section, _, _ := strings.Cut(key, "."); !sections[section] {
+			continue
+		}
+		sources[key] = SourceDefault
+		if v.InConfig(key) {
+			sources[key] = SourceFile
+		}
+		if value, ok := os.LookupEnv(prefix + "_" + strings.ToUpper(strings.ReplaceAll(key, ".", "_"))); ok && value != "" {
+			sources[key] = SourceEnv
+		}
+	}
+	for _, key := range envKeys {
+		sources[key] = SourceEnv
+	}
+	if flags != nil {
+		flags.Visit(func(f *pflag.Flag) {
+			if _, ok := sources[flagKey(f.Name)]; ok {
+				sources[flagKey(f.Name)] = SourceFlag
+			}
+		})
+	}
+	return sources
+}
+
+// secretSettings are the keys, with list items sharing their list's key,
+// whose values are replaced by Redacted.
+var secretSettings = map[string]bool{
+	"auth.keys.inline.access_key": true,
+	"auth.keys.inline.secret_key": true,
+	"auth.presign.signing_key":    true,
+	"auth.presign.keys":           true,
+}
+
+// Redacted returns the configuration as nested maps keyed like a config
+// file, with access keys, secret keys and the database password replaced by
+// supportbundle.Redacted, for support bundles.
+func (c *Config) Redacted() map[string]any {
+	m, _ := redactValue("", reflect.ValueOf(*c)).(map[string]any)
+	return m
+}
+
+// redactValue converts v, found at key, to maps, slices and plain values,
+// redacting secret settings.
+func redactValue(key string, v reflect.Value) any {
+	switch v.Kind() {
+	case reflect.Pointer, reflect.Interface:
+		if v.IsNil() {
+			return nil
+		}
+		return redactValue(key, v.Elem())
+	case reflect.Struct:
+		m := make(map[string]any)
+		for i := range v.NumField() {
+			field := v.Type().Field(i)
+			tag, _, _ := strings.Cut(field.Tag.Get("mapstructure"), ",")
+			if !field.IsExported() || tag == "" || tag == "-" {
+				continue
+			}
+			m[tag] = redactValue(strings.TrimPrefix(key+"."+tag, "."), v.Field(i))
+		}
+		return m
+	case reflect.Slice, reflect.Array:
+		if v.Kind() == reflect.Slice && v.IsNil() {
+			return nil
+		}
+		items := make([]any, v.Len())
+		for i := range items {
+			items[i] = redactValue(key, v.Index(i))
+		}
+		return items
+	case reflect.Map:
+		if v.IsNil() {
+			return nil
+		}
+		m := make(map[string]any, v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			m[iter.Key().String()] = redactValue(key, iter.Value())
+		}
+		return m
+	case reflect.String:
+		switch {
+		case key == "database.dsn":
+			redacted, _ := redactDSN(v.String())
+			return redacted
+		case secretSettings[key] && v.String() != "":
+			return supportbundle.Redacted
+		}
+		return v.String()
+	default:
+		return v.Interface()
+	}
+}
+
+// Secrets returns the secret values of the configuration: access and secret
+// keys, inline and from the keys files, the presign keys and the database
+// password, for scrubbing them from text that may quote them.
+func (c *Config) Secrets() []string {
+	var secrets []string
+	for _, key := range c.lintKeys() {
+		secrets = append(secrets, key.AccessKey, key.SecretKey)
+	}
+	secrets = append(secrets, c.Auth.Presign.SigningKey)
+	secrets = append(secrets, c.Auth.Presign.Keys...)
+	_, passwords := redactDSN(c.Database.DSN)
+	return append(secrets, passwords...)
+}
+
+// dsnPassword matches a password in a key=value DSN, such as
+// "host=db password=secret", or in the query of a URL DSN.
+var dsnPassword = regexp.MustCompile(`(?i)(\bpassword\s*=\s*)('(?:[^'\\]|\\.)*'|[^\s&]+)`)
+
+// redactDSN replaces the password in dsn, as URL user info or a password
+// parameter, with supportbundle.Redacted. It also returns the passwords,
+// decoded and as written.
+func redactDSN(dsn string) (string, []string) {
+	var passwords []string
+
+	if scheme, rest, ok := strings.Cut(dsn, "://"); ok {
+		authority := rest
+		if i := strings.IndexAny(rest, "/?#"); i >= 0 {
+			authority = rest[:i]
+		}
+		if at := strings.LastIndex(authority, "@"); at >= 0 {
+			if user, password, ok := strings.Cut(authority[:at], ":"); ok && password != "" {
+				passwords = append(passwords, password)
+				if decoded, err := url.PathUnescape(password); err == nil {
+					passwords = append(passwords, decoded)
+				}
+				dsn = scheme + "://" + user + ":" + supportbundle.Redacted + rest[at:]
+			}
+		}
+	}
+
+	dsn = dsnPassword.ReplaceAllStringFunc(dsn, func(match string) string {
+		parts := dsnPassword.FindStringSubmatch(match)
+		password := parts[2]
+		passwords = append(passwords, password)
+		if unquoted := strings.Trim(password, "'"); unquoted != password {
+			passwords = append(passwords, strings.ReplaceAll(unquoted, `\'`, `'`))
+		} else if decoded, err := url.QueryUnescape(password); err == nil {
+			passwords = append(passwords, decoded)
+		}
+		return parts[1] + supportbundle.Redacted
+	})
+	return dsn, passwords
+}
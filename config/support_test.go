@@ -0,0 +1,129 @@
+package config_test
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/pflag"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sagarc03/stowry/config"
+	"github.com/sagarc03/stowry/supportbundle"
+)
+
+func TestConfig_Sources(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(configPath, []byte("server:\n  port: 8080\n  mode: static\nlog:\n  level: debug\n"), 0o644))
+	t.Setenv("STOWRY_SERVER_MODE", "store")
+	flags := pflag.NewFlagSet("test", pflag.ContinueOnError)
+	flags.String("db-type", "sqlite", "db type")
+	require.NoError(t, flags.Set("db-type", "postgres"))
+
+	cfg, err := config.Load([]string{configPath}, flags)
+	require.NoError(t, err)
+
+	sources := cfg.Sources()
+	assert.Equal(t, config.SourceFile, sources["server.port"])
+	assert.Equal(t, config.SourceFile, sources["log.level"])
+	assert.Equal(t, config.SourceEnv, sources["server.mode"], "the environment overrides the file")
+	assert.Equal(t, config.SourceFlag, sources["database.type"])
+	assert.Equal(t, config.SourceDefault, sources["storage.path"])
+	assert.NotContains(t, sources, "config", "flags outside the configuration")
+
+	assert.Empty(t, (&config.Config{}).Sources())
+}
+
+func TestRedactDSN(t *testing.T) {
+	tests := []struct {
+		dsn       string
+		want      string
+		passwords []string
+	}{
+		{dsn: "stowry.db", want: "stowry.db"},
+		{dsn: "file:stowry.db?_pragma=busy_timeout(5000)", want: "file:stowry.db?_pragma=busy_timeout(5000)"},
+		{dsn: "postgres://app:s3cr%2Ft@db:5432/stowry?sslmode=disable", want: "postgres://app:[REDACTED]@db:5432/stowry?sslmode=disable", passwords: []string{"s3cr%2Ft", "s3cr/t"}},
+		{dsn: "postgres://app@db/stowry", want: "postgres://app@db/stowry"},
+		{dsn: "postgres://db/stowry?user=app&password=hunter2", want: "postgres://db/stowry?user=app&password=[REDACTED]", passwords: []string{"hunter2", "hunter2"}},
+		{dsn: `host=db user=app password='it\'s secret' dbname=stowry`, want: "host=db user=app password=[REDACTED] dbname=stowry", passwords: []string{`'it\'s secret'`, "it's secret"}},
+		{dsn: "host=db PASSWORD = hunter2 dbname=stowry", want: "host=db PASSWORD = [REDACTED] dbname=stowry", passwords: []string{"hunter2", "hunter2"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.dsn, func(t *testing.T) {
+			got, passwords := config.RedactDSN(tt.dsn)
+			assert.Equal(t, tt.want, got)
+			if tt.passwords != nil {
+				assert.Equal(t, tt.passwords, passwords)
+			}
+			for _, p := range passwords {
+				assert.NotContains(t, got, p)
+			}
+		})
+	}
+}
+
+// TestSupportBundle_Redaction writes a support bundle from a configuration
+// full of secrets, quoted in the collected values and in errors, and checks
+// that none of them appear in any file.
+func TestSupportBundle_Redaction(t *testing.T) {
+	dir := t.TempDir()
+	keysPath := filepath.Join(dir, "keys.json")
+	require.NoError(t, os.WriteFile(keysPath, []byte(`[{"access_key": "AKIAFILEKEY0001", "secret_key": "file-secret-0001"}]`), 0o600))
+	configPath := filepath.Join(dir, "config.yaml")
+	require.NoError(t, os.WriteFile(configPath, []byte(`
+database:
+  type: postgres
+  dsn: postgres://stowry:pg-p%40ss-0001@db:5432/stowry?password=query-pass-0001
+auth:
+  read: private
+  write: private
+  keys:
+    file: `+keysPath+`
+    inline:
+      - access_key: AKIAINLINEKEY001
+        secret_key: inline-secret-0001
+  presign:
+    enabled: true
+    signing_key: AKIAINLINEKEY001
+    keys: [AKIAPRESIGNKEY01]
+`), 0o600))
+
+	cfg, err := config.Load([]string{configPath}, nil)
+	require.NoError(t, err)
+
+	secrets := []string{
+		"AKIAFILEKEY0001", "file-secret-0001",
+		"AKIAINLINEKEY001", "inline-secret-0001",
+		"AKIAPRESIGNKEY01",
+		"pg-p%40ss-0001", "pg-p@ss-0001", "query-pass-0001",
+	}
+	sections := []supportbundle.Section{
+		{Name: "config", Collect: func(context.Context) (any, error) {
+			return map[string]any{"values": cfg.Redacted(), "sources": cfg.Sources(), "security_findings": cfg.LintSecurity()}, nil
+		}},
+		{Name: "quoted", Collect: func(context.Context) (any, error) {
+			return map[string]any{"dsn": cfg.Database.DSN, "keys": secrets}, nil
+		}},
+		{Name: "failed", Collect: func(context.Context) (any, error) {
+			return nil, &os.PathError{Op: "connect", Path: cfg.Database.DSN, Err: os.ErrPermission}
+		}},
+	}
+
+	var buf bytes.Buffer
+	require.NoError(t, supportbundle.Write(context.Background(), &buf, supportbundle.NewRedactor(cfg.Secrets()...), sections))
+	files, err := supportbundle.Read(&buf)
+	require.NoError(t, err)
+	require.Len(t, files, 3)
+
+	for name, data := range files {
+		for _, secret := range secrets {
+			assert.NotContains(t, string(data), secret, "%s holds %s", name, secret)
+		}
+	}
+	assert.Contains(t, string(files["stowry-support/config.json"]), "postgres://stowry:[REDACTED]@db:5432/stowry?password=[REDACTED]")
+	assert.Contains(t, string(files["stowry-support/config.json"]), `"secret_key": "[REDACTED]"`)
+	assert.Contains(t, string(files["stowry-support/config.json"]), `"signing_key": "[REDACTED]"`)
+}
@@ -0,0 +1,171 @@
+package stowry
+
+import (
+	"fmt"
+	"mime"
+	"slices"
+	"strings"
+)
+
+// MaxContentTypeLength is the longest Content-Type value accepted on upload.
+const MaxContentTypeLength = 256
+
+// NormalizeContentType validates a Content-Type value and returns its
+// canonical form: the lowercased type/subtype followed by the charset
+// parameter, if any. Other parameters are dropped, since the value is served
+// back verbatim on every download.
+//
+// Returns:
+//   - string: The normalized content type
+//   - error: ErrInvalidContentType (which also matches ErrInvalidInput) for a
+//     value that is too long, contains control characters, or is not a
+//     type/subtype media type
+func NormalizeContentType(value string) (string, error) {
+	if len(value) > MaxContentTypeLength {
+		return "", fmt.Errorf("%w: longer than %d bytes", ErrInvalidContentType, MaxContentTypeLength)
+	}
+	if strings.ContainsFunc(value, func(r rune) bool { return r < 0x20 || r == 0x7f }) {
+		return "", fmt.Errorf("%w: contains control characters", ErrInvalidContentType)
+	}
+
+	mediaType, params, err := mime.ParseMediaType(value)
+	if err != nil {
+		return "", fmt.Errorf("%w: %w", ErrInvalidContentType, err)
+	}
+	typ, subtype, ok := strings.Cut(mediaType, "/")
+	if !ok || typ == "" || subtype == "" || strings.Contains(subtype, "/") {
+		return "", fmt.Errorf("%w: %q is not a type/subtype", ErrInvalidContentType, mediaType)
+	}
+
+	var kept map[string]string
+	if charset := params["charset"]; charset != "" {
+		kept = map[string]string{"charset": strings.ToLower(charset)}
+	}
+	normalized := mime.FormatMediaType(mediaType, kept)
+	if normalized == "" {
+		return "", fmt.Errorf("%w: cannot format %q", ErrInvalidContentType, mediaType)
+	}
+	return normalized, nil
+}
+
+// ContentTypeRule restricts the content types that may be uploaded under
+// Prefix. An empty Prefix applies to every object. Patterns are a media type
+// such as "text/html" or a wildcard such as "image/*" or "*/*"; parameters
+// are ignored when matching.
+type ContentTypeRule struct {
+	Prefix string   `mapstructure:"prefix"`
+	Allow  []string `mapstructure:"allow"` // Empty allows every type not denied
+	Deny   []string `mapstructure:"deny"`  // Checked before Allow
+}
+
+// ContentTypeError reports an upload whose content type a ContentTypeRule
+// forbids. It wraps ErrContentTypeNotAllowed.
+type ContentTypeError struct {
+	Prefix      string
+	ContentType string // Media type of the upload, without parameters
+}
+
+func (e *ContentTypeError) Error() string {
+	return fmt.Sprintf("%s: %s under prefix %q", ErrContentTypeNotAllowed, e.ContentType, e.Prefix)
+}
+
+func (e *ContentTypeError) Unwrap() error {
+	return ErrContentTypeNotAllowed
+}
+
+// ContentTypePolicy enforces ContentTypeRules on uploads. The rule with the
+// longest matching prefix decides, so a rule for a narrower prefix replaces a
+// broader one instead of adding to it.
+type ContentTypePolicy struct {
+	rules []ContentTypeRule // Longest prefix first
+}
+
+// NewContentTypePolicy validates rules and creates a policy. It returns nil
+// when rules is empty, which allows every content type.
+func NewContentTypePolicy(rules []ContentTypeRule) (*ContentTypePolicy, error) {
+	if len(rules) == 0 {
+		return nil, nil
+	}
+
+	normalized, err := normalizeContentTypeRules(rules)
+	if err != nil {
+		return nil, err
+	}
+	slices.SortStableFunc(normalized, func(a, b ContentTypeRule) int {
+		return len(b.Prefix) - len(a.Prefix)
+	})
+	return &ContentTypePolicy{rules: normalized}, nil
+}
+
+// ValidateContentTypeRules reports the first invalid rule: a prefix listed
+// twice, a rule without patterns, or a malformed pattern.
+func ValidateContentTypeRules(rules []ContentTypeRule) error {
+	_, err := normalizeContentTypeRules(rules)
+	return err
+}
+
+// normalizeContentTypeRules validates rules and lowercases their patterns.
+func normalizeContentTypeRules(rules []ContentTypeRule) ([]ContentTypeRule, error) {
+	normalized := make([]ContentTypeRule, 0, len(rules))
+	seen := make(map[string]bool, len(rules))
+	for i, rule := range rules {
+		switch {
+		case seen[rule.Prefix]:
+			return nil, fmt.Errorf("content type rule %d (%s): duplicate prefix", i+1, rule.Prefix)
+		case len(rule.Allow) == 0 && len(rule.Deny) == 0:
+			return nil, fmt.Errorf("content type rule %d (%s): allow or deny is required", i+1, rule.Prefix)
+		}
+		seen[rule.Prefix] = true
+
+		out := ContentTypeRule{Prefix: rule.Prefix}
+		for _, list := range []struct {
+			src []string
+			dst *[]string
+		}{{rule.Allow, &out.Allow}, {rule.Deny, &out.Deny}} {
+			for _, pattern := range list.src {
+				p := strings.ToLower(strings.TrimSpace(pattern))
+				typ, subtype, ok := strings.Cut(p, "/")
+				if !ok || typ == "" || subtype == "" || (typ == "*" && subtype != "*") {
+					return nil, fmt.Errorf("content type rule %d (%s): invalid pattern %q (want type/subtype, type/* or */*)", i+1, rule.Prefix, pattern)
+				}
+				*list.dst = append(*list.dst, p)
+			}
+		}
+		normalized = append(normalized, out)
+	}
+	return normalized, nil
+}
+
+// Check reports whether contentType may be uploaded to path.
+//
+// Returns:
+//   - error: nil when allowed, an *ContentTypeError when a rule forbids the
+//     type, or ErrInvalidContentType when contentType cannot be parsed
+func (p *ContentTypePolicy) Check(path, contentType string) error {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidContentType, err)
+	}
+
+	for _, rule := range p.rules {
+		if !strings.HasPrefix(path, rule.Prefix) {
+			continue
+		}
+		denied := slices.ContainsFunc(rule.Deny, func(pattern string) bool { return matchMediaType(pattern, mediaType) })
+		allowed := len(rule.Allow) == 0 || slices.ContainsFunc(rule.Allow, func(pattern string) bool { return matchMediaType(pattern, mediaType) })
+		if denied || !allowed {
+			return &ContentTypeError{Prefix: rule.Prefix, ContentType: mediaType}
+		}
+		return nil
+	}
+	return nil
+}
+
+// matchMediaType reports whether a lowercased media type matches pattern.
+func matchMediaType(pattern, mediaType string) bool {
+	if pattern == "*/*" || pattern == mediaType {
+		return true
+	}
+	typ, ok := strings.CutSuffix(pattern, "/*")
+	return ok && strings.HasPrefix(mediaType, typ+"/")
+}
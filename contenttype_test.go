@@ -0,0 +1,157 @@
+package stowry_test
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/sagarc03/stowry"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNormalizeContentType(t *testing.T) {
+	valid := []struct {
+		in   string
+		want string
+	}{
+		{in: "text/plain", want: "text/plain"},
+		{in: "Text/HTML; Charset=UTF-8", want: "text/html; charset=utf-8"},
+		{in: `text/plain;charset="iso-8859-1"`, want: "text/plain; charset=iso-8859-1"},
+		{in: "multipart/form-data; boundary=xyz", want: "multipart/form-data"},
+		{in: "application/vnd.api+json ; q=1", want: "application/vnd.api+json"},
+	}
+	for _, tt := range valid {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := stowry.NormalizeContentType(tt.in)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+
+	invalid := []struct {
+		name string
+		in   string
+	}{
+		{name: "newline", in: "text/plain\r\nSet-Cookie: a=b"},
+		{name: "tab", in: "text/plain;\tcharset=utf-8"},
+		{name: "nul", in: "text/plain\x00"},
+		{name: "data uri", in: "data:text/html;base64,PHNjcmlwdD4="},
+		{name: "no subtype", in: "text"},
+		{name: "empty subtype", in: "text/"},
+		{name: "extra slash", in: "text/plain/x"},
+		{name: "garbage", in: ";;;"},
+		{name: "too long", in: "text/plain; charset=" + strings.Repeat("a", stowry.MaxContentTypeLength)},
+	}
+	for _, tt := range invalid {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := stowry.NormalizeContentType(tt.in)
+			assert.ErrorIs(t, err, stowry.ErrInvalidContentType)
+			assert.ErrorIs(t, err, stowry.ErrInvalidInput)
+		})
+	}
+}
+
+func TestContentTypePolicy(t *testing.T) {
+	policy, err := stowry.NewContentTypePolicy([]stowry.ContentTypeRule{
+		{Prefix: "", Deny: []string{"text/html"}},
+		{Prefix: "avatars/", Allow: []string{"Image/*"}, Deny: []string{"image/svg+xml"}},
+		{Prefix: "site/", Allow: []string{"*/*"}},
+	})
+	require.NoError(t, err)
+
+	tests := []struct {
+		path        string
+		contentType string
+		allowed     bool
+		wantPrefix  string
+	}{
+		{path: "docs/a.txt", contentType: "text/plain", allowed: true},
+		{path: "docs/a.html", contentType: "text/html; charset=utf-8", wantPrefix: ""},
+		{path: "avatars/u1.png", contentType: "image/png", allowed: true},
+		{path: "avatars/u1.svg", contentType: "image/svg+xml", wantPrefix: "avatars/"},
+		{path: "avatars/u1.txt", contentType: "text/plain", wantPrefix: "avatars/"},
+		{path: "site/index.html", contentType: "text/html", allowed: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			err := policy.Check(tt.path, tt.contentType)
+			if tt.allowed {
+				assert.NoError(t, err)
+				return
+			}
+			var typeErr *stowry.ContentTypeError
+			require.ErrorAs(t, err, &typeErr)
+			assert.ErrorIs(t, err, stowry.ErrContentTypeNotAllowed)
+			assert.Equal(t, tt.wantPrefix, typeErr.Prefix)
+		})
+	}
+
+	t.Run("no rules", func(t *testing.T) {
+		policy, err := stowry.NewContentTypePolicy(nil)
+		require.NoError(t, err)
+		assert.Nil(t, policy)
+	})
+}
+
+func TestValidateContentTypeRules(t *testing.T) {
+	tests := []struct {
+		name    string
+		rules   []stowry.ContentTypeRule
+		wantErr string
+	}{
+		{name: "duplicate prefix", rules: []stowry.ContentTypeRule{{Prefix: "a/", Deny: []string{"text/html"}}, {Prefix: "a/", Allow: []string{"image/*"}}}, wantErr: "duplicate prefix"},
+		{name: "empty rule", rules: []stowry.ContentTypeRule{{Prefix: "a/"}}, wantErr: "allow or deny is required"},
+		{name: "missing subtype", rules: []stowry.ContentTypeRule{{Deny: []string{"text"}}}, wantErr: `invalid pattern "text"`},
+		{name: "wildcard type", rules: []stowry.ContentTypeRule{{Allow: []string{"*/html"}}}, wantErr: `invalid pattern "*/html"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.ErrorContains(t, stowry.ValidateContentTypeRules(tt.rules), tt.wantErr)
+		})
+	}
+}
+
+func TestStowryService_Create_ContentType(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("normalized before storing", func(t *testing.T) {
+		service, repo, storage := NewStowryService(t)
+		content := bytes.NewBufferString("hi")
+
+		storage.On("Write", ctx, "a.txt", content).Return(stowry.SaveResult{BytesWritten: 2, Etag: "e"}, nil)
+		repo.On("Upsert", ctx, mock.MatchedBy(func(entry stowry.ObjectEntry) bool {
+			return entry.ContentType == "text/plain; charset=utf-8"
+		})).Return(stowry.MetaData{Path: "a.txt"}, nil, nil)
+
+		_, err := service.Create(ctx, stowry.CreateObject{Path: "a.txt", ContentType: "TEXT/Plain; Charset=UTF-8; foo=bar"}, content)
+		require.NoError(t, err)
+		repo.AssertExpectations(t)
+	})
+
+	t.Run("invalid content type", func(t *testing.T) {
+		service, repo, storage := NewStowryService(t)
+
+		_, err := service.Create(ctx, stowry.CreateObject{Path: "a.txt", ContentType: "text/plain\nX-Injected: 1"}, bytes.NewBufferString("hi"))
+		assert.ErrorIs(t, err, stowry.ErrInvalidContentType)
+		storage.AssertNotCalled(t, "Write")
+		repo.AssertNotCalled(t, "Upsert")
+	})
+
+	t.Run("forbidden by a rule", func(t *testing.T) {
+		policy, err := stowry.NewContentTypePolicy([]stowry.ContentTypeRule{{Prefix: "uploads/", Deny: []string{"text/html"}}})
+		require.NoError(t, err)
+		repo, storage := new(SpyMetaDataRepo), new(SpyFileStorage)
+		service, err := stowry.NewStowryService(repo, storage, stowry.ServiceConfig{Mode: stowry.ModeStore, ContentTypes: policy})
+		require.NoError(t, err)
+
+		_, err = service.Create(ctx, stowry.CreateObject{Path: "uploads/x.html", ContentType: "text/html"}, bytes.NewBufferString("<script>"))
+		var typeErr *stowry.ContentTypeError
+		require.ErrorAs(t, err, &typeErr)
+		assert.Equal(t, "uploads/", typeErr.Prefix)
+		assert.Equal(t, "text/html", typeErr.ContentType)
+		storage.AssertNotCalled(t, "Write")
+	})
+}
@@ -0,0 +1,94 @@
+package stowry
+
+import (
+	"context"
+	"fmt"
+)
+
+// Copy copies the active object at srcPath to dstPath on the server, without
+// the client downloading and uploading it again. The copy keeps the source's
+// content type, content encoding, filename, user metadata and Cache-Control,
+// and gets an ID of its own.
+// An object at dstPath is overwritten as by Create. The content is copied
+// with the storage's StorageCopier when it has one, such as a hard link on
+// the filesystem, and streamed from the source otherwise.
+//
+// Parameters:
+//   - ctx: Context for cancellation and timeout
+//   - srcPath: Path of the object to copy, used as is without the static and
+//     SPA fallbacks
+//   - dstPath: Path to copy it to
+//
+// Returns:
+//   - CreateResult: The metadata of the copy, and of the object it
+//     overwrote, if any
+//   - error: ErrNotFound if no object exists at srcPath; ErrInvalidInput for
+//     an invalid source or a destination equal to it; otherwise the errors of
+//     Create for dstPath
+func (s *StowryService) Copy(ctx context.Context, srcPath, dstPath string) (CreateResult, error) {
+	if err := ctx.Err(); err != nil {
+		return CreateResult{}, fmt.Errorf("copy object: %w", err)
+	}
+	if srcPath == "" || !IsValidPath(srcPath) {
+		return CreateResult{}, fmt.Errorf("copy object %s: %w: invalid source path", srcPath, ErrInvalidInput)
+	}
+	if srcPath == dstPath {
+		return CreateResult{}, fmt.Errorf("copy object %s: %w: source and destination are the same", srcPath, ErrInvalidInput)
+	}
+
+	src, err := s.repo.Get(ctx, srcPath)
+	if err != nil {
+		return CreateResult{}, fmt.Errorf("copy object %s: %w", srcPath, err)
+	}
+
+	obj := CreateObject{
+		Path:            dstPath,
+		ContentType:     src.ContentType,
+		Size:            src.FileSizeBytes,
+		ContentEncoding: src.ContentEncoding,
+		Filename:        src.Filename,
+		UserMetadata:    src.UserMetadata,
+		CacheControl:    src.CacheControl,
+	}
+	if err := s.validateCreateObject(obj); err != nil {
+		return CreateResult{}, err
+	}
+	if err := s.checkRepo(ctx, obj.Size); err != nil {
+		return CreateResult{}, fmt.Errorf("copy object %s: %w", dstPath, err)
+	}
+	if s.objectLimiter != nil {
+		if err := s.objectLimiter.admit(ctx, []string{dstPath}); err != nil {
+			return CreateResult{}, fmt.Errorf("copy object %s: %w", dstPath, err)
+		}
+	}
+
+	copier, ok := s.storage.(StorageCopier)
+	if !ok {
+		content, err := s.storageGet(ctx, src.Path)
+		if err != nil {
+			return CreateResult{}, fmt.Errorf("copy object %s: %w", srcPath, err)
+		}
+		defer func() { _ = content.Close() }()
+		return s.createObject(ctx, obj, content)
+	}
+
+	return s.createObjectWith(ctx, obj, func(obj CreateObject) (ObjectEntry, error) {
+		saved, err := copier.Copy(ctx, src.Path, obj.Path)
+		if err != nil {
+			return ObjectEntry{}, fmt.Errorf("copy object %s: %w", srcPath, err)
+		}
+		return ObjectEntry{
+			ID:              obj.ID,
+			Path:            obj.Path,
+			Size:            saved.BytesWritten,
+			ETag:            saved.Etag,
+			ContentType:     obj.ContentType,
+			ContentEncoding: obj.ContentEncoding,
+			DecodedSize:     src.DecodedSizeBytes,
+			Filename:        obj.Filename,
+			UserMetadata:    obj.UserMetadata,
+			CacheControl:    obj.CacheControl,
+			StorageBackend:  s.storageBackend(obj.Path),
+		}, nil
+	})
+}
@@ -0,0 +1,146 @@
+package stowry_test
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/sagarc03/stowry"
+	"github.com/sagarc03/stowry/database/sqlite"
+	"github.com/sagarc03/stowry/filesystem"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newCopyService returns a store-mode service over SQLite and storage.
+func newCopyService(t *testing.T, storage stowry.FileStorage) *stowry.StowryService {
+	t.Helper()
+	ctx := context.Background()
+
+	db, err := sqlite.Connect(ctx, filepath.Join(t.TempDir(), "stowry.db"), stowry.Tables{MetaData: "stowry_metadata"})
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = db.Close() })
+	require.NoError(t, db.Migrate(ctx))
+
+	service, err := stowry.NewStowryService(db.GetRepo(), storage, stowry.ServiceConfig{Mode: stowry.ModeStore})
+	require.NoError(t, err)
+	return service
+}
+
+func newStorageRoot(t *testing.T) *os.Root {
+	t.Helper()
+	root, err := os.OpenRoot(t.TempDir())
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = root.Close() })
+	return root
+}
+
+func readObject(t *testing.T, service *stowry.StowryService, path string) string {
+	t.Helper()
+	_, content, err := service.Get(context.Background(), path)
+	require.NoError(t, err)
+	defer func() { _ = content.Close() }()
+	data, err := io.ReadAll(content)
+	require.NoError(t, err)
+	return string(data)
+}
+
+func TestStowryService_Copy(t *testing.T) {
+	ctx := context.Background()
+	storages := map[string]func(t *testing.T) stowry.FileStorage{
+		"filesystem": func(t *testing.T) stowry.FileStorage {
+			return filesystem.NewFileStorage(newStorageRoot(t))
+		},
+		// Embedding hides memStorage's Copy
+		"without copier": func(*testing.T) stowry.FileStorage { return struct{ stowry.FileStorage }{newMemStorage()} },
+	}
+
+	for name, newStorage := range storages {
+		t.Run(name, func(t *testing.T) {
+			t.Run("copies content and metadata", func(t *testing.T) {
+				service := newCopyService(t, newStorage(t))
+				src, err := service.Create(ctx, stowry.CreateObject{Path: "uploads/tmp/foo.jpg", ContentType: "image/jpeg", Filename: "Foo.jpg", UserMetadata: map[string]string{"uploader": "u-42"}}, strings.NewReader("jpeg bytes"))
+				require.NoError(t, err)
+
+				res, err := service.Copy(ctx, "uploads/tmp/foo.jpg", "images/foo.jpg")
+				require.NoError(t, err)
+
+				sum := sha256.Sum256([]byte("jpeg bytes"))
+				assert.Equal(t, "images/foo.jpg", res.Path)
+				assert.Equal(t, hex.EncodeToString(sum[:]), res.Etag)
+				assert.Equal(t, int64(len("jpeg bytes")), res.FileSizeBytes)
+				assert.Equal(t, "image/jpeg", res.ContentType)
+				assert.Equal(t, "Foo.jpg", res.Filename)
+				assert.Equal(t, map[string]string{"uploader": "u-42"}, res.UserMetadata)
+				assert.NotEqual(t, src.ID, res.ID, "the copy is a new object")
+				assert.Nil(t, res.Previous)
+				assert.Equal(t, "jpeg bytes", readObject(t, service, "images/foo.jpg"))
+				assert.Equal(t, "jpeg bytes", readObject(t, service, "uploads/tmp/foo.jpg"), "the source is kept")
+			})
+
+			t.Run("overwrites the destination", func(t *testing.T) {
+				service := newCopyService(t, newStorage(t))
+				_, err := service.Create(ctx, stowry.CreateObject{Path: "a.txt", ContentType: "text/plain"}, strings.NewReader("new"))
+				require.NoError(t, err)
+				old, err := service.Create(ctx, stowry.CreateObject{Path: "b.txt", ContentType: "text/plain"}, strings.NewReader("old"))
+				require.NoError(t, err)
+
+				res, err := service.Copy(ctx, "a.txt", "b.txt")
+				require.NoError(t, err)
+
+				require.NotNil(t, res.Previous)
+				assert.Equal(t, old.Etag, res.Previous.Etag)
+				assert.Equal(t, "new", readObject(t, service, "b.txt"))
+
+				// Replacing the source later leaves the copy alone
+				_, err = service.Create(ctx, stowry.CreateObject{Path: "a.txt", ContentType: "text/plain"}, strings.NewReader("newer"))
+				require.NoError(t, err)
+				assert.Equal(t, "new", readObject(t, service, "b.txt"))
+			})
+
+			t.Run("missing source", func(t *testing.T) {
+				service := newCopyService(t, newStorage(t))
+
+				_, err := service.Copy(ctx, "missing.txt", "b.txt")
+				assert.ErrorIs(t, err, stowry.ErrNotFound)
+			})
+		})
+	}
+
+	t.Run("invalid paths", func(t *testing.T) {
+		service := newCopyService(t, newMemStorage())
+		_, err := service.Create(ctx, stowry.CreateObject{Path: "a.txt", ContentType: "text/plain"}, strings.NewReader("a"))
+		require.NoError(t, err)
+
+		for name, paths := range map[string][2]string{
+			"invalid source":      {"../a.txt", "b.txt"},
+			"invalid destination": {"a.txt", "../b.txt"},
+			"same path":           {"a.txt", "a.txt"},
+			"reserved":            {"a.txt", stowry.StagePrefix + "b.txt"},
+		} {
+			_, err := service.Copy(ctx, paths[0], paths[1])
+			assert.ErrorIs(t, err, stowry.ErrInvalidInput, name)
+		}
+	})
+
+	t.Run("hard links on the filesystem", func(t *testing.T) {
+		root := newStorageRoot(t)
+		service := newCopyService(t, filesystem.NewFileStorage(root))
+		_, err := service.Create(ctx, stowry.CreateObject{Path: "a.txt", ContentType: "text/plain"}, strings.NewReader("a"))
+		require.NoError(t, err)
+
+		_, err = service.Copy(ctx, "a.txt", "dir/b.txt")
+		require.NoError(t, err)
+
+		src, err := root.Stat("a.txt")
+		require.NoError(t, err)
+		dst, err := root.Stat("dir/b.txt")
+		require.NoError(t, err)
+		assert.True(t, os.SameFile(src, dst))
+	})
+}
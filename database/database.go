@@ -2,9 +2,11 @@ package database
 
 import (
 	"context"
+	"errors"
 	"fmt"
 
 	"github.com/sagarc03/stowry"
+	"github.com/sagarc03/stowry/database/internal"
 	"github.com/sagarc03/stowry/database/postgres"
 	"github.com/sagarc03/stowry/database/sqlite"
 )
@@ -19,9 +21,17 @@ type Database interface {
 	Migrate(ctx context.Context) error
 
 	// Validate checks that the database schema matches expected structure.
-	// Returns an error if required tables or columns are missing or have wrong types.
+	// Returns an error wrapping a *SchemaDiff if tables, columns or indexes
+	// are missing or columns have wrong types.
 	Validate(ctx context.Context) error
 
+	// Plan returns the migration steps that resolve the differences Validate
+	// reports, without running them. It returns no steps for a valid schema.
+	Plan(ctx context.Context) ([]MigrationStep, error)
+
+	// Apply runs steps from Plan in one transaction. It refuses manual steps.
+	Apply(ctx context.Context, steps []MigrationStep) error
+
 	// GetRepo returns the MetaDataRepo for database operations.
 	GetRepo() stowry.MetaDataRepo
 
@@ -29,6 +39,57 @@ type Database interface {
 	Close() error
 }
 
+// Backuper is implemented by databases that can write a consistent snapshot
+// of themselves while in use. Only SQLite does; back up PostgreSQL with
+// pg_dump.
+type Backuper interface {
+	// Backup writes the snapshot to path, which must not exist.
+	Backup(ctx context.Context, path string) error
+}
+
+// Restore replaces the configured database with the snapshot at path after
+// checking its integrity and schema. No server may be using the database.
+// It returns stowry.ErrNotSupported for backends other than SQLite.
+func Restore(ctx context.Context, cfg Config, path string) error {
+	if cfg.Type != "sqlite" {
+		return fmt.Errorf("restore %s: %w", cfg.Type, stowry.ErrNotSupported)
+	}
+	return sqlite.Restore(ctx, path, cfg.DSN, cfg.Tables)
+}
+
+// SchemaDiff is how the database schema differs from the expected one,
+// rendered as a table by Error. Resolvable reports whether Migrate fixes it.
+type SchemaDiff = internal.SchemaDiff
+
+// TableDiff is how one table differs from its expected schema.
+type TableDiff = internal.TableDiff
+
+// ColumnDiff is one missing, changed or extra column of a TableDiff.
+type ColumnDiff = internal.ColumnDiff
+
+// MigrationStep is one statement of a migration plan. Steps without SQL are
+// done by hand; destructive ones can lose data or reject existing rows.
+type MigrationStep = internal.MigrationStep
+
+// RenderSQL renders steps as a SQL script with a comment before each.
+func RenderSQL(steps []MigrationStep) string {
+	return internal.RenderSQL(steps)
+}
+
+// MigrateMode is how startup handles a schema that needs migrations.
+type MigrateMode string
+
+const (
+	// MigrateAuto applies pending migrations, refusing destructive steps
+	// unless they are allowed.
+	MigrateAuto MigrateMode = "auto"
+	// MigrateCheck fails with the pending migrations without running them.
+	MigrateCheck MigrateMode = "check"
+	// MigrateSkip only validates, for operators who run "stowry migrate"
+	// out of band.
+	MigrateSkip MigrateMode = "skip"
+)
+
 // Config holds the configuration for connecting to a metadata backend.
 type Config struct {
 	// Type specifies the database type: "sqlite" or "postgres"
@@ -37,6 +98,112 @@ type Config struct {
 	DSN string `mapstructure:"dsn"`
 	// Tables defines the table names for the database
 	Tables stowry.Tables `mapstructure:"tables"`
+	// AutoMigrate applies pending migrations when Validate finds a diff they
+	// resolve. Nil uses the backend default; see MigrateOnStartup.
+	AutoMigrate *bool `mapstructure:"auto_migrate"`
+	// MigrateMode is "auto", "check" or "skip". Empty derives it from
+	// AutoMigrate; see StartupMigrateMode.
+	MigrateMode MigrateMode `mapstructure:"migrate_mode" validate:"omitempty,oneof=auto check skip"`
+	// AllowDestructiveMigrations lets auto mode apply steps that can lose
+	// data, such as narrowing a column's type.
+	AllowDestructiveMigrations bool `mapstructure:"allow_destructive_migrations"`
+	// IDScheme is how the IDs of new metadata rows are generated: "uuidv4",
+	// "uuidv7" or "ulid-as-uuid". Empty uses uuidv4.
+	IDScheme stowry.IDScheme `mapstructure:"id_scheme" validate:"omitempty,oneof=uuidv4 uuidv7 ulid-as-uuid"`
+}
+
+// StartupMigrateMode returns MigrateMode when set. Otherwise it is auto
+// when AutoMigrate is set and true, or when unset for SQLite, whose single
+// local file is not shared with operators running migrations; else skip.
+func (c Config) StartupMigrateMode() MigrateMode {
+	if c.MigrateMode != "" {
+		return c.MigrateMode
+	}
+	if c.AutoMigrate != nil && *c.AutoMigrate || c.AutoMigrate == nil && c.Type == "sqlite" {
+		return MigrateAuto
+	}
+	return MigrateSkip
+}
+
+// MigrateOnStartup reports whether pending migrations are applied on
+// startup, that is whether StartupMigrateMode is auto.
+func (c Config) MigrateOnStartup() bool {
+	return c.StartupMigrateMode() == MigrateAuto
+}
+
+// ErrPendingMigrations is wrapped by the *PendingMigrationsError that
+// PrepareSchema returns for migrations it did not apply.
+var ErrPendingMigrations = errors.New("pending migrations")
+
+// PendingMigrationsError lists the migration steps that PrepareSchema found
+// but did not apply: all of them in check mode, or, in auto mode, a plan
+// with destructive steps that are not allowed.
+type PendingMigrationsError struct {
+	Steps []MigrationStep
+	Mode  MigrateMode
+}
+
+func (e *PendingMigrationsError) Error() string {
+	destructive := 0
+	for _, s := range e.Steps {
+		if s.Destructive {
+			destructive++
+		}
+	}
+	if e.Mode == MigrateCheck {
+		return fmt.Sprintf("%d pending migration steps (%d destructive); apply them with \"stowry migrate\"", len(e.Steps), destructive)
+	}
+	return fmt.Sprintf("%d pending migration steps include %d destructive; review them with \"stowry migrate --dry-run\" "+
+		"and apply them with \"stowry migrate --allow-destructive\" or database.allow_destructive_migrations", len(e.Steps), destructive)
+}
+
+func (e *PendingMigrationsError) Unwrap() error {
+	return ErrPendingMigrations
+}
+
+// PrepareSchema readies the schema for serving according to mode and
+// returns the migration steps it applied:
+//
+//   - skip validates only.
+//   - check returns a *PendingMigrationsError when the schema needs any
+//     migration, without running it.
+//   - auto applies the plan, then validates. A plan with destructive steps
+//     is refused with a *PendingMigrationsError unless allowDestructive is
+//     set, and one with manual steps fails with the schema diff.
+func PrepareSchema(ctx context.Context, db Database, mode MigrateMode, allowDestructive bool) ([]MigrationStep, error) {
+	if mode == MigrateSkip {
+		return nil, db.Validate(ctx)
+	}
+
+	steps, err := db.Plan(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if len(steps) == 0 {
+		return nil, nil
+	}
+	if mode == MigrateCheck {
+		return nil, &PendingMigrationsError{Steps: steps, Mode: mode}
+	}
+
+	for _, s := range steps {
+		if s.Manual() {
+			return nil, db.Validate(ctx)
+		}
+	}
+	for _, s := range steps {
+		if s.Destructive && !allowDestructive {
+			return nil, &PendingMigrationsError{Steps: steps, Mode: mode}
+		}
+	}
+
+	if err := db.Apply(ctx, steps); err != nil {
+		return nil, fmt.Errorf("migrate: %w", err)
+	}
+	if err := db.Validate(ctx); err != nil {
+		return steps, err
+	}
+	return steps, nil
 }
 
 // Connect establishes a connection to the configured database backend.
@@ -45,9 +212,9 @@ type Config struct {
 func Connect(ctx context.Context, cfg Config) (Database, error) {
 	switch cfg.Type {
 	case "sqlite":
-		return sqlite.Connect(ctx, cfg.DSN, cfg.Tables)
+		return sqlite.Connect(ctx, cfg.DSN, cfg.Tables, sqlite.WithIDScheme(cfg.IDScheme))
 	case "postgres":
-		return postgres.Connect(ctx, cfg.DSN, cfg.Tables)
+		return postgres.Connect(ctx, cfg.DSN, cfg.Tables, postgres.WithIDScheme(cfg.IDScheme))
 	default:
 		return nil, fmt.Errorf("unsupported database type: %s", cfg.Type)
 	}
@@ -2,6 +2,7 @@ package database_test
 
 import (
 	"context"
+	"errors"
 	"testing"
 
 	"github.com/sagarc03/stowry"
@@ -148,6 +149,132 @@ func TestDatabase_Validate_AfterMigration(t *testing.T) {
 	assert.NoError(t, err, "validate should pass after migration")
 }
 
+// plannedDB is a Database whose Plan returns steps, recording what is
+// applied. Validate succeeds once the steps are applied.
+type plannedDB struct {
+	database.Database
+	steps   []database.MigrationStep
+	applied []database.MigrationStep
+}
+
+func (d *plannedDB) Plan(context.Context) ([]database.MigrationStep, error) {
+	if d.applied != nil {
+		return nil, nil
+	}
+	return d.steps, nil
+}
+
+func (d *plannedDB) Apply(_ context.Context, steps []database.MigrationStep) error {
+	d.applied = steps
+	return nil
+}
+
+func (d *plannedDB) Validate(context.Context) error {
+	if d.applied == nil {
+		return errors.New("schema out of date")
+	}
+	return nil
+}
+
+func TestPrepareSchema(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	t.Run("auto applies the plan", func(t *testing.T) {
+		db := setupTestDB(t, "auto_migrate_test")
+
+		applied, err := database.PrepareSchema(ctx, db, database.MigrateAuto, false)
+		require.NoError(t, err)
+		assert.NotEmpty(t, applied)
+		assert.NoError(t, db.Validate(ctx))
+	})
+
+	t.Run("skip returns the diff", func(t *testing.T) {
+		db := setupTestDB(t, "no_migrate_test")
+
+		applied, err := database.PrepareSchema(ctx, db, database.MigrateSkip, false)
+		assert.Empty(t, applied)
+		var diff *database.SchemaDiff
+		require.ErrorAs(t, err, &diff)
+		assert.True(t, diff.Resolvable())
+		assert.Len(t, diff.Tables, 11)
+	})
+
+	t.Run("check returns the plan without running it", func(t *testing.T) {
+		db := setupTestDB(t, "check_migrate_test")
+
+		applied, err := database.PrepareSchema(ctx, db, database.MigrateCheck, false)
+		assert.Empty(t, applied)
+		var pending *database.PendingMigrationsError
+		require.ErrorAs(t, err, &pending)
+		assert.ErrorIs(t, err, database.ErrPendingMigrations)
+		assert.Contains(t, database.RenderSQL(pending.Steps), `CREATE TABLE IF NOT EXISTS "check_migrate_test"`)
+
+		var diff *database.SchemaDiff
+		require.ErrorAs(t, db.Validate(ctx), &diff, "check must not migrate")
+		assert.Len(t, diff.Tables, 11)
+	})
+
+	t.Run("valid schema", func(t *testing.T) {
+		for _, mode := range []database.MigrateMode{database.MigrateAuto, database.MigrateCheck, database.MigrateSkip} {
+			db := setupTestDBWithMigration(t, "valid_migrate_test")
+
+			applied, err := database.PrepareSchema(ctx, db, mode, false)
+			require.NoError(t, err, mode)
+			assert.Empty(t, applied, mode)
+		}
+	})
+
+	t.Run("auto refuses destructive steps", func(t *testing.T) {
+		db := &plannedDB{steps: []database.MigrationStep{
+			{Table: "t", Description: "add column a", SQL: "ALTER TABLE t ADD COLUMN a TEXT"},
+			{Table: "t", Description: "make column b not null", SQL: "ALTER TABLE t ALTER COLUMN b SET NOT NULL", Destructive: true},
+		}}
+
+		_, err := database.PrepareSchema(ctx, db, database.MigrateAuto, false)
+		var pending *database.PendingMigrationsError
+		require.ErrorAs(t, err, &pending)
+		assert.Len(t, pending.Steps, 2)
+		assert.Contains(t, err.Error(), "1 destructive")
+		assert.Nil(t, db.applied, "no step runs when one is refused")
+
+		applied, err := database.PrepareSchema(ctx, db, database.MigrateAuto, true)
+		require.NoError(t, err)
+		assert.Equal(t, db.steps, applied)
+	})
+
+	t.Run("auto fails on manual steps", func(t *testing.T) {
+		db := &plannedDB{steps: []database.MigrationStep{
+			{Table: "t", Description: "change column b from integer to text (rebuild the table)", Destructive: true},
+		}}
+
+		_, err := database.PrepareSchema(ctx, db, database.MigrateAuto, true)
+		assert.EqualError(t, err, "schema out of date")
+		assert.Nil(t, db.applied)
+	})
+}
+
+func TestConfig_MigrateOnStartup(t *testing.T) {
+	t.Parallel()
+
+	enabled, disabled := true, false
+	assert.True(t, database.Config{Type: "sqlite"}.MigrateOnStartup())
+	assert.False(t, database.Config{Type: "postgres"}.MigrateOnStartup())
+	assert.True(t, database.Config{Type: "postgres", AutoMigrate: &enabled}.MigrateOnStartup())
+	assert.False(t, database.Config{Type: "sqlite", AutoMigrate: &disabled}.MigrateOnStartup())
+}
+
+func TestConfig_StartupMigrateMode(t *testing.T) {
+	t.Parallel()
+
+	enabled, disabled := true, false
+	assert.Equal(t, database.MigrateAuto, database.Config{Type: "sqlite"}.StartupMigrateMode())
+	assert.Equal(t, database.MigrateSkip, database.Config{Type: "postgres"}.StartupMigrateMode())
+	assert.Equal(t, database.MigrateAuto, database.Config{Type: "postgres", AutoMigrate: &enabled}.StartupMigrateMode())
+	assert.Equal(t, database.MigrateSkip, database.Config{Type: "sqlite", AutoMigrate: &disabled}.StartupMigrateMode())
+	assert.Equal(t, database.MigrateCheck, database.Config{Type: "sqlite", AutoMigrate: &enabled, MigrateMode: database.MigrateCheck}.StartupMigrateMode())
+}
+
 func TestDatabase_GetRepo(t *testing.T) {
 	t.Parallel()
 	ctx := context.Background()
@@ -164,9 +291,9 @@ func TestDatabase_GetRepo(t *testing.T) {
 		ContentType: "text/plain",
 	}
 
-	meta, inserted, err := repo.Upsert(ctx, entry)
+	meta, previous, err := repo.Upsert(ctx, entry)
 	require.NoError(t, err)
-	assert.True(t, inserted)
+	assert.Nil(t, previous)
 	assert.Equal(t, "test/file.txt", meta.Path)
 }
 
@@ -0,0 +1,38 @@
+package internal
+
+import "github.com/sagarc03/stowry"
+
+// MaxUpsertBatchSize is the largest number of entries a backend writes in one
+// statement (PostgreSQL) or one transaction (SQLite) during UpsertBatch.
+// Larger batches are split into chunks of at most this size.
+const MaxUpsertBatchSize = 1000
+
+// ChunkEntries splits entries into consecutive chunks of at most size entries,
+// preserving order. A chunk never contains the same path twice: a repeated
+// path starts a new chunk, so a multi-row upsert touches each row at most once
+// and later entries win exactly as they would with sequential upserts.
+func ChunkEntries(entries []stowry.ObjectEntry, size int) [][]stowry.ObjectEntry {
+	if size <= 0 || size > MaxUpsertBatchSize {
+		size = MaxUpsertBatchSize
+	}
+
+	var chunks [][]stowry.ObjectEntry
+	start := 0
+	seen := make(map[string]struct{})
+
+	for i, entry := range entries {
+		_, dup := seen[entry.Path]
+		if dup || i-start == size {
+			chunks = append(chunks, entries[start:i])
+			start = i
+			clear(seen)
+		}
+		seen[entry.Path] = struct{}{}
+	}
+
+	if start < len(entries) {
+		chunks = append(chunks, entries[start:])
+	}
+
+	return chunks
+}
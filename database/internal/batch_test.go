@@ -0,0 +1,68 @@
+package internal_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/sagarc03/stowry"
+	"github.com/sagarc03/stowry/database/internal"
+	"github.com/stretchr/testify/assert"
+)
+
+func chunkPaths(chunks [][]stowry.ObjectEntry) [][]string {
+	out := make([][]string, len(chunks))
+	for i, chunk := range chunks {
+		for _, e := range chunk {
+			out[i] = append(out[i], e.Path)
+		}
+	}
+	return out
+}
+
+func TestChunkEntries(t *testing.T) {
+	t.Parallel()
+
+	entries := func(paths ...string) []stowry.ObjectEntry {
+		out := make([]stowry.ObjectEntry, len(paths))
+		for i, p := range paths {
+			out[i] = stowry.ObjectEntry{Path: p}
+		}
+		return out
+	}
+
+	tests := []struct {
+		name    string
+		entries []stowry.ObjectEntry
+		size    int
+		want    [][]string
+	}{
+		{name: "empty", entries: nil, size: 10, want: [][]string{}},
+		{name: "single chunk", entries: entries("a", "b", "c"), size: 10, want: [][]string{{"a", "b", "c"}}},
+		{name: "exact multiple", entries: entries("a", "b", "c", "d"), size: 2, want: [][]string{{"a", "b"}, {"c", "d"}}},
+		{name: "remainder", entries: entries("a", "b", "c"), size: 2, want: [][]string{{"a", "b"}, {"c"}}},
+		{name: "duplicate path splits", entries: entries("a", "b", "a", "c"), size: 10, want: [][]string{{"a", "b"}, {"a", "c"}}},
+		{name: "adjacent duplicates", entries: entries("a", "a", "a"), size: 10, want: [][]string{{"a"}, {"a"}, {"a"}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			assert.Equal(t, tt.want, chunkPaths(internal.ChunkEntries(tt.entries, tt.size)))
+		})
+	}
+}
+
+func TestChunkEntries_ClampsToMax(t *testing.T) {
+	t.Parallel()
+
+	entries := make([]stowry.ObjectEntry, internal.MaxUpsertBatchSize+1)
+	for i := range entries {
+		entries[i] = stowry.ObjectEntry{Path: fmt.Sprintf("file-%d.txt", i)}
+	}
+
+	for _, size := range []int{0, internal.MaxUpsertBatchSize * 2} {
+		chunks := internal.ChunkEntries(entries, size)
+		assert.Len(t, chunks, 2)
+		assert.Len(t, chunks[0], internal.MaxUpsertBatchSize)
+	}
+}
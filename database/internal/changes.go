@@ -0,0 +1,44 @@
+package internal
+
+import (
+	"fmt"
+
+	"github.com/sagarc03/stowry"
+)
+
+// ChangeWindow checks a change feed cursor against the lowest and highest
+// sequence in the feed (both zero when it is empty) and returns the cursor
+// to continue from when no change is left after since.
+func ChangeWindow(since, minSeq, maxSeq int64) (int64, error) {
+	switch {
+	case since < 0:
+		return maxSeq, nil
+	case since > maxSeq:
+		return 0, fmt.Errorf("%w: sequence %d is beyond the feed", stowry.ErrCursorExpired, since)
+	case minSeq > since+1:
+		return 0, fmt.Errorf("%w: changes after sequence %d were pruned", stowry.ErrCursorExpired, since)
+	}
+	return maxSeq, nil
+}
+
+// ChangePage builds a ChangeBatch from changes read with limit. A full page
+// continues after its last change; otherwise everything up to maxSeq was
+// scanned.
+func ChangePage(changes []stowry.Change, limit int, maxSeq int64) stowry.ChangeBatch {
+	next := maxSeq
+	if len(changes) > 0 && len(changes) >= limit {
+		next = changes[len(changes)-1].Seq
+	}
+	return stowry.ChangeBatch{Changes: changes, Next: next}
+}
+
+// HistoryPage builds the History of path from changes read with limit+1.
+// The extra change only signals that another page follows and is dropped.
+func HistoryPage(path string, changes []stowry.Change, limit int) stowry.History {
+	h := stowry.History{Path: path, Changes: changes}
+	if len(changes) > limit {
+		h.Changes = changes[:limit]
+		h.Next = changes[limit-1].Seq
+	}
+	return h
+}
@@ -0,0 +1,67 @@
+package internal_test
+
+import (
+	"testing"
+
+	"github.com/sagarc03/stowry"
+	"github.com/sagarc03/stowry/database/internal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestChangeWindow(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		since   int64
+		minSeq  int64
+		maxSeq  int64
+		want    int64
+		expired bool
+	}{
+		{name: "empty feed", since: 0, want: 0},
+		{name: "latest", since: -1, minSeq: 3, maxSeq: 9, want: 9},
+		{name: "from start", since: 0, minSeq: 1, maxSeq: 9, want: 9},
+		{name: "next after oldest", since: 2, minSeq: 3, maxSeq: 9, want: 9},
+		{name: "caught up", since: 9, minSeq: 3, maxSeq: 9, want: 9},
+		{name: "pruned", since: 1, minSeq: 3, maxSeq: 9, expired: true},
+		{name: "beyond feed", since: 10, minSeq: 3, maxSeq: 9, expired: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			got, err := internal.ChangeWindow(tt.since, tt.minSeq, tt.maxSeq)
+			if tt.expired {
+				assert.ErrorIs(t, err, stowry.ErrCursorExpired)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestChangePage(t *testing.T) {
+	t.Parallel()
+
+	changes := []stowry.Change{{Seq: 4}, {Seq: 7}}
+	assert.Equal(t, int64(7), internal.ChangePage(changes, 2, 12).Next, "full page continues after its last change")
+	assert.Equal(t, int64(12), internal.ChangePage(changes, 5, 12).Next, "short page skips to the end of the feed")
+	assert.Equal(t, int64(12), internal.ChangePage(nil, 5, 12).Next)
+}
+
+func TestHistoryPage(t *testing.T) {
+	t.Parallel()
+
+	changes := []stowry.Change{{Seq: 4}, {Seq: 7}, {Seq: 9}}
+
+	h := internal.HistoryPage("a.txt", changes, 2)
+	assert.Equal(t, "a.txt", h.Path)
+	assert.Len(t, h.Changes, 2, "the extra change is dropped")
+	assert.Equal(t, int64(7), h.Next, "a full page continues after its last change")
+
+	h = internal.HistoryPage("a.txt", changes, 3)
+	assert.Len(t, h.Changes, 3)
+	assert.Zero(t, h.Next, "the last page has no cursor")
+}
@@ -0,0 +1,23 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// ContextError makes err match ctx.Err() with errors.Is once ctx is done.
+// Drivers report an interrupted query in their own terms (SQLite's
+// "interrupted", a closed connection), so callers could not tell a cancelled
+// request from a failing database. err is returned unchanged when it is nil,
+// when ctx is still live or when it already wraps ctx.Err().
+func ContextError(ctx context.Context, err error) error {
+	if err == nil {
+		return nil
+	}
+	ctxErr := ctx.Err()
+	if ctxErr == nil || errors.Is(err, ctxErr) {
+		return err
+	}
+	return fmt.Errorf("%w: %w", ctxErr, err)
+}
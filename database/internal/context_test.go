@@ -0,0 +1,61 @@
+package internal_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/sagarc03/stowry/database/internal"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestContextError(t *testing.T) {
+	t.Parallel()
+
+	driverErr := errors.New("interrupted (9)")
+
+	t.Run("nil error", func(t *testing.T) {
+		t.Parallel()
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		assert.NoError(t, internal.ContextError(ctx, nil))
+	})
+
+	t.Run("live context keeps error", func(t *testing.T) {
+		t.Parallel()
+		err := internal.ContextError(context.Background(), driverErr)
+		assert.Same(t, driverErr, err)
+		assert.NotErrorIs(t, err, context.Canceled)
+	})
+
+	t.Run("canceled context", func(t *testing.T) {
+		t.Parallel()
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		err := internal.ContextError(ctx, driverErr)
+		assert.ErrorIs(t, err, context.Canceled)
+		assert.ErrorIs(t, err, driverErr)
+	})
+
+	t.Run("deadline exceeded", func(t *testing.T) {
+		t.Parallel()
+		ctx, cancel := context.WithDeadline(context.Background(), time.Now().Add(-time.Second))
+		defer cancel()
+
+		err := internal.ContextError(ctx, driverErr)
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+		assert.ErrorIs(t, err, driverErr)
+	})
+
+	t.Run("already wrapped", func(t *testing.T) {
+		t.Parallel()
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		wrapped := fmt.Errorf("get: %w", context.Canceled)
+		assert.Same(t, wrapped, internal.ContextError(ctx, wrapped))
+	})
+}
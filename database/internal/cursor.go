@@ -47,11 +47,3 @@ func DecodeCursor(cursor string) (Cursor, error) {
 
 	return Cursor{CreatedAt: createdAt, Path: parts[1]}, nil
 }
-
-// EscapeLikePattern escapes special LIKE characters (%, _, \) to prevent SQL injection.
-func EscapeLikePattern(pattern string) string {
-	pattern = strings.ReplaceAll(pattern, `\`, `\\`)
-	pattern = strings.ReplaceAll(pattern, `%`, `\%`)
-	pattern = strings.ReplaceAll(pattern, `_`, `\_`)
-	return pattern
-}
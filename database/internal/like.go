@@ -0,0 +1,48 @@
+package internal
+
+import "strings"
+
+// LikeEscape is the escape character both backends use in LIKE patterns.
+// It is backslash, which PostgreSQL uses by default and SQLite and MySQL
+// accept with ESCAPE '\'.
+const LikeEscape = '\\'
+
+// EscapeLikePrefix returns prefix as a LIKE pattern that matches it
+// literally, with %, _ and escape itself preceded by escape. Append an
+// unescaped % to match the paths starting with prefix, and declare escape in
+// the query's ESCAPE clause unless it is the database's default.
+func EscapeLikePrefix(prefix string, escape rune) string {
+	var b strings.Builder
+	b.Grow(len(prefix))
+	for _, r := range prefix {
+		if r == '%' || r == '_' || r == escape {
+			b.WriteRune(escape)
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// PrefixUpperBound returns the smallest string greater than every string
+// that starts with prefix, in byte order, so that
+//
+//	path >= prefix AND path < bound
+//
+// selects the paths starting with prefix with a range scan of an index on
+// path. Trailing 0xFF bytes are dropped and the last remaining byte is
+// incremented. It reports false when no bound exists, for an empty prefix or
+// one made only of 0xFF bytes; path >= prefix alone is then enough.
+//
+// The range only matches byte order under a binary collation, such as
+// SQLite's default. PostgreSQL databases usually compare text by locale and
+// should use EscapeLikePrefix instead.
+func PrefixUpperBound(prefix string) (string, bool) {
+	b := []byte(prefix)
+	for i := len(b) - 1; i >= 0; i-- {
+		if b[i] < 0xFF {
+			b[i]++
+			return string(b[:i+1]), true
+		}
+	}
+	return "", false
+}
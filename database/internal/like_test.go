@@ -0,0 +1,165 @@
+package internal_test
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/sagarc03/stowry/database/internal"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEscapeLikePrefix(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		input    string
+		escape   rune
+		expected string
+	}{
+		{name: "no special characters", input: "simple/path/file.txt", escape: '\\', expected: "simple/path/file.txt"},
+		{name: "percent sign", input: "100%complete", escape: '\\', expected: `100\%complete`},
+		{name: "underscore", input: "file_name.txt", escape: '\\', expected: `file\_name.txt`},
+		{name: "backslash", input: `path\to\file`, escape: '\\', expected: `path\\to\\file`},
+		{name: "all special characters", input: `50%_done\today`, escape: '\\', expected: `50\%\_done\\today`},
+		{name: "multiple consecutive special chars", input: "%%__\\\\", escape: '\\', expected: `\%\%\_\_\\\\`},
+		{name: "empty string", input: "", escape: '\\', expected: ""},
+		{name: "only special characters", input: `%_\`, escape: '\\', expected: `\%\_\\`},
+		{name: "other escape character", input: `a!b%c\d`, escape: '!', expected: `a!!b!%c\d`},
+		{name: "multibyte escape character", input: "a§b_", escape: '§', expected: "a§§b§_"},
+		{name: "multibyte characters", input: "データ_1", escape: '\\', expected: `データ\_1`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			assert.Equal(t, tt.expected, internal.EscapeLikePrefix(tt.input, tt.escape))
+		})
+	}
+}
+
+func TestPrefixUpperBound(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name   string
+		prefix string
+		bound  string
+		ok     bool
+	}{
+		{name: "empty", prefix: "", ok: false},
+		{name: "ascii", prefix: "reports/", bound: "reports0", ok: true},
+		{name: "single byte", prefix: "a", bound: "b", ok: true},
+		{name: "trailing 0xFF dropped", prefix: "a\xff\xff", bound: "b", ok: true},
+		{name: "only 0xFF", prefix: "\xff\xff", ok: false},
+		{name: "multibyte", prefix: "é", bound: "\xc3\xaa", ok: true},
+		{name: "last byte below 0xFF", prefix: "a\xfe", bound: "a\xff", ok: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			bound, ok := internal.PrefixUpperBound(tt.prefix)
+			assert.Equal(t, tt.ok, ok)
+			assert.Equal(t, tt.bound, bound)
+		})
+	}
+}
+
+// likeMatch reports whether s matches the LIKE pattern, case-sensitively as
+// PostgreSQL does: % matches any run of characters, _ one character, and
+// escape makes the next character literal.
+func likeMatch(pattern, s string, escape rune) bool {
+	p := []rune(pattern)
+	str := []rune(s)
+
+	var match func(pi, si int) bool
+	match = func(pi, si int) bool {
+		for pi < len(p) {
+			switch c := p[pi]; {
+			case c == escape && pi+1 < len(p):
+				if si >= len(str) || str[si] != p[pi+1] {
+					return false
+				}
+				pi, si = pi+2, si+1
+			case c == '%':
+				for k := si; k <= len(str); k++ {
+					if match(pi+1, k) {
+						return true
+					}
+				}
+				return false
+			case c == '_':
+				if si >= len(str) {
+					return false
+				}
+				pi, si = pi+1, si+1
+			default:
+				if si >= len(str) || str[si] != c {
+					return false
+				}
+				pi, si = pi+1, si+1
+			}
+		}
+		return si == len(str)
+	}
+	return match(0, 0)
+}
+
+// inPrefixRange evaluates path >= prefix AND path < bound as SQLite does
+// under its binary collation.
+func inPrefixRange(prefix, path string) bool {
+	bound, ok := internal.PrefixUpperBound(prefix)
+	return path >= prefix && (!ok || path < bound)
+}
+
+func FuzzPrefixPredicates(f *testing.F) {
+	seeds := [][3]string{
+		{"", "a", ""},
+		{"reports/", "reports/q1.csv", "reports0"},
+		{"100%", "100%/a", "1000/a"},
+		{"a_b", "a_b/c", "axb/c"},
+		{`a\`, `a\b`, `a\\b`},
+		{`a\%`, `a\%b`, `a\xb`},
+		{"é/", "é/x", "ê"},
+		{"a\xff", "a\xff\xff", "b"},
+		{"%", "%", "x"},
+	}
+	for _, s := range seeds {
+		f.Add(s[0], s[1], s[2])
+	}
+
+	f.Fuzz(func(t *testing.T, prefix, a, b string) {
+		// Object paths are UTF-8; LIKE is undefined on invalid text
+		if !utf8.ValidString(prefix) || !utf8.ValidString(a) || !utf8.ValidString(b) {
+			t.Skip()
+		}
+
+		keys := []string{a, b, prefix, prefix + a, a + prefix, prefix + b}
+		if bound, ok := internal.PrefixUpperBound(prefix); ok {
+			keys = append(keys, bound, bound+a)
+		}
+		if prefix != "" {
+			keys = append(keys, prefix[:len(prefix)-1])
+		}
+
+		for _, escape := range []rune{internal.LikeEscape, '!'} {
+			pattern := internal.EscapeLikePrefix(prefix, escape) + "%"
+			for _, key := range keys {
+				want := strings.HasPrefix(key, prefix)
+				if got := likeMatch(pattern, key, escape); got != want {
+					t.Fatalf("LIKE %q ESCAPE %q on %q = %v, HasPrefix = %v", pattern, escape, key, got, want)
+				}
+			}
+		}
+		for _, key := range keys {
+			want := strings.HasPrefix(key, prefix)
+			if got := inPrefixRange(prefix, key); got != want {
+				t.Fatalf("range of prefix %q on %q = %v, HasPrefix = %v", prefix, key, got, want)
+			}
+		}
+	})
+}
@@ -0,0 +1,121 @@
+package internal
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Statement is one named DDL statement: a column it adds or an index it
+// creates.
+type Statement struct {
+	Name string
+	SQL  string
+}
+
+// TableDDL is the SQL that creates a table and brings one created by an
+// older release up to date. Migrate runs all of it; PlanMigrations picks
+// the statements a SchemaDiff needs.
+type TableDDL struct {
+	Table   string
+	Create  string
+	Columns []Statement // Adds each column in TableSchema.Migratable
+	Indexes []Statement
+}
+
+// MigrationStep is one change that brings a table closer to its expected
+// schema. A step without SQL cannot be automated and is done by hand.
+type MigrationStep struct {
+	Table       string
+	Description string
+	SQL         string
+	// Destructive steps can lose data or reject existing rows, such as a
+	// type change that narrows a column or a new NOT NULL constraint.
+	Destructive bool
+}
+
+// Manual reports whether the step has to be done by hand.
+func (s MigrationStep) Manual() bool {
+	return s.SQL == ""
+}
+
+// ColumnChanger returns the steps that change a column of table to its
+// expected type and nullability.
+type ColumnChanger func(table string, column ColumnDiff) []MigrationStep
+
+// PlanMigrations returns the steps that resolve diff, table by table in the
+// order of ddl. Missing tables are created with their indexes, missing
+// columns and indexes are added, and changeColumn plans changed columns.
+// A missing column that no migration adds becomes a manual step.
+func PlanMigrations(diff *SchemaDiff, ddl []TableDDL, changeColumn ColumnChanger) []MigrationStep {
+	tables := make(map[string]TableDiff, len(diff.Tables))
+	for _, t := range diff.Tables {
+		tables[t.Table] = t
+	}
+
+	var steps []MigrationStep
+	for _, table := range ddl {
+		t, ok := tables[table.Table]
+		if !ok {
+			continue
+		}
+
+		if t.Missing {
+			steps = append(steps, MigrationStep{Table: table.Table, Description: "create table", SQL: table.Create})
+			for _, index := range table.Indexes {
+				steps = append(steps, MigrationStep{Table: table.Table, Description: "create index " + index.Name, SQL: index.SQL})
+			}
+			continue
+		}
+
+		for _, c := range t.MissingColumns {
+			step := MigrationStep{Table: table.Table, Description: "add column " + c.Name}
+			if stmt, ok := find(table.Columns, c.Name); ok && c.Migratable {
+				step.SQL = stmt.SQL
+			} else {
+				step.Description = fmt.Sprintf("add column %s %s (no migration adds it)", c.Name, c.Expected)
+			}
+			steps = append(steps, step)
+		}
+		for _, c := range t.ChangedColumns {
+			steps = append(steps, changeColumn(table.Table, c)...)
+		}
+		for _, name := range t.MissingIndexes {
+			step := MigrationStep{Table: table.Table, Description: "create index " + name}
+			if stmt, ok := find(table.Indexes, name); ok {
+				step.SQL = stmt.SQL
+			}
+			steps = append(steps, step)
+		}
+	}
+	return steps
+}
+
+func find(statements []Statement, name string) (Statement, bool) {
+	for _, s := range statements {
+		if s.Name == name {
+			return s, true
+		}
+	}
+	return Statement{}, false
+}
+
+// RenderSQL renders steps as a SQL script, each statement preceded by a
+// comment naming its table and what it does. Manual steps are comments only.
+func RenderSQL(steps []MigrationStep) string {
+	var b strings.Builder
+	for i, s := range steps {
+		if i > 0 {
+			b.WriteString("\n")
+		}
+		fmt.Fprintf(&b, "-- %s: %s", s.Table, s.Description)
+		switch {
+		case s.Manual():
+			b.WriteString(" (manual)\n")
+			continue
+		case s.Destructive:
+			b.WriteString(" (destructive)")
+		}
+		fmt.Fprintf(&b, "\n%s;\n", strings.TrimSpace(s.SQL))
+	}
+	return b.String()
+}
@@ -0,0 +1,76 @@
+package internal_test
+
+import (
+	"testing"
+
+	"github.com/sagarc03/stowry/database/internal"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPlanMigrations(t *testing.T) {
+	ddl := []internal.TableDDL{{
+		Table:   "meta",
+		Create:  "CREATE TABLE meta (id TEXT)",
+		Columns: []internal.Statement{{Name: "filename", SQL: "ALTER TABLE meta ADD COLUMN filename TEXT"}},
+		Indexes: []internal.Statement{{Name: "idx_meta_path", SQL: "CREATE INDEX idx_meta_path ON meta (path)"}},
+	}, {
+		Table:  "settings",
+		Create: "CREATE TABLE settings (key TEXT)",
+	}}
+	changed := func(table string, c internal.ColumnDiff) []internal.MigrationStep {
+		return []internal.MigrationStep{{Table: table, Description: "change " + c.Name, SQL: "ALTER", Destructive: true}}
+	}
+	text := internal.Column{Type: "text"}
+	integer := internal.Column{Type: "integer"}
+
+	t.Run("missing table", func(t *testing.T) {
+		diff := &internal.SchemaDiff{Tables: []internal.TableDiff{internal.MissingTable("meta")}}
+
+		assert.Equal(t, []internal.MigrationStep{
+			{Table: "meta", Description: "create table", SQL: "CREATE TABLE meta (id TEXT)"},
+			{Table: "meta", Description: "create index idx_meta_path", SQL: "CREATE INDEX idx_meta_path ON meta (path)"},
+		}, internal.PlanMigrations(diff, ddl, changed))
+	})
+
+	t.Run("columns and indexes", func(t *testing.T) {
+		diff := &internal.SchemaDiff{Tables: []internal.TableDiff{{
+			Table: "meta",
+			MissingColumns: []internal.ColumnDiff{
+				{Name: "filename", Expected: &text, Migratable: true},
+				{Name: "path", Expected: &text},
+			},
+			ChangedColumns: []internal.ColumnDiff{{Name: "size", Expected: &integer, Actual: &text}},
+			MissingIndexes: []string{"idx_meta_path"},
+		}}}
+
+		steps := internal.PlanMigrations(diff, ddl, changed)
+		assert.Equal(t, []internal.MigrationStep{
+			{Table: "meta", Description: "add column filename", SQL: "ALTER TABLE meta ADD COLUMN filename TEXT"},
+			{Table: "meta", Description: "add column path text not null (no migration adds it)"},
+			{Table: "meta", Description: "change size", SQL: "ALTER", Destructive: true},
+			{Table: "meta", Description: "create index idx_meta_path", SQL: "CREATE INDEX idx_meta_path ON meta (path)"},
+		}, steps)
+		assert.True(t, steps[1].Manual())
+	})
+
+	t.Run("tables without problems", func(t *testing.T) {
+		assert.Empty(t, internal.PlanMigrations(&internal.SchemaDiff{}, ddl, changed))
+	})
+}
+
+func TestRenderSQL(t *testing.T) {
+	steps := []internal.MigrationStep{
+		{Table: "meta", Description: "add column filename", SQL: "ALTER TABLE meta ADD COLUMN filename TEXT"},
+		{Table: "meta", Description: "change size", SQL: "ALTER TABLE meta ALTER COLUMN size TYPE bigint", Destructive: true},
+		{Table: "meta", Description: "rebuild"},
+	}
+
+	assert.Equal(t, `-- meta: add column filename
+ALTER TABLE meta ADD COLUMN filename TEXT;
+
+-- meta: change size (destructive)
+ALTER TABLE meta ALTER COLUMN size TYPE bigint;
+
+-- meta: rebuild (manual)
+`, internal.RenderSQL(steps))
+}
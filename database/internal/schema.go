@@ -0,0 +1,196 @@
+package internal
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"text/tabwriter"
+)
+
+// Column describes one column of a table: its lowercase type name as the
+// backend reports it and whether it accepts NULL.
+type Column struct {
+	Type     string
+	Nullable bool
+}
+
+func (c Column) String() string {
+	if c.Nullable {
+		return c.Type + " null"
+	}
+	return c.Type + " not null"
+}
+
+// TableSchema is the schema a table is expected to have.
+type TableSchema struct {
+	Columns map[string]Column
+	Indexes []string
+	// Migratable lists the columns that migrations add to an existing table.
+	// No migration can add any other missing column.
+	Migratable []string
+}
+
+// ColumnDiff is one column whose actual definition differs from the
+// expected one. Expected is nil for an extra column and Actual is nil for
+// a missing one.
+type ColumnDiff struct {
+	Name       string
+	Expected   *Column
+	Actual     *Column
+	Migratable bool // A migration adds this missing column
+}
+
+// TableDiff is how one table differs from its expected schema.
+type TableDiff struct {
+	Table          string
+	Missing        bool // The table does not exist
+	MissingColumns []ColumnDiff
+	ChangedColumns []ColumnDiff // Wrong type or nullability
+	ExtraColumns   []ColumnDiff // Not used by stowry, reported only
+	MissingIndexes []string
+}
+
+// SchemaDiff is how the database schema differs from the one stowry
+// expects. It is returned as the error of a failed Validate.
+type SchemaDiff struct {
+	Tables []TableDiff
+}
+
+// CompareTable returns how actual columns and indexes differ from expected.
+// Columns and indexes are reported in name order.
+func CompareTable(table string, expected TableSchema, actual map[string]Column, actualIndexes []string) TableDiff {
+	diff := TableDiff{Table: table}
+
+	for _, name := range slices.Sorted(maps.Keys(expected.Columns)) {
+		want := expected.Columns[name]
+		got, ok := actual[name]
+		switch {
+		case !ok:
+			diff.MissingColumns = append(diff.MissingColumns, ColumnDiff{
+				Name:       name,
+				Expected:   &want,
+				Migratable: slices.Contains(expected.Migratable, name),
+			})
+		case got != want:
+			diff.ChangedColumns = append(diff.ChangedColumns, ColumnDiff{Name: name, Expected: &want, Actual: &got})
+		}
+	}
+
+	for _, name := range slices.Sorted(maps.Keys(actual)) {
+		if _, ok := expected.Columns[name]; !ok {
+			got := actual[name]
+			diff.ExtraColumns = append(diff.ExtraColumns, ColumnDiff{Name: name, Actual: &got})
+		}
+	}
+
+	for _, index := range expected.Indexes {
+		if !slices.Contains(actualIndexes, index) {
+			diff.MissingIndexes = append(diff.MissingIndexes, index)
+		}
+	}
+	slices.Sort(diff.MissingIndexes)
+
+	return diff
+}
+
+// MissingTable returns the diff of a table that does not exist.
+func MissingTable(table string) TableDiff {
+	return TableDiff{Table: table, Missing: true}
+}
+
+// HasProblems reports whether the table differs in a way stowry cannot run
+// with. Extra columns alone are not a problem.
+func (d TableDiff) HasProblems() bool {
+	return d.Missing || len(d.MissingColumns) > 0 || len(d.ChangedColumns) > 0 || len(d.MissingIndexes) > 0
+}
+
+// Resolvable reports whether running the migrations fixes the table: it is
+// missing, or only lacks indexes and columns that migrations add.
+func (d TableDiff) Resolvable() bool {
+	if len(d.ChangedColumns) > 0 {
+		return false
+	}
+	for _, c := range d.MissingColumns {
+		if !c.Migratable {
+			return false
+		}
+	}
+	return true
+}
+
+// Add records table's diff if it has problems. It reports whether it did.
+func (d *SchemaDiff) Add(table TableDiff) bool {
+	if !table.HasProblems() {
+		return false
+	}
+	d.Tables = append(d.Tables, table)
+	return true
+}
+
+// Empty reports whether no table has problems.
+func (d *SchemaDiff) Empty() bool {
+	return len(d.Tables) == 0
+}
+
+// Resolvable reports whether running the migrations fixes every table.
+func (d *SchemaDiff) Resolvable() bool {
+	for _, t := range d.Tables {
+		if !t.Resolvable() {
+			return false
+		}
+	}
+	return true
+}
+
+// Error renders the diff as a table, followed by how to fix it.
+func (d *SchemaDiff) Error() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "schema does not match (%d %s):\n\n", len(d.Tables), plural(len(d.Tables), "table differs", "tables differ"))
+
+	w := tabwriter.NewWriter(&b, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "  TABLE\tNAME\tEXPECTED\tACTUAL\tPROBLEM")
+	for _, t := range d.Tables {
+		if t.Missing {
+			fmt.Fprintf(w, "  %s\t-\ttable\t-\tmissing table\n", t.Table)
+			continue
+		}
+		for _, c := range t.MissingColumns {
+			problem := "missing column"
+			if !c.Migratable {
+				problem += " (no migration adds it)"
+			}
+			fmt.Fprintf(w, "  %s\t%s\t%s\t-\t%s\n", t.Table, c.Name, c.Expected, problem)
+		}
+		for _, c := range t.ChangedColumns {
+			problem := "wrong type"
+			if c.Expected.Type == c.Actual.Type {
+				problem = "wrong nullability"
+			}
+			fmt.Fprintf(w, "  %s\t%s\t%s\t%s\t%s\n", t.Table, c.Name, c.Expected, c.Actual, problem)
+		}
+		for _, index := range t.MissingIndexes {
+			fmt.Fprintf(w, "  %s\t%s\tindex\t-\tmissing index\n", t.Table, index)
+		}
+		for _, c := range t.ExtraColumns {
+			fmt.Fprintf(w, "  %s\t%s\t-\t%s\textra column (ignored)\n", t.Table, c.Name, c.Actual)
+		}
+	}
+	_ = w.Flush()
+
+	b.WriteString("\n")
+	if d.Resolvable() {
+		b.WriteString("The pending migrations resolve this: run \"stowry migrate\", or start the server with --migrate-mode=auto.")
+	} else {
+		b.WriteString("Migrations cannot resolve columns that have the wrong type or that no migration adds. " +
+			"Alter them by hand to match EXPECTED, or configure database.tables to use new tables.")
+	}
+	return b.String()
+}
+
+func plural(n int, one, many string) string {
+	if n == 1 {
+		return one
+	}
+	return many
+}
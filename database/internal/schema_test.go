@@ -0,0 +1,91 @@
+package internal
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCompareTable(t *testing.T) {
+	expected := TableSchema{
+		Columns: map[string]Column{
+			"id":    {Type: "text"},
+			"size":  {Type: "integer"},
+			"note":  {Type: "text", Nullable: true},
+			"count": {Type: "integer", Nullable: true},
+		},
+		Indexes:    []string{"idx_b", "idx_a"},
+		Migratable: []string{"count"},
+	}
+
+	t.Run("matching table", func(t *testing.T) {
+		diff := CompareTable("objects", expected, expected.Columns, []string{"idx_a", "idx_b", "sqlite_autoindex_objects_1"})
+		assert.False(t, diff.HasProblems())
+	})
+
+	t.Run("reports every difference", func(t *testing.T) {
+		diff := CompareTable("objects", expected, map[string]Column{
+			"id":     {Type: "text", Nullable: true},
+			"size":   {Type: "text"},
+			"legacy": {Type: "blob", Nullable: true},
+		}, []string{"idx_a"})
+
+		assert.Equal(t, []ColumnDiff{
+			{Name: "count", Expected: &Column{Type: "integer", Nullable: true}, Migratable: true},
+			{Name: "note", Expected: &Column{Type: "text", Nullable: true}},
+		}, diff.MissingColumns)
+		assert.Equal(t, []ColumnDiff{
+			{Name: "id", Expected: &Column{Type: "text"}, Actual: &Column{Type: "text", Nullable: true}},
+			{Name: "size", Expected: &Column{Type: "integer"}, Actual: &Column{Type: "text"}},
+		}, diff.ChangedColumns)
+		assert.Equal(t, []ColumnDiff{{Name: "legacy", Actual: &Column{Type: "blob", Nullable: true}}}, diff.ExtraColumns)
+		assert.Equal(t, []string{"idx_b"}, diff.MissingIndexes)
+		assert.False(t, diff.Resolvable())
+	})
+
+	t.Run("extra columns alone are not a problem", func(t *testing.T) {
+		actual := map[string]Column{"legacy": {Type: "blob"}}
+		for name, c := range expected.Columns {
+			actual[name] = c
+		}
+		diff := CompareTable("objects", expected, actual, []string{"idx_a", "idx_b"})
+		assert.Len(t, diff.ExtraColumns, 1)
+		assert.False(t, diff.HasProblems())
+	})
+}
+
+func TestSchemaDiff(t *testing.T) {
+	expected := TableSchema{
+		Columns:    map[string]Column{"id": {Type: "text"}, "count": {Type: "integer", Nullable: true}},
+		Indexes:    []string{"idx_objects_id"},
+		Migratable: []string{"count"},
+	}
+
+	t.Run("resolvable by migrations", func(t *testing.T) {
+		diff := &SchemaDiff{}
+		assert.False(t, diff.Add(CompareTable("ok", expected, expected.Columns, expected.Indexes)))
+		assert.True(t, diff.Add(MissingTable("objects_changes")))
+		assert.True(t, diff.Add(CompareTable("objects", expected, map[string]Column{"id": {Type: "text"}}, nil)))
+
+		assert.False(t, diff.Empty())
+		assert.True(t, diff.Resolvable())
+		msg := diff.Error()
+		assert.Contains(t, msg, "2 tables differ")
+		assert.Regexp(t, `objects_changes\s+-\s+table\s+-\s+missing table`, msg)
+		assert.Regexp(t, `objects\s+count\s+integer null\s+-\s+missing column\n`, msg)
+		assert.Regexp(t, `objects\s+idx_objects_id\s+index\s+-\s+missing index`, msg)
+		assert.Contains(t, msg, "--migrate-mode=auto")
+	})
+
+	t.Run("unresolvable", func(t *testing.T) {
+		diff := &SchemaDiff{}
+		diff.Add(CompareTable("objects", expected, map[string]Column{"id": {Type: "integer"}}, expected.Indexes))
+
+		assert.False(t, diff.Resolvable())
+		msg := diff.Error()
+		assert.Contains(t, msg, "1 table differs")
+		assert.Regexp(t, `objects\s+id\s+text not null\s+integer not null\s+wrong type`, msg)
+		assert.Contains(t, msg, "Alter them by hand")
+		assert.NotContains(t, msg, "--migrate-mode=auto")
+	})
+}
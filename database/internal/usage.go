@@ -0,0 +1,55 @@
+package internal
+
+import (
+	"maps"
+	"slices"
+
+	"github.com/sagarc03/stowry"
+)
+
+// UsageCount is the number and total size of the objects under a prefix.
+type UsageCount struct {
+	Objects int64
+	Bytes   int64
+}
+
+// MergeUsage returns the prefixes of a new usage snapshot: the active
+// objects of each prefix, and the added counters of previous, the previous
+// snapshot's prefixes, increased by added. A prefix of previous with no
+// active objects is kept with zero counts so its counters carry on.
+func MergeUsage(previous []stowry.PrefixUsage, added, active map[string]UsageCount) []stowry.PrefixUsage {
+	merged := make(map[string]stowry.PrefixUsage, len(previous)+len(active))
+	for _, p := range previous {
+		merged[p.Prefix] = stowry.PrefixUsage{Prefix: p.Prefix, ObjectsAdded: p.ObjectsAdded, BytesAdded: p.BytesAdded}
+	}
+	for prefix, count := range added {
+		p := merged[prefix]
+		p.Prefix = prefix
+		p.ObjectsAdded += count.Objects
+		p.BytesAdded += count.Bytes
+		merged[prefix] = p
+	}
+	for prefix, count := range active {
+		p := merged[prefix]
+		p.Prefix = prefix
+		p.Objects = count.Objects
+		p.Bytes = count.Bytes
+		merged[prefix] = p
+	}
+
+	prefixes := make([]stowry.PrefixUsage, 0, len(merged))
+	for _, prefix := range slices.Sorted(maps.Keys(merged)) {
+		prefixes = append(prefixes, merged[prefix])
+	}
+	return prefixes
+}
+
+// BackendUsage returns counts, keyed by storage backend, as BackendUsage in
+// backend order.
+func BackendUsage(counts map[string]UsageCount) []stowry.BackendUsage {
+	backends := make([]stowry.BackendUsage, 0, len(counts))
+	for _, backend := range slices.Sorted(maps.Keys(counts)) {
+		backends = append(backends, stowry.BackendUsage{Backend: backend, Objects: counts[backend].Objects, Bytes: counts[backend].Bytes})
+	}
+	return backends
+}
@@ -0,0 +1,32 @@
+package internal_test
+
+import (
+	"testing"
+
+	"github.com/sagarc03/stowry"
+	"github.com/sagarc03/stowry/database/internal"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMergeUsage(t *testing.T) {
+	previous := []stowry.PrefixUsage{
+		{Prefix: "a/", Objects: 2, Bytes: 20, ObjectsAdded: 5, BytesAdded: 50},
+		{Prefix: "b/", Objects: 1, Bytes: 10, ObjectsAdded: 1, BytesAdded: 10},
+	}
+	added := map[string]internal.UsageCount{
+		"a/": {Objects: 1, Bytes: 7},
+		"c/": {Objects: 1, Bytes: 3},
+	}
+	active := map[string]internal.UsageCount{
+		"a/": {Objects: 3, Bytes: 27},
+		"c/": {Objects: 1, Bytes: 3},
+		"":   {Objects: 1, Bytes: 1},
+	}
+
+	assert.Equal(t, []stowry.PrefixUsage{
+		{Prefix: "", Objects: 1, Bytes: 1},
+		{Prefix: "a/", Objects: 3, Bytes: 27, ObjectsAdded: 6, BytesAdded: 57},
+		{Prefix: "b/", ObjectsAdded: 1, BytesAdded: 10},
+		{Prefix: "c/", Objects: 1, Bytes: 3, ObjectsAdded: 1, BytesAdded: 3},
+	}, internal.MergeUsage(previous, added, active))
+}
@@ -0,0 +1,49 @@
+package internal
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// EncodeUserMetadata returns the JSON both backends store for an object's
+// user metadata, or "" for none, which they store as NULL.
+func EncodeUserMetadata(metadata map[string]string) string {
+	if len(metadata) == 0 {
+		return ""
+	}
+	// A map of strings always marshals
+	data, _ := json.Marshal(metadata)
+	return string(data)
+}
+
+// UserMetadata scans a nullable column of JSON user metadata into Dest;
+// NULL leaves it nil.
+type UserMetadata struct {
+	Dest *map[string]string
+}
+
+// Scan implements sql.Scanner.
+func (u UserMetadata) Scan(src any) error {
+	var data []byte
+	switch v := src.(type) {
+	case nil:
+		*u.Dest = nil
+		return nil
+	case string:
+		data = []byte(v)
+	case []byte:
+		data = v
+	default:
+		return fmt.Errorf("scan user metadata: unsupported type %T", src)
+	}
+
+	var metadata map[string]string
+	if err := json.Unmarshal(data, &metadata); err != nil {
+		return fmt.Errorf("scan user metadata: %w", err)
+	}
+	if len(metadata) == 0 {
+		metadata = nil
+	}
+	*u.Dest = metadata
+	return nil
+}
@@ -0,0 +1,120 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/sagarc03/stowry"
+	"github.com/sagarc03/stowry/database/internal"
+)
+
+func (r *repo) RecordAccessKeyUse(ctx context.Context, uses []stowry.AccessKeyUse) (err error) {
+	defer func() { err = internal.ContextError(ctx, err) }()
+
+	if len(uses) == 0 {
+		return nil
+	}
+
+	keys := make([]string, len(uses))
+	counts := make([]int64, len(uses))
+	firstUsed := make([]time.Time, len(uses))
+	lastUsed := make([]time.Time, len(uses))
+	for i, use := range uses {
+		keys[i] = use.AccessKey
+		counts[i] = use.Count
+		firstUsed[i] = use.FirstUsedAt
+		lastUsed[i] = use.LastUsedAt
+	}
+
+	query := fmt.Sprintf(`
+		INSERT INTO %s AS t (access_key, created_at, last_used_at, request_count)
+		SELECT * FROM unnest($1::text[], $2::timestamptz[], $3::timestamptz[], $4::bigint[])
+		ON CONFLICT (access_key) DO UPDATE SET
+			request_count = t.request_count + excluded.request_count,
+			last_used_at = GREATEST(t.last_used_at, excluded.last_used_at)
+	`, r.accessKeysTable)
+
+	if _, err := r.pool.Exec(ctx, query, keys, firstUsed, lastUsed, counts); err != nil {
+		return fmt.Errorf("record access key use: %w", err)
+	}
+	return nil
+}
+
+func (r *repo) ListAccessKeys(ctx context.Context) (_ []stowry.AccessKeyInfo, err error) {
+	defer func() { err = internal.ContextError(ctx, err) }()
+
+	query := fmt.Sprintf(`
+		SELECT access_key, created_at, last_used_at, request_count, deprecated_at, COALESCE(replaced_by, ''), revoked_at
+		FROM %s
+		ORDER BY access_key
+	`, r.accessKeysTable)
+
+	rows, err := r.pool.Query(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("list access keys: %w", err)
+	}
+	defer rows.Close()
+
+	keys := []stowry.AccessKeyInfo{}
+	for rows.Next() {
+		var k stowry.AccessKeyInfo
+		var createdAt time.Time
+		if err := rows.Scan(&k.AccessKey, &createdAt, &k.LastUsedAt, &k.RequestCount, &k.DeprecatedAt, &k.ReplacedBy, &k.RevokedAt); err != nil {
+			return nil, fmt.Errorf("list access keys: scan: %w", err)
+		}
+		k.CreatedAt = &createdAt
+		keys = append(keys, k)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("list access keys: %w", err)
+	}
+	return keys, nil
+}
+
+func (r *repo) RotateAccessKey(ctx context.Context, oldKey, newKey string, at time.Time) (err error) {
+	defer func() { err = internal.ContextError(ctx, err) }()
+
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("rotate access key: begin: %w", err)
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	_, err = tx.Exec(ctx, fmt.Sprintf(`
+		INSERT INTO %s (access_key, created_at, request_count, deprecated_at, replaced_by)
+		VALUES ($1, $2, 0, $2, $3)
+		ON CONFLICT (access_key) DO UPDATE SET
+			deprecated_at = excluded.deprecated_at,
+			replaced_by = excluded.replaced_by
+	`, r.accessKeysTable), oldKey, at, newKey)
+	if err != nil {
+		return fmt.Errorf("rotate access key: deprecate %s: %w", oldKey, err)
+	}
+
+	_, err = tx.Exec(ctx, fmt.Sprintf(`
+		INSERT INTO %s (access_key, created_at, request_count) VALUES ($1, $2, 0)
+	`, r.accessKeysTable), newKey, at)
+	if err != nil {
+		return fmt.Errorf("rotate access key: create %s: %w", newKey, err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("rotate access key: commit: %w", err)
+	}
+	return nil
+}
+
+func (r *repo) RevokeAccessKey(ctx context.Context, accessKey string, at time.Time) (err error) {
+	defer func() { err = internal.ContextError(ctx, err) }()
+
+	_, err = r.pool.Exec(ctx, fmt.Sprintf(`
+		INSERT INTO %s (access_key, created_at, request_count, revoked_at)
+		VALUES ($1, $2, 0, $2)
+		ON CONFLICT (access_key) DO UPDATE SET revoked_at = excluded.revoked_at
+	`, r.accessKeysTable), accessKey, at)
+	if err != nil {
+		return fmt.Errorf("revoke access key %s: %w", accessKey, err)
+	}
+	return nil
+}
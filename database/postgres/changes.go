@@ -0,0 +1,161 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/sagarc03/stowry"
+	"github.com/sagarc03/stowry/database/internal"
+)
+
+// recordChanges appends a change for each of objects to the feed within tx.
+// The transaction-scoped advisory lock serializes writers from here to
+// commit, so sequences become visible in order and a reader that has seen
+// sequence n never misses a smaller one committed later.
+//
+// previousETags, when not nil, holds the ETag each object replaced, in the
+// order of objects; empty entries are stored as NULL. The actor comes from
+// ctx.
+func (r *repo) recordChanges(ctx context.Context, tx pgx.Tx, kind stowry.ChangeKind, objects []stowry.MetaData, previousETags []string) error {
+	if len(objects) == 0 {
+		return nil
+	}
+
+	if _, err := tx.Exec(ctx, `SELECT pg_advisory_xact_lock(hashtext($1))`, r.changesTable); err != nil {
+		return fmt.Errorf("record changes: lock: %w", err)
+	}
+
+	paths := make([]string, len(objects))
+	etags := make([]string, len(objects))
+	sizes := make([]int64, len(objects))
+	previous := make([]string, len(objects))
+	for i, m := range objects {
+		paths[i] = m.Path
+		etags[i] = m.Etag
+		sizes[i] = m.FileSizeBytes
+	}
+	copy(previous, previousETags)
+
+	query := fmt.Sprintf(`
+		INSERT INTO %s (kind, path, etag, file_size_bytes, previous_etag, actor)
+		SELECT $1, path, etag, file_size_bytes, NULLIF(previous_etag, ''), NULLIF($6, '')
+		FROM unnest($2::text[], $3::text[], $4::bigint[], $5::text[]) AS t(path, etag, file_size_bytes, previous_etag)
+	`, r.changesTable)
+
+	if _, err := tx.Exec(ctx, query, string(kind), paths, etags, sizes, previous, stowry.ActorFromContext(ctx)); err != nil {
+		return fmt.Errorf("record changes: %w", err)
+	}
+	return nil
+}
+
+func (r *repo) Changes(ctx context.Context, q stowry.ChangeQuery) (_ stowry.ChangeBatch, err error) {
+	defer func() { err = internal.ContextError(ctx, err) }()
+
+	if q.Limit <= 0 {
+		q.Limit = stowry.DefaultChangeLimit
+	}
+
+	var minSeq, maxSeq int64
+	err = r.pool.QueryRow(ctx, fmt.Sprintf(
+		`SELECT COALESCE(MIN(seq), 0), COALESCE(MAX(seq), 0) FROM %s`, r.changesTable)).Scan(&minSeq, &maxSeq)
+	if err != nil {
+		return stowry.ChangeBatch{}, fmt.Errorf("changes: %w", err)
+	}
+
+	next, err := internal.ChangeWindow(q.Since, minSeq, maxSeq)
+	if err != nil {
+		return stowry.ChangeBatch{}, fmt.Errorf("changes: %w", err)
+	}
+	if q.Since < 0 || next == q.Since {
+		return stowry.ChangeBatch{Changes: []stowry.Change{}, Next: next}, nil
+	}
+
+	query := fmt.Sprintf(`
+		SELECT %s
+		FROM %s
+		WHERE seq > $1 AND seq <= $2 AND path LIKE $3 || '%%'
+		ORDER BY seq
+		LIMIT $4
+	`, changeColumns, r.changesTable)
+
+	rows, err := r.pool.Query(ctx, query, q.Since, maxSeq, internal.EscapeLikePrefix(q.PathPrefix, internal.LikeEscape), q.Limit)
+	if err != nil {
+		return stowry.ChangeBatch{}, fmt.Errorf("changes: %w", err)
+	}
+	defer rows.Close()
+
+	changes, err := scanChanges(rows, q.Limit)
+	if err != nil {
+		return stowry.ChangeBatch{}, fmt.Errorf("changes: %w", err)
+	}
+
+	return internal.ChangePage(changes, q.Limit, next), nil
+}
+
+func (r *repo) History(ctx context.Context, q stowry.HistoryQuery) (_ stowry.History, err error) {
+	defer func() { err = internal.ContextError(ctx, err) }()
+
+	if q.Limit <= 0 {
+		q.Limit = stowry.DefaultChangeLimit
+	}
+
+	// One extra row tells whether another page follows
+	query := fmt.Sprintf(`
+		SELECT %s
+		FROM %s
+		WHERE path = $1 AND seq > $2
+		ORDER BY seq
+		LIMIT $3
+	`, changeColumns, r.changesTable)
+
+	rows, err := r.pool.Query(ctx, query, q.Path, q.After, q.Limit+1)
+	if err != nil {
+		return stowry.History{}, fmt.Errorf("history: %w", err)
+	}
+	defer rows.Close()
+
+	changes, err := scanChanges(rows, q.Limit+1)
+	if err != nil {
+		return stowry.History{}, fmt.Errorf("history: %w", err)
+	}
+
+	return internal.HistoryPage(q.Path, changes, q.Limit), nil
+}
+
+// changeColumns are the change feed columns read by scanChanges.
+const changeColumns = `seq, kind, path, etag, file_size_bytes, created_at, COALESCE(previous_etag, ''), COALESCE(actor, '')`
+
+// scanChanges reads rows selected with changeColumns.
+func scanChanges(rows pgx.Rows, capacity int) ([]stowry.Change, error) {
+	changes := make([]stowry.Change, 0, capacity)
+	for rows.Next() {
+		var c stowry.Change
+		var kind string
+		if err := rows.Scan(&c.Seq, &kind, &c.Path, &c.ETag, &c.Size, &c.At, &c.PreviousETag, &c.Actor); err != nil {
+			return nil, fmt.Errorf("scan: %w", err)
+		}
+		c.Kind = stowry.ChangeKind(kind)
+		changes = append(changes, c)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return changes, nil
+}
+
+func (r *repo) PruneChanges(ctx context.Context, before time.Time) (_ int64, err error) {
+	defer func() { err = internal.ContextError(ctx, err) }()
+
+	query := fmt.Sprintf(`
+		DELETE FROM %s
+		WHERE created_at < $1 AND seq < (SELECT MAX(seq) FROM %s)
+	`, r.changesTable, r.changesTable)
+
+	result, err := r.pool.Exec(ctx, query, before)
+	if err != nil {
+		return 0, fmt.Errorf("prune changes: %w", err)
+	}
+	return result.RowsAffected(), nil
+}
@@ -6,56 +6,148 @@ import (
 
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/sagarc03/stowry"
+	"github.com/sagarc03/stowry/database/internal"
 )
 
 type database struct {
-	pool   *pgxpool.Pool
-	tables stowry.Tables
+	pool     *pgxpool.Pool
+	tables   stowry.Tables
+	idScheme stowry.IDScheme
+}
+
+// Option customizes a database created by Connect.
+type Option func(*database)
+
+// WithIDScheme sets how the IDs of new metadata rows are generated. The
+// default is stowry.IDSchemeUUIDv4.
+func WithIDScheme(scheme stowry.IDScheme) Option {
+	return func(d *database) {
+		d.idScheme = scheme
+	}
 }
 
 // Connect establishes a connection to PostgreSQL.
 // Tables should be validated before calling Connect.
-func Connect(ctx context.Context, dsn string, tables stowry.Tables) (*database, error) {
+func Connect(ctx context.Context, dsn string, tables stowry.Tables, opts ...Option) (*database, error) {
+	d := &database{tables: tables}
+	for _, opt := range opts {
+		opt(d)
+	}
+	if !d.idScheme.Valid() {
+		return nil, fmt.Errorf("connect postgres: unknown id scheme %q", d.idScheme)
+	}
+
 	pool, err := pgxpool.New(ctx, dsn)
 	if err != nil {
 		return nil, fmt.Errorf("connect postgres: %w", err)
 	}
-
-	return &database{
-		pool:   pool,
-		tables: tables,
-	}, nil
+	d.pool = pool
+	return d, nil
 }
 
 // Ping verifies the database connection is alive.
 func (d *database) Ping(ctx context.Context) error {
-	return d.pool.Ping(ctx)
+	return internal.ContextError(ctx, d.pool.Ping(ctx))
 }
 
 // Migrate runs database migrations to create required tables.
-func (d *database) Migrate(ctx context.Context) error {
-	if err := createMetaTable(ctx, d.pool, d.tables.MetaData); err != nil {
-		return fmt.Errorf("migrate: %w", err)
+func (d *database) Migrate(ctx context.Context) (err error) {
+	defer func() { err = internal.ContextError(ctx, err) }()
+
+	for _, ddl := range tableDDL(d.tables) {
+		if err := migrateTable(ctx, d.pool, ddl); err != nil {
+			return fmt.Errorf("migrate: %w", err)
+		}
 	}
 	return nil
 }
 
 // Validate checks that the database schema matches expected structure.
-func (d *database) Validate(ctx context.Context) error {
-	validations := getTableValidations(d.tables)
+// A mismatch is returned as a *internal.SchemaDiff covering every table.
+func (d *database) Validate(ctx context.Context) (err error) {
+	defer func() { err = internal.ContextError(ctx, err) }()
+
+	diff, err := d.schemaDiff(ctx)
+	if err != nil {
+		return err
+	}
+	if !diff.Empty() {
+		return fmt.Errorf("validate schema: %w", diff)
+	}
+	return nil
+}
+
+// Plan returns the steps that resolve the schema's differences from the
+// expected one, without running them.
+func (d *database) Plan(ctx context.Context) (steps []internal.MigrationStep, err error) {
+	defer func() { err = internal.ContextError(ctx, err) }()
 
-	for _, validation := range validations {
-		if err := validateTableSchema(ctx, d.pool, validation.tableName, validation.expectedSchema); err != nil {
-			return fmt.Errorf("validate schema %s: %w", validation.tableName, err)
+	diff, err := d.schemaDiff(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("plan migrations: %w", err)
+	}
+	return internal.PlanMigrations(diff, tableDDL(d.tables), changeColumn), nil
+}
+
+// Apply runs steps in one transaction, so a failed step leaves the schema
+// as it was. It refuses manual steps before running any.
+func (d *database) Apply(ctx context.Context, steps []internal.MigrationStep) (err error) {
+	defer func() { err = internal.ContextError(ctx, err) }()
+
+	for _, step := range steps {
+		if step.Manual() {
+			return fmt.Errorf("apply migrations: %s: %s must be done by hand", step.Table, step.Description)
 		}
 	}
 
+	tx, err := d.pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("apply migrations: begin: %w", err)
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	for _, step := range steps {
+		if _, err := tx.Exec(ctx, step.SQL); err != nil {
+			return fmt.Errorf("apply migrations: %s: %s: %w", step.Table, step.Description, err)
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("apply migrations: commit: %w", err)
+	}
 	return nil
 }
 
+// schemaDiff compares every table with its expected schema.
+func (d *database) schemaDiff(ctx context.Context) (*internal.SchemaDiff, error) {
+	diff := &internal.SchemaDiff{}
+	for _, validation := range getTableValidations(d.tables) {
+		table, err := compareTableSchema(ctx, d.pool, validation.tableName, validation.expectedSchema)
+		if err != nil {
+			return nil, fmt.Errorf("validate schema %s: %w", validation.tableName, err)
+		}
+		diff.Add(table)
+	}
+	return diff, nil
+}
+
 // GetRepo returns the MetaDataRepo for database operations.
 func (d *database) GetRepo() stowry.MetaDataRepo {
-	return &repo{pool: d.pool, tableName: d.tables.MetaData}
+	return &repo{
+		pool:               d.pool,
+		tableName:          d.tables.MetaData,
+		stagesTable:        d.tables.Stages(),
+		stagedObjectsTable: d.tables.StagedObjects(),
+		changesTable:       d.tables.Changes(),
+		settingsTable:      d.tables.Settings(),
+		usageTable:         d.tables.Usage(),
+		usagePrefixesTable: d.tables.UsagePrefixes(),
+		accessKeysTable:    d.tables.AccessKeys(),
+		writeIntentsTable:  d.tables.WriteIntents(),
+		uploadsTable:       d.tables.Uploads(),
+		uploadPartsTable:   d.tables.UploadParts(),
+		idScheme:           d.idScheme,
+	}
 }
 
 // Close closes the database connection pool.
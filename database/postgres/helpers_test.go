@@ -3,6 +3,7 @@ package postgres_test
 import (
 	"context"
 	"crypto/rand"
+	"errors"
 	"fmt"
 	"math"
 	"math/big"
@@ -12,6 +13,7 @@ import (
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/sagarc03/stowry"
+	"github.com/sagarc03/stowry/database/internal"
 	"github.com/sagarc03/stowry/database/postgres"
 	"github.com/stretchr/testify/assert"
 	"github.com/testcontainers/testcontainers-go"
@@ -92,7 +94,7 @@ func getDSN(pool *pgxpool.Pool) string {
 }
 
 // setupTestRepo creates a repo with a unique table name for test isolation.
-func setupTestRepo(t *testing.T) (stowry.MetaDataRepo, func()) {
+func setupTestRepo(t *testing.T, opts ...postgres.Option) (stowry.MetaDataRepo, func()) {
 	t.Helper()
 
 	pool := getSharedTestDatabase(t)
@@ -102,7 +104,7 @@ func setupTestRepo(t *testing.T) (stowry.MetaDataRepo, func()) {
 	tableName := fmt.Sprintf("metadata_%s", getRandomString(t))
 	tables := stowry.Tables{MetaData: tableName}
 
-	db, err := postgres.Connect(ctx, getDSN(pool), tables)
+	db, err := postgres.Connect(ctx, getDSN(pool), tables, opts...)
 	assert.NoError(t, err, "failed to connect")
 
 	// Migrate the table
@@ -111,9 +113,48 @@ func setupTestRepo(t *testing.T) (stowry.MetaDataRepo, func()) {
 
 	cleanup := func() {
 		_ = db.Close()
-		// Drop the table after the test
+		// Drop the tables after the test
 		_ = dropTable(ctx, pool, tableName)
+		_ = dropTable(ctx, pool, tables.Stages())
+		_ = dropTable(ctx, pool, tables.StagedObjects())
+		_ = dropTable(ctx, pool, tables.Changes())
+		_ = dropTable(ctx, pool, tables.Settings())
+		_ = dropTable(ctx, pool, tables.Usage())
+		_ = dropTable(ctx, pool, tables.UsagePrefixes())
+		_ = dropTable(ctx, pool, tables.AccessKeys())
+		_ = dropTable(ctx, pool, tables.WriteIntents())
+		_ = dropTable(ctx, pool, tables.Uploads())
+		_ = dropTable(ctx, pool, tables.UploadParts())
 	}
 
 	return db.GetRepo(), cleanup
 }
+
+// schemaDiff returns the schema diff err carries.
+func schemaDiff(t *testing.T, err error) *internal.SchemaDiff {
+	t.Helper()
+	var diff *internal.SchemaDiff
+	if !errors.As(err, &diff) {
+		t.Fatalf("expected a schema diff, got %v", err)
+	}
+	return diff
+}
+
+func diffTable(t *testing.T, diff *internal.SchemaDiff, name string) internal.TableDiff {
+	t.Helper()
+	for _, table := range diff.Tables {
+		if table.Table == name {
+			return table
+		}
+	}
+	t.Fatalf("table %s not in diff", name)
+	return internal.TableDiff{}
+}
+
+func columnNames(columns []internal.ColumnDiff) []string {
+	names := make([]string, len(columns))
+	for i, c := range columns {
+		names[i] = c.Name
+	}
+	return names
+}
@@ -0,0 +1,45 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/sagarc03/stowry"
+	"github.com/sagarc03/stowry/database/internal"
+)
+
+// instanceIDKey is the settings key holding the instance ID.
+const instanceIDKey = "instance_id"
+
+func (r *repo) InstanceID(ctx context.Context) (_ string, err error) {
+	defer func() { err = internal.ContextError(ctx, err) }()
+
+	query := fmt.Sprintf(`SELECT value FROM %s WHERE key = $1`, r.settingsTable)
+
+	var id string
+	if err := r.pool.QueryRow(ctx, query, instanceIDKey).Scan(&id); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return "", fmt.Errorf("instance id: %w", stowry.ErrNotFound)
+		}
+		return "", fmt.Errorf("instance id: %w", err)
+	}
+	return id, nil
+}
+
+func (r *repo) SetInstanceID(ctx context.Context, id string) (err error) {
+	defer func() { err = internal.ContextError(ctx, err) }()
+
+	query := fmt.Sprintf(`
+		INSERT INTO %s (key, value) VALUES ($1, $2)
+		ON CONFLICT (key) DO UPDATE
+		SET value = EXCLUDED.value,
+			updated_at = NOW()
+	`, r.settingsTable)
+
+	if _, err := r.pool.Exec(ctx, query, instanceIDKey, id); err != nil {
+		return fmt.Errorf("set instance id: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,76 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sagarc03/stowry"
+	"github.com/sagarc03/stowry/database/internal"
+)
+
+func (r *repo) RecordWriteIntent(ctx context.Context, intent stowry.WriteIntent) (_ stowry.WriteIntent, err error) {
+	defer func() { err = internal.ContextError(ctx, err) }()
+
+	query := fmt.Sprintf(`
+		INSERT INTO %s (id, path, content_type, content_encoding, filename, object_id, user_metadata, cache_control)
+		VALUES ($1, $2, $3, NULLIF($4, ''), NULLIF($5, ''), $6, NULLIF($7, '')::jsonb, NULLIF($8, ''))
+		RETURNING id, started_at
+	`, r.writeIntentsTable)
+
+	err = r.pool.QueryRow(ctx, query, uuid.New(), intent.Path, intent.ContentType, intent.ContentEncoding, intent.Filename,
+		nullUUID(intent.ObjectID), internal.EncodeUserMetadata(intent.UserMetadata), intent.CacheControl).
+		Scan(&intent.ID, &intent.StartedAt)
+	if err != nil {
+		return stowry.WriteIntent{}, fmt.Errorf("record write intent %s: %w", intent.Path, err)
+	}
+	return intent, nil
+}
+
+func (r *repo) ClearWriteIntent(ctx context.Context, id uuid.UUID) (err error) {
+	defer func() { err = internal.ContextError(ctx, err) }()
+
+	query := fmt.Sprintf(`DELETE FROM %s WHERE id = $1`, r.writeIntentsTable)
+
+	if _, err := r.pool.Exec(ctx, query, id); err != nil {
+		return fmt.Errorf("clear write intent %s: %w", id, err)
+	}
+	return nil
+}
+
+func (r *repo) WriteIntents(ctx context.Context, before time.Time) (_ []stowry.WriteIntent, err error) {
+	defer func() { err = internal.ContextError(ctx, err) }()
+
+	query := fmt.Sprintf(`
+		SELECT id, path, content_type, COALESCE(content_encoding, ''), COALESCE(filename, ''), object_id, started_at, user_metadata, COALESCE(cache_control, '')
+		FROM %s
+		WHERE started_at < $1
+		ORDER BY started_at, id
+	`, r.writeIntentsTable)
+
+	rows, err := r.pool.Query(ctx, query, before)
+	if err != nil {
+		return nil, fmt.Errorf("list write intents: %w", err)
+	}
+	defer rows.Close()
+
+	intents := []stowry.WriteIntent{}
+	for rows.Next() {
+		var intent stowry.WriteIntent
+		var objectID *uuid.UUID
+		if err := rows.Scan(&intent.ID, &intent.Path, &intent.ContentType, &intent.ContentEncoding, &intent.Filename, &objectID, &intent.StartedAt,
+			&intent.UserMetadata, &intent.CacheControl); err != nil {
+			return nil, fmt.Errorf("list write intents: scan: %w", err)
+		}
+		if objectID != nil {
+			intent.ObjectID = *objectID
+		}
+		intent.StartedAt = intent.StartedAt.UTC()
+		intents = append(intents, intent)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("list write intents: %w", err)
+	}
+	return intents, nil
+}
@@ -3,51 +3,302 @@ package postgres
 import (
 	"context"
 	"fmt"
+	"slices"
 
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/sagarc03/stowry"
+	"github.com/sagarc03/stowry/database/internal"
 )
 
-func createMetaTable(ctx context.Context, pool *pgxpool.Pool, tableName string) error {
-	quotedTable := pgx.Identifier{tableName}.Sanitize()
-	indexDeletedAt := pgx.Identifier{fmt.Sprintf("idx_%s_deleted_at", tableName)}.Sanitize()
-	indexPendingCleanup := pgx.Identifier{fmt.Sprintf("idx_%s_pending_cleanup", tableName)}.Sanitize()
-	indexActiveList := pgx.Identifier{fmt.Sprintf("idx_%s_active_list", tableName)}.Sanitize()
-
-	sql := fmt.Sprintf(`
-		CREATE TABLE IF NOT EXISTS %s (
-			id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
-			path TEXT NOT NULL UNIQUE,
-			content_type TEXT NOT NULL,
-			etag TEXT NOT NULL,
-			file_size_bytes BIGINT NOT NULL,
-			created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
-			updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
-			deleted_at TIMESTAMPTZ,
-			cleaned_up_at TIMESTAMPTZ
-		);
-
-		CREATE INDEX IF NOT EXISTS %s
-		ON %s (deleted_at)
-		WHERE (deleted_at IS NOT NULL);
-
-		CREATE INDEX IF NOT EXISTS %s
-		ON %s (deleted_at, cleaned_up_at)
-		WHERE (deleted_at IS NOT NULL AND cleaned_up_at IS NULL);
-
-		CREATE INDEX IF NOT EXISTS %s
-		ON %s (created_at, path)
-		WHERE (deleted_at IS NULL);
-	`,
-		quotedTable,
-		indexDeletedAt, quotedTable,
-		indexPendingCleanup, quotedTable,
-		indexActiveList, quotedTable,
-	)
-
-	_, err := pool.Exec(ctx, sql)
-	if err != nil {
-		return fmt.Errorf("create meta table: %w", err)
+type columnDef struct {
+	name       string
+	definition string
+}
+
+// metaDataAddedColumns are the metadata columns added after the initial
+// schema; existing tables gain them on migrate.
+var metaDataAddedColumns = []columnDef{
+	{name: "download_count", definition: "BIGINT"},
+	{name: "last_accessed_at", definition: "TIMESTAMPTZ"},
+	{name: "content_encoding", definition: "TEXT"},
+	{name: "decoded_size_bytes", definition: "BIGINT"},
+	{name: "quarantined_at", definition: "TIMESTAMPTZ"},
+	{name: "filename", definition: "TEXT"},
+	{name: "storage_backend", definition: "TEXT"},
+	{name: "cleanup_retry_at", definition: "TIMESTAMPTZ"},
+	{name: "user_metadata", definition: "JSONB"},
+	{name: "cache_control", definition: "TEXT"},
+}
+
+// stagedObjectsAddedColumns are the staged object columns added after the
+// initial schema.
+var stagedObjectsAddedColumns = []columnDef{
+	{name: "content_encoding", definition: "TEXT"},
+	{name: "decoded_size_bytes", definition: "BIGINT"},
+	{name: "filename", definition: "TEXT"},
+	{name: "storage_backend", definition: "TEXT"},
+	{name: "user_metadata", definition: "JSONB"},
+	{name: "cache_control", definition: "TEXT"},
+}
+
+// writeIntentsAddedColumns are the write intent columns added after the
+// initial schema.
+var writeIntentsAddedColumns = []columnDef{
+	{name: "user_metadata", definition: "JSONB"},
+	{name: "cache_control", definition: "TEXT"},
+}
+
+// uploadsAddedColumns are the multipart upload columns added after the
+// initial schema.
+var uploadsAddedColumns = []columnDef{
+	{name: "user_metadata", definition: "JSONB"},
+	{name: "cache_control", definition: "TEXT"},
+}
+
+// changesAddedColumns are the change feed columns added after the initial
+// schema.
+var changesAddedColumns = []columnDef{
+	{name: "previous_etag", definition: "TEXT"},
+	{name: "actor", definition: "TEXT"},
+}
+
+// tableDDL returns the statements that create each table and bring one
+// created by an older release up to date. Every statement is idempotent.
+func tableDDL(tables stowry.Tables) []internal.TableDDL {
+	metaData := pgx.Identifier{tables.MetaData}.Sanitize()
+	changes := pgx.Identifier{tables.Changes()}.Sanitize()
+
+	return []internal.TableDDL{{
+		Table: tables.MetaData,
+		Create: fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+	id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+	path TEXT NOT NULL UNIQUE,
+	content_type TEXT NOT NULL,
+	etag TEXT NOT NULL,
+	file_size_bytes BIGINT NOT NULL,
+	created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+	updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+	deleted_at TIMESTAMPTZ,
+	cleaned_up_at TIMESTAMPTZ,
+	download_count BIGINT,
+	last_accessed_at TIMESTAMPTZ,
+	content_encoding TEXT,
+	decoded_size_bytes BIGINT,
+	quarantined_at TIMESTAMPTZ,
+	filename TEXT,
+	storage_backend TEXT,
+	cleanup_retry_at TIMESTAMPTZ,
+	user_metadata JSONB,
+	cache_control TEXT
+)`, metaData),
+		Columns: addColumns(metaData, metaDataAddedColumns),
+		Indexes: []internal.Statement{
+			createIndex(tables.MetaData, "deleted_at", "(deleted_at) WHERE (deleted_at IS NOT NULL)"),
+			createIndex(tables.MetaData, "pending_cleanup", "(deleted_at, cleaned_up_at) WHERE (deleted_at IS NOT NULL AND cleaned_up_at IS NULL)"),
+			createIndex(tables.MetaData, "active_list", "(created_at, path) WHERE (deleted_at IS NULL)"),
+			createIndex(tables.MetaData, "etag", "(etag, updated_at) WHERE (deleted_at IS NULL)"),
+		},
+	}, {
+		Table: tables.Stages(),
+		Create: fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+	id UUID PRIMARY KEY,
+	created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+)`, pgx.Identifier{tables.Stages()}.Sanitize()),
+	}, {
+		Table: tables.StagedObjects(),
+		Create: fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+	stage_id UUID NOT NULL,
+	path TEXT NOT NULL,
+	content_type TEXT NOT NULL,
+	etag TEXT NOT NULL,
+	file_size_bytes BIGINT NOT NULL,
+	created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+	content_encoding TEXT,
+	decoded_size_bytes BIGINT,
+	filename TEXT,
+	storage_backend TEXT,
+	user_metadata JSONB,
+	cache_control TEXT,
+	PRIMARY KEY (stage_id, path)
+)`, pgx.Identifier{tables.StagedObjects()}.Sanitize()),
+		Columns: addColumns(pgx.Identifier{tables.StagedObjects()}.Sanitize(), stagedObjectsAddedColumns),
+	}, {
+		Table: tables.Changes(),
+		Create: fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+	seq BIGSERIAL PRIMARY KEY,
+	kind TEXT NOT NULL,
+	path TEXT NOT NULL,
+	etag TEXT NOT NULL,
+	file_size_bytes BIGINT NOT NULL,
+	created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+	previous_etag TEXT,
+	actor TEXT
+)`, changes),
+		Columns: addColumns(changes, changesAddedColumns),
+		Indexes: []internal.Statement{
+			createIndex(tables.Changes(), "created_at", "(created_at)"),
+			createIndex(tables.Changes(), "path", "(path, seq)"),
+		},
+	}, {
+		Table: tables.Settings(),
+		Create: fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+	key TEXT PRIMARY KEY,
+	value TEXT NOT NULL,
+	updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+)`, pgx.Identifier{tables.Settings()}.Sanitize()),
+	}, {
+		Table: tables.Usage(),
+		Create: fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+	id BIGSERIAL PRIMARY KEY,
+	taken_at TIMESTAMPTZ NOT NULL,
+	change_seq BIGINT NOT NULL,
+	complete BOOLEAN NOT NULL
+)`, pgx.Identifier{tables.Usage()}.Sanitize()),
+		Indexes: []internal.Statement{
+			createIndex(tables.Usage(), "taken_at", "(taken_at)"),
+		},
+	}, {
+		Table: tables.UsagePrefixes(),
+		Create: fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+	snapshot_id BIGINT NOT NULL,
+	prefix TEXT NOT NULL,
+	objects BIGINT NOT NULL,
+	bytes BIGINT NOT NULL,
+	objects_added BIGINT NOT NULL,
+	bytes_added BIGINT NOT NULL,
+	PRIMARY KEY (snapshot_id, prefix)
+)`, pgx.Identifier{tables.UsagePrefixes()}.Sanitize()),
+	}, {
+		Table: tables.AccessKeys(),
+		Create: fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+	access_key TEXT NOT NULL PRIMARY KEY,
+	created_at TIMESTAMPTZ NOT NULL,
+	last_used_at TIMESTAMPTZ,
+	request_count BIGINT NOT NULL,
+	deprecated_at TIMESTAMPTZ,
+	replaced_by TEXT,
+	revoked_at TIMESTAMPTZ
+)`, pgx.Identifier{tables.AccessKeys()}.Sanitize()),
+	}, {
+		Table: tables.WriteIntents(),
+		Create: fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+	id UUID PRIMARY KEY,
+	path TEXT NOT NULL,
+	content_type TEXT NOT NULL,
+	content_encoding TEXT,
+	filename TEXT,
+	object_id UUID,
+	started_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+	user_metadata JSONB,
+	cache_control TEXT
+)`, pgx.Identifier{tables.WriteIntents()}.Sanitize()),
+		Columns: addColumns(pgx.Identifier{tables.WriteIntents()}.Sanitize(), writeIntentsAddedColumns),
+	}, {
+		Table: tables.Uploads(),
+		Create: fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+	id UUID PRIMARY KEY,
+	path TEXT NOT NULL,
+	content_type TEXT NOT NULL,
+	content_encoding TEXT,
+	filename TEXT,
+	created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+	user_metadata JSONB,
+	cache_control TEXT
+)`, pgx.Identifier{tables.Uploads()}.Sanitize()),
+		Columns: addColumns(pgx.Identifier{tables.Uploads()}.Sanitize(), uploadsAddedColumns),
+	}, {
+		Table: tables.UploadParts(),
+		Create: fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+	upload_id UUID NOT NULL,
+	part_number INTEGER NOT NULL,
+	etag TEXT NOT NULL,
+	size_bytes BIGINT NOT NULL,
+	uploaded_at TIMESTAMPTZ NOT NULL,
+	PRIMARY KEY (upload_id, part_number)
+)`, pgx.Identifier{tables.UploadParts()}.Sanitize()),
+	}}
+}
+
+func addColumns(quotedTable string, columns []columnDef) []internal.Statement {
+	statements := make([]internal.Statement, len(columns))
+	for i, col := range columns {
+		statements[i] = internal.Statement{
+			Name: col.name,
+			SQL:  fmt.Sprintf(`ALTER TABLE %s ADD COLUMN IF NOT EXISTS %s %s`, quotedTable, pgx.Identifier{col.name}.Sanitize(), col.definition),
+		}
+	}
+	return statements
+}
+
+// createIndex returns the statement creating index idx_<table>_<suffix>
+// with the given columns and predicate.
+func createIndex(table, suffix, definition string) internal.Statement {
+	name := fmt.Sprintf("idx_%s_%s", table, suffix)
+	return internal.Statement{
+		Name: name,
+		SQL:  fmt.Sprintf(`CREATE INDEX IF NOT EXISTS %s ON %s %s`, pgx.Identifier{name}.Sanitize(), pgx.Identifier{table}.Sanitize(), definition),
+	}
+}
+
+// migrateTable creates the table if needed, then adds the columns and
+// indexes it lacks.
+func migrateTable(ctx context.Context, pool *pgxpool.Pool, ddl internal.TableDDL) error {
+	if _, err := pool.Exec(ctx, ddl.Create); err != nil {
+		return fmt.Errorf("create table %s: %w", ddl.Table, err)
+	}
+	for _, col := range ddl.Columns {
+		if _, err := pool.Exec(ctx, col.SQL); err != nil {
+			return fmt.Errorf("add column %s.%s: %w", ddl.Table, col.Name, err)
+		}
+	}
+	for _, index := range ddl.Indexes {
+		if _, err := pool.Exec(ctx, index.SQL); err != nil {
+			return fmt.Errorf("create index %s: %w", index.Name, err)
+		}
 	}
 	return nil
 }
+
+// widenings lists, for each column type, the types it converts to without
+// losing data.
+var widenings = map[string][]string{
+	"smallint":          {"integer", "bigint"},
+	"integer":           {"bigint"},
+	"character varying": {"text"},
+}
+
+// changeColumn returns the steps that alter a column to its expected type
+// and nullability. Changing the type is destructive unless it widens the
+// column; adding NOT NULL is destructive because it rejects existing rows.
+func changeColumn(table string, c internal.ColumnDiff) []internal.MigrationStep {
+	quotedTable := pgx.Identifier{table}.Sanitize()
+	quotedColumn := pgx.Identifier{c.Name}.Sanitize()
+
+	var steps []internal.MigrationStep
+	if c.Expected.Type != c.Actual.Type {
+		steps = append(steps, internal.MigrationStep{
+			Table:       table,
+			Description: fmt.Sprintf("change column %s from %s to %s", c.Name, c.Actual.Type, c.Expected.Type),
+			SQL: fmt.Sprintf(`ALTER TABLE %s ALTER COLUMN %s TYPE %s USING %s::%s`,
+				quotedTable, quotedColumn, c.Expected.Type, quotedColumn, c.Expected.Type),
+			Destructive: !slices.Contains(widenings[c.Actual.Type], c.Expected.Type),
+		})
+	}
+	switch {
+	case c.Expected.Nullable && !c.Actual.Nullable:
+		steps = append(steps, internal.MigrationStep{
+			Table:       table,
+			Description: fmt.Sprintf("allow null in column %s", c.Name),
+			SQL:         fmt.Sprintf(`ALTER TABLE %s ALTER COLUMN %s DROP NOT NULL`, quotedTable, quotedColumn),
+		})
+	case !c.Expected.Nullable && c.Actual.Nullable:
+		steps = append(steps, internal.MigrationStep{
+			Table:       table,
+			Description: fmt.Sprintf("make column %s not null", c.Name),
+			SQL:         fmt.Sprintf(`ALTER TABLE %s ALTER COLUMN %s SET NOT NULL`, quotedTable, quotedColumn),
+			Destructive: true,
+		})
+	}
+	return steps
+}
@@ -0,0 +1,171 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/sagarc03/stowry"
+	"github.com/sagarc03/stowry/database/internal"
+)
+
+func (r *repo) CreateMultipartUpload(ctx context.Context, upload stowry.MultipartUpload) (_ stowry.MultipartUpload, err error) {
+	defer func() { err = internal.ContextError(ctx, err) }()
+
+	query := fmt.Sprintf(`
+		INSERT INTO %s (id, path, content_type, content_encoding, filename, user_metadata, cache_control)
+		VALUES ($1, $2, $3, NULLIF($4, ''), NULLIF($5, ''), NULLIF($6, '')::jsonb, NULLIF($7, ''))
+		RETURNING id, created_at
+	`, r.uploadsTable)
+
+	err = r.pool.QueryRow(ctx, query, uuid.New(), upload.Path, upload.ContentType, upload.ContentEncoding, upload.Filename,
+		internal.EncodeUserMetadata(upload.UserMetadata), upload.CacheControl).
+		Scan(&upload.ID, &upload.CreatedAt)
+	if err != nil {
+		return stowry.MultipartUpload{}, fmt.Errorf("create multipart upload %s: %w", upload.Path, err)
+	}
+	upload.CreatedAt = upload.CreatedAt.UTC()
+	return upload, nil
+}
+
+func (r *repo) GetMultipartUpload(ctx context.Context, id uuid.UUID) (_ stowry.MultipartUpload, _ []stowry.MultipartPart, err error) {
+	defer func() { err = internal.ContextError(ctx, err) }()
+
+	query := fmt.Sprintf(`
+		SELECT id, path, content_type, COALESCE(content_encoding, ''), COALESCE(filename, ''), created_at, user_metadata, COALESCE(cache_control, '')
+		FROM %s WHERE id = $1
+	`, r.uploadsTable)
+
+	upload, err := scanMultipartUpload(r.pool.QueryRow(ctx, query, id))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return stowry.MultipartUpload{}, nil, fmt.Errorf("get multipart upload %s: %w", id, stowry.ErrNotFound)
+	}
+	if err != nil {
+		return stowry.MultipartUpload{}, nil, fmt.Errorf("get multipart upload %s: %w", id, err)
+	}
+
+	partsQuery := fmt.Sprintf(`
+		SELECT part_number, etag, size_bytes, uploaded_at
+		FROM %s WHERE upload_id = $1
+		ORDER BY part_number
+	`, r.uploadPartsTable)
+
+	rows, err := r.pool.Query(ctx, partsQuery, id)
+	if err != nil {
+		return stowry.MultipartUpload{}, nil, fmt.Errorf("get multipart upload %s: parts: %w", id, err)
+	}
+	defer rows.Close()
+
+	parts := []stowry.MultipartPart{}
+	for rows.Next() {
+		var part stowry.MultipartPart
+		if err := rows.Scan(&part.Number, &part.ETag, &part.Size, &part.UploadedAt); err != nil {
+			return stowry.MultipartUpload{}, nil, fmt.Errorf("get multipart upload %s: scan part: %w", id, err)
+		}
+		part.UploadedAt = part.UploadedAt.UTC()
+		parts = append(parts, part)
+	}
+	if err := rows.Err(); err != nil {
+		return stowry.MultipartUpload{}, nil, fmt.Errorf("get multipart upload %s: parts: %w", id, err)
+	}
+	return upload, parts, nil
+}
+
+func (r *repo) AddMultipartPart(ctx context.Context, id uuid.UUID, part stowry.MultipartPart) (err error) {
+	defer func() { err = internal.ContextError(ctx, err) }()
+
+	// The SELECT yields no row for an unknown upload, so nothing is inserted
+	query := fmt.Sprintf(`
+		INSERT INTO %s (upload_id, part_number, etag, size_bytes, uploaded_at)
+		SELECT id, $2, $3, $4, $5 FROM %s WHERE id = $1
+		ON CONFLICT (upload_id, part_number) DO UPDATE
+		SET etag = EXCLUDED.etag,
+			size_bytes = EXCLUDED.size_bytes,
+			uploaded_at = EXCLUDED.uploaded_at
+	`, r.uploadPartsTable, r.uploadsTable)
+
+	result, err := r.pool.Exec(ctx, query, id, part.Number, part.ETag, part.Size, part.UploadedAt)
+	if err != nil {
+		return fmt.Errorf("add multipart part %d: %w", part.Number, err)
+	}
+	if result.RowsAffected() == 0 {
+		return fmt.Errorf("add multipart part %d: upload %s: %w", part.Number, id, stowry.ErrNotFound)
+	}
+	return nil
+}
+
+func (r *repo) DeleteMultipartUpload(ctx context.Context, id uuid.UUID) (err error) {
+	defer func() { err = internal.ContextError(ctx, err) }()
+
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("delete multipart upload: begin: %w", err)
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	if _, err := tx.Exec(ctx, fmt.Sprintf(`DELETE FROM %s WHERE upload_id = $1`, r.uploadPartsTable), id); err != nil {
+		return fmt.Errorf("delete multipart upload: parts: %w", err)
+	}
+
+	result, err := tx.Exec(ctx, fmt.Sprintf(`DELETE FROM %s WHERE id = $1`, r.uploadsTable), id)
+	if err != nil {
+		return fmt.Errorf("delete multipart upload: %w", err)
+	}
+	if result.RowsAffected() == 0 {
+		return fmt.Errorf("delete multipart upload %s: %w", id, stowry.ErrNotFound)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("delete multipart upload: commit: %w", err)
+	}
+	return nil
+}
+
+func (r *repo) StaleMultipartUploads(ctx context.Context, before time.Time, limit int) (_ []stowry.MultipartUpload, err error) {
+	defer func() { err = internal.ContextError(ctx, err) }()
+
+	// LIMIT NULL is no limit
+	var limitArg *int
+	if limit > 0 {
+		limitArg = &limit
+	}
+	query := fmt.Sprintf(`
+		SELECT id, path, content_type, COALESCE(content_encoding, ''), COALESCE(filename, ''), created_at, user_metadata, COALESCE(cache_control, '')
+		FROM %s
+		WHERE created_at < $1
+		ORDER BY created_at, id
+		LIMIT $2
+	`, r.uploadsTable)
+
+	rows, err := r.pool.Query(ctx, query, before, limitArg)
+	if err != nil {
+		return nil, fmt.Errorf("list stale multipart uploads: %w", err)
+	}
+	defer rows.Close()
+
+	uploads := []stowry.MultipartUpload{}
+	for rows.Next() {
+		upload, err := scanMultipartUpload(rows)
+		if err != nil {
+			return nil, fmt.Errorf("list stale multipart uploads: scan: %w", err)
+		}
+		uploads = append(uploads, upload)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("list stale multipart uploads: %w", err)
+	}
+	return uploads, nil
+}
+
+func scanMultipartUpload(row pgx.Row) (stowry.MultipartUpload, error) {
+	var upload stowry.MultipartUpload
+	if err := row.Scan(&upload.ID, &upload.Path, &upload.ContentType, &upload.ContentEncoding, &upload.Filename, &upload.CreatedAt,
+		&upload.UserMetadata, &upload.CacheControl); err != nil {
+		return stowry.MultipartUpload{}, err
+	}
+	upload.CreatedAt = upload.CreatedAt.UTC()
+	return upload, nil
+}
@@ -0,0 +1,55 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/sagarc03/stowry"
+	"github.com/sagarc03/stowry/database/internal"
+)
+
+// DeleteHard removes the row and records the change in one transaction.
+func (r *repo) DeleteHard(ctx context.Context, path string) (_ stowry.MetaData, err error) {
+	defer func() { err = internal.ContextError(ctx, err) }()
+
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return stowry.MetaData{}, fmt.Errorf("delete hard: begin: %w", err)
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	query := fmt.Sprintf(`
+		DELETE FROM %s
+		WHERE path = $1
+		RETURNING id, path, content_type, etag, file_size_bytes, created_at, updated_at,
+			COALESCE(download_count, 0), last_accessed_at, deleted_at,
+			COALESCE(content_encoding, ''), decoded_size_bytes, COALESCE(filename, ''), user_metadata, COALESCE(cache_control, ''), COALESCE(storage_backend, ''), quarantined_at
+	`, r.tableName)
+
+	var m stowry.MetaData
+	err = tx.QueryRow(ctx, query, path).Scan(
+		&m.ID, &m.Path, &m.ContentType, &m.Etag, &m.FileSizeBytes, &m.CreatedAt, &m.UpdatedAt,
+		&m.DownloadCount, &m.LastAccessedAt, &m.DeletedAt, &m.ContentEncoding, &m.DecodedSizeBytes, &m.Filename, &m.UserMetadata, &m.CacheControl, &m.StorageBackend,
+		&m.QuarantinedAt,
+	)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return stowry.MetaData{}, fmt.Errorf("delete hard: %w", stowry.ErrNotFound)
+		}
+		return stowry.MetaData{}, fmt.Errorf("delete hard: %w", err)
+	}
+
+	// A soft-deleted object already had its delete recorded
+	if m.DeletedAt == nil {
+		if err := r.recordChanges(ctx, tx, stowry.ChangeDelete, []stowry.MetaData{m}, nil); err != nil {
+			return stowry.MetaData{}, fmt.Errorf("delete hard: %w", err)
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return stowry.MetaData{}, fmt.Errorf("delete hard: commit: %w", err)
+	}
+	return m, nil
+}
@@ -0,0 +1,74 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/sagarc03/stowry"
+	"github.com/sagarc03/stowry/database/internal"
+)
+
+func (r *repo) Rename(ctx context.Context, oldPath, newPath, backend string) (_ stowry.MetaData, err error) {
+	defer func() { err = internal.ContextError(ctx, err) }()
+
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return stowry.MetaData{}, fmt.Errorf("rename: begin: %w", err)
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	// A soft-deleted row would hold newPath against the unique constraint
+	if _, err := tx.Exec(ctx, fmt.Sprintf(`
+		DELETE FROM %s WHERE path = $1 AND deleted_at IS NOT NULL
+	`, r.tableName), newPath); err != nil {
+		return stowry.MetaData{}, fmt.Errorf("rename: drop deleted entry: %w", err)
+	}
+
+	query := fmt.Sprintf(`
+		UPDATE %s
+		SET path = $1, updated_at = NOW(), storage_backend = $3
+		WHERE path = $2 AND deleted_at IS NULL
+		RETURNING id, path, content_type, etag, file_size_bytes, created_at, updated_at,
+			COALESCE(download_count, 0), last_accessed_at,
+			COALESCE(content_encoding, ''), decoded_size_bytes, COALESCE(filename, ''), user_metadata, COALESCE(cache_control, ''), COALESCE(storage_backend, '')
+	`, r.tableName)
+
+	var m stowry.MetaData
+	err = tx.QueryRow(ctx, query, newPath, oldPath, backend).Scan(
+		&m.ID, &m.Path, &m.ContentType, &m.Etag, &m.FileSizeBytes, &m.CreatedAt, &m.UpdatedAt,
+		&m.DownloadCount, &m.LastAccessedAt, &m.ContentEncoding, &m.DecodedSizeBytes, &m.Filename, &m.UserMetadata, &m.CacheControl, &m.StorageBackend,
+	)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return stowry.MetaData{}, fmt.Errorf("rename: %w", stowry.ErrNotFound)
+		}
+		return stowry.MetaData{}, fmt.Errorf("rename: %w", classifyRenameError(err))
+	}
+
+	old := m
+	old.Path = oldPath
+	if err := r.recordChanges(ctx, tx, stowry.ChangeDelete, []stowry.MetaData{old}, nil); err != nil {
+		return stowry.MetaData{}, fmt.Errorf("rename: %w", err)
+	}
+	if err := r.recordChanges(ctx, tx, stowry.ChangeCreate, []stowry.MetaData{m}, nil); err != nil {
+		return stowry.MetaData{}, fmt.Errorf("rename: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return stowry.MetaData{}, fmt.Errorf("rename: commit: %w", err)
+	}
+	return m, nil
+}
+
+// classifyRenameError marks the unique-constraint violation of a rename onto
+// the path of an active object as stowry.ErrAlreadyExists.
+func classifyRenameError(err error) error {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == uniqueViolation {
+		return fmt.Errorf("%w: %w", stowry.ErrAlreadyExists, err)
+	}
+	return err
+}
@@ -5,29 +5,48 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"strings"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/sagarc03/stowry"
 	"github.com/sagarc03/stowry/database/internal"
 )
 
 type repo struct {
-	pool      *pgxpool.Pool
-	tableName string
+	pool               *pgxpool.Pool
+	tableName          string
+	stagesTable        string
+	stagedObjectsTable string
+	changesTable       string
+	settingsTable      string
+	usageTable         string
+	usagePrefixesTable string
+	accessKeysTable    string
+	writeIntentsTable  string
+	uploadsTable       string
+	uploadPartsTable   string
+	idScheme           stowry.IDScheme
 }
 
-func (r *repo) Get(ctx context.Context, path string) (stowry.MetaData, error) {
+func (r *repo) Get(ctx context.Context, path string) (_ stowry.MetaData, err error) {
+	defer func() { err = internal.ContextError(ctx, err) }()
+
 	query := fmt.Sprintf(`
-		SELECT id, path, content_type, etag, file_size_bytes, created_at, updated_at
+		SELECT id, path, content_type, etag, file_size_bytes, created_at, updated_at,
+			COALESCE(download_count, 0), last_accessed_at,
+			COALESCE(content_encoding, ''), decoded_size_bytes, COALESCE(filename, ''), user_metadata, COALESCE(cache_control, ''), COALESCE(storage_backend, '')
 		FROM %s
 		WHERE path = $1 AND deleted_at IS NULL
 	`, r.tableName)
 
 	var m stowry.MetaData
-	err := r.pool.QueryRow(ctx, query, path).Scan(
+	err = r.pool.QueryRow(ctx, query, path).Scan(
 		&m.ID, &m.Path, &m.ContentType, &m.Etag, &m.FileSizeBytes, &m.CreatedAt, &m.UpdatedAt,
+		&m.DownloadCount, &m.LastAccessedAt, &m.ContentEncoding, &m.DecodedSizeBytes, &m.Filename, &m.UserMetadata, &m.CacheControl, &m.StorageBackend,
 	)
 	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
@@ -39,90 +58,486 @@ func (r *repo) Get(ctx context.Context, path string) (stowry.MetaData, error) {
 	return m, nil
 }
 
-func (r *repo) Upsert(ctx context.Context, entry stowry.ObjectEntry) (stowry.MetaData, bool, error) {
+func (r *repo) GetDeleted(ctx context.Context, path string) (_ stowry.MetaData, err error) {
+	defer func() { err = internal.ContextError(ctx, err) }()
+
 	query := fmt.Sprintf(`
-		INSERT INTO %s (path, content_type, etag, file_size_bytes)
-		VALUES ($1, $2, $3, $4)
+		SELECT id, path, content_type, etag, file_size_bytes, created_at, updated_at,
+			COALESCE(download_count, 0), last_accessed_at, deleted_at, cleaned_up_at IS NOT NULL,
+			COALESCE(content_encoding, ''), decoded_size_bytes, COALESCE(filename, ''), user_metadata, COALESCE(cache_control, ''), COALESCE(storage_backend, ''), quarantined_at
+		FROM %s
+		WHERE path = $1 AND deleted_at IS NOT NULL
+	`, r.tableName)
+
+	var m stowry.MetaData
+	var cleanedUp bool
+	err = r.pool.QueryRow(ctx, query, path).Scan(
+		&m.ID, &m.Path, &m.ContentType, &m.Etag, &m.FileSizeBytes, &m.CreatedAt, &m.UpdatedAt,
+		&m.DownloadCount, &m.LastAccessedAt, &m.DeletedAt, &cleanedUp, &m.ContentEncoding, &m.DecodedSizeBytes, &m.Filename, &m.UserMetadata, &m.CacheControl, &m.StorageBackend,
+		&m.QuarantinedAt,
+	)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return stowry.MetaData{}, stowry.ErrNotFound
+		}
+		return stowry.MetaData{}, fmt.Errorf("get deleted: %w", err)
+	}
+	if cleanedUp {
+		return stowry.MetaData{}, fmt.Errorf("get deleted: %w", stowry.ErrGone)
+	}
+
+	return m, nil
+}
+
+// Upsert records the change in the same transaction as the metadata.
+func (r *repo) GetByETag(ctx context.Context, etag string) (_ stowry.MetaData, err error) {
+	defer func() { err = internal.ContextError(ctx, err) }()
+
+	query := fmt.Sprintf(`
+		SELECT id, path, content_type, etag, file_size_bytes, created_at, updated_at,
+			COALESCE(download_count, 0), last_accessed_at,
+			COALESCE(content_encoding, ''), decoded_size_bytes, COALESCE(filename, ''), user_metadata, COALESCE(cache_control, ''), COALESCE(storage_backend, '')
+		FROM %s
+		WHERE etag = $1 AND deleted_at IS NULL
+		ORDER BY updated_at DESC, path
+		LIMIT 1
+	`, r.tableName)
+
+	var m stowry.MetaData
+	err = r.pool.QueryRow(ctx, query, etag).Scan(
+		&m.ID, &m.Path, &m.ContentType, &m.Etag, &m.FileSizeBytes, &m.CreatedAt, &m.UpdatedAt,
+		&m.DownloadCount, &m.LastAccessedAt, &m.ContentEncoding, &m.DecodedSizeBytes, &m.Filename, &m.UserMetadata, &m.CacheControl, &m.StorageBackend,
+	)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return stowry.MetaData{}, stowry.ErrNotFound
+		}
+		return stowry.MetaData{}, fmt.Errorf("get by etag: %w", err)
+	}
+
+	return m, nil
+}
+
+func (r *repo) GetMany(ctx context.Context, paths []string) (_ []stowry.MetaData, err error) {
+	defer func() { err = internal.ContextError(ctx, err) }()
+
+	if len(paths) == 0 {
+		return nil, nil
+	}
+
+	query := fmt.Sprintf(`
+		SELECT id, path, content_type, etag, file_size_bytes, created_at, updated_at,
+			COALESCE(download_count, 0), last_accessed_at,
+			COALESCE(content_encoding, ''), decoded_size_bytes, COALESCE(filename, ''), user_metadata, COALESCE(cache_control, ''), COALESCE(storage_backend, '')
+		FROM %s
+		WHERE path = ANY($1::text[]) AND deleted_at IS NULL
+	`, r.tableName)
+
+	rows, err := r.pool.Query(ctx, query, paths)
+	if err != nil {
+		return nil, fmt.Errorf("get many: %w", err)
+	}
+	defer rows.Close()
+
+	var items []stowry.MetaData
+	for rows.Next() {
+		var m stowry.MetaData
+		if err := rows.Scan(&m.ID, &m.Path, &m.ContentType, &m.Etag, &m.FileSizeBytes, &m.CreatedAt, &m.UpdatedAt,
+			&m.DownloadCount, &m.LastAccessedAt, &m.ContentEncoding, &m.DecodedSizeBytes, &m.Filename, &m.UserMetadata, &m.CacheControl, &m.StorageBackend); err != nil {
+			return nil, fmt.Errorf("get many: scan: %w", err)
+		}
+		items = append(items, m)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("get many: rows: %w", err)
+	}
+
+	return items, nil
+}
+
+// Upsert returns the replaced row from a writable CTE. The previous CTE locks
+// the active row before the insert runs, and both see the same snapshot, so
+// the row read is the row overwritten.
+func (r *repo) Upsert(ctx context.Context, entry stowry.ObjectEntry) (_ stowry.MetaData, _ *stowry.MetaData, err error) {
+	defer func() { err = internal.ContextError(ctx, err) }()
+
+	id, supplied := entry.ID, entry.ID != uuid.Nil
+	if !supplied {
+		if id, err = r.idScheme.NewID(); err != nil {
+			return stowry.MetaData{}, nil, fmt.Errorf("upsert: generate id: %w", err)
+		}
+	}
+
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return stowry.MetaData{}, nil, fmt.Errorf("upsert: begin: %w", err)
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	// An existing row keeps its ID, unless $9 marks the ID as client-supplied:
+	// then a soft-deleted row takes it and a live row with another ID is left
+	// alone, so no row is returned
+	query := fmt.Sprintf(`
+		WITH previous AS (
+			SELECT id, content_type, etag, file_size_bytes, created_at, updated_at,
+				COALESCE(download_count, 0) AS download_count, last_accessed_at,
+				COALESCE(content_encoding, '') AS content_encoding, decoded_size_bytes,
+				COALESCE(filename, '') AS filename, COALESCE(storage_backend, '') AS storage_backend
+			FROM %s
+			WHERE path = $1 AND deleted_at IS NULL
+			FOR UPDATE
+		), upserted AS (
+			INSERT INTO %s AS t (id, path, content_type, etag, file_size_bytes, content_encoding, decoded_size_bytes, filename, storage_backend,
+				user_metadata, cache_control)
+			VALUES ($8, $1, $2, $3, $4, NULLIF($5, ''), $6, NULLIF($7, ''), NULLIF($10, ''), NULLIF($11, '')::jsonb, NULLIF($12, ''))
+			ON CONFLICT (path) DO UPDATE
+			SET id = CASE WHEN $9::boolean AND t.deleted_at IS NOT NULL THEN EXCLUDED.id ELSE t.id END,
+				content_type = EXCLUDED.content_type,
+				etag = EXCLUDED.etag,
+				file_size_bytes = EXCLUDED.file_size_bytes,
+				content_encoding = EXCLUDED.content_encoding,
+				decoded_size_bytes = EXCLUDED.decoded_size_bytes,
+				filename = EXCLUDED.filename,
+				user_metadata = EXCLUDED.user_metadata,
+				cache_control = EXCLUDED.cache_control,
+				storage_backend = EXCLUDED.storage_backend,
+				updated_at = NOW(),
+				deleted_at = NULL,
+				cleaned_up_at = NULL,
+				quarantined_at = NULL,
+				cleanup_retry_at = NULL
+			WHERE NOT $9::boolean OR t.deleted_at IS NOT NULL OR t.id = EXCLUDED.id
+			RETURNING id, path, content_type, etag, file_size_bytes, created_at, updated_at,
+				COALESCE(content_encoding, '') AS content_encoding, decoded_size_bytes, COALESCE(filename, '') AS filename,
+				user_metadata, COALESCE(cache_control, '') AS cache_control, COALESCE(storage_backend, '') AS storage_backend
+		)
+		SELECT u.id, u.path, u.content_type, u.etag, u.file_size_bytes, u.created_at, u.updated_at,
+			u.content_encoding, u.decoded_size_bytes, u.filename, u.user_metadata, u.cache_control, u.storage_backend,
+			p.id, p.content_type, p.etag, p.file_size_bytes, p.created_at, p.updated_at,
+			p.download_count, p.last_accessed_at, p.content_encoding, p.decoded_size_bytes, p.filename,
+			p.storage_backend
+		FROM upserted u LEFT JOIN previous p ON true
+	`, r.tableName, r.tableName)
+
+	var m stowry.MetaData
+	var prev struct {
+		id              *uuid.UUID
+		contentType     *string
+		etag            *string
+		size            *int64
+		createdAt       *time.Time
+		updatedAt       *time.Time
+		downloadCount   *int64
+		lastAccessedAt  *time.Time
+		contentEncoding *string
+		decodedSize     *int64
+		filename        *string
+		storageBackend  *string
+	}
+
+	err = tx.QueryRow(ctx, query, entry.Path, entry.ContentType, entry.ETag, entry.Size,
+		entry.ContentEncoding, entry.DecodedSize, entry.Filename, id, supplied, entry.StorageBackend,
+		internal.EncodeUserMetadata(entry.UserMetadata), entry.CacheControl).Scan(
+		&m.ID, &m.Path, &m.ContentType, &m.Etag, &m.FileSizeBytes, &m.CreatedAt, &m.UpdatedAt,
+		&m.ContentEncoding, &m.DecodedSizeBytes, &m.Filename, &m.UserMetadata, &m.CacheControl, &m.StorageBackend,
+		&prev.id, &prev.contentType, &prev.etag, &prev.size, &prev.createdAt, &prev.updatedAt,
+		&prev.downloadCount, &prev.lastAccessedAt, &prev.contentEncoding, &prev.decodedSize, &prev.filename,
+		&prev.storageBackend,
+	)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return stowry.MetaData{}, nil, fmt.Errorf("upsert: %s has another id: %w", entry.Path, stowry.ErrIDConflict)
+	}
+	if err != nil {
+		return stowry.MetaData{}, nil, fmt.Errorf("upsert: %w", classifyUpsertError(err))
+	}
+
+	var previous *stowry.MetaData
+	previousETags := []string{""}
+	if prev.id != nil {
+		previous = &stowry.MetaData{
+			ID:               *prev.id,
+			Path:             m.Path,
+			ContentType:      *prev.contentType,
+			Etag:             *prev.etag,
+			FileSizeBytes:    *prev.size,
+			ContentEncoding:  *prev.contentEncoding,
+			DecodedSizeBytes: prev.decodedSize,
+			Filename:         *prev.filename,
+			StorageBackend:   *prev.storageBackend,
+			CreatedAt:        *prev.createdAt,
+			UpdatedAt:        *prev.updatedAt,
+			DownloadCount:    *prev.downloadCount,
+			LastAccessedAt:   prev.lastAccessedAt,
+		}
+		previousETags[0] = previous.Etag
+	}
+
+	if err := r.recordChanges(ctx, tx, stowry.ChangeCreate, []stowry.MetaData{m}, previousETags); err != nil {
+		return stowry.MetaData{}, nil, fmt.Errorf("upsert: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return stowry.MetaData{}, nil, fmt.Errorf("upsert: commit: %w", classifyWriteError(err))
+	}
+	return m, previous, nil
+}
+
+// UpsertBatch writes each chunk of at most internal.MaxUpsertBatchSize entries
+// with a single multi-row INSERT ... ON CONFLICT statement.
+func (r *repo) UpsertBatch(ctx context.Context, entries []stowry.ObjectEntry) (_ []stowry.MetaData, err error) {
+	defer func() { err = internal.ContextError(ctx, err) }()
+
+	results := make([]stowry.MetaData, 0, len(entries))
+
+	for _, chunk := range internal.ChunkEntries(entries, internal.MaxUpsertBatchSize) {
+		written, err := r.upsertChunk(ctx, chunk)
+		if err != nil {
+			return nil, fmt.Errorf("upsert batch: %w", err)
+		}
+		results = append(results, written...)
+	}
+
+	return results, nil
+}
+
+// upsertChunk upserts entries whose paths are unique within the chunk, which
+// ON CONFLICT DO UPDATE requires, and records their changes in the same
+// transaction.
+func (r *repo) upsertChunk(ctx context.Context, chunk []stowry.ObjectEntry) ([]stowry.MetaData, error) {
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("begin: %w", err)
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	ids := make([]uuid.UUID, len(chunk))
+	paths := make([]string, len(chunk))
+	contentTypes := make([]string, len(chunk))
+	etags := make([]string, len(chunk))
+	sizes := make([]int64, len(chunk))
+	contentEncodings := make([]string, len(chunk))
+	decodedSizes := make([]*int64, len(chunk))
+	filenames := make([]string, len(chunk))
+	backends := make([]string, len(chunk))
+	userMetadata := make([]string, len(chunk))
+	cacheControls := make([]string, len(chunk))
+	for i, entry := range chunk {
+		id, err := r.idScheme.NewID()
+		if err != nil {
+			return nil, fmt.Errorf("%s: generate id: %w", entry.Path, err)
+		}
+		ids[i] = id
+		paths[i] = entry.Path
+		contentTypes[i] = entry.ContentType
+		etags[i] = entry.ETag
+		sizes[i] = entry.Size
+		contentEncodings[i] = entry.ContentEncoding
+		decodedSizes[i] = entry.DecodedSize
+		filenames[i] = entry.Filename
+		backends[i] = entry.StorageBackend
+		userMetadata[i] = internal.EncodeUserMetadata(entry.UserMetadata)
+		cacheControls[i] = entry.CacheControl
+	}
+
+	query := fmt.Sprintf(`
+		INSERT INTO %s (id, path, content_type, etag, file_size_bytes, content_encoding, decoded_size_bytes, filename,
+			storage_backend, user_metadata, cache_control)
+		SELECT id, path, content_type, etag, file_size_bytes, NULLIF(content_encoding, ''), decoded_size_bytes,
+			NULLIF(filename, ''), NULLIF(storage_backend, ''), NULLIF(user_metadata, '')::jsonb, NULLIF(cache_control, '')
+		FROM unnest($1::text[], $2::text[], $3::text[], $4::bigint[], $5::text[], $6::bigint[], $7::text[], $8::uuid[],
+			$9::text[], $10::text[], $11::text[])
+			AS t(path, content_type, etag, file_size_bytes, content_encoding, decoded_size_bytes, filename, id,
+				storage_backend, user_metadata, cache_control)
 		ON CONFLICT (path) DO UPDATE
 		SET content_type = EXCLUDED.content_type,
 			etag = EXCLUDED.etag,
 			file_size_bytes = EXCLUDED.file_size_bytes,
+			content_encoding = EXCLUDED.content_encoding,
+			decoded_size_bytes = EXCLUDED.decoded_size_bytes,
+			filename = EXCLUDED.filename,
+			user_metadata = EXCLUDED.user_metadata,
+			cache_control = EXCLUDED.cache_control,
+			storage_backend = EXCLUDED.storage_backend,
 			updated_at = NOW(),
 			deleted_at = NULL,
-			cleaned_up_at = NULL
+			cleaned_up_at = NULL,
+			quarantined_at = NULL,
+			cleanup_retry_at = NULL
 		RETURNING id, path, content_type, etag, file_size_bytes, created_at, updated_at,
-			(xmax = 0) AS inserted
+			COALESCE(content_encoding, ''), decoded_size_bytes, COALESCE(filename, ''), user_metadata, COALESCE(cache_control, ''), COALESCE(storage_backend, '')
 	`, r.tableName)
 
-	var m stowry.MetaData
-	var inserted bool
-
-	err := r.pool.QueryRow(ctx, query, entry.Path, entry.ContentType, entry.ETag, entry.Size).Scan(
-		&m.ID, &m.Path, &m.ContentType, &m.Etag, &m.FileSizeBytes, &m.CreatedAt, &m.UpdatedAt, &inserted,
-	)
+	rows, err := tx.Query(ctx, query, paths, contentTypes, etags, sizes, contentEncodings, decodedSizes, filenames, ids,
+		backends, userMetadata, cacheControls)
 	if err != nil {
-		return stowry.MetaData{}, false, fmt.Errorf("upsert: %w", err)
+		return nil, classifyWriteError(err)
+	}
+
+	// RETURNING order is not guaranteed, so match rows back to their input by path
+	byPath := make(map[string]stowry.MetaData, len(chunk))
+	for rows.Next() {
+		var m stowry.MetaData
+		if err := rows.Scan(&m.ID, &m.Path, &m.ContentType, &m.Etag, &m.FileSizeBytes, &m.CreatedAt, &m.UpdatedAt,
+			&m.ContentEncoding, &m.DecodedSizeBytes, &m.Filename, &m.UserMetadata, &m.CacheControl, &m.StorageBackend); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("scan: %w", err)
+		}
+		byPath[m.Path] = m
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, classifyWriteError(err)
+	}
+
+	results := make([]stowry.MetaData, len(chunk))
+	for i, entry := range chunk {
+		m, ok := byPath[entry.Path]
+		if !ok {
+			return nil, fmt.Errorf("%s: no row returned", entry.Path)
+		}
+		results[i] = m
+	}
+
+	if err := r.recordChanges(ctx, tx, stowry.ChangeCreate, results, nil); err != nil {
+		return nil, err
 	}
 
-	return m, inserted, nil
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("commit: %w", classifyWriteError(err))
+	}
+	return results, nil
 }
 
-func (r *repo) Delete(ctx context.Context, path string) error {
+func (r *repo) Delete(ctx context.Context, path string) (err error) {
+	defer func() { err = internal.ContextError(ctx, err) }()
+
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("delete: begin: %w", err)
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
 	query := fmt.Sprintf(`
 		UPDATE %s
 		SET deleted_at = NOW()
 		WHERE path = $1 AND deleted_at IS NULL
+		RETURNING path, etag, file_size_bytes
 	`, r.tableName)
 
-	result, err := r.pool.Exec(ctx, query, path)
+	var m stowry.MetaData
+	err = tx.QueryRow(ctx, query, path).Scan(&m.Path, &m.Etag, &m.FileSizeBytes)
 	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return fmt.Errorf("delete: %w", stowry.ErrNotFound)
+		}
 		return fmt.Errorf("delete: %w", err)
 	}
 
-	if result.RowsAffected() == 0 {
-		return fmt.Errorf("delete: %w", stowry.ErrNotFound)
+	if err := r.recordChanges(ctx, tx, stowry.ChangeDelete, []stowry.MetaData{m}, nil); err != nil {
+		return fmt.Errorf("delete: %w", err)
 	}
 
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("delete: commit: %w", err)
+	}
 	return nil
 }
 
-func (r *repo) List(ctx context.Context, q stowry.ListQuery) (stowry.ListResult, error) {
-	return r.listWithCondition(ctx, q, "deleted_at IS NULL", "list")
+// Quarantine soft-deletes like Delete, and flags the row so cleanup keeps
+// its file.
+func (r *repo) Quarantine(ctx context.Context, path, etag string) (err error) {
+	defer func() { err = internal.ContextError(ctx, err) }()
+
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("quarantine: begin: %w", err)
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	query := fmt.Sprintf(`
+		UPDATE %s
+		SET deleted_at = NOW(), quarantined_at = NOW()
+		WHERE path = $1 AND etag = $2 AND deleted_at IS NULL
+		RETURNING path, etag, file_size_bytes
+	`, r.tableName)
+
+	var m stowry.MetaData
+	err = tx.QueryRow(ctx, query, path, etag).Scan(&m.Path, &m.Etag, &m.FileSizeBytes)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return fmt.Errorf("quarantine: %w", stowry.ErrNotFound)
+		}
+		return fmt.Errorf("quarantine: %w", err)
+	}
+
+	if err := r.recordChanges(ctx, tx, stowry.ChangeDelete, []stowry.MetaData{m}, nil); err != nil {
+		return fmt.Errorf("quarantine: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("quarantine: commit: %w", err)
+	}
+	return nil
+}
+
+func (r *repo) List(ctx context.Context, q stowry.ListQuery) (_ stowry.ListResult, err error) {
+	defer func() { err = internal.ContextError(ctx, err) }()
+
+	return r.listWithCondition(ctx, q, "deleted_at IS NULL", nil, "list")
 }
 
-func (r *repo) ListPendingCleanup(ctx context.Context, q stowry.ListQuery) (stowry.ListResult, error) {
-	return r.listWithCondition(ctx, q, "deleted_at IS NOT NULL AND cleaned_up_at IS NULL", "list pending cleanup")
+func (r *repo) ListPendingCleanup(ctx context.Context, q stowry.ListQuery) (_ stowry.ListResult, err error) {
+	defer func() { err = internal.ContextError(ctx, err) }()
+
+	condition := "deleted_at IS NOT NULL AND cleaned_up_at IS NULL AND quarantined_at IS NULL"
+	var conditionArgs []any
+	if !q.DeletedBefore.IsZero() {
+		condition += " AND deleted_at < $1"
+		conditionArgs = append(conditionArgs, q.DeletedBefore)
+	}
+	return r.listWithCondition(ctx, q, condition, conditionArgs, "list pending cleanup")
 }
 
-func (r *repo) listWithCondition(ctx context.Context, q stowry.ListQuery, whereCondition, opName string) (stowry.ListResult, error) {
+// listWithCondition lists the rows matching whereCondition, a page of q at a
+// time. The placeholders of whereCondition are $1 onwards, bound to
+// conditionArgs; the query's own placeholders follow them.
+func (r *repo) listWithCondition(ctx context.Context, q stowry.ListQuery, whereCondition string, conditionArgs []any, opName string) (stowry.ListResult, error) {
 	cursor, err := internal.DecodeCursor(q.Cursor)
 	if err != nil {
 		return stowry.ListResult{}, fmt.Errorf("%s: %w", opName, err)
 	}
 
-	escapedPrefix := internal.EscapeLikePattern(q.PathPrefix)
+	escapedPrefix := internal.EscapeLikePrefix(q.PathPrefix, internal.LikeEscape)
+	n := len(conditionArgs)
 
 	var query string
 	var args []any
 
 	if q.Cursor == "" {
 		query = fmt.Sprintf(`
-			SELECT id, path, content_type, etag, file_size_bytes, created_at, updated_at
+			SELECT id, path, content_type, etag, file_size_bytes, created_at, updated_at,
+				COALESCE(download_count, 0), last_accessed_at,
+				COALESCE(content_encoding, ''), decoded_size_bytes, COALESCE(filename, ''), user_metadata, COALESCE(cache_control, ''), COALESCE(storage_backend, ''),
+				cleanup_retry_at, deleted_at
 			FROM %s
-			WHERE %s AND path LIKE $1 || '%%'
+			WHERE %s AND path LIKE $%d || '%%'
 			ORDER BY created_at, path
-			LIMIT $2
-		`, r.tableName, whereCondition)
-		args = []any{escapedPrefix, q.Limit + 1}
+			LIMIT $%d
+		`, r.tableName, whereCondition, n+1, n+2)
+		args = append(conditionArgs, escapedPrefix, q.Limit+1)
 	} else {
 		query = fmt.Sprintf(`
-			SELECT id, path, content_type, etag, file_size_bytes, created_at, updated_at
+			SELECT id, path, content_type, etag, file_size_bytes, created_at, updated_at,
+				COALESCE(download_count, 0), last_accessed_at,
+				COALESCE(content_encoding, ''), decoded_size_bytes, COALESCE(filename, ''), user_metadata, COALESCE(cache_control, ''), COALESCE(storage_backend, ''),
+				cleanup_retry_at, deleted_at
 			FROM %s
-			WHERE %s AND path LIKE $1 || '%%' AND (created_at, path) > ($2, $3)
+			WHERE %s AND path LIKE $%d || '%%' AND (created_at, path) > ($%d, $%d)
 			ORDER BY created_at, path
-			LIMIT $4
-		`, r.tableName, whereCondition)
-		args = []any{escapedPrefix, cursor.CreatedAt, cursor.Path, q.Limit + 1}
+			LIMIT $%d
+		`, r.tableName, whereCondition, n+1, n+2, n+3, n+4)
+		args = append(conditionArgs, escapedPrefix, cursor.CreatedAt, cursor.Path, q.Limit+1)
 	}
 
 	rows, err := r.pool.Query(ctx, query, args...)
@@ -134,7 +549,9 @@ func (r *repo) listWithCondition(ctx context.Context, q stowry.ListQuery, whereC
 	items := make([]stowry.MetaData, 0, q.Limit)
 	for rows.Next() {
 		var m stowry.MetaData
-		if err := rows.Scan(&m.ID, &m.Path, &m.ContentType, &m.Etag, &m.FileSizeBytes, &m.CreatedAt, &m.UpdatedAt); err != nil {
+		if err := rows.Scan(&m.ID, &m.Path, &m.ContentType, &m.Etag, &m.FileSizeBytes, &m.CreatedAt, &m.UpdatedAt,
+			&m.DownloadCount, &m.LastAccessedAt, &m.ContentEncoding, &m.DecodedSizeBytes, &m.Filename, &m.UserMetadata, &m.CacheControl, &m.StorageBackend,
+			&m.CleanupRetryAt, &m.DeletedAt); err != nil {
 			return stowry.ListResult{}, fmt.Errorf("%s: scan: %w", opName, err)
 		}
 		items = append(items, m)
@@ -155,7 +572,9 @@ func (r *repo) listWithCondition(ctx context.Context, q stowry.ListQuery, whereC
 	return stowry.ListResult{Items: items, NextCursor: nextCursor}, nil
 }
 
-func (r *repo) MarkCleanedUp(ctx context.Context, id uuid.UUID) error {
+func (r *repo) MarkCleanedUp(ctx context.Context, id uuid.UUID) (err error) {
+	defer func() { err = internal.ContextError(ctx, err) }()
+
 	query := fmt.Sprintf(`
 		UPDATE %s
 		SET cleaned_up_at = NOW()
@@ -173,3 +592,133 @@ func (r *repo) MarkCleanedUp(ctx context.Context, id uuid.UUID) error {
 
 	return nil
 }
+
+func (r *repo) DeferCleanup(ctx context.Context, id uuid.UUID, until time.Time) (err error) {
+	defer func() { err = internal.ContextError(ctx, err) }()
+
+	query := fmt.Sprintf(`
+		UPDATE %s
+		SET cleanup_retry_at = $2
+		WHERE id = $1 AND deleted_at IS NOT NULL AND cleaned_up_at IS NULL
+	`, r.tableName)
+
+	result, err := r.pool.Exec(ctx, query, id, until)
+	if err != nil {
+		return fmt.Errorf("defer cleanup: %w", err)
+	}
+
+	if result.RowsAffected() == 0 {
+		return fmt.Errorf("defer cleanup: %w", stowry.ErrNotFound)
+	}
+
+	return nil
+}
+
+func (r *repo) RecordAccess(ctx context.Context, records []stowry.AccessRecord) (err error) {
+	defer func() { err = internal.ContextError(ctx, err) }()
+
+	if len(records) == 0 {
+		return nil
+	}
+
+	paths := make([]string, len(records))
+	counts := make([]int64, len(records))
+	times := make([]time.Time, len(records))
+	for i, rec := range records {
+		paths[i] = rec.Path
+		counts[i] = rec.Count
+		times[i] = rec.LastAccessedAt
+	}
+
+	query := fmt.Sprintf(`
+		UPDATE %s AS t
+		SET download_count = COALESCE(t.download_count, 0) + v.count,
+			last_accessed_at = GREATEST(t.last_accessed_at, v.accessed_at)
+		FROM unnest($1::text[], $2::bigint[], $3::timestamptz[]) AS v(path, count, accessed_at)
+		WHERE t.path = v.path AND t.deleted_at IS NULL
+	`, r.tableName)
+
+	if _, err := r.pool.Exec(ctx, query, paths, counts, times); err != nil {
+		return fmt.Errorf("record access: %w", err)
+	}
+
+	return nil
+}
+
+func (r *repo) ExistingPaths(ctx context.Context, paths []string) (_ []string, err error) {
+	defer func() { err = internal.ContextError(ctx, err) }()
+
+	if len(paths) == 0 {
+		return nil, nil
+	}
+
+	query := fmt.Sprintf(`
+		SELECT path FROM %s
+		WHERE path = ANY($1::text[]) AND cleaned_up_at IS NULL
+	`, r.tableName)
+
+	rows, err := r.pool.Query(ctx, query, paths)
+	if err != nil {
+		return nil, fmt.Errorf("existing paths: %w", err)
+	}
+
+	found, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		return nil, fmt.Errorf("existing paths: %w", err)
+	}
+
+	return found, nil
+}
+
+func (r *repo) CountPrefix(ctx context.Context, prefix string) (_ int64, err error) {
+	defer func() { err = internal.ContextError(ctx, err) }()
+
+	query := fmt.Sprintf(`
+		SELECT COUNT(*) FROM %s
+		WHERE deleted_at IS NULL AND path LIKE $1 || '%%'
+	`, r.tableName)
+
+	var count int64
+	if err := r.pool.QueryRow(ctx, query, internal.EscapeLikePrefix(prefix, internal.LikeEscape)).Scan(&count); err != nil {
+		return 0, fmt.Errorf("count prefix: %w", err)
+	}
+
+	return count, nil
+}
+
+// uniqueViolation is the SQLSTATE for a unique constraint violation.
+const uniqueViolation = "23505"
+
+// classifyUpsertError is classifyWriteError for metadata upserts, where a
+// primary key violation means a client-supplied ID belongs to another path.
+func classifyUpsertError(err error) error {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == uniqueViolation && strings.HasSuffix(pgErr.ConstraintName, "_pkey") {
+		return fmt.Errorf("%w: %w", stowry.ErrIDConflict, err)
+	}
+	return classifyWriteError(err)
+}
+
+// nullUUID returns id as an argument, or NULL for uuid.Nil.
+func nullUUID(id uuid.UUID) *uuid.UUID {
+	if id == uuid.Nil {
+		return nil
+	}
+	return &id
+}
+
+// classifyWriteError marks unique-constraint violations, which happen when a
+// concurrent writer inserts the same row first, as stowry.ErrConflict.
+func classifyWriteError(err error) error {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == uniqueViolation {
+		return fmt.Errorf("%w: %w", stowry.ErrConflict, err)
+	}
+	return err
+}
+
+// Ping reports whether the database answers, for the service's check
+// before large uploads.
+func (r *repo) Ping(ctx context.Context) error {
+	return internal.ContextError(ctx, r.pool.Ping(ctx))
+}
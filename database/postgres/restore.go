@@ -0,0 +1,67 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/sagarc03/stowry"
+	"github.com/sagarc03/stowry/database/internal"
+)
+
+// Restore clears deleted_at and records the change in one transaction.
+func (r *repo) Restore(ctx context.Context, path string) (_ stowry.MetaData, err error) {
+	defer func() { err = internal.ContextError(ctx, err) }()
+
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return stowry.MetaData{}, fmt.Errorf("restore: begin: %w", err)
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	query := fmt.Sprintf(`
+		UPDATE %s
+		SET deleted_at = NULL, cleanup_retry_at = NULL
+		WHERE path = $1 AND deleted_at IS NOT NULL AND cleaned_up_at IS NULL AND quarantined_at IS NULL
+		RETURNING id, path, content_type, etag, file_size_bytes, created_at, updated_at,
+			COALESCE(download_count, 0), last_accessed_at,
+			COALESCE(content_encoding, ''), decoded_size_bytes, COALESCE(filename, ''), user_metadata, COALESCE(cache_control, ''), COALESCE(storage_backend, '')
+	`, r.tableName)
+
+	var m stowry.MetaData
+	err = tx.QueryRow(ctx, query, path).Scan(
+		&m.ID, &m.Path, &m.ContentType, &m.Etag, &m.FileSizeBytes, &m.CreatedAt, &m.UpdatedAt,
+		&m.DownloadCount, &m.LastAccessedAt, &m.ContentEncoding, &m.DecodedSizeBytes, &m.Filename, &m.UserMetadata, &m.CacheControl, &m.StorageBackend,
+	)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return stowry.MetaData{}, fmt.Errorf("restore: %w", r.restoreMiss(ctx, tx, path))
+	}
+	if err != nil {
+		return stowry.MetaData{}, fmt.Errorf("restore: %w", err)
+	}
+
+	if err := r.recordChanges(ctx, tx, stowry.ChangeCreate, []stowry.MetaData{m}, nil); err != nil {
+		return stowry.MetaData{}, fmt.Errorf("restore: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return stowry.MetaData{}, fmt.Errorf("restore: commit: %w", err)
+	}
+	return m, nil
+}
+
+// restoreMiss explains why path had no row to restore: ErrAlreadyExists when
+// the path holds an active object, ErrNotFound otherwise.
+func (r *repo) restoreMiss(ctx context.Context, tx pgx.Tx, path string) error {
+	query := fmt.Sprintf(`SELECT EXISTS (SELECT 1 FROM %s WHERE path = $1 AND deleted_at IS NULL)`, r.tableName)
+
+	var active bool
+	if err := tx.QueryRow(ctx, query, path).Scan(&active); err != nil {
+		return err
+	}
+	if active {
+		return stowry.ErrAlreadyExists
+	}
+	return stowry.ErrNotFound
+}
@@ -0,0 +1,217 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/sagarc03/stowry"
+	"github.com/sagarc03/stowry/database/internal"
+)
+
+func (r *repo) CreateStage(ctx context.Context) (_ stowry.Stage, err error) {
+	defer func() { err = internal.ContextError(ctx, err) }()
+
+	query := fmt.Sprintf(`
+		INSERT INTO %s (id) VALUES ($1)
+		RETURNING id, created_at
+	`, r.stagesTable)
+
+	var stage stowry.Stage
+	if err := r.pool.QueryRow(ctx, query, uuid.New()).Scan(&stage.ID, &stage.CreatedAt); err != nil {
+		return stowry.Stage{}, fmt.Errorf("create stage: %w", err)
+	}
+	return stage, nil
+}
+
+func (r *repo) AddStagedObject(ctx context.Context, id uuid.UUID, entry stowry.ObjectEntry) (err error) {
+	defer func() { err = internal.ContextError(ctx, err) }()
+
+	// The SELECT yields no row for an unknown stage, so nothing is inserted
+	query := fmt.Sprintf(`
+		INSERT INTO %s (stage_id, path, content_type, etag, file_size_bytes, content_encoding, decoded_size_bytes,
+			filename, storage_backend, user_metadata, cache_control)
+		SELECT id, $2, $3, $4, $5, NULLIF($6, ''), $7, NULLIF($8, ''), NULLIF($9, ''), NULLIF($10, '')::jsonb, NULLIF($11, '')
+		FROM %s WHERE id = $1
+		ON CONFLICT (stage_id, path) DO UPDATE
+		SET content_type = EXCLUDED.content_type,
+			etag = EXCLUDED.etag,
+			file_size_bytes = EXCLUDED.file_size_bytes,
+			content_encoding = EXCLUDED.content_encoding,
+			decoded_size_bytes = EXCLUDED.decoded_size_bytes,
+			filename = EXCLUDED.filename,
+			storage_backend = EXCLUDED.storage_backend,
+			user_metadata = EXCLUDED.user_metadata,
+			cache_control = EXCLUDED.cache_control,
+			created_at = NOW()
+	`, r.stagedObjectsTable, r.stagesTable)
+
+	result, err := r.pool.Exec(ctx, query, id, entry.Path, entry.ContentType, entry.ETag, entry.Size,
+		entry.ContentEncoding, entry.DecodedSize, entry.Filename, entry.StorageBackend,
+		internal.EncodeUserMetadata(entry.UserMetadata), entry.CacheControl)
+	if err != nil {
+		return fmt.Errorf("add staged object: %w", err)
+	}
+	if result.RowsAffected() == 0 {
+		return fmt.Errorf("add staged object: stage %s: %w", id, stowry.ErrNotFound)
+	}
+	return nil
+}
+
+func (r *repo) StagedObjects(ctx context.Context, id uuid.UUID) (_ []stowry.ObjectEntry, err error) {
+	defer func() { err = internal.ContextError(ctx, err) }()
+
+	entries, err := r.stagedObjects(ctx, r.pool, id)
+	if err != nil {
+		return nil, fmt.Errorf("staged objects: %w", err)
+	}
+	return entries, nil
+}
+
+// querier is the part of *pgxpool.Pool and pgx.Tx that stage queries use.
+type querier interface {
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+}
+
+// stagedObjects returns the entries of stage id, or ErrNotFound when the
+// stage does not exist.
+func (r *repo) stagedObjects(ctx context.Context, q querier, id uuid.UUID) ([]stowry.ObjectEntry, error) {
+	var found uuid.UUID
+	err := q.QueryRow(ctx, fmt.Sprintf(`SELECT id FROM %s WHERE id = $1`, r.stagesTable), id).Scan(&found)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, fmt.Errorf("stage %s: %w", id, stowry.ErrNotFound)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := q.Query(ctx, fmt.Sprintf(`
+		SELECT path, content_type, etag, file_size_bytes,
+			COALESCE(content_encoding, ''), decoded_size_bytes, COALESCE(filename, ''),
+			COALESCE(storage_backend, ''), user_metadata, COALESCE(cache_control, '')
+		FROM %s
+		WHERE stage_id = $1
+		ORDER BY path
+	`, r.stagedObjectsTable), id)
+	if err != nil {
+		return nil, err
+	}
+
+	entries, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (stowry.ObjectEntry, error) {
+		var e stowry.ObjectEntry
+		err := row.Scan(&e.Path, &e.ContentType, &e.ETag, &e.Size, &e.ContentEncoding, &e.DecodedSize, &e.Filename,
+			&e.StorageBackend, &e.UserMetadata, &e.CacheControl)
+		return e, err
+	})
+	if err != nil {
+		return nil, err
+	}
+	if entries == nil {
+		entries = []stowry.ObjectEntry{}
+	}
+	return entries, nil
+}
+
+func (r *repo) CommitStage(ctx context.Context, id uuid.UUID) (_ []stowry.MetaData, err error) {
+	defer func() { err = internal.ContextError(ctx, err) }()
+
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("commit stage: begin: %w", err)
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	entries, err := r.stagedObjects(ctx, tx, id)
+	if err != nil {
+		return nil, fmt.Errorf("commit stage: %w", err)
+	}
+
+	replaceQuery := fmt.Sprintf(`
+		UPDATE %s
+		SET path = $1, deleted_at = COALESCE(deleted_at, NOW())
+		WHERE path = $2
+	`, r.tableName)
+
+	insertQuery := fmt.Sprintf(`
+		INSERT INTO %s (id, path, content_type, etag, file_size_bytes, content_encoding, decoded_size_bytes, filename,
+			storage_backend, user_metadata, cache_control)
+		VALUES ($8, $1, $2, $3, $4, NULLIF($5, ''), $6, NULLIF($7, ''), NULLIF($9, ''), NULLIF($10, '')::jsonb, NULLIF($11, ''))
+		RETURNING id, path, content_type, etag, file_size_bytes, created_at, updated_at,
+			COALESCE(content_encoding, ''), decoded_size_bytes, COALESCE(filename, ''), user_metadata, COALESCE(cache_control, ''), COALESCE(storage_backend, '')
+	`, r.tableName)
+
+	committed := make([]stowry.MetaData, 0, len(entries))
+	for _, entry := range entries {
+		if _, err := tx.Exec(ctx, replaceQuery, stowry.ReplacedPath(id, entry.Path), entry.Path); err != nil {
+			return nil, fmt.Errorf("commit stage: replace %s: %w", entry.Path, err)
+		}
+
+		objectID, err := r.idScheme.NewID()
+		if err != nil {
+			return nil, fmt.Errorf("commit stage: %s: generate id: %w", entry.Path, err)
+		}
+
+		var m stowry.MetaData
+		err = tx.QueryRow(ctx, insertQuery, entry.Path, entry.ContentType, entry.ETag, entry.Size,
+			entry.ContentEncoding, entry.DecodedSize, entry.Filename, objectID, entry.StorageBackend,
+			internal.EncodeUserMetadata(entry.UserMetadata), entry.CacheControl).Scan(
+			&m.ID, &m.Path, &m.ContentType, &m.Etag, &m.FileSizeBytes, &m.CreatedAt, &m.UpdatedAt,
+			&m.ContentEncoding, &m.DecodedSizeBytes, &m.Filename, &m.UserMetadata, &m.CacheControl, &m.StorageBackend,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("commit stage: %s: %w", entry.Path, classifyWriteError(err))
+		}
+		committed = append(committed, m)
+	}
+
+	if err := r.recordChanges(ctx, tx, stowry.ChangeCreate, committed, nil); err != nil {
+		return nil, fmt.Errorf("commit stage: %w", err)
+	}
+
+	if err := r.deleteStage(ctx, tx, id); err != nil {
+		return nil, fmt.Errorf("commit stage: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("commit stage: commit: %w", err)
+	}
+	return committed, nil
+}
+
+func (r *repo) DeleteStage(ctx context.Context, id uuid.UUID) (err error) {
+	defer func() { err = internal.ContextError(ctx, err) }()
+
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("delete stage: begin: %w", err)
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	if err := r.deleteStage(ctx, tx, id); err != nil {
+		return fmt.Errorf("delete stage: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("delete stage: commit: %w", err)
+	}
+	return nil
+}
+
+// deleteStage removes stage id and its objects within tx.
+func (r *repo) deleteStage(ctx context.Context, tx pgx.Tx, id uuid.UUID) error {
+	if _, err := tx.Exec(ctx, fmt.Sprintf(`DELETE FROM %s WHERE stage_id = $1`, r.stagedObjectsTable), id); err != nil {
+		return fmt.Errorf("delete staged objects: %w", err)
+	}
+
+	result, err := tx.Exec(ctx, fmt.Sprintf(`DELETE FROM %s WHERE id = $1`, r.stagesTable), id)
+	if err != nil {
+		return fmt.Errorf("delete stage: %w", err)
+	}
+	if result.RowsAffected() == 0 {
+		return fmt.Errorf("stage %s: %w", id, stowry.ErrNotFound)
+	}
+	return nil
+}
@@ -0,0 +1,230 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/sagarc03/stowry"
+	"github.com/sagarc03/stowry/database/internal"
+)
+
+// usagePrefixSQL is the top-level prefix of path, as stowry.UsagePrefix.
+const usagePrefixSQL = `CASE WHEN strpos(path, '/') > 0 THEN left(path, strpos(path, '/')) ELSE '' END`
+
+func (r *repo) TakeUsageSnapshot(ctx context.Context) (_ stowry.UsageSnapshot, err error) {
+	defer func() { err = internal.ContextError(ctx, err) }()
+
+	// Repeatable read, so the change feed and the metadata are counted at
+	// the same moment. The lock comes first because the transaction's
+	// snapshot is taken by its first query, and LOCK is not one; it keeps
+	// concurrent snapshots from counting the same changes twice.
+	tx, err := r.pool.BeginTx(ctx, pgx.TxOptions{IsoLevel: pgx.RepeatableRead})
+	if err != nil {
+		return stowry.UsageSnapshot{}, fmt.Errorf("take usage snapshot: begin: %w", err)
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	if _, err := tx.Exec(ctx, fmt.Sprintf(`LOCK TABLE %s IN SHARE ROW EXCLUSIVE MODE`, r.usageTable)); err != nil {
+		return stowry.UsageSnapshot{}, fmt.Errorf("take usage snapshot: lock: %w", err)
+	}
+
+	previous, err := r.latestUsageSnapshot(ctx, tx, time.Now())
+	hasPrevious := err == nil
+	if err != nil && !errors.Is(err, stowry.ErrNotFound) {
+		return stowry.UsageSnapshot{}, fmt.Errorf("take usage snapshot: %w", err)
+	}
+
+	var minSeq, maxSeq int64
+	err = tx.QueryRow(ctx, fmt.Sprintf(
+		`SELECT COALESCE(MIN(seq), 0), COALESCE(MAX(seq), 0) FROM %s`, r.changesTable)).Scan(&minSeq, &maxSeq)
+	if err != nil {
+		return stowry.UsageSnapshot{}, fmt.Errorf("take usage snapshot: change window: %w", err)
+	}
+
+	snapshot := stowry.UsageSnapshot{TakenAt: time.Now().UTC(), Complete: true}
+	added := map[string]internal.UsageCount{}
+	if hasPrevious {
+		if _, err := internal.ChangeWindow(previous.seq, minSeq, maxSeq); err != nil {
+			snapshot.Complete = false
+		}
+		added, err = usageCounts(ctx, tx, fmt.Sprintf(`
+			SELECT %s, COUNT(*), COALESCE(SUM(file_size_bytes), 0)
+			FROM %s
+			WHERE kind = $1 AND seq > $2 AND seq <= $3
+			GROUP BY 1`, usagePrefixSQL, r.changesTable), string(stowry.ChangeCreate), previous.seq, maxSeq)
+		if err != nil {
+			return stowry.UsageSnapshot{}, fmt.Errorf("take usage snapshot: added: %w", err)
+		}
+	}
+
+	active, err := usageCounts(ctx, tx, fmt.Sprintf(`
+		SELECT %s, COUNT(*), COALESCE(SUM(file_size_bytes), 0)
+		FROM %s
+		WHERE deleted_at IS NULL
+		GROUP BY 1`, usagePrefixSQL, r.tableName))
+	if err != nil {
+		return stowry.UsageSnapshot{}, fmt.Errorf("take usage snapshot: active: %w", err)
+	}
+	snapshot.Prefixes = internal.MergeUsage(previous.Prefixes, added, active)
+
+	err = tx.QueryRow(ctx, fmt.Sprintf(
+		`INSERT INTO %s (taken_at, change_seq, complete) VALUES ($1, $2, $3) RETURNING id`, r.usageTable),
+		snapshot.TakenAt, maxSeq, snapshot.Complete).Scan(&snapshot.ID)
+	if err != nil {
+		return stowry.UsageSnapshot{}, fmt.Errorf("take usage snapshot: insert: %w", err)
+	}
+
+	prefixes := make([]string, len(snapshot.Prefixes))
+	objects := make([]int64, len(snapshot.Prefixes))
+	bytes := make([]int64, len(snapshot.Prefixes))
+	objectsAdded := make([]int64, len(snapshot.Prefixes))
+	bytesAdded := make([]int64, len(snapshot.Prefixes))
+	for i, p := range snapshot.Prefixes {
+		prefixes[i], objects[i], bytes[i], objectsAdded[i], bytesAdded[i] = p.Prefix, p.Objects, p.Bytes, p.ObjectsAdded, p.BytesAdded
+	}
+
+	_, err = tx.Exec(ctx, fmt.Sprintf(`
+		INSERT INTO %s (snapshot_id, prefix, objects, bytes, objects_added, bytes_added)
+		SELECT $1, prefix, objects, bytes, objects_added, bytes_added
+		FROM unnest($2::text[], $3::bigint[], $4::bigint[], $5::bigint[], $6::bigint[])
+			AS t(prefix, objects, bytes, objects_added, bytes_added)
+	`, r.usagePrefixesTable), snapshot.ID, prefixes, objects, bytes, objectsAdded, bytesAdded)
+	if err != nil {
+		return stowry.UsageSnapshot{}, fmt.Errorf("take usage snapshot: insert prefixes: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return stowry.UsageSnapshot{}, fmt.Errorf("take usage snapshot: commit: %w", err)
+	}
+	return snapshot, nil
+}
+
+func (r *repo) BackendUsage(ctx context.Context) (_ []stowry.BackendUsage, err error) {
+	defer func() { err = internal.ContextError(ctx, err) }()
+
+	counts, err := usageCounts(ctx, r.pool, fmt.Sprintf(`
+		SELECT COALESCE(storage_backend, ''), COUNT(*), COALESCE(SUM(file_size_bytes), 0)
+		FROM %s
+		WHERE deleted_at IS NULL
+		GROUP BY 1`, r.tableName))
+	if err != nil {
+		return nil, fmt.Errorf("backend usage: %w", err)
+	}
+	return internal.BackendUsage(counts), nil
+}
+
+func (r *repo) ListUsageSnapshots(ctx context.Context, from, to time.Time) (_ []stowry.UsageSnapshot, err error) {
+	defer func() { err = internal.ContextError(ctx, err) }()
+
+	query := fmt.Sprintf(`
+		SELECT id, taken_at, complete
+		FROM %s
+		WHERE taken_at >= $1 AND taken_at <= $2
+		ORDER BY id
+	`, r.usageTable)
+
+	rows, err := r.pool.Query(ctx, query, from, to)
+	if err != nil {
+		return nil, fmt.Errorf("list usage snapshots: %w", err)
+	}
+	defer rows.Close()
+
+	snapshots := []stowry.UsageSnapshot{}
+	for rows.Next() {
+		var s stowry.UsageSnapshot
+		if err := rows.Scan(&s.ID, &s.TakenAt, &s.Complete); err != nil {
+			return nil, fmt.Errorf("list usage snapshots: scan: %w", err)
+		}
+		s.TakenAt = s.TakenAt.UTC()
+		snapshots = append(snapshots, s)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("list usage snapshots: %w", err)
+	}
+	return snapshots, nil
+}
+
+func (r *repo) UsageSnapshotAt(ctx context.Context, at time.Time) (_ stowry.UsageSnapshot, err error) {
+	defer func() { err = internal.ContextError(ctx, err) }()
+
+	snapshot, err := r.latestUsageSnapshot(ctx, r.pool, at)
+	if err != nil {
+		return stowry.UsageSnapshot{}, fmt.Errorf("usage snapshot at: %w", err)
+	}
+	return snapshot.UsageSnapshot, nil
+}
+
+// storedUsageSnapshot is a usage snapshot with the change feed sequence it
+// counted creates up to.
+type storedUsageSnapshot struct {
+	stowry.UsageSnapshot
+	seq int64
+}
+
+// latestUsageSnapshot returns the latest snapshot taken at or before at,
+// with its prefixes, or ErrNotFound.
+func (r *repo) latestUsageSnapshot(ctx context.Context, q querier, at time.Time) (storedUsageSnapshot, error) {
+	var s storedUsageSnapshot
+	err := q.QueryRow(ctx, fmt.Sprintf(`
+		SELECT id, taken_at, complete, change_seq
+		FROM %s
+		WHERE taken_at <= $1
+		ORDER BY taken_at DESC, id DESC
+		LIMIT 1
+	`, r.usageTable), at).Scan(&s.ID, &s.TakenAt, &s.Complete, &s.seq)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return storedUsageSnapshot{}, stowry.ErrNotFound
+	}
+	if err != nil {
+		return storedUsageSnapshot{}, fmt.Errorf("latest snapshot: %w", err)
+	}
+	s.TakenAt = s.TakenAt.UTC()
+
+	rows, err := q.Query(ctx, fmt.Sprintf(`
+		SELECT prefix, objects, bytes, objects_added, bytes_added
+		FROM %s
+		WHERE snapshot_id = $1
+		ORDER BY prefix COLLATE "C"
+	`, r.usagePrefixesTable), s.ID)
+	if err != nil {
+		return storedUsageSnapshot{}, fmt.Errorf("snapshot prefixes: %w", err)
+	}
+	defer rows.Close()
+
+	s.Prefixes = []stowry.PrefixUsage{}
+	for rows.Next() {
+		var p stowry.PrefixUsage
+		if err := rows.Scan(&p.Prefix, &p.Objects, &p.Bytes, &p.ObjectsAdded, &p.BytesAdded); err != nil {
+			return storedUsageSnapshot{}, fmt.Errorf("snapshot prefixes: scan: %w", err)
+		}
+		s.Prefixes = append(s.Prefixes, p)
+	}
+	if err := rows.Err(); err != nil {
+		return storedUsageSnapshot{}, fmt.Errorf("snapshot prefixes: %w", err)
+	}
+	return s, nil
+}
+
+// usageCounts runs query, which selects a prefix, a count and a byte total
+// per row, and returns the counts by prefix.
+func usageCounts(ctx context.Context, q querier, query string, args ...any) (map[string]internal.UsageCount, error) {
+	rows, err := q.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	counts := make(map[string]internal.UsageCount)
+	for rows.Next() {
+		var prefix string
+		var c internal.UsageCount
+		if err := rows.Scan(&prefix, &c.Objects, &c.Bytes); err != nil {
+			return nil, fmt.Errorf("scan: %w", err)
+		}
+		counts[prefix] = c
+	}
+	return counts, rows.Err()
+}
@@ -2,32 +2,28 @@ package postgres
 
 import (
 	"context"
-	"errors"
 	"fmt"
 	"strings"
 
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/sagarc03/stowry"
+	"github.com/sagarc03/stowry/database/internal"
 )
 
-type columnInfo struct {
-	name       string
-	dataType   string
-	isNullable bool
-}
-
-func validateTableSchema(ctx context.Context, pool *pgxpool.Pool, tableName string, expectedSchema map[string]columnInfo) error {
+// compareTableSchema returns how the table differs from expectedSchema. A
+// table that does not exist is reported as missing, not as an error.
+func compareTableSchema(ctx context.Context, pool *pgxpool.Pool, tableName string, expectedSchema internal.TableSchema) (internal.TableDiff, error) {
 	if !stowry.IsValidTableName(tableName) {
-		return fmt.Errorf("validate table schema: invalid table name: %s", tableName)
+		return internal.TableDiff{}, fmt.Errorf("validate table schema: invalid table name: %s", tableName)
 	}
 
 	exists, err := tableExists(ctx, pool, tableName)
 	if err != nil {
-		return fmt.Errorf("validate table schema: %w", err)
+		return internal.TableDiff{}, fmt.Errorf("validate table schema: %w", err)
 	}
 
 	if !exists {
-		return fmt.Errorf("validate table schema: table %s does not exist", tableName)
+		return internal.MissingTable(tableName), nil
 	}
 
 	query := `
@@ -39,67 +35,32 @@ func validateTableSchema(ctx context.Context, pool *pgxpool.Pool, tableName stri
 
 	rows, err := pool.Query(ctx, query, tableName)
 	if err != nil {
-		return fmt.Errorf("validate table schema: query columns: %w", err)
+		return internal.TableDiff{}, fmt.Errorf("validate table schema: query columns: %w", err)
 	}
 	defer rows.Close()
 
-	actualColumns := make(map[string]columnInfo)
+	actualColumns := make(map[string]internal.Column)
 	for rows.Next() {
 		var name, dataType, nullable string
 		if err := rows.Scan(&name, &dataType, &nullable); err != nil {
-			return fmt.Errorf("validate table schema: scan column: %w", err)
+			return internal.TableDiff{}, fmt.Errorf("validate table schema: scan column: %w", err)
 		}
-		actualColumns[name] = columnInfo{
-			name:       name,
-			dataType:   strings.ToLower(dataType),
-			isNullable: nullable == "YES",
+		actualColumns[name] = internal.Column{
+			Type:     strings.ToLower(dataType),
+			Nullable: nullable == "YES",
 		}
 	}
 
 	if err := rows.Err(); err != nil {
-		return fmt.Errorf("validate table schema: rows error: %w", err)
+		return internal.TableDiff{}, fmt.Errorf("validate table schema: rows error: %w", err)
 	}
 
-	var missingColumns []string
-	var mismatchedColumns []string
-
-	for colName, expected := range expectedSchema {
-		actual, exists := actualColumns[colName]
-		if !exists {
-			missingColumns = append(missingColumns, colName)
-			continue
-		}
-
-		if actual.dataType != expected.dataType {
-			mismatchedColumns = append(mismatchedColumns,
-				fmt.Sprintf("%s: expected %s, got %s", colName, expected.dataType, actual.dataType))
-		}
-
-		if actual.isNullable != expected.isNullable {
-			mismatchedColumns = append(mismatchedColumns,
-				fmt.Sprintf("%s: expected nullable=%v, got nullable=%v", colName, expected.isNullable, actual.isNullable))
-		}
-	}
-
-	if len(missingColumns) > 0 || len(mismatchedColumns) > 0 {
-		var errMsg strings.Builder
-		fmt.Fprintf(&errMsg, "table %s schema validation failed:\n", tableName)
-
-		if len(missingColumns) > 0 {
-			fmt.Fprintf(&errMsg, "  missing columns: %s\n", strings.Join(missingColumns, ", "))
-		}
-
-		if len(mismatchedColumns) > 0 {
-			fmt.Fprintf(&errMsg, "  mismatched columns:\n")
-			for _, msg := range mismatchedColumns {
-				fmt.Fprintf(&errMsg, "    - %s\n", msg)
-			}
-		}
-
-		return errors.New(errMsg.String())
+	indexes, err := tableIndexes(ctx, pool, tableName)
+	if err != nil {
+		return internal.TableDiff{}, fmt.Errorf("validate table schema: %w", err)
 	}
 
-	return nil
+	return internal.CompareTable(tableName, expectedSchema, actualColumns, indexes), nil
 }
 
 func tableExists(ctx context.Context, pool *pgxpool.Pool, tableName string) (bool, error) {
@@ -119,30 +80,208 @@ func tableExists(ctx context.Context, pool *pgxpool.Pool, tableName string) (boo
 	return exists, nil
 }
 
+func tableIndexes(ctx context.Context, pool *pgxpool.Pool, tableName string) ([]string, error) {
+	query := `SELECT indexname FROM pg_indexes WHERE schemaname = 'public' AND tablename = $1`
+	rows, err := pool.Query(ctx, query, tableName)
+	if err != nil {
+		return nil, fmt.Errorf("query indexes: %w", err)
+	}
+	defer rows.Close()
+
+	var indexes []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, fmt.Errorf("scan index: %w", err)
+		}
+		indexes = append(indexes, name)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("query indexes: %w", err)
+	}
+	return indexes, nil
+}
+
 type tableValidation struct {
 	tableName      string
-	expectedSchema map[string]columnInfo
+	expectedSchema internal.TableSchema
 }
 
-var metaDataTableSchema = map[string]columnInfo{
-	"id":              {"id", "uuid", false},
-	"path":            {"path", "text", false},
-	"content_type":    {"content_type", "text", false},
-	"etag":            {"etag", "text", false},
-	"file_size_bytes": {"file_size_bytes", "bigint", false},
-	"created_at":      {"created_at", "timestamp with time zone", false},
-	"updated_at":      {"updated_at", "timestamp with time zone", false},
-	"deleted_at":      {"deleted_at", "timestamp with time zone", true},
-	"cleaned_up_at":   {"cleaned_up_at", "timestamp with time zone", true},
+var metaDataColumns = map[string]internal.Column{
+	"id":                 {Type: "uuid", Nullable: false},
+	"path":               {Type: "text", Nullable: false},
+	"content_type":       {Type: "text", Nullable: false},
+	"etag":               {Type: "text", Nullable: false},
+	"file_size_bytes":    {Type: "bigint", Nullable: false},
+	"created_at":         {Type: "timestamp with time zone", Nullable: false},
+	"updated_at":         {Type: "timestamp with time zone", Nullable: false},
+	"deleted_at":         {Type: "timestamp with time zone", Nullable: true},
+	"cleaned_up_at":      {Type: "timestamp with time zone", Nullable: true},
+	"download_count":     {Type: "bigint", Nullable: true},
+	"last_accessed_at":   {Type: "timestamp with time zone", Nullable: true},
+	"content_encoding":   {Type: "text", Nullable: true},
+	"decoded_size_bytes": {Type: "bigint", Nullable: true},
+	"quarantined_at":     {Type: "timestamp with time zone", Nullable: true},
+	"filename":           {Type: "text", Nullable: true},
+	"storage_backend":    {Type: "text", Nullable: true},
+	"cleanup_retry_at":   {Type: "timestamp with time zone", Nullable: true},
+	"user_metadata":      {Type: "jsonb", Nullable: true},
+	"cache_control":      {Type: "text", Nullable: true},
 }
 
-func getTableValidations(tables stowry.Tables) []tableValidation {
-	validations := []tableValidation{}
+var stagesColumns = map[string]internal.Column{
+	"id":         {Type: "uuid", Nullable: false},
+	"created_at": {Type: "timestamp with time zone", Nullable: false},
+}
+
+var stagedObjectsColumns = map[string]internal.Column{
+	"stage_id":           {Type: "uuid", Nullable: false},
+	"path":               {Type: "text", Nullable: false},
+	"content_type":       {Type: "text", Nullable: false},
+	"etag":               {Type: "text", Nullable: false},
+	"file_size_bytes":    {Type: "bigint", Nullable: false},
+	"created_at":         {Type: "timestamp with time zone", Nullable: false},
+	"content_encoding":   {Type: "text", Nullable: true},
+	"decoded_size_bytes": {Type: "bigint", Nullable: true},
+	"filename":           {Type: "text", Nullable: true},
+	"storage_backend":    {Type: "text", Nullable: true},
+	"user_metadata":      {Type: "jsonb", Nullable: true},
+	"cache_control":      {Type: "text", Nullable: true},
+}
+
+var changesColumns = map[string]internal.Column{
+	"seq":             {Type: "bigint", Nullable: false},
+	"kind":            {Type: "text", Nullable: false},
+	"path":            {Type: "text", Nullable: false},
+	"etag":            {Type: "text", Nullable: false},
+	"file_size_bytes": {Type: "bigint", Nullable: false},
+	"created_at":      {Type: "timestamp with time zone", Nullable: false},
+	"previous_etag":   {Type: "text", Nullable: true},
+	"actor":           {Type: "text", Nullable: true},
+}
+
+var settingsColumns = map[string]internal.Column{
+	"key":        {Type: "text", Nullable: false},
+	"value":      {Type: "text", Nullable: false},
+	"updated_at": {Type: "timestamp with time zone", Nullable: false},
+}
+
+var usageColumns = map[string]internal.Column{
+	"id":         {Type: "bigint", Nullable: false},
+	"taken_at":   {Type: "timestamp with time zone", Nullable: false},
+	"change_seq": {Type: "bigint", Nullable: false},
+	"complete":   {Type: "boolean", Nullable: false},
+}
+
+var usagePrefixesColumns = map[string]internal.Column{
+	"snapshot_id":   {Type: "bigint", Nullable: false},
+	"prefix":        {Type: "text", Nullable: false},
+	"objects":       {Type: "bigint", Nullable: false},
+	"bytes":         {Type: "bigint", Nullable: false},
+	"objects_added": {Type: "bigint", Nullable: false},
+	"bytes_added":   {Type: "bigint", Nullable: false},
+}
+
+var accessKeysColumns = map[string]internal.Column{
+	"access_key":    {Type: "text", Nullable: false},
+	"created_at":    {Type: "timestamp with time zone", Nullable: false},
+	"last_used_at":  {Type: "timestamp with time zone", Nullable: true},
+	"request_count": {Type: "bigint", Nullable: false},
+	"deprecated_at": {Type: "timestamp with time zone", Nullable: true},
+	"replaced_by":   {Type: "text", Nullable: true},
+	"revoked_at":    {Type: "timestamp with time zone", Nullable: true},
+}
 
-	validations = append(validations, tableValidation{
-		tableName:      tables.MetaData,
-		expectedSchema: metaDataTableSchema,
-	})
+var writeIntentsColumns = map[string]internal.Column{
+	"id":               {Type: "uuid", Nullable: false},
+	"path":             {Type: "text", Nullable: false},
+	"content_type":     {Type: "text", Nullable: false},
+	"content_encoding": {Type: "text", Nullable: true},
+	"filename":         {Type: "text", Nullable: true},
+	"object_id":        {Type: "uuid", Nullable: true},
+	"started_at":       {Type: "timestamp with time zone", Nullable: false},
+	"user_metadata":    {Type: "jsonb", Nullable: true},
+	"cache_control":    {Type: "text", Nullable: true},
+}
+
+var uploadsColumns = map[string]internal.Column{
+	"id":               {Type: "uuid", Nullable: false},
+	"path":             {Type: "text", Nullable: false},
+	"content_type":     {Type: "text", Nullable: false},
+	"content_encoding": {Type: "text", Nullable: true},
+	"filename":         {Type: "text", Nullable: true},
+	"created_at":       {Type: "timestamp with time zone", Nullable: false},
+	"user_metadata":    {Type: "jsonb", Nullable: true},
+	"cache_control":    {Type: "text", Nullable: true},
+}
+
+var uploadPartsColumns = map[string]internal.Column{
+	"upload_id":   {Type: "uuid", Nullable: false},
+	"part_number": {Type: "integer", Nullable: false},
+	"etag":        {Type: "text", Nullable: false},
+	"size_bytes":  {Type: "bigint", Nullable: false},
+	"uploaded_at": {Type: "timestamp with time zone", Nullable: false},
+}
+
+func getTableValidations(tables stowry.Tables) []tableValidation {
+	validations := []tableValidation{{
+		tableName: tables.MetaData,
+		expectedSchema: internal.TableSchema{
+			Columns: metaDataColumns,
+			Indexes: []string{
+				fmt.Sprintf("idx_%s_deleted_at", tables.MetaData),
+				fmt.Sprintf("idx_%s_pending_cleanup", tables.MetaData),
+				fmt.Sprintf("idx_%s_active_list", tables.MetaData),
+			},
+			Migratable: columnNames(metaDataAddedColumns),
+		},
+	}, {
+		tableName:      tables.Stages(),
+		expectedSchema: internal.TableSchema{Columns: stagesColumns},
+	}, {
+		tableName: tables.StagedObjects(),
+		expectedSchema: internal.TableSchema{
+			Columns:    stagedObjectsColumns,
+			Migratable: columnNames(stagedObjectsAddedColumns),
+		},
+	}, {
+		tableName: tables.Changes(),
+		expectedSchema: internal.TableSchema{
+			Columns:    changesColumns,
+			Indexes:    []string{fmt.Sprintf("idx_%s_created_at", tables.Changes())},
+			Migratable: columnNames(changesAddedColumns),
+		},
+	}, {
+		tableName:      tables.Settings(),
+		expectedSchema: internal.TableSchema{Columns: settingsColumns},
+	}, {
+		tableName: tables.Usage(),
+		expectedSchema: internal.TableSchema{
+			Columns: usageColumns,
+			Indexes: []string{fmt.Sprintf("idx_%s_taken_at", tables.Usage())},
+		},
+	}, {
+		tableName:      tables.UsagePrefixes(),
+		expectedSchema: internal.TableSchema{Columns: usagePrefixesColumns},
+	}, {
+		tableName:      tables.AccessKeys(),
+		expectedSchema: internal.TableSchema{Columns: accessKeysColumns},
+	}, {
+		tableName: tables.WriteIntents(),
+		expectedSchema: internal.TableSchema{
+			Columns:    writeIntentsColumns,
+			Migratable: columnNames(writeIntentsAddedColumns),
+		},
+	}, {
+		tableName: tables.Uploads(),
+		expectedSchema: internal.TableSchema{
+			Columns:    uploadsColumns,
+			Migratable: columnNames(uploadsAddedColumns),
+		},
+	}, {
+		tableName:      tables.UploadParts(),
+		expectedSchema: internal.TableSchema{Columns: uploadPartsColumns},
+	}}
 
 	// Future table validations would be added here:
 	// validations = append(validations, tableValidation{
@@ -152,3 +291,11 @@ func getTableValidations(tables stowry.Tables) []tableValidation {
 
 	return validations
 }
+
+func columnNames(columns []columnDef) []string {
+	names := make([]string, len(columns))
+	for i, col := range columns {
+		names[i] = col.name
+	}
+	return names
+}
@@ -0,0 +1,157 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/sagarc03/stowry"
+	"github.com/sagarc03/stowry/database/internal"
+)
+
+func (r *repo) RecordAccessKeyUse(ctx context.Context, uses []stowry.AccessKeyUse) (err error) {
+	defer func() { err = internal.ContextError(ctx, err) }()
+
+	if len(uses) == 0 {
+		return nil
+	}
+
+	query := fmt.Sprintf( //nolint:gosec // G201: table name is validated
+		`INSERT INTO %s (access_key, created_at, last_used_at, request_count)
+		VALUES (?, ?, ?, ?)
+		ON CONFLICT (access_key) DO UPDATE SET
+			request_count = request_count + excluded.request_count,
+			last_used_at = MAX(COALESCE(last_used_at, ''), excluded.last_used_at)`, r.accessKeysTable)
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("record access key use: begin: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	stmt, err := tx.PrepareContext(ctx, query)
+	if err != nil {
+		return fmt.Errorf("record access key use: prepare: %w", err)
+	}
+	defer func() { _ = stmt.Close() }()
+
+	for _, use := range uses {
+		_, err := stmt.ExecContext(ctx, use.AccessKey, formatKeyTime(use.FirstUsedAt), formatKeyTime(use.LastUsedAt), use.Count)
+		if err != nil {
+			return fmt.Errorf("record access key use %s: %w", use.AccessKey, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("record access key use: commit: %w", err)
+	}
+	return nil
+}
+
+func (r *repo) ListAccessKeys(ctx context.Context) (_ []stowry.AccessKeyInfo, err error) {
+	defer func() { err = internal.ContextError(ctx, err) }()
+
+	query := fmt.Sprintf( //nolint:gosec // G201: table name is validated
+		`SELECT access_key, created_at, last_used_at, request_count, deprecated_at, COALESCE(replaced_by, ''), revoked_at
+		FROM %s
+		ORDER BY access_key`, r.accessKeysTable)
+
+	rows, err := r.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("list access keys: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	keys := []stowry.AccessKeyInfo{}
+	for rows.Next() {
+		var k stowry.AccessKeyInfo
+		var createdAt, lastUsedAt, deprecatedAt, revokedAt sql.NullString
+		if err := rows.Scan(&k.AccessKey, &createdAt, &lastUsedAt, &k.RequestCount, &deprecatedAt, &k.ReplacedBy, &revokedAt); err != nil {
+			return nil, fmt.Errorf("list access keys: scan: %w", err)
+		}
+		if k.CreatedAt, err = parseKeyTime(createdAt); err != nil {
+			return nil, fmt.Errorf("list access keys: parse created_at: %w", err)
+		}
+		if k.LastUsedAt, err = parseKeyTime(lastUsedAt); err != nil {
+			return nil, fmt.Errorf("list access keys: parse last_used_at: %w", err)
+		}
+		if k.DeprecatedAt, err = parseKeyTime(deprecatedAt); err != nil {
+			return nil, fmt.Errorf("list access keys: parse deprecated_at: %w", err)
+		}
+		if k.RevokedAt, err = parseKeyTime(revokedAt); err != nil {
+			return nil, fmt.Errorf("list access keys: parse revoked_at: %w", err)
+		}
+		keys = append(keys, k)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("list access keys: %w", err)
+	}
+	return keys, nil
+}
+
+func (r *repo) RotateAccessKey(ctx context.Context, oldKey, newKey string, at time.Time) (err error) {
+	defer func() { err = internal.ContextError(ctx, err) }()
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("rotate access key: begin: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	_, err = tx.ExecContext(ctx, fmt.Sprintf( //nolint:gosec // G201: table name is validated
+		`INSERT INTO %s (access_key, created_at, request_count, deprecated_at, replaced_by)
+		VALUES (?, ?, 0, ?, ?)
+		ON CONFLICT (access_key) DO UPDATE SET
+			deprecated_at = excluded.deprecated_at,
+			replaced_by = excluded.replaced_by`, r.accessKeysTable),
+		oldKey, formatKeyTime(at), formatKeyTime(at), newKey)
+	if err != nil {
+		return fmt.Errorf("rotate access key: deprecate %s: %w", oldKey, err)
+	}
+
+	_, err = tx.ExecContext(ctx, fmt.Sprintf( //nolint:gosec // G201: table name is validated
+		`INSERT INTO %s (access_key, created_at, request_count) VALUES (?, ?, 0)`, r.accessKeysTable),
+		newKey, formatKeyTime(at))
+	if err != nil {
+		return fmt.Errorf("rotate access key: create %s: %w", newKey, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("rotate access key: commit: %w", err)
+	}
+	return nil
+}
+
+func (r *repo) RevokeAccessKey(ctx context.Context, accessKey string, at time.Time) (err error) {
+	defer func() { err = internal.ContextError(ctx, err) }()
+
+	_, err = r.db.ExecContext(ctx, fmt.Sprintf( //nolint:gosec // G201: table name is validated
+		`INSERT INTO %s (access_key, created_at, request_count, revoked_at)
+		VALUES (?, ?, 0, ?)
+		ON CONFLICT (access_key) DO UPDATE SET revoked_at = excluded.revoked_at`, r.accessKeysTable),
+		accessKey, formatKeyTime(at), formatKeyTime(at))
+	if err != nil {
+		return fmt.Errorf("revoke access key %s: %w", accessKey, err)
+	}
+	return nil
+}
+
+// formatKeyTime formats an access key time for storage like a usage
+// snapshot time, with a fixed number of fractional digits, so MAX orders
+// them as text.
+func formatKeyTime(t time.Time) string {
+	return t.UTC().Format(usageTimeFormat)
+}
+
+// parseKeyTime parses a nullable access key time.
+func parseKeyTime(s sql.NullString) (*time.Time, error) {
+	if !s.Valid {
+		return nil, nil
+	}
+	t, err := time.Parse(time.RFC3339Nano, s.String)
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
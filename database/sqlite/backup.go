@@ -0,0 +1,168 @@
+package sqlite
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/sagarc03/stowry"
+	"github.com/sagarc03/stowry/database/internal"
+)
+
+// Backup writes a consistent snapshot of the database to path with VACUUM
+// INTO. Writers keep going while it runs; changes committed after it starts
+// are not in the snapshot. The snapshot is written beside path and renamed
+// into place, so path never holds a partial file. path must not exist.
+func (d *database) Backup(ctx context.Context, path string) (err error) {
+	defer func() { err = internal.ContextError(ctx, err) }()
+
+	if _, err := os.Stat(path); err == nil {
+		return fmt.Errorf("backup: %s already exists", path)
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("backup: %w", err)
+	}
+
+	// A leftover from an interrupted backup would make VACUUM INTO fail
+	tmp := path + ".tmp"
+	if err := os.Remove(tmp); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("backup: %w", err)
+	}
+
+	if _, err := d.db.ExecContext(ctx, `VACUUM INTO ?`, tmp); err != nil {
+		_ = os.Remove(tmp)
+		return fmt.Errorf("backup: %w", err)
+	}
+
+	if err := os.Rename(tmp, path); err != nil {
+		_ = os.Remove(tmp)
+		return fmt.Errorf("backup: %w", err)
+	}
+	return nil
+}
+
+// Restore replaces the database file named by dsn with the snapshot at
+// path. The snapshot must pass an integrity check and hold the metadata
+// table with the expected schema, or one the migrations bring up to date.
+//
+// Nothing may have the database open while it is replaced: its journal
+// files are removed, and a process still using the old file would keep
+// writing to it. Callers make sure no server is running.
+func Restore(ctx context.Context, path, dsn string, tables stowry.Tables) error {
+	target, err := dsnPath(dsn)
+	if err != nil {
+		return fmt.Errorf("restore: %w", err)
+	}
+
+	if err := checkSnapshot(ctx, path, tables); err != nil {
+		return fmt.Errorf("restore: %w", err)
+	}
+
+	// Keep the permissions of the database being replaced
+	perm := fs.FileMode(0o644)
+	if info, err := os.Stat(target); err == nil {
+		perm = info.Mode().Perm()
+	}
+
+	tmp := target + ".restore"
+	if err := copyFile(path, tmp, perm); err != nil {
+		_ = os.Remove(tmp)
+		return fmt.Errorf("restore: %w", err)
+	}
+
+	// A journal left by the old database would be applied to the new one
+	for _, suffix := range []string{"-journal", "-wal", "-shm"} {
+		if err := os.Remove(target + suffix); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			_ = os.Remove(tmp)
+			return fmt.Errorf("restore: remove %s: %w", suffix, err)
+		}
+	}
+
+	if err := os.Rename(tmp, target); err != nil {
+		_ = os.Remove(tmp)
+		return fmt.Errorf("restore: %w", err)
+	}
+	return nil
+}
+
+// checkSnapshot verifies that the file at path is an intact SQLite
+// database holding the metadata table.
+func checkSnapshot(ctx context.Context, path string, tables stowry.Tables) error {
+	// Opening a missing file would create an empty database
+	if _, err := os.Stat(path); err != nil {
+		return fmt.Errorf("snapshot: %w", err)
+	}
+
+	snapshot, err := Connect(ctx, path, tables)
+	if err != nil {
+		return fmt.Errorf("snapshot: %w", err)
+	}
+	defer func() { _ = snapshot.Close() }()
+
+	var result string
+	if err := snapshot.db.QueryRowContext(ctx, `PRAGMA integrity_check`).Scan(&result); err != nil {
+		return fmt.Errorf("snapshot: integrity check: %w", err)
+	}
+	if result != "ok" {
+		return fmt.Errorf("snapshot: integrity check: %s", result)
+	}
+
+	err = snapshot.Validate(ctx)
+	if err == nil {
+		return nil
+	}
+
+	var diff *internal.SchemaDiff
+	if !errors.As(err, &diff) {
+		return fmt.Errorf("snapshot: %w", err)
+	}
+	for _, table := range diff.Tables {
+		if table.Table == tables.MetaData && table.Missing {
+			return fmt.Errorf("snapshot: table %s does not exist; is this a stowry backup?", tables.MetaData)
+		}
+	}
+	// An older snapshot is brought up to date by the next migrate
+	if !diff.Resolvable() {
+		return fmt.Errorf("snapshot: %w", err)
+	}
+	return nil
+}
+
+// dsnPath returns the file a DSN names, without its URI scheme and
+// parameters. In-memory databases have no file to restore.
+func dsnPath(dsn string) (string, error) {
+	path := strings.TrimPrefix(dsn, "file:")
+	path, params, _ := strings.Cut(path, "?")
+	if path == "" || path == ":memory:" || strings.Contains(params, "mode=memory") {
+		return "", fmt.Errorf("dsn %q is not a database file", dsn)
+	}
+	return filepath.Clean(path), nil
+}
+
+// copyFile copies src to dst with perm and syncs it to disk.
+func copyFile(src, dst string, perm fs.FileMode) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = in.Close() }()
+
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, perm)
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(out, in); err != nil {
+		_ = out.Close()
+		return err
+	}
+	if err := out.Sync(); err != nil {
+		_ = out.Close()
+		return err
+	}
+	return out.Close()
+}
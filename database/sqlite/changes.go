@@ -0,0 +1,143 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/sagarc03/stowry"
+	"github.com/sagarc03/stowry/database/internal"
+)
+
+// recordChange appends a change to the feed within tx. SQLite serializes
+// writers, so sequences are assigned in commit order. The actor comes from
+// ctx. An empty previousETag or actor is stored as NULL.
+func (r *repo) recordChange(ctx context.Context, tx *sql.Tx, kind stowry.ChangeKind, path, etag string, size int64, at time.Time, previousETag string) error {
+	query := fmt.Sprintf( //nolint:gosec // G201: table name is validated
+		`INSERT INTO %s (kind, path, etag, file_size_bytes, created_at, previous_etag, actor)
+		VALUES (?, ?, ?, ?, ?, NULLIF(?, ''), NULLIF(?, ''))`, r.changesTable)
+
+	if _, err := tx.ExecContext(ctx, query, string(kind), path, etag, size, at.UTC().Format(time.RFC3339Nano), previousETag, stowry.ActorFromContext(ctx)); err != nil {
+		return fmt.Errorf("record change: %w", err)
+	}
+	return nil
+}
+
+func (r *repo) Changes(ctx context.Context, q stowry.ChangeQuery) (_ stowry.ChangeBatch, err error) {
+	defer func() { err = internal.ContextError(ctx, err) }()
+
+	if q.Limit <= 0 {
+		q.Limit = stowry.DefaultChangeLimit
+	}
+
+	var minSeq, maxSeq int64
+	err = r.db.QueryRowContext(ctx, fmt.Sprintf( //nolint:gosec // G201: table name is validated
+		`SELECT COALESCE(MIN(seq), 0), COALESCE(MAX(seq), 0) FROM %s`, r.changesTable)).Scan(&minSeq, &maxSeq)
+	if err != nil {
+		return stowry.ChangeBatch{}, fmt.Errorf("changes: %w", err)
+	}
+
+	next, err := internal.ChangeWindow(q.Since, minSeq, maxSeq)
+	if err != nil {
+		return stowry.ChangeBatch{}, fmt.Errorf("changes: %w", err)
+	}
+	if q.Since < 0 || next == q.Since {
+		return stowry.ChangeBatch{Changes: []stowry.Change{}, Next: next}, nil
+	}
+
+	query := fmt.Sprintf( //nolint:gosec // G201: table name is validated
+		`SELECT %s
+		FROM %s
+		WHERE seq > ? AND seq <= ? AND %s
+		ORDER BY seq
+		LIMIT ?`, changeColumns, r.changesTable, prefixCondition)
+
+	args := append([]any{q.Since, maxSeq}, prefixArgs(q.PathPrefix)...)
+	rows, err := r.db.QueryContext(ctx, query, append(args, q.Limit)...)
+	if err != nil {
+		return stowry.ChangeBatch{}, fmt.Errorf("changes: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	changes, err := scanChanges(rows, q.Limit)
+	if err != nil {
+		return stowry.ChangeBatch{}, fmt.Errorf("changes: %w", err)
+	}
+
+	return internal.ChangePage(changes, q.Limit, next), nil
+}
+
+func (r *repo) History(ctx context.Context, q stowry.HistoryQuery) (_ stowry.History, err error) {
+	defer func() { err = internal.ContextError(ctx, err) }()
+
+	if q.Limit <= 0 {
+		q.Limit = stowry.DefaultChangeLimit
+	}
+
+	// One extra row tells whether another page follows
+	query := fmt.Sprintf( //nolint:gosec // G201: table name is validated
+		`SELECT %s
+		FROM %s
+		WHERE path = ? AND seq > ?
+		ORDER BY seq
+		LIMIT ?`, changeColumns, r.changesTable)
+
+	rows, err := r.db.QueryContext(ctx, query, q.Path, q.After, q.Limit+1)
+	if err != nil {
+		return stowry.History{}, fmt.Errorf("history: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	changes, err := scanChanges(rows, q.Limit+1)
+	if err != nil {
+		return stowry.History{}, fmt.Errorf("history: %w", err)
+	}
+
+	return internal.HistoryPage(q.Path, changes, q.Limit), nil
+}
+
+// changeColumns are the change feed columns read by scanChanges.
+const changeColumns = `seq, kind, path, etag, file_size_bytes, created_at, COALESCE(previous_etag, ''), COALESCE(actor, '')`
+
+// scanChanges reads rows selected with changeColumns.
+func scanChanges(rows *sql.Rows, capacity int) ([]stowry.Change, error) {
+	changes := make([]stowry.Change, 0, capacity)
+	for rows.Next() {
+		var c stowry.Change
+		var kind, at string
+		if err := rows.Scan(&c.Seq, &kind, &c.Path, &c.ETag, &c.Size, &at, &c.PreviousETag, &c.Actor); err != nil {
+			return nil, fmt.Errorf("scan: %w", err)
+		}
+		c.Kind = stowry.ChangeKind(kind)
+		var err error
+		c.At, err = time.Parse(time.RFC3339Nano, at)
+		if err != nil {
+			return nil, fmt.Errorf("parse created_at: %w", err)
+		}
+		changes = append(changes, c)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return changes, nil
+}
+
+func (r *repo) PruneChanges(ctx context.Context, before time.Time) (_ int64, err error) {
+	defer func() { err = internal.ContextError(ctx, err) }()
+
+	query := fmt.Sprintf( //nolint:gosec // G201: table name is validated
+		`DELETE FROM %s
+		WHERE created_at < ? AND seq < (SELECT MAX(seq) FROM %s)`, r.changesTable, r.changesTable)
+
+	result, err := r.db.ExecContext(ctx, query, before.UTC().Format(time.RFC3339Nano))
+	if err != nil {
+		return 0, fmt.Errorf("prune changes: %w", err)
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("prune changes: rows affected: %w", err)
+	}
+	return n, nil
+}
@@ -4,61 +4,183 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
+	"strings"
 
 	"github.com/sagarc03/stowry"
+	"github.com/sagarc03/stowry/database/internal"
 
 	_ "modernc.org/sqlite" // SQLite driver
 )
 
 // database provides SQLite database operations.
 type database struct {
-	db     *sql.DB
-	tables stowry.Tables
+	db       *sql.DB
+	tables   stowry.Tables
+	idScheme stowry.IDScheme
 }
 
+// Option customizes a database created by Connect.
+type Option func(*database)
+
+// WithIDScheme sets how the IDs of new metadata rows are generated. The
+// default is stowry.IDSchemeUUIDv4.
+func WithIDScheme(scheme stowry.IDScheme) Option {
+	return func(d *database) {
+		d.idScheme = scheme
+	}
+}
+
+// busyTimeoutMillis is how long a connection waits for a lock held by
+// another before failing with SQLITE_BUSY.
+const busyTimeoutMillis = 5000
+
 // Connect establishes a connection to SQLite.
 // Tables should be validated before calling Connect.
-func Connect(ctx context.Context, dsn string, tables stowry.Tables) (*database, error) {
-	db, err := sql.Open("sqlite", dsn)
+func Connect(ctx context.Context, dsn string, tables stowry.Tables, opts ...Option) (*database, error) {
+	db, err := sql.Open("sqlite", withConnParams(dsn))
 	if err != nil {
 		return nil, fmt.Errorf("connect sqlite: %w", err)
 	}
 
-	return &database{
+	d := &database{
 		db:     db,
 		tables: tables,
-	}, nil
+	}
+	for _, opt := range opts {
+		opt(d)
+	}
+	if !d.idScheme.Valid() {
+		_ = db.Close()
+		return nil, fmt.Errorf("connect sqlite: unknown id scheme %q", d.idScheme)
+	}
+	return d, nil
+}
+
+// withConnParams adds a busy timeout and immediate write transactions to
+// dsn, so concurrent writers and online backups wait for each other's locks
+// instead of failing. Parameters dsn already sets are kept.
+func withConnParams(dsn string) string {
+	var params []string
+	if !strings.Contains(dsn, "busy_timeout") {
+		params = append(params, fmt.Sprintf("_pragma=busy_timeout(%d)", busyTimeoutMillis))
+	}
+	if !strings.Contains(dsn, "_txlock") {
+		params = append(params, "_txlock=immediate")
+	}
+	if len(params) == 0 {
+		return dsn
+	}
+
+	sep := "?"
+	if strings.Contains(dsn, "?") {
+		sep = "&"
+	}
+	return dsn + sep + strings.Join(params, "&")
 }
 
 // Ping verifies the database connection is alive.
 func (d *database) Ping(ctx context.Context) error {
-	return d.db.PingContext(ctx)
+	return internal.ContextError(ctx, d.db.PingContext(ctx))
 }
 
 // Migrate runs database migrations to create required tables.
-func (d *database) Migrate(ctx context.Context) error {
-	if err := createMetaTable(ctx, d.db, d.tables.MetaData); err != nil {
-		return fmt.Errorf("migrate: %w", err)
+func (d *database) Migrate(ctx context.Context) (err error) {
+	defer func() { err = internal.ContextError(ctx, err) }()
+
+	for _, ddl := range tableDDL(d.tables) {
+		if err := migrateTable(ctx, d.db, ddl); err != nil {
+			return fmt.Errorf("migrate: %w", err)
+		}
 	}
 	return nil
 }
 
 // Validate checks that the database schema matches expected structure.
-func (d *database) Validate(ctx context.Context) error {
-	validations := getTableValidations(d.tables)
+// A mismatch is returned as a *internal.SchemaDiff covering every table.
+func (d *database) Validate(ctx context.Context) (err error) {
+	defer func() { err = internal.ContextError(ctx, err) }()
 
-	for _, validation := range validations {
-		if err := validateTableSchema(ctx, d.db, validation.tableName, validation.expectedSchema); err != nil {
-			return fmt.Errorf("validate schema %s: %w", validation.tableName, err)
+	diff, err := d.schemaDiff(ctx)
+	if err != nil {
+		return err
+	}
+	if !diff.Empty() {
+		return fmt.Errorf("validate schema: %w", diff)
+	}
+	return nil
+}
+
+// Plan returns the steps that resolve the schema's differences from the
+// expected one, without running them.
+func (d *database) Plan(ctx context.Context) (steps []internal.MigrationStep, err error) {
+	defer func() { err = internal.ContextError(ctx, err) }()
+
+	diff, err := d.schemaDiff(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("plan migrations: %w", err)
+	}
+	return internal.PlanMigrations(diff, tableDDL(d.tables), changeColumn), nil
+}
+
+// Apply runs steps in one transaction, so a failed step leaves the schema
+// as it was. It refuses manual steps before running any.
+func (d *database) Apply(ctx context.Context, steps []internal.MigrationStep) (err error) {
+	defer func() { err = internal.ContextError(ctx, err) }()
+
+	for _, step := range steps {
+		if step.Manual() {
+			return fmt.Errorf("apply migrations: %s: %s must be done by hand", step.Table, step.Description)
+		}
+	}
+
+	tx, err := d.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("apply migrations: begin: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	for _, step := range steps {
+		if _, err := tx.ExecContext(ctx, step.SQL); err != nil {
+			return fmt.Errorf("apply migrations: %s: %s: %w", step.Table, step.Description, err)
 		}
 	}
 
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("apply migrations: commit: %w", err)
+	}
 	return nil
 }
 
+// schemaDiff compares every table with its expected schema.
+func (d *database) schemaDiff(ctx context.Context) (*internal.SchemaDiff, error) {
+	diff := &internal.SchemaDiff{}
+	for _, validation := range getTableValidations(d.tables) {
+		table, err := compareTableSchema(ctx, d.db, validation.tableName, validation.expectedSchema)
+		if err != nil {
+			return nil, fmt.Errorf("validate schema %s: %w", validation.tableName, err)
+		}
+		diff.Add(table)
+	}
+	return diff, nil
+}
+
 // GetRepo returns the MetaDataRepo for database operations.
 func (d *database) GetRepo() stowry.MetaDataRepo {
-	return &repo{db: d.db, tableName: d.tables.MetaData}
+	return &repo{
+		db:                 d.db,
+		tableName:          d.tables.MetaData,
+		stagesTable:        d.tables.Stages(),
+		stagedObjectsTable: d.tables.StagedObjects(),
+		changesTable:       d.tables.Changes(),
+		settingsTable:      d.tables.Settings(),
+		usageTable:         d.tables.Usage(),
+		usagePrefixesTable: d.tables.UsagePrefixes(),
+		accessKeysTable:    d.tables.AccessKeys(),
+		writeIntentsTable:  d.tables.WriteIntents(),
+		uploadsTable:       d.tables.Uploads(),
+		uploadPartsTable:   d.tables.UploadParts(),
+		idScheme:           d.idScheme,
+	}
 }
 
 // Close closes the database connection.
@@ -3,13 +3,24 @@ package sqlite_test
 import (
 	"context"
 	"database/sql"
+	"encoding/binary"
+	"errors"
+	"fmt"
 	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/sagarc03/stowry"
+	"github.com/sagarc03/stowry/database/internal"
 	"github.com/sagarc03/stowry/database/sqlite"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 
 	_ "modernc.org/sqlite"
 )
@@ -72,6 +83,150 @@ func TestDatabase_Migrate(t *testing.T) {
 	})
 }
 
+func TestDatabase_Migrate_AddsColumnsToExistingTable(t *testing.T) {
+	ctx := context.Background()
+
+	tmpFile, err := os.CreateTemp("", "test-*.db")
+	assert.NoError(t, err)
+	tmpPath := tmpFile.Name()
+	tmpFile.Close()
+	defer os.Remove(tmpPath)
+
+	// Table as created by earlier releases, before access tracking, content
+	// encoding and quarantine columns
+	rawDB, err := sql.Open("sqlite", tmpPath)
+	assert.NoError(t, err)
+	_, err = rawDB.ExecContext(ctx, `CREATE TABLE metadata (
+		id TEXT NOT NULL PRIMARY KEY,
+		path TEXT NOT NULL UNIQUE,
+		content_type TEXT NOT NULL,
+		etag TEXT NOT NULL,
+		file_size_bytes INTEGER NOT NULL,
+		created_at TEXT NOT NULL,
+		updated_at TEXT NOT NULL,
+		deleted_at TEXT,
+		cleaned_up_at TEXT
+	)`)
+	assert.NoError(t, err)
+	rawDB.Close()
+
+	tables := stowry.Tables{MetaData: "metadata"}
+	db, err := sqlite.Connect(ctx, tmpPath, tables)
+	assert.NoError(t, err)
+	defer func() { _ = db.Close() }()
+
+	diff := schemaDiff(t, db.Validate(ctx))
+	assert.True(t, diff.Resolvable(), "migrations add the later columns")
+	table := diffTable(t, diff, "metadata")
+	assert.Equal(t, []string{"cache_control", "cleanup_retry_at", "content_encoding", "decoded_size_bytes", "download_count", "filename", "last_accessed_at", "quarantined_at", "storage_backend", "user_metadata"}, columnNames(table.MissingColumns))
+	assert.True(t, table.MissingColumns[0].Migratable)
+	assert.NoError(t, db.Migrate(ctx))
+	assert.NoError(t, db.Validate(ctx), "migrate should add missing columns")
+}
+
+func TestDatabase_Plan(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("missing tables are created with their indexes", func(t *testing.T) {
+		db, err := sqlite.Connect(ctx, filepath.Join(t.TempDir(), "plan.db"), stowry.Tables{MetaData: "metadata"})
+		require.NoError(t, err)
+		defer func() { _ = db.Close() }()
+
+		steps, err := db.Plan(ctx)
+		require.NoError(t, err)
+		require.Len(t, steps, 18, "eleven tables and seven indexes")
+		assert.Equal(t, internal.MigrationStep{Table: "metadata", Description: "create table", SQL: steps[0].SQL}, steps[0])
+		assert.Equal(t, "create index idx_metadata_deleted_at", steps[1].Description)
+		for _, step := range steps {
+			assert.False(t, step.Destructive, step.Description)
+		}
+
+		require.NoError(t, db.Apply(ctx, steps))
+		assert.NoError(t, db.Validate(ctx))
+
+		steps, err = db.Plan(ctx)
+		require.NoError(t, err)
+		assert.Empty(t, steps, "nothing to do after apply")
+	})
+
+	t.Run("missing columns are added", func(t *testing.T) {
+		path := legacyDatabase(t, `CREATE TABLE metadata (
+			id TEXT NOT NULL PRIMARY KEY,
+			path TEXT NOT NULL UNIQUE,
+			content_type TEXT NOT NULL,
+			etag TEXT NOT NULL,
+			file_size_bytes INTEGER NOT NULL,
+			created_at TEXT NOT NULL,
+			updated_at TEXT NOT NULL,
+			deleted_at TEXT,
+			cleaned_up_at TEXT,
+			download_count INTEGER,
+			last_accessed_at TEXT,
+			content_encoding TEXT,
+			decoded_size_bytes INTEGER,
+			quarantined_at TEXT
+		)`)
+		db, err := sqlite.Connect(ctx, path, stowry.Tables{MetaData: "metadata"})
+		require.NoError(t, err)
+		defer func() { _ = db.Close() }()
+
+		steps, err := db.Plan(ctx)
+		require.NoError(t, err)
+		assert.Contains(t, steps, internal.MigrationStep{
+			Table:       "metadata",
+			Description: "add column filename",
+			SQL:         `ALTER TABLE "metadata" ADD COLUMN "filename" TEXT`,
+		})
+
+		require.NoError(t, db.Apply(ctx, steps))
+		assert.NoError(t, db.Validate(ctx))
+	})
+
+	t.Run("changed columns are manual", func(t *testing.T) {
+		path := legacyDatabase(t, `CREATE TABLE metadata (
+			id TEXT NOT NULL PRIMARY KEY,
+			path TEXT NOT NULL UNIQUE,
+			content_type TEXT NOT NULL,
+			etag TEXT NOT NULL,
+			file_size_bytes TEXT NOT NULL,
+			created_at TEXT NOT NULL,
+			updated_at TEXT NOT NULL,
+			deleted_at TEXT,
+			cleaned_up_at TEXT
+		)`)
+		db, err := sqlite.Connect(ctx, path, stowry.Tables{MetaData: "metadata"})
+		require.NoError(t, err)
+		defer func() { _ = db.Close() }()
+
+		steps, err := db.Plan(ctx)
+		require.NoError(t, err)
+		i := slices.IndexFunc(steps, func(s internal.MigrationStep) bool { return strings.Contains(s.Description, "file_size_bytes") })
+		require.GreaterOrEqual(t, i, 0)
+		assert.True(t, steps[i].Manual())
+		assert.True(t, steps[i].Destructive)
+
+		err = db.Apply(ctx, steps)
+		assert.ErrorContains(t, err, "must be done by hand")
+		table := diffTable(t, schemaDiff(t, db.Validate(ctx)), "metadata")
+		assert.NotEmpty(t, table.MissingColumns, "apply runs no step when one is manual")
+	})
+}
+
+// legacyDatabase returns the path of a database whose metadata table was
+// created by createSQL, as by an earlier release.
+func legacyDatabase(t *testing.T, createSQL string) string {
+	t.Helper()
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "legacy.db")
+
+	rawDB, err := sql.Open("sqlite", path)
+	require.NoError(t, err)
+	_, err = rawDB.ExecContext(ctx, createSQL)
+	require.NoError(t, err)
+	require.NoError(t, rawDB.Close())
+	return path
+}
+
 func TestDatabase_Validate(t *testing.T) {
 	ctx := context.Background()
 
@@ -95,8 +250,11 @@ func TestDatabase_Validate(t *testing.T) {
 		defer func() { _ = db.Close() }()
 
 		// Don't migrate - table won't exist
-		err = db.Validate(ctx)
-		assert.Error(t, err)
+		diff := schemaDiff(t, db.Validate(ctx))
+		assert.True(t, diff.Resolvable(), "migrate creates missing tables")
+		assert.True(t, diffTable(t, diff, "metadata").Missing)
+		assert.True(t, diffTable(t, diff, "metadata_changes").Missing)
+		assert.Contains(t, diff.Error(), "stowry migrate")
 	})
 
 	t.Run("error - missing columns", func(t *testing.T) {
@@ -122,9 +280,19 @@ func TestDatabase_Validate(t *testing.T) {
 		assert.NoError(t, err)
 		defer func() { _ = db.Close() }()
 
-		err = db.Validate(ctx)
-		assert.Error(t, err)
-		assert.Contains(t, err.Error(), "missing columns")
+		diff := schemaDiff(t, db.Validate(ctx))
+		assert.False(t, diff.Resolvable(), "no migration adds the original columns")
+		table := diffTable(t, diff, "metadata")
+		assert.Equal(t, []string{
+			"cache_control", "cleaned_up_at", "cleanup_retry_at", "content_encoding", "content_type", "created_at", "decoded_size_bytes",
+			"deleted_at", "download_count", "etag", "file_size_bytes", "filename", "last_accessed_at", "quarantined_at",
+			"storage_backend", "updated_at", "user_metadata",
+		}, columnNames(table.MissingColumns))
+		assert.Equal(t, []string{
+			"idx_metadata_active_list", "idx_metadata_deleted_at", "idx_metadata_pending_cleanup",
+		}, table.MissingIndexes)
+		assert.Contains(t, diff.Error(), "no migration adds it")
+		assert.Contains(t, diff.Error(), "Alter them by hand")
 	})
 
 	t.Run("error - wrong column type", func(t *testing.T) {
@@ -157,12 +325,74 @@ func TestDatabase_Validate(t *testing.T) {
 		assert.NoError(t, err)
 		defer func() { _ = db.Close() }()
 
-		err = db.Validate(ctx)
-		assert.Error(t, err)
-		assert.Contains(t, err.Error(), "file_size_bytes")
+		diff := schemaDiff(t, db.Validate(ctx))
+		assert.False(t, diff.Resolvable(), "migrations do not change column types")
+		table := diffTable(t, diff, "metadata")
+		assert.Equal(t, []internal.ColumnDiff{{
+			Name:     "file_size_bytes",
+			Expected: &internal.Column{Type: "integer"},
+			Actual:   &internal.Column{Type: "text"},
+		}}, table.ChangedColumns)
+		assert.Contains(t, diff.Error(), "wrong type")
+	})
+
+	t.Run("error - missing index and extra column", func(t *testing.T) {
+		tmpPath := filepath.Join(t.TempDir(), "test.db")
+		tables := stowry.Tables{MetaData: "metadata"}
+		db, err := sqlite.Connect(ctx, tmpPath, tables)
+		assert.NoError(t, err)
+		defer func() { _ = db.Close() }()
+		assert.NoError(t, db.Migrate(ctx))
+
+		rawDB, err := sql.Open("sqlite", tmpPath)
+		assert.NoError(t, err)
+		_, err = rawDB.ExecContext(ctx, `DROP INDEX idx_metadata_changes_created_at`)
+		assert.NoError(t, err)
+		_, err = rawDB.ExecContext(ctx, `ALTER TABLE metadata_changes ADD COLUMN note TEXT`)
+		assert.NoError(t, err)
+		rawDB.Close()
+
+		diff := schemaDiff(t, db.Validate(ctx))
+		assert.True(t, diff.Resolvable())
+		assert.Len(t, diff.Tables, 1)
+		table := diffTable(t, diff, "metadata_changes")
+		assert.Equal(t, []string{"idx_metadata_changes_created_at"}, table.MissingIndexes)
+		assert.Equal(t, []string{"note"}, columnNames(table.ExtraColumns))
+
+		assert.NoError(t, db.Migrate(ctx))
+		assert.NoError(t, db.Validate(ctx), "extra columns alone do not fail validation")
 	})
 }
 
+// schemaDiff returns the schema diff err carries.
+func schemaDiff(t *testing.T, err error) *internal.SchemaDiff {
+	t.Helper()
+	var diff *internal.SchemaDiff
+	if !errors.As(err, &diff) {
+		t.Fatalf("expected a schema diff, got %v", err)
+	}
+	return diff
+}
+
+func diffTable(t *testing.T, diff *internal.SchemaDiff, name string) internal.TableDiff {
+	t.Helper()
+	for _, table := range diff.Tables {
+		if table.Table == name {
+			return table
+		}
+	}
+	t.Fatalf("table %s not in diff", name)
+	return internal.TableDiff{}
+}
+
+func columnNames(columns []internal.ColumnDiff) []string {
+	names := make([]string, len(columns))
+	for i, c := range columns {
+		names[i] = c.Name
+	}
+	return names
+}
+
 func TestDatabase_GetRepo(t *testing.T) {
 	ctx := context.Background()
 	tables := stowry.Tables{MetaData: "metadata"}
@@ -221,9 +451,9 @@ func TestRepo_Upsert(t *testing.T) {
 			ContentType: "text/plain",
 		}
 
-		metadata, inserted, err := repo.Upsert(ctx, entry)
+		metadata, previous, err := repo.Upsert(ctx, entry)
 		assert.NoError(t, err, "expected no error, got: %v")
-		assert.True(t, inserted, "expected inserted to be true for new entry")
+		assert.Nil(t, previous, "expected no previous entry for new entry")
 		assert.Equal(t, entry.Path, metadata.Path, "expected path")
 		assert.Equal(t, entry.Size, metadata.FileSizeBytes, "expected size")
 		assert.Equal(t, entry.ETag, metadata.Etag, "expected etag")
@@ -244,9 +474,9 @@ func TestRepo_Upsert(t *testing.T) {
 			ContentType: "text/plain",
 		}
 
-		metadata1, inserted1, err := repo.Upsert(ctx, entry1)
+		metadata1, previous1, err := repo.Upsert(ctx, entry1)
 		assert.NoError(t, err, "first upsert failed: %v")
-		assert.True(t, inserted1, "expected first upsert to be insert")
+		assert.Nil(t, previous1, "expected first upsert to be insert")
 
 		entry2 := stowry.ObjectEntry{
 			Path:        "/test/file.txt",
@@ -255,10 +485,13 @@ func TestRepo_Upsert(t *testing.T) {
 			ContentType: "application/octet-stream",
 		}
 
-		metadata2, inserted2, err := repo.Upsert(ctx, entry2)
+		metadata2, previous2, err := repo.Upsert(ctx, entry2)
 		assert.NoError(t, err, "second upsert failed: %v")
-		if inserted2 {
-			t.Error("expected second upsert to be update")
+		if assert.NotNil(t, previous2, "expected second upsert to be update") {
+			assert.Equal(t, metadata1.ID, previous2.ID, "expected previous ID")
+			assert.Equal(t, entry1.ETag, previous2.Etag, "expected previous etag")
+			assert.Equal(t, entry1.Size, previous2.FileSizeBytes, "expected previous size")
+			assert.Equal(t, entry1.ContentType, previous2.ContentType, "expected previous content type")
 		}
 		assert.Equal(t, metadata1.ID, metadata2.ID, "ID should remain the same after update")
 		assert.Equal(t, entry2.Size, metadata2.FileSizeBytes, "expected updated size")
@@ -285,15 +518,128 @@ func TestRepo_Upsert(t *testing.T) {
 		err = repo.Delete(ctx, entry.Path)
 		assert.NoError(t, err, "delete failed: %v")
 
-		metadata, inserted, err := repo.Upsert(ctx, entry)
+		metadata, previous, err := repo.Upsert(ctx, entry)
 		assert.NoError(t, err, "upsert after delete failed: %v")
-		if inserted {
-			t.Error("expected upsert to be update (restore)")
-		}
+		assert.Nil(t, previous, "expected no previous entry for a restore")
 		assert.Equal(t, entry.Path, metadata.Path, "expected path")
 	})
 }
 
+// upsertPaths runs entries through either sequential Upsert calls or a single
+// UpsertBatch call, so both code paths can be checked against one scenario.
+var upsertPaths = map[string]func(ctx context.Context, repo stowry.MetaDataRepo, entries []stowry.ObjectEntry) ([]stowry.MetaData, error){
+	"upsert": func(ctx context.Context, repo stowry.MetaDataRepo, entries []stowry.ObjectEntry) ([]stowry.MetaData, error) {
+		results := make([]stowry.MetaData, 0, len(entries))
+		for _, entry := range entries {
+			m, _, err := repo.Upsert(ctx, entry)
+			if err != nil {
+				return nil, err
+			}
+			results = append(results, m)
+		}
+		return results, nil
+	},
+	"upsert batch": func(ctx context.Context, repo stowry.MetaDataRepo, entries []stowry.ObjectEntry) ([]stowry.MetaData, error) {
+		return repo.UpsertBatch(ctx, entries)
+	},
+}
+
+func TestRepo_UpsertBatch_MatchesUpsert(t *testing.T) {
+	for name, upsert := range upsertPaths {
+		t.Run(name, func(t *testing.T) {
+			repo, cleanup := setupTestRepo(t)
+			defer cleanup()
+
+			ctx := context.Background()
+
+			seed := func(path string) stowry.MetaData {
+				m, _, err := repo.Upsert(ctx, stowry.ObjectEntry{Path: path, Size: 1, ETag: "old", ContentType: "text/plain"})
+				assert.NoError(t, err, "seed %s", path)
+				return m
+			}
+			kept := seed("keep.txt")
+			deleted := seed("deleted.txt")
+			cleaned := seed("cleaned.txt")
+			assert.NoError(t, repo.Delete(ctx, "deleted.txt"))
+			assert.NoError(t, repo.Delete(ctx, "cleaned.txt"))
+			assert.NoError(t, repo.MarkCleanedUp(ctx, cleaned.ID))
+
+			entries := []stowry.ObjectEntry{
+				{Path: "new.txt", Size: 10, ETag: "n1", ContentType: "text/plain"},
+				{Path: "keep.txt", Size: 20, ETag: "k2", ContentType: "text/html"},
+				{Path: "deleted.txt", Size: 30, ETag: "d2", ContentType: "text/plain"},
+				{Path: "cleaned.txt", Size: 40, ETag: "c2", ContentType: "text/plain"},
+				{Path: "new.txt", Size: 11, ETag: "n2", ContentType: "application/json"},
+			}
+
+			results, err := upsert(ctx, repo, entries)
+			assert.NoError(t, err)
+			assert.Len(t, results, len(entries))
+
+			for i, entry := range entries {
+				assert.Equal(t, entry.Path, results[i].Path, "result %d path", i)
+				assert.Equal(t, entry.ETag, results[i].Etag, "result %d etag", i)
+				assert.Equal(t, entry.Size, results[i].FileSizeBytes, "result %d size", i)
+				assert.Equal(t, entry.ContentType, results[i].ContentType, "result %d content type", i)
+			}
+
+			// Existing and soft-deleted rows are updated in place; new paths get a fresh ID once
+			assert.NotContains(t, []uuid.UUID{kept.ID, deleted.ID, cleaned.ID}, results[0].ID)
+			assert.Equal(t, kept.ID, results[1].ID)
+			assert.Equal(t, deleted.ID, results[2].ID)
+			assert.Equal(t, cleaned.ID, results[3].ID)
+			assert.Equal(t, results[0].ID, results[4].ID)
+			assert.True(t, kept.CreatedAt.Equal(results[1].CreatedAt), "created_at preserved on update")
+
+			list, err := repo.List(ctx, stowry.ListQuery{Limit: 10})
+			assert.NoError(t, err)
+			etags := map[string]string{}
+			for _, item := range list.Items {
+				etags[item.Path] = item.Etag
+			}
+			assert.Equal(t, map[string]string{"keep.txt": "k2", "deleted.txt": "d2", "cleaned.txt": "c2", "new.txt": "n2"}, etags)
+
+			pending, err := repo.ListPendingCleanup(ctx, stowry.ListQuery{Limit: 10})
+			assert.NoError(t, err)
+			assert.Empty(t, pending.Items, "restored rows are no longer pending cleanup")
+		})
+	}
+}
+
+func TestRepo_UpsertBatch(t *testing.T) {
+	t.Run("empty batch", func(t *testing.T) {
+		repo, cleanup := setupTestRepo(t)
+		defer cleanup()
+
+		results, err := repo.UpsertBatch(context.Background(), nil)
+		assert.NoError(t, err)
+		assert.Empty(t, results)
+	})
+
+	t.Run("spans several chunks", func(t *testing.T) {
+		repo, cleanup := setupTestRepo(t)
+		defer cleanup()
+
+		ctx := context.Background()
+
+		entries := make([]stowry.ObjectEntry, internal.MaxUpsertBatchSize+5)
+		for i := range entries {
+			entries[i] = stowry.ObjectEntry{Path: fmt.Sprintf("bulk/%04d.txt", i), Size: int64(i), ETag: "e", ContentType: "text/plain"}
+		}
+
+		results, err := repo.UpsertBatch(ctx, entries)
+		assert.NoError(t, err)
+		assert.Len(t, results, len(entries))
+		for i := range entries {
+			assert.Equal(t, entries[i].Path, results[i].Path)
+		}
+
+		last, err := repo.Get(ctx, entries[len(entries)-1].Path)
+		assert.NoError(t, err)
+		assert.Equal(t, results[len(results)-1].ID, last.ID)
+	})
+}
+
 func TestRepo_Get(t *testing.T) {
 	t.Run("success - gets existing entry", func(t *testing.T) {
 		repo, cleanup := setupTestRepo(t)
@@ -353,6 +699,82 @@ func TestRepo_Get(t *testing.T) {
 	})
 }
 
+func TestRepo_GetDeleted(t *testing.T) {
+	entry := stowry.ObjectEntry{
+		Path:        "/test/file.txt",
+		Size:        1024,
+		ETag:        "etag123",
+		ContentType: "text/plain",
+	}
+
+	t.Run("success - gets soft-deleted entry", func(t *testing.T) {
+		repo, cleanup := setupTestRepo(t)
+		defer cleanup()
+
+		ctx := context.Background()
+
+		upserted, _, err := repo.Upsert(ctx, entry)
+		require.NoError(t, err)
+		before := time.Now().Add(-time.Second)
+		require.NoError(t, repo.Delete(ctx, entry.Path))
+
+		metadata, err := repo.GetDeleted(ctx, entry.Path)
+		require.NoError(t, err)
+		assert.Equal(t, upserted.ID, metadata.ID)
+		assert.Equal(t, entry.ETag, metadata.Etag)
+		assert.Equal(t, entry.Size, metadata.FileSizeBytes)
+		require.NotNil(t, metadata.DeletedAt)
+		assert.True(t, metadata.DeletedAt.After(before), "deleted_at is the delete time")
+	})
+
+	t.Run("error - not found for active entry", func(t *testing.T) {
+		repo, cleanup := setupTestRepo(t)
+		defer cleanup()
+
+		ctx := context.Background()
+
+		_, _, err := repo.Upsert(ctx, entry)
+		require.NoError(t, err)
+
+		_, err = repo.GetDeleted(ctx, entry.Path)
+		assert.ErrorIs(t, err, stowry.ErrNotFound)
+
+		_, err = repo.GetDeleted(ctx, "/nonexistent/file.txt")
+		assert.ErrorIs(t, err, stowry.ErrNotFound)
+	})
+
+	t.Run("error - not found after re-upload", func(t *testing.T) {
+		repo, cleanup := setupTestRepo(t)
+		defer cleanup()
+
+		ctx := context.Background()
+
+		_, _, err := repo.Upsert(ctx, entry)
+		require.NoError(t, err)
+		require.NoError(t, repo.Delete(ctx, entry.Path))
+		_, _, err = repo.Upsert(ctx, entry)
+		require.NoError(t, err)
+
+		_, err = repo.GetDeleted(ctx, entry.Path)
+		assert.ErrorIs(t, err, stowry.ErrNotFound)
+	})
+
+	t.Run("error - gone after cleanup", func(t *testing.T) {
+		repo, cleanup := setupTestRepo(t)
+		defer cleanup()
+
+		ctx := context.Background()
+
+		upserted, _, err := repo.Upsert(ctx, entry)
+		require.NoError(t, err)
+		require.NoError(t, repo.Delete(ctx, entry.Path))
+		require.NoError(t, repo.MarkCleanedUp(ctx, upserted.ID))
+
+		_, err = repo.GetDeleted(ctx, entry.Path)
+		assert.ErrorIs(t, err, stowry.ErrGone)
+	})
+}
+
 func TestRepo_Delete(t *testing.T) {
 	t.Run("success - soft deletes existing entry", func(t *testing.T) {
 		repo, cleanup := setupTestRepo(t)
@@ -413,6 +835,81 @@ func TestRepo_Delete(t *testing.T) {
 	})
 }
 
+func TestRepo_Quarantine(t *testing.T) {
+	entry := stowry.ObjectEntry{
+		Path:        "/test/file.txt",
+		Size:        1024,
+		ETag:        "etag123",
+		ContentType: "text/plain",
+	}
+
+	t.Run("success - soft-deletes and flags the entry", func(t *testing.T) {
+		repo, cleanup := setupTestRepo(t)
+		defer cleanup()
+
+		ctx := context.Background()
+
+		_, _, err := repo.Upsert(ctx, entry)
+		require.NoError(t, err)
+		require.NoError(t, repo.Quarantine(ctx, entry.Path, entry.ETag))
+
+		_, err = repo.Get(ctx, entry.Path)
+		assert.ErrorIs(t, err, stowry.ErrNotFound)
+
+		metadata, err := repo.GetDeleted(ctx, entry.Path)
+		require.NoError(t, err)
+		assert.NotNil(t, metadata.DeletedAt)
+		assert.NotNil(t, metadata.QuarantinedAt)
+
+		pending, err := repo.ListPendingCleanup(ctx, stowry.ListQuery{Limit: 10})
+		require.NoError(t, err)
+		assert.Empty(t, pending.Items, "cleanup keeps quarantined files")
+
+		changes, err := repo.(stowry.ChangeFeed).Changes(ctx, stowry.ChangeQuery{Since: 0, Limit: 10})
+		require.NoError(t, err)
+		require.Len(t, changes.Changes, 2)
+		assert.Equal(t, stowry.ChangeDelete, changes.Changes[1].Kind)
+	})
+
+	t.Run("error - not found for another etag", func(t *testing.T) {
+		repo, cleanup := setupTestRepo(t)
+		defer cleanup()
+
+		ctx := context.Background()
+
+		_, _, err := repo.Upsert(ctx, entry)
+		require.NoError(t, err)
+
+		err = repo.Quarantine(ctx, entry.Path, "replaced")
+		assert.ErrorIs(t, err, stowry.ErrNotFound)
+
+		_, err = repo.Get(ctx, entry.Path)
+		assert.NoError(t, err)
+	})
+
+	t.Run("re-upload clears the flag", func(t *testing.T) {
+		repo, cleanup := setupTestRepo(t)
+		defer cleanup()
+
+		ctx := context.Background()
+
+		_, _, err := repo.Upsert(ctx, entry)
+		require.NoError(t, err)
+		require.NoError(t, repo.Quarantine(ctx, entry.Path, entry.ETag))
+		_, _, err = repo.Upsert(ctx, entry)
+		require.NoError(t, err)
+		require.NoError(t, repo.Delete(ctx, entry.Path))
+
+		metadata, err := repo.GetDeleted(ctx, entry.Path)
+		require.NoError(t, err)
+		assert.Nil(t, metadata.QuarantinedAt)
+
+		pending, err := repo.ListPendingCleanup(ctx, stowry.ListQuery{Limit: 10})
+		require.NoError(t, err)
+		assert.Len(t, pending.Items, 1)
+	})
+}
+
 func TestRepo_List(t *testing.T) {
 	t.Run("success - lists all entries", func(t *testing.T) {
 		repo, cleanup := setupTestRepo(t)
@@ -594,6 +1091,37 @@ func TestRepo_List(t *testing.T) {
 			assert.Equal(t, "/foo_bar/file.txt", result.Items[0].Path)
 		}
 	})
+
+	t.Run("success - prefix as long as max_key_bytes", func(t *testing.T) {
+		repo, cleanup := setupTestRepo(t)
+		defer cleanup()
+
+		ctx := context.Background()
+
+		// Four 252-byte segments with LIKE wildcards, then a 12-byte file name
+		dir := strings.Repeat(strings.Repeat("d%_", 84)+"/", 4)
+		paths := []string{dir + "name-a.b.txt", dir + "name-b.b.txt"}
+		for _, path := range paths {
+			_, _, err := repo.Upsert(ctx, stowry.ObjectEntry{Path: path, Size: 1, ETag: "etag", ContentType: "text/plain"})
+			require.NoError(t, err, "upsert")
+		}
+
+		prefix := paths[0]
+		require.Len(t, prefix, stowry.DefaultMaxKeyBytes)
+		q := stowry.ListQuery{PathPrefix: prefix, Limit: 1}
+		require.NoError(t, q.Validate(stowry.DefaultPathLimits()))
+
+		result, err := repo.List(ctx, q)
+		require.NoError(t, err)
+		require.Len(t, result.Items, 1)
+		assert.Equal(t, paths[0], result.Items[0].Path)
+
+		// The cursor for the longest path is within the cursor bound
+		result, err = repo.List(ctx, stowry.ListQuery{PathPrefix: dir, Limit: 1})
+		require.NoError(t, err)
+		require.NotEmpty(t, result.NextCursor)
+		assert.NoError(t, stowry.ListQuery{PathPrefix: dir, Cursor: result.NextCursor}.Validate(stowry.DefaultPathLimits()))
+	})
 }
 
 func TestRepo_ListPendingCleanup(t *testing.T) {
@@ -656,6 +1184,26 @@ func TestRepo_ListPendingCleanup(t *testing.T) {
 		}
 	})
 
+	t.Run("success - deleted before filters by delete time", func(t *testing.T) {
+		repo, cleanup := setupTestRepo(t)
+		defer cleanup()
+
+		ctx := context.Background()
+
+		_, _, err := repo.Upsert(ctx, stowry.ObjectEntry{Path: "/file1.txt", Size: 100, ETag: "etag1", ContentType: "text/plain"})
+		require.NoError(t, err)
+		require.NoError(t, repo.Delete(ctx, "/file1.txt"))
+
+		result, err := repo.ListPendingCleanup(ctx, stowry.ListQuery{Limit: 10, DeletedBefore: time.Now().Add(-time.Hour)})
+		require.NoError(t, err)
+		assert.Empty(t, result.Items)
+
+		result, err = repo.ListPendingCleanup(ctx, stowry.ListQuery{Limit: 10, DeletedBefore: time.Now().Add(time.Minute)})
+		require.NoError(t, err)
+		require.Len(t, result.Items, 1)
+		assert.Equal(t, "/file1.txt", result.Items[0].Path)
+	})
+
 	t.Run("success - excludes cleaned up entries", func(t *testing.T) {
 		repo, cleanup := setupTestRepo(t)
 		defer cleanup()
@@ -694,6 +1242,42 @@ func TestRepo_ListPendingCleanup(t *testing.T) {
 	})
 }
 
+func TestRepo_DeferCleanup(t *testing.T) {
+	repo, cleanup := setupTestRepo(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	deferrer, ok := repo.(stowry.CleanupDeferrer)
+	require.True(t, ok, "repo implements stowry.CleanupDeferrer")
+
+	entry := stowry.ObjectEntry{Path: "/file1.txt", Size: 100, ETag: "etag1", ContentType: "text/plain"}
+	metadata, _, err := repo.Upsert(ctx, entry)
+	require.NoError(t, err)
+
+	err = deferrer.DeferCleanup(ctx, metadata.ID, time.Now())
+	assert.ErrorIs(t, err, stowry.ErrNotFound, "active entries are not pending cleanup")
+	err = deferrer.DeferCleanup(ctx, uuid.New(), time.Now())
+	assert.ErrorIs(t, err, stowry.ErrNotFound)
+
+	require.NoError(t, repo.Delete(ctx, entry.Path))
+	until := time.Now().Add(time.Hour).Truncate(time.Microsecond)
+	require.NoError(t, deferrer.DeferCleanup(ctx, metadata.ID, until))
+
+	result, err := repo.ListPendingCleanup(ctx, stowry.ListQuery{PathPrefix: "/", Limit: 10})
+	require.NoError(t, err)
+	require.Len(t, result.Items, 1, "deferred entries are still listed")
+	require.NotNil(t, result.Items[0].CleanupRetryAt)
+	assert.True(t, until.Equal(*result.Items[0].CleanupRetryAt))
+
+	// Writing the path again revives the row and clears the marker
+	_, _, err = repo.Upsert(ctx, entry)
+	require.NoError(t, err)
+	list, err := repo.List(ctx, stowry.ListQuery{PathPrefix: "/", Limit: 10})
+	require.NoError(t, err)
+	require.Len(t, list.Items, 1)
+	assert.Nil(t, list.Items[0].CleanupRetryAt)
+}
+
 func TestRepo_MarkCleanedUp(t *testing.T) {
 	t.Run("success - marks deleted entry as cleaned up", func(t *testing.T) {
 		repo, cleanup := setupTestRepo(t)
@@ -783,3 +1367,1584 @@ func TestRepo_MarkCleanedUp(t *testing.T) {
 		assert.ErrorIs(t, err, stowry.ErrNotFound, "expected ErrNotFound")
 	})
 }
+
+func TestRepo_RecordAccess(t *testing.T) {
+	t.Run("success - accumulates counts and last access", func(t *testing.T) {
+		repo, cleanup := setupTestRepo(t)
+		defer cleanup()
+
+		ctx := context.Background()
+
+		_, _, err := repo.Upsert(ctx, stowry.ObjectEntry{Path: "a.txt", Size: 1, ETag: "e1", ContentType: "text/plain"})
+		assert.NoError(t, err)
+
+		fresh, err := repo.Get(ctx, "a.txt")
+		assert.NoError(t, err)
+		assert.Equal(t, int64(0), fresh.DownloadCount)
+		assert.Nil(t, fresh.LastAccessedAt)
+
+		first := time.Now().UTC().Add(-time.Minute).Truncate(time.Millisecond)
+		err = repo.RecordAccess(ctx, []stowry.AccessRecord{{Path: "a.txt", Count: 3, LastAccessedAt: first}})
+		assert.NoError(t, err)
+
+		second := first.Add(30 * time.Second)
+		err = repo.RecordAccess(ctx, []stowry.AccessRecord{
+			{Path: "a.txt", Count: 2, LastAccessedAt: second},
+			{Path: "missing.txt", Count: 1, LastAccessedAt: second},
+		})
+		assert.NoError(t, err)
+
+		got, err := repo.Get(ctx, "a.txt")
+		assert.NoError(t, err)
+		assert.Equal(t, int64(5), got.DownloadCount)
+		if assert.NotNil(t, got.LastAccessedAt) {
+			assert.True(t, second.Equal(*got.LastAccessedAt), "expected %v, got %v", second, *got.LastAccessedAt)
+		}
+
+		list, err := repo.List(ctx, stowry.ListQuery{Limit: 10})
+		assert.NoError(t, err)
+		if assert.Len(t, list.Items, 1) {
+			assert.Equal(t, int64(5), list.Items[0].DownloadCount)
+		}
+	})
+
+	t.Run("success - empty batch", func(t *testing.T) {
+		repo, cleanup := setupTestRepo(t)
+		defer cleanup()
+
+		assert.NoError(t, repo.RecordAccess(context.Background(), nil))
+	})
+}
+
+func TestRepo_ContentEncoding(t *testing.T) {
+	repo, cleanup := setupTestRepo(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	decoded := int64(300)
+
+	encoded, _, err := repo.Upsert(ctx, stowry.ObjectEntry{Path: "a.json", Size: 40, ETag: "e1", ContentType: "application/json", ContentEncoding: "gzip", DecodedSize: &decoded})
+	assert.NoError(t, err)
+	assert.Equal(t, "gzip", encoded.ContentEncoding)
+	if assert.NotNil(t, encoded.DecodedSizeBytes) {
+		assert.Equal(t, decoded, *encoded.DecodedSizeBytes)
+	}
+
+	got, err := repo.Get(ctx, "a.json")
+	assert.NoError(t, err)
+	assert.Equal(t, "gzip", got.ContentEncoding)
+	if assert.NotNil(t, got.DecodedSizeBytes) {
+		assert.Equal(t, decoded, *got.DecodedSizeBytes)
+	}
+
+	list, err := repo.List(ctx, stowry.ListQuery{Limit: 10})
+	assert.NoError(t, err)
+	if assert.Len(t, list.Items, 1) {
+		assert.Equal(t, "gzip", list.Items[0].ContentEncoding)
+	}
+
+	// Replacing with an unencoded upload clears both fields
+	_, _, err = repo.Upsert(ctx, stowry.ObjectEntry{Path: "a.json", Size: 300, ETag: "e2", ContentType: "application/json"})
+	assert.NoError(t, err)
+	got, err = repo.Get(ctx, "a.json")
+	assert.NoError(t, err)
+	assert.Empty(t, got.ContentEncoding)
+	assert.Nil(t, got.DecodedSizeBytes)
+
+	stages := repo.(stowry.StageRepo)
+	stage, err := stages.CreateStage(ctx)
+	assert.NoError(t, err)
+	staged := stowry.ObjectEntry{Path: "b.json", Size: 40, ETag: "e3", ContentType: "application/json", ContentEncoding: "gzip", DecodedSize: &decoded}
+	assert.NoError(t, stages.AddStagedObject(ctx, stage.ID, staged))
+
+	entries, err := stages.StagedObjects(ctx, stage.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, []stowry.ObjectEntry{staged}, entries)
+
+	_, err = stages.CommitStage(ctx, stage.ID)
+	assert.NoError(t, err)
+	got, err = repo.Get(ctx, "b.json")
+	assert.NoError(t, err)
+	assert.Equal(t, "gzip", got.ContentEncoding)
+	if assert.NotNil(t, got.DecodedSizeBytes) {
+		assert.Equal(t, decoded, *got.DecodedSizeBytes)
+	}
+}
+
+func TestRepo_GetByETag(t *testing.T) {
+	repo, cleanup := setupTestRepo(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	shared := strings.Repeat("a", 64)
+	other := strings.Repeat("b", 64)
+
+	upsert := func(path, etag string) {
+		t.Helper()
+		// Keep updated_at of consecutive writes apart
+		time.Sleep(2 * time.Millisecond)
+		_, _, err := repo.Upsert(ctx, stowry.ObjectEntry{Path: path, Size: 1, ETag: etag, ContentType: "text/plain"})
+		require.NoError(t, err)
+	}
+	pathOf := func(etag string) string {
+		t.Helper()
+		m, err := repo.GetByETag(ctx, etag)
+		require.NoError(t, err)
+		assert.Equal(t, etag, m.Etag)
+		return m.Path
+	}
+
+	_, err := repo.GetByETag(ctx, shared)
+	assert.ErrorIs(t, err, stowry.ErrNotFound)
+
+	upsert("a.txt", shared)
+	upsert("b.txt", shared)
+	assert.Equal(t, "b.txt", pathOf(shared), "the most recently updated object wins")
+
+	upsert("a.txt", shared)
+	assert.Equal(t, "a.txt", pathOf(shared), "rewriting the same content updates it")
+
+	require.NoError(t, repo.Delete(ctx, "a.txt"))
+	assert.Equal(t, "b.txt", pathOf(shared), "deleted objects are skipped")
+
+	upsert("b.txt", other)
+	assert.Equal(t, "b.txt", pathOf(other))
+	_, err = repo.GetByETag(ctx, shared)
+	assert.ErrorIs(t, err, stowry.ErrNotFound, "overwritten content has no object")
+}
+
+func TestRepo_GetMany(t *testing.T) {
+	repo, cleanup := setupTestRepo(t)
+	defer cleanup()
+
+	ctx := context.Background()
+
+	want := make(map[string]stowry.MetaData)
+	var paths []string
+	for i := range 600 {
+		entry := stowry.ObjectEntry{Path: fmt.Sprintf("live/%03d.txt", i), Size: int64(i), ETag: fmt.Sprintf("etag-%d", i), ContentType: "text/plain"}
+		m, _, err := repo.Upsert(ctx, entry)
+		assert.NoError(t, err, "upsert")
+		want[m.Path] = m
+		paths = append(paths, m.Path)
+	}
+
+	_, _, err := repo.Upsert(ctx, stowry.ObjectEntry{Path: "deleted.txt", Size: 1, ETag: "etag", ContentType: "text/plain"})
+	assert.NoError(t, err, "upsert")
+	assert.NoError(t, repo.Delete(ctx, "deleted.txt"), "delete")
+
+	got, err := repo.GetMany(ctx, append([]string{"missing.txt", "deleted.txt"}, paths...))
+	assert.NoError(t, err)
+	assert.Len(t, got, len(want))
+	for _, m := range got {
+		expected, ok := want[m.Path]
+		if assert.True(t, ok, "unexpected path %s", m.Path) {
+			assert.Equal(t, expected.ID, m.ID)
+			assert.Equal(t, expected.Etag, m.Etag)
+			assert.Equal(t, expected.FileSizeBytes, m.FileSizeBytes)
+			assert.True(t, expected.UpdatedAt.Equal(m.UpdatedAt))
+		}
+	}
+
+	got, err = repo.GetMany(ctx, nil)
+	assert.NoError(t, err)
+	assert.Empty(t, got)
+}
+
+func TestRepo_ExistingPaths(t *testing.T) {
+	repo, cleanup := setupTestRepo(t)
+	defer cleanup()
+
+	ctx := context.Background()
+
+	var live []string
+	for i := range 600 {
+		entry := stowry.ObjectEntry{Path: fmt.Sprintf("live/%03d.txt", i), Size: 1, ETag: "etag", ContentType: "text/plain"}
+		_, _, err := repo.Upsert(ctx, entry)
+		assert.NoError(t, err, "upsert")
+		live = append(live, entry.Path)
+	}
+
+	_, _, err := repo.Upsert(ctx, stowry.ObjectEntry{Path: "deleted.txt", Size: 1, ETag: "etag", ContentType: "text/plain"})
+	assert.NoError(t, err, "upsert")
+	assert.NoError(t, repo.Delete(ctx, "deleted.txt"), "delete")
+
+	cleaned, _, err := repo.Upsert(ctx, stowry.ObjectEntry{Path: "cleaned.txt", Size: 1, ETag: "etag", ContentType: "text/plain"})
+	assert.NoError(t, err, "upsert")
+	assert.NoError(t, repo.Delete(ctx, "cleaned.txt"), "delete")
+	assert.NoError(t, repo.MarkCleanedUp(ctx, cleaned.ID), "mark cleaned up")
+
+	query := append([]string{"missing.txt", "deleted.txt", "cleaned.txt"}, live...)
+	got, err := repo.ExistingPaths(ctx, query)
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, append([]string{"deleted.txt"}, live...), got)
+
+	got, err = repo.ExistingPaths(ctx, nil)
+	assert.NoError(t, err)
+	assert.Empty(t, got)
+}
+
+func TestRepo_CountPrefix(t *testing.T) {
+	repo, cleanup := setupTestRepo(t)
+	defer cleanup()
+
+	ctx := context.Background()
+
+	for _, path := range []string{"cache/a.txt", "cache/b.txt", "cache/sub/c.txt", "cache_x/d.txt", "other.txt", "Cache/e.txt", `cache\f.txt`} {
+		_, _, err := repo.Upsert(ctx, stowry.ObjectEntry{Path: path, Size: 1, ETag: "etag", ContentType: "text/plain"})
+		assert.NoError(t, err, "upsert")
+	}
+	assert.NoError(t, repo.Delete(ctx, "cache/b.txt"), "delete")
+
+	tests := []struct {
+		prefix string
+		want   int64
+	}{
+		{prefix: "", want: 6},
+		{prefix: "cache/", want: 2},
+		{prefix: "cache/sub/", want: 1},
+		{prefix: "cache_", want: 1}, // _ is matched literally, not as a wildcard
+		{prefix: "missing/", want: 0},
+		{prefix: "Cache/", want: 1}, // case-sensitive, unlike SQLite's LIKE
+		{prefix: `cache\`, want: 1},
+	}
+	for _, tt := range tests {
+		got, err := repo.CountPrefix(ctx, tt.prefix)
+		assert.NoError(t, err, "count %q", tt.prefix)
+		assert.Equal(t, tt.want, got, "count %q", tt.prefix)
+	}
+}
+
+func TestRepo_Ping(t *testing.T) {
+	repo, cleanup := setupTestRepo(t)
+
+	pinger, ok := repo.(stowry.Pinger)
+	require.True(t, ok, "repo implements stowry.Pinger")
+	assert.NoError(t, pinger.Ping(context.Background()))
+
+	cleanup()
+	assert.Error(t, pinger.Ping(context.Background()), "ping after close")
+}
+
+func TestRepo_Stage(t *testing.T) {
+	ctx := context.Background()
+	entry := func(path, etag string) stowry.ObjectEntry {
+		return stowry.ObjectEntry{Path: path, Size: int64(len(etag)), ETag: etag, ContentType: "text/plain"}
+	}
+
+	t.Run("commit promotes staged objects and soft-deletes replaced ones", func(t *testing.T) {
+		repo, cleanup := setupTestRepo(t)
+		defer cleanup()
+		stages := repo.(stowry.StageRepo)
+
+		old, _, err := repo.Upsert(ctx, entry("site/index.html", "old"))
+		assert.NoError(t, err)
+
+		stage, err := stages.CreateStage(ctx)
+		assert.NoError(t, err)
+		assert.NoError(t, stages.AddStagedObject(ctx, stage.ID, entry("site/index.html", "first")))
+		assert.NoError(t, stages.AddStagedObject(ctx, stage.ID, entry("site/index.html", "new")))
+		assert.NoError(t, stages.AddStagedObject(ctx, stage.ID, entry("site/app.js", "js")))
+
+		staged, err := stages.StagedObjects(ctx, stage.ID)
+		assert.NoError(t, err)
+		assert.Equal(t, []stowry.ObjectEntry{entry("site/app.js", "js"), entry("site/index.html", "new")}, staged)
+
+		// Nothing is visible before the commit
+		got, err := repo.Get(ctx, "site/index.html")
+		assert.NoError(t, err)
+		assert.Equal(t, "old", got.Etag)
+		_, err = repo.Get(ctx, "site/app.js")
+		assert.ErrorIs(t, err, stowry.ErrNotFound)
+
+		committed, err := stages.CommitStage(ctx, stage.ID)
+		assert.NoError(t, err)
+		assert.Len(t, committed, 2)
+		assert.Equal(t, "site/app.js", committed[0].Path)
+		assert.Equal(t, "new", committed[1].Etag)
+		assert.NotEqual(t, old.ID, committed[1].ID)
+
+		got, err = repo.Get(ctx, "site/index.html")
+		assert.NoError(t, err)
+		assert.Equal(t, "new", got.Etag)
+
+		pending, err := repo.ListPendingCleanup(ctx, stowry.ListQuery{Limit: 10})
+		assert.NoError(t, err)
+		assert.Len(t, pending.Items, 1)
+		assert.Equal(t, old.ID, pending.Items[0].ID)
+		assert.Equal(t, stowry.ReplacedPath(stage.ID, "site/index.html"), pending.Items[0].Path)
+
+		_, err = stages.StagedObjects(ctx, stage.ID)
+		assert.ErrorIs(t, err, stowry.ErrNotFound)
+	})
+
+	t.Run("commit replaces soft-deleted rows", func(t *testing.T) {
+		repo, cleanup := setupTestRepo(t)
+		defer cleanup()
+		stages := repo.(stowry.StageRepo)
+
+		_, _, err := repo.Upsert(ctx, entry("a.txt", "old"))
+		assert.NoError(t, err)
+		assert.NoError(t, repo.Delete(ctx, "a.txt"))
+
+		stage, err := stages.CreateStage(ctx)
+		assert.NoError(t, err)
+		assert.NoError(t, stages.AddStagedObject(ctx, stage.ID, entry("a.txt", "new")))
+
+		_, err = stages.CommitStage(ctx, stage.ID)
+		assert.NoError(t, err)
+
+		pending, err := repo.ListPendingCleanup(ctx, stowry.ListQuery{Limit: 10})
+		assert.NoError(t, err)
+		assert.Len(t, pending.Items, 1)
+		assert.Equal(t, stowry.ReplacedPath(stage.ID, "a.txt"), pending.Items[0].Path)
+	})
+
+	t.Run("delete discards the stage", func(t *testing.T) {
+		repo, cleanup := setupTestRepo(t)
+		defer cleanup()
+		stages := repo.(stowry.StageRepo)
+
+		stage, err := stages.CreateStage(ctx)
+		assert.NoError(t, err)
+		assert.NoError(t, stages.AddStagedObject(ctx, stage.ID, entry("a.txt", "new")))
+
+		assert.NoError(t, stages.DeleteStage(ctx, stage.ID))
+		assert.ErrorIs(t, stages.DeleteStage(ctx, stage.ID), stowry.ErrNotFound)
+		_, err = repo.Get(ctx, "a.txt")
+		assert.ErrorIs(t, err, stowry.ErrNotFound)
+	})
+
+	t.Run("unknown stage", func(t *testing.T) {
+		repo, cleanup := setupTestRepo(t)
+		defer cleanup()
+		stages := repo.(stowry.StageRepo)
+
+		id := uuid.New()
+		assert.ErrorIs(t, stages.AddStagedObject(ctx, id, entry("a.txt", "new")), stowry.ErrNotFound)
+		_, err := stages.StagedObjects(ctx, id)
+		assert.ErrorIs(t, err, stowry.ErrNotFound)
+		_, err = stages.CommitStage(ctx, id)
+		assert.ErrorIs(t, err, stowry.ErrNotFound)
+		assert.ErrorIs(t, stages.DeleteStage(ctx, id), stowry.ErrNotFound)
+	})
+}
+
+func TestRepo_Rename(t *testing.T) {
+	ctx := context.Background()
+	entry := func(path, etag string) stowry.ObjectEntry {
+		return stowry.ObjectEntry{Path: path, Size: int64(len(etag)), ETag: etag, ContentType: "text/plain"}
+	}
+
+	t.Run("moves the entry and records the change", func(t *testing.T) {
+		repo, cleanup := setupTestRepo(t)
+		defer cleanup()
+		renamer := repo.(stowry.RenameRepo)
+
+		old, _, err := repo.Upsert(ctx, entry("tmp/a.txt", "a1"))
+		assert.NoError(t, err)
+
+		moved, err := renamer.Rename(ctx, "tmp/a.txt", "docs/a.txt", "")
+		assert.NoError(t, err)
+		assert.Equal(t, old.ID, moved.ID)
+		assert.Equal(t, "docs/a.txt", moved.Path)
+		assert.Equal(t, "a1", moved.Etag)
+
+		_, err = repo.Get(ctx, "tmp/a.txt")
+		assert.ErrorIs(t, err, stowry.ErrNotFound)
+		got, err := repo.Get(ctx, "docs/a.txt")
+		assert.NoError(t, err)
+		assert.Equal(t, old.ID, got.ID)
+
+		batch, err := repo.(stowry.ChangeFeed).Changes(ctx, stowry.ChangeQuery{})
+		assert.NoError(t, err)
+		assert.Len(t, batch.Changes, 3)
+		assert.Equal(t, stowry.ChangeDelete, batch.Changes[1].Kind)
+		assert.Equal(t, "tmp/a.txt", batch.Changes[1].Path)
+		assert.Equal(t, stowry.ChangeCreate, batch.Changes[2].Kind)
+		assert.Equal(t, "docs/a.txt", batch.Changes[2].Path)
+	})
+
+	t.Run("records the backend of the new path", func(t *testing.T) {
+		repo, cleanup := setupTestRepo(t)
+		defer cleanup()
+
+		first := entry("fast/a.txt", "a1")
+		first.StorageBackend = "fast/"
+		_, _, err := repo.Upsert(ctx, first)
+		assert.NoError(t, err)
+
+		moved, err := repo.(stowry.RenameRepo).Rename(ctx, "fast/a.txt", "a.txt", "")
+		assert.NoError(t, err)
+		assert.Empty(t, moved.StorageBackend)
+
+		got, err := repo.Get(ctx, "a.txt")
+		assert.NoError(t, err)
+		assert.Empty(t, got.StorageBackend)
+	})
+
+	t.Run("active destination already exists", func(t *testing.T) {
+		repo, cleanup := setupTestRepo(t)
+		defer cleanup()
+
+		_, _, err := repo.Upsert(ctx, entry("a.txt", "a1"))
+		assert.NoError(t, err)
+		_, _, err = repo.Upsert(ctx, entry("b.txt", "b1"))
+		assert.NoError(t, err)
+
+		_, err = repo.(stowry.RenameRepo).Rename(ctx, "a.txt", "b.txt", "")
+		assert.ErrorIs(t, err, stowry.ErrAlreadyExists)
+
+		got, err := repo.Get(ctx, "a.txt")
+		assert.NoError(t, err)
+		assert.Equal(t, "a1", got.Etag)
+	})
+
+	t.Run("soft-deleted destination is replaced", func(t *testing.T) {
+		repo, cleanup := setupTestRepo(t)
+		defer cleanup()
+
+		_, _, err := repo.Upsert(ctx, entry("a.txt", "a1"))
+		assert.NoError(t, err)
+		_, _, err = repo.Upsert(ctx, entry("b.txt", "b1"))
+		assert.NoError(t, err)
+		assert.NoError(t, repo.Delete(ctx, "b.txt"))
+
+		moved, err := repo.(stowry.RenameRepo).Rename(ctx, "a.txt", "b.txt", "")
+		assert.NoError(t, err)
+		assert.Equal(t, "a1", moved.Etag)
+
+		pending, err := repo.ListPendingCleanup(ctx, stowry.ListQuery{Limit: 10})
+		assert.NoError(t, err)
+		assert.Empty(t, pending.Items)
+	})
+
+	t.Run("missing or soft-deleted source", func(t *testing.T) {
+		repo, cleanup := setupTestRepo(t)
+		defer cleanup()
+		renamer := repo.(stowry.RenameRepo)
+
+		_, _, err := repo.Upsert(ctx, entry("a.txt", "a1"))
+		assert.NoError(t, err)
+		assert.NoError(t, repo.Delete(ctx, "a.txt"))
+
+		_, err = renamer.Rename(ctx, "a.txt", "b.txt", "")
+		assert.ErrorIs(t, err, stowry.ErrNotFound)
+		_, err = renamer.Rename(ctx, "missing.txt", "b.txt", "")
+		assert.ErrorIs(t, err, stowry.ErrNotFound)
+
+		deleted, err := repo.GetDeleted(ctx, "a.txt")
+		assert.NoError(t, err)
+		assert.Equal(t, "a1", deleted.Etag)
+	})
+}
+
+func TestRepo_DeleteHard(t *testing.T) {
+	ctx := context.Background()
+	entry := func(path, etag string) stowry.ObjectEntry {
+		return stowry.ObjectEntry{Path: path, Size: int64(len(etag)), ETag: etag, ContentType: "text/plain"}
+	}
+
+	t.Run("removes an active entry and records the delete", func(t *testing.T) {
+		repo, cleanup := setupTestRepo(t)
+		defer cleanup()
+
+		created, _, err := repo.Upsert(ctx, entry("a.txt", "a1"))
+		assert.NoError(t, err)
+
+		purged, err := repo.(stowry.PurgeRepo).DeleteHard(ctx, "a.txt")
+		assert.NoError(t, err)
+		assert.Equal(t, created.ID, purged.ID)
+		assert.Equal(t, "a1", purged.Etag)
+		assert.Nil(t, purged.DeletedAt)
+
+		_, err = repo.Get(ctx, "a.txt")
+		assert.ErrorIs(t, err, stowry.ErrNotFound)
+		_, err = repo.GetDeleted(ctx, "a.txt")
+		assert.ErrorIs(t, err, stowry.ErrNotFound)
+
+		batch, err := repo.(stowry.ChangeFeed).Changes(ctx, stowry.ChangeQuery{})
+		assert.NoError(t, err)
+		assert.Len(t, batch.Changes, 2)
+		assert.Equal(t, stowry.ChangeDelete, batch.Changes[1].Kind)
+		assert.Equal(t, "a.txt", batch.Changes[1].Path)
+	})
+
+	t.Run("removes a soft-deleted entry", func(t *testing.T) {
+		repo, cleanup := setupTestRepo(t)
+		defer cleanup()
+
+		_, _, err := repo.Upsert(ctx, entry("a.txt", "a1"))
+		assert.NoError(t, err)
+		assert.NoError(t, repo.Delete(ctx, "a.txt"))
+
+		purged, err := repo.(stowry.PurgeRepo).DeleteHard(ctx, "a.txt")
+		assert.NoError(t, err)
+		assert.NotNil(t, purged.DeletedAt)
+
+		_, err = repo.GetDeleted(ctx, "a.txt")
+		assert.ErrorIs(t, err, stowry.ErrNotFound)
+		pending, err := repo.ListPendingCleanup(ctx, stowry.ListQuery{Limit: 10})
+		assert.NoError(t, err)
+		assert.Empty(t, pending.Items)
+
+		// The delete was recorded when it happened
+		batch, err := repo.(stowry.ChangeFeed).Changes(ctx, stowry.ChangeQuery{})
+		assert.NoError(t, err)
+		assert.Len(t, batch.Changes, 2)
+	})
+
+	t.Run("missing path", func(t *testing.T) {
+		repo, cleanup := setupTestRepo(t)
+		defer cleanup()
+
+		_, err := repo.(stowry.PurgeRepo).DeleteHard(ctx, "missing.txt")
+		assert.ErrorIs(t, err, stowry.ErrNotFound)
+	})
+}
+
+func TestRepo_Restore(t *testing.T) {
+	ctx := context.Background()
+	entry := func(path, etag string) stowry.ObjectEntry {
+		return stowry.ObjectEntry{Path: path, Size: int64(len(etag)), ETag: etag, ContentType: "text/plain"}
+	}
+
+	t.Run("clears deleted_at and records a create", func(t *testing.T) {
+		repo, cleanup := setupTestRepo(t)
+		defer cleanup()
+
+		created, _, err := repo.Upsert(ctx, entry("a.txt", "a1"))
+		assert.NoError(t, err)
+		assert.NoError(t, repo.Delete(ctx, "a.txt"))
+
+		pending, err := repo.ListPendingCleanup(ctx, stowry.ListQuery{Limit: 10})
+		assert.NoError(t, err)
+		assert.Len(t, pending.Items, 1)
+		assert.NotNil(t, pending.Items[0].DeletedAt)
+
+		restored, err := repo.Restore(ctx, "a.txt")
+		assert.NoError(t, err)
+		assert.Equal(t, created.ID, restored.ID)
+		assert.Equal(t, "a1", restored.Etag)
+		assert.Nil(t, restored.DeletedAt)
+
+		got, err := repo.Get(ctx, "a.txt")
+		assert.NoError(t, err)
+		assert.Equal(t, created.ID, got.ID)
+		pending, err = repo.ListPendingCleanup(ctx, stowry.ListQuery{Limit: 10})
+		assert.NoError(t, err)
+		assert.Empty(t, pending.Items)
+
+		batch, err := repo.(stowry.ChangeFeed).Changes(ctx, stowry.ChangeQuery{})
+		assert.NoError(t, err)
+		assert.Len(t, batch.Changes, 3)
+		assert.Equal(t, stowry.ChangeCreate, batch.Changes[2].Kind)
+		assert.Equal(t, "a1", batch.Changes[2].ETag)
+	})
+
+	t.Run("active entry", func(t *testing.T) {
+		repo, cleanup := setupTestRepo(t)
+		defer cleanup()
+
+		_, _, err := repo.Upsert(ctx, entry("a.txt", "a1"))
+		assert.NoError(t, err)
+
+		_, err = repo.Restore(ctx, "a.txt")
+		assert.ErrorIs(t, err, stowry.ErrAlreadyExists)
+	})
+
+	t.Run("cleaned up entry", func(t *testing.T) {
+		repo, cleanup := setupTestRepo(t)
+		defer cleanup()
+
+		created, _, err := repo.Upsert(ctx, entry("a.txt", "a1"))
+		assert.NoError(t, err)
+		assert.NoError(t, repo.Delete(ctx, "a.txt"))
+		assert.NoError(t, repo.MarkCleanedUp(ctx, created.ID))
+
+		_, err = repo.Restore(ctx, "a.txt")
+		assert.ErrorIs(t, err, stowry.ErrNotFound)
+	})
+
+	t.Run("quarantined entry", func(t *testing.T) {
+		repo, cleanup := setupTestRepo(t)
+		defer cleanup()
+
+		_, _, err := repo.Upsert(ctx, entry("a.txt", "a1"))
+		assert.NoError(t, err)
+		assert.NoError(t, repo.Quarantine(ctx, "a.txt", "a1"))
+
+		_, err = repo.Restore(ctx, "a.txt")
+		assert.ErrorIs(t, err, stowry.ErrNotFound)
+	})
+
+	t.Run("missing path", func(t *testing.T) {
+		repo, cleanup := setupTestRepo(t)
+		defer cleanup()
+
+		_, err := repo.Restore(ctx, "missing.txt")
+		assert.ErrorIs(t, err, stowry.ErrNotFound)
+	})
+}
+
+func TestRepo_InstanceID(t *testing.T) {
+	ctx := context.Background()
+	repo, cleanup := setupTestRepo(t)
+	defer cleanup()
+	instances := repo.(stowry.InstanceRepo)
+
+	_, err := instances.InstanceID(ctx)
+	assert.ErrorIs(t, err, stowry.ErrNotFound)
+
+	assert.NoError(t, instances.SetInstanceID(ctx, "first"))
+	assert.NoError(t, instances.SetInstanceID(ctx, "second"))
+
+	id, err := instances.InstanceID(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, "second", id)
+}
+
+func TestRepo_Changes(t *testing.T) {
+	ctx := context.Background()
+	entry := func(path, etag string) stowry.ObjectEntry {
+		return stowry.ObjectEntry{Path: path, Size: int64(len(etag)), ETag: etag, ContentType: "text/plain"}
+	}
+
+	t.Run("creates and deletes are recorded in order", func(t *testing.T) {
+		repo, cleanup := setupTestRepo(t)
+		defer cleanup()
+		feed := repo.(stowry.ChangeFeed)
+
+		latest, err := feed.Changes(ctx, stowry.ChangeQuery{Since: -1})
+		assert.NoError(t, err)
+		assert.Equal(t, int64(0), latest.Next)
+
+		_, _, err = repo.Upsert(ctx, entry("docs/a.txt", "a1"))
+		assert.NoError(t, err)
+		_, err = repo.UpsertBatch(ctx, []stowry.ObjectEntry{entry("img/b.png", "b1"), entry("docs/c.txt", "c1")})
+		assert.NoError(t, err)
+		assert.NoError(t, repo.Delete(ctx, "docs/a.txt"))
+		assert.ErrorIs(t, repo.Delete(ctx, "docs/a.txt"), stowry.ErrNotFound)
+
+		all, err := feed.Changes(ctx, stowry.ChangeQuery{Since: 0, Limit: 10})
+		assert.NoError(t, err)
+		assert.Len(t, all.Changes, 4)
+		assert.Equal(t, int64(4), all.Next)
+		assert.Equal(t, stowry.Change{Seq: 4, Kind: stowry.ChangeDelete, Path: "docs/a.txt", ETag: "a1", Size: 2, At: all.Changes[3].At}, all.Changes[3])
+		assert.False(t, all.Changes[3].At.IsZero())
+
+		docs, err := feed.Changes(ctx, stowry.ChangeQuery{PathPrefix: "docs/", Since: 0, Limit: 2})
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"docs/a.txt", "docs/c.txt"}, []string{docs.Changes[0].Path, docs.Changes[1].Path})
+		assert.Equal(t, int64(3), docs.Next, "a full page continues after its last change")
+
+		rest, err := feed.Changes(ctx, stowry.ChangeQuery{PathPrefix: "docs/", Since: docs.Next, Limit: 2})
+		assert.NoError(t, err)
+		assert.Len(t, rest.Changes, 1)
+		assert.Equal(t, int64(4), rest.Next)
+
+		caughtUp, err := feed.Changes(ctx, stowry.ChangeQuery{Since: 4})
+		assert.NoError(t, err)
+		assert.Empty(t, caughtUp.Changes)
+		assert.Equal(t, int64(4), caughtUp.Next)
+	})
+
+	t.Run("overwrites record the previous etag", func(t *testing.T) {
+		repo, cleanup := setupTestRepo(t)
+		defer cleanup()
+
+		_, _, err := repo.Upsert(ctx, entry("a.txt", "a1"))
+		assert.NoError(t, err)
+		_, _, err = repo.Upsert(ctx, entry("a.txt", "a2"))
+		assert.NoError(t, err)
+		assert.NoError(t, repo.Delete(ctx, "a.txt"))
+		_, _, err = repo.Upsert(ctx, entry("a.txt", "a3"))
+		assert.NoError(t, err)
+
+		batch, err := repo.(stowry.ChangeFeed).Changes(ctx, stowry.ChangeQuery{})
+		assert.NoError(t, err)
+		previous := make([]string, len(batch.Changes))
+		for i, c := range batch.Changes {
+			previous[i] = c.PreviousETag
+		}
+		assert.Equal(t, []string{"", "a1", "", ""}, previous)
+	})
+
+	t.Run("stage commits are recorded", func(t *testing.T) {
+		repo, cleanup := setupTestRepo(t)
+		defer cleanup()
+		stages := repo.(stowry.StageRepo)
+
+		stage, err := stages.CreateStage(ctx)
+		assert.NoError(t, err)
+		assert.NoError(t, stages.AddStagedObject(ctx, stage.ID, entry("site/index.html", "new")))
+		_, err = stages.CommitStage(ctx, stage.ID)
+		assert.NoError(t, err)
+
+		batch, err := repo.(stowry.ChangeFeed).Changes(ctx, stowry.ChangeQuery{})
+		assert.NoError(t, err)
+		assert.Len(t, batch.Changes, 1)
+		assert.Equal(t, stowry.ChangeCreate, batch.Changes[0].Kind)
+		assert.Equal(t, "site/index.html", batch.Changes[0].Path)
+	})
+
+	t.Run("history reads one path with its actors", func(t *testing.T) {
+		repo, cleanup := setupTestRepo(t)
+		defer cleanup()
+		hist := repo.(stowry.ChangeHistory)
+
+		alice := stowry.WithActor(ctx, "AKALICE")
+		_, _, err := repo.Upsert(alice, entry("a.txt", "a1"))
+		assert.NoError(t, err)
+		_, _, err = repo.Upsert(ctx, entry("a.txt.bak", "b1"))
+		assert.NoError(t, err)
+		_, _, err = repo.Upsert(stowry.WithActor(ctx, "AKBOB"), entry("a.txt", "a2"))
+		assert.NoError(t, err)
+		assert.NoError(t, repo.Delete(alice, "a.txt"))
+		_, _, err = repo.Upsert(alice, entry("a.txt", "a3"))
+		assert.NoError(t, err)
+
+		first, err := hist.History(ctx, stowry.HistoryQuery{Path: "a.txt", Limit: 2})
+		assert.NoError(t, err)
+		assert.Equal(t, "a.txt", first.Path)
+		assert.Equal(t, []stowry.Change{
+			{Seq: 1, Kind: stowry.ChangeCreate, Path: "a.txt", ETag: "a1", Size: 2, At: first.Changes[0].At, Actor: "AKALICE"},
+			{Seq: 3, Kind: stowry.ChangeCreate, Path: "a.txt", ETag: "a2", Size: 2, At: first.Changes[1].At, PreviousETag: "a1", Actor: "AKBOB"},
+		}, first.Changes)
+		assert.Equal(t, int64(3), first.Next)
+
+		rest, err := hist.History(ctx, stowry.HistoryQuery{Path: "a.txt", After: first.Next, Limit: 2})
+		assert.NoError(t, err)
+		assert.Equal(t, []stowry.Change{
+			{Seq: 4, Kind: stowry.ChangeDelete, Path: "a.txt", ETag: "a2", Size: 2, At: rest.Changes[0].At, Actor: "AKALICE"},
+			{Seq: 5, Kind: stowry.ChangeCreate, Path: "a.txt", ETag: "a3", Size: 2, At: rest.Changes[1].At, Actor: "AKALICE"},
+		}, rest.Changes, "reusing a deleted path is a create without a previous etag")
+		assert.Zero(t, rest.Next, "the last page has no cursor")
+
+		none, err := hist.History(ctx, stowry.HistoryQuery{Path: "missing.txt"})
+		assert.NoError(t, err)
+		assert.Empty(t, none.Changes)
+
+		batch, err := repo.(stowry.ChangeFeed).Changes(ctx, stowry.ChangeQuery{})
+		assert.NoError(t, err)
+		assert.Empty(t, batch.Changes[1].Actor, "changes without an actor record none")
+	})
+
+	t.Run("pruning keeps the newest change and expires old cursors", func(t *testing.T) {
+		repo, cleanup := setupTestRepo(t)
+		defer cleanup()
+		feed := repo.(stowry.ChangeFeed)
+
+		for _, path := range []string{"a", "b", "c"} {
+			_, _, err := repo.Upsert(ctx, entry(path, "x"))
+			assert.NoError(t, err)
+		}
+
+		pruned, err := feed.PruneChanges(ctx, time.Now().Add(time.Minute))
+		assert.NoError(t, err)
+		assert.Equal(t, int64(2), pruned)
+
+		_, err = feed.Changes(ctx, stowry.ChangeQuery{Since: 1})
+		assert.ErrorIs(t, err, stowry.ErrCursorExpired)
+		_, err = feed.Changes(ctx, stowry.ChangeQuery{Since: 9})
+		assert.ErrorIs(t, err, stowry.ErrCursorExpired)
+
+		batch, err := feed.Changes(ctx, stowry.ChangeQuery{Since: 2})
+		assert.NoError(t, err)
+		assert.Len(t, batch.Changes, 1)
+		assert.Equal(t, "c", batch.Changes[0].Path)
+	})
+}
+
+func TestRepo_Usage(t *testing.T) {
+	ctx := context.Background()
+	entry := func(path, etag string) stowry.ObjectEntry {
+		return stowry.ObjectEntry{Path: path, Size: int64(len(etag)), ETag: etag, ContentType: "text/plain"}
+	}
+
+	repo, cleanup := setupTestRepo(t)
+	defer cleanup()
+	usage := repo.(stowry.UsageRepo)
+
+	_, err := usage.UsageSnapshotAt(ctx, time.Now())
+	assert.ErrorIs(t, err, stowry.ErrNotFound)
+
+	first, err := usage.TakeUsageSnapshot(ctx)
+	require.NoError(t, err)
+	assert.True(t, first.Complete)
+	assert.Empty(t, first.Prefixes)
+
+	for _, e := range []stowry.ObjectEntry{entry("team-a/x", "a1"), entry("team-a/y", "a2"), entry("readme", "r1"), entry("team-b/z", "b1")} {
+		_, _, err := repo.Upsert(ctx, e)
+		require.NoError(t, err)
+	}
+	second, err := usage.TakeUsageSnapshot(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []stowry.PrefixUsage{
+		{Prefix: "", Objects: 1, Bytes: 2, ObjectsAdded: 1, BytesAdded: 2},
+		{Prefix: "team-a/", Objects: 2, Bytes: 4, ObjectsAdded: 2, BytesAdded: 4},
+		{Prefix: "team-b/", Objects: 1, Bytes: 2, ObjectsAdded: 1, BytesAdded: 2},
+	}, second.Prefixes)
+
+	// An overwrite adds the new version; the emptied prefix keeps its counters
+	_, _, err = repo.Upsert(ctx, entry("team-a/x", "a1-v2"))
+	require.NoError(t, err)
+	require.NoError(t, repo.Delete(ctx, "team-b/z"))
+	third, err := usage.TakeUsageSnapshot(ctx)
+	require.NoError(t, err)
+	assert.True(t, third.Complete)
+	assert.Equal(t, []stowry.PrefixUsage{
+		{Prefix: "", Objects: 1, Bytes: 2, ObjectsAdded: 1, BytesAdded: 2},
+		{Prefix: "team-a/", Objects: 2, Bytes: 7, ObjectsAdded: 3, BytesAdded: 9},
+		{Prefix: "team-b/", Objects: 0, Bytes: 0, ObjectsAdded: 1, BytesAdded: 2},
+	}, third.Prefixes)
+
+	at, err := usage.UsageSnapshotAt(ctx, time.Now())
+	require.NoError(t, err)
+	assert.Equal(t, third.ID, at.ID)
+	assert.True(t, third.TakenAt.Equal(at.TakenAt))
+	assert.Equal(t, third.Prefixes, at.Prefixes)
+
+	at, err = usage.UsageSnapshotAt(ctx, second.TakenAt)
+	require.NoError(t, err)
+	assert.Equal(t, second.ID, at.ID)
+
+	listed, err := usage.ListUsageSnapshots(ctx, second.TakenAt, time.Now())
+	require.NoError(t, err)
+	require.Len(t, listed, 2)
+	assert.Equal(t, []int64{second.ID, third.ID}, []int64{listed[0].ID, listed[1].ID})
+	assert.Nil(t, listed[0].Prefixes, "listed without prefixes")
+
+	// Changes pruned before a snapshot reads them make it incomplete
+	for _, path := range []string{"team-c/1", "team-c/2"} {
+		_, _, err := repo.Upsert(ctx, entry(path, "c"))
+		require.NoError(t, err)
+	}
+	_, err = repo.(stowry.ChangeFeed).PruneChanges(ctx, time.Now().Add(time.Minute))
+	require.NoError(t, err)
+	fourth, err := usage.TakeUsageSnapshot(ctx)
+	require.NoError(t, err)
+	assert.False(t, fourth.Complete)
+	assert.Equal(t, stowry.PrefixUsage{Prefix: "team-c/", Objects: 2, Bytes: 2, ObjectsAdded: 1, BytesAdded: 1}, fourth.Prefixes[3])
+}
+
+func TestDatabase_Backup(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	tables := stowry.Tables{MetaData: "metadata"}
+
+	db, err := sqlite.Connect(ctx, filepath.Join(dir, "stowry.db"), tables)
+	require.NoError(t, err)
+	defer func() { _ = db.Close() }()
+	require.NoError(t, db.Migrate(ctx))
+	repo := db.GetRepo()
+
+	_, _, err = repo.Upsert(ctx, stowry.ObjectEntry{Path: "before.txt", ContentType: "text/plain", ETag: "a", Size: 1})
+	require.NoError(t, err)
+
+	// Writers keep going while snapshots are taken
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+	var writes atomic.Int64
+	writeErrs := make(chan error, 4)
+	for w := range 4 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; ; i++ {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				path := fmt.Sprintf("writer%d/%d.txt", w, i)
+				if _, _, err := repo.Upsert(ctx, stowry.ObjectEntry{Path: path, ContentType: "text/plain", ETag: "b", Size: 2}); err != nil {
+					writeErrs <- err
+					return
+				}
+				writes.Add(1)
+			}
+		}()
+	}
+
+	var snapshots []string
+	for i := range 3 {
+		for writes.Load() < int64(50*(i+1)) {
+			time.Sleep(time.Millisecond)
+		}
+		path := filepath.Join(dir, fmt.Sprintf("backup%d.db", i))
+		require.NoError(t, db.Backup(ctx, path))
+		snapshots = append(snapshots, path)
+	}
+	close(stop)
+	wg.Wait()
+	close(writeErrs)
+	for err := range writeErrs {
+		t.Errorf("upsert during backup: %v", err)
+	}
+
+	for _, path := range snapshots {
+		snapshot, err := sqlite.Connect(ctx, path, tables)
+		require.NoError(t, err)
+
+		assert.NoError(t, snapshot.Validate(ctx), path)
+		result, err := snapshot.GetRepo().List(ctx, stowry.ListQuery{Limit: 1000})
+		assert.NoError(t, err)
+		assert.NotEmpty(t, result.Items)
+		_, err = snapshot.GetRepo().Get(ctx, "before.txt")
+		assert.NoError(t, err, "writes committed before the backup are in it")
+		_ = snapshot.Close()
+	}
+
+	_, err = os.Stat(snapshots[0] + ".tmp")
+	assert.ErrorIs(t, err, os.ErrNotExist)
+	assert.Error(t, db.Backup(ctx, snapshots[0]), "an existing file is not overwritten")
+}
+
+func TestRestore(t *testing.T) {
+	ctx := context.Background()
+	tables := stowry.Tables{MetaData: "metadata"}
+
+	newDB := func(t *testing.T, path string, objects ...string) {
+		t.Helper()
+		db, err := sqlite.Connect(ctx, path, tables)
+		require.NoError(t, err)
+		defer func() { _ = db.Close() }()
+		require.NoError(t, db.Migrate(ctx))
+		for _, p := range objects {
+			_, _, err := db.GetRepo().Upsert(ctx, stowry.ObjectEntry{Path: p, ContentType: "text/plain", ETag: "e", Size: 1})
+			require.NoError(t, err)
+		}
+	}
+
+	t.Run("replaces the database", func(t *testing.T) {
+		dir := t.TempDir()
+		live := filepath.Join(dir, "stowry.db")
+		snapshot := filepath.Join(dir, "backup.db")
+		newDB(t, live, "current.txt")
+		newDB(t, snapshot, "restored.txt")
+		require.NoError(t, os.WriteFile(live+"-journal", []byte("stale"), 0o600))
+
+		require.NoError(t, sqlite.Restore(ctx, snapshot, live, tables))
+
+		db, err := sqlite.Connect(ctx, live, tables)
+		require.NoError(t, err)
+		defer func() { _ = db.Close() }()
+		assert.NoError(t, db.Validate(ctx))
+		_, err = db.GetRepo().Get(ctx, "restored.txt")
+		assert.NoError(t, err)
+		_, err = db.GetRepo().Get(ctx, "current.txt")
+		assert.ErrorIs(t, err, stowry.ErrNotFound)
+		_, err = os.Stat(live + "-journal")
+		assert.ErrorIs(t, err, os.ErrNotExist)
+	})
+
+	t.Run("rejects snapshots without the metadata table", func(t *testing.T) {
+		dir := t.TempDir()
+		live := filepath.Join(dir, "stowry.db")
+		newDB(t, live, "current.txt")
+
+		other := filepath.Join(dir, "other.db")
+		rawDB, err := sql.Open("sqlite", other)
+		require.NoError(t, err)
+		_, err = rawDB.ExecContext(ctx, `CREATE TABLE unrelated (id INTEGER)`)
+		require.NoError(t, err)
+		_ = rawDB.Close()
+
+		err = sqlite.Restore(ctx, other, live, tables)
+		assert.ErrorContains(t, err, "is this a stowry backup")
+
+		notDB := filepath.Join(dir, "not.db")
+		require.NoError(t, os.WriteFile(notDB, []byte("definitely not sqlite, just some text padding it out"), 0o600))
+		assert.Error(t, sqlite.Restore(ctx, notDB, live, tables))
+
+		assert.Error(t, sqlite.Restore(ctx, filepath.Join(dir, "missing.db"), live, tables))
+		_, err = os.Stat(filepath.Join(dir, "missing.db"))
+		assert.ErrorIs(t, err, os.ErrNotExist, "a missing snapshot is not created")
+
+		db, err := sqlite.Connect(ctx, live, tables)
+		require.NoError(t, err)
+		defer func() { _ = db.Close() }()
+		_, err = db.GetRepo().Get(ctx, "current.txt")
+		assert.NoError(t, err, "a rejected snapshot leaves the database alone")
+	})
+
+	t.Run("rejects in-memory databases", func(t *testing.T) {
+		snapshot := filepath.Join(t.TempDir(), "backup.db")
+		newDB(t, snapshot)
+		assert.Error(t, sqlite.Restore(ctx, snapshot, ":memory:", tables))
+	})
+}
+
+func TestRepo_ContextCanceled(t *testing.T) {
+	repo, cleanup := setupTestRepo(t)
+	defer cleanup()
+
+	entry := stowry.ObjectEntry{Path: "file.txt", Size: 4, ETag: "etag123", ContentType: "text/plain"}
+	_, _, err := repo.Upsert(context.Background(), entry)
+	require.NoError(t, err)
+
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+	expired, cancelExpired := context.WithDeadline(context.Background(), time.Now().Add(-time.Second))
+	defer cancelExpired()
+
+	for _, tc := range []struct {
+		name string
+		ctx  context.Context
+		want error
+	}{
+		{name: "canceled", ctx: canceled, want: context.Canceled},
+		{name: "deadline exceeded", ctx: expired, want: context.DeadlineExceeded},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := tc.ctx
+			calls := map[string]func() error{
+				"Get": func() error { _, err := repo.Get(ctx, entry.Path); return err },
+				"GetMany": func() error {
+					_, err := repo.GetMany(ctx, []string{entry.Path})
+					return err
+				},
+				"Upsert": func() error { _, _, err := repo.Upsert(ctx, entry); return err },
+				"UpsertBatch": func() error {
+					_, err := repo.UpsertBatch(ctx, []stowry.ObjectEntry{entry})
+					return err
+				},
+				"Delete":     func() error { return repo.Delete(ctx, entry.Path) },
+				"Quarantine": func() error { return repo.Quarantine(ctx, entry.Path, entry.ETag) },
+				"List": func() error {
+					_, err := repo.List(ctx, stowry.ListQuery{Limit: 10})
+					return err
+				},
+				"ListPendingCleanup": func() error {
+					_, err := repo.ListPendingCleanup(ctx, stowry.ListQuery{Limit: 10})
+					return err
+				},
+				"Changes": func() error {
+					_, err := repo.(stowry.ChangeFeed).Changes(ctx, stowry.ChangeQuery{Limit: 10})
+					return err
+				},
+			}
+			for name, call := range calls {
+				assert.ErrorIs(t, call(), tc.want, name)
+			}
+		})
+	}
+
+	_, err = repo.Get(context.Background(), entry.Path)
+	assert.NoError(t, err, "canceled calls must not change the entry")
+}
+
+func TestRepo_Filename(t *testing.T) {
+	repo, cleanup := setupTestRepo(t)
+	defer cleanup()
+
+	ctx := context.Background()
+
+	written, _, err := repo.Upsert(ctx, stowry.ObjectEntry{Path: "uploads/3f2a.pdf", Size: 4, ETag: "e1", ContentType: "application/pdf", Filename: "résumé.pdf"})
+	assert.NoError(t, err)
+	assert.Equal(t, "résumé.pdf", written.Filename)
+
+	got, err := repo.Get(ctx, "uploads/3f2a.pdf")
+	assert.NoError(t, err)
+	assert.Equal(t, "résumé.pdf", got.Filename)
+
+	many, err := repo.GetMany(ctx, []string{"uploads/3f2a.pdf"})
+	assert.NoError(t, err)
+	if assert.Len(t, many, 1) {
+		assert.Equal(t, "résumé.pdf", many[0].Filename)
+	}
+
+	list, err := repo.List(ctx, stowry.ListQuery{Limit: 10})
+	assert.NoError(t, err)
+	if assert.Len(t, list.Items, 1) {
+		assert.Equal(t, "résumé.pdf", list.Items[0].Filename)
+	}
+
+	batch, err := repo.UpsertBatch(ctx, []stowry.ObjectEntry{{Path: "uploads/b.txt", Size: 1, ETag: "e2", ContentType: "text/plain", Filename: "b.txt"}})
+	assert.NoError(t, err)
+	if assert.Len(t, batch, 1) {
+		assert.Equal(t, "b.txt", batch[0].Filename)
+	}
+
+	// Replacing without a filename clears it
+	_, _, err = repo.Upsert(ctx, stowry.ObjectEntry{Path: "uploads/3f2a.pdf", Size: 5, ETag: "e3", ContentType: "application/pdf"})
+	assert.NoError(t, err)
+	got, err = repo.Get(ctx, "uploads/3f2a.pdf")
+	assert.NoError(t, err)
+	assert.Empty(t, got.Filename)
+
+	assert.NoError(t, repo.Delete(ctx, "uploads/b.txt"))
+	deleted, err := repo.GetDeleted(ctx, "uploads/b.txt")
+	assert.NoError(t, err)
+	assert.Equal(t, "b.txt", deleted.Filename)
+
+	stages := repo.(stowry.StageRepo)
+	stage, err := stages.CreateStage(ctx)
+	assert.NoError(t, err)
+	staged := stowry.ObjectEntry{Path: "uploads/c.pdf", Size: 4, ETag: "e4", ContentType: "application/pdf", Filename: "Q3 report.pdf"}
+	assert.NoError(t, stages.AddStagedObject(ctx, stage.ID, staged))
+
+	entries, err := stages.StagedObjects(ctx, stage.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, []stowry.ObjectEntry{staged}, entries)
+
+	committed, err := stages.CommitStage(ctx, stage.ID)
+	assert.NoError(t, err)
+	if assert.Len(t, committed, 1) {
+		assert.Equal(t, "Q3 report.pdf", committed[0].Filename)
+	}
+	got, err = repo.Get(ctx, "uploads/c.pdf")
+	assert.NoError(t, err)
+	assert.Equal(t, "Q3 report.pdf", got.Filename)
+}
+
+func TestRepo_UserMetadata(t *testing.T) {
+	repo, cleanup := setupTestRepo(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	meta := map[string]string{"uploader": "u-42", "original-name": "résumé.pdf"}
+
+	written, _, err := repo.Upsert(ctx, stowry.ObjectEntry{Path: "a.pdf", Size: 4, ETag: "e1", ContentType: "application/pdf", UserMetadata: meta})
+	assert.NoError(t, err)
+	assert.Equal(t, meta, written.UserMetadata)
+
+	got, err := repo.Get(ctx, "a.pdf")
+	assert.NoError(t, err)
+	assert.Equal(t, meta, got.UserMetadata)
+
+	list, err := repo.List(ctx, stowry.ListQuery{Limit: 10})
+	assert.NoError(t, err)
+	if assert.Len(t, list.Items, 1) {
+		assert.Equal(t, meta, list.Items[0].UserMetadata)
+	}
+
+	batch, err := repo.UpsertBatch(ctx, []stowry.ObjectEntry{
+		{Path: "b.txt", Size: 1, ETag: "e2", ContentType: "text/plain", UserMetadata: map[string]string{"k": "v"}},
+		{Path: "c.txt", Size: 1, ETag: "e3", ContentType: "text/plain"},
+	})
+	assert.NoError(t, err)
+	if assert.Len(t, batch, 2) {
+		assert.Equal(t, map[string]string{"k": "v"}, batch[0].UserMetadata)
+		assert.Nil(t, batch[1].UserMetadata)
+	}
+	many, err := repo.GetMany(ctx, []string{"b.txt", "c.txt"})
+	assert.NoError(t, err)
+	for _, m := range many {
+		if m.Path == "b.txt" {
+			assert.Equal(t, map[string]string{"k": "v"}, m.UserMetadata)
+		} else {
+			assert.Nil(t, m.UserMetadata)
+		}
+	}
+
+	// Replacing without metadata clears it
+	_, _, err = repo.Upsert(ctx, stowry.ObjectEntry{Path: "a.pdf", Size: 5, ETag: "e4", ContentType: "application/pdf"})
+	assert.NoError(t, err)
+	got, err = repo.Get(ctx, "a.pdf")
+	assert.NoError(t, err)
+	assert.Nil(t, got.UserMetadata)
+
+	assert.NoError(t, repo.Delete(ctx, "b.txt"))
+	deleted, err := repo.GetDeleted(ctx, "b.txt")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"k": "v"}, deleted.UserMetadata)
+
+	stages := repo.(stowry.StageRepo)
+	stage, err := stages.CreateStage(ctx)
+	assert.NoError(t, err)
+	staged := stowry.ObjectEntry{Path: "d.txt", Size: 4, ETag: "e5", ContentType: "text/plain", UserMetadata: meta}
+	assert.NoError(t, stages.AddStagedObject(ctx, stage.ID, staged))
+	entries, err := stages.StagedObjects(ctx, stage.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, []stowry.ObjectEntry{staged}, entries)
+	committed, err := stages.CommitStage(ctx, stage.ID)
+	assert.NoError(t, err)
+	if assert.Len(t, committed, 1) {
+		assert.Equal(t, meta, committed[0].UserMetadata)
+	}
+
+	uploads := repo.(stowry.MultipartRepo)
+	upload, err := uploads.CreateMultipartUpload(ctx, stowry.MultipartUpload{Path: "e.bin", ContentType: "application/octet-stream", UserMetadata: meta})
+	assert.NoError(t, err)
+	gotUpload, _, err := uploads.GetMultipartUpload(ctx, upload.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, meta, gotUpload.UserMetadata)
+
+	intents := repo.(stowry.IntentRepo)
+	_, err = intents.RecordWriteIntent(ctx, stowry.WriteIntent{Path: "f.txt", ContentType: "text/plain", UserMetadata: meta})
+	assert.NoError(t, err)
+	recorded, err := intents.WriteIntents(ctx, time.Now().Add(time.Minute))
+	assert.NoError(t, err)
+	if assert.Len(t, recorded, 1) {
+		assert.Equal(t, meta, recorded[0].UserMetadata)
+	}
+}
+
+func TestRepo_CacheControl(t *testing.T) {
+	repo, cleanup := setupTestRepo(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	const immutable = "public, max-age=31536000, immutable"
+
+	written, _, err := repo.Upsert(ctx, stowry.ObjectEntry{Path: "app.js", Size: 4, ETag: "e1", ContentType: "text/javascript", CacheControl: immutable})
+	assert.NoError(t, err)
+	assert.Equal(t, immutable, written.CacheControl)
+
+	got, err := repo.Get(ctx, "app.js")
+	assert.NoError(t, err)
+	assert.Equal(t, immutable, got.CacheControl)
+
+	batch, err := repo.UpsertBatch(ctx, []stowry.ObjectEntry{
+		{Path: "index.html", Size: 1, ETag: "e2", ContentType: "text/html", CacheControl: "no-cache"},
+	})
+	assert.NoError(t, err)
+	if assert.Len(t, batch, 1) {
+		assert.Equal(t, "no-cache", batch[0].CacheControl)
+	}
+
+	list, err := repo.List(ctx, stowry.ListQuery{Limit: 10})
+	assert.NoError(t, err)
+	assert.Len(t, list.Items, 2)
+	for _, m := range list.Items {
+		assert.NotEmpty(t, m.CacheControl, m.Path)
+	}
+
+	// Replacing without a Cache-Control clears it
+	_, _, err = repo.Upsert(ctx, stowry.ObjectEntry{Path: "app.js", Size: 5, ETag: "e3", ContentType: "text/javascript"})
+	assert.NoError(t, err)
+	got, err = repo.Get(ctx, "app.js")
+	assert.NoError(t, err)
+	assert.Empty(t, got.CacheControl)
+
+	stages := repo.(stowry.StageRepo)
+	stage, err := stages.CreateStage(ctx)
+	assert.NoError(t, err)
+	staged := stowry.ObjectEntry{Path: "d.txt", Size: 4, ETag: "e4", ContentType: "text/plain", CacheControl: "no-store"}
+	assert.NoError(t, stages.AddStagedObject(ctx, stage.ID, staged))
+	committed, err := stages.CommitStage(ctx, stage.ID)
+	assert.NoError(t, err)
+	if assert.Len(t, committed, 1) {
+		assert.Equal(t, "no-store", committed[0].CacheControl)
+	}
+
+	uploads := repo.(stowry.MultipartRepo)
+	upload, err := uploads.CreateMultipartUpload(ctx, stowry.MultipartUpload{Path: "e.bin", ContentType: "application/octet-stream", CacheControl: "no-store"})
+	assert.NoError(t, err)
+	gotUpload, _, err := uploads.GetMultipartUpload(ctx, upload.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, "no-store", gotUpload.CacheControl)
+
+	intents := repo.(stowry.IntentRepo)
+	_, err = intents.RecordWriteIntent(ctx, stowry.WriteIntent{Path: "f.txt", ContentType: "text/plain", CacheControl: "no-store"})
+	assert.NoError(t, err)
+	recorded, err := intents.WriteIntents(ctx, time.Now().Add(time.Minute))
+	assert.NoError(t, err)
+	if assert.Len(t, recorded, 1) {
+		assert.Equal(t, "no-store", recorded[0].CacheControl)
+	}
+}
+
+func TestRepo_StorageBackend(t *testing.T) {
+	repo, cleanup := setupTestRepo(t)
+	defer cleanup()
+
+	ctx := context.Background()
+
+	written, _, err := repo.Upsert(ctx, stowry.ObjectEntry{Path: "media/a.mp4", Size: 4, ETag: "e1", ContentType: "video/mp4", StorageBackend: "media/"})
+	assert.NoError(t, err)
+	assert.Equal(t, "media/", written.StorageBackend)
+
+	got, err := repo.Get(ctx, "media/a.mp4")
+	assert.NoError(t, err)
+	assert.Equal(t, "media/", got.StorageBackend)
+
+	list, err := repo.List(ctx, stowry.ListQuery{Limit: 10})
+	assert.NoError(t, err)
+	if assert.Len(t, list.Items, 1) {
+		assert.Equal(t, "media/", list.Items[0].StorageBackend)
+	}
+
+	batch, err := repo.UpsertBatch(ctx, []stowry.ObjectEntry{{Path: "docs/b.txt", Size: 1, ETag: "e2", ContentType: "text/plain"}})
+	assert.NoError(t, err)
+	if assert.Len(t, batch, 1) {
+		assert.Empty(t, batch[0].StorageBackend, "the default backend is stored as NULL")
+	}
+
+	stages := repo.(stowry.StageRepo)
+	stage, err := stages.CreateStage(ctx)
+	assert.NoError(t, err)
+	staged := stowry.ObjectEntry{Path: "media/c.mp4", Size: 4, ETag: "e4", ContentType: "video/mp4", StorageBackend: "media/"}
+	assert.NoError(t, stages.AddStagedObject(ctx, stage.ID, staged))
+
+	committed, err := stages.CommitStage(ctx, stage.ID)
+	assert.NoError(t, err)
+	if assert.Len(t, committed, 1) {
+		assert.Equal(t, "media/", committed[0].StorageBackend)
+	}
+
+	usage, err := repo.(stowry.BackendUsageRepo).BackendUsage(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, []stowry.BackendUsage{
+		{Backend: "", Objects: 1, Bytes: 1},
+		{Backend: "media/", Objects: 2, Bytes: 8},
+	}, usage)
+}
+
+func TestRepo_AccessKeys(t *testing.T) {
+	ctx := context.Background()
+	repo, cleanup := setupTestRepo(t)
+	defer cleanup()
+	keys := repo.(stowry.AccessKeyRepo)
+
+	list, err := keys.ListAccessKeys(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, list)
+
+	t0 := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	require.NoError(t, keys.RecordAccessKeyUse(ctx, []stowry.AccessKeyUse{
+		{AccessKey: "OLD", Count: 3, FirstUsedAt: t0, LastUsedAt: t0.Add(time.Minute)},
+	}))
+	// A later flush adds to the count; an earlier last use never moves it back
+	require.NoError(t, keys.RecordAccessKeyUse(ctx, []stowry.AccessKeyUse{
+		{AccessKey: "OLD", Count: 2, FirstUsedAt: t0.Add(time.Hour), LastUsedAt: t0.Add(2 * time.Hour)},
+		{AccessKey: "OTHER", Count: 1, FirstUsedAt: t0, LastUsedAt: t0},
+	}))
+	require.NoError(t, keys.RecordAccessKeyUse(ctx, []stowry.AccessKeyUse{
+		{AccessKey: "OLD", Count: 1, FirstUsedAt: t0, LastUsedAt: t0.Add(time.Hour)},
+	}))
+
+	list, err = keys.ListAccessKeys(ctx)
+	require.NoError(t, err)
+	require.Len(t, list, 2)
+	old := list[0]
+	assert.Equal(t, "OLD", old.AccessKey)
+	assert.Equal(t, int64(6), old.RequestCount)
+	require.NotNil(t, old.CreatedAt)
+	assert.True(t, t0.Equal(*old.CreatedAt))
+	require.NotNil(t, old.LastUsedAt)
+	assert.True(t, t0.Add(2*time.Hour).Equal(*old.LastUsedAt))
+	assert.Equal(t, stowry.AccessKeyActive, old.Status())
+
+	rotatedAt := t0.Add(3 * time.Hour)
+	require.NoError(t, keys.RotateAccessKey(ctx, "OLD", "NEW", rotatedAt))
+	list, err = keys.ListAccessKeys(ctx)
+	require.NoError(t, err)
+	require.Len(t, list, 3)
+	newKey, old := list[0], list[1]
+	assert.Equal(t, "NEW", newKey.AccessKey)
+	assert.Equal(t, stowry.AccessKeyActive, newKey.Status())
+	assert.True(t, rotatedAt.Equal(*newKey.CreatedAt))
+	assert.Nil(t, newKey.LastUsedAt)
+	assert.Equal(t, stowry.AccessKeyDeprecated, old.Status())
+	assert.Equal(t, "NEW", old.ReplacedBy)
+	assert.Equal(t, int64(6), old.RequestCount, "rotation keeps the use")
+
+	require.Error(t, keys.RotateAccessKey(ctx, "OTHER", "NEW", rotatedAt), "the new key must not exist")
+
+	require.NoError(t, keys.RevokeAccessKey(ctx, "OLD", rotatedAt.Add(time.Hour)))
+	// A key the metadata never saw can be revoked too
+	require.NoError(t, keys.RevokeAccessKey(ctx, "UNSEEN", rotatedAt.Add(time.Hour)))
+	list, err = keys.ListAccessKeys(ctx)
+	require.NoError(t, err)
+	require.Len(t, list, 4)
+	assert.Equal(t, stowry.AccessKeyRevoked, list[1].Status())
+	assert.Equal(t, "OTHER", list[2].AccessKey)
+	assert.Equal(t, stowry.AccessKeyActive, list[2].Status())
+	assert.Equal(t, stowry.AccessKeyRevoked, list[3].Status())
+}
+
+func TestRepo_WriteIntents(t *testing.T) {
+	ctx := context.Background()
+	repo, cleanup := setupTestRepo(t)
+	defer cleanup()
+	intents := repo.(stowry.IntentRepo)
+
+	objectID := uuid.New()
+	first, err := intents.RecordWriteIntent(ctx, stowry.WriteIntent{
+		Path: "a.txt", ContentType: "text/plain", ContentEncoding: "gzip", Filename: "A.txt", ObjectID: objectID,
+	})
+	require.NoError(t, err)
+	assert.NotEqual(t, uuid.Nil, first.ID)
+	assert.False(t, first.StartedAt.IsZero())
+	second, err := intents.RecordWriteIntent(ctx, stowry.WriteIntent{Path: "b.txt", ContentType: "text/plain"})
+	require.NoError(t, err)
+
+	list, err := intents.WriteIntents(ctx, time.Now().Add(time.Minute))
+	require.NoError(t, err)
+	require.Len(t, list, 2)
+	assert.Equal(t, first.ID, list[0].ID)
+	assert.Equal(t, "a.txt", list[0].Path)
+	assert.Equal(t, "text/plain", list[0].ContentType)
+	assert.Equal(t, "gzip", list[0].ContentEncoding)
+	assert.Equal(t, "A.txt", list[0].Filename)
+	assert.Equal(t, objectID, list[0].ObjectID)
+	assert.Equal(t, second.ID, list[1].ID)
+	assert.Empty(t, list[1].ContentEncoding)
+	assert.Equal(t, uuid.Nil, list[1].ObjectID)
+
+	list, err = intents.WriteIntents(ctx, first.StartedAt.Add(-time.Minute))
+	require.NoError(t, err)
+	assert.Empty(t, list, "only intents started before the cutoff")
+
+	require.NoError(t, intents.ClearWriteIntent(ctx, first.ID))
+	require.NoError(t, intents.ClearWriteIntent(ctx, first.ID), "clearing twice is not an error")
+	list, err = intents.WriteIntents(ctx, time.Now().Add(time.Minute))
+	require.NoError(t, err)
+	require.Len(t, list, 1)
+	assert.Equal(t, second.ID, list[0].ID)
+}
+
+func TestRepo_Multipart(t *testing.T) {
+	ctx := context.Background()
+	repo, cleanup := setupTestRepo(t)
+	defer cleanup()
+	multipart := repo.(stowry.MultipartRepo)
+
+	upload, err := multipart.CreateMultipartUpload(ctx, stowry.MultipartUpload{
+		Path: "big.bin", ContentType: "application/octet-stream", ContentEncoding: "gzip", Filename: "Big.bin",
+	})
+	require.NoError(t, err)
+	assert.NotEqual(t, uuid.Nil, upload.ID)
+	assert.False(t, upload.CreatedAt.IsZero())
+
+	now := time.Now().UTC()
+	require.NoError(t, multipart.AddMultipartPart(ctx, upload.ID, stowry.MultipartPart{Number: 2, ETag: "e2", Size: 20, UploadedAt: now}))
+	require.NoError(t, multipart.AddMultipartPart(ctx, upload.ID, stowry.MultipartPart{Number: 1, ETag: "e1", Size: 10, UploadedAt: now}))
+	require.NoError(t, multipart.AddMultipartPart(ctx, upload.ID, stowry.MultipartPart{Number: 2, ETag: "e2b", Size: 25, UploadedAt: now}),
+		"a part uploaded again replaces the earlier one")
+
+	got, parts, err := multipart.GetMultipartUpload(ctx, upload.ID)
+	require.NoError(t, err)
+	assert.Equal(t, upload.ID, got.ID)
+	assert.Equal(t, "big.bin", got.Path)
+	assert.Equal(t, "application/octet-stream", got.ContentType)
+	assert.Equal(t, "gzip", got.ContentEncoding)
+	assert.Equal(t, "Big.bin", got.Filename)
+	require.Len(t, parts, 2)
+	assert.Equal(t, 1, parts[0].Number)
+	assert.Equal(t, "e1", parts[0].ETag)
+	assert.Equal(t, int64(10), parts[0].Size)
+	assert.Equal(t, 2, parts[1].Number)
+	assert.Equal(t, "e2b", parts[1].ETag)
+	assert.Equal(t, int64(25), parts[1].Size)
+
+	t.Run("unknown upload", func(t *testing.T) {
+		_, _, err := multipart.GetMultipartUpload(ctx, uuid.New())
+		assert.ErrorIs(t, err, stowry.ErrNotFound)
+		err = multipart.AddMultipartPart(ctx, uuid.New(), stowry.MultipartPart{Number: 1, ETag: "e", UploadedAt: now})
+		assert.ErrorIs(t, err, stowry.ErrNotFound)
+		assert.ErrorIs(t, multipart.DeleteMultipartUpload(ctx, uuid.New()), stowry.ErrNotFound)
+	})
+
+	t.Run("stale uploads", func(t *testing.T) {
+		second, err := multipart.CreateMultipartUpload(ctx, stowry.MultipartUpload{Path: "other.bin", ContentType: "text/plain"})
+		require.NoError(t, err)
+
+		stale, err := multipart.StaleMultipartUploads(ctx, time.Now().Add(time.Minute), 0)
+		require.NoError(t, err)
+		require.Len(t, stale, 2)
+		assert.Equal(t, upload.ID, stale[0].ID, "oldest first")
+		assert.Equal(t, second.ID, stale[1].ID)
+		assert.Empty(t, stale[1].ContentEncoding)
+
+		stale, err = multipart.StaleMultipartUploads(ctx, time.Now().Add(time.Minute), 1)
+		require.NoError(t, err)
+		require.Len(t, stale, 1)
+		assert.Equal(t, upload.ID, stale[0].ID)
+
+		stale, err = multipart.StaleMultipartUploads(ctx, upload.CreatedAt.Add(-time.Minute), 0)
+		require.NoError(t, err)
+		assert.Empty(t, stale, "only uploads created before the cutoff")
+
+		require.NoError(t, multipart.DeleteMultipartUpload(ctx, second.ID))
+	})
+
+	require.NoError(t, multipart.DeleteMultipartUpload(ctx, upload.ID))
+	_, _, err = multipart.GetMultipartUpload(ctx, upload.ID)
+	assert.ErrorIs(t, err, stowry.ErrNotFound)
+	err = multipart.AddMultipartPart(ctx, upload.ID, stowry.MultipartPart{Number: 1, ETag: "e", UploadedAt: now})
+	assert.ErrorIs(t, err, stowry.ErrNotFound, "parts of a deleted upload are rejected")
+}
+
+func TestRepo_IDs(t *testing.T) {
+	ctx := context.Background()
+	repo, cleanup := setupTestRepo(t, sqlite.WithIDScheme(stowry.IDSchemeULID))
+	defer cleanup()
+
+	entry := func(path, etag string, id uuid.UUID) stowry.ObjectEntry {
+		return stowry.ObjectEntry{ID: id, Path: path, Size: 1, ETag: etag, ContentType: "text/plain"}
+	}
+	v4 := uuid.New()
+	v7, err := uuid.NewV7()
+	require.NoError(t, err)
+
+	// The table mixes generated ULIDs with supplied UUIDs of other versions
+	before := time.Now().UnixMilli()
+	generated, _, err := repo.Upsert(ctx, entry("a.txt", "e1", uuid.Nil))
+	require.NoError(t, err)
+	var ms [8]byte
+	copy(ms[2:], generated.ID[:6])
+	assert.GreaterOrEqual(t, int64(binary.BigEndian.Uint64(ms[:])), before, "ulid timestamp")
+	m, _, err := repo.Upsert(ctx, entry("b.txt", "e1", v4))
+	require.NoError(t, err)
+	assert.Equal(t, v4, m.ID)
+	m, _, err = repo.Upsert(ctx, entry("c.txt", "e1", v7))
+	require.NoError(t, err)
+	assert.Equal(t, v7, m.ID)
+
+	t.Run("pagination does not depend on id order", func(t *testing.T) {
+		want := map[string]uuid.UUID{"a.txt": generated.ID, "b.txt": v4, "c.txt": v7}
+		got := map[string]uuid.UUID{}
+		cursor := ""
+		for range 4 {
+			res, err := repo.List(ctx, stowry.ListQuery{Limit: 1, Cursor: cursor})
+			require.NoError(t, err)
+			for _, item := range res.Items {
+				got[item.Path] = item.ID
+			}
+			if cursor = res.NextCursor; cursor == "" {
+				break
+			}
+		}
+		assert.Equal(t, want, got)
+	})
+
+	t.Run("existing rows keep their ids", func(t *testing.T) {
+		m, previous, err := repo.Upsert(ctx, entry("a.txt", "e2", uuid.Nil))
+		require.NoError(t, err)
+		assert.Equal(t, generated.ID, m.ID)
+		require.NotNil(t, previous)
+
+		m, _, err = repo.Upsert(ctx, entry("b.txt", "e2", v4))
+		require.NoError(t, err, "the object's own id")
+		assert.Equal(t, v4, m.ID)
+	})
+
+	t.Run("supplied id of another live object", func(t *testing.T) {
+		_, _, err := repo.Upsert(ctx, entry("b.txt", "e3", uuid.New()))
+		assert.ErrorIs(t, err, stowry.ErrIDConflict)
+		m, err := repo.Get(ctx, "b.txt")
+		require.NoError(t, err)
+		assert.Equal(t, v4, m.ID)
+		assert.Equal(t, "e2", m.Etag, "the row is unchanged")
+	})
+
+	t.Run("supplied id held by another path", func(t *testing.T) {
+		_, _, err := repo.Upsert(ctx, entry("d.txt", "e1", v4))
+		assert.ErrorIs(t, err, stowry.ErrIDConflict)
+		_, err = repo.Get(ctx, "d.txt")
+		assert.ErrorIs(t, err, stowry.ErrNotFound)
+	})
+
+	t.Run("a deleted row takes the supplied id", func(t *testing.T) {
+		require.NoError(t, repo.Delete(ctx, "c.txt"))
+		id := uuid.New()
+		m, previous, err := repo.Upsert(ctx, entry("c.txt", "e2", id))
+		require.NoError(t, err)
+		assert.Equal(t, id, m.ID)
+		assert.Nil(t, previous)
+	})
+}
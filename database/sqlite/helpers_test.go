@@ -21,7 +21,7 @@ func getRandomString(t *testing.T) string {
 }
 
 // setupTestRepo creates a repo with a unique table name for test isolation
-func setupTestRepo(t *testing.T) (stowry.MetaDataRepo, func()) {
+func setupTestRepo(t *testing.T, opts ...sqlite.Option) (stowry.MetaDataRepo, func()) {
 	t.Helper()
 
 	ctx := context.Background()
@@ -31,7 +31,7 @@ func setupTestRepo(t *testing.T) (stowry.MetaDataRepo, func()) {
 	tables := stowry.Tables{MetaData: tableName}
 
 	// Connect to in-memory database
-	db, err := sqlite.Connect(ctx, ":memory:", tables)
+	db, err := sqlite.Connect(ctx, ":memory:", tables, opts...)
 	assert.NoError(t, err, "failed to connect")
 
 	// Migrate the table
@@ -0,0 +1,47 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/sagarc03/stowry"
+	"github.com/sagarc03/stowry/database/internal"
+)
+
+// instanceIDKey is the settings key holding the instance ID.
+const instanceIDKey = "instance_id"
+
+func (r *repo) InstanceID(ctx context.Context) (_ string, err error) {
+	defer func() { err = internal.ContextError(ctx, err) }()
+
+	query := fmt.Sprintf( //nolint:gosec // G201: table name is validated
+		`SELECT value FROM %s WHERE key = ?`, r.settingsTable)
+
+	var id string
+	if err := r.db.QueryRowContext(ctx, query, instanceIDKey).Scan(&id); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return "", fmt.Errorf("instance id: %w", stowry.ErrNotFound)
+		}
+		return "", fmt.Errorf("instance id: %w", err)
+	}
+	return id, nil
+}
+
+func (r *repo) SetInstanceID(ctx context.Context, id string) (err error) {
+	defer func() { err = internal.ContextError(ctx, err) }()
+
+	query := fmt.Sprintf( //nolint:gosec // G201: table name is validated
+		`INSERT INTO %s (key, value, updated_at) VALUES (?, ?, ?)
+		ON CONFLICT (key) DO UPDATE
+		SET value = excluded.value,
+			updated_at = excluded.updated_at`, r.settingsTable)
+
+	now := time.Now().UTC().Format(time.RFC3339Nano)
+	if _, err := r.db.ExecContext(ctx, query, instanceIDKey, id, now); err != nil {
+		return fmt.Errorf("set instance id: %w", err)
+	}
+	return nil
+}
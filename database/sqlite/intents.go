@@ -0,0 +1,86 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sagarc03/stowry"
+	"github.com/sagarc03/stowry/database/internal"
+)
+
+func (r *repo) RecordWriteIntent(ctx context.Context, intent stowry.WriteIntent) (_ stowry.WriteIntent, err error) {
+	defer func() { err = internal.ContextError(ctx, err) }()
+
+	intent.ID = uuid.New()
+	intent.StartedAt = time.Now().UTC()
+	query := fmt.Sprintf( //nolint:gosec // G201: table name is validated
+		`INSERT INTO %s (id, path, content_type, content_encoding, filename, object_id, started_at, user_metadata, cache_control)
+		VALUES (?, ?, ?, NULLIF(?, ''), NULLIF(?, ''), ?, ?, NULLIF(?, ''), NULLIF(?, ''))`, r.writeIntentsTable)
+
+	// Fixed-width times compare correctly as text in WriteIntents
+	_, err = r.db.ExecContext(ctx, query, intent.ID.String(), intent.Path, intent.ContentType,
+		intent.ContentEncoding, intent.Filename, nullUUID(intent.ObjectID), intent.StartedAt.Format(usageTimeFormat),
+		internal.EncodeUserMetadata(intent.UserMetadata), intent.CacheControl)
+	if err != nil {
+		return stowry.WriteIntent{}, fmt.Errorf("record write intent %s: %w", intent.Path, err)
+	}
+	return intent, nil
+}
+
+func (r *repo) ClearWriteIntent(ctx context.Context, id uuid.UUID) (err error) {
+	defer func() { err = internal.ContextError(ctx, err) }()
+
+	query := fmt.Sprintf( //nolint:gosec // G201: table name is validated
+		`DELETE FROM %s WHERE id = ?`, r.writeIntentsTable)
+
+	if _, err := r.db.ExecContext(ctx, query, id.String()); err != nil {
+		return fmt.Errorf("clear write intent %s: %w", id, err)
+	}
+	return nil
+}
+
+func (r *repo) WriteIntents(ctx context.Context, before time.Time) (_ []stowry.WriteIntent, err error) {
+	defer func() { err = internal.ContextError(ctx, err) }()
+
+	query := fmt.Sprintf( //nolint:gosec // G201: table name is validated
+		`SELECT id, path, content_type, COALESCE(content_encoding, ''), COALESCE(filename, ''), object_id, started_at, user_metadata, COALESCE(cache_control, '')
+		FROM %s
+		WHERE started_at < ?
+		ORDER BY started_at, id`, r.writeIntentsTable)
+
+	rows, err := r.db.QueryContext(ctx, query, before.UTC().Format(usageTimeFormat))
+	if err != nil {
+		return nil, fmt.Errorf("list write intents: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	intents := []stowry.WriteIntent{}
+	for rows.Next() {
+		var intent stowry.WriteIntent
+		var id, startedAt string
+		var objectID sql.NullString
+		if err := rows.Scan(&id, &intent.Path, &intent.ContentType, &intent.ContentEncoding, &intent.Filename, &objectID, &startedAt,
+			internal.UserMetadata{Dest: &intent.UserMetadata}, &intent.CacheControl); err != nil {
+			return nil, fmt.Errorf("list write intents: scan: %w", err)
+		}
+		if intent.ID, err = uuid.Parse(id); err != nil {
+			return nil, fmt.Errorf("list write intents: parse id: %w", err)
+		}
+		if objectID.Valid {
+			if intent.ObjectID, err = uuid.Parse(objectID.String); err != nil {
+				return nil, fmt.Errorf("list write intents: parse object_id: %w", err)
+			}
+		}
+		if intent.StartedAt, err = time.Parse(usageTimeFormat, startedAt); err != nil {
+			return nil, fmt.Errorf("list write intents: parse started_at: %w", err)
+		}
+		intents = append(intents, intent)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("list write intents: %w", err)
+	}
+	return intents, nil
+}
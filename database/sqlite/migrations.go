@@ -4,6 +4,9 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
+
+	"github.com/sagarc03/stowry"
+	"github.com/sagarc03/stowry/database/internal"
 )
 
 // quoteIdentifier safely quotes a SQLite identifier.
@@ -11,44 +14,300 @@ func quoteIdentifier(name string) string {
 	return `"` + name + `"`
 }
 
-func createMetaTable(ctx context.Context, db *sql.DB, tableName string) error {
+type columnDef struct {
+	name       string
+	definition string
+}
+
+// metaDataAddedColumns are the metadata columns added after the initial
+// schema; existing tables gain them on migrate.
+var metaDataAddedColumns = []columnDef{
+	{name: "download_count", definition: "INTEGER"},
+	{name: "last_accessed_at", definition: "TEXT"},
+	{name: "content_encoding", definition: "TEXT"},
+	{name: "decoded_size_bytes", definition: "INTEGER"},
+	{name: "quarantined_at", definition: "TEXT"},
+	{name: "filename", definition: "TEXT"},
+	{name: "storage_backend", definition: "TEXT"},
+	{name: "cleanup_retry_at", definition: "TEXT"},
+	{name: "user_metadata", definition: "TEXT"},
+	{name: "cache_control", definition: "TEXT"},
+}
+
+// stagedObjectsAddedColumns are the staged object columns added after the
+// initial schema.
+var stagedObjectsAddedColumns = []columnDef{
+	{name: "content_encoding", definition: "TEXT"},
+	{name: "decoded_size_bytes", definition: "INTEGER"},
+	{name: "filename", definition: "TEXT"},
+	{name: "storage_backend", definition: "TEXT"},
+	{name: "user_metadata", definition: "TEXT"},
+	{name: "cache_control", definition: "TEXT"},
+}
+
+// writeIntentsAddedColumns are the write intent columns added after the
+// initial schema.
+var writeIntentsAddedColumns = []columnDef{
+	{name: "user_metadata", definition: "TEXT"},
+	{name: "cache_control", definition: "TEXT"},
+}
+
+// uploadsAddedColumns are the multipart upload columns added after the
+// initial schema.
+var uploadsAddedColumns = []columnDef{
+	{name: "user_metadata", definition: "TEXT"},
+	{name: "cache_control", definition: "TEXT"},
+}
+
+// changesAddedColumns are the change feed columns added after the initial
+// schema.
+var changesAddedColumns = []columnDef{
+	{name: "previous_etag", definition: "TEXT"},
+	{name: "actor", definition: "TEXT"},
+}
+
+// addMissingColumns adds each column that the table does not already have.
+// SQLite has no ADD COLUMN IF NOT EXISTS, so existing columns are read first.
+func addMissingColumns(ctx context.Context, db *sql.DB, tableName string, columns []internal.Statement) error {
 	quotedTable := quoteIdentifier(tableName)
-	indexDeletedAt := quoteIdentifier(fmt.Sprintf("idx_%s_deleted_at", tableName))
-	indexPendingCleanup := quoteIdentifier(fmt.Sprintf("idx_%s_pending_cleanup", tableName))
-	indexActiveList := quoteIdentifier(fmt.Sprintf("idx_%s_active_list", tableName))
 
-	createTableSQL := fmt.Sprintf(`
-		CREATE TABLE IF NOT EXISTS %s (
-			id TEXT NOT NULL PRIMARY KEY,
-			path TEXT NOT NULL UNIQUE,
-			content_type TEXT NOT NULL,
-			etag TEXT NOT NULL,
-			file_size_bytes INTEGER NOT NULL,
-			created_at TEXT NOT NULL,
-			updated_at TEXT NOT NULL,
-			deleted_at TEXT,
-			cleaned_up_at TEXT
-		)
-	`, quotedTable)
+	rows, err := db.QueryContext(ctx, fmt.Sprintf(`PRAGMA table_info(%s)`, quotedTable))
+	if err != nil {
+		return fmt.Errorf("query columns: %w", err)
+	}
 
-	if _, err := db.ExecContext(ctx, createTableSQL); err != nil {
-		return fmt.Errorf("create table: %w", err)
+	existing := make(map[string]bool)
+	for rows.Next() {
+		var cid, notNull, pk int
+		var name, dataType string
+		var dfltValue sql.NullString
+		if err := rows.Scan(&cid, &name, &dataType, &notNull, &dfltValue, &pk); err != nil {
+			_ = rows.Close()
+			return fmt.Errorf("scan column: %w", err)
+		}
+		existing[name] = true
+	}
+	if err := rows.Close(); err != nil {
+		return fmt.Errorf("close rows: %w", err)
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("rows error: %w", err)
 	}
 
-	indexSQL := fmt.Sprintf(`CREATE INDEX IF NOT EXISTS %s ON %s (deleted_at)`, indexDeletedAt, quotedTable)
-	if _, err := db.ExecContext(ctx, indexSQL); err != nil {
-		return fmt.Errorf("create index deleted_at: %w", err)
+	for _, col := range columns {
+		if existing[col.Name] {
+			continue
+		}
+		if _, err := db.ExecContext(ctx, col.SQL); err != nil {
+			return fmt.Errorf("add column %s: %w", col.Name, err)
+		}
 	}
 
-	indexSQL = fmt.Sprintf(`CREATE INDEX IF NOT EXISTS %s ON %s (deleted_at, cleaned_up_at)`, indexPendingCleanup, quotedTable)
-	if _, err := db.ExecContext(ctx, indexSQL); err != nil {
-		return fmt.Errorf("create index pending_cleanup: %w", err)
+	return nil
+}
+
+// tableDDL returns the statements that create each table and bring one
+// created by an older release up to date. The changes table uses
+// AUTOINCREMENT so sequences are not reused after the newest changes are
+// pruned.
+func tableDDL(tables stowry.Tables) []internal.TableDDL {
+	return []internal.TableDDL{{
+		Table: tables.MetaData,
+		Create: fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+	id TEXT NOT NULL PRIMARY KEY,
+	path TEXT NOT NULL UNIQUE,
+	content_type TEXT NOT NULL,
+	etag TEXT NOT NULL,
+	file_size_bytes INTEGER NOT NULL,
+	created_at TEXT NOT NULL,
+	updated_at TEXT NOT NULL,
+	deleted_at TEXT,
+	cleaned_up_at TEXT,
+	download_count INTEGER,
+	last_accessed_at TEXT,
+	content_encoding TEXT,
+	decoded_size_bytes INTEGER,
+	quarantined_at TEXT,
+	filename TEXT,
+	storage_backend TEXT,
+	cleanup_retry_at TEXT,
+	user_metadata TEXT,
+	cache_control TEXT
+)`, quoteIdentifier(tables.MetaData)),
+		Columns: addColumns(tables.MetaData, metaDataAddedColumns),
+		Indexes: []internal.Statement{
+			createIndex(tables.MetaData, "deleted_at", "(deleted_at)"),
+			createIndex(tables.MetaData, "pending_cleanup", "(deleted_at, cleaned_up_at)"),
+			createIndex(tables.MetaData, "active_list", "(created_at, path)"),
+			createIndex(tables.MetaData, "etag", "(etag)"),
+		},
+	}, {
+		Table: tables.Stages(),
+		Create: fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+	id TEXT NOT NULL PRIMARY KEY,
+	created_at TEXT NOT NULL
+)`, quoteIdentifier(tables.Stages())),
+	}, {
+		Table: tables.StagedObjects(),
+		Create: fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+	stage_id TEXT NOT NULL,
+	path TEXT NOT NULL,
+	content_type TEXT NOT NULL,
+	etag TEXT NOT NULL,
+	file_size_bytes INTEGER NOT NULL,
+	created_at TEXT NOT NULL,
+	content_encoding TEXT,
+	decoded_size_bytes INTEGER,
+	filename TEXT,
+	storage_backend TEXT,
+	user_metadata TEXT,
+	cache_control TEXT,
+	PRIMARY KEY (stage_id, path)
+)`, quoteIdentifier(tables.StagedObjects())),
+		Columns: addColumns(tables.StagedObjects(), stagedObjectsAddedColumns),
+	}, {
+		Table: tables.Changes(),
+		Create: fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+	seq INTEGER PRIMARY KEY AUTOINCREMENT,
+	kind TEXT NOT NULL,
+	path TEXT NOT NULL,
+	etag TEXT NOT NULL,
+	file_size_bytes INTEGER NOT NULL,
+	created_at TEXT NOT NULL,
+	previous_etag TEXT,
+	actor TEXT
+)`, quoteIdentifier(tables.Changes())),
+		Columns: addColumns(tables.Changes(), changesAddedColumns),
+		Indexes: []internal.Statement{
+			createIndex(tables.Changes(), "created_at", "(created_at)"),
+			createIndex(tables.Changes(), "path", "(path, seq)"),
+		},
+	}, {
+		Table: tables.Settings(),
+		Create: fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+	key TEXT NOT NULL PRIMARY KEY,
+	value TEXT NOT NULL,
+	updated_at TEXT NOT NULL
+)`, quoteIdentifier(tables.Settings())),
+	}, {
+		Table: tables.Usage(),
+		Create: fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	taken_at TEXT NOT NULL,
+	change_seq INTEGER NOT NULL,
+	complete INTEGER NOT NULL
+)`, quoteIdentifier(tables.Usage())),
+		Indexes: []internal.Statement{
+			createIndex(tables.Usage(), "taken_at", "(taken_at)"),
+		},
+	}, {
+		Table: tables.UsagePrefixes(),
+		Create: fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+	snapshot_id INTEGER NOT NULL,
+	prefix TEXT NOT NULL,
+	objects INTEGER NOT NULL,
+	bytes INTEGER NOT NULL,
+	objects_added INTEGER NOT NULL,
+	bytes_added INTEGER NOT NULL,
+	PRIMARY KEY (snapshot_id, prefix)
+)`, quoteIdentifier(tables.UsagePrefixes())),
+	}, {
+		Table: tables.AccessKeys(),
+		Create: fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+	access_key TEXT NOT NULL PRIMARY KEY,
+	created_at TEXT NOT NULL,
+	last_used_at TEXT,
+	request_count INTEGER NOT NULL,
+	deprecated_at TEXT,
+	replaced_by TEXT,
+	revoked_at TEXT
+)`, quoteIdentifier(tables.AccessKeys())),
+	}, {
+		Table: tables.WriteIntents(),
+		Create: fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+	id TEXT NOT NULL PRIMARY KEY,
+	path TEXT NOT NULL,
+	content_type TEXT NOT NULL,
+	content_encoding TEXT,
+	filename TEXT,
+	object_id TEXT,
+	started_at TEXT NOT NULL,
+	user_metadata TEXT,
+	cache_control TEXT
+)`, quoteIdentifier(tables.WriteIntents())),
+		Columns: addColumns(tables.WriteIntents(), writeIntentsAddedColumns),
+	}, {
+		Table: tables.Uploads(),
+		Create: fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+	id TEXT NOT NULL PRIMARY KEY,
+	path TEXT NOT NULL,
+	content_type TEXT NOT NULL,
+	content_encoding TEXT,
+	filename TEXT,
+	created_at TEXT NOT NULL,
+	user_metadata TEXT,
+	cache_control TEXT
+)`, quoteIdentifier(tables.Uploads())),
+		Columns: addColumns(tables.Uploads(), uploadsAddedColumns),
+	}, {
+		Table: tables.UploadParts(),
+		Create: fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+	upload_id TEXT NOT NULL,
+	part_number INTEGER NOT NULL,
+	etag TEXT NOT NULL,
+	size_bytes INTEGER NOT NULL,
+	uploaded_at TEXT NOT NULL,
+	PRIMARY KEY (upload_id, part_number)
+)`, quoteIdentifier(tables.UploadParts())),
+	}}
+}
+
+// addColumns returns the statements adding columns to tableName. SQLite has
+// no ADD COLUMN IF NOT EXISTS; addMissingColumns runs only those needed.
+func addColumns(tableName string, columns []columnDef) []internal.Statement {
+	statements := make([]internal.Statement, len(columns))
+	for i, col := range columns {
+		statements[i] = internal.Statement{
+			Name: col.name,
+			SQL:  fmt.Sprintf(`ALTER TABLE %s ADD COLUMN %s %s`, quoteIdentifier(tableName), quoteIdentifier(col.name), col.definition),
+		}
 	}
+	return statements
+}
 
-	indexSQL = fmt.Sprintf(`CREATE INDEX IF NOT EXISTS %s ON %s (created_at, path)`, indexActiveList, quotedTable)
-	if _, err := db.ExecContext(ctx, indexSQL); err != nil {
-		return fmt.Errorf("create index active_list: %w", err)
+// createIndex returns the statement creating index idx_<table>_<suffix>
+// on the given columns.
+func createIndex(table, suffix, columns string) internal.Statement {
+	name := fmt.Sprintf("idx_%s_%s", table, suffix)
+	return internal.Statement{
+		Name: name,
+		SQL:  fmt.Sprintf(`CREATE INDEX IF NOT EXISTS %s ON %s %s`, quoteIdentifier(name), quoteIdentifier(table), columns),
 	}
+}
 
+// migrateTable creates the table if needed, then adds the columns and
+// indexes it lacks.
+func migrateTable(ctx context.Context, db *sql.DB, ddl internal.TableDDL) error {
+	if _, err := db.ExecContext(ctx, ddl.Create); err != nil {
+		return fmt.Errorf("create table %s: %w", ddl.Table, err)
+	}
+	if err := addMissingColumns(ctx, db, ddl.Table, ddl.Columns); err != nil {
+		return fmt.Errorf("add columns %s: %w", ddl.Table, err)
+	}
+	for _, index := range ddl.Indexes {
+		if _, err := db.ExecContext(ctx, index.SQL); err != nil {
+			return fmt.Errorf("create index %s: %w", index.Name, err)
+		}
+	}
 	return nil
 }
+
+// changeColumn returns a manual step: SQLite cannot alter a column in
+// place, so changing one means rebuilding the table, which may lose data.
+func changeColumn(table string, c internal.ColumnDiff) []internal.MigrationStep {
+	return []internal.MigrationStep{{
+		Table:       table,
+		Description: fmt.Sprintf("change column %s from %s to %s (rebuild the table)", c.Name, c.Actual, c.Expected),
+		Destructive: true,
+	}}
+}
@@ -0,0 +1,187 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sagarc03/stowry"
+	"github.com/sagarc03/stowry/database/internal"
+)
+
+func (r *repo) CreateMultipartUpload(ctx context.Context, upload stowry.MultipartUpload) (_ stowry.MultipartUpload, err error) {
+	defer func() { err = internal.ContextError(ctx, err) }()
+
+	upload.ID = uuid.New()
+	upload.CreatedAt = time.Now().UTC()
+	query := fmt.Sprintf( //nolint:gosec // G201: table name is validated
+		`INSERT INTO %s (id, path, content_type, content_encoding, filename, created_at, user_metadata, cache_control)
+		VALUES (?, ?, ?, NULLIF(?, ''), NULLIF(?, ''), ?, NULLIF(?, ''), NULLIF(?, ''))`, r.uploadsTable)
+
+	// Fixed-width times compare correctly as text in StaleMultipartUploads
+	_, err = r.db.ExecContext(ctx, query, upload.ID.String(), upload.Path, upload.ContentType,
+		upload.ContentEncoding, upload.Filename, upload.CreatedAt.Format(usageTimeFormat),
+		internal.EncodeUserMetadata(upload.UserMetadata), upload.CacheControl)
+	if err != nil {
+		return stowry.MultipartUpload{}, fmt.Errorf("create multipart upload %s: %w", upload.Path, err)
+	}
+	return upload, nil
+}
+
+func (r *repo) GetMultipartUpload(ctx context.Context, id uuid.UUID) (_ stowry.MultipartUpload, _ []stowry.MultipartPart, err error) {
+	defer func() { err = internal.ContextError(ctx, err) }()
+
+	query := fmt.Sprintf( //nolint:gosec // G201: table name is validated
+		`SELECT id, path, content_type, COALESCE(content_encoding, ''), COALESCE(filename, ''), created_at, user_metadata, COALESCE(cache_control, '')
+		FROM %s WHERE id = ?`, r.uploadsTable)
+
+	upload, err := scanMultipartUpload(r.db.QueryRowContext(ctx, query, id.String()))
+	if errors.Is(err, sql.ErrNoRows) {
+		return stowry.MultipartUpload{}, nil, fmt.Errorf("get multipart upload %s: %w", id, stowry.ErrNotFound)
+	}
+	if err != nil {
+		return stowry.MultipartUpload{}, nil, fmt.Errorf("get multipart upload %s: %w", id, err)
+	}
+
+	partsQuery := fmt.Sprintf( //nolint:gosec // G201: table name is validated
+		`SELECT part_number, etag, size_bytes, uploaded_at
+		FROM %s WHERE upload_id = ?
+		ORDER BY part_number`, r.uploadPartsTable)
+
+	rows, err := r.db.QueryContext(ctx, partsQuery, id.String())
+	if err != nil {
+		return stowry.MultipartUpload{}, nil, fmt.Errorf("get multipart upload %s: parts: %w", id, err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	parts := []stowry.MultipartPart{}
+	for rows.Next() {
+		var part stowry.MultipartPart
+		var uploadedAt string
+		if err := rows.Scan(&part.Number, &part.ETag, &part.Size, &uploadedAt); err != nil {
+			return stowry.MultipartUpload{}, nil, fmt.Errorf("get multipart upload %s: scan part: %w", id, err)
+		}
+		if part.UploadedAt, err = time.Parse(time.RFC3339Nano, uploadedAt); err != nil {
+			return stowry.MultipartUpload{}, nil, fmt.Errorf("get multipart upload %s: parse uploaded_at: %w", id, err)
+		}
+		parts = append(parts, part)
+	}
+	if err := rows.Err(); err != nil {
+		return stowry.MultipartUpload{}, nil, fmt.Errorf("get multipart upload %s: parts: %w", id, err)
+	}
+	return upload, parts, nil
+}
+
+func (r *repo) AddMultipartPart(ctx context.Context, id uuid.UUID, part stowry.MultipartPart) (err error) {
+	defer func() { err = internal.ContextError(ctx, err) }()
+
+	// The SELECT yields no row for an unknown upload, so nothing is inserted
+	query := fmt.Sprintf( //nolint:gosec // G201: table names are validated
+		`INSERT INTO %s (upload_id, part_number, etag, size_bytes, uploaded_at)
+		SELECT id, ?, ?, ?, ? FROM %s WHERE id = ?
+		ON CONFLICT (upload_id, part_number) DO UPDATE
+		SET etag = excluded.etag,
+			size_bytes = excluded.size_bytes,
+			uploaded_at = excluded.uploaded_at`, r.uploadPartsTable, r.uploadsTable)
+
+	result, err := r.db.ExecContext(ctx, query, part.Number, part.ETag, part.Size,
+		part.UploadedAt.UTC().Format(time.RFC3339Nano), id.String())
+	if err != nil {
+		return fmt.Errorf("add multipart part %d: %w", part.Number, err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("add multipart part %d: rows affected: %w", part.Number, err)
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("add multipart part %d: upload %s: %w", part.Number, id, stowry.ErrNotFound)
+	}
+	return nil
+}
+
+func (r *repo) DeleteMultipartUpload(ctx context.Context, id uuid.UUID) (err error) {
+	defer func() { err = internal.ContextError(ctx, err) }()
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("delete multipart upload: begin: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if _, err := tx.ExecContext(ctx, fmt.Sprintf( //nolint:gosec // G201: table name is validated
+		`DELETE FROM %s WHERE upload_id = ?`, r.uploadPartsTable), id.String()); err != nil {
+		return fmt.Errorf("delete multipart upload: parts: %w", err)
+	}
+
+	result, err := tx.ExecContext(ctx, fmt.Sprintf( //nolint:gosec // G201: table name is validated
+		`DELETE FROM %s WHERE id = ?`, r.uploadsTable), id.String())
+	if err != nil {
+		return fmt.Errorf("delete multipart upload: %w", err)
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("delete multipart upload: rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("delete multipart upload %s: %w", id, stowry.ErrNotFound)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("delete multipart upload: commit: %w", err)
+	}
+	return nil
+}
+
+func (r *repo) StaleMultipartUploads(ctx context.Context, before time.Time, limit int) (_ []stowry.MultipartUpload, err error) {
+	defer func() { err = internal.ContextError(ctx, err) }()
+
+	if limit <= 0 {
+		limit = -1 // No limit in SQLite
+	}
+	query := fmt.Sprintf( //nolint:gosec // G201: table name is validated
+		`SELECT id, path, content_type, COALESCE(content_encoding, ''), COALESCE(filename, ''), created_at, user_metadata, COALESCE(cache_control, '')
+		FROM %s
+		WHERE created_at < ?
+		ORDER BY created_at, id
+		LIMIT ?`, r.uploadsTable)
+
+	rows, err := r.db.QueryContext(ctx, query, before.UTC().Format(usageTimeFormat), limit)
+	if err != nil {
+		return nil, fmt.Errorf("list stale multipart uploads: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	uploads := []stowry.MultipartUpload{}
+	for rows.Next() {
+		upload, err := scanMultipartUpload(rows)
+		if err != nil {
+			return nil, fmt.Errorf("list stale multipart uploads: %w", err)
+		}
+		uploads = append(uploads, upload)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("list stale multipart uploads: %w", err)
+	}
+	return uploads, nil
+}
+
+func scanMultipartUpload(row interface{ Scan(...any) error }) (stowry.MultipartUpload, error) {
+	var upload stowry.MultipartUpload
+	var id, createdAt string
+	if err := row.Scan(&id, &upload.Path, &upload.ContentType, &upload.ContentEncoding, &upload.Filename, &createdAt,
+		internal.UserMetadata{Dest: &upload.UserMetadata}, &upload.CacheControl); err != nil {
+		return stowry.MultipartUpload{}, err
+	}
+	var err error
+	if upload.ID, err = uuid.Parse(id); err != nil {
+		return stowry.MultipartUpload{}, fmt.Errorf("parse id: %w", err)
+	}
+	if upload.CreatedAt, err = time.Parse(usageTimeFormat, createdAt); err != nil {
+		return stowry.MultipartUpload{}, fmt.Errorf("parse created_at: %w", err)
+	}
+	return upload, nil
+}
@@ -0,0 +1,80 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sagarc03/stowry"
+	"github.com/sagarc03/stowry/database/internal"
+)
+
+// DeleteHard removes the row and records the change in one transaction.
+func (r *repo) DeleteHard(ctx context.Context, path string) (_ stowry.MetaData, err error) {
+	defer func() { err = internal.ContextError(ctx, err) }()
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return stowry.MetaData{}, fmt.Errorf("delete hard: begin: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	query := fmt.Sprintf( //nolint:gosec // G201: table name is validated
+		`DELETE FROM %s
+		WHERE path = ?
+		RETURNING id, path, content_type, etag, file_size_bytes, created_at, updated_at,
+			COALESCE(download_count, 0), last_accessed_at, deleted_at,
+			COALESCE(content_encoding, ''), decoded_size_bytes, COALESCE(filename, ''), user_metadata, COALESCE(cache_control, ''), COALESCE(storage_backend, ''), quarantined_at`, r.tableName)
+
+	var m stowry.MetaData
+	var idStr, createdAt, updatedAt string
+	var lastAccessedAt, deletedAt, quarantinedAt sql.NullString
+	var decodedSize sql.NullInt64
+
+	err = tx.QueryRowContext(ctx, query, path).Scan(
+		&idStr, &m.Path, &m.ContentType, &m.Etag, &m.FileSizeBytes, &createdAt, &updatedAt,
+		&m.DownloadCount, &lastAccessedAt, &deletedAt, &m.ContentEncoding, &decodedSize, &m.Filename, internal.UserMetadata{Dest: &m.UserMetadata}, &m.CacheControl, &m.StorageBackend,
+		&quarantinedAt,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return stowry.MetaData{}, fmt.Errorf("delete hard: %w", stowry.ErrNotFound)
+		}
+		return stowry.MetaData{}, fmt.Errorf("delete hard: %w", err)
+	}
+
+	if m.ID, err = uuid.Parse(idStr); err != nil {
+		return stowry.MetaData{}, fmt.Errorf("delete hard: parse uuid: %w", err)
+	}
+	if m.CreatedAt, err = time.Parse(time.RFC3339Nano, createdAt); err != nil {
+		return stowry.MetaData{}, fmt.Errorf("delete hard: parse created_at: %w", err)
+	}
+	if m.UpdatedAt, err = time.Parse(time.RFC3339Nano, updatedAt); err != nil {
+		return stowry.MetaData{}, fmt.Errorf("delete hard: parse updated_at: %w", err)
+	}
+	if m.LastAccessedAt, err = parseNullTime(lastAccessedAt); err != nil {
+		return stowry.MetaData{}, fmt.Errorf("delete hard: parse last_accessed_at: %w", err)
+	}
+	if m.DeletedAt, err = parseNullTime(deletedAt); err != nil {
+		return stowry.MetaData{}, fmt.Errorf("delete hard: parse deleted_at: %w", err)
+	}
+	if m.QuarantinedAt, err = parseNullTime(quarantinedAt); err != nil {
+		return stowry.MetaData{}, fmt.Errorf("delete hard: parse quarantined_at: %w", err)
+	}
+	m.DecodedSizeBytes = nullInt64(decodedSize)
+
+	// A soft-deleted object already had its delete recorded
+	if m.DeletedAt == nil {
+		if err := r.recordChange(ctx, tx, stowry.ChangeDelete, path, m.Etag, m.FileSizeBytes, time.Now().UTC(), ""); err != nil {
+			return stowry.MetaData{}, fmt.Errorf("delete hard: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return stowry.MetaData{}, fmt.Errorf("delete hard: commit: %w", err)
+	}
+	return m, nil
+}
@@ -0,0 +1,76 @@
+package sqlite
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/sagarc03/stowry"
+	"github.com/sagarc03/stowry/database/internal"
+	sqlitedriver "modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+func (r *repo) Rename(ctx context.Context, oldPath, newPath, backend string) (_ stowry.MetaData, err error) {
+	defer func() { err = internal.ContextError(ctx, err) }()
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return stowry.MetaData{}, fmt.Errorf("rename: begin: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	// A soft-deleted row would hold newPath against the unique constraint
+	if _, err := tx.ExecContext(ctx, fmt.Sprintf( //nolint:gosec // G201: table name is validated
+		`DELETE FROM %s WHERE path = ? AND deleted_at IS NOT NULL`, r.tableName), newPath); err != nil {
+		return stowry.MetaData{}, fmt.Errorf("rename: drop deleted entry: %w", err)
+	}
+
+	now := time.Now().UTC()
+	result, err := tx.ExecContext(ctx, fmt.Sprintf( //nolint:gosec // G201: table name is validated
+		`UPDATE %s
+		SET path = ?, updated_at = ?, storage_backend = ?
+		WHERE path = ? AND deleted_at IS NULL`, r.tableName), newPath, now.Format(time.RFC3339Nano), backend, oldPath)
+	if err != nil {
+		return stowry.MetaData{}, fmt.Errorf("rename: %w", classifyRenameError(err))
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return stowry.MetaData{}, fmt.Errorf("rename: rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return stowry.MetaData{}, fmt.Errorf("rename: %w", stowry.ErrNotFound)
+	}
+
+	found, err := r.queryMetaData(ctx, tx, r.activeQuery(), []any{newPath})
+	if err != nil {
+		return stowry.MetaData{}, fmt.Errorf("rename: read renamed: %w", err)
+	}
+	if len(found) == 0 {
+		return stowry.MetaData{}, fmt.Errorf("rename: %w", stowry.ErrNotFound)
+	}
+	m := found[0]
+
+	if err := r.recordChange(ctx, tx, stowry.ChangeDelete, oldPath, m.Etag, m.FileSizeBytes, now, ""); err != nil {
+		return stowry.MetaData{}, fmt.Errorf("rename: %w", err)
+	}
+	if err := r.recordChange(ctx, tx, stowry.ChangeCreate, newPath, m.Etag, m.FileSizeBytes, now, ""); err != nil {
+		return stowry.MetaData{}, fmt.Errorf("rename: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return stowry.MetaData{}, fmt.Errorf("rename: commit: %w", err)
+	}
+	return m, nil
+}
+
+// classifyRenameError marks the unique-constraint violation of a rename onto
+// the path of an active object as stowry.ErrAlreadyExists.
+func classifyRenameError(err error) error {
+	var sqliteErr *sqlitedriver.Error
+	if errors.As(err, &sqliteErr) && sqliteErr.Code() == sqlite3.SQLITE_CONSTRAINT_UNIQUE {
+		return fmt.Errorf("%w: %w", stowry.ErrAlreadyExists, err)
+	}
+	return err
+}
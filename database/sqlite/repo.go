@@ -6,30 +6,47 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
+	"slices"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
 	"github.com/sagarc03/stowry"
 	"github.com/sagarc03/stowry/database/internal"
+	sqlitedriver "modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
 )
 
 type repo struct {
-	db        *sql.DB
-	tableName string
+	db                 *sql.DB
+	tableName          string
+	stagesTable        string
+	stagedObjectsTable string
+	changesTable       string
+	settingsTable      string
+	usageTable         string
+	usagePrefixesTable string
+	accessKeysTable    string
+	writeIntentsTable  string
+	uploadsTable       string
+	uploadPartsTable   string
+	idScheme           stowry.IDScheme
 }
 
-func (r *repo) Get(ctx context.Context, path string) (stowry.MetaData, error) {
-	query := fmt.Sprintf( //nolint:gosec // G201: table name is validated
-		`SELECT id, path, content_type, etag, file_size_bytes, created_at, updated_at
-		FROM %s
-		WHERE path = ? AND deleted_at IS NULL`, r.tableName)
+func (r *repo) Get(ctx context.Context, path string) (_ stowry.MetaData, err error) {
+	defer func() { err = internal.ContextError(ctx, err) }()
+
+	query := r.activeQuery()
 
 	var m stowry.MetaData
 	var idStr string
 	var createdAt, updatedAt string
+	var lastAccessedAt sql.NullString
+	var decodedSize sql.NullInt64
 
-	err := r.db.QueryRowContext(ctx, query, path).Scan(
+	err = r.db.QueryRowContext(ctx, query, path).Scan(
 		&idStr, &m.Path, &m.ContentType, &m.Etag, &m.FileSizeBytes, &createdAt, &updatedAt,
+		&m.DownloadCount, &lastAccessedAt, &m.ContentEncoding, &decodedSize, &m.Filename, internal.UserMetadata{Dest: &m.UserMetadata}, &m.CacheControl, &m.StorageBackend,
 	)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
@@ -53,120 +70,501 @@ func (r *repo) Get(ctx context.Context, path string) (stowry.MetaData, error) {
 		return stowry.MetaData{}, fmt.Errorf("get: parse updated_at: %w", err)
 	}
 
+	m.LastAccessedAt, err = parseNullTime(lastAccessedAt)
+	if err != nil {
+		return stowry.MetaData{}, fmt.Errorf("get: parse last_accessed_at: %w", err)
+	}
+	m.DecodedSizeBytes = nullInt64(decodedSize)
+
 	return m, nil
 }
 
-func (r *repo) Upsert(ctx context.Context, entry stowry.ObjectEntry) (stowry.MetaData, bool, error) {
-	newID := uuid.New()
-	now := time.Now().UTC()
-	nowStr := now.Format(time.RFC3339Nano)
+func (r *repo) GetDeleted(ctx context.Context, path string) (_ stowry.MetaData, err error) {
+	defer func() { err = internal.ContextError(ctx, err) }()
 
-	// Use INSERT ... ON CONFLICT for atomic upsert (requires SQLite 3.24+)
 	query := fmt.Sprintf( //nolint:gosec // G201: table name is validated
-		`INSERT INTO %s (id, path, content_type, etag, file_size_bytes, created_at, updated_at)
-		VALUES (?, ?, ?, ?, ?, ?, ?)
+		`SELECT id, path, content_type, etag, file_size_bytes, created_at, updated_at,
+			COALESCE(download_count, 0), last_accessed_at, deleted_at, cleaned_up_at,
+			COALESCE(content_encoding, ''), decoded_size_bytes, COALESCE(filename, ''), user_metadata, COALESCE(cache_control, ''), COALESCE(storage_backend, ''), quarantined_at
+		FROM %s
+		WHERE path = ? AND deleted_at IS NOT NULL`, r.tableName)
+
+	var m stowry.MetaData
+	var idStr string
+	var createdAt, updatedAt string
+	var lastAccessedAt, deletedAt, cleanedUpAt, quarantinedAt sql.NullString
+	var decodedSize sql.NullInt64
+
+	err = r.db.QueryRowContext(ctx, query, path).Scan(
+		&idStr, &m.Path, &m.ContentType, &m.Etag, &m.FileSizeBytes, &createdAt, &updatedAt,
+		&m.DownloadCount, &lastAccessedAt, &deletedAt, &cleanedUpAt, &m.ContentEncoding, &decodedSize, &m.Filename, internal.UserMetadata{Dest: &m.UserMetadata}, &m.CacheControl, &m.StorageBackend,
+		&quarantinedAt,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return stowry.MetaData{}, stowry.ErrNotFound
+		}
+		return stowry.MetaData{}, fmt.Errorf("get deleted: %w", err)
+	}
+	if cleanedUpAt.Valid {
+		return stowry.MetaData{}, fmt.Errorf("get deleted: %w", stowry.ErrGone)
+	}
+
+	m.ID, err = uuid.Parse(idStr)
+	if err != nil {
+		return stowry.MetaData{}, fmt.Errorf("get deleted: parse uuid: %w", err)
+	}
+
+	m.CreatedAt, err = time.Parse(time.RFC3339Nano, createdAt)
+	if err != nil {
+		return stowry.MetaData{}, fmt.Errorf("get deleted: parse created_at: %w", err)
+	}
+
+	m.UpdatedAt, err = time.Parse(time.RFC3339Nano, updatedAt)
+	if err != nil {
+		return stowry.MetaData{}, fmt.Errorf("get deleted: parse updated_at: %w", err)
+	}
+
+	m.LastAccessedAt, err = parseNullTime(lastAccessedAt)
+	if err != nil {
+		return stowry.MetaData{}, fmt.Errorf("get deleted: parse last_accessed_at: %w", err)
+	}
+
+	m.DeletedAt, err = parseNullTime(deletedAt)
+	if err != nil {
+		return stowry.MetaData{}, fmt.Errorf("get deleted: parse deleted_at: %w", err)
+	}
+
+	m.QuarantinedAt, err = parseNullTime(quarantinedAt)
+	if err != nil {
+		return stowry.MetaData{}, fmt.Errorf("get deleted: parse quarantined_at: %w", err)
+	}
+	m.DecodedSizeBytes = nullInt64(decodedSize)
+
+	return m, nil
+}
+
+// Upsert records the change in the same transaction as the metadata.
+func (r *repo) GetMany(ctx context.Context, paths []string) (_ []stowry.MetaData, err error) {
+	defer func() { err = internal.ContextError(ctx, err) }()
+
+	var items []stowry.MetaData
+
+	for chunk := range slices.Chunk(paths, inListChunk) {
+		query := fmt.Sprintf( //nolint:gosec // G201: table name is validated
+			`SELECT id, path, content_type, etag, file_size_bytes, created_at, updated_at,
+				COALESCE(download_count, 0), last_accessed_at,
+				COALESCE(content_encoding, ''), decoded_size_bytes, COALESCE(filename, ''), user_metadata, COALESCE(cache_control, ''), COALESCE(storage_backend, '')
+			FROM %s
+			WHERE deleted_at IS NULL AND path IN (?%s)`, r.tableName, strings.Repeat(", ?", len(chunk)-1))
+
+		args := make([]any, len(chunk))
+		for i, p := range chunk {
+			args[i] = p
+		}
+
+		found, err := r.queryMetaData(ctx, r.db, query, args)
+		if err != nil {
+			return nil, fmt.Errorf("get many: %w", err)
+		}
+		items = append(items, found...)
+	}
+
+	return items, nil
+}
+
+// GetByETag orders by julianday, not the updated_at text, because
+// RFC 3339 timestamps with trimmed fractions do not sort as text.
+func (r *repo) GetByETag(ctx context.Context, etag string) (_ stowry.MetaData, err error) {
+	defer func() { err = internal.ContextError(ctx, err) }()
+
+	query := fmt.Sprintf( //nolint:gosec // G201: table name is validated
+		`SELECT id, path, content_type, etag, file_size_bytes, created_at, updated_at,
+			COALESCE(download_count, 0), last_accessed_at,
+			COALESCE(content_encoding, ''), decoded_size_bytes, COALESCE(filename, ''), user_metadata, COALESCE(cache_control, ''), COALESCE(storage_backend, '')
+		FROM %s
+		WHERE etag = ? AND deleted_at IS NULL
+		ORDER BY julianday(updated_at) DESC, updated_at DESC, path
+		LIMIT 1`, r.tableName)
+
+	found, err := r.queryMetaData(ctx, r.db, query, []any{etag})
+	if err != nil {
+		return stowry.MetaData{}, fmt.Errorf("get by etag: %w", err)
+	}
+	if len(found) == 0 {
+		return stowry.MetaData{}, stowry.ErrNotFound
+	}
+	return found[0], nil
+}
+
+// activeQuery selects the columns of Get for the active entry at a path.
+func (r *repo) activeQuery() string {
+	return fmt.Sprintf( //nolint:gosec // G201: table name is validated
+		`SELECT id, path, content_type, etag, file_size_bytes, created_at, updated_at,
+			COALESCE(download_count, 0), last_accessed_at,
+			COALESCE(content_encoding, ''), decoded_size_bytes, COALESCE(filename, ''), user_metadata, COALESCE(cache_control, ''), COALESCE(storage_backend, '')
+		FROM %s
+		WHERE path = ? AND deleted_at IS NULL`, r.tableName)
+}
+
+// prefixCondition selects the paths starting with the prefix passed as
+// prefixArgs. It compares bytes instead of using LIKE, which SQLite matches
+// without regard to ASCII case, and can use the index on path.
+const prefixCondition = `path >= ? AND (? IS NULL OR path < ?)`
+
+// prefixArgs returns the arguments of prefixCondition for prefix.
+func prefixArgs(prefix string) []any {
+	bound, ok := internal.PrefixUpperBound(prefix)
+	upper := sql.NullString{String: bound, Valid: ok}
+	return []any{prefix, upper, upper}
+}
+
+// queryMetaData runs query through q, which may be a transaction; query
+// selects the columns of Get. It scans every row.
+func (r *repo) queryMetaData(ctx context.Context, q querier, query string, args []any) ([]stowry.MetaData, error) {
+	rows, err := q.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	var items []stowry.MetaData
+	for rows.Next() {
+		var m stowry.MetaData
+		var idStr, createdAt, updatedAt string
+		var lastAccessedAt sql.NullString
+		var decodedSize sql.NullInt64
+
+		if err := rows.Scan(&idStr, &m.Path, &m.ContentType, &m.Etag, &m.FileSizeBytes, &createdAt, &updatedAt,
+			&m.DownloadCount, &lastAccessedAt, &m.ContentEncoding, &decodedSize, &m.Filename, internal.UserMetadata{Dest: &m.UserMetadata}, &m.CacheControl, &m.StorageBackend); err != nil {
+			return nil, fmt.Errorf("scan: %w", err)
+		}
+
+		if m.ID, err = uuid.Parse(idStr); err != nil {
+			return nil, fmt.Errorf("parse uuid: %w", err)
+		}
+		if m.CreatedAt, err = time.Parse(time.RFC3339Nano, createdAt); err != nil {
+			return nil, fmt.Errorf("parse created_at: %w", err)
+		}
+		if m.UpdatedAt, err = time.Parse(time.RFC3339Nano, updatedAt); err != nil {
+			return nil, fmt.Errorf("parse updated_at: %w", err)
+		}
+		if m.LastAccessedAt, err = parseNullTime(lastAccessedAt); err != nil {
+			return nil, fmt.Errorf("parse last_accessed_at: %w", err)
+		}
+		m.DecodedSizeBytes = nullInt64(decodedSize)
+
+		items = append(items, m)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows: %w", err)
+	}
+
+	return items, nil
+}
+
+// Upsert reads the entry it replaces before writing. Write transactions
+// begin immediately, so no other writer can change the row in between.
+func (r *repo) Upsert(ctx context.Context, entry stowry.ObjectEntry) (_ stowry.MetaData, _ *stowry.MetaData, err error) {
+	defer func() { err = internal.ContextError(ctx, err) }()
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return stowry.MetaData{}, nil, fmt.Errorf("upsert: begin: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	found, err := r.queryMetaData(ctx, tx, r.activeQuery(), []any{entry.Path})
+	if err != nil {
+		return stowry.MetaData{}, nil, fmt.Errorf("upsert: read previous: %w", err)
+	}
+	var previous *stowry.MetaData
+	var previousETag string
+	if len(found) > 0 {
+		previous = &found[0]
+		previousETag = previous.Etag
+	}
+
+	m, err := r.upsertRow(entry, func(args ...any) *sql.Row {
+		return tx.QueryRowContext(ctx, r.upsertQuery(), args...)
+	})
+	if err != nil {
+		return stowry.MetaData{}, nil, fmt.Errorf("upsert: %w", classifyUpsertError(err))
+	}
+
+	if err := r.recordChange(ctx, tx, stowry.ChangeCreate, m.Path, m.Etag, m.FileSizeBytes, m.UpdatedAt, previousETag); err != nil {
+		return stowry.MetaData{}, nil, fmt.Errorf("upsert: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return stowry.MetaData{}, nil, fmt.Errorf("upsert: commit: %w", err)
+	}
+	return m, previous, nil
+}
+
+// UpsertBatch writes each chunk of at most internal.MaxUpsertBatchSize entries
+// in its own transaction, reusing one prepared upsert statement per chunk.
+func (r *repo) UpsertBatch(ctx context.Context, entries []stowry.ObjectEntry) (_ []stowry.MetaData, err error) {
+	defer func() { err = internal.ContextError(ctx, err) }()
+
+	results := make([]stowry.MetaData, 0, len(entries))
+
+	for _, chunk := range internal.ChunkEntries(entries, internal.MaxUpsertBatchSize) {
+		written, err := r.upsertChunk(ctx, chunk)
+		if err != nil {
+			return nil, fmt.Errorf("upsert batch: %w", err)
+		}
+		results = append(results, written...)
+	}
+
+	return results, nil
+}
+
+func (r *repo) upsertChunk(ctx context.Context, chunk []stowry.ObjectEntry) ([]stowry.MetaData, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("begin: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	stmt, err := tx.PrepareContext(ctx, r.upsertQuery())
+	if err != nil {
+		return nil, fmt.Errorf("prepare: %w", err)
+	}
+	defer func() { _ = stmt.Close() }()
+
+	results := make([]stowry.MetaData, 0, len(chunk))
+	for _, entry := range chunk {
+		entry.ID = uuid.Nil
+		m, err := r.upsertRow(entry, func(args ...any) *sql.Row {
+			return stmt.QueryRowContext(ctx, args...)
+		})
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", entry.Path, classifyWriteError(err))
+		}
+		if err := r.recordChange(ctx, tx, stowry.ChangeCreate, m.Path, m.Etag, m.FileSizeBytes, m.UpdatedAt, ""); err != nil {
+			return nil, fmt.Errorf("%s: %w", entry.Path, err)
+		}
+		results = append(results, m)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("commit: %w", err)
+	}
+
+	return results, nil
+}
+
+// upsertQuery uses INSERT ... ON CONFLICT for atomic upsert (requires SQLite 3.24+).
+// An existing row keeps its ID, unless ?12 marks the ID as client-supplied:
+// then a soft-deleted row takes it and a live row with another ID is left
+// alone, so no row is returned.
+func (r *repo) upsertQuery() string {
+	return fmt.Sprintf( //nolint:gosec // G201: table name is validated
+		`INSERT INTO %[1]s (id, path, content_type, etag, file_size_bytes, created_at, updated_at,
+			content_encoding, decoded_size_bytes, filename, storage_backend, user_metadata, cache_control)
+		VALUES (?, ?, ?, ?, ?, ?, ?, NULLIF(?, ''), ?, NULLIF(?, ''), NULLIF(?, ''), NULLIF(?13, ''), NULLIF(?14, ''))
 		ON CONFLICT (path) DO UPDATE
-		SET content_type = excluded.content_type,
+		SET id = CASE WHEN ?12 AND %[1]s.deleted_at IS NOT NULL THEN excluded.id ELSE %[1]s.id END,
+			content_type = excluded.content_type,
 			etag = excluded.etag,
 			file_size_bytes = excluded.file_size_bytes,
+			content_encoding = excluded.content_encoding,
+			decoded_size_bytes = excluded.decoded_size_bytes,
+			filename = excluded.filename,
+			user_metadata = excluded.user_metadata,
+			cache_control = excluded.cache_control,
+			storage_backend = excluded.storage_backend,
 			updated_at = excluded.updated_at,
 			deleted_at = NULL,
-			cleaned_up_at = NULL
+			cleaned_up_at = NULL,
+			quarantined_at = NULL,
+			cleanup_retry_at = NULL
+		WHERE NOT ?12 OR %[1]s.deleted_at IS NOT NULL OR %[1]s.id = excluded.id
 		RETURNING id, created_at`, r.tableName)
+}
+
+// upsertRow runs the upsert query through queryRow, with entry.ID or a new ID
+// of the repository's scheme.
+func (r *repo) upsertRow(entry stowry.ObjectEntry, queryRow func(args ...any) *sql.Row) (stowry.MetaData, error) {
+	newID, supplied := entry.ID, entry.ID != uuid.Nil
+	if !supplied {
+		var err error
+		if newID, err = r.idScheme.NewID(); err != nil {
+			return stowry.MetaData{}, fmt.Errorf("generate id: %w", err)
+		}
+	}
+	now := time.Now().UTC()
+	nowStr := now.Format(time.RFC3339Nano)
 
 	var m stowry.MetaData
 	var idStr, createdAtStr string
 
-	err := r.db.QueryRowContext(ctx, query,
+	err := queryRow(
 		newID.String(), entry.Path, entry.ContentType, entry.ETag, entry.Size, nowStr, nowStr,
+		entry.ContentEncoding, entry.DecodedSize, entry.Filename, entry.StorageBackend, supplied,
+		internal.EncodeUserMetadata(entry.UserMetadata), entry.CacheControl,
 	).Scan(&idStr, &createdAtStr)
+	if errors.Is(err, sql.ErrNoRows) {
+		return stowry.MetaData{}, fmt.Errorf("%s has another id: %w", entry.Path, stowry.ErrIDConflict)
+	}
 	if err != nil {
-		return stowry.MetaData{}, false, fmt.Errorf("upsert: %w", err)
+		return stowry.MetaData{}, err
 	}
 
 	m.ID, err = uuid.Parse(idStr)
 	if err != nil {
-		return stowry.MetaData{}, false, fmt.Errorf("upsert: parse id: %w", err)
+		return stowry.MetaData{}, fmt.Errorf("parse id: %w", err)
 	}
 
 	m.CreatedAt, err = time.Parse(time.RFC3339Nano, createdAtStr)
 	if err != nil {
-		return stowry.MetaData{}, false, fmt.Errorf("upsert: parse created_at: %w", err)
+		return stowry.MetaData{}, fmt.Errorf("parse created_at: %w", err)
 	}
 
-	// If the returned ID matches our newID, it was an insert
-	inserted := m.ID == newID
-
 	m.Path = entry.Path
 	m.ContentType = entry.ContentType
 	m.Etag = entry.ETag
 	m.FileSizeBytes = entry.Size
+	m.ContentEncoding = entry.ContentEncoding
+	m.DecodedSizeBytes = entry.DecodedSize
+	m.Filename = entry.Filename
+	m.UserMetadata = entry.UserMetadata
+	m.CacheControl = entry.CacheControl
+	m.StorageBackend = entry.StorageBackend
 	m.UpdatedAt = now
 
-	return m, inserted, nil
+	return m, nil
 }
 
-func (r *repo) Delete(ctx context.Context, path string) error {
-	now := time.Now().UTC().Format(time.RFC3339Nano)
+func (r *repo) Delete(ctx context.Context, path string) (err error) {
+	defer func() { err = internal.ContextError(ctx, err) }()
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("delete: begin: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	now := time.Now().UTC()
 	query := fmt.Sprintf( //nolint:gosec // G201: table name is validated
 		`UPDATE %s
 		SET deleted_at = ?
-		WHERE path = ? AND deleted_at IS NULL`, r.tableName)
+		WHERE path = ? AND deleted_at IS NULL
+		RETURNING etag, file_size_bytes`, r.tableName)
 
-	result, err := r.db.ExecContext(ctx, query, now, path)
+	var etag string
+	var size int64
+	err = tx.QueryRowContext(ctx, query, now.Format(time.RFC3339Nano), path).Scan(&etag, &size)
 	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("delete: %w", stowry.ErrNotFound)
+		}
 		return fmt.Errorf("delete: %w", err)
 	}
 
-	rowsAffected, err := result.RowsAffected()
+	if err := r.recordChange(ctx, tx, stowry.ChangeDelete, path, etag, size, now, ""); err != nil {
+		return fmt.Errorf("delete: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("delete: commit: %w", err)
+	}
+	return nil
+}
+
+// Quarantine soft-deletes like Delete, and flags the row so cleanup keeps
+// its file.
+func (r *repo) Quarantine(ctx context.Context, path, etag string) (err error) {
+	defer func() { err = internal.ContextError(ctx, err) }()
+
+	tx, err := r.db.BeginTx(ctx, nil)
 	if err != nil {
-		return fmt.Errorf("delete: rows affected: %w", err)
+		return fmt.Errorf("quarantine: begin: %w", err)
 	}
+	defer func() { _ = tx.Rollback() }()
 
-	if rowsAffected == 0 {
-		return fmt.Errorf("delete: %w", stowry.ErrNotFound)
+	now := time.Now().UTC()
+	nowStr := now.Format(time.RFC3339Nano)
+	query := fmt.Sprintf( //nolint:gosec // G201: table name is validated
+		`UPDATE %s
+		SET deleted_at = ?, quarantined_at = ?
+		WHERE path = ? AND etag = ? AND deleted_at IS NULL
+		RETURNING file_size_bytes`, r.tableName)
+
+	var size int64
+	err = tx.QueryRowContext(ctx, query, nowStr, nowStr, path, etag).Scan(&size)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("quarantine: %w", stowry.ErrNotFound)
+		}
+		return fmt.Errorf("quarantine: %w", err)
 	}
 
+	if err := r.recordChange(ctx, tx, stowry.ChangeDelete, path, etag, size, now, ""); err != nil {
+		return fmt.Errorf("quarantine: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("quarantine: commit: %w", err)
+	}
 	return nil
 }
 
-func (r *repo) List(ctx context.Context, q stowry.ListQuery) (stowry.ListResult, error) {
-	return r.listWithCondition(ctx, q, "deleted_at IS NULL", "list")
+func (r *repo) List(ctx context.Context, q stowry.ListQuery) (_ stowry.ListResult, err error) {
+	defer func() { err = internal.ContextError(ctx, err) }()
+
+	return r.listWithCondition(ctx, q, "deleted_at IS NULL", nil, "list")
 }
 
-func (r *repo) ListPendingCleanup(ctx context.Context, q stowry.ListQuery) (stowry.ListResult, error) {
-	return r.listWithCondition(ctx, q, "deleted_at IS NOT NULL AND cleaned_up_at IS NULL", "list pending cleanup")
+func (r *repo) ListPendingCleanup(ctx context.Context, q stowry.ListQuery) (_ stowry.ListResult, err error) {
+	defer func() { err = internal.ContextError(ctx, err) }()
+
+	condition := "deleted_at IS NOT NULL AND cleaned_up_at IS NULL AND quarantined_at IS NULL"
+	var conditionArgs []any
+	if !q.DeletedBefore.IsZero() {
+		condition += " AND deleted_at < ?"
+		conditionArgs = append(conditionArgs, q.DeletedBefore.UTC().Format(time.RFC3339Nano))
+	}
+	return r.listWithCondition(ctx, q, condition, conditionArgs, "list pending cleanup")
 }
 
-func (r *repo) listWithCondition(ctx context.Context, q stowry.ListQuery, whereCondition, opName string) (stowry.ListResult, error) {
+// listWithCondition lists the rows matching whereCondition, whose
+// placeholders are bound to conditionArgs, a page of q at a time.
+func (r *repo) listWithCondition(ctx context.Context, q stowry.ListQuery, whereCondition string, conditionArgs []any, opName string) (stowry.ListResult, error) {
 	cursor, err := internal.DecodeCursor(q.Cursor)
 	if err != nil {
 		return stowry.ListResult{}, fmt.Errorf("%s: %w", opName, err)
 	}
 
-	escapedPrefix := internal.EscapeLikePattern(q.PathPrefix)
-
 	var query string
 	var args []any
 
 	if q.Cursor == "" {
 		query = fmt.Sprintf(`
-			SELECT id, path, content_type, etag, file_size_bytes, created_at, updated_at
+			SELECT id, path, content_type, etag, file_size_bytes, created_at, updated_at,
+				COALESCE(download_count, 0), last_accessed_at,
+				COALESCE(content_encoding, ''), decoded_size_bytes, COALESCE(filename, ''), user_metadata, COALESCE(cache_control, ''), COALESCE(storage_backend, ''),
+				cleanup_retry_at, deleted_at
 			FROM %s
-			WHERE %s AND path LIKE ? || '%%' ESCAPE '\'
+			WHERE %s AND %s
 			ORDER BY created_at, path
 			LIMIT ?
-		`, r.tableName, whereCondition)
-		args = []any{escapedPrefix, q.Limit + 1}
+		`, r.tableName, whereCondition, prefixCondition)
+		args = append(append(conditionArgs, prefixArgs(q.PathPrefix)...), q.Limit+1)
 	} else {
 		query = fmt.Sprintf(`
-			SELECT id, path, content_type, etag, file_size_bytes, created_at, updated_at
+			SELECT id, path, content_type, etag, file_size_bytes, created_at, updated_at,
+				COALESCE(download_count, 0), last_accessed_at,
+				COALESCE(content_encoding, ''), decoded_size_bytes, COALESCE(filename, ''), user_metadata, COALESCE(cache_control, ''), COALESCE(storage_backend, ''),
+				cleanup_retry_at, deleted_at
 			FROM %s
-			WHERE %s AND path LIKE ? || '%%' ESCAPE '\' AND (created_at, path) > (?, ?)
+			WHERE %s AND %s AND (created_at, path) > (?, ?)
 			ORDER BY created_at, path
 			LIMIT ?
-		`, r.tableName, whereCondition)
-		args = []any{escapedPrefix, cursor.CreatedAt.Format(time.RFC3339Nano), cursor.Path, q.Limit + 1}
+		`, r.tableName, whereCondition, prefixCondition)
+		args = append(append(conditionArgs, prefixArgs(q.PathPrefix)...), cursor.CreatedAt.Format(time.RFC3339Nano), cursor.Path, q.Limit+1)
 	}
 
 	rows, err := r.db.QueryContext(ctx, query, args...)
@@ -179,8 +577,12 @@ func (r *repo) listWithCondition(ctx context.Context, q stowry.ListQuery, whereC
 	for rows.Next() {
 		var m stowry.MetaData
 		var idStr, createdAt, updatedAt string
+		var lastAccessedAt, cleanupRetryAt, deletedAt sql.NullString
+		var decodedSize sql.NullInt64
 
-		if scanErr := rows.Scan(&idStr, &m.Path, &m.ContentType, &m.Etag, &m.FileSizeBytes, &createdAt, &updatedAt); scanErr != nil {
+		if scanErr := rows.Scan(&idStr, &m.Path, &m.ContentType, &m.Etag, &m.FileSizeBytes, &createdAt, &updatedAt,
+			&m.DownloadCount, &lastAccessedAt, &m.ContentEncoding, &decodedSize, &m.Filename, internal.UserMetadata{Dest: &m.UserMetadata}, &m.CacheControl, &m.StorageBackend,
+			&cleanupRetryAt, &deletedAt); scanErr != nil {
 			return stowry.ListResult{}, fmt.Errorf("%s: scan: %w", opName, scanErr)
 		}
 
@@ -200,6 +602,20 @@ func (r *repo) listWithCondition(ctx context.Context, q stowry.ListQuery, whereC
 			return stowry.ListResult{}, fmt.Errorf("%s: parse updated_at: %w", opName, parseErr)
 		}
 
+		m.LastAccessedAt, parseErr = parseNullTime(lastAccessedAt)
+		if parseErr != nil {
+			return stowry.ListResult{}, fmt.Errorf("%s: parse last_accessed_at: %w", opName, parseErr)
+		}
+		m.CleanupRetryAt, parseErr = parseNullTime(cleanupRetryAt)
+		if parseErr != nil {
+			return stowry.ListResult{}, fmt.Errorf("%s: parse cleanup_retry_at: %w", opName, parseErr)
+		}
+		m.DeletedAt, parseErr = parseNullTime(deletedAt)
+		if parseErr != nil {
+			return stowry.ListResult{}, fmt.Errorf("%s: parse deleted_at: %w", opName, parseErr)
+		}
+		m.DecodedSizeBytes = nullInt64(decodedSize)
+
 		items = append(items, m)
 	}
 
@@ -218,7 +634,9 @@ func (r *repo) listWithCondition(ctx context.Context, q stowry.ListQuery, whereC
 	return stowry.ListResult{Items: items, NextCursor: nextCursor}, nil
 }
 
-func (r *repo) MarkCleanedUp(ctx context.Context, id uuid.UUID) error {
+func (r *repo) MarkCleanedUp(ctx context.Context, id uuid.UUID) (err error) {
+	defer func() { err = internal.ContextError(ctx, err) }()
+
 	now := time.Now().UTC().Format(time.RFC3339Nano)
 	query := fmt.Sprintf( //nolint:gosec // G201: table name is validated
 		`UPDATE %s
@@ -241,3 +659,178 @@ func (r *repo) MarkCleanedUp(ctx context.Context, id uuid.UUID) error {
 
 	return nil
 }
+
+func (r *repo) DeferCleanup(ctx context.Context, id uuid.UUID, until time.Time) (err error) {
+	defer func() { err = internal.ContextError(ctx, err) }()
+
+	query := fmt.Sprintf( //nolint:gosec // G201: table name is validated
+		`UPDATE %s
+		SET cleanup_retry_at = ?
+		WHERE id = ? AND deleted_at IS NOT NULL AND cleaned_up_at IS NULL`, r.tableName)
+
+	result, err := r.db.ExecContext(ctx, query, until.UTC().Format(time.RFC3339Nano), id.String())
+	if err != nil {
+		return fmt.Errorf("defer cleanup: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("defer cleanup: rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return fmt.Errorf("defer cleanup: %w", stowry.ErrNotFound)
+	}
+
+	return nil
+}
+
+func (r *repo) RecordAccess(ctx context.Context, records []stowry.AccessRecord) (err error) {
+	defer func() { err = internal.ContextError(ctx, err) }()
+
+	if len(records) == 0 {
+		return nil
+	}
+
+	query := fmt.Sprintf( //nolint:gosec // G201: table name is validated
+		`UPDATE %s
+		SET download_count = COALESCE(download_count, 0) + ?,
+			last_accessed_at = ?
+		WHERE path = ? AND deleted_at IS NULL`, r.tableName)
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("record access: begin: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	stmt, err := tx.PrepareContext(ctx, query)
+	if err != nil {
+		return fmt.Errorf("record access: prepare: %w", err)
+	}
+	defer func() { _ = stmt.Close() }()
+
+	for _, rec := range records {
+		if _, err := stmt.ExecContext(ctx, rec.Count, rec.LastAccessedAt.UTC().Format(time.RFC3339Nano), rec.Path); err != nil {
+			return fmt.Errorf("record access %s: %w", rec.Path, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("record access: commit: %w", err)
+	}
+
+	return nil
+}
+
+// inListChunk keeps each IN list well below SQLite's variable limit.
+const inListChunk = 500
+
+func (r *repo) ExistingPaths(ctx context.Context, paths []string) (_ []string, err error) {
+	defer func() { err = internal.ContextError(ctx, err) }()
+
+	var found []string
+
+	for chunk := range slices.Chunk(paths, inListChunk) {
+		query := fmt.Sprintf( //nolint:gosec // G201: table name is validated
+			`SELECT path FROM %s
+			WHERE cleaned_up_at IS NULL AND path IN (?%s)`, r.tableName, strings.Repeat(", ?", len(chunk)-1))
+
+		args := make([]any, len(chunk))
+		for i, p := range chunk {
+			args[i] = p
+		}
+
+		rows, err := r.db.QueryContext(ctx, query, args...)
+		if err != nil {
+			return nil, fmt.Errorf("existing paths: %w", err)
+		}
+
+		for rows.Next() {
+			var p string
+			if err := rows.Scan(&p); err != nil {
+				_ = rows.Close()
+				return nil, fmt.Errorf("existing paths: scan: %w", err)
+			}
+			found = append(found, p)
+		}
+		err = rows.Err()
+		_ = rows.Close()
+		if err != nil {
+			return nil, fmt.Errorf("existing paths: %w", err)
+		}
+	}
+
+	return found, nil
+}
+
+func (r *repo) CountPrefix(ctx context.Context, prefix string) (_ int64, err error) {
+	defer func() { err = internal.ContextError(ctx, err) }()
+
+	query := fmt.Sprintf( //nolint:gosec // G201: table name is validated
+		`SELECT COUNT(*) FROM %s
+		WHERE deleted_at IS NULL AND %s`, r.tableName, prefixCondition)
+
+	var count int64
+	if err := r.db.QueryRowContext(ctx, query, prefixArgs(prefix)...).Scan(&count); err != nil {
+		return 0, fmt.Errorf("count prefix: %w", err)
+	}
+
+	return count, nil
+}
+
+// classifyWriteError marks unique-constraint violations, which happen when a
+// concurrent writer inserts the same row first, as stowry.ErrConflict.
+func classifyWriteError(err error) error {
+	var sqliteErr *sqlitedriver.Error
+	if errors.As(err, &sqliteErr) {
+		switch sqliteErr.Code() {
+		case sqlite3.SQLITE_CONSTRAINT_UNIQUE, sqlite3.SQLITE_CONSTRAINT_PRIMARYKEY:
+			return fmt.Errorf("%w: %w", stowry.ErrConflict, err)
+		}
+	}
+	return err
+}
+
+// classifyUpsertError is classifyWriteError for metadata upserts, where a
+// primary key violation means a client-supplied ID belongs to another path.
+func classifyUpsertError(err error) error {
+	var sqliteErr *sqlitedriver.Error
+	if errors.As(err, &sqliteErr) && sqliteErr.Code() == sqlite3.SQLITE_CONSTRAINT_PRIMARYKEY {
+		return fmt.Errorf("%w: %w", stowry.ErrIDConflict, err)
+	}
+	return classifyWriteError(err)
+}
+
+// parseNullTime parses an optional RFC3339Nano timestamp column.
+func parseNullTime(s sql.NullString) (*time.Time, error) {
+	if !s.Valid {
+		return nil, nil
+	}
+	t, err := time.Parse(time.RFC3339Nano, s.String)
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// nullUUID returns id as a string argument, or NULL for uuid.Nil.
+func nullUUID(id uuid.UUID) any {
+	if id == uuid.Nil {
+		return nil
+	}
+	return id.String()
+}
+
+func nullInt64(n sql.NullInt64) *int64 {
+	if !n.Valid {
+		return nil
+	}
+	return &n.Int64
+}
+
+// Ping reports whether the database answers, for the service's check
+// before large uploads.
+func (r *repo) Ping(ctx context.Context) error {
+	return internal.ContextError(ctx, r.db.PingContext(ctx))
+}
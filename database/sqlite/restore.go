@@ -0,0 +1,89 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sagarc03/stowry"
+	"github.com/sagarc03/stowry/database/internal"
+)
+
+// Restore clears deleted_at and records the change in one transaction.
+func (r *repo) Restore(ctx context.Context, path string) (_ stowry.MetaData, err error) {
+	defer func() { err = internal.ContextError(ctx, err) }()
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return stowry.MetaData{}, fmt.Errorf("restore: begin: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	query := fmt.Sprintf( //nolint:gosec // G201: table name is validated
+		`UPDATE %s
+		SET deleted_at = NULL, cleanup_retry_at = NULL
+		WHERE path = ? AND deleted_at IS NOT NULL AND cleaned_up_at IS NULL AND quarantined_at IS NULL
+		RETURNING id, path, content_type, etag, file_size_bytes, created_at, updated_at,
+			COALESCE(download_count, 0), last_accessed_at,
+			COALESCE(content_encoding, ''), decoded_size_bytes, COALESCE(filename, ''), user_metadata, COALESCE(cache_control, ''), COALESCE(storage_backend, '')`, r.tableName)
+
+	var m stowry.MetaData
+	var idStr, createdAt, updatedAt string
+	var lastAccessedAt sql.NullString
+	var decodedSize sql.NullInt64
+
+	err = tx.QueryRowContext(ctx, query, path).Scan(
+		&idStr, &m.Path, &m.ContentType, &m.Etag, &m.FileSizeBytes, &createdAt, &updatedAt,
+		&m.DownloadCount, &lastAccessedAt, &m.ContentEncoding, &decodedSize, &m.Filename, internal.UserMetadata{Dest: &m.UserMetadata}, &m.CacheControl, &m.StorageBackend,
+	)
+	if errors.Is(err, sql.ErrNoRows) {
+		return stowry.MetaData{}, fmt.Errorf("restore: %w", r.restoreMiss(ctx, tx, path))
+	}
+	if err != nil {
+		return stowry.MetaData{}, fmt.Errorf("restore: %w", err)
+	}
+
+	if m.ID, err = uuid.Parse(idStr); err != nil {
+		return stowry.MetaData{}, fmt.Errorf("restore: parse uuid: %w", err)
+	}
+	if m.CreatedAt, err = time.Parse(time.RFC3339Nano, createdAt); err != nil {
+		return stowry.MetaData{}, fmt.Errorf("restore: parse created_at: %w", err)
+	}
+	if m.UpdatedAt, err = time.Parse(time.RFC3339Nano, updatedAt); err != nil {
+		return stowry.MetaData{}, fmt.Errorf("restore: parse updated_at: %w", err)
+	}
+	if m.LastAccessedAt, err = parseNullTime(lastAccessedAt); err != nil {
+		return stowry.MetaData{}, fmt.Errorf("restore: parse last_accessed_at: %w", err)
+	}
+	m.DecodedSizeBytes = nullInt64(decodedSize)
+
+	if err := r.recordChange(ctx, tx, stowry.ChangeCreate, path, m.Etag, m.FileSizeBytes, time.Now().UTC(), ""); err != nil {
+		return stowry.MetaData{}, fmt.Errorf("restore: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return stowry.MetaData{}, fmt.Errorf("restore: commit: %w", err)
+	}
+	return m, nil
+}
+
+// restoreMiss explains why path had no row to restore: ErrAlreadyExists when
+// the path holds an active object, ErrNotFound otherwise.
+func (r *repo) restoreMiss(ctx context.Context, tx *sql.Tx, path string) error {
+	query := fmt.Sprintf( //nolint:gosec // G201: table name is validated
+		`SELECT 1 FROM %s WHERE path = ? AND deleted_at IS NULL`, r.tableName)
+
+	var one int
+	err := tx.QueryRowContext(ctx, query, path).Scan(&one)
+	switch {
+	case err == nil:
+		return stowry.ErrAlreadyExists
+	case errors.Is(err, sql.ErrNoRows):
+		return stowry.ErrNotFound
+	default:
+		return err
+	}
+}
@@ -0,0 +1,212 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sagarc03/stowry"
+	"github.com/sagarc03/stowry/database/internal"
+)
+
+func (r *repo) CreateStage(ctx context.Context) (_ stowry.Stage, err error) {
+	defer func() { err = internal.ContextError(ctx, err) }()
+
+	stage := stowry.Stage{ID: uuid.New(), CreatedAt: time.Now().UTC()}
+	query := fmt.Sprintf( //nolint:gosec // G201: table name is validated
+		`INSERT INTO %s (id, created_at) VALUES (?, ?)`, r.stagesTable)
+
+	if _, err := r.db.ExecContext(ctx, query, stage.ID.String(), stage.CreatedAt.Format(time.RFC3339Nano)); err != nil {
+		return stowry.Stage{}, fmt.Errorf("create stage: %w", err)
+	}
+	return stage, nil
+}
+
+func (r *repo) AddStagedObject(ctx context.Context, id uuid.UUID, entry stowry.ObjectEntry) (err error) {
+	defer func() { err = internal.ContextError(ctx, err) }()
+
+	// The SELECT yields no row for an unknown stage, so nothing is inserted
+	query := fmt.Sprintf( //nolint:gosec // G201: table names are validated
+		`INSERT INTO %s (stage_id, path, content_type, etag, file_size_bytes, created_at,
+			content_encoding, decoded_size_bytes, filename, storage_backend, user_metadata, cache_control)
+		SELECT id, ?, ?, ?, ?, ?, NULLIF(?, ''), ?, NULLIF(?, ''), NULLIF(?, ''), NULLIF(?, ''), NULLIF(?, '') FROM %s WHERE id = ?
+		ON CONFLICT (stage_id, path) DO UPDATE
+		SET content_type = excluded.content_type,
+			etag = excluded.etag,
+			file_size_bytes = excluded.file_size_bytes,
+			created_at = excluded.created_at,
+			content_encoding = excluded.content_encoding,
+			decoded_size_bytes = excluded.decoded_size_bytes,
+			filename = excluded.filename,
+			storage_backend = excluded.storage_backend,
+			user_metadata = excluded.user_metadata,
+			cache_control = excluded.cache_control`, r.stagedObjectsTable, r.stagesTable)
+
+	now := time.Now().UTC().Format(time.RFC3339Nano)
+	result, err := r.db.ExecContext(ctx, query, entry.Path, entry.ContentType, entry.ETag, entry.Size, now,
+		entry.ContentEncoding, entry.DecodedSize, entry.Filename, entry.StorageBackend,
+		internal.EncodeUserMetadata(entry.UserMetadata), entry.CacheControl, id.String())
+	if err != nil {
+		return fmt.Errorf("add staged object: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("add staged object: rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("add staged object: stage %s: %w", id, stowry.ErrNotFound)
+	}
+	return nil
+}
+
+func (r *repo) StagedObjects(ctx context.Context, id uuid.UUID) (_ []stowry.ObjectEntry, err error) {
+	defer func() { err = internal.ContextError(ctx, err) }()
+
+	entries, err := r.stagedObjects(ctx, r.db, id)
+	if err != nil {
+		return nil, fmt.Errorf("staged objects: %w", err)
+	}
+	return entries, nil
+}
+
+// querier is the part of *sql.DB and *sql.Tx that stage queries use.
+type querier interface {
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+}
+
+// stagedObjects returns the entries of stage id, or ErrNotFound when the
+// stage does not exist.
+func (r *repo) stagedObjects(ctx context.Context, q querier, id uuid.UUID) ([]stowry.ObjectEntry, error) {
+	var found string
+	err := q.QueryRowContext(ctx, fmt.Sprintf( //nolint:gosec // G201: table name is validated
+		`SELECT id FROM %s WHERE id = ?`, r.stagesTable), id.String()).Scan(&found)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("stage %s: %w", id, stowry.ErrNotFound)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := q.QueryContext(ctx, fmt.Sprintf( //nolint:gosec // G201: table name is validated
+		`SELECT path, content_type, etag, file_size_bytes,
+			COALESCE(content_encoding, ''), decoded_size_bytes, COALESCE(filename, ''),
+			COALESCE(storage_backend, ''), user_metadata, COALESCE(cache_control, '')
+		FROM %s
+		WHERE stage_id = ?
+		ORDER BY path`, r.stagedObjectsTable), id.String())
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	entries := []stowry.ObjectEntry{}
+	for rows.Next() {
+		var e stowry.ObjectEntry
+		var decodedSize sql.NullInt64
+		if err := rows.Scan(&e.Path, &e.ContentType, &e.ETag, &e.Size, &e.ContentEncoding, &decodedSize, &e.Filename, &e.StorageBackend,
+			internal.UserMetadata{Dest: &e.UserMetadata}, &e.CacheControl); err != nil {
+			return nil, fmt.Errorf("scan: %w", err)
+		}
+		e.DecodedSize = nullInt64(decodedSize)
+		entries = append(entries, e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows: %w", err)
+	}
+	return entries, nil
+}
+
+func (r *repo) CommitStage(ctx context.Context, id uuid.UUID) (_ []stowry.MetaData, err error) {
+	defer func() { err = internal.ContextError(ctx, err) }()
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("commit stage: begin: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	entries, err := r.stagedObjects(ctx, tx, id)
+	if err != nil {
+		return nil, fmt.Errorf("commit stage: %w", err)
+	}
+
+	now := time.Now().UTC().Format(time.RFC3339Nano)
+	replaceQuery := fmt.Sprintf( //nolint:gosec // G201: table name is validated
+		`UPDATE %s
+		SET path = ?, deleted_at = COALESCE(deleted_at, ?)
+		WHERE path = ?`, r.tableName)
+
+	committed := make([]stowry.MetaData, 0, len(entries))
+	for _, entry := range entries {
+		if _, err := tx.ExecContext(ctx, replaceQuery, stowry.ReplacedPath(id, entry.Path), now, entry.Path); err != nil {
+			return nil, fmt.Errorf("commit stage: replace %s: %w", entry.Path, err)
+		}
+
+		m, err := r.upsertRow(entry, func(args ...any) *sql.Row {
+			return tx.QueryRowContext(ctx, r.upsertQuery(), args...)
+		})
+		if err != nil {
+			return nil, fmt.Errorf("commit stage: %s: %w", entry.Path, classifyWriteError(err))
+		}
+		if err := r.recordChange(ctx, tx, stowry.ChangeCreate, m.Path, m.Etag, m.FileSizeBytes, m.UpdatedAt, ""); err != nil {
+			return nil, fmt.Errorf("commit stage: %s: %w", entry.Path, err)
+		}
+		committed = append(committed, m)
+	}
+
+	if err := r.deleteStage(ctx, tx, id); err != nil {
+		return nil, fmt.Errorf("commit stage: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("commit stage: commit: %w", err)
+	}
+	return committed, nil
+}
+
+func (r *repo) DeleteStage(ctx context.Context, id uuid.UUID) (err error) {
+	defer func() { err = internal.ContextError(ctx, err) }()
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("delete stage: begin: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if err := r.deleteStage(ctx, tx, id); err != nil {
+		return fmt.Errorf("delete stage: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("delete stage: commit: %w", err)
+	}
+	return nil
+}
+
+// deleteStage removes stage id and its objects within tx.
+func (r *repo) deleteStage(ctx context.Context, tx *sql.Tx, id uuid.UUID) error {
+	if _, err := tx.ExecContext(ctx, fmt.Sprintf( //nolint:gosec // G201: table name is validated
+		`DELETE FROM %s WHERE stage_id = ?`, r.stagedObjectsTable), id.String()); err != nil {
+		return fmt.Errorf("delete staged objects: %w", err)
+	}
+
+	result, err := tx.ExecContext(ctx, fmt.Sprintf( //nolint:gosec // G201: table name is validated
+		`DELETE FROM %s WHERE id = ?`, r.stagesTable), id.String())
+	if err != nil {
+		return fmt.Errorf("delete stage: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("stage %s: %w", id, stowry.ErrNotFound)
+	}
+	return nil
+}
@@ -0,0 +1,225 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/sagarc03/stowry"
+	"github.com/sagarc03/stowry/database/internal"
+)
+
+// usageTimeFormat stores snapshot times with a fixed number of fractional
+// digits, so they sort as text in time order.
+const usageTimeFormat = "2006-01-02T15:04:05.000000000Z07:00"
+
+// usagePrefixSQL is the top-level prefix of path, as stowry.UsagePrefix.
+const usagePrefixSQL = `CASE WHEN instr(path, '/') > 0 THEN substr(path, 1, instr(path, '/')) ELSE '' END`
+
+func (r *repo) TakeUsageSnapshot(ctx context.Context) (_ stowry.UsageSnapshot, err error) {
+	defer func() { err = internal.ContextError(ctx, err) }()
+
+	// A write transaction, so no create commits between the counts below
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return stowry.UsageSnapshot{}, fmt.Errorf("take usage snapshot: begin: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	previous, err := r.latestUsageSnapshot(ctx, tx, time.Now())
+	hasPrevious := err == nil
+	if err != nil && !errors.Is(err, stowry.ErrNotFound) {
+		return stowry.UsageSnapshot{}, fmt.Errorf("take usage snapshot: %w", err)
+	}
+
+	var minSeq, maxSeq int64
+	err = tx.QueryRowContext(ctx, fmt.Sprintf( //nolint:gosec // G201: table name is validated
+		`SELECT COALESCE(MIN(seq), 0), COALESCE(MAX(seq), 0) FROM %s`, r.changesTable)).Scan(&minSeq, &maxSeq)
+	if err != nil {
+		return stowry.UsageSnapshot{}, fmt.Errorf("take usage snapshot: change window: %w", err)
+	}
+
+	snapshot := stowry.UsageSnapshot{TakenAt: time.Now().UTC(), Complete: true}
+	added := map[string]internal.UsageCount{}
+	if hasPrevious {
+		if _, err := internal.ChangeWindow(previous.seq, minSeq, maxSeq); err != nil {
+			snapshot.Complete = false
+		}
+		added, err = r.usageCounts(ctx, tx, fmt.Sprintf( //nolint:gosec // G201: table name is validated
+			`SELECT %s, COUNT(*), COALESCE(SUM(file_size_bytes), 0)
+			FROM %s
+			WHERE kind = ? AND seq > ? AND seq <= ?
+			GROUP BY 1`, usagePrefixSQL, r.changesTable), string(stowry.ChangeCreate), previous.seq, maxSeq)
+		if err != nil {
+			return stowry.UsageSnapshot{}, fmt.Errorf("take usage snapshot: added: %w", err)
+		}
+	}
+
+	active, err := r.usageCounts(ctx, tx, fmt.Sprintf( //nolint:gosec // G201: table name is validated
+		`SELECT %s, COUNT(*), COALESCE(SUM(file_size_bytes), 0)
+		FROM %s
+		WHERE deleted_at IS NULL
+		GROUP BY 1`, usagePrefixSQL, r.tableName))
+	if err != nil {
+		return stowry.UsageSnapshot{}, fmt.Errorf("take usage snapshot: active: %w", err)
+	}
+	snapshot.Prefixes = internal.MergeUsage(previous.Prefixes, added, active)
+
+	err = tx.QueryRowContext(ctx, fmt.Sprintf( //nolint:gosec // G201: table name is validated
+		`INSERT INTO %s (taken_at, change_seq, complete) VALUES (?, ?, ?) RETURNING id`, r.usageTable),
+		snapshot.TakenAt.Format(usageTimeFormat), maxSeq, snapshot.Complete).Scan(&snapshot.ID)
+	if err != nil {
+		return stowry.UsageSnapshot{}, fmt.Errorf("take usage snapshot: insert: %w", err)
+	}
+
+	stmt, err := tx.PrepareContext(ctx, fmt.Sprintf( //nolint:gosec // G201: table name is validated
+		`INSERT INTO %s (snapshot_id, prefix, objects, bytes, objects_added, bytes_added)
+		VALUES (?, ?, ?, ?, ?, ?)`, r.usagePrefixesTable))
+	if err != nil {
+		return stowry.UsageSnapshot{}, fmt.Errorf("take usage snapshot: prepare: %w", err)
+	}
+	defer func() { _ = stmt.Close() }()
+
+	for _, p := range snapshot.Prefixes {
+		if _, err := stmt.ExecContext(ctx, snapshot.ID, p.Prefix, p.Objects, p.Bytes, p.ObjectsAdded, p.BytesAdded); err != nil {
+			return stowry.UsageSnapshot{}, fmt.Errorf("take usage snapshot: insert prefix %q: %w", p.Prefix, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return stowry.UsageSnapshot{}, fmt.Errorf("take usage snapshot: commit: %w", err)
+	}
+	return snapshot, nil
+}
+
+func (r *repo) BackendUsage(ctx context.Context) (_ []stowry.BackendUsage, err error) {
+	defer func() { err = internal.ContextError(ctx, err) }()
+
+	counts, err := r.usageCounts(ctx, r.db, fmt.Sprintf( //nolint:gosec // G201: table name is validated
+		`SELECT COALESCE(storage_backend, ''), COUNT(*), COALESCE(SUM(file_size_bytes), 0)
+		FROM %s
+		WHERE deleted_at IS NULL
+		GROUP BY 1`, r.tableName))
+	if err != nil {
+		return nil, fmt.Errorf("backend usage: %w", err)
+	}
+	return internal.BackendUsage(counts), nil
+}
+
+func (r *repo) ListUsageSnapshots(ctx context.Context, from, to time.Time) (_ []stowry.UsageSnapshot, err error) {
+	defer func() { err = internal.ContextError(ctx, err) }()
+
+	query := fmt.Sprintf( //nolint:gosec // G201: table name is validated
+		`SELECT id, taken_at, complete
+		FROM %s
+		WHERE taken_at >= ? AND taken_at <= ?
+		ORDER BY id`, r.usageTable)
+
+	rows, err := r.db.QueryContext(ctx, query, from.UTC().Format(usageTimeFormat), to.UTC().Format(usageTimeFormat))
+	if err != nil {
+		return nil, fmt.Errorf("list usage snapshots: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	snapshots := []stowry.UsageSnapshot{}
+	for rows.Next() {
+		var s stowry.UsageSnapshot
+		var takenAt string
+		if err := rows.Scan(&s.ID, &takenAt, &s.Complete); err != nil {
+			return nil, fmt.Errorf("list usage snapshots: scan: %w", err)
+		}
+		if s.TakenAt, err = time.Parse(time.RFC3339Nano, takenAt); err != nil {
+			return nil, fmt.Errorf("list usage snapshots: parse taken_at: %w", err)
+		}
+		snapshots = append(snapshots, s)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("list usage snapshots: %w", err)
+	}
+	return snapshots, nil
+}
+
+func (r *repo) UsageSnapshotAt(ctx context.Context, at time.Time) (_ stowry.UsageSnapshot, err error) {
+	defer func() { err = internal.ContextError(ctx, err) }()
+
+	snapshot, err := r.latestUsageSnapshot(ctx, r.db, at)
+	if err != nil {
+		return stowry.UsageSnapshot{}, fmt.Errorf("usage snapshot at: %w", err)
+	}
+	return snapshot.UsageSnapshot, nil
+}
+
+// storedUsageSnapshot is a usage snapshot with the change feed sequence it
+// counted creates up to.
+type storedUsageSnapshot struct {
+	stowry.UsageSnapshot
+	seq int64
+}
+
+// latestUsageSnapshot returns the latest snapshot taken at or before at,
+// with its prefixes, or ErrNotFound.
+func (r *repo) latestUsageSnapshot(ctx context.Context, q querier, at time.Time) (storedUsageSnapshot, error) {
+	var s storedUsageSnapshot
+	var takenAt string
+	err := q.QueryRowContext(ctx, fmt.Sprintf( //nolint:gosec // G201: table name is validated
+		`SELECT id, taken_at, complete, change_seq
+		FROM %s
+		WHERE taken_at <= ?
+		ORDER BY taken_at DESC, id DESC
+		LIMIT 1`, r.usageTable), at.UTC().Format(usageTimeFormat)).Scan(&s.ID, &takenAt, &s.Complete, &s.seq)
+	if errors.Is(err, sql.ErrNoRows) {
+		return storedUsageSnapshot{}, stowry.ErrNotFound
+	}
+	if err != nil {
+		return storedUsageSnapshot{}, fmt.Errorf("latest snapshot: %w", err)
+	}
+	if s.TakenAt, err = time.Parse(time.RFC3339Nano, takenAt); err != nil {
+		return storedUsageSnapshot{}, fmt.Errorf("latest snapshot: parse taken_at: %w", err)
+	}
+
+	rows, err := q.QueryContext(ctx, fmt.Sprintf( //nolint:gosec // G201: table name is validated
+		`SELECT prefix, objects, bytes, objects_added, bytes_added
+		FROM %s
+		WHERE snapshot_id = ?
+		ORDER BY prefix`, r.usagePrefixesTable), s.ID)
+	if err != nil {
+		return storedUsageSnapshot{}, fmt.Errorf("snapshot prefixes: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	s.Prefixes = []stowry.PrefixUsage{}
+	for rows.Next() {
+		var p stowry.PrefixUsage
+		if err := rows.Scan(&p.Prefix, &p.Objects, &p.Bytes, &p.ObjectsAdded, &p.BytesAdded); err != nil {
+			return storedUsageSnapshot{}, fmt.Errorf("snapshot prefixes: scan: %w", err)
+		}
+		s.Prefixes = append(s.Prefixes, p)
+	}
+	if err := rows.Err(); err != nil {
+		return storedUsageSnapshot{}, fmt.Errorf("snapshot prefixes: %w", err)
+	}
+	return s, nil
+}
+
+// usageCounts runs query, which selects a prefix, a count and a byte total
+// per row, and returns the counts by prefix.
+func (r *repo) usageCounts(ctx context.Context, q querier, query string, args ...any) (map[string]internal.UsageCount, error) {
+	rows, err := q.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	counts := make(map[string]internal.UsageCount)
+	for rows.Next() {
+		var prefix string
+		var c internal.UsageCount
+		if err := rows.Scan(&prefix, &c.Objects, &c.Bytes); err != nil {
+			return nil, fmt.Errorf("scan: %w", err)
+		}
+		counts[prefix] = c
+	}
+	return counts, rows.Err()
+}
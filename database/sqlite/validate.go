@@ -8,26 +8,23 @@ import (
 	"strings"
 
 	"github.com/sagarc03/stowry"
+	"github.com/sagarc03/stowry/database/internal"
 )
 
-type columnInfo struct {
-	name       string
-	dataType   string
-	isNullable bool
-}
-
-func validateTableSchema(ctx context.Context, db *sql.DB, tableName string, expectedSchema map[string]columnInfo) error {
+// compareTableSchema returns how the table differs from expectedSchema. A
+// table that does not exist is reported as missing, not as an error.
+func compareTableSchema(ctx context.Context, db *sql.DB, tableName string, expectedSchema internal.TableSchema) (internal.TableDiff, error) {
 	if !stowry.IsValidTableName(tableName) {
-		return fmt.Errorf("validate table schema: invalid table name: %s", tableName)
+		return internal.TableDiff{}, fmt.Errorf("validate table schema: invalid table name: %s", tableName)
 	}
 
 	exists, err := tableExists(ctx, db, tableName)
 	if err != nil {
-		return fmt.Errorf("validate table schema: %w", err)
+		return internal.TableDiff{}, fmt.Errorf("validate table schema: %w", err)
 	}
 
 	if !exists {
-		return fmt.Errorf("validate table schema: table %s does not exist", tableName)
+		return internal.MissingTable(tableName), nil
 	}
 
 	// SQLite uses PRAGMA table_info to get column information
@@ -35,11 +32,11 @@ func validateTableSchema(ctx context.Context, db *sql.DB, tableName string, expe
 
 	rows, err := db.QueryContext(ctx, query)
 	if err != nil {
-		return fmt.Errorf("validate table schema: query columns: %w", err)
+		return internal.TableDiff{}, fmt.Errorf("validate table schema: query columns: %w", err)
 	}
 	defer func() { _ = rows.Close() }()
 
-	actualColumns := make(map[string]columnInfo)
+	actualColumns := make(map[string]internal.Column)
 	for rows.Next() {
 		var cid int
 		var name, dataType string
@@ -48,59 +45,24 @@ func validateTableSchema(ctx context.Context, db *sql.DB, tableName string, expe
 		var pk int
 
 		if err := rows.Scan(&cid, &name, &dataType, &notNull, &dfltValue, &pk); err != nil {
-			return fmt.Errorf("validate table schema: scan column: %w", err)
+			return internal.TableDiff{}, fmt.Errorf("validate table schema: scan column: %w", err)
 		}
-		actualColumns[name] = columnInfo{
-			name:       name,
-			dataType:   strings.ToLower(dataType),
-			isNullable: notNull == 0,
+		actualColumns[name] = internal.Column{
+			Type:     strings.ToLower(dataType),
+			Nullable: notNull == 0,
 		}
 	}
 
 	if err := rows.Err(); err != nil {
-		return fmt.Errorf("validate table schema: rows error: %w", err)
+		return internal.TableDiff{}, fmt.Errorf("validate table schema: rows error: %w", err)
 	}
 
-	var missingColumns []string
-	var mismatchedColumns []string
-
-	for colName, expected := range expectedSchema {
-		actual, exists := actualColumns[colName]
-		if !exists {
-			missingColumns = append(missingColumns, colName)
-			continue
-		}
-
-		if actual.dataType != expected.dataType {
-			mismatchedColumns = append(mismatchedColumns,
-				fmt.Sprintf("%s: expected %s, got %s", colName, expected.dataType, actual.dataType))
-		}
-
-		if actual.isNullable != expected.isNullable {
-			mismatchedColumns = append(mismatchedColumns,
-				fmt.Sprintf("%s: expected nullable=%v, got nullable=%v", colName, expected.isNullable, actual.isNullable))
-		}
-	}
-
-	if len(missingColumns) > 0 || len(mismatchedColumns) > 0 {
-		var errMsg strings.Builder
-		fmt.Fprintf(&errMsg, "table %s schema validation failed:\n", tableName)
-
-		if len(missingColumns) > 0 {
-			fmt.Fprintf(&errMsg, "  missing columns: %s\n", strings.Join(missingColumns, ", "))
-		}
-
-		if len(mismatchedColumns) > 0 {
-			fmt.Fprintf(&errMsg, "  mismatched columns:\n")
-			for _, msg := range mismatchedColumns {
-				fmt.Fprintf(&errMsg, "    - %s\n", msg)
-			}
-		}
-
-		return errors.New(errMsg.String())
+	indexes, err := tableIndexes(ctx, db, tableName)
+	if err != nil {
+		return internal.TableDiff{}, fmt.Errorf("validate table schema: %w", err)
 	}
 
-	return nil
+	return internal.CompareTable(tableName, expectedSchema, actualColumns, indexes), nil
 }
 
 func tableExists(ctx context.Context, db *sql.DB, tableName string) (bool, error) {
@@ -116,30 +78,214 @@ func tableExists(ctx context.Context, db *sql.DB, tableName string) (bool, error
 	return true, nil
 }
 
+func tableIndexes(ctx context.Context, db *sql.DB, tableName string) ([]string, error) {
+	query := `SELECT name FROM sqlite_master WHERE type='index' AND tbl_name=?`
+	rows, err := db.QueryContext(ctx, query, tableName)
+	if err != nil {
+		return nil, fmt.Errorf("query indexes: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	var indexes []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, fmt.Errorf("scan index: %w", err)
+		}
+		indexes = append(indexes, name)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("query indexes: %w", err)
+	}
+	return indexes, nil
+}
+
 type tableValidation struct {
 	tableName      string
-	expectedSchema map[string]columnInfo
+	expectedSchema internal.TableSchema
 }
 
-var metaDataTableSchema = map[string]columnInfo{
-	"id":              {"id", "text", false},
-	"path":            {"path", "text", false},
-	"content_type":    {"content_type", "text", false},
-	"etag":            {"etag", "text", false},
-	"file_size_bytes": {"file_size_bytes", "integer", false},
-	"created_at":      {"created_at", "text", false},
-	"updated_at":      {"updated_at", "text", false},
-	"deleted_at":      {"deleted_at", "text", true},
-	"cleaned_up_at":   {"cleaned_up_at", "text", true},
+var metaDataColumns = map[string]internal.Column{
+	"id":                 {Type: "text", Nullable: false},
+	"path":               {Type: "text", Nullable: false},
+	"content_type":       {Type: "text", Nullable: false},
+	"etag":               {Type: "text", Nullable: false},
+	"file_size_bytes":    {Type: "integer", Nullable: false},
+	"created_at":         {Type: "text", Nullable: false},
+	"updated_at":         {Type: "text", Nullable: false},
+	"deleted_at":         {Type: "text", Nullable: true},
+	"cleaned_up_at":      {Type: "text", Nullable: true},
+	"download_count":     {Type: "integer", Nullable: true},
+	"last_accessed_at":   {Type: "text", Nullable: true},
+	"content_encoding":   {Type: "text", Nullable: true},
+	"decoded_size_bytes": {Type: "integer", Nullable: true},
+	"quarantined_at":     {Type: "text", Nullable: true},
+	"filename":           {Type: "text", Nullable: true},
+	"storage_backend":    {Type: "text", Nullable: true},
+	"cleanup_retry_at":   {Type: "text", Nullable: true},
+	"user_metadata":      {Type: "text", Nullable: true},
+	"cache_control":      {Type: "text", Nullable: true},
 }
 
-func getTableValidations(tables stowry.Tables) []tableValidation {
-	validations := []tableValidation{}
+var stagesColumns = map[string]internal.Column{
+	"id":         {Type: "text", Nullable: false},
+	"created_at": {Type: "text", Nullable: false},
+}
 
-	validations = append(validations, tableValidation{
-		tableName:      tables.MetaData,
-		expectedSchema: metaDataTableSchema,
-	})
+var stagedObjectsColumns = map[string]internal.Column{
+	"stage_id":           {Type: "text", Nullable: false},
+	"path":               {Type: "text", Nullable: false},
+	"content_type":       {Type: "text", Nullable: false},
+	"etag":               {Type: "text", Nullable: false},
+	"file_size_bytes":    {Type: "integer", Nullable: false},
+	"created_at":         {Type: "text", Nullable: false},
+	"content_encoding":   {Type: "text", Nullable: true},
+	"decoded_size_bytes": {Type: "integer", Nullable: true},
+	"filename":           {Type: "text", Nullable: true},
+	"storage_backend":    {Type: "text", Nullable: true},
+	"user_metadata":      {Type: "text", Nullable: true},
+	"cache_control":      {Type: "text", Nullable: true},
+}
+
+var changesColumns = map[string]internal.Column{
+	"seq":             {Type: "integer", Nullable: true},
+	"kind":            {Type: "text", Nullable: false},
+	"path":            {Type: "text", Nullable: false},
+	"etag":            {Type: "text", Nullable: false},
+	"file_size_bytes": {Type: "integer", Nullable: false},
+	"created_at":      {Type: "text", Nullable: false},
+	"previous_etag":   {Type: "text", Nullable: true},
+	"actor":           {Type: "text", Nullable: true},
+}
+
+var settingsColumns = map[string]internal.Column{
+	"key":        {Type: "text", Nullable: false},
+	"value":      {Type: "text", Nullable: false},
+	"updated_at": {Type: "text", Nullable: false},
+}
+
+var usageColumns = map[string]internal.Column{
+	"id":         {Type: "integer", Nullable: true},
+	"taken_at":   {Type: "text", Nullable: false},
+	"change_seq": {Type: "integer", Nullable: false},
+	"complete":   {Type: "integer", Nullable: false},
+}
+
+var usagePrefixesColumns = map[string]internal.Column{
+	"snapshot_id":   {Type: "integer", Nullable: false},
+	"prefix":        {Type: "text", Nullable: false},
+	"objects":       {Type: "integer", Nullable: false},
+	"bytes":         {Type: "integer", Nullable: false},
+	"objects_added": {Type: "integer", Nullable: false},
+	"bytes_added":   {Type: "integer", Nullable: false},
+}
+
+var accessKeysColumns = map[string]internal.Column{
+	"access_key":    {Type: "text", Nullable: false},
+	"created_at":    {Type: "text", Nullable: false},
+	"last_used_at":  {Type: "text", Nullable: true},
+	"request_count": {Type: "integer", Nullable: false},
+	"deprecated_at": {Type: "text", Nullable: true},
+	"replaced_by":   {Type: "text", Nullable: true},
+	"revoked_at":    {Type: "text", Nullable: true},
+}
+
+var writeIntentsColumns = map[string]internal.Column{
+	"id":               {Type: "text", Nullable: false},
+	"path":             {Type: "text", Nullable: false},
+	"content_type":     {Type: "text", Nullable: false},
+	"content_encoding": {Type: "text", Nullable: true},
+	"filename":         {Type: "text", Nullable: true},
+	"object_id":        {Type: "text", Nullable: true},
+	"started_at":       {Type: "text", Nullable: false},
+	"user_metadata":    {Type: "text", Nullable: true},
+	"cache_control":    {Type: "text", Nullable: true},
+}
+
+var uploadsColumns = map[string]internal.Column{
+	"id":               {Type: "text", Nullable: false},
+	"path":             {Type: "text", Nullable: false},
+	"content_type":     {Type: "text", Nullable: false},
+	"content_encoding": {Type: "text", Nullable: true},
+	"filename":         {Type: "text", Nullable: true},
+	"created_at":       {Type: "text", Nullable: false},
+	"user_metadata":    {Type: "text", Nullable: true},
+	"cache_control":    {Type: "text", Nullable: true},
+}
+
+var uploadPartsColumns = map[string]internal.Column{
+	"upload_id":   {Type: "text", Nullable: false},
+	"part_number": {Type: "integer", Nullable: false},
+	"etag":        {Type: "text", Nullable: false},
+	"size_bytes":  {Type: "integer", Nullable: false},
+	"uploaded_at": {Type: "text", Nullable: false},
+}
+
+func getTableValidations(tables stowry.Tables) []tableValidation {
+	columnNames := func(columns []columnDef) []string {
+		names := make([]string, len(columns))
+		for i, col := range columns {
+			names[i] = col.name
+		}
+		return names
+	}
 
-	return validations
+	return []tableValidation{{
+		tableName: tables.MetaData,
+		expectedSchema: internal.TableSchema{
+			Columns: metaDataColumns,
+			Indexes: []string{
+				fmt.Sprintf("idx_%s_deleted_at", tables.MetaData),
+				fmt.Sprintf("idx_%s_pending_cleanup", tables.MetaData),
+				fmt.Sprintf("idx_%s_active_list", tables.MetaData),
+			},
+			Migratable: columnNames(metaDataAddedColumns),
+		},
+	}, {
+		tableName:      tables.Stages(),
+		expectedSchema: internal.TableSchema{Columns: stagesColumns},
+	}, {
+		tableName: tables.StagedObjects(),
+		expectedSchema: internal.TableSchema{
+			Columns:    stagedObjectsColumns,
+			Migratable: columnNames(stagedObjectsAddedColumns),
+		},
+	}, {
+		tableName: tables.Changes(),
+		expectedSchema: internal.TableSchema{
+			Columns:    changesColumns,
+			Indexes:    []string{fmt.Sprintf("idx_%s_created_at", tables.Changes())},
+			Migratable: columnNames(changesAddedColumns),
+		},
+	}, {
+		tableName:      tables.Settings(),
+		expectedSchema: internal.TableSchema{Columns: settingsColumns},
+	}, {
+		tableName: tables.Usage(),
+		expectedSchema: internal.TableSchema{
+			Columns: usageColumns,
+			Indexes: []string{fmt.Sprintf("idx_%s_taken_at", tables.Usage())},
+		},
+	}, {
+		tableName:      tables.UsagePrefixes(),
+		expectedSchema: internal.TableSchema{Columns: usagePrefixesColumns},
+	}, {
+		tableName:      tables.AccessKeys(),
+		expectedSchema: internal.TableSchema{Columns: accessKeysColumns},
+	}, {
+		tableName: tables.WriteIntents(),
+		expectedSchema: internal.TableSchema{
+			Columns:    writeIntentsColumns,
+			Migratable: columnNames(writeIntentsAddedColumns),
+		},
+	}, {
+		tableName: tables.Uploads(),
+		expectedSchema: internal.TableSchema{
+			Columns:    uploadsColumns,
+			Migratable: columnNames(uploadsAddedColumns),
+		},
+	}, {
+		tableName:      tables.UploadParts(),
+		expectedSchema: internal.TableSchema{Columns: uploadPartsColumns},
+	}}
 }
@@ -0,0 +1,156 @@
+package stowry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// DefaultMaxDeletePaths is the default for the most paths one bulk delete
+// request may name.
+const DefaultMaxDeletePaths = 1000
+
+// DeleteStatus is the outcome of deleting one path of a DeleteMany call.
+type DeleteStatus string
+
+const (
+	DeleteStatusDeleted  DeleteStatus = "deleted"   // The object was soft-deleted
+	DeleteStatusNotFound DeleteStatus = "not_found" // No active object exists at the path
+	DeleteStatusError    DeleteStatus = "error"     // The path is invalid or the delete failed
+)
+
+// DeleteManyResult is the outcome of deleting one path.
+type DeleteManyResult struct {
+	Path   string       `json:"path"`
+	Status DeleteStatus `json:"status"`
+	Err    error        `json:"-"` // Set when Status is DeleteStatusError
+}
+
+// DeleteMany soft-deletes the object at each of paths, as Delete does, and
+// reports the outcome per path. A path without an object, an invalid path or
+// a failed delete does not stop the others; a leading "/" is ignored.
+//
+// Parameters:
+//   - ctx: Context for cancellation and timeout
+//   - paths: Object paths; a path listed twice is reported not found the
+//     second time
+//
+// Returns:
+//   - []DeleteManyResult: A result for each path, in the order given
+//   - error: Context errors only; the results up to the cancellation are
+//     returned with it
+func (s *StowryService) DeleteMany(ctx context.Context, paths []string) ([]DeleteManyResult, error) {
+	results := make([]DeleteManyResult, 0, len(paths))
+	for _, p := range paths {
+		if err := ctx.Err(); err != nil {
+			return results, fmt.Errorf("delete objects: %w", err)
+		}
+
+		result := DeleteManyResult{Path: p, Status: DeleteStatusDeleted}
+		key := strings.TrimPrefix(p, "/")
+		var err error
+		if key == "" || !IsValidPath(key) {
+			err = fmt.Errorf("delete object %s: %w: invalid path", p, ErrInvalidInput)
+		} else {
+			err = s.Delete(ctx, key)
+		}
+		switch {
+		case err == nil:
+		case errors.Is(err, ErrNotFound):
+			result.Status = DeleteStatusNotFound
+		default:
+			result.Status = DeleteStatusError
+			result.Err = err
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+// deletePrefixPageSize is how many objects DeletePrefix lists at a time.
+const deletePrefixPageSize = 1000
+
+// maxDryRunPaths caps DeletePrefixResult.Paths, so a dry run over a large
+// prefix still counts every object but only holds a page of paths.
+const maxDryRunPaths = deletePrefixPageSize
+
+// DeletePrefixResult summarizes a DeletePrefix call.
+type DeletePrefixResult struct {
+	// Deleted counts the objects soft-deleted, or that would be with a dry run
+	Deleted int
+	// Paths are the first objects that would be deleted, at most 1000; only
+	// set by a dry run
+	Paths []string
+	// PathsTruncated reports that a dry run matched more objects than Paths
+	// lists
+	PathsTruncated bool
+	// Errors are the objects whose delete failed, with DeleteStatusError
+	Errors []DeleteManyResult
+}
+
+// DeletePrefix soft-deletes every active object whose path starts with
+// prefix, as Delete does, listing them a page at a time. A failed delete does
+// not stop the others; objects deleted meanwhile by someone else are left
+// out of the result. An empty prefix matches every object, so callers
+// exposing this must guard against it.
+//
+// Parameters:
+//   - ctx: Context for cancellation and timeout
+//   - prefix: Path prefix, matched as in List; "builds/2023/" deletes that
+//     directory, "builds/2023" also deletes "builds/2023-old.zip"
+//   - dryRun: Only report the objects that would be deleted, listing the
+//     first 1000 of them
+//
+// Returns:
+//   - DeletePrefixResult: The objects deleted and the deletes that failed
+//   - error: ErrInvalidInput for a prefix no path could start with, list or
+//     context errors; the result up to the failure is returned with it
+func (s *StowryService) DeletePrefix(ctx context.Context, prefix string, dryRun bool) (DeletePrefixResult, error) {
+	var result DeletePrefixResult
+	q := ListQuery{PathPrefix: prefix, Limit: deletePrefixPageSize}
+	if err := q.Validate(s.pathLimits); err != nil {
+		return result, fmt.Errorf("delete prefix: %w", err)
+	}
+
+	for {
+		if err := ctx.Err(); err != nil {
+			return result, fmt.Errorf("delete prefix: %w", err)
+		}
+
+		page, err := s.repo.List(ctx, q)
+		if err != nil {
+			return result, fmt.Errorf("delete prefix %s: %w", prefix, err)
+		}
+
+		for _, m := range page.Items {
+			if dryRun {
+				result.Deleted++
+				if len(result.Paths) < maxDryRunPaths {
+					result.Paths = append(result.Paths, m.Path)
+				} else {
+					result.PathsTruncated = true
+				}
+				continue
+			}
+
+			err := s.Delete(ctx, m.Path)
+			switch {
+			case err == nil:
+				result.Deleted++
+			case errors.Is(err, ErrNotFound):
+			case ctx.Err() != nil:
+				return result, fmt.Errorf("delete prefix: %w", ctx.Err())
+			default:
+				result.Errors = append(result.Errors, DeleteManyResult{Path: m.Path, Status: DeleteStatusError, Err: err})
+			}
+		}
+
+		// The cursor is keyed on created_at and path, so deleting the page
+		// does not shift the next one
+		if page.NextCursor == "" {
+			return result, nil
+		}
+		q.Cursor = page.NextCursor
+	}
+}
@@ -0,0 +1,176 @@
+package stowry_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/sagarc03/stowry"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStowryService_DeleteMany(t *testing.T) {
+	newService := func(t *testing.T) (*stowry.StowryService, *SpyMetaDataRepo) {
+		t.Helper()
+		repo := new(SpyMetaDataRepo)
+		service, err := stowry.NewStowryService(repo, new(SpyFileStorage), stowry.ServiceConfig{Mode: stowry.ModeStore})
+		require.NoError(t, err)
+		return service, repo
+	}
+
+	t.Run("reports each path and continues", func(t *testing.T) {
+		service, repo := newService(t)
+		ctx := context.Background()
+		repo.On("Delete", ctx, "a.txt").Return(nil).Once()
+		repo.On("Delete", ctx, "missing.txt").Return(fmt.Errorf("delete: %w", stowry.ErrNotFound)).Once()
+		repo.On("Delete", ctx, "broken.txt").Return(errors.New("db down")).Once()
+		repo.On("Delete", ctx, "dir/c.txt").Return(nil).Once()
+
+		results, err := service.DeleteMany(ctx, []string{"a.txt", "missing.txt", "../x", "broken.txt", "/dir/c.txt"})
+		require.NoError(t, err)
+		require.Len(t, results, 5)
+
+		statuses := make([]stowry.DeleteStatus, len(results))
+		for i, r := range results {
+			statuses[i] = r.Status
+		}
+		assert.Equal(t, []stowry.DeleteStatus{
+			stowry.DeleteStatusDeleted,
+			stowry.DeleteStatusNotFound,
+			stowry.DeleteStatusError,
+			stowry.DeleteStatusError,
+			stowry.DeleteStatusDeleted,
+		}, statuses)
+		assert.Equal(t, "/dir/c.txt", results[4].Path, "paths are reported as given")
+		assert.ErrorIs(t, results[2].Err, stowry.ErrInvalidInput)
+		assert.ErrorContains(t, results[3].Err, "db down")
+		assert.NoError(t, results[1].Err)
+		repo.AssertExpectations(t)
+	})
+
+	t.Run("canceled context", func(t *testing.T) {
+		service, repo := newService(t)
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		results, err := service.DeleteMany(ctx, []string{"a.txt"})
+		assert.ErrorIs(t, err, context.Canceled)
+		assert.Empty(t, results)
+		repo.AssertNotCalled(t, "Delete", mock.Anything, mock.Anything)
+	})
+}
+
+func TestStowryService_DeletePrefix(t *testing.T) {
+	ctx := context.Background()
+	seed := func(t *testing.T, service *stowry.StowryService, paths ...string) {
+		t.Helper()
+		for _, p := range paths {
+			_, err := service.Create(ctx, stowry.CreateObject{Path: p, ContentType: "text/plain"}, strings.NewReader(p))
+			require.NoError(t, err)
+		}
+	}
+
+	t.Run("soft-deletes every object under the prefix", func(t *testing.T) {
+		service := newCopyService(t, newMemStorage())
+		seed(t, service, "builds/2023/a.zip", "builds/2023/x/b.zip", "builds/2024/c.zip", "builds/2023-old.zip")
+
+		result, err := service.DeletePrefix(ctx, "builds/2023/", false)
+		require.NoError(t, err)
+		assert.Equal(t, 2, result.Deleted)
+		assert.Empty(t, result.Paths)
+		assert.Empty(t, result.Errors)
+
+		list, err := service.List(ctx, stowry.ListQuery{Limit: 10})
+		require.NoError(t, err)
+		var left []string
+		for _, m := range list.Items {
+			left = append(left, m.Path)
+		}
+		assert.ElementsMatch(t, []string{"builds/2024/c.zip", "builds/2023-old.zip"}, left)
+
+		// Deleted objects can still be restored
+		_, err = service.Restore(ctx, "builds/2023/a.zip")
+		assert.NoError(t, err)
+	})
+
+	t.Run("dry run only reports", func(t *testing.T) {
+		service := newCopyService(t, newMemStorage())
+		seed(t, service, "builds/2023/a.zip", "builds/2023/b.zip", "keep.txt")
+
+		result, err := service.DeletePrefix(ctx, "builds/", true)
+		require.NoError(t, err)
+		assert.Equal(t, 2, result.Deleted)
+		assert.ElementsMatch(t, []string{"builds/2023/a.zip", "builds/2023/b.zip"}, result.Paths)
+
+		list, err := service.List(ctx, stowry.ListQuery{Limit: 10})
+		require.NoError(t, err)
+		assert.Len(t, list.Items, 3)
+	})
+
+	t.Run("dry run lists only the first paths", func(t *testing.T) {
+		repo := new(SpyMetaDataRepo)
+		service, err := stowry.NewStowryService(repo, new(SpyFileStorage), stowry.ServiceConfig{Mode: stowry.ModeStore})
+		require.NoError(t, err)
+		page := make([]stowry.MetaData, 1000)
+		for i := range page {
+			page[i] = stowry.MetaData{Path: fmt.Sprintf("logs/%04d", i)}
+		}
+		repo.On("List", ctx, stowry.ListQuery{PathPrefix: "logs/", Limit: 1000}).
+			Return(stowry.ListResult{Items: page, NextCursor: "page2"}, nil).Once()
+		repo.On("List", ctx, stowry.ListQuery{PathPrefix: "logs/", Limit: 1000, Cursor: "page2"}).
+			Return(stowry.ListResult{Items: []stowry.MetaData{{Path: "logs/last"}}}, nil).Once()
+
+		result, err := service.DeletePrefix(ctx, "logs/", true)
+		require.NoError(t, err)
+		assert.Equal(t, 1001, result.Deleted)
+		assert.Len(t, result.Paths, 1000)
+		assert.NotContains(t, result.Paths, "logs/last")
+		assert.True(t, result.PathsTruncated)
+		repo.AssertExpectations(t)
+	})
+
+	t.Run("pages through the listing and continues past failures", func(t *testing.T) {
+		repo := new(SpyMetaDataRepo)
+		service, err := stowry.NewStowryService(repo, new(SpyFileStorage), stowry.ServiceConfig{Mode: stowry.ModeStore})
+		require.NoError(t, err)
+		repo.On("List", ctx, stowry.ListQuery{PathPrefix: "logs/", Limit: 1000}).
+			Return(stowry.ListResult{Items: []stowry.MetaData{{Path: "logs/a"}, {Path: "logs/b"}}, NextCursor: "page2"}, nil).Once()
+		repo.On("List", ctx, stowry.ListQuery{PathPrefix: "logs/", Limit: 1000, Cursor: "page2"}).
+			Return(stowry.ListResult{Items: []stowry.MetaData{{Path: "logs/c"}}}, nil).Once()
+		repo.On("Delete", ctx, "logs/a").Return(nil).Once()
+		repo.On("Delete", ctx, "logs/b").Return(errors.New("db down")).Once()
+		repo.On("Delete", ctx, "logs/c").Return(fmt.Errorf("delete: %w", stowry.ErrNotFound)).Once()
+
+		result, err := service.DeletePrefix(ctx, "logs/", false)
+		require.NoError(t, err)
+		assert.Equal(t, 1, result.Deleted)
+		require.Len(t, result.Errors, 1)
+		assert.Equal(t, "logs/b", result.Errors[0].Path)
+		assert.Equal(t, stowry.DeleteStatusError, result.Errors[0].Status)
+		assert.ErrorContains(t, result.Errors[0].Err, "db down")
+		repo.AssertExpectations(t)
+	})
+
+	t.Run("invalid prefix", func(t *testing.T) {
+		service := newCopyService(t, newMemStorage())
+
+		_, err := service.DeletePrefix(ctx, "bad\x00", false)
+		assert.ErrorIs(t, err, stowry.ErrInvalidInput)
+	})
+
+	t.Run("canceled context", func(t *testing.T) {
+		repo := new(SpyMetaDataRepo)
+		service, err := stowry.NewStowryService(repo, new(SpyFileStorage), stowry.ServiceConfig{Mode: stowry.ModeStore})
+		require.NoError(t, err)
+		canceled, cancel := context.WithCancel(ctx)
+		cancel()
+
+		_, err = service.DeletePrefix(canceled, "logs/", false)
+		assert.ErrorIs(t, err, context.Canceled)
+		repo.AssertNotCalled(t, "List", mock.Anything, mock.Anything)
+	})
+}
@@ -0,0 +1,295 @@
+package stowry
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Block sizes accepted by Signature. Clients should use DefaultBlockSize unless
+// they know the write pattern of a file (e.g. the page size of a database).
+const (
+	DefaultBlockSize = 64 << 10
+	MinBlockSize     = 4 << 10
+	MaxBlockSize     = 16 << 20
+)
+
+// SignatureVersion identifies the BlockSignature format. Version 1 splits the
+// object into fixed-size blocks and hashes each with SHA-256.
+const SignatureVersion = 1
+
+// BlockSignature describes an object as a list of block hashes, so a client can
+// tell which blocks of its local copy differ from the stored object.
+type BlockSignature struct {
+	Version   int      `json:"version"`
+	ETag      string   `json:"etag"`
+	Size      int64    `json:"size"`
+	BlockSize int      `json:"block_size"`
+	Blocks    []string `json:"blocks"` // Hex SHA-256 of each block; the last block may be short
+}
+
+// Patch operation sources.
+const (
+	PatchSourceBase = "base" // Copy bytes from the current object
+	PatchSourceData = "data" // Take the next bytes from the patch data
+)
+
+// PatchOp is one step in rebuilding an object. Ops are applied in order and
+// their output is concatenated.
+type PatchOp struct {
+	Source string `json:"source"`
+	Offset int64  `json:"offset,omitempty"` // Start in the current object; only for PatchSourceBase
+	Length int64  `json:"length"`
+}
+
+// DeltaManifest describes how to rebuild an object from its current content and
+// new data. It is only applied while the object's ETag equals BaseETag.
+type DeltaManifest struct {
+	BaseETag    string `json:"base_etag"`
+	Size        int64  `json:"size"`                   // Size of the rebuilt object
+	ContentType string `json:"content_type,omitempty"` // Empty keeps the current content type
+	// ContentEncoding is the coding of the rebuilt object, like the
+	// Content-Encoding of a PUT; empty stores it unencoded
+	ContentEncoding string    `json:"content_encoding,omitempty"`
+	Ops             []PatchOp `json:"ops"`
+}
+
+// Validate checks that the ops stay inside a base object of baseSize bytes and
+// add up to Size.
+func (m DeltaManifest) Validate(baseSize int64) error {
+	if m.BaseETag == "" {
+		return fmt.Errorf("%w: base etag cannot be empty", ErrInvalidInput)
+	}
+
+	var total int64
+	for i, op := range m.Ops {
+		if op.Length <= 0 {
+			return fmt.Errorf("%w: op %d: length must be positive", ErrInvalidInput, i)
+		}
+		switch op.Source {
+		case PatchSourceBase:
+			if op.Offset < 0 || op.Offset > baseSize-op.Length {
+				return fmt.Errorf("%w: op %d: range outside base object", ErrInvalidInput, i)
+			}
+		case PatchSourceData:
+			if op.Offset != 0 {
+				return fmt.Errorf("%w: op %d: data ops take no offset", ErrInvalidInput, i)
+			}
+		default:
+			return fmt.Errorf("%w: op %d: unknown source %q", ErrInvalidInput, i, op.Source)
+		}
+		total += op.Length
+	}
+
+	if total != m.Size {
+		return fmt.Errorf("%w: ops produce %d bytes, manifest size is %d", ErrInvalidInput, total, m.Size)
+	}
+	return nil
+}
+
+// DataSize returns the number of patch data bytes the ops consume.
+func (m DeltaManifest) DataSize() int64 {
+	var n int64
+	for _, op := range m.Ops {
+		if op.Source == PatchSourceData {
+			n += op.Length
+		}
+	}
+	return n
+}
+
+// NewPatchReader returns a reader producing the object described by ops, copying
+// base ranges from base and data ranges from data in order. Ops must have passed
+// DeltaManifest.Validate. The reader fails with ErrInvalidInput if data ends
+// early or holds more bytes than the ops consume.
+func NewPatchReader(base io.ReadSeeker, ops []PatchOp, data io.Reader) io.Reader {
+	return &patchReader{base: base, ops: ops, data: data}
+}
+
+type patchReader struct {
+	base io.ReadSeeker
+	ops  []PatchOp
+	data io.Reader
+	cur  io.Reader
+}
+
+func (p *patchReader) Read(b []byte) (int, error) {
+	for {
+		if p.cur == nil {
+			if len(p.ops) == 0 {
+				return 0, p.finish()
+			}
+			if err := p.next(); err != nil {
+				return 0, err
+			}
+		}
+
+		n, err := p.cur.Read(b)
+		if errors.Is(err, io.EOF) {
+			if lr, ok := p.cur.(*io.LimitedReader); ok && lr.N > 0 {
+				return n, fmt.Errorf("%w: patch source ends early", ErrInvalidInput)
+			}
+			p.cur = nil
+			err = nil
+		}
+		if n > 0 || err != nil {
+			return n, err
+		}
+	}
+}
+
+func (p *patchReader) next() error {
+	op := p.ops[0]
+	p.ops = p.ops[1:]
+
+	if op.Source == PatchSourceBase {
+		if _, err := p.base.Seek(op.Offset, io.SeekStart); err != nil {
+			return fmt.Errorf("seek base object: %w", err)
+		}
+		p.cur = &io.LimitedReader{R: p.base, N: op.Length}
+		return nil
+	}
+	p.cur = &io.LimitedReader{R: p.data, N: op.Length}
+	return nil
+}
+
+// finish reports io.EOF once the patch data is fully consumed.
+func (p *patchReader) finish() error {
+	var extra [1]byte
+	n, err := io.ReadFull(p.data, extra[:])
+	if n > 0 {
+		return fmt.Errorf("%w: patch data has trailing bytes", ErrInvalidInput)
+	}
+	if err != nil && !errors.Is(err, io.EOF) {
+		return err
+	}
+	return io.EOF
+}
+
+// Signature hashes the object at path in fixed blocks of blockSize bytes so a
+// client can upload only the blocks that changed with ApplyDelta. The
+// signature is computed on demand by reading the whole object.
+//
+// Parameters:
+//   - ctx: Context for cancellation and timeout
+//   - path: Exact object path; no static or SPA fallback is applied
+//   - blockSize: Block size in bytes; 0 uses DefaultBlockSize
+//
+// Returns:
+//   - BlockSignature: Block hashes with the object's ETag and size
+//   - error: ErrNotFound, ErrInvalidInput for an out-of-range block size, or storage errors
+func (s *StowryService) Signature(ctx context.Context, path string, blockSize int) (BlockSignature, error) {
+	if err := ctx.Err(); err != nil {
+		return BlockSignature{}, fmt.Errorf("signature: %w", err)
+	}
+
+	if blockSize == 0 {
+		blockSize = DefaultBlockSize
+	}
+	if blockSize < MinBlockSize || blockSize > MaxBlockSize {
+		return BlockSignature{}, fmt.Errorf("signature: %w: block size must be between %d and %d", ErrInvalidInput, MinBlockSize, MaxBlockSize)
+	}
+
+	m, err := s.repo.Get(ctx, path)
+	if err != nil {
+		return BlockSignature{}, fmt.Errorf("signature: %w", err)
+	}
+
+	f, err := s.storageGet(ctx, m.Path)
+	if err != nil {
+		return BlockSignature{}, fmt.Errorf("signature: %w", err)
+	}
+	defer func() { _ = f.Close() }()
+
+	sig := BlockSignature{
+		Version:   SignatureVersion,
+		ETag:      m.Etag,
+		Size:      m.FileSizeBytes,
+		BlockSize: blockSize,
+		Blocks:    make([]string, 0, (m.FileSizeBytes+int64(blockSize)-1)/int64(blockSize)),
+	}
+
+	h := sha256.New()
+	for {
+		h.Reset()
+		n, copyErr := io.CopyN(h, f, int64(blockSize))
+		if n > 0 {
+			sig.Blocks = append(sig.Blocks, hex.EncodeToString(h.Sum(nil)))
+		}
+		if errors.Is(copyErr, io.EOF) {
+			break
+		}
+		if copyErr != nil {
+			return BlockSignature{}, fmt.Errorf("signature %s: %w", path, copyErr)
+		}
+	}
+
+	return sig, nil
+}
+
+// ApplyDelta rebuilds the object at path from its current content and data
+// following m, then stores the result atomically and recomputes its ETag.
+//
+// The rebuilt object is staged like any other upload, so readers see either
+// the old or the new content, never a mix.
+//
+// Parameters:
+//   - ctx: Context for cancellation and timeout
+//   - path: Object path; the object must exist
+//   - m: Base ETag, resulting size and ops; see DeltaManifest
+//   - data: Bytes for the PatchSourceData ops, in op order
+//
+// Returns:
+//   - MetaData: Metadata of the rebuilt object
+//   - error: Any error encountered, including:
+//   - ErrNotFound: The object does not exist
+//   - ErrPreconditionFailed: The object's ETag is not m.BaseETag
+//   - ErrInvalidInput: Invalid path, invalid ops, or data that does not match the ops
+func (s *StowryService) ApplyDelta(ctx context.Context, path string, m DeltaManifest, data io.Reader) (MetaData, error) {
+	if err := ctx.Err(); err != nil {
+		return MetaData{}, fmt.Errorf("apply delta: %w", err)
+	}
+
+	if !IsValidPath(path) {
+		return MetaData{}, fmt.Errorf("apply delta %s: %w", path, ErrInvalidInput)
+	}
+
+	base, err := s.repo.Get(ctx, path)
+	if err != nil {
+		return MetaData{}, fmt.Errorf("apply delta %s: %w", path, err)
+	}
+
+	if base.Etag != m.BaseETag {
+		return MetaData{}, fmt.Errorf("apply delta %s: %w", path, ErrPreconditionFailed)
+	}
+
+	if err := m.Validate(base.FileSizeBytes); err != nil {
+		return MetaData{}, fmt.Errorf("apply delta %s: %w", path, err)
+	}
+
+	f, err := s.storageGet(ctx, base.Path)
+	if err != nil {
+		return MetaData{}, fmt.Errorf("apply delta %s: %w", path, err)
+	}
+	defer func() { _ = f.Close() }()
+
+	contentType := m.ContentType
+	if contentType == "" {
+		contentType = base.ContentType
+	}
+
+	obj := CreateObject{
+		Path:            path,
+		ContentType:     contentType,
+		ContentEncoding: m.ContentEncoding,
+		Filename:        base.Filename, // A patch keeps the original filename
+		UserMetadata:    base.UserMetadata,
+		CacheControl:    base.CacheControl,
+		Size:            m.Size,
+	}
+	res, err := s.createObject(ctx, obj, NewPatchReader(f, m.Ops, data))
+	return res.MetaData, err
+}
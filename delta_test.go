@@ -0,0 +1,210 @@
+package stowry_test
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/sagarc03/stowry"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDeltaManifest_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		m       stowry.DeltaManifest
+		wantErr bool
+	}{
+		{
+			name: "valid",
+			m: stowry.DeltaManifest{BaseETag: "e", Size: 15, Ops: []stowry.PatchOp{
+				{Source: stowry.PatchSourceBase, Offset: 0, Length: 10},
+				{Source: stowry.PatchSourceData, Length: 5},
+			}},
+		},
+		{name: "empty object", m: stowry.DeltaManifest{BaseETag: "e"}},
+		{name: "missing base etag", m: stowry.DeltaManifest{Size: 0}, wantErr: true},
+		{
+			name:    "base range past end",
+			m:       stowry.DeltaManifest{BaseETag: "e", Size: 10, Ops: []stowry.PatchOp{{Source: stowry.PatchSourceBase, Offset: 95, Length: 10}}},
+			wantErr: true,
+		},
+		{
+			name:    "negative offset",
+			m:       stowry.DeltaManifest{BaseETag: "e", Size: 10, Ops: []stowry.PatchOp{{Source: stowry.PatchSourceBase, Offset: -1, Length: 10}}},
+			wantErr: true,
+		},
+		{
+			name:    "zero length",
+			m:       stowry.DeltaManifest{BaseETag: "e", Size: 0, Ops: []stowry.PatchOp{{Source: stowry.PatchSourceData}}},
+			wantErr: true,
+		},
+		{
+			name:    "data op with offset",
+			m:       stowry.DeltaManifest{BaseETag: "e", Size: 5, Ops: []stowry.PatchOp{{Source: stowry.PatchSourceData, Offset: 3, Length: 5}}},
+			wantErr: true,
+		},
+		{
+			name:    "unknown source",
+			m:       stowry.DeltaManifest{BaseETag: "e", Size: 5, Ops: []stowry.PatchOp{{Source: "elsewhere", Length: 5}}},
+			wantErr: true,
+		},
+		{
+			name:    "size mismatch",
+			m:       stowry.DeltaManifest{BaseETag: "e", Size: 6, Ops: []stowry.PatchOp{{Source: stowry.PatchSourceData, Length: 5}}},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.m.Validate(100)
+			if tt.wantErr {
+				assert.ErrorIs(t, err, stowry.ErrInvalidInput)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestNewPatchReader(t *testing.T) {
+	base := strings.NewReader("hello world")
+	ops := []stowry.PatchOp{
+		{Source: stowry.PatchSourceBase, Offset: 6, Length: 5},
+		{Source: stowry.PatchSourceData, Length: 2},
+		{Source: stowry.PatchSourceBase, Offset: 0, Length: 5},
+	}
+
+	t.Run("assembles ops in order", func(t *testing.T) {
+		got, err := io.ReadAll(stowry.NewPatchReader(base, ops, strings.NewReader(", ")))
+		require.NoError(t, err)
+		assert.Equal(t, "world, hello", string(got))
+	})
+
+	t.Run("short data", func(t *testing.T) {
+		_, err := io.ReadAll(stowry.NewPatchReader(base, ops, strings.NewReader(",")))
+		assert.ErrorIs(t, err, stowry.ErrInvalidInput)
+	})
+
+	t.Run("trailing data", func(t *testing.T) {
+		_, err := io.ReadAll(stowry.NewPatchReader(base, ops, strings.NewReader(", !")))
+		assert.ErrorIs(t, err, stowry.ErrInvalidInput)
+	})
+}
+
+func blockHash(b []byte) string {
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
+
+func TestStowryService_Signature(t *testing.T) {
+	content := bytes.Repeat([]byte("a"), stowry.MinBlockSize)
+	content = append(content, []byte("tail")...)
+
+	t.Run("hashes fixed blocks", func(t *testing.T) {
+		service, repo, storage := NewStowryService(t)
+		ctx := context.Background()
+		repo.On("Get", ctx, "db.sqlite").Return(stowry.MetaData{Path: "db.sqlite", Etag: "etag", FileSizeBytes: int64(len(content))}, nil)
+		storage.On("Get", ctx, "db.sqlite").Return(io.ReadSeekCloser(&mockReadSeekCloser{content: content}), nil)
+
+		sig, err := service.Signature(ctx, "db.sqlite", stowry.MinBlockSize)
+
+		require.NoError(t, err)
+		assert.Equal(t, stowry.SignatureVersion, sig.Version)
+		assert.Equal(t, "etag", sig.ETag)
+		assert.Equal(t, int64(len(content)), sig.Size)
+		assert.Equal(t, []string{blockHash(content[:stowry.MinBlockSize]), blockHash([]byte("tail"))}, sig.Blocks)
+	})
+
+	t.Run("invalid block size", func(t *testing.T) {
+		service, _, _ := NewStowryService(t)
+
+		_, err := service.Signature(context.Background(), "db.sqlite", 10)
+
+		assert.ErrorIs(t, err, stowry.ErrInvalidInput)
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		service, repo, _ := NewStowryService(t)
+		ctx := context.Background()
+		repo.On("Get", ctx, "missing").Return(stowry.MetaData{}, stowry.ErrNotFound)
+
+		_, err := service.Signature(ctx, "missing", 0)
+
+		assert.ErrorIs(t, err, stowry.ErrNotFound)
+	})
+}
+
+func TestStowryService_ApplyDelta(t *testing.T) {
+	base := stowry.MetaData{ID: uuid.New(), Path: "notes.txt", Etag: "v1", ContentType: "text/plain", FileSizeBytes: 11}
+	manifest := stowry.DeltaManifest{BaseETag: "v1", Size: 12, Ops: []stowry.PatchOp{
+		{Source: stowry.PatchSourceBase, Offset: 0, Length: 6},
+		{Source: stowry.PatchSourceData, Length: 6},
+	}}
+
+	t.Run("rebuilds and stores the object", func(t *testing.T) {
+		service, repo, storage := NewStowryService(t)
+		ctx := context.Background()
+		repo.On("Get", ctx, "notes.txt").Return(base, nil)
+		storage.On("Get", ctx, "notes.txt").Return(io.ReadSeekCloser(&mockReadSeekCloser{content: []byte("hello world")}), nil)
+
+		var written []byte
+		storage.On("Write", ctx, "notes.txt", mock.Anything).Run(func(args mock.Arguments) {
+			written, _ = io.ReadAll(args.Get(2).(io.Reader))
+		}).Return(stowry.SaveResult{BytesWritten: 12, Etag: "v2"}, nil)
+
+		updated := base
+		updated.Etag = "v2"
+		updated.FileSizeBytes = 12
+		repo.On("Upsert", ctx, stowry.ObjectEntry{Path: "notes.txt", Size: 12, ETag: "v2", ContentType: "text/plain"}).Return(updated, &base, nil)
+
+		m, err := service.ApplyDelta(ctx, "notes.txt", manifest, strings.NewReader("gopher"))
+
+		require.NoError(t, err)
+		assert.Equal(t, "hello gopher", string(written))
+		assert.Equal(t, "v2", m.Etag)
+	})
+
+	t.Run("base etag mismatch", func(t *testing.T) {
+		service, repo, storage := NewStowryService(t)
+		ctx := context.Background()
+		changed := base
+		changed.Etag = "v3"
+		repo.On("Get", ctx, "notes.txt").Return(changed, nil)
+
+		_, err := service.ApplyDelta(ctx, "notes.txt", manifest, strings.NewReader("gopher"))
+
+		assert.ErrorIs(t, err, stowry.ErrPreconditionFailed)
+		storage.AssertNotCalled(t, "Write", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("invalid ops", func(t *testing.T) {
+		service, repo, _ := NewStowryService(t)
+		ctx := context.Background()
+		repo.On("Get", ctx, "notes.txt").Return(base, nil)
+		bad := manifest
+		bad.Size = 100
+
+		_, err := service.ApplyDelta(ctx, "notes.txt", bad, strings.NewReader("gopher"))
+
+		assert.ErrorIs(t, err, stowry.ErrInvalidInput)
+	})
+
+	t.Run("missing object", func(t *testing.T) {
+		service, repo, _ := NewStowryService(t)
+		ctx := context.Background()
+		repo.On("Get", ctx, "notes.txt").Return(stowry.MetaData{}, stowry.ErrNotFound)
+
+		_, err := service.ApplyDelta(ctx, "notes.txt", manifest, strings.NewReader("gopher"))
+
+		assert.ErrorIs(t, err, stowry.ErrNotFound)
+	})
+}
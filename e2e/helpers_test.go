@@ -92,7 +92,11 @@ type ServerConfig struct {
 	AuthRead      string    // public, private
 	AuthWrite     string    // public, private
 	AuthKeys      []AuthKey // Access keys for private auth
+	KeysExtra     string    // YAML appended to the auth.keys section (optional)
 	ErrorDocument string    // Custom error page path (optional)
+	ExtraConfig   string    // YAML appended to the generated config (optional)
+	ServerExtra   string    // YAML appended to the server section (optional)
+	SkipInit      bool      // Start on a database "stowry init" has not prepared
 }
 
 // buildBinary compiles the stowry binary once per test run.
@@ -174,7 +178,7 @@ func createConfigFile(t *testing.T, cfg ServerConfig) string {
   port: %d
   mode: %s
   error_document: "%s"
-
+%s
 database:
   type: %s
   dsn: "%s"
@@ -192,6 +196,7 @@ auth:
 		cfg.Port,
 		cfg.Mode,
 		cfg.ErrorDocument,
+		cfg.ServerExtra,
 		cfg.DBType,
 		cfg.DBDSN,
 		cfg.StoragePath,
@@ -200,14 +205,19 @@ auth:
 	)
 
 	// Add auth keys if provided
-	if len(cfg.AuthKeys) > 0 {
-		sb.WriteString("  keys:\n    inline:\n")
+	if len(cfg.AuthKeys) > 0 || cfg.KeysExtra != "" {
+		sb.WriteString("  keys:\n")
+		if len(cfg.AuthKeys) > 0 {
+			sb.WriteString("    inline:\n")
+		}
 		for _, key := range cfg.AuthKeys {
 			fmt.Fprintf(&sb, "      - access_key: %s\n        secret_key: %s\n", key.AccessKey, key.SecretKey)
 		}
+		sb.WriteString(cfg.KeysExtra)
 	}
 
 	sb.WriteString("\nlog:\n  level: error\n")
+	sb.WriteString(cfg.ExtraConfig)
 
 	configPath := filepath.Join(t.TempDir(), "config.yaml")
 	err := os.WriteFile(configPath, []byte(sb.String()), 0o600)
@@ -216,13 +226,31 @@ auth:
 	return configPath
 }
 
+// runStowry runs a stowry command with a config file for cfg and returns
+// its combined output.
+func runStowry(t *testing.T, cfg ServerConfig, args ...string) (string, error) {
+	t.Helper()
+
+	binary := buildBinary(t)
+	configPath := createConfigFile(t, cfg)
+	cmd := exec.Command(binary, append(args, "--config", configPath)...)
+	output, err := cmd.CombinedOutput()
+	return string(output), err
+}
+
 // startServer starts the stowry binary with the given configuration.
 // Returns the base URL and a cleanup function that must be called to stop the server.
+//
+// Tests that only need a mode, auth settings and seeded objects use
+// stowrytest.NewTestServer instead; the binary is for configuration file
+// options and PostgreSQL.
 func startServer(t *testing.T, cfg ServerConfig) (string, func()) {
 	t.Helper()
 
 	// Initialize the database before starting the server
-	initDatabase(t, cfg)
+	if !cfg.SkipInit {
+		initDatabase(t, cfg)
+	}
 
 	binary := buildBinary(t)
 
@@ -258,7 +286,8 @@ func startServer(t *testing.T, cfg ServerConfig) (string, func()) {
 	return baseURL, cleanup
 }
 
-// waitForServer polls the server until it responds or times out.
+// waitForServer polls the server until it is ready or times out. The
+// listener is up before startup tasks finish, so it waits for /readyz.
 func waitForServer(t *testing.T, baseURL string, timeout time.Duration) {
 	t.Helper()
 
@@ -266,10 +295,12 @@ func waitForServer(t *testing.T, baseURL string, timeout time.Duration) {
 	client := &http.Client{Timeout: 1 * time.Second}
 
 	for time.Now().Before(deadline) {
-		resp, err := client.Get(baseURL + "/")
+		resp, err := client.Get(baseURL + "/readyz")
 		if err == nil {
 			resp.Body.Close()
-			return // Server is ready
+			if resp.StatusCode == http.StatusOK {
+				return // Server is ready
+			}
 		}
 		time.Sleep(100 * time.Millisecond)
 	}
@@ -0,0 +1,112 @@
+package stowry
+
+import (
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// ContentEncodingGzip is the only content-coding accepted on upload, since it
+// is the one every HTTP client can decode and the server can decode on the fly
+// for clients that cannot.
+const ContentEncodingGzip = "gzip"
+
+// NormalizeContentEncoding validates an upload Content-Encoding value and
+// returns its canonical form: "gzip" for gzip and its x-gzip alias, and ""
+// for an empty value or identity.
+//
+// Returns:
+//   - string: The normalized content-coding
+//   - error: ErrUnsupportedContentEncoding (which also matches ErrInvalidInput)
+//     for any other coding, including lists of several codings
+func NormalizeContentEncoding(value string) (string, error) {
+	switch coding := strings.ToLower(strings.TrimSpace(value)); coding {
+	case "", "identity":
+		return "", nil
+	case ContentEncodingGzip, "x-gzip":
+		return ContentEncodingGzip, nil
+	default:
+		return "", fmt.Errorf("%w: %q (supported: %s)", ErrUnsupportedContentEncoding, value, ContentEncodingGzip)
+	}
+}
+
+// errDecodeAborted stops the decoder of a write that ended without reading
+// the content to the end.
+var errDecodeAborted = errors.New("decode aborted")
+
+// decodingReader passes content through unchanged while decoding a copy of it
+// in the background, which both checks the encoding and measures the decoded
+// size without a second read of the stored file. At the end of the content it returns
+// ErrInvalidEncodedContent instead of io.EOF when the copy did not decode, so
+// storage discards the write instead of committing it.
+type decodingReader struct {
+	r      io.Reader
+	pw     *io.PipeWriter
+	result chan decodeResult
+	done   bool
+	size   int64
+	err    error
+}
+
+type decodeResult struct {
+	size int64
+	err  error
+}
+
+// newDecodingReader starts decoding r as gzip, the only accepted coding.
+// close must be called once the reader is no longer used.
+func newDecodingReader(r io.Reader) *decodingReader {
+	pr, pw := io.Pipe()
+	d := &decodingReader{r: r, pw: pw, result: make(chan decodeResult, 1)}
+
+	go func() {
+		var res decodeResult
+		zr, err := gzip.NewReader(pr)
+		if err == nil {
+			res.size, err = io.Copy(io.Discard, zr)
+		}
+		res.err = err
+		// Keep draining so writes to the pipe never block on a failed decode
+		_, _ = io.Copy(io.Discard, pr)
+		d.result <- res
+	}()
+
+	return d
+}
+
+func (d *decodingReader) Read(p []byte) (int, error) {
+	if d.done {
+		return 0, d.err
+	}
+
+	n, err := d.r.Read(p)
+	if n > 0 {
+		_, _ = d.pw.Write(p[:n])
+	}
+
+	switch {
+	case errors.Is(err, io.EOF):
+		_ = d.pw.Close()
+		res := <-d.result
+		d.done, d.size, d.err = true, res.size, io.EOF
+		if res.err != nil {
+			d.err = fmt.Errorf("%w: %w", ErrInvalidEncodedContent, res.err)
+		}
+		return n, d.err
+	case err != nil:
+		_ = d.pw.CloseWithError(err)
+	}
+	return n, err
+}
+
+// decodedSize returns the decoded length once the content was read to the end.
+func (d *decodingReader) decodedSize() int64 {
+	return d.size
+}
+
+// close stops the decoder if the content was not read to the end.
+func (d *decodingReader) close() {
+	_ = d.pw.CloseWithError(errDecodeAborted)
+}
@@ -0,0 +1,89 @@
+package stowry_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/sagarc03/stowry"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNormalizeContentEncoding(t *testing.T) {
+	valid := map[string]string{
+		"":         "",
+		"identity": "",
+		"gzip":     "gzip",
+		" GZip ":   "gzip",
+		"x-gzip":   "gzip",
+	}
+	for in, want := range valid {
+		got, err := stowry.NormalizeContentEncoding(in)
+		require.NoError(t, err, in)
+		assert.Equal(t, want, got, in)
+	}
+
+	for _, in := range []string{"br", "deflate", "gzip, br", "compress"} {
+		_, err := stowry.NormalizeContentEncoding(in)
+		assert.ErrorIs(t, err, stowry.ErrUnsupportedContentEncoding, in)
+		assert.ErrorIs(t, err, stowry.ErrInvalidInput, in)
+	}
+}
+
+func TestStowryService_Create_ContentEncoding(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("gzip records the decoded size", func(t *testing.T) {
+		service, repo, storage := NewStowryService(t)
+		plain := strings.Repeat("hello ", 100)
+		var buf bytes.Buffer
+		zw := gzip.NewWriter(&buf)
+		_, _ = zw.Write([]byte(plain))
+		require.NoError(t, zw.Close())
+		stored := buf.Bytes()
+
+		var written []byte
+		storage.On("Write", ctx, "a.txt", mock.Anything).Run(func(args mock.Arguments) {
+			written, _ = io.ReadAll(args.Get(2).(io.Reader))
+		}).Return(stowry.SaveResult{BytesWritten: int64(len(stored)), Etag: "e"}, nil)
+		repo.On("Upsert", ctx, mock.MatchedBy(func(entry stowry.ObjectEntry) bool {
+			return entry.ContentEncoding == "gzip" &&
+				entry.Size == int64(len(stored)) &&
+				entry.DecodedSize != nil && *entry.DecodedSize == int64(len(plain))
+		})).Return(stowry.MetaData{Path: "a.txt", ContentEncoding: "gzip"}, nil, nil)
+
+		_, err := service.Create(ctx, stowry.CreateObject{Path: "a.txt", ContentType: "text/plain", ContentEncoding: "x-gzip"}, bytes.NewReader(stored))
+		require.NoError(t, err)
+		assert.Equal(t, stored, written, "stored bytes are the encoded upload")
+		repo.AssertExpectations(t)
+	})
+
+	t.Run("invalid gzip fails the write", func(t *testing.T) {
+		service, repo, storage := NewStowryService(t)
+
+		var readErr error
+		storage.On("Write", ctx, "a.txt", mock.Anything).Run(func(args mock.Arguments) {
+			_, readErr = io.ReadAll(args.Get(2).(io.Reader))
+		}).Return(stowry.SaveResult{}, fmt.Errorf("write: %w", stowry.ErrInvalidEncodedContent))
+
+		_, err := service.Create(ctx, stowry.CreateObject{Path: "a.txt", ContentType: "text/plain", ContentEncoding: "gzip"}, strings.NewReader("not gzip"))
+		assert.ErrorIs(t, err, stowry.ErrInvalidEncodedContent)
+		assert.ErrorIs(t, readErr, stowry.ErrInvalidEncodedContent)
+		repo.AssertNotCalled(t, "Upsert")
+	})
+
+	t.Run("unsupported coding", func(t *testing.T) {
+		service, repo, storage := NewStowryService(t)
+
+		_, err := service.Create(ctx, stowry.CreateObject{Path: "a.txt", ContentType: "text/plain", ContentEncoding: "br"}, strings.NewReader("x"))
+		assert.ErrorIs(t, err, stowry.ErrUnsupportedContentEncoding)
+		storage.AssertNotCalled(t, "Write")
+		repo.AssertNotCalled(t, "Upsert")
+	})
+}
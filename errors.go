@@ -1,10 +1,88 @@
 package stowry
 
-import "errors"
+import (
+	"errors"
+	"fmt"
+)
 
 var (
 	// ErrNotFound is returned when a resource is not found
 	ErrNotFound = errors.New("not found")
 	// ErrInvalidInput is returned when input validation fails
 	ErrInvalidInput = errors.New("invalid input")
+	// ErrInsufficientStorage is returned when storage has no room for an object
+	ErrInsufficientStorage = errors.New("insufficient storage")
+	// ErrConflict is returned when a concurrent writer created the same path first
+	ErrConflict = errors.New("conflict")
+	// ErrAlreadyExists is returned when an object is moved to a path that
+	// already holds an active object
+	ErrAlreadyExists = errors.New("already exists")
+	// ErrIDConflict is returned when a client-supplied object ID belongs to
+	// another object, or differs from the ID of the object it would replace
+	ErrIDConflict = errors.New("id conflict")
+	// ErrPreconditionFailed is returned when an object no longer matches the
+	// version a change was based on
+	ErrPreconditionFailed = errors.New("precondition failed")
+	// ErrObjectLimitExceeded is returned when a create would take a prefix past
+	// its object limit. The error is an *ObjectLimitError.
+	ErrObjectLimitExceeded = errors.New("object limit exceeded")
+	// ErrNotSupported is returned when the configured repository or storage
+	// lacks an optional capability an operation needs
+	ErrNotSupported = errors.New("not supported")
+	// ErrInvalidContentType is returned for a Content-Type that is not a valid
+	// media type. It wraps ErrInvalidInput.
+	ErrInvalidContentType = fmt.Errorf("invalid content type: %w", ErrInvalidInput)
+	// ErrContentTypeNotAllowed is returned when a ContentTypeRule forbids the
+	// content type of an upload. The error is a *ContentTypeError.
+	ErrContentTypeNotAllowed = errors.New("content type not allowed")
+	// ErrUnsupportedContentEncoding is returned for an upload Content-Encoding
+	// other than gzip. It wraps ErrInvalidInput.
+	ErrUnsupportedContentEncoding = fmt.Errorf("unsupported content encoding: %w", ErrInvalidInput)
+	// ErrInvalidEncodedContent is returned when uploaded content cannot be
+	// decoded with its Content-Encoding. It wraps ErrInvalidInput.
+	ErrInvalidEncodedContent = fmt.Errorf("content does not match its content encoding: %w", ErrInvalidInput)
+	// ErrInvalidID is returned for a client-supplied object ID that is neither
+	// a UUID nor a ULID. It wraps ErrInvalidInput.
+	ErrInvalidID = fmt.Errorf("invalid object id: %w", ErrInvalidInput)
+	// ErrInvalidETag is returned for a permalink ETag that is not a SHA-256
+	// digest in lowercase hex. It wraps ErrInvalidInput.
+	ErrInvalidETag = fmt.Errorf("invalid etag: %w", ErrInvalidInput)
+	// ErrInvalidFilename is returned for an original filename that is too long
+	// or not valid UTF-8. It wraps ErrInvalidInput.
+	ErrInvalidFilename = fmt.Errorf("invalid filename: %w", ErrInvalidInput)
+	// ErrInvalidUserMetadata is returned for a user metadata key that is not
+	// a header token, or a value with control characters or invalid UTF-8.
+	// It wraps ErrInvalidInput.
+	ErrInvalidUserMetadata = fmt.Errorf("invalid user metadata: %w", ErrInvalidInput)
+	// ErrUserMetadataTooLarge is returned for user metadata larger than
+	// MaxUserMetadataBytes. It wraps ErrInvalidInput.
+	ErrUserMetadataTooLarge = fmt.Errorf("user metadata too large: %w", ErrInvalidInput)
+	// ErrInvalidCacheControl is returned for a Cache-Control value that is too
+	// long or contains control characters. It wraps ErrInvalidInput.
+	ErrInvalidCacheControl = fmt.Errorf("invalid cache control: %w", ErrInvalidInput)
+	// ErrPathLimitExceeded is returned for an object path longer or deeper
+	// than the configured PathLimits, or than the storage backend accepts. It
+	// wraps ErrInvalidInput.
+	ErrPathLimitExceeded = fmt.Errorf("path limit exceeded: %w", ErrInvalidInput)
+	// ErrInvalidPart is returned for a multipart part number out of range, or
+	// a completion listing parts out of order, not uploaded, or with another
+	// ETag. It wraps ErrInvalidInput.
+	ErrInvalidPart = fmt.Errorf("invalid part: %w", ErrInvalidInput)
+	// ErrObjectTooLarge is returned when the parts of a multipart upload add
+	// up to more than the maximum size given to CompleteMultipart. It wraps
+	// ErrInvalidInput.
+	ErrObjectTooLarge = fmt.Errorf("object too large: %w", ErrInvalidInput)
+	// ErrCursorExpired is returned when changes after a change feed cursor
+	// were already pruned
+	ErrCursorExpired = errors.New("cursor expired")
+	// ErrGone is returned when a soft-deleted object was already cleaned up,
+	// so its content no longer exists
+	ErrGone = errors.New("gone")
+	// ErrUnavailable is returned when a dependency an operation needs, such
+	// as the metadata database, is known to be down, so the operation was
+	// not attempted
+	ErrUnavailable = errors.New("unavailable")
+	// ErrInstanceMismatch is returned when the database and storage directory
+	// belong to different instances. The error is an *InstanceMismatchError.
+	ErrInstanceMismatch = errors.New("instance mismatch")
 )
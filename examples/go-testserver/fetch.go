@@ -0,0 +1,54 @@
+// Package fetch downloads objects from Stowry, retrying server errors. It is
+// the code under test in this example.
+package fetch
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// Fetch downloads url, making up to attempts requests while the server
+// answers with a 5xx status.
+func Fetch(ctx context.Context, client *http.Client, url string, attempts int) ([]byte, error) {
+	var lastErr error
+	for attempt := range attempts {
+		if attempt > 0 {
+			select {
+			case <-time.After(time.Duration(attempt) * 10 * time.Millisecond):
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			}
+		}
+
+		body, retry, err := fetchOnce(ctx, client, url)
+		if err == nil {
+			return body, nil
+		}
+		if !retry {
+			return nil, err
+		}
+		lastErr = err
+	}
+	return nil, fmt.Errorf("fetch %s: giving up after %d attempts: %w", url, attempts, lastErr)
+}
+
+func fetchOnce(ctx context.Context, client *http.Client, url string) ([]byte, bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, false, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, true, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, resp.StatusCode >= 500, fmt.Errorf("fetch %s: %s", url, resp.Status)
+	}
+	body, err := io.ReadAll(resp.Body)
+	return body, true, err
+}
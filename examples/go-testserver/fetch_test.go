@@ -0,0 +1,60 @@
+package fetch_test
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/sagarc03/stowry/stowrytest"
+
+	fetch "stowry-testserver-example"
+)
+
+var seed = []stowrytest.SeedObject{{Path: "config/app.json", Content: []byte(`{"feature":true}`)}}
+
+func TestFetch(t *testing.T) {
+	srv := stowrytest.NewTestServer(t, stowrytest.Options{Seed: seed})
+
+	body, err := fetch.Fetch(context.Background(), http.DefaultClient, srv.URL+"/config/app.json", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(body) != `{"feature":true}` {
+		t.Fatalf("got %q", body)
+	}
+}
+
+func TestFetch_RetriesServerErrors(t *testing.T) {
+	// Half of the metadata lookups fail. The fault sequence is fixed by the
+	// seed, so this test sees the same failures on every run.
+	srv := stowrytest.NewTestServer(t, stowrytest.Options{
+		Seed:       seed,
+		RepoFaults: stowrytest.Faults{FailureRate: 0.5},
+		FaultSeed:  7,
+	})
+
+	for range 10 {
+		if _, err := fetch.Fetch(context.Background(), http.DefaultClient, srv.URL+"/config/app.json", 8); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestFetch_GivesUp(t *testing.T) {
+	srv := stowrytest.NewTestServer(t, stowrytest.Options{
+		Seed:          seed,
+		StorageFaults: stowrytest.Faults{FailureRate: 1},
+	})
+
+	if _, err := fetch.Fetch(context.Background(), http.DefaultClient, srv.URL+"/config/app.json", 3); err == nil {
+		t.Fatal("expected an error once every attempt fails")
+	}
+}
+
+func TestFetch_NotFoundIsNotRetried(t *testing.T) {
+	srv := stowrytest.NewTestServer(t, stowrytest.Options{})
+
+	if _, err := fetch.Fetch(context.Background(), http.DefaultClient, srv.URL+"/missing.json", 3); err == nil {
+		t.Fatal("expected an error for a missing object")
+	}
+}
@@ -0,0 +1,45 @@
+package stowry
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// MaxFilenameBytes is the longest original filename, in bytes, that is
+// stored with an object.
+const MaxFilenameBytes = 255
+
+// SanitizeFilename returns the name to store as an object's original
+// filename. Only the final segment of a path is kept, whether it is separated
+// by / or \ as browsers on Windows send it, control characters are removed
+// and surrounding spaces trimmed. A name left empty, or only dots, yields "".
+//
+// Returns:
+//   - string: The sanitized filename, or "" for none
+//   - error: ErrInvalidFilename (which also matches ErrInvalidInput) when the
+//     name is not valid UTF-8 or is longer than MaxFilenameBytes
+func SanitizeFilename(name string) (string, error) {
+	if !utf8.ValidString(name) {
+		return "", fmt.Errorf("%w: not valid UTF-8", ErrInvalidFilename)
+	}
+
+	if i := strings.LastIndexAny(name, `/\`); i >= 0 {
+		name = name[i+1:]
+	}
+	name = strings.TrimSpace(strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) {
+			return -1
+		}
+		return r
+	}, name))
+
+	if strings.Trim(name, ".") == "" {
+		return "", nil
+	}
+	if len(name) > MaxFilenameBytes {
+		return "", fmt.Errorf("%w: %d bytes (max %d)", ErrInvalidFilename, len(name), MaxFilenameBytes)
+	}
+	return name, nil
+}
@@ -0,0 +1,79 @@
+package stowry_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/sagarc03/stowry"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSanitizeFilename(t *testing.T) {
+	valid := map[string]string{
+		"":                            "",
+		"report.pdf":                  "report.pdf",
+		"  Q3 report.pdf ":            "Q3 report.pdf",
+		"dir/sub/photo.jpg":           "photo.jpg",
+		`C:\fakepath\invoice.pdf`:     "invoice.pdf",
+		"evil\r\nSet-Cookie: x=1.txt": "evilSet-Cookie: x=1.txt",
+		"tab\there.txt":               "tabhere.txt",
+		"résumé 履歴書.pdf":              "résumé 履歴書.pdf",
+		"..":                          "",
+		"dir/":                        "",
+		"\x00\x01":                    "",
+	}
+	for in, want := range valid {
+		got, err := stowry.SanitizeFilename(in)
+		require.NoError(t, err, in)
+		assert.Equal(t, want, got, in)
+	}
+
+	at := strings.Repeat("a", stowry.MaxFilenameBytes)
+	got, err := stowry.SanitizeFilename("dir/" + at)
+	require.NoError(t, err)
+	assert.Equal(t, at, got, "the length cap applies to the final segment")
+
+	for _, in := range []string{at + "a", strings.Repeat("é", stowry.MaxFilenameBytes/2+1), "bad\xffutf8"} {
+		_, err := stowry.SanitizeFilename(in)
+		assert.ErrorIs(t, err, stowry.ErrInvalidFilename)
+		assert.ErrorIs(t, err, stowry.ErrInvalidInput)
+	}
+}
+
+func TestStowryService_Create_Filename(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("sanitized filename is recorded", func(t *testing.T) {
+		service, repo, storage := NewStowryService(t)
+
+		storage.On("Write", ctx, "uploads/3f2a.pdf", mock.Anything).Return(stowry.SaveResult{BytesWritten: 4, Etag: "e"}, nil)
+		repo.On("Upsert", ctx, mock.MatchedBy(func(entry stowry.ObjectEntry) bool {
+			return entry.Filename == "invoice.pdf"
+		})).Return(stowry.MetaData{Path: "uploads/3f2a.pdf", Filename: "invoice.pdf"}, nil, nil)
+
+		m, err := service.Create(ctx, stowry.CreateObject{
+			Path:        "uploads/3f2a.pdf",
+			ContentType: "application/pdf",
+			Filename:    `C:\fakepath\invoice.pdf`,
+		}, strings.NewReader("data"))
+		require.NoError(t, err)
+		assert.Equal(t, "invoice.pdf", m.Filename)
+		repo.AssertExpectations(t)
+	})
+
+	t.Run("too long filename is rejected before the write", func(t *testing.T) {
+		service, repo, storage := NewStowryService(t)
+
+		_, err := service.Create(ctx, stowry.CreateObject{
+			Path:        "a.txt",
+			ContentType: "text/plain",
+			Filename:    strings.Repeat("a", stowry.MaxFilenameBytes+1),
+		}, strings.NewReader("data"))
+		assert.ErrorIs(t, err, stowry.ErrInvalidFilename)
+		storage.AssertNotCalled(t, "Write")
+		repo.AssertNotCalled(t, "Upsert")
+	})
+}
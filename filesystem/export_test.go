@@ -0,0 +1,13 @@
+package filesystem
+
+import "io"
+
+// SetTempWriterWrapper wraps the temp file writer used by Write.
+func SetTempWriterWrapper(s *Store, wrap func(io.Writer) io.Writer) {
+	s.wrapTemp = wrap
+}
+
+// SetRename replaces the rename used to move staged files into the root.
+func SetRename(s *Store, rename func(oldpath, newpath string) error) {
+	s.rename = rename
+}
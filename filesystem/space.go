@@ -0,0 +1,15 @@
+package filesystem
+
+// DiskUsage is the size and free space of a filesystem, in bytes.
+type DiskUsage struct {
+	TotalBytes     uint64 `json:"total_bytes"`
+	FreeBytes      uint64 `json:"free_bytes"`
+	AvailableBytes uint64 `json:"available_bytes"` // Free to unprivileged users
+}
+
+// availableBytes returns the bytes available to unprivileged users on the
+// filesystem containing dir.
+func availableBytes(dir string) (uint64, bool) {
+	usage, ok := StatDisk(dir)
+	return usage.AvailableBytes, ok
+}
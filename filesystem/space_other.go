@@ -0,0 +1,8 @@
+//go:build !linux && !darwin
+
+package filesystem
+
+// StatDisk reports that disk usage is unknown on this platform.
+func StatDisk(string) (DiskUsage, bool) {
+	return DiskUsage{}, false
+}
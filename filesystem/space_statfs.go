@@ -0,0 +1,20 @@
+//go:build linux || darwin
+
+package filesystem
+
+import "golang.org/x/sys/unix"
+
+// StatDisk returns the size and free space of the filesystem containing dir.
+// ok is false when they are unknown.
+func StatDisk(dir string) (usage DiskUsage, ok bool) {
+	var st unix.Statfs_t
+	if err := unix.Statfs(dir, &st); err != nil {
+		return DiskUsage{}, false
+	}
+	bsize := uint64(st.Bsize) //nolint:gosec // G115: block size is positive
+	return DiskUsage{
+		TotalBytes:     st.Blocks * bsize,
+		FreeBytes:      st.Bfree * bsize,
+		AvailableBytes: st.Bavail * bsize,
+	}, true
+}
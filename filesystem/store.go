@@ -15,6 +15,8 @@ import (
 	"mime"
 	"os"
 	"path/filepath"
+	"strings"
+	"syscall"
 
 	"github.com/google/uuid"
 	"github.com/sagarc03/stowry"
@@ -22,13 +24,61 @@ import (
 
 // Store provides file system storage operations.
 type Store struct {
-	root *os.Root
+	root    *os.Root
+	tempDir string
+
+	// Test hooks for fault injection.
+	wrapTemp func(io.Writer) io.Writer
+	rename   func(oldpath, newpath string) error
+}
+
+// Option configures a Store.
+type Option func(*Store)
+
+// WithTempDir stages uploads in dir instead of the storage root. Use it to put
+// in-flight uploads on a larger scratch volume. When dir is on a different
+// filesystem, finalizing falls back to copy, fsync, and rename.
+func WithTempDir(dir string) Option {
+	return func(s *Store) {
+		s.tempDir = dir
+	}
 }
 
 // NewFileStorage creates a new Store with the given root directory.
 // The root provides sandboxed file operations preventing path traversal.
-func NewFileStorage(root *os.Root) *Store {
-	return &Store{root: root}
+func NewFileStorage(root *os.Root, opts ...Option) *Store {
+	s := &Store{root: root, rename: os.Rename}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// CheckSpace reports stowry.ErrInsufficientStorage when the storage root, or the
+// temp directory if configured, has fewer than size bytes available.
+// It implements stowry.SpaceChecker.
+func (s *Store) CheckSpace(ctx context.Context, size int64) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	dirs := []string{s.root.Name()}
+	if s.tempDir != "" {
+		dirs = append(dirs, s.tempDir)
+	}
+
+	for _, dir := range dirs {
+		available, ok := availableBytes(dir)
+		if !ok {
+			continue
+		}
+		if available < uint64(size) { //nolint:gosec // G115: size is positive
+			slog.Warn("insufficient storage for upload", "dir", dir, "available_bytes", available, "needed_bytes", size)
+			return fmt.Errorf("%w: %d bytes needed, %d available in %s", stowry.ErrInsufficientStorage, size, available, dir)
+		}
+	}
+
+	return nil
 }
 
 // Get opens a file for reading. Returns stowry.ErrNotFound if the file does not exist.
@@ -42,7 +92,7 @@ func (s *Store) Get(ctx context.Context, path string) (io.ReadSeekCloser, error)
 		if errors.Is(err, os.ErrNotExist) {
 			return nil, stowry.ErrNotFound
 		}
-		return nil, fmt.Errorf("open file: %w", err)
+		return nil, pathError("open file", err)
 	}
 
 	return f, nil
@@ -63,51 +113,64 @@ func (r *ctxReader) Read(p []byte) (n int, err error) {
 // Write atomically writes content to the given path using a temp file and rename.
 // It creates intermediate directories as needed and returns a SaveResult containing
 // the number of bytes written and SHA256-based etag. The operation respects context cancellation.
+// Running out of disk space returns an error wrapping stowry.ErrInsufficientStorage,
+// and a path the filesystem rejects as too long or too deep one wrapping
+// stowry.ErrPathLimitExceeded.
 func (s *Store) Write(ctx context.Context, path string, content io.Reader) (stowry.SaveResult, error) {
 	if ctxErr := ctx.Err(); ctxErr != nil {
 		return stowry.SaveResult{}, ctxErr
 	}
 
 	tmpFile := tmpFileName()
-	t, createErr := s.root.Create(tmpFile)
+	t, createErr := s.createTemp(tmpFile)
 	if createErr != nil {
 		return stowry.SaveResult{}, fmt.Errorf("create temp file: %w", createErr)
 	}
 
 	success := false
+	closed := false
 	defer func() {
-		if closeErr := t.Close(); closeErr != nil {
-			slog.Warn("failed to close tmp file", "err", closeErr)
+		if !closed {
+			if closeErr := t.Close(); closeErr != nil {
+				slog.Warn("failed to close tmp file", "err", closeErr)
+			}
 		}
 		if !success {
-			if rmErr := s.root.Remove(t.Name()); rmErr != nil {
-				slog.Warn("failed to remove tmp file", "err", rmErr)
-			}
+			s.removeTemp(tmpFile)
 		}
 	}()
 
 	h := sha256.New()
-	w := io.MultiWriter(h, t)
+	var dst io.Writer = t
+	if s.wrapTemp != nil {
+		dst = s.wrapTemp(t)
+	}
+	w := io.MultiWriter(h, dst)
 
 	fileSizeBytes, err := io.Copy(w, &ctxReader{ctx: ctx, r: content})
 	if err != nil {
-		return stowry.SaveResult{}, fmt.Errorf("copy contents: %w", err)
+		return stowry.SaveResult{}, s.writeError("copy contents", err, fileSizeBytes)
 	}
 
 	err = t.Sync()
 	if err != nil {
-		return stowry.SaveResult{}, fmt.Errorf("sync file: %w", err)
+		return stowry.SaveResult{}, s.writeError("sync file", err, fileSizeBytes)
+	}
+
+	closed = true
+	if closeErr := t.Close(); closeErr != nil {
+		return stowry.SaveResult{}, s.writeError("close temp file", closeErr, fileSizeBytes)
 	}
 
 	destDir := filepath.Dir(path)
 	if destDir != "." {
 		if err := s.root.MkdirAll(destDir, 0o755); err != nil {
-			return stowry.SaveResult{}, fmt.Errorf("create directories: %w", err)
+			return stowry.SaveResult{}, pathError("create directories", err)
 		}
 	}
 
-	if renameErr := s.root.Rename(tmpFile, path); renameErr != nil {
-		return stowry.SaveResult{}, fmt.Errorf("rename file: %w", renameErr)
+	if err := s.finalize(tmpFile, path); err != nil {
+		return stowry.SaveResult{}, s.writeError("finalize file", err, fileSizeBytes)
 	}
 
 	etag := hex.EncodeToString(h.Sum(nil))
@@ -116,6 +179,117 @@ func (s *Store) Write(ctx context.Context, path string, content io.Reader) (stow
 	return stowry.SaveResult{BytesWritten: fileSizeBytes, Etag: etag}, nil
 }
 
+// createTemp creates the staging file in the temp directory or, by default,
+// inside the storage root.
+func (s *Store) createTemp(name string) (*os.File, error) {
+	if s.tempDir == "" {
+		return s.root.Create(name)
+	}
+	return os.OpenFile(filepath.Join(s.tempDir, name), os.O_RDWR|os.O_CREATE|os.O_EXCL, 0o600) //#nosec G304 -- name is generated
+}
+
+func (s *Store) removeTemp(name string) {
+	var err error
+	if s.tempDir == "" {
+		err = s.root.Remove(name)
+	} else {
+		err = os.Remove(filepath.Join(s.tempDir, name))
+	}
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		slog.Warn("failed to remove tmp file", "err", err)
+	}
+}
+
+// finalize moves the staged temp file to path. Temp files outside the root are
+// first moved into the root under their temp name so the final rename stays
+// inside the sandbox. Moves across filesystems fail with EXDEV and fall back to
+// copy, fsync, and rename.
+func (s *Store) finalize(tmpFile, path string) error {
+	if s.tempDir == "" {
+		return s.root.Rename(tmpFile, path)
+	}
+
+	src := filepath.Join(s.tempDir, tmpFile)
+	err := s.rename(src, filepath.Join(s.root.Name(), tmpFile))
+	if errors.Is(err, syscall.EXDEV) {
+		err = s.copyIntoRoot(src, tmpFile)
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := s.root.Rename(tmpFile, path); err != nil {
+		if rmErr := s.root.Remove(tmpFile); rmErr != nil {
+			slog.Warn("failed to remove tmp file", "err", rmErr)
+		}
+		return err
+	}
+
+	if err := os.Remove(src); err != nil && !errors.Is(err, os.ErrNotExist) {
+		slog.Warn("failed to remove staged file", "path", src, "err", err)
+	}
+	return nil
+}
+
+func (s *Store) copyIntoRoot(src, name string) (err error) {
+	in, err := os.Open(src) //#nosec G304 -- src is the generated temp file
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if closeErr := in.Close(); closeErr != nil {
+			slog.Warn("failed to close staged file", "err", closeErr)
+		}
+	}()
+
+	out, err := s.root.Create(name)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if closeErr := out.Close(); closeErr != nil && err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			if rmErr := s.root.Remove(name); rmErr != nil {
+				slog.Warn("failed to remove tmp file", "err", rmErr)
+			}
+		}
+	}()
+
+	if _, err = io.Copy(out, in); err != nil {
+		return err
+	}
+	return out.Sync()
+}
+
+// writeError wraps err, classifying a full disk as stowry.ErrInsufficientStorage.
+func (s *Store) writeError(op string, err error, written int64) error {
+	if !errors.Is(err, syscall.ENOSPC) {
+		return pathError(op, err)
+	}
+
+	dir := s.root.Name()
+	if s.tempDir != "" {
+		dir = s.tempDir
+	}
+	available, _ := availableBytes(dir)
+	slog.Warn("storage full during upload", "dir", dir, "written_bytes", written, "available_bytes", available)
+
+	return fmt.Errorf("%s: %w: %w", op, stowry.ErrInsufficientStorage, err)
+}
+
+// pathError wraps err, classifying a path the filesystem rejects as too long
+// (ENAMETOOLONG) or as too deep to resolve (ELOOP) as
+// stowry.ErrPathLimitExceeded. It catches paths the service's path limits let
+// through, such as when they are raised above what the filesystem supports.
+func pathError(op string, err error) error {
+	if errors.Is(err, syscall.ENAMETOOLONG) || errors.Is(err, syscall.ELOOP) {
+		return fmt.Errorf("%s: %w: %w", op, stowry.ErrPathLimitExceeded, err)
+	}
+	return fmt.Errorf("%s: %w", op, err)
+}
+
 // Delete removes a file. Returns stowry.ErrNotFound if the file does not exist.
 func (s *Store) Delete(ctx context.Context, path string) error {
 	if err := ctx.Err(); err != nil {
@@ -127,7 +301,7 @@ func (s *Store) Delete(ctx context.Context, path string) error {
 		if errors.Is(err, os.ErrNotExist) {
 			return stowry.ErrNotFound
 		}
-		return fmt.Errorf("delete file: %w", err)
+		return pathError("delete file", err)
 	}
 	return nil
 }
@@ -209,6 +383,213 @@ func (s *Store) walkDir(ctx context.Context, path string, entries *[]stowry.Obje
 	return nil
 }
 
+// Walk calls fn for every file under the root with its size and modification
+// time. Unlike List it does not read file content, so it is cheap on large trees.
+func (s *Store) Walk(ctx context.Context, fn func(stowry.StoredFile) error) error {
+	err := fs.WalkDir(s.root.FS(), ".", func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+
+		return fn(stowry.StoredFile{Path: path, Size: info.Size(), ModTime: info.ModTime()})
+	})
+	if err != nil {
+		return fmt.Errorf("walk files: %w", err)
+	}
+	return nil
+}
+
+// Stat returns the size and modification time of the file at path without
+// reading it. Returns stowry.ErrNotFound if there is no file at path.
+// It implements stowry.StorageStater.
+func (s *Store) Stat(ctx context.Context, path string) (stowry.StoredFile, error) {
+	if err := ctx.Err(); err != nil {
+		return stowry.StoredFile{}, err
+	}
+
+	info, err := s.root.Stat(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return stowry.StoredFile{}, stowry.ErrNotFound
+		}
+		return stowry.StoredFile{}, pathError("stat file", err)
+	}
+	if info.IsDir() {
+		return stowry.StoredFile{}, stowry.ErrNotFound
+	}
+	return stowry.StoredFile{Path: path, Size: info.Size(), ModTime: info.ModTime()}, nil
+}
+
+// Move renames from to to inside the root, creating parent directories of to.
+// Returns stowry.ErrNotFound if from does not exist.
+func (s *Store) Move(ctx context.Context, from, to string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	if _, err := s.root.Stat(from); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return stowry.ErrNotFound
+		}
+		return pathError("move file", err)
+	}
+
+	if destDir := filepath.Dir(to); destDir != "." {
+		if err := s.root.MkdirAll(destDir, 0o755); err != nil {
+			return pathError("move file: create directories", err)
+		}
+	}
+
+	if err := s.root.Rename(from, to); err != nil {
+		return pathError("move file", err)
+	}
+	return nil
+}
+
+// Copy copies the file at from to to as a hard link, replacing any file at
+// to, and hashes it for the result. Stored files are only ever replaced by
+// renames, never written in place, so the two paths stay independent.
+// Where the file system cannot link, the content is copied instead.
+// Returns stowry.ErrNotFound if from does not exist.
+// It implements stowry.StorageCopier.
+func (s *Store) Copy(ctx context.Context, from, to string) (stowry.SaveResult, error) {
+	if err := ctx.Err(); err != nil {
+		return stowry.SaveResult{}, err
+	}
+
+	info, err := s.root.Stat(from)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return stowry.SaveResult{}, stowry.ErrNotFound
+		}
+		return stowry.SaveResult{}, pathError("copy file", err)
+	}
+	if info.IsDir() {
+		return stowry.SaveResult{}, stowry.ErrNotFound
+	}
+
+	tmpFile := tmpFileName()
+	if err := s.root.Link(from, tmpFile); err != nil {
+		slog.Debug("hard link failed, copying content", "path", from, "err", err)
+		content, getErr := s.Get(ctx, from)
+		if getErr != nil {
+			return stowry.SaveResult{}, getErr
+		}
+		defer func() { _ = content.Close() }()
+		return s.Write(ctx, to, content)
+	}
+
+	// The link is in the root, even when Write stages in a temp directory
+	success := false
+	defer func() {
+		if !success {
+			if rmErr := s.root.Remove(tmpFile); rmErr != nil {
+				slog.Warn("failed to remove tmp file", "err", rmErr)
+			}
+		}
+	}()
+
+	// Hash the link, not to: another write may replace to once renamed
+	result, err := s.hashFile(ctx, tmpFile)
+	if err != nil {
+		return stowry.SaveResult{}, err
+	}
+
+	if destDir := filepath.Dir(to); destDir != "." {
+		if err := s.root.MkdirAll(destDir, 0o755); err != nil {
+			return stowry.SaveResult{}, pathError("copy file: create directories", err)
+		}
+	}
+	if err := s.root.Rename(tmpFile, to); err != nil {
+		return stowry.SaveResult{}, pathError("copy file", err)
+	}
+	success = true
+	return result, nil
+}
+
+// hashFile returns the size and SHA-256 ETag of the file at name.
+func (s *Store) hashFile(ctx context.Context, name string) (stowry.SaveResult, error) {
+	f, err := s.root.Open(name)
+	if err != nil {
+		return stowry.SaveResult{}, pathError("open file", err)
+	}
+	defer func() { _ = f.Close() }()
+
+	h := sha256.New()
+	n, err := io.Copy(h, &ctxReader{ctx: ctx, r: f})
+	if err != nil {
+		return stowry.SaveResult{}, fmt.Errorf("hash file: %w", err)
+	}
+	return stowry.SaveResult{BytesWritten: n, Etag: hex.EncodeToString(h.Sum(nil))}, nil
+}
+
+// ReadInstanceID returns the instance ID in stowry.InstanceFile, or
+// stowry.ErrNotFound if the file does not exist.
+// It implements stowry.InstanceMarker.
+func (s *Store) ReadInstanceID(ctx context.Context) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+
+	data, err := s.root.ReadFile(stowry.InstanceFile)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return "", stowry.ErrNotFound
+		}
+		return "", fmt.Errorf("read instance id: %w", err)
+	}
+
+	id := strings.TrimSpace(string(data))
+	if id == "" {
+		return "", fmt.Errorf("read instance id: %s is empty", stowry.InstanceFile)
+	}
+	return id, nil
+}
+
+// WriteInstanceID replaces stowry.InstanceFile with one holding id. The file
+// is written next to it and renamed into place, so it is never partial.
+// It implements stowry.InstanceMarker.
+func (s *Store) WriteInstanceID(ctx context.Context, id string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	tmpFile := tmpFileName()
+	f, err := s.root.OpenFile(tmpFile, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+	if err != nil {
+		return fmt.Errorf("write instance id: %w", err)
+	}
+
+	_, err = f.WriteString(id + "\n")
+	if err == nil {
+		err = f.Sync()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = s.root.Rename(tmpFile, stowry.InstanceFile)
+	}
+	if err != nil {
+		if rmErr := s.root.Remove(tmpFile); rmErr != nil && !errors.Is(rmErr, os.ErrNotExist) {
+			slog.Warn("failed to remove tmp file", "err", rmErr)
+		}
+		return fmt.Errorf("write instance id: %w", err)
+	}
+	return nil
+}
+
 func detectContentType(path string) string {
 	ext := filepath.Ext(path)
 	contentType := mime.TypeByExtension(ext)
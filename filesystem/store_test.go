@@ -5,13 +5,18 @@ import (
 	"context"
 	"fmt"
 	"io"
+	"math"
 	"os"
 	"path/filepath"
+	"runtime"
+	"strings"
+	"syscall"
 	"testing"
 
 	"github.com/sagarc03/stowry"
 	"github.com/sagarc03/stowry/filesystem"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestStore_Get_Success(t *testing.T) {
@@ -94,6 +99,24 @@ func TestStore_Write_Success(t *testing.T) {
 	assert.Equal(t, []byte("test content"), data)
 }
 
+func TestStore_Write_Empty(t *testing.T) {
+	tempDir := t.TempDir()
+	osDir, err := os.OpenRoot(tempDir)
+	assert.NoError(t, err)
+
+	store := filesystem.NewFileStorage(osDir)
+
+	result, err := store.Write(context.Background(), "markers/.keep", bytes.NewReader(nil))
+
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), result.BytesWritten)
+	assert.Equal(t, "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855", result.Etag) // SHA256 of empty input
+
+	info, err := os.Stat(filepath.Join(tempDir, "markers", ".keep"))
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), info.Size())
+}
+
 func TestStore_Write_WithSubdirectory(t *testing.T) {
 	tempDir := t.TempDir()
 	osDir, err := os.OpenRoot(tempDir)
@@ -450,3 +473,304 @@ func TestStore_ConcurrentWrites(t *testing.T) {
 	assert.NoError(t, err)
 	assert.Len(t, entries, 10)
 }
+
+// enospcWriter fails with ENOSPC once more than limit bytes have been written.
+type enospcWriter struct {
+	w     io.Writer
+	limit int
+}
+
+func (e *enospcWriter) Write(p []byte) (int, error) {
+	if len(p) > e.limit {
+		n, _ := e.w.Write(p[:e.limit])
+		e.limit = 0
+		return n, &os.PathError{Op: "write", Path: "tmp", Err: syscall.ENOSPC}
+	}
+	e.limit -= len(p)
+	return e.w.Write(p)
+}
+
+func TestStore_Write_DiskFull(t *testing.T) {
+	tempDir := t.TempDir()
+	osDir, err := os.OpenRoot(tempDir)
+	require.NoError(t, err)
+
+	store := filesystem.NewFileStorage(osDir)
+	filesystem.SetTempWriterWrapper(store, func(w io.Writer) io.Writer {
+		return &enospcWriter{w: w, limit: 10}
+	})
+
+	_, err = store.Write(context.Background(), "big.bin", bytes.NewReader(make([]byte, 1024)))
+	require.Error(t, err)
+	assert.ErrorIs(t, err, stowry.ErrInsufficientStorage)
+	assert.ErrorIs(t, err, syscall.ENOSPC)
+
+	entries, err := os.ReadDir(tempDir)
+	require.NoError(t, err)
+	assert.Empty(t, entries, "temp file should be removed")
+}
+
+func TestStore_Write_TempDir(t *testing.T) {
+	rootDir := t.TempDir()
+	scratchDir := t.TempDir()
+	osDir, err := os.OpenRoot(rootDir)
+	require.NoError(t, err)
+
+	store := filesystem.NewFileStorage(osDir, filesystem.WithTempDir(scratchDir))
+
+	result, err := store.Write(context.Background(), "a/b/file.txt", bytes.NewReader([]byte("staged")))
+	require.NoError(t, err)
+	assert.Equal(t, int64(6), result.BytesWritten)
+
+	data, err := os.ReadFile(filepath.Join(rootDir, "a", "b", "file.txt"))
+	require.NoError(t, err)
+	assert.Equal(t, "staged", string(data))
+
+	scratch, err := os.ReadDir(scratchDir)
+	require.NoError(t, err)
+	assert.Empty(t, scratch)
+
+	rootEntries, err := os.ReadDir(rootDir)
+	require.NoError(t, err)
+	require.Len(t, rootEntries, 1)
+	assert.Equal(t, "a", rootEntries[0].Name())
+}
+
+func TestStore_Write_TempDirCrossDevice(t *testing.T) {
+	rootDir := t.TempDir()
+	scratchDir := t.TempDir()
+	osDir, err := os.OpenRoot(rootDir)
+	require.NoError(t, err)
+
+	store := filesystem.NewFileStorage(osDir, filesystem.WithTempDir(scratchDir))
+	filesystem.SetRename(store, func(oldpath, newpath string) error {
+		return &os.LinkError{Op: "rename", Old: oldpath, New: newpath, Err: syscall.EXDEV}
+	})
+
+	result, err := store.Write(context.Background(), "file.txt", bytes.NewReader([]byte("copied across devices")))
+	require.NoError(t, err)
+	assert.Equal(t, int64(21), result.BytesWritten)
+
+	data, err := os.ReadFile(filepath.Join(rootDir, "file.txt"))
+	require.NoError(t, err)
+	assert.Equal(t, "copied across devices", string(data))
+
+	scratch, err := os.ReadDir(scratchDir)
+	require.NoError(t, err)
+	assert.Empty(t, scratch)
+
+	rootEntries, err := os.ReadDir(rootDir)
+	require.NoError(t, err)
+	assert.Len(t, rootEntries, 1)
+}
+
+func TestStore_CheckSpace(t *testing.T) {
+	tempDir := t.TempDir()
+	osDir, err := os.OpenRoot(tempDir)
+	require.NoError(t, err)
+
+	store := filesystem.NewFileStorage(osDir)
+
+	assert.NoError(t, store.CheckSpace(context.Background(), 1))
+
+	err = store.CheckSpace(context.Background(), math.MaxInt64)
+	if runtime.GOOS == "linux" || runtime.GOOS == "darwin" {
+		assert.ErrorIs(t, err, stowry.ErrInsufficientStorage)
+	} else {
+		assert.NoError(t, err)
+	}
+}
+
+func TestStore_Walk(t *testing.T) {
+	tempDir := t.TempDir()
+	osDir, err := os.OpenRoot(tempDir)
+	require.NoError(t, err)
+
+	require.NoError(t, os.MkdirAll(filepath.Join(tempDir, "a", "b"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(tempDir, "top.txt"), []byte("top"), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(tempDir, "a", "b", "deep.txt"), []byte("deeper"), 0o644))
+
+	store := filesystem.NewFileStorage(osDir)
+
+	got := map[string]int64{}
+	err = store.Walk(context.Background(), func(f stowry.StoredFile) error {
+		assert.False(t, f.ModTime.IsZero())
+		got[f.Path] = f.Size
+		return nil
+	})
+
+	require.NoError(t, err)
+	assert.Equal(t, map[string]int64{"top.txt": 3, "a/b/deep.txt": 6}, got)
+
+	stop := fmt.Errorf("stop")
+	err = store.Walk(context.Background(), func(stowry.StoredFile) error { return stop })
+	assert.ErrorIs(t, err, stop)
+}
+
+func TestStore_Move(t *testing.T) {
+	tempDir := t.TempDir()
+	osDir, err := os.OpenRoot(tempDir)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(filepath.Join(tempDir, "orphan.txt"), []byte("data"), 0o644))
+
+	store := filesystem.NewFileStorage(osDir)
+	ctx := context.Background()
+
+	require.NoError(t, store.Move(ctx, "orphan.txt", ".stowry-quarantine/orphan.txt"))
+
+	content, err := os.ReadFile(filepath.Join(tempDir, ".stowry-quarantine", "orphan.txt"))
+	require.NoError(t, err)
+	assert.Equal(t, "data", string(content))
+	assert.NoFileExists(t, filepath.Join(tempDir, "orphan.txt"))
+
+	err = store.Move(ctx, "orphan.txt", "elsewhere.txt")
+	assert.ErrorIs(t, err, stowry.ErrNotFound)
+}
+
+func TestStore_Copy(t *testing.T) {
+	tempDir := t.TempDir()
+	osDir, err := os.OpenRoot(tempDir)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(filepath.Join(tempDir, "a.txt"), []byte("data"), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(tempDir, "b.txt"), []byte("old"), 0o644))
+
+	store := filesystem.NewFileStorage(osDir)
+	ctx := context.Background()
+
+	for _, to := range []string{"dir/copy.txt", "b.txt"} {
+		result, err := store.Copy(ctx, "a.txt", to)
+		require.NoError(t, err)
+		assert.Equal(t, int64(4), result.BytesWritten)
+		assert.Equal(t, "3a6eb0790f39ac87c94f3856b2dd2c5d110e6811602261a9a923d3bb23adc8b7", result.Etag)
+
+		content, err := os.ReadFile(filepath.Join(tempDir, to))
+		require.NoError(t, err)
+		assert.Equal(t, "data", string(content))
+	}
+
+	// The copy is independent of a later write to the source
+	_, err = store.Write(ctx, "a.txt", strings.NewReader("changed"))
+	require.NoError(t, err)
+	content, err := os.ReadFile(filepath.Join(tempDir, "b.txt"))
+	require.NoError(t, err)
+	assert.Equal(t, "data", string(content))
+
+	_, err = store.Copy(ctx, "missing.txt", "c.txt")
+	assert.ErrorIs(t, err, stowry.ErrNotFound)
+	_, err = store.Copy(ctx, "dir", "c.txt")
+	assert.ErrorIs(t, err, stowry.ErrNotFound)
+
+	entries, err := store.List(ctx)
+	require.NoError(t, err)
+	assert.Len(t, entries, 3, "no temp files are left")
+}
+
+func TestStore_Stat(t *testing.T) {
+	tempDir := t.TempDir()
+	osDir, err := os.OpenRoot(tempDir)
+	require.NoError(t, err)
+	require.NoError(t, os.MkdirAll(filepath.Join(tempDir, "dir"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(tempDir, "dir", "file.txt"), []byte("data"), 0o644))
+
+	store := filesystem.NewFileStorage(osDir)
+	ctx := context.Background()
+
+	info, err := store.Stat(ctx, "dir/file.txt")
+	require.NoError(t, err)
+	assert.Equal(t, "dir/file.txt", info.Path)
+	assert.Equal(t, int64(4), info.Size)
+	assert.False(t, info.ModTime.IsZero())
+
+	_, err = store.Stat(ctx, "missing.txt")
+	assert.ErrorIs(t, err, stowry.ErrNotFound)
+
+	_, err = store.Stat(ctx, "dir")
+	assert.ErrorIs(t, err, stowry.ErrNotFound)
+}
+
+func TestStore_PathLimitErrors(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("segment length and symlink errors differ on Windows")
+	}
+	tempDir := t.TempDir()
+	osDir, err := os.OpenRoot(tempDir)
+	require.NoError(t, err)
+	require.NoError(t, os.Symlink("loop", filepath.Join(tempDir, "loop")))
+
+	store := filesystem.NewFileStorage(osDir)
+	ctx := context.Background()
+	longName := "dir/" + strings.Repeat("a", 300) + ".txt"
+
+	_, err = store.Write(ctx, longName, bytes.NewReader([]byte("data")))
+	assert.ErrorIs(t, err, stowry.ErrPathLimitExceeded)
+	assert.ErrorIs(t, err, syscall.ENAMETOOLONG)
+
+	_, err = store.Get(ctx, longName)
+	assert.ErrorIs(t, err, stowry.ErrPathLimitExceeded)
+
+	_, err = store.Stat(ctx, "loop/file.txt")
+	assert.ErrorIs(t, err, stowry.ErrPathLimitExceeded)
+
+	// The temp file of the failed write is removed
+	entries, err := os.ReadDir(tempDir)
+	require.NoError(t, err)
+	for _, entry := range entries {
+		assert.False(t, strings.HasPrefix(entry.Name(), ".t"), "leftover temp file %s", entry.Name())
+	}
+}
+
+func TestStore_InstanceID(t *testing.T) {
+	tempDir := t.TempDir()
+	osDir, err := os.OpenRoot(tempDir)
+	require.NoError(t, err)
+
+	store := filesystem.NewFileStorage(osDir)
+	ctx := context.Background()
+
+	_, err = store.ReadInstanceID(ctx)
+	assert.ErrorIs(t, err, stowry.ErrNotFound)
+
+	require.NoError(t, store.WriteInstanceID(ctx, "first"))
+	require.NoError(t, store.WriteInstanceID(ctx, "second"))
+
+	id, err := store.ReadInstanceID(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, "second", id)
+
+	entries, err := os.ReadDir(tempDir)
+	require.NoError(t, err)
+	require.Len(t, entries, 1, "no temp file is left behind")
+	assert.Equal(t, stowry.InstanceFile, entries[0].Name())
+
+	require.NoError(t, os.WriteFile(filepath.Join(tempDir, stowry.InstanceFile), []byte("\n"), 0o600))
+	_, err = store.ReadInstanceID(ctx)
+	assert.ErrorContains(t, err, "is empty")
+}
+
+func TestStore_ContextCanceled(t *testing.T) {
+	tempDir := t.TempDir()
+	osDir, err := os.OpenRoot(tempDir)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(filepath.Join(tempDir, "test.txt"), []byte("data"), 0o644))
+
+	store := filesystem.NewFileStorage(osDir)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	calls := map[string]func() error{
+		"CheckSpace": func() error { return store.CheckSpace(ctx, 1) },
+		"Walk": func() error {
+			return store.Walk(ctx, func(stowry.StoredFile) error { return nil })
+		},
+		"Stat":            func() error { _, err := store.Stat(ctx, "test.txt"); return err },
+		"Move":            func() error { return store.Move(ctx, "test.txt", "moved.txt") },
+		"ReadInstanceID":  func() error { _, err := store.ReadInstanceID(ctx); return err },
+		"WriteInstanceID": func() error { return store.WriteInstanceID(ctx, "id") },
+	}
+	for name, call := range calls {
+		assert.ErrorIs(t, call(), context.Canceled, name)
+	}
+
+	assert.FileExists(t, filepath.Join(tempDir, "test.txt"))
+}
@@ -0,0 +1,172 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/sagarc03/stowry"
+	"github.com/sagarc03/stowry/progress"
+)
+
+// maxJobRequestSize bounds the JSON options of an admin job request.
+const maxJobRequestSize = 64 << 10
+
+// MaintenanceService runs the maintenance operations behind the admin job
+// endpoints. *stowry.StowryService implements it.
+type MaintenanceService interface {
+	PopulateWithOptions(ctx context.Context, opts stowry.PopulateOptions) error
+	TombstoneWithReport(ctx context.Context, opts stowry.TombstoneOptions) (stowry.TombstoneReport, error)
+	Verify(ctx context.Context, opts stowry.VerifyOptions) (stowry.VerifyReport, error)
+}
+
+// CleanupJobRequest is the optional body of POST /admin/cleanup.
+type CleanupJobRequest struct {
+	Prefix     string `json:"prefix"`
+	Limit      int    `json:"limit"` // Items per page (default: 100)
+	DryRun     bool   `json:"dry_run"`
+	VerifyHash bool   `json:"verify_hash"`
+	Force      bool   `json:"force"`
+	// Strict stops the job at the first item that fails instead of
+	// skipping it.
+	Strict        bool `json:"strict"`
+	RetryDeferred bool `json:"retry_deferred"` // Also retry items deferred after an earlier failure
+}
+
+// VerifyJobRequest is the optional body of POST /admin/verify.
+type VerifyJobRequest struct {
+	Prefix     string `json:"prefix"`
+	VerifyHash bool   `json:"verify_hash"`
+}
+
+// JobConflictResponse is the error response for a job that cannot start
+// because one of its kind is running.
+type JobConflictResponse struct {
+	ErrorResponse
+	Job Job `json:"job"`
+}
+
+// handlePopulate serves POST /admin/populate.
+func (h *Handler) handlePopulate(w http.ResponseWriter, r *http.Request) {
+	ms := h.config.Maintenance
+	h.startJob(w, r, JobPopulate, func(ctx context.Context, onProgress progress.Func) (any, error) {
+		return nil, ms.PopulateWithOptions(ctx, stowry.PopulateOptions{Progress: onProgress})
+	})
+}
+
+// handleCleanup serves POST /admin/cleanup.
+func (h *Handler) handleCleanup(w http.ResponseWriter, r *http.Request) {
+	req := CleanupJobRequest{Limit: 100}
+	if !h.decodeJobRequest(w, r, &req) {
+		return
+	}
+	if req.Limit <= 0 {
+		h.writeError(w, r, http.StatusBadRequest, "invalid_request", "limit must be positive")
+		return
+	}
+
+	ms := h.config.Maintenance
+	h.startJob(w, r, JobCleanup, func(ctx context.Context, onProgress progress.Func) (any, error) {
+		return ms.TombstoneWithReport(ctx, stowry.TombstoneOptions{
+			Query:           stowry.ListQuery{PathPrefix: req.Prefix, Limit: req.Limit},
+			DryRun:          req.DryRun,
+			VerifyHash:      req.VerifyHash,
+			Force:           req.Force,
+			Progress:        onProgress,
+			ContinueOnError: !req.Strict,
+			RetryDeferred:   req.RetryDeferred,
+		})
+	})
+}
+
+// handleVerify serves POST /admin/verify.
+func (h *Handler) handleVerify(w http.ResponseWriter, r *http.Request) {
+	var req VerifyJobRequest
+	if !h.decodeJobRequest(w, r, &req) {
+		return
+	}
+
+	ms := h.config.Maintenance
+	h.startJob(w, r, JobVerify, func(ctx context.Context, onProgress progress.Func) (any, error) {
+		return ms.Verify(ctx, stowry.VerifyOptions{
+			Query:      stowry.ListQuery{PathPrefix: req.Prefix},
+			VerifyHash: req.VerifyHash,
+			Progress:   onProgress,
+		})
+	})
+}
+
+// decodeJobRequest reads the JSON options of a job request into v. An empty
+// body keeps the defaults in v.
+func (h *Handler) decodeJobRequest(w http.ResponseWriter, r *http.Request, v any) bool {
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxJobRequestSize))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil && !errors.Is(err, io.EOF) {
+		h.writeError(w, r, http.StatusBadRequest, "invalid_request", "Request body must be a JSON object of job options")
+		return false
+	}
+	return true
+}
+
+// startJob starts fn as a job of kind and answers 202 with its snapshot, or
+// 409 with the running job of that kind.
+func (h *Handler) startJob(w http.ResponseWriter, r *http.Request, kind JobKind, fn JobFunc) {
+	job, err := h.jobs.Start(kind, fn)
+	if errors.Is(err, ErrJobRunning) {
+		h.auditJob(r, "admin job rejected", job, "reason", "already running")
+		_ = WriteJSON(w, http.StatusConflict, JobConflictResponse{
+			ErrorResponse: ErrorResponse{Error: "job_running", Message: "A " + string(kind) + " job is already running"},
+			Job:           job,
+		})
+		return
+	}
+
+	h.auditJob(r, "admin job started", job)
+	w.Header().Set("Location", "/admin/jobs/"+job.ID)
+	w.Header().Set("Cache-Control", "no-store")
+	_ = WriteJSON(w, http.StatusAccepted, job)
+}
+
+// handleGetJob serves GET /admin/jobs/{id}.
+func (h *Handler) handleGetJob(w http.ResponseWriter, r *http.Request) {
+	job, err := h.jobs.Get(chi.URLParam(r, "id"))
+	if err != nil {
+		h.writeError(w, r, http.StatusNotFound, "job_not_found", "No job with this ID; jobs are kept in memory only")
+		return
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	_ = WriteJSON(w, http.StatusOK, job)
+}
+
+// handleCancelJob serves DELETE /admin/jobs/{id}. The job stops
+// asynchronously; its status turns to canceled once it has.
+func (h *Handler) handleCancelJob(w http.ResponseWriter, r *http.Request) {
+	job, err := h.jobs.Cancel(chi.URLParam(r, "id"))
+	if err != nil {
+		h.writeError(w, r, http.StatusNotFound, "job_not_found", "No job with this ID; jobs are kept in memory only")
+		return
+	}
+
+	h.auditJob(r, "admin job cancel requested", job)
+	w.Header().Set("Cache-Control", "no-store")
+	_ = WriteJSON(w, http.StatusAccepted, job)
+}
+
+// auditJob logs an admin action on job with who requested it.
+func (h *Handler) auditJob(r *http.Request, msg string, job Job, args ...any) {
+	principal, _ := PrincipalFromContext(r.Context())
+	args = append([]any{
+		"job", job.ID,
+		"kind", job.Kind,
+		"status", job.Status,
+		"principal", principal.Subject,
+		"auth", principal.Method,
+		"remote_addr", r.RemoteAddr,
+	}, args...)
+	h.logger.Info(msg, args...)
+}
@@ -0,0 +1,226 @@
+package http_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sagarc03/stowry"
+	stowryhttp "github.com/sagarc03/stowry/http"
+	"github.com/sagarc03/stowry/progress"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeMaintenance records the options it was run with. Each operation
+// reports one item and then blocks until release is closed or its context is
+// done.
+type fakeMaintenance struct {
+	release chan struct{}
+
+	mu        sync.Mutex
+	tombstone stowry.TombstoneOptions
+	verify    stowry.VerifyOptions
+}
+
+func newFakeMaintenance() *fakeMaintenance {
+	return &fakeMaintenance{release: make(chan struct{})}
+}
+
+func (f *fakeMaintenance) wait(ctx context.Context, onProgress progress.Func) error {
+	onProgress(progress.Event{Processed: 1, Bytes: 10})
+	select {
+	case <-f.release:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (f *fakeMaintenance) PopulateWithOptions(ctx context.Context, opts stowry.PopulateOptions) error {
+	return f.wait(ctx, opts.Progress)
+}
+
+func (f *fakeMaintenance) TombstoneWithReport(ctx context.Context, opts stowry.TombstoneOptions) (stowry.TombstoneReport, error) {
+	f.mu.Lock()
+	f.tombstone = opts
+	f.mu.Unlock()
+	return stowry.TombstoneReport{Cleaned: 1}, f.wait(ctx, opts.Progress)
+}
+
+func (f *fakeMaintenance) Verify(ctx context.Context, opts stowry.VerifyOptions) (stowry.VerifyReport, error) {
+	f.mu.Lock()
+	f.verify = opts
+	f.mu.Unlock()
+	return stowry.VerifyReport{Checked: 1, Missing: []string{"a.txt"}}, f.wait(ctx, opts.Progress)
+}
+
+func TestHandler_AdminJobs(t *testing.T) {
+	setup := func(t *testing.T) (*stowryhttp.Handler, *stowryhttp.JobManager, *fakeMaintenance) {
+		t.Helper()
+		ms := newFakeMaintenance()
+		jobs := stowryhttp.NewJobManager(t.Context())
+		h, err := stowryhttp.New(&stowryhttp.HandlerConfig{
+			Mode:          stowry.ModeStore,
+			WriteVerifier: headerVerifier{},
+			Maintenance:   ms,
+			Jobs:          jobs,
+		}, new(MockService))
+		require.NoError(t, err)
+		return h, jobs, ms
+	}
+	do := func(t *testing.T, h *stowryhttp.Handler, method, target, body string) *httptest.ResponseRecorder {
+		t.Helper()
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		req.Header.Set("X-Test-Auth", "ok")
+		rec := httptest.NewRecorder()
+		h.Router().ServeHTTP(rec, req)
+		return rec
+	}
+	decodeJob := func(t *testing.T, rec *httptest.ResponseRecorder) stowryhttp.Job {
+		t.Helper()
+		var job stowryhttp.Job
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &job))
+		return job
+	}
+
+	t.Run("start, poll and finish", func(t *testing.T) {
+		h, jobs, ms := setup(t)
+
+		rec := do(t, h, http.MethodPost, "/admin/verify", `{"prefix":"docs/","verify_hash":true}`)
+		require.Equal(t, http.StatusAccepted, rec.Code)
+		started := decodeJob(t, rec)
+		assert.Equal(t, stowryhttp.JobVerify, started.Kind)
+		assert.Equal(t, stowryhttp.JobRunning, started.Status)
+		assert.Equal(t, "/admin/jobs/"+started.ID, rec.Header().Get("Location"))
+
+		assert.Eventually(t, func() bool {
+			rec := do(t, h, http.MethodGet, "/admin/jobs/"+started.ID, "")
+			return rec.Code == http.StatusOK && decodeJob(t, rec).Progress.Processed == 1
+		}, time.Second, 10*time.Millisecond)
+
+		close(ms.release)
+		waitJob(t, jobs, started.ID)
+
+		rec = do(t, h, http.MethodGet, "/admin/jobs/"+started.ID, "")
+		require.Equal(t, http.StatusOK, rec.Code)
+		assert.JSONEq(t, `{"checked":1,"bytes":0,"missing":["a.txt"]}`, string(mustField(t, rec, "report")))
+		assert.Equal(t, stowryhttp.JobSucceeded, decodeJob(t, rec).Status)
+		assert.Equal(t, "docs/", ms.verify.Query.PathPrefix)
+		assert.True(t, ms.verify.VerifyHash)
+	})
+
+	t.Run("cleanup options", func(t *testing.T) {
+		h, jobs, ms := setup(t)
+		close(ms.release)
+
+		rec := do(t, h, http.MethodPost, "/admin/cleanup", `{"prefix":"tmp/","dry_run":true,"force":true}`)
+		require.Equal(t, http.StatusAccepted, rec.Code)
+		waitJob(t, jobs, decodeJob(t, rec).ID)
+
+		assert.Equal(t, stowry.ListQuery{PathPrefix: "tmp/", Limit: 100}, ms.tombstone.Query)
+		assert.True(t, ms.tombstone.DryRun)
+		assert.True(t, ms.tombstone.Force)
+		assert.False(t, ms.tombstone.VerifyHash)
+		assert.True(t, ms.tombstone.ContinueOnError, "the job skips failing items by default")
+		assert.False(t, ms.tombstone.RetryDeferred)
+	})
+
+	t.Run("strict cleanup", func(t *testing.T) {
+		h, jobs, ms := setup(t)
+		close(ms.release)
+
+		rec := do(t, h, http.MethodPost, "/admin/cleanup", `{"strict":true,"retry_deferred":true}`)
+		require.Equal(t, http.StatusAccepted, rec.Code)
+		waitJob(t, jobs, decodeJob(t, rec).ID)
+
+		assert.False(t, ms.tombstone.ContinueOnError)
+		assert.True(t, ms.tombstone.RetryDeferred)
+	})
+
+	t.Run("empty body uses defaults", func(t *testing.T) {
+		h, jobs, ms := setup(t)
+		close(ms.release)
+
+		rec := do(t, h, http.MethodPost, "/admin/populate", "")
+		require.Equal(t, http.StatusAccepted, rec.Code)
+		assert.Equal(t, stowryhttp.JobSucceeded, waitJob(t, jobs, decodeJob(t, rec).ID).Status)
+	})
+
+	t.Run("second job of a kind conflicts", func(t *testing.T) {
+		h, _, ms := setup(t)
+		defer close(ms.release)
+
+		first := decodeJob(t, do(t, h, http.MethodPost, "/admin/populate", ""))
+
+		rec := do(t, h, http.MethodPost, "/admin/populate", "")
+		assert.Equal(t, http.StatusConflict, rec.Code)
+		var resp stowryhttp.JobConflictResponse
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+		assert.Equal(t, "job_running", resp.Error)
+		assert.Equal(t, first.ID, resp.Job.ID)
+	})
+
+	t.Run("cancel", func(t *testing.T) {
+		h, jobs, _ := setup(t)
+
+		started := decodeJob(t, do(t, h, http.MethodPost, "/admin/verify", ""))
+
+		rec := do(t, h, http.MethodDelete, "/admin/jobs/"+started.ID, "")
+		require.Equal(t, http.StatusAccepted, rec.Code)
+		assert.True(t, decodeJob(t, rec).Canceling)
+
+		assert.Equal(t, stowryhttp.JobCanceled, waitJob(t, jobs, started.ID).Status)
+	})
+
+	t.Run("unknown job", func(t *testing.T) {
+		h, _, _ := setup(t)
+
+		for _, method := range []string{http.MethodGet, http.MethodDelete} {
+			rec := do(t, h, method, "/admin/jobs/missing", "")
+			assert.Equal(t, http.StatusNotFound, rec.Code, method)
+			assert.Contains(t, rec.Body.String(), "job_not_found")
+		}
+	})
+
+	t.Run("invalid options", func(t *testing.T) {
+		h, _, _ := setup(t)
+
+		for _, body := range []string{`{"prefx":"a/"}`, `not json`, `{"limit":-1}`} {
+			rec := do(t, h, http.MethodPost, "/admin/cleanup", body)
+			assert.Equal(t, http.StatusBadRequest, rec.Code, body)
+		}
+	})
+
+	t.Run("requires write authentication", func(t *testing.T) {
+		h, _, _ := setup(t)
+
+		rec := httptest.NewRecorder()
+		h.Router().ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/admin/verify", nil))
+
+		assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	})
+
+	t.Run("disabled without a maintenance service", func(t *testing.T) {
+		h, err := stowryhttp.New(&stowryhttp.HandlerConfig{Mode: stowry.ModeStore}, new(MockService))
+		require.NoError(t, err)
+
+		rec := httptest.NewRecorder()
+		h.Router().ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/admin/verify", nil))
+
+		assert.Equal(t, http.StatusMethodNotAllowed, rec.Code)
+	})
+}
+
+// mustField returns the raw JSON of field in the response body.
+func mustField(t *testing.T, rec *httptest.ResponseRecorder, field string) json.RawMessage {
+	t.Helper()
+	var body map[string]json.RawMessage
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+	return body[field]
+}
@@ -0,0 +1,173 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strings"
+
+	stowrysign "github.com/sagarc03/stowry-go"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/sagarc03/stowry"
+)
+
+// Permission is an operation a Principal may perform.
+type Permission string
+
+const (
+	PermissionRead  Permission = "read"  // GET, HEAD and list
+	PermissionWrite Permission = "write" // PUT, PATCH, DELETE, staging, deleted objects and admin endpoints
+)
+
+// Principal is who a request was authenticated as.
+type Principal struct {
+	// Subject identifies the caller: the access key of a signed request, or
+	// the subject of a bearer token.
+	Subject string `json:"subject"`
+	// Method names how the request was authenticated, such as "signature"
+	// or "jwt".
+	Method string `json:"method"`
+	// Permissions the principal holds. nil holds every permission, as an
+	// access key does.
+	Permissions []Permission `json:"permissions,omitempty"`
+}
+
+// Can reports whether the principal holds perm.
+func (p Principal) Can(perm Permission) bool {
+	return p.Permissions == nil || slices.Contains(p.Permissions, perm)
+}
+
+// Authenticator identifies the caller of a request. It returns
+// ErrNoCredentials when the request carries no credentials it handles, so
+// the next authenticator in the chain is tried, and any other error when it
+// does but they are invalid.
+type Authenticator interface {
+	Authenticate(r *http.Request) (Principal, error)
+}
+
+// SignatureAuthenticator authenticates signed requests, such as presigned
+// URLs, with Verifier. The principal is the access key the request was
+// signed with and holds every permission. It is the last authenticator of
+// every chain the handler builds.
+type SignatureAuthenticator struct {
+	Verifier RequestVerifier
+	// AccessKeys, when not nil, is told the access key of each request
+	// the verifier accepts.
+	AccessKeys AccessKeyTracker
+}
+
+// Authenticate verifies r with the verifier. The verifier sees the path as
+// the client sent it, before duplicate slashes were collapsed.
+func (a SignatureAuthenticator) Authenticate(r *http.Request) (Principal, error) {
+	if err := a.Verifier.Verify(requestAsSent(r)); err != nil {
+		return Principal{}, err
+	}
+	accessKey := requestAccessKey(r)
+	if a.AccessKeys != nil && accessKey != "" {
+		a.AccessKeys.Record(accessKey)
+	}
+	return Principal{Subject: accessKey, Method: "signature"}, nil
+}
+
+// Validate validates the verifier when it has a Validate() error method.
+func (a SignatureAuthenticator) Validate() error {
+	if validator, ok := a.Verifier.(interface{ Validate() error }); ok {
+		return validator.Validate()
+	}
+	return nil
+}
+
+// requestAccessKey returns the access key a presigned request was signed
+// with, or "" for an unsigned request.
+func requestAccessKey(r *http.Request) string {
+	query := r.URL.Query()
+	if key := query.Get(stowrysign.StowryCredentialParam); key != "" {
+		return key
+	}
+	key, _, _ := strings.Cut(query.Get(stowry.AWSCredentialParam), "/")
+	return key
+}
+
+type principalKey struct{}
+
+// PrincipalFromContext returns the principal the request of ctx was
+// authenticated as. It reports false for public routes.
+func PrincipalFromContext(ctx context.Context) (Principal, bool) {
+	p, ok := ctx.Value(principalKey{}).(Principal)
+	return p, ok
+}
+
+// authenticateMiddleware authenticates requests with the first authenticator
+// of chain that accepts them and requires the principal to hold perm. An
+// empty chain leaves requests unauthenticated.
+//
+// The principal is added to the request context, for PrincipalFromContext,
+// and to the request's trace span as enduser.id. Its subject is the actor
+// recorded with the changes the request makes.
+//
+// A rejected request is reported to failed, when not nil, with
+// ErrNonCanonicalPath, ErrForbidden, ErrNoCredentials or the errors of the
+// authenticators that refused its credentials.
+func authenticateMiddleware(chain []Authenticator, perm Permission, logger *slog.Logger, handleError func(http.ResponseWriter, *http.Request, error), failed func(error)) func(http.Handler) http.Handler {
+	if len(chain) == 0 {
+		return func(next http.Handler) http.Handler {
+			return next
+		}
+	}
+
+	if failed == nil {
+		failed = func(error) {}
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var errs []error
+			for _, a := range chain {
+				p, err := a.Authenticate(r)
+				if errors.Is(err, ErrNoCredentials) {
+					continue
+				}
+				if err != nil {
+					errs = append(errs, err)
+					continue
+				}
+
+				// The signature covers the path as sent, which is not the key
+				// the request would be served from
+				if p.Method == "signature" && pathRewritten(r) {
+					logger.Warn("signed path is not canonical", "principal", p.Subject,
+						"method", r.Method, "path", requestAsSent(r).URL.Path)
+					failed(ErrNonCanonicalPath)
+					handleError(w, r, ErrNonCanonicalPath)
+					return
+				}
+
+				if !p.Can(perm) {
+					logger.Warn("permission denied", "principal", p.Subject, "auth", p.Method, "permission", perm,
+						"method", r.Method, "path", r.URL.Path)
+					failed(ErrForbidden)
+					handleError(w, r, ErrForbidden)
+					return
+				}
+
+				ctx := context.WithValue(r.Context(), principalKey{}, p)
+				ctx = stowry.WithActor(ctx, p.Subject)
+				trace.SpanFromContext(ctx).SetAttributes(attribute.String("enduser.id", p.Subject))
+				next.ServeHTTP(w, r.WithContext(ctx))
+				return
+			}
+
+			err := errors.Join(errs...)
+			if err == nil {
+				err = ErrNoCredentials
+			}
+			logger.Warn("authentication failed", "error", err, "method", r.Method, "path", r.URL.Path)
+			failed(err)
+			handleError(w, r, ErrUnauthorized)
+		})
+	}
+}
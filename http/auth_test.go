@@ -0,0 +1,198 @@
+package http_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/sagarc03/stowry"
+	stowrysign "github.com/sagarc03/stowry-go"
+	stowryhttp "github.com/sagarc03/stowry/http"
+	"github.com/sagarc03/stowry/keybackend"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// tokenAuthenticator accepts requests whose X-Test-Token names one of its
+// principals and ignores requests without the header.
+type tokenAuthenticator map[string]stowryhttp.Principal
+
+func (a tokenAuthenticator) Authenticate(r *http.Request) (stowryhttp.Principal, error) {
+	token := r.Header.Get("X-Test-Token")
+	if token == "" {
+		return stowryhttp.Principal{}, stowryhttp.ErrNoCredentials
+	}
+	p, ok := a[token]
+	if !ok {
+		return stowryhttp.Principal{}, errors.New("unknown token")
+	}
+	return p, nil
+}
+
+func TestHandler_Authenticators(t *testing.T) {
+	alice := stowryhttp.Principal{Subject: "alice", Method: "sso", Permissions: []stowryhttp.Permission{stowryhttp.PermissionRead}}
+	bob := stowryhttp.Principal{Subject: "bob", Method: "sso", Permissions: []stowryhttp.Permission{stowryhttp.PermissionRead, stowryhttp.PermissionWrite}}
+	carol := stowryhttp.Principal{Subject: "carol", Method: "backup"}
+
+	service := new(MockService)
+	service.On("Info", mock.Anything, "a.txt").Return(stowry.MetaData{Path: "a.txt", Etag: "e"}, nil)
+	service.On("Delete", mock.Anything, "a.txt").Return(nil)
+
+	var got []stowryhttp.Principal
+	capture := func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			p, _ := stowryhttp.PrincipalFromContext(r.Context())
+			got = append(got, p)
+			next.ServeHTTP(w, r)
+		})
+	}
+
+	h, err := stowryhttp.New(&stowryhttp.HandlerConfig{
+		Mode:          stowry.ModeStore,
+		ReadVerifier:  headerVerifier{},
+		WriteVerifier: headerVerifier{},
+		Authenticators: []stowryhttp.Authenticator{
+			tokenAuthenticator{"a": alice, "b": bob},
+			tokenAuthenticator{"a": carol, "c": carol},
+		},
+	}, service, stowryhttp.WithMiddleware(stowryhttp.AfterAuth, capture))
+	require.NoError(t, err)
+	router := h.Router()
+
+	tests := []struct {
+		name     string
+		method   string
+		token    string
+		signed   bool
+		wantCode int
+		want     *stowryhttp.Principal
+	}{
+		{name: "first authenticator wins", method: http.MethodHead, token: "a", wantCode: http.StatusOK, want: &alice},
+		{name: "later authenticator", method: http.MethodHead, token: "c", wantCode: http.StatusOK, want: &carol},
+		{name: "read-only principal cannot write", method: http.MethodDelete, token: "a", wantCode: http.StatusForbidden},
+		{name: "write principal", method: http.MethodDelete, token: "b", wantCode: http.StatusNoContent, want: &bob},
+		{name: "unknown token", method: http.MethodHead, token: "x", wantCode: http.StatusUnauthorized},
+		{name: "no credentials", method: http.MethodHead, wantCode: http.StatusUnauthorized},
+		{name: "signature fallback", method: http.MethodDelete, signed: true, wantCode: http.StatusNoContent,
+			want: &stowryhttp.Principal{Method: "signature"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got = nil
+			req := httptest.NewRequest(tt.method, "/a.txt", nil)
+			if tt.token != "" {
+				req.Header.Set("X-Test-Token", tt.token)
+			}
+			if tt.signed {
+				req.Header.Set("X-Test-Auth", "ok")
+			}
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, req)
+
+			assert.Equal(t, tt.wantCode, rec.Code)
+			if tt.want == nil {
+				assert.Empty(t, got, "handler must not run")
+				return
+			}
+			assert.Equal(t, []stowryhttp.Principal{*tt.want}, got)
+		})
+	}
+
+	t.Run("forbidden response", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodDelete, "/a.txt", nil)
+		req.Header.Set("X-Test-Token", "a")
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+
+		assert.JSONEq(t, `{"error":"forbidden","message":"forbidden"}`, rec.Body.String())
+	})
+
+	t.Run("the subject is the actor of changes", func(t *testing.T) {
+		service := new(MockService)
+		byBob := mock.MatchedBy(func(ctx context.Context) bool { return stowry.ActorFromContext(ctx) == "bob" })
+		service.On("Delete", byBob, "a.txt").Return(nil)
+
+		h, err := stowryhttp.New(&stowryhttp.HandlerConfig{
+			Mode:           stowry.ModeStore,
+			WriteVerifier:  headerVerifier{},
+			Authenticators: []stowryhttp.Authenticator{tokenAuthenticator{"b": bob}},
+		}, service)
+		require.NoError(t, err)
+
+		req := httptest.NewRequest(http.MethodDelete, "/a.txt", nil)
+		req.Header.Set("X-Test-Token", "b")
+		rec := httptest.NewRecorder()
+		h.Router().ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusNoContent, rec.Code)
+		service.AssertExpectations(t)
+	})
+}
+
+func TestHandler_Authenticators_PublicRoutes(t *testing.T) {
+	service := new(MockService)
+	service.On("Info", mock.Anything, "a.txt").Return(stowry.MetaData{Path: "a.txt", Etag: "e"}, nil)
+
+	h, err := stowryhttp.New(&stowryhttp.HandlerConfig{
+		Mode:           stowry.ModeStore,
+		WriteVerifier:  headerVerifier{},
+		Authenticators: []stowryhttp.Authenticator{tokenAuthenticator{}},
+	}, service)
+	require.NoError(t, err)
+
+	rec := httptest.NewRecorder()
+	h.Router().ServeHTTP(rec, httptest.NewRequest(http.MethodHead, "/a.txt", nil))
+	assert.Equal(t, http.StatusOK, rec.Code, "authenticators must not make public reads private")
+
+	t.Run("nil authenticator is rejected", func(t *testing.T) {
+		_, err := stowryhttp.New(&stowryhttp.HandlerConfig{
+			Mode:           stowry.ModeStore,
+			Authenticators: []stowryhttp.Authenticator{nil},
+		}, service)
+		assert.ErrorContains(t, err, "authenticator 0 is nil")
+	})
+}
+
+func TestHandler_Authenticators_PresignedURL(t *testing.T) {
+	const accessKey, secretKey = "AKIATEST", "testsecret"
+	store := keybackend.NewMapSecretStore(map[string]string{accessKey: secretKey})
+	verifier := stowry.NewSignatureVerifier(stowry.AuthConfig{AWS: stowry.AWSConfig{Region: "us-east-1", Service: "s3"}}, store)
+
+	service := new(MockService)
+	service.On("Delete", mock.Anything, "a.txt").Return(nil)
+
+	var got stowryhttp.Principal
+	capture := func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			got, _ = stowryhttp.PrincipalFromContext(r.Context())
+			next.ServeHTTP(w, r)
+		})
+	}
+
+	h, err := stowryhttp.New(&stowryhttp.HandlerConfig{
+		Mode:           stowry.ModeStore,
+		WriteVerifier:  verifier,
+		Authenticators: []stowryhttp.Authenticator{tokenAuthenticator{}},
+	}, service, stowryhttp.WithMiddleware(stowryhttp.AfterAuth, capture))
+	require.NoError(t, err)
+
+	client := stowrysign.NewClient("http://example.com", accessKey, secretKey)
+	rec := httptest.NewRecorder()
+	h.Router().ServeHTTP(rec, httptest.NewRequest(http.MethodDelete, client.PresignDelete("a.txt", 900), nil))
+
+	assert.Equal(t, http.StatusNoContent, rec.Code)
+	assert.Equal(t, stowryhttp.Principal{Subject: accessKey, Method: "signature"}, got)
+}
+
+func TestPrincipal_Can(t *testing.T) {
+	assert.True(t, stowryhttp.Principal{}.Can(stowryhttp.PermissionWrite), "nil permissions hold every permission")
+
+	reader := stowryhttp.Principal{Permissions: []stowryhttp.Permission{stowryhttp.PermissionRead}}
+	assert.True(t, reader.Can(stowryhttp.PermissionRead))
+	assert.False(t, reader.Can(stowryhttp.PermissionWrite))
+
+	assert.False(t, stowryhttp.Principal{Permissions: []stowryhttp.Permission{}}.Can(stowryhttp.PermissionRead))
+}
@@ -0,0 +1,63 @@
+package http
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+// Backuper writes a consistent snapshot of the metadata database to path,
+// which does not exist yet.
+type Backuper interface {
+	Backup(ctx context.Context, path string) error
+}
+
+// handleBackup serves POST /admin/backup: it snapshots the metadata
+// database into a temporary file and streams it as an attachment.
+func (h *Handler) handleBackup(w http.ResponseWriter, r *http.Request) {
+	dir, err := os.MkdirTemp("", "stowry-backup-*")
+	if err != nil {
+		h.handleError(w, r, fmt.Errorf("backup: %w", err))
+		return
+	}
+	defer func() { _ = os.RemoveAll(dir) }()
+
+	path := filepath.Join(dir, "backup.db")
+	if err := h.config.Backup.Backup(r.Context(), path); err != nil {
+		h.handleError(w, r, err)
+		return
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		h.handleError(w, r, fmt.Errorf("backup: %w", err))
+		return
+	}
+	defer func() { _ = f.Close() }()
+
+	info, err := f.Stat()
+	if err != nil {
+		h.handleError(w, r, fmt.Errorf("backup: %w", err))
+		return
+	}
+
+	// A large database can take longer to send than the server's write
+	// timeout allows
+	_ = http.NewResponseController(w).SetWriteDeadline(time.Time{})
+
+	filename := "stowry-" + time.Now().UTC().Format("20060102T150405Z") + ".db"
+	w.Header().Set("Content-Type", "application/vnd.sqlite3")
+	w.Header().Set("Content-Disposition", `attachment; filename="`+filename+`"`)
+	w.Header().Set("Content-Length", strconv.FormatInt(info.Size(), 10))
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusOK)
+
+	if _, err := io.Copy(w, f); err != nil {
+		h.logger.Warn("send backup failed", "error", err)
+	}
+}
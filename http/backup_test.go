@@ -0,0 +1,82 @@
+package http_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/sagarc03/stowry"
+	stowryhttp "github.com/sagarc03/stowry/http"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeBackuper writes content as the snapshot, or fails with err.
+type fakeBackuper struct {
+	content string
+	err     error
+	path    string
+}
+
+func (f *fakeBackuper) Backup(_ context.Context, path string) error {
+	f.path = path
+	if f.err != nil {
+		return f.err
+	}
+	return os.WriteFile(path, []byte(f.content), 0o600)
+}
+
+func TestHandler_Backup(t *testing.T) {
+	serve := func(t *testing.T, config *stowryhttp.HandlerConfig, req *http.Request) *httptest.ResponseRecorder {
+		t.Helper()
+		h, err := stowryhttp.New(config, new(MockService))
+		require.NoError(t, err)
+		rec := httptest.NewRecorder()
+		h.Router().ServeHTTP(rec, req)
+		return rec
+	}
+
+	t.Run("streams the snapshot", func(t *testing.T) {
+		backuper := &fakeBackuper{content: "SQLite format 3"}
+		config := &stowryhttp.HandlerConfig{Mode: stowry.ModeStore, WriteVerifier: headerVerifier{}, Backup: backuper}
+		req := httptest.NewRequest(http.MethodPost, "/admin/backup", nil)
+		req.Header.Set("X-Test-Auth", "ok")
+
+		rec := serve(t, config, req)
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "SQLite format 3", rec.Body.String())
+		assert.Equal(t, "application/vnd.sqlite3", rec.Header().Get("Content-Type"))
+		assert.Equal(t, "15", rec.Header().Get("Content-Length"))
+		assert.Regexp(t, `^attachment; filename="stowry-\d{8}T\d{6}Z\.db"$`, rec.Header().Get("Content-Disposition"))
+		_, err := os.Stat(backuper.path)
+		assert.ErrorIs(t, err, os.ErrNotExist, "the temporary snapshot is removed")
+	})
+
+	t.Run("requires write authentication", func(t *testing.T) {
+		backuper := &fakeBackuper{content: "SQLite format 3"}
+		config := &stowryhttp.HandlerConfig{Mode: stowry.ModeStore, WriteVerifier: headerVerifier{}, Backup: backuper}
+
+		rec := serve(t, config, httptest.NewRequest(http.MethodPost, "/admin/backup", nil))
+
+		assert.Equal(t, http.StatusUnauthorized, rec.Code)
+		assert.Empty(t, backuper.path)
+	})
+
+	t.Run("backup failure", func(t *testing.T) {
+		config := &stowryhttp.HandlerConfig{Mode: stowry.ModeStore, Backup: &fakeBackuper{err: errors.New("disk full")}}
+
+		rec := serve(t, config, httptest.NewRequest(http.MethodPost, "/admin/backup", nil))
+
+		assert.Equal(t, http.StatusInternalServerError, rec.Code)
+		assert.NotContains(t, rec.Body.String(), "disk full")
+	})
+
+	t.Run("disabled without a backuper", func(t *testing.T) {
+		rec := serve(t, &stowryhttp.HandlerConfig{Mode: stowry.ModeStore}, httptest.NewRequest(http.MethodPost, "/admin/backup", nil))
+		assert.Equal(t, http.StatusMethodNotAllowed, rec.Code)
+	})
+}
@@ -0,0 +1,159 @@
+package http
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// DefaultImmutablePattern matches a content hash of 8 or more hex characters
+// between dots in a filename, as in app.3f2a9c1d.js.
+const DefaultImmutablePattern = `\.[0-9a-fA-F]{8,}\.`
+
+const (
+	cacheControlImmutable = "public, max-age=31536000, immutable"
+	cacheControlNoCache   = "no-cache"
+)
+
+// CachePolicy decides the Cache-Control header for files served in static and
+// SPA modes. Filenames matching the immutable pattern are cached for a year;
+// everything else, including index.html, must be revalidated.
+type CachePolicy struct {
+	immutable *regexp.Regexp
+}
+
+// NewCachePolicy compiles pattern, which is matched against the base name of
+// the served file. An empty pattern uses DefaultImmutablePattern.
+func NewCachePolicy(pattern string) (*CachePolicy, error) {
+	if pattern == "" {
+		pattern = DefaultImmutablePattern
+	}
+
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("immutable asset pattern: %w", err)
+	}
+
+	return &CachePolicy{immutable: re}, nil
+}
+
+// CacheControl returns the Cache-Control value for the object at objectPath.
+// An object's own Cache-Control and the configured CacheRules take precedence
+// over this heuristic.
+func (p *CachePolicy) CacheControl(objectPath string) string {
+	if p.immutable.MatchString(path.Base(objectPath)) {
+		return cacheControlImmutable
+	}
+	return cacheControlNoCache
+}
+
+// CacheRule sets the Cache-Control of objects whose path matches Pattern.
+//
+// A pattern without a slash, such as "*.html", matches the file name at any
+// depth; one with a slash, such as "assets/**" or "/index.html", matches the
+// whole path. "*", "?" and "[...]" match within a path segment, as
+// path.Match, and a "**" segment matches any number of segments.
+type CacheRule struct {
+	Pattern      string `mapstructure:"pattern"`
+	CacheControl string `mapstructure:"cache_control"`
+}
+
+// CacheRules is a compiled, ordered list of cache rules. The first matching
+// rule wins.
+type CacheRules struct {
+	rules []compiledCacheRule
+}
+
+type compiledCacheRule struct {
+	cacheControl string
+	segments     []string // Pattern split on "/"; nil matches the base name
+	name         string   // Pattern of a rule without a slash
+}
+
+// NewCacheRules validates and compiles rules, keeping their order.
+func NewCacheRules(rules []CacheRule) (*CacheRules, error) {
+	compiled := make([]compiledCacheRule, 0, len(rules))
+	for i, rule := range rules {
+		c, err := compileCacheRule(rule)
+		if err != nil {
+			return nil, fmt.Errorf("cache rule %d (%s): %w", i+1, rule.Pattern, err)
+		}
+		compiled = append(compiled, c)
+	}
+	return &CacheRules{rules: compiled}, nil
+}
+
+func compileCacheRule(rule CacheRule) (compiledCacheRule, error) {
+	value := strings.TrimSpace(rule.CacheControl)
+	if value == "" {
+		return compiledCacheRule{}, errors.New("cache_control is required")
+	}
+	if strings.ContainsFunc(value, unicode.IsControl) {
+		return compiledCacheRule{}, errors.New("cache_control contains control characters")
+	}
+
+	pattern := strings.TrimPrefix(rule.Pattern, "/")
+	if pattern == "" || strings.HasSuffix(pattern, "/") {
+		return compiledCacheRule{}, errors.New("pattern must name files")
+	}
+	c := compiledCacheRule{cacheControl: value}
+	if !strings.Contains(rule.Pattern, "/") {
+		c.name = pattern
+	} else {
+		c.segments = strings.Split(pattern, "/")
+	}
+	for _, segment := range append([]string{c.name}, c.segments...) {
+		if _, err := path.Match(segment, ""); err != nil {
+			return compiledCacheRule{}, fmt.Errorf("pattern: %w", err)
+		}
+	}
+	return c, nil
+}
+
+// CacheControl returns the Cache-Control value of the first rule matching
+// objectPath, and false when none does. A nil *CacheRules matches nothing.
+func (c *CacheRules) CacheControl(objectPath string) (string, bool) {
+	if c == nil {
+		return "", false
+	}
+	for _, rule := range c.rules {
+		if rule.matches(objectPath) {
+			return rule.cacheControl, true
+		}
+	}
+	return "", false
+}
+
+func (r compiledCacheRule) matches(objectPath string) bool {
+	if r.segments == nil {
+		ok, _ := path.Match(r.name, path.Base(objectPath))
+		return ok
+	}
+	return matchSegments(r.segments, strings.Split(objectPath, "/"))
+}
+
+// matchSegments matches path segments against pattern segments, where a
+// "**" segment matches any number of path segments.
+func matchSegments(pattern, segments []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			for i := 0; i <= len(segments); i++ {
+				if matchSegments(pattern[1:], segments[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(segments) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(pattern[0], segments[0]); !ok {
+			return false
+		}
+		pattern, segments = pattern[1:], segments[1:]
+	}
+	return len(segments) == 0
+}
@@ -0,0 +1,104 @@
+package http_test
+
+import (
+	"testing"
+
+	stowryhttp "github.com/sagarc03/stowry/http"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCachePolicy_CacheControl(t *testing.T) {
+	const immutable = "public, max-age=31536000, immutable"
+	const noCache = "no-cache"
+
+	policy, err := stowryhttp.NewCachePolicy("")
+	require.NoError(t, err)
+
+	tests := []struct {
+		path string
+		want string
+	}{
+		{path: "assets/app.3f2a9c1d.js", want: immutable},
+		{path: "app.3F2A9C1D0B.css", want: immutable},
+		{path: "chunks/vendor.0123456789abcdef.chunk.js", want: immutable},
+		{path: "index.html", want: noCache},
+		{path: "about/index.html", want: noCache},
+		{path: "app.3f2a9c.js", want: noCache},     // too short to be a hash
+		{path: "app.deadbeefzz.js", want: noCache}, // not hex
+		{path: "3f2a9c1d.js", want: noCache},       // no leading dot
+		{path: "a1b2c3d4e5.f6/logo.png", want: noCache},
+		{path: "logo.png", want: noCache},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			assert.Equal(t, tt.want, policy.CacheControl(tt.path))
+		})
+	}
+}
+
+func TestCachePolicy_CustomPattern(t *testing.T) {
+	policy, err := stowryhttp.NewCachePolicy(`-[0-9a-f]{6}\.`)
+	require.NoError(t, err)
+
+	assert.Equal(t, "public, max-age=31536000, immutable", policy.CacheControl("app-3f2a9c.js"))
+	assert.Equal(t, "no-cache", policy.CacheControl("app.3f2a9c1d.js"))
+}
+
+func TestNewCachePolicy_InvalidPattern(t *testing.T) {
+	_, err := stowryhttp.NewCachePolicy(`[`)
+	assert.Error(t, err)
+}
+
+func TestCacheRules_CacheControl(t *testing.T) {
+	rules, err := stowryhttp.NewCacheRules([]stowryhttp.CacheRule{
+		{Pattern: "index.html", CacheControl: "no-cache"},
+		{Pattern: "/assets/**", CacheControl: "public, max-age=31536000, immutable"},
+		{Pattern: "docs/*/*.pdf", CacheControl: "max-age=3600"},
+		{Pattern: "*.html", CacheControl: "max-age=60"},
+	})
+	require.NoError(t, err)
+
+	tests := []struct {
+		path string
+		want string
+	}{
+		{path: "index.html", want: "no-cache"},
+		{path: "blog/index.html", want: "no-cache"}, // no slash matches at any depth
+		{path: "assets/app.js", want: "public, max-age=31536000, immutable"},
+		{path: "assets/js/vendor/app.js", want: "public, max-age=31536000, immutable"},
+		{path: "assets/index.html", want: "no-cache"}, // first match wins
+		{path: "docs/2024/report.pdf", want: "max-age=3600"},
+		{path: "docs/report.pdf", want: ""},
+		{path: "about.html", want: "max-age=60"},
+		{path: "static/assets/app.js", want: ""},
+		{path: "logo.png", want: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			got, ok := rules.CacheControl(tt.path)
+			assert.Equal(t, tt.want != "", ok)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+
+	var none *stowryhttp.CacheRules
+	_, ok := none.CacheControl("index.html")
+	assert.False(t, ok)
+}
+
+func TestNewCacheRules_Invalid(t *testing.T) {
+	for name, rule := range map[string]stowryhttp.CacheRule{
+		"empty pattern":       {Pattern: "", CacheControl: "no-cache"},
+		"directory pattern":   {Pattern: "assets/", CacheControl: "no-cache"},
+		"malformed pattern":   {Pattern: "[", CacheControl: "no-cache"},
+		"malformed segment":   {Pattern: "assets/[", CacheControl: "no-cache"},
+		"empty cache control": {Pattern: "*.html", CacheControl: " "},
+		"control character":   {Pattern: "*.html", CacheControl: "no-cache\r\nX-Evil: 1"},
+	} {
+		_, err := stowryhttp.NewCacheRules([]stowryhttp.CacheRule{rule})
+		assert.Error(t, err, name)
+	}
+}
@@ -0,0 +1,209 @@
+package http
+
+import (
+	"net/http"
+	"slices"
+
+	"github.com/sagarc03/stowry"
+)
+
+// CapabilitiesPath serves the capability document in every mode. In store
+// mode GET /?capabilities serves the same document.
+const CapabilitiesPath = "/.well-known/stowry"
+
+// MaxListLimit is the largest page GET / returns; larger limits are lowered
+// to it.
+const MaxListLimit = 1000
+
+// PathLimitService is an optional Service extension reporting the limits
+// object paths are checked against. When the service implements it, the
+// capability document includes them so clients can check paths before
+// uploading.
+type PathLimitService interface {
+	PathLimits() stowry.PathLimits
+}
+
+// pathLimits returns the service's path limits, or the defaults when it does
+// not report them.
+func (h *Handler) pathLimits() stowry.PathLimits {
+	if pls, ok := h.service.(PathLimitService); ok {
+		return pls.PathLimits()
+	}
+	return stowry.DefaultPathLimits()
+}
+
+// Capabilities is the capability document served at CapabilitiesPath: what
+// the server accepts, for clients to pick the requests they send instead of
+// trying and falling back on errors.
+type Capabilities struct {
+	// Version is the server version; empty when HandlerConfig.Version is not set
+	Version string `json:"version,omitempty"`
+	// Mode is the server mode: "store", "static" or "spa"
+	Mode stowry.ServerMode `json:"mode"`
+	// Limits bound request sizes
+	Limits Limits `json:"limits"`
+	// PathLimits bound object paths; nil when the service does not report them
+	PathLimits *stowry.PathLimits `json:"path_limits,omitempty"`
+	// Features lists the optional endpoints and headers that are enabled:
+	// "range" in every mode, and in store mode "watch", "deleted",
+	// "history", "delta", "stage", "stat", "bulk_delete", "delete_prefix",
+	// "purge", "restore", "object_id", "permalink", "presign", "backup" and
+	// "jobs". Features added with WithFeatures follow.
+	Features []string `json:"features"`
+	// ListFormats are the formats GET / lists objects in; empty outside
+	// store mode or with listing disabled
+	ListFormats []string `json:"list_formats"`
+	// ConditionalHeaders maps each method to the conditional request
+	// headers it evaluates
+	ConditionalHeaders map[string][]string `json:"conditional_headers"`
+	// Compression lists the content-codings responses are compressed with,
+	// in server preference order; empty when compression is off
+	Compression []string `json:"compression"`
+	// IfMatchComparison is how a PUT's If-Match is compared with the
+	// stored ETag: "strong" or "weak"
+	IfMatchComparison string `json:"if_match_comparison"`
+	// ListDisabled is set when GET / listing and the change feed answer 403
+	// list_disabled
+	ListDisabled bool `json:"list_disabled,omitempty"`
+	// Region is the server's region; empty when HandlerConfig.Region is not set
+	Region string `json:"region,omitempty"`
+}
+
+// Limits are the sizes the server checks requests against. A zero limit
+// means there is none.
+type Limits struct {
+	MaxUploadSize        int64 `json:"max_upload_size"`         // Bytes per object, a PUT body or the total of multipart parts
+	MaxListLimit         int   `json:"max_list_limit"`          // Objects per GET / page
+	MaxExportRows        int   `json:"max_export_rows"`         // Rows per CSV list export
+	MaxStatPaths         int   `json:"max_stat_paths"`          // Paths per POST /?stat
+	MaxDeletePaths       int   `json:"max_delete_paths"`        // Paths per POST /?delete
+	MaxMultipartParts    int   `json:"max_multipart_parts"`     // Parts per multipart upload
+	MaxFilenameBytes     int   `json:"max_filename_bytes"`      // Bytes of an original filename
+	MaxUserMetadataBytes int   `json:"max_user_metadata_bytes"` // Bytes of user metadata keys and values
+	MaxContentTypeLength int   `json:"max_content_type_length"` // Bytes of a Content-Type
+}
+
+// isCapabilitiesRequest reports whether a GET / asks for the server's
+// capabilities.
+func isCapabilitiesRequest(r *http.Request) bool {
+	return r.URL.Query().Has("capabilities")
+}
+
+// handleCapabilities serves the capability document.
+func (h *Handler) handleCapabilities(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Cache-Control", "no-store")
+	_ = WriteJSON(w, http.StatusOK, h.capabilities())
+}
+
+// capabilities builds the capability document from the configuration, the
+// optional extensions the service implements and the features added with
+// WithFeatures.
+func (h *Handler) capabilities() Capabilities {
+	store := h.config.Mode == stowry.ModeStore
+	caps := Capabilities{
+		Version: h.config.Version,
+		Region:  h.config.Region,
+		Mode:    h.config.Mode,
+		Limits: Limits{
+			MaxListLimit:         MaxListLimit,
+			MaxFilenameBytes:     stowry.MaxFilenameBytes,
+			MaxUserMetadataBytes: stowry.MaxUserMetadataBytes,
+			MaxContentTypeLength: stowry.MaxContentTypeLength,
+		},
+		Features:    []string{"range"},
+		ListFormats: []string{},
+		ConditionalHeaders: map[string][]string{
+			http.MethodGet:  {"If-None-Match", "If-Modified-Since", "If-Range"},
+			http.MethodHead: {"If-None-Match", "If-Modified-Since"},
+		},
+		Compression:       []string{},
+		IfMatchComparison: h.config.IfMatchComparison,
+		ListDisabled:      store && h.config.DisableList,
+	}
+	if caps.IfMatchComparison == "" {
+		caps.IfMatchComparison = ETagStrong
+	}
+	if h.config.Compression.Enabled {
+		caps.Compression = h.config.Compression.Encodings
+		if len(caps.Compression) == 0 {
+			caps.Compression = SupportedEncodings()
+		}
+	}
+
+	if pls, ok := h.service.(PathLimitService); ok {
+		limits := pls.PathLimits()
+		caps.PathLimits = &limits
+	}
+
+	if store {
+		caps.Limits.MaxUploadSize = h.config.MaxUploadSize
+		caps.Limits.MaxExportRows = h.config.ListExportMaxRows
+		caps.ConditionalHeaders[http.MethodPut] = []string{"If-Match"}
+		if !caps.ListDisabled {
+			caps.ListFormats = []string{"json", "csv"}
+		}
+
+		if _, ok := h.changeService(); ok && !caps.ListDisabled {
+			caps.Features = append(caps.Features, "watch")
+		}
+		if _, ok := h.service.(DeletedService); ok {
+			caps.Features = append(caps.Features, "deleted")
+		}
+		if _, ok := h.historyService(); ok {
+			caps.Features = append(caps.Features, "history")
+		}
+		if _, ok := h.deltaService(); ok {
+			caps.Features = append(caps.Features, "delta")
+		}
+		if _, ok := h.stageService(); ok {
+			caps.Features = append(caps.Features, "stage")
+		}
+		if _, ok := h.multipartService(); ok {
+			caps.Features = append(caps.Features, "multipart")
+			caps.Limits.MaxMultipartParts = stowry.MaxMultipartParts
+		}
+		if _, ok := h.statService(); ok {
+			caps.Features = append(caps.Features, "stat")
+			caps.Limits.MaxStatPaths = stowry.MaxStatPaths
+		}
+		if _, ok := h.deleteManyService(); ok {
+			caps.Features = append(caps.Features, "bulk_delete")
+			caps.Limits.MaxDeletePaths = h.maxDeletePaths()
+		}
+		if _, ok := h.deletePrefixService(); ok {
+			caps.Features = append(caps.Features, "delete_prefix")
+		}
+		if _, ok := h.service.(CopyService); ok {
+			caps.Features = append(caps.Features, "copy")
+		}
+		if _, ok := h.moveService(); ok {
+			caps.Features = append(caps.Features, "move")
+		}
+		if _, ok := h.purgeService(); ok {
+			caps.Features = append(caps.Features, "purge")
+		}
+		if _, ok := h.restoreService(); ok {
+			caps.Features = append(caps.Features, "restore")
+		}
+		caps.Features = append(caps.Features, "object_id")
+		if _, ok := h.permalinkService(); ok {
+			caps.Features = append(caps.Features, "permalink")
+		}
+		if h.config.Presigner != nil {
+			caps.Features = append(caps.Features, "presign")
+		}
+		if h.config.Backup != nil {
+			caps.Features = append(caps.Features, "backup")
+		}
+		if h.jobs != nil {
+			caps.Features = append(caps.Features, "jobs")
+		}
+	}
+
+	for _, f := range h.features {
+		if !slices.Contains(caps.Features, f) {
+			caps.Features = append(caps.Features, f)
+		}
+	}
+	return caps
+}
@@ -0,0 +1,160 @@
+package http_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/sagarc03/stowry"
+	stowryhttp "github.com/sagarc03/stowry/http"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// MockPathLimitService is a MockService that also implements
+// http.PathLimitService.
+type MockPathLimitService struct {
+	MockService
+	limits stowry.PathLimits
+}
+
+func (m *MockPathLimitService) PathLimits() stowry.PathLimits {
+	return m.limits
+}
+
+func TestHandler_Capabilities(t *testing.T) {
+	serve := func(t *testing.T, config *stowryhttp.HandlerConfig, service stowryhttp.Service, target string, opts ...stowryhttp.Option) *httptest.ResponseRecorder {
+		t.Helper()
+		h, err := stowryhttp.New(config, service, opts...)
+		require.NoError(t, err)
+		rec := httptest.NewRecorder()
+		h.Router().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, target, nil))
+		return rec
+	}
+	decode := func(t *testing.T, rec *httptest.ResponseRecorder) stowryhttp.Capabilities {
+		t.Helper()
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		var caps stowryhttp.Capabilities
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &caps))
+		return caps
+	}
+
+	t.Run("store mode document", func(t *testing.T) {
+		service := &MockPathLimitService{limits: stowry.DefaultPathLimits()}
+
+		rec := serve(t, &stowryhttp.HandlerConfig{Mode: stowry.ModeStore, Version: "1.4.0"}, service, stowryhttp.CapabilitiesPath)
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "no-store", rec.Header().Get("Cache-Control"))
+		assert.JSONEq(t, `{
+			"version": "1.4.0",
+			"mode": "store",
+			"limits": {
+				"max_upload_size": 0,
+				"max_list_limit": 1000,
+				"max_export_rows": 0,
+				"max_stat_paths": 0,
+				"max_delete_paths": 0,
+				"max_multipart_parts": 0,
+				"max_filename_bytes": 255,
+				"max_user_metadata_bytes": 2048,
+				"max_content_type_length": 256
+			},
+			"path_limits": {"max_segment_bytes": 255, "max_key_bytes": 1024, "max_depth": 64},
+			"features": ["range", "object_id"],
+			"list_formats": ["json", "csv"],
+			"conditional_headers": {
+				"GET": ["If-None-Match", "If-Modified-Since", "If-Range"],
+				"HEAD": ["If-None-Match", "If-Modified-Since"],
+				"PUT": ["If-Match"]
+			},
+			"compression": [],
+			"if_match_comparison": "strong"
+		}`, rec.Body.String())
+		service.AssertNotCalled(t, "List", mock.Anything, mock.Anything)
+	})
+
+	t.Run("query form serves the same document", func(t *testing.T) {
+		config := &stowryhttp.HandlerConfig{Mode: stowry.ModeStore, Version: "1.4.0"}
+		wellKnown := serve(t, config, new(MockService), stowryhttp.CapabilitiesPath)
+		query := serve(t, config, new(MockService), "/?capabilities")
+
+		assert.Equal(t, http.StatusOK, query.Code)
+		assert.JSONEq(t, wellKnown.Body.String(), query.Body.String())
+	})
+
+	t.Run("reflects the configuration", func(t *testing.T) {
+		config := &stowryhttp.HandlerConfig{
+			Mode:              stowry.ModeStore,
+			MaxUploadSize:     10 << 20,
+			ListExportMaxRows: 5000,
+			IfMatchComparison: stowryhttp.ETagWeak,
+			Compression:       stowryhttp.CompressionConfig{Enabled: true, Encodings: []string{"gzip"}},
+			Presigner:         &stowryhttp.Presigner{},
+		}
+
+		caps := decode(t, serve(t, config, new(MockService), stowryhttp.CapabilitiesPath))
+
+		assert.Equal(t, int64(10<<20), caps.Limits.MaxUploadSize)
+		assert.Equal(t, 5000, caps.Limits.MaxExportRows)
+		assert.Equal(t, stowryhttp.ETagWeak, caps.IfMatchComparison)
+		assert.Equal(t, []string{"gzip"}, caps.Compression)
+		assert.Contains(t, caps.Features, "presign")
+		assert.Empty(t, caps.Version)
+		assert.Nil(t, caps.PathLimits)
+	})
+
+	t.Run("lists enabled features", func(t *testing.T) {
+		caps := decode(t, serve(t, &stowryhttp.HandlerConfig{Mode: stowry.ModeStore}, new(MockChangeService), stowryhttp.CapabilitiesPath))
+		assert.Equal(t, []string{"range", "watch", "object_id"}, caps.Features)
+
+		caps = decode(t, serve(t, &stowryhttp.HandlerConfig{Mode: stowry.ModeStore}, new(MockStageService), stowryhttp.CapabilitiesPath))
+		assert.Contains(t, caps.Features, "stage")
+		assert.NotContains(t, caps.Features, "watch")
+	})
+
+	t.Run("features added by options", func(t *testing.T) {
+		caps := decode(t, serve(t, &stowryhttp.HandlerConfig{Mode: stowry.ModeStore}, new(MockService), stowryhttp.CapabilitiesPath,
+			stowryhttp.WithFeatures("jwt", "range")))
+
+		assert.Equal(t, []string{"range", "object_id", "jwt"}, caps.Features)
+	})
+
+	t.Run("reports disabled listing", func(t *testing.T) {
+		caps := decode(t, serve(t, &stowryhttp.HandlerConfig{Mode: stowry.ModeStore, DisableList: true}, new(MockChangeService), stowryhttp.CapabilitiesPath))
+
+		assert.True(t, caps.ListDisabled)
+		assert.Empty(t, caps.ListFormats)
+		assert.NotContains(t, caps.Features, "watch", "the change feed is disabled with listing")
+	})
+
+	t.Run("static mode document", func(t *testing.T) {
+		config := &stowryhttp.HandlerConfig{Mode: stowry.ModeStatic, MaxUploadSize: 1024, DisableList: true}
+		caps := decode(t, serve(t, config, new(MockChangeService), stowryhttp.CapabilitiesPath))
+
+		assert.Equal(t, stowry.ModeStatic, caps.Mode)
+		assert.Equal(t, []string{"range"}, caps.Features)
+		assert.Empty(t, caps.ListFormats)
+		assert.False(t, caps.ListDisabled)
+		assert.Zero(t, caps.Limits.MaxUploadSize, "no uploads to limit")
+		assert.NotContains(t, caps.ConditionalHeaders, http.MethodPut)
+	})
+
+	t.Run("static mode has no query form", func(t *testing.T) {
+		service := &MockPathLimitService{limits: stowry.DefaultPathLimits()}
+		service.On("Get", mock.Anything, "").Return(stowry.MetaData{}, nil, stowry.ErrNotFound)
+
+		rec := serve(t, &stowryhttp.HandlerConfig{Mode: stowry.ModeStatic}, service, "/?capabilities")
+
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+	})
+
+	t.Run("private reads", func(t *testing.T) {
+		config := &stowryhttp.HandlerConfig{Mode: stowry.ModeStore, ReadVerifier: headerVerifier{}}
+
+		rec := serve(t, config, new(MockService), stowryhttp.CapabilitiesPath)
+		assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	})
+}
@@ -0,0 +1,415 @@
+package http
+
+import (
+	"bufio"
+	"compress/gzip"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// CompressionConfig configures on-the-fly response compression.
+type CompressionConfig struct {
+	Enabled bool `mapstructure:"enabled"`
+	// Encodings lists the content-codings to offer in server preference order.
+	// Empty means every registered encoding, ordered by registration priority.
+	Encodings []string `mapstructure:"encodings"`
+	// Levels overrides the quality level per encoding (e.g. gzip: 6, br: 5).
+	// Missing entries use the encoding's default level.
+	Levels map[string]int `mapstructure:"levels"`
+	// MinSize skips compression for responses whose Content-Length is below
+	// this many bytes. 0 compresses everything eligible.
+	MinSize int64 `mapstructure:"min_size"`
+	// Types lists the media types to compress: "type/subtype", "type/*" for
+	// any subtype, or "+suffix" for a structured syntax suffix such as +json.
+	// Empty means DefaultCompressibleTypes.
+	Types []string `mapstructure:"types"`
+}
+
+// Validate checks that every configured encoding is registered and every level
+// is accepted by its encoder.
+func (c CompressionConfig) Validate() error {
+	if !c.Enabled {
+		return nil
+	}
+
+	for _, name := range c.Encodings {
+		if _, ok := lookupEncoder(name); !ok {
+			return fmt.Errorf("compression: unsupported encoding %q (available: %s)", name, strings.Join(SupportedEncodings(), ", "))
+		}
+	}
+
+	for _, t := range c.Types {
+		if !validCompressibleType(t) {
+			return fmt.Errorf("compression: invalid media type %q (want type/subtype, type/* or +suffix)", t)
+		}
+	}
+
+	for name, level := range c.Levels {
+		enc, ok := lookupEncoder(name)
+		if !ok {
+			return fmt.Errorf("compression: level set for unsupported encoding %q", name)
+		}
+		if _, err := enc.factory(io.Discard, level); err != nil {
+			return fmt.Errorf("compression: invalid level %d for %s: %w", level, name, err)
+		}
+	}
+
+	return nil
+}
+
+// EncoderFactory creates a compressing writer around w at the given quality level.
+// A level of 0 selects the encoding's default level.
+type EncoderFactory func(w io.Writer, level int) (io.WriteCloser, error)
+
+type registeredEncoder struct {
+	name     string
+	priority int
+	factory  EncoderFactory
+}
+
+var (
+	encodersMu sync.RWMutex
+	encoders   = map[string]registeredEncoder{}
+)
+
+// RegisterEncoder makes a content-coding available to the compression middleware.
+// Higher priority encodings are preferred when the client accepts several with
+// equal q-values. Registering an existing name replaces it.
+//
+// gzip is always registered. Brotli ("br") and zstd ("zstd") are compiled in
+// only with the brotli and zstd build tags so the default binary stays small.
+func RegisterEncoder(name string, priority int, factory EncoderFactory) {
+	encodersMu.Lock()
+	defer encodersMu.Unlock()
+	encoders[strings.ToLower(name)] = registeredEncoder{name: strings.ToLower(name), priority: priority, factory: factory}
+}
+
+// SupportedEncodings returns the registered content-codings in priority order.
+func SupportedEncodings() []string {
+	encodersMu.RLock()
+	defer encodersMu.RUnlock()
+
+	list := make([]registeredEncoder, 0, len(encoders))
+	for _, e := range encoders {
+		list = append(list, e)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].priority != list[j].priority {
+			return list[i].priority > list[j].priority
+		}
+		return list[i].name < list[j].name
+	})
+
+	names := make([]string, len(list))
+	for i, e := range list {
+		names[i] = e.name
+	}
+	return names
+}
+
+func lookupEncoder(name string) (registeredEncoder, bool) {
+	encodersMu.RLock()
+	defer encodersMu.RUnlock()
+	e, ok := encoders[strings.ToLower(name)]
+	return e, ok
+}
+
+func init() {
+	RegisterEncoder("gzip", 10, func(w io.Writer, level int) (io.WriteCloser, error) {
+		if level == 0 {
+			level = gzip.DefaultCompression
+		}
+		return gzip.NewWriterLevel(w, level)
+	})
+	// deflate is the zlib format, per RFC 9110 §8.4.1.2
+	RegisterEncoder("deflate", 5, func(w io.Writer, level int) (io.WriteCloser, error) {
+		if level == 0 {
+			level = zlib.DefaultCompression
+		}
+		return zlib.NewWriterLevel(w, level)
+	})
+}
+
+// acceptedEncoding is a single entry of an Accept-Encoding header.
+type acceptedEncoding struct {
+	coding string
+	q      float64
+}
+
+// parseAcceptEncoding parses an Accept-Encoding header into its codings and
+// q-values per RFC 9110 §12.5.3. Malformed q-values are treated as q=0.
+func parseAcceptEncoding(header string) []acceptedEncoding {
+	var result []acceptedEncoding
+	for _, part := range strings.Split(header, ",") {
+		fields := strings.Split(part, ";")
+		coding := strings.ToLower(strings.TrimSpace(fields[0]))
+		if coding == "" {
+			continue
+		}
+
+		q := 1.0
+		for _, param := range fields[1:] {
+			key, value, ok := strings.Cut(strings.TrimSpace(param), "=")
+			if !ok || strings.ToLower(strings.TrimSpace(key)) != "q" {
+				continue
+			}
+			parsed, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+			if err != nil || parsed < 0 || parsed > 1 {
+				parsed = 0
+			}
+			q = parsed
+		}
+
+		result = append(result, acceptedEncoding{coding: coding, q: q})
+	}
+	return result
+}
+
+// negotiateEncoding picks the client-preferred encoding from offered (listed in
+// server preference order). It returns "" when nothing acceptable is offered,
+// meaning the response should be sent with the identity coding.
+func negotiateEncoding(header string, offered []string) string {
+	if header == "" || len(offered) == 0 {
+		return ""
+	}
+
+	accepted := parseAcceptEncoding(header)
+	explicit := make(map[string]float64, len(accepted))
+	wildcard := -1.0
+	for _, a := range accepted {
+		if a.coding == "*" {
+			wildcard = a.q
+			continue
+		}
+		explicit[a.coding] = a.q
+	}
+
+	best := ""
+	bestQ := 0.0
+	for _, name := range offered {
+		q, ok := explicit[name]
+		if !ok {
+			if wildcard < 0 {
+				continue
+			}
+			q = wildcard
+		}
+		// Strictly greater keeps server preference order on ties
+		if q > bestQ {
+			best = name
+			bestQ = q
+		}
+	}
+	return best
+}
+
+// DefaultCompressibleTypes are the media types compressed when
+// CompressionConfig.Types is empty.
+var DefaultCompressibleTypes = []string{
+	"text/*",
+	"application/json",
+	"application/javascript",
+	"application/xml",
+	"application/wasm",
+	"image/svg+xml",
+	"+json",
+	"+xml",
+}
+
+// validCompressibleType reports whether t is a CompressionConfig.Types entry.
+func validCompressibleType(t string) bool {
+	if suffix, ok := strings.CutPrefix(t, "+"); ok {
+		return suffix != "" && !strings.ContainsAny(suffix, "/+*")
+	}
+	typ, subtype, ok := strings.Cut(t, "/")
+	return ok && typ != "" && typ != "*" && subtype != "" && !strings.Contains(subtype, "/") &&
+		(subtype == "*" || !strings.Contains(subtype, "*"))
+}
+
+// isCompressible reports whether contentType matches one of types.
+func isCompressible(contentType string, types []string) bool {
+	mediaType, _, _ := strings.Cut(contentType, ";")
+	mediaType = strings.ToLower(strings.TrimSpace(mediaType))
+	if mediaType == "" {
+		return false
+	}
+	for _, t := range types {
+		t = strings.ToLower(t)
+		switch {
+		case strings.HasPrefix(t, "+"):
+			if strings.HasSuffix(mediaType, t) {
+				return true
+			}
+		case strings.HasSuffix(t, "/*"):
+			if strings.HasPrefix(mediaType, strings.TrimSuffix(t, "*")) {
+				return true
+			}
+		case mediaType == t:
+			return true
+		}
+	}
+	return false
+}
+
+// CompressionMiddleware returns middleware that compresses eligible responses
+// using the best encoding accepted by the client. Only complete 200 responses
+// with a Content-Type listed in cfg.Types are compressed; partial content, HEAD
+// requests, and responses that already carry a Content-Encoding pass through.
+// Strong ETags are downgraded to weak ones on compressed responses because the
+// bytes on the wire no longer match the stored representation.
+func CompressionMiddleware(cfg CompressionConfig) func(http.Handler) http.Handler {
+	if !cfg.Enabled {
+		return func(next http.Handler) http.Handler { return next }
+	}
+
+	offered := cfg.Encodings
+	if len(offered) == 0 {
+		offered = SupportedEncodings()
+	}
+	offered = slices.DeleteFunc(slices.Clone(offered), func(name string) bool {
+		if _, ok := lookupEncoder(name); !ok {
+			slog.Warn("compression encoding not available in this build", "encoding", name)
+			return true
+		}
+		return false
+	})
+	types := cfg.Types
+	if len(types) == 0 {
+		types = DefaultCompressibleTypes
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method == http.MethodHead || r.Header.Get("Range") != "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			encoding := negotiateEncoding(r.Header.Get("Accept-Encoding"), offered)
+			cw := &compressWriter{
+				ResponseWriter: w,
+				encoding:       encoding,
+				level:          cfg.Levels[encoding],
+				minSize:        cfg.MinSize,
+				types:          types,
+			}
+			defer cw.close()
+
+			next.ServeHTTP(cw, r)
+		})
+	}
+}
+
+// compressWriter decides at WriteHeader time whether to compress the response.
+type compressWriter struct {
+	http.ResponseWriter
+	encoding    string
+	level       int
+	minSize     int64
+	types       []string
+	wroteHeader bool
+	enc         io.WriteCloser
+}
+
+func (cw *compressWriter) WriteHeader(code int) {
+	if cw.wroteHeader {
+		return
+	}
+	cw.wroteHeader = true
+
+	h := cw.Header()
+	if isCompressible(h.Get("Content-Type"), cw.types) {
+		addVary(h, "Accept-Encoding")
+	}
+
+	if cw.shouldCompress(code) {
+		if entry, ok := lookupEncoder(cw.encoding); ok {
+			enc, err := entry.factory(cw.ResponseWriter, cw.level)
+			if err != nil {
+				slog.Error("create compression writer", "encoding", cw.encoding, "error", err)
+			} else {
+				cw.enc = enc
+				h.Del("Content-Length")
+				h.Set("Content-Encoding", cw.encoding)
+				if etag := h.Get("ETag"); etag != "" && !strings.HasPrefix(etag, "W/") {
+					h.Set("ETag", "W/"+etag)
+				}
+			}
+		}
+	}
+
+	cw.ResponseWriter.WriteHeader(code)
+}
+
+func (cw *compressWriter) shouldCompress(code int) bool {
+	if cw.encoding == "" || code != http.StatusOK {
+		return false
+	}
+
+	h := cw.Header()
+	if h.Get("Content-Encoding") != "" || h.Get("Content-Range") != "" {
+		return false
+	}
+	if !isCompressible(h.Get("Content-Type"), cw.types) {
+		return false
+	}
+	// An empty body would grow into an encoder frame sent chunked
+	if cl := h.Get("Content-Length"); cl != "" {
+		if n, err := strconv.ParseInt(cl, 10, 64); err == nil && (n == 0 || n < cw.minSize) {
+			return false
+		}
+	}
+	return true
+}
+
+func (cw *compressWriter) Write(p []byte) (int, error) {
+	if !cw.wroteHeader {
+		cw.WriteHeader(http.StatusOK)
+	}
+	if cw.enc != nil {
+		return cw.enc.Write(p)
+	}
+	return cw.ResponseWriter.Write(p)
+}
+
+// Flush flushes buffered compressed data to the client.
+func (cw *compressWriter) Flush() {
+	if f, ok := cw.enc.(interface{ Flush() error }); ok {
+		_ = f.Flush()
+	}
+	if f, ok := cw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Hijack allows protocol upgrades through the middleware.
+func (cw *compressWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	if hj, ok := cw.ResponseWriter.(http.Hijacker); ok {
+		return hj.Hijack()
+	}
+	return nil, nil, errors.New("hijack not supported")
+}
+
+// Unwrap supports http.ResponseController.
+func (cw *compressWriter) Unwrap() http.ResponseWriter {
+	return cw.ResponseWriter
+}
+
+func (cw *compressWriter) close() {
+	if cw.enc == nil {
+		return
+	}
+	if err := cw.enc.Close(); err != nil {
+		slog.Warn("close compression writer", "encoding", cw.encoding, "error", err)
+	}
+}
@@ -0,0 +1,26 @@
+//go:build brotli
+
+package http
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/andybalholm/brotli"
+)
+
+// defaultBrotliLevel favors on-the-fly throughput over ratio. Raise it through
+// CompressionConfig.Levels for mostly static, cache-friendly content.
+const defaultBrotliLevel = 5
+
+func init() {
+	RegisterEncoder("br", 30, func(w io.Writer, level int) (io.WriteCloser, error) {
+		if level == 0 {
+			level = defaultBrotliLevel
+		}
+		if level < brotli.BestSpeed || level > brotli.BestCompression {
+			return nil, fmt.Errorf("brotli level must be between %d and %d", brotli.BestSpeed, brotli.BestCompression)
+		}
+		return brotli.NewWriterLevel(w, level), nil
+	})
+}
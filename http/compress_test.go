@@ -0,0 +1,336 @@
+package http_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sagarc03/stowry"
+	stowryhttp "github.com/sagarc03/stowry/http"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+var compressBody = strings.Repeat(`{"path":"assets/app.js","content_type":"application/javascript"},`, 200)
+
+func compressTestHandler(contentType, body string, status int) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", contentType)
+		w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+		w.Header().Set("ETag", `"abc123"`)
+		w.WriteHeader(status)
+		_, _ = io.WriteString(w, body)
+	})
+}
+
+func TestCompressionMiddleware_Negotiation(t *testing.T) {
+	tests := []struct {
+		name           string
+		acceptEncoding string
+		wantEncoding   string
+	}{
+		{name: "no header", acceptEncoding: "", wantEncoding: ""},
+		{name: "gzip", acceptEncoding: "gzip", wantEncoding: "gzip"},
+		{name: "case insensitive", acceptEncoding: "GZIP", wantEncoding: "gzip"},
+		{name: "q zero refuses", acceptEncoding: "gzip;q=0", wantEncoding: ""},
+		{name: "wildcard", acceptEncoding: "*", wantEncoding: "gzip"},
+		{name: "wildcard with explicit refusal", acceptEncoding: "*, gzip;q=0", wantEncoding: ""},
+		{name: "identity only", acceptEncoding: "identity", wantEncoding: ""},
+		{name: "unknown coding", acceptEncoding: "compress, x-custom", wantEncoding: ""},
+		{name: "malformed q treated as zero", acceptEncoding: "gzip;q=abc", wantEncoding: ""},
+		{name: "spaces around params", acceptEncoding: " deflate ; q=0.5 , gzip ; q=0.8", wantEncoding: "gzip"},
+		{name: "encoding not offered", acceptEncoding: "lzma", wantEncoding: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Pin the offered set so results don't depend on build tags
+			cfg := stowryhttp.CompressionConfig{Enabled: true, Encodings: []string{"gzip"}}
+			handler := stowryhttp.CompressionMiddleware(cfg)(compressTestHandler("application/json", compressBody, http.StatusOK))
+
+			req := httptest.NewRequest(http.MethodGet, "/file.json", http.NoBody)
+			if tt.acceptEncoding != "" {
+				req.Header.Set("Accept-Encoding", tt.acceptEncoding)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			assert.Equal(t, tt.wantEncoding, rec.Header().Get("Content-Encoding"))
+			assert.Equal(t, "Accept-Encoding", rec.Header().Get("Vary"))
+		})
+	}
+}
+
+func TestCompressionMiddleware_GzipRoundTrip(t *testing.T) {
+	cfg := stowryhttp.CompressionConfig{Enabled: true, Encodings: []string{"gzip"}, Levels: map[string]int{"gzip": gzip.BestSpeed}}
+	handler := stowryhttp.CompressionMiddleware(cfg)(compressTestHandler("text/html; charset=utf-8", compressBody, http.StatusOK))
+
+	req := httptest.NewRequest(http.MethodGet, "/index.html", http.NoBody)
+	req.Header.Set("Accept-Encoding", "gzip")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	assert.Equal(t, "gzip", rec.Header().Get("Content-Encoding"))
+	assert.Empty(t, rec.Header().Get("Content-Length"))
+	assert.Equal(t, `W/"abc123"`, rec.Header().Get("ETag"))
+	assert.Less(t, rec.Body.Len(), len(compressBody))
+
+	gr, err := gzip.NewReader(rec.Body)
+	require.NoError(t, err)
+	decoded, err := io.ReadAll(gr)
+	require.NoError(t, err)
+	assert.Equal(t, compressBody, string(decoded))
+}
+
+func TestCompressionMiddleware_DeflateRoundTrip(t *testing.T) {
+	cfg := stowryhttp.CompressionConfig{Enabled: true, Encodings: []string{"gzip", "deflate"}}
+	handler := stowryhttp.CompressionMiddleware(cfg)(compressTestHandler("application/javascript", compressBody, http.StatusOK))
+
+	req := httptest.NewRequest(http.MethodGet, "/app.js", http.NoBody)
+	req.Header.Set("Accept-Encoding", "deflate")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	assert.Equal(t, "deflate", rec.Header().Get("Content-Encoding"))
+	assert.Equal(t, `W/"abc123"`, rec.Header().Get("ETag"))
+
+	zr, err := zlib.NewReader(rec.Body)
+	require.NoError(t, err)
+	decoded, err := io.ReadAll(zr)
+	require.NoError(t, err)
+	assert.Equal(t, compressBody, string(decoded))
+}
+
+func TestCompressionMiddleware_Types(t *testing.T) {
+	tests := []struct {
+		name        string
+		types       []string
+		contentType string
+		want        bool
+	}{
+		{name: "default text", contentType: "text/css; charset=utf-8", want: true},
+		{name: "default json suffix", contentType: "application/ld+json", want: true},
+		{name: "default binary", contentType: "application/octet-stream", want: false},
+		{name: "custom exact", types: []string{"application/octet-stream"}, contentType: "application/octet-stream", want: true},
+		{name: "custom replaces defaults", types: []string{"application/octet-stream"}, contentType: "text/plain", want: false},
+		{name: "custom wildcard", types: []string{"font/*"}, contentType: "font/ttf", want: true},
+		{name: "custom suffix", types: []string{"+yaml"}, contentType: "application/openapi+yaml", want: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := stowryhttp.CompressionConfig{Enabled: true, Encodings: []string{"gzip"}, Types: tt.types}
+			handler := stowryhttp.CompressionMiddleware(cfg)(compressTestHandler(tt.contentType, compressBody, http.StatusOK))
+
+			req := httptest.NewRequest(http.MethodGet, "/file", http.NoBody)
+			req.Header.Set("Accept-Encoding", "gzip")
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if tt.want {
+				assert.Equal(t, "gzip", rec.Header().Get("Content-Encoding"))
+			} else {
+				assert.Empty(t, rec.Header().Get("Content-Encoding"))
+				assert.Equal(t, compressBody, rec.Body.String())
+			}
+		})
+	}
+}
+
+func TestCompressionMiddleware_Skips(t *testing.T) {
+	tests := []struct {
+		name        string
+		method      string
+		contentType string
+		status      int
+		body        string
+		header      http.Header
+		minSize     int64
+	}{
+		{name: "binary content type", method: http.MethodGet, contentType: "image/png", status: http.StatusOK, body: compressBody},
+		{name: "not modified", method: http.MethodGet, contentType: "text/plain", status: http.StatusNotModified, body: ""},
+		{name: "head request", method: http.MethodHead, contentType: "text/plain", status: http.StatusOK, body: ""},
+		{name: "range request", method: http.MethodGet, contentType: "text/plain", status: http.StatusOK, body: compressBody, header: http.Header{"Range": {"bytes=0-10"}}},
+		{name: "below min size", method: http.MethodGet, contentType: "text/plain", status: http.StatusOK, body: "tiny", minSize: 1024},
+		{name: "empty body without min size", method: http.MethodGet, contentType: "text/plain", status: http.StatusOK, body: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := stowryhttp.CompressionConfig{Enabled: true, MinSize: tt.minSize}
+			handler := stowryhttp.CompressionMiddleware(cfg)(compressTestHandler(tt.contentType, tt.body, tt.status))
+
+			req := httptest.NewRequest(tt.method, "/file", http.NoBody)
+			req.Header.Set("Accept-Encoding", "gzip")
+			for k, v := range tt.header {
+				req.Header[k] = v
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			assert.Equal(t, tt.status, rec.Code)
+			assert.Empty(t, rec.Header().Get("Content-Encoding"))
+			assert.Equal(t, `"abc123"`, rec.Header().Get("ETag"))
+			assert.Equal(t, tt.body, rec.Body.String())
+			if tt.method == http.MethodGet && tt.status == http.StatusOK {
+				assert.Equal(t, strconv.Itoa(len(tt.body)), rec.Header().Get("Content-Length"))
+			}
+		})
+	}
+}
+
+func TestCompressionMiddleware_AlreadyEncoded(t *testing.T) {
+	inner := http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "text/css")
+		w.Header().Set("Content-Encoding", "br")
+		_, _ = io.WriteString(w, "precompressed")
+	})
+	handler := stowryhttp.CompressionMiddleware(stowryhttp.CompressionConfig{Enabled: true})(inner)
+
+	req := httptest.NewRequest(http.MethodGet, "/style.css", http.NoBody)
+	req.Header.Set("Accept-Encoding", "gzip")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	assert.Equal(t, "br", rec.Header().Get("Content-Encoding"))
+	assert.Equal(t, "precompressed", rec.Body.String())
+}
+
+func TestCompressionMiddleware_Disabled(t *testing.T) {
+	handler := stowryhttp.CompressionMiddleware(stowryhttp.CompressionConfig{})(compressTestHandler("text/plain", compressBody, http.StatusOK))
+
+	req := httptest.NewRequest(http.MethodGet, "/file.txt", http.NoBody)
+	req.Header.Set("Accept-Encoding", "gzip")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	assert.Empty(t, rec.Header().Get("Content-Encoding"))
+	assert.Empty(t, rec.Header().Get("Vary"))
+	assert.Equal(t, compressBody, rec.Body.String())
+}
+
+func TestHandler_Compression(t *testing.T) {
+	config := &stowryhttp.HandlerConfig{
+		Mode:        stowry.ModeSPA,
+		Compression: stowryhttp.CompressionConfig{Enabled: true, Encodings: []string{"gzip"}},
+	}
+	plain := stowry.MetaData{Path: "app.js", ContentType: "application/javascript", Etag: "abc123",
+		FileSizeBytes: int64(len(compressBody)), UpdatedAt: time.Now()}
+	stored := gzipBytes(t, compressBody)
+	encoded := stowry.MetaData{Path: "data.json", ContentType: "application/json", ContentEncoding: "gzip", Etag: "def456",
+		FileSizeBytes: int64(len(stored)), UpdatedAt: time.Now()}
+
+	service := new(MockService)
+	service.On("Get", mock.Anything, "app.js").Return(plain, readSeekNopCloser{strings.NewReader(compressBody)}, nil)
+	service.On("Get", mock.Anything, "data.json").Return(encoded, readSeekNopCloser{bytes.NewReader(stored)}, nil)
+	handler := stowryhttp.NewHandler(config, service).Router()
+
+	get := func(path string, header http.Header) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, http.NoBody)
+		req.Header = header
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	t.Run("compressed", func(t *testing.T) {
+		rec := get("/app.js", http.Header{"Accept-Encoding": {"gzip"}})
+		require.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "gzip", rec.Header().Get("Content-Encoding"))
+		assert.Contains(t, rec.Header().Values("Vary"), "Accept-Encoding")
+		assert.Empty(t, rec.Header().Get("Content-Length"))
+		assert.Equal(t, `W/"abc123"`, rec.Header().Get("ETag"))
+	})
+
+	t.Run("weak etag revalidates", func(t *testing.T) {
+		rec := get("/app.js", http.Header{"Accept-Encoding": {"gzip"}, "If-None-Match": {`W/"abc123"`}})
+		assert.Equal(t, http.StatusNotModified, rec.Code)
+		assert.Empty(t, rec.Body.String())
+	})
+
+	t.Run("range", func(t *testing.T) {
+		rec := get("/app.js", http.Header{"Accept-Encoding": {"gzip"}, "Range": {"bytes=0-9"}})
+		assert.Equal(t, http.StatusPartialContent, rec.Code)
+		assert.Empty(t, rec.Header().Get("Content-Encoding"))
+		assert.Equal(t, compressBody[:10], rec.Body.String())
+	})
+
+	t.Run("encoded object is not compressed again", func(t *testing.T) {
+		rec := get("/data.json", http.Header{"Accept-Encoding": {"gzip"}})
+		require.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "gzip", rec.Header().Get("Content-Encoding"))
+		assert.Equal(t, stored, rec.Body.Bytes())
+	})
+}
+
+func TestCompressionConfig_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     stowryhttp.CompressionConfig
+		wantErr bool
+	}{
+		{name: "disabled ignores unknown", cfg: stowryhttp.CompressionConfig{Encodings: []string{"lzma"}}},
+		{name: "gzip", cfg: stowryhttp.CompressionConfig{Enabled: true, Encodings: []string{"gzip"}}},
+		{name: "unknown encoding", cfg: stowryhttp.CompressionConfig{Enabled: true, Encodings: []string{"lzma"}}, wantErr: true},
+		{name: "valid gzip level", cfg: stowryhttp.CompressionConfig{Enabled: true, Levels: map[string]int{"gzip": 9}}},
+		{name: "invalid gzip level", cfg: stowryhttp.CompressionConfig{Enabled: true, Levels: map[string]int{"gzip": 42}}, wantErr: true},
+		{name: "level for unknown encoding", cfg: stowryhttp.CompressionConfig{Enabled: true, Levels: map[string]int{"lzma": 1}}, wantErr: true},
+		{name: "deflate", cfg: stowryhttp.CompressionConfig{Enabled: true, Encodings: []string{"deflate"}, Levels: map[string]int{"deflate": 9}}},
+		{name: "invalid deflate level", cfg: stowryhttp.CompressionConfig{Enabled: true, Levels: map[string]int{"deflate": 42}}, wantErr: true},
+		{name: "valid types", cfg: stowryhttp.CompressionConfig{Enabled: true, Types: []string{"text/*", "application/json", "+xml"}}},
+		{name: "bare type", cfg: stowryhttp.CompressionConfig{Enabled: true, Types: []string{"text"}}, wantErr: true},
+		{name: "any type", cfg: stowryhttp.CompressionConfig{Enabled: true, Types: []string{"*/*"}}, wantErr: true},
+		{name: "partial wildcard", cfg: stowryhttp.CompressionConfig{Enabled: true, Types: []string{"text/x-*"}}, wantErr: true},
+		{name: "empty suffix", cfg: stowryhttp.CompressionConfig{Enabled: true, Types: []string{"+"}}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.cfg.Validate()
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestSupportedEncodings_IncludesGzip(t *testing.T) {
+	assert.Contains(t, stowryhttp.SupportedEncodings(), "gzip")
+}
+
+// BenchmarkCompressionEncoders compares CPU cost and ratio of each compiled-in
+// encoding at its default level. Run with -tags brotli,zstd to include them.
+func BenchmarkCompressionEncoders(b *testing.B) {
+	body := strings.Repeat(compressBody, 8)
+
+	for _, encoding := range stowryhttp.SupportedEncodings() {
+		b.Run(encoding, func(b *testing.B) {
+			cfg := stowryhttp.CompressionConfig{Enabled: true, Encodings: []string{encoding}}
+			handler := stowryhttp.CompressionMiddleware(cfg)(compressTestHandler("application/json", body, http.StatusOK))
+
+			req := httptest.NewRequest(http.MethodGet, "/file.json", http.NoBody)
+			req.Header.Set("Accept-Encoding", encoding)
+
+			var out bytes.Buffer
+			b.SetBytes(int64(len(body)))
+			b.ResetTimer()
+			for b.Loop() {
+				out.Reset()
+				rec := httptest.NewRecorder()
+				rec.Body = &out
+				handler.ServeHTTP(rec, req)
+			}
+			b.ReportMetric(float64(out.Len())/float64(len(body)), "ratio")
+		})
+	}
+}
@@ -0,0 +1,19 @@
+//go:build zstd
+
+package http
+
+import (
+	"io"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+func init() {
+	RegisterEncoder("zstd", 20, func(w io.Writer, level int) (io.WriteCloser, error) {
+		opts := []zstd.EOption{zstd.WithEncoderConcurrency(1)}
+		if level != 0 {
+			opts = append(opts, zstd.WithEncoderLevel(zstd.EncoderLevelFromZstd(level)))
+		}
+		return zstd.NewWriter(w, opts...)
+	})
+}
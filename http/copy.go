@@ -0,0 +1,95 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/sagarc03/stowry"
+)
+
+// CopySourceHeader makes a PUT copy the object it names to the request path
+// instead of storing the body. AmzCopySourceHeader is accepted in its place
+// for S3 SDKs; since Stowry has no buckets, its value is the object path.
+const (
+	CopySourceHeader    = "X-Stowry-Copy-Source"
+	AmzCopySourceHeader = "X-Amz-Copy-Source"
+)
+
+// CopyService is an optional Service extension for copying an object on the
+// server. When the service implements it, a store mode PUT with
+// CopySourceHeader copies the named object instead of reading the body.
+// *stowry.StowryService implements it.
+type CopyService interface {
+	Copy(ctx context.Context, srcPath, dstPath string) (stowry.CreateResult, error)
+}
+
+// requestCopySource returns the object path a PUT copies from, and whether
+// the request is a copy. The value may be percent-encoded and start with
+// "/", as S3 clients send it.
+func requestCopySource(r *http.Request) (string, bool, error) {
+	header := r.Header.Get(CopySourceHeader)
+	if header == "" {
+		header = r.Header.Get(AmzCopySourceHeader)
+	}
+	if header == "" {
+		return "", false, nil
+	}
+
+	source, err := url.PathUnescape(header)
+	if err != nil {
+		return "", true, err
+	}
+	source = strings.TrimPrefix(source, "/")
+	if source == "" || !stowry.IsValidPath(source) {
+		return "", true, errors.New("invalid copy source")
+	}
+	return source, true, nil
+}
+
+// handleCopy serves a PUT with a copy source. It is answered like any other
+// PUT, with the metadata of the object written to path, and honors If-Match.
+// The copy keeps the source's content type, encoding and filename, so
+// presigned upload constraints, staging and a client-chosen ID do not apply.
+func (h *Handler) handleCopy(w http.ResponseWriter, r *http.Request, source, path string, constraints stowry.PresignConstraints) {
+	cs, ok := h.service.(CopyService)
+	if !ok {
+		h.writeError(w, r, http.StatusNotImplemented, "not_supported", "Copying objects is not supported")
+		return
+	}
+	switch {
+	case source == path:
+		h.writeError(w, r, http.StatusBadRequest, "invalid_copy_source", "Copy source and destination are the same")
+		return
+	case !constraints.IsZero():
+		h.writeError(w, r, http.StatusBadRequest, "invalid_parameter", "Presigned upload constraints cannot be used with a copy")
+		return
+	case r.Header.Get(StageHeader) != "":
+		h.writeError(w, r, http.StatusBadRequest, "invalid_parameter", StageHeader+" cannot be used with a copy")
+		return
+	case r.Header.Get(ObjectIDHeader) != "":
+		h.writeError(w, r, http.StatusBadRequest, "invalid_parameter", ObjectIDHeader+" cannot be used with a copy")
+		return
+	}
+	if !h.checkIfMatch(w, r, path) {
+		return
+	}
+
+	res, err := cs.Copy(r.Context(), source, path)
+	if err != nil {
+		if errors.Is(err, stowry.ErrNotFound) {
+			h.writeError(w, r, http.StatusNotFound, "source_not_found", "Copy source not found")
+			return
+		}
+		// Lost a first-write race, as for an upload
+		if errors.Is(err, stowry.ErrConflict) && res.Etag != "" {
+			w.Header().Set("ETag", `"`+res.Etag+`"`)
+		}
+		h.handleError(w, r, err)
+		return
+	}
+
+	writeUploadResult(w, r, res)
+}
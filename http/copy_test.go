@@ -0,0 +1,149 @@
+package http_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/sagarc03/stowry"
+	stowryhttp "github.com/sagarc03/stowry/http"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// copyMockService is a MockService that can copy objects.
+type copyMockService struct {
+	MockService
+}
+
+func (m *copyMockService) Copy(ctx context.Context, srcPath, dstPath string) (stowry.CreateResult, error) {
+	args := m.Called(ctx, srcPath, dstPath)
+	return args.Get(0).(stowry.CreateResult), args.Error(1)
+}
+
+func TestHandler_Copy(t *testing.T) {
+	serve := func(t *testing.T, service stowryhttp.Service, path string, header http.Header) *httptest.ResponseRecorder {
+		t.Helper()
+		h, err := stowryhttp.New(&stowryhttp.HandlerConfig{Mode: stowry.ModeStore}, service)
+		require.NoError(t, err)
+		req := httptest.NewRequest(http.MethodPut, path, strings.NewReader("ignored"))
+		for k, v := range header {
+			req.Header[k] = v
+		}
+		rec := httptest.NewRecorder()
+		h.Router().ServeHTTP(rec, req)
+		return rec
+	}
+	copied := stowry.CreateResult{MetaData: stowry.MetaData{
+		Path:          "b.txt",
+		ContentType:   "text/plain",
+		Etag:          "abc123",
+		FileSizeBytes: 5,
+	}}
+
+	t.Run("copies the source", func(t *testing.T) {
+		service := new(copyMockService)
+		service.On("Copy", mock.Anything, "dir/a.txt", "b.txt").Return(copied, nil)
+
+		rec := serve(t, service, "/b.txt", http.Header{stowryhttp.CopySourceHeader: {"dir/a.txt"}})
+
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		assert.Equal(t, `"abc123"`, rec.Header().Get("ETag"))
+		var meta stowry.MetaData
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &meta))
+		assert.Equal(t, "b.txt", meta.Path)
+		service.AssertNotCalled(t, "Create", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("S3 header with leading slash and escapes", func(t *testing.T) {
+		service := new(copyMockService)
+		service.On("Copy", mock.Anything, "dir/café.txt", "b.txt").Return(copied, nil)
+
+		rec := serve(t, service, "/b.txt", http.Header{stowryhttp.AmzCopySourceHeader: {"/dir/caf%C3%A9.txt"}})
+
+		assert.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		service.AssertExpectations(t)
+	})
+
+	t.Run("missing source", func(t *testing.T) {
+		service := new(copyMockService)
+		service.On("Copy", mock.Anything, "a.txt", "b.txt").Return(stowry.CreateResult{}, fmt.Errorf("copy object: %w", stowry.ErrNotFound))
+
+		rec := serve(t, service, "/b.txt", http.Header{stowryhttp.CopySourceHeader: {"a.txt"}})
+
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+		assertErrorCode(t, rec, "source_not_found")
+	})
+
+	t.Run("invalid source", func(t *testing.T) {
+		service := new(copyMockService)
+
+		rec := serve(t, service, "/b.txt", http.Header{stowryhttp.CopySourceHeader: {"../a.txt"}})
+
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		assertErrorCode(t, rec, "invalid_copy_source")
+		service.AssertNotCalled(t, "Copy", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("source is the destination", func(t *testing.T) {
+		service := new(copyMockService)
+
+		rec := serve(t, service, "/b.txt", http.Header{stowryhttp.CopySourceHeader: {"/b.txt"}})
+
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		assertErrorCode(t, rec, "invalid_copy_source")
+		service.AssertNotCalled(t, "Copy", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("staging cannot be combined", func(t *testing.T) {
+		service := new(copyMockService)
+
+		rec := serve(t, service, "/b.txt", http.Header{
+			stowryhttp.CopySourceHeader: {"a.txt"},
+			stowryhttp.StageHeader:      {"true"},
+		})
+
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		assertErrorCode(t, rec, "invalid_parameter")
+		service.AssertNotCalled(t, "Copy", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("If-Match mismatch", func(t *testing.T) {
+		service := new(copyMockService)
+		service.On("Info", mock.Anything, "b.txt").Return(stowry.MetaData{Path: "b.txt", Etag: "old"}, nil)
+
+		rec := serve(t, service, "/b.txt", http.Header{
+			stowryhttp.CopySourceHeader: {"a.txt"},
+			"If-Match":                  {`"other"`},
+		})
+
+		assert.Equal(t, http.StatusPreconditionFailed, rec.Code)
+		service.AssertNotCalled(t, "Copy", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("not supported without CopyService", func(t *testing.T) {
+		service := new(MockService)
+
+		rec := serve(t, service, "/b.txt", http.Header{stowryhttp.CopySourceHeader: {"a.txt"}})
+
+		assert.Equal(t, http.StatusNotImplemented, rec.Code)
+		assertErrorCode(t, rec, "not_supported")
+		service.AssertNotCalled(t, "Create", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("listed in capabilities", func(t *testing.T) {
+		h, err := stowryhttp.New(&stowryhttp.HandlerConfig{Mode: stowry.ModeStore}, new(copyMockService))
+		require.NoError(t, err)
+		rec := httptest.NewRecorder()
+		h.Router().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/?capabilities", nil))
+
+		var caps stowryhttp.Capabilities
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &caps))
+		assert.Contains(t, caps.Features, "copy")
+	})
+}
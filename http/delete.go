@@ -0,0 +1,169 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/sagarc03/stowry"
+)
+
+// maxDeleteRequestSize bounds the JSON path list of POST /?delete, as
+// maxStatRequestSize does for POST /?stat.
+const maxDeleteRequestSize = 2 << 20
+
+// DeleteManyService is an optional Service extension for deleting many
+// objects at once. When the service implements it, store mode serves
+// POST /?delete with write authentication, so a client deleting hundreds of
+// objects needs one request instead of a DELETE each.
+// *stowry.StowryService implements it.
+type DeleteManyService interface {
+	DeleteMany(ctx context.Context, paths []string) ([]stowry.DeleteManyResult, error)
+}
+
+// DeleteResponse is the body of POST /?delete: a result for every path
+// requested, in the order given.
+type DeleteResponse struct {
+	Results []DeletePathResult `json:"results"`
+}
+
+// DeletePathResult is the outcome of deleting one path. Error and Message
+// are set, as in ErrorResponse, when Status is "error".
+type DeletePathResult struct {
+	Path    string              `json:"path"`
+	Status  stowry.DeleteStatus `json:"status"`
+	Error   string              `json:"error,omitempty"`
+	Message string              `json:"message,omitempty"`
+}
+
+// isDeleteRequest reports whether a POST / asks to delete objects.
+func isDeleteRequest(r *http.Request) bool {
+	return r.URL.Query().Has("delete")
+}
+
+// deleteManyService returns the service's DeleteManyService, if any.
+func (h *Handler) deleteManyService() (DeleteManyService, bool) {
+	ds, ok := h.service.(DeleteManyService)
+	return ds, ok
+}
+
+// maxDeletePaths returns the most paths a POST /?delete may name.
+func (h *Handler) maxDeletePaths() int {
+	if h.config.MaxDeletePaths > 0 {
+		return h.config.MaxDeletePaths
+	}
+	return stowry.DefaultMaxDeletePaths
+}
+
+// handleDeleteMany serves POST /?delete in store mode. The body is a JSON
+// array of up to HandlerConfig.MaxDeletePaths paths. A path that cannot be
+// deleted does not fail the request; its result says why.
+func (h *Handler) handleDeleteMany(w http.ResponseWriter, r *http.Request) {
+	// Only routed when deleteManyService is available
+	ds, _ := h.deleteManyService()
+
+	var paths []string
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxDeleteRequestSize)).Decode(&paths); err != nil {
+		h.writeError(w, r, http.StatusBadRequest, "invalid_request", "Request body must be a JSON array of paths")
+		return
+	}
+	if limit := h.maxDeletePaths(); len(paths) > limit {
+		h.writeError(w, r, http.StatusBadRequest, "too_many_paths", fmt.Sprintf("At most %d paths per request", limit))
+		return
+	}
+
+	results, err := ds.DeleteMany(r.Context(), paths)
+	if err != nil {
+		h.handleError(w, r, err)
+		return
+	}
+
+	resp := DeleteResponse{Results: make([]DeletePathResult, len(results))}
+	for i, res := range results {
+		resp.Results[i] = DeletePathResult{Path: res.Path, Status: res.Status}
+		if res.Status == stowry.DeleteStatusError {
+			var status int
+			status, resp.Results[i].Error, resp.Results[i].Message = errorStatus(res.Err)
+			if status >= http.StatusInternalServerError {
+				h.logger.Error("bulk delete error", "error", res.Err, "path", res.Path)
+			}
+		}
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	_ = WriteJSON(w, http.StatusOK, resp)
+}
+
+// DeletePrefixService is an optional Service extension for deleting every
+// object under a prefix. When the service implements it, store mode serves
+// DELETE /?prefix={prefix} with write authentication.
+// *stowry.StowryService implements it.
+type DeletePrefixService interface {
+	DeletePrefix(ctx context.Context, prefix string, dryRun bool) (stowry.DeletePrefixResult, error)
+}
+
+// DeletePrefixResponse is the body of DELETE /?prefix. Paths is only set for
+// a dry run when listing is not disabled, with PathsTruncated when it lists
+// only the first of the objects, and Errors lists the objects whose delete
+// failed.
+type DeletePrefixResponse struct {
+	Prefix         string             `json:"prefix"`
+	DryRun         bool               `json:"dry_run"`
+	Deleted        int                `json:"deleted"`
+	Paths          []string           `json:"paths,omitempty"`
+	PathsTruncated bool               `json:"paths_truncated,omitempty"`
+	Errors         []DeletePathResult `json:"errors,omitempty"`
+}
+
+// deletePrefixService returns the service's DeletePrefixService, if any.
+func (h *Handler) deletePrefixService() (DeletePrefixService, bool) {
+	if h.config.Mode != stowry.ModeStore {
+		return nil, false
+	}
+	ds, ok := h.service.(DeletePrefixService)
+	return ds, ok
+}
+
+// handleDeletePrefix serves DELETE /?prefix={prefix}, with dry_run=true to
+// only report what would be deleted. An empty or missing prefix would delete
+// every object, so it is refused unless force=true is set too.
+func (h *Handler) handleDeletePrefix(w http.ResponseWriter, r *http.Request) {
+	// Only routed when deletePrefixService is available
+	ds, _ := h.deletePrefixService()
+
+	q := r.URL.Query()
+	prefix := q.Get("prefix")
+	if prefix == "" && q.Get("force") != "true" {
+		h.writeError(w, r, http.StatusBadRequest, "invalid_parameter", "prefix is required; set force=true to delete every object")
+		return
+	}
+	dryRun := q.Get("dry_run") == "true"
+
+	result, err := ds.DeletePrefix(r.Context(), prefix, dryRun)
+	if err != nil {
+		h.handleError(w, r, err)
+		return
+	}
+
+	resp := DeletePrefixResponse{Prefix: prefix, DryRun: dryRun, Deleted: result.Deleted}
+	// The paths of a dry run would list the objects
+	if !h.config.DisableList {
+		resp.Paths, resp.PathsTruncated = result.Paths, result.PathsTruncated
+	}
+	for _, res := range result.Errors {
+		status, code, message := errorStatus(res.Err)
+		if status >= http.StatusInternalServerError {
+			h.logger.Error("prefix delete error", "error", res.Err, "path", res.Path)
+		}
+		resp.Errors = append(resp.Errors, DeletePathResult{Path: res.Path, Status: res.Status, Error: code, Message: message})
+	}
+
+	if !dryRun {
+		principal, _ := PrincipalFromContext(r.Context())
+		h.logger.Info("prefix deleted", "prefix", prefix, "deleted", result.Deleted, "failed", len(result.Errors), "principal", principal.Subject)
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	_ = WriteJSON(w, http.StatusOK, resp)
+}
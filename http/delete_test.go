@@ -0,0 +1,252 @@
+package http_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/sagarc03/stowry"
+	stowryhttp "github.com/sagarc03/stowry/http"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// deleteManyMockService is a MockService that can delete many objects at once.
+type deleteManyMockService struct {
+	MockService
+}
+
+func (m *deleteManyMockService) DeleteMany(ctx context.Context, paths []string) ([]stowry.DeleteManyResult, error) {
+	args := m.Called(ctx, paths)
+	results, _ := args.Get(0).([]stowry.DeleteManyResult)
+	return results, args.Error(1)
+}
+
+func TestHandler_DeleteMany(t *testing.T) {
+	serve := func(t *testing.T, config stowryhttp.HandlerConfig, service stowryhttp.Service, target, body string, authed bool) *httptest.ResponseRecorder {
+		t.Helper()
+		config.Mode = stowry.ModeStore
+		config.WriteVerifier = headerVerifier{}
+		h, err := stowryhttp.New(&config, service)
+		require.NoError(t, err)
+		req := httptest.NewRequest(http.MethodPost, target, strings.NewReader(body))
+		if authed {
+			req.Header.Set("X-Test-Auth", "ok")
+		}
+		rec := httptest.NewRecorder()
+		h.Router().ServeHTTP(rec, req)
+		return rec
+	}
+
+	t.Run("reports each path", func(t *testing.T) {
+		service := new(deleteManyMockService)
+		service.On("DeleteMany", mock.Anything, []string{"a.txt", "missing.txt", "../x", "broken.txt"}).Return([]stowry.DeleteManyResult{
+			{Path: "a.txt", Status: stowry.DeleteStatusDeleted},
+			{Path: "missing.txt", Status: stowry.DeleteStatusNotFound},
+			{Path: "../x", Status: stowry.DeleteStatusError, Err: fmt.Errorf("delete object: %w", stowry.ErrInvalidInput)},
+			{Path: "broken.txt", Status: stowry.DeleteStatusError, Err: errors.New("db down")},
+		}, nil)
+
+		rec := serve(t, stowryhttp.HandlerConfig{}, service, "/?delete", `["a.txt","missing.txt","../x","broken.txt"]`, true)
+
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		assert.Equal(t, "no-store", rec.Header().Get("Cache-Control"))
+		assert.JSONEq(t, `{"results": [
+			{"path": "a.txt", "status": "deleted"},
+			{"path": "missing.txt", "status": "not_found"},
+			{"path": "../x", "status": "error", "error": "invalid_path", "message": "Invalid path"},
+			{"path": "broken.txt", "status": "error", "error": "internal_error", "message": "Internal server error"}
+		]}`, rec.Body.String())
+	})
+
+	t.Run("needs write authentication", func(t *testing.T) {
+		service := new(deleteManyMockService)
+
+		rec := serve(t, stowryhttp.HandlerConfig{}, service, "/?delete", `["a.txt"]`, false)
+
+		assert.Equal(t, http.StatusUnauthorized, rec.Code)
+		service.AssertNotCalled(t, "DeleteMany", mock.Anything, mock.Anything)
+	})
+
+	t.Run("too many paths", func(t *testing.T) {
+		service := new(deleteManyMockService)
+
+		rec := serve(t, stowryhttp.HandlerConfig{MaxDeletePaths: 2}, service, "/?delete", `["a","b","c"]`, true)
+
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		assertErrorCode(t, rec, "too_many_paths")
+		service.AssertNotCalled(t, "DeleteMany", mock.Anything, mock.Anything)
+	})
+
+	t.Run("default limit", func(t *testing.T) {
+		service := new(deleteManyMockService)
+		paths, err := json.Marshal(make([]string, stowry.DefaultMaxDeletePaths+1))
+		require.NoError(t, err)
+
+		rec := serve(t, stowryhttp.HandlerConfig{}, service, "/?delete", string(paths), true)
+
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		assertErrorCode(t, rec, "too_many_paths")
+	})
+
+	t.Run("invalid body", func(t *testing.T) {
+		rec := serve(t, stowryhttp.HandlerConfig{}, new(deleteManyMockService), "/?delete", `{"paths":["a.txt"]}`, true)
+
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		assertErrorCode(t, rec, "invalid_request")
+	})
+
+	t.Run("not served without DeleteManyService", func(t *testing.T) {
+		rec := serve(t, stowryhttp.HandlerConfig{}, new(MockService), "/?delete", `["a.txt"]`, true)
+
+		assert.Equal(t, http.StatusMethodNotAllowed, rec.Code)
+	})
+
+	t.Run("listed in capabilities", func(t *testing.T) {
+		h, err := stowryhttp.New(&stowryhttp.HandlerConfig{Mode: stowry.ModeStore, MaxDeletePaths: 50}, new(deleteManyMockService))
+		require.NoError(t, err)
+		rec := httptest.NewRecorder()
+		h.Router().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/?capabilities", nil))
+
+		var caps stowryhttp.Capabilities
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &caps))
+		assert.Contains(t, caps.Features, "bulk_delete")
+		assert.Equal(t, 50, caps.Limits.MaxDeletePaths)
+	})
+
+	t.Run("negative limit is invalid", func(t *testing.T) {
+		_, err := stowryhttp.New(&stowryhttp.HandlerConfig{Mode: stowry.ModeStore, MaxDeletePaths: -1}, new(deleteManyMockService))
+		assert.Error(t, err)
+	})
+}
+
+// deletePrefixMockService is a MockService that can delete a whole prefix.
+type deletePrefixMockService struct {
+	MockService
+}
+
+func (m *deletePrefixMockService) DeletePrefix(ctx context.Context, prefix string, dryRun bool) (stowry.DeletePrefixResult, error) {
+	args := m.Called(ctx, prefix, dryRun)
+	return args.Get(0).(stowry.DeletePrefixResult), args.Error(1)
+}
+
+func TestHandler_DeletePrefix(t *testing.T) {
+	serve := func(t *testing.T, service stowryhttp.Service, target string, authed bool) *httptest.ResponseRecorder {
+		t.Helper()
+		config := stowryhttp.HandlerConfig{Mode: stowry.ModeStore, WriteVerifier: headerVerifier{}}
+		h, err := stowryhttp.New(&config, service)
+		require.NoError(t, err)
+		req := httptest.NewRequest(http.MethodDelete, target, nil)
+		if authed {
+			req.Header.Set("X-Test-Auth", "ok")
+		}
+		rec := httptest.NewRecorder()
+		h.Router().ServeHTTP(rec, req)
+		return rec
+	}
+
+	t.Run("deletes the prefix and reports failures", func(t *testing.T) {
+		service := new(deletePrefixMockService)
+		service.On("DeletePrefix", mock.Anything, "builds/2023/", false).Return(stowry.DeletePrefixResult{
+			Deleted: 2,
+			Errors:  []stowry.DeleteManyResult{{Path: "builds/2023/c.zip", Status: stowry.DeleteStatusError, Err: errors.New("db down")}},
+		}, nil)
+
+		rec := serve(t, service, "/?prefix=builds/2023/", true)
+
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		assert.Equal(t, "no-store", rec.Header().Get("Cache-Control"))
+		assert.JSONEq(t, `{"prefix": "builds/2023/", "dry_run": false, "deleted": 2, "errors": [
+			{"path": "builds/2023/c.zip", "status": "error", "error": "internal_error", "message": "Internal server error"}
+		]}`, rec.Body.String())
+	})
+
+	t.Run("dry run", func(t *testing.T) {
+		service := new(deletePrefixMockService)
+		service.On("DeletePrefix", mock.Anything, "tmp/", true).
+			Return(stowry.DeletePrefixResult{Deleted: 1, Paths: []string{"tmp/a"}}, nil)
+
+		rec := serve(t, service, "/?prefix=tmp/&dry_run=true", true)
+
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		assert.JSONEq(t, `{"prefix": "tmp/", "dry_run": true, "deleted": 1, "paths": ["tmp/a"]}`, rec.Body.String())
+	})
+
+	t.Run("dry run with truncated paths", func(t *testing.T) {
+		service := new(deletePrefixMockService)
+		service.On("DeletePrefix", mock.Anything, "tmp/", true).
+			Return(stowry.DeletePrefixResult{Deleted: 3, Paths: []string{"tmp/a"}, PathsTruncated: true}, nil)
+
+		rec := serve(t, service, "/?prefix=tmp/&dry_run=true", true)
+
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		assert.JSONEq(t, `{"prefix": "tmp/", "dry_run": true, "deleted": 3, "paths": ["tmp/a"], "paths_truncated": true}`, rec.Body.String())
+	})
+
+	t.Run("dry run only counts when listing is disabled", func(t *testing.T) {
+		service := new(deletePrefixMockService)
+		service.On("DeletePrefix", mock.Anything, "tmp/", true).
+			Return(stowry.DeletePrefixResult{Deleted: 3, Paths: []string{"tmp/a"}, PathsTruncated: true}, nil)
+		config := stowryhttp.HandlerConfig{Mode: stowry.ModeStore, WriteVerifier: headerVerifier{}, DisableList: true}
+		h, err := stowryhttp.New(&config, service)
+		require.NoError(t, err)
+		req := httptest.NewRequest(http.MethodDelete, "/?prefix=tmp/&dry_run=true", nil)
+		req.Header.Set("X-Test-Auth", "ok")
+		rec := httptest.NewRecorder()
+
+		h.Router().ServeHTTP(rec, req)
+
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		assert.JSONEq(t, `{"prefix": "tmp/", "dry_run": true, "deleted": 3}`, rec.Body.String())
+	})
+
+	t.Run("empty prefix needs force", func(t *testing.T) {
+		service := new(deletePrefixMockService)
+		service.On("DeletePrefix", mock.Anything, "", false).Return(stowry.DeletePrefixResult{Deleted: 5}, nil)
+
+		for _, target := range []string{"/", "/?prefix="} {
+			rec := serve(t, service, target, true)
+			assert.Equal(t, http.StatusBadRequest, rec.Code, target)
+			assertErrorCode(t, rec, "invalid_parameter")
+		}
+		service.AssertNotCalled(t, "DeletePrefix", mock.Anything, mock.Anything, mock.Anything)
+
+		rec := serve(t, service, "/?prefix=&force=true", true)
+		assert.Equal(t, http.StatusOK, rec.Code)
+	})
+
+	t.Run("needs write authentication", func(t *testing.T) {
+		service := new(deletePrefixMockService)
+
+		rec := serve(t, service, "/?prefix=tmp/", false)
+
+		assert.Equal(t, http.StatusUnauthorized, rec.Code)
+		service.AssertNotCalled(t, "DeletePrefix", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("invalid prefix", func(t *testing.T) {
+		service := new(deletePrefixMockService)
+		service.On("DeletePrefix", mock.Anything, "bad", false).
+			Return(stowry.DeletePrefixResult{}, fmt.Errorf("delete prefix: %w", &stowry.ListQueryError{Param: "prefix", Reason: "is not valid UTF-8"}))
+
+		rec := serve(t, service, "/?prefix=bad", true)
+
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+	})
+
+	t.Run("object deletes are unaffected", func(t *testing.T) {
+		service := new(deletePrefixMockService)
+		service.On("Delete", mock.Anything, "tmp/a").Return(nil)
+
+		rec := serve(t, service, "/tmp/a?prefix=x", true)
+
+		assert.Equal(t, http.StatusNoContent, rec.Code)
+		service.AssertNotCalled(t, "DeletePrefix", mock.Anything, mock.Anything, mock.Anything)
+	})
+}
@@ -0,0 +1,69 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/sagarc03/stowry"
+)
+
+// DeletedHeader marks a response carrying a soft-deleted object, and
+// DeletedAtHeader holds the time it was deleted.
+const (
+	DeletedHeader   = "X-Stowry-Deleted"
+	DeletedAtHeader = "X-Stowry-Deleted-At"
+)
+
+// DeletedService is an optional Service extension for reading soft-deleted
+// objects. When the service implements it, store mode serves
+// GET /{path}?deleted=true with write authentication, so recovery tools can
+// check an object before restoring it.
+type DeletedService interface {
+	GetDeleted(ctx context.Context, path string) (stowry.MetaData, io.ReadSeekCloser, error)
+}
+
+// isDeletedRequest reports whether a GET asks for a soft-deleted object.
+func isDeletedRequest(r *http.Request) bool {
+	return r.URL.Query().Get("deleted") == "true"
+}
+
+// handleGetDeleted serves GET /{path}?deleted=true. The router only reaches it
+// after write authentication.
+func (h *Handler) handleGetDeleted(w http.ResponseWriter, r *http.Request) {
+	ds, ok := h.service.(DeletedService)
+	if !ok || h.config.Mode != stowry.ModeStore {
+		h.handleError(w, r, stowry.ErrNotSupported)
+		return
+	}
+
+	path := strings.TrimPrefix(r.URL.Path, "/")
+	if !stowry.IsValidPath(path) {
+		h.writeError(w, r, http.StatusBadRequest, "invalid_path", "Invalid path")
+		return
+	}
+
+	obj, content, err := ds.GetDeleted(r.Context(), path)
+	if err != nil {
+		if errors.Is(err, stowry.ErrNotFound) {
+			h.writeError(w, r, http.StatusNotFound, "not_found", "No deleted object at this path")
+			return
+		}
+		h.handleError(w, r, err)
+		return
+	}
+	defer func() { _ = content.Close() }()
+
+	w.Header().Set("ETag", `"`+obj.Etag+`"`)
+	w.Header().Set("Content-Type", obj.ContentType)
+	h.setContentDisposition(w, obj)
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set(DeletedHeader, "true")
+	if obj.DeletedAt != nil {
+		w.Header().Set(DeletedAtHeader, obj.DeletedAt.UTC().Format(http.TimeFormat))
+	}
+
+	serveObject(w, r, path, obj, content)
+}
@@ -0,0 +1,124 @@
+package http_test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sagarc03/stowry"
+	stowryhttp "github.com/sagarc03/stowry/http"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// deletedMockService is a MockService that can read soft-deleted objects.
+type deletedMockService struct {
+	MockService
+}
+
+func (m *deletedMockService) GetDeleted(ctx context.Context, path string) (stowry.MetaData, io.ReadSeekCloser, error) {
+	args := m.Called(ctx, path)
+	var rc io.ReadSeekCloser
+	if v := args.Get(1); v != nil {
+		rc = v.(io.ReadSeekCloser)
+	}
+	return args.Get(0).(stowry.MetaData), rc, args.Error(2)
+}
+
+func TestHandler_GetDeleted(t *testing.T) {
+	deletedAt := time.Date(2024, 1, 15, 10, 30, 0, 0, time.UTC)
+
+	serve := func(t *testing.T, mode stowry.ServerMode, service stowryhttp.Service, req *http.Request) *httptest.ResponseRecorder {
+		t.Helper()
+		// Reads are public; only the write verifier guards deleted objects
+		config := &stowryhttp.HandlerConfig{Mode: mode}
+		if mode == stowry.ModeStore {
+			config.WriteVerifier = headerVerifier{}
+		}
+		h, err := stowryhttp.New(config, service)
+		require.NoError(t, err)
+		rec := httptest.NewRecorder()
+		h.Router().ServeHTTP(rec, req)
+		return rec
+	}
+
+	authed := func(target string) *http.Request {
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		req.Header.Set("X-Test-Auth", "ok")
+		return req
+	}
+
+	t.Run("streams the deleted object", func(t *testing.T) {
+		service := new(deletedMockService)
+		service.On("GetDeleted", mock.Anything, "docs/a.txt").Return(
+			stowry.MetaData{Path: "docs/a.txt", ContentType: "text/plain", Etag: "abc", DeletedAt: &deletedAt},
+			readSeekNopCloser{strings.NewReader("old content")},
+			nil,
+		)
+
+		rec := serve(t, stowry.ModeStore, service, authed("/docs/a.txt?deleted=true"))
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "old content", rec.Body.String())
+		assert.Equal(t, "true", rec.Header().Get("X-Stowry-Deleted"))
+		assert.Equal(t, "Mon, 15 Jan 2024 10:30:00 GMT", rec.Header().Get("X-Stowry-Deleted-At"))
+		assert.Equal(t, `"abc"`, rec.Header().Get("ETag"))
+		assert.Equal(t, "no-store", rec.Header().Get("Cache-Control"))
+		service.AssertNotCalled(t, "Get", mock.Anything, mock.Anything)
+	})
+
+	t.Run("requires write authentication when reads are public", func(t *testing.T) {
+		service := new(deletedMockService)
+
+		rec := serve(t, stowry.ModeStore, service, httptest.NewRequest(http.MethodGet, "/docs/a.txt?deleted=true", nil))
+
+		assert.Equal(t, http.StatusUnauthorized, rec.Code)
+		service.AssertNotCalled(t, "GetDeleted", mock.Anything, mock.Anything)
+	})
+
+	t.Run("gone after cleanup", func(t *testing.T) {
+		service := new(deletedMockService)
+		service.On("GetDeleted", mock.Anything, "docs/a.txt").Return(stowry.MetaData{}, nil, stowry.ErrGone)
+
+		rec := serve(t, stowry.ModeStore, service, authed("/docs/a.txt?deleted=true"))
+
+		assert.Equal(t, http.StatusGone, rec.Code)
+		assert.Contains(t, rec.Body.String(), `"gone"`)
+	})
+
+	t.Run("no deleted object", func(t *testing.T) {
+		service := new(deletedMockService)
+		service.On("GetDeleted", mock.Anything, "docs/a.txt").Return(stowry.MetaData{}, nil, stowry.ErrNotFound)
+
+		rec := serve(t, stowry.ModeStore, service, authed("/docs/a.txt?deleted=true"))
+
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+	})
+
+	t.Run("service without support", func(t *testing.T) {
+		rec := serve(t, stowry.ModeStore, new(MockService), authed("/docs/a.txt?deleted=true"))
+
+		assert.Equal(t, http.StatusNotImplemented, rec.Code)
+	})
+
+	t.Run("ignored outside store mode", func(t *testing.T) {
+		service := new(deletedMockService)
+		service.On("Get", mock.Anything, "docs/a.txt").Return(
+			stowry.MetaData{Path: "docs/a.txt", ContentType: "text/plain", Etag: "abc"},
+			readSeekNopCloser{strings.NewReader("live content")},
+			nil,
+		)
+
+		rec := serve(t, stowry.ModeStatic, service, httptest.NewRequest(http.MethodGet, "/docs/a.txt?deleted=true", nil))
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "live content", rec.Body.String())
+		assert.Empty(t, rec.Header().Get("X-Stowry-Deleted"))
+		service.AssertNotCalled(t, "GetDeleted", mock.Anything, mock.Anything)
+	})
+}
@@ -0,0 +1,139 @@
+package http
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/sagarc03/stowry"
+)
+
+// DeltaContentType is the media type of a delta upload body: one line of JSON
+// holding a stowry.DeltaManifest, a newline, then the patch data. HEAD
+// responses advertise it in Accept-Patch when delta uploads are supported.
+const DeltaContentType = "application/vnd.stowry.delta"
+
+// MaxDeltaManifestSize bounds the JSON manifest line of a delta upload.
+const MaxDeltaManifestSize = 32 << 20
+
+var errManifestTooLarge = errors.New("delta manifest too large")
+
+// DeltaService is an optional Service extension for delta uploads. When the
+// service implements it, store mode serves block signatures on
+// GET /{path}?signature and accepts PATCH /{path} with a DeltaContentType body.
+type DeltaService interface {
+	Signature(ctx context.Context, path string, blockSize int) (stowry.BlockSignature, error)
+	ApplyDelta(ctx context.Context, path string, m stowry.DeltaManifest, data io.Reader) (stowry.MetaData, error)
+}
+
+// deltaService returns the service as a DeltaService if delta uploads are
+// available in the configured mode.
+func (h *Handler) deltaService() (DeltaService, bool) {
+	if h.config.Mode != stowry.ModeStore {
+		return nil, false
+	}
+	ds, ok := h.service.(DeltaService)
+	return ds, ok
+}
+
+// isSignatureRequest reports whether a GET asks for a block signature.
+func isSignatureRequest(r *http.Request) bool {
+	return r.URL.Query().Has("signature")
+}
+
+func (h *Handler) handleSignature(w http.ResponseWriter, r *http.Request, ds DeltaService, path string) {
+	blockSize := 0
+	if s := r.URL.Query().Get("block_size"); s != "" {
+		parsed, err := strconv.Atoi(s)
+		if err != nil || parsed < stowry.MinBlockSize || parsed > stowry.MaxBlockSize {
+			h.writeError(w, r, http.StatusBadRequest, "invalid_block_size",
+				"block_size must be between "+strconv.Itoa(stowry.MinBlockSize)+" and "+strconv.Itoa(stowry.MaxBlockSize))
+			return
+		}
+		blockSize = parsed
+	}
+
+	sig, err := ds.Signature(r.Context(), path, blockSize)
+	if err != nil {
+		h.handleError(w, r, err)
+		return
+	}
+
+	w.Header().Set("ETag", `"`+sig.ETag+`"`)
+	_ = WriteJSON(w, http.StatusOK, sig)
+}
+
+func (h *Handler) handlePatch(w http.ResponseWriter, r *http.Request) {
+	// Only routed when deltaService is available
+	ds, _ := h.deltaService()
+
+	path := strings.TrimPrefix(r.URL.Path, "/")
+	if path == "" || strings.HasSuffix(path, "/") || !stowry.IsValidPath(path) {
+		h.writeError(w, r, http.StatusBadRequest, "invalid_path", "Invalid path")
+		return
+	}
+
+	if ct := r.Header.Get("Content-Type"); !strings.EqualFold(strings.TrimSpace(strings.Split(ct, ";")[0]), DeltaContentType) {
+		w.Header().Set("Accept-Patch", DeltaContentType)
+		h.writeError(w, r, http.StatusUnsupportedMediaType, "unsupported_media_type", "PATCH body must be "+DeltaContentType)
+		return
+	}
+
+	body := io.Reader(r.Body)
+	if h.config.MaxUploadSize > 0 {
+		body = http.MaxBytesReader(w, r.Body, h.config.MaxUploadSize+MaxDeltaManifestSize)
+	}
+	br := bufio.NewReader(body)
+
+	line, err := readManifestLine(br)
+	if err != nil {
+		h.writeError(w, r, http.StatusBadRequest, "invalid_delta", "Could not read delta manifest: "+err.Error())
+		return
+	}
+
+	var manifest stowry.DeltaManifest
+	if err := json.Unmarshal(line, &manifest); err != nil {
+		h.writeError(w, r, http.StatusBadRequest, "invalid_delta", "Invalid delta manifest: "+err.Error())
+		return
+	}
+
+	if h.config.MaxUploadSize > 0 && manifest.Size > h.config.MaxUploadSize {
+		h.writeError(w, r, http.StatusRequestEntityTooLarge, "too_large", "Object would exceed the maximum upload size")
+		return
+	}
+
+	metaData, err := ds.ApplyDelta(r.Context(), path, manifest, br)
+	if err != nil {
+		if errors.Is(err, stowry.ErrInvalidInput) {
+			h.writeError(w, r, http.StatusBadRequest, "invalid_delta", err.Error())
+			return
+		}
+		h.handleError(w, r, err)
+		return
+	}
+
+	writeUploadResult(w, r, stowry.CreateResult{MetaData: metaData})
+}
+
+// readManifestLine reads up to and including the first newline.
+func readManifestLine(br *bufio.Reader) ([]byte, error) {
+	var line []byte
+	for {
+		chunk, err := br.ReadSlice('\n')
+		line = append(line, chunk...)
+		if len(line) > MaxDeltaManifestSize {
+			return nil, errManifestTooLarge
+		}
+		if err == nil {
+			return line, nil
+		}
+		if !errors.Is(err, bufio.ErrBufferFull) {
+			return nil, err
+		}
+	}
+}
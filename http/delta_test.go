@@ -0,0 +1,195 @@
+package http_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/sagarc03/stowry"
+	stowryhttp "github.com/sagarc03/stowry/http"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// MockDeltaService is a MockService that also implements http.DeltaService.
+type MockDeltaService struct {
+	MockService
+}
+
+func (m *MockDeltaService) Signature(ctx context.Context, path string, blockSize int) (stowry.BlockSignature, error) {
+	args := m.Called(ctx, path, blockSize)
+	return args.Get(0).(stowry.BlockSignature), args.Error(1)
+}
+
+func (m *MockDeltaService) ApplyDelta(ctx context.Context, path string, manifest stowry.DeltaManifest, data io.Reader) (stowry.MetaData, error) {
+	args := m.Called(ctx, path, manifest, data)
+	return args.Get(0).(stowry.MetaData), args.Error(1)
+}
+
+func newDeltaRouter(service stowryhttp.Service, maxUpload int64) http.Handler {
+	config := &stowryhttp.HandlerConfig{Mode: stowry.ModeStore, MaxUploadSize: maxUpload}
+	return stowryhttp.NewHandler(config, service).Router()
+}
+
+func deltaBody(t *testing.T, m stowry.DeltaManifest, data string) io.Reader {
+	t.Helper()
+	line, err := json.Marshal(m)
+	require.NoError(t, err)
+	return strings.NewReader(string(line) + "\n" + data)
+}
+
+func TestHandler_HandleHead_AcceptPatch(t *testing.T) {
+	meta := stowry.MetaData{Path: "db.sqlite", Etag: "abc", ContentType: "application/octet-stream"}
+
+	t.Run("advertised with delta service", func(t *testing.T) {
+		service := new(MockDeltaService)
+		service.On("Info", mock.Anything, "db.sqlite").Return(meta, nil)
+
+		rec := httptest.NewRecorder()
+		newDeltaRouter(service, 0).ServeHTTP(rec, httptest.NewRequest(http.MethodHead, "/db.sqlite", http.NoBody))
+
+		assert.Equal(t, stowryhttp.DeltaContentType, rec.Header().Get("Accept-Patch"))
+	})
+
+	t.Run("absent without delta service", func(t *testing.T) {
+		service := new(MockService)
+		service.On("Info", mock.Anything, "db.sqlite").Return(meta, nil)
+
+		rec := httptest.NewRecorder()
+		newDeltaRouter(service, 0).ServeHTTP(rec, httptest.NewRequest(http.MethodHead, "/db.sqlite", http.NoBody))
+
+		assert.Empty(t, rec.Header().Get("Accept-Patch"))
+	})
+}
+
+func TestHandler_HandleSignature(t *testing.T) {
+	t.Run("returns signature", func(t *testing.T) {
+		service := new(MockDeltaService)
+		sig := stowry.BlockSignature{Version: 1, ETag: "abc", Size: 5, BlockSize: 4096, Blocks: []string{"h1"}}
+		service.On("Signature", mock.Anything, "db.sqlite", 4096).Return(sig, nil)
+
+		rec := httptest.NewRecorder()
+		newDeltaRouter(service, 0).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/db.sqlite?signature&block_size=4096", http.NoBody))
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, `"abc"`, rec.Header().Get("ETag"))
+		var got stowry.BlockSignature
+		require.NoError(t, json.NewDecoder(rec.Body).Decode(&got))
+		assert.Equal(t, sig, got)
+		service.AssertNotCalled(t, "Get", mock.Anything, mock.Anything)
+	})
+
+	t.Run("invalid block size", func(t *testing.T) {
+		service := new(MockDeltaService)
+
+		rec := httptest.NewRecorder()
+		newDeltaRouter(service, 0).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/db.sqlite?signature&block_size=12", http.NoBody))
+
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		service := new(MockDeltaService)
+		service.On("Signature", mock.Anything, "missing", 0).Return(stowry.BlockSignature{}, stowry.ErrNotFound)
+
+		rec := httptest.NewRecorder()
+		newDeltaRouter(service, 0).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/missing?signature", http.NoBody))
+
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+	})
+}
+
+func TestHandler_HandlePatch(t *testing.T) {
+	manifest := stowry.DeltaManifest{BaseETag: "v1", Size: 4, Ops: []stowry.PatchOp{{Source: stowry.PatchSourceData, Length: 4}}}
+
+	t.Run("applies delta", func(t *testing.T) {
+		service := new(MockDeltaService)
+		var data string
+		service.On("ApplyDelta", mock.Anything, "db.sqlite", manifest, mock.Anything).Run(func(args mock.Arguments) {
+			b, _ := io.ReadAll(args.Get(3).(io.Reader))
+			data = string(b)
+		}).Return(stowry.MetaData{Path: "db.sqlite", Etag: "v2"}, nil)
+
+		req := httptest.NewRequest(http.MethodPatch, "/db.sqlite", deltaBody(t, manifest, "data"))
+		req.Header.Set("Content-Type", stowryhttp.DeltaContentType)
+		rec := httptest.NewRecorder()
+		newDeltaRouter(service, 0).ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "data", data)
+	})
+
+	errorTests := []struct {
+		name       string
+		err        error
+		wantStatus int
+	}{
+		{name: "precondition failed", err: fmt.Errorf("apply delta: %w", stowry.ErrPreconditionFailed), wantStatus: http.StatusPreconditionFailed},
+		{name: "invalid delta", err: fmt.Errorf("apply delta: %w: op 0", stowry.ErrInvalidInput), wantStatus: http.StatusBadRequest},
+		{name: "not found", err: stowry.ErrNotFound, wantStatus: http.StatusNotFound},
+	}
+	for _, tt := range errorTests {
+		t.Run(tt.name, func(t *testing.T) {
+			service := new(MockDeltaService)
+			service.On("ApplyDelta", mock.Anything, "db.sqlite", manifest, mock.Anything).Return(stowry.MetaData{}, tt.err)
+
+			req := httptest.NewRequest(http.MethodPatch, "/db.sqlite", deltaBody(t, manifest, "data"))
+			req.Header.Set("Content-Type", stowryhttp.DeltaContentType)
+			rec := httptest.NewRecorder()
+			newDeltaRouter(service, 0).ServeHTTP(rec, req)
+
+			assert.Equal(t, tt.wantStatus, rec.Code)
+		})
+	}
+
+	t.Run("wrong content type", func(t *testing.T) {
+		service := new(MockDeltaService)
+
+		req := httptest.NewRequest(http.MethodPatch, "/db.sqlite", deltaBody(t, manifest, "data"))
+		req.Header.Set("Content-Type", "application/json")
+		rec := httptest.NewRecorder()
+		newDeltaRouter(service, 0).ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusUnsupportedMediaType, rec.Code)
+		assert.Equal(t, stowryhttp.DeltaContentType, rec.Header().Get("Accept-Patch"))
+	})
+
+	t.Run("malformed manifest", func(t *testing.T) {
+		service := new(MockDeltaService)
+
+		req := httptest.NewRequest(http.MethodPatch, "/db.sqlite", strings.NewReader("{not json\ndata"))
+		req.Header.Set("Content-Type", stowryhttp.DeltaContentType)
+		rec := httptest.NewRecorder()
+		newDeltaRouter(service, 0).ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+	})
+
+	t.Run("result over max upload size", func(t *testing.T) {
+		service := new(MockDeltaService)
+
+		req := httptest.NewRequest(http.MethodPatch, "/db.sqlite", deltaBody(t, manifest, "data"))
+		req.Header.Set("Content-Type", stowryhttp.DeltaContentType)
+		rec := httptest.NewRecorder()
+		newDeltaRouter(service, 2).ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusRequestEntityTooLarge, rec.Code)
+	})
+
+	t.Run("not routed without delta service", func(t *testing.T) {
+		service := new(MockService)
+
+		req := httptest.NewRequest(http.MethodPatch, "/db.sqlite", deltaBody(t, manifest, "data"))
+		req.Header.Set("Content-Type", stowryhttp.DeltaContentType)
+		rec := httptest.NewRecorder()
+		newDeltaRouter(service, 0).ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusMethodNotAllowed, rec.Code)
+	})
+}
@@ -13,6 +13,7 @@
 //   - Path traversal protection
 //   - JSON error responses
 //   - Configurable CORS support
+//   - Optional response compression (gzip; br and zstd via build tags)
 //
 // # Server Modes
 //
@@ -38,9 +39,18 @@
 //	// Apply middleware (nil = public access)
 //	router.Use(http.AuthMiddleware(verifier))
 //
+// Handlers built with New also accept HandlerConfig.Authenticators, tried in
+// order ahead of the verifier on the routes it protects, so deployments can
+// sign people in with SSO while programs keep using presigned URLs. The first
+// Authenticator to accept a request yields its Principal, which must hold the
+// route's Permission (read, or write for changes and admin endpoints) and is
+// available to later middleware through PrincipalFromContext. The jwtauth
+// sub-package provides an OpenID Connect bearer token Authenticator.
+//
 // # Usage
 //
-// Create a handler with HandlerConfig:
+// Create a handler with New, which validates the HandlerConfig and returns an
+// error listing every problem found:
 //
 //	store := keybackend.NewMapSecretStore(accessKeys)
 //	cfg := stowry.AuthConfig{AWS: stowry.AWSConfig{Region: "us-east-1", Service: "s3"}}
@@ -51,12 +61,24 @@
 //	    ReadVerifier:  verifier,  // nil for public read
 //	    WriteVerifier: verifier,  // nil for public write
 //	}
-//	handler := http.NewHandler(&handlerCfg, service)
-//	router := handler.Router()
-//	http.ListenAndServe(":8080", router)
+//	handler, err := http.New(&handlerCfg, service)
+//	if err != nil {
+//	    log.Fatal(err)
+//	}
+//	http.ListenAndServe(":8080", handler.Router())
 //
 // The service parameter must implement the Service interface with Get, Create,
-// Delete, and List methods.
+// Delete, and List methods. NewHandler is kept for compatibility and skips
+// validation.
+//
+// # Options
+//
+// New accepts options for embedders that need to customize the router:
+//
+//   - WithLogger sets the logger for request errors and failed authentication
+//   - WithVerifier authenticates reads and writes with one RequestVerifier
+//   - WithMiddleware adds middleware before or after authentication
+//   - WithErrorWriter replaces the JSON error responses
 //
 // # Middleware
 //
@@ -65,5 +87,11 @@
 //	router.Use(http.AuthMiddleware(verifier))  // authenticated
 //	router.Use(http.AuthMiddleware(nil))       // public access
 //
+// CompressionMiddleware negotiates Accept-Encoding against the encoders
+// registered with RegisterEncoder. gzip is built in; building with the brotli
+// or zstd tags registers those encoders as well:
+//
+//	router.Use(http.CompressionMiddleware(http.CompressionConfig{Enabled: true}))
+//
 // Path validation is handled by individual handlers and the service layer.
 package http
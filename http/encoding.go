@@ -0,0 +1,194 @@
+package http
+
+import (
+	"compress/gzip"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sagarc03/stowry"
+)
+
+// serveObject writes content, the stored bytes of obj, with Range and
+// conditional request support. The caller sets ETag and Content-Type.
+//
+// An object uploaded with a Content-Encoding is sent as stored, with that
+// Content-Encoding, so its ETag and any Range address the encoded bytes.
+// Only a client that does not accept the encoding, such as one sending
+// "Accept-Encoding: identity", gets it decoded on the fly; that response has
+// a weak ETag and ignores Range.
+//
+// A Range request with If-Range is answered with the range only while the
+// validator still matches the object; otherwise the whole current object is
+// sent with 200, so a resumed download never mixes two versions. A Range
+// listing several ranges, or one that is malformed or past the end, is
+// answered with 416 and "Content-Range: bytes */{size}". Conditional
+// headers are evaluated before Range, so a matching If-None-Match still
+// gets 304.
+func serveObject(w http.ResponseWriter, r *http.Request, name string, obj stowry.MetaData, content io.ReadSeeker) {
+	r = applyIfRange(w, r, obj.UpdatedAt)
+
+	if obj.ContentEncoding == "" || acceptsEncoding(r, obj.ContentEncoding) {
+		rw, err := newRangeWriter(w, content)
+		if err != nil {
+			slog.Error("serve object", "path", obj.Path, "error", err)
+			writeErrorFor(w, err)
+			return
+		}
+		r = singleRange(r)
+
+		if obj.ContentEncoding == "" {
+			// ServeContent sets Content-Length from the seeker, including 0
+			// for empty objects, so HEAD and GET agree
+			http.ServeContent(rw, r, name, obj.UpdatedAt, content)
+			return
+		}
+
+		// ServeContent omits Content-Length once Content-Encoding is set, so
+		// the coding is added as the status is written
+		addVary(w.Header(), "Accept-Encoding")
+		http.ServeContent(&encodedWriter{ResponseWriter: rw, coding: obj.ContentEncoding}, r, name, obj.UpdatedAt, content)
+		return
+	}
+
+	addVary(w.Header(), "Accept-Encoding")
+
+	zr, err := gzip.NewReader(content)
+	if err != nil {
+		slog.Error("decode stored object", "path", obj.Path, "encoding", obj.ContentEncoding, "error", err)
+		writeErrorFor(w, err)
+		return
+	}
+	defer func() { _ = zr.Close() }()
+
+	etag := setDecodedHeaders(w.Header(), obj)
+	w.Header().Set("Last-Modified", obj.UpdatedAt.UTC().Format(http.TimeFormat))
+	if notModified(r, etag, obj.UpdatedAt) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	if r.Method == http.MethodHead {
+		return
+	}
+	if _, err := io.Copy(w, zr); err != nil {
+		// Headers are sent; the short body tells the client it failed
+		slog.Warn("decode stored object", "path", obj.Path, "encoding", obj.ContentEncoding, "error", err)
+	}
+}
+
+// encodedWriter sets Content-Encoding on successful responses only, so an
+// error such as 416 from ServeContent is not labelled with the coding.
+type encodedWriter struct {
+	http.ResponseWriter
+	coding      string
+	wroteHeader bool
+}
+
+func (w *encodedWriter) WriteHeader(status int) {
+	if !w.wroteHeader {
+		w.wroteHeader = true
+		if status == http.StatusOK || status == http.StatusPartialContent {
+			w.Header().Set("Content-Encoding", w.coding)
+		}
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *encodedWriter) Write(p []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	return w.ResponseWriter.Write(p)
+}
+
+func (w *encodedWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// setDecodedHeaders replaces the stored-representation headers of an encoded
+// object with those of its decoded form and returns the weak ETag it set.
+func setDecodedHeaders(h http.Header, obj stowry.MetaData) string {
+	etag := `W/"` + obj.Etag + `"`
+	h.Set("ETag", etag)
+	h.Set("Accept-Ranges", "none")
+	h.Del("Content-Length")
+	if obj.DecodedSizeBytes != nil {
+		h.Set("Content-Length", strconv.FormatInt(*obj.DecodedSizeBytes, 10))
+	}
+	return etag
+}
+
+// acceptsEncoding reports whether the request accepts content-coding. A
+// request without Accept-Encoding accepts any coding (RFC 9110 §12.5.3).
+func acceptsEncoding(r *http.Request, coding string) bool {
+	header := r.Header.Get("Accept-Encoding")
+	if header == "" {
+		return true
+	}
+	return negotiateEncoding(header, []string{coding}) != ""
+}
+
+// notModified evaluates If-None-Match, or If-Modified-Since when it is
+// absent, against etag and modTime.
+func notModified(r *http.Request, etag string, modTime time.Time) bool {
+	// If-None-Match takes precedence per RFC 7232
+	if inm := r.Header.Get("If-None-Match"); inm != "" {
+		return etagMatch(inm, etag, ETagWeak)
+	}
+	if ims := r.Header.Get("If-Modified-Since"); ims != "" {
+		if t, err := http.ParseTime(ims); err == nil {
+			return !modTime.UTC().Truncate(time.Second).After(t.Truncate(time.Second))
+		}
+	}
+	return false
+}
+
+// applyIfRange evaluates If-Range (RFC 9110 §13.1.5) against the ETag set on
+// w and modTime, and returns the request ServeContent should serve: without
+// Range when the validator does not match, so the full object is sent, and
+// without If-Range when it does, so the lenient ETag comparison used by the
+// other conditional headers is not repeated more strictly.
+func applyIfRange(w http.ResponseWriter, r *http.Request, modTime time.Time) *http.Request {
+	ifRange := r.Header.Get("If-Range")
+	if ifRange == "" {
+		return r
+	}
+
+	r = r.Clone(r.Context())
+	if !ifRangeMatches(ifRange, w.Header().Get("ETag"), modTime) {
+		r.Header.Del("Range")
+	}
+	r.Header.Del("If-Range")
+	return r
+}
+
+// ifRangeMatches reports whether the If-Range value, an entity tag or an
+// HTTP-date, matches etag or modTime. Entity tags use strong comparison, so
+// a weak tag never matches, and a date must equal the Last-Modified time.
+func ifRangeMatches(ifRange, etag string, modTime time.Time) bool {
+	if t, err := http.ParseTime(ifRange); err == nil {
+		return modTime.UTC().Truncate(time.Second).Equal(t)
+	}
+	// If-Range holds a single validator; * and lists are not allowed
+	if ifRange == "*" || strings.Contains(ifRange, ",") {
+		return false
+	}
+	return etagMatch(ifRange, etag, ETagStrong)
+}
+
+// addVary adds field to the Vary header unless it is already listed.
+func addVary(h http.Header, field string) {
+	for _, value := range h.Values("Vary") {
+		for _, listed := range strings.Split(value, ",") {
+			if strings.EqualFold(strings.TrimSpace(listed), field) {
+				return
+			}
+		}
+	}
+	h.Add("Vary", field)
+}
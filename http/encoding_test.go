@@ -0,0 +1,280 @@
+package http_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sagarc03/stowry"
+	stowryhttp "github.com/sagarc03/stowry/http"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func gzipBytes(t *testing.T, s string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	_, err := zw.Write([]byte(s))
+	require.NoError(t, err)
+	require.NoError(t, zw.Close())
+	return buf.Bytes()
+}
+
+// encodedObjectHandler serves one gzip-encoded object at /data.json.
+func encodedObjectHandler(t *testing.T, plain string) (http.Handler, []byte) {
+	t.Helper()
+	stored := gzipBytes(t, plain)
+	decoded := int64(len(plain))
+	metadata := stowry.MetaData{
+		ID:               uuid.New(),
+		Path:             "data.json",
+		ContentType:      "application/json",
+		ContentEncoding:  stowry.ContentEncodingGzip,
+		Etag:             "enc123",
+		FileSizeBytes:    int64(len(stored)),
+		DecodedSizeBytes: &decoded,
+		CreatedAt:        time.Now(),
+		UpdatedAt:        time.Now(),
+	}
+
+	service := new(MockService)
+	service.On("Get", mock.Anything, "data.json").Return(
+		metadata,
+		readSeekNopCloser{bytes.NewReader(stored)},
+		nil,
+	).Maybe()
+	service.On("Info", mock.Anything, "data.json").Return(metadata, nil).Maybe()
+	config := &stowryhttp.HandlerConfig{Mode: stowry.ModeStore}
+	return stowryhttp.NewHandler(config, service).Router(), stored
+}
+
+func TestHandler_HandleGet_EncodedPassThrough(t *testing.T) {
+	plain := strings.Repeat(`{"k":"v"}`, 50)
+
+	for _, accept := range []string{"", "gzip", "gzip, deflate, br"} {
+		t.Run("accept "+accept, func(t *testing.T) {
+			handler, stored := encodedObjectHandler(t, plain)
+
+			req := httptest.NewRequest(http.MethodGet, "/data.json", nil)
+			if accept != "" {
+				req.Header.Set("Accept-Encoding", accept)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			assert.Equal(t, http.StatusOK, rec.Code)
+			assert.Equal(t, "gzip", rec.Header().Get("Content-Encoding"))
+			assert.Equal(t, `"enc123"`, rec.Header().Get("ETag"))
+			assert.Equal(t, fmt.Sprint(len(stored)), rec.Header().Get("Content-Length"))
+			assert.Contains(t, rec.Header().Values("Vary"), "Accept-Encoding")
+			assert.Equal(t, stored, rec.Body.Bytes())
+		})
+	}
+}
+
+func TestHandler_HandleGet_EncodedRange(t *testing.T) {
+	handler, stored := encodedObjectHandler(t, strings.Repeat("abc", 100))
+
+	req := httptest.NewRequest(http.MethodGet, "/data.json", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	req.Header.Set("Range", "bytes=0-9")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusPartialContent, rec.Code)
+	assert.Equal(t, "gzip", rec.Header().Get("Content-Encoding"))
+	assert.Equal(t, stored[:10], rec.Body.Bytes())
+}
+
+func TestHandler_HandleGet_EncodedDecodedForIdentity(t *testing.T) {
+	plain := strings.Repeat(`{"k":"v"}`, 50)
+
+	for _, accept := range []string{"identity", "br", "gzip;q=0"} {
+		t.Run(accept, func(t *testing.T) {
+			handler, _ := encodedObjectHandler(t, plain)
+
+			req := httptest.NewRequest(http.MethodGet, "/data.json", nil)
+			req.Header.Set("Accept-Encoding", accept)
+			req.Header.Set("Range", "bytes=0-9")
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			assert.Equal(t, http.StatusOK, rec.Code, "Range is ignored when decoding")
+			assert.Empty(t, rec.Header().Get("Content-Encoding"))
+			assert.Equal(t, `W/"enc123"`, rec.Header().Get("ETag"))
+			assert.Equal(t, "none", rec.Header().Get("Accept-Ranges"))
+			assert.Equal(t, fmt.Sprint(len(plain)), rec.Header().Get("Content-Length"))
+			assert.Equal(t, plain, rec.Body.String())
+		})
+	}
+}
+
+func TestHandler_HandleGet_EncodedDecodedNotModified(t *testing.T) {
+	handler, _ := encodedObjectHandler(t, "hello")
+
+	req := httptest.NewRequest(http.MethodGet, "/data.json", nil)
+	req.Header.Set("Accept-Encoding", "identity")
+	req.Header.Set("If-None-Match", `W/"enc123"`)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusNotModified, rec.Code)
+	assert.Empty(t, rec.Body.String())
+}
+
+func TestHandler_HandleHead_Encoded(t *testing.T) {
+	plain := strings.Repeat("x", 300)
+
+	t.Run("pass through", func(t *testing.T) {
+		handler, stored := encodedObjectHandler(t, plain)
+
+		req := httptest.NewRequest(http.MethodHead, "/data.json", nil)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "gzip", rec.Header().Get("Content-Encoding"))
+		assert.Equal(t, `"enc123"`, rec.Header().Get("ETag"))
+		assert.Equal(t, fmt.Sprint(len(stored)), rec.Header().Get("Content-Length"))
+		assert.Empty(t, rec.Body.String())
+	})
+
+	t.Run("identity", func(t *testing.T) {
+		handler, _ := encodedObjectHandler(t, plain)
+
+		req := httptest.NewRequest(http.MethodHead, "/data.json", nil)
+		req.Header.Set("Accept-Encoding", "identity")
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Empty(t, rec.Header().Get("Content-Encoding"))
+		assert.Equal(t, `W/"enc123"`, rec.Header().Get("ETag"))
+		assert.Equal(t, "300", rec.Header().Get("Content-Length"))
+		assert.Empty(t, rec.Body.String())
+	})
+}
+
+func TestHandler_HandlePut_ContentEncoding(t *testing.T) {
+	config := &stowryhttp.HandlerConfig{Mode: stowry.ModeStore}
+
+	t.Run("recorded on create", func(t *testing.T) {
+		body := gzipBytes(t, "hello")
+		service := new(MockService)
+		service.On("Create", mock.Anything, stowry.CreateObject{
+			Path:            "a.txt",
+			ContentType:     "text/plain",
+			ContentEncoding: stowry.ContentEncodingGzip,
+			Size:            int64(len(body)),
+		}, mock.Anything).Return(stowry.MetaData{Path: "a.txt", ContentEncoding: "gzip", Etag: "e"}, nil)
+
+		req := httptest.NewRequest(http.MethodPut, "/a.txt", bytes.NewReader(body))
+		req.Header.Set("Content-Type", "text/plain")
+		req.Header.Set("Content-Encoding", "x-gzip")
+		rec := httptest.NewRecorder()
+		stowryhttp.NewHandler(config, service).Router().ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		service.AssertExpectations(t)
+	})
+
+	t.Run("unsupported coding", func(t *testing.T) {
+		service := new(MockService)
+
+		req := httptest.NewRequest(http.MethodPut, "/a.txt", strings.NewReader("hi"))
+		req.Header.Set("Content-Type", "text/plain")
+		req.Header.Set("Content-Encoding", "br")
+		rec := httptest.NewRecorder()
+		stowryhttp.NewHandler(config, service).Router().ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusUnsupportedMediaType, rec.Code)
+		assert.Contains(t, rec.Body.String(), "unsupported_content_encoding")
+		service.AssertNotCalled(t, "Create", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("invalid content", func(t *testing.T) {
+		service := new(MockService)
+		service.On("Create", mock.Anything, mock.Anything, mock.Anything).
+			Return(stowry.MetaData{}, fmt.Errorf("create object: %w", stowry.ErrInvalidEncodedContent))
+
+		req := httptest.NewRequest(http.MethodPut, "/a.txt", strings.NewReader("not gzip"))
+		req.Header.Set("Content-Type", "text/plain")
+		req.Header.Set("Content-Encoding", "gzip")
+		rec := httptest.NewRecorder()
+		stowryhttp.NewHandler(config, service).Router().ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		assert.Contains(t, rec.Body.String(), "invalid_encoded_content")
+	})
+}
+
+func TestHandler_HandleGet_IfRange(t *testing.T) {
+	updatedAt := time.Date(2025, 6, 15, 10, 30, 0, 0, time.UTC)
+	content := "0123456789abcdefghij"
+
+	tests := []struct {
+		name     string
+		ifRange  string
+		wantCode int
+		wantBody string
+	}{
+		{name: "no If-Range", wantCode: http.StatusPartialContent, wantBody: "abcdefghij"},
+		{name: "matching etag", ifRange: `"abc123"`, wantCode: http.StatusPartialContent, wantBody: "abcdefghij"},
+		{name: "matching bare etag", ifRange: "abc123", wantCode: http.StatusPartialContent, wantBody: "abcdefghij"},
+		{name: "changed etag", ifRange: `"old456"`, wantCode: http.StatusOK, wantBody: content},
+		{name: "weak etag", ifRange: `W/"abc123"`, wantCode: http.StatusOK, wantBody: content},
+		{name: "wildcard", ifRange: "*", wantCode: http.StatusOK, wantBody: content},
+		{name: "etag list", ifRange: `"abc123", "old456"`, wantCode: http.StatusOK, wantBody: content},
+		{name: "matching date", ifRange: updatedAt.Format(http.TimeFormat), wantCode: http.StatusPartialContent, wantBody: "abcdefghij"},
+		{name: "older date", ifRange: updatedAt.Add(-time.Hour).Format(http.TimeFormat), wantCode: http.StatusOK, wantBody: content},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service := new(MockService)
+			service.On("Get", mock.Anything, "test.txt").Return(stowry.MetaData{
+				ID:            uuid.New(),
+				Path:          "test.txt",
+				ContentType:   "text/plain",
+				Etag:          "abc123",
+				FileSizeBytes: int64(len(content)),
+				UpdatedAt:     updatedAt,
+			}, readSeekNopCloser{strings.NewReader(content)}, nil)
+			handler := stowryhttp.NewHandler(&stowryhttp.HandlerConfig{Mode: stowry.ModeStore}, service)
+
+			req := httptest.NewRequest(http.MethodGet, "/test.txt", nil)
+			req.Header.Set("Range", "bytes=10-")
+			if tt.ifRange != "" {
+				req.Header.Set("If-Range", tt.ifRange)
+			}
+			rec := httptest.NewRecorder()
+			handler.Router().ServeHTTP(rec, req)
+
+			assert.Equal(t, tt.wantCode, rec.Code)
+			assert.Equal(t, tt.wantBody, rec.Body.String())
+			assert.Equal(t, "bytes=10-", req.Header.Get("Range"), "the request is not modified")
+		})
+	}
+
+	t.Run("encoded object ranges address stored bytes", func(t *testing.T) {
+		handler, stored := encodedObjectHandler(t, strings.Repeat("abc", 100))
+
+		req := httptest.NewRequest(http.MethodGet, "/data.json", nil)
+		req.Header.Set("Accept-Encoding", "gzip")
+		req.Header.Set("Range", "bytes=10-")
+		req.Header.Set("If-Range", `"enc123"`)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusPartialContent, rec.Code)
+		assert.Equal(t, stored[10:], rec.Body.Bytes())
+	})
+}
@@ -4,3 +4,16 @@ import "errors"
 
 // ErrUnauthorized is returned when authentication fails.
 var ErrUnauthorized = errors.New("unauthorized")
+
+// ErrForbidden is returned when an authenticated principal lacks the
+// permission a route needs.
+var ErrForbidden = errors.New("forbidden")
+
+// ErrNoCredentials is returned by an Authenticator when the request carries
+// no credentials it handles.
+var ErrNoCredentials = errors.New("no credentials")
+
+// ErrNonCanonicalPath is returned for a signed request whose path is not in
+// canonical form, such as one with duplicate slashes. The signature covers
+// the path as sent, not the object key it normalizes to.
+var ErrNonCanonicalPath = errors.New("signed path is not canonical")
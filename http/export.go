@@ -0,0 +1,115 @@
+package http
+
+import (
+	"encoding/csv"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sagarc03/stowry"
+)
+
+// ExportStatusTrailer is the HTTP trailer that reports how a CSV list export
+// ended: "complete", "truncated" (row cap reached), or "error".
+const ExportStatusTrailer = "X-Stowry-Export-Status"
+
+// Export status values sent in ExportStatusTrailer.
+const (
+	ExportComplete  = "complete"
+	ExportTruncated = "truncated"
+	ExportError     = "error"
+)
+
+// csvPageSize is the page size used when paginating internally for an export.
+const csvPageSize = 1000
+
+var listCSVHeader = []string{"path", "size", "content_type", "etag", "created_at", "updated_at"}
+
+// listFormat returns the requested list format, "json" or "csv". ?format
+// takes precedence over the Accept header.
+func listFormat(r *http.Request) (string, bool) {
+	switch format := r.URL.Query().Get("format"); format {
+	case "csv", "json":
+		return format, true
+	case "":
+	default:
+		return "", false
+	}
+
+	for part := range strings.SplitSeq(r.Header.Get("Accept"), ",") {
+		mediaType, _, _ := strings.Cut(part, ";")
+		if strings.EqualFold(strings.TrimSpace(mediaType), "text/csv") {
+			return "csv", true
+		}
+	}
+	return "json", true
+}
+
+// handleListCSV streams every object under prefix as CSV, paginating through
+// the service so memory use stays bounded.
+//
+// The first page is fetched before the status line is written, so early
+// failures still get a normal JSON error response. After that the status is
+// committed: a later failure, or reaching ListExportMaxRows, ends the output
+// with a "# ..." comment line and sets ExportStatusTrailer accordingly, so
+// clients can detect incomplete output.
+func (h *Handler) handleListCSV(w http.ResponseWriter, r *http.Request, prefix, cursor string) {
+	ctx := r.Context()
+
+	query := stowry.ListQuery{PathPrefix: prefix, Limit: csvPageSize, Cursor: cursor}
+	page, err := h.service.List(ctx, query)
+	if err != nil {
+		h.handleError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", `attachment; filename="objects.csv"`)
+	w.Header().Set("Trailer", ExportStatusTrailer)
+	w.WriteHeader(http.StatusOK)
+
+	rc := http.NewResponseController(w)
+	cw := csv.NewWriter(w)
+	_ = cw.Write(listCSVHeader)
+
+	maxRows := h.config.ListExportMaxRows
+	rows := 0
+	status := ExportComplete
+	note := ""
+
+	for item, err := range h.listItems(ctx, query, page, true) {
+		if err != nil {
+			h.logger.Error("csv export failed", "prefix", prefix, "rows", rows, "error", err)
+			status = ExportError
+			note = fmt.Sprintf("error: listing failed after %d rows; output is incomplete", rows)
+			break
+		}
+		if maxRows > 0 && rows >= maxRows {
+			status = ExportTruncated
+			note = fmt.Sprintf("truncated: row limit %d reached", maxRows)
+			break
+		}
+		_ = cw.Write([]string{
+			item.Path,
+			strconv.FormatInt(item.FileSizeBytes, 10),
+			item.ContentType,
+			item.Etag,
+			item.CreatedAt.UTC().Format(time.RFC3339Nano),
+			item.UpdatedAt.UTC().Format(time.RFC3339Nano),
+		})
+		rows++
+
+		if rows%listFlushItems == 0 {
+			cw.Flush()
+			_ = rc.Flush()
+		}
+	}
+
+	cw.Flush()
+	if note != "" {
+		_, _ = fmt.Fprintf(w, "# %s\n", note)
+	}
+	w.Header().Set(ExportStatusTrailer, status)
+}
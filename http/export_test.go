@@ -0,0 +1,163 @@
+package http_test
+
+import (
+	"encoding/csv"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sagarc03/stowry"
+	stowryhttp "github.com/sagarc03/stowry/http"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+var exportTime = time.Date(2024, 3, 31, 12, 0, 0, 0, time.UTC)
+
+func exportItem(path string, size int64) stowry.MetaData {
+	return stowry.MetaData{
+		Path:          path,
+		ContentType:   "application/pdf",
+		Etag:          "etag-" + path,
+		FileSizeBytes: size,
+		CreatedAt:     exportTime,
+		UpdatedAt:     exportTime,
+	}
+}
+
+func exportQuery(cursor string) any {
+	return mock.MatchedBy(func(q stowry.ListQuery) bool {
+		return q.PathPrefix == "invoices/" && q.Cursor == cursor && q.Limit == 1000
+	})
+}
+
+func serveExport(t *testing.T, config *stowryhttp.HandlerConfig, service *MockService, target, accept string) *http.Response {
+	t.Helper()
+	handler := stowryhttp.NewHandler(config, service)
+
+	req := httptest.NewRequest(http.MethodGet, target, http.NoBody)
+	if accept != "" {
+		req.Header.Set("Accept", accept)
+	}
+	rec := httptest.NewRecorder()
+	handler.Router().ServeHTTP(rec, req)
+	return rec.Result()
+}
+
+func TestHandler_HandleList_CSV(t *testing.T) {
+	tests := []struct {
+		name   string
+		target string
+		accept string
+	}{
+		{name: "format parameter", target: "/?prefix=invoices/&format=csv"},
+		{name: "accept header", target: "/?prefix=invoices/", accept: "text/csv"},
+		{name: "accept header with params", target: "/?prefix=invoices/", accept: "application/json;q=0.5, text/csv; charset=utf-8"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service := new(MockService)
+			service.On("List", mock.Anything, exportQuery("")).Return(stowry.ListResult{
+				Items:      []stowry.MetaData{exportItem("invoices/a.pdf", 10)},
+				NextCursor: "page2",
+			}, nil)
+			service.On("List", mock.Anything, exportQuery("page2")).Return(stowry.ListResult{
+				Items: []stowry.MetaData{exportItem("invoices/b, \"q\"\nc.pdf", 20)},
+			}, nil)
+
+			resp := serveExport(t, &stowryhttp.HandlerConfig{Mode: stowry.ModeStore}, service, tt.target, tt.accept)
+
+			assert.Equal(t, http.StatusOK, resp.StatusCode)
+			assert.Equal(t, "text/csv; charset=utf-8", resp.Header.Get("Content-Type"))
+
+			records, err := csv.NewReader(resp.Body).ReadAll()
+			require.NoError(t, err)
+			assert.Equal(t, [][]string{
+				{"path", "size", "content_type", "etag", "created_at", "updated_at"},
+				{"invoices/a.pdf", "10", "application/pdf", "etag-invoices/a.pdf", "2024-03-31T12:00:00Z", "2024-03-31T12:00:00Z"},
+				{"invoices/b, \"q\"\nc.pdf", "20", "application/pdf", "etag-invoices/b, \"q\"\nc.pdf", "2024-03-31T12:00:00Z", "2024-03-31T12:00:00Z"},
+			}, records)
+			assert.Equal(t, stowryhttp.ExportComplete, resp.Trailer.Get(stowryhttp.ExportStatusTrailer))
+
+			service.AssertExpectations(t)
+		})
+	}
+}
+
+func TestHandler_HandleList_CSV_RowCap(t *testing.T) {
+	service := new(MockService)
+	service.On("List", mock.Anything, exportQuery("")).Return(stowry.ListResult{
+		Items:      []stowry.MetaData{exportItem("invoices/a.pdf", 1), exportItem("invoices/b.pdf", 2)},
+		NextCursor: "page2",
+	}, nil)
+	service.On("List", mock.Anything, exportQuery("page2")).Return(stowry.ListResult{
+		Items: []stowry.MetaData{exportItem("invoices/c.pdf", 3)},
+	}, nil)
+
+	config := &stowryhttp.HandlerConfig{Mode: stowry.ModeStore, ListExportMaxRows: 2}
+
+	t.Run("truncates beyond cap", func(t *testing.T) {
+		resp := serveExport(t, config, service, "/?prefix=invoices/&format=csv", "")
+
+		body := readBody(t, resp)
+		assert.Equal(t, 4, strings.Count(body, "\n"), "header, two rows, trailer comment")
+		assert.NotContains(t, body, "invoices/c.pdf")
+		assert.True(t, strings.HasSuffix(body, "# truncated: row limit 2 reached\n"))
+		assert.Equal(t, stowryhttp.ExportTruncated, resp.Trailer.Get(stowryhttp.ExportStatusTrailer))
+	})
+
+	t.Run("exactly at cap is complete", func(t *testing.T) {
+		config := &stowryhttp.HandlerConfig{Mode: stowry.ModeStore, ListExportMaxRows: 3}
+		resp := serveExport(t, config, service, "/?prefix=invoices/&format=csv", "")
+
+		body := readBody(t, resp)
+		assert.NotContains(t, body, "#")
+		assert.Equal(t, stowryhttp.ExportComplete, resp.Trailer.Get(stowryhttp.ExportStatusTrailer))
+	})
+}
+
+func TestHandler_HandleList_CSV_MidStreamError(t *testing.T) {
+	service := new(MockService)
+	service.On("List", mock.Anything, exportQuery("")).Return(stowry.ListResult{
+		Items:      []stowry.MetaData{exportItem("invoices/a.pdf", 1)},
+		NextCursor: "page2",
+	}, nil)
+	service.On("List", mock.Anything, exportQuery("page2")).Return(stowry.ListResult{}, errors.New("connection reset"))
+
+	resp := serveExport(t, &stowryhttp.HandlerConfig{Mode: stowry.ModeStore}, service, "/?prefix=invoices/&format=csv", "")
+
+	assert.Equal(t, http.StatusOK, resp.StatusCode, "status is committed once rows are streamed")
+	body := readBody(t, resp)
+	assert.Contains(t, body, "invoices/a.pdf")
+	assert.True(t, strings.HasSuffix(body, "# error: listing failed after 1 rows; output is incomplete\n"))
+	assert.Equal(t, stowryhttp.ExportError, resp.Trailer.Get(stowryhttp.ExportStatusTrailer))
+}
+
+func TestHandler_HandleList_CSV_FirstPageError(t *testing.T) {
+	service := new(MockService)
+	service.On("List", mock.Anything, exportQuery("")).Return(stowry.ListResult{}, errors.New("database down"))
+
+	resp := serveExport(t, &stowryhttp.HandlerConfig{Mode: stowry.ModeStore}, service, "/?prefix=invoices/&format=csv", "")
+
+	assert.Equal(t, http.StatusInternalServerError, resp.StatusCode)
+	assert.Equal(t, "application/json", resp.Header.Get("Content-Type"))
+}
+
+func TestHandler_HandleList_InvalidFormat(t *testing.T) {
+	resp := serveExport(t, &stowryhttp.HandlerConfig{Mode: stowry.ModeStore}, new(MockService), "/?format=xml", "")
+
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+}
+
+func readBody(t *testing.T, resp *http.Response) string {
+	t.Helper()
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	return string(body)
+}
@@ -0,0 +1,117 @@
+package http
+
+import (
+	"fmt"
+	"mime"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/sagarc03/stowry"
+)
+
+// FilenameHeader carries the original filename of an upload, percent-encoded
+// as UTF-8 when it is not plain ASCII. It takes precedence over the filename
+// parameter of a Content-Disposition request header.
+const FilenameHeader = "X-Stowry-Filename"
+
+// Content-Disposition types of downloads of objects with an original
+// filename.
+const (
+	DispositionInline     = "inline"     // Let the browser display the object
+	DispositionAttachment = "attachment" // Have the browser save the object
+)
+
+// requestFilename returns the original filename a PUT gives in
+// FilenameHeader or in a Content-Disposition header, or "" for none. The
+// service sanitizes it.
+func requestFilename(r *http.Request) (string, error) {
+	if value := r.Header.Get(FilenameHeader); value != "" {
+		name, err := url.PathUnescape(value)
+		if err != nil {
+			return "", fmt.Errorf("%w: %s is not percent-encoded", stowry.ErrInvalidFilename, FilenameHeader)
+		}
+		return name, nil
+	}
+
+	value := r.Header.Get("Content-Disposition")
+	if value == "" {
+		return "", nil
+	}
+	// ParseMediaType decodes RFC 5987 filename* and prefers it to filename
+	_, params, err := mime.ParseMediaType(value)
+	if err != nil {
+		return "", fmt.Errorf("%w: malformed Content-Disposition", stowry.ErrInvalidFilename)
+	}
+	return params["filename"], nil
+}
+
+// setContentDisposition names the download after the object's original
+// filename. Objects without one get no Content-Disposition.
+func (h *Handler) setContentDisposition(w http.ResponseWriter, obj stowry.MetaData) {
+	if obj.Filename == "" {
+		return
+	}
+	disposition := h.config.ContentDisposition
+	if disposition == "" {
+		disposition = DispositionInline
+	}
+	w.Header().Set("Content-Disposition", contentDisposition(disposition, obj.Filename))
+}
+
+// contentDisposition formats a Content-Disposition value for filename. A
+// name that is not plain ASCII is sent as an RFC 5987 filename* with an
+// ASCII filename fallback for clients that do not support it.
+func contentDisposition(disposition, filename string) string {
+	if isPlainASCII(filename) {
+		return disposition + `; filename="` + quoteEscaper.Replace(filename) + `"`
+	}
+
+	fallback := strings.Map(func(r rune) rune {
+		if r < 0x20 || r >= 0x7f {
+			return '_'
+		}
+		return r
+	}, filename)
+	return disposition + `; filename="` + quoteEscaper.Replace(fallback) + `"; filename*=UTF-8''` + encodeRFC5987(filename)
+}
+
+var quoteEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`)
+
+func isPlainASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] < 0x20 || s[i] >= 0x7f {
+			return false
+		}
+	}
+	return true
+}
+
+// encodeRFC5987 percent-encodes the UTF-8 bytes of s outside the RFC 5987
+// attr-char set.
+func encodeRFC5987(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if isAttrChar(c) {
+			b.WriteByte(c)
+			continue
+		}
+		fmt.Fprintf(&b, "%%%02X", c)
+	}
+	return b.String()
+}
+
+func isAttrChar(c byte) bool {
+	switch {
+	case 'a' <= c && c <= 'z', 'A' <= c && c <= 'Z', '0' <= c && c <= '9':
+		return true
+	}
+	return strings.IndexByte("!#$&+-.^_`|~", c) >= 0
+}
+
+// validDisposition reports whether d is a Content-Disposition type the
+// handler can be configured with.
+func validDisposition(d string) bool {
+	return d == "" || d == DispositionInline || d == DispositionAttachment
+}
@@ -0,0 +1,164 @@
+package http_test
+
+import (
+	"bytes"
+	"fmt"
+	"mime"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sagarc03/stowry"
+	stowryhttp "github.com/sagarc03/stowry/http"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHandler_HandlePut_Filename(t *testing.T) {
+	config := &stowryhttp.HandlerConfig{Mode: stowry.ModeStore}
+
+	tests := []struct {
+		name   string
+		header string
+		value  string
+		want   string
+	}{
+		{name: "none", want: ""},
+		{name: "stowry header", header: stowryhttp.FilenameHeader, value: "Q3%20report.pdf", want: "Q3 report.pdf"},
+		{name: "stowry header utf-8", header: stowryhttp.FilenameHeader, value: "r%C3%A9sum%C3%A9.pdf", want: "résumé.pdf"},
+		{name: "content disposition", header: "Content-Disposition", value: `attachment; filename="invoice.pdf"`, want: "invoice.pdf"},
+		{name: "content disposition rfc 5987", header: "Content-Disposition", value: `attachment; filename="resume.pdf"; filename*=UTF-8''r%C3%A9sum%C3%A9.pdf`, want: "résumé.pdf"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service := new(MockService)
+			service.On("Create", mock.Anything, stowry.CreateObject{
+				Path:        "uploads/3f2a.pdf",
+				ContentType: "application/pdf",
+				Size:        4,
+				Filename:    tt.want,
+			}, mock.Anything).Return(stowry.MetaData{Path: "uploads/3f2a.pdf", Filename: tt.want, Etag: "e"}, nil)
+
+			req := httptest.NewRequest(http.MethodPut, "/uploads/3f2a.pdf", strings.NewReader("data"))
+			req.Header.Set("Content-Type", "application/pdf")
+			if tt.header != "" {
+				req.Header.Set(tt.header, tt.value)
+			}
+			rec := httptest.NewRecorder()
+			stowryhttp.NewHandler(config, service).Router().ServeHTTP(rec, req)
+
+			require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+			service.AssertExpectations(t)
+		})
+	}
+
+	t.Run("stowry header wins", func(t *testing.T) {
+		service := new(MockService)
+		service.On("Create", mock.Anything, mock.MatchedBy(func(obj stowry.CreateObject) bool {
+			return obj.Filename == "a.pdf"
+		}), mock.Anything).Return(stowry.MetaData{Path: "x.pdf", Etag: "e"}, nil)
+
+		req := httptest.NewRequest(http.MethodPut, "/x.pdf", strings.NewReader("data"))
+		req.Header.Set(stowryhttp.FilenameHeader, "a.pdf")
+		req.Header.Set("Content-Disposition", `attachment; filename="b.pdf"`)
+		rec := httptest.NewRecorder()
+		stowryhttp.NewHandler(config, service).Router().ServeHTTP(rec, req)
+
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		service.AssertExpectations(t)
+	})
+
+	for name, header := range map[string][2]string{
+		"bad percent-encoding":  {stowryhttp.FilenameHeader, "50%zz.pdf"},
+		"malformed disposition": {"Content-Disposition", `attachment; filename="unterminated`},
+		"too long":              {stowryhttp.FilenameHeader, strings.Repeat("a", stowry.MaxFilenameBytes+1)},
+	} {
+		t.Run(name, func(t *testing.T) {
+			service := new(MockService)
+			service.On("Create", mock.Anything, mock.Anything, mock.Anything).
+				Return(stowry.MetaData{}, fmt.Errorf("create object: %w", stowry.ErrInvalidFilename)).Maybe()
+
+			req := httptest.NewRequest(http.MethodPut, "/x.pdf", strings.NewReader("data"))
+			req.Header.Set(header[0], header[1])
+			rec := httptest.NewRecorder()
+			stowryhttp.NewHandler(config, service).Router().ServeHTTP(rec, req)
+
+			assert.Equal(t, http.StatusBadRequest, rec.Code)
+			assert.Contains(t, rec.Body.String(), "invalid_filename")
+		})
+	}
+}
+
+// filenameObjectHandler serves one object at /uploads/3f2a.bin uploaded
+// as filename.
+func filenameObjectHandler(t *testing.T, filename, disposition string) http.Handler {
+	t.Helper()
+	metadata := stowry.MetaData{
+		ID:            uuid.New(),
+		Path:          "uploads/3f2a.bin",
+		ContentType:   "application/octet-stream",
+		Etag:          "abc123",
+		FileSizeBytes: 4,
+		Filename:      filename,
+		CreatedAt:     time.Now(),
+		UpdatedAt:     time.Now(),
+	}
+
+	service := new(MockService)
+	service.On("Get", mock.Anything, "uploads/3f2a.bin").Return(
+		metadata,
+		readSeekNopCloser{bytes.NewReader([]byte("data"))},
+		nil,
+	).Maybe()
+	service.On("Info", mock.Anything, "uploads/3f2a.bin").Return(metadata, nil).Maybe()
+
+	config := &stowryhttp.HandlerConfig{Mode: stowry.ModeStore, ContentDisposition: disposition}
+	return stowryhttp.NewHandler(config, service).Router()
+}
+
+func TestHandler_ContentDisposition(t *testing.T) {
+	tests := []struct {
+		name        string
+		filename    string
+		disposition string
+		want        string
+	}{
+		{name: "no filename", want: ""},
+		{name: "inline by default", filename: "report.pdf", want: `inline; filename="report.pdf"`},
+		{name: "attachment", filename: "report.pdf", disposition: stowryhttp.DispositionAttachment, want: `attachment; filename="report.pdf"`},
+		{name: "quotes escaped", filename: `say "hi".txt`, want: `inline; filename="say \"hi\".txt"`},
+		{
+			name:     "non-ascii uses rfc 5987",
+			filename: "résumé 履歴.pdf",
+			want:     `inline; filename="r_sum_ __.pdf"; filename*=UTF-8''r%C3%A9sum%C3%A9%20%E5%B1%A5%E6%AD%B4.pdf`,
+		},
+	}
+	for _, tt := range tests {
+		for _, method := range []string{http.MethodGet, http.MethodHead} {
+			t.Run(tt.name+" "+method, func(t *testing.T) {
+				handler := filenameObjectHandler(t, tt.filename, tt.disposition)
+
+				rec := httptest.NewRecorder()
+				handler.ServeHTTP(rec, httptest.NewRequest(method, "/uploads/3f2a.bin", nil))
+
+				assert.Equal(t, http.StatusOK, rec.Code)
+				assert.Equal(t, tt.want, rec.Header().Get("Content-Disposition"))
+				if tt.want == "" {
+					return
+				}
+				_, params, err := mime.ParseMediaType(rec.Header().Get("Content-Disposition"))
+				require.NoError(t, err)
+				assert.Equal(t, tt.filename, params["filename"], "clients decode the original name")
+			})
+		}
+	}
+}
+
+func TestHandlerConfig_Validate_ContentDisposition(t *testing.T) {
+	config := &stowryhttp.HandlerConfig{Mode: stowry.ModeStore, ContentDisposition: "download"}
+	assert.ErrorContains(t, config.Validate(), "content disposition")
+}
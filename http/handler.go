@@ -5,20 +5,24 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"log/slog"
 	"net/http"
+	"net/url"
+	"slices"
 	"strconv"
 	"strings"
 	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/cors"
+	"github.com/google/uuid"
 	"github.com/sagarc03/stowry"
 )
 
 type Service interface {
 	Get(ctx context.Context, path string) (stowry.MetaData, io.ReadSeekCloser, error)
 	Info(ctx context.Context, path string) (stowry.MetaData, error)
-	Create(ctx context.Context, obj stowry.CreateObject, content io.Reader) (stowry.MetaData, error)
+	Create(ctx context.Context, obj stowry.CreateObject, content io.Reader) (stowry.CreateResult, error)
 	Delete(ctx context.Context, path string) error
 	List(ctx context.Context, query stowry.ListQuery) (stowry.ListResult, error)
 }
@@ -37,23 +41,287 @@ type HandlerConfig struct {
 	Mode          stowry.ServerMode
 	ReadVerifier  RequestVerifier
 	WriteVerifier RequestVerifier
-	CORS          CORSConfig
-	MaxUploadSize int64  // Maximum upload size in bytes. 0 means no limit.
-	ErrorDocument string // Path to custom error page in storage. Empty uses default.
+	// Authenticators are tried in order, before ReadVerifier or
+	// WriteVerifier, on the routes those protect; the first to accept a
+	// request authenticates it, and the principal must hold the route's
+	// permission. They do not make public routes private.
+	Authenticators []Authenticator
+	CORS           CORSConfig
+	Compression    CompressionConfig
+	// SecurityHeaders sets X-Content-Type-Options, Content-Security-Policy
+	// and related headers; see SecurityHeadersConfig for where each applies.
+	SecurityHeaders SecurityHeadersConfig
+	MaxUploadSize   int64  // Maximum upload size in bytes. 0 means no limit.
+	ErrorDocument   string // Path to custom error page in storage. Empty uses default.
+	// WriteLimiter bounds concurrent uploads; ReadLimiter bounds concurrent
+	// GET, HEAD and list requests. nil means unlimited.
+	WriteLimiter *ConcurrencyLimiter
+	ReadLimiter  *ConcurrencyLimiter
+	// ListExportMaxRows caps the rows of a CSV list export. 0 means no cap.
+	ListExportMaxRows int
+	// MaxDeletePaths caps the paths of a POST /?delete bulk delete. 0 uses
+	// stowry.DefaultMaxDeletePaths.
+	MaxDeletePaths int
+	// PurgeVerifier authenticates DELETE /{path}?purge, after the
+	// Authenticators, requiring write permission even when writes are
+	// public. nil uses WriteVerifier.
+	PurgeVerifier RequestVerifier
+	// AllowPublicPurge serves DELETE /{path}?purge without authentication
+	// when neither PurgeVerifier nor WriteVerifier is set. By default such
+	// purges are refused with 401.
+	AllowPublicPurge bool
+	// DisableList answers GET / listing, JSON and CSV, and the change feed
+	// with 403 list_disabled in store mode, before authentication so every
+	// client gets the same answer. The capabilities stay available.
+	DisableList bool
+	// CachePolicy sets Cache-Control on files served in static and SPA modes.
+	// nil leaves Cache-Control unset.
+	CachePolicy *CachePolicy
+	// CacheRules set Cache-Control by path in every mode, ahead of
+	// CachePolicy. An object uploaded with its own Cache-Control keeps it.
+	// nil means none.
+	CacheRules *CacheRules
+	// Precompressed serves a stored foo.js.br or foo.js.gz in place of
+	// foo.js to clients accepting that coding, in static and SPA modes, when
+	// the service implements PrecompressedService.
+	Precompressed bool
+	// Tracing wraps every request in an OpenTelemetry server span.
+	Tracing bool
+	// Redirects are evaluated before the object lookup in static and SPA
+	// modes, ahead of any rules in RedirectsFile. nil means none.
+	Redirects *Redirects
+	// RedirectsFile names an object holding _redirects-format rules, reloaded
+	// when its ETag changes and never served itself. Empty disables it.
+	RedirectsFile string
+	// RedirectsReloadInterval is how often RedirectsFile's ETag is checked.
+	// 0 uses 5 seconds.
+	RedirectsReloadInterval time.Duration
+	// Presigner serves POST /presign in store mode. nil disables the endpoint.
+	Presigner *Presigner
+	// MaxWatchTimeout caps how long GET /?watch waits for changes. 0 uses
+	// DefaultMaxWatchTimeout.
+	MaxWatchTimeout time.Duration
+	// Backup serves POST /admin/backup in store mode, authenticated as a
+	// write. nil disables the endpoint.
+	Backup Backuper
+	// SupportBundle serves GET /admin/support-bundle in store mode,
+	// authenticated as a write. nil disables the endpoint.
+	SupportBundle SupportBundler
+	// Maintenance serves the admin job endpoints in store mode, authenticated
+	// as a write: POST /admin/populate, /admin/cleanup and /admin/verify, and
+	// GET and DELETE /admin/jobs/{id}. nil disables them.
+	Maintenance MaintenanceService
+	// Jobs tracks the admin jobs. nil uses a JobManager whose jobs run until
+	// they finish or are canceled.
+	Jobs *JobManager
+	// Latency records per-route request latency. Its snapshot is served
+	// separately, not by Router. nil disables recording.
+	Latency *LatencyTracker
+	// Metrics records Prometheus metrics for every request. nil disables
+	// recording.
+	Metrics *Metrics
+	// MetricsPath serves Metrics on the handler's own routes, to clients with
+	// read access. Empty leaves them to be served separately.
+	MetricsPath string
+	// SPA sets what SPA mode serves while index.html does not exist, and
+	// the paths that never fall back to it.
+	SPA SPAConfig
+	// SiteFiles sets the built-in /robots.txt and /favicon.ico of static and
+	// SPA modes, served when no object exists at those keys.
+	SiteFiles SiteFilesConfig
+	// ContentDisposition is the Content-Disposition type, DispositionInline
+	// or DispositionAttachment, of downloads of objects uploaded with an
+	// original filename. Empty uses DispositionInline.
+	ContentDisposition string
+	// IfMatchComparison is how a PUT's If-Match is compared with the stored
+	// ETag, ETagStrong or ETagWeak. ETagWeak accepts a W/ ETag, as proxies
+	// that re-encode responses send back. Empty uses ETagStrong.
+	IfMatchComparison string
+	// AccessKeys is told the access key of every request a signature or the
+	// presigner authenticates, and serves GET /admin/keys in store mode,
+	// authenticated as a write. nil disables both.
+	AccessKeys AccessKeyTracker
+	// AccessTracker counts a download of every GET that sends a whole
+	// object with 200; 304s, ranges and HEAD are not downloads. nil
+	// disables tracking.
+	AccessTracker *stowry.AccessTracker
+	// Version is the server version reported by the capability document.
+	// Empty omits it.
+	Version string
+	// Region identifies this server's region in multi-region deployments. It
+	// is sent as X-Stowry-Region with every response and in the capability
+	// document, and requests whose X-Stowry-Expect-Region names another
+	// region are answered 421 Misdirected Request. Empty disables both.
+	Region string
 }
 
 // Handler provides HTTP handlers for object storage operations.
 type Handler struct {
-	config  HandlerConfig
-	service Service
+	config        HandlerConfig
+	service       Service
+	redirectsFile *redirectsFile
+	jobs          *JobManager
+	logger        *slog.Logger
+	errorWriter   ErrorWriter
+	beforeAuth    []func(http.Handler) http.Handler
+	afterAuth     []func(http.Handler) http.Handler
+	features      []string
+}
+
+// New creates a Handler with the given configuration and service. Options
+// are applied before the configuration is validated, and every problem
+// found is reported in the returned error.
+func New(config *HandlerConfig, service Service, opts ...Option) (*Handler, error) {
+	if config == nil {
+		return nil, errors.New("handler config is required")
+	}
+	if service == nil {
+		return nil, errors.New("service is required")
+	}
+
+	h := newHandler(config, service)
+	for _, opt := range opts {
+		opt(h)
+	}
+	if err := h.config.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid handler config: %w", err)
+	}
+	if h.redirectsFile != nil {
+		h.redirectsFile.logger = h.logger
+	}
+	return h, nil
 }
 
 // NewHandler creates a new Handler with the given configuration and service.
+//
+// Deprecated: Use New, which validates the configuration and accepts options.
 func NewHandler(config *HandlerConfig, service Service) *Handler {
-	return &Handler{
+	return newHandler(config, service)
+}
+
+func newHandler(config *HandlerConfig, service Service) *Handler {
+	h := &Handler{
 		config:  *config,
 		service: service,
+		logger:  slog.Default(),
 	}
+	if config.Mode != stowry.ModeStore && config.RedirectsFile != "" {
+		interval := config.RedirectsReloadInterval
+		if interval <= 0 {
+			interval = defaultRedirectsReloadInterval
+		}
+		h.redirectsFile = &redirectsFile{name: config.RedirectsFile, interval: interval, logger: h.logger}
+	}
+	if config.Maintenance != nil {
+		h.jobs = config.Jobs
+		if h.jobs == nil {
+			h.jobs = NewJobManager(context.Background())
+		}
+	}
+	return h
+}
+
+// Validate checks the configuration for settings that cannot work together
+// and returns every problem found, joined.
+func (c *HandlerConfig) Validate() error {
+	var errs []error
+
+	if !c.Mode.IsValid() {
+		errs = append(errs, fmt.Errorf("invalid mode %q (valid modes: store, static, spa)", c.Mode))
+	}
+	if c.MaxUploadSize < 0 {
+		errs = append(errs, errors.New("max upload size must not be negative"))
+	}
+	if c.ListExportMaxRows < 0 {
+		errs = append(errs, errors.New("list export max rows must not be negative"))
+	}
+	if c.MaxDeletePaths < 0 {
+		errs = append(errs, errors.New("max delete paths must not be negative"))
+	}
+	if c.MaxWatchTimeout < 0 {
+		errs = append(errs, errors.New("max watch timeout must not be negative"))
+	}
+	if c.RedirectsReloadInterval < 0 {
+		errs = append(errs, errors.New("redirects reload interval must not be negative"))
+	}
+	if !validDisposition(c.ContentDisposition) {
+		errs = append(errs, fmt.Errorf("invalid content disposition %q (valid: inline, attachment)", c.ContentDisposition))
+	}
+	if !validETagComparison(c.IfMatchComparison) {
+		errs = append(errs, fmt.Errorf("invalid if-match comparison %q (valid: strong, weak)", c.IfMatchComparison))
+	}
+	if err := validateRegion(c.Region); err != nil {
+		errs = append(errs, err)
+	}
+
+	if c.Mode != stowry.ModeStore {
+		if c.WriteVerifier != nil {
+			errs = append(errs, fmt.Errorf("write verifier is set but %s mode does not accept writes", c.Mode))
+		}
+		if c.Presigner != nil {
+			errs = append(errs, fmt.Errorf("presigner is set but POST /presign is only served in store mode, not %s", c.Mode))
+		}
+	}
+
+	for _, v := range []struct {
+		name     string
+		verifier RequestVerifier
+	}{
+		{"read verifier", c.ReadVerifier},
+		{"write verifier", c.WriteVerifier},
+	} {
+		if validator, ok := v.verifier.(interface{ Validate() error }); ok {
+			if err := validator.Validate(); err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", v.name, err))
+			}
+		}
+	}
+	for i, a := range c.Authenticators {
+		if a == nil {
+			errs = append(errs, fmt.Errorf("authenticator %d is nil", i))
+			continue
+		}
+		if validator, ok := a.(interface{ Validate() error }); ok {
+			if err := validator.Validate(); err != nil {
+				errs = append(errs, fmt.Errorf("authenticator %d: %w", i, err))
+			}
+		}
+	}
+
+	if c.CORS.Enabled {
+		if len(c.CORS.AllowedOrigins) == 0 {
+			errs = append(errs, errors.New("cors: allowed origins must not be empty when cors is enabled"))
+		}
+		if c.CORS.AllowCredentials && slices.Contains(c.CORS.AllowedOrigins, "*") {
+			errs = append(errs, errors.New("cors: allow credentials cannot be used with the \"*\" origin"))
+		}
+	}
+
+	if c.MetricsPath != "" {
+		if c.Metrics == nil {
+			errs = append(errs, errors.New("metrics path is set without metrics"))
+		} else if !strings.HasPrefix(c.MetricsPath, "/") || c.MetricsPath == "/" {
+			errs = append(errs, fmt.Errorf("metrics path %q must start with / and name a path", c.MetricsPath))
+		}
+	}
+
+	if c.Compression.Enabled {
+		if err := c.Compression.Validate(); err != nil {
+			errs = append(errs, fmt.Errorf("compression: %w", err))
+		}
+	}
+
+	if err := c.SecurityHeaders.Validate(); err != nil {
+		errs = append(errs, err)
+	}
+	if err := c.SPA.Validate(); err != nil {
+		errs = append(errs, err)
+	}
+	if err := c.SiteFiles.Validate(); err != nil {
+		errs = append(errs, err)
+	}
+
+	return errors.Join(errs...)
 }
 
 // Router returns an http.Handler with routes configured based on mode.
@@ -61,6 +329,25 @@ func NewHandler(config *HandlerConfig, service Service) *Handler {
 // In static/SPA modes, GET / is handled by the get handler (serves index.html via service).
 func (h *Handler) Router() http.Handler {
 	r := chi.NewRouter()
+	if h.config.Region != "" {
+		// First, so redirects and errors from any middleware carry it
+		r.Use(h.regionHeaderMiddleware)
+	}
+	r.Use(canonicalPathMiddleware)
+
+	if h.config.Latency != nil {
+		r.Use(h.config.Latency.Middleware)
+	}
+
+	if h.config.Metrics != nil {
+		r.Use(h.config.Metrics.Middleware(h.config.Mode))
+	}
+
+	if h.config.Tracing {
+		r.Use(TracingMiddleware)
+	}
+
+	r.Use(h.securityHeadersMiddleware())
 
 	if h.config.CORS.Enabled {
 		r.Use(cors.Handler(cors.Options{
@@ -73,27 +360,213 @@ func (h *Handler) Router() http.Handler {
 		}))
 	}
 
+	if h.config.Region != "" {
+		r.Use(h.expectRegionMiddleware)
+	}
+
+	if h.config.Compression.Enabled {
+		r.Use(CompressionMiddleware(h.config.Compression))
+	}
+
+	r.Use(h.beforeAuth...)
+
+	if h.config.Mode == stowry.ModeStore && h.config.DisableList {
+		r.Use(h.listDisabledMiddleware)
+	}
+
 	r.Group(func(r chi.Router) {
-		r.Use(AuthMiddleware(h.config.ReadVerifier))
+		r.Use(h.authMiddleware(h.config.ReadVerifier, PermissionRead))
+		r.Use(h.afterAuth...)
+		r.Get(CapabilitiesPath, h.handleCapabilities)
+		if h.config.MetricsPath != "" {
+			r.Get(h.config.MetricsPath, h.config.Metrics.ServeHTTP)
+		}
 		if h.config.Mode == stowry.ModeStore {
-			r.Get("/", h.handleList)
+			// A watch can wait for a minute, so it does not hold a read slot
+			list := h.config.ReadLimiter.Middleware(http.HandlerFunc(h.handleList))
+			// Deleted objects are only for writers, even when reads are public
+			listDeleted := h.authMiddleware(h.config.WriteVerifier, PermissionWrite)(h.config.ReadLimiter.Middleware(http.HandlerFunc(h.handleListDeleted)))
+			r.Get("/", func(w http.ResponseWriter, r *http.Request) {
+				if isWatchRequest(r) {
+					h.handleWatch(w, r)
+					return
+				}
+				if isCapabilitiesRequest(r) {
+					h.handleCapabilities(w, r)
+					return
+				}
+				if isDeletedRequest(r) {
+					listDeleted.ServeHTTP(w, r)
+					return
+				}
+				list.ServeHTTP(w, r)
+			})
+			_, hasStat := h.statService()
+			if _, ok := h.deleteManyService(); ok {
+				// Deleting needs write authentication, even when reads are public
+				deleteMany := h.authMiddleware(h.config.WriteVerifier, PermissionWrite)(h.config.WriteLimiter.Middleware(http.HandlerFunc(h.handleDeleteMany)))
+				stat := h.config.ReadLimiter.Middleware(http.HandlerFunc(h.handleStat))
+				r.Post("/", func(w http.ResponseWriter, r *http.Request) {
+					if isDeleteRequest(r) {
+						deleteMany.ServeHTTP(w, r)
+						return
+					}
+					stat.ServeHTTP(w, r)
+				})
+			} else if hasStat {
+				r.With(h.config.ReadLimiter.Middleware).Post("/", h.handleStat)
+			}
+			if _, ok := h.permalinkService(); ok {
+				r.With(h.config.ReadLimiter.Middleware).Get("/"+stowry.ETagPrefix+"{etag}", h.handleGetByETag)
+				r.With(h.config.ReadLimiter.Middleware).Head("/"+stowry.ETagPrefix+"{etag}", h.handleGetByETag)
+			}
 		}
-		r.Get("/*", h.handleGet)
-		r.Head("/*", h.handleHead)
+		get := h.config.ReadLimiter.Middleware(http.HandlerFunc(h.handleGet))
+		// Deleted objects are only for writers, even when reads are public
+		deleted := h.authMiddleware(h.config.WriteVerifier, PermissionWrite)(h.config.ReadLimiter.Middleware(http.HandlerFunc(h.handleGetDeleted)))
+		history := h.authMiddleware(h.config.WriteVerifier, PermissionWrite)(h.config.ReadLimiter.Middleware(http.HandlerFunc(h.handleHistory)))
+		_, hasHistory := h.historyService()
+		r.Get("/*", func(w http.ResponseWriter, r *http.Request) {
+			if h.config.Mode == stowry.ModeStore && isDeletedRequest(r) {
+				deleted.ServeHTTP(w, r)
+				return
+			}
+			if hasHistory && isHistoryRequest(r) {
+				history.ServeHTTP(w, r)
+				return
+			}
+			get.ServeHTTP(w, r)
+		})
+		r.With(h.config.ReadLimiter.Middleware).Head("/*", h.handleHead)
 	})
 
 	if h.config.Mode == stowry.ModeStore {
 		r.Group(func(r chi.Router) {
-			r.Use(AuthMiddleware(h.config.WriteVerifier))
-			r.Put("/*", h.handlePut)
-			r.Delete("/*", h.handleDelete)
+			r.Use(h.authMiddleware(h.config.WriteVerifier, PermissionWrite))
+			r.Use(h.afterAuth...)
+			_, multipart := h.multipartService()
+			if multipart {
+				r.With(h.config.WriteLimiter.Middleware).Put("/*", func(w http.ResponseWriter, r *http.Request) {
+					if isUploadPartRequest(r) {
+						h.handleUploadPart(w, r)
+						return
+					}
+					h.handlePut(w, r)
+				})
+			} else {
+				r.With(h.config.WriteLimiter.Middleware).Put("/*", h.handlePut)
+			}
+			if _, ok := h.deletePrefixService(); ok {
+				r.With(h.config.WriteLimiter.Middleware).Delete("/", h.handleDeletePrefix)
+			}
+			// Purging needs authentication, even when writes are public
+			purge := h.purgeAuthMiddleware()(http.HandlerFunc(h.handlePurge))
+			r.Delete("/*", func(w http.ResponseWriter, r *http.Request) {
+				if isPurgeRequest(r) {
+					purge.ServeHTTP(w, r)
+					return
+				}
+				if multipart && isMultipartRequest(r) {
+					h.handleAbortMultipart(w, r)
+					return
+				}
+				h.handleDelete(w, r)
+			})
+			_, move := h.moveService()
+			if _, restore := h.restoreService(); multipart || move || restore {
+				r.With(h.config.WriteLimiter.Middleware).Post("/*", h.handlePostObject)
+			}
+			if _, ok := h.deltaService(); ok {
+				r.With(h.config.WriteLimiter.Middleware).Patch("/*", h.handlePatch)
+			}
+			if _, ok := h.stageService(); ok {
+				r.Post("/stage", h.handleCreateStage)
+				r.Post("/stage/{id}/commit", h.handleCommitStage)
+				r.Delete("/stage/{id}", h.handleDiscardStage)
+			}
+			if h.config.Backup != nil {
+				r.Post("/admin/backup", h.handleBackup)
+			}
+			if h.config.SupportBundle != nil {
+				r.Get("/admin/support-bundle", h.handleSupportBundle)
+			}
+			if h.jobs != nil {
+				r.Post("/admin/populate", h.handlePopulate)
+				r.Post("/admin/cleanup", h.handleCleanup)
+				r.Post("/admin/verify", h.handleVerify)
+				r.Get("/admin/jobs/{id}", h.handleGetJob)
+				r.Delete("/admin/jobs/{id}", h.handleCancelJob)
+			}
+			if _, ok := h.usageService(); ok {
+				r.Post("/admin/usage/snapshots", h.handleTakeUsageSnapshot)
+				r.Get("/admin/usage/snapshots", h.handleListUsageSnapshots)
+				r.Get("/admin/usage/snapshot", h.handleGetUsageSnapshot)
+				r.Get("/admin/usage/report", h.handleUsageReport)
+			}
+			if _, ok := h.backendUsageService(); ok {
+				r.Get("/admin/usage/backends", h.handleBackendUsage)
+			}
+			if h.config.AccessKeys != nil {
+				r.Get("/admin/keys", h.handleListAccessKeys)
+			}
 		})
+
+		// Authenticated by the presigner itself with Basic credentials
+		if h.config.Presigner != nil {
+			r.Post("/presign", h.handlePresign)
+		}
 	}
 
 	return r
 }
 
+// listDisabledMiddleware answers GET / listing and the change feed with 403
+// list_disabled when HandlerConfig.DisableList is set; the feed replays the
+// path of every retained change, so it would list the objects too. It runs
+// ahead of authentication, so whether listing exists does not depend on who
+// asks.
+func (h *Handler) listDisabledMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet && r.URL.Path == "/" && !isCapabilitiesRequest(r) {
+			h.writeError(w, r, http.StatusForbidden, "list_disabled", "listing is disabled on this server")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
 func (h *Handler) handleList(w http.ResponseWriter, r *http.Request) {
+	query, ok := h.listQuery(w, r)
+	if !ok {
+		return
+	}
+
+	format, ok := listFormat(r)
+	if !ok {
+		h.writeError(w, r, http.StatusBadRequest, "invalid_parameter", "format must be json or csv")
+		return
+	}
+	if format == "csv" {
+		h.handleListCSV(w, r, query.PathPrefix, query.Cursor)
+		return
+	}
+
+	result, err := h.service.List(r.Context(), query)
+	if err != nil {
+		h.handleError(w, r, err)
+		return
+	}
+	h.trimListItems(r, result.Items)
+
+	if err := writeListJSON(w, h.listItems(r.Context(), query, result, false), result.NextCursor); err != nil {
+		h.logger.Error("list response failed", "prefix", query.PathPrefix, "error", err)
+		panic(http.ErrAbortHandler)
+	}
+}
+
+// listQuery parses the prefix, limit and cursor parameters of a list
+// request, writing a 400 response when they are invalid.
+func (h *Handler) listQuery(w http.ResponseWriter, r *http.Request) (stowry.ListQuery, bool) {
 	prefix := r.URL.Query().Get("prefix")
 	limitStr := r.URL.Query().Get("limit")
 	cursor := r.URL.Query().Get("cursor")
@@ -102,10 +575,10 @@ func (h *Handler) handleList(w http.ResponseWriter, r *http.Request) {
 	if limitStr != "" {
 		parsed, err := strconv.Atoi(limitStr)
 		if err != nil {
-			WriteError(w, http.StatusBadRequest, "invalid_parameter", "limit must be a valid integer")
-			return
+			h.writeError(w, r, http.StatusBadRequest, "invalid_parameter", "limit must be a valid integer")
+			return stowry.ListQuery{}, false
 		}
-		limit = max(1, min(1000, parsed))
+		limit = max(1, min(MaxListLimit, parsed))
 	}
 
 	query := stowry.ListQuery{
@@ -113,56 +586,154 @@ func (h *Handler) handleList(w http.ResponseWriter, r *http.Request) {
 		Limit:      limit,
 		Cursor:     cursor,
 	}
+	// Checked here too for services that do not validate list queries
+	if err := query.Validate(h.pathLimits()); err != nil {
+		h.handleError(w, r, err)
+		return stowry.ListQuery{}, false
+	}
+	return query, true
+}
 
-	result, err := h.service.List(r.Context(), query)
-	if err != nil {
-		HandleError(w, err)
-		return
+// trimListItems clears the fields of items the request did not ask to
+// include.
+func (h *Handler) trimListItems(r *http.Request, items []stowry.MetaData) {
+	access, backend := includeAccess(r), includeBackend(r)
+	for i := range items {
+		if !access {
+			items[i].DownloadCount = 0
+			items[i].LastAccessedAt = nil
+		}
+		if !backend {
+			items[i].StorageBackend = ""
+		}
 	}
+}
+
+// includeAccess reports whether the request asked for access statistics with
+// ?include=access.
+func includeAccess(r *http.Request) bool {
+	return includes(r, "access")
+}
+
+// includeBackend reports whether the request asked for the storage backend
+// of each object with ?include=backend.
+func includeBackend(r *http.Request) bool {
+	return includes(r, "backend")
+}
 
-	_ = WriteJSON(w, http.StatusOK, result)
+// includes reports whether the comma-separated ?include list names field, as
+// in ?include=access,backend.
+func includes(r *http.Request, field string) bool {
+	for _, v := range strings.Split(r.URL.Query().Get("include"), ",") {
+		if strings.TrimSpace(v) == field {
+			return true
+		}
+	}
+	return false
 }
 
 func (h *Handler) handleGet(w http.ResponseWriter, r *http.Request) {
+	if isMetadataRequest(r) {
+		h.handleMetadata(w, r)
+		return
+	}
+
 	path := strings.TrimPrefix(r.URL.Path, "/")
 
 	if path != "" && !h.isValidRequestPath(path) {
-		WriteError(w, http.StatusBadRequest, "invalid_path", "Invalid path")
+		h.writeError(w, r, http.StatusBadRequest, "invalid_path", "Invalid path")
+		return
+	}
+
+	if ds, ok := h.deltaService(); ok && isSignatureRequest(r) {
+		h.handleSignature(w, r, ds, path)
 		return
 	}
 
-	obj, content, err := h.service.Get(r.Context(), path)
+	path, rewritten, handled := h.applyRedirects(w, r, path)
+	if handled {
+		return
+	}
+	if path != "" && !h.isValidRequestPath(path) {
+		h.writeError(w, r, http.StatusBadRequest, "invalid_path", "Invalid path")
+		return
+	}
+	if h.isRedirectsFile(path) {
+		h.handleNotFound(w, r)
+		return
+	}
+
+	get := h.service.Get
+	if ps, ok := h.precompressedService(); ok {
+		encodings := precompressedEncodings(r)
+		get = func(ctx context.Context, path string) (stowry.MetaData, io.ReadSeekCloser, error) {
+			return ps.GetNegotiated(ctx, path, encodings)
+		}
+		addVary(w.Header(), "Accept-Encoding")
+	}
+
+	noFallback := h.noFallback(path)
+	obj, content, err := get(r.Context(), path)
+	if noFallback {
+		if err == nil && obj.Path != strings.TrimSuffix(path, "/") {
+			_ = content.Close()
+			err = stowry.ErrNotFound
+		}
+	} else {
+		err = h.awaitIndex(r.Context(), err, func() error {
+			obj, content, err = get(r.Context(), path)
+			return err
+		})
+	}
 	if err != nil {
-		if errors.Is(err, stowry.ErrNotFound) {
-			h.handleNotFound(w, r)
-		} else {
-			HandleError(w, err)
+		switch {
+		case h.indexMissing(err) && !noFallback:
+			writeSPAPlaceholder(w, r)
+		case errors.Is(err, stowry.ErrNotFound):
+			h.handleMissing(w, r, path)
+		default:
+			h.handleError(w, r, err)
 		}
 		return
 	}
 	defer func() { _ = content.Close() }()
 
+	if location, ok := h.canonicalLocation(r, path, obj.Path); ok && !rewritten {
+		http.Redirect(w, r, location, http.StatusMovedPermanently)
+		return
+	}
+
 	w.Header().Set("ETag", `"`+obj.Etag+`"`)
 	w.Header().Set("Content-Type", obj.ContentType)
+	h.setContentDisposition(w, obj)
+	setMetadataHeaders(w.Header(), obj)
+	setUserMetadataHeaders(w.Header(), obj)
+	if _, ok := h.deltaService(); ok {
+		w.Header().Set("Accept-Patch", DeltaContentType)
+	}
+	h.setCacheControl(w, obj)
 
-	http.ServeContent(w, r, path, obj.UpdatedAt, content)
+	h.serveDownload(w, r, path, obj, content)
 }
 
-func (h *Handler) handleHead(w http.ResponseWriter, r *http.Request) {
-	path := strings.TrimPrefix(r.URL.Path, "/")
-
-	if path != "" && !h.isValidRequestPath(path) {
-		WriteError(w, http.StatusBadRequest, "invalid_path", "Invalid path")
+// serveDownload is serveObject for a GET of an object, counting the download
+// with the AccessTracker only once the whole object was sent with 200.
+func (h *Handler) serveDownload(w http.ResponseWriter, r *http.Request, name string, obj stowry.MetaData, content io.ReadSeeker) {
+	if h.config.AccessTracker == nil || r.Method != http.MethodGet {
+		serveObject(w, r, name, obj, content)
 		return
 	}
 
-	obj, err := h.service.Info(r.Context(), path)
-	if err != nil {
-		if errors.Is(err, stowry.ErrNotFound) {
-			h.handleNotFound(w, r)
-		} else {
-			HandleError(w, err)
-		}
+	sw := &statusWriter{ResponseWriter: w}
+	serveObject(sw, r, name, obj, content)
+	if sw.status == http.StatusOK {
+		h.config.AccessTracker.Record(obj.Path)
+	}
+}
+
+func (h *Handler) handleHead(w http.ResponseWriter, r *http.Request) {
+	obj, ok := h.resolveInfo(w, r, true)
+	if !ok {
 		return
 	}
 
@@ -174,91 +745,362 @@ func (h *Handler) handleHead(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Length", fmt.Sprintf("%d", obj.FileSizeBytes))
 	w.Header().Set("Last-Modified", modTime.Format(http.TimeFormat))
 	w.Header().Set("Accept-Ranges", "bytes")
-
-	// If-None-Match takes precedence per RFC 7232
-	if inm := r.Header.Get("If-None-Match"); inm != "" {
-		if etagWeakMatch(inm, etag) {
-			w.WriteHeader(http.StatusNotModified)
-			return
+	h.setContentDisposition(w, obj)
+	setMetadataHeaders(w.Header(), obj)
+	setUserMetadataHeaders(w.Header(), obj)
+	if obj.ContentEncoding != "" {
+		// Describe the representation a GET with the same headers receives
+		addVary(w.Header(), "Accept-Encoding")
+		if acceptsEncoding(r, obj.ContentEncoding) {
+			w.Header().Set("Content-Encoding", obj.ContentEncoding)
+		} else {
+			etag = setDecodedHeaders(w.Header(), obj)
 		}
-	} else if ims := r.Header.Get("If-Modified-Since"); ims != "" {
-		if t, err := http.ParseTime(ims); err == nil {
-			if !modTime.Truncate(time.Second).After(t.Truncate(time.Second)) {
-				w.WriteHeader(http.StatusNotModified)
-				return
-			}
+	}
+	if _, ok := h.deltaService(); ok {
+		w.Header().Set("Accept-Patch", DeltaContentType)
+	}
+	h.setCacheControl(w, obj)
+
+	if includeAccess(r) {
+		w.Header().Set("X-Stowry-Download-Count", strconv.FormatInt(obj.DownloadCount, 10))
+		if obj.LastAccessedAt != nil {
+			w.Header().Set("X-Stowry-Last-Accessed", obj.LastAccessedAt.UTC().Format(http.TimeFormat))
 		}
 	}
 
+	if notModified(r, etag, modTime) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
 	w.WriteHeader(http.StatusOK)
 }
 
+// resolveInfo resolves the object a HEAD, or a GET with ?metadata, describes
+// without opening its content, applying redirect rules and the index and SPA
+// fallbacks like a GET. It reports false when it has already written the
+// response. With negotiate, a HEAD describes the pre-compressed variant a
+// GET would be sent.
+func (h *Handler) resolveInfo(w http.ResponseWriter, r *http.Request, negotiate bool) (stowry.MetaData, bool) {
+	path := strings.TrimPrefix(r.URL.Path, "/")
+
+	if path != "" && !h.isValidRequestPath(path) {
+		h.writeError(w, r, http.StatusBadRequest, "invalid_path", "Invalid path")
+		return stowry.MetaData{}, false
+	}
+
+	path, rewritten, handled := h.applyRedirects(w, r, path)
+	if handled {
+		return stowry.MetaData{}, false
+	}
+	if path != "" && !h.isValidRequestPath(path) {
+		h.writeError(w, r, http.StatusBadRequest, "invalid_path", "Invalid path")
+		return stowry.MetaData{}, false
+	}
+	if h.isRedirectsFile(path) {
+		h.handleNotFound(w, r)
+		return stowry.MetaData{}, false
+	}
+
+	info := h.service.Info
+	if ps, ok := h.precompressedService(); ok && negotiate {
+		encodings := precompressedEncodings(r)
+		info = func(ctx context.Context, path string) (stowry.MetaData, error) {
+			return ps.InfoNegotiated(ctx, path, encodings)
+		}
+		addVary(w.Header(), "Accept-Encoding")
+	}
+
+	noFallback := h.noFallback(path)
+	obj, err := info(r.Context(), path)
+	if noFallback {
+		if err == nil && obj.Path != strings.TrimSuffix(path, "/") {
+			err = stowry.ErrNotFound
+		}
+	} else {
+		err = h.awaitIndex(r.Context(), err, func() error {
+			obj, err = info(r.Context(), path)
+			return err
+		})
+	}
+	if err != nil {
+		switch {
+		case h.indexMissing(err) && !noFallback:
+			writeSPAPlaceholder(w, r)
+		case errors.Is(err, stowry.ErrNotFound):
+			h.handleMissing(w, r, path)
+		default:
+			h.handleError(w, r, err)
+		}
+		return stowry.MetaData{}, false
+	}
+
+	if location, ok := h.canonicalLocation(r, path, obj.Path); ok && !rewritten {
+		http.Redirect(w, r, location, http.StatusMovedPermanently)
+		return stowry.MetaData{}, false
+	}
+
+	return obj, true
+}
+
+// setCacheControl sends the Cache-Control of the resolved object, so a static
+// or SPA fallback to index.html gets the index's value and is never cached as
+// immutable. The object's own value wins over the cache rules, which win over
+// the cache policy.
+func (h *Handler) setCacheControl(w http.ResponseWriter, obj stowry.MetaData) {
+	if obj.CacheControl != "" {
+		w.Header().Set("Cache-Control", obj.CacheControl)
+		return
+	}
+	if value, ok := h.config.CacheRules.CacheControl(obj.Path); ok {
+		w.Header().Set("Cache-Control", value)
+		return
+	}
+	if h.config.CachePolicy == nil || h.config.Mode == stowry.ModeStore {
+		return
+	}
+	w.Header().Set("Cache-Control", h.config.CachePolicy.CacheControl(obj.Path))
+}
+
 func (h *Handler) handlePut(w http.ResponseWriter, r *http.Request) {
 	path := strings.TrimPrefix(r.URL.Path, "/")
 
+	if strings.HasSuffix(path, "/") {
+		h.writeError(w, r, http.StatusBadRequest, "invalid_path", "Object keys cannot end with /")
+		return
+	}
+
 	if path == "" || !stowry.IsValidPath(path) {
-		WriteError(w, http.StatusBadRequest, "invalid_path", "Invalid path")
+		h.writeError(w, r, http.StatusBadRequest, "invalid_path", "Invalid path")
 		return
 	}
 
-	contentType := r.Header.Get("Content-Type")
+	constraints, err := stowry.ParsePresignConstraints(r.URL.Query())
+	if err != nil {
+		h.writeError(w, r, http.StatusBadRequest, "invalid_parameter", err.Error())
+		return
+	}
 
-	ifMatch := r.Header.Get("If-Match")
-	if ifMatch != "" {
-		existing, err := h.service.Info(r.Context(), path)
-		if err != nil && !errors.Is(err, stowry.ErrNotFound) {
-			HandleError(w, err)
+	copySource, isCopy, err := requestCopySource(r)
+	if err != nil {
+		h.writeError(w, r, http.StatusBadRequest, "invalid_copy_source", "Invalid copy source")
+		return
+	}
+	if isCopy {
+		h.handleCopy(w, r, copySource, path, constraints)
+		return
+	}
+
+	if !h.checkUploadConstraints(w, r, constraints) {
+		return
+	}
+
+	var stages StageService
+	var stageID uuid.UUID
+	if header := r.Header.Get(StageHeader); header != "" {
+		var ok bool
+		if stages, ok = h.stageService(); !ok {
+			h.writeError(w, r, http.StatusNotImplemented, "not_supported", "Staged uploads are not supported")
 			return
 		}
-		// RFC 9110 §13.1.1: If-Match is false when there is no current representation
-		if errors.Is(err, stowry.ErrNotFound) {
-			WriteError(w, http.StatusPreconditionFailed, "precondition_failed", "ETag mismatch")
+		if stageID, err = uuid.Parse(header); err != nil {
+			h.writeStageNotFound(w, r)
 			return
 		}
-		if !etagStrongMatch(ifMatch, `"`+existing.Etag+`"`) {
-			WriteError(w, http.StatusPreconditionFailed, "precondition_failed", "ETag mismatch")
+	}
+
+	// Rejected before the If-Match lookup; the service normalizes again
+	contentType := r.Header.Get("Content-Type")
+	if contentType != "" {
+		if contentType, err = stowry.NormalizeContentType(contentType); err != nil {
+			h.handleError(w, r, err)
 			return
 		}
 	}
+	contentEncoding, err := stowry.NormalizeContentEncoding(r.Header.Get("Content-Encoding"))
+	if err != nil {
+		h.handleError(w, r, err)
+		return
+	}
+	filename, err := requestFilename(r)
+	if err != nil {
+		h.handleError(w, r, err)
+		return
+	}
+	objectID, err := requestObjectID(r)
+	if err != nil {
+		h.handleError(w, r, err)
+		return
+	}
+	if objectID != uuid.Nil && stages != nil {
+		h.writeError(w, r, http.StatusBadRequest, "invalid_parameter", ObjectIDHeader+" cannot be used with staged uploads")
+		return
+	}
+
+	if !h.checkIfMatch(w, r, path) {
+		return
+	}
 
 	obj := stowry.CreateObject{
-		Path:        path,
-		ContentType: contentType,
+		Path:            path,
+		ContentType:     contentType,
+		ContentEncoding: contentEncoding,
+		Filename:        filename,
+		UserMetadata:    requestUserMetadata(r),
+		CacheControl:    r.Header.Get("Cache-Control"),
+		ID:              objectID,
+	}
+	if r.ContentLength > 0 {
+		obj.Size = r.ContentLength
 	}
 
+	maxSize := h.config.MaxUploadSize
+	if constraints.MaxSize > 0 && (maxSize == 0 || constraints.MaxSize < maxSize) {
+		maxSize = constraints.MaxSize
+	}
 	body := io.Reader(r.Body)
-	if h.config.MaxUploadSize > 0 {
-		body = http.MaxBytesReader(w, r.Body, h.config.MaxUploadSize)
+	if maxSize > 0 {
+		body = http.MaxBytesReader(w, r.Body, maxSize)
 	}
 
-	metaData, err := h.service.Create(r.Context(), obj, body)
+	var res stowry.CreateResult
+	if stages != nil {
+		res.MetaData, err = h.stageObject(r, stages, stageID, obj, body)
+		if errors.Is(err, stowry.ErrNotFound) {
+			h.writeStageNotFound(w, r)
+			return
+		}
+	} else {
+		res, err = h.service.Create(r.Context(), obj, body)
+	}
 	if err != nil {
-		HandleError(w, err)
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			h.writeError(w, r, http.StatusRequestEntityTooLarge, "too_large", "Upload exceeds the maximum size of "+strconv.FormatInt(tooLarge.Limit, 10)+" bytes")
+			return
+		}
+
+		// Lost a first-write race: report 409 with the winner's ETag so the
+		// client can decide whether to retry with If-Match
+		if errors.Is(err, stowry.ErrConflict) && res.Etag != "" {
+			w.Header().Set("ETag", `"`+res.Etag+`"`)
+		}
+		h.handleError(w, r, err)
+		return
+	}
+
+	writeUploadResult(w, r, res)
+}
+
+// checkIfMatch evaluates the If-Match header of a PUT to path against the
+// object there, writing 412 and returning false when it does not match.
+func (h *Handler) checkIfMatch(w http.ResponseWriter, r *http.Request, path string) bool {
+	ifMatch := r.Header.Get("If-Match")
+	if ifMatch == "" {
+		return true
+	}
+	existing, err := h.service.Info(r.Context(), path)
+	if err != nil && !errors.Is(err, stowry.ErrNotFound) {
+		h.handleError(w, r, err)
+		return false
+	}
+	// RFC 9110 §13.1.1: If-Match is false when there is no current representation
+	if errors.Is(err, stowry.ErrNotFound) {
+		h.writeError(w, r, http.StatusPreconditionFailed, "precondition_failed", "ETag mismatch")
+		return false
+	}
+	if !etagMatch(ifMatch, `"`+existing.Etag+`"`, h.config.IfMatchComparison) {
+		h.writeError(w, r, http.StatusPreconditionFailed, "precondition_failed", "ETag mismatch")
+		return false
+	}
+	return true
+}
+
+// Upload result headers repeat the server-computed SHA-256 ETag and stored
+// size of a committed upload, so clients can verify the transfer without
+// parsing the JSON body or sending a HEAD. UploadPreviousETagHeader carries
+// the ETag of the object a PUT overwrote and is left out when it overwrote
+// nothing.
+const (
+	UploadETagHeader         = "X-Stowry-ETag"
+	UploadBytesWrittenHeader = "X-Stowry-Bytes-Written"
+	UploadPreviousETagHeader = "X-Stowry-Previous-ETag"
+)
+
+// uploadResponse is the JSON body of a successful upload.
+type uploadResponse struct {
+	stowry.MetaData
+	PreviousETag string `json:"previous_etag,omitempty"`
+}
+
+// writeUploadResult answers a successful PUT or PATCH with the object's
+// metadata. It is only called once the content is in place and the metadata
+// committed, so the result always describes the stored object. Clients that
+// send "TE: trailers" receive the upload result headers as trailers.
+func writeUploadResult(w http.ResponseWriter, r *http.Request, res stowry.CreateResult) {
+	etag := res.Etag
+	size := strconv.FormatInt(res.FileSizeBytes, 10)
+	w.Header().Set("ETag", `"`+etag+`"`)
+	setMetadataHeaders(w.Header(), res.MetaData)
+
+	body := uploadResponse{MetaData: res.MetaData}
+	// The backend is only reported by list and stat with ?include=backend
+	body.StorageBackend = ""
+	if res.Previous != nil {
+		body.PreviousETag = res.Previous.Etag
+		w.Header().Set(UploadPreviousETagHeader, body.PreviousETag)
+	}
+
+	if !acceptsTrailers(r) {
+		w.Header().Set(UploadETagHeader, etag)
+		w.Header().Set(UploadBytesWrittenHeader, size)
+		_ = WriteJSON(w, http.StatusOK, body)
 		return
 	}
 
-	_ = WriteJSON(w, http.StatusOK, metaData)
+	w.Header().Set("Trailer", UploadETagHeader+", "+UploadBytesWrittenHeader)
+	_ = WriteJSON(w, http.StatusOK, body)
+	w.Header().Set(UploadETagHeader, etag)
+	w.Header().Set(UploadBytesWrittenHeader, size)
+}
+
+// acceptsTrailers reports whether the TE header lists "trailers".
+func acceptsTrailers(r *http.Request) bool {
+	for _, te := range r.Header.Values("TE") {
+		for _, token := range strings.Split(te, ",") {
+			if name, _, _ := strings.Cut(token, ";"); strings.EqualFold(strings.TrimSpace(name), "trailers") {
+				return true
+			}
+		}
+	}
+	return false
 }
 
 func (h *Handler) handleDelete(w http.ResponseWriter, r *http.Request) {
 	path := strings.TrimPrefix(r.URL.Path, "/")
 
+	if strings.HasSuffix(path, "/") {
+		h.writeError(w, r, http.StatusBadRequest, "invalid_path", "Object keys cannot end with /")
+		return
+	}
+
 	if path == "" || !stowry.IsValidPath(path) {
-		WriteError(w, http.StatusBadRequest, "invalid_path", "Invalid path")
+		h.writeError(w, r, http.StatusBadRequest, "invalid_path", "Invalid path")
 		return
 	}
 
 	err := h.service.Delete(r.Context(), path)
 	if err != nil {
 		if errors.Is(err, stowry.ErrNotFound) {
-			WriteError(w, http.StatusNotFound, "not_found", "Object not found")
+			h.writeError(w, r, http.StatusNotFound, "not_found", "Object not found")
 		} else {
-			HandleError(w, err)
+			h.handleError(w, r, err)
 		}
 		return
 	}
 
-	w.WriteHeader(http.StatusNoContent)
+	writeNoContent(w)
 }
 
 // isValidRequestPath validates the request path, allowing trailing slashes in static/SPA modes
@@ -276,12 +1118,44 @@ func (h *Handler) isValidRequestPath(path string) bool {
 	return stowry.IsValidPath(path)
 }
 
+// canonicalLocation returns a redirect target when a static/SPA request used a
+// different spelling than the object it resolved to: a directory index is
+// canonical with a trailing slash (/docs/) and a file without one (/about).
+// Redirecting keeps relative links in served HTML resolving against the right
+// base. SPA fallbacks to the root index are never redirected.
+func (h *Handler) canonicalLocation(r *http.Request, requested, resolved string) (string, bool) {
+	if h.config.Mode == stowry.ModeStore || requested == "" {
+		return "", false
+	}
+
+	trimmed := strings.TrimSuffix(requested, "/")
+
+	// The service resolves a directory to one of its index documents, so any
+	// file directly inside the requested path is one
+	var canonical string
+	switch {
+	case resolved == trimmed, resolved == trimmed+".html":
+		canonical = trimmed
+	case trimmed != "" && strings.HasPrefix(resolved, trimmed+"/") && !strings.Contains(resolved[len(trimmed)+1:], "/"):
+		canonical = trimmed + "/"
+	default:
+		return "", false
+	}
+
+	if canonical == requested {
+		return "", false
+	}
+
+	location := url.URL{Path: "/" + canonical, RawQuery: r.URL.RawQuery}
+	return location.String(), true
+}
+
 // handleNotFound serves the appropriate 404 response based on server mode.
 // In store mode, returns a JSON error. In static/SPA modes, tries the custom
 // error document first, then falls back to a default HTML 404 page.
 func (h *Handler) handleNotFound(w http.ResponseWriter, r *http.Request) {
 	if h.config.Mode == stowry.ModeStore {
-		WriteError(w, http.StatusNotFound, "not_found", "Object not found")
+		h.writeError(w, r, http.StatusNotFound, "not_found", "Object not found")
 		return
 	}
 
@@ -301,50 +1175,47 @@ func (h *Handler) handleNotFound(w http.ResponseWriter, r *http.Request) {
 	writeDefaultNotFound(w)
 }
 
-// etagStrongMatch checks if the If-Match header value matches the given ETag
-// using strong comparison per RFC 9110 §8.8.3.2.
-// Both ETags must not be weak, and their opaque-tags must be identical.
-// Handles * and comma-separated lists.
-func etagStrongMatch(headerVal, etag string) bool {
+// ETag comparisons, per RFC 9110 §8.8.3.2, a conditional PUT can be
+// configured with.
+const (
+	ETagStrong = "strong" // Neither ETag may be weak
+	ETagWeak   = "weak"   // W/ prefixes are ignored
+)
+
+// validETagComparison reports whether c is an ETag comparison the handler
+// can be configured with.
+func validETagComparison(c string) bool {
+	return c == "" || c == ETagStrong || c == ETagWeak
+}
+
+// etagMatch checks if an If-Match, If-None-Match or If-Range header value
+// matches the given ETag, handling * and comma-separated lists. Quoted and
+// bare values match alike.
+//
+// ETagStrong is the strong comparison of RFC 9110 §8.8.3.2: neither ETag may
+// be weak and the opaque-tags must be identical. ETagWeak ignores a W/ prefix
+// on either side and compares only the opaque-tags.
+func etagMatch(headerVal, etag, comparison string) bool {
 	if headerVal == "*" {
 		return true
 	}
-	// Our etag must not be weak for strong comparison
 	if strings.HasPrefix(etag, `W/`) {
-		return false
+		if comparison != ETagWeak {
+			return false
+		}
+		etag = strings.TrimPrefix(etag, `W/`)
 	}
-	// Extract opaque value without quotes for lenient matching
 	opaqueTag := strings.Trim(etag, `"`)
 
-	for _, raw := range strings.Split(headerVal, ",") {
+	for raw := range strings.SplitSeq(headerVal, ",") {
 		candidate := strings.TrimSpace(raw)
-		// Reject weak ETags in strong comparison
 		if strings.HasPrefix(candidate, `W/`) {
-			continue
-		}
-		// Match quoted or bare values
-		if candidate == etag || strings.Trim(candidate, `"`) == opaqueTag {
-			return true
+			if comparison != ETagWeak {
+				continue
+			}
+			candidate = strings.TrimPrefix(candidate, `W/`)
 		}
-	}
-	return false
-}
-
-// etagWeakMatch checks if the If-None-Match header value matches the given ETag
-// using weak comparison per RFC 9110 §8.8.3.2.
-// Handles *, comma-separated lists, and W/ prefixes.
-func etagWeakMatch(headerVal, etag string) bool {
-	if headerVal == "*" {
-		return true
-	}
-	// Strip W/ prefix from our etag for comparison
-	opaqueTag := strings.TrimPrefix(etag, `W/`)
-
-	for _, raw := range strings.Split(headerVal, ",") {
-		candidate := strings.TrimSpace(raw)
-		// Strip W/ prefix from candidate for weak comparison
-		candidate = strings.TrimPrefix(candidate, `W/`)
-		if candidate == opaqueTag {
+		if strings.Trim(candidate, `"`) == opaqueTag {
 			return true
 		}
 	}
@@ -4,9 +4,11 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"strconv"
 	"strings"
 	"testing"
 	"time"
@@ -16,6 +18,7 @@ import (
 	stowryhttp "github.com/sagarc03/stowry/http"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
 )
 
 // readSeekNopCloser wraps an io.ReadSeeker to add a no-op Close method
@@ -38,9 +41,13 @@ func (m *MockService) Get(ctx context.Context, path string) (stowry.MetaData, io
 	return args.Get(0).(stowry.MetaData), args.Get(1).(io.ReadSeekCloser), args.Error(2)
 }
 
-func (m *MockService) Create(ctx context.Context, obj stowry.CreateObject, content io.Reader) (stowry.MetaData, error) {
+func (m *MockService) Create(ctx context.Context, obj stowry.CreateObject, content io.Reader) (stowry.CreateResult, error) {
 	args := m.Called(ctx, obj, content)
-	return args.Get(0).(stowry.MetaData), args.Error(1)
+	// Tests that do not care about overwrites return plain metadata
+	if md, ok := args.Get(0).(stowry.MetaData); ok {
+		return stowry.CreateResult{MetaData: md}, args.Error(1)
+	}
+	return args.Get(0).(stowry.CreateResult), args.Error(1)
 }
 
 func (m *MockService) Delete(ctx context.Context, path string) error {
@@ -100,6 +107,49 @@ func TestHandler_HandleList_StoreMode(t *testing.T) {
 	service.AssertExpectations(t)
 }
 
+func TestHandler_HandleList_IncludeAccess(t *testing.T) {
+	accessed := time.Date(2025, 6, 15, 10, 30, 0, 0, time.UTC)
+	listResult := func() stowry.ListResult {
+		return stowry.ListResult{Items: []stowry.MetaData{
+			{Path: "file1.txt", DownloadCount: 7, LastAccessedAt: &accessed, StorageBackend: "archive/"},
+		}}
+	}
+
+	tests := []struct {
+		name        string
+		url         string
+		wantCount   int64
+		wantBackend string
+	}{
+		{name: "hidden by default", url: "/", wantCount: 0},
+		{name: "included on request", url: "/?include=access", wantCount: 7},
+		{name: "backend on request", url: "/?include=backend", wantBackend: "archive/"},
+		{name: "both on request", url: "/?include=access,backend", wantCount: 7, wantBackend: "archive/"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := &stowryhttp.HandlerConfig{Mode: stowry.ModeStore}
+			service := new(MockService)
+			handler := stowryhttp.NewHandler(config, service)
+
+			service.On("List", mock.Anything, mock.Anything).Return(listResult(), nil)
+
+			req := httptest.NewRequest("GET", tt.url, nil)
+			rec := httptest.NewRecorder()
+			handler.Router().ServeHTTP(rec, req)
+
+			assert.Equal(t, http.StatusOK, rec.Code)
+
+			var result stowry.ListResult
+			assert.NoError(t, json.NewDecoder(rec.Body).Decode(&result))
+			assert.Equal(t, tt.wantCount, result.Items[0].DownloadCount)
+			assert.Equal(t, tt.wantCount != 0, result.Items[0].LastAccessedAt != nil)
+			assert.Equal(t, tt.wantBackend, result.Items[0].StorageBackend)
+		})
+	}
+}
+
 func TestHandler_HandleList_DefaultLimit(t *testing.T) {
 	config := &stowryhttp.HandlerConfig{Mode: stowry.ModeStore}
 	service := new(MockService)
@@ -154,6 +204,141 @@ func TestHandler_HandleList_InvalidLimit(t *testing.T) {
 	service.AssertExpectations(t)
 }
 
+func TestHandler_HandleList_InvalidQuery(t *testing.T) {
+	serve := func(t *testing.T, service stowryhttp.Service, target string) *httptest.ResponseRecorder {
+		t.Helper()
+		handler := stowryhttp.NewHandler(&stowryhttp.HandlerConfig{Mode: stowry.ModeStore}, service)
+		rec := httptest.NewRecorder()
+		handler.Router().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, target, nil))
+		return rec
+	}
+
+	tests := []struct {
+		name      string
+		target    string
+		parameter string
+	}{
+		{name: "prefix over max_key_bytes", target: "/?prefix=" + strings.Repeat("a", stowry.DefaultMaxKeyBytes+1), parameter: "prefix"},
+		{name: "prefix over max_key_bytes as csv", target: "/?format=csv&prefix=" + strings.Repeat("a", stowry.DefaultMaxKeyBytes+1), parameter: "prefix"},
+		{name: "NUL byte in prefix", target: "/?prefix=docs%00", parameter: "prefix"},
+		{name: "invalid UTF-8 in prefix", target: "/?prefix=docs%ff", parameter: "prefix"},
+		{name: "cursor too long", target: "/?cursor=" + strings.Repeat("A", 200_000), parameter: "cursor"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service := new(MockService)
+
+			rec := serve(t, service, tt.target)
+
+			assert.Equal(t, http.StatusBadRequest, rec.Code)
+			var body map[string]any
+			require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+			assert.Equal(t, "invalid_parameter", body["error"])
+			assert.Equal(t, tt.parameter, body["parameter"])
+			service.AssertNotCalled(t, "List", mock.Anything, mock.Anything)
+		})
+	}
+
+	t.Run("uses the service's max_key_bytes", func(t *testing.T) {
+		service := &MockPathLimitService{limits: stowry.PathLimits{MaxKeyBytes: 8}}
+
+		rec := serve(t, service, "/?prefix=docs/abcde")
+
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		assert.Contains(t, rec.Body.String(), "max_key_bytes is 8")
+	})
+
+	t.Run("prefix at max_key_bytes is listed", func(t *testing.T) {
+		prefix := strings.Repeat("a", stowry.DefaultMaxKeyBytes)
+		service := new(MockService)
+		service.On("List", mock.Anything, stowry.ListQuery{PathPrefix: prefix, Limit: 100}).Return(stowry.ListResult{Items: []stowry.MetaData{}}, nil)
+
+		rec := serve(t, service, "/?prefix="+prefix)
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		service.AssertExpectations(t)
+	})
+}
+
+func TestHandler_HandleList_Disabled(t *testing.T) {
+	serve := func(t *testing.T, config *stowryhttp.HandlerConfig, service stowryhttp.Service, req *http.Request) *httptest.ResponseRecorder {
+		t.Helper()
+		h, err := stowryhttp.New(config, service)
+		require.NoError(t, err)
+		rec := httptest.NewRecorder()
+		h.Router().ServeHTTP(rec, req)
+		return rec
+	}
+	private := &stowryhttp.HandlerConfig{
+		Mode:          stowry.ModeStore,
+		ReadVerifier:  headerVerifier{},
+		WriteVerifier: headerVerifier{},
+		DisableList:   true,
+	}
+
+	for _, tc := range []struct {
+		name   string
+		target string
+		auth   bool
+	}{
+		{name: "anonymous", target: "/"},
+		{name: "authenticated", target: "/?prefix=docs/", auth: true},
+		{name: "csv export", target: "/?format=csv", auth: true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			service := new(MockService)
+			req := httptest.NewRequest(http.MethodGet, tc.target, nil)
+			if tc.auth {
+				req.Header.Set("X-Test-Auth", "ok")
+			}
+
+			rec := serve(t, private, service, req)
+
+			assert.Equal(t, http.StatusForbidden, rec.Code)
+			var body stowryhttp.ErrorResponse
+			require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+			assert.Equal(t, "list_disabled", body.Error)
+			service.AssertNotCalled(t, "List", mock.Anything, mock.Anything)
+		})
+	}
+
+	t.Run("objects are still served", func(t *testing.T) {
+		service := new(MockService)
+		service.On("Get", mock.Anything, "a.txt").
+			Return(stowry.MetaData{Path: "a.txt", ContentType: "text/plain", Etag: "abc", FileSizeBytes: 2}, readSeekNopCloser{strings.NewReader("hi")}, nil)
+		req := httptest.NewRequest(http.MethodGet, "/a.txt", nil)
+		req.Header.Set("X-Test-Auth", "ok")
+
+		rec := serve(t, private, service, req)
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "hi", rec.Body.String())
+	})
+
+	t.Run("watch is refused too", func(t *testing.T) {
+		service := new(MockChangeService)
+		req := httptest.NewRequest(http.MethodGet, "/?watch&since=0", nil)
+		req.Header.Set("X-Test-Auth", "ok")
+
+		rec := serve(t, private, service, req)
+
+		assert.Equal(t, http.StatusForbidden, rec.Code)
+		assert.Contains(t, rec.Body.String(), "list_disabled")
+		service.AssertNotCalled(t, "WatchChanges", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("ignored outside store mode", func(t *testing.T) {
+		service := new(MockService)
+		service.On("Get", mock.Anything, "").
+			Return(stowry.MetaData{Path: "index.html", ContentType: "text/html", Etag: "abc", FileSizeBytes: 2}, readSeekNopCloser{strings.NewReader("ok")}, nil)
+
+		rec := serve(t, &stowryhttp.HandlerConfig{Mode: stowry.ModeStatic, DisableList: true}, service, httptest.NewRequest(http.MethodGet, "/", nil))
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "ok", rec.Body.String())
+	})
+}
+
 func TestHandler_HandleGet_Success(t *testing.T) {
 	config := &stowryhttp.HandlerConfig{Mode: stowry.ModeStore}
 	service := new(MockService)
@@ -296,10 +481,122 @@ func TestHandler_HandlePut_Success(t *testing.T) {
 	assert.NoError(t, err)
 	assert.Equal(t, "new.txt", result.Path)
 	assert.Equal(t, "def456", result.Etag)
+	assert.Equal(t, `"def456"`, rec.Header().Get("ETag"))
+	assert.Equal(t, "def456", rec.Header().Get(stowryhttp.UploadETagHeader))
+	assert.Equal(t, "16", rec.Header().Get(stowryhttp.UploadBytesWrittenHeader))
+	assert.Empty(t, rec.Header().Get(stowryhttp.UploadPreviousETagHeader))
+	assert.NotContains(t, rec.Body.String(), "previous_etag")
 
 	service.AssertExpectations(t)
 }
 
+func TestHandler_HandlePut_Overwrite(t *testing.T) {
+	config := &stowryhttp.HandlerConfig{Mode: stowry.ModeStore}
+	service := new(MockService)
+	handler := stowryhttp.NewHandler(config, service)
+
+	service.On("Create", mock.Anything, mock.Anything, mock.Anything).Return(stowry.CreateResult{
+		MetaData: stowry.MetaData{Path: "a.txt", ContentType: "text/plain", Etag: "new", FileSizeBytes: 3},
+		Previous: &stowry.MetaData{Path: "a.txt", ContentType: "text/plain", Etag: "old", FileSizeBytes: 3},
+	}, nil)
+
+	req := httptest.NewRequest("PUT", "/a.txt", strings.NewReader("new"))
+	req.Header.Set("Content-Type", "text/plain")
+	rec := httptest.NewRecorder()
+
+	handler.Router().ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "old", rec.Header().Get(stowryhttp.UploadPreviousETagHeader))
+
+	var result struct {
+		Etag         string `json:"etag"`
+		PreviousETag string `json:"previous_etag"`
+	}
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&result))
+	assert.Equal(t, "new", result.Etag)
+	assert.Equal(t, "old", result.PreviousETag)
+}
+
+func TestHandler_HandlePut_ResultTrailers(t *testing.T) {
+	config := &stowryhttp.HandlerConfig{Mode: stowry.ModeStore}
+	service := new(MockService)
+	handler := stowryhttp.NewHandler(config, service)
+
+	metadata := stowry.MetaData{Path: "big.bin", ContentType: "application/octet-stream", Etag: "abc789", FileSizeBytes: 3}
+	service.On("Create", mock.Anything, mock.Anything, mock.Anything).Return(metadata, nil)
+
+	req := httptest.NewRequest("PUT", "/big.bin", strings.NewReader("abc"))
+	req.Header.Set("Content-Type", "application/octet-stream")
+	req.Header.Set("TE", "gzip, trailers")
+	rec := httptest.NewRecorder()
+
+	handler.Router().ServeHTTP(rec, req)
+
+	resp := rec.Result()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, stowryhttp.UploadETagHeader+", "+stowryhttp.UploadBytesWrittenHeader, resp.Header.Get("Trailer"))
+	assert.Empty(t, resp.Header.Get(stowryhttp.UploadETagHeader))
+	assert.Equal(t, "abc789", resp.Trailer.Get(stowryhttp.UploadETagHeader))
+	assert.Equal(t, "3", resp.Trailer.Get(stowryhttp.UploadBytesWrittenHeader))
+}
+
+func TestHandler_HandlePut_ContentType(t *testing.T) {
+	config := &stowryhttp.HandlerConfig{Mode: stowry.ModeStore}
+
+	t.Run("normalized before create", func(t *testing.T) {
+		service := new(MockService)
+		service.On("Create", mock.Anything, stowry.CreateObject{Path: "a.txt", ContentType: "text/plain; charset=utf-8", Size: 2}, mock.Anything).
+			Return(stowry.MetaData{Path: "a.txt", ContentType: "text/plain; charset=utf-8", Etag: "e"}, nil)
+
+		req := httptest.NewRequest(http.MethodPut, "/a.txt", strings.NewReader("hi"))
+		req.Header.Set("Content-Type", "Text/Plain; Charset=UTF-8")
+		rec := httptest.NewRecorder()
+		stowryhttp.NewHandler(config, service).Router().ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		service.AssertExpectations(t)
+	})
+
+	for _, value := range []string{
+		"text/html\r\nSet-Cookie: session=x",
+		"data:text/html;base64,PHNjcmlwdD4=",
+		"unknown",
+	} {
+		t.Run("rejects "+value, func(t *testing.T) {
+			service := new(MockService)
+
+			req := httptest.NewRequest(http.MethodPut, "/a.txt", strings.NewReader("hi"))
+			req.Header["Content-Type"] = []string{value}
+			rec := httptest.NewRecorder()
+			stowryhttp.NewHandler(config, service).Router().ServeHTTP(rec, req)
+
+			assert.Equal(t, http.StatusBadRequest, rec.Code)
+			assert.Contains(t, rec.Body.String(), "invalid_content_type")
+			assert.NotContains(t, rec.Body.String(), "Set-Cookie")
+			service.AssertNotCalled(t, "Create", mock.Anything, mock.Anything, mock.Anything)
+		})
+	}
+
+	t.Run("forbidden by a rule", func(t *testing.T) {
+		service := new(MockService)
+		service.On("Create", mock.Anything, mock.Anything, mock.Anything).
+			Return(stowry.MetaData{}, fmt.Errorf("create object: %w", &stowry.ContentTypeError{Prefix: "uploads/", ContentType: "text/html"}))
+
+		req := httptest.NewRequest(http.MethodPut, "/uploads/x.html", strings.NewReader("<p>"))
+		req.Header.Set("Content-Type", "text/html")
+		rec := httptest.NewRecorder()
+		stowryhttp.NewHandler(config, service).Router().ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusUnsupportedMediaType, rec.Code)
+		var resp stowryhttp.ContentTypeResponse
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+		assert.Equal(t, "content_type_not_allowed", resp.Error)
+		assert.Equal(t, "uploads/", resp.Prefix)
+		assert.Equal(t, "text/html", resp.ContentType)
+	})
+}
+
 func TestHandler_HandlePut_InvalidPath(t *testing.T) {
 	config := &stowryhttp.HandlerConfig{Mode: stowry.ModeStore}
 	service := new(MockService)
@@ -382,6 +679,83 @@ func TestHandler_HandleDelete_InvalidPath(t *testing.T) {
 	service.AssertExpectations(t)
 }
 
+// TestHandler_HandleDelete_ResponseShape pins the exact status, headers and
+// body bytes of each DELETE outcome, with compression and CORS in the chain.
+func TestHandler_HandleDelete_ResponseShape(t *testing.T) {
+	tests := []struct {
+		name        string
+		path        string
+		deleteErr   error
+		callsDelete bool
+		wantStatus  int
+		wantBody    string
+	}{
+		{
+			name:        "success",
+			path:        "/a.txt",
+			callsDelete: true,
+			wantStatus:  http.StatusNoContent,
+		},
+		{
+			name:        "unknown path",
+			path:        "/missing.txt",
+			deleteErr:   stowry.ErrNotFound,
+			callsDelete: true,
+			wantStatus:  http.StatusNotFound,
+			wantBody:    `{"error":"not_found","message":"Object not found"}` + "\n",
+		},
+		{
+			name:        "service error",
+			path:        "/broken.txt",
+			deleteErr:   errors.New("database unavailable"),
+			callsDelete: true,
+			wantStatus:  http.StatusInternalServerError,
+			wantBody:    `{"error":"internal_error","message":"Internal server error"}` + "\n",
+		},
+		{
+			name:       "trailing slash",
+			path:       "/docs/",
+			wantStatus: http.StatusBadRequest,
+			wantBody:   `{"error":"invalid_path","message":"Object keys cannot end with /"}` + "\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := &stowryhttp.HandlerConfig{
+				Mode:        stowry.ModeStore,
+				CORS:        stowryhttp.CORSConfig{Enabled: true, AllowedOrigins: []string{"*"}},
+				Compression: stowryhttp.CompressionConfig{Enabled: true},
+			}
+			service := new(MockService)
+			handler := stowryhttp.NewHandler(config, service)
+			if tt.callsDelete {
+				service.On("Delete", mock.Anything, strings.TrimPrefix(tt.path, "/")).Return(tt.deleteErr)
+			}
+
+			req := httptest.NewRequest("DELETE", tt.path, nil)
+			req.Header.Set("Accept-Encoding", "gzip")
+			req.Header.Set("Origin", "http://example.com")
+			rec := httptest.NewRecorder()
+
+			handler.Router().ServeHTTP(rec, req)
+
+			assert.Equal(t, tt.wantStatus, rec.Code)
+			assert.Equal(t, tt.wantBody, rec.Body.String())
+			assert.Empty(t, rec.Header().Get("Content-Encoding"))
+			if tt.wantBody == "" {
+				assert.Empty(t, rec.Header().Get("Content-Type"))
+				assert.Empty(t, rec.Header().Get("Content-Length"))
+			} else {
+				assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+				assert.Equal(t, strconv.Itoa(len(tt.wantBody)), rec.Header().Get("Content-Length"))
+			}
+
+			service.AssertExpectations(t)
+		})
+	}
+}
+
 func TestHandler_CORS_Disabled(t *testing.T) {
 	config := &stowryhttp.HandlerConfig{
 		Mode: stowry.ModeStore,
@@ -526,6 +900,215 @@ func TestHandler_SPAMode_RootPath_CallsGet(t *testing.T) {
 	service.AssertNotCalled(t, "List")
 }
 
+func TestHandler_TrailingSlash_CanonicalRedirect(t *testing.T) {
+	tests := []struct {
+		name         string
+		mode         stowry.ServerMode
+		method       string
+		target       string
+		requestPath  string
+		resolvedPath string
+		wantStatus   int
+		wantLocation string
+	}{
+		{name: "directory without slash", mode: stowry.ModeStatic, method: http.MethodGet, target: "/docs", requestPath: "docs", resolvedPath: "docs/index.html", wantStatus: http.StatusMovedPermanently, wantLocation: "/docs/"},
+		{name: "directory with slash", mode: stowry.ModeStatic, method: http.MethodGet, target: "/docs/", requestPath: "docs/", resolvedPath: "docs/index.html", wantStatus: http.StatusOK},
+		{name: "file with slash", mode: stowry.ModeStatic, method: http.MethodGet, target: "/about/", requestPath: "about/", resolvedPath: "about.html", wantStatus: http.StatusMovedPermanently, wantLocation: "/about"},
+		{name: "clean url", mode: stowry.ModeStatic, method: http.MethodGet, target: "/about", requestPath: "about", resolvedPath: "about.html", wantStatus: http.StatusOK},
+		{name: "query preserved", mode: stowry.ModeStatic, method: http.MethodGet, target: "/docs?v=2", requestPath: "docs", resolvedPath: "docs/index.html", wantStatus: http.StatusMovedPermanently, wantLocation: "/docs/?v=2"},
+		{name: "escaped location", mode: stowry.ModeStatic, method: http.MethodGet, target: "/caf%C3%A9", requestPath: "café", resolvedPath: "café/index.html", wantStatus: http.StatusMovedPermanently, wantLocation: "/caf%C3%A9/"},
+		{name: "other index document", mode: stowry.ModeStatic, method: http.MethodGet, target: "/docs", requestPath: "docs", resolvedPath: "docs/default.html", wantStatus: http.StatusMovedPermanently, wantLocation: "/docs/"},
+		{name: "spa custom index not redirected", mode: stowry.ModeSPA, method: http.MethodGet, target: "/route/", requestPath: "route/", resolvedPath: "app.html", wantStatus: http.StatusOK},
+		{name: "head redirects", mode: stowry.ModeStatic, method: http.MethodHead, target: "/docs", requestPath: "docs", resolvedPath: "docs/index.html", wantStatus: http.StatusMovedPermanently, wantLocation: "/docs/"},
+		{name: "spa file with slash", mode: stowry.ModeSPA, method: http.MethodGet, target: "/real.txt/", requestPath: "real.txt/", resolvedPath: "real.txt", wantStatus: http.StatusMovedPermanently, wantLocation: "/real.txt"},
+		{name: "spa fallback not redirected", mode: stowry.ModeSPA, method: http.MethodGet, target: "/route/", requestPath: "route/", resolvedPath: "index.html", wantStatus: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := &stowryhttp.HandlerConfig{Mode: tt.mode}
+			service := new(MockService)
+			handler := stowryhttp.NewHandler(config, service)
+
+			metadata := stowry.MetaData{
+				ID:          uuid.New(),
+				Path:        tt.resolvedPath,
+				ContentType: "text/html",
+				Etag:        "abc123",
+				UpdatedAt:   time.Now(),
+			}
+			if tt.method == http.MethodHead {
+				service.On("Info", mock.Anything, tt.requestPath).Return(metadata, nil)
+			} else {
+				service.On("Get", mock.Anything, tt.requestPath).Return(
+					metadata,
+					readSeekNopCloser{strings.NewReader("<html></html>")},
+					nil,
+				)
+			}
+
+			req := httptest.NewRequest(tt.method, tt.target, nil)
+			rec := httptest.NewRecorder()
+			handler.Router().ServeHTTP(rec, req)
+
+			assert.Equal(t, tt.wantStatus, rec.Code)
+			assert.Equal(t, tt.wantLocation, rec.Header().Get("Location"))
+		})
+	}
+}
+
+func TestHandler_StoreMode_RejectsTrailingSlash(t *testing.T) {
+	for _, method := range []string{http.MethodGet, http.MethodHead, http.MethodPut, http.MethodDelete} {
+		t.Run(method, func(t *testing.T) {
+			config := &stowryhttp.HandlerConfig{Mode: stowry.ModeStore}
+			service := new(MockService)
+			handler := stowryhttp.NewHandler(config, service)
+
+			req := httptest.NewRequest(method, "/docs/", strings.NewReader("data"))
+			req.Header.Set("Content-Type", "text/plain")
+			rec := httptest.NewRecorder()
+			handler.Router().ServeHTTP(rec, req)
+
+			assert.Equal(t, http.StatusBadRequest, rec.Code)
+			service.AssertExpectations(t)
+		})
+	}
+}
+
+func TestHandler_ImmutableAssets_CacheControl(t *testing.T) {
+	policy, err := stowryhttp.NewCachePolicy("")
+	require.NoError(t, err)
+
+	tests := []struct {
+		name         string
+		mode         stowry.ServerMode
+		requestPath  string
+		resolvedPath string
+		want         string
+	}{
+		{name: "hashed asset", mode: stowry.ModeStatic, requestPath: "assets/app.3f2a9c1d.js", resolvedPath: "assets/app.3f2a9c1d.js", want: "public, max-age=31536000, immutable"},
+		{name: "index", mode: stowry.ModeStatic, requestPath: "", resolvedPath: "index.html", want: "no-cache"},
+		{name: "spa fallback uses resolved path", mode: stowry.ModeSPA, requestPath: "app.3f2a9c1d.route", resolvedPath: "index.html", want: "no-cache"},
+		{name: "store mode unaffected", mode: stowry.ModeStore, requestPath: "app.3f2a9c1d.js", resolvedPath: "app.3f2a9c1d.js", want: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := &stowryhttp.HandlerConfig{Mode: tt.mode, CachePolicy: policy}
+			service := new(MockService)
+			handler := stowryhttp.NewHandler(config, service)
+
+			metadata := stowry.MetaData{
+				ID:          uuid.New(),
+				Path:        tt.resolvedPath,
+				ContentType: "text/plain",
+				Etag:        "abc123",
+				UpdatedAt:   time.Now(),
+			}
+			service.On("Get", mock.Anything, tt.requestPath).Return(
+				metadata,
+				readSeekNopCloser{strings.NewReader("data")},
+				nil,
+			)
+			service.On("Info", mock.Anything, tt.requestPath).Return(metadata, nil)
+
+			for _, method := range []string{http.MethodGet, http.MethodHead} {
+				req := httptest.NewRequest(method, "/"+tt.requestPath, nil)
+				rec := httptest.NewRecorder()
+
+				handler.Router().ServeHTTP(rec, req)
+
+				assert.Equal(t, http.StatusOK, rec.Code, method)
+				assert.Equal(t, tt.want, rec.Header().Get("Cache-Control"), method)
+			}
+		})
+	}
+}
+
+func TestHandler_CacheRules_CacheControl(t *testing.T) {
+	policy, err := stowryhttp.NewCachePolicy("")
+	require.NoError(t, err)
+	rules, err := stowryhttp.NewCacheRules([]stowryhttp.CacheRule{
+		{Pattern: "index.html", CacheControl: "no-cache, must-revalidate"},
+		{Pattern: "/reports/**", CacheControl: "private, max-age=60"},
+	})
+	require.NoError(t, err)
+
+	tests := []struct {
+		name         string
+		mode         stowry.ServerMode
+		requestPath  string
+		resolvedPath string
+		objectValue  string
+		want         string
+	}{
+		{name: "rule", mode: stowry.ModeStatic, requestPath: "index.html", resolvedPath: "index.html", want: "no-cache, must-revalidate"},
+		{name: "spa fallback uses rule of index", mode: stowry.ModeSPA, requestPath: "app.3f2a9c1d.route", resolvedPath: "index.html", want: "no-cache, must-revalidate"},
+		{name: "policy without a rule", mode: stowry.ModeStatic, requestPath: "app.3f2a9c1d.js", resolvedPath: "app.3f2a9c1d.js", want: "public, max-age=31536000, immutable"},
+		{name: "object value wins", mode: stowry.ModeStatic, requestPath: "index.html", resolvedPath: "index.html", objectValue: "no-store", want: "no-store"},
+		{name: "store mode rule", mode: stowry.ModeStore, requestPath: "reports/q3.pdf", resolvedPath: "reports/q3.pdf", want: "private, max-age=60"},
+		{name: "store mode object value", mode: stowry.ModeStore, requestPath: "a.txt", resolvedPath: "a.txt", objectValue: "max-age=5", want: "max-age=5"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := &stowryhttp.HandlerConfig{Mode: tt.mode, CachePolicy: policy, CacheRules: rules}
+			service := new(MockService)
+			handler := stowryhttp.NewHandler(config, service)
+
+			metadata := stowry.MetaData{
+				ID:           uuid.New(),
+				Path:         tt.resolvedPath,
+				ContentType:  "text/plain",
+				Etag:         "abc123",
+				CacheControl: tt.objectValue,
+				UpdatedAt:    time.Now(),
+			}
+			service.On("Get", mock.Anything, tt.requestPath).Return(
+				metadata,
+				readSeekNopCloser{strings.NewReader("data")},
+				nil,
+			)
+			service.On("Info", mock.Anything, tt.requestPath).Return(metadata, nil)
+
+			for _, method := range []string{http.MethodGet, http.MethodHead} {
+				req := httptest.NewRequest(method, "/"+tt.requestPath, nil)
+				rec := httptest.NewRecorder()
+
+				handler.Router().ServeHTTP(rec, req)
+
+				assert.Equal(t, http.StatusOK, rec.Code, method)
+				assert.Equal(t, tt.want, rec.Header().Get("Cache-Control"), method)
+			}
+		})
+	}
+}
+
+func TestHandler_HandlePut_CacheControl(t *testing.T) {
+	config := &stowryhttp.HandlerConfig{Mode: stowry.ModeStore}
+	service := new(MockService)
+	handler := stowryhttp.NewHandler(config, service)
+
+	service.On("Create", mock.Anything, mock.MatchedBy(func(obj stowry.CreateObject) bool {
+		return obj.CacheControl == "public, max-age=31536000, immutable"
+	}), mock.Anything).Return(stowry.MetaData{Path: "app.js", Etag: "e"}, nil)
+
+	req := httptest.NewRequest(http.MethodPut, "/app.js", strings.NewReader("data"))
+	req.Header.Set("Cache-Control", "public, max-age=31536000, immutable")
+	rec := httptest.NewRecorder()
+	handler.Router().ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	service.AssertExpectations(t)
+
+	invalid := new(MockService)
+	invalid.On("Create", mock.Anything, mock.Anything, mock.Anything).Return(stowry.MetaData{}, stowry.ErrInvalidCacheControl)
+	rec = httptest.NewRecorder()
+	stowryhttp.NewHandler(config, invalid).Router().ServeHTTP(rec, httptest.NewRequest(http.MethodPut, "/app.js", strings.NewReader("data")))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assertErrorCode(t, rec, "invalid_cache_control")
+}
+
 // MaxUploadSize tests
 
 func TestHandler_HandlePut_MaxUploadSize_WithinLimit(t *testing.T) {
@@ -561,6 +1144,46 @@ func TestHandler_HandlePut_MaxUploadSize_WithinLimit(t *testing.T) {
 	service.AssertExpectations(t)
 }
 
+func TestHandler_HandlePut_PassesContentLength(t *testing.T) {
+	config := &stowryhttp.HandlerConfig{Mode: stowry.ModeStore}
+	service := new(MockService)
+	handler := stowryhttp.NewHandler(config, service)
+
+	service.On("Create", mock.Anything, mock.MatchedBy(func(obj stowry.CreateObject) bool {
+		return obj.Path == "big.bin" && obj.Size == 5
+	}), mock.Anything).Return(stowry.MetaData{}, stowry.ErrInsufficientStorage)
+
+	req := httptest.NewRequest("PUT", "/big.bin", strings.NewReader("hello"))
+	req.Header.Set("Content-Type", "application/octet-stream")
+	rec := httptest.NewRecorder()
+
+	handler.Router().ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusInsufficientStorage, rec.Code)
+	assert.Contains(t, rec.Body.String(), "insufficient_storage")
+	service.AssertExpectations(t)
+}
+
+func TestHandler_HandlePut_Conflict(t *testing.T) {
+	config := &stowryhttp.HandlerConfig{Mode: stowry.ModeStore}
+	service := new(MockService)
+	handler := stowryhttp.NewHandler(config, service)
+
+	winner := stowry.MetaData{Path: "race.txt", Etag: "winner"}
+	service.On("Create", mock.Anything, mock.Anything, mock.Anything).
+		Return(winner, fmt.Errorf("create object race.txt: %w", stowry.ErrConflict))
+
+	req := httptest.NewRequest("PUT", "/race.txt", strings.NewReader("hello"))
+	req.Header.Set("Content-Type", "text/plain")
+	rec := httptest.NewRecorder()
+
+	handler.Router().ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusConflict, rec.Code)
+	assert.Equal(t, `"winner"`, rec.Header().Get("ETag"))
+	assert.Contains(t, rec.Body.String(), "conflict")
+}
+
 func TestHandler_HandlePut_MaxUploadSize_NoLimit(t *testing.T) {
 	config := &stowryhttp.HandlerConfig{
 		Mode:          stowry.ModeStore,
@@ -730,6 +1353,60 @@ func TestHandler_HandlePut_IfMatch_FileNotExists(t *testing.T) {
 	}
 }
 
+func TestHandler_HandlePut_IfMatch_Comparison(t *testing.T) {
+	tests := []struct {
+		ifMatch string
+		strong  bool
+		weak    bool
+	}{
+		{`"current-etag"`, true, true},
+		{`current-etag`, true, true},
+		{`W/"current-etag"`, false, true},
+		{`W/current-etag`, false, true},
+		{`*`, true, true},
+		{`"other", W/"current-etag"`, false, true},
+		{`W/"other", "current-etag"`, true, true},
+		{`"stale-etag"`, false, false},
+		{`W/"stale-etag"`, false, false},
+		{`w/"current-etag"`, false, false},
+		{`"Current-Etag"`, false, false},
+	}
+
+	for _, comparison := range []string{stowryhttp.ETagStrong, stowryhttp.ETagWeak} {
+		for _, tt := range tests {
+			want := tt.strong
+			if comparison == stowryhttp.ETagWeak {
+				want = tt.weak
+			}
+			t.Run(comparison+" "+tt.ifMatch, func(t *testing.T) {
+				service := new(MockService)
+				handler, err := stowryhttp.New(&stowryhttp.HandlerConfig{Mode: stowry.ModeStore, IfMatchComparison: comparison}, service)
+				require.NoError(t, err)
+
+				service.On("Info", mock.Anything, "existing.txt").Return(stowry.MetaData{Path: "existing.txt", Etag: "current-etag"}, nil)
+				service.On("Create", mock.Anything, mock.Anything, mock.Anything).Return(stowry.MetaData{Path: "existing.txt", Etag: "new-etag"}, nil).Maybe()
+
+				req := httptest.NewRequest("PUT", "/existing.txt", strings.NewReader("new content"))
+				req.Header.Set("If-Match", tt.ifMatch)
+				rec := httptest.NewRecorder()
+				handler.Router().ServeHTTP(rec, req)
+
+				if want {
+					assert.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+				} else {
+					assert.Equal(t, http.StatusPreconditionFailed, rec.Code)
+					service.AssertNotCalled(t, "Create", mock.Anything, mock.Anything, mock.Anything)
+				}
+			})
+		}
+	}
+}
+
+func TestNew_InvalidIfMatchComparison(t *testing.T) {
+	_, err := stowryhttp.New(&stowryhttp.HandlerConfig{Mode: stowry.ModeStore, IfMatchComparison: "lenient"}, new(MockService))
+	assert.ErrorContains(t, err, `invalid if-match comparison "lenient"`)
+}
+
 // Limit edge cases
 
 func TestHandler_HandleList_LimitZero(t *testing.T) {
@@ -917,6 +1594,99 @@ func TestHandler_HandleHead_Success(t *testing.T) {
 	service.AssertExpectations(t)
 }
 
+func TestHandler_HandleHead_IncludeAccess(t *testing.T) {
+	config := &stowryhttp.HandlerConfig{Mode: stowry.ModeStore}
+	service := new(MockService)
+	handler := stowryhttp.NewHandler(config, service)
+
+	accessed := time.Date(2025, 6, 15, 10, 30, 0, 0, time.UTC)
+	metadata := stowry.MetaData{
+		Path:           "test.txt",
+		ContentType:    "text/plain",
+		Etag:           "abc123",
+		DownloadCount:  42,
+		LastAccessedAt: &accessed,
+	}
+	service.On("Info", mock.Anything, "test.txt").Return(metadata, nil)
+
+	req := httptest.NewRequest("HEAD", "/test.txt?include=access", nil)
+	rec := httptest.NewRecorder()
+	handler.Router().ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "42", rec.Header().Get("X-Stowry-Download-Count"))
+	assert.Equal(t, "Sun, 15 Jun 2025 10:30:00 GMT", rec.Header().Get("X-Stowry-Last-Accessed"))
+
+	req = httptest.NewRequest("HEAD", "/test.txt", nil)
+	rec = httptest.NewRecorder()
+	handler.Router().ServeHTTP(rec, req)
+
+	assert.Empty(t, rec.Header().Get("X-Stowry-Download-Count"))
+}
+
+// accessRepo collects the counts an AccessTracker flushes.
+type accessRepo struct {
+	stowry.MetaDataRepo
+	records []stowry.AccessRecord
+}
+
+func (r *accessRepo) RecordAccess(_ context.Context, records []stowry.AccessRecord) error {
+	r.records = append(r.records, records...)
+	return nil
+}
+
+func TestHandler_HandleGet_RecordsAccess(t *testing.T) {
+	metadata := stowry.MetaData{
+		Path:          "test.txt",
+		ContentType:   "text/plain",
+		Etag:          "abc123",
+		FileSizeBytes: 13,
+		UpdatedAt:     time.Date(2025, 6, 15, 10, 30, 0, 0, time.UTC),
+	}
+
+	tests := []struct {
+		name       string
+		header     map[string]string
+		wantStatus int
+		wantCount  int64
+	}{
+		{name: "full download", wantStatus: http.StatusOK, wantCount: 1},
+		{name: "not modified", header: map[string]string{"If-None-Match": `"abc123"`}, wantStatus: http.StatusNotModified},
+		{name: "range", header: map[string]string{"Range": "bytes=0-4"}, wantStatus: http.StatusPartialContent},
+		{
+			name:       "range of a changed object",
+			header:     map[string]string{"Range": "bytes=0-4", "If-Range": `"old"`},
+			wantStatus: http.StatusOK,
+			wantCount:  1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := new(accessRepo)
+			tracker := stowry.NewAccessTracker(repo, time.Hour)
+			service := new(MockService)
+			service.On("Get", mock.Anything, "test.txt").Return(metadata, readSeekNopCloser{strings.NewReader("Hello, World!")}, nil)
+			handler := stowryhttp.NewHandler(&stowryhttp.HandlerConfig{Mode: stowry.ModeStore, AccessTracker: tracker}, service)
+
+			req := httptest.NewRequest(http.MethodGet, "/test.txt", nil)
+			for k, v := range tt.header {
+				req.Header.Set(k, v)
+			}
+			rec := httptest.NewRecorder()
+			handler.Router().ServeHTTP(rec, req)
+			require.Equal(t, tt.wantStatus, rec.Code)
+
+			require.NoError(t, tracker.Flush(context.Background()))
+			var count int64
+			for _, r := range repo.records {
+				count += r.Count
+			}
+			assert.Equal(t, tt.wantCount, count)
+		})
+	}
+}
+
 func TestHandler_HandleHead_NotFound(t *testing.T) {
 	config := &stowryhttp.HandlerConfig{Mode: stowry.ModeStore}
 	service := new(MockService)
@@ -0,0 +1,312 @@
+package http
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/sagarc03/stowry"
+)
+
+// DefaultReferrerPolicy is the Referrer-Policy sent when security headers are
+// enabled without one.
+const DefaultReferrerPolicy = "strict-origin-when-cross-origin"
+
+// referrerPolicies are the values Referrer-Policy accepts.
+var referrerPolicies = []string{
+	"no-referrer",
+	"no-referrer-when-downgrade",
+	"origin",
+	"origin-when-cross-origin",
+	"same-origin",
+	"strict-origin",
+	"strict-origin-when-cross-origin",
+	"unsafe-url",
+}
+
+// SecurityHeadersConfig configures the security headers sent with responses.
+//
+// NoSniff applies to every response in every mode. The other headers are sent
+// with GET and HEAD responses in static and SPA modes when Enabled is set,
+// since only there does Stowry serve pages to browsers. Store mode responses
+// additionally get Cache-Control: no-store on errors.
+type SecurityHeadersConfig struct {
+	// NoSniff sends X-Content-Type-Options: nosniff.
+	NoSniff bool `mapstructure:"nosniff"`
+	Enabled bool `mapstructure:"enabled"`
+	// ContentSecurityPolicy is sent as is, followed by a frame-ancestors
+	// directive built from FrameAncestors. Empty sends no policy unless
+	// FrameAncestors is set.
+	ContentSecurityPolicy string `mapstructure:"content_security_policy"`
+	// ReferrerPolicy is sent as Referrer-Policy. Empty sends none.
+	ReferrerPolicy string `mapstructure:"referrer_policy"`
+	// FrameAncestors lists the CSP sources allowed to embed pages, such as
+	// 'self' or https://example.com. X-Frame-Options is derived from it for
+	// older browsers when it is exactly 'none' or 'self'. Empty allows any.
+	FrameAncestors []string `mapstructure:"frame_ancestors"`
+	// HSTSMaxAge sends Strict-Transport-Security with this max-age on
+	// requests received over TLS, directly or through a proxy setting
+	// X-Forwarded-Proto. 0 disables it.
+	HSTSMaxAge            int  `mapstructure:"hsts_max_age"` // seconds
+	HSTSIncludeSubdomains bool `mapstructure:"hsts_include_subdomains"`
+	// Overrides replace headers for matching request paths. The first match
+	// wins.
+	Overrides []SecurityHeadersOverride `mapstructure:"overrides"`
+}
+
+// SecurityHeadersOverride replaces the headers sent for requests under Path.
+// Path is an absolute path; a final * matches the rest of the path. Empty
+// fields keep the value from SecurityHeadersConfig.
+type SecurityHeadersOverride struct {
+	Path                  string   `mapstructure:"path"`
+	ContentSecurityPolicy string   `mapstructure:"content_security_policy"`
+	ReferrerPolicy        string   `mapstructure:"referrer_policy"`
+	FrameAncestors        []string `mapstructure:"frame_ancestors"`
+}
+
+// Validate reports malformed header values. Only settings that are sent are
+// checked, so a disabled configuration is always valid.
+func (c SecurityHeadersConfig) Validate() error {
+	if !c.Enabled {
+		return nil
+	}
+
+	if err := validateHeaderSet(c.ContentSecurityPolicy, c.ReferrerPolicy, c.FrameAncestors); err != nil {
+		return fmt.Errorf("security headers: %w", err)
+	}
+	if c.HSTSMaxAge < 0 {
+		return errors.New("security headers: hsts max age must not be negative")
+	}
+
+	for i, o := range c.Overrides {
+		if !strings.HasPrefix(o.Path, "/") {
+			return fmt.Errorf("security headers override %d (%s): path must start with /", i+1, o.Path)
+		}
+		if err := validateHeaderSet(c.resolve(o)); err != nil {
+			return fmt.Errorf("security headers override %d (%s): %w", i+1, o.Path, err)
+		}
+	}
+	return nil
+}
+
+// resolve returns the header values for requests matching o.
+func (c SecurityHeadersConfig) resolve(o SecurityHeadersOverride) (csp, referrer string, ancestors []string) {
+	csp, referrer, ancestors = c.ContentSecurityPolicy, c.ReferrerPolicy, c.FrameAncestors
+	if o.ContentSecurityPolicy != "" {
+		csp = o.ContentSecurityPolicy
+	}
+	if o.ReferrerPolicy != "" {
+		referrer = o.ReferrerPolicy
+	}
+	if len(o.FrameAncestors) > 0 {
+		ancestors = o.FrameAncestors
+	}
+	return csp, referrer, ancestors
+}
+
+func validateHeaderSet(csp, referrer string, ancestors []string) error {
+	if err := validateCSP(csp); err != nil {
+		return err
+	}
+	if len(ancestors) > 0 && hasDirective(csp, "frame-ancestors") {
+		return errors.New("content security policy sets frame-ancestors; use frame_ancestors instead")
+	}
+	for _, source := range ancestors {
+		if source == "" || strings.ContainsAny(source, " ;,") || !validCSPToken(source) {
+			return fmt.Errorf("invalid frame ancestor %q", source)
+		}
+	}
+	if referrer != "" && !slices.Contains(referrerPolicies, referrer) {
+		return fmt.Errorf("invalid referrer policy %q (valid: %s)", referrer, strings.Join(referrerPolicies, ", "))
+	}
+	return nil
+}
+
+// validateCSP catches policies a browser would misread: control characters,
+// empty directive names and unbalanced quotes around keywords such as
+// 'self'. It does not check directive names or source syntax.
+func validateCSP(csp string) error {
+	for _, r := range csp {
+		if r < 0x20 || r == 0x7f {
+			return errors.New("content security policy contains a control character")
+		}
+	}
+	for directive := range strings.SplitSeq(csp, ";") {
+		tokens := strings.Fields(directive)
+		if len(tokens) == 0 {
+			continue
+		}
+		if strings.Contains(tokens[0], "'") {
+			return fmt.Errorf("content security policy directive %q has a quoted name", tokens[0])
+		}
+		for _, token := range tokens[1:] {
+			if !validCSPToken(token) {
+				return fmt.Errorf("content security policy source %q has unbalanced quotes", token)
+			}
+		}
+	}
+	return nil
+}
+
+// validCSPToken reports whether a source expression is either unquoted or a
+// single quoted keyword such as 'self' or 'nonce-abc'.
+func validCSPToken(token string) bool {
+	n := strings.Count(token, "'")
+	if n == 0 {
+		return true
+	}
+	return n == 2 && len(token) > 2 && token[0] == '\'' && token[len(token)-1] == '\''
+}
+
+func hasDirective(csp, name string) bool {
+	for directive := range strings.SplitSeq(csp, ";") {
+		if fields := strings.Fields(directive); len(fields) > 0 && strings.EqualFold(fields[0], name) {
+			return true
+		}
+	}
+	return false
+}
+
+// headerSet is the resolved values of the page headers for one path.
+type headerSet struct {
+	csp          string
+	frameOptions string
+	referrer     string
+}
+
+func newHeaderSet(csp, referrer string, ancestors []string) headerSet {
+	csp = strings.TrimRight(strings.TrimSpace(csp), "; ")
+	if len(ancestors) > 0 {
+		if csp != "" {
+			csp += "; "
+		}
+		csp += "frame-ancestors " + strings.Join(ancestors, " ")
+	}
+
+	var frameOptions string
+	if len(ancestors) == 1 {
+		switch ancestors[0] {
+		case "'none'":
+			frameOptions = "DENY"
+		case "'self'":
+			frameOptions = "SAMEORIGIN"
+		}
+	}
+
+	return headerSet{csp: csp, frameOptions: frameOptions, referrer: referrer}
+}
+
+func (s headerSet) apply(header http.Header) {
+	if s.csp != "" {
+		header.Set("Content-Security-Policy", s.csp)
+	}
+	if s.frameOptions != "" {
+		header.Set("X-Frame-Options", s.frameOptions)
+	}
+	if s.referrer != "" {
+		header.Set("Referrer-Policy", s.referrer)
+	}
+}
+
+type headerOverride struct {
+	path   string
+	prefix bool // path ended in *
+	set    headerSet
+}
+
+// securityHeadersMiddleware sets the headers configured in
+// HandlerConfig.SecurityHeaders before the request is handled.
+func (h *Handler) securityHeadersMiddleware() func(http.Handler) http.Handler {
+	cfg := h.config.SecurityHeaders
+	store := h.config.Mode == stowry.ModeStore
+	pages := !store && cfg.Enabled
+
+	base := newHeaderSet(cfg.ContentSecurityPolicy, cfg.ReferrerPolicy, cfg.FrameAncestors)
+	overrides := make([]headerOverride, 0, len(cfg.Overrides))
+	for _, o := range cfg.Overrides {
+		path, prefix := strings.CutSuffix(o.Path, "*")
+		overrides = append(overrides, headerOverride{path: path, prefix: prefix, set: newHeaderSet(cfg.resolve(o))})
+	}
+
+	var hsts string
+	if cfg.HSTSMaxAge > 0 {
+		hsts = "max-age=" + strconv.Itoa(cfg.HSTSMaxAge)
+		if cfg.HSTSIncludeSubdomains {
+			hsts += "; includeSubDomains"
+		}
+	}
+
+	return func(next http.Handler) http.Handler {
+		if !cfg.NoSniff && !store && !pages {
+			return next
+		}
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if cfg.NoSniff {
+				w.Header().Set("X-Content-Type-Options", "nosniff")
+			}
+
+			if pages && (r.Method == http.MethodGet || r.Method == http.MethodHead) {
+				set := base
+				for _, o := range overrides {
+					if r.URL.Path == o.path || (o.prefix && strings.HasPrefix(r.URL.Path, o.path)) {
+						set = o.set
+						break
+					}
+				}
+				set.apply(w.Header())
+				if hsts != "" && isTLS(r) {
+					w.Header().Set("Strict-Transport-Security", hsts)
+				}
+			}
+
+			if store {
+				w = &noStoreErrorWriter{ResponseWriter: w}
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// isTLS reports whether the client connected over TLS, either to Stowry or
+// to a proxy in front of it.
+func isTLS(r *http.Request) bool {
+	return r.TLS != nil || strings.EqualFold(r.Header.Get("X-Forwarded-Proto"), "https")
+}
+
+// noStoreErrorWriter marks error responses as not cacheable, so a shared
+// cache never keeps an error in place of an object.
+type noStoreErrorWriter struct {
+	http.ResponseWriter
+	wroteHeader bool
+}
+
+func (nw *noStoreErrorWriter) WriteHeader(code int) {
+	if !nw.wroteHeader && code >= http.StatusBadRequest {
+		nw.Header().Set("Cache-Control", "no-store")
+	}
+	if code >= http.StatusOK {
+		nw.wroteHeader = true
+	}
+	nw.ResponseWriter.WriteHeader(code)
+}
+
+func (nw *noStoreErrorWriter) Write(p []byte) (int, error) {
+	nw.wroteHeader = true
+	return nw.ResponseWriter.Write(p)
+}
+
+// Flush forwards to the underlying writer so streamed responses still flush.
+func (nw *noStoreErrorWriter) Flush() {
+	if f, ok := nw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Unwrap exposes the underlying writer to http.ResponseController.
+func (nw *noStoreErrorWriter) Unwrap() http.ResponseWriter {
+	return nw.ResponseWriter
+}
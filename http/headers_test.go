@@ -0,0 +1,176 @@
+package http_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/sagarc03/stowry"
+	stowryhttp "github.com/sagarc03/stowry/http"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSecurityHeadersConfig_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  stowryhttp.SecurityHeadersConfig
+		wantErr string
+	}{
+		{
+			name:   "disabled is not checked",
+			config: stowryhttp.SecurityHeadersConfig{ContentSecurityPolicy: "default-src 'self"},
+		},
+		{
+			name: "valid",
+			config: stowryhttp.SecurityHeadersConfig{
+				Enabled:               true,
+				ContentSecurityPolicy: "default-src 'self'; script-src 'self' 'sha256-abc=' https://cdn.example.com; img-src * data:;",
+				ReferrerPolicy:        "no-referrer",
+				FrameAncestors:        []string{"'self'", "https://*.example.com"},
+				Overrides:             []stowryhttp.SecurityHeadersOverride{{Path: "/widget/*", FrameAncestors: []string{"*"}}},
+			},
+		},
+		{
+			name:    "unbalanced quote",
+			config:  stowryhttp.SecurityHeadersConfig{Enabled: true, ContentSecurityPolicy: "default-src 'self"},
+			wantErr: "unbalanced quotes",
+		},
+		{
+			name:    "quote spanning sources",
+			config:  stowryhttp.SecurityHeadersConfig{Enabled: true, ContentSecurityPolicy: "default-src 'self https://a.com'"},
+			wantErr: "unbalanced quotes",
+		},
+		{
+			name:    "quoted directive name",
+			config:  stowryhttp.SecurityHeadersConfig{Enabled: true, ContentSecurityPolicy: "'default-src' 'self'"},
+			wantErr: "quoted name",
+		},
+		{
+			name:    "control character",
+			config:  stowryhttp.SecurityHeadersConfig{Enabled: true, ContentSecurityPolicy: "default-src 'self'\r\nSet-Cookie: x"},
+			wantErr: "control character",
+		},
+		{
+			name:    "frame-ancestors in both places",
+			config:  stowryhttp.SecurityHeadersConfig{Enabled: true, ContentSecurityPolicy: "frame-ancestors 'none'", FrameAncestors: []string{"'self'"}},
+			wantErr: "use frame_ancestors",
+		},
+		{
+			name:    "invalid referrer policy",
+			config:  stowryhttp.SecurityHeadersConfig{Enabled: true, ReferrerPolicy: "never"},
+			wantErr: "invalid referrer policy",
+		},
+		{
+			name:    "invalid frame ancestor",
+			config:  stowryhttp.SecurityHeadersConfig{Enabled: true, FrameAncestors: []string{"'self' https://a.com"}},
+			wantErr: "invalid frame ancestor",
+		},
+		{
+			name: "override inherits frame ancestors",
+			config: stowryhttp.SecurityHeadersConfig{
+				Enabled:        true,
+				FrameAncestors: []string{"'self'"},
+				Overrides:      []stowryhttp.SecurityHeadersOverride{{Path: "/embed/*", ContentSecurityPolicy: "frame-ancestors *"}},
+			},
+			wantErr: "override 1 (/embed/*)",
+		},
+		{
+			name: "relative override path",
+			config: stowryhttp.SecurityHeadersConfig{
+				Enabled:   true,
+				Overrides: []stowryhttp.SecurityHeadersOverride{{Path: "widget/*"}},
+			},
+			wantErr: "must start with /",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.config.Validate()
+			if tt.wantErr == "" {
+				assert.NoError(t, err)
+				return
+			}
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.wantErr)
+		})
+	}
+}
+
+func TestHandler_SecurityHeaders(t *testing.T) {
+	headers := stowryhttp.SecurityHeadersConfig{
+		NoSniff:               true,
+		Enabled:               true,
+		ContentSecurityPolicy: "default-src 'self';",
+		ReferrerPolicy:        "same-origin",
+		FrameAncestors:        []string{"'self'"},
+		HSTSMaxAge:            31536000,
+		Overrides: []stowryhttp.SecurityHeadersOverride{
+			{Path: "/widget/*", FrameAncestors: []string{"https://partner.example.com"}},
+		},
+	}
+
+	serve := func(t *testing.T, mode stowry.ServerMode, req *http.Request) *httptest.ResponseRecorder {
+		t.Helper()
+		service := new(MockService)
+		service.On("Get", mock.Anything, mock.Anything).Return(
+			stowry.MetaData{Path: "page.html", ContentType: "text/html", Etag: "abc"},
+			readSeekNopCloser{strings.NewReader("<html></html>")},
+			nil,
+		)
+		service.On("Info", mock.Anything, mock.Anything).Return(stowry.MetaData{}, stowry.ErrNotFound)
+		h, err := stowryhttp.New(&stowryhttp.HandlerConfig{Mode: mode, SecurityHeaders: headers}, service)
+		require.NoError(t, err)
+		rec := httptest.NewRecorder()
+		h.Router().ServeHTTP(rec, req)
+		return rec
+	}
+
+	t.Run("page headers in static mode", func(t *testing.T) {
+		rec := serve(t, stowry.ModeStatic, httptest.NewRequest(http.MethodGet, "/page.html", nil))
+
+		assert.Equal(t, "nosniff", rec.Header().Get("X-Content-Type-Options"))
+		assert.Equal(t, "default-src 'self'; frame-ancestors 'self'", rec.Header().Get("Content-Security-Policy"))
+		assert.Equal(t, "SAMEORIGIN", rec.Header().Get("X-Frame-Options"))
+		assert.Equal(t, "same-origin", rec.Header().Get("Referrer-Policy"))
+		assert.Empty(t, rec.Header().Get("Strict-Transport-Security"), "plain HTTP gets no HSTS")
+	})
+
+	t.Run("HSTS behind a TLS proxy", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/page.html", nil)
+		req.Header.Set("X-Forwarded-Proto", "https")
+
+		rec := serve(t, stowry.ModeSPA, req)
+
+		assert.Equal(t, "max-age=31536000", rec.Header().Get("Strict-Transport-Security"))
+	})
+
+	t.Run("override relaxes frame ancestors", func(t *testing.T) {
+		rec := serve(t, stowry.ModeStatic, httptest.NewRequest(http.MethodGet, "/widget/embed.html", nil))
+
+		assert.Equal(t, "default-src 'self'; frame-ancestors https://partner.example.com", rec.Header().Get("Content-Security-Policy"))
+		assert.Empty(t, rec.Header().Get("X-Frame-Options"))
+		assert.Equal(t, "same-origin", rec.Header().Get("Referrer-Policy"))
+	})
+
+	t.Run("store mode gets nosniff only", func(t *testing.T) {
+		rec := serve(t, stowry.ModeStore, httptest.NewRequest(http.MethodGet, "/page.html", nil))
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "nosniff", rec.Header().Get("X-Content-Type-Options"))
+		assert.Empty(t, rec.Header().Get("Content-Security-Policy"))
+		assert.Empty(t, rec.Header().Get("Referrer-Policy"))
+		assert.Empty(t, rec.Header().Get("Cache-Control"))
+	})
+
+	t.Run("store mode errors are not cached", func(t *testing.T) {
+		rec := serve(t, stowry.ModeStore, httptest.NewRequest(http.MethodGet, "/bad/../path", nil))
+
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		assert.Equal(t, "no-store", rec.Header().Get("Cache-Control"))
+		assert.Equal(t, "nosniff", rec.Header().Get("X-Content-Type-Options"))
+	})
+}
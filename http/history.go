@@ -0,0 +1,79 @@
+package http
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/sagarc03/stowry"
+)
+
+// HistoryService is an optional Service extension for per-path history. When
+// the service implements it, store mode serves GET /{path}?history with write
+// authentication, since history names the keys that changed an object and
+// covers deleted objects.
+type HistoryService interface {
+	History(ctx context.Context, q stowry.HistoryQuery) (stowry.History, error)
+}
+
+// historyService returns the service as a HistoryService if history is
+// available in the configured mode.
+func (h *Handler) historyService() (HistoryService, bool) {
+	if h.config.Mode != stowry.ModeStore {
+		return nil, false
+	}
+	hs, ok := h.service.(HistoryService)
+	return hs, ok
+}
+
+// isHistoryRequest reports whether a GET asks for the history of a path.
+func isHistoryRequest(r *http.Request) bool {
+	return r.URL.Query().Has("history")
+}
+
+// handleHistory serves GET /{path}?history&after=&limit=. The router only
+// reaches it after write authentication.
+func (h *Handler) handleHistory(w http.ResponseWriter, r *http.Request) {
+	hs, ok := h.historyService()
+	if !ok {
+		h.handleError(w, r, stowry.ErrNotSupported)
+		return
+	}
+
+	path := strings.TrimPrefix(r.URL.Path, "/")
+	if !stowry.IsValidPath(path) {
+		h.writeError(w, r, http.StatusBadRequest, "invalid_path", "Invalid path")
+		return
+	}
+
+	query := r.URL.Query()
+	q := stowry.HistoryQuery{Path: path, Limit: stowry.DefaultChangeLimit}
+
+	if after := query.Get("after"); after != "" {
+		parsed, err := strconv.ParseInt(after, 10, 64)
+		if err != nil || parsed < 0 {
+			h.writeError(w, r, http.StatusBadRequest, "invalid_parameter", "after must be a non-negative integer")
+			return
+		}
+		q.After = parsed
+	}
+
+	if limit := query.Get("limit"); limit != "" {
+		parsed, err := strconv.Atoi(limit)
+		if err != nil {
+			h.writeError(w, r, http.StatusBadRequest, "invalid_parameter", "limit must be a valid integer")
+			return
+		}
+		q.Limit = max(1, min(1000, parsed))
+	}
+
+	history, err := hs.History(r.Context(), q)
+	if err != nil {
+		h.handleError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	_ = WriteJSON(w, http.StatusOK, history)
+}
@@ -0,0 +1,127 @@
+package http_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sagarc03/stowry"
+	stowryhttp "github.com/sagarc03/stowry/http"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// historyMockService is a MockService that reads per-path history.
+type historyMockService struct {
+	MockService
+}
+
+func (m *historyMockService) History(ctx context.Context, q stowry.HistoryQuery) (stowry.History, error) {
+	args := m.Called(ctx, q)
+	return args.Get(0).(stowry.History), args.Error(1)
+}
+
+func TestHandler_History(t *testing.T) {
+	at := time.Date(2024, 1, 15, 10, 30, 0, 0, time.UTC)
+
+	serve := func(t *testing.T, mode stowry.ServerMode, service stowryhttp.Service, req *http.Request) *httptest.ResponseRecorder {
+		t.Helper()
+		// Reads are public; only the write verifier guards history
+		config := &stowryhttp.HandlerConfig{Mode: mode}
+		if mode == stowry.ModeStore {
+			config.WriteVerifier = headerVerifier{}
+		}
+		h, err := stowryhttp.New(config, service)
+		require.NoError(t, err)
+		rec := httptest.NewRecorder()
+		h.Router().ServeHTTP(rec, req)
+		return rec
+	}
+
+	authed := func(target string) *http.Request {
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		req.Header.Set("X-Test-Auth", "ok")
+		return req
+	}
+
+	t.Run("returns the history page", func(t *testing.T) {
+		service := new(historyMockService)
+		service.On("History", mock.Anything, stowry.HistoryQuery{Path: "docs/a.txt", After: 3, Limit: 2}).Return(stowry.History{
+			Path: "docs/a.txt",
+			Changes: []stowry.Change{
+				{Seq: 4, Kind: stowry.ChangeCreate, Path: "docs/a.txt", ETag: "new", Size: 3, At: at, PreviousETag: "old", Actor: "AKALICE"},
+				{Seq: 9, Kind: stowry.ChangeDelete, Path: "docs/a.txt", ETag: "new", Size: 3, At: at},
+			},
+			Next: 9,
+		}, nil)
+
+		rec := serve(t, stowry.ModeStore, service, authed("/docs/a.txt?history&after=3&limit=2"))
+
+		require.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "no-store", rec.Header().Get("Cache-Control"))
+		assert.JSONEq(t, `{
+			"path": "docs/a.txt",
+			"changes": [
+				{"seq": 4, "kind": "create", "path": "docs/a.txt", "etag": "new", "file_size_bytes": 3, "at": "2024-01-15T10:30:00Z", "previous_etag": "old", "actor": "AKALICE"},
+				{"seq": 9, "kind": "delete", "path": "docs/a.txt", "etag": "new", "file_size_bytes": 3, "at": "2024-01-15T10:30:00Z"}
+			],
+			"next": 9
+		}`, rec.Body.String())
+		service.AssertNotCalled(t, "Get", mock.Anything, mock.Anything)
+	})
+
+	t.Run("clamps the limit", func(t *testing.T) {
+		service := new(historyMockService)
+		service.On("History", mock.Anything, stowry.HistoryQuery{Path: "a.txt", Limit: 1000}).Return(stowry.History{Path: "a.txt", Changes: []stowry.Change{}}, nil)
+
+		rec := serve(t, stowry.ModeStore, service, authed("/a.txt?history&limit=5000"))
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		var got stowry.History
+		require.NoError(t, json.NewDecoder(rec.Body).Decode(&got))
+		assert.Empty(t, got.Changes)
+		assert.Zero(t, got.Next)
+	})
+
+	t.Run("rejects a bad cursor", func(t *testing.T) {
+		rec := serve(t, stowry.ModeStore, new(historyMockService), authed("/a.txt?history&after=-1"))
+
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		assert.Contains(t, rec.Body.String(), "invalid_parameter")
+	})
+
+	t.Run("requires write authentication when reads are public", func(t *testing.T) {
+		service := new(historyMockService)
+
+		rec := serve(t, stowry.ModeStore, service, httptest.NewRequest(http.MethodGet, "/a.txt?history", nil))
+
+		assert.Equal(t, http.StatusUnauthorized, rec.Code)
+		service.AssertNotCalled(t, "History", mock.Anything, mock.Anything)
+	})
+
+	t.Run("advertised in capabilities", func(t *testing.T) {
+		rec := serve(t, stowry.ModeStore, new(historyMockService), httptest.NewRequest(http.MethodGet, stowryhttp.CapabilitiesPath, nil))
+
+		assert.Contains(t, rec.Body.String(), `"history"`)
+	})
+
+	t.Run("ignored outside store mode", func(t *testing.T) {
+		service := new(historyMockService)
+		service.On("Get", mock.Anything, "a.txt").Return(
+			stowry.MetaData{Path: "a.txt", ContentType: "text/plain", Etag: "abc"},
+			readSeekNopCloser{strings.NewReader("live content")},
+			nil,
+		)
+
+		rec := serve(t, stowry.ModeStatic, service, httptest.NewRequest(http.MethodGet, "/a.txt?history", nil))
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "live content", rec.Body.String())
+		service.AssertNotCalled(t, "History", mock.Anything, mock.Anything)
+	})
+}
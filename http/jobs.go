@@ -0,0 +1,217 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sagarc03/stowry"
+	"github.com/sagarc03/stowry/progress"
+)
+
+// DefaultJobHistory is how many finished jobs a JobManager keeps for
+// GET /admin/jobs/{id}.
+const DefaultJobHistory = 100
+
+// ErrJobRunning is returned when a job of the same kind is already running.
+var ErrJobRunning = errors.New("job already running")
+
+// JobKind names the operation an admin job runs. At most one job of each kind
+// runs at a time.
+type JobKind string
+
+const (
+	JobPopulate JobKind = "populate" // Record files in storage that have no metadata
+	JobCleanup  JobKind = "cleanup"  // Remove the files of soft-deleted objects
+	JobVerify   JobKind = "verify"   // Check that every object has its file
+)
+
+// JobStatus is the state of an admin job.
+type JobStatus string
+
+const (
+	JobRunning   JobStatus = "running"
+	JobSucceeded JobStatus = "succeeded"
+	JobFailed    JobStatus = "failed"
+	JobCanceled  JobStatus = "canceled"
+)
+
+// Job is a snapshot of an admin job.
+type Job struct {
+	ID         string      `json:"id"`
+	Kind       JobKind     `json:"kind"`
+	Status     JobStatus   `json:"status"`
+	StartedAt  time.Time   `json:"started_at"`
+	FinishedAt *time.Time  `json:"finished_at,omitempty"`
+	Progress   JobProgress `json:"progress"`
+	// Canceling is set once cancellation was requested for a running job.
+	Canceling bool `json:"canceling,omitempty"`
+	// Report is the operation's final report, set when it finished.
+	Report any    `json:"report,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+// JobProgress is how far a job has got.
+type JobProgress struct {
+	Processed int64 `json:"processed"`
+	Total     int64 `json:"total,omitempty"` // 0 while unknown
+	Bytes     int64 `json:"bytes"`
+	Errors    int   `json:"errors"`
+}
+
+// JobFunc runs the operation of a job. It passes onProgress to the
+// operation and returns its final report. It must stop when ctx is done.
+type JobFunc func(ctx context.Context, onProgress progress.Func) (any, error)
+
+// JobManager runs admin jobs in the background and keeps their status in
+// memory, so a restart forgets every job and cancels the running ones. It is
+// safe for concurrent use.
+type JobManager struct {
+	ctx     context.Context
+	history int
+
+	mu       sync.Mutex
+	jobs     map[string]*jobState
+	running  map[JobKind]*jobState
+	finished []string // IDs of finished jobs, oldest first
+}
+
+type jobState struct {
+	job    Job
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// NewJobManager creates a JobManager whose jobs are canceled when ctx is
+// done. It keeps the last DefaultJobHistory finished jobs.
+func NewJobManager(ctx context.Context) *JobManager {
+	return &JobManager{
+		ctx:     ctx,
+		history: DefaultJobHistory,
+		jobs:    make(map[string]*jobState),
+		running: make(map[JobKind]*jobState),
+	}
+}
+
+// Start runs fn as a new job of kind in the background and returns its
+// snapshot. When a job of kind is already running, it returns that job's
+// snapshot with ErrJobRunning.
+func (m *JobManager) Start(kind JobKind, fn JobFunc) (Job, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if st, ok := m.running[kind]; ok {
+		return st.job, ErrJobRunning
+	}
+
+	ctx, cancel := context.WithCancel(m.ctx)
+	st := &jobState{
+		job: Job{
+			ID:        uuid.NewString(),
+			Kind:      kind,
+			Status:    JobRunning,
+			StartedAt: time.Now().UTC(),
+		},
+		cancel: cancel,
+		done:   make(chan struct{}),
+	}
+	m.jobs[st.job.ID] = st
+	m.running[kind] = st
+
+	go m.run(ctx, st, fn)
+
+	return st.job, nil
+}
+
+func (m *JobManager) run(ctx context.Context, st *jobState, fn JobFunc) {
+	defer close(st.done)
+	defer st.cancel()
+
+	report, err := fn(ctx, func(e progress.Event) {
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		st.job.Progress = JobProgress{Processed: e.Processed, Total: e.Total, Bytes: e.Bytes, Errors: e.Errors}
+	})
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	finished := time.Now().UTC()
+	st.job.FinishedAt = &finished
+	st.job.Report = report
+	switch {
+	case ctx.Err() != nil:
+		st.job.Status = JobCanceled
+	case err != nil:
+		st.job.Status = JobFailed
+	default:
+		st.job.Status = JobSucceeded
+	}
+	if err != nil {
+		st.job.Error = err.Error()
+	}
+	st.job.Canceling = false
+
+	delete(m.running, st.job.Kind)
+	m.finished = append(m.finished, st.job.ID)
+	for len(m.finished) > m.history {
+		delete(m.jobs, m.finished[0])
+		m.finished = m.finished[1:]
+	}
+
+	slog.Info("admin job finished", "job", st.job.ID, "kind", st.job.Kind, "status", st.job.Status,
+		"processed", st.job.Progress.Processed, "errors", st.job.Progress.Errors,
+		"duration", finished.Sub(st.job.StartedAt), "error", st.job.Error)
+}
+
+// Get returns the snapshot of job id, or stowry.ErrNotFound for an unknown
+// or forgotten job.
+func (m *JobManager) Get(id string) (Job, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	st, ok := m.jobs[id]
+	if !ok {
+		return Job{}, stowry.ErrNotFound
+	}
+	return st.job, nil
+}
+
+// Cancel requests that job id stops and returns its snapshot, in which a job
+// still running has Canceling set. Canceling a finished job does nothing.
+// Returns stowry.ErrNotFound for an unknown job.
+func (m *JobManager) Cancel(id string) (Job, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	st, ok := m.jobs[id]
+	if !ok {
+		return Job{}, stowry.ErrNotFound
+	}
+	if st.job.Status == JobRunning {
+		st.job.Canceling = true
+		st.cancel()
+	}
+	return st.job, nil
+}
+
+// Wait blocks until job id finishes or ctx is done and returns its snapshot.
+// Returns stowry.ErrNotFound for an unknown job.
+func (m *JobManager) Wait(ctx context.Context, id string) (Job, error) {
+	m.mu.Lock()
+	st, ok := m.jobs[id]
+	m.mu.Unlock()
+	if !ok {
+		return Job{}, stowry.ErrNotFound
+	}
+
+	select {
+	case <-st.done:
+	case <-ctx.Done():
+		return Job{}, ctx.Err()
+	}
+	return m.Get(id)
+}
@@ -0,0 +1,144 @@
+package http_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/sagarc03/stowry"
+	stowryhttp "github.com/sagarc03/stowry/http"
+	"github.com/sagarc03/stowry/progress"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// waitJob waits for job id to finish.
+func waitJob(t *testing.T, jobs *stowryhttp.JobManager, id string) stowryhttp.Job {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	job, err := jobs.Wait(ctx, id)
+	require.NoError(t, err)
+	return job
+}
+
+func TestJobManager(t *testing.T) {
+	t.Run("runs a job to completion", func(t *testing.T) {
+		jobs := stowryhttp.NewJobManager(context.Background())
+
+		started, err := jobs.Start(stowryhttp.JobVerify, func(_ context.Context, onProgress progress.Func) (any, error) {
+			onProgress(progress.Event{Processed: 3, Total: 3, Bytes: 30, Errors: 1})
+			return "report", nil
+		})
+		require.NoError(t, err)
+		assert.Equal(t, stowryhttp.JobRunning, started.Status)
+		assert.Equal(t, stowryhttp.JobVerify, started.Kind)
+
+		job := waitJob(t, jobs, started.ID)
+		assert.Equal(t, stowryhttp.JobSucceeded, job.Status)
+		assert.Equal(t, stowryhttp.JobProgress{Processed: 3, Total: 3, Bytes: 30, Errors: 1}, job.Progress)
+		assert.Equal(t, "report", job.Report)
+		assert.NotNil(t, job.FinishedAt)
+		assert.Empty(t, job.Error)
+	})
+
+	t.Run("records a failure", func(t *testing.T) {
+		jobs := stowryhttp.NewJobManager(context.Background())
+
+		started, err := jobs.Start(stowryhttp.JobPopulate, func(context.Context, progress.Func) (any, error) {
+			return nil, errors.New("storage unavailable")
+		})
+		require.NoError(t, err)
+
+		job := waitJob(t, jobs, started.ID)
+		assert.Equal(t, stowryhttp.JobFailed, job.Status)
+		assert.Equal(t, "storage unavailable", job.Error)
+	})
+
+	t.Run("one job of each kind at a time", func(t *testing.T) {
+		jobs := stowryhttp.NewJobManager(context.Background())
+		release := make(chan struct{})
+		block := func(context.Context, progress.Func) (any, error) {
+			<-release
+			return nil, nil
+		}
+
+		first, err := jobs.Start(stowryhttp.JobCleanup, block)
+		require.NoError(t, err)
+
+		running, err := jobs.Start(stowryhttp.JobCleanup, block)
+		assert.ErrorIs(t, err, stowryhttp.ErrJobRunning)
+		assert.Equal(t, first.ID, running.ID)
+
+		other, err := jobs.Start(stowryhttp.JobVerify, block)
+		assert.NoError(t, err, "a different kind may run alongside")
+
+		close(release)
+		waitJob(t, jobs, first.ID)
+		waitJob(t, jobs, other.ID)
+
+		_, err = jobs.Start(stowryhttp.JobCleanup, block)
+		assert.NoError(t, err, "the kind is free again once the job finished")
+	})
+
+	t.Run("cancel stops the job", func(t *testing.T) {
+		jobs := stowryhttp.NewJobManager(context.Background())
+
+		started, err := jobs.Start(stowryhttp.JobVerify, func(ctx context.Context, _ progress.Func) (any, error) {
+			<-ctx.Done()
+			return nil, ctx.Err()
+		})
+		require.NoError(t, err)
+
+		canceling, err := jobs.Cancel(started.ID)
+		require.NoError(t, err)
+		assert.True(t, canceling.Canceling)
+
+		job := waitJob(t, jobs, started.ID)
+		assert.Equal(t, stowryhttp.JobCanceled, job.Status)
+		assert.False(t, job.Canceling)
+	})
+
+	t.Run("jobs stop with the manager context", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		jobs := stowryhttp.NewJobManager(ctx)
+
+		started, err := jobs.Start(stowryhttp.JobPopulate, func(ctx context.Context, _ progress.Func) (any, error) {
+			<-ctx.Done()
+			return nil, ctx.Err()
+		})
+		require.NoError(t, err)
+		cancel()
+
+		assert.Equal(t, stowryhttp.JobCanceled, waitJob(t, jobs, started.ID).Status)
+	})
+
+	t.Run("unknown job", func(t *testing.T) {
+		jobs := stowryhttp.NewJobManager(context.Background())
+
+		_, err := jobs.Get("nope")
+		assert.ErrorIs(t, err, stowry.ErrNotFound)
+		_, err = jobs.Cancel("nope")
+		assert.ErrorIs(t, err, stowry.ErrNotFound)
+		_, err = jobs.Wait(context.Background(), "nope")
+		assert.ErrorIs(t, err, stowry.ErrNotFound)
+	})
+
+	t.Run("forgets the oldest finished jobs", func(t *testing.T) {
+		jobs := stowryhttp.NewJobManager(context.Background())
+		noop := func(context.Context, progress.Func) (any, error) { return nil, nil }
+
+		first, err := jobs.Start(stowryhttp.JobVerify, noop)
+		require.NoError(t, err)
+		waitJob(t, jobs, first.ID)
+		for range stowryhttp.DefaultJobHistory {
+			job, err := jobs.Start(stowryhttp.JobVerify, noop)
+			require.NoError(t, err)
+			waitJob(t, jobs, job.ID)
+		}
+
+		_, err = jobs.Get(first.ID)
+		assert.ErrorIs(t, err, stowry.ErrNotFound)
+	})
+}
@@ -0,0 +1,257 @@
+package jwtauth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math/big"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxDocumentSize bounds discovery documents and key sets.
+const maxDocumentSize = 1 << 20
+
+// ecdsaCurves maps the ECDSA algorithms to the curve their keys must use.
+var ecdsaCurves = map[string]string{
+	"ES256": "P-256",
+	"ES384": "P-384",
+	"ES512": "P-521",
+}
+
+// jwk is a JSON Web Key as served in a key set.
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	Crv string `json:"crv"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// publicKey is a parsed signing key of the key set.
+type publicKey struct {
+	jwk
+	key crypto.PublicKey
+}
+
+// keySet caches the provider's signing keys.
+type keySet struct {
+	client     *http.Client
+	issuer     string
+	ttl        time.Duration
+	minRefresh time.Duration
+
+	mu          sync.Mutex
+	url         string // key set URL, empty until discovered
+	keys        []publicKey
+	fetched     time.Time // when keys were last fetched
+	lastAttempt time.Time // when a fetch was last attempted
+}
+
+// key returns the key kid for a token signed with alg, which needs a key of
+// type kty. A token without kid may use the only key of its type.
+//
+// Keys are fetched when none are cached, and when the cache is older than
+// the TTL or kid is not cached, but then not more often than minRefresh. If
+// a fetch fails the cached keys are kept.
+func (s *keySet) key(ctx context.Context, kid, alg, kty string) (crypto.PublicKey, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	key, found := s.find(kid, kty)
+	stale := now.Sub(s.fetched) >= s.ttl
+	if s.keys == nil || ((stale || !found) && now.Sub(s.lastAttempt) >= s.minRefresh) {
+		if err := s.refresh(ctx, now); err != nil {
+			if !found {
+				return nil, err
+			}
+			slog.Warn("jwt key set refresh failed, using cached keys", "error", err)
+		} else {
+			key, found = s.find(kid, kty)
+		}
+	}
+
+	if !found {
+		return nil, fmt.Errorf("%w: no %s key %q in the key set", ErrInvalidToken, kty, kid)
+	}
+	if key.Alg != "" && key.Alg != alg {
+		return nil, fmt.Errorf("%w: key %q is for %s, not %s", ErrInvalidToken, kid, key.Alg, alg)
+	}
+	if crv, ok := ecdsaCurves[alg]; ok && key.Crv != crv {
+		return nil, fmt.Errorf("%w: key %q is on %s, %s needs %s", ErrInvalidToken, kid, key.Crv, alg, crv)
+	}
+	if alg == "EdDSA" && key.Crv != "Ed25519" {
+		return nil, fmt.Errorf("%w: key %q is on %s, EdDSA needs Ed25519", ErrInvalidToken, kid, key.Crv)
+	}
+	return key.key, nil
+}
+
+func (s *keySet) find(kid, kty string) (publicKey, bool) {
+	var match []publicKey
+	for _, k := range s.keys {
+		if k.Kty == kty && (kid == "" || k.Kid == kid) {
+			match = append(match, k)
+		}
+	}
+	if len(match) != 1 {
+		return publicKey{}, false
+	}
+	return match[0], true
+}
+
+// refresh fetches the key set, discovering its URL first if needed.
+func (s *keySet) refresh(ctx context.Context, now time.Time) error {
+	s.lastAttempt = now
+
+	if s.url == "" {
+		url, err := s.discover(ctx)
+		if err != nil {
+			return err
+		}
+		s.url = url
+	}
+
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := s.get(ctx, s.url, &set); err != nil {
+		return fmt.Errorf("fetch jwt key set: %w", err)
+	}
+
+	keys := make([]publicKey, 0, len(set.Keys))
+	for _, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		key, err := k.publicKey()
+		if err != nil {
+			slog.Warn("skipping jwt key", "kid", k.Kid, "error", err)
+			continue
+		}
+		keys = append(keys, publicKey{jwk: k, key: key})
+	}
+
+	s.keys = keys
+	s.fetched = now
+	return nil
+}
+
+// discover returns the key set URL from the issuer's OpenID configuration.
+func (s *keySet) discover(ctx context.Context) (string, error) {
+	var doc struct {
+		Issuer  string `json:"issuer"`
+		JWKSURI string `json:"jwks_uri"`
+	}
+	url := strings.TrimSuffix(s.issuer, "/") + "/.well-known/openid-configuration"
+	if err := s.get(ctx, url, &doc); err != nil {
+		return "", fmt.Errorf("openid discovery: %w", err)
+	}
+	if doc.Issuer != s.issuer {
+		return "", fmt.Errorf("openid discovery: issuer %q does not match %q", doc.Issuer, s.issuer)
+	}
+	if err := checkURL("jwks_uri", doc.JWKSURI); err != nil {
+		return "", fmt.Errorf("openid discovery: %w", err)
+	}
+	return doc.JWKSURI, nil
+}
+
+func (s *keySet) get(ctx context.Context, url string, v any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s: %s", url, resp.Status)
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxDocumentSize)).Decode(v); err != nil {
+		return fmt.Errorf("GET %s: %w", url, err)
+	}
+	return nil
+}
+
+// publicKey parses the key material of k.
+func (k jwk) publicKey() (crypto.PublicKey, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := decodeInt(k.N)
+		if err != nil {
+			return nil, fmt.Errorf("n: %w", err)
+		}
+		e, err := decodeInt(k.E)
+		if err != nil {
+			return nil, fmt.Errorf("e: %w", err)
+		}
+		if !e.IsInt64() || e.Int64() < 3 || e.Int64() > 1<<31-1 {
+			return nil, errors.New("unsupported exponent")
+		}
+		if n.BitLen() < 2048 {
+			return nil, errors.New("RSA keys must be at least 2048 bits")
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		size := (curve.Params().BitSize + 7) / 8
+		x, errX := base64.RawURLEncoding.DecodeString(k.X)
+		y, errY := base64.RawURLEncoding.DecodeString(k.Y)
+		if errX != nil || errY != nil || len(x) != size || len(y) != size {
+			return nil, errors.New("invalid coordinates")
+		}
+		return ecdsa.ParseUncompressedPublicKey(curve, append(append([]byte{4}, x...), y...))
+	case "OKP":
+		if k.Crv != "Ed25519" {
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil || len(x) != ed25519.PublicKeySize {
+			return nil, errors.New("invalid Ed25519 key")
+		}
+		return ed25519.PublicKey(x), nil
+	default:
+		return nil, fmt.Errorf("unsupported key type %q", k.Kty)
+	}
+}
+
+func decodeInt(s string) (*big.Int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	if len(b) == 0 {
+		return nil, errors.New("empty")
+	}
+	return new(big.Int).SetBytes(b), nil
+}
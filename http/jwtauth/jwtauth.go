@@ -0,0 +1,289 @@
+// Package jwtauth authenticates requests bearing a JWT issued by an OpenID
+// Connect provider, for deployments where people sign in through SSO rather
+// than carrying presigned URLs.
+//
+// An Authenticator is a stowryhttp.Authenticator; add it to
+// HandlerConfig.Authenticators ahead of the signature verifier:
+//
+//	jwt, err := jwtauth.New(jwtauth.Config{
+//	    Enabled:  true,
+//	    Issuer:   "https://login.example.com",
+//	    Audience: "stowry",
+//	})
+//	if err != nil {
+//	    log.Fatal(err)
+//	}
+//	handlerCfg.Authenticators = []stowryhttp.Authenticator{jwt}
+//
+// The token is read from an "Authorization: Bearer" header or, when
+// Config.Cookie is set, from that cookie. Its signature is checked against
+// the provider's JSON Web Key Set, found through OpenID discovery unless
+// Config.JWKSURL is set, and its iss, aud, exp and nbf claims are checked.
+// RSA (RS256, RS384, RS512, PS256, PS384, PS512), ECDSA (ES256, ES384,
+// ES512) and Ed25519 (EdDSA) signatures are accepted; unsigned and
+// HMAC-signed tokens are rejected.
+//
+// Keys are cached for Config.CacheTTL. A token signed with a key that is not
+// cached refetches the key set, at most once per Config.MinRefreshInterval,
+// so keys the provider rotates in are picked up without a restart.
+package jwtauth
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	stowryhttp "github.com/sagarc03/stowry/http"
+)
+
+// Defaults used for zero Config fields.
+const (
+	DefaultSubjectClaim = "sub"
+	DefaultCacheTTL     = 3600 // seconds
+)
+
+// ErrInvalidToken is returned for a token that is malformed, badly signed,
+// or whose claims do not match the Config.
+var ErrInvalidToken = errors.New("invalid token")
+
+// Config configures an Authenticator.
+type Config struct {
+	Enabled bool `mapstructure:"enabled"`
+	// Issuer is the provider's issuer URL; tokens must carry it as iss. It
+	// is also where OpenID discovery looks for the key set.
+	Issuer string `mapstructure:"issuer"`
+	// Audience must be one of the token's aud values.
+	Audience string `mapstructure:"audience"`
+	// JWKSURL is the provider's key set. Empty discovers it from
+	// Issuer/.well-known/openid-configuration.
+	JWKSURL string `mapstructure:"jwks_url"`
+	// Cookie, if set, names a cookie to read the token from when the
+	// request has no Authorization header.
+	Cookie string `mapstructure:"cookie"`
+	// SubjectClaim names the claim identifying the user in logs. Empty uses
+	// DefaultSubjectClaim.
+	SubjectClaim string `mapstructure:"subject_claim"`
+	// PermissionsClaim names a claim, such as "groups" or "scope", whose
+	// values grant permissions. It may be a string array or a
+	// space-separated string. Empty grants DefaultPermissions only.
+	PermissionsClaim string `mapstructure:"permissions_claim"`
+	// Permissions maps values of PermissionsClaim, compared without regard
+	// to case, to the permissions they grant. Empty treats the values
+	// themselves as permission names, so a "write" value grants write.
+	Permissions map[string][]stowryhttp.Permission `mapstructure:"permissions"`
+	// DefaultPermissions are granted to every valid token. nil grants read;
+	// an empty slice grants nothing beyond PermissionsClaim.
+	DefaultPermissions []stowryhttp.Permission `mapstructure:"default_permissions"`
+	// Leeway allows for clock skew when checking exp and nbf, in seconds.
+	Leeway int `mapstructure:"leeway"`
+	// CacheTTL is how long fetched keys are used before the key set is
+	// fetched again, in seconds. 0 uses DefaultCacheTTL.
+	CacheTTL int `mapstructure:"cache_ttl"`
+	// MinRefreshInterval is the least time between key set fetches caused
+	// by tokens signed with an unknown key, in seconds. 0 refetches for
+	// every such token.
+	MinRefreshInterval int `mapstructure:"min_refresh_interval"`
+	// HTTPClient fetches discovery documents and key sets. nil uses a
+	// client with a 10 second timeout.
+	HTTPClient *http.Client `mapstructure:"-"`
+}
+
+// Validate checks the settings of an enabled Config.
+func (c Config) Validate() error {
+	if !c.Enabled {
+		return nil
+	}
+	if c.Issuer == "" {
+		return errors.New("jwt: issuer is required")
+	}
+	if err := checkURL("issuer", c.Issuer); err != nil {
+		return err
+	}
+	if c.JWKSURL != "" {
+		if err := checkURL("jwks_url", c.JWKSURL); err != nil {
+			return err
+		}
+	}
+	if c.Audience == "" {
+		return errors.New("jwt: audience is required")
+	}
+	if c.Leeway < 0 || c.CacheTTL < 0 || c.MinRefreshInterval < 0 {
+		return errors.New("jwt: leeway, cache_ttl and min_refresh_interval must not be negative")
+	}
+	if len(c.Permissions) > 0 && c.PermissionsClaim == "" {
+		return errors.New("jwt: permissions requires permissions_claim")
+	}
+	if err := checkPermissions("default_permissions", c.DefaultPermissions); err != nil {
+		return err
+	}
+	for value, perms := range c.Permissions {
+		if err := checkPermissions(fmt.Sprintf("permissions[%q]", value), perms); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkURL requires an https URL, or http on a loopback host.
+func checkURL(name, raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil || u.Host == "" {
+		return fmt.Errorf("jwt: %s %q must be an absolute URL", name, raw)
+	}
+	switch u.Scheme {
+	case "https":
+		return nil
+	case "http":
+		if host := u.Hostname(); host == "localhost" || net.ParseIP(host).IsLoopback() {
+			return nil
+		}
+	}
+	return fmt.Errorf("jwt: %s %q must use https", name, raw)
+}
+
+func checkPermissions(name string, perms []stowryhttp.Permission) error {
+	for _, p := range perms {
+		if p != stowryhttp.PermissionRead && p != stowryhttp.PermissionWrite {
+			return fmt.Errorf("jwt: %s: unknown permission %q, want read or write", name, p)
+		}
+	}
+	return nil
+}
+
+// Authenticator authenticates requests bearing a JWT. It is safe for
+// concurrent use.
+type Authenticator struct {
+	cfg  Config
+	keys *keySet
+}
+
+// New validates cfg and creates an Authenticator. The key set is not fetched
+// until the first token arrives, so the provider need not be reachable at
+// startup.
+func New(cfg Config) (*Authenticator, error) {
+	cfg.Enabled = true
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	if cfg.SubjectClaim == "" {
+		cfg.SubjectClaim = DefaultSubjectClaim
+	}
+	if cfg.DefaultPermissions == nil {
+		cfg.DefaultPermissions = []stowryhttp.Permission{stowryhttp.PermissionRead}
+	}
+	if cfg.CacheTTL == 0 {
+		cfg.CacheTTL = DefaultCacheTTL
+	}
+	if cfg.HTTPClient == nil {
+		cfg.HTTPClient = &http.Client{Timeout: 10 * time.Second}
+	}
+	permissions := make(map[string][]stowryhttp.Permission, len(cfg.Permissions))
+	for value, perms := range cfg.Permissions {
+		permissions[strings.ToLower(value)] = perms
+	}
+	cfg.Permissions = permissions
+
+	return &Authenticator{
+		cfg: cfg,
+		keys: &keySet{
+			client:     cfg.HTTPClient,
+			issuer:     cfg.Issuer,
+			url:        cfg.JWKSURL,
+			ttl:        time.Duration(cfg.CacheTTL) * time.Second,
+			minRefresh: time.Duration(cfg.MinRefreshInterval) * time.Second,
+		},
+	}, nil
+}
+
+// Authenticate verifies the request's token. It returns
+// stowryhttp.ErrNoCredentials when the request has none, and an error
+// wrapping ErrInvalidToken when it is not valid.
+func (a *Authenticator) Authenticate(r *http.Request) (stowryhttp.Principal, error) {
+	raw, ok := a.token(r)
+	if !ok {
+		return stowryhttp.Principal{}, stowryhttp.ErrNoCredentials
+	}
+
+	claims, err := a.verify(r.Context(), raw, time.Now())
+	if err != nil {
+		return stowryhttp.Principal{}, err
+	}
+
+	subject, _ := claims[a.cfg.SubjectClaim].(string)
+	if subject == "" {
+		return stowryhttp.Principal{}, fmt.Errorf("%w: missing %s claim", ErrInvalidToken, a.cfg.SubjectClaim)
+	}
+
+	return stowryhttp.Principal{
+		Subject:     subject,
+		Method:      "jwt",
+		Permissions: a.permissions(claims),
+	}, nil
+}
+
+// token returns the bearer token of r, from the Authorization header or the
+// configured cookie.
+func (a *Authenticator) token(r *http.Request) (string, bool) {
+	if header := r.Header.Get("Authorization"); header != "" {
+		scheme, token, ok := strings.Cut(header, " ")
+		if !ok || !strings.EqualFold(scheme, "Bearer") {
+			return "", false
+		}
+		token = strings.TrimSpace(token)
+		return token, token != ""
+	}
+
+	if a.cfg.Cookie != "" {
+		if c, err := r.Cookie(a.cfg.Cookie); err == nil && c.Value != "" {
+			return c.Value, true
+		}
+	}
+	return "", false
+}
+
+// permissions returns the permissions claims grant. The result is never nil,
+// since a nil Principal.Permissions grants everything.
+func (a *Authenticator) permissions(claims map[string]any) []stowryhttp.Permission {
+	perms := slices.Clone(a.cfg.DefaultPermissions)
+	if perms == nil {
+		perms = []stowryhttp.Permission{}
+	}
+
+	for _, value := range claimValues(claims[a.cfg.PermissionsClaim]) {
+		granted := []stowryhttp.Permission{stowryhttp.Permission(value)}
+		if len(a.cfg.Permissions) > 0 {
+			granted = a.cfg.Permissions[strings.ToLower(value)]
+		}
+		for _, p := range granted {
+			if (p == stowryhttp.PermissionRead || p == stowryhttp.PermissionWrite) && !slices.Contains(perms, p) {
+				perms = append(perms, p)
+			}
+		}
+	}
+	return perms
+}
+
+// claimValues returns the values of a string array claim, or the words of a
+// space-separated string claim such as scope.
+func claimValues(claim any) []string {
+	switch v := claim.(type) {
+	case string:
+		return strings.Fields(v)
+	case []any:
+		values := make([]string, 0, len(v))
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				values = append(values, s)
+			}
+		}
+		return values
+	default:
+		return nil
+	}
+}
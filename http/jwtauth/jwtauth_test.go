@@ -0,0 +1,413 @@
+package jwtauth_test
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	stowryhttp "github.com/sagarc03/stowry/http"
+	"github.com/sagarc03/stowry/http/jwtauth"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testAudience = "stowry"
+
+// signingKey is a provider key that signs test tokens.
+type signingKey struct {
+	kid string
+	alg string
+	key crypto.Signer
+}
+
+func (k signingKey) jwk() map[string]string {
+	enc := base64.RawURLEncoding.EncodeToString
+	m := map[string]string{"kid": k.kid, "use": "sig", "alg": k.alg}
+	switch pub := k.key.Public().(type) {
+	case *rsa.PublicKey:
+		m["kty"] = "RSA"
+		m["n"] = enc(pub.N.Bytes())
+		m["e"] = enc(big.NewInt(int64(pub.E)).Bytes())
+	case *ecdsa.PublicKey:
+		b, _ := pub.Bytes()
+		size := (len(b) - 1) / 2
+		m["kty"] = "EC"
+		m["crv"] = pub.Curve.Params().Name
+		m["x"] = enc(b[1 : 1+size])
+		m["y"] = enc(b[1+size:])
+	case ed25519.PublicKey:
+		m["kty"] = "OKP"
+		m["crv"] = "Ed25519"
+		m["x"] = enc(pub)
+	}
+	return m
+}
+
+func (k signingKey) sign(t *testing.T, claims map[string]any) string {
+	t.Helper()
+	enc := base64.RawURLEncoding.EncodeToString
+
+	hdr, err := json.Marshal(map[string]string{"alg": k.alg, "kid": k.kid, "typ": "JWT"})
+	require.NoError(t, err)
+	payload, err := json.Marshal(claims)
+	require.NoError(t, err)
+	signed := enc(hdr) + "." + enc(payload)
+
+	var sig []byte
+	switch key := k.key.(type) {
+	case ed25519.PrivateKey:
+		sig = ed25519.Sign(key, []byte(signed))
+	case *ecdsa.PrivateKey:
+		digest := sha256.Sum256([]byte(signed))
+		r, s, err := ecdsa.Sign(rand.Reader, key, digest[:])
+		require.NoError(t, err)
+		sig = append(r.FillBytes(make([]byte, 32)), s.FillBytes(make([]byte, 32))...)
+	case *rsa.PrivateKey:
+		digest := sha256.Sum256([]byte(signed))
+		sig, err = rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+		require.NoError(t, err)
+	}
+	return signed + "." + enc(sig)
+}
+
+var (
+	rsaOnce sync.Once
+	rsaKey  *rsa.PrivateKey
+)
+
+func newRSAKey(t *testing.T, kid string) signingKey {
+	t.Helper()
+	rsaOnce.Do(func() {
+		var err error
+		rsaKey, err = rsa.GenerateKey(rand.Reader, 2048)
+		require.NoError(t, err)
+	})
+	return signingKey{kid: kid, alg: "RS256", key: rsaKey}
+}
+
+func newECKey(t *testing.T, kid string) signingKey {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	return signingKey{kid: kid, alg: "ES256", key: key}
+}
+
+func newEdKey(t *testing.T, kid string) signingKey {
+	t.Helper()
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	return signingKey{kid: kid, alg: "EdDSA", key: key}
+}
+
+// provider is a test OpenID provider serving discovery and a key set.
+type provider struct {
+	*httptest.Server
+	mu          sync.Mutex
+	keys        []signingKey
+	jwksFetches atomic.Int32
+}
+
+func newProvider(t *testing.T, keys ...signingKey) *provider {
+	t.Helper()
+	p := &provider{keys: keys}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /.well-known/openid-configuration", func(w http.ResponseWriter, _ *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]string{"issuer": p.URL, "jwks_uri": p.URL + "/jwks"})
+	})
+	mux.HandleFunc("GET /jwks", func(w http.ResponseWriter, _ *http.Request) {
+		p.jwksFetches.Add(1)
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		set := make([]map[string]string, 0, len(p.keys))
+		for _, k := range p.keys {
+			set = append(set, k.jwk())
+		}
+		_ = json.NewEncoder(w).Encode(map[string]any{"keys": set})
+	})
+	p.Server = httptest.NewServer(mux)
+	t.Cleanup(p.Close)
+	return p
+}
+
+func (p *provider) rotate(keys ...signingKey) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.keys = keys
+}
+
+func (p *provider) claims(sub string) map[string]any {
+	return map[string]any{
+		"iss": p.URL,
+		"aud": testAudience,
+		"sub": sub,
+		"exp": time.Now().Add(time.Hour).Unix(),
+	}
+}
+
+func bearer(token string) *http.Request {
+	r := httptest.NewRequest(http.MethodGet, "/file.txt", nil)
+	r.Header.Set("Authorization", "Bearer "+token)
+	return r
+}
+
+func newAuthenticator(t *testing.T, cfg jwtauth.Config) *jwtauth.Authenticator {
+	t.Helper()
+	a, err := jwtauth.New(cfg)
+	require.NoError(t, err)
+	return a
+}
+
+func TestAuthenticator_Authenticate(t *testing.T) {
+	rsaKey, ecKey, edKey := newRSAKey(t, "rsa"), newECKey(t, "ec"), newEdKey(t, "ed")
+	p := newProvider(t, rsaKey, ecKey, edKey)
+	auth := newAuthenticator(t, jwtauth.Config{Issuer: p.URL, Audience: testAudience})
+
+	for _, key := range []signingKey{rsaKey, ecKey, edKey} {
+		t.Run(key.alg, func(t *testing.T) {
+			principal, err := auth.Authenticate(bearer(key.sign(t, p.claims("alice"))))
+			require.NoError(t, err)
+			assert.Equal(t, stowryhttp.Principal{
+				Subject:     "alice",
+				Method:      "jwt",
+				Permissions: []stowryhttp.Permission{stowryhttp.PermissionRead},
+			}, principal)
+		})
+	}
+
+	assert.Equal(t, int32(1), p.jwksFetches.Load(), "key set must be cached")
+}
+
+func TestAuthenticator_Rejects(t *testing.T) {
+	key := newECKey(t, "ec")
+	p := newProvider(t, key)
+	auth := newAuthenticator(t, jwtauth.Config{Issuer: p.URL, Audience: testAudience, Leeway: 30})
+
+	with := func(changes map[string]any) map[string]any {
+		claims := p.claims("alice")
+		for k, v := range changes {
+			if v == nil {
+				delete(claims, k)
+				continue
+			}
+			claims[k] = v
+		}
+		return claims
+	}
+	enc := base64.RawURLEncoding.EncodeToString
+	valid := key.sign(t, p.claims("alice"))
+
+	tests := []struct {
+		name  string
+		token string
+	}{
+		{name: "expired", token: key.sign(t, with(map[string]any{"exp": time.Now().Add(-time.Minute).Unix()}))},
+		{name: "missing exp", token: key.sign(t, with(map[string]any{"exp": nil}))},
+		{name: "not yet valid", token: key.sign(t, with(map[string]any{"nbf": time.Now().Add(time.Minute).Unix()}))},
+		{name: "wrong audience", token: key.sign(t, with(map[string]any{"aud": "other"}))},
+		{name: "wrong issuer", token: key.sign(t, with(map[string]any{"iss": "https://evil.example.com"}))},
+		{name: "missing subject", token: key.sign(t, with(map[string]any{"sub": nil}))},
+		{name: "unknown key", token: newECKey(t, "other").sign(t, p.claims("alice"))},
+		{name: "forged signature", token: valid[:len(valid)-4] + "AAAA"},
+		{name: "alg none", token: enc([]byte(`{"alg":"none"}`)) + "." + enc([]byte(`{"sub":"alice"}`)) + "."},
+		{name: "alg HS256", token: signingKey{kid: "ec", alg: "HS256", key: key.key}.sign(t, p.claims("alice"))},
+		{name: "malformed", token: "not-a-jwt"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := auth.Authenticate(bearer(tt.token))
+			assert.ErrorIs(t, err, jwtauth.ErrInvalidToken)
+		})
+	}
+
+	t.Run("within leeway", func(t *testing.T) {
+		_, err := auth.Authenticate(bearer(key.sign(t, with(map[string]any{"exp": time.Now().Add(-10 * time.Second).Unix()}))))
+		assert.NoError(t, err)
+	})
+
+	t.Run("audience array", func(t *testing.T) {
+		_, err := auth.Authenticate(bearer(key.sign(t, with(map[string]any{"aud": []string{"other", testAudience}}))))
+		assert.NoError(t, err)
+	})
+}
+
+func TestAuthenticator_NoCredentials(t *testing.T) {
+	p := newProvider(t, newECKey(t, "ec"))
+	auth := newAuthenticator(t, jwtauth.Config{Issuer: p.URL, Audience: testAudience, Cookie: "session"})
+
+	_, err := auth.Authenticate(httptest.NewRequest(http.MethodGet, "/file.txt", nil))
+	assert.ErrorIs(t, err, stowryhttp.ErrNoCredentials)
+
+	r := httptest.NewRequest(http.MethodGet, "/file.txt", nil)
+	r.Header.Set("Authorization", "Basic dXNlcjpwYXNz")
+	_, err = auth.Authenticate(r)
+	assert.ErrorIs(t, err, stowryhttp.ErrNoCredentials)
+
+	assert.Zero(t, p.jwksFetches.Load())
+}
+
+func TestAuthenticator_Cookie(t *testing.T) {
+	key := newEdKey(t, "ed")
+	p := newProvider(t, key)
+	auth := newAuthenticator(t, jwtauth.Config{Issuer: p.URL, Audience: testAudience, Cookie: "session"})
+
+	r := httptest.NewRequest(http.MethodGet, "/file.txt", nil)
+	r.AddCookie(&http.Cookie{Name: "session", Value: key.sign(t, p.claims("bob"))})
+
+	principal, err := auth.Authenticate(r)
+	require.NoError(t, err)
+	assert.Equal(t, "bob", principal.Subject)
+}
+
+func TestAuthenticator_KeyRotation(t *testing.T) {
+	oldKey, newKey := newECKey(t, "2025"), newECKey(t, "2026")
+	p := newProvider(t, oldKey)
+	auth := newAuthenticator(t, jwtauth.Config{Issuer: p.URL, Audience: testAudience})
+
+	_, err := auth.Authenticate(bearer(oldKey.sign(t, p.claims("alice"))))
+	require.NoError(t, err)
+
+	p.rotate(newKey)
+
+	_, err = auth.Authenticate(bearer(newKey.sign(t, p.claims("alice"))))
+	require.NoError(t, err, "unknown kid must refetch the key set")
+	assert.Equal(t, int32(2), p.jwksFetches.Load())
+
+	_, err = auth.Authenticate(bearer(oldKey.sign(t, p.claims("alice"))))
+	assert.ErrorIs(t, err, jwtauth.ErrInvalidToken, "rotated out key must be rejected")
+}
+
+func TestAuthenticator_KeyRefreshRateLimited(t *testing.T) {
+	key := newECKey(t, "ec")
+	p := newProvider(t, key)
+	auth := newAuthenticator(t, jwtauth.Config{Issuer: p.URL, Audience: testAudience, MinRefreshInterval: 60})
+
+	_, err := auth.Authenticate(bearer(key.sign(t, p.claims("alice"))))
+	require.NoError(t, err)
+
+	unknown := newECKey(t, "unknown")
+	for range 5 {
+		_, err = auth.Authenticate(bearer(unknown.sign(t, p.claims("alice"))))
+		assert.ErrorIs(t, err, jwtauth.ErrInvalidToken)
+	}
+	assert.Equal(t, int32(1), p.jwksFetches.Load())
+}
+
+func TestAuthenticator_ProviderDown(t *testing.T) {
+	key := newECKey(t, "ec")
+	p := newProvider(t, key)
+	auth := newAuthenticator(t, jwtauth.Config{Issuer: p.URL, Audience: testAudience})
+	token := key.sign(t, p.claims("alice"))
+	p.Close()
+
+	_, err := auth.Authenticate(bearer(token))
+	require.Error(t, err)
+	assert.NotErrorIs(t, err, jwtauth.ErrInvalidToken)
+	assert.ErrorContains(t, err, "openid discovery")
+}
+
+func TestAuthenticator_Permissions(t *testing.T) {
+	key := newECKey(t, "ec")
+	p := newProvider(t, key)
+	read, write := stowryhttp.PermissionRead, stowryhttp.PermissionWrite
+
+	tests := []struct {
+		name   string
+		cfg    jwtauth.Config
+		claims map[string]any
+		want   []stowryhttp.Permission
+	}{
+		{
+			name: "default read",
+			want: []stowryhttp.Permission{read},
+		},
+		{
+			name:   "mapped group",
+			cfg:    jwtauth.Config{PermissionsClaim: "groups", Permissions: map[string][]stowryhttp.Permission{"editors": {write}}},
+			claims: map[string]any{"groups": []string{"staff", "Editors"}},
+			want:   []stowryhttp.Permission{read, write},
+		},
+		{
+			name:   "unmapped group",
+			cfg:    jwtauth.Config{PermissionsClaim: "groups", Permissions: map[string][]stowryhttp.Permission{"editors": {write}}},
+			claims: map[string]any{"groups": []string{"staff"}},
+			want:   []stowryhttp.Permission{read},
+		},
+		{
+			name:   "scope names permissions",
+			cfg:    jwtauth.Config{PermissionsClaim: "scope", DefaultPermissions: []stowryhttp.Permission{}},
+			claims: map[string]any{"scope": "openid write admin"},
+			want:   []stowryhttp.Permission{write},
+		},
+		{
+			name: "no defaults grants nothing",
+			cfg:  jwtauth.Config{DefaultPermissions: []stowryhttp.Permission{}},
+			want: []stowryhttp.Permission{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.cfg.Issuer, tt.cfg.Audience = p.URL, testAudience
+			auth := newAuthenticator(t, tt.cfg)
+
+			claims := p.claims("alice")
+			for k, v := range tt.claims {
+				claims[k] = v
+			}
+			principal, err := auth.Authenticate(bearer(key.sign(t, claims)))
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, principal.Permissions)
+			assert.NotNil(t, principal.Permissions, "nil permissions would grant everything")
+		})
+	}
+}
+
+func TestConfig_Validate(t *testing.T) {
+	assert.NoError(t, jwtauth.Config{}.Validate(), "disabled config is not checked")
+
+	valid := jwtauth.Config{Enabled: true, Issuer: "https://login.example.com", Audience: testAudience}
+	assert.NoError(t, valid.Validate())
+
+	tests := []struct {
+		name    string
+		change  func(*jwtauth.Config)
+		wantErr string
+	}{
+		{name: "missing issuer", change: func(c *jwtauth.Config) { c.Issuer = "" }, wantErr: "issuer is required"},
+		{name: "plain http issuer", change: func(c *jwtauth.Config) { c.Issuer = "http://login.example.com" }, wantErr: "must use https"},
+		{name: "relative jwks url", change: func(c *jwtauth.Config) { c.JWKSURL = "/jwks" }, wantErr: "absolute URL"},
+		{name: "missing audience", change: func(c *jwtauth.Config) { c.Audience = "" }, wantErr: "audience is required"},
+		{name: "negative leeway", change: func(c *jwtauth.Config) { c.Leeway = -1 }, wantErr: "must not be negative"},
+		{name: "unknown permission", change: func(c *jwtauth.Config) {
+			c.DefaultPermissions = []stowryhttp.Permission{"admin"}
+		}, wantErr: `unknown permission "admin"`},
+		{name: "permissions without claim", change: func(c *jwtauth.Config) {
+			c.Permissions = map[string][]stowryhttp.Permission{"editors": {stowryhttp.PermissionWrite}}
+		}, wantErr: "requires permissions_claim"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := valid
+			tt.change(&cfg)
+			assert.ErrorContains(t, cfg.Validate(), tt.wantErr)
+		})
+	}
+
+	t.Run("loopback http issuer", func(t *testing.T) {
+		cfg := valid
+		cfg.Issuer = "http://127.0.0.1:8080"
+		assert.NoError(t, cfg.Validate())
+	})
+}
@@ -0,0 +1,150 @@
+package jwtauth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"slices"
+	"strings"
+	"time"
+
+	// Register the hashes the signing algorithms use.
+	_ "crypto/sha256"
+	_ "crypto/sha512"
+)
+
+// header is the JOSE header of a token.
+type header struct {
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+}
+
+// algorithm describes a supported signing algorithm.
+type algorithm struct {
+	kty  string // JWK key type the algorithm needs
+	hash crypto.Hash
+	pss  bool
+}
+
+var algorithms = map[string]algorithm{
+	"RS256": {kty: "RSA", hash: crypto.SHA256},
+	"RS384": {kty: "RSA", hash: crypto.SHA384},
+	"RS512": {kty: "RSA", hash: crypto.SHA512},
+	"PS256": {kty: "RSA", hash: crypto.SHA256, pss: true},
+	"PS384": {kty: "RSA", hash: crypto.SHA384, pss: true},
+	"PS512": {kty: "RSA", hash: crypto.SHA512, pss: true},
+	"ES256": {kty: "EC", hash: crypto.SHA256},
+	"ES384": {kty: "EC", hash: crypto.SHA384},
+	"ES512": {kty: "EC", hash: crypto.SHA512},
+	"EdDSA": {kty: "OKP"},
+}
+
+// verify checks the signature and registered claims of raw at now and
+// returns its claims.
+func (a *Authenticator) verify(ctx context.Context, raw string, now time.Time) (map[string]any, error) {
+	parts := strings.Split(raw, ".")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("%w: not a signed JWT", ErrInvalidToken)
+	}
+
+	var hdr header
+	if err := decodeSegment(parts[0], &hdr); err != nil {
+		return nil, fmt.Errorf("%w: header: %w", ErrInvalidToken, err)
+	}
+	alg, ok := algorithms[hdr.Alg]
+	if !ok {
+		return nil, fmt.Errorf("%w: unsupported algorithm %q", ErrInvalidToken, hdr.Alg)
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("%w: signature: %w", ErrInvalidToken, err)
+	}
+
+	key, err := a.keys.key(ctx, hdr.Kid, hdr.Alg, alg.kty)
+	if err != nil {
+		return nil, err
+	}
+	if !verifySignature(alg, key, []byte(parts[0]+"."+parts[1]), sig) {
+		return nil, fmt.Errorf("%w: bad signature", ErrInvalidToken)
+	}
+
+	var claims map[string]any
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return nil, fmt.Errorf("%w: claims: %w", ErrInvalidToken, err)
+	}
+	if err := a.checkClaims(claims, now); err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
+
+// checkClaims checks iss, aud, exp and nbf.
+func (a *Authenticator) checkClaims(claims map[string]any, now time.Time) error {
+	if iss, _ := claims["iss"].(string); iss != a.cfg.Issuer {
+		return fmt.Errorf("%w: issuer %q does not match", ErrInvalidToken, iss)
+	}
+	if !slices.Contains(claimValues(claims["aud"]), a.cfg.Audience) {
+		return fmt.Errorf("%w: audience does not include %q", ErrInvalidToken, a.cfg.Audience)
+	}
+
+	leeway := time.Duration(a.cfg.Leeway) * time.Second
+	exp, ok := claims["exp"].(float64)
+	if !ok {
+		return fmt.Errorf("%w: missing exp claim", ErrInvalidToken)
+	}
+	if !now.Before(unixTime(exp).Add(leeway)) {
+		return fmt.Errorf("%w: expired", ErrInvalidToken)
+	}
+	if nbf, ok := claims["nbf"].(float64); ok && now.Add(leeway).Before(unixTime(nbf)) {
+		return fmt.Errorf("%w: not valid yet", ErrInvalidToken)
+	}
+	return nil
+}
+
+func unixTime(seconds float64) time.Time {
+	return time.Unix(0, 0).Add(time.Duration(seconds * float64(time.Second)))
+}
+
+func decodeSegment(segment string, v any) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// verifySignature verifies sig over signed with key, whose type was checked
+// against alg when the key was looked up.
+func verifySignature(alg algorithm, key crypto.PublicKey, signed, sig []byte) bool {
+	if pub, ok := key.(ed25519.PublicKey); ok {
+		return ed25519.Verify(pub, signed, sig)
+	}
+
+	h := alg.hash.New()
+	h.Write(signed)
+	digest := h.Sum(nil)
+
+	switch pub := key.(type) {
+	case *rsa.PublicKey:
+		if alg.pss {
+			return rsa.VerifyPSS(pub, alg.hash, digest, sig, &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash}) == nil
+		}
+		return rsa.VerifyPKCS1v15(pub, alg.hash, digest, sig) == nil
+	case *ecdsa.PublicKey:
+		size := (pub.Curve.Params().BitSize + 7) / 8
+		if len(sig) != 2*size {
+			return false
+		}
+		r := new(big.Int).SetBytes(sig[:size])
+		s := new(big.Int).SetBytes(sig[size:])
+		return ecdsa.Verify(pub, digest, r, s)
+	default:
+		return false
+	}
+}
@@ -0,0 +1,49 @@
+package http
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/sagarc03/stowry"
+)
+
+// AccessKeyTracker records which access keys authenticate requests and
+// reports their use. *stowry.KeyUseTracker implements it.
+type AccessKeyTracker interface {
+	// Record counts one request authenticated with accessKey. It is called
+	// on the request path and must not block.
+	Record(accessKey string)
+	// AccessKeys returns the known access keys with their use, in access
+	// key order.
+	AccessKeys(ctx context.Context) ([]stowry.AccessKeyInfo, error)
+}
+
+// AccessKeysResponse is the body of GET /admin/keys.
+type AccessKeysResponse struct {
+	Keys []AccessKeyEntry `json:"keys"`
+}
+
+// AccessKeyEntry is one access key of an AccessKeysResponse, with its
+// status spelled out.
+type AccessKeyEntry struct {
+	stowry.AccessKeyInfo
+	Status string `json:"status"`
+}
+
+// handleListAccessKeys serves GET /admin/keys: every access key with when
+// it was created and last used, its request count and its rotation status.
+// Secret keys are never included.
+func (h *Handler) handleListAccessKeys(w http.ResponseWriter, r *http.Request) {
+	keys, err := h.config.AccessKeys.AccessKeys(r.Context())
+	if err != nil {
+		h.handleError(w, r, err)
+		return
+	}
+
+	resp := AccessKeysResponse{Keys: make([]AccessKeyEntry, len(keys))}
+	for i, k := range keys {
+		resp.Keys[i] = AccessKeyEntry{AccessKeyInfo: k, Status: k.Status()}
+	}
+	w.Header().Set("Cache-Control", "no-store")
+	_ = WriteJSON(w, http.StatusOK, resp)
+}
@@ -0,0 +1,119 @@
+package http_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sagarc03/stowry"
+	stowryhttp "github.com/sagarc03/stowry/http"
+	"github.com/sagarc03/stowry/keybackend"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeAccessKeys counts recorded keys and lists fixed ones.
+type fakeAccessKeys struct {
+	mu   sync.Mutex
+	used map[string]int
+	keys []stowry.AccessKeyInfo
+}
+
+func (f *fakeAccessKeys) Record(accessKey string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.used == nil {
+		f.used = map[string]int{}
+	}
+	f.used[accessKey]++
+}
+
+func (f *fakeAccessKeys) AccessKeys(context.Context) ([]stowry.AccessKeyInfo, error) {
+	return f.keys, nil
+}
+
+func TestHandler_AccessKeys(t *testing.T) {
+	serve := func(t *testing.T, config *stowryhttp.HandlerConfig, service stowryhttp.Service, req *http.Request) *httptest.ResponseRecorder {
+		t.Helper()
+		h, err := stowryhttp.New(config, service)
+		require.NoError(t, err)
+		rec := httptest.NewRecorder()
+		h.Router().ServeHTTP(rec, req)
+		return rec
+	}
+	signed := func(method, target string) *http.Request {
+		req := httptest.NewRequest(method, target, nil)
+		req.Header.Set("X-Test-Auth", "ok")
+		return req
+	}
+
+	t.Run("signed requests record their access key", func(t *testing.T) {
+		keys := &fakeAccessKeys{}
+		service := new(MockService)
+		service.On("Delete", mock.Anything, "a.txt").Return(nil)
+		config := &stowryhttp.HandlerConfig{Mode: stowry.ModeStore, WriteVerifier: headerVerifier{}, AccessKeys: keys}
+
+		rec := serve(t, config, service, signed(http.MethodDelete, "/a.txt?X-Stowry-Credential=AK1"))
+		require.Equal(t, http.StatusNoContent, rec.Code)
+
+		rec = serve(t, config, service, httptest.NewRequest(http.MethodDelete, "/a.txt?X-Stowry-Credential=AK1", nil))
+		require.Equal(t, http.StatusUnauthorized, rec.Code)
+
+		assert.Equal(t, map[string]int{"AK1": 1}, keys.used, "only accepted requests count")
+	})
+
+	t.Run("presign callers record their access key", func(t *testing.T) {
+		keys := &fakeAccessKeys{}
+		store := keybackend.NewMapSecretStore(map[string]string{"AK": "SK"})
+		presigner, err := stowryhttp.NewPresigner(stowryhttp.PresignConfig{Enabled: true, SigningKey: "AK"}, store)
+		require.NoError(t, err)
+		config := &stowryhttp.HandlerConfig{Mode: stowry.ModeStore, Presigner: presigner, AccessKeys: keys}
+
+		req := httptest.NewRequest(http.MethodPost, "/presign", strings.NewReader(`{"method":"GET","path":"a.txt"}`))
+		req.SetBasicAuth("AK", "SK")
+		rec := serve(t, config, new(MockService), req)
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+		assert.Equal(t, map[string]int{"AK": 1}, keys.used)
+	})
+
+	t.Run("lists access keys", func(t *testing.T) {
+		at := time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC)
+		keys := &fakeAccessKeys{keys: []stowry.AccessKeyInfo{
+			{AccessKey: "NEW", CreatedAt: &at},
+			{AccessKey: "OLD", CreatedAt: &at, LastUsedAt: &at, RequestCount: 7, DeprecatedAt: &at, ReplacedBy: "NEW"},
+		}}
+		config := &stowryhttp.HandlerConfig{Mode: stowry.ModeStore, WriteVerifier: headerVerifier{}, AccessKeys: keys}
+
+		rec := serve(t, config, new(MockService), signed(http.MethodGet, "/admin/keys"))
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		assert.Equal(t, "no-store", rec.Header().Get("Cache-Control"))
+
+		var body stowryhttp.AccessKeysResponse
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+		require.Len(t, body.Keys, 2)
+		assert.Equal(t, "active", body.Keys[0].Status)
+		assert.Nil(t, body.Keys[0].LastUsedAt)
+		assert.Equal(t, "deprecated", body.Keys[1].Status)
+		assert.Equal(t, "NEW", body.Keys[1].ReplacedBy)
+		assert.Equal(t, int64(7), body.Keys[1].RequestCount)
+
+		rec = serve(t, config, new(MockService), httptest.NewRequest(http.MethodGet, "/admin/keys", nil))
+		assert.Equal(t, http.StatusUnauthorized, rec.Code, "the listing needs write access")
+	})
+
+	t.Run("no listing without a tracker", func(t *testing.T) {
+		service := new(MockService)
+		service.On("Get", mock.Anything, "admin/keys").Return(stowry.MetaData{}, nil, stowry.ErrNotFound)
+		config := &stowryhttp.HandlerConfig{Mode: stowry.ModeStore, WriteVerifier: headerVerifier{}}
+
+		rec := serve(t, config, service, signed(http.MethodGet, "/admin/keys"))
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+	})
+}
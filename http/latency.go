@@ -0,0 +1,274 @@
+package http
+
+import (
+	"cmp"
+	"math"
+	"math/bits"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// Latencies are recorded in microseconds into a log-linear histogram: values
+// below 2*latencySubBuckets get their own bucket, larger ones share a bucket
+// with values within 1/latencySubBuckets (about 3%) of them. 36 powers of two
+// cover latencies up to about 12 days.
+const (
+	latencySubBits    = 5
+	latencySubBuckets = 1 << latencySubBits
+	latencyBuckets    = 36 * latencySubBuckets
+)
+
+// LatencyTracker records request latency per route and method over a sliding
+// period made of a ring of fixed windows, for GET /stats/latency. It keeps a
+// fixed-size histogram per route and window, so recording a request does not
+// allocate. Change feed watches are not recorded since they wait on purpose.
+type LatencyTracker struct {
+	window  time.Duration
+	windows int
+	slo     time.Duration
+	now     func() time.Time
+
+	mu     sync.RWMutex
+	routes map[latencyRoute]*latencySeries
+}
+
+type latencyRoute struct {
+	method string
+	route  string // chi route pattern, e.g. "/*"; empty for unmatched requests
+}
+
+// latencySeries is a ring of windows for one route.
+type latencySeries struct {
+	mu    sync.Mutex
+	slots []latencyHistogram
+}
+
+type latencyHistogram struct {
+	epoch  int64 // window number since the Unix epoch
+	count  uint64
+	max    uint64 // microseconds
+	counts [latencyBuckets]uint32
+}
+
+// NewLatencyTracker creates a tracker reporting the last windows windows of
+// length window. slo, when positive, is the p99 latency above which the
+// server is reported as degraded.
+func NewLatencyTracker(window time.Duration, windows int, slo time.Duration) *LatencyTracker {
+	return &LatencyTracker{
+		window:  window,
+		windows: max(windows, 1),
+		slo:     slo,
+		now:     time.Now,
+		routes:  make(map[latencyRoute]*latencySeries),
+	}
+}
+
+// Middleware records how long each request takes to be served, under the
+// route pattern it matched. A nil tracker passes requests through unchanged.
+func (t *LatencyTracker) Middleware(next http.Handler) http.Handler {
+	if t == nil {
+		return next
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.RawQuery != "" && isWatchRequest(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		start := t.now()
+		next.ServeHTTP(w, r)
+		elapsed := t.now().Sub(start)
+
+		var route string
+		if rctx := chi.RouteContext(r.Context()); rctx != nil {
+			route = rctx.RoutePattern()
+		}
+		t.Record(r.Method, route, elapsed)
+	})
+}
+
+// Record adds one request to route with the given method.
+func (t *LatencyTracker) Record(method, route string, elapsed time.Duration) {
+	key := latencyRoute{method: method, route: route}
+
+	t.mu.RLock()
+	s, ok := t.routes[key]
+	t.mu.RUnlock()
+	if !ok {
+		t.mu.Lock()
+		if s, ok = t.routes[key]; !ok {
+			s = &latencySeries{slots: make([]latencyHistogram, t.windows)}
+			t.routes[key] = s
+		}
+		t.mu.Unlock()
+	}
+
+	us := uint64(max(elapsed.Microseconds(), 0))
+	epoch := t.epoch()
+
+	s.mu.Lock()
+	h := &s.slots[epoch%int64(len(s.slots))]
+	if h.epoch != epoch {
+		*h = latencyHistogram{epoch: epoch}
+	}
+	h.count++
+	h.max = max(h.max, us)
+	h.counts[latencyBucket(us)]++
+	s.mu.Unlock()
+}
+
+func (t *LatencyTracker) epoch() int64 {
+	return t.now().UnixNano() / int64(t.window)
+}
+
+// latencyBucket returns the histogram bucket for us microseconds.
+func latencyBucket(us uint64) int {
+	if us < 2*latencySubBuckets {
+		return int(us)
+	}
+	shift := bits.Len64(us) - latencySubBits - 1
+	return min(shift*latencySubBuckets+int(us>>shift), latencyBuckets-1)
+}
+
+// latencyBucketValue returns the midpoint of bucket i in microseconds.
+func latencyBucketValue(i int) float64 {
+	if i < 2*latencySubBuckets {
+		return float64(i)
+	}
+	shift := i/latencySubBuckets - 1
+	lower := uint64(i-shift*latencySubBuckets) << shift
+	return float64(lower) + float64(uint64(1)<<shift-1)/2
+}
+
+// quantile returns the latency in microseconds below which a fraction q of
+// the recorded requests fall.
+func (h *latencyHistogram) quantile(q float64) float64 {
+	if h.count == 0 {
+		return 0
+	}
+	// Nearest rank: the smallest value with at least q of the requests at or below it
+	rank := uint64(math.Ceil(q * float64(h.count)))
+	rank = min(max(rank, 1), h.count)
+
+	var seen uint64
+	for i, c := range h.counts {
+		seen += uint64(c)
+		if seen >= rank {
+			return min(latencyBucketValue(i), float64(h.max))
+		}
+	}
+	return float64(h.max)
+}
+
+func (h *latencyHistogram) merge(o *latencyHistogram) {
+	h.count += o.count
+	h.max = max(h.max, o.max)
+	for i, c := range o.counts {
+		h.counts[i] += c
+	}
+}
+
+// LatencyStats summarizes the latency of one route, or of all requests.
+// Latencies are in milliseconds.
+type LatencyStats struct {
+	Method string  `json:"method,omitempty"`
+	Route  string  `json:"route,omitempty"`
+	Count  uint64  `json:"count"`
+	P50    float64 `json:"p50_ms"`
+	P95    float64 `json:"p95_ms"`
+	P99    float64 `json:"p99_ms"`
+	Max    float64 `json:"max_ms"`
+}
+
+// LatencySnapshot is the body of GET /stats/latency.
+type LatencySnapshot struct {
+	Period   int            `json:"period_seconds"` // time covered by the stats
+	SLOP99   float64        `json:"slo_p99_ms,omitempty"`
+	Degraded bool           `json:"degraded"` // overall p99 is above SLOP99
+	Overall  LatencyStats   `json:"overall"`
+	Routes   []LatencyStats `json:"routes"`
+}
+
+// Snapshot summarizes the requests recorded in the current period.
+func (t *LatencyTracker) Snapshot() LatencySnapshot {
+	snap := LatencySnapshot{
+		Period: int((t.window * time.Duration(t.windows)).Seconds()),
+		SLOP99: durationMillis(t.slo),
+		Routes: []LatencyStats{},
+	}
+
+	t.mu.RLock()
+	keys := make([]latencyRoute, 0, len(t.routes))
+	series := make([]*latencySeries, 0, len(t.routes))
+	for k, s := range t.routes {
+		keys = append(keys, k)
+		series = append(series, s)
+	}
+	t.mu.RUnlock()
+
+	oldest := t.epoch() - int64(t.windows) + 1
+	overall := new(latencyHistogram)
+	route := new(latencyHistogram)
+
+	for i, s := range series {
+		*route = latencyHistogram{}
+		s.mu.Lock()
+		for j := range s.slots {
+			if s.slots[j].epoch >= oldest {
+				route.merge(&s.slots[j])
+			}
+		}
+		s.mu.Unlock()
+
+		if route.count == 0 {
+			continue
+		}
+		overall.merge(route)
+		stats := latencyStats(route)
+		stats.Method, stats.Route = keys[i].method, keys[i].route
+		snap.Routes = append(snap.Routes, stats)
+	}
+
+	slices.SortFunc(snap.Routes, func(a, b LatencyStats) int {
+		return cmp.Or(cmp.Compare(a.Route, b.Route), cmp.Compare(a.Method, b.Method))
+	})
+
+	snap.Overall = latencyStats(overall)
+	snap.Degraded = t.slo > 0 && snap.Overall.P99 > snap.SLOP99
+	return snap
+}
+
+// Degraded reports whether the p99 latency of all requests in the current
+// period is above the SLO. It is always false without an SLO.
+func (t *LatencyTracker) Degraded() bool {
+	return t != nil && t.slo > 0 && t.Snapshot().Degraded
+}
+
+// ServeHTTP serves the snapshot as JSON.
+func (t *LatencyTracker) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Cache-Control", "no-store")
+	_ = WriteJSON(w, http.StatusOK, t.Snapshot())
+}
+
+func latencyStats(h *latencyHistogram) LatencyStats {
+	return LatencyStats{
+		Count: h.count,
+		P50:   microsToMillis(h.quantile(0.50)),
+		P95:   microsToMillis(h.quantile(0.95)),
+		P99:   microsToMillis(h.quantile(0.99)),
+		Max:   microsToMillis(float64(h.max)),
+	}
+}
+
+func microsToMillis(us float64) float64 {
+	return float64(int64(us+0.5)) / 1000
+}
+
+func durationMillis(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
@@ -0,0 +1,192 @@
+package http_test
+
+import (
+	"encoding/json"
+	"math"
+	"math/rand/v2"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/sagarc03/stowry"
+	stowryhttp "github.com/sagarc03/stowry/http"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// assertWithin checks got against want within the histogram's relative error.
+func assertWithin(t *testing.T, want, got float64, name string) {
+	t.Helper()
+	assert.InEpsilon(t, want, got, 0.035, "%s: want %.3f, got %.3f", name, want, got)
+}
+
+func TestLatencyTracker_Quantiles(t *testing.T) {
+	t.Run("uniform", func(t *testing.T) {
+		tracker := stowryhttp.NewLatencyTracker(time.Minute, 5, 0)
+		// 1ms to 1000ms in 1ms steps: the q quantile is q seconds
+		for ms := 1; ms <= 1000; ms++ {
+			tracker.Record(http.MethodGet, "/*", time.Duration(ms)*time.Millisecond)
+		}
+
+		stats := tracker.Snapshot().Overall
+		assert.Equal(t, uint64(1000), stats.Count)
+		assertWithin(t, 500, stats.P50, "p50")
+		assertWithin(t, 950, stats.P95, "p95")
+		assertWithin(t, 990, stats.P99, "p99")
+		assert.InDelta(t, 1000, stats.Max, 0.001)
+	})
+
+	t.Run("exponential", func(t *testing.T) {
+		tracker := stowryhttp.NewLatencyTracker(time.Minute, 5, 0)
+		rng := rand.New(rand.NewPCG(1, 2))
+		const mean = 20.0 // ms
+		samples := make([]float64, 100000)
+		for i := range samples {
+			samples[i] = rng.ExpFloat64() * mean
+			tracker.Record(http.MethodGet, "/*", time.Duration(samples[i]*float64(time.Millisecond)))
+		}
+		slices.Sort(samples)
+
+		stats := tracker.Snapshot().Overall
+		for _, q := range []struct {
+			name string
+			q    float64
+			got  float64
+		}{
+			{"p50", 0.50, stats.P50},
+			{"p95", 0.95, stats.P95},
+			{"p99", 0.99, stats.P99},
+		} {
+			// The analytic quantile is -ln(1-q)*mean; the sample quantile is
+			// what the histogram approximates
+			exact := samples[int(math.Ceil(q.q*float64(len(samples))))-1]
+			assert.InEpsilon(t, -math.Log(1-q.q)*mean, exact, 0.05, q.name)
+			assertWithin(t, exact, q.got, q.name)
+		}
+	})
+
+	t.Run("small values are exact", func(t *testing.T) {
+		tracker := stowryhttp.NewLatencyTracker(time.Minute, 1, 0)
+		for range 10 {
+			tracker.Record(http.MethodGet, "/*", 40*time.Microsecond)
+		}
+
+		stats := tracker.Snapshot().Overall
+		assert.InDelta(t, 0.040, stats.P50, 1e-9)
+		assert.InDelta(t, 0.040, stats.P99, 1e-9)
+	})
+}
+
+func TestLatencyTracker_Snapshot(t *testing.T) {
+	t.Run("per route and method", func(t *testing.T) {
+		tracker := stowryhttp.NewLatencyTracker(time.Minute, 5, 100*time.Millisecond)
+		tracker.Record(http.MethodPut, "/*", 300*time.Millisecond)
+		tracker.Record(http.MethodGet, "/*", 2*time.Millisecond)
+		tracker.Record(http.MethodGet, "/", 1*time.Millisecond)
+
+		snap := tracker.Snapshot()
+
+		assert.Equal(t, 300, snap.Period)
+		assert.InDelta(t, 100, snap.SLOP99, 1e-9)
+		assert.True(t, snap.Degraded, "p99 of all requests is above the SLO")
+		assert.True(t, tracker.Degraded())
+		assert.Equal(t, uint64(3), snap.Overall.Count)
+		require.Len(t, snap.Routes, 3)
+		assert.Equal(t, [][2]string{{"GET", "/"}, {"GET", "/*"}, {"PUT", "/*"}}, [][2]string{
+			{snap.Routes[0].Method, snap.Routes[0].Route},
+			{snap.Routes[1].Method, snap.Routes[1].Route},
+			{snap.Routes[2].Method, snap.Routes[2].Route},
+		})
+	})
+
+	t.Run("not degraded without an SLO", func(t *testing.T) {
+		tracker := stowryhttp.NewLatencyTracker(time.Minute, 5, 0)
+		tracker.Record(http.MethodGet, "/*", time.Hour)
+
+		assert.False(t, tracker.Snapshot().Degraded)
+		assert.False(t, tracker.Degraded())
+	})
+
+	t.Run("old windows expire", func(t *testing.T) {
+		tracker := stowryhttp.NewLatencyTracker(20*time.Millisecond, 2, 0)
+		tracker.Record(http.MethodGet, "/*", time.Millisecond)
+		require.Equal(t, uint64(1), tracker.Snapshot().Overall.Count)
+
+		time.Sleep(60 * time.Millisecond)
+
+		snap := tracker.Snapshot()
+		assert.Zero(t, snap.Overall.Count)
+		assert.Empty(t, snap.Routes)
+	})
+}
+
+func TestLatencyTracker_Record_DoesNotAllocate(t *testing.T) {
+	tracker := stowryhttp.NewLatencyTracker(time.Minute, 5, 0)
+	tracker.Record(http.MethodGet, "/*", time.Millisecond)
+
+	allocs := testing.AllocsPerRun(1000, func() {
+		tracker.Record(http.MethodGet, "/*", time.Millisecond)
+	})
+	assert.Zero(t, allocs)
+}
+
+func TestHandler_Latency(t *testing.T) {
+	tracker := stowryhttp.NewLatencyTracker(time.Minute, 5, 0)
+	service := new(MockService)
+	service.On("List", mock.Anything, mock.Anything).Return(stowry.ListResult{}, nil)
+	h, err := stowryhttp.New(&stowryhttp.HandlerConfig{Mode: stowry.ModeStore, Latency: tracker}, service)
+	require.NoError(t, err)
+	router := h.Router()
+
+	for _, target := range []string{"/", "/?watch&timeout=0", "/missing/../path"} {
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, target, nil))
+	}
+
+	rec := httptest.NewRecorder()
+	tracker.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/stats/latency", nil))
+	assert.Equal(t, "no-store", rec.Header().Get("Cache-Control"))
+
+	var snap stowryhttp.LatencySnapshot
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &snap))
+	routes := make(map[string]uint64)
+	for _, r := range snap.Routes {
+		routes[r.Method+" "+r.Route] += r.Count
+	}
+	assert.Equal(t, map[string]uint64{"GET /": 1, "GET /*": 1}, routes, "watches are not recorded")
+}
+
+func BenchmarkLatencyTracker_Record(b *testing.B) {
+	tracker := stowryhttp.NewLatencyTracker(time.Minute, 5, 0)
+	b.ReportAllocs()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			tracker.Record(http.MethodGet, "/*", 3*time.Millisecond)
+		}
+	})
+}
+
+func BenchmarkLatencyTracker_Middleware(b *testing.B) {
+	next := http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {})
+	req := httptest.NewRequest(http.MethodGet, "/docs/a.txt", nil)
+	w := httptest.NewRecorder()
+
+	b.Run("disabled", func(b *testing.B) {
+		var tracker *stowryhttp.LatencyTracker
+		h := tracker.Middleware(next)
+		b.ReportAllocs()
+		for b.Loop() {
+			h.ServeHTTP(w, req)
+		}
+	})
+
+	b.Run("enabled", func(b *testing.B) {
+		h := stowryhttp.NewLatencyTracker(time.Minute, 5, 0).Middleware(next)
+		b.ReportAllocs()
+		for b.Loop() {
+			h.ServeHTTP(w, req)
+		}
+	})
+}
@@ -0,0 +1,116 @@
+package http
+
+import (
+	"context"
+	"expvar"
+	"log/slog"
+	"math"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/sync/semaphore"
+)
+
+// concurrencyVars publishes limiter counters under the expvar name
+// "stowry_concurrency", e.g. write_in_flight and read_rejected.
+var concurrencyVars = expvar.NewMap("stowry_concurrency")
+
+// ConcurrencyLimiter bounds the number of requests of one kind that are
+// served at the same time. Requests beyond the limit wait up to a timeout for
+// a slot and then receive 503 Service Unavailable with Retry-After.
+type ConcurrencyLimiter struct {
+	sem  *semaphore.Weighted
+	wait time.Duration
+
+	inFlight atomic.Int64
+	waiting  atomic.Int64
+	rejected atomic.Int64
+}
+
+// NewConcurrencyLimiter creates a limiter allowing limit concurrent requests.
+// name identifies the limiter in expvar ("read", "write"); a later limiter
+// with the same name replaces the published counters. It returns nil when
+// limit is 0 or less, which disables limiting.
+func NewConcurrencyLimiter(name string, limit int64, wait time.Duration) *ConcurrencyLimiter {
+	if limit <= 0 {
+		return nil
+	}
+
+	l := &ConcurrencyLimiter{
+		sem:  semaphore.NewWeighted(limit),
+		wait: wait,
+	}
+
+	concurrencyVars.Set(name+"_limit", expvar.Func(func() any { return limit }))
+	concurrencyVars.Set(name+"_in_flight", expvar.Func(func() any { return l.inFlight.Load() }))
+	concurrencyVars.Set(name+"_waiting", expvar.Func(func() any { return l.waiting.Load() }))
+	concurrencyVars.Set(name+"_rejected", expvar.Func(func() any { return l.rejected.Load() }))
+
+	return l
+}
+
+// InFlight returns the number of requests currently holding a slot.
+func (l *ConcurrencyLimiter) InFlight() int64 {
+	return l.inFlight.Load()
+}
+
+// Waiting returns the number of requests waiting for a slot.
+func (l *ConcurrencyLimiter) Waiting() int64 {
+	return l.waiting.Load()
+}
+
+// Middleware holds a slot for the duration of each request. A nil limiter
+// passes requests through unchanged.
+func (l *ConcurrencyLimiter) Middleware(next http.Handler) http.Handler {
+	if l == nil {
+		return next
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := l.acquire(r.Context()); err != nil {
+			// The client gave up while waiting; nobody is left to read a response
+			if r.Context().Err() != nil {
+				return
+			}
+
+			l.rejected.Add(1)
+			slog.Warn("concurrency limit reached", "method", r.Method, "path", r.URL.Path, "waited", l.wait)
+			w.Header().Set("Retry-After", strconv.Itoa(l.retryAfter()))
+			WriteError(w, http.StatusServiceUnavailable, "server_busy", "Too many concurrent requests, retry later")
+			return
+		}
+		defer l.release()
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// acquire takes a slot, waiting at most l.wait. On error no slot is held.
+func (l *ConcurrencyLimiter) acquire(ctx context.Context) error {
+	if !l.sem.TryAcquire(1) {
+		l.waiting.Add(1)
+		defer l.waiting.Add(-1)
+
+		waitCtx, cancel := context.WithTimeout(ctx, l.wait)
+		defer cancel()
+
+		if err := l.sem.Acquire(waitCtx, 1); err != nil {
+			return err
+		}
+	}
+
+	l.inFlight.Add(1)
+	return nil
+}
+
+func (l *ConcurrencyLimiter) release() {
+	l.inFlight.Add(-1)
+	l.sem.Release(1)
+}
+
+// retryAfter suggests a retry delay in whole seconds, at least one.
+func (l *ConcurrencyLimiter) retryAfter() int {
+	return max(1, int(math.Ceil(l.wait.Seconds())))
+}
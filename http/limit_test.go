@@ -0,0 +1,154 @@
+package http_test
+
+import (
+	"context"
+	"expvar"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sagarc03/stowry"
+	stowryhttp "github.com/sagarc03/stowry/http"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// blockingHandler holds its slot until release is closed.
+func blockingHandler(entered chan<- struct{}, release <-chan struct{}) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		entered <- struct{}{}
+		<-release
+		w.WriteHeader(http.StatusOK)
+	})
+}
+
+func serveAsync(h http.Handler, req *http.Request) <-chan *httptest.ResponseRecorder {
+	done := make(chan *httptest.ResponseRecorder, 1)
+	go func() {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		done <- rec
+	}()
+	return done
+}
+
+func TestConcurrencyLimiter_RejectsAfterWait(t *testing.T) {
+	limiter := stowryhttp.NewConcurrencyLimiter("test_reject", 1, 20*time.Millisecond)
+	entered := make(chan struct{}, 1)
+	release := make(chan struct{})
+	handler := limiter.Middleware(blockingHandler(entered, release))
+
+	first := serveAsync(handler, httptest.NewRequest(http.MethodPut, "/a", http.NoBody))
+	<-entered
+	assert.Equal(t, int64(1), limiter.InFlight())
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPut, "/b", http.NoBody))
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+	assert.Equal(t, "1", rec.Header().Get("Retry-After"))
+	assert.Contains(t, rec.Body.String(), "server_busy")
+
+	close(release)
+	assert.Equal(t, http.StatusOK, (<-first).Code)
+	assert.Equal(t, int64(0), limiter.InFlight())
+
+	assert.Equal(t, "1", expvar.Get("stowry_concurrency").(*expvar.Map).Get("test_reject_rejected").String())
+}
+
+func TestConcurrencyLimiter_WaiterGetsReleasedSlot(t *testing.T) {
+	limiter := stowryhttp.NewConcurrencyLimiter("test_wait", 1, 5*time.Second)
+	entered := make(chan struct{}, 2)
+	release := make(chan struct{})
+	handler := limiter.Middleware(blockingHandler(entered, release))
+
+	first := serveAsync(handler, httptest.NewRequest(http.MethodPut, "/a", http.NoBody))
+	<-entered
+	second := serveAsync(handler, httptest.NewRequest(http.MethodPut, "/b", http.NoBody))
+	require.Eventually(t, func() bool { return limiter.Waiting() == 1 }, time.Second, time.Millisecond)
+
+	release <- struct{}{}
+	assert.Equal(t, http.StatusOK, (<-first).Code)
+	<-entered
+	assert.Equal(t, int64(1), limiter.InFlight())
+
+	close(release)
+	assert.Equal(t, http.StatusOK, (<-second).Code)
+	assert.Equal(t, int64(0), limiter.InFlight())
+}
+
+func TestConcurrencyLimiter_CancelledWhileWaiting(t *testing.T) {
+	limiter := stowryhttp.NewConcurrencyLimiter("test_cancel", 1, 5*time.Second)
+	entered := make(chan struct{}, 2)
+	release := make(chan struct{})
+	handler := limiter.Middleware(blockingHandler(entered, release))
+
+	first := serveAsync(handler, httptest.NewRequest(http.MethodPut, "/a", http.NoBody))
+	<-entered
+
+	ctx, cancel := context.WithCancel(context.Background())
+	waiter := serveAsync(handler, httptest.NewRequest(http.MethodPut, "/b", http.NoBody).WithContext(ctx))
+	require.Eventually(t, func() bool { return limiter.Waiting() == 1 }, time.Second, time.Millisecond)
+
+	cancel()
+	<-waiter
+	assert.Equal(t, int64(0), limiter.Waiting())
+	assert.Equal(t, int64(1), limiter.InFlight(), "cancelled waiter must not hold a slot")
+
+	close(release)
+	<-first
+	assert.Equal(t, int64(0), limiter.InFlight())
+
+	// The slot is free again for new requests
+	third := serveAsync(handler, httptest.NewRequest(http.MethodPut, "/c", http.NoBody))
+	<-entered
+	assert.Equal(t, http.StatusOK, (<-third).Code)
+	assert.Equal(t, int64(0), limiter.InFlight())
+}
+
+func TestConcurrencyLimiter_Disabled(t *testing.T) {
+	limiter := stowryhttp.NewConcurrencyLimiter("test_disabled", 0, time.Second)
+	assert.Nil(t, limiter)
+
+	called := false
+	handler := limiter.Middleware(http.HandlerFunc(func(http.ResponseWriter, *http.Request) { called = true }))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", http.NoBody))
+	assert.True(t, called)
+}
+
+func TestHandler_WriteLimiter_OnlyLimitsPut(t *testing.T) {
+	writeLimiter := stowryhttp.NewConcurrencyLimiter("test_router", 1, 10*time.Millisecond)
+	config := &stowryhttp.HandlerConfig{Mode: stowry.ModeStore, WriteLimiter: writeLimiter}
+	service := new(MockService)
+	handler := stowryhttp.NewHandler(config, service)
+
+	entered := make(chan struct{})
+	release := make(chan struct{})
+	service.On("Create", mock.Anything, mock.Anything, mock.Anything).Run(func(mock.Arguments) {
+		entered <- struct{}{}
+		<-release
+	}).Return(stowry.MetaData{Path: "a.txt"}, nil)
+	service.On("Delete", mock.Anything, "b.txt").Return(nil)
+
+	put := func(path string) *http.Request {
+		req := httptest.NewRequest(http.MethodPut, path, strings.NewReader("data"))
+		req.Header.Set("Content-Type", "text/plain")
+		return req
+	}
+
+	first := serveAsync(handler.Router(), put("/a.txt"))
+	<-entered
+
+	rec := httptest.NewRecorder()
+	handler.Router().ServeHTTP(rec, put("/c.txt"))
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+
+	rec = httptest.NewRecorder()
+	handler.Router().ServeHTTP(rec, httptest.NewRequest(http.MethodDelete, "/b.txt", http.NoBody))
+	assert.Equal(t, http.StatusNoContent, rec.Code)
+
+	close(release)
+	assert.Equal(t, http.StatusOK, (<-first).Code)
+}
@@ -0,0 +1,106 @@
+package http
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"iter"
+	"net/http"
+
+	"github.com/sagarc03/stowry"
+)
+
+// listFlushItems is how many items a streamed list response writes between
+// flushes, so clients start receiving data before the response is complete.
+const listFlushItems = 100
+
+// listItems yields the items of page and, when follow is set, of the pages
+// after it, fetched from the service with query and each page's NextCursor.
+// A failed fetch yields its error and ends the sequence.
+func (h *Handler) listItems(ctx context.Context, query stowry.ListQuery, page stowry.ListResult, follow bool) iter.Seq2[stowry.MetaData, error] {
+	return func(yield func(stowry.MetaData, error) bool) {
+		for {
+			for _, item := range page.Items {
+				if !yield(h.withPermalink(item), nil) {
+					return
+				}
+			}
+			if !follow || page.NextCursor == "" {
+				return
+			}
+
+			query.Cursor = page.NextCursor
+			var err error
+			page, err = h.service.List(ctx, query)
+			if err != nil {
+				yield(stowry.MetaData{}, err)
+				return
+			}
+		}
+	}
+}
+
+// writeListJSON writes a 200 list response of items and nextCursor, the same
+// document WriteJSON makes of a stowry.ListResult, encoding one item at a
+// time so memory use stays proportional to a single item.
+//
+// The status is committed before the first item. An error from items or
+// from encoding an item ends the output mid-document and is returned; the
+// caller must then abort the connection so clients see truncated JSON
+// rather than a shorter list.
+func writeListJSON(w http.ResponseWriter, items iter.Seq2[stowry.MetaData, error], nextCursor string) error {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+
+	rc := http.NewResponseController(w)
+	bw := bufio.NewWriter(w)
+
+	// Items are encoded through one reused variable and buffer so each item
+	// does not allocate a copy of itself. Encode appends a newline that is
+	// not written.
+	var (
+		cur stowry.MetaData
+		buf bytes.Buffer
+	)
+	enc := json.NewEncoder(&buf)
+
+	_, _ = bw.WriteString(`{"items":[`)
+	n := 0
+	for item, err := range items {
+		if err != nil {
+			return err
+		}
+		cur = item
+		buf.Reset()
+		if err := enc.Encode(&cur); err != nil {
+			return fmt.Errorf("encode %s: %w", item.Path, err)
+		}
+		if n > 0 {
+			_ = bw.WriteByte(',')
+		}
+		_, _ = bw.Write(bytes.TrimSuffix(buf.Bytes(), []byte("\n")))
+		n++
+
+		if n%listFlushItems == 0 {
+			if err := bw.Flush(); err != nil {
+				return err
+			}
+			_ = rc.Flush()
+		}
+	}
+	_ = bw.WriteByte(']')
+
+	if nextCursor != "" {
+		cursor, err := json.Marshal(nextCursor)
+		if err != nil {
+			return err
+		}
+		_, _ = bw.WriteString(`,"next_cursor":`)
+		_, _ = bw.Write(cursor)
+	}
+	_, _ = bw.WriteString("}\n")
+
+	return bw.Flush()
+}
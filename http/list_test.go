@@ -0,0 +1,124 @@
+package http_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/sagarc03/stowry"
+	stowryhttp "github.com/sagarc03/stowry/http"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// listPage returns a page of n objects.
+func listPage(n int, cursor string) stowry.ListResult {
+	items := make([]stowry.MetaData, n)
+	for i := range items {
+		items[i] = exportItem(fmt.Sprintf("files/%05d.bin", i), int64(i))
+	}
+	return stowry.ListResult{Items: items, NextCursor: cursor}
+}
+
+func TestHandler_HandleList_Streamed(t *testing.T) {
+	tests := []struct {
+		name string
+		page stowry.ListResult
+	}{
+		{name: "empty", page: stowry.ListResult{Items: []stowry.MetaData{}}},
+		{name: "one item", page: listPage(1, "")},
+		{name: "several flushes with cursor", page: listPage(250, `next "page"`)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service := new(MockService)
+			service.On("List", mock.Anything, mock.Anything).Return(tt.page, nil)
+			handler := stowryhttp.NewHandler(&stowryhttp.HandlerConfig{Mode: stowry.ModeStore}, service)
+
+			rec := httptest.NewRecorder()
+			handler.Router().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/?limit=1000", http.NoBody))
+
+			want := httptest.NewRecorder()
+			require.NoError(t, stowryhttp.WriteJSON(want, http.StatusOK, tt.page))
+
+			assert.Equal(t, http.StatusOK, rec.Code)
+			assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+			assert.Equal(t, want.Body.String(), rec.Body.String(), "same document as WriteJSON")
+			service.AssertNumberOfCalls(t, "List", 1)
+		})
+	}
+}
+
+func TestHandler_HandleList_MidStreamError(t *testing.T) {
+	page := listPage(150, "")
+	page.Items[120].CreatedAt = time.Date(10000, 1, 1, 0, 0, 0, 0, time.UTC) // not representable in JSON
+
+	service := new(MockService)
+	service.On("List", mock.Anything, mock.Anything).Return(page, nil)
+	handler := stowryhttp.NewHandler(&stowryhttp.HandlerConfig{Mode: stowry.ModeStore}, service)
+
+	t.Run("aborts the handler", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		assert.PanicsWithValue(t, http.ErrAbortHandler, func() {
+			handler.Router().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", http.NoBody))
+		})
+		assert.Equal(t, http.StatusOK, rec.Code, "status is committed before the items")
+		assert.True(t, rec.Flushed, "items before the failure were flushed")
+	})
+
+	t.Run("client sees truncated JSON", func(t *testing.T) {
+		server := httptest.NewServer(handler.Router())
+		defer server.Close()
+
+		resp, err := http.Get(server.URL + "/")
+		require.NoError(t, err)
+		defer resp.Body.Close()
+
+		body, err := io.ReadAll(resp.Body)
+		assert.Error(t, err, "connection is aborted")
+		assert.Contains(t, string(body), "files/00099.bin")
+
+		var result stowry.ListResult
+		assert.Error(t, json.Unmarshal(body, &result))
+	})
+}
+
+// BenchmarkHandleList compares the list response built in memory by WriteJSON
+// with the streamed response, for a page of 10k items.
+func BenchmarkHandleList(b *testing.B) {
+	page := listPage(10000, "cursor")
+
+	b.Run("buffered", func(b *testing.B) {
+		var out bytes.Buffer
+		b.ReportAllocs()
+		for b.Loop() {
+			out.Reset()
+			rec := httptest.NewRecorder()
+			rec.Body = &out
+			_ = stowryhttp.WriteJSON(rec, http.StatusOK, page)
+		}
+	})
+
+	b.Run("streamed", func(b *testing.B) {
+		service := new(MockService)
+		service.On("List", mock.Anything, mock.Anything).Return(page, nil)
+		handler := stowryhttp.NewHandler(&stowryhttp.HandlerConfig{Mode: stowry.ModeStore}, service).Router()
+		req := httptest.NewRequest(http.MethodGet, "/?limit=1000&include=access", http.NoBody)
+
+		var out bytes.Buffer
+		b.ReportAllocs()
+		for b.Loop() {
+			out.Reset()
+			rec := httptest.NewRecorder()
+			rec.Body = &out
+			handler.ServeHTTP(rec, req)
+		}
+	})
+}
@@ -0,0 +1,236 @@
+package http
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// ListenerConfig is one address the server listens on.
+type ListenerConfig struct {
+	// Addr is host:port. An IPv6 host is written in brackets, such as
+	// [::1]:5708; an empty host listens on every interface.
+	Addr string `mapstructure:"addr"`
+	// TLSCertFile and TLSKeyFile are PEM files to serve HTTPS with on this
+	// listener. Empty serves plain HTTP.
+	TLSCertFile string `mapstructure:"tls_cert_file"`
+	TLSKeyFile  string `mapstructure:"tls_key_file"`
+}
+
+// TLS reports whether the listener serves HTTPS.
+func (c ListenerConfig) TLS() bool {
+	return c.TLSCertFile != ""
+}
+
+// Host returns the host of Addr without brackets, empty for every
+// interface or an invalid address.
+func (c ListenerConfig) Host() string {
+	host, _, _ := net.SplitHostPort(c.Addr)
+	return host
+}
+
+// Validate reports an address that is not host:port with a port from 1 to
+// 65535, a bracketed host that is not an IPv6 address, or a certificate
+// without its key.
+func (c ListenerConfig) Validate() error {
+	host, port, err := net.SplitHostPort(c.Addr)
+	if err != nil {
+		return fmt.Errorf("listener %q: %w", c.Addr, err)
+	}
+	if n, err := strconv.Atoi(port); err != nil || n < 1 || n > 65535 {
+		return fmt.Errorf("listener %q: port must be a number from 1 to 65535", c.Addr)
+	}
+	// A bracketed host must be an IPv6 address; a bare one may be a name
+	_, ipErr := netip.ParseAddr(host)
+	if ipErr != nil && (strings.HasPrefix(c.Addr, "[") || !validHostname(host)) {
+		return fmt.Errorf("listener %q: invalid host %q", c.Addr, host)
+	}
+	if (c.TLSCertFile == "") != (c.TLSKeyFile == "") {
+		return fmt.Errorf("listener %q: set both tls_cert_file and tls_key_file, or neither", c.Addr)
+	}
+	return nil
+}
+
+// validHostname reports whether host can be a DNS name: non-empty labels of
+// letters, digits, hyphens and underscores. An empty host is valid and
+// listens on every interface.
+func validHostname(host string) bool {
+	if host == "" {
+		return true
+	}
+	label := 0
+	for _, r := range host {
+		switch {
+		case r == '.':
+			if label == 0 {
+				return false
+			}
+			label = 0
+			continue
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '-', r == '_':
+		default:
+			return false
+		}
+		label++
+	}
+	return label > 0
+}
+
+// ValidateListeners validates each listener and reports an address listed
+// twice.
+func ValidateListeners(listeners []ListenerConfig) error {
+	seen := make(map[string]bool, len(listeners))
+	for _, l := range listeners {
+		if err := l.Validate(); err != nil {
+			return err
+		}
+		if seen[l.Addr] {
+			return fmt.Errorf("listener %q: listed more than once", l.Addr)
+		}
+		seen[l.Addr] = true
+	}
+	return nil
+}
+
+// Servers serves one handler on several listeners, which start and stop
+// together.
+type Servers struct {
+	servers   []*http.Server
+	listeners []net.Listener
+	configs   []ListenerConfig
+}
+
+// Listen binds every listener, with a server from newServer for each. It is
+// all or nothing: when an address cannot be bound or a certificate cannot be
+// loaded, the addresses already bound are released and the error reports
+// every listener that failed.
+func Listen(listeners []ListenerConfig, newServer func() *http.Server) (*Servers, error) {
+	if len(listeners) == 0 {
+		return nil, errors.New("listen: no listeners")
+	}
+
+	s := &Servers{}
+	var errs []error
+	for _, l := range listeners {
+		server := newServer()
+		if l.TLS() {
+			cert, err := tls.LoadX509KeyPair(l.TLSCertFile, l.TLSKeyFile)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("listener %s: load certificate: %w", l.Addr, err))
+				continue
+			}
+			server.TLSConfig = &tls.Config{
+				Certificates: []tls.Certificate{cert},
+				MinVersion:   tls.VersionTLS12,
+			}
+		}
+
+		ln, err := net.Listen("tcp", l.Addr)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("listener %s: %w", l.Addr, err))
+			continue
+		}
+		server.Addr = ln.Addr().String()
+		s.servers = append(s.servers, server)
+		s.listeners = append(s.listeners, ln)
+		s.configs = append(s.configs, l)
+	}
+
+	if len(errs) > 0 {
+		for _, ln := range s.listeners {
+			_ = ln.Close()
+		}
+		return nil, fmt.Errorf("listen: %w", errors.Join(errs...))
+	}
+	return s, nil
+}
+
+// Addrs returns the bound addresses, in the order of the listeners. A port
+// of 0 is replaced by the one chosen.
+func (s *Servers) Addrs() []net.Addr {
+	addrs := make([]net.Addr, len(s.listeners))
+	for i, ln := range s.listeners {
+		addrs[i] = ln.Addr()
+	}
+	return addrs
+}
+
+// URLs returns the base URL of each listener, such as http://[::1]:5708.
+func (s *Servers) URLs() []string {
+	urls := make([]string, len(s.listeners))
+	for i, ln := range s.listeners {
+		scheme := "http"
+		if s.configs[i].TLS() {
+			scheme = "https"
+		}
+		urls[i] = scheme + "://" + ln.Addr().String()
+	}
+	return urls
+}
+
+// Serve serves every listener until the servers are shut down or one of
+// them fails. It returns http.ErrServerClosed after Shutdown or Close;
+// when a server fails, the others are closed and its error is returned.
+func (s *Servers) Serve() error {
+	errs := make(chan error, len(s.servers))
+	for i, server := range s.servers {
+		go func() {
+			if s.configs[i].TLS() {
+				errs <- server.ServeTLS(s.listeners[i], "", "")
+				return
+			}
+			errs <- server.Serve(s.listeners[i])
+		}()
+	}
+
+	var first error
+	for range s.servers {
+		err := <-errs
+		if first == nil {
+			first = err
+			if !errors.Is(err, http.ErrServerClosed) {
+				_ = s.Close()
+			}
+		}
+	}
+	return first
+}
+
+// Shutdown gracefully shuts down every server at once, each draining its
+// own connections, and returns their errors joined.
+func (s *Servers) Shutdown(ctx context.Context) error {
+	var wg sync.WaitGroup
+	errs := make([]error, len(s.servers))
+	for i, server := range s.servers {
+		wg.Go(func() {
+			if err := server.Shutdown(ctx); err != nil {
+				errs[i] = fmt.Errorf("listener %s: %w", server.Addr, err)
+			}
+		})
+	}
+	wg.Wait()
+	return errors.Join(errs...)
+}
+
+// Close closes every server and its listener immediately.
+func (s *Servers) Close() error {
+	var errs []error
+	for _, server := range s.servers {
+		if err := server.Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	for _, ln := range s.listeners {
+		if err := ln.Close(); err != nil && !errors.Is(err, net.ErrClosed) {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,218 @@
+package http_test
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	stowryhttp "github.com/sagarc03/stowry/http"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestListenerConfig_Validate(t *testing.T) {
+	valid := []string{
+		"127.0.0.1:5708",
+		"0.0.0.0:8080",
+		":5708",
+		"[::1]:5708",
+		"[::]:5708",
+		"[fe80::1%eth0]:5708",
+		"localhost:5708",
+		"stowry.internal:5708",
+	}
+	for _, addr := range valid {
+		assert.NoError(t, stowryhttp.ListenerConfig{Addr: addr}.Validate(), addr)
+	}
+
+	invalid := []string{
+		"",
+		"5708",
+		"::1:5708",
+		"[::1]",
+		"127.0.0.1:0",
+		"127.0.0.1:65536",
+		"127.0.0.1:http",
+		"bad host:5708",
+		"[not-ipv6]:5708",
+	}
+	for _, addr := range invalid {
+		assert.Error(t, stowryhttp.ListenerConfig{Addr: addr}.Validate(), addr)
+	}
+
+	err := stowryhttp.ListenerConfig{Addr: ":8443", TLSCertFile: "cert.pem"}.Validate()
+	assert.ErrorContains(t, err, "tls_key_file")
+
+	err = stowryhttp.ValidateListeners([]stowryhttp.ListenerConfig{{Addr: ":5708"}, {Addr: ":5708"}})
+	assert.ErrorContains(t, err, "more than once")
+}
+
+// ipv6Loopback skips the test when the host has no IPv6 loopback.
+func ipv6Loopback(t *testing.T) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "[::1]:0")
+	if err != nil {
+		t.Skipf("IPv6 loopback unavailable: %v", err)
+	}
+	_ = ln.Close()
+}
+
+func newTestServer(handler http.Handler) func() *http.Server {
+	return func() *http.Server {
+		return &http.Server{Handler: handler, ReadHeaderTimeout: 5 * time.Second}
+	}
+}
+
+// serve starts servers and returns a function that shuts them down and
+// returns the result of Serve.
+func serve(t *testing.T, servers *stowryhttp.Servers) func() error {
+	t.Helper()
+	done := make(chan error, 1)
+	go func() { done <- servers.Serve() }()
+	t.Cleanup(func() { _ = servers.Close() })
+	return func() error {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		require.NoError(t, servers.Shutdown(ctx))
+		return <-done
+	}
+}
+
+func get(t *testing.T, client *http.Client, url string) string {
+	t.Helper()
+	resp, err := client.Get(url)
+	require.NoError(t, err)
+	defer func() { _ = resp.Body.Close() }()
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	return string(body)
+}
+
+func TestListen(t *testing.T) {
+	hello := http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte("hello"))
+	})
+
+	t.Run("ipv4 and ipv6 share the handler", func(t *testing.T) {
+		ipv6Loopback(t)
+		servers, err := stowryhttp.Listen([]stowryhttp.ListenerConfig{
+			{Addr: "127.0.0.1:0"},
+			{Addr: "[::1]:0"},
+		}, newTestServer(hello))
+		require.NoError(t, err)
+		stop := serve(t, servers)
+
+		urls := servers.URLs()
+		require.Len(t, urls, 2)
+		assert.Regexp(t, `^http://127\.0\.0\.1:\d+$`, urls[0])
+		assert.Regexp(t, `^http://\[::1\]:\d+$`, urls[1])
+		for _, url := range urls {
+			assert.Equal(t, "hello", get(t, http.DefaultClient, url), url)
+		}
+
+		require.ErrorIs(t, stop(), http.ErrServerClosed)
+		for _, url := range urls {
+			_, err := http.Get(url)
+			assert.Error(t, err, "%s is closed after shutdown", url)
+		}
+	})
+
+	t.Run("one failed bind releases the others", func(t *testing.T) {
+		taken, err := net.Listen("tcp", "127.0.0.1:0")
+		require.NoError(t, err)
+		defer func() { _ = taken.Close() }()
+
+		free, err := net.Listen("tcp", "127.0.0.1:0")
+		require.NoError(t, err)
+		freeAddr := free.Addr().String()
+		require.NoError(t, free.Close())
+
+		_, err = stowryhttp.Listen([]stowryhttp.ListenerConfig{
+			{Addr: freeAddr},
+			{Addr: taken.Addr().String()},
+			{Addr: "127.0.0.1:0", TLSCertFile: "missing.pem", TLSKeyFile: "missing.key"},
+		}, newTestServer(hello))
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), taken.Addr().String())
+		assert.Contains(t, err.Error(), "load certificate")
+
+		ln, err := net.Listen("tcp", freeAddr)
+		require.NoError(t, err, "the address bound before the failure is released")
+		_ = ln.Close()
+	})
+
+	t.Run("tls listener", func(t *testing.T) {
+		certFile, keyFile, pool := writeTestCertificate(t)
+		servers, err := stowryhttp.Listen([]stowryhttp.ListenerConfig{
+			{Addr: "127.0.0.1:0"},
+			{Addr: "127.0.0.1:0", TLSCertFile: certFile, TLSKeyFile: keyFile},
+		}, newTestServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.TLS != nil {
+				_, _ = w.Write([]byte("https"))
+				return
+			}
+			_, _ = w.Write([]byte("http"))
+		})))
+		require.NoError(t, err)
+		stop := serve(t, servers)
+
+		urls := servers.URLs()
+		assert.Regexp(t, `^https://`, urls[1])
+		client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: pool, MinVersion: tls.VersionTLS12}}}
+		assert.Equal(t, "http", get(t, client, urls[0]))
+		assert.Equal(t, "https", get(t, client, urls[1]))
+
+		require.ErrorIs(t, stop(), http.ErrServerClosed)
+	})
+
+	t.Run("no listeners", func(t *testing.T) {
+		_, err := stowryhttp.Listen(nil, newTestServer(hello))
+		assert.Error(t, err)
+	})
+}
+
+// writeTestCertificate writes a self-signed certificate for 127.0.0.1 and
+// returns its files and a pool trusting it.
+func writeTestCertificate(t *testing.T) (certFile, keyFile string, pool *x509.CertPool) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "stowry test"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+
+	dir := t.TempDir()
+	certFile = filepath.Join(dir, "cert.pem")
+	keyFile = filepath.Join(dir, "key.pem")
+	require.NoError(t, os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600))
+	require.NoError(t, os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600))
+
+	pool = x509.NewCertPool()
+	pool.AddCert(cert)
+	return certFile, keyFile, pool
+}
@@ -0,0 +1,56 @@
+package http
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sagarc03/stowry"
+)
+
+// CreatedAtHeader carries, on GET, HEAD and upload responses, when the
+// object was first created, in RFC 3339. Last-Modified reports when it was
+// last written.
+const CreatedAtHeader = "X-Stowry-Created-At"
+
+// isMetadataRequest reports whether a GET asks for an object's metadata
+// instead of its content.
+func isMetadataRequest(r *http.Request) bool {
+	return r.URL.Query().Has("metadata")
+}
+
+// setMetadataHeaders reports obj's ID and creation time, for clients that
+// would otherwise need a second request for them. Objects recorded without
+// them omit the headers.
+func setMetadataHeaders(header http.Header, obj stowry.MetaData) {
+	if obj.ID != uuid.Nil {
+		header.Set(ObjectIDHeader, obj.ID.String())
+	}
+	if !obj.CreatedAt.IsZero() {
+		header.Set(CreatedAtHeader, obj.CreatedAt.UTC().Format(time.RFC3339))
+	}
+}
+
+// handleMetadata serves GET with ?metadata: the MetaData JSON of the object
+// a plain GET would serve, resolved the same way, so a fallback reports the
+// document actually served. It reads the metadata only and does not count as
+// a download. ?include=access and ?include=backend add the fields they name.
+func (h *Handler) handleMetadata(w http.ResponseWriter, r *http.Request) {
+	obj, ok := h.resolveInfo(w, r, false)
+	if !ok {
+		return
+	}
+
+	obj = h.withPermalink(obj)
+	if !includeAccess(r) {
+		obj.DownloadCount = 0
+		obj.LastAccessedAt = nil
+	}
+	if !includeBackend(r) {
+		obj.StorageBackend = ""
+	}
+
+	setMetadataHeaders(w.Header(), obj)
+	w.Header().Set("Cache-Control", "no-store")
+	_ = WriteJSON(w, http.StatusOK, obj)
+}
@@ -0,0 +1,163 @@
+package http_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sagarc03/stowry"
+	stowryhttp "github.com/sagarc03/stowry/http"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHandler_MetadataHeaders(t *testing.T) {
+	config := &stowryhttp.HandlerConfig{Mode: stowry.ModeStore}
+	id := uuid.MustParse("01563e3a-b5d3-d676-4c61-efb99302bd5b")
+	created := time.Date(2024, 3, 1, 12, 30, 0, 0, time.FixedZone("", 2*60*60))
+	obj := stowry.MetaData{
+		ID:            id,
+		Path:          "a.txt",
+		ContentType:   "text/plain",
+		Etag:          "e",
+		FileSizeBytes: 4,
+		CreatedAt:     created,
+		UpdatedAt:     created.Add(time.Hour),
+	}
+
+	t.Run("get", func(t *testing.T) {
+		service := new(MockService)
+		service.On("Get", mock.Anything, "a.txt").Return(obj, readSeekNopCloser{strings.NewReader("data")}, nil)
+
+		rec := httptest.NewRecorder()
+		stowryhttp.NewHandler(config, service).Router().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/a.txt", nil))
+
+		require.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, id.String(), rec.Header().Get(stowryhttp.ObjectIDHeader))
+		assert.Equal(t, "2024-03-01T10:30:00Z", rec.Header().Get(stowryhttp.CreatedAtHeader))
+		assert.Equal(t, "data", rec.Body.String())
+	})
+
+	t.Run("head", func(t *testing.T) {
+		service := new(MockService)
+		service.On("Info", mock.Anything, "a.txt").Return(obj, nil)
+
+		rec := httptest.NewRecorder()
+		stowryhttp.NewHandler(config, service).Router().ServeHTTP(rec, httptest.NewRequest(http.MethodHead, "/a.txt", nil))
+
+		require.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, id.String(), rec.Header().Get(stowryhttp.ObjectIDHeader))
+		assert.Equal(t, "2024-03-01T10:30:00Z", rec.Header().Get(stowryhttp.CreatedAtHeader))
+	})
+
+	t.Run("put", func(t *testing.T) {
+		service := new(MockService)
+		service.On("Create", mock.Anything, mock.Anything, mock.Anything).Return(obj, nil)
+
+		rec := httptest.NewRecorder()
+		stowryhttp.NewHandler(config, service).Router().ServeHTTP(rec, httptest.NewRequest(http.MethodPut, "/a.txt", strings.NewReader("data")))
+
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		assert.Equal(t, id.String(), rec.Header().Get(stowryhttp.ObjectIDHeader))
+		assert.Equal(t, "2024-03-01T10:30:00Z", rec.Header().Get(stowryhttp.CreatedAtHeader))
+	})
+
+	t.Run("no id", func(t *testing.T) {
+		service := new(MockService)
+		service.On("Info", mock.Anything, "a.txt").Return(stowry.MetaData{Path: "a.txt", Etag: "e"}, nil)
+
+		rec := httptest.NewRecorder()
+		stowryhttp.NewHandler(config, service).Router().ServeHTTP(rec, httptest.NewRequest(http.MethodHead, "/a.txt", nil))
+
+		require.Equal(t, http.StatusOK, rec.Code)
+		assert.NotContains(t, rec.Header(), stowryhttp.ObjectIDHeader)
+		assert.NotContains(t, rec.Header(), stowryhttp.CreatedAtHeader)
+	})
+}
+
+func TestHandler_HandleGet_Metadata(t *testing.T) {
+	accessed := time.Date(2024, 3, 2, 0, 0, 0, 0, time.UTC)
+	obj := stowry.MetaData{
+		ID:             uuid.MustParse("01563e3a-b5d3-d676-4c61-efb99302bd5b"),
+		Path:           "a.txt",
+		ContentType:    "text/plain",
+		Etag:           "e",
+		FileSizeBytes:  4,
+		StorageBackend: "archive",
+		CreatedAt:      time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC),
+		UpdatedAt:      time.Date(2024, 3, 1, 1, 0, 0, 0, time.UTC),
+		DownloadCount:  7,
+		LastAccessedAt: &accessed,
+	}
+
+	serve := func(t *testing.T, config *stowryhttp.HandlerConfig, service *MockService, target string) (*httptest.ResponseRecorder, stowry.MetaData) {
+		t.Helper()
+		rec := httptest.NewRecorder()
+		stowryhttp.NewHandler(config, service).Router().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, target, nil))
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+		var got stowry.MetaData
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &got))
+		return rec, got
+	}
+
+	t.Run("returns metadata without reading the object", func(t *testing.T) {
+		service := new(MockService)
+		service.On("Info", mock.Anything, "a.txt").Return(obj, nil)
+
+		rec, got := serve(t, &stowryhttp.HandlerConfig{Mode: stowry.ModeStore}, service, "/a.txt?metadata")
+
+		assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+		assert.Equal(t, "no-store", rec.Header().Get("Cache-Control"))
+		assert.Equal(t, obj.ID.String(), rec.Header().Get(stowryhttp.ObjectIDHeader))
+		assert.Equal(t, obj.ID, got.ID)
+		assert.Equal(t, "a.txt", got.Path)
+		assert.Equal(t, "e", got.Etag)
+		assert.True(t, obj.CreatedAt.Equal(got.CreatedAt))
+		assert.Zero(t, got.DownloadCount)
+		assert.Nil(t, got.LastAccessedAt)
+		assert.Empty(t, got.StorageBackend)
+		service.AssertNotCalled(t, "Get", mock.Anything, mock.Anything)
+	})
+
+	t.Run("include", func(t *testing.T) {
+		service := new(MockService)
+		service.On("Info", mock.Anything, "a.txt").Return(obj, nil)
+
+		_, got := serve(t, &stowryhttp.HandlerConfig{Mode: stowry.ModeStore}, service, "/a.txt?metadata&include=access,backend")
+
+		assert.Equal(t, int64(7), got.DownloadCount)
+		require.NotNil(t, got.LastAccessedAt)
+		assert.True(t, accessed.Equal(*got.LastAccessedAt))
+		assert.Equal(t, "archive", got.StorageBackend)
+	})
+
+	t.Run("reports the fallback served", func(t *testing.T) {
+		index := obj
+		index.Path = "index.html"
+		index.ContentType = "text/html"
+		service := new(MockService)
+		service.On("Info", mock.Anything, "app/route").Return(index, nil)
+
+		_, got := serve(t, &stowryhttp.HandlerConfig{Mode: stowry.ModeSPA}, service, "/app/route?metadata")
+
+		assert.Equal(t, "index.html", got.Path)
+		assert.Equal(t, "text/html", got.ContentType)
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		service := new(MockService)
+		service.On("Info", mock.Anything, "missing.txt").Return(stowry.MetaData{}, stowry.ErrNotFound)
+
+		rec := httptest.NewRecorder()
+		stowryhttp.NewHandler(&stowryhttp.HandlerConfig{Mode: stowry.ModeStore}, service).Router().
+			ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/missing.txt?metadata", nil))
+
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+	})
+}
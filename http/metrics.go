@@ -0,0 +1,213 @@
+package http
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/sagarc03/stowry"
+)
+
+// DefaultMetricsPath is where Prometheus metrics are served when no other
+// path is configured.
+const DefaultMetricsPath = "/metrics"
+
+// Metrics records Prometheus metrics for the requests a Handler serves and,
+// as a stowry.ServiceMetrics, for the storage calls and tombstone runs of the
+// service behind it. All of them are registered on their own registry, with
+// the Go runtime and process collectors, and served by ServeHTTP.
+//
+// Requests are labeled with their method, route class and status code. The
+// route class is "get", "put", "delete", "list", "head" or "other", so the
+// label does not grow with the object paths requested.
+type Metrics struct {
+	registry *prometheus.Registry
+
+	requests     *prometheus.CounterVec
+	duration     *prometheus.HistogramVec
+	inFlight     *prometheus.GaugeVec
+	authFailures *prometheus.CounterVec
+	uploaded     prometheus.Counter
+	downloaded   prometheus.Counter
+
+	storageDuration *prometheus.HistogramVec
+	tombstoneItems  *prometheus.CounterVec
+	tombstoneBytes  prometheus.Counter
+}
+
+// NewMetrics creates the metrics and registers them on a new registry.
+func NewMetrics() *Metrics {
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "stowry_http_requests_total",
+			Help: "HTTP requests served, by method, route class and status code.",
+		}, []string{"method", "route", "code"}),
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "stowry_http_request_duration_seconds",
+			Help:    "Time taken to serve HTTP requests, by method, route class and status code.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"method", "route", "code"}),
+		inFlight: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "stowry_http_requests_in_flight",
+			Help: "HTTP requests being served, by method and route class.",
+		}, []string{"method", "route"}),
+		authFailures: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "stowry_http_auth_failures_total",
+			Help: "Requests rejected by authentication or authorization, by reason.",
+		}, []string{"reason"}),
+		uploaded: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "stowry_http_uploaded_bytes_total",
+			Help: "Request body bytes read.",
+		}),
+		downloaded: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "stowry_http_downloaded_bytes_total",
+			Help: "Response body bytes written, after compression.",
+		}),
+		storageDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "stowry_storage_operation_duration_seconds",
+			Help:    "Time taken by storage calls, by operation and result.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"op", "result"}),
+		tombstoneItems: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "stowry_tombstone_items_total",
+			Help: "Soft-deleted items processed by cleanup runs, by outcome.",
+		}, []string{"outcome"}),
+		tombstoneBytes: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "stowry_tombstone_reclaimed_bytes_total",
+			Help: "Bytes removed from storage by cleanup runs.",
+		}),
+	}
+
+	m.registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		m.requests, m.duration, m.inFlight, m.authFailures, m.uploaded, m.downloaded,
+		m.storageDuration, m.tombstoneItems, m.tombstoneBytes,
+	)
+	return m
+}
+
+// Registry returns the registry the metrics are registered on, so callers
+// can add their own.
+func (m *Metrics) Registry() *prometheus.Registry {
+	return m.registry
+}
+
+// ServeHTTP serves the metrics in the Prometheus exposition format.
+func (m *Metrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{}).ServeHTTP(w, r)
+}
+
+// Middleware records every request under its route class, as seen by
+// routeClass for mode. A nil Metrics passes requests through unchanged.
+func (m *Metrics) Middleware(mode stowry.ServerMode) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if m == nil {
+			return next
+		}
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			route := routeClass(r, mode)
+			inFlight := m.inFlight.WithLabelValues(r.Method, route)
+			inFlight.Inc()
+			defer inFlight.Dec()
+
+			if r.Body != nil && r.Body != http.NoBody {
+				r.Body = &countingBody{ReadCloser: r.Body, counter: m.uploaded}
+			}
+			sw := &statusWriter{ResponseWriter: w, status: http.StatusOK}
+
+			start := time.Now()
+			next.ServeHTTP(sw, r)
+			elapsed := time.Since(start)
+
+			code := strconv.Itoa(sw.status)
+			m.requests.WithLabelValues(r.Method, route, code).Inc()
+			m.duration.WithLabelValues(r.Method, route, code).Observe(elapsed.Seconds())
+			m.downloaded.Add(float64(sw.bytes))
+		})
+	}
+}
+
+// RecordAuthFailure counts a request rejected with err by authentication.
+// It does nothing on a nil Metrics.
+func (m *Metrics) RecordAuthFailure(err error) {
+	if m == nil {
+		return
+	}
+	m.authFailures.WithLabelValues(authFailureReason(err)).Inc()
+}
+
+// ObserveStorage implements stowry.ServiceMetrics.
+func (m *Metrics) ObserveStorage(op string, elapsed time.Duration, err error) {
+	result := "ok"
+	switch {
+	case errors.Is(err, stowry.ErrNotFound):
+		result = "not_found"
+	case err != nil:
+		result = "error"
+	}
+	m.storageDuration.WithLabelValues(op, result).Observe(elapsed.Seconds())
+}
+
+// ObserveTombstone implements stowry.ServiceMetrics.
+func (m *Metrics) ObserveTombstone(report stowry.TombstoneReport) {
+	m.tombstoneItems.WithLabelValues("cleaned").Add(float64(report.Cleaned))
+	m.tombstoneItems.WithLabelValues("skipped").Add(float64(report.Skipped))
+	m.tombstoneItems.WithLabelValues("mismatched").Add(float64(report.Mismatched))
+	m.tombstoneItems.WithLabelValues("failed").Add(float64(report.Failed))
+	m.tombstoneItems.WithLabelValues("deferred").Add(float64(report.Deferred))
+	m.tombstoneBytes.Add(float64(report.BytesReclaimed))
+}
+
+// routeClass returns the route class label of r: the listing at / in store
+// mode is "list", other requests are classed by method.
+func routeClass(r *http.Request, mode stowry.ServerMode) string {
+	switch r.Method {
+	case http.MethodGet:
+		if mode == stowry.ModeStore && r.URL.Path == "/" {
+			return "list"
+		}
+		return "get"
+	case http.MethodHead:
+		return "head"
+	case http.MethodPut:
+		return "put"
+	case http.MethodDelete:
+		return "delete"
+	}
+	return "other"
+}
+
+// authFailureReason returns the reason label for an authentication error:
+// "unauthorized" when credentials were sent but refused, or none were
+// accepted by the signature verifier.
+func authFailureReason(err error) string {
+	switch {
+	case errors.Is(err, ErrNoCredentials):
+		return "no_credentials"
+	case errors.Is(err, ErrNonCanonicalPath):
+		return "non_canonical_path"
+	case errors.Is(err, ErrForbidden):
+		return "forbidden"
+	}
+	return "unauthorized"
+}
+
+// countingBody adds the bytes read from a request body to counter.
+type countingBody struct {
+	io.ReadCloser
+	counter prometheus.Counter
+}
+
+func (b *countingBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.counter.Add(float64(n))
+	return n, err
+}
@@ -0,0 +1,110 @@
+package http_test
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sagarc03/stowry"
+	stowryhttp "github.com/sagarc03/stowry/http"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// scrape returns the metrics exposition served by handler at /metrics.
+func scrape(t *testing.T, handler http.Handler) string {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+	req.Header.Set("X-Test-Auth", "ok")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusOK, rec.Code)
+	return rec.Body.String()
+}
+
+func TestHandler_Metrics(t *testing.T) {
+	metrics := stowryhttp.NewMetrics()
+	config := &stowryhttp.HandlerConfig{
+		Mode:         stowry.ModeStore,
+		ReadVerifier: headerVerifier{},
+		Metrics:      metrics,
+		MetricsPath:  "/metrics",
+	}
+	service := new(MockService)
+	service.On("Get", mock.Anything, "a.txt").
+		Return(stowry.MetaData{Path: "a.txt", ContentType: "text/plain", Etag: "e", FileSizeBytes: 5}, readSeekNopCloser{strings.NewReader("hello")}, nil)
+	service.On("List", mock.Anything, mock.Anything).Return(stowry.ListResult{}, nil)
+	handler := stowryhttp.NewHandler(config, service).Router()
+
+	for _, path := range []string{"/a.txt", "/a.txt", "/"} {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.Header.Set("X-Test-Auth", "ok")
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		require.Equal(t, http.StatusOK, rec.Code)
+	}
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/a.txt", nil))
+	require.Equal(t, http.StatusUnauthorized, rec.Code)
+
+	body := scrape(t, handler)
+	assert.Contains(t, body, `stowry_http_requests_total{code="200",method="GET",route="get"} 2`)
+	assert.Contains(t, body, `stowry_http_requests_total{code="200",method="GET",route="list"} 1`)
+	assert.Contains(t, body, `stowry_http_requests_total{code="401",method="GET",route="get"} 1`)
+	assert.Contains(t, body, `stowry_http_request_duration_seconds_count{code="200",method="GET",route="get"} 2`)
+	assert.Contains(t, body, `stowry_http_auth_failures_total{reason="unauthorized"} 1`)
+	assert.Contains(t, body, `stowry_http_requests_in_flight{method="GET",route="list"} 0`)
+	assert.Contains(t, body, "go_goroutines")
+}
+
+func TestHandler_Metrics_Bytes(t *testing.T) {
+	metrics := stowryhttp.NewMetrics()
+	config := &stowryhttp.HandlerConfig{Mode: stowry.ModeStore, Metrics: metrics, MetricsPath: "/metrics"}
+	service := new(MockService)
+	service.On("Create", mock.Anything, mock.Anything, mock.Anything, mock.Anything).
+		Run(func(args mock.Arguments) {
+			_, _ = io.ReadAll(args.Get(2).(io.Reader))
+		}).
+		Return(stowry.MetaData{Path: "a.txt", Etag: "e", FileSizeBytes: 11}, nil)
+	handler := stowryhttp.NewHandler(config, service).Router()
+
+	req := httptest.NewRequest(http.MethodPut, "/a.txt", strings.NewReader("hello world"))
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	body := scrape(t, handler)
+	assert.Contains(t, body, "stowry_http_uploaded_bytes_total 11")
+	assert.Contains(t, body, `stowry_http_requests_total{code="200",method="PUT",route="put"} 1`)
+}
+
+func TestMetrics_Service(t *testing.T) {
+	metrics := stowryhttp.NewMetrics()
+	metrics.ObserveStorage("get", 10*time.Millisecond, nil)
+	metrics.ObserveStorage("get", time.Millisecond, stowry.ErrNotFound)
+	metrics.ObserveStorage("write", time.Millisecond, errors.New("disk full"))
+	metrics.ObserveTombstone(stowry.TombstoneReport{Cleaned: 3, Skipped: 1, Failed: 2, BytesReclaimed: 4096})
+
+	config := &stowryhttp.HandlerConfig{Mode: stowry.ModeStore, Metrics: metrics, MetricsPath: "/metrics"}
+	body := scrape(t, stowryhttp.NewHandler(config, new(MockService)).Router())
+
+	assert.Contains(t, body, `stowry_storage_operation_duration_seconds_count{op="get",result="ok"} 1`)
+	assert.Contains(t, body, `stowry_storage_operation_duration_seconds_count{op="get",result="not_found"} 1`)
+	assert.Contains(t, body, `stowry_storage_operation_duration_seconds_count{op="write",result="error"} 1`)
+	assert.Contains(t, body, `stowry_tombstone_items_total{outcome="cleaned"} 3`)
+	assert.Contains(t, body, `stowry_tombstone_items_total{outcome="failed"} 2`)
+	assert.Contains(t, body, "stowry_tombstone_reclaimed_bytes_total 4096")
+}
+
+func TestHandlerConfig_Validate_MetricsPath(t *testing.T) {
+	config := &stowryhttp.HandlerConfig{Mode: stowry.ModeStore, MetricsPath: "/metrics"}
+	assert.ErrorContains(t, config.Validate(), "metrics path is set without metrics")
+
+	config = &stowryhttp.HandlerConfig{Mode: stowry.ModeStore, Metrics: stowryhttp.NewMetrics(), MetricsPath: "metrics"}
+	assert.ErrorContains(t, config.Validate(), "must start with /")
+}
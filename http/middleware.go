@@ -3,6 +3,7 @@ package http
 import (
 	"log/slog"
 	"net/http"
+	"slices"
 )
 
 // RequestVerifier verifies HTTP requests for authentication.
@@ -15,21 +16,18 @@ type RequestVerifier interface {
 // AuthMiddleware creates middleware that enforces signature authentication.
 // If verifier is nil, requests pass through without authentication.
 func AuthMiddleware(verifier RequestVerifier) func(http.Handler) http.Handler {
-	if verifier == nil {
-		return func(next http.Handler) http.Handler {
-			return next
-		}
-	}
-
-	return func(next http.Handler) http.Handler {
-		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			if err := verifier.Verify(r); err != nil {
-				slog.Warn("authentication failed", "error", err, "method", r.Method, "path", r.URL.Path)
-				HandleError(w, ErrUnauthorized)
-				return
-			}
+	return authenticateMiddleware(authChain(nil, verifier, nil), PermissionRead, slog.Default(), func(w http.ResponseWriter, _ *http.Request, err error) {
+		HandleError(w, err)
+	}, nil)
+}
 
-			next.ServeHTTP(w, r)
-		})
+// authChain returns the authenticator chain for routes protected by verifier:
+// the handler's Authenticators followed by verifier, which reports the keys
+// it accepts to keys when not nil. A nil verifier leaves the routes public,
+// so the chain is empty.
+func authChain(authenticators []Authenticator, verifier RequestVerifier, keys AccessKeyTracker) []Authenticator {
+	if verifier == nil {
+		return nil
 	}
+	return append(slices.Clip(authenticators), SignatureAuthenticator{Verifier: verifier, AccessKeys: keys})
 }
@@ -0,0 +1,60 @@
+package http
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/sagarc03/stowry"
+)
+
+// RenameToParam is the query parameter of POST /{path}?rename-to={newpath},
+// which moves the object at path to newpath. Presigned URLs sign it along
+// with the path, so a URL cannot be pointed at another destination.
+const RenameToParam = "rename-to"
+
+// MoveService is an optional Service extension for moving an object to
+// another path. When the service implements it, store mode serves
+// POST /{path}?rename-to={newpath} with write authentication.
+// *stowry.StowryService implements it.
+type MoveService interface {
+	Move(ctx context.Context, srcPath, dstPath string) (stowry.MetaData, error)
+}
+
+// moveService returns the service's MoveService, if any.
+func (h *Handler) moveService() (MoveService, bool) {
+	if h.config.Mode != stowry.ModeStore {
+		return nil, false
+	}
+	ms, ok := h.service.(MoveService)
+	return ms, ok
+}
+
+// handleMove serves POST /{path}?rename-to={newpath}. The new path may start
+// with "/". The response is the moved object's metadata.
+func (h *Handler) handleMove(w http.ResponseWriter, r *http.Request) {
+	ms, ok := h.moveService()
+	if !ok {
+		h.writeError(w, r, http.StatusNotImplemented, "not_supported", "Moving objects is not supported")
+		return
+	}
+
+	path, ok := h.multipartPath(w, r)
+	if !ok {
+		return
+	}
+	dst := strings.TrimPrefix(r.URL.Query().Get(RenameToParam), "/")
+	if dst == "" {
+		h.writeError(w, r, http.StatusBadRequest, "invalid_parameter", RenameToParam+" must name the new path")
+		return
+	}
+
+	moved, err := ms.Move(r.Context(), path, dst)
+	if err != nil {
+		h.handleError(w, r, err)
+		return
+	}
+
+	w.Header().Set("ETag", `"`+moved.Etag+`"`)
+	_ = WriteJSON(w, http.StatusOK, moved)
+}
@@ -0,0 +1,110 @@
+package http_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/sagarc03/stowry"
+	stowryhttp "github.com/sagarc03/stowry/http"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// moveMockService is a MockService that can move objects.
+type moveMockService struct {
+	MockService
+}
+
+func (m *moveMockService) Move(ctx context.Context, srcPath, dstPath string) (stowry.MetaData, error) {
+	args := m.Called(ctx, srcPath, dstPath)
+	return args.Get(0).(stowry.MetaData), args.Error(1)
+}
+
+func TestHandler_Move(t *testing.T) {
+	serve := func(t *testing.T, service stowryhttp.Service, target string, authed bool) *httptest.ResponseRecorder {
+		t.Helper()
+		h, err := stowryhttp.New(&stowryhttp.HandlerConfig{Mode: stowry.ModeStore, WriteVerifier: headerVerifier{}}, service)
+		require.NoError(t, err)
+		req := httptest.NewRequest(http.MethodPost, target, nil)
+		if authed {
+			req.Header.Set("X-Test-Auth", "ok")
+		}
+		rec := httptest.NewRecorder()
+		h.Router().ServeHTTP(rec, req)
+		return rec
+	}
+
+	t.Run("moves the object", func(t *testing.T) {
+		service := new(moveMockService)
+		service.On("Move", mock.Anything, "uploads/tmp/foo.jpg", "images/foo.jpg").Return(stowry.MetaData{Path: "images/foo.jpg", Etag: "abc123"}, nil)
+
+		rec := serve(t, service, "/uploads/tmp/foo.jpg?rename-to=/images/foo.jpg", true)
+
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		assert.Equal(t, `"abc123"`, rec.Header().Get("ETag"))
+		var meta stowry.MetaData
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &meta))
+		assert.Equal(t, "images/foo.jpg", meta.Path)
+	})
+
+	t.Run("needs write authentication", func(t *testing.T) {
+		service := new(moveMockService)
+
+		rec := serve(t, service, "/a.txt?rename-to=b.txt", false)
+
+		assert.Equal(t, http.StatusUnauthorized, rec.Code)
+		service.AssertNotCalled(t, "Move", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	for name, tc := range map[string]struct {
+		err      error
+		wantCode int
+		wantErr  string
+	}{
+		"missing source":      {fmt.Errorf("move object: %w", stowry.ErrNotFound), http.StatusNotFound, "not_found"},
+		"destination exists":  {fmt.Errorf("move object: %w", stowry.ErrAlreadyExists), http.StatusConflict, "already_exists"},
+		"invalid destination": {fmt.Errorf("move object: %w", stowry.ErrInvalidInput), http.StatusBadRequest, "invalid_path"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			service := new(moveMockService)
+			service.On("Move", mock.Anything, "a.txt", "b.txt").Return(stowry.MetaData{}, tc.err)
+
+			rec := serve(t, service, "/a.txt?rename-to=b.txt", true)
+
+			assert.Equal(t, tc.wantCode, rec.Code)
+			assertErrorCode(t, rec, tc.wantErr)
+		})
+	}
+
+	t.Run("empty destination", func(t *testing.T) {
+		service := new(moveMockService)
+
+		rec := serve(t, service, "/a.txt?rename-to=", true)
+
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		assertErrorCode(t, rec, "invalid_parameter")
+		service.AssertNotCalled(t, "Move", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("not served without MoveService", func(t *testing.T) {
+		rec := serve(t, new(MockService), "/a.txt?rename-to=b.txt", true)
+
+		assert.Equal(t, http.StatusMethodNotAllowed, rec.Code)
+	})
+
+	t.Run("listed in capabilities", func(t *testing.T) {
+		h, err := stowryhttp.New(&stowryhttp.HandlerConfig{Mode: stowry.ModeStore}, new(moveMockService))
+		require.NoError(t, err)
+		rec := httptest.NewRecorder()
+		h.Router().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/?capabilities", nil))
+
+		var caps stowryhttp.Capabilities
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &caps))
+		assert.Contains(t, caps.Features, "move")
+	})
+}
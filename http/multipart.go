@@ -0,0 +1,330 @@
+package http
+
+import (
+	"context"
+	"encoding/xml"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/google/uuid"
+
+	"github.com/sagarc03/stowry"
+)
+
+// maxCompleteMultipartSize bounds the CompleteMultipartUpload body: an entry
+// for each of stowry.MaxMultipartParts parts, with room for whitespace.
+const maxCompleteMultipartSize = 2 << 20
+
+// s3Namespace is the XML namespace of S3 request and response bodies.
+const s3Namespace = "http://s3.amazonaws.com/doc/2006-03-01/"
+
+// MultipartService is an optional Service extension for uploading an object
+// in parts, in the query forms of S3, so AWS SDK presigned multipart flows
+// work. When the service implements it, store mode serves:
+//
+//	POST   /{path}?uploads                        start an upload
+//	PUT    /{path}?partNumber={n}&uploadId={id}   upload part n
+//	POST   /{path}?uploadId={id}                  join the parts into the object
+//	DELETE /{path}?uploadId={id}                  abort the upload
+//
+// *stowry.StowryService implements it.
+type MultipartService interface {
+	InitiateMultipart(ctx context.Context, obj stowry.CreateObject) (stowry.MultipartUpload, error)
+	UploadPart(ctx context.Context, path string, id uuid.UUID, number int, content io.Reader) (stowry.MultipartPart, error)
+	CompleteMultipart(ctx context.Context, path string, id uuid.UUID, parts []stowry.CompletedPart, maxSize int64) (stowry.CreateResult, error)
+	AbortMultipart(ctx context.Context, path string, id uuid.UUID) error
+}
+
+// InitiateMultipartUploadResult is the XML response to POST /{path}?uploads.
+type InitiateMultipartUploadResult struct {
+	XMLName  xml.Name `xml:"InitiateMultipartUploadResult"`
+	Xmlns    string   `xml:"xmlns,attr"`
+	Bucket   string   `xml:"Bucket"`
+	Key      string   `xml:"Key"`
+	UploadID string   `xml:"UploadId"`
+}
+
+// CompleteMultipartUpload is the XML request body of POST /{path}?uploadId.
+type CompleteMultipartUpload struct {
+	XMLName xml.Name        `xml:"CompleteMultipartUpload"`
+	Parts   []CompletedPart `xml:"Part"`
+}
+
+// CompletedPart is a part listed in CompleteMultipartUpload.
+type CompletedPart struct {
+	PartNumber int    `xml:"PartNumber"`
+	ETag       string `xml:"ETag"`
+}
+
+// CompleteMultipartUploadResult is the XML response to POST /{path}?uploadId.
+type CompleteMultipartUploadResult struct {
+	XMLName  xml.Name `xml:"CompleteMultipartUploadResult"`
+	Xmlns    string   `xml:"xmlns,attr"`
+	Location string   `xml:"Location"`
+	Bucket   string   `xml:"Bucket"`
+	Key      string   `xml:"Key"`
+	ETag     string   `xml:"ETag"`
+}
+
+// multipartService returns the service's MultipartService if multipart
+// uploads are available in the configured mode.
+func (h *Handler) multipartService() (MultipartService, bool) {
+	if h.config.Mode != stowry.ModeStore {
+		return nil, false
+	}
+	ms, ok := h.service.(MultipartService)
+	return ms, ok
+}
+
+// isUploadPartRequest reports whether a PUT uploads a part.
+func isUploadPartRequest(r *http.Request) bool {
+	q := r.URL.Query()
+	return q.Has("uploadId") && q.Has("partNumber")
+}
+
+// isMultipartRequest reports whether a DELETE aborts an upload.
+func isMultipartRequest(r *http.Request) bool {
+	return r.URL.Query().Has("uploadId")
+}
+
+// multipartPath returns the object path of a multipart or move request,
+// writing an error and returning false when it is invalid.
+func (h *Handler) multipartPath(w http.ResponseWriter, r *http.Request) (string, bool) {
+	path := strings.TrimPrefix(r.URL.Path, "/")
+	if path == "" || strings.HasSuffix(path, "/") || !stowry.IsValidPath(path) {
+		h.writeError(w, r, http.StatusBadRequest, "invalid_path", "Invalid path")
+		return "", false
+	}
+	return path, true
+}
+
+// uploadID parses the uploadId parameter. An ID that is not a UUID names no
+// upload, so it is answered like an unknown one.
+func (h *Handler) uploadID(w http.ResponseWriter, r *http.Request) (uuid.UUID, bool) {
+	id, err := uuid.Parse(r.URL.Query().Get("uploadId"))
+	if err != nil {
+		h.writeUploadNotFound(w, r)
+		return uuid.Nil, false
+	}
+	return id, true
+}
+
+func (h *Handler) writeUploadNotFound(w http.ResponseWriter, r *http.Request) {
+	h.writeError(w, r, http.StatusNotFound, "upload_not_found", "Multipart upload not found")
+}
+
+// handleMultipartError writes the response for err of a multipart request.
+func (h *Handler) handleMultipartError(w http.ResponseWriter, r *http.Request, err error) {
+	var tooLarge *http.MaxBytesError
+	switch {
+	case errors.As(err, &tooLarge):
+		h.writeError(w, r, http.StatusRequestEntityTooLarge, "too_large", "Part exceeds the maximum size of "+strconv.FormatInt(tooLarge.Limit, 10)+" bytes")
+	case errors.Is(err, stowry.ErrObjectTooLarge):
+		h.writeError(w, r, http.StatusRequestEntityTooLarge, "too_large", "Object would exceed the maximum upload size")
+	case errors.Is(err, stowry.ErrNotFound):
+		h.writeUploadNotFound(w, r)
+	default:
+		h.handleError(w, r, err)
+	}
+}
+
+// handlePostObject serves POST /{path}, which starts or completes a
+// multipart upload, moves the object or restores it.
+func (h *Handler) handlePostObject(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	_, multipart := h.multipartService()
+	switch {
+	case q.Has(RenameToParam):
+		h.handleMove(w, r)
+	case q.Has("restore"):
+		h.handleRestore(w, r)
+	case multipart && q.Has("uploads"):
+		h.handleInitiateMultipart(w, r)
+	case multipart && q.Has("uploadId"):
+		h.handleCompleteMultipart(w, r)
+	default:
+		h.writeError(w, r, http.StatusMethodNotAllowed, "method_not_allowed", "POST is only supported with ?uploads, ?uploadId, ?restore or ?"+RenameToParam)
+	}
+}
+
+func (h *Handler) handleInitiateMultipart(w http.ResponseWriter, r *http.Request) {
+	// Only routed when multipartService is available
+	ms, _ := h.multipartService()
+
+	path, ok := h.multipartPath(w, r)
+	if !ok {
+		return
+	}
+	filename, err := requestFilename(r)
+	if err != nil {
+		h.handleError(w, r, err)
+		return
+	}
+
+	upload, err := ms.InitiateMultipart(r.Context(), stowry.CreateObject{
+		Path:            path,
+		ContentType:     r.Header.Get("Content-Type"),
+		ContentEncoding: r.Header.Get("Content-Encoding"),
+		Filename:        filename,
+		UserMetadata:    requestUserMetadata(r),
+		CacheControl:    r.Header.Get("Cache-Control"),
+	})
+	if err != nil {
+		h.handleError(w, r, err)
+		return
+	}
+
+	_ = writeXML(w, http.StatusOK, InitiateMultipartUploadResult{
+		Xmlns:    s3Namespace,
+		Key:      upload.Path,
+		UploadID: upload.ID.String(),
+	})
+}
+
+// multipartMaxSize returns the maximum upload size of a multipart request:
+// the server's, or the presigned URL's max-size if smaller. Zero means no
+// limit.
+func (h *Handler) multipartMaxSize(w http.ResponseWriter, r *http.Request) (int64, bool) {
+	constraints, err := stowry.ParsePresignConstraints(r.URL.Query())
+	if err != nil {
+		h.writeError(w, r, http.StatusBadRequest, "invalid_parameter", err.Error())
+		return 0, false
+	}
+	maxSize := h.config.MaxUploadSize
+	if constraints.MaxSize > 0 && (maxSize == 0 || constraints.MaxSize < maxSize) {
+		maxSize = constraints.MaxSize
+	}
+	return maxSize, true
+}
+
+// handleUploadPart serves PUT /{path}?partNumber={n}&uploadId={id}. The
+// maximum upload size applies to each part here, and to their total when
+// the upload is completed.
+func (h *Handler) handleUploadPart(w http.ResponseWriter, r *http.Request) {
+	// Only routed when multipartService is available
+	ms, _ := h.multipartService()
+
+	path, ok := h.multipartPath(w, r)
+	if !ok {
+		return
+	}
+	id, ok := h.uploadID(w, r)
+	if !ok {
+		return
+	}
+	number, err := strconv.Atoi(r.URL.Query().Get("partNumber"))
+	if err != nil {
+		h.writeError(w, r, http.StatusBadRequest, "invalid_parameter", "partNumber must be an integer")
+		return
+	}
+
+	maxSize, ok := h.multipartMaxSize(w, r)
+	if !ok {
+		return
+	}
+	body := io.Reader(r.Body)
+	if maxSize > 0 {
+		body = http.MaxBytesReader(w, r.Body, maxSize)
+	}
+
+	part, err := ms.UploadPart(r.Context(), path, id, number, body)
+	if err != nil {
+		h.handleMultipartError(w, r, err)
+		return
+	}
+
+	w.Header().Set("ETag", `"`+part.ETag+`"`)
+	w.WriteHeader(http.StatusOK)
+}
+
+// handleCompleteMultipart serves POST /{path}?uploadId={id}. Parts adding
+// up to more than the maximum upload size are refused with 413.
+func (h *Handler) handleCompleteMultipart(w http.ResponseWriter, r *http.Request) {
+	// Only routed when multipartService is available
+	ms, _ := h.multipartService()
+
+	path, ok := h.multipartPath(w, r)
+	if !ok {
+		return
+	}
+	id, ok := h.uploadID(w, r)
+	if !ok {
+		return
+	}
+	maxSize, ok := h.multipartMaxSize(w, r)
+	if !ok {
+		return
+	}
+
+	var req CompleteMultipartUpload
+	if err := xml.NewDecoder(http.MaxBytesReader(w, r.Body, maxCompleteMultipartSize)).Decode(&req); err != nil {
+		h.writeError(w, r, http.StatusBadRequest, "invalid_body", "Request body must be a CompleteMultipartUpload document")
+		return
+	}
+	parts := make([]stowry.CompletedPart, len(req.Parts))
+	for i, p := range req.Parts {
+		parts[i] = stowry.CompletedPart{Number: p.PartNumber, ETag: p.ETag}
+	}
+
+	res, err := ms.CompleteMultipart(r.Context(), path, id, parts, maxSize)
+	if err != nil {
+		// Lost a first-write race: report the winner's ETag, as for PUT
+		if errors.Is(err, stowry.ErrConflict) && res.Etag != "" {
+			w.Header().Set("ETag", `"`+res.Etag+`"`)
+		}
+		h.handleMultipartError(w, r, err)
+		return
+	}
+
+	w.Header().Set("ETag", `"`+res.Etag+`"`)
+	w.Header().Set(UploadETagHeader, res.Etag)
+	w.Header().Set(UploadBytesWrittenHeader, strconv.FormatInt(res.FileSizeBytes, 10))
+	if res.Previous != nil {
+		w.Header().Set(UploadPreviousETagHeader, res.Previous.Etag)
+	}
+	_ = writeXML(w, http.StatusOK, CompleteMultipartUploadResult{
+		Xmlns:    s3Namespace,
+		Location: r.URL.Path,
+		Key:      res.Path,
+		ETag:     `"` + res.Etag + `"`,
+	})
+}
+
+// handleAbortMultipart serves DELETE /{path}?uploadId={id}.
+func (h *Handler) handleAbortMultipart(w http.ResponseWriter, r *http.Request) {
+	// Only routed when multipartService is available
+	ms, _ := h.multipartService()
+
+	path, ok := h.multipartPath(w, r)
+	if !ok {
+		return
+	}
+	id, ok := h.uploadID(w, r)
+	if !ok {
+		return
+	}
+
+	if err := ms.AbortMultipart(r.Context(), path, id); err != nil {
+		h.handleMultipartError(w, r, err)
+		return
+	}
+	writeNoContent(w)
+}
+
+// writeXML writes an XML response. Like WriteJSON, the body is encoded
+// before the header is written.
+func writeXML(w http.ResponseWriter, code int, data any) error {
+	body, err := xml.Marshal(data)
+	if err != nil {
+		return err
+	}
+	body = append([]byte(xml.Header), body...)
+	w.Header().Set("Content-Type", "application/xml")
+	w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+	w.WriteHeader(code)
+	_, _ = w.Write(body)
+	return nil
+}
@@ -0,0 +1,253 @@
+package http_test
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/sagarc03/stowry"
+	stowryhttp "github.com/sagarc03/stowry/http"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// MockMultipartService is a MockService that also implements
+// http.MultipartService.
+type MockMultipartService struct {
+	MockService
+}
+
+func (m *MockMultipartService) InitiateMultipart(ctx context.Context, obj stowry.CreateObject) (stowry.MultipartUpload, error) {
+	args := m.Called(ctx, obj)
+	return args.Get(0).(stowry.MultipartUpload), args.Error(1)
+}
+
+func (m *MockMultipartService) UploadPart(ctx context.Context, path string, id uuid.UUID, number int, content io.Reader) (stowry.MultipartPart, error) {
+	data, err := io.ReadAll(content)
+	if err != nil {
+		return stowry.MultipartPart{}, err
+	}
+	args := m.Called(ctx, path, id, number, string(data))
+	return args.Get(0).(stowry.MultipartPart), args.Error(1)
+}
+
+func (m *MockMultipartService) CompleteMultipart(ctx context.Context, path string, id uuid.UUID, parts []stowry.CompletedPart, maxSize int64) (stowry.CreateResult, error) {
+	args := m.Called(ctx, path, id, parts, maxSize)
+	return args.Get(0).(stowry.CreateResult), args.Error(1)
+}
+
+func (m *MockMultipartService) AbortMultipart(ctx context.Context, path string, id uuid.UUID) error {
+	args := m.Called(ctx, path, id)
+	return args.Error(0)
+}
+
+func newMultipartRouter(t *testing.T, service stowryhttp.Service, config stowryhttp.HandlerConfig) http.Handler {
+	t.Helper()
+	h, err := stowryhttp.New(&config, service)
+	require.NoError(t, err)
+	return h.Router()
+}
+
+func TestHandler_Multipart(t *testing.T) {
+	id := uuid.MustParse("0b9a6c1e-5f1d-4c1b-8f0e-2d9f4a7c3b21")
+	store := stowryhttp.HandlerConfig{Mode: stowry.ModeStore}
+
+	t.Run("initiate", func(t *testing.T) {
+		service := new(MockMultipartService)
+		service.On("InitiateMultipart", mock.Anything, stowry.CreateObject{Path: "big/file.bin", ContentType: "application/octet-stream"}).
+			Return(stowry.MultipartUpload{ID: id, Path: "big/file.bin"}, nil)
+
+		req := httptest.NewRequest(http.MethodPost, "/big/file.bin?uploads", nil)
+		req.Header.Set("Content-Type", "application/octet-stream")
+		rec := httptest.NewRecorder()
+		newMultipartRouter(t, service, store).ServeHTTP(rec, req)
+
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		assert.Equal(t, "application/xml", rec.Header().Get("Content-Type"))
+		var res stowryhttp.InitiateMultipartUploadResult
+		require.NoError(t, xml.Unmarshal(rec.Body.Bytes(), &res))
+		assert.Equal(t, "big/file.bin", res.Key)
+		assert.Equal(t, id.String(), res.UploadID)
+	})
+
+	t.Run("upload part", func(t *testing.T) {
+		service := new(MockMultipartService)
+		service.On("UploadPart", mock.Anything, "big/file.bin", id, 2, "hello").
+			Return(stowry.MultipartPart{Number: 2, ETag: "abc", Size: 5}, nil)
+
+		target := fmt.Sprintf("/big/file.bin?partNumber=2&uploadId=%s", id)
+		rec := httptest.NewRecorder()
+		newMultipartRouter(t, service, store).ServeHTTP(rec, httptest.NewRequest(http.MethodPut, target, strings.NewReader("hello")))
+
+		assert.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		assert.Equal(t, `"abc"`, rec.Header().Get("ETag"))
+		service.AssertExpectations(t)
+	})
+
+	t.Run("parts are limited by the maximum upload size", func(t *testing.T) {
+		service := new(MockMultipartService)
+
+		target := fmt.Sprintf("/big/file.bin?partNumber=1&uploadId=%s", id)
+		rec := httptest.NewRecorder()
+		newMultipartRouter(t, service, stowryhttp.HandlerConfig{Mode: stowry.ModeStore, MaxUploadSize: 3}).
+			ServeHTTP(rec, httptest.NewRequest(http.MethodPut, target, strings.NewReader("hello")))
+
+		assert.Equal(t, http.StatusRequestEntityTooLarge, rec.Code)
+	})
+
+	t.Run("complete", func(t *testing.T) {
+		service := new(MockMultipartService)
+		parts := []stowry.CompletedPart{{Number: 1, ETag: `"a1"`}, {Number: 2, ETag: `"b2"`}}
+		service.On("CompleteMultipart", mock.Anything, "big/file.bin", id, parts, int64(0)).
+			Return(stowry.CreateResult{MetaData: stowry.MetaData{Path: "big/file.bin", Etag: "joined", FileSizeBytes: 10}}, nil)
+
+		body := `<CompleteMultipartUpload>
+			<Part><PartNumber>1</PartNumber><ETag>"a1"</ETag></Part>
+			<Part><PartNumber>2</PartNumber><ETag>"b2"</ETag></Part>
+		</CompleteMultipartUpload>`
+		rec := httptest.NewRecorder()
+		newMultipartRouter(t, service, store).ServeHTTP(rec,
+			httptest.NewRequest(http.MethodPost, "/big/file.bin?uploadId="+id.String(), strings.NewReader(body)))
+
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		assert.Equal(t, `"joined"`, rec.Header().Get("ETag"))
+		assert.Equal(t, "10", rec.Header().Get(stowryhttp.UploadBytesWrittenHeader))
+		var res stowryhttp.CompleteMultipartUploadResult
+		require.NoError(t, xml.Unmarshal(rec.Body.Bytes(), &res))
+		assert.Equal(t, "big/file.bin", res.Key)
+		assert.Equal(t, `"joined"`, res.ETag)
+	})
+
+	t.Run("complete limits the total by the maximum upload size", func(t *testing.T) {
+		service := new(MockMultipartService)
+		service.On("CompleteMultipart", mock.Anything, "big/file.bin", id, []stowry.CompletedPart{{Number: 1}}, int64(3)).
+			Return(stowry.CreateResult{}, fmt.Errorf("complete multipart: %w", stowry.ErrObjectTooLarge))
+
+		body := `<CompleteMultipartUpload><Part><PartNumber>1</PartNumber></Part></CompleteMultipartUpload>`
+		rec := httptest.NewRecorder()
+		newMultipartRouter(t, service, stowryhttp.HandlerConfig{Mode: stowry.ModeStore, MaxUploadSize: 3}).ServeHTTP(rec,
+			httptest.NewRequest(http.MethodPost, "/big/file.bin?uploadId="+id.String(), strings.NewReader(body)))
+
+		assert.Equal(t, http.StatusRequestEntityTooLarge, rec.Code)
+		assert.Contains(t, rec.Body.String(), "too_large")
+		service.AssertExpectations(t)
+	})
+
+	t.Run("complete with an invalid body", func(t *testing.T) {
+		service := new(MockMultipartService)
+
+		rec := httptest.NewRecorder()
+		newMultipartRouter(t, service, store).ServeHTTP(rec,
+			httptest.NewRequest(http.MethodPost, "/big/file.bin?uploadId="+id.String(), strings.NewReader("{}")))
+
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		assertErrorCode(t, rec, "invalid_body")
+	})
+
+	t.Run("complete with invalid parts", func(t *testing.T) {
+		service := new(MockMultipartService)
+		service.On("CompleteMultipart", mock.Anything, "big/file.bin", id, mock.Anything, mock.Anything).
+			Return(stowry.CreateResult{}, fmt.Errorf("complete multipart: %w: part 3 was not uploaded", stowry.ErrInvalidPart))
+
+		body := `<CompleteMultipartUpload><Part><PartNumber>3</PartNumber></Part></CompleteMultipartUpload>`
+		rec := httptest.NewRecorder()
+		newMultipartRouter(t, service, store).ServeHTTP(rec,
+			httptest.NewRequest(http.MethodPost, "/big/file.bin?uploadId="+id.String(), strings.NewReader(body)))
+
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		assertErrorCode(t, rec, "invalid_part")
+	})
+
+	t.Run("abort", func(t *testing.T) {
+		service := new(MockMultipartService)
+		service.On("AbortMultipart", mock.Anything, "big/file.bin", id).Return(nil)
+
+		rec := httptest.NewRecorder()
+		newMultipartRouter(t, service, store).ServeHTTP(rec,
+			httptest.NewRequest(http.MethodDelete, "/big/file.bin?uploadId="+id.String(), nil))
+
+		assert.Equal(t, http.StatusNoContent, rec.Code)
+		service.AssertExpectations(t)
+	})
+
+	t.Run("unknown upload", func(t *testing.T) {
+		service := new(MockMultipartService)
+		service.On("AbortMultipart", mock.Anything, "big/file.bin", id).Return(fmt.Errorf("abort multipart: %w", stowry.ErrNotFound))
+
+		rec := httptest.NewRecorder()
+		newMultipartRouter(t, service, store).ServeHTTP(rec,
+			httptest.NewRequest(http.MethodDelete, "/big/file.bin?uploadId="+id.String(), nil))
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+		assertErrorCode(t, rec, "upload_not_found")
+
+		rec = httptest.NewRecorder()
+		newMultipartRouter(t, service, store).ServeHTTP(rec,
+			httptest.NewRequest(http.MethodPut, "/big/file.bin?partNumber=1&uploadId=nope", strings.NewReader("a")))
+		assert.Equal(t, http.StatusNotFound, rec.Code, "an upload ID that is not a UUID")
+		assertErrorCode(t, rec, "upload_not_found")
+	})
+
+	t.Run("invalid part number", func(t *testing.T) {
+		service := new(MockMultipartService)
+
+		rec := httptest.NewRecorder()
+		newMultipartRouter(t, service, store).ServeHTTP(rec,
+			httptest.NewRequest(http.MethodPut, "/big/file.bin?partNumber=x&uploadId="+id.String(), strings.NewReader("a")))
+
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		assertErrorCode(t, rec, "invalid_parameter")
+	})
+
+	t.Run("POST without a multipart query", func(t *testing.T) {
+		service := new(MockMultipartService)
+
+		rec := httptest.NewRecorder()
+		newMultipartRouter(t, service, store).ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/big/file.bin", nil))
+
+		assert.Equal(t, http.StatusMethodNotAllowed, rec.Code)
+	})
+
+	t.Run("plain PUT and DELETE are unchanged", func(t *testing.T) {
+		service := new(MockMultipartService)
+		service.On("Delete", mock.Anything, "big/file.bin").Return(nil)
+
+		rec := httptest.NewRecorder()
+		newMultipartRouter(t, service, store).ServeHTTP(rec, httptest.NewRequest(http.MethodDelete, "/big/file.bin", nil))
+
+		assert.Equal(t, http.StatusNoContent, rec.Code)
+		service.AssertExpectations(t)
+	})
+
+	t.Run("not routed without multipart support", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		newMultipartRouter(t, new(MockService), store).ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/big/file.bin?uploads", nil))
+
+		assert.Equal(t, http.StatusMethodNotAllowed, rec.Code)
+	})
+
+	t.Run("capabilities", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		newMultipartRouter(t, new(MockMultipartService), store).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/?capabilities", nil))
+
+		require.Equal(t, http.StatusOK, rec.Code)
+		var caps stowryhttp.Capabilities
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &caps))
+		assert.Contains(t, caps.Features, "multipart")
+		assert.Equal(t, stowry.MaxMultipartParts, caps.Limits.MaxMultipartParts)
+	})
+}
+
+func assertErrorCode(t *testing.T, rec *httptest.ResponseRecorder, code string) {
+	t.Helper()
+	var res stowryhttp.ErrorResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &res))
+	assert.Equal(t, code, res.Error)
+}
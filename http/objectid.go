@@ -0,0 +1,23 @@
+package http
+
+import (
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/sagarc03/stowry"
+)
+
+// ObjectIDHeader carries the ID a PUT gives its object, as a UUID or a
+// ULID, instead of one the repository generates. See
+// stowry.StowryService.Create for the rules an ID must follow.
+const ObjectIDHeader = "X-Stowry-Id"
+
+// requestObjectID returns the ID a PUT gives in ObjectIDHeader, or uuid.Nil
+// for none.
+func requestObjectID(r *http.Request) (uuid.UUID, error) {
+	value := r.Header.Get(ObjectIDHeader)
+	if value == "" {
+		return uuid.Nil, nil
+	}
+	return stowry.ParseObjectID(value)
+}
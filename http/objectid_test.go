@@ -0,0 +1,98 @@
+package http_test
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/sagarc03/stowry"
+	stowryhttp "github.com/sagarc03/stowry/http"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHandler_HandlePut_ObjectID(t *testing.T) {
+	config := &stowryhttp.HandlerConfig{Mode: stowry.ModeStore}
+	id := uuid.MustParse("01563e3a-b5d3-d676-4c61-efb99302bd5b")
+
+	for name, value := range map[string]string{
+		"uuid": id.String(),
+		"ulid": "01ARZ3NDEKTSV4RRFFQ69G5FAV",
+	} {
+		t.Run(name, func(t *testing.T) {
+			service := new(MockService)
+			service.On("Create", mock.Anything, mock.MatchedBy(func(obj stowry.CreateObject) bool {
+				return obj.ID == id
+			}), mock.Anything).Return(stowry.MetaData{ID: id, Path: "a.txt", Etag: "e"}, nil)
+
+			req := httptest.NewRequest(http.MethodPut, "/a.txt", strings.NewReader("data"))
+			req.Header.Set(stowryhttp.ObjectIDHeader, value)
+			rec := httptest.NewRecorder()
+			stowryhttp.NewHandler(config, service).Router().ServeHTTP(rec, req)
+
+			require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+			assert.Contains(t, rec.Body.String(), id.String())
+			service.AssertExpectations(t)
+		})
+	}
+
+	t.Run("none", func(t *testing.T) {
+		service := new(MockService)
+		service.On("Create", mock.Anything, mock.MatchedBy(func(obj stowry.CreateObject) bool {
+			return obj.ID == uuid.Nil
+		}), mock.Anything).Return(stowry.MetaData{Path: "a.txt", Etag: "e"}, nil)
+
+		req := httptest.NewRequest(http.MethodPut, "/a.txt", strings.NewReader("data"))
+		rec := httptest.NewRecorder()
+		stowryhttp.NewHandler(config, service).Router().ServeHTTP(rec, req)
+
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		service.AssertExpectations(t)
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		service := new(MockService)
+		for _, value := range []string{"not-an-id", uuid.Nil.String()} {
+			req := httptest.NewRequest(http.MethodPut, "/a.txt", strings.NewReader("data"))
+			req.Header.Set(stowryhttp.ObjectIDHeader, value)
+			rec := httptest.NewRecorder()
+			stowryhttp.NewHandler(config, service).Router().ServeHTTP(rec, req)
+
+			assert.Equal(t, http.StatusBadRequest, rec.Code, value)
+			assert.Contains(t, rec.Body.String(), "invalid_id", value)
+		}
+		service.AssertNotCalled(t, "Create", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("staged upload", func(t *testing.T) {
+		service := new(MockStageService)
+
+		req := httptest.NewRequest(http.MethodPut, "/a.txt", strings.NewReader("data"))
+		req.Header.Set(stowryhttp.ObjectIDHeader, id.String())
+		req.Header.Set(stowryhttp.StageHeader, uuid.NewString())
+		rec := httptest.NewRecorder()
+		newStageRouter(t, service, stowry.ModeStore).ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		assert.Contains(t, rec.Body.String(), "invalid_parameter")
+		service.AssertNotCalled(t, "StageObject", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("conflict", func(t *testing.T) {
+		service := new(MockService)
+		service.On("Create", mock.Anything, mock.Anything, mock.Anything).
+			Return(stowry.MetaData{}, fmt.Errorf("create object a.txt: %w", stowry.ErrIDConflict))
+
+		req := httptest.NewRequest(http.MethodPut, "/a.txt", strings.NewReader("data"))
+		req.Header.Set(stowryhttp.ObjectIDHeader, id.String())
+		rec := httptest.NewRecorder()
+		stowryhttp.NewHandler(config, service).Router().ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusConflict, rec.Code)
+		assert.Contains(t, rec.Body.String(), "id_conflict")
+	})
+}
@@ -0,0 +1,109 @@
+package http
+
+import (
+	"log/slog"
+	"net/http"
+)
+
+// Option customizes a Handler created by New.
+type Option func(*Handler)
+
+// ErrorWriter writes an error response. status, code and message are what
+// the default JSON response would carry, so a custom writer can keep the
+// mapping and change only the format.
+type ErrorWriter func(w http.ResponseWriter, r *http.Request, status int, code, message string)
+
+// MiddlewarePosition selects where WithMiddleware installs middleware.
+type MiddlewarePosition int
+
+const (
+	// BeforeAuth runs for every request, after tracing, CORS and compression
+	// and before signature verification.
+	BeforeAuth MiddlewarePosition = iota
+	// AfterAuth runs for object routes once the request has been verified,
+	// before the concurrency limiters. POST /presign is not included, as it
+	// authenticates itself.
+	AfterAuth
+)
+
+// WithLogger sets the logger used for request errors, failed
+// authentication and redirects file reloads. The default is slog.Default().
+func WithLogger(logger *slog.Logger) Option {
+	return func(h *Handler) {
+		if logger != nil {
+			h.logger = logger
+		}
+	}
+}
+
+// WithVerifier authenticates both reads and writes with verifier,
+// replacing HandlerConfig.ReadVerifier and HandlerConfig.WriteVerifier. A
+// verifier with a Validate() error method, such as *stowry.SignatureVerifier,
+// is validated by New.
+func WithVerifier(verifier RequestVerifier) Option {
+	return func(h *Handler) {
+		h.config.ReadVerifier = verifier
+		h.config.WriteVerifier = verifier
+	}
+}
+
+// WithMiddleware adds middleware at pos. Middleware added at the same
+// position runs in the order given.
+func WithMiddleware(pos MiddlewarePosition, middleware ...func(http.Handler) http.Handler) Option {
+	return func(h *Handler) {
+		switch pos {
+		case BeforeAuth:
+			h.beforeAuth = append(h.beforeAuth, middleware...)
+		case AfterAuth:
+			h.afterAuth = append(h.afterAuth, middleware...)
+		}
+	}
+}
+
+// WithFeatures adds names to the features the capability document lists,
+// for features the server offers outside the handler, such as an
+// authenticator or middleware added with WithMiddleware.
+func WithFeatures(names ...string) Option {
+	return func(h *Handler) {
+		h.features = append(h.features, names...)
+	}
+}
+
+// WithErrorWriter replaces the JSON error responses written by the handler.
+// Error pages served in static and SPA modes are not affected.
+func WithErrorWriter(writer ErrorWriter) Option {
+	return func(h *Handler) {
+		h.errorWriter = writer
+	}
+}
+
+func (h *Handler) writeError(w http.ResponseWriter, r *http.Request, status int, code, message string) {
+	if h.errorWriter != nil {
+		h.errorWriter(w, r, status, code, message)
+		return
+	}
+	WriteError(w, status, code, message)
+}
+
+func (h *Handler) handleError(w http.ResponseWriter, r *http.Request, err error) {
+	if requestCanceled(r, err) {
+		// The client is gone or the request timed out: nothing reads a
+		// response, and it is not a server failure worth an error log.
+		h.logger.Debug("request canceled", "error", err, "method", r.Method, "path", r.URL.Path)
+		return
+	}
+	h.logger.Error("request error", "error", err, "method", r.Method, "path", r.URL.Path)
+	if h.errorWriter != nil {
+		status, code, message := errorStatus(err)
+		h.errorWriter(w, r, status, code, message)
+		return
+	}
+	writeErrorFor(w, err)
+}
+
+// authMiddleware authenticates routes protected by verifier with the
+// handler's Authenticators followed by verifier, requiring perm, and reports
+// through the handler's logger, error writer and metrics.
+func (h *Handler) authMiddleware(verifier RequestVerifier, perm Permission) func(http.Handler) http.Handler {
+	return authenticateMiddleware(authChain(h.config.Authenticators, verifier, h.config.AccessKeys), perm, h.logger, h.handleError, h.config.Metrics.RecordAuthFailure)
+}
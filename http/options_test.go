@@ -0,0 +1,321 @@
+package http_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sagarc03/stowry"
+	stowryhttp "github.com/sagarc03/stowry/http"
+	"github.com/sagarc03/stowry/keybackend"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// headerVerifier accepts requests carrying X-Test-Auth: ok.
+type headerVerifier struct{}
+
+func (headerVerifier) Verify(r *http.Request) error {
+	if r.Header.Get("X-Test-Auth") != "ok" {
+		return errors.New("missing test auth")
+	}
+	return nil
+}
+
+// invalidVerifier fails validation.
+type invalidVerifier struct{ headerVerifier }
+
+func (invalidVerifier) Validate() error { return errors.New("verifier is broken") }
+
+func TestNew_Validation(t *testing.T) {
+	store := keybackend.NewMapSecretStore(map[string]string{"AK": "SK"})
+	presigner, err := stowryhttp.NewPresigner(stowryhttp.PresignConfig{Enabled: true, SigningKey: "AK"}, store)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name    string
+		config  stowryhttp.HandlerConfig
+		wantErr []string
+	}{
+		{
+			name:   "store mode with verifiers",
+			config: stowryhttp.HandlerConfig{Mode: stowry.ModeStore, ReadVerifier: headerVerifier{}, WriteVerifier: headerVerifier{}, Presigner: presigner},
+		},
+		{
+			name:   "static mode public",
+			config: stowryhttp.HandlerConfig{Mode: stowry.ModeStatic, CORS: stowryhttp.CORSConfig{Enabled: true, AllowedOrigins: []string{"*"}}},
+		},
+		{
+			name:    "missing mode",
+			config:  stowryhttp.HandlerConfig{},
+			wantErr: []string{`invalid mode ""`},
+		},
+		{
+			name:    "unknown mode",
+			config:  stowryhttp.HandlerConfig{Mode: "archive"},
+			wantErr: []string{`invalid mode "archive"`},
+		},
+		{
+			name:    "negative limits",
+			config:  stowryhttp.HandlerConfig{Mode: stowry.ModeStore, MaxUploadSize: -1, ListExportMaxRows: -1},
+			wantErr: []string{"max upload size must not be negative", "list export max rows must not be negative"},
+		},
+		{
+			name:    "negative redirects reload interval",
+			config:  stowryhttp.HandlerConfig{Mode: stowry.ModeStatic, RedirectsReloadInterval: -time.Second},
+			wantErr: []string{"redirects reload interval must not be negative"},
+		},
+		{
+			name:    "write verifier outside store mode",
+			config:  stowryhttp.HandlerConfig{Mode: stowry.ModeSPA, WriteVerifier: headerVerifier{}},
+			wantErr: []string{"write verifier is set but spa mode does not accept writes"},
+		},
+		{
+			name:    "presigner outside store mode",
+			config:  stowryhttp.HandlerConfig{Mode: stowry.ModeStatic, Presigner: presigner},
+			wantErr: []string{"presign is only served in store mode"},
+		},
+		{
+			name:    "verifier fails validation",
+			config:  stowryhttp.HandlerConfig{Mode: stowry.ModeStore, ReadVerifier: invalidVerifier{}},
+			wantErr: []string{"read verifier: verifier is broken"},
+		},
+		{
+			name: "signature verifier without keys",
+			config: stowryhttp.HandlerConfig{
+				Mode:          stowry.ModeStore,
+				WriteVerifier: stowry.NewSignatureVerifier(stowry.AuthConfig{}, keybackend.NewMapSecretStore(map[string]string{})),
+			},
+			wantErr: []string{"write verifier: no access keys configured"},
+		},
+		{
+			name:    "cors without origins",
+			config:  stowryhttp.HandlerConfig{Mode: stowry.ModeStore, CORS: stowryhttp.CORSConfig{Enabled: true}},
+			wantErr: []string{"allowed origins must not be empty"},
+		},
+		{
+			name:    "cors credentials with any origin",
+			config:  stowryhttp.HandlerConfig{Mode: stowry.ModeStore, CORS: stowryhttp.CORSConfig{Enabled: true, AllowedOrigins: []string{"*"}, AllowCredentials: true}},
+			wantErr: []string{`allow credentials cannot be used with the "*" origin`},
+		},
+		{
+			name:    "unknown compression encoding",
+			config:  stowryhttp.HandlerConfig{Mode: stowry.ModeStore, Compression: stowryhttp.CompressionConfig{Enabled: true, Encodings: []string{"lzma"}}},
+			wantErr: []string{"compression:"},
+		},
+		{
+			name: "every problem is reported",
+			config: stowryhttp.HandlerConfig{
+				Mode:          stowry.ModeStatic,
+				MaxUploadSize: -1,
+				WriteVerifier: headerVerifier{},
+				CORS:          stowryhttp.CORSConfig{Enabled: true},
+			},
+			wantErr: []string{"max upload size", "write verifier is set", "allowed origins"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h, err := stowryhttp.New(&tt.config, new(MockService))
+			if len(tt.wantErr) == 0 {
+				require.NoError(t, err)
+				assert.NotNil(t, h)
+				return
+			}
+			require.Error(t, err)
+			assert.Nil(t, h)
+			for _, want := range tt.wantErr {
+				assert.Contains(t, err.Error(), want)
+			}
+		})
+	}
+
+	t.Run("nil config", func(t *testing.T) {
+		_, err := stowryhttp.New(nil, new(MockService))
+		assert.ErrorContains(t, err, "handler config is required")
+	})
+
+	t.Run("nil service", func(t *testing.T) {
+		_, err := stowryhttp.New(&stowryhttp.HandlerConfig{Mode: stowry.ModeStore}, nil)
+		assert.ErrorContains(t, err, "service is required")
+	})
+
+	t.Run("config is copied", func(t *testing.T) {
+		config := &stowryhttp.HandlerConfig{Mode: stowry.ModeStore}
+		_, err := stowryhttp.New(config, new(MockService), stowryhttp.WithVerifier(headerVerifier{}))
+		require.NoError(t, err)
+		assert.Nil(t, config.ReadVerifier)
+	})
+}
+
+func TestWithVerifier(t *testing.T) {
+	service := new(MockService)
+	service.On("Delete", mock.Anything, "a.txt").Return(nil)
+
+	h, err := stowryhttp.New(&stowryhttp.HandlerConfig{Mode: stowry.ModeStore}, service, stowryhttp.WithVerifier(headerVerifier{}))
+	require.NoError(t, err)
+	router := h.Router()
+
+	for _, method := range []string{http.MethodGet, http.MethodDelete} {
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(method, "/a.txt", nil))
+		assert.Equal(t, http.StatusUnauthorized, rec.Code, method)
+	}
+
+	req := httptest.NewRequest(http.MethodDelete, "/a.txt", nil)
+	req.Header.Set("X-Test-Auth", "ok")
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusNoContent, rec.Code)
+	service.AssertExpectations(t)
+
+	t.Run("validated by New", func(t *testing.T) {
+		_, err := stowryhttp.New(&stowryhttp.HandlerConfig{Mode: stowry.ModeStore}, service, stowryhttp.WithVerifier(invalidVerifier{}))
+		assert.ErrorContains(t, err, "verifier is broken")
+	})
+}
+
+func TestWithMiddleware(t *testing.T) {
+	var calls []string
+	record := func(name string) func(http.Handler) http.Handler {
+		return func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				calls = append(calls, name)
+				next.ServeHTTP(w, r)
+			})
+		}
+	}
+
+	service := new(MockService)
+	service.On("Info", mock.Anything, "a.txt").Return(stowry.MetaData{Path: "a.txt", Etag: "e"}, nil)
+
+	h, err := stowryhttp.New(&stowryhttp.HandlerConfig{Mode: stowry.ModeStore}, service,
+		stowryhttp.WithVerifier(headerVerifier{}),
+		stowryhttp.WithMiddleware(stowryhttp.AfterAuth, record("after")),
+		stowryhttp.WithMiddleware(stowryhttp.BeforeAuth, record("before1"), record("before2")),
+	)
+	require.NoError(t, err)
+	router := h.Router()
+
+	t.Run("authenticated request", func(t *testing.T) {
+		calls = nil
+		req := httptest.NewRequest(http.MethodHead, "/a.txt", nil)
+		req.Header.Set("X-Test-Auth", "ok")
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, []string{"before1", "before2", "after"}, calls)
+	})
+
+	t.Run("rejected request skips after-auth middleware", func(t *testing.T) {
+		calls = nil
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(http.MethodHead, "/a.txt", nil))
+
+		assert.Equal(t, http.StatusUnauthorized, rec.Code)
+		assert.Equal(t, []string{"before1", "before2"}, calls)
+	})
+}
+
+func TestWithErrorWriter(t *testing.T) {
+	writeText := func(w http.ResponseWriter, r *http.Request, status int, code, message string) {
+		w.Header().Set("Content-Type", "text/plain")
+		w.WriteHeader(status)
+		_, _ = w.Write([]byte(r.Method + " " + code + ": " + message))
+	}
+
+	service := new(MockService)
+	service.On("Get", mock.Anything, "missing.txt").Return(stowry.MetaData{}, nil, stowry.ErrNotFound)
+
+	h, err := stowryhttp.New(&stowryhttp.HandlerConfig{Mode: stowry.ModeStore, WriteVerifier: headerVerifier{}}, service,
+		stowryhttp.WithErrorWriter(writeText))
+	require.NoError(t, err)
+	router := h.Router()
+
+	tests := []struct {
+		name     string
+		method   string
+		target   string
+		wantCode int
+		wantBody string
+	}{
+		{name: "service error", method: http.MethodGet, target: "/missing.txt", wantCode: http.StatusNotFound, wantBody: "GET not_found: Object not found"},
+		{name: "handler error", method: http.MethodGet, target: "/?limit=abc", wantCode: http.StatusBadRequest, wantBody: "GET invalid_parameter: limit must be a valid integer"},
+		{name: "authentication error", method: http.MethodPut, target: "/a.txt", wantCode: http.StatusUnauthorized, wantBody: "PUT unauthorized: unauthorized"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, httptest.NewRequest(tt.method, tt.target, nil))
+
+			assert.Equal(t, tt.wantCode, rec.Code)
+			assert.Equal(t, "text/plain", rec.Header().Get("Content-Type"))
+			assert.Equal(t, tt.wantBody, rec.Body.String())
+		})
+	}
+}
+
+func TestWithLogger(t *testing.T) {
+	var logs bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&logs, nil))
+
+	service := new(MockService)
+	service.On("Get", mock.Anything, "a.txt").Return(stowry.MetaData{}, nil, errors.New("disk on fire"))
+
+	h, err := stowryhttp.New(&stowryhttp.HandlerConfig{Mode: stowry.ModeStore, WriteVerifier: headerVerifier{}}, service,
+		stowryhttp.WithLogger(logger))
+	require.NoError(t, err)
+	router := h.Router()
+
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/a.txt", nil))
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodDelete, "/a.txt", nil))
+
+	lines := strings.Split(strings.TrimSpace(logs.String()), "\n")
+	require.Len(t, lines, 3)
+	assert.Contains(t, lines[0], `msg="request error" error="disk on fire" method=GET path=/a.txt`)
+	assert.Contains(t, lines[1], `msg="authentication failed" error="missing test auth" method=DELETE`)
+	assert.Contains(t, lines[2], `msg="request error" error=unauthorized method=DELETE`)
+}
+
+func TestHandleError_CanceledRequest(t *testing.T) {
+	var logs bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&logs, nil))
+
+	service := new(MockService)
+	service.On("Get", mock.Anything, "a.txt").
+		Return(stowry.MetaData{}, nil, fmt.Errorf("get: %w: interrupted (9)", context.Canceled))
+
+	h, err := stowryhttp.New(&stowryhttp.HandlerConfig{Mode: stowry.ModeStore}, service, stowryhttp.WithLogger(logger))
+	require.NoError(t, err)
+
+	t.Run("canceled request writes nothing", func(t *testing.T) {
+		logs.Reset()
+		ctx, cancel := context.WithCancel(t.Context())
+		cancel()
+
+		rec := httptest.NewRecorder()
+		h.Router().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/a.txt", nil).WithContext(ctx))
+
+		assert.Empty(t, rec.Body.String())
+		assert.False(t, rec.Flushed)
+		assert.NotContains(t, logs.String(), "request error")
+	})
+
+	t.Run("live request still gets an error", func(t *testing.T) {
+		logs.Reset()
+		rec := httptest.NewRecorder()
+		h.Router().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/a.txt", nil))
+
+		assert.Equal(t, http.StatusInternalServerError, rec.Code)
+		assert.Contains(t, logs.String(), `msg="request error"`)
+	})
+}
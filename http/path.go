@@ -0,0 +1,76 @@
+package http
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+type sentURLKey struct{}
+
+// canonicalPathMiddleware replaces the request path with its canonical form
+// and keeps the URL as sent for requestAsSent. Request paths are handled in
+// a fixed order:
+//
+//  1. This middleware collapses runs of slashes, so //a///b.txt becomes
+//     /a/b.txt. Dot segments are kept; path validation rejects them with
+//     400 invalid_path rather than resolving them.
+//  2. Routing, authentication and handlers see only the canonical path.
+//     Signature verifiers are the exception: SignatureAuthenticator hands
+//     them the request as sent, percent-decoded once, so a signature never
+//     covers a path other than the one the client signed.
+//  3. A signed request whose canonical path differs from the path as sent is
+//     rejected with ErrNonCanonicalPath, even when its signature is valid.
+//     Unsigned and bearer token requests are served from the canonical path.
+func canonicalPathMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		canonical := canonicalPath(r.URL.Path)
+		if canonical == r.URL.Path {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		u := *r.URL
+		u.Path = canonical
+		u.RawPath = ""
+		r = r.WithContext(context.WithValue(r.Context(), sentURLKey{}, r.URL))
+		r.URL = &u
+		next.ServeHTTP(w, r)
+	})
+}
+
+// canonicalPath collapses every run of slashes in p into one.
+func canonicalPath(p string) string {
+	if !strings.Contains(p, "//") {
+		return p
+	}
+	var b strings.Builder
+	b.Grow(len(p))
+	for i := 0; i < len(p); i++ {
+		if p[i] == '/' && i > 0 && p[i-1] == '/' {
+			continue
+		}
+		b.WriteByte(p[i])
+	}
+	return b.String()
+}
+
+// requestAsSent returns r with the URL the client sent, before
+// canonicalPathMiddleware rewrote its path. It returns r itself when the path
+// was already canonical.
+func requestAsSent(r *http.Request) *http.Request {
+	sent, ok := r.Context().Value(sentURLKey{}).(*url.URL)
+	if !ok {
+		return r
+	}
+	r = r.WithContext(r.Context())
+	r.URL = sent
+	return r
+}
+
+// pathRewritten reports whether canonicalPathMiddleware changed the path of r.
+func pathRewritten(r *http.Request) bool {
+	_, ok := r.Context().Value(sentURLKey{}).(*url.URL)
+	return ok
+}
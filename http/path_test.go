@@ -0,0 +1,158 @@
+package http_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sagarc03/stowry"
+	stowrysign "github.com/sagarc03/stowry-go"
+	stowryhttp "github.com/sagarc03/stowry/http"
+	"github.com/sagarc03/stowry/keybackend"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+const pathTestAccessKey, pathTestSecretKey = "AKIATEST", "testsecret"
+
+func newPathTestHandler(t testing.TB, service *MockService, private bool) *stowryhttp.Handler {
+	t.Helper()
+	config := &stowryhttp.HandlerConfig{Mode: stowry.ModeStore}
+	if private {
+		store := keybackend.NewMapSecretStore(map[string]string{pathTestAccessKey: pathTestSecretKey})
+		config.ReadVerifier = stowry.NewSignatureVerifier(stowry.AuthConfig{AWS: stowry.AWSConfig{Region: "us-east-1", Service: "s3"}}, store)
+	}
+	h, err := stowryhttp.New(config, service)
+	require.NoError(t, err)
+	return h
+}
+
+// requestWithPath builds a GET request whose decoded path is exactly p, which
+// httptest.NewRequest cannot express for paths such as "//a".
+func requestWithPath(p, rawQuery string) *http.Request {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.URL.Path = p
+	req.URL.RawPath = ""
+	req.URL.RawQuery = rawQuery
+	req.RequestURI = req.URL.RequestURI()
+	return req
+}
+
+func TestRouter_CanonicalPath(t *testing.T) {
+	metadata := stowry.MetaData{Path: "a/b.txt", ContentType: "text/plain", Etag: "abc", FileSizeBytes: 2}
+
+	t.Run("duplicate slashes are collapsed", func(t *testing.T) {
+		service := new(MockService)
+		service.On("Get", mock.Anything, "a/b.txt").Return(metadata, readSeekNopCloser{strings.NewReader("ok")}, nil)
+		h := newPathTestHandler(t, service, false)
+
+		rec := httptest.NewRecorder()
+		h.Router().ServeHTTP(rec, requestWithPath("//a///b.txt", ""))
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		service.AssertExpectations(t)
+	})
+
+	t.Run("dot segments are rejected", func(t *testing.T) {
+		service := new(MockService)
+		h := newPathTestHandler(t, service, false)
+
+		rec := httptest.NewRecorder()
+		h.Router().ServeHTTP(rec, requestWithPath("/a/../b.txt", ""))
+
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		assert.Contains(t, rec.Body.String(), "invalid_path")
+		service.AssertNotCalled(t, "Get", mock.Anything, mock.Anything)
+	})
+
+	t.Run("signature covers the path as sent", func(t *testing.T) {
+		service := new(MockService)
+		h := newPathTestHandler(t, service, true)
+		signed := stowrysign.NewClient("http://example.com", pathTestAccessKey, pathTestSecretKey).PresignGet("/a/b.txt", 900)
+		query := signed[strings.Index(signed, "?")+1:]
+
+		rec := httptest.NewRecorder()
+		h.Router().ServeHTTP(rec, requestWithPath("//a/b.txt", query))
+
+		assert.Equal(t, http.StatusUnauthorized, rec.Code)
+		service.AssertNotCalled(t, "Get", mock.Anything, mock.Anything)
+	})
+
+	t.Run("signed non-canonical path is rejected", func(t *testing.T) {
+		service := new(MockService)
+		h := newPathTestHandler(t, service, true)
+		signed := stowrysign.NewClient("http://example.com", pathTestAccessKey, pathTestSecretKey).PresignGet("//a/b.txt", 900)
+		query := signed[strings.Index(signed, "?")+1:]
+
+		rec := httptest.NewRecorder()
+		h.Router().ServeHTTP(rec, requestWithPath("//a/b.txt", query))
+
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		assert.Contains(t, rec.Body.String(), "non_canonical_path")
+		service.AssertNotCalled(t, "Get", mock.Anything, mock.Anything)
+	})
+
+	t.Run("signed canonical path is served", func(t *testing.T) {
+		service := new(MockService)
+		service.On("Get", mock.Anything, "a/b.txt").Return(metadata, readSeekNopCloser{strings.NewReader("ok")}, nil)
+		h := newPathTestHandler(t, service, true)
+		signed := stowrysign.NewClient("http://example.com", pathTestAccessKey, pathTestSecretKey).PresignGet("/a/b.txt", 900)
+
+		rec := httptest.NewRecorder()
+		h.Router().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, signed, nil))
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		service.AssertExpectations(t)
+	})
+}
+
+// FuzzRouter_Path sends adversarial paths through the full router of a
+// private store, signed for the path as sent and unsigned. Every key that
+// reaches the service must be valid, unsigned requests must never reach it,
+// and signed requests must reach it only for the key they signed.
+func FuzzRouter_Path(f *testing.F) {
+	for _, seed := range []string{
+		"/a.txt", "//a.txt", "/a//b.txt", "/a/../b.txt", "//foo/../bar.txt",
+		"/./a.txt", "/a/.", "/", "", "a.txt", "/a/%2e%2e/b", "/a\x00b", "/..", "/.../a",
+	} {
+		f.Add(seed)
+	}
+
+	f.Fuzz(func(t *testing.T, p string) {
+		var (
+			mu   sync.Mutex
+			keys []string
+		)
+		record := func(args mock.Arguments) {
+			mu.Lock()
+			defer mu.Unlock()
+			keys = append(keys, args.String(1))
+		}
+		service := new(MockService)
+		service.On("Get", mock.Anything, mock.Anything).Run(record).Return(stowry.MetaData{}, nil, stowry.ErrNotFound).Maybe()
+		service.On("List", mock.Anything, mock.Anything).Return(stowry.ListResult{Items: []stowry.MetaData{}}, nil).Maybe()
+		h := newPathTestHandler(t, service, true)
+
+		rec := httptest.NewRecorder()
+		h.Router().ServeHTTP(rec, requestWithPath(p, ""))
+		if len(keys) > 0 {
+			t.Fatalf("unsigned request for %q reached the service with %q", p, keys)
+		}
+
+		query := stowry.PresignStowryQuery(pathTestAccessKey, pathTestSecretKey, http.MethodGet, p, time.Now().Unix(), 900, stowry.PresignConstraints{})
+		rec = httptest.NewRecorder()
+		h.Router().ServeHTTP(rec, requestWithPath(p, query.Encode()))
+		for _, key := range keys {
+			if !stowry.IsValidPath(key) {
+				t.Fatalf("signed request for %q reached the service with invalid key %q", p, key)
+			}
+			if "/"+key != p {
+				t.Fatalf("signed request for %q reached the service with key %q", p, key)
+			}
+		}
+	})
+}
@@ -0,0 +1,72 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/sagarc03/stowry"
+)
+
+// PermalinkService is an optional Service extension for looking up objects by
+// content. When the service implements it, store mode serves GET and HEAD
+// /_by-etag/{etag} with read authentication, and list and stat responses
+// carry each object's permalink. *stowry.StowryService implements it.
+type PermalinkService interface {
+	GetByETag(ctx context.Context, etag string) (stowry.MetaData, io.ReadSeekCloser, error)
+}
+
+// permalinkService returns the service's PermalinkService, if any. It is only
+// served in store mode.
+func (h *Handler) permalinkService() (PermalinkService, bool) {
+	if h.config.Mode != stowry.ModeStore {
+		return nil, false
+	}
+	ps, ok := h.service.(PermalinkService)
+	return ps, ok
+}
+
+// withPermalink sets the permalink of obj when permalinks are served.
+func (h *Handler) withPermalink(obj stowry.MetaData) stowry.MetaData {
+	if _, ok := h.permalinkService(); ok {
+		obj.Permalink = stowry.Permalink(obj.Etag)
+	}
+	return obj
+}
+
+// handleGetByETag serves GET and HEAD /_by-etag/{etag}. The URL changes
+// whenever the content does, so the response may be cached for a year
+// without revalidation.
+func (h *Handler) handleGetByETag(w http.ResponseWriter, r *http.Request) {
+	ps, ok := h.permalinkService()
+	if !ok {
+		h.handleError(w, r, stowry.ErrNotSupported)
+		return
+	}
+
+	obj, content, err := ps.GetByETag(r.Context(), chi.URLParam(r, "etag"))
+	if err != nil {
+		if errors.Is(err, stowry.ErrNotFound) {
+			h.writeError(w, r, http.StatusNotFound, "not_found", "No object has this ETag")
+			return
+		}
+		h.handleError(w, r, err)
+		return
+	}
+	defer func() { _ = content.Close() }()
+
+	w.Header().Set("ETag", `"`+obj.Etag+`"`)
+	w.Header().Set("Content-Type", obj.ContentType)
+	w.Header().Set("Content-Location", "/"+obj.Path)
+	h.setContentDisposition(w, obj)
+	if h.config.ReadVerifier == nil {
+		w.Header().Set("Cache-Control", cacheControlImmutable)
+	} else {
+		// Shared caches must not hand a private object to other clients
+		w.Header().Set("Cache-Control", "private, max-age=31536000, immutable")
+	}
+
+	h.serveDownload(w, r, obj.Path, obj, content)
+}
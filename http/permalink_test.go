@@ -0,0 +1,124 @@
+package http_test
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/sagarc03/stowry"
+	stowryhttp "github.com/sagarc03/stowry/http"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// permalinkMockService is a MockService that can look up objects by ETag.
+type permalinkMockService struct {
+	MockService
+}
+
+func (m *permalinkMockService) GetByETag(ctx context.Context, etag string) (stowry.MetaData, io.ReadSeekCloser, error) {
+	args := m.Called(ctx, etag)
+	var rc io.ReadSeekCloser
+	if v := args.Get(1); v != nil {
+		rc = v.(io.ReadSeekCloser)
+	}
+	return args.Get(0).(stowry.MetaData), rc, args.Error(2)
+}
+
+func TestHandler_GetByETag(t *testing.T) {
+	etag := strings.Repeat("a", 64)
+	obj := stowry.MetaData{Path: "docs/a.txt", Etag: etag, ContentType: "text/plain", FileSizeBytes: 5}
+
+	serve := func(t *testing.T, config *stowryhttp.HandlerConfig, service stowryhttp.Service, req *http.Request) *httptest.ResponseRecorder {
+		t.Helper()
+		h, err := stowryhttp.New(config, service)
+		require.NoError(t, err)
+		rec := httptest.NewRecorder()
+		h.Router().ServeHTTP(rec, req)
+		return rec
+	}
+
+	t.Run("serves immutable content", func(t *testing.T) {
+		service := new(permalinkMockService)
+		service.On("GetByETag", mock.Anything, etag).Return(obj, readSeekNopCloser{strings.NewReader("hello")}, nil)
+
+		rec := serve(t, &stowryhttp.HandlerConfig{Mode: stowry.ModeStore}, service, httptest.NewRequest(http.MethodGet, "/_by-etag/"+etag, http.NoBody))
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		assert.Equal(t, "hello", rec.Body.String())
+		assert.Equal(t, `"`+etag+`"`, rec.Header().Get("ETag"))
+		assert.Equal(t, "/docs/a.txt", rec.Header().Get("Content-Location"))
+		assert.Contains(t, rec.Header().Get("Cache-Control"), "immutable")
+		assert.Contains(t, rec.Header().Get("Cache-Control"), "public")
+	})
+
+	t.Run("head", func(t *testing.T) {
+		service := new(permalinkMockService)
+		service.On("GetByETag", mock.Anything, etag).Return(obj, readSeekNopCloser{strings.NewReader("hello")}, nil)
+
+		rec := serve(t, &stowryhttp.HandlerConfig{Mode: stowry.ModeStore}, service, httptest.NewRequest(http.MethodHead, "/_by-etag/"+etag, http.NoBody))
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Empty(t, rec.Body.String())
+	})
+
+	t.Run("private objects are not cached by shared caches", func(t *testing.T) {
+		service := new(permalinkMockService)
+		service.On("GetByETag", mock.Anything, etag).Return(obj, readSeekNopCloser{strings.NewReader("hello")}, nil)
+
+		req := httptest.NewRequest(http.MethodGet, "/_by-etag/"+etag, http.NoBody)
+		req.Header.Set("X-Test-Auth", "ok")
+		rec := serve(t, &stowryhttp.HandlerConfig{Mode: stowry.ModeStore, ReadVerifier: headerVerifier{}}, service, req)
+		require.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "private, max-age=31536000, immutable", rec.Header().Get("Cache-Control"))
+	})
+
+	t.Run("requires read authentication", func(t *testing.T) {
+		service := new(permalinkMockService)
+		rec := serve(t, &stowryhttp.HandlerConfig{Mode: stowry.ModeStore, ReadVerifier: headerVerifier{}}, service, httptest.NewRequest(http.MethodGet, "/_by-etag/"+etag, http.NoBody))
+		assert.Equal(t, http.StatusUnauthorized, rec.Code)
+		service.AssertNotCalled(t, "GetByETag", mock.Anything, mock.Anything)
+	})
+
+	t.Run("unknown etag", func(t *testing.T) {
+		service := new(permalinkMockService)
+		service.On("GetByETag", mock.Anything, etag).Return(stowry.MetaData{}, nil, stowry.ErrNotFound)
+
+		rec := serve(t, &stowryhttp.HandlerConfig{Mode: stowry.ModeStore}, service, httptest.NewRequest(http.MethodGet, "/_by-etag/"+etag, http.NoBody))
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+		assert.Contains(t, rec.Body.String(), "not_found")
+	})
+
+	t.Run("invalid etag", func(t *testing.T) {
+		service := new(permalinkMockService)
+		service.On("GetByETag", mock.Anything, "xyz").Return(stowry.MetaData{}, nil, stowry.ErrInvalidETag)
+
+		rec := serve(t, &stowryhttp.HandlerConfig{Mode: stowry.ModeStore}, service, httptest.NewRequest(http.MethodGet, "/_by-etag/xyz", http.NoBody))
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		assert.Contains(t, rec.Body.String(), "invalid_etag")
+	})
+
+	t.Run("list carries permalinks", func(t *testing.T) {
+		service := new(permalinkMockService)
+		service.On("List", mock.Anything, mock.Anything).Return(stowry.ListResult{Items: []stowry.MetaData{obj}}, nil)
+
+		rec := serve(t, &stowryhttp.HandlerConfig{Mode: stowry.ModeStore}, service, httptest.NewRequest(http.MethodGet, "/", http.NoBody))
+		require.Equal(t, http.StatusOK, rec.Code)
+		var page stowry.ListResult
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &page))
+		require.Len(t, page.Items, 1)
+		assert.Equal(t, "/_by-etag/"+etag, page.Items[0].Permalink)
+	})
+
+	t.Run("no permalinks without the extension", func(t *testing.T) {
+		service := new(MockService)
+		service.On("List", mock.Anything, mock.Anything).Return(stowry.ListResult{Items: []stowry.MetaData{obj}}, nil)
+
+		rec := serve(t, &stowryhttp.HandlerConfig{Mode: stowry.ModeStore}, service, httptest.NewRequest(http.MethodGet, "/", http.NoBody))
+		require.Equal(t, http.StatusOK, rec.Code)
+		assert.NotContains(t, rec.Body.String(), "permalink")
+	})
+}
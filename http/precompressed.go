@@ -0,0 +1,75 @@
+package http
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"sort"
+
+	"github.com/sagarc03/stowry"
+)
+
+// PrecompressedService is an optional Service extension for pre-compressed
+// assets. When the service implements it and HandlerConfig.Precompressed is
+// set, static and SPA modes serve a stored foo.js.br or foo.js.gz in place of
+// foo.js to clients accepting that coding.
+type PrecompressedService interface {
+	GetNegotiated(ctx context.Context, path string, encodings []string) (stowry.MetaData, io.ReadSeekCloser, error)
+	InfoNegotiated(ctx context.Context, path string, encodings []string) (stowry.MetaData, error)
+}
+
+// precompressedService returns the service as a PrecompressedService if
+// pre-compressed assets are enabled in the configured mode.
+func (h *Handler) precompressedService() (PrecompressedService, bool) {
+	if !h.config.Precompressed || h.config.Mode == stowry.ModeStore {
+		return nil, false
+	}
+	ps, ok := h.service.(PrecompressedService)
+	return ps, ok
+}
+
+// precompressedEncodings returns the codings with a pre-compressed variant
+// the request accepts, most preferred first. Ties in q-value keep the order
+// of stowry.PrecompressedEncodings. A request without Accept-Encoding gets
+// none, so clients that never asked are sent the identity coding.
+func precompressedEncodings(r *http.Request) []string {
+	header := r.Header.Get("Accept-Encoding")
+	if header == "" {
+		return nil
+	}
+
+	type candidate struct {
+		coding string
+		q      float64
+	}
+	var candidates []candidate
+	for _, coding := range stowry.PrecompressedEncodings() {
+		if q := acceptQuality(header, coding); q > 0 {
+			candidates = append(candidates, candidate{coding: coding, q: q})
+		}
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].q > candidates[j].q
+	})
+
+	encodings := make([]string, len(candidates))
+	for i, c := range candidates {
+		encodings[i] = c.coding
+	}
+	return encodings
+}
+
+// acceptQuality returns the q-value header gives coding, falling back to a
+// "*" entry. It is 0 when the coding is not acceptable.
+func acceptQuality(header, coding string) float64 {
+	wildcard := 0.0
+	for _, a := range parseAcceptEncoding(header) {
+		switch a.coding {
+		case coding:
+			return a.q
+		case "*":
+			wildcard = a.q
+		}
+	}
+	return wildcard
+}
@@ -0,0 +1,121 @@
+package http_test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/sagarc03/stowry"
+	stowryhttp "github.com/sagarc03/stowry/http"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// MockPrecompressedService is a MockService that also implements
+// http.PrecompressedService.
+type MockPrecompressedService struct {
+	MockService
+}
+
+func (m *MockPrecompressedService) GetNegotiated(ctx context.Context, path string, encodings []string) (stowry.MetaData, io.ReadSeekCloser, error) {
+	args := m.Called(ctx, path, encodings)
+	var rc io.ReadSeekCloser
+	if args.Get(1) != nil {
+		rc = args.Get(1).(io.ReadSeekCloser)
+	}
+	return args.Get(0).(stowry.MetaData), rc, args.Error(2)
+}
+
+func (m *MockPrecompressedService) InfoNegotiated(ctx context.Context, path string, encodings []string) (stowry.MetaData, error) {
+	args := m.Called(ctx, path, encodings)
+	return args.Get(0).(stowry.MetaData), args.Error(1)
+}
+
+func TestHandler_Precompressed(t *testing.T) {
+	decoded := int64(1000)
+	variant := stowry.MetaData{Path: "app.js", ContentType: "text/javascript", Etag: "br-etag",
+		FileSizeBytes: 7, ContentEncoding: "br", DecodedSizeBytes: &decoded}
+	config := &stowryhttp.HandlerConfig{Mode: stowry.ModeStatic, Precompressed: true}
+
+	serve := func(t *testing.T, config *stowryhttp.HandlerConfig, service stowryhttp.Service, method, acceptEncoding string) *httptest.ResponseRecorder {
+		t.Helper()
+		req := httptest.NewRequest(method, "/app.js", nil)
+		if acceptEncoding != "" {
+			req.Header.Set("Accept-Encoding", acceptEncoding)
+		}
+		rec := httptest.NewRecorder()
+		stowryhttp.NewHandler(config, service).Router().ServeHTTP(rec, req)
+		return rec
+	}
+
+	t.Run("get serves the variant", func(t *testing.T) {
+		service := new(MockPrecompressedService)
+		service.On("GetNegotiated", mock.Anything, "app.js", []string{"br", "gzip"}).
+			Return(variant, readSeekNopCloser{strings.NewReader("brotli!")}, nil)
+
+		rec := serve(t, config, service, http.MethodGet, "gzip, deflate, br")
+
+		require.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "brotli!", rec.Body.String())
+		assert.Equal(t, "br", rec.Header().Get("Content-Encoding"))
+		assert.Equal(t, "text/javascript", rec.Header().Get("Content-Type"))
+		assert.Equal(t, `"br-etag"`, rec.Header().Get("ETag"))
+		assert.Equal(t, "7", rec.Header().Get("Content-Length"))
+		assert.Equal(t, "Accept-Encoding", rec.Header().Get("Vary"))
+		service.AssertExpectations(t)
+	})
+
+	t.Run("q-values order the encodings", func(t *testing.T) {
+		service := new(MockPrecompressedService)
+		service.On("GetNegotiated", mock.Anything, "app.js", []string{"gzip", "br"}).
+			Return(variant, readSeekNopCloser{strings.NewReader("brotli!")}, nil)
+
+		rec := serve(t, config, service, http.MethodGet, "br;q=0.5, gzip")
+
+		require.Equal(t, http.StatusOK, rec.Code)
+		service.AssertExpectations(t)
+	})
+
+	t.Run("without accept-encoding no variant is asked for", func(t *testing.T) {
+		plain := stowry.MetaData{Path: "app.js", ContentType: "text/javascript", Etag: "orig", FileSizeBytes: 4}
+		service := new(MockPrecompressedService)
+		service.On("GetNegotiated", mock.Anything, "app.js", []string(nil)).
+			Return(plain, readSeekNopCloser{strings.NewReader("code")}, nil)
+
+		rec := serve(t, config, service, http.MethodGet, "")
+
+		require.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "code", rec.Body.String())
+		assert.Empty(t, rec.Header().Get("Content-Encoding"))
+		assert.Equal(t, "Accept-Encoding", rec.Header().Get("Vary"))
+	})
+
+	t.Run("head describes the variant", func(t *testing.T) {
+		service := new(MockPrecompressedService)
+		service.On("InfoNegotiated", mock.Anything, "app.js", []string{"br"}).Return(variant, nil)
+
+		rec := serve(t, config, service, http.MethodHead, "br")
+
+		require.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "br", rec.Header().Get("Content-Encoding"))
+		assert.Equal(t, "7", rec.Header().Get("Content-Length"))
+		assert.Equal(t, "Accept-Encoding", rec.Header().Get("Vary"))
+		service.AssertExpectations(t)
+	})
+
+	t.Run("store mode ignores the flag", func(t *testing.T) {
+		plain := stowry.MetaData{Path: "app.js", ContentType: "text/javascript", Etag: "orig", FileSizeBytes: 4}
+		service := new(MockPrecompressedService)
+		service.On("Get", mock.Anything, "app.js").Return(plain, readSeekNopCloser{strings.NewReader("code")}, nil)
+
+		rec := serve(t, &stowryhttp.HandlerConfig{Mode: stowry.ModeStore, Precompressed: true}, service, http.MethodGet, "br")
+
+		require.Equal(t, http.StatusOK, rec.Code)
+		assert.Empty(t, rec.Header().Get("Vary"))
+		service.AssertExpectations(t)
+	})
+}
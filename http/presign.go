@@ -0,0 +1,320 @@
+package http
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	stowrysign "github.com/sagarc03/stowry-go"
+	"golang.org/x/time/rate"
+
+	"github.com/sagarc03/stowry"
+)
+
+// DefaultPresignMaxExpires caps the lifetime of URLs issued by POST /presign
+// when PresignConfig.MaxExpires is not set.
+const DefaultPresignMaxExpires = 3600
+
+// maxPresignRequestSize bounds the JSON body of a presign request.
+const maxPresignRequestSize = 64 << 10
+
+// PresignConfig configures the POST /presign endpoint, which lets trusted
+// backends obtain presigned URLs without a Stowry SDK.
+type PresignConfig struct {
+	Enabled bool `mapstructure:"enabled"`
+	// SigningKey is the access key whose secret signs issued URLs.
+	SigningKey string `mapstructure:"signing_key"`
+	// Keys lists the access keys allowed to call POST /presign. Empty allows
+	// only SigningKey.
+	Keys []string `mapstructure:"keys"`
+	// MaxExpires caps the requested lifetime in seconds. 0 uses
+	// DefaultPresignMaxExpires.
+	MaxExpires int64 `mapstructure:"max_expires"`
+	// RateLimit caps presign requests per minute for each calling key.
+	// 0 means unlimited.
+	RateLimit int `mapstructure:"rate_limit"`
+	// BaseURL is the public origin put in issued URLs, e.g.
+	// https://files.example.com. Empty uses the scheme and host of the request.
+	BaseURL string `mapstructure:"base_url"`
+}
+
+// Validate checks the settings that do not depend on the key store.
+func (c PresignConfig) Validate() error {
+	if !c.Enabled {
+		return nil
+	}
+	if c.SigningKey == "" {
+		return errors.New("presign: signing_key is required")
+	}
+	if c.MaxExpires < 0 || c.MaxExpires > stowrysign.MaxExpires {
+		return fmt.Errorf("presign: max_expires must be between 0 and %d", stowrysign.MaxExpires)
+	}
+	if c.RateLimit < 0 {
+		return errors.New("presign: rate_limit must not be negative")
+	}
+	if c.BaseURL != "" {
+		u, err := url.Parse(c.BaseURL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("presign: base_url %q must be an absolute http(s) URL", c.BaseURL)
+		}
+	}
+	return nil
+}
+
+// Presigner issues Stowry native presigned URLs on behalf of authenticated
+// callers. Callers authenticate with HTTP Basic auth using an access key and
+// its secret key.
+type Presigner struct {
+	store      stowry.SecretStore
+	signingKey string
+	callers    map[string]bool
+	maxExpires int64
+	baseURL    string
+	now        func() time.Time
+
+	rateLimit int
+	mu        sync.Mutex
+	limiters  map[string]*rate.Limiter
+}
+
+// NewPresigner validates cfg against store and returns a Presigner, or nil
+// when presigning is disabled.
+func NewPresigner(cfg PresignConfig, store stowry.SecretStore) (*Presigner, error) {
+	if !cfg.Enabled {
+		return nil, nil
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	if _, err := store.Lookup(cfg.SigningKey); err != nil {
+		return nil, fmt.Errorf("presign: signing key %s: %w", cfg.SigningKey, err)
+	}
+
+	callers := map[string]bool{cfg.SigningKey: len(cfg.Keys) == 0}
+	for _, key := range cfg.Keys {
+		callers[key] = true
+	}
+
+	maxExpires := cfg.MaxExpires
+	if maxExpires == 0 {
+		maxExpires = DefaultPresignMaxExpires
+	}
+
+	return &Presigner{
+		store:      store,
+		signingKey: cfg.SigningKey,
+		callers:    callers,
+		maxExpires: maxExpires,
+		baseURL:    strings.TrimSuffix(cfg.BaseURL, "/"),
+		now:        time.Now,
+		rateLimit:  cfg.RateLimit,
+		limiters:   make(map[string]*rate.Limiter),
+	}, nil
+}
+
+// PresignRequest is the JSON body of POST /presign.
+type PresignRequest struct {
+	Method      string `json:"method"`
+	Path        string `json:"path"`
+	Expires     int64  `json:"expires,omitempty"`      // Seconds. 0 uses 900.
+	ContentType string `json:"content_type,omitempty"` // PUT only
+	MaxSize     int64  `json:"max_size,omitempty"`     // PUT only, bytes
+}
+
+// PresignResponse is the JSON response of POST /presign.
+type PresignResponse struct {
+	URL       string    `json:"url"`
+	Method    string    `json:"method"`
+	ExpiresAt time.Time `json:"expires_at"`
+	// Headers the request made with URL must send, such as Content-Type.
+	Headers map[string]string `json:"headers,omitempty"`
+}
+
+// authenticate checks the Basic credentials of r and returns the caller's
+// access key.
+func (p *Presigner) authenticate(r *http.Request) (string, error) {
+	accessKey, secretKey, ok := r.BasicAuth()
+	if !ok {
+		return "", errors.New("missing basic credentials")
+	}
+
+	expected, err := p.store.Lookup(accessKey)
+	if err != nil {
+		return "", fmt.Errorf("lookup access key: %w", err)
+	}
+	if subtle.ConstantTimeCompare([]byte(expected), []byte(secretKey)) != 1 {
+		return "", errors.New("secret key mismatch")
+	}
+	return accessKey, nil
+}
+
+// allow reports whether caller is within its rate limit.
+func (p *Presigner) allow(caller string) bool {
+	if p.rateLimit <= 0 {
+		return true
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	l, ok := p.limiters[caller]
+	if !ok {
+		l = rate.NewLimiter(rate.Limit(float64(p.rateLimit)/60), p.rateLimit)
+		p.limiters[caller] = l
+	}
+	return l.Allow()
+}
+
+// parse validates req and returns the object path and upload constraints.
+func (p *Presigner) parse(req *PresignRequest) (string, stowry.PresignConstraints, error) {
+	req.Method = strings.ToUpper(req.Method)
+	switch req.Method {
+	case http.MethodGet, http.MethodHead, http.MethodPut, http.MethodDelete:
+	default:
+		return "", stowry.PresignConstraints{}, errors.New("method must be GET, HEAD, PUT or DELETE")
+	}
+
+	path := strings.TrimPrefix(req.Path, "/")
+	if path == "" || !stowry.IsValidPath(path) {
+		return "", stowry.PresignConstraints{}, fmt.Errorf("invalid path %q", req.Path)
+	}
+
+	if req.Expires == 0 {
+		req.Expires = stowrysign.DefaultExpires
+	}
+	if req.Expires < 0 || req.Expires > p.maxExpires {
+		return "", stowry.PresignConstraints{}, fmt.Errorf("expires must be between 1 and %d seconds", p.maxExpires)
+	}
+
+	c := stowry.PresignConstraints{ContentType: req.ContentType, MaxSize: req.MaxSize}
+	if c.MaxSize < 0 {
+		return "", stowry.PresignConstraints{}, errors.New("max_size must not be negative")
+	}
+	if !c.IsZero() && req.Method != http.MethodPut {
+		return "", stowry.PresignConstraints{}, errors.New("content_type and max_size apply to PUT only")
+	}
+	if c.ContentType != "" {
+		if _, _, err := mime.ParseMediaType(c.ContentType); err != nil {
+			return "", stowry.PresignConstraints{}, fmt.Errorf("invalid content_type %q", c.ContentType)
+		}
+	}
+
+	return "/" + path, c, nil
+}
+
+// origin returns the scheme and host put in front of issued URLs.
+func (p *Presigner) origin(r *http.Request) string {
+	if p.baseURL != "" {
+		return p.baseURL
+	}
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	return scheme + "://" + r.Host
+}
+
+func (h *Handler) handlePresign(w http.ResponseWriter, r *http.Request) {
+	p := h.config.Presigner
+
+	caller, err := p.authenticate(r)
+	if err != nil {
+		h.logger.Warn("presign denied", "error", err, "remote_addr", r.RemoteAddr)
+		w.Header().Set("WWW-Authenticate", `Basic realm="stowry"`)
+		h.handleError(w, r, ErrUnauthorized)
+		return
+	}
+	if h.config.AccessKeys != nil {
+		h.config.AccessKeys.Record(caller)
+	}
+	if !p.callers[caller] {
+		h.logger.Warn("presign denied", "caller", caller, "error", "key lacks presign permission", "remote_addr", r.RemoteAddr)
+		h.writeError(w, r, http.StatusForbidden, "forbidden", "Access key may not presign URLs")
+		return
+	}
+	if !p.allow(caller) {
+		h.logger.Warn("presign rate limited", "caller", caller, "remote_addr", r.RemoteAddr)
+		w.Header().Set("Retry-After", "60")
+		h.writeError(w, r, http.StatusTooManyRequests, "rate_limited", "Too many presign requests")
+		return
+	}
+
+	var req PresignRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxPresignRequestSize)).Decode(&req); err != nil {
+		h.writeError(w, r, http.StatusBadRequest, "invalid_request", "Request body must be a JSON presign request")
+		return
+	}
+
+	path, constraints, err := p.parse(&req)
+	if err != nil {
+		h.writeError(w, r, http.StatusBadRequest, "invalid_parameter", err.Error())
+		return
+	}
+
+	secretKey, err := p.store.Lookup(p.signingKey)
+	if err != nil {
+		h.handleError(w, r, fmt.Errorf("presign: signing key: %w", err))
+		return
+	}
+
+	now := p.now()
+	query := stowry.PresignStowryQuery(p.signingKey, secretKey, req.Method, path, now.Unix(), req.Expires, constraints)
+
+	resp := PresignResponse{
+		URL:       p.origin(r) + (&url.URL{Path: path}).EscapedPath() + "?" + query.Encode(),
+		Method:    req.Method,
+		ExpiresAt: now.Add(time.Duration(req.Expires) * time.Second).UTC().Truncate(time.Second),
+	}
+	if constraints.ContentType != "" {
+		resp.Headers = map[string]string{"Content-Type": constraints.ContentType}
+	}
+
+	h.logger.Info("presign issued",
+		"caller", caller,
+		"method", req.Method,
+		"path", path,
+		"expires", req.Expires,
+		"content_type", constraints.ContentType,
+		"max_size", constraints.MaxSize,
+		"remote_addr", r.RemoteAddr,
+	)
+
+	_ = WriteJSON(w, http.StatusOK, resp)
+}
+
+// checkUploadConstraints enforces the constraints signed into a presigned
+// PUT URL. It writes the error response and returns false when the upload
+// is not allowed.
+func (h *Handler) checkUploadConstraints(w http.ResponseWriter, r *http.Request, c stowry.PresignConstraints) bool {
+	if c.ContentType != "" && !sameMediaType(r.Header.Get("Content-Type"), c.ContentType) {
+		h.writeError(w, r, http.StatusForbidden, "content_type_mismatch", "Content-Type does not match the presigned URL")
+		return false
+	}
+	if c.MaxSize > 0 && r.ContentLength > c.MaxSize {
+		h.writeError(w, r, http.StatusRequestEntityTooLarge, "too_large", "Upload exceeds the presigned URL's max size of "+strconv.FormatInt(c.MaxSize, 10)+" bytes")
+		return false
+	}
+	return true
+}
+
+// sameMediaType compares two Content-Type values, ignoring case and
+// parameters.
+func sameMediaType(got, want string) bool {
+	gotType, _, err := mime.ParseMediaType(got)
+	if err != nil {
+		return false
+	}
+	wantType, _, err := mime.ParseMediaType(want)
+	if err != nil {
+		return false
+	}
+	return gotType == wantType
+}
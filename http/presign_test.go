@@ -0,0 +1,248 @@
+package http_test
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sagarc03/stowry"
+	"github.com/sagarc03/stowry/database"
+	"github.com/sagarc03/stowry/filesystem"
+	stowryhttp "github.com/sagarc03/stowry/http"
+	"github.com/sagarc03/stowry/keybackend"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var presignKeys = map[string]string{
+	"signer":  "signer-secret",
+	"backend": "backend-secret",
+	"reader":  "reader-secret",
+}
+
+// newPresignServer serves a store-mode stack over sqlite and the filesystem
+// with private writes and POST /presign enabled.
+func newPresignServer(t *testing.T, cfg stowryhttp.PresignConfig) *httptest.Server {
+	t.Helper()
+	ctx := context.Background()
+
+	db, err := database.Connect(ctx, database.Config{
+		Type:   "sqlite",
+		DSN:    ":memory:",
+		Tables: stowry.Tables{MetaData: "stowry_metadata"},
+	})
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = db.Close() })
+	require.NoError(t, db.Migrate(ctx))
+
+	root, err := os.OpenRoot(t.TempDir())
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = root.Close() })
+
+	service, err := stowry.NewStowryService(db.GetRepo(), filesystem.NewFileStorage(root), stowry.ServiceConfig{Mode: stowry.ModeStore})
+	require.NoError(t, err)
+
+	store := keybackend.NewMapSecretStore(presignKeys)
+	cfg.Enabled = true
+	presigner, err := stowryhttp.NewPresigner(cfg, store)
+	require.NoError(t, err)
+
+	verifier := stowry.NewSignatureVerifier(stowry.AuthConfig{}, store)
+	config := &stowryhttp.HandlerConfig{
+		Mode:          stowry.ModeStore,
+		ReadVerifier:  verifier,
+		WriteVerifier: verifier,
+		Presigner:     presigner,
+	}
+	server := httptest.NewServer(stowryhttp.NewHandler(config, service).Router())
+	t.Cleanup(server.Close)
+	return server
+}
+
+func presign(t *testing.T, server *httptest.Server, accessKey, secretKey, body string) (*http.Response, stowryhttp.PresignResponse) {
+	t.Helper()
+	req, err := http.NewRequest(http.MethodPost, server.URL+"/presign", strings.NewReader(body))
+	require.NoError(t, err)
+	req.Header.Set("Content-Type", "application/json")
+	if accessKey != "" {
+		req.SetBasicAuth(accessKey, secretKey)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer func() { _ = resp.Body.Close() }()
+
+	var out stowryhttp.PresignResponse
+	if resp.StatusCode == http.StatusOK {
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&out))
+	}
+	return resp, out
+}
+
+func upload(t *testing.T, rawURL, contentType string, body io.Reader, contentLength int64) *http.Response {
+	t.Helper()
+	req, err := http.NewRequest(http.MethodPut, rawURL, body)
+	require.NoError(t, err)
+	req.Header.Set("Content-Type", contentType)
+	req.ContentLength = contentLength
+
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	_ = resp.Body.Close()
+	return resp
+}
+
+func TestPresign_IssuesWorkingURLs(t *testing.T) {
+	server := newPresignServer(t, stowryhttp.PresignConfig{SigningKey: "signer", Keys: []string{"backend"}})
+
+	resp, put := presign(t, server, "backend", "backend-secret", `{"method":"put","path":"uploads/résumé.txt","expires":60}`)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, http.MethodPut, put.Method)
+	assert.WithinDuration(t, time.Now().Add(time.Minute), put.ExpiresAt, 2*time.Second)
+
+	u, err := url.Parse(put.URL)
+	require.NoError(t, err)
+	assert.Equal(t, "/uploads/r%C3%A9sum%C3%A9.txt", u.EscapedPath())
+	assert.Equal(t, "signer", u.Query().Get("X-Stowry-Credential"), "URLs are signed with the designated key")
+
+	resp = upload(t, put.URL, "text/plain", strings.NewReader("hello"), 5)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	resp, get := presign(t, server, "backend", "backend-secret", `{"method":"GET","path":"/uploads/résumé.txt"}`)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	getResp, err := http.Get(get.URL)
+	require.NoError(t, err)
+	defer func() { _ = getResp.Body.Close() }()
+	content, err := io.ReadAll(getResp.Body)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, getResp.StatusCode)
+	assert.Equal(t, "hello", string(content))
+
+	// A GET URL cannot be used to upload
+	resp = upload(t, get.URL, "text/plain", strings.NewReader("evil"), 4)
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+}
+
+func TestPresign_UploadConstraints(t *testing.T) {
+	server := newPresignServer(t, stowryhttp.PresignConfig{SigningKey: "signer"})
+
+	resp, put := presign(t, server, "signer", "signer-secret",
+		`{"method":"PUT","path":"avatars/u1.png","content_type":"image/png","max_size":10}`)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, map[string]string{"Content-Type": "image/png"}, put.Headers)
+
+	t.Run("oversized upload with content length", func(t *testing.T) {
+		resp := upload(t, put.URL, "image/png", strings.NewReader(strings.Repeat("x", 11)), 11)
+		assert.Equal(t, http.StatusRequestEntityTooLarge, resp.StatusCode)
+	})
+
+	t.Run("oversized chunked upload", func(t *testing.T) {
+		body := io.MultiReader(strings.NewReader(strings.Repeat("x", 8)), strings.NewReader(strings.Repeat("x", 8)))
+		resp := upload(t, put.URL, "image/png", body, -1)
+		assert.Equal(t, http.StatusRequestEntityTooLarge, resp.StatusCode)
+
+		headResp, err := http.Head(server.URL + "/avatars/u1.png")
+		require.NoError(t, err)
+		_ = headResp.Body.Close()
+		assert.NotEqual(t, http.StatusOK, headResp.StatusCode, "nothing is stored")
+	})
+
+	t.Run("wrong content type", func(t *testing.T) {
+		resp := upload(t, put.URL, "text/html", strings.NewReader("<p>"), 3)
+		assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+	})
+
+	t.Run("tampered constraint", func(t *testing.T) {
+		u, err := url.Parse(put.URL)
+		require.NoError(t, err)
+		q := u.Query()
+		q.Set("X-Stowry-Max-Size", "1000000")
+		u.RawQuery = q.Encode()
+
+		resp := upload(t, u.String(), "image/png", strings.NewReader(strings.Repeat("x", 11)), 11)
+		assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	})
+
+	t.Run("upload within constraints", func(t *testing.T) {
+		resp := upload(t, put.URL, "image/png; charset=binary", strings.NewReader("tiny"), 4)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+	})
+}
+
+func TestPresign_Rejections(t *testing.T) {
+	server := newPresignServer(t, stowryhttp.PresignConfig{SigningKey: "signer", Keys: []string{"backend"}, MaxExpires: 600})
+	valid := `{"method":"GET","path":"a.txt"}`
+
+	tests := []struct {
+		name      string
+		accessKey string
+		secretKey string
+		body      string
+		want      int
+	}{
+		{name: "no credentials", body: valid, want: http.StatusUnauthorized},
+		{name: "wrong secret", accessKey: "backend", secretKey: "nope", body: valid, want: http.StatusUnauthorized},
+		{name: "unknown key", accessKey: "ghost", secretKey: "x", body: valid, want: http.StatusUnauthorized},
+		{name: "key without presign permission", accessKey: "reader", secretKey: "reader-secret", body: valid, want: http.StatusForbidden},
+		{name: "signing key not listed", accessKey: "signer", secretKey: "signer-secret", body: valid, want: http.StatusForbidden},
+		{name: "malformed body", accessKey: "backend", secretKey: "backend-secret", body: `{`, want: http.StatusBadRequest},
+		{name: "unsupported method", accessKey: "backend", secretKey: "backend-secret", body: `{"method":"PATCH","path":"a.txt"}`, want: http.StatusBadRequest},
+		{name: "invalid path", accessKey: "backend", secretKey: "backend-secret", body: `{"method":"GET","path":"../a.txt"}`, want: http.StatusBadRequest},
+		{name: "expires too long", accessKey: "backend", secretKey: "backend-secret", body: `{"method":"GET","path":"a.txt","expires":601}`, want: http.StatusBadRequest},
+		{name: "constraints on GET", accessKey: "backend", secretKey: "backend-secret", body: `{"method":"GET","path":"a.txt","max_size":5}`, want: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, _ := presign(t, server, tt.accessKey, tt.secretKey, tt.body)
+			assert.Equal(t, tt.want, resp.StatusCode)
+		})
+	}
+
+	t.Run("rate limit", func(t *testing.T) {
+		server := newPresignServer(t, stowryhttp.PresignConfig{SigningKey: "signer", RateLimit: 2})
+		valid := `{"method":"GET","path":"a.txt"}`
+
+		for range 2 {
+			resp, _ := presign(t, server, "signer", "signer-secret", valid)
+			require.Equal(t, http.StatusOK, resp.StatusCode)
+		}
+		resp, _ := presign(t, server, "signer", "signer-secret", valid)
+		assert.Equal(t, http.StatusTooManyRequests, resp.StatusCode)
+		assert.Equal(t, "60", resp.Header.Get("Retry-After"))
+	})
+}
+
+func TestNewPresigner(t *testing.T) {
+	store := keybackend.NewMapSecretStore(presignKeys)
+
+	t.Run("disabled", func(t *testing.T) {
+		p, err := stowryhttp.NewPresigner(stowryhttp.PresignConfig{}, store)
+		require.NoError(t, err)
+		assert.Nil(t, p)
+	})
+
+	tests := []struct {
+		name    string
+		cfg     stowryhttp.PresignConfig
+		wantErr string
+	}{
+		{name: "missing signing key", cfg: stowryhttp.PresignConfig{Enabled: true}, wantErr: "signing_key is required"},
+		{name: "unknown signing key", cfg: stowryhttp.PresignConfig{Enabled: true, SigningKey: "ghost"}, wantErr: "signing key ghost"},
+		{name: "expires over seven days", cfg: stowryhttp.PresignConfig{Enabled: true, SigningKey: "signer", MaxExpires: 604801}, wantErr: "max_expires"},
+		{name: "relative base url", cfg: stowryhttp.PresignConfig{Enabled: true, SigningKey: "signer", BaseURL: "files.example.com"}, wantErr: "base_url"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := stowryhttp.NewPresigner(tt.cfg, store)
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.wantErr)
+		})
+	}
+}
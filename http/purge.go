@@ -0,0 +1,87 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/sagarc03/stowry"
+)
+
+// PurgeService is an optional Service extension for removing an object at
+// once instead of soft-deleting it. When the service implements it, store
+// mode serves DELETE /{path}?purge with write authentication that public
+// writes do not waive; see HandlerConfig.PurgeVerifier.
+// *stowry.StowryService implements it.
+type PurgeService interface {
+	Purge(ctx context.Context, path string) (stowry.MetaData, error)
+}
+
+// purgeService returns the service's PurgeService, if any.
+func (h *Handler) purgeService() (PurgeService, bool) {
+	ps, ok := h.service.(PurgeService)
+	return ps, ok
+}
+
+// isPurgeRequest reports whether a DELETE asks to purge the object.
+func isPurgeRequest(r *http.Request) bool {
+	return r.URL.Query().Has("purge")
+}
+
+// purgeAuthMiddleware requires write permission from the handler's
+// Authenticators followed by PurgeVerifier, or WriteVerifier when it is nil.
+// When neither leaves anything to authenticate with, every purge is refused
+// with 401 unless AllowPublicPurge is set.
+func (h *Handler) purgeAuthMiddleware() func(http.Handler) http.Handler {
+	verifier := h.config.PurgeVerifier
+	if verifier == nil {
+		verifier = h.config.WriteVerifier
+	}
+	if len(authChain(h.config.Authenticators, verifier, h.config.AccessKeys)) == 0 && !h.config.AllowPublicPurge {
+		return func(http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				h.logger.Warn("purge refused without authentication", "path", r.URL.Path)
+				h.config.Metrics.RecordAuthFailure(ErrNoCredentials)
+				h.handleError(w, r, ErrUnauthorized)
+			})
+		}
+	}
+	return h.authMiddleware(verifier, PermissionWrite)
+}
+
+// handlePurge serves DELETE /{path}?purge in store mode. The object's file
+// and metadata are removed at once, and the metadata removed is returned,
+// and logged, for audit records.
+func (h *Handler) handlePurge(w http.ResponseWriter, r *http.Request) {
+	ps, ok := h.purgeService()
+	if !ok {
+		h.handleError(w, r, stowry.ErrNotSupported)
+		return
+	}
+
+	path := strings.TrimPrefix(r.URL.Path, "/")
+	if path == "" || strings.HasSuffix(path, "/") || !stowry.IsValidPath(path) {
+		h.writeError(w, r, http.StatusBadRequest, "invalid_path", "Invalid path")
+		return
+	}
+
+	m, err := ps.Purge(r.Context(), path)
+	if err != nil {
+		if errors.Is(err, stowry.ErrNotFound) {
+			h.writeError(w, r, http.StatusNotFound, "not_found", "Object not found")
+		} else {
+			h.handleError(w, r, err)
+		}
+		return
+	}
+
+	principal, _ := PrincipalFromContext(r.Context())
+	h.logger.Info("object purged", "path", m.Path, "id", m.ID, "etag", m.Etag, "size", m.FileSizeBytes,
+		"soft_deleted", m.DeletedAt != nil, "principal", principal.Subject)
+
+	// The backend is only reported by list and stat with ?include=backend
+	m.StorageBackend = ""
+	w.Header().Set("Cache-Control", "no-store")
+	_ = WriteJSON(w, http.StatusOK, m)
+}
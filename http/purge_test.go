@@ -0,0 +1,137 @@
+package http_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/sagarc03/stowry"
+	stowryhttp "github.com/sagarc03/stowry/http"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// purgeMockService is a MockService that can purge objects.
+type purgeMockService struct {
+	MockService
+}
+
+func (m *purgeMockService) Purge(ctx context.Context, path string) (stowry.MetaData, error) {
+	args := m.Called(ctx, path)
+	return args.Get(0).(stowry.MetaData), args.Error(1)
+}
+
+func TestHandler_Purge(t *testing.T) {
+	serve := func(t *testing.T, config stowryhttp.HandlerConfig, service stowryhttp.Service, target string, authed bool) *httptest.ResponseRecorder {
+		t.Helper()
+		config.Mode = stowry.ModeStore
+		h, err := stowryhttp.New(&config, service)
+		require.NoError(t, err)
+		req := httptest.NewRequest(http.MethodDelete, target, nil)
+		if authed {
+			req.Header.Set("X-Test-Auth", "ok")
+		}
+		rec := httptest.NewRecorder()
+		h.Router().ServeHTTP(rec, req)
+		return rec
+	}
+
+	t.Run("purges the object and returns its metadata", func(t *testing.T) {
+		service := new(purgeMockService)
+		service.On("Purge", mock.Anything, "legal/hold.pdf").
+			Return(stowry.MetaData{Path: "legal/hold.pdf", Etag: "abc123", FileSizeBytes: 42, StorageBackend: "archive"}, nil)
+
+		rec := serve(t, stowryhttp.HandlerConfig{WriteVerifier: headerVerifier{}}, service, "/legal/hold.pdf?purge=1", true)
+
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		assert.Equal(t, "no-store", rec.Header().Get("Cache-Control"))
+		var meta stowry.MetaData
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &meta))
+		assert.Equal(t, "legal/hold.pdf", meta.Path)
+		assert.Equal(t, "abc123", meta.Etag)
+		assert.Empty(t, meta.StorageBackend)
+		service.AssertNotCalled(t, "Delete", mock.Anything, mock.Anything)
+	})
+
+	t.Run("needs write authentication", func(t *testing.T) {
+		service := new(purgeMockService)
+
+		rec := serve(t, stowryhttp.HandlerConfig{WriteVerifier: headerVerifier{}}, service, "/a.txt?purge=1", false)
+
+		assert.Equal(t, http.StatusUnauthorized, rec.Code)
+		service.AssertNotCalled(t, "Purge", mock.Anything, mock.Anything)
+	})
+
+	t.Run("needs authentication when writes are public", func(t *testing.T) {
+		service := new(purgeMockService)
+		service.On("Purge", mock.Anything, "a.txt").Return(stowry.MetaData{Path: "a.txt"}, nil)
+		config := stowryhttp.HandlerConfig{PurgeVerifier: headerVerifier{}}
+
+		rec := serve(t, config, service, "/a.txt?purge=1", false)
+		assert.Equal(t, http.StatusUnauthorized, rec.Code)
+
+		rec = serve(t, config, service, "/a.txt?purge=1", true)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		service.AssertNumberOfCalls(t, "Purge", 1)
+	})
+
+	t.Run("refused without any verifier", func(t *testing.T) {
+		service := new(purgeMockService)
+
+		rec := serve(t, stowryhttp.HandlerConfig{}, service, "/a.txt?purge=1", true)
+
+		assert.Equal(t, http.StatusUnauthorized, rec.Code)
+		service.AssertNotCalled(t, "Purge", mock.Anything, mock.Anything)
+	})
+
+	t.Run("public purge when allowed", func(t *testing.T) {
+		service := new(purgeMockService)
+		service.On("Purge", mock.Anything, "a.txt").Return(stowry.MetaData{Path: "a.txt"}, nil)
+
+		rec := serve(t, stowryhttp.HandlerConfig{AllowPublicPurge: true}, service, "/a.txt?purge=1", false)
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+	})
+
+	t.Run("not supported by the service", func(t *testing.T) {
+		service := new(MockService)
+
+		rec := serve(t, stowryhttp.HandlerConfig{WriteVerifier: headerVerifier{}}, service, "/a.txt?purge=1", true)
+
+		assert.Equal(t, http.StatusNotImplemented, rec.Code)
+		service.AssertNotCalled(t, "Delete", mock.Anything, mock.Anything)
+	})
+
+	for name, tc := range map[string]struct {
+		err      error
+		wantCode int
+		wantErr  string
+	}{
+		"unknown path":  {fmt.Errorf("purge object: %w", stowry.ErrNotFound), http.StatusNotFound, "not_found"},
+		"storage error": {fmt.Errorf("purge object: %w", assert.AnError), http.StatusInternalServerError, "internal_error"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			service := new(purgeMockService)
+			service.On("Purge", mock.Anything, "a.txt").Return(stowry.MetaData{}, tc.err)
+
+			rec := serve(t, stowryhttp.HandlerConfig{WriteVerifier: headerVerifier{}}, service, "/a.txt?purge=1", true)
+
+			assert.Equal(t, tc.wantCode, rec.Code)
+			assertErrorCode(t, rec, tc.wantErr)
+		})
+	}
+
+	t.Run("plain delete still soft-deletes", func(t *testing.T) {
+		service := new(purgeMockService)
+		service.On("Delete", mock.Anything, "a.txt").Return(nil)
+
+		rec := serve(t, stowryhttp.HandlerConfig{WriteVerifier: headerVerifier{}}, service, "/a.txt", true)
+
+		assert.Equal(t, http.StatusNoContent, rec.Code)
+		service.AssertNotCalled(t, "Purge", mock.Anything, mock.Anything)
+	})
+}
@@ -0,0 +1,85 @@
+package http
+
+import (
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// unsatisfiableRange replaces a Range header ServeContent must answer with
+// 416. It cannot be parsed, so ServeContent rejects it only after the
+// conditional headers, which keep their precedence over Range.
+const unsatisfiableRange = "bytes=unsatisfiable"
+
+// singleRange returns the request ServeContent should serve for a Range
+// header listing more than one range: one whose range is unsatisfiable.
+// Stowry serves single ranges only, rather than multipart/byteranges
+// responses that few clients read and that can be abused to amplify a
+// small request into many copies of the same bytes.
+func singleRange(r *http.Request) *http.Request {
+	if !isMultiRange(r.Header.Get("Range")) {
+		return r
+	}
+	r = r.Clone(r.Context())
+	r.Header.Set("Range", unsatisfiableRange)
+	return r
+}
+
+// isMultiRange reports whether a Range header lists more than one byte range.
+func isMultiRange(header string) bool {
+	spec, ok := strings.CutPrefix(header, "bytes=")
+	if !ok {
+		return false
+	}
+	n := 0
+	for _, part := range strings.Split(spec, ",") {
+		if strings.TrimSpace(part) != "" {
+			n++
+		}
+	}
+	return n > 1
+}
+
+// rangeWriter adds "Content-Range: bytes */{size}" to every 416 response, as
+// RFC 9110 §15.5.17 asks. ServeContent only sets it for ranges past the end
+// of the content, not for malformed ones.
+type rangeWriter struct {
+	http.ResponseWriter
+	size        int64
+	wroteHeader bool
+}
+
+// newRangeWriter returns w as a rangeWriter for content, whose size is found
+// by seeking to its end. The position of content is restored.
+func newRangeWriter(w http.ResponseWriter, content io.ReadSeeker) (*rangeWriter, error) {
+	size, err := content.Seek(0, io.SeekEnd)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := content.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	return &rangeWriter{ResponseWriter: w, size: size}, nil
+}
+
+func (w *rangeWriter) WriteHeader(status int) {
+	if !w.wroteHeader {
+		w.wroteHeader = true
+		if status == http.StatusRequestedRangeNotSatisfiable {
+			w.Header().Set("Content-Range", "bytes */"+strconv.FormatInt(w.size, 10))
+		}
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *rangeWriter) Write(p []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	return w.ResponseWriter.Write(p)
+}
+
+func (w *rangeWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
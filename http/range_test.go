@@ -0,0 +1,133 @@
+package http_test
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sagarc03/stowry"
+	stowryhttp "github.com/sagarc03/stowry/http"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestHandler_HandleGet_Range(t *testing.T) {
+	updatedAt := time.Date(2025, 6, 15, 10, 30, 0, 0, time.UTC)
+	content := "0123456789abcdefghij"
+
+	tests := []struct {
+		name             string
+		header           http.Header
+		wantCode         int
+		wantBody         string
+		wantContentRange string
+		wantLength       string
+	}{
+		{
+			name:     "first bytes",
+			header:   http.Header{"Range": {"bytes=0-9"}},
+			wantCode: http.StatusPartialContent, wantBody: "0123456789",
+			wantContentRange: "bytes 0-9/20", wantLength: "10",
+		},
+		{
+			name:     "open-ended",
+			header:   http.Header{"Range": {"bytes=15-"}},
+			wantCode: http.StatusPartialContent, wantBody: "fghij",
+			wantContentRange: "bytes 15-19/20", wantLength: "5",
+		},
+		{
+			name:     "suffix",
+			header:   http.Header{"Range": {"bytes=-3"}},
+			wantCode: http.StatusPartialContent, wantBody: "hij",
+			wantContentRange: "bytes 17-19/20", wantLength: "3",
+		},
+		{
+			name:     "end past the content is clamped",
+			header:   http.Header{"Range": {"bytes=10-100"}},
+			wantCode: http.StatusPartialContent, wantBody: "abcdefghij",
+			wantContentRange: "bytes 10-19/20", wantLength: "10",
+		},
+		{
+			name:             "several ranges",
+			header:           http.Header{"Range": {"bytes=0-1, 5-6"}},
+			wantCode:         http.StatusRequestedRangeNotSatisfiable,
+			wantContentRange: "bytes */20",
+		},
+		{
+			name:             "start past the content",
+			header:           http.Header{"Range": {"bytes=20-"}},
+			wantCode:         http.StatusRequestedRangeNotSatisfiable,
+			wantContentRange: "bytes */20",
+		},
+		{
+			name:             "malformed",
+			header:           http.Header{"Range": {"bytes=9-1"}},
+			wantCode:         http.StatusRequestedRangeNotSatisfiable,
+			wantContentRange: "bytes */20",
+		},
+		{
+			name:     "a trailing comma is one range",
+			header:   http.Header{"Range": {"bytes=0-1,"}},
+			wantCode: http.StatusPartialContent, wantBody: "01",
+			wantContentRange: "bytes 0-1/20", wantLength: "2",
+		},
+		{
+			name:     "If-None-Match takes precedence",
+			header:   http.Header{"Range": {"bytes=0-1, 5-6"}, "If-None-Match": {`"abc123"`}},
+			wantCode: http.StatusNotModified,
+		},
+		{
+			name:     "changed If-Range sends everything",
+			header:   http.Header{"Range": {"bytes=0-1, 5-6"}, "If-Range": {`"old456"`}},
+			wantCode: http.StatusOK, wantBody: content, wantLength: "20",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service := new(MockService)
+			service.On("Get", mock.Anything, "test.txt").Return(stowry.MetaData{
+				ID:            uuid.New(),
+				Path:          "test.txt",
+				ContentType:   "text/plain",
+				Etag:          "abc123",
+				FileSizeBytes: int64(len(content)),
+				UpdatedAt:     updatedAt,
+			}, readSeekNopCloser{strings.NewReader(content)}, nil)
+			handler := stowryhttp.NewHandler(&stowryhttp.HandlerConfig{Mode: stowry.ModeStore}, service)
+
+			req := httptest.NewRequest(http.MethodGet, "/test.txt", nil)
+			for k, v := range tt.header {
+				req.Header[k] = v
+			}
+			rec := httptest.NewRecorder()
+			handler.Router().ServeHTTP(rec, req)
+
+			assert.Equal(t, tt.wantCode, rec.Code)
+			assert.Equal(t, tt.wantContentRange, rec.Header().Get("Content-Range"))
+			if tt.wantCode == http.StatusRequestedRangeNotSatisfiable {
+				return
+			}
+			assert.Equal(t, tt.wantBody, rec.Body.String())
+			assert.Equal(t, tt.wantLength, rec.Header().Get("Content-Length"))
+		})
+	}
+
+	t.Run("encoded object", func(t *testing.T) {
+		handler, stored := encodedObjectHandler(t, strings.Repeat("abc", 100))
+
+		req := httptest.NewRequest(http.MethodGet, "/data.json", nil)
+		req.Header.Set("Accept-Encoding", "gzip")
+		req.Header.Set("Range", "bytes=0-1,3-4")
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusRequestedRangeNotSatisfiable, rec.Code)
+		assert.Equal(t, fmt.Sprintf("bytes */%d", len(stored)), rec.Header().Get("Content-Range"))
+		assert.Empty(t, rec.Header().Get("Content-Encoding"), "errors are not labelled with the coding")
+	})
+}
@@ -0,0 +1,348 @@
+package http
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/sagarc03/stowry"
+)
+
+// DefaultRedirectsFile is the object that holds Netlify-style redirect rules
+// in static and SPA modes.
+const DefaultRedirectsFile = "_redirects"
+
+// MaxRewrites caps how many 200 rewrites a single request may follow before
+// it is answered with 508 Loop Detected.
+const MaxRewrites = 10
+
+// defaultRedirectsReloadInterval is how often the redirects object's ETag is
+// checked when HandlerConfig.RedirectsReloadInterval is unset.
+const defaultRedirectsReloadInterval = 5 * time.Second
+
+// RedirectRule maps requests matching From to To.
+//
+// From is an absolute path. A segment of the form :name matches one path
+// segment, and a final * matches the rest of the path, including nothing.
+// To is an absolute path or an http(s) URL and may use :name and :splat to
+// insert the matched values.
+//
+// Status 301, 302, 303, 307 and 308 send a redirect; 200 rewrites the request
+// to serve To while keeping the URL. 0 means 301.
+type RedirectRule struct {
+	From   string `mapstructure:"from"`
+	To     string `mapstructure:"to"`
+	Status int    `mapstructure:"status"`
+}
+
+// Redirects is a compiled, ordered list of redirect rules. The first matching
+// rule wins.
+type Redirects struct {
+	rules []compiledRule
+}
+
+type compiledRule struct {
+	RedirectRule
+	segments []string // From split on "/", without a trailing "*"
+	splat    bool
+	params   []string // Placeholder names, longest first
+}
+
+// NewRedirects validates and compiles rules, keeping their order.
+func NewRedirects(rules []RedirectRule) (*Redirects, error) {
+	compiled := make([]compiledRule, 0, len(rules))
+	for i, rule := range rules {
+		c, err := compileRule(rule)
+		if err != nil {
+			return nil, fmt.Errorf("redirect rule %d (%s): %w", i+1, rule.From, err)
+		}
+		compiled = append(compiled, c)
+	}
+	return &Redirects{rules: compiled}, nil
+}
+
+// ParseRedirects reads rules in the _redirects text format: one rule per line
+// as "from to [status]", with blank lines and lines starting with # ignored.
+// A status suffix of ! is accepted for compatibility; rules always apply
+// before the object lookup.
+func ParseRedirects(r io.Reader) (*Redirects, error) {
+	var rules []RedirectRule
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		fields := strings.Fields(text)
+		if len(fields) < 2 || len(fields) > 3 {
+			return nil, fmt.Errorf("line %d: want \"from to [status]\"", line)
+		}
+
+		rule := RedirectRule{From: fields[0], To: fields[1]}
+		if len(fields) == 3 {
+			status, err := strconv.Atoi(strings.TrimSuffix(fields[2], "!"))
+			if err != nil {
+				return nil, fmt.Errorf("line %d: invalid status %q", line, fields[2])
+			}
+			rule.Status = status
+		}
+		if _, err := compileRule(rule); err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		rules = append(rules, rule)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("read redirects: %w", err)
+	}
+
+	return NewRedirects(rules)
+}
+
+func compileRule(rule RedirectRule) (compiledRule, error) {
+	switch rule.Status {
+	case 0:
+		rule.Status = http.StatusMovedPermanently
+	case http.StatusOK, http.StatusMovedPermanently, http.StatusFound, http.StatusSeeOther,
+		http.StatusTemporaryRedirect, http.StatusPermanentRedirect:
+	default:
+		return compiledRule{}, fmt.Errorf("unsupported status %d", rule.Status)
+	}
+
+	if !strings.HasPrefix(rule.From, "/") {
+		return compiledRule{}, errors.New("from must start with /")
+	}
+
+	external := strings.HasPrefix(rule.To, "http://") || strings.HasPrefix(rule.To, "https://")
+	switch {
+	case external && rule.Status == http.StatusOK:
+		return compiledRule{}, errors.New("rewrites must target a local path")
+	case !external && !strings.HasPrefix(rule.To, "/"):
+		return compiledRule{}, errors.New("to must start with / or be an http(s) URL")
+	}
+
+	c := compiledRule{RedirectRule: rule}
+	from := strings.TrimSuffix(strings.TrimPrefix(rule.From, "/"), "/")
+	if from == "*" || strings.HasSuffix(from, "/*") {
+		c.splat = true
+		from = strings.TrimSuffix(strings.TrimSuffix(from, "*"), "/")
+	}
+	if from != "" {
+		c.segments = strings.Split(from, "/")
+	}
+
+	for _, seg := range c.segments {
+		if strings.Contains(seg, "*") {
+			return compiledRule{}, errors.New("* is only allowed as the last segment")
+		}
+		if name, ok := strings.CutPrefix(seg, ":"); ok {
+			if name == "" || name == "splat" {
+				return compiledRule{}, fmt.Errorf("invalid placeholder %q", seg)
+			}
+			c.params = append(c.params, name)
+		}
+	}
+	if c.splat {
+		c.params = append(c.params, "splat")
+	}
+	// Replace longer names first so :id never clobbers :identifier
+	sort.Slice(c.params, func(i, j int) bool { return len(c.params[i]) > len(c.params[j]) })
+
+	return c, nil
+}
+
+// Match returns the target and status of the first rule matching path, an
+// absolute URL path.
+func (rd *Redirects) Match(path string) (string, int, bool) {
+	if rd == nil {
+		return "", 0, false
+	}
+
+	trimmed := strings.TrimSuffix(strings.TrimPrefix(path, "/"), "/")
+	var segments []string
+	if trimmed != "" {
+		segments = strings.Split(trimmed, "/")
+	}
+
+	for _, rule := range rd.rules {
+		if values, ok := rule.match(segments); ok {
+			return rule.target(values), rule.Status, true
+		}
+	}
+	return "", 0, false
+}
+
+func (c compiledRule) match(segments []string) (map[string]string, bool) {
+	if len(segments) < len(c.segments) || (!c.splat && len(segments) != len(c.segments)) {
+		return nil, false
+	}
+
+	values := make(map[string]string, len(c.params))
+	for i, seg := range c.segments {
+		if name, ok := strings.CutPrefix(seg, ":"); ok {
+			values[name] = segments[i]
+			continue
+		}
+		if seg != segments[i] {
+			return nil, false
+		}
+	}
+	if c.splat {
+		values["splat"] = strings.Join(segments[len(c.segments):], "/")
+	}
+	return values, true
+}
+
+func (c compiledRule) target(values map[string]string) string {
+	to := c.To
+	for _, name := range c.params {
+		to = strings.ReplaceAll(to, ":"+name, values[name])
+	}
+	return to
+}
+
+// redirectsFile caches the rules parsed from the redirects object, reloading
+// them when its ETag changes.
+type redirectsFile struct {
+	name     string
+	interval time.Duration
+	logger   *slog.Logger
+	reload   sync.Mutex
+	state    atomic.Pointer[redirectsFileState]
+}
+
+type redirectsFileState struct {
+	etag      string
+	redirects *Redirects
+	checked   time.Time
+}
+
+// rules returns the current file rules, checking the object's ETag at most
+// once per interval. A missing object means no rules; a file that
+// fails to parse keeps the previous rules.
+func (f *redirectsFile) rules(ctx context.Context, service Service) *Redirects {
+	st := f.state.Load()
+	if st != nil && time.Since(st.checked) < f.interval {
+		return st.redirects
+	}
+
+	if !f.reload.TryLock() {
+		if st != nil {
+			// Another request is reloading; serve the previous rules meanwhile
+			return st.redirects
+		}
+		f.reload.Lock()
+	}
+	defer f.reload.Unlock()
+
+	if cur := f.state.Load(); cur != nil && time.Since(cur.checked) < f.interval {
+		return cur.redirects
+	}
+
+	next := f.load(ctx, service, st)
+	f.state.Store(next)
+	return next.redirects
+}
+
+func (f *redirectsFile) load(ctx context.Context, service Service, prev *redirectsFileState) *redirectsFileState {
+	next := &redirectsFileState{checked: time.Now()}
+	if prev != nil {
+		next.etag, next.redirects = prev.etag, prev.redirects
+	}
+
+	obj, err := service.Info(ctx, f.name)
+	// SPA and clean-URL fallbacks resolve to other objects
+	if errors.Is(err, stowry.ErrNotFound) || (err == nil && obj.Path != f.name) {
+		return &redirectsFileState{checked: next.checked}
+	}
+	if err != nil {
+		f.logger.Warn("load redirects", "file", f.name, "error", err)
+		return next
+	}
+	if obj.Etag == next.etag {
+		return next
+	}
+
+	_, content, err := service.Get(ctx, f.name)
+	if err != nil {
+		f.logger.Warn("load redirects", "file", f.name, "error", err)
+		return next
+	}
+	defer func() { _ = content.Close() }()
+
+	redirects, err := ParseRedirects(content)
+	if err != nil {
+		f.logger.Warn("parse redirects", "file", f.name, "error", err)
+		return next
+	}
+	next.etag, next.redirects = obj.Etag, redirects
+	return next
+}
+
+// applyRedirects evaluates configured and file rules for a static or SPA
+// request. It writes the response and returns handled=true for redirects and
+// rewrite loops; otherwise it returns the object path to serve, which differs
+// from path when a rewrite applied.
+func (h *Handler) applyRedirects(w http.ResponseWriter, r *http.Request, path string) (target string, rewritten, handled bool) {
+	if h.config.Mode == stowry.ModeStore {
+		return path, false, false
+	}
+
+	var fileRules *Redirects
+	if h.redirectsFile != nil {
+		fileRules = h.redirectsFile.rules(r.Context(), h.service)
+	}
+	if h.config.Redirects == nil && fileRules == nil {
+		return path, false, false
+	}
+
+	current := "/" + path
+	for range MaxRewrites + 1 {
+		to, status, ok := h.config.Redirects.Match(current)
+		if !ok {
+			to, status, ok = fileRules.Match(current)
+		}
+		if !ok {
+			return strings.TrimPrefix(current, "/"), rewritten, false
+		}
+
+		if status != http.StatusOK {
+			if !strings.Contains(to, "?") && r.URL.RawQuery != "" {
+				to += "?" + r.URL.RawQuery
+			}
+			http.Redirect(w, r, to, status)
+			return "", false, true
+		}
+
+		// Rewrites keep the URL and serve another object
+		u, err := url.Parse(to)
+		if err != nil {
+			h.writeError(w, r, http.StatusBadRequest, "invalid_path", "Invalid path")
+			return "", false, true
+		}
+		if u.Path == current {
+			return strings.TrimPrefix(current, "/"), true, false
+		}
+		current, rewritten = u.Path, true
+	}
+
+	h.writeError(w, r, http.StatusLoopDetected, "redirect_loop", "Too many rewrites")
+	return "", false, true
+}
+
+// isRedirectsFile reports whether path names the redirects object, which is
+// never served.
+func (h *Handler) isRedirectsFile(path string) bool {
+	return h.redirectsFile != nil && strings.TrimSuffix(path, "/") == h.redirectsFile.name
+}
@@ -0,0 +1,297 @@
+package http_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sagarc03/stowry"
+	stowryhttp "github.com/sagarc03/stowry/http"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseRedirects(t *testing.T) {
+	t.Run("parses rules, comments and statuses", func(t *testing.T) {
+		rd, err := stowryhttp.ParseRedirects(strings.NewReader(`
+# Moved pages
+/old        /new
+/temp       /elsewhere   302
+/forced     /target      301!
+
+/app/*      /app/index.html  200
+`))
+		require.NoError(t, err)
+
+		to, status, ok := rd.Match("/old")
+		assert.True(t, ok)
+		assert.Equal(t, "/new", to)
+		assert.Equal(t, http.StatusMovedPermanently, status)
+
+		_, status, _ = rd.Match("/temp")
+		assert.Equal(t, http.StatusFound, status)
+
+		_, status, _ = rd.Match("/forced")
+		assert.Equal(t, http.StatusMovedPermanently, status)
+
+		to, status, _ = rd.Match("/app/settings/profile")
+		assert.Equal(t, "/app/index.html", to)
+		assert.Equal(t, http.StatusOK, status)
+	})
+
+	errorTests := []struct {
+		name    string
+		input   string
+		wantErr string
+	}{
+		{name: "missing target", input: "/only", wantErr: "line 1"},
+		{name: "too many fields", input: "/a /b 301 extra", wantErr: "line 1"},
+		{name: "invalid status", input: "# c\n/a /b abc", wantErr: "line 2"},
+		{name: "unsupported status", input: "/a /b 404", wantErr: "unsupported status"},
+		{name: "relative from", input: "a /b", wantErr: "from must start with /"},
+		{name: "relative to", input: "/a b", wantErr: "to must start with /"},
+		{name: "external rewrite", input: "/a https://example.com 200", wantErr: "local path"},
+		{name: "splat in middle", input: "/a/*/b /c", wantErr: "last segment"},
+		{name: "reserved placeholder", input: "/a/:splat /c", wantErr: "invalid placeholder"},
+	}
+	for _, tt := range errorTests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := stowryhttp.ParseRedirects(strings.NewReader(tt.input))
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.wantErr)
+		})
+	}
+}
+
+func TestRedirects_Match(t *testing.T) {
+	rd, err := stowryhttp.NewRedirects([]stowryhttp.RedirectRule{
+		{From: "/blog/:year/:slug", To: "/posts/:year-:slug", Status: 301},
+		{From: "/blog/*", To: "/news/:splat", Status: 302},
+		{From: "/go/:id", To: "https://example.com/items/:id", Status: 307},
+		{From: "/docs/", To: "/documentation/", Status: 308},
+		{From: "/*", To: "/catch-all", Status: 200},
+	})
+	require.NoError(t, err)
+
+	tests := []struct {
+		name       string
+		path       string
+		wantTo     string
+		wantStatus int
+	}{
+		{name: "placeholders", path: "/blog/2024/hello", wantTo: "/posts/2024-hello", wantStatus: 301},
+		{name: "first match wins over splat", path: "/blog/2024/hello/", wantTo: "/posts/2024-hello", wantStatus: 301},
+		{name: "splat captures the rest", path: "/blog/a/b/c", wantTo: "/news/a/b/c", wantStatus: 302},
+		{name: "splat matches empty rest", path: "/blog", wantTo: "/news/", wantStatus: 302},
+		{name: "external target", path: "/go/42", wantTo: "https://example.com/items/42", wantStatus: 307},
+		{name: "trailing slash is ignored", path: "/docs", wantTo: "/documentation/", wantStatus: 308},
+		{name: "root splat", path: "/anything/else", wantTo: "/catch-all", wantStatus: 200},
+		{name: "root", path: "/", wantTo: "/catch-all", wantStatus: 200},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			to, status, ok := rd.Match(tt.path)
+			require.True(t, ok)
+			assert.Equal(t, tt.wantTo, to)
+			assert.Equal(t, tt.wantStatus, status)
+		})
+	}
+
+	t.Run("no match", func(t *testing.T) {
+		rd, err := stowryhttp.NewRedirects([]stowryhttp.RedirectRule{{From: "/a", To: "/b"}})
+		require.NoError(t, err)
+
+		_, _, ok := rd.Match("/a/b")
+		assert.False(t, ok)
+	})
+
+	t.Run("nil redirects match nothing", func(t *testing.T) {
+		var rd *stowryhttp.Redirects
+		_, _, ok := rd.Match("/a")
+		assert.False(t, ok)
+	})
+}
+
+func newRedirectRouter(t *testing.T, service stowryhttp.Service, mode stowry.ServerMode, rules []stowryhttp.RedirectRule, file string) http.Handler {
+	t.Helper()
+	config := &stowryhttp.HandlerConfig{Mode: mode, RedirectsFile: file, RedirectsReloadInterval: time.Nanosecond}
+	if rules != nil {
+		rd, err := stowryhttp.NewRedirects(rules)
+		require.NoError(t, err)
+		config.Redirects = rd
+	}
+	return stowryhttp.NewHandler(config, service).Router()
+}
+
+func TestHandler_Redirects(t *testing.T) {
+	page := stowry.MetaData{Path: "app/index.html", Etag: "e1", ContentType: "text/html"}
+
+	t.Run("redirect keeps the query string", func(t *testing.T) {
+		router := newRedirectRouter(t, new(MockService), stowry.ModeStatic, []stowryhttp.RedirectRule{{From: "/old", To: "/new", Status: 308}}, "")
+
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/old?ref=mail", http.NoBody))
+
+		assert.Equal(t, http.StatusPermanentRedirect, rec.Code)
+		assert.Equal(t, "/new?ref=mail", rec.Header().Get("Location"))
+	})
+
+	t.Run("rewrite serves another object without redirecting", func(t *testing.T) {
+		service := new(MockService)
+		service.On("Get", mock.Anything, "app/index.html").Return(page, readSeekNopCloser{strings.NewReader("app")}, nil)
+		router := newRedirectRouter(t, service, stowry.ModeStatic, []stowryhttp.RedirectRule{{From: "/app/*", To: "/app/index.html", Status: 200}}, "")
+
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/app/settings", http.NoBody))
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "app", rec.Body.String())
+		assert.Empty(t, rec.Header().Get("Location"))
+	})
+
+	t.Run("rewrite chain ending in a redirect", func(t *testing.T) {
+		router := newRedirectRouter(t, new(MockService), stowry.ModeStatic, []stowryhttp.RedirectRule{
+			{From: "/a", To: "/b", Status: 200},
+			{From: "/b", To: "/c", Status: 302},
+		}, "")
+
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/a", http.NoBody))
+
+		assert.Equal(t, http.StatusFound, rec.Code)
+		assert.Equal(t, "/c", rec.Header().Get("Location"))
+	})
+
+	t.Run("rewrite loop is capped", func(t *testing.T) {
+		service := new(MockService)
+		router := newRedirectRouter(t, service, stowry.ModeStatic, []stowryhttp.RedirectRule{
+			{From: "/a", To: "/b", Status: 200},
+			{From: "/b", To: "/a", Status: 200},
+		}, "")
+
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/a", http.NoBody))
+
+		assert.Equal(t, http.StatusLoopDetected, rec.Code)
+		service.AssertNotCalled(t, "Get", mock.Anything, mock.Anything)
+	})
+
+	t.Run("self rewrite is served", func(t *testing.T) {
+		service := new(MockService)
+		service.On("Get", mock.Anything, "app/index.html").Return(page, readSeekNopCloser{strings.NewReader("app")}, nil)
+		router := newRedirectRouter(t, service, stowry.ModeStatic, []stowryhttp.RedirectRule{{From: "/app/index.html", To: "/app/index.html", Status: 200}}, "")
+
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/app/index.html", http.NoBody))
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+	})
+
+	t.Run("head follows rules", func(t *testing.T) {
+		router := newRedirectRouter(t, new(MockService), stowry.ModeSPA, []stowryhttp.RedirectRule{{From: "/old", To: "/new"}}, "")
+
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(http.MethodHead, "/old", http.NoBody))
+
+		assert.Equal(t, http.StatusMovedPermanently, rec.Code)
+		assert.Equal(t, "/new", rec.Header().Get("Location"))
+	})
+
+	t.Run("ignored in store mode", func(t *testing.T) {
+		service := new(MockService)
+		service.On("Get", mock.Anything, "old").Return(stowry.MetaData{Path: "old", Etag: "e"}, readSeekNopCloser{strings.NewReader("x")}, nil)
+		router := newRedirectRouter(t, service, stowry.ModeStore, []stowryhttp.RedirectRule{{From: "/old", To: "/new"}}, stowryhttp.DefaultRedirectsFile)
+
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/old", http.NoBody))
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+	})
+}
+
+func TestHandler_RedirectsFile(t *testing.T) {
+	fileMeta := func(etag string) stowry.MetaData {
+		return stowry.MetaData{Path: "_redirects", Etag: etag, ContentType: "text/plain"}
+	}
+
+	t.Run("loads rules and reloads when the etag changes", func(t *testing.T) {
+		service := new(MockService)
+		service.On("Info", mock.Anything, "_redirects").Return(fileMeta("v1"), nil).Once()
+		service.On("Get", mock.Anything, "_redirects").Return(fileMeta("v1"), readSeekNopCloser{strings.NewReader("/old /v1 302")}, nil).Once()
+		router := newRedirectRouter(t, service, stowry.ModeStatic, nil, "_redirects")
+
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/old", http.NoBody))
+		assert.Equal(t, "/v1", rec.Header().Get("Location"))
+
+		// Unchanged ETag: the object is not read again
+		service.On("Info", mock.Anything, "_redirects").Return(fileMeta("v1"), nil).Once()
+		rec = httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/old", http.NoBody))
+		assert.Equal(t, "/v1", rec.Header().Get("Location"))
+
+		service.On("Info", mock.Anything, "_redirects").Return(fileMeta("v2"), nil).Once()
+		service.On("Get", mock.Anything, "_redirects").Return(fileMeta("v2"), readSeekNopCloser{strings.NewReader("/old /v2 302")}, nil).Once()
+		rec = httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/old", http.NoBody))
+		assert.Equal(t, "/v2", rec.Header().Get("Location"))
+
+		service.AssertExpectations(t)
+	})
+
+	t.Run("config rules take precedence", func(t *testing.T) {
+		service := new(MockService)
+		service.On("Info", mock.Anything, "_redirects").Return(fileMeta("v1"), nil)
+		service.On("Get", mock.Anything, "_redirects").Return(fileMeta("v1"), readSeekNopCloser{strings.NewReader("/old /from-file 302")}, nil)
+		router := newRedirectRouter(t, service, stowry.ModeStatic, []stowryhttp.RedirectRule{{From: "/old", To: "/from-config", Status: 302}}, "_redirects")
+
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/old", http.NoBody))
+
+		assert.Equal(t, "/from-config", rec.Header().Get("Location"))
+	})
+
+	t.Run("invalid file keeps previous rules", func(t *testing.T) {
+		service := new(MockService)
+		service.On("Info", mock.Anything, "_redirects").Return(fileMeta("v1"), nil).Once()
+		service.On("Get", mock.Anything, "_redirects").Return(fileMeta("v1"), readSeekNopCloser{strings.NewReader("/old /v1 302")}, nil).Once()
+		service.On("Info", mock.Anything, "_redirects").Return(fileMeta("v2"), nil).Once()
+		service.On("Get", mock.Anything, "_redirects").Return(fileMeta("v2"), readSeekNopCloser{strings.NewReader("not a rule")}, nil).Once()
+		router := newRedirectRouter(t, service, stowry.ModeStatic, nil, "_redirects")
+
+		for range 2 {
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/old", http.NoBody))
+			assert.Equal(t, "/v1", rec.Header().Get("Location"))
+		}
+	})
+
+	t.Run("spa fallback is not mistaken for the file", func(t *testing.T) {
+		index := stowry.MetaData{Path: "index.html", Etag: "i", ContentType: "text/html"}
+		service := new(MockService)
+		service.On("Info", mock.Anything, "_redirects").Return(index, nil)
+		service.On("Get", mock.Anything, "old").Return(index, readSeekNopCloser{strings.NewReader("spa")}, nil)
+		router := newRedirectRouter(t, service, stowry.ModeSPA, nil, "_redirects")
+
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/old", http.NoBody))
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "spa", rec.Body.String())
+	})
+
+	t.Run("file itself is not served", func(t *testing.T) {
+		service := new(MockService)
+		service.On("Info", mock.Anything, "_redirects").Return(stowry.MetaData{}, stowry.ErrNotFound)
+		router := newRedirectRouter(t, service, stowry.ModeStatic, nil, "_redirects")
+
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/_redirects", http.NoBody))
+
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+		service.AssertNotCalled(t, "Get", mock.Anything, "_redirects")
+	})
+}
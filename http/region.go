@@ -0,0 +1,57 @@
+package http
+
+import (
+	"fmt"
+	"net/http"
+)
+
+// RegionHeader carries the region of the server that answered, on every
+// response of a server with HandlerConfig.Region set.
+const RegionHeader = "X-Stowry-Region"
+
+// ExpectRegionHeader names the region a client expects to answer its
+// request. A server in another region answers 421 Misdirected Request
+// instead of serving it, so a client reading its own writes can retry
+// against the region that acknowledged them.
+const ExpectRegionHeader = "X-Stowry-Expect-Region"
+
+// maxRegionLength bounds a region identity.
+const maxRegionLength = 63
+
+// validateRegion reports whether region is a short token that is safe in a
+// header value: letters, digits, '-', '_' and '.'.
+func validateRegion(region string) error {
+	if len(region) > maxRegionLength {
+		return fmt.Errorf("region %q is longer than %d bytes", region, maxRegionLength)
+	}
+	for _, c := range region {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9', c == '-', c == '_', c == '.':
+		default:
+			return fmt.Errorf("region %q may only contain letters, digits, '-', '_' and '.'", region)
+		}
+	}
+	return nil
+}
+
+// regionHeaderMiddleware sets RegionHeader on every response.
+func (h *Handler) regionHeaderMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set(RegionHeader, h.config.Region)
+		next.ServeHTTP(w, r)
+	})
+}
+
+// expectRegionMiddleware answers 421 Misdirected Request to requests whose
+// ExpectRegionHeader names another region, before the request body is read.
+// It runs after CORS, so browsers can read the answer.
+func (h *Handler) expectRegionMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if expect := r.Header.Get(ExpectRegionHeader); expect != "" && expect != h.config.Region {
+			h.writeError(w, r, http.StatusMisdirectedRequest, "misdirected_request",
+				fmt.Sprintf("request expects region %q but reached region %q", expect, h.config.Region))
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,129 @@
+package http_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/sagarc03/stowry"
+	stowryhttp "github.com/sagarc03/stowry/http"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHandler_Region(t *testing.T) {
+	serve := func(t *testing.T, config *stowryhttp.HandlerConfig, service stowryhttp.Service, req *http.Request) *httptest.ResponseRecorder {
+		t.Helper()
+		h, err := stowryhttp.New(config, service)
+		require.NoError(t, err)
+		rec := httptest.NewRecorder()
+		h.Router().ServeHTTP(rec, req)
+		return rec
+	}
+	config := func() *stowryhttp.HandlerConfig {
+		return &stowryhttp.HandlerConfig{
+			Mode:          stowry.ModeStore,
+			ReadVerifier:  headerVerifier{},
+			WriteVerifier: headerVerifier{},
+			Region:        "eu-west-1",
+		}
+	}
+
+	t.Run("every response names the region", func(t *testing.T) {
+		service := new(MockService)
+		service.On("Get", mock.Anything, "a.txt").
+			Return(stowry.MetaData{Path: "a.txt", ContentType: "text/plain", Etag: "abc", FileSizeBytes: 2}, readSeekNopCloser{strings.NewReader("hi")}, nil)
+
+		ok := httptest.NewRequest(http.MethodGet, "/a.txt", nil)
+		ok.Header.Set("X-Test-Auth", "ok")
+		rec := serve(t, config(), service, ok)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "eu-west-1", rec.Header().Get(stowryhttp.RegionHeader))
+
+		rec = serve(t, config(), service, httptest.NewRequest(http.MethodGet, "/a.txt", nil))
+		assert.Equal(t, http.StatusUnauthorized, rec.Code)
+		assert.Equal(t, "eu-west-1", rec.Header().Get(stowryhttp.RegionHeader))
+	})
+
+	t.Run("matching expected region is served", func(t *testing.T) {
+		service := new(MockService)
+		service.On("Get", mock.Anything, "a.txt").
+			Return(stowry.MetaData{Path: "a.txt", ContentType: "text/plain", Etag: "abc", FileSizeBytes: 2}, readSeekNopCloser{strings.NewReader("hi")}, nil)
+		req := httptest.NewRequest(http.MethodGet, "/a.txt", nil)
+		req.Header.Set("X-Test-Auth", "ok")
+		req.Header.Set(stowryhttp.ExpectRegionHeader, "eu-west-1")
+
+		rec := serve(t, config(), service, req)
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "hi", rec.Body.String())
+	})
+
+	t.Run("other expected region is misdirected", func(t *testing.T) {
+		service := new(MockService)
+		req := httptest.NewRequest(http.MethodPut, "/a.txt", strings.NewReader("hi"))
+		req.Header.Set("X-Test-Auth", "ok")
+		req.Header.Set(stowryhttp.ExpectRegionHeader, "us-east-1")
+
+		rec := serve(t, config(), service, req)
+
+		assert.Equal(t, http.StatusMisdirectedRequest, rec.Code)
+		assert.Equal(t, "eu-west-1", rec.Header().Get(stowryhttp.RegionHeader))
+		var body stowryhttp.ErrorResponse
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+		assert.Equal(t, "misdirected_request", body.Error)
+		service.AssertNotCalled(t, "Create", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("misdirected answer carries CORS headers", func(t *testing.T) {
+		cfg := config()
+		cfg.CORS = stowryhttp.CORSConfig{Enabled: true, AllowedOrigins: []string{"https://app.example.com"}, ExposedHeaders: []string{stowryhttp.RegionHeader}}
+		req := httptest.NewRequest(http.MethodGet, "/a.txt", nil)
+		req.Header.Set("Origin", "https://app.example.com")
+		req.Header.Set(stowryhttp.ExpectRegionHeader, "us-east-1")
+
+		rec := serve(t, cfg, new(MockService), req)
+
+		assert.Equal(t, http.StatusMisdirectedRequest, rec.Code)
+		assert.Equal(t, "https://app.example.com", rec.Header().Get("Access-Control-Allow-Origin"))
+	})
+
+	t.Run("without a region nothing is sent or checked", func(t *testing.T) {
+		service := new(MockService)
+		service.On("Get", mock.Anything, "a.txt").
+			Return(stowry.MetaData{Path: "a.txt", ContentType: "text/plain", Etag: "abc", FileSizeBytes: 2}, readSeekNopCloser{strings.NewReader("hi")}, nil)
+		cfg := config()
+		cfg.Region = ""
+		req := httptest.NewRequest(http.MethodGet, "/a.txt", nil)
+		req.Header.Set("X-Test-Auth", "ok")
+		req.Header.Set(stowryhttp.ExpectRegionHeader, "us-east-1")
+
+		rec := serve(t, cfg, service, req)
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Empty(t, rec.Header().Get(stowryhttp.RegionHeader))
+	})
+
+	t.Run("capabilities report the region", func(t *testing.T) {
+		rec := serve(t, &stowryhttp.HandlerConfig{Mode: stowry.ModeStore, Region: "eu-west-1"}, new(MockService), httptest.NewRequest(http.MethodGet, stowryhttp.CapabilitiesPath, nil))
+
+		require.Equal(t, http.StatusOK, rec.Code)
+		var caps stowryhttp.Capabilities
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &caps))
+		assert.Equal(t, "eu-west-1", caps.Region)
+	})
+}
+
+func TestHandlerConfig_Validate_Region(t *testing.T) {
+	for _, region := range []string{"eu-west-1", "dc_2.rack-a"} {
+		config := &stowryhttp.HandlerConfig{Mode: stowry.ModeStore, Region: region}
+		assert.NoError(t, config.Validate(), region)
+	}
+	for _, region := range []string{"eu west", "eu\r\nX-Evil: 1", strings.Repeat("a", 64)} {
+		config := &stowryhttp.HandlerConfig{Mode: stowry.ModeStore, Region: region}
+		assert.Error(t, config.Validate(), region)
+	}
+}
@@ -4,8 +4,10 @@ import (
 	"bytes"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"log/slog"
 	"net/http"
+	"strconv"
 
 	"github.com/sagarc03/stowry"
 )
@@ -16,6 +18,37 @@ type ErrorResponse struct {
 	Message string `json:"message"`
 }
 
+// ObjectLimitResponse is the error response for an upload rejected by a
+// per-prefix object limit.
+type ObjectLimitResponse struct {
+	ErrorResponse
+	Prefix     string `json:"prefix"`
+	MaxObjects int64  `json:"max_objects"`
+}
+
+// ContentTypeResponse is the error response for an upload whose content type
+// a content type rule forbids.
+type ContentTypeResponse struct {
+	ErrorResponse
+	Prefix      string `json:"prefix"`
+	ContentType string `json:"content_type"`
+}
+
+// PathLimitResponse is the error response for an upload whose path exceeds
+// a path limit.
+type PathLimitResponse struct {
+	ErrorResponse
+	Limit string `json:"limit"`
+	Max   int    `json:"max"`
+}
+
+// ParameterResponse is the error response for a request with a query
+// parameter no object path could need.
+type ParameterResponse struct {
+	ErrorResponse
+	Parameter string `json:"parameter"`
+}
+
 // WriteError writes a JSON error response
 func WriteError(w http.ResponseWriter, code int, errCode, message string) {
 	var buf bytes.Buffer
@@ -28,6 +61,7 @@ func WriteError(w http.ResponseWriter, code int, errCode, message string) {
 		return
 	}
 	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Length", strconv.Itoa(buf.Len()))
 	w.WriteHeader(code)
 	_, _ = w.Write(buf.Bytes())
 }
@@ -35,34 +69,165 @@ func WriteError(w http.ResponseWriter, code int, errCode, message string) {
 // HandleError writes appropriate error response based on error type
 func HandleError(w http.ResponseWriter, err error) {
 	slog.Error("request error", "error", err)
+	writeErrorFor(w, err)
+}
 
-	if errors.Is(err, stowry.ErrNotFound) {
-		WriteError(w, http.StatusNotFound, "not_found", "Object not found")
-		return
+// requestCanceled reports whether err comes from r's context being done,
+// which the backends report as an error wrapping context.Canceled or
+// context.DeadlineExceeded.
+func requestCanceled(r *http.Request, err error) bool {
+	ctxErr := r.Context().Err()
+	return ctxErr != nil && errors.Is(err, ctxErr)
+}
+
+// errorStatus maps err to the status, error code and message of its
+// response.
+func errorStatus(err error) (int, string, string) {
+	var limitErr *stowry.ObjectLimitError
+	var typeErr *stowry.ContentTypeError
+	var pathErr *stowry.PathLimitError
+	var queryErr *stowry.ListQueryError
+	switch {
+	case errors.Is(err, stowry.ErrNotFound):
+		return http.StatusNotFound, "not_found", "Object not found"
+	case errors.Is(err, stowry.ErrInvalidContentType):
+		return http.StatusBadRequest, "invalid_content_type", "Content-Type is not a valid media type"
+	case errors.As(err, &typeErr):
+		return http.StatusUnsupportedMediaType, "content_type_not_allowed",
+			fmt.Sprintf("Content type %s is not allowed under prefix %q", typeErr.ContentType, typeErr.Prefix)
+	case errors.Is(err, stowry.ErrUnsupportedContentEncoding):
+		return http.StatusUnsupportedMediaType, "unsupported_content_encoding", "Content-Encoding must be gzip or identity"
+	case errors.Is(err, stowry.ErrInvalidFilename):
+		return http.StatusBadRequest, "invalid_filename", fmt.Sprintf("Filename must be valid UTF-8 of at most %d bytes", stowry.MaxFilenameBytes)
+	case errors.Is(err, stowry.ErrUserMetadataTooLarge):
+		return http.StatusBadRequest, "metadata_too_large", fmt.Sprintf("User metadata must take at most %d bytes", stowry.MaxUserMetadataBytes)
+	case errors.Is(err, stowry.ErrInvalidUserMetadata):
+		return http.StatusBadRequest, "invalid_metadata", "User metadata keys must be header names and values must not contain control characters"
+	case errors.Is(err, stowry.ErrInvalidCacheControl):
+		return http.StatusBadRequest, "invalid_cache_control", fmt.Sprintf("Cache-Control must be at most %d bytes without control characters", stowry.MaxCacheControlBytes)
+	case errors.Is(err, stowry.ErrInvalidID):
+		return http.StatusBadRequest, "invalid_id", ObjectIDHeader + " must be a UUID or a ULID"
+	case errors.Is(err, stowry.ErrInvalidETag):
+		return http.StatusBadRequest, "invalid_etag", "ETag must be a SHA-256 digest in lowercase hex"
+	case errors.Is(err, stowry.ErrInvalidEncodedContent):
+		return http.StatusBadRequest, "invalid_encoded_content", "Content does not decode with its Content-Encoding"
+	case errors.As(err, &pathErr):
+		return http.StatusBadRequest, "path_limit_exceeded", pathLimitMessage(pathErr)
+	case errors.Is(err, stowry.ErrPathLimitExceeded):
+		return http.StatusBadRequest, "path_limit_exceeded", "Path is too long or too deep for the storage backend"
+	case errors.As(err, &queryErr):
+		return http.StatusBadRequest, "invalid_parameter", queryErr.Param + " " + queryErr.Reason
+	case errors.Is(err, stowry.ErrInvalidPart):
+		return http.StatusBadRequest, "invalid_part",
+			fmt.Sprintf("Parts must be numbered 1 to %d and completed in ascending order with the ETags their uploads returned", stowry.MaxMultipartParts)
+	case errors.Is(err, stowry.ErrInvalidInput):
+		return http.StatusBadRequest, "invalid_path", "Invalid path"
+	case errors.Is(err, stowry.ErrInsufficientStorage):
+		return http.StatusInsufficientStorage, "insufficient_storage", "Insufficient storage for this upload"
+	case errors.As(err, &limitErr):
+		return http.StatusInsufficientStorage, "object_limit_exceeded",
+			fmt.Sprintf("Prefix %q has reached its limit of %d objects", limitErr.Prefix, limitErr.MaxObjects)
+	case errors.Is(err, stowry.ErrAlreadyExists):
+		return http.StatusConflict, "already_exists", "An object already exists at the destination path"
+	case errors.Is(err, stowry.ErrConflict):
+		return http.StatusConflict, "conflict", "Object was created concurrently by another request"
+	case errors.Is(err, stowry.ErrIDConflict):
+		return http.StatusConflict, "id_conflict", "Object ID belongs to another object or differs from the existing object's ID"
+	case errors.Is(err, stowry.ErrPreconditionFailed):
+		return http.StatusPreconditionFailed, "precondition_failed", "ETag mismatch"
+	case errors.Is(err, stowry.ErrGone):
+		return http.StatusGone, "gone", "Object was deleted and its content has been cleaned up"
+	case errors.Is(err, stowry.ErrCursorExpired):
+		return http.StatusGone, "cursor_expired", "Changes after this cursor were pruned; restart from the current position"
+	case errors.Is(err, stowry.ErrNotSupported):
+		return http.StatusNotImplemented, "not_supported", "Not supported by this server"
+	case errors.Is(err, stowry.ErrUnavailable):
+		return http.StatusServiceUnavailable, "unavailable", "Metadata database is unavailable, retry later"
+	case errors.Is(err, ErrNonCanonicalPath):
+		return http.StatusBadRequest, "non_canonical_path", "Signed requests must use the canonical path, without duplicate slashes"
+	case errors.Is(err, ErrUnauthorized):
+		return http.StatusUnauthorized, "unauthorized", err.Error()
+	case errors.Is(err, ErrForbidden):
+		return http.StatusForbidden, "forbidden", err.Error()
 	}
+	return http.StatusInternalServerError, "internal_error", "Internal server error"
+}
 
-	if errors.Is(err, stowry.ErrInvalidInput) {
-		WriteError(w, http.StatusBadRequest, "invalid_path", "Invalid path")
+// writeErrorFor writes the default JSON error response for err.
+func writeErrorFor(w http.ResponseWriter, err error) {
+	status, code, message := errorStatus(err)
+
+	var limitErr *stowry.ObjectLimitError
+	if errors.As(err, &limitErr) {
+		_ = WriteJSON(w, status, ObjectLimitResponse{
+			ErrorResponse: ErrorResponse{Error: code, Message: message},
+			Prefix:        limitErr.Prefix,
+			MaxObjects:    limitErr.MaxObjects,
+		})
 		return
 	}
-
-	if errors.Is(err, ErrUnauthorized) {
-		WriteError(w, http.StatusUnauthorized, "unauthorized", err.Error())
+	var typeErr *stowry.ContentTypeError
+	if errors.As(err, &typeErr) {
+		_ = WriteJSON(w, status, ContentTypeResponse{
+			ErrorResponse: ErrorResponse{Error: code, Message: message},
+			Prefix:        typeErr.Prefix,
+			ContentType:   typeErr.ContentType,
+		})
 		return
 	}
-
-	// Default internal error
-	WriteError(w, http.StatusInternalServerError, "internal_error", "Internal server error")
+	var pathErr *stowry.PathLimitError
+	if errors.As(err, &pathErr) {
+		_ = WriteJSON(w, status, PathLimitResponse{
+			ErrorResponse: ErrorResponse{Error: code, Message: message},
+			Limit:         pathErr.Limit,
+			Max:           pathErr.Max,
+		})
+		return
+	}
+	var queryErr *stowry.ListQueryError
+	if errors.As(err, &queryErr) {
+		_ = WriteJSON(w, status, ParameterResponse{
+			ErrorResponse: ErrorResponse{Error: code, Message: message},
+			Parameter:     queryErr.Param,
+		})
+		return
+	}
+	WriteError(w, status, code, message)
 }
 
-// WriteJSON writes a JSON response
+// WriteJSON writes a JSON response. The body is encoded before the header
+// is written, so a failed encode never leaves a half-written response, and
+// Content-Length always matches the bytes sent.
 func WriteJSON(w http.ResponseWriter, code int, data any) error {
 	var buf bytes.Buffer
 	if err := json.NewEncoder(&buf).Encode(data); err != nil {
 		return err
 	}
 	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Length", strconv.Itoa(buf.Len()))
 	w.WriteHeader(code)
 	_, _ = w.Write(buf.Bytes())
 	return nil
 }
+
+// writeNoContent writes a 204 with no body, dropping any entity headers set
+// earlier so nothing announces a body that is not sent.
+func writeNoContent(w http.ResponseWriter) {
+	h := w.Header()
+	h.Del("Content-Type")
+	h.Del("Content-Length")
+	h.Del("Content-Encoding")
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// pathLimitMessage describes the path limit err reports.
+func pathLimitMessage(err *stowry.PathLimitError) string {
+	switch err.Limit {
+	case stowry.LimitMaxSegmentBytes:
+		return fmt.Sprintf("Path segment %d is %d bytes; %s is %d", err.Segment, err.Actual, err.Limit, err.Max)
+	case stowry.LimitMaxDepth:
+		return fmt.Sprintf("Path has %d segments; %s is %d", err.Actual, err.Limit, err.Max)
+	default:
+		return fmt.Sprintf("Path is %d bytes; %s is %d", err.Actual, err.Limit, err.Max)
+	}
+}
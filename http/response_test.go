@@ -2,6 +2,7 @@ package http_test
 
 import (
 	"errors"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"testing"
@@ -38,6 +39,72 @@ func TestHandleError_Unauthorized(t *testing.T) {
 	assert.Contains(t, rec.Body.String(), "unauthorized")
 }
 
+func TestHandleError_InsufficientStorage(t *testing.T) {
+	rec := httptest.NewRecorder()
+
+	stowryhttp.HandleError(rec, fmt.Errorf("create object big.bin: %w", stowry.ErrInsufficientStorage))
+
+	assert.Equal(t, http.StatusInsufficientStorage, rec.Code)
+	assert.Contains(t, rec.Body.String(), "insufficient_storage")
+}
+
+func TestHandleError_Unavailable(t *testing.T) {
+	rec := httptest.NewRecorder()
+
+	stowryhttp.HandleError(rec, fmt.Errorf("create object big.bin: metadata repository: %w: connection refused", stowry.ErrUnavailable))
+
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"unavailable"`)
+}
+
+func TestHandleError_ObjectLimitExceeded(t *testing.T) {
+	rec := httptest.NewRecorder()
+
+	limitErr := &stowry.ObjectLimitError{Prefix: "cache/", MaxObjects: 100, Count: 100}
+	stowryhttp.HandleError(rec, fmt.Errorf("create object cache/a.txt: %w", limitErr))
+
+	assert.Equal(t, http.StatusInsufficientStorage, rec.Code)
+	assert.JSONEq(t, `{
+		"error": "object_limit_exceeded",
+		"message": "Prefix \"cache/\" has reached its limit of 100 objects",
+		"prefix": "cache/",
+		"max_objects": 100
+	}`, rec.Body.String())
+}
+
+func TestHandleError_PathLimitExceeded(t *testing.T) {
+	rec := httptest.NewRecorder()
+
+	pathErr := &stowry.PathLimitError{Limit: stowry.LimitMaxSegmentBytes, Max: 255, Actual: 300, Segment: 2}
+	stowryhttp.HandleError(rec, fmt.Errorf("create object: %w", pathErr))
+
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.JSONEq(t, `{
+		"error": "path_limit_exceeded",
+		"message": "Path segment 2 is 300 bytes; max_segment_bytes is 255",
+		"limit": "max_segment_bytes",
+		"max": 255
+	}`, rec.Body.String())
+
+	t.Run("from storage", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+
+		stowryhttp.HandleError(rec, fmt.Errorf("finalize file: %w: file name too long", stowry.ErrPathLimitExceeded))
+
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		assert.Contains(t, rec.Body.String(), "path_limit_exceeded")
+	})
+}
+
+func TestHandleError_Conflict(t *testing.T) {
+	rec := httptest.NewRecorder()
+
+	stowryhttp.HandleError(rec, fmt.Errorf("create object a.txt: %w", stowry.ErrConflict))
+
+	assert.Equal(t, http.StatusConflict, rec.Code)
+	assert.Contains(t, rec.Body.String(), "conflict")
+}
+
 func TestHandleError_InternalError(t *testing.T) {
 	rec := httptest.NewRecorder()
 
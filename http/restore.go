@@ -0,0 +1,89 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/sagarc03/stowry"
+)
+
+// RestoreService is an optional Service extension for undoing soft deletes.
+// When the service implements it, store mode serves POST /{path}?restore
+// and GET /?deleted=true, both with write authentication even when reads
+// or writes are public. *stowry.StowryService implements it.
+type RestoreService interface {
+	Restore(ctx context.Context, path string) (stowry.MetaData, error)
+	ListDeleted(ctx context.Context, q stowry.ListQuery) (stowry.ListResult, error)
+}
+
+// restoreService returns the service's RestoreService, if any.
+func (h *Handler) restoreService() (RestoreService, bool) {
+	if h.config.Mode != stowry.ModeStore {
+		return nil, false
+	}
+	rs, ok := h.service.(RestoreService)
+	return rs, ok
+}
+
+// handleRestore serves POST /{path}?restore. The response is the restored
+// object's metadata.
+func (h *Handler) handleRestore(w http.ResponseWriter, r *http.Request) {
+	rs, ok := h.restoreService()
+	if !ok {
+		h.handleError(w, r, stowry.ErrNotSupported)
+		return
+	}
+
+	path, ok := h.multipartPath(w, r)
+	if !ok {
+		return
+	}
+
+	m, err := rs.Restore(r.Context(), path)
+	if err != nil {
+		if errors.Is(err, stowry.ErrNotFound) {
+			h.writeError(w, r, http.StatusNotFound, "not_found", "No deleted object at this path")
+			return
+		}
+		h.handleError(w, r, err)
+		return
+	}
+
+	principal, _ := PrincipalFromContext(r.Context())
+	h.logger.Info("object restored", "path", m.Path, "id", m.ID, "etag", m.Etag, "principal", principal.Subject)
+
+	// The backend is only reported by list and stat with ?include=backend
+	m.StorageBackend = ""
+	w.Header().Set("ETag", `"`+m.Etag+`"`)
+	_ = WriteJSON(w, http.StatusOK, m)
+}
+
+// handleListDeleted serves GET /?deleted=true, the list of soft-deleted
+// objects that can still be restored, with the parameters of GET /. The
+// router only reaches it after write authentication.
+func (h *Handler) handleListDeleted(w http.ResponseWriter, r *http.Request) {
+	rs, ok := h.restoreService()
+	if !ok {
+		h.handleError(w, r, stowry.ErrNotSupported)
+		return
+	}
+
+	query, ok := h.listQuery(w, r)
+	if !ok {
+		return
+	}
+
+	result, err := rs.ListDeleted(r.Context(), query)
+	if err != nil {
+		h.handleError(w, r, err)
+		return
+	}
+	h.trimListItems(r, result.Items)
+
+	w.Header().Set("Cache-Control", "no-store")
+	if err := writeListJSON(w, h.listItems(r.Context(), query, result, false), result.NextCursor); err != nil {
+		h.logger.Error("list deleted response failed", "prefix", query.PathPrefix, "error", err)
+		panic(http.ErrAbortHandler)
+	}
+}
@@ -0,0 +1,158 @@
+package http_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/sagarc03/stowry"
+	stowryhttp "github.com/sagarc03/stowry/http"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// restoreMockService is a MockService that can restore deleted objects.
+type restoreMockService struct {
+	MockService
+}
+
+func (m *restoreMockService) Restore(ctx context.Context, path string) (stowry.MetaData, error) {
+	args := m.Called(ctx, path)
+	return args.Get(0).(stowry.MetaData), args.Error(1)
+}
+
+func (m *restoreMockService) ListDeleted(ctx context.Context, q stowry.ListQuery) (stowry.ListResult, error) {
+	args := m.Called(ctx, q)
+	return args.Get(0).(stowry.ListResult), args.Error(1)
+}
+
+func serveRestore(t *testing.T, service stowryhttp.Service, method, target string, authed bool) *httptest.ResponseRecorder {
+	t.Helper()
+	config := stowryhttp.HandlerConfig{Mode: stowry.ModeStore, WriteVerifier: headerVerifier{}}
+	h, err := stowryhttp.New(&config, service)
+	require.NoError(t, err)
+	req := httptest.NewRequest(method, target, nil)
+	if authed {
+		req.Header.Set("X-Test-Auth", "ok")
+	}
+	rec := httptest.NewRecorder()
+	h.Router().ServeHTTP(rec, req)
+	return rec
+}
+
+func TestHandler_Restore(t *testing.T) {
+	t.Run("restores the object and returns its metadata", func(t *testing.T) {
+		service := new(restoreMockService)
+		service.On("Restore", mock.Anything, "docs/report.pdf").
+			Return(stowry.MetaData{Path: "docs/report.pdf", Etag: "abc123", StorageBackend: "archive"}, nil)
+
+		rec := serveRestore(t, service, http.MethodPost, "/docs/report.pdf?restore", true)
+
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		assert.Equal(t, `"abc123"`, rec.Header().Get("ETag"))
+		var meta stowry.MetaData
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &meta))
+		assert.Equal(t, "docs/report.pdf", meta.Path)
+		assert.Empty(t, meta.StorageBackend)
+	})
+
+	t.Run("needs write authentication", func(t *testing.T) {
+		service := new(restoreMockService)
+
+		rec := serveRestore(t, service, http.MethodPost, "/a.txt?restore", false)
+
+		assert.Equal(t, http.StatusUnauthorized, rec.Code)
+		service.AssertNotCalled(t, "Restore", mock.Anything, mock.Anything)
+	})
+
+	t.Run("not supported by the service", func(t *testing.T) {
+		rec := serveRestore(t, new(MockService), http.MethodPost, "/a.txt?restore", true)
+
+		assert.Equal(t, http.StatusMethodNotAllowed, rec.Code)
+	})
+
+	t.Run("invalid path", func(t *testing.T) {
+		service := new(restoreMockService)
+
+		rec := serveRestore(t, service, http.MethodPost, "/dir/?restore", true)
+
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		assertErrorCode(t, rec, "invalid_path")
+	})
+
+	for name, tc := range map[string]struct {
+		err      error
+		wantCode int
+		wantErr  string
+	}{
+		"nothing to restore": {fmt.Errorf("restore object: %w", stowry.ErrNotFound), http.StatusNotFound, "not_found"},
+		"cleaned up":         {fmt.Errorf("restore object: %w", stowry.ErrGone), http.StatusGone, "gone"},
+		"path in use":        {fmt.Errorf("restore object: %w", stowry.ErrAlreadyExists), http.StatusConflict, "already_exists"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			service := new(restoreMockService)
+			service.On("Restore", mock.Anything, "a.txt").Return(stowry.MetaData{}, tc.err)
+
+			rec := serveRestore(t, service, http.MethodPost, "/a.txt?restore", true)
+
+			assert.Equal(t, tc.wantCode, rec.Code)
+			assertErrorCode(t, rec, tc.wantErr)
+		})
+	}
+}
+
+func TestHandler_ListDeleted(t *testing.T) {
+	deletedAt := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	t.Run("lists restorable objects", func(t *testing.T) {
+		service := new(restoreMockService)
+		service.On("ListDeleted", mock.Anything, stowry.ListQuery{PathPrefix: "docs/", Limit: 10}).
+			Return(stowry.ListResult{
+				Items:      []stowry.MetaData{{Path: "docs/a.txt", DeletedAt: &deletedAt, DownloadCount: 3}},
+				NextCursor: "next",
+			}, nil)
+
+		rec := serveRestore(t, service, http.MethodGet, "/?deleted=true&prefix=docs/&limit=10", true)
+
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		assert.Equal(t, "no-store", rec.Header().Get("Cache-Control"))
+		var result stowry.ListResult
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &result))
+		require.Len(t, result.Items, 1)
+		assert.Equal(t, "docs/a.txt", result.Items[0].Path)
+		require.NotNil(t, result.Items[0].DeletedAt)
+		assert.True(t, deletedAt.Equal(*result.Items[0].DeletedAt))
+		assert.Zero(t, result.Items[0].DownloadCount)
+		assert.Equal(t, "next", result.NextCursor)
+		service.AssertNotCalled(t, "List", mock.Anything, mock.Anything)
+	})
+
+	t.Run("needs write authentication", func(t *testing.T) {
+		service := new(restoreMockService)
+
+		rec := serveRestore(t, service, http.MethodGet, "/?deleted=true", false)
+
+		assert.Equal(t, http.StatusUnauthorized, rec.Code)
+		service.AssertNotCalled(t, "ListDeleted", mock.Anything, mock.Anything)
+	})
+
+	t.Run("not supported by the service", func(t *testing.T) {
+		rec := serveRestore(t, new(MockService), http.MethodGet, "/?deleted=true", true)
+
+		assert.Equal(t, http.StatusNotImplemented, rec.Code)
+	})
+
+	t.Run("invalid limit", func(t *testing.T) {
+		service := new(restoreMockService)
+
+		rec := serveRestore(t, service, http.MethodGet, "/?deleted=true&limit=many", true)
+
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		service.AssertNotCalled(t, "ListDeleted", mock.Anything, mock.Anything)
+	})
+}
@@ -0,0 +1,89 @@
+package http
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/sagarc03/stowry"
+)
+
+// DefaultRobotsTxt is the built-in /robots.txt when SiteFilesConfig.RobotsTxt
+// is not set: every crawler may crawl everything.
+const DefaultRobotsTxt = "User-agent: *\nAllow: /\n"
+
+// SiteFilesConfig sets what static and SPA modes answer for /robots.txt and
+// /favicon.ico when no object exists at those keys. An uploaded robots.txt
+// or favicon.ico always takes precedence.
+type SiteFilesConfig struct {
+	// RobotsTxt is the body of the built-in /robots.txt. Empty uses
+	// DefaultRobotsTxt.
+	RobotsTxt string `mapstructure:"robots_txt"`
+	// Favicon is an object served for /favicon.ico, such as
+	// assets/icon.ico. Empty answers 404.
+	Favicon string `mapstructure:"favicon"`
+}
+
+// Validate reports a Favicon that is not a valid object path.
+func (c SiteFilesConfig) Validate() error {
+	if c.Favicon != "" && !stowry.IsValidPath(c.Favicon) {
+		return fmt.Errorf("site files: invalid favicon path %q", c.Favicon)
+	}
+	return nil
+}
+
+// handleMissing answers a static or SPA request for path, which has no
+// object: the built-in /robots.txt or /favicon.ico, or the 404 page.
+func (h *Handler) handleMissing(w http.ResponseWriter, r *http.Request, path string) {
+	switch strings.TrimSuffix(path, "/") {
+	case "robots.txt":
+		h.serveRobotsTxt(w, r)
+	case "favicon.ico":
+		h.serveFavicon(w, r)
+	default:
+		h.handleNotFound(w, r)
+	}
+}
+
+// serveRobotsTxt serves the built-in robots.txt. It is revalidated on every
+// request, so an uploaded robots.txt takes over at once.
+func (h *Handler) serveRobotsTxt(w http.ResponseWriter, r *http.Request) {
+	body := h.config.SiteFiles.RobotsTxt
+	if body == "" {
+		body = DefaultRobotsTxt
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	if r.Method != http.MethodHead {
+		_, _ = io.WriteString(w, body)
+	}
+}
+
+// serveFavicon serves the configured favicon object, or an empty 404 that
+// browsers do not cache, so a favicon uploaded later is picked up.
+func (h *Handler) serveFavicon(w http.ResponseWriter, r *http.Request) {
+	if name := h.config.SiteFiles.Favicon; name != "" {
+		obj, content, err := h.service.Get(r.Context(), name)
+		// In SPA mode a missing favicon resolves to the index
+		if err == nil && obj.Path == name {
+			defer func() { _ = content.Close() }()
+			w.Header().Set("ETag", `"`+obj.Etag+`"`)
+			w.Header().Set("Content-Type", obj.ContentType)
+			h.setCacheControl(w, obj)
+			h.serveDownload(w, r, obj.Path, obj, content)
+			return
+		}
+		if err == nil {
+			_ = content.Close()
+		} else if !errors.Is(err, stowry.ErrNotFound) {
+			h.logger.Warn("favicon unavailable", "path", name, "error", err)
+		}
+	}
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusNotFound)
+}
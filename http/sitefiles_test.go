@@ -0,0 +1,140 @@
+package http_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/sagarc03/stowry"
+	stowryhttp "github.com/sagarc03/stowry/http"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHandler_SiteFiles(t *testing.T) {
+	index := stowry.MetaData{Path: "index.html", ContentType: "text/html", Etag: "idx"}
+	indexBody := func() readSeekNopCloser { return readSeekNopCloser{strings.NewReader("<html>app</html>")} }
+
+	serve := func(t *testing.T, config *stowryhttp.HandlerConfig, service stowryhttp.Service, method, target string) *httptest.ResponseRecorder {
+		t.Helper()
+		h, err := stowryhttp.New(config, service)
+		require.NoError(t, err)
+		rec := httptest.NewRecorder()
+		h.Router().ServeHTTP(rec, httptest.NewRequest(method, target, http.NoBody))
+		return rec
+	}
+	spa := func() *stowryhttp.HandlerConfig {
+		return &stowryhttp.HandlerConfig{Mode: stowry.ModeSPA, SPA: stowryhttp.SPAConfig{NoFallbackPaths: stowryhttp.DefaultSPANoFallbackPaths}}
+	}
+
+	t.Run("an uploaded robots.txt takes precedence", func(t *testing.T) {
+		service := new(MockService)
+		service.On("Get", mock.Anything, "robots.txt").Return(
+			stowry.MetaData{Path: "robots.txt", ContentType: "text/plain", Etag: "r"},
+			readSeekNopCloser{strings.NewReader("User-agent: *\nDisallow: /\n")}, nil)
+
+		rec := serve(t, spa(), service, http.MethodGet, "/robots.txt")
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "User-agent: *\nDisallow: /\n", rec.Body.String())
+	})
+
+	t.Run("spa serves the built-in robots.txt instead of the index", func(t *testing.T) {
+		service := new(MockService)
+		service.On("Get", mock.Anything, "robots.txt").Return(index, indexBody(), nil)
+
+		rec := serve(t, spa(), service, http.MethodGet, "/robots.txt")
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "text/plain; charset=utf-8", rec.Header().Get("Content-Type"))
+		assert.Equal(t, "no-cache", rec.Header().Get("Cache-Control"))
+		assert.Equal(t, stowryhttp.DefaultRobotsTxt, rec.Body.String())
+	})
+
+	t.Run("configured robots.txt", func(t *testing.T) {
+		service := new(MockService)
+		service.On("Info", mock.Anything, "robots.txt").Return(stowry.MetaData{}, stowry.ErrNotFound)
+		config := &stowryhttp.HandlerConfig{Mode: stowry.ModeStatic, SiteFiles: stowryhttp.SiteFilesConfig{RobotsTxt: "User-agent: *\nDisallow: /admin/\n"}}
+
+		rec := serve(t, config, service, http.MethodHead, "/robots.txt")
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "32", rec.Header().Get("Content-Length"))
+		assert.Empty(t, rec.Body.String())
+	})
+
+	t.Run("missing favicon is an uncached 404", func(t *testing.T) {
+		service := new(MockService)
+		service.On("Get", mock.Anything, "favicon.ico").Return(index, indexBody(), nil)
+
+		rec := serve(t, spa(), service, http.MethodGet, "/favicon.ico")
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+		assert.Equal(t, "no-cache", rec.Header().Get("Cache-Control"))
+		assert.Empty(t, rec.Body.String())
+	})
+
+	t.Run("configured favicon", func(t *testing.T) {
+		service := new(MockService)
+		service.On("Get", mock.Anything, "favicon.ico").Return(stowry.MetaData{}, nil, stowry.ErrNotFound)
+		service.On("Get", mock.Anything, "assets/icon.ico").Return(
+			stowry.MetaData{Path: "assets/icon.ico", ContentType: "image/x-icon", Etag: "ico"},
+			readSeekNopCloser{strings.NewReader("icon")}, nil)
+		config := &stowryhttp.HandlerConfig{Mode: stowry.ModeStatic, SiteFiles: stowryhttp.SiteFilesConfig{Favicon: "assets/icon.ico"}}
+
+		rec := serve(t, config, service, http.MethodGet, "/favicon.ico")
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "image/x-icon", rec.Header().Get("Content-Type"))
+		assert.Equal(t, "icon", rec.Body.String())
+	})
+
+	t.Run("excluded paths are a real 404 in spa mode", func(t *testing.T) {
+		service := new(MockService)
+		service.On("Get", mock.Anything, "sitemap.xml").Return(index, indexBody(), nil)
+		service.On("Get", mock.Anything, ".well-known/security.txt").Return(index, indexBody(), nil)
+
+		for _, target := range []string{"/sitemap.xml", "/.well-known/security.txt"} {
+			rec := serve(t, spa(), service, http.MethodGet, target)
+			assert.Equal(t, http.StatusNotFound, rec.Code, target)
+			assert.NotContains(t, rec.Body.String(), "app", target)
+		}
+	})
+
+	t.Run("excluded paths skip the deploying placeholder", func(t *testing.T) {
+		service := new(MockService)
+		service.On("Info", mock.Anything, "sitemap.xml").Return(stowry.MetaData{}, stowry.ErrNotFound)
+		config := spa()
+		config.SPA.MissingIndex = stowryhttp.MissingIndexWait
+
+		rec := serve(t, config, service, http.MethodHead, "/sitemap.xml")
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+		service.AssertNumberOfCalls(t, "Info", 1)
+	})
+
+	t.Run("other routes still fall back", func(t *testing.T) {
+		service := new(MockService)
+		service.On("Get", mock.Anything, "dashboard").Return(index, indexBody(), nil)
+
+		rec := serve(t, spa(), service, http.MethodGet, "/dashboard")
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "<html>app</html>", rec.Body.String())
+	})
+
+	t.Run("without exclusions robots.txt falls back", func(t *testing.T) {
+		service := new(MockService)
+		service.On("Get", mock.Anything, "robots.txt").Return(index, indexBody(), nil)
+
+		rec := serve(t, &stowryhttp.HandlerConfig{Mode: stowry.ModeSPA}, service, http.MethodGet, "/robots.txt")
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "<html>app</html>", rec.Body.String())
+	})
+
+	t.Run("invalid configuration", func(t *testing.T) {
+		_, err := stowryhttp.New(&stowryhttp.HandlerConfig{
+			Mode:      stowry.ModeSPA,
+			SPA:       stowryhttp.SPAConfig{NoFallbackPaths: []string{"/*"}},
+			SiteFiles: stowryhttp.SiteFilesConfig{Favicon: "../icon.ico"},
+		}, new(MockService))
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), `invalid no fallback path "/*"`)
+		assert.Contains(t, err.Error(), `invalid favicon path "../icon.ico"`)
+	})
+}
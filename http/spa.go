@@ -0,0 +1,173 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sagarc03/stowry"
+)
+
+// Behaviors for an SPA request while index.html does not exist, such as
+// during the first deploy.
+const (
+	// MissingIndexError serves the usual 404 page.
+	MissingIndexError = "error"
+	// MissingIndexPlaceholder serves a minimal "deploying" page with 503.
+	MissingIndexPlaceholder = "placeholder"
+	// MissingIndexWait holds the request until index.html appears or
+	// SPAConfig.Wait passes, then serves the placeholder.
+	MissingIndexWait = "wait"
+)
+
+// DefaultSPAWait is how long MissingIndexWait holds a request when
+// SPAConfig.Wait is not set, in seconds.
+const DefaultSPAWait = 10
+
+const (
+	// spaIndexPollInterval is how often a held request looks for index.html.
+	spaIndexPollInterval = 250 * time.Millisecond
+	// spaPlaceholderRetryAfter is the Retry-After of the placeholder, in seconds.
+	spaPlaceholderRetryAfter = 5
+)
+
+// SPAConfig configures SPA mode.
+type SPAConfig struct {
+	// Index is the object every unknown route falls back to, such as
+	// app.html. The service resolves it, so it takes effect through
+	// stowry.ServiceConfig.SPAIndex. Empty means stowry.SPAIndexPath.
+	Index string `mapstructure:"index"`
+	// MissingIndex is what a request gets while index.html does not exist:
+	// MissingIndexError (the default), MissingIndexPlaceholder or
+	// MissingIndexWait.
+	MissingIndex string `mapstructure:"missing_index"`
+	// Wait is how long MissingIndexWait holds a request. 0 uses
+	// DefaultSPAWait.
+	Wait int `mapstructure:"wait"` // seconds
+	// NoFallbackPaths are paths that never fall back to the index, so a
+	// missing /robots.txt is a 404 rather than the app's HTML. An entry
+	// ending in /* matches everything under it, such as /.well-known/*. The
+	// leading / is optional. Objects at these paths are still served.
+	NoFallbackPaths []string `mapstructure:"no_fallback_paths"`
+}
+
+// DefaultSPANoFallbackPaths are the paths crawlers and browsers request on
+// their own, which SPA mode serves as 404s instead of the index by default.
+var DefaultSPANoFallbackPaths = []string{"/robots.txt", "/favicon.ico", "/sitemap.xml", "/.well-known/*"}
+
+// Validate reports an Index that is not a plain file name, an unknown
+// MissingIndex or a negative Wait.
+func (c SPAConfig) Validate() error {
+	if c.Index != "" {
+		if err := stowry.ValidateIndexFile(c.Index); err != nil {
+			return fmt.Errorf("spa: index: %w", err)
+		}
+	}
+	switch c.MissingIndex {
+	case "", MissingIndexError, MissingIndexPlaceholder, MissingIndexWait:
+	default:
+		return fmt.Errorf("spa: invalid missing index behavior %q (valid: %s, %s, %s)", c.MissingIndex, MissingIndexError, MissingIndexPlaceholder, MissingIndexWait)
+	}
+	if c.Wait < 0 {
+		return errors.New("spa: wait must not be negative")
+	}
+	for _, p := range c.NoFallbackPaths {
+		name := strings.TrimSuffix(strings.TrimPrefix(p, "/"), "/*")
+		if name == "" || strings.Contains(name, "*") || !stowry.IsValidPath(name) {
+			return fmt.Errorf("spa: invalid no fallback path %q", p)
+		}
+	}
+	return nil
+}
+
+// noFallback reports whether path is one of SPAConfig.NoFallbackPaths in
+// SPA mode.
+func (h *Handler) noFallback(path string) bool {
+	if h.config.Mode != stowry.ModeSPA || path == "" {
+		return false
+	}
+	path = strings.TrimSuffix(path, "/")
+	for _, p := range h.config.SPA.NoFallbackPaths {
+		p = strings.TrimPrefix(p, "/")
+		if prefix, ok := strings.CutSuffix(p, "*"); ok {
+			if strings.HasPrefix(path, prefix) {
+				return true
+			}
+		} else if path == p {
+			return true
+		}
+	}
+	return false
+}
+
+// indexMissing reports whether err from an SPA lookup means index.html does
+// not exist and the request gets more than the usual 404. Every unknown
+// route falls back to index.html, so ErrNotFound only happens without it.
+func (h *Handler) indexMissing(err error) bool {
+	return h.config.Mode == stowry.ModeSPA &&
+		h.config.SPA.MissingIndex != "" && h.config.SPA.MissingIndex != MissingIndexError &&
+		errors.Is(err, stowry.ErrNotFound)
+}
+
+// awaitIndex retries lookup while index.html is missing and MissingIndex is
+// MissingIndexWait, until lookup stops failing with ErrNotFound, the wait
+// passes or the client goes away. It returns the last lookup error.
+func (h *Handler) awaitIndex(ctx context.Context, err error, lookup func() error) error {
+	if !h.indexMissing(err) || h.config.SPA.MissingIndex != MissingIndexWait {
+		return err
+	}
+
+	wait := time.Duration(h.config.SPA.Wait) * time.Second
+	if wait <= 0 {
+		wait = DefaultSPAWait * time.Second
+	}
+	deadline := time.NewTimer(wait)
+	defer deadline.Stop()
+	ticker := time.NewTicker(spaIndexPollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return err
+		case <-deadline.C:
+			return err
+		case <-ticker.C:
+		}
+		if err = lookup(); !errors.Is(err, stowry.ErrNotFound) {
+			return err
+		}
+	}
+}
+
+// spaPlaceholderHTML is served while an SPA has no index.html yet. It reloads
+// itself, so visitors land on the app once the deploy is done.
+const spaPlaceholderHTML = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<meta http-equiv="refresh" content="5">
+<title>Deploying</title>
+</head>
+<body>
+<center><h1>Deploying</h1><p>This site is being deployed. The page reloads when it is ready.</p></center>
+<hr><center>stowry</center>
+</body>
+</html>`
+
+// writeSPAPlaceholder serves the placeholder page with 503 and Retry-After,
+// marked uncacheable so caches do not keep it once the deploy lands.
+func writeSPAPlaceholder(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Retry-After", strconv.Itoa(spaPlaceholderRetryAfter))
+	w.WriteHeader(http.StatusServiceUnavailable)
+	if r.Method != http.MethodHead {
+		_, _ = io.WriteString(w, spaPlaceholderHTML)
+	}
+}
@@ -0,0 +1,95 @@
+package http_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sagarc03/stowry"
+	stowryhttp "github.com/sagarc03/stowry/http"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestHandler_SPAMissingIndex(t *testing.T) {
+	missing := func() *MockService {
+		service := new(MockService)
+		service.On("Get", mock.Anything, "dashboard").Return(stowry.MetaData{}, nil, stowry.ErrNotFound)
+		service.On("Info", mock.Anything, "dashboard").Return(stowry.MetaData{}, stowry.ErrNotFound)
+		return service
+	}
+
+	t.Run("error serves the 404 page", func(t *testing.T) {
+		config := &stowryhttp.HandlerConfig{Mode: stowry.ModeSPA, SPA: stowryhttp.SPAConfig{MissingIndex: stowryhttp.MissingIndexError}}
+		rec := httptest.NewRecorder()
+		stowryhttp.NewHandler(config, missing()).Router().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/dashboard", nil))
+
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+	})
+
+	t.Run("placeholder", func(t *testing.T) {
+		config := &stowryhttp.HandlerConfig{Mode: stowry.ModeSPA, SPA: stowryhttp.SPAConfig{MissingIndex: stowryhttp.MissingIndexPlaceholder}}
+		handler := stowryhttp.NewHandler(config, missing()).Router()
+
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/dashboard", nil))
+
+		assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+		assert.Equal(t, "5", rec.Header().Get("Retry-After"))
+		assert.Equal(t, "no-store", rec.Header().Get("Cache-Control"))
+		assert.Equal(t, "text/html; charset=utf-8", rec.Header().Get("Content-Type"))
+		assert.Contains(t, rec.Body.String(), "Deploying")
+
+		rec = httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodHead, "/dashboard", nil))
+
+		assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+		assert.Empty(t, rec.Body.String())
+	})
+
+	t.Run("store mode is unaffected", func(t *testing.T) {
+		config := &stowryhttp.HandlerConfig{Mode: stowry.ModeStore, SPA: stowryhttp.SPAConfig{MissingIndex: stowryhttp.MissingIndexPlaceholder}}
+		rec := httptest.NewRecorder()
+		stowryhttp.NewHandler(config, missing()).Router().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/dashboard", nil))
+
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+		assert.Contains(t, rec.Body.String(), "not_found")
+	})
+
+	t.Run("wait serves index.html once it appears", func(t *testing.T) {
+		service := new(MockService)
+		service.On("Get", mock.Anything, "dashboard").Return(stowry.MetaData{}, nil, stowry.ErrNotFound).Twice()
+		service.On("Get", mock.Anything, "dashboard").Return(
+			stowry.MetaData{Path: "index.html", ContentType: "text/html", Etag: "idx"},
+			readSeekNopCloser{strings.NewReader("<html>app</html>")},
+			nil,
+		).Once()
+
+		config := &stowryhttp.HandlerConfig{Mode: stowry.ModeSPA, SPA: stowryhttp.SPAConfig{MissingIndex: stowryhttp.MissingIndexWait, Wait: 5}}
+		rec := httptest.NewRecorder()
+		stowryhttp.NewHandler(config, service).Router().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/dashboard", nil))
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "<html>app</html>", rec.Body.String())
+		service.AssertExpectations(t)
+	})
+
+	t.Run("wait falls back to the placeholder", func(t *testing.T) {
+		config := &stowryhttp.HandlerConfig{Mode: stowry.ModeSPA, SPA: stowryhttp.SPAConfig{MissingIndex: stowryhttp.MissingIndexWait, Wait: 1}}
+		rec := httptest.NewRecorder()
+		start := time.Now()
+		stowryhttp.NewHandler(config, missing()).Router().ServeHTTP(rec, httptest.NewRequest(http.MethodHead, "/dashboard", nil))
+
+		assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+		assert.GreaterOrEqual(t, time.Since(start), time.Second)
+	})
+}
+
+func TestSPAConfig_Validate(t *testing.T) {
+	assert.NoError(t, stowryhttp.SPAConfig{}.Validate())
+	assert.NoError(t, stowryhttp.SPAConfig{MissingIndex: stowryhttp.MissingIndexWait, Wait: 30}.Validate())
+	assert.ErrorContains(t, stowryhttp.SPAConfig{MissingIndex: "retry"}.Validate(), "invalid missing index behavior")
+	assert.ErrorContains(t, stowryhttp.SPAConfig{Wait: -1}.Validate(), "wait must not be negative")
+}
@@ -0,0 +1,132 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/sagarc03/stowry"
+)
+
+// StageHeader names the stage a PUT uploads into. The object is staged under
+// its path and only becomes visible when the stage is committed.
+const StageHeader = "X-Stowry-Stage"
+
+// StageService is an optional Service extension for atomic multi-object
+// uploads. When the service implements it, store mode serves:
+//
+//	POST   /stage              open a stage, answered with a stowry.Stage
+//	PUT    /{path}             with StageHeader, upload into the stage
+//	POST   /stage/{id}/commit  promote every staged object at once
+//	DELETE /stage/{id}         discard the stage
+type StageService interface {
+	CreateStage(ctx context.Context) (stowry.Stage, error)
+	StageObject(ctx context.Context, id uuid.UUID, obj stowry.CreateObject, content io.Reader) (stowry.ObjectEntry, error)
+	CommitStage(ctx context.Context, id uuid.UUID) ([]stowry.MetaData, error)
+	DiscardStage(ctx context.Context, id uuid.UUID) error
+}
+
+// StageCommitResponse is the response to a successful stage commit.
+type StageCommitResponse struct {
+	ID      uuid.UUID         `json:"id"`
+	Objects []stowry.MetaData `json:"objects"`
+}
+
+// stageService returns the service as a StageService if staging is
+// available in the configured mode.
+func (h *Handler) stageService() (StageService, bool) {
+	if h.config.Mode != stowry.ModeStore {
+		return nil, false
+	}
+	ss, ok := h.service.(StageService)
+	return ss, ok
+}
+
+func (h *Handler) handleCreateStage(w http.ResponseWriter, r *http.Request) {
+	// Only routed when stageService is available
+	ss, _ := h.stageService()
+
+	stage, err := ss.CreateStage(r.Context())
+	if err != nil {
+		h.handleError(w, r, err)
+		return
+	}
+
+	_ = WriteJSON(w, http.StatusCreated, stage)
+}
+
+func (h *Handler) handleCommitStage(w http.ResponseWriter, r *http.Request) {
+	ss, _ := h.stageService()
+
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		h.writeStageNotFound(w, r)
+		return
+	}
+
+	objects, err := ss.CommitStage(r.Context(), id)
+	if err != nil {
+		if errors.Is(err, stowry.ErrNotFound) {
+			h.writeStageNotFound(w, r)
+			return
+		}
+		h.handleError(w, r, err)
+		return
+	}
+
+	for i := range objects {
+		objects[i].StorageBackend = ""
+	}
+	_ = WriteJSON(w, http.StatusOK, StageCommitResponse{ID: id, Objects: objects})
+}
+
+// handleDiscardStage discards a stage. Paths that do not name a stage are
+// deleted as objects, so objects under stage/ stay reachable.
+func (h *Handler) handleDiscardStage(w http.ResponseWriter, r *http.Request) {
+	ss, _ := h.stageService()
+
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		h.handleDelete(w, r)
+		return
+	}
+
+	err = ss.DiscardStage(r.Context(), id)
+	if errors.Is(err, stowry.ErrNotFound) {
+		h.handleDelete(w, r)
+		return
+	}
+	if err != nil {
+		h.handleError(w, r, err)
+		return
+	}
+
+	writeNoContent(w)
+}
+
+// stageObject uploads obj into stage id and describes the staged object as
+// metadata without an ID.
+func (h *Handler) stageObject(r *http.Request, ss StageService, id uuid.UUID, obj stowry.CreateObject, body io.Reader) (stowry.MetaData, error) {
+	entry, err := ss.StageObject(r.Context(), id, obj, body)
+	if err != nil {
+		return stowry.MetaData{}, err
+	}
+	return stowry.MetaData{
+		Path:             entry.Path,
+		ContentType:      entry.ContentType,
+		ContentEncoding:  entry.ContentEncoding,
+		Etag:             entry.ETag,
+		FileSizeBytes:    entry.Size,
+		DecodedSizeBytes: entry.DecodedSize,
+		Filename:         entry.Filename,
+		UserMetadata:     entry.UserMetadata,
+		CacheControl:     entry.CacheControl,
+	}, nil
+}
+
+func (h *Handler) writeStageNotFound(w http.ResponseWriter, r *http.Request) {
+	h.writeError(w, r, http.StatusNotFound, "stage_not_found", "Stage not found")
+}
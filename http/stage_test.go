@@ -0,0 +1,185 @@
+package http_test
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sagarc03/stowry"
+	stowryhttp "github.com/sagarc03/stowry/http"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// MockStageService is a MockService that also implements http.StageService.
+type MockStageService struct {
+	MockService
+}
+
+func (m *MockStageService) CreateStage(ctx context.Context) (stowry.Stage, error) {
+	args := m.Called(ctx)
+	return args.Get(0).(stowry.Stage), args.Error(1)
+}
+
+func (m *MockStageService) StageObject(ctx context.Context, id uuid.UUID, obj stowry.CreateObject, content io.Reader) (stowry.ObjectEntry, error) {
+	args := m.Called(ctx, id, obj, content)
+	return args.Get(0).(stowry.ObjectEntry), args.Error(1)
+}
+
+func (m *MockStageService) CommitStage(ctx context.Context, id uuid.UUID) ([]stowry.MetaData, error) {
+	args := m.Called(ctx, id)
+	return args.Get(0).([]stowry.MetaData), args.Error(1)
+}
+
+func (m *MockStageService) DiscardStage(ctx context.Context, id uuid.UUID) error {
+	args := m.Called(ctx, id)
+	return args.Error(0)
+}
+
+func newStageRouter(t *testing.T, service stowryhttp.Service, mode stowry.ServerMode) http.Handler {
+	t.Helper()
+	h, err := stowryhttp.New(&stowryhttp.HandlerConfig{Mode: mode}, service)
+	require.NoError(t, err)
+	return h.Router()
+}
+
+func TestHandler_Stage(t *testing.T) {
+	id := uuid.MustParse("0b9a6c1e-5f1d-4c1b-8f0e-2d9f4a7c3b21")
+	target := "/stage/" + id.String()
+
+	t.Run("create", func(t *testing.T) {
+		service := new(MockStageService)
+		created := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+		service.On("CreateStage", mock.Anything).Return(stowry.Stage{ID: id, CreatedAt: created}, nil)
+
+		rec := httptest.NewRecorder()
+		newStageRouter(t, service, stowry.ModeStore).ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/stage", nil))
+
+		assert.Equal(t, http.StatusCreated, rec.Code)
+		var stage stowry.Stage
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &stage))
+		assert.Equal(t, id, stage.ID)
+		assert.Equal(t, created, stage.CreatedAt)
+	})
+
+	t.Run("put into stage", func(t *testing.T) {
+		service := new(MockStageService)
+		obj := stowry.CreateObject{Path: "site/index.html", ContentType: "text/html", Size: 5}
+		service.On("StageObject", mock.Anything, id, obj, mock.Anything).
+			Return(stowry.ObjectEntry{Path: "site/index.html", ContentType: "text/html", ETag: "abc", Size: 5}, nil)
+
+		req := httptest.NewRequest(http.MethodPut, "/site/index.html", strings.NewReader("hello"))
+		req.Header.Set("Content-Type", "text/html")
+		req.Header.Set(stowryhttp.StageHeader, id.String())
+		rec := httptest.NewRecorder()
+		newStageRouter(t, service, stowry.ModeStore).ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "abc", rec.Header().Get(stowryhttp.UploadETagHeader))
+		assert.Equal(t, "5", rec.Header().Get(stowryhttp.UploadBytesWrittenHeader))
+		service.AssertNotCalled(t, "Create", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("put into unknown stage", func(t *testing.T) {
+		service := new(MockStageService)
+		service.On("StageObject", mock.Anything, id, mock.Anything, mock.Anything).Return(stowry.ObjectEntry{}, stowry.ErrNotFound)
+
+		for _, header := range []string{id.String(), "not-a-uuid"} {
+			req := httptest.NewRequest(http.MethodPut, "/a.txt", strings.NewReader("x"))
+			req.Header.Set(stowryhttp.StageHeader, header)
+			rec := httptest.NewRecorder()
+			newStageRouter(t, service, stowry.ModeStore).ServeHTTP(rec, req)
+
+			assert.Equal(t, http.StatusNotFound, rec.Code, header)
+			assert.Contains(t, rec.Body.String(), "stage_not_found", header)
+		}
+	})
+
+	t.Run("commit", func(t *testing.T) {
+		service := new(MockStageService)
+		objects := []stowry.MetaData{{Path: "a.txt", Etag: "e1"}, {Path: "b.txt", Etag: "e2"}}
+		service.On("CommitStage", mock.Anything, id).Return(objects, nil)
+
+		rec := httptest.NewRecorder()
+		newStageRouter(t, service, stowry.ModeStore).ServeHTTP(rec, httptest.NewRequest(http.MethodPost, target+"/commit", nil))
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		var resp stowryhttp.StageCommitResponse
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+		assert.Equal(t, id, resp.ID)
+		assert.Len(t, resp.Objects, 2)
+	})
+
+	t.Run("commit unknown stage", func(t *testing.T) {
+		service := new(MockStageService)
+		service.On("CommitStage", mock.Anything, id).Return([]stowry.MetaData(nil), stowry.ErrNotFound)
+
+		rec := httptest.NewRecorder()
+		newStageRouter(t, service, stowry.ModeStore).ServeHTTP(rec, httptest.NewRequest(http.MethodPost, target+"/commit", nil))
+
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+		assert.Contains(t, rec.Body.String(), "stage_not_found")
+	})
+
+	t.Run("discard", func(t *testing.T) {
+		service := new(MockStageService)
+		service.On("DiscardStage", mock.Anything, id).Return(nil)
+
+		rec := httptest.NewRecorder()
+		newStageRouter(t, service, stowry.ModeStore).ServeHTTP(rec, httptest.NewRequest(http.MethodDelete, target, nil))
+
+		assert.Equal(t, http.StatusNoContent, rec.Code)
+		service.AssertNotCalled(t, "Delete", mock.Anything, mock.Anything)
+	})
+
+	t.Run("delete of a non-stage path deletes the object", func(t *testing.T) {
+		service := new(MockStageService)
+		service.On("DiscardStage", mock.Anything, id).Return(stowry.ErrNotFound)
+		service.On("Delete", mock.Anything, "stage/"+id.String()).Return(nil)
+		service.On("Delete", mock.Anything, "stage/notes.txt").Return(nil)
+
+		for _, path := range []string{target, "/stage/notes.txt"} {
+			rec := httptest.NewRecorder()
+			newStageRouter(t, service, stowry.ModeStore).ServeHTTP(rec, httptest.NewRequest(http.MethodDelete, path, nil))
+			assert.Equal(t, http.StatusNoContent, rec.Code, path)
+		}
+		service.AssertExpectations(t)
+	})
+
+	t.Run("objects named stage stay reachable", func(t *testing.T) {
+		service := new(MockStageService)
+		service.On("Get", mock.Anything, "stage").Return(stowry.MetaData{Path: "stage", ContentType: "text/plain"}, readSeekNopCloser{strings.NewReader("x")}, nil)
+
+		rec := httptest.NewRecorder()
+		newStageRouter(t, service, stowry.ModeStore).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/stage", nil))
+		assert.Equal(t, http.StatusOK, rec.Code)
+	})
+
+	t.Run("not routed without stage service", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		newStageRouter(t, new(MockService), stowry.ModeStore).ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/stage", nil))
+		assert.Equal(t, http.StatusMethodNotAllowed, rec.Code)
+
+		req := httptest.NewRequest(http.MethodPut, "/a.txt", strings.NewReader("x"))
+		req.Header.Set(stowryhttp.StageHeader, id.String())
+		rec = httptest.NewRecorder()
+		newStageRouter(t, new(MockService), stowry.ModeStore).ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusNotImplemented, rec.Code)
+	})
+
+	t.Run("service without staging support", func(t *testing.T) {
+		service := new(MockStageService)
+		service.On("CreateStage", mock.Anything).Return(stowry.Stage{}, stowry.ErrNotSupported)
+
+		rec := httptest.NewRecorder()
+		newStageRouter(t, service, stowry.ModeStore).ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/stage", nil))
+		assert.Equal(t, http.StatusNotImplemented, rec.Code)
+	})
+}
@@ -0,0 +1,109 @@
+package http
+
+import (
+	"net/http"
+	"strconv"
+	"sync/atomic"
+
+	"github.com/sagarc03/stowry/progress"
+)
+
+// Paths the StartupGate answers itself, before and after it opens.
+const (
+	HealthPath = "/healthz" // 200 while the process is alive
+	ReadyPath  = "/readyz"  // 200 once the gate is open, 503 before
+)
+
+// Startup tasks a StartupGate reports while it is closed.
+const (
+	StartupStarting   = "starting"   // Connecting and configuring
+	StartupMigrating  = "migrating"  // Applying database migrations
+	StartupPopulating = "populating" // Recording storage files in the metadata
+)
+
+// startupRetryAfter is the Retry-After of a request held off by a closed
+// StartupGate, in seconds.
+const startupRetryAfter = 5
+
+// MaintenanceResponse is the body of a 503 served while startup tasks run.
+type MaintenanceResponse struct {
+	ErrorResponse
+	// Task is the startup task running, such as StartupPopulating
+	Task string `json:"task"`
+	// Progress is the last progress of Task; nil when it reports none
+	Progress *progress.Event `json:"progress,omitempty"`
+}
+
+// StartupGate lets the listener come up before startup tasks, such as
+// migrations and populating the metadata, have finished, without answering
+// from a database that is not ready. Until Open, every request gets 503
+// with a MaintenanceResponse and Retry-After, except HealthPath, which
+// reports the process alive so health checks do not restart it. ReadyPath
+// answers 503 until Open and 200 after.
+//
+// It is safe for concurrent use.
+type StartupGate struct {
+	next     atomic.Pointer[http.Handler]
+	task     atomic.Pointer[string]
+	progress atomic.Pointer[progress.Event]
+}
+
+// NewStartupGate returns a closed gate running StartupStarting.
+func NewStartupGate() *StartupGate {
+	g := &StartupGate{}
+	g.SetTask(StartupStarting)
+	return g
+}
+
+// SetTask records the startup task now running and clears the progress of
+// the previous one.
+func (g *StartupGate) SetTask(task string) {
+	g.task.Store(&task)
+	g.progress.Store(nil)
+}
+
+// Progress records the progress of the running task. It is a progress.Func.
+func (g *StartupGate) Progress(e progress.Event) {
+	g.progress.Store(&e)
+}
+
+// Open hands every later request, other than HealthPath and ReadyPath, to
+// next. Opening an open gate replaces next.
+func (g *StartupGate) Open(next http.Handler) {
+	g.next.Store(&next)
+}
+
+// IsOpen reports whether Open has been called.
+func (g *StartupGate) IsOpen() bool {
+	return g.next.Load() != nil
+}
+
+func (g *StartupGate) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	next := g.next.Load()
+
+	switch r.URL.Path {
+	case HealthPath:
+		w.Header().Set("Cache-Control", "no-store")
+		_ = WriteJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+		return
+	case ReadyPath:
+		if next != nil {
+			w.Header().Set("Cache-Control", "no-store")
+			_ = WriteJSON(w, http.StatusOK, map[string]string{"status": "ready"})
+			return
+		}
+	default:
+		if next != nil {
+			(*next).ServeHTTP(w, r)
+			return
+		}
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Retry-After", strconv.Itoa(startupRetryAfter))
+	_ = WriteJSON(w, http.StatusServiceUnavailable, MaintenanceResponse{
+		ErrorResponse: ErrorResponse{Error: "starting_up", Message: "The server is starting up; retry shortly"},
+		Task:          *g.task.Load(),
+		Progress:      g.progress.Load(),
+	})
+}
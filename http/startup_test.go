@@ -0,0 +1,142 @@
+package http_test
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/sagarc03/stowry"
+	stowryhttp "github.com/sagarc03/stowry/http"
+	"github.com/sagarc03/stowry/progress"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStartupGate(t *testing.T) {
+	serve := func(h http.Handler, target string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, target, nil))
+		return rec
+	}
+
+	t.Run("closed", func(t *testing.T) {
+		gate := stowryhttp.NewStartupGate()
+		gate.SetTask(stowryhttp.StartupPopulating)
+		gate.Progress(progress.Event{Operation: "populate", Processed: 40, Total: 100})
+
+		rec := serve(gate, "/docs/a.txt")
+		assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+		assert.Equal(t, "5", rec.Header().Get("Retry-After"))
+		assert.Equal(t, "no-store", rec.Header().Get("Cache-Control"))
+
+		var body stowryhttp.MaintenanceResponse
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+		assert.Equal(t, "starting_up", body.Error)
+		assert.Equal(t, stowryhttp.StartupPopulating, body.Task)
+		require.NotNil(t, body.Progress)
+		assert.Equal(t, int64(40), body.Progress.Processed)
+		assert.Equal(t, int64(100), body.Progress.Total)
+
+		assert.Equal(t, http.StatusOK, serve(gate, stowryhttp.HealthPath).Code)
+		assert.Equal(t, http.StatusServiceUnavailable, serve(gate, stowryhttp.ReadyPath).Code)
+		assert.False(t, gate.IsOpen())
+	})
+
+	t.Run("a new task clears the progress", func(t *testing.T) {
+		gate := stowryhttp.NewStartupGate()
+		gate.Progress(progress.Event{Processed: 1})
+		gate.SetTask(stowryhttp.StartupMigrating)
+
+		var body stowryhttp.MaintenanceResponse
+		require.NoError(t, json.Unmarshal(serve(gate, "/").Body.Bytes(), &body))
+		assert.Equal(t, stowryhttp.StartupMigrating, body.Task)
+		assert.Nil(t, body.Progress)
+	})
+
+	t.Run("open", func(t *testing.T) {
+		gate := stowryhttp.NewStartupGate()
+		gate.Open(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusTeapot)
+		}))
+
+		assert.True(t, gate.IsOpen())
+		assert.Equal(t, http.StatusTeapot, serve(gate, "/docs/a.txt").Code)
+		assert.Equal(t, http.StatusOK, serve(gate, stowryhttp.HealthPath).Code)
+		assert.Equal(t, http.StatusOK, serve(gate, stowryhttp.ReadyPath).Code)
+	})
+}
+
+// populatingService finds docs/a.txt only once populated is set, as a
+// service over metadata that Populate is still filling.
+type populatingService struct {
+	MockService
+	populated atomic.Bool
+	early     atomic.Int64 // lookups before populated
+}
+
+func (s *populatingService) Get(ctx context.Context, path string) (stowry.MetaData, io.ReadSeekCloser, error) {
+	if !s.populated.Load() || path != "docs/a.txt" {
+		s.early.Add(1)
+		return stowry.MetaData{}, nil, stowry.ErrNotFound
+	}
+	return stowry.MetaData{Path: path, ContentType: "text/plain", Etag: "e", FileSizeBytes: 5},
+		readSeekNopCloser{strings.NewReader("hello")}, nil
+}
+
+// TestStartupGate_SlowPopulate holds a populate open and checks that an
+// object storage already holds is never answered 404 while the metadata is
+// still being filled.
+func TestStartupGate_SlowPopulate(t *testing.T) {
+	service := &populatingService{}
+	handler, err := stowryhttp.New(&stowryhttp.HandlerConfig{Mode: stowry.ModeStore}, service)
+	require.NoError(t, err)
+	gate := stowryhttp.NewStartupGate()
+	server := httptest.NewServer(gate)
+	defer server.Close()
+
+	get := func(path string) (int, string) {
+		resp, err := http.Get(server.URL + path)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		return resp.StatusCode, string(body)
+	}
+
+	gate.SetTask(stowryhttp.StartupPopulating)
+	step := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := range int64(3) {
+			<-step
+			gate.Progress(progress.Event{Operation: "populate", Processed: i + 1, Total: 3})
+		}
+		service.populated.Store(true)
+		gate.Open(handler.Router())
+	}()
+
+	for i := range 3 {
+		code, body := get("/docs/a.txt")
+		assert.Equal(t, http.StatusServiceUnavailable, code, "step %d", i)
+		assert.Contains(t, body, `"task":"populating"`)
+		readyCode, _ := get(stowryhttp.ReadyPath)
+		assert.Equal(t, http.StatusServiceUnavailable, readyCode)
+		healthCode, _ := get(stowryhttp.HealthPath)
+		assert.Equal(t, http.StatusOK, healthCode)
+		step <- struct{}{}
+	}
+	<-done
+
+	code, body := get("/docs/a.txt")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "hello", body)
+	readyCode, _ := get(stowryhttp.ReadyPath)
+	assert.Equal(t, http.StatusOK, readyCode)
+	assert.Zero(t, service.early.Load(), "no lookup reached the service before the gate opened")
+}
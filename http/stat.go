@@ -0,0 +1,91 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/sagarc03/stowry"
+)
+
+// maxStatRequestSize bounds the JSON path list of POST /?stat: MaxStatPaths
+// keys of the default maximum length, with room for quoting.
+const maxStatRequestSize = 2 << 20
+
+// StatService is an optional Service extension for looking up many objects at
+// once. When the service implements it, store mode serves POST /?stat with
+// read authentication, so sync tools can compare many paths without a HEAD
+// request each. *stowry.StowryService implements it.
+type StatService interface {
+	StatMany(ctx context.Context, paths []string) (map[string]stowry.MetaData, error)
+}
+
+// StatResponse is the body of POST /?stat. Objects has an entry for every
+// path requested: its metadata, or null when no object exists there.
+type StatResponse struct {
+	Objects map[string]*stowry.MetaData `json:"objects"`
+}
+
+// isStatRequest reports whether a POST / asks for object metadata.
+func isStatRequest(r *http.Request) bool {
+	return r.URL.Query().Has("stat")
+}
+
+// statService returns the service's StatService, if any.
+func (h *Handler) statService() (StatService, bool) {
+	ss, ok := h.service.(StatService)
+	return ss, ok
+}
+
+// handleStat serves POST /?stat in store mode. The body is a JSON array of up
+// to stowry.MaxStatPaths paths. ?include=backend adds each object's storage
+// backend.
+func (h *Handler) handleStat(w http.ResponseWriter, r *http.Request) {
+	if !isStatRequest(r) {
+		msg := "POST / is only supported with ?stat"
+		if _, ok := h.deleteManyService(); ok {
+			msg += " or ?delete"
+		}
+		h.writeError(w, r, http.StatusMethodNotAllowed, "method_not_allowed", msg)
+		return
+	}
+	ss, ok := h.statService()
+	if !ok {
+		h.handleError(w, r, stowry.ErrNotSupported)
+		return
+	}
+
+	var paths []string
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxStatRequestSize)).Decode(&paths); err != nil {
+		h.writeError(w, r, http.StatusBadRequest, "invalid_request", "Request body must be a JSON array of paths")
+		return
+	}
+	if len(paths) > stowry.MaxStatPaths {
+		h.writeError(w, r, http.StatusBadRequest, "too_many_paths", fmt.Sprintf("At most %d paths per request", stowry.MaxStatPaths))
+		return
+	}
+
+	found, err := ss.StatMany(r.Context(), paths)
+	if err != nil {
+		h.handleError(w, r, err)
+		return
+	}
+
+	backend := includeBackend(r)
+	resp := StatResponse{Objects: make(map[string]*stowry.MetaData, len(paths))}
+	for _, p := range paths {
+		if m, ok := found[p]; ok {
+			m = h.withPermalink(m)
+			if !backend {
+				m.StorageBackend = ""
+			}
+			resp.Objects[p] = &m
+			continue
+		}
+		resp.Objects[p] = nil
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	_ = WriteJSON(w, http.StatusOK, resp)
+}
@@ -0,0 +1,114 @@
+package http_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sagarc03/stowry"
+	stowryhttp "github.com/sagarc03/stowry/http"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// statMockService is a MockService that can look up many objects at once.
+type statMockService struct {
+	MockService
+}
+
+func (m *statMockService) StatMany(ctx context.Context, paths []string) (map[string]stowry.MetaData, error) {
+	args := m.Called(ctx, paths)
+	found, _ := args.Get(0).(map[string]stowry.MetaData)
+	return found, args.Error(1)
+}
+
+func TestHandler_Stat(t *testing.T) {
+	updated := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	serve := func(t *testing.T, service stowryhttp.Service, target, body string, authed bool) *httptest.ResponseRecorder {
+		t.Helper()
+		h, err := stowryhttp.New(&stowryhttp.HandlerConfig{Mode: stowry.ModeStore, ReadVerifier: headerVerifier{}}, service)
+		require.NoError(t, err)
+		req := httptest.NewRequest(http.MethodPost, target, strings.NewReader(body))
+		if authed {
+			req.Header.Set("X-Test-Auth", "ok")
+		}
+		rec := httptest.NewRecorder()
+		h.Router().ServeHTTP(rec, req)
+		return rec
+	}
+
+	t.Run("reports every requested path", func(t *testing.T) {
+		service := new(statMockService)
+		service.On("StatMany", mock.Anything, []string{"a.txt", "missing.txt", "/dir/b.txt"}).Return(map[string]stowry.MetaData{
+			"a.txt":      {Path: "a.txt", Etag: "ea", ContentType: "text/plain", FileSizeBytes: 3, UpdatedAt: updated},
+			"/dir/b.txt": {Path: "dir/b.txt", Etag: "eb", ContentType: "image/png", FileSizeBytes: 7, UpdatedAt: updated},
+		}, nil)
+
+		rec := serve(t, service, "/?stat", `["a.txt","missing.txt","/dir/b.txt"]`, true)
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		assert.Equal(t, "no-store", rec.Header().Get("Cache-Control"))
+
+		var resp stowryhttp.StatResponse
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+		require.Len(t, resp.Objects, 3)
+		assert.Nil(t, resp.Objects["missing.txt"])
+		require.NotNil(t, resp.Objects["a.txt"])
+		assert.Equal(t, "ea", resp.Objects["a.txt"].Etag)
+		assert.Equal(t, int64(3), resp.Objects["a.txt"].FileSizeBytes)
+		assert.True(t, updated.Equal(resp.Objects["a.txt"].UpdatedAt))
+		require.NotNil(t, resp.Objects["/dir/b.txt"])
+		assert.Equal(t, "dir/b.txt", resp.Objects["/dir/b.txt"].Path)
+		assert.Contains(t, rec.Body.String(), `"missing.txt":null`)
+	})
+
+	t.Run("requires read authentication", func(t *testing.T) {
+		service := new(statMockService)
+		rec := serve(t, service, "/?stat", `["a.txt"]`, false)
+		assert.Equal(t, http.StatusUnauthorized, rec.Code)
+		service.AssertNotCalled(t, "StatMany", mock.Anything, mock.Anything)
+	})
+
+	t.Run("too many paths", func(t *testing.T) {
+		service := new(statMockService)
+		paths, err := json.Marshal(make([]string, stowry.MaxStatPaths+1))
+		require.NoError(t, err)
+
+		rec := serve(t, service, "/?stat", string(paths), true)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		assert.Contains(t, rec.Body.String(), "too_many_paths")
+		service.AssertNotCalled(t, "StatMany", mock.Anything, mock.Anything)
+	})
+
+	t.Run("invalid body", func(t *testing.T) {
+		rec := serve(t, new(statMockService), "/?stat", `{"paths":["a.txt"]}`, true)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		assert.Contains(t, rec.Body.String(), "invalid_request")
+	})
+
+	t.Run("POST / without stat", func(t *testing.T) {
+		rec := serve(t, new(statMockService), "/", `["a.txt"]`, true)
+		assert.Equal(t, http.StatusMethodNotAllowed, rec.Code)
+	})
+
+	t.Run("not served without StatService", func(t *testing.T) {
+		rec := serve(t, new(MockService), "/?stat", `["a.txt"]`, true)
+		assert.Equal(t, http.StatusMethodNotAllowed, rec.Code)
+	})
+
+	t.Run("listed in capabilities", func(t *testing.T) {
+		h, err := stowryhttp.New(&stowryhttp.HandlerConfig{Mode: stowry.ModeStore}, new(statMockService))
+		require.NoError(t, err)
+		rec := httptest.NewRecorder()
+		h.Router().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/?capabilities", nil))
+
+		var caps stowryhttp.Capabilities
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &caps))
+		assert.Contains(t, caps.Features, "stat")
+	})
+}
@@ -0,0 +1,38 @@
+package http
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// SupportBundler writes a support bundle, a gzipped tar describing the
+// deployment, including the capabilities the handler advertises.
+type SupportBundler interface {
+	WriteSupportBundle(ctx context.Context, w io.Writer, capabilities Capabilities) error
+}
+
+// handleSupportBundle serves GET /admin/support-bundle. The bundle is built
+// in memory before anything is sent, so a failure is still reported with a
+// status code.
+func (h *Handler) handleSupportBundle(w http.ResponseWriter, r *http.Request) {
+	var buf bytes.Buffer
+	if err := h.config.SupportBundle.WriteSupportBundle(r.Context(), &buf, h.capabilities()); err != nil {
+		h.handleError(w, r, err)
+		return
+	}
+
+	filename := "stowry-support-" + time.Now().UTC().Format("20060102T150405Z") + ".tar.gz"
+	w.Header().Set("Content-Type", "application/gzip")
+	w.Header().Set("Content-Disposition", `attachment; filename="`+filename+`"`)
+	w.Header().Set("Content-Length", strconv.Itoa(buf.Len()))
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusOK)
+
+	if _, err := buf.WriteTo(w); err != nil {
+		h.logger.Warn("send support bundle failed", "error", err)
+	}
+}
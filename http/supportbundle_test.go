@@ -0,0 +1,88 @@
+package http_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/sagarc03/stowry"
+	stowryhttp "github.com/sagarc03/stowry/http"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeSupportBundler writes content as the bundle, or fails with err.
+type fakeSupportBundler struct {
+	content      string
+	err          error
+	called       bool
+	capabilities stowryhttp.Capabilities
+}
+
+func (f *fakeSupportBundler) WriteSupportBundle(_ context.Context, w io.Writer, capabilities stowryhttp.Capabilities) error {
+	f.called = true
+	f.capabilities = capabilities
+	if f.err != nil {
+		return f.err
+	}
+	_, err := io.WriteString(w, f.content)
+	return err
+}
+
+func TestHandler_SupportBundle(t *testing.T) {
+	serve := func(t *testing.T, config *stowryhttp.HandlerConfig, req *http.Request) *httptest.ResponseRecorder {
+		t.Helper()
+		service := new(MockService)
+		service.On("Get", mock.Anything, "admin/support-bundle").Return(stowry.MetaData{}, nil, stowry.ErrNotFound)
+		h, err := stowryhttp.New(config, service)
+		require.NoError(t, err)
+		rec := httptest.NewRecorder()
+		h.Router().ServeHTTP(rec, req)
+		return rec
+	}
+
+	t.Run("sends the bundle", func(t *testing.T) {
+		bundler := &fakeSupportBundler{content: "bundle"}
+		config := &stowryhttp.HandlerConfig{Mode: stowry.ModeStore, Version: "1.2.3", WriteVerifier: headerVerifier{}, SupportBundle: bundler}
+		req := httptest.NewRequest(http.MethodGet, "/admin/support-bundle", nil)
+		req.Header.Set("X-Test-Auth", "ok")
+
+		rec := serve(t, config, req)
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "bundle", rec.Body.String())
+		assert.Equal(t, "application/gzip", rec.Header().Get("Content-Type"))
+		assert.Equal(t, "6", rec.Header().Get("Content-Length"))
+		assert.Equal(t, "no-store", rec.Header().Get("Cache-Control"))
+		assert.Regexp(t, `^attachment; filename="stowry-support-\d{8}T\d{6}Z\.tar\.gz"$`, rec.Header().Get("Content-Disposition"))
+		assert.Equal(t, "1.2.3", bundler.capabilities.Version)
+	})
+
+	t.Run("requires write authentication", func(t *testing.T) {
+		bundler := &fakeSupportBundler{content: "bundle"}
+		config := &stowryhttp.HandlerConfig{Mode: stowry.ModeStore, WriteVerifier: headerVerifier{}, SupportBundle: bundler}
+
+		rec := serve(t, config, httptest.NewRequest(http.MethodGet, "/admin/support-bundle", nil))
+
+		assert.Equal(t, http.StatusUnauthorized, rec.Code)
+		assert.False(t, bundler.called)
+	})
+
+	t.Run("bundle failure", func(t *testing.T) {
+		config := &stowryhttp.HandlerConfig{Mode: stowry.ModeStore, SupportBundle: &fakeSupportBundler{err: errors.New("disk full")}}
+
+		rec := serve(t, config, httptest.NewRequest(http.MethodGet, "/admin/support-bundle", nil))
+
+		assert.Equal(t, http.StatusInternalServerError, rec.Code)
+		assert.NotContains(t, rec.Body.String(), "disk full")
+	})
+
+	t.Run("disabled without a bundler", func(t *testing.T) {
+		rec := serve(t, &stowryhttp.HandlerConfig{Mode: stowry.ModeStore}, httptest.NewRequest(http.MethodGet, "/admin/support-bundle", nil))
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+	})
+}
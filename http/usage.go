@@ -0,0 +1,218 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/sagarc03/stowry"
+)
+
+// UsageService is an optional Service extension for per-prefix usage
+// snapshots. When the service implements it, store mode serves the usage
+// endpoints with write authentication:
+//
+//   - POST /admin/usage/snapshots takes a snapshot now
+//   - GET /admin/usage/snapshots?from=&to= lists the snapshots taken
+//   - GET /admin/usage/snapshot?at= returns the latest snapshot by at
+//   - GET /admin/usage/report?from=&to= compares two snapshots, as JSON or,
+//     with format=csv or Accept: text/csv, as CSV
+//
+// Times are RFC 3339. *stowry.StowryService implements it.
+type UsageService interface {
+	TakeUsageSnapshot(ctx context.Context) (stowry.UsageSnapshot, error)
+	UsageSnapshots(ctx context.Context, from, to time.Time) ([]stowry.UsageSnapshot, error)
+	UsageSnapshotAt(ctx context.Context, at time.Time) (stowry.UsageSnapshot, error)
+	UsageReport(ctx context.Context, from, to time.Time) (stowry.UsageReport, error)
+}
+
+// BackendUsageService is an optional Service extension for the usage of each
+// storage backend. When the service implements it, store mode serves GET
+// /admin/usage/backends with write authentication. *stowry.StowryService
+// implements it.
+type BackendUsageService interface {
+	BackendUsage(ctx context.Context) ([]stowry.BackendUsage, error)
+}
+
+// BackendUsageResponse is the body of GET /admin/usage/backends.
+type BackendUsageResponse struct {
+	Backends []stowry.BackendUsage `json:"backends"`
+}
+
+// UsageSnapshotsResponse is the body of GET /admin/usage/snapshots.
+type UsageSnapshotsResponse struct {
+	Snapshots []stowry.UsageSnapshot `json:"snapshots"`
+}
+
+// usageService returns the service's UsageService, if any.
+func (h *Handler) usageService() (UsageService, bool) {
+	us, ok := h.service.(UsageService)
+	return us, ok
+}
+
+// backendUsageService returns the service's BackendUsageService, if any.
+func (h *Handler) backendUsageService() (BackendUsageService, bool) {
+	bs, ok := h.service.(BackendUsageService)
+	return bs, ok
+}
+
+// usageTime parses the RFC 3339 query parameter name, returning fallback
+// when it is absent.
+func usageTime(r *http.Request, name string, fallback time.Time) (time.Time, bool) {
+	value := r.URL.Query().Get(name)
+	if value == "" {
+		return fallback, true
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return t, true
+}
+
+// usagePeriod parses the from and to parameters. from defaults to
+// defaultFrom and to to now; to must not be before from.
+func (h *Handler) usagePeriod(w http.ResponseWriter, r *http.Request, defaultFrom time.Time) (time.Time, time.Time, bool) {
+	from, ok := usageTime(r, "from", defaultFrom)
+	if !ok {
+		h.writeError(w, r, http.StatusBadRequest, "invalid_parameter", "from must be an RFC 3339 time")
+		return time.Time{}, time.Time{}, false
+	}
+	to, ok := usageTime(r, "to", time.Now())
+	if !ok {
+		h.writeError(w, r, http.StatusBadRequest, "invalid_parameter", "to must be an RFC 3339 time")
+		return time.Time{}, time.Time{}, false
+	}
+	if to.Before(from) {
+		h.writeError(w, r, http.StatusBadRequest, "invalid_parameter", "to must not be before from")
+		return time.Time{}, time.Time{}, false
+	}
+	return from, to, true
+}
+
+// handleTakeUsageSnapshot serves POST /admin/usage/snapshots.
+func (h *Handler) handleTakeUsageSnapshot(w http.ResponseWriter, r *http.Request) {
+	us, ok := h.usageService()
+	if !ok {
+		h.handleError(w, r, stowry.ErrNotSupported)
+		return
+	}
+
+	snapshot, err := us.TakeUsageSnapshot(r.Context())
+	if err != nil {
+		h.handleError(w, r, err)
+		return
+	}
+	_ = WriteJSON(w, http.StatusCreated, snapshot)
+}
+
+// handleListUsageSnapshots serves GET /admin/usage/snapshots?from=&to=.
+// Without from it lists every snapshot up to to.
+func (h *Handler) handleListUsageSnapshots(w http.ResponseWriter, r *http.Request) {
+	us, ok := h.usageService()
+	if !ok {
+		h.handleError(w, r, stowry.ErrNotSupported)
+		return
+	}
+	from, to, ok := h.usagePeriod(w, r, time.Time{})
+	if !ok {
+		return
+	}
+
+	snapshots, err := us.UsageSnapshots(r.Context(), from, to)
+	if err != nil {
+		h.handleError(w, r, err)
+		return
+	}
+	w.Header().Set("Cache-Control", "no-store")
+	_ = WriteJSON(w, http.StatusOK, UsageSnapshotsResponse{Snapshots: snapshots})
+}
+
+// handleGetUsageSnapshot serves GET /admin/usage/snapshot?at=, the latest
+// snapshot taken at or before at, now by default.
+func (h *Handler) handleGetUsageSnapshot(w http.ResponseWriter, r *http.Request) {
+	us, ok := h.usageService()
+	if !ok {
+		h.handleError(w, r, stowry.ErrNotSupported)
+		return
+	}
+	at, ok := usageTime(r, "at", time.Now())
+	if !ok {
+		h.writeError(w, r, http.StatusBadRequest, "invalid_parameter", "at must be an RFC 3339 time")
+		return
+	}
+
+	snapshot, err := us.UsageSnapshotAt(r.Context(), at)
+	if err != nil {
+		if errors.Is(err, stowry.ErrNotFound) {
+			h.writeError(w, r, http.StatusNotFound, "not_found", "No usage snapshot was taken by this time")
+			return
+		}
+		h.handleError(w, r, err)
+		return
+	}
+	w.Header().Set("Cache-Control", "no-store")
+	_ = WriteJSON(w, http.StatusOK, snapshot)
+}
+
+// handleUsageReport serves GET /admin/usage/report?from=&to=. from is
+// required; to defaults to now.
+func (h *Handler) handleUsageReport(w http.ResponseWriter, r *http.Request) {
+	us, ok := h.usageService()
+	if !ok {
+		h.handleError(w, r, stowry.ErrNotSupported)
+		return
+	}
+	if !r.URL.Query().Has("from") {
+		h.writeError(w, r, http.StatusBadRequest, "invalid_parameter", "from is required")
+		return
+	}
+	format, ok := listFormat(r)
+	if !ok {
+		h.writeError(w, r, http.StatusBadRequest, "invalid_parameter", "format must be json or csv")
+		return
+	}
+	from, to, ok := h.usagePeriod(w, r, time.Time{})
+	if !ok {
+		return
+	}
+
+	report, err := us.UsageReport(r.Context(), from, to)
+	if err != nil {
+		if errors.Is(err, stowry.ErrNotFound) {
+			h.writeError(w, r, http.StatusNotFound, "not_found", "No usage snapshot was taken by the start of the period")
+			return
+		}
+		h.handleError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	if format == "csv" {
+		w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+		w.WriteHeader(http.StatusOK)
+		if err := report.WriteCSV(w); err != nil {
+			h.logger.Warn("usage report: write csv", "error", err)
+		}
+		return
+	}
+	_ = WriteJSON(w, http.StatusOK, report)
+}
+
+// handleBackendUsage serves GET /admin/usage/backends.
+func (h *Handler) handleBackendUsage(w http.ResponseWriter, r *http.Request) {
+	bs, ok := h.backendUsageService()
+	if !ok {
+		h.handleError(w, r, stowry.ErrNotSupported)
+		return
+	}
+
+	backends, err := bs.BackendUsage(r.Context())
+	if err != nil {
+		h.handleError(w, r, err)
+		return
+	}
+	w.Header().Set("Cache-Control", "no-store")
+	_ = WriteJSON(w, http.StatusOK, BackendUsageResponse{Backends: backends})
+}
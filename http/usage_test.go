@@ -0,0 +1,189 @@
+package http_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/sagarc03/stowry"
+	stowryhttp "github.com/sagarc03/stowry/http"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// usageMockService is a MockService that records usage snapshots.
+type usageMockService struct {
+	MockService
+}
+
+func (m *usageMockService) TakeUsageSnapshot(ctx context.Context) (stowry.UsageSnapshot, error) {
+	args := m.Called(ctx)
+	return args.Get(0).(stowry.UsageSnapshot), args.Error(1)
+}
+
+func (m *usageMockService) UsageSnapshots(ctx context.Context, from, to time.Time) ([]stowry.UsageSnapshot, error) {
+	args := m.Called(ctx, from, to)
+	snapshots, _ := args.Get(0).([]stowry.UsageSnapshot)
+	return snapshots, args.Error(1)
+}
+
+func (m *usageMockService) UsageSnapshotAt(ctx context.Context, at time.Time) (stowry.UsageSnapshot, error) {
+	args := m.Called(ctx, at)
+	return args.Get(0).(stowry.UsageSnapshot), args.Error(1)
+}
+
+func (m *usageMockService) UsageReport(ctx context.Context, from, to time.Time) (stowry.UsageReport, error) {
+	args := m.Called(ctx, from, to)
+	return args.Get(0).(stowry.UsageReport), args.Error(1)
+}
+
+func (m *usageMockService) BackendUsage(ctx context.Context) ([]stowry.BackendUsage, error) {
+	args := m.Called(ctx)
+	backends, _ := args.Get(0).([]stowry.BackendUsage)
+	return backends, args.Error(1)
+}
+
+func TestHandler_Usage(t *testing.T) {
+	from := time.Date(2026, 9, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC)
+
+	serve := func(t *testing.T, service stowryhttp.Service, method, target string, header http.Header) *httptest.ResponseRecorder {
+		t.Helper()
+		h, err := stowryhttp.New(&stowryhttp.HandlerConfig{Mode: stowry.ModeStore, WriteVerifier: headerVerifier{}}, service)
+		require.NoError(t, err)
+		req := httptest.NewRequest(method, target, nil)
+		req.Header.Set("X-Test-Auth", "ok")
+		for k, v := range header {
+			req.Header[k] = v
+		}
+		rec := httptest.NewRecorder()
+		h.Router().ServeHTTP(rec, req)
+		return rec
+	}
+
+	t.Run("takes a snapshot", func(t *testing.T) {
+		service := new(usageMockService)
+		service.On("TakeUsageSnapshot", mock.Anything).Return(stowry.UsageSnapshot{
+			ID: 3, TakenAt: to, Complete: true,
+			Prefixes: []stowry.PrefixUsage{{Prefix: "team-a/", Objects: 2, Bytes: 10, ObjectsAdded: 4, BytesAdded: 30}},
+		}, nil)
+
+		rec := serve(t, service, http.MethodPost, "/admin/usage/snapshots", nil)
+		require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+
+		var snapshot stowry.UsageSnapshot
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &snapshot))
+		assert.Equal(t, int64(3), snapshot.ID)
+		require.Len(t, snapshot.Prefixes, 1)
+		assert.Equal(t, int64(30), snapshot.Prefixes[0].BytesAdded)
+	})
+
+	t.Run("lists snapshots in a period", func(t *testing.T) {
+		service := new(usageMockService)
+		service.On("UsageSnapshots", mock.Anything, from, to).Return([]stowry.UsageSnapshot{
+			{ID: 1, TakenAt: from, Complete: true},
+			{ID: 2, TakenAt: to, Complete: false},
+		}, nil)
+
+		rec := serve(t, service, http.MethodGet, "/admin/usage/snapshots?from=2026-09-01T00:00:00Z&to=2026-10-01T00:00:00Z", nil)
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+		var resp stowryhttp.UsageSnapshotsResponse
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+		require.Len(t, resp.Snapshots, 2)
+		assert.False(t, resp.Snapshots[1].Complete)
+	})
+
+	t.Run("snapshot at a time", func(t *testing.T) {
+		service := new(usageMockService)
+		service.On("UsageSnapshotAt", mock.Anything, from).Return(stowry.UsageSnapshot{ID: 1, TakenAt: from}, nil)
+
+		rec := serve(t, service, http.MethodGet, "/admin/usage/snapshot?at=2026-09-01T00:00:00Z", nil)
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		assert.Contains(t, rec.Body.String(), `"id":1`)
+	})
+
+	t.Run("no snapshot by then", func(t *testing.T) {
+		service := new(usageMockService)
+		service.On("UsageSnapshotAt", mock.Anything, from).Return(stowry.UsageSnapshot{}, stowry.ErrNotFound)
+
+		rec := serve(t, service, http.MethodGet, "/admin/usage/snapshot?at=2026-09-01T00:00:00Z", nil)
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+	})
+
+	t.Run("usage per backend", func(t *testing.T) {
+		service := new(usageMockService)
+		service.On("BackendUsage", mock.Anything).Return([]stowry.BackendUsage{
+			{Backend: "", Objects: 3, Bytes: 30},
+			{Backend: "archive/", Objects: 1, Bytes: 500},
+		}, nil)
+
+		rec := serve(t, service, http.MethodGet, "/admin/usage/backends", nil)
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+		var resp stowryhttp.BackendUsageResponse
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+		require.Len(t, resp.Backends, 2)
+		assert.Equal(t, stowry.BackendUsage{Backend: "archive/", Objects: 1, Bytes: 500}, resp.Backends[1])
+	})
+
+	report := stowry.UsageReport{From: from, To: to, Complete: true, Prefixes: []stowry.UsageDelta{
+		{Prefix: "team-a/", Objects: 2, Bytes: 10, ObjectsAdded: 3, ObjectsDeleted: 1, BytesAdded: 15, BytesDeleted: 5},
+	}}
+
+	t.Run("report as json", func(t *testing.T) {
+		service := new(usageMockService)
+		service.On("UsageReport", mock.Anything, from, to).Return(report, nil)
+
+		rec := serve(t, service, http.MethodGet, "/admin/usage/report?from=2026-09-01T00:00:00Z&to=2026-10-01T00:00:00Z", nil)
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+		var got stowry.UsageReport
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &got))
+		assert.Equal(t, report.Prefixes, got.Prefixes)
+	})
+
+	t.Run("report as csv", func(t *testing.T) {
+		service := new(usageMockService)
+		service.On("UsageReport", mock.Anything, from, to).Return(report, nil)
+
+		rec := serve(t, service, http.MethodGet, "/admin/usage/report?from=2026-09-01T00:00:00Z&to=2026-10-01T00:00:00Z",
+			http.Header{"Accept": {"text/csv"}})
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		assert.Equal(t, "text/csv; charset=utf-8", rec.Header().Get("Content-Type"))
+		assert.Equal(t, "prefix,objects,bytes,objects_added,objects_deleted,bytes_added,bytes_deleted\nteam-a/,2,10,3,1,15,5\n", rec.Body.String())
+	})
+
+	t.Run("report requires from", func(t *testing.T) {
+		service := new(usageMockService)
+		rec := serve(t, service, http.MethodGet, "/admin/usage/report", nil)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		service.AssertNotCalled(t, "UsageReport", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("rejects a period that ends before it starts", func(t *testing.T) {
+		service := new(usageMockService)
+		rec := serve(t, service, http.MethodGet, "/admin/usage/report?from=2026-10-01T00:00:00Z&to=2026-09-01T00:00:00Z", nil)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+	})
+
+	t.Run("rejects a malformed time", func(t *testing.T) {
+		service := new(usageMockService)
+		rec := serve(t, service, http.MethodGet, "/admin/usage/snapshots?from=yesterday", nil)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+	})
+
+	t.Run("requires write authentication", func(t *testing.T) {
+		service := new(usageMockService)
+		h, err := stowryhttp.New(&stowryhttp.HandlerConfig{Mode: stowry.ModeStore, WriteVerifier: headerVerifier{}}, service)
+		require.NoError(t, err)
+		rec := httptest.NewRecorder()
+		h.Router().ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/admin/usage/snapshots", nil))
+		assert.Equal(t, http.StatusUnauthorized, rec.Code)
+		service.AssertNotCalled(t, "TakeUsageSnapshot", mock.Anything)
+	})
+}
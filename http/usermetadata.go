@@ -0,0 +1,45 @@
+package http
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/sagarc03/stowry"
+)
+
+// User metadata header prefixes. Each header a PUT sends with one of them
+// stores a metadata key, the rest of its name, with the header's value; GET
+// and HEAD send every key back under both. A key given under both prefixes
+// takes the UserMetadataPrefix value.
+const (
+	UserMetadataPrefix    = "X-Stowry-Meta-"
+	AmzUserMetadataPrefix = "X-Amz-Meta-"
+)
+
+// requestUserMetadata returns the user metadata the headers of r give, or
+// nil for none. Repeated headers are joined with commas. The service checks
+// the keys, values and total size.
+func requestUserMetadata(r *http.Request) map[string]string {
+	var metadata map[string]string
+	// The Amazon prefix is read first, so the Stowry one wins
+	for _, prefix := range []string{AmzUserMetadataPrefix, UserMetadataPrefix} {
+		for name, values := range r.Header {
+			if len(name) <= len(prefix) || !strings.EqualFold(name[:len(prefix)], prefix) {
+				continue
+			}
+			if metadata == nil {
+				metadata = make(map[string]string)
+			}
+			metadata[strings.ToLower(name[len(prefix):])] = strings.Join(values, ",")
+		}
+	}
+	return metadata
+}
+
+// setUserMetadataHeaders sends obj's user metadata under both prefixes.
+func setUserMetadataHeaders(header http.Header, obj stowry.MetaData) {
+	for key, value := range obj.UserMetadata {
+		header.Set(UserMetadataPrefix+key, value)
+		header.Set(AmzUserMetadataPrefix+key, value)
+	}
+}
@@ -0,0 +1,108 @@
+package http_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/sagarc03/stowry"
+	stowryhttp "github.com/sagarc03/stowry/http"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHandler_HandlePut_UserMetadata(t *testing.T) {
+	config := &stowryhttp.HandlerConfig{Mode: stowry.ModeStore}
+
+	t.Run("both prefixes are captured", func(t *testing.T) {
+		service := new(MockService)
+		service.On("Create", mock.Anything, mock.MatchedBy(func(obj stowry.CreateObject) bool {
+			return assert.ObjectsAreEqual(map[string]string{"uploader": "u-42", "original-name": "a.pdf", "tag": "stowry"}, obj.UserMetadata)
+		}), mock.Anything).Return(stowry.MetaData{Path: "a.txt", Etag: "e"}, nil)
+
+		req := httptest.NewRequest(http.MethodPut, "/a.txt", strings.NewReader("data"))
+		req.Header.Set("X-Amz-Meta-Uploader", "u-42")
+		req.Header.Set("x-stowry-meta-original-name", "a.pdf")
+		req.Header.Set("X-Amz-Meta-Tag", "amz")
+		req.Header.Set("X-Stowry-Meta-Tag", "stowry")
+		rec := httptest.NewRecorder()
+		stowryhttp.NewHandler(config, service).Router().ServeHTTP(rec, req)
+
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		service.AssertExpectations(t)
+	})
+
+	t.Run("none", func(t *testing.T) {
+		service := new(MockService)
+		service.On("Create", mock.Anything, mock.MatchedBy(func(obj stowry.CreateObject) bool {
+			return obj.UserMetadata == nil
+		}), mock.Anything).Return(stowry.MetaData{Path: "a.txt", Etag: "e"}, nil)
+
+		rec := httptest.NewRecorder()
+		stowryhttp.NewHandler(config, service).Router().ServeHTTP(rec, httptest.NewRequest(http.MethodPut, "/a.txt", strings.NewReader("data")))
+
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		service.AssertExpectations(t)
+	})
+
+	for code, err := range map[string]error{
+		"metadata_too_large": stowry.ErrUserMetadataTooLarge,
+		"invalid_metadata":   stowry.ErrInvalidUserMetadata,
+	} {
+		t.Run(code, func(t *testing.T) {
+			service := new(MockService)
+			service.On("Create", mock.Anything, mock.Anything, mock.Anything).Return(stowry.MetaData{}, err)
+
+			req := httptest.NewRequest(http.MethodPut, "/a.txt", strings.NewReader("data"))
+			req.Header.Set("X-Amz-Meta-K", "v")
+			rec := httptest.NewRecorder()
+			stowryhttp.NewHandler(config, service).Router().ServeHTTP(rec, req)
+
+			assert.Equal(t, http.StatusBadRequest, rec.Code)
+			assertErrorCode(t, rec, code)
+		})
+	}
+}
+
+func TestHandler_UserMetadataHeaders(t *testing.T) {
+	config := &stowryhttp.HandlerConfig{Mode: stowry.ModeStore}
+	obj := stowry.MetaData{Path: "a.txt", ContentType: "text/plain", Etag: "e", FileSizeBytes: 4,
+		UserMetadata: map[string]string{"uploader": "u-42"}}
+
+	t.Run("get", func(t *testing.T) {
+		service := new(MockService)
+		service.On("Get", mock.Anything, "a.txt").Return(obj, readSeekNopCloser{strings.NewReader("data")}, nil)
+
+		rec := httptest.NewRecorder()
+		stowryhttp.NewHandler(config, service).Router().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/a.txt", nil))
+
+		require.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "u-42", rec.Header().Get("X-Stowry-Meta-Uploader"))
+		assert.Equal(t, "u-42", rec.Header().Get("X-Amz-Meta-Uploader"))
+	})
+
+	t.Run("head", func(t *testing.T) {
+		service := new(MockService)
+		service.On("Info", mock.Anything, "a.txt").Return(obj, nil)
+
+		rec := httptest.NewRecorder()
+		stowryhttp.NewHandler(config, service).Router().ServeHTTP(rec, httptest.NewRequest(http.MethodHead, "/a.txt", nil))
+
+		require.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "u-42", rec.Header().Get("X-Stowry-Meta-Uploader"))
+		assert.Equal(t, "u-42", rec.Header().Get("X-Amz-Meta-Uploader"))
+	})
+
+	t.Run("list", func(t *testing.T) {
+		service := new(MockService)
+		service.On("List", mock.Anything, mock.Anything).Return(stowry.ListResult{Items: []stowry.MetaData{obj}}, nil)
+
+		rec := httptest.NewRecorder()
+		stowryhttp.NewHandler(config, service).Router().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		assert.Contains(t, rec.Body.String(), `"user_metadata":{"uploader":"u-42"}`)
+	})
+}
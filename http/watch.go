@@ -0,0 +1,114 @@
+package http
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/sagarc03/stowry"
+)
+
+const (
+	// DefaultWatchTimeout is how long GET /?watch waits for a change when the
+	// request has no timeout parameter.
+	DefaultWatchTimeout = 30 * time.Second
+	// DefaultMaxWatchTimeout caps the timeout of GET /?watch when
+	// HandlerConfig.MaxWatchTimeout is not set.
+	DefaultMaxWatchTimeout = 60 * time.Second
+
+	// watchWriteMargin is added to a watch's timeout when extending the
+	// server's write deadline, leaving time to send the response.
+	watchWriteMargin = 10 * time.Second
+)
+
+// ChangeService is an optional Service extension for the change feed. When
+// the service implements it, store mode serves GET /?watch, which returns the
+// changes after a cursor or waits for new ones.
+type ChangeService interface {
+	WatchChanges(ctx context.Context, q stowry.ChangeQuery, wait time.Duration) (stowry.ChangeBatch, error)
+}
+
+// changeService returns the service as a ChangeService if the change feed is
+// available in the configured mode.
+func (h *Handler) changeService() (ChangeService, bool) {
+	if h.config.Mode != stowry.ModeStore {
+		return nil, false
+	}
+	cs, ok := h.service.(ChangeService)
+	return cs, ok
+}
+
+// isWatchRequest reports whether a GET / asks for the change feed.
+func isWatchRequest(r *http.Request) bool {
+	return r.URL.Query().Has("watch")
+}
+
+// handleWatch serves GET /?watch&prefix=&since=&limit=&timeout=. Without since
+// it waits for changes after the current position; timeout is in seconds and
+// capped by MaxWatchTimeout.
+func (h *Handler) handleWatch(w http.ResponseWriter, r *http.Request) {
+	cs, ok := h.changeService()
+	if !ok {
+		h.handleError(w, r, stowry.ErrNotSupported)
+		return
+	}
+
+	query := r.URL.Query()
+	q := stowry.ChangeQuery{
+		PathPrefix: query.Get("prefix"),
+		Since:      -1,
+		Limit:      stowry.DefaultChangeLimit,
+	}
+
+	if since := query.Get("since"); since != "" {
+		parsed, err := strconv.ParseInt(since, 10, 64)
+		if err != nil || parsed < 0 {
+			h.writeError(w, r, http.StatusBadRequest, "invalid_parameter", "since must be a non-negative integer")
+			return
+		}
+		q.Since = parsed
+	}
+
+	if limit := query.Get("limit"); limit != "" {
+		parsed, err := strconv.Atoi(limit)
+		if err != nil {
+			h.writeError(w, r, http.StatusBadRequest, "invalid_parameter", "limit must be a valid integer")
+			return
+		}
+		q.Limit = max(1, min(1000, parsed))
+	}
+
+	wait := DefaultWatchTimeout
+	if timeout := query.Get("timeout"); timeout != "" {
+		parsed, err := strconv.Atoi(timeout)
+		if err != nil || parsed < 0 {
+			h.writeError(w, r, http.StatusBadRequest, "invalid_parameter", "timeout must be a non-negative number of seconds")
+			return
+		}
+		wait = time.Duration(parsed) * time.Second
+	}
+	wait = min(wait, h.maxWatchTimeout())
+
+	if wait > 0 {
+		// The server's write timeout would otherwise cut the wait short.
+		// Writers that cannot extend it just keep their deadline.
+		_ = http.NewResponseController(w).SetWriteDeadline(time.Now().Add(wait + watchWriteMargin))
+	}
+
+	batch, err := cs.WatchChanges(r.Context(), q, wait)
+	if err != nil {
+		h.handleError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	_ = WriteJSON(w, http.StatusOK, batch)
+}
+
+func (h *Handler) maxWatchTimeout() time.Duration {
+	if h.config.MaxWatchTimeout > 0 {
+		return h.config.MaxWatchTimeout
+	}
+	return DefaultMaxWatchTimeout
+}
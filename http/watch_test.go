@@ -0,0 +1,119 @@
+package http_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/sagarc03/stowry"
+	stowryhttp "github.com/sagarc03/stowry/http"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// MockChangeService is a MockService that also implements
+// http.ChangeService.
+type MockChangeService struct {
+	MockService
+}
+
+func (m *MockChangeService) WatchChanges(ctx context.Context, q stowry.ChangeQuery, wait time.Duration) (stowry.ChangeBatch, error) {
+	args := m.Called(ctx, q, wait)
+	return args.Get(0).(stowry.ChangeBatch), args.Error(1)
+}
+
+func TestHandler_Watch(t *testing.T) {
+	serve := func(t *testing.T, config *stowryhttp.HandlerConfig, service stowryhttp.Service, target string) *httptest.ResponseRecorder {
+		t.Helper()
+		h, err := stowryhttp.New(config, service)
+		require.NoError(t, err)
+		rec := httptest.NewRecorder()
+		h.Router().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, target, nil))
+		return rec
+	}
+	storeConfig := &stowryhttp.HandlerConfig{Mode: stowry.ModeStore}
+
+	t.Run("returns changes and the next cursor", func(t *testing.T) {
+		service := new(MockChangeService)
+		change := stowry.Change{Seq: 8, Kind: stowry.ChangeCreate, Path: "docs/a.txt", ETag: "abc", Size: 3}
+		service.On("WatchChanges", mock.Anything, stowry.ChangeQuery{PathPrefix: "docs/", Since: 7, Limit: 50}, 5*time.Second).
+			Return(stowry.ChangeBatch{Changes: []stowry.Change{change}, Next: 8}, nil)
+
+		rec := serve(t, storeConfig, service, "/?watch&prefix=docs/&since=7&limit=50&timeout=5")
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "no-store", rec.Header().Get("Cache-Control"))
+		var batch stowry.ChangeBatch
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &batch))
+		assert.Equal(t, int64(8), batch.Next)
+		require.Len(t, batch.Changes, 1)
+		assert.Equal(t, "docs/a.txt", batch.Changes[0].Path)
+		service.AssertNotCalled(t, "List", mock.Anything, mock.Anything)
+	})
+
+	t.Run("defaults start at the current position", func(t *testing.T) {
+		service := new(MockChangeService)
+		service.On("WatchChanges", mock.Anything, stowry.ChangeQuery{Since: -1, Limit: stowry.DefaultChangeLimit}, stowryhttp.DefaultWatchTimeout).
+			Return(stowry.ChangeBatch{Changes: []stowry.Change{}, Next: 3}, nil)
+
+		rec := serve(t, storeConfig, service, "/?watch")
+		assert.Equal(t, http.StatusOK, rec.Code)
+		service.AssertExpectations(t)
+	})
+
+	t.Run("timeout is capped", func(t *testing.T) {
+		service := new(MockChangeService)
+		service.On("WatchChanges", mock.Anything, mock.Anything, 10*time.Second).
+			Return(stowry.ChangeBatch{Changes: []stowry.Change{}}, nil)
+
+		config := &stowryhttp.HandlerConfig{Mode: stowry.ModeStore, MaxWatchTimeout: 10 * time.Second}
+		rec := serve(t, config, service, "/?watch&timeout=3600")
+		assert.Equal(t, http.StatusOK, rec.Code)
+		service.AssertExpectations(t)
+	})
+
+	t.Run("invalid parameters", func(t *testing.T) {
+		for _, query := range []string{"since=-1", "since=x", "limit=x", "timeout=-5", "timeout=1.5"} {
+			rec := serve(t, storeConfig, new(MockChangeService), "/?watch&"+query)
+			assert.Equal(t, http.StatusBadRequest, rec.Code, query)
+			assert.Contains(t, rec.Body.String(), "invalid_parameter", query)
+		}
+	})
+
+	t.Run("expired cursor", func(t *testing.T) {
+		service := new(MockChangeService)
+		service.On("WatchChanges", mock.Anything, mock.Anything, mock.Anything).Return(stowry.ChangeBatch{}, stowry.ErrCursorExpired)
+
+		rec := serve(t, storeConfig, service, "/?watch&since=1")
+		assert.Equal(t, http.StatusGone, rec.Code)
+		assert.Contains(t, rec.Body.String(), "cursor_expired")
+	})
+
+	t.Run("service without a change feed", func(t *testing.T) {
+		rec := serve(t, storeConfig, new(MockService), "/?watch")
+		assert.Equal(t, http.StatusNotImplemented, rec.Code)
+	})
+
+	t.Run("watch does not hold a read slot", func(t *testing.T) {
+		limiter := stowryhttp.NewConcurrencyLimiter("test_watch", 1, 20*time.Millisecond)
+		entered := make(chan struct{}, 1)
+		release := make(chan struct{})
+		busy := serveAsync(limiter.Middleware(blockingHandler(entered, release)), httptest.NewRequest(http.MethodGet, "/a", http.NoBody))
+		<-entered
+		defer func() {
+			close(release)
+			<-busy
+		}()
+
+		service := new(MockChangeService)
+		service.On("WatchChanges", mock.Anything, mock.Anything, mock.Anything).Return(stowry.ChangeBatch{Changes: []stowry.Change{}}, nil)
+
+		config := &stowryhttp.HandlerConfig{Mode: stowry.ModeStore, ReadLimiter: limiter}
+		assert.Equal(t, http.StatusOK, serve(t, config, service, "/?watch").Code)
+		assert.Equal(t, http.StatusServiceUnavailable, serve(t, config, service, "/").Code, "lists still wait for a slot")
+	})
+}
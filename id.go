@@ -0,0 +1,106 @@
+package stowry
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// IDScheme selects how repositories generate the IDs of new metadata rows.
+// IDs are always stored as UUIDs, so tables may mix rows of every scheme.
+type IDScheme string
+
+const (
+	// IDSchemeUUIDv4 generates random version 4 UUIDs. It is the default.
+	IDSchemeUUIDv4 IDScheme = "uuidv4"
+	// IDSchemeUUIDv7 generates time-ordered version 7 UUIDs (RFC 9562).
+	IDSchemeUUIDv7 IDScheme = "uuidv7"
+	// IDSchemeULID generates ULIDs, a 48-bit millisecond timestamp followed
+	// by 80 random bits, stored as the 16 bytes of a UUID. The bytes carry no
+	// UUID version, so any ULID library reads them back unchanged.
+	IDSchemeULID IDScheme = "ulid-as-uuid"
+)
+
+// Valid reports whether s is a known scheme. The empty scheme is valid and
+// means IDSchemeUUIDv4.
+func (s IDScheme) Valid() bool {
+	switch s {
+	case "", IDSchemeUUIDv4, IDSchemeUUIDv7, IDSchemeULID:
+		return true
+	}
+	return false
+}
+
+// NewID returns a new ID of scheme s.
+func (s IDScheme) NewID() (uuid.UUID, error) {
+	switch s {
+	case "", IDSchemeUUIDv4:
+		return uuid.NewRandom()
+	case IDSchemeUUIDv7:
+		return uuid.NewV7()
+	case IDSchemeULID:
+		return newULID(time.Now()), nil
+	}
+	return uuid.Nil, fmt.Errorf("unknown id scheme %q", s)
+}
+
+// newULID returns a ULID for t with crypto/rand randomness.
+func newULID(t time.Time) uuid.UUID {
+	var id uuid.UUID
+	var ms [8]byte
+	binary.BigEndian.PutUint64(ms[:], uint64(t.UnixMilli()))
+	copy(id[:6], ms[2:])
+	_, _ = rand.Read(id[6:])
+	return id
+}
+
+// crockford is the Crockford base32 alphabet ULIDs are written in.
+const crockford = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+// ParseObjectID parses a client-supplied object ID: a UUID in its canonical
+// 36-character form, or a 26-character ULID, which is decoded into the
+// UUID's 16 bytes. The nil UUID is rejected.
+//
+// Returns:
+//   - uuid.UUID: The parsed ID
+//   - error: ErrInvalidID for anything else
+func ParseObjectID(s string) (uuid.UUID, error) {
+	var id uuid.UUID
+	switch len(s) {
+	case 36:
+		parsed, err := uuid.Parse(s)
+		if err != nil {
+			return uuid.Nil, fmt.Errorf("%w: %w", ErrInvalidID, err)
+		}
+		id = parsed
+	case 26:
+		for _, c := range strings.ToUpper(s) {
+			v := strings.IndexRune(crockford, c)
+			if v < 0 {
+				return uuid.Nil, fmt.Errorf("%w: invalid ulid character %q", ErrInvalidID, c)
+			}
+			// Shift the 128 bits left by 5 and add v; 26 characters hold 130
+			// bits, so the first must not use its top two
+			carry := uint16(v)
+			for i := len(id) - 1; i >= 0; i-- {
+				x := uint16(id[i])<<5 | carry
+				id[i] = byte(x)
+				carry = x >> 8
+			}
+			if carry != 0 {
+				return uuid.Nil, fmt.Errorf("%w: ulid overflows 128 bits", ErrInvalidID)
+			}
+		}
+	default:
+		return uuid.Nil, fmt.Errorf("%w: want a uuid or ulid", ErrInvalidID)
+	}
+
+	if id == uuid.Nil {
+		return uuid.Nil, fmt.Errorf("%w: nil id", ErrInvalidID)
+	}
+	return id, nil
+}
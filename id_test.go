@@ -0,0 +1,190 @@
+package stowry_test
+
+import (
+	"context"
+	"encoding/binary"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sagarc03/stowry"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIDScheme_NewID(t *testing.T) {
+	t.Run("uuidv4", func(t *testing.T) {
+		for _, scheme := range []stowry.IDScheme{"", stowry.IDSchemeUUIDv4} {
+			id, err := scheme.NewID()
+			require.NoError(t, err)
+			assert.Equal(t, uuid.Version(4), id.Version())
+		}
+	})
+
+	t.Run("uuidv7", func(t *testing.T) {
+		id, err := stowry.IDSchemeUUIDv7.NewID()
+		require.NoError(t, err)
+		assert.Equal(t, uuid.Version(7), id.Version())
+	})
+
+	t.Run("ulid-as-uuid carries the time", func(t *testing.T) {
+		before := time.Now().UnixMilli()
+		id, err := stowry.IDSchemeULID.NewID()
+		require.NoError(t, err)
+		after := time.Now().UnixMilli()
+
+		var ms [8]byte
+		copy(ms[2:], id[:6])
+		stamp := int64(binary.BigEndian.Uint64(ms[:]))
+		assert.GreaterOrEqual(t, stamp, before)
+		assert.LessOrEqual(t, stamp, after)
+	})
+
+	t.Run("time-ordered schemes sort by creation", func(t *testing.T) {
+		for _, scheme := range []stowry.IDScheme{stowry.IDSchemeUUIDv7, stowry.IDSchemeULID} {
+			first, err := scheme.NewID()
+			require.NoError(t, err)
+			time.Sleep(2 * time.Millisecond)
+			second, err := scheme.NewID()
+			require.NoError(t, err)
+			assert.Less(t, first.String(), second.String(), scheme)
+		}
+	})
+
+	t.Run("unknown scheme", func(t *testing.T) {
+		assert.False(t, stowry.IDScheme("snowflake").Valid())
+		_, err := stowry.IDScheme("snowflake").NewID()
+		assert.Error(t, err)
+	})
+}
+
+func TestParseObjectID(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{name: "uuid", input: "0190a8e4-7b2c-7d3e-8f40-5a6b7c8d9e0f", want: "0190a8e4-7b2c-7d3e-8f40-5a6b7c8d9e0f"},
+		{name: "upper case uuid", input: "0190A8E4-7B2C-7D3E-8F40-5A6B7C8D9E0F", want: "0190a8e4-7b2c-7d3e-8f40-5a6b7c8d9e0f"},
+		{name: "ulid", input: "01ARZ3NDEKTSV4RRFFQ69G5FAV", want: "01563e3a-b5d3-d676-4c61-efb99302bd5b"},
+		{name: "lower case ulid", input: "01arz3ndektsv4rrffq69g5fav", want: "01563e3a-b5d3-d676-4c61-efb99302bd5b"},
+		{name: "largest ulid", input: "7ZZZZZZZZZZZZZZZZZZZZZZZZZ", want: "ffffffff-ffff-ffff-ffff-ffffffffffff"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			id, err := stowry.ParseObjectID(tt.input)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, id.String())
+		})
+	}
+
+	for _, input := range []string{
+		"",
+		"not-an-id",
+		"00000000-0000-0000-0000-000000000000",
+		"00000000000000000000000000",
+		"0190a8e4-7b2c-7d3e-8f40-5a6b7c8d9e0g",
+		"01ARZ3NDEKTSV4RRFFQ69G5FAU", // U is not in the alphabet
+		"8ZZZZZZZZZZZZZZZZZZZZZZZZZ", // more than 128 bits
+		"01ARZ3NDEKTSV4RRFFQ69G5FA",  // 25 characters
+		"{0190a8e4-7b2c-7d3e-8f40-5a6b7c8d9e0f}",
+	} {
+		_, err := stowry.ParseObjectID(input)
+		assert.ErrorIs(t, err, stowry.ErrInvalidID, input)
+		assert.ErrorIs(t, err, stowry.ErrInvalidInput, input)
+	}
+}
+
+func TestStowryService_Create_ObjectID(t *testing.T) {
+	ctx := context.Background()
+	id := uuid.MustParse("01563e3a-b5d3-d676-4c61-efb99302bd5b")
+
+	t.Run("stores the supplied id", func(t *testing.T) {
+		env := newIntentEnv(t)
+		service := env.service(t, nil, true)
+
+		res, err := service.Create(ctx, stowry.CreateObject{Path: "a.txt", ContentType: "text/plain", ID: id}, strings.NewReader("one"))
+		require.NoError(t, err)
+		assert.Equal(t, id, res.ID)
+
+		res, err = service.Create(ctx, stowry.CreateObject{Path: "a.txt", ContentType: "text/plain", ID: id}, strings.NewReader("two"))
+		require.NoError(t, err, "overwriting with the same id")
+		assert.Equal(t, id, res.ID)
+		require.NotNil(t, res.Previous)
+		assert.Equal(t, id, res.Previous.ID)
+	})
+
+	t.Run("another id leaves the object untouched", func(t *testing.T) {
+		env := newIntentEnv(t)
+		service := env.service(t, nil, true)
+		_, err := service.Create(ctx, stowry.CreateObject{Path: "a.txt", ContentType: "text/plain", ID: id}, strings.NewReader("one"))
+		require.NoError(t, err)
+
+		_, err = service.Create(ctx, stowry.CreateObject{Path: "a.txt", ContentType: "text/plain", ID: uuid.New()}, strings.NewReader("two"))
+		assert.ErrorIs(t, err, stowry.ErrIDConflict)
+
+		m, content, err := service.Get(ctx, "a.txt")
+		require.NoError(t, err)
+		defer func() { _ = content.Close() }()
+		body, err := io.ReadAll(content)
+		require.NoError(t, err)
+		assert.Equal(t, id, m.ID)
+		assert.Equal(t, "one", string(body))
+	})
+
+	t.Run("id of another path", func(t *testing.T) {
+		env := newIntentEnv(t)
+		service := env.service(t, nil, true)
+		_, err := service.Create(ctx, stowry.CreateObject{Path: "a.txt", ContentType: "text/plain", ID: id}, strings.NewReader("one"))
+		require.NoError(t, err)
+
+		_, err = service.Create(ctx, stowry.CreateObject{Path: "b.txt", ContentType: "text/plain", ID: id}, strings.NewReader("two"))
+		assert.ErrorIs(t, err, stowry.ErrIDConflict)
+
+		_, err = service.Info(ctx, "b.txt")
+		assert.ErrorIs(t, err, stowry.ErrNotFound)
+		orphans, err := service.CollectOrphans(ctx, stowry.OrphanOptions{})
+		require.NoError(t, err)
+		assert.Zero(t, orphans.Orphans, "the rejected file is deleted")
+	})
+
+	t.Run("a deleted object takes a new id", func(t *testing.T) {
+		env := newIntentEnv(t)
+		service := env.service(t, nil, true)
+		_, err := service.Create(ctx, stowry.CreateObject{Path: "a.txt", ContentType: "text/plain", ID: id}, strings.NewReader("one"))
+		require.NoError(t, err)
+		require.NoError(t, service.Delete(ctx, "a.txt"))
+
+		other := uuid.New()
+		res, err := service.Create(ctx, stowry.CreateObject{Path: "a.txt", ContentType: "text/plain", ID: other}, strings.NewReader("two"))
+		require.NoError(t, err)
+		assert.Equal(t, other, res.ID)
+	})
+
+	t.Run("recovery keeps the supplied id", func(t *testing.T) {
+		env := newIntentEnv(t)
+		service := env.service(t, crashRepo{intentMetaDataRepo: env.repo, crashAt: "upsert"}, true)
+
+		assert.PanicsWithValue(t, errCrash, func() {
+			_, _ = service.Create(ctx, stowry.CreateObject{Path: "a.txt", ContentType: "text/plain", ID: id}, strings.NewReader("hello"))
+		})
+
+		report := env.restart(t)
+		assert.Equal(t, 1, report.Completed)
+		m, err := env.repo.Get(ctx, "a.txt")
+		require.NoError(t, err)
+		assert.Equal(t, id, m.ID)
+	})
+}
+
+func BenchmarkIDScheme_NewID(b *testing.B) {
+	for _, scheme := range []stowry.IDScheme{stowry.IDSchemeUUIDv4, stowry.IDSchemeUUIDv7, stowry.IDSchemeULID} {
+		b.Run(string(scheme), func(b *testing.B) {
+			for b.Loop() {
+				_, _ = scheme.NewID()
+			}
+		})
+	}
+}
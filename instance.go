@@ -0,0 +1,109 @@
+package stowry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+)
+
+// InstanceFile is the file in the storage root holding the ID of the
+// instance the stored files belong to. Populate and CollectOrphans skip it,
+// and no object can be created at its path.
+const InstanceFile = ".stowry-instance"
+
+// InstanceRepo is an optional MetaDataRepo extension that records which
+// instance the metadata belongs to. BindInstance checks for it with a type
+// assertion.
+type InstanceRepo interface {
+	// InstanceID returns the recorded instance ID.
+	//
+	// Returns:
+	//   - string: The instance ID
+	//   - error: ErrNotFound if no ID is recorded, or other database errors
+	InstanceID(ctx context.Context) (string, error)
+
+	// SetInstanceID records id, replacing any recorded ID.
+	SetInstanceID(ctx context.Context, id string) error
+}
+
+// InstanceMarker reads and writes the instance ID kept with the stored files.
+type InstanceMarker interface {
+	// ReadInstanceID returns the instance ID, or ErrNotFound if there is none.
+	ReadInstanceID(ctx context.Context) (string, error)
+
+	// WriteInstanceID replaces the instance ID with id.
+	WriteInstanceID(ctx context.Context, id string) error
+}
+
+// InstanceMismatchError reports a database and storage directory that were
+// not set up together. It wraps ErrInstanceMismatch.
+type InstanceMismatchError struct {
+	Database string // ID recorded in the database; empty if none
+	Storage  string // ID in the storage directory; empty if none
+}
+
+func (e *InstanceMismatchError) Error() string {
+	switch {
+	case e.Database == "":
+		return fmt.Sprintf("%s: storage belongs to instance %s but the database records no instance", ErrInstanceMismatch, e.Storage)
+	case e.Storage == "":
+		return fmt.Sprintf("%s: database belongs to instance %s but storage has no %s file", ErrInstanceMismatch, e.Database, InstanceFile)
+	default:
+		return fmt.Sprintf("%s: storage belongs to instance %s but the database to instance %s", ErrInstanceMismatch, e.Storage, e.Database)
+	}
+}
+
+func (e *InstanceMismatchError) Unwrap() error {
+	return ErrInstanceMismatch
+}
+
+// InstanceBinding is the outcome of BindInstance.
+type InstanceBinding struct {
+	ID      string
+	Created bool // neither side had an ID, so a new one was written to both
+	Rebound bool // the sides disagreed and were paired under a new ID
+}
+
+// BindInstance checks that the database and storage directory belong to the
+// same instance. On first start, when neither records an ID, it pairs them
+// under a new one. The marker is written before the database, so an
+// interrupted first start leaves a mismatch rather than an unchecked pair.
+//
+// When they disagree, including when only one side has an ID, it returns an
+// *InstanceMismatchError, unless rebind is set: then both are paired under a
+// new ID, so any other database or storage directory that was paired with
+// either side no longer matches it.
+func BindInstance(ctx context.Context, repo InstanceRepo, marker InstanceMarker, rebind bool) (InstanceBinding, error) {
+	dbID, err := repo.InstanceID(ctx)
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		return InstanceBinding{}, fmt.Errorf("bind instance: %w", err)
+	}
+
+	storageID, err := marker.ReadInstanceID(ctx)
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		return InstanceBinding{}, fmt.Errorf("bind instance: %w", err)
+	}
+
+	var binding InstanceBinding
+	switch {
+	case dbID != "" && dbID == storageID:
+		return InstanceBinding{ID: dbID}, nil
+	case dbID == "" && storageID == "":
+		binding.Created = true
+	case !rebind:
+		return InstanceBinding{}, &InstanceMismatchError{Database: dbID, Storage: storageID}
+	default:
+		binding.Rebound = true
+	}
+
+	binding.ID = uuid.NewString()
+	if err := marker.WriteInstanceID(ctx, binding.ID); err != nil {
+		return InstanceBinding{}, fmt.Errorf("bind instance: %w", err)
+	}
+	if err := repo.SetInstanceID(ctx, binding.ID); err != nil {
+		return InstanceBinding{}, fmt.Errorf("bind instance: %w", err)
+	}
+	return binding, nil
+}
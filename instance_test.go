@@ -0,0 +1,134 @@
+package stowry_test
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"testing"
+
+	"github.com/sagarc03/stowry"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// memInstanceID is an instance ID slot standing in for both the database
+// and the storage marker. An empty id means none is recorded.
+type memInstanceID struct {
+	id       string
+	writeErr error
+	writes   int
+}
+
+func (m *memInstanceID) get() (string, error) {
+	if m.id == "" {
+		return "", stowry.ErrNotFound
+	}
+	return m.id, nil
+}
+
+func (m *memInstanceID) set(id string) error {
+	if m.writeErr != nil {
+		return m.writeErr
+	}
+	m.id = id
+	m.writes++
+	return nil
+}
+
+type memInstanceRepo struct{ memInstanceID }
+
+func (r *memInstanceRepo) InstanceID(context.Context) (string, error) { return r.get() }
+
+func (r *memInstanceRepo) SetInstanceID(_ context.Context, id string) error { return r.set(id) }
+
+type memInstanceMarker struct{ memInstanceID }
+
+func (m *memInstanceMarker) ReadInstanceID(context.Context) (string, error) { return m.get() }
+
+func (m *memInstanceMarker) WriteInstanceID(_ context.Context, id string) error { return m.set(id) }
+
+func TestBindInstance(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("fresh start pairs both sides", func(t *testing.T) {
+		repo, marker := &memInstanceRepo{}, &memInstanceMarker{}
+
+		binding, err := stowry.BindInstance(ctx, repo, marker, false)
+
+		require.NoError(t, err)
+		assert.True(t, binding.Created)
+		assert.NotEmpty(t, binding.ID)
+		assert.Equal(t, binding.ID, repo.id)
+		assert.Equal(t, binding.ID, marker.id)
+	})
+
+	t.Run("matched restart writes nothing", func(t *testing.T) {
+		repo := &memInstanceRepo{memInstanceID{id: "abc"}}
+		marker := &memInstanceMarker{memInstanceID{id: "abc"}}
+
+		binding, err := stowry.BindInstance(ctx, repo, marker, false)
+
+		require.NoError(t, err)
+		assert.Equal(t, stowry.InstanceBinding{ID: "abc"}, binding)
+		assert.Zero(t, repo.writes+marker.writes)
+	})
+
+	mismatches := []struct {
+		name    string
+		db      string
+		storage string
+		wantMsg string
+	}{
+		{name: "different instances", db: "db-id", storage: "storage-id", wantMsg: "storage belongs to instance storage-id but the database to instance db-id"},
+		{name: "fresh database on used storage", storage: "storage-id", wantMsg: "the database records no instance"},
+		{name: "used database on fresh storage", db: "db-id", wantMsg: "storage has no .stowry-instance file"},
+	}
+
+	for _, tt := range mismatches {
+		t.Run("refuses "+tt.name, func(t *testing.T) {
+			repo := &memInstanceRepo{memInstanceID{id: tt.db}}
+			marker := &memInstanceMarker{memInstanceID{id: tt.storage}}
+
+			_, err := stowry.BindInstance(ctx, repo, marker, false)
+
+			assert.ErrorIs(t, err, stowry.ErrInstanceMismatch)
+			var mismatch *stowry.InstanceMismatchError
+			require.ErrorAs(t, err, &mismatch)
+			assert.Equal(t, stowry.InstanceMismatchError{Database: tt.db, Storage: tt.storage}, *mismatch)
+			assert.ErrorContains(t, err, tt.wantMsg)
+			assert.Zero(t, repo.writes+marker.writes)
+		})
+
+		t.Run("rebinds "+tt.name, func(t *testing.T) {
+			repo := &memInstanceRepo{memInstanceID{id: tt.db}}
+			marker := &memInstanceMarker{memInstanceID{id: tt.storage}}
+
+			binding, err := stowry.BindInstance(ctx, repo, marker, true)
+
+			require.NoError(t, err)
+			assert.True(t, binding.Rebound)
+			assert.NotContains(t, []string{tt.db, tt.storage}, binding.ID, "a new ID unpairs any old partner")
+			assert.Equal(t, binding.ID, repo.id)
+			assert.Equal(t, binding.ID, marker.id)
+		})
+	}
+
+	t.Run("interrupted first start is a mismatch", func(t *testing.T) {
+		repo := &memInstanceRepo{memInstanceID{writeErr: io.ErrClosedPipe}}
+		marker := &memInstanceMarker{}
+
+		_, err := stowry.BindInstance(ctx, repo, marker, false)
+		assert.ErrorIs(t, err, io.ErrClosedPipe)
+
+		repo.writeErr = nil
+		_, err = stowry.BindInstance(ctx, repo, marker, false)
+		assert.ErrorIs(t, err, stowry.ErrInstanceMismatch)
+	})
+}
+
+func TestStowryService_Create_InstanceFileReserved(t *testing.T) {
+	service, _, _ := NewStowryService(t)
+
+	_, err := service.Create(context.Background(), stowry.CreateObject{Path: stowry.InstanceFile, ContentType: "text/plain"}, bytes.NewReader(nil))
+	assert.ErrorIs(t, err, stowry.ErrInvalidInput)
+}
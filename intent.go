@@ -0,0 +1,286 @@
+package stowry
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// WriteIntent records a Create between its storage write and its metadata
+// commit. A process that dies in between leaves the intent behind, so
+// RecoverIntents can find the file without a full orphan sweep.
+type WriteIntent struct {
+	ID              uuid.UUID
+	Path            string
+	ContentType     string
+	ContentEncoding string
+	Filename        string
+	UserMetadata    map[string]string
+	CacheControl    string
+	ObjectID        uuid.UUID // Client-supplied object ID; uuid.Nil when generated
+	StartedAt       time.Time
+}
+
+// IntentRepo is an optional MetaDataRepo extension for the write intent log.
+// The service checks for it with a type assertion.
+type IntentRepo interface {
+	// RecordWriteIntent records intent with a fresh ID and StartedAt.
+	//
+	// Returns:
+	//   - WriteIntent: The recorded intent
+	//   - error: Any database error
+	RecordWriteIntent(ctx context.Context, intent WriteIntent) (WriteIntent, error)
+
+	// ClearWriteIntent removes an intent. Clearing an unknown intent is not
+	// an error.
+	ClearWriteIntent(ctx context.Context, id uuid.UUID) error
+
+	// WriteIntents returns the intents started before before, oldest first.
+	WriteIntents(ctx context.Context, before time.Time) ([]WriteIntent, error)
+}
+
+// IntentReport summarizes a RecoverIntents run.
+type IntentReport struct {
+	Scanned    int // Intents older than the minimum age
+	NotWritten int // No file was stored
+	Committed  int // The metadata already matched the stored file
+	Completed  int // Metadata was upserted for the stored file
+	Removed    int // The stored file could not be committed and was deleted
+}
+
+// intentRepo returns the repository's intent log, or nil when the log is
+// disabled. NewStowryService refuses to enable it for a repository without one.
+func (s *StowryService) intentRepo() IntentRepo {
+	if !s.writeIntents {
+		return nil
+	}
+	repo, _ := s.repo.(IntentRepo)
+	return repo
+}
+
+// createObject writes obj to storage and commits its metadata. With the
+// intent log enabled, the write is bracketed by a write intent: recorded
+// before the file is written and cleared once the metadata is committed.
+// An intent left by a failed commit is cleared by RecoverIntents.
+func (s *StowryService) createObject(ctx context.Context, obj CreateObject, content io.Reader) (CreateResult, error) {
+	return s.createObjectWith(ctx, obj, func(obj CreateObject) (ObjectEntry, error) {
+		return s.storeObject(ctx, obj.Path, obj, content)
+	})
+}
+
+// createObjectWith is createObject with store writing the prepared obj to
+// storage in place of storeObject.
+func (s *StowryService) createObjectWith(ctx context.Context, obj CreateObject, store func(CreateObject) (ObjectEntry, error)) (CreateResult, error) {
+	obj, err := s.prepareCreateObject(obj)
+	if err != nil {
+		return CreateResult{}, err
+	}
+	if err := s.checkObjectID(ctx, obj); err != nil {
+		return CreateResult{}, err
+	}
+
+	var intent WriteIntent
+	intents := s.intentRepo()
+	if intents != nil {
+		intent, err = intents.RecordWriteIntent(ctx, WriteIntent{
+			Path:            obj.Path,
+			ContentType:     obj.ContentType,
+			ContentEncoding: obj.ContentEncoding,
+			Filename:        obj.Filename,
+			UserMetadata:    obj.UserMetadata,
+			CacheControl:    obj.CacheControl,
+			ObjectID:        obj.ID,
+		})
+		if err != nil {
+			return CreateResult{}, fmt.Errorf("create object %s: record write intent: %w", obj.Path, err)
+		}
+	}
+
+	oe, err := store(obj)
+	if err != nil {
+		// Storage discards a failed write, so nothing is left to recover
+		if intents != nil {
+			s.clearIntent(intents, intent)
+		}
+		return CreateResult{}, err
+	}
+
+	res, err := s.commitObject(ctx, oe)
+	if intents != nil && (err == nil || errors.Is(err, ErrConflict)) {
+		s.clearIntent(intents, intent)
+	}
+	return res, err
+}
+
+// checkObjectID rejects a client-supplied ID that differs from the ID of the
+// live object at obj.Path. Writing the content first would replace that
+// object's file while its metadata, rejected by Upsert, stays.
+func (s *StowryService) checkObjectID(ctx context.Context, obj CreateObject) error {
+	if obj.ID == uuid.Nil {
+		return nil
+	}
+	current, err := s.repo.Get(ctx, obj.Path)
+	switch {
+	case errors.Is(err, ErrNotFound):
+		return nil
+	case err != nil:
+		return fmt.Errorf("create object %s: %w", obj.Path, err)
+	case current.ID != obj.ID:
+		return fmt.Errorf("create object %s: object has id %s: %w", obj.Path, current.ID, ErrIDConflict)
+	}
+	return nil
+}
+
+// clearIntent removes a finished intent. A failure only delays the cleanup
+// to the next RecoverIntents, so it is logged instead of failing the write.
+func (s *StowryService) clearIntent(intents IntentRepo, intent WriteIntent) {
+	// The write is done, so the cleanup must not depend on the request context
+	ctx, cancel := context.WithTimeout(context.Background(), s.cleanupTimeout)
+	defer cancel()
+
+	if err := intents.ClearWriteIntent(ctx, intent.ID); err != nil {
+		slog.Warn("clear write intent failed", "path", intent.Path, "intent", intent.ID, "err", err)
+	}
+}
+
+// RecoverIntents reconciles the write intents started more than minAge ago,
+// left behind by writes interrupted between storage and metadata. For each
+// intent:
+//   - Without a stored file, nothing was written and the intent is cleared
+//   - With metadata whose ETag matches the stored file, the write finished
+//   - Otherwise the metadata is upserted from the stored file and the
+//     intent, as the write would have done
+//   - A stored file that cannot be committed, because its content does not
+//     decode or the metadata upsert fails, is deleted
+//
+// Intents are reconciled even when ServiceConfig.WriteIntents is off, so
+// the intents of an earlier run are not left behind. minAge keeps intents
+// of writes still in progress on other instances out of the run.
+//
+// Parameters:
+//   - ctx: Context for cancellation and timeout
+//   - minAge: How long ago an intent must have been started to be reconciled
+//
+// Returns:
+//   - IntentReport: Intents handled so far, also on error
+//   - error: ErrNotSupported when the repository has no intent log, or
+//     storage and database errors
+func (s *StowryService) RecoverIntents(ctx context.Context, minAge time.Duration) (IntentReport, error) {
+	var report IntentReport
+
+	intents, ok := s.repo.(IntentRepo)
+	if !ok {
+		return report, fmt.Errorf("recover intents: metadata repository has no intent log: %w", ErrNotSupported)
+	}
+
+	pending, err := intents.WriteIntents(ctx, time.Now().Add(-minAge))
+	if err != nil {
+		return report, fmt.Errorf("recover intents: %w", err)
+	}
+
+	for _, intent := range pending {
+		if err := ctx.Err(); err != nil {
+			return report, fmt.Errorf("recover intents: %w", err)
+		}
+		report.Scanned++
+		if err := s.recoverIntent(ctx, intent, &report); err != nil {
+			return report, fmt.Errorf("recover intents: %s: %w", intent.Path, err)
+		}
+		if err := intents.ClearWriteIntent(ctx, intent.ID); err != nil {
+			return report, fmt.Errorf("recover intents: %s: %w", intent.Path, err)
+		}
+	}
+
+	return report, nil
+}
+
+// recoverIntent brings the metadata of intent.Path in line with its stored
+// file and counts the outcome in report.
+func (s *StowryService) recoverIntent(ctx context.Context, intent WriteIntent, report *IntentReport) error {
+	oe, err := s.describeStored(ctx, intent)
+	switch {
+	case errors.Is(err, ErrNotFound):
+		report.NotWritten++
+		return nil
+	case errors.Is(err, ErrInvalidEncodedContent):
+		if err := s.storageDelete(ctx, intent.Path); err != nil && !errors.Is(err, ErrNotFound) {
+			return fmt.Errorf("delete stored file: %w", err)
+		}
+		report.Removed++
+		return nil
+	case err != nil:
+		return err
+	}
+
+	current, err := s.repo.Get(ctx, intent.Path)
+	switch {
+	case err == nil && current.Etag == oe.ETag:
+		report.Committed++
+		return nil
+	case err != nil && !errors.Is(err, ErrNotFound):
+		return err
+	}
+
+	// commitObject deletes the file when the upsert fails
+	if _, err := s.commitObject(ctx, oe); err != nil && !errors.Is(err, ErrConflict) {
+		report.Removed++
+		slog.Warn("write intent not recoverable, stored file removed", "path", intent.Path, "err", err)
+		return nil
+	}
+	report.Completed++
+	return nil
+}
+
+// describeStored reads the file stored for intent and returns the entry
+// storeObject would have returned for it.
+//
+// Returns:
+//   - ObjectEntry: The entry for the stored file
+//   - error: ErrNotFound if no file is stored, ErrInvalidEncodedContent if
+//     it does not decode with the intent's content encoding, or storage errors
+func (s *StowryService) describeStored(ctx context.Context, intent WriteIntent) (ObjectEntry, error) {
+	content, err := s.storageGet(ctx, intent.Path)
+	if err != nil {
+		return ObjectEntry{}, err
+	}
+	defer func() { _ = content.Close() }()
+
+	var r io.Reader = content
+	var decoder *decodingReader
+	if intent.ContentEncoding != "" {
+		decoder = newDecodingReader(content)
+		defer decoder.close()
+		r = decoder
+	}
+
+	h := sha256.New()
+	size, err := io.Copy(h, r)
+	if err != nil {
+		return ObjectEntry{}, fmt.Errorf("read stored file: %w", err)
+	}
+
+	oe := ObjectEntry{
+		ID:              intent.ObjectID,
+		Path:            intent.Path,
+		Size:            size,
+		ETag:            hex.EncodeToString(h.Sum(nil)),
+		ContentType:     intent.ContentType,
+		ContentEncoding: intent.ContentEncoding,
+		Filename:        intent.Filename,
+		UserMetadata:    intent.UserMetadata,
+		CacheControl:    intent.CacheControl,
+		StorageBackend:  s.storageBackend(intent.Path),
+	}
+	if decoder != nil {
+		decodedSize := decoder.decodedSize()
+		oe.DecodedSize = &decodedSize
+	}
+	return oe, nil
+}
@@ -0,0 +1,282 @@
+package stowry_test
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sagarc03/stowry"
+	"github.com/sagarc03/stowry/database/sqlite"
+	"github.com/sagarc03/stowry/filesystem"
+	"github.com/sagarc03/stowry/tracing"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// errCrash is the panic a failpoint raises to stop a write the way a killed
+// process would: no cleanup runs after it.
+var errCrash = errors.New("simulated crash")
+
+type intentMetaDataRepo interface {
+	stowry.MetaDataRepo
+	stowry.IntentRepo
+}
+
+// crashRepo panics in the call named by crashAt: "upsert" or "clear".
+type crashRepo struct {
+	intentMetaDataRepo
+	crashAt string
+}
+
+func (r crashRepo) Upsert(ctx context.Context, entry stowry.ObjectEntry) (stowry.MetaData, *stowry.MetaData, error) {
+	if r.crashAt == "upsert" {
+		panic(errCrash)
+	}
+	return r.intentMetaDataRepo.Upsert(ctx, entry)
+}
+
+func (r crashRepo) ClearWriteIntent(ctx context.Context, id uuid.UUID) error {
+	if r.crashAt == "clear" {
+		panic(errCrash)
+	}
+	return r.intentMetaDataRepo.ClearWriteIntent(ctx, id)
+}
+
+// crashReader panics once its content has been read halfway.
+type crashReader struct {
+	content []byte
+	read    int
+}
+
+func (r *crashReader) Read(p []byte) (int, error) {
+	if r.read >= len(r.content)/2 {
+		panic(errCrash)
+	}
+	n := copy(p, r.content[r.read:len(r.content)/2])
+	r.read += n
+	return n, nil
+}
+
+// intentEnv is a store-mode service over SQLite and a storage directory that
+// survive a simulated crash, so a new service can recover them.
+type intentEnv struct {
+	repo    intentMetaDataRepo
+	storage *filesystem.Store
+	root    *os.Root
+}
+
+func newIntentEnv(t *testing.T) intentEnv {
+	t.Helper()
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	db, err := sqlite.Connect(ctx, filepath.Join(dir, "stowry.db"), stowry.Tables{MetaData: "stowry_metadata"})
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = db.Close() })
+	require.NoError(t, db.Migrate(ctx))
+
+	require.NoError(t, os.Mkdir(filepath.Join(dir, "storage"), 0o755))
+	root, err := os.OpenRoot(filepath.Join(dir, "storage"))
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = root.Close() })
+
+	return intentEnv{
+		repo:    db.GetRepo().(intentMetaDataRepo),
+		storage: filesystem.NewFileStorage(root),
+		root:    root,
+	}
+}
+
+// service returns a service over the environment's repository, as wrapped
+// by repo when it is not nil.
+func (e intentEnv) service(t *testing.T, repo stowry.MetaDataRepo, writeIntents bool) *stowry.StowryService {
+	t.Helper()
+	if repo == nil {
+		repo = e.repo
+	}
+	s, err := stowry.NewStowryService(repo, e.storage, stowry.ServiceConfig{Mode: stowry.ModeStore, WriteIntents: writeIntents})
+	require.NoError(t, err)
+	return s
+}
+
+// restart recovers the environment with a fresh service and checks that no
+// intents or orphaned files survive.
+func (e intentEnv) restart(t *testing.T) stowry.IntentReport {
+	t.Helper()
+	ctx := context.Background()
+	service := e.service(t, nil, true)
+
+	report, err := service.RecoverIntents(ctx, 0)
+	require.NoError(t, err)
+
+	left, err := e.repo.WriteIntents(ctx, time.Now().Add(time.Minute))
+	require.NoError(t, err)
+	assert.Empty(t, left, "recovery clears every intent")
+
+	orphans, err := service.CollectOrphans(ctx, stowry.OrphanOptions{})
+	require.NoError(t, err)
+	assert.Zero(t, orphans.Orphans, "no orphaned files: %v", orphans.Paths)
+	return report
+}
+
+func sha256Hex(s string) string {
+	sum := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
+
+func TestStowryService_WriteIntents(t *testing.T) {
+	ctx := context.Background()
+	obj := stowry.CreateObject{Path: "a.txt", ContentType: "text/plain", Filename: "A.txt"}
+
+	t.Run("refused without an intent log", func(t *testing.T) {
+		env := newIntentEnv(t)
+
+		_, err := stowry.NewStowryService(struct{ stowry.MetaDataRepo }{env.repo}, env.storage,
+			stowry.ServiceConfig{Mode: stowry.ModeStore, WriteIntents: true})
+		assert.ErrorIs(t, err, stowry.ErrNotSupported)
+	})
+
+	t.Run("kept through tracing", func(t *testing.T) {
+		env := newIntentEnv(t)
+		service := env.service(t, tracing.WrapRepo(crashRepo{intentMetaDataRepo: env.repo, crashAt: "upsert"}), true)
+
+		assert.PanicsWithValue(t, errCrash, func() {
+			_, _ = service.Create(ctx, obj, strings.NewReader("hello"))
+		})
+
+		left, err := env.repo.WriteIntents(ctx, time.Now().Add(time.Minute))
+		require.NoError(t, err)
+		assert.Len(t, left, 1)
+	})
+
+	t.Run("a finished create leaves no intent", func(t *testing.T) {
+		env := newIntentEnv(t)
+
+		_, err := env.service(t, nil, true).Create(ctx, obj, strings.NewReader("hello"))
+		require.NoError(t, err)
+
+		left, err := env.repo.WriteIntents(ctx, time.Now().Add(time.Minute))
+		require.NoError(t, err)
+		assert.Empty(t, left)
+	})
+
+	t.Run("crash during the storage write", func(t *testing.T) {
+		env := newIntentEnv(t)
+		service := env.service(t, nil, true)
+
+		assert.PanicsWithValue(t, errCrash, func() {
+			_, _ = service.Create(ctx, obj, &crashReader{content: []byte("hello world")})
+		})
+
+		report := env.restart(t)
+		assert.Equal(t, stowry.IntentReport{Scanned: 1, NotWritten: 1}, report)
+		_, err := env.repo.Get(ctx, "a.txt")
+		assert.ErrorIs(t, err, stowry.ErrNotFound)
+	})
+
+	t.Run("crash before the metadata commit", func(t *testing.T) {
+		env := newIntentEnv(t)
+		service := env.service(t, crashRepo{intentMetaDataRepo: env.repo, crashAt: "upsert"}, true)
+
+		assert.PanicsWithValue(t, errCrash, func() {
+			_, _ = service.Create(ctx, obj, strings.NewReader("hello"))
+		})
+		_, err := env.repo.Get(ctx, "a.txt")
+		require.ErrorIs(t, err, stowry.ErrNotFound, "the file is stored without metadata")
+
+		report := env.restart(t)
+		assert.Equal(t, stowry.IntentReport{Scanned: 1, Completed: 1}, report)
+		m, err := env.repo.Get(ctx, "a.txt")
+		require.NoError(t, err)
+		assert.Equal(t, sha256Hex("hello"), m.Etag)
+		assert.Equal(t, int64(5), m.FileSizeBytes)
+		assert.Equal(t, "text/plain", m.ContentType)
+		assert.Equal(t, "A.txt", m.Filename)
+	})
+
+	t.Run("crash before the metadata commit of an overwrite", func(t *testing.T) {
+		env := newIntentEnv(t)
+		_, err := env.service(t, nil, true).Create(ctx, obj, strings.NewReader("old"))
+		require.NoError(t, err)
+		service := env.service(t, crashRepo{intentMetaDataRepo: env.repo, crashAt: "upsert"}, true)
+
+		assert.PanicsWithValue(t, errCrash, func() {
+			_, _ = service.Create(ctx, obj, strings.NewReader("new content"))
+		})
+
+		report := env.restart(t)
+		assert.Equal(t, stowry.IntentReport{Scanned: 1, Completed: 1}, report)
+		m, err := env.repo.Get(ctx, "a.txt")
+		require.NoError(t, err)
+		assert.Equal(t, sha256Hex("new content"), m.Etag, "the metadata describes the stored file")
+	})
+
+	t.Run("crash after the metadata commit", func(t *testing.T) {
+		env := newIntentEnv(t)
+		service := env.service(t, crashRepo{intentMetaDataRepo: env.repo, crashAt: "clear"}, true)
+
+		assert.PanicsWithValue(t, errCrash, func() {
+			_, _ = service.Create(ctx, obj, strings.NewReader("hello"))
+		})
+
+		report := env.restart(t)
+		assert.Equal(t, stowry.IntentReport{Scanned: 1, Committed: 1}, report)
+		m, err := env.repo.Get(ctx, "a.txt")
+		require.NoError(t, err)
+		assert.Equal(t, sha256Hex("hello"), m.Etag)
+	})
+
+	t.Run("stored file that does not decode is removed", func(t *testing.T) {
+		env := newIntentEnv(t)
+		_, err := env.repo.RecordWriteIntent(ctx, stowry.WriteIntent{Path: "a.txt.gz", ContentType: "text/plain", ContentEncoding: "gzip"})
+		require.NoError(t, err)
+		_, err = env.storage.Write(ctx, "a.txt.gz", strings.NewReader("not gzip"))
+		require.NoError(t, err)
+
+		report := env.restart(t)
+		assert.Equal(t, stowry.IntentReport{Scanned: 1, Removed: 1}, report)
+		_, err = env.root.Stat("a.txt.gz")
+		assert.ErrorIs(t, err, os.ErrNotExist)
+	})
+
+	t.Run("recent intents are left alone", func(t *testing.T) {
+		env := newIntentEnv(t)
+		_, err := env.repo.RecordWriteIntent(ctx, stowry.WriteIntent{Path: "a.txt", ContentType: "text/plain"})
+		require.NoError(t, err)
+
+		report, err := env.service(t, nil, true).RecoverIntents(ctx, time.Hour)
+		require.NoError(t, err)
+		assert.Zero(t, report.Scanned)
+		left, err := env.repo.WriteIntents(ctx, time.Now().Add(time.Minute))
+		require.NoError(t, err)
+		assert.Len(t, left, 1)
+	})
+
+	t.Run("disabled intent log records nothing", func(t *testing.T) {
+		env := newIntentEnv(t)
+		service := env.service(t, crashRepo{intentMetaDataRepo: env.repo, crashAt: "upsert"}, false)
+
+		assert.PanicsWithValue(t, errCrash, func() {
+			_, _ = service.Create(ctx, obj, strings.NewReader("hello"))
+		})
+
+		left, err := env.repo.WriteIntents(ctx, time.Now().Add(time.Minute))
+		require.NoError(t, err)
+		assert.Empty(t, left)
+	})
+
+	t.Run("repository without an intent log", func(t *testing.T) {
+		service, err := stowry.NewStowryService(new(SpyMetaDataRepo), new(SpyFileStorage), stowry.ServiceConfig{Mode: stowry.ModeStore})
+		require.NoError(t, err)
+
+		_, err = service.RecoverIntents(ctx, 0)
+		assert.ErrorIs(t, err, stowry.ErrNotSupported)
+	})
+}
@@ -4,3 +4,7 @@ import "errors"
 
 // ErrKeyNotFound is returned when the access key does not exist in the store.
 var ErrKeyNotFound = errors.New("access key not found")
+
+// ErrKeyRecentlyUsed is returned when revoking an access key used within the
+// revoke window.
+var ErrKeyRecentlyUsed = errors.New("access key was used within the revoke window")
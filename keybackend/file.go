@@ -27,6 +27,11 @@ func LoadKeysFromFile(path string) (map[string]string, error) {
 		return nil, fmt.Errorf("read keys file: %w", err)
 	}
 
+	return parseKeys(data)
+}
+
+// parseKeys parses the content of a keys file.
+func parseKeys(data []byte) (map[string]string, error) {
 	var pairs []KeyPair
 	if err := json.Unmarshal(data, &pairs); err != nil {
 		return nil, fmt.Errorf("parse keys file: %w", err)
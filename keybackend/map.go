@@ -1,24 +1,48 @@
 // Package keybackend provides SecretStore implementations for key retrieval.
 package keybackend
 
-// MapSecretStore retrieves keys from an in-memory map.
-// Suitable for configuration file-based key storage. It is safe for concurrent
-// use provided that the underlying map is not modified after initialization, as
-// MapSecretStore only performs read operations on the map.
+import (
+	"maps"
+	"slices"
+	"sync/atomic"
+)
+
+// MapSecretStore retrieves keys from an in-memory map. Suitable for
+// configuration file-based key storage. It is safe for concurrent use; Replace
+// swaps in a new set of keys atomically, so a lookup sees either the old or the
+// new set, never a mix. The maps it holds must not be modified after they are
+// handed to it.
 type MapSecretStore struct {
-	keys map[string]string
+	keys atomic.Pointer[map[string]string]
 }
 
 // NewMapSecretStore creates a new map-based secret store with the given access key to secret key mapping.
 func NewMapSecretStore(keys map[string]string) *MapSecretStore {
-	return &MapSecretStore{keys: keys}
+	s := &MapSecretStore{}
+	s.keys.Store(&keys)
+	return s
 }
 
 // Lookup retrieves the secret key for the given access key from the map.
 func (s *MapSecretStore) Lookup(accessKey string) (string, error) {
-	secretKey, found := s.keys[accessKey]
+	secretKey, found := (*s.keys.Load())[accessKey]
 	if !found {
 		return "", ErrKeyNotFound
 	}
 	return secretKey, nil
 }
+
+// Len returns the number of access keys in the store.
+func (s *MapSecretStore) Len() int {
+	return len(*s.keys.Load())
+}
+
+// AccessKeys returns the access keys in the store, sorted.
+func (s *MapSecretStore) AccessKeys() []string {
+	return slices.Sorted(maps.Keys(*s.keys.Load()))
+}
+
+// Replace makes keys the store's access keys, replacing all previous ones.
+func (s *MapSecretStore) Replace(keys map[string]string) {
+	s.keys.Store(&keys)
+}
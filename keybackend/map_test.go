@@ -66,3 +66,26 @@ func TestMapSecretStore_Lookup(t *testing.T) {
 		})
 	}
 }
+
+func TestMapSecretStore_Len(t *testing.T) {
+	assert.Equal(t, 0, keybackend.NewMapSecretStore(map[string]string{}).Len())
+	assert.Equal(t, 2, keybackend.NewMapSecretStore(map[string]string{"a": "1", "b": "2"}).Len())
+}
+
+func TestMapSecretStore_AccessKeys(t *testing.T) {
+	assert.Empty(t, keybackend.NewMapSecretStore(map[string]string{}).AccessKeys())
+	assert.Equal(t, []string{"a", "b"}, keybackend.NewMapSecretStore(map[string]string{"b": "2", "a": "1"}).AccessKeys())
+}
+
+func TestMapSecretStore_Replace(t *testing.T) {
+	store := keybackend.NewMapSecretStore(map[string]string{"old": "secret"})
+
+	store.Replace(map[string]string{"new": "secret"})
+
+	_, err := store.Lookup("old")
+	require.ErrorIs(t, err, keybackend.ErrKeyNotFound)
+	secret, err := store.Lookup("new")
+	require.NoError(t, err)
+	assert.Equal(t, "secret", secret)
+	assert.Equal(t, 1, store.Len())
+}
@@ -0,0 +1,214 @@
+package keybackend
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base32"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+
+	"github.com/sagarc03/stowry"
+)
+
+// GenerateKeyPair returns a new random key pair: a 20 character access key
+// starting with AK, and a 40 character secret key.
+func GenerateKeyPair() (KeyPair, error) {
+	b := make([]byte, 42)
+	if _, err := rand.Read(b); err != nil {
+		return KeyPair{}, fmt.Errorf("generate key pair: %w", err)
+	}
+	return KeyPair{
+		AccessKey: "AK" + base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(b[:12])[:18],
+		SecretKey: base64.RawURLEncoding.EncodeToString(b[12:]),
+	}, nil
+}
+
+// KeyRotator replaces and revokes access keys through KeysConfig.ExtraFile,
+// recording each step in an AccessKeyRepo. A server watching the file picks
+// the changes up without a restart, so a key is rotated in three steps:
+//
+//  1. Rotate adds a new key to the file and deprecates the old one, which
+//     still authenticates and logs a warning on each use.
+//  2. Clients move to the new key.
+//  3. Revoke removes the old key from the file, refusing while it was used
+//     within the revoke window.
+type KeyRotator struct {
+	cfg    KeysConfig
+	repo   stowry.AccessKeyRepo
+	window time.Duration
+	now    func() time.Time
+}
+
+// NewKeyRotator returns a KeyRotator for the keys of cfg, whose ExtraFile
+// must be set.
+func NewKeyRotator(cfg KeysConfig, repo stowry.AccessKeyRepo) (*KeyRotator, error) {
+	if cfg.ExtraFile == "" {
+		return nil, errors.New("rotate keys: extra keys file is not set")
+	}
+	return &KeyRotator{
+		cfg:    cfg,
+		repo:   repo,
+		window: time.Duration(cfg.RevokeWindow) * time.Second,
+		now:    time.Now,
+	}, nil
+}
+
+// Rotate creates a key to replace accessKey, adds it to the extra keys file
+// and records accessKey as deprecated. accessKey may come from any key
+// source, and must not already be deprecated or revoked.
+//
+// Returns:
+//   - KeyPair: The new key, whose secret is not stored anywhere else
+//   - error: ErrKeyNotFound if accessKey is not configured, or any file or
+//     database error; the file is left as it was then
+func (r *KeyRotator) Rotate(ctx context.Context, accessKey string) (KeyPair, error) {
+	base, err := loadBaseKeys(r.cfg)
+	if err != nil {
+		return KeyPair{}, fmt.Errorf("rotate %s: %w", accessKey, err)
+	}
+	pairs, err := readKeyPairs(r.cfg.ExtraFile)
+	if err != nil {
+		return KeyPair{}, fmt.Errorf("rotate %s: %w", accessKey, err)
+	}
+	if _, ok := base[accessKey]; !ok && !slices.ContainsFunc(pairs, hasAccessKey(accessKey)) {
+		return KeyPair{}, fmt.Errorf("rotate %s: %w", accessKey, ErrKeyNotFound)
+	}
+
+	info, err := r.info(ctx, accessKey)
+	if err != nil {
+		return KeyPair{}, fmt.Errorf("rotate %s: %w", accessKey, err)
+	}
+	switch info.Status() {
+	case stowry.AccessKeyDeprecated:
+		return KeyPair{}, fmt.Errorf("rotate %s: already replaced by %s", accessKey, info.ReplacedBy)
+	case stowry.AccessKeyRevoked:
+		return KeyPair{}, fmt.Errorf("rotate %s: key is revoked", accessKey)
+	}
+
+	pair, err := GenerateKeyPair()
+	if err != nil {
+		return KeyPair{}, fmt.Errorf("rotate %s: %w", accessKey, err)
+	}
+	if err := writeKeyPairs(r.cfg.ExtraFile, append(slices.Clip(pairs), pair)); err != nil {
+		return KeyPair{}, fmt.Errorf("rotate %s: %w", accessKey, err)
+	}
+	if err := r.repo.RotateAccessKey(ctx, accessKey, pair.AccessKey, r.now().UTC()); err != nil {
+		if restoreErr := writeKeyPairs(r.cfg.ExtraFile, pairs); restoreErr != nil {
+			err = errors.Join(err, fmt.Errorf("restore extra keys file: %w", restoreErr))
+		}
+		return KeyPair{}, fmt.Errorf("rotate %s: %w", accessKey, err)
+	}
+	return pair, nil
+}
+
+// Revoke removes accessKey from the extra keys file and records it as
+// revoked. Unless force is set, it refuses a key used within the revoke
+// window. Use recorded by a server is only seen once flushed, so the window
+// should be well above the usage flush interval.
+//
+// Returns:
+//   - error: ErrKeyRecentlyUsed if the key was used within the window,
+//     ErrKeyNotFound if it is not in the extra keys file, or any file or
+//     database error
+func (r *KeyRotator) Revoke(ctx context.Context, accessKey string, force bool) error {
+	info, err := r.info(ctx, accessKey)
+	if err != nil {
+		return fmt.Errorf("revoke %s: %w", accessKey, err)
+	}
+	now := r.now().UTC()
+	if !force && info.UsedWithin(r.window, now) {
+		return fmt.Errorf("revoke %s: last used %s, within the revoke window of %s: %w",
+			accessKey, info.LastUsedAt.Format(time.RFC3339), r.window, ErrKeyRecentlyUsed)
+	}
+
+	pairs, err := readKeyPairs(r.cfg.ExtraFile)
+	if err != nil {
+		return fmt.Errorf("revoke %s: %w", accessKey, err)
+	}
+	kept := slices.DeleteFunc(slices.Clone(pairs), hasAccessKey(accessKey))
+	if len(kept) == len(pairs) {
+		return fmt.Errorf("revoke %s: not in the extra keys file %s; remove it from the configuration by hand: %w",
+			accessKey, r.cfg.ExtraFile, ErrKeyNotFound)
+	}
+	if err := writeKeyPairs(r.cfg.ExtraFile, kept); err != nil {
+		return fmt.Errorf("revoke %s: %w", accessKey, err)
+	}
+	if err := r.repo.RevokeAccessKey(ctx, accessKey, now); err != nil {
+		return fmt.Errorf("revoke %s: removed from the extra keys file, but: %w", accessKey, err)
+	}
+	return nil
+}
+
+// info returns what the repository records about accessKey; a key it has
+// never seen is active and unused.
+func (r *KeyRotator) info(ctx context.Context, accessKey string) (stowry.AccessKeyInfo, error) {
+	keys, err := r.repo.ListAccessKeys(ctx)
+	if err != nil {
+		return stowry.AccessKeyInfo{}, err
+	}
+	for _, k := range keys {
+		if k.AccessKey == accessKey {
+			return k, nil
+		}
+	}
+	return stowry.AccessKeyInfo{AccessKey: accessKey}, nil
+}
+
+func hasAccessKey(accessKey string) func(KeyPair) bool {
+	return func(p KeyPair) bool { return p.AccessKey == accessKey }
+}
+
+// readKeyPairs returns the key pairs of a keys file in file order. A
+// missing file holds none.
+func readKeyPairs(path string) ([]KeyPair, error) {
+	data, err := os.ReadFile(path) //nolint:gosec // Path is from trusted config file
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read keys file: %w", err)
+	}
+
+	var pairs []KeyPair
+	if err := json.Unmarshal(data, &pairs); err != nil {
+		return nil, fmt.Errorf("parse keys file: %w", err)
+	}
+	return pairs, nil
+}
+
+// writeKeyPairs replaces the keys file at path with pairs by renaming a
+// temporary file over it, so a KeysWatcher never reads it half written.
+func writeKeyPairs(path string, pairs []KeyPair) error {
+	if pairs == nil {
+		pairs = []KeyPair{}
+	}
+	data, err := json.MarshalIndent(pairs, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encode keys file: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("write keys file: %w", err)
+	}
+	defer func() { _ = os.Remove(tmp.Name()) }()
+
+	if _, err := tmp.Write(append(data, '\n')); err != nil {
+		_ = tmp.Close()
+		return fmt.Errorf("write keys file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("write keys file: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("write keys file: %w", err)
+	}
+	return nil
+}
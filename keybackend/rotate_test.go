@@ -0,0 +1,174 @@
+package keybackend_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/sagarc03/stowry"
+	"github.com/sagarc03/stowry/keybackend"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// memAccessKeyRepo is an in-memory stowry.AccessKeyRepo.
+type memAccessKeyRepo struct {
+	keys      map[string]stowry.AccessKeyInfo
+	rotateErr error
+}
+
+func newMemAccessKeyRepo() *memAccessKeyRepo {
+	return &memAccessKeyRepo{keys: map[string]stowry.AccessKeyInfo{}}
+}
+
+func (m *memAccessKeyRepo) RecordAccessKeyUse(_ context.Context, uses []stowry.AccessKeyUse) error {
+	for _, u := range uses {
+		k := m.keys[u.AccessKey]
+		k.AccessKey = u.AccessKey
+		k.RequestCount += u.Count
+		k.LastUsedAt = &u.LastUsedAt
+		m.keys[u.AccessKey] = k
+	}
+	return nil
+}
+
+func (m *memAccessKeyRepo) ListAccessKeys(context.Context) ([]stowry.AccessKeyInfo, error) {
+	keys := make([]stowry.AccessKeyInfo, 0, len(m.keys))
+	for _, k := range m.keys {
+		keys = append(keys, k)
+	}
+	return stowry.MergeAccessKeys(keys, nil), nil
+}
+
+func (m *memAccessKeyRepo) RotateAccessKey(_ context.Context, oldKey, newKey string, at time.Time) error {
+	if m.rotateErr != nil {
+		return m.rotateErr
+	}
+	old := m.keys[oldKey]
+	old.AccessKey, old.DeprecatedAt, old.ReplacedBy = oldKey, &at, newKey
+	m.keys[oldKey] = old
+	m.keys[newKey] = stowry.AccessKeyInfo{AccessKey: newKey, CreatedAt: &at}
+	return nil
+}
+
+func (m *memAccessKeyRepo) RevokeAccessKey(_ context.Context, accessKey string, at time.Time) error {
+	k := m.keys[accessKey]
+	k.AccessKey, k.RevokedAt = accessKey, &at
+	m.keys[accessKey] = k
+	return nil
+}
+
+func TestGenerateKeyPair(t *testing.T) {
+	a, err := keybackend.GenerateKeyPair()
+	require.NoError(t, err)
+	b, err := keybackend.GenerateKeyPair()
+	require.NoError(t, err)
+
+	assert.Regexp(t, `^AK[A-Z2-7]{18}$`, a.AccessKey)
+	assert.Len(t, a.SecretKey, 40)
+	assert.NotEqual(t, a, b)
+}
+
+func TestKeyRotator(t *testing.T) {
+	ctx := context.Background()
+	setup := func(t *testing.T) (*keybackend.KeyRotator, *memAccessKeyRepo, string) {
+		t.Helper()
+		path := filepath.Join(t.TempDir(), "extra.json")
+		replaceFile(t, path, `[{"access_key": "OLD", "secret_key": "old_secret"}]`)
+		repo := newMemAccessKeyRepo()
+		rotator, err := keybackend.NewKeyRotator(keybackend.KeysConfig{
+			Inline:       []keybackend.KeyPair{{AccessKey: "BASE", SecretKey: "base"}},
+			ExtraFile:    path,
+			RevokeWindow: 3600,
+		}, repo)
+		require.NoError(t, err)
+		return rotator, repo, path
+	}
+	fileKeys := func(t *testing.T, path string) map[string]string {
+		t.Helper()
+		keys, err := keybackend.LoadKeysFromFile(path)
+		require.NoError(t, err)
+		return keys
+	}
+
+	t.Run("requires an extra keys file", func(t *testing.T) {
+		_, err := keybackend.NewKeyRotator(keybackend.KeysConfig{}, newMemAccessKeyRepo())
+		assert.Error(t, err)
+	})
+
+	t.Run("rotate adds the new key and deprecates the old", func(t *testing.T) {
+		rotator, repo, path := setup(t)
+
+		pair, err := rotator.Rotate(ctx, "OLD")
+		require.NoError(t, err)
+		assert.Equal(t, map[string]string{"OLD": "old_secret", pair.AccessKey: pair.SecretKey}, fileKeys(t, path))
+		assert.Equal(t, stowry.AccessKeyDeprecated, repo.keys["OLD"].Status())
+		assert.Equal(t, pair.AccessKey, repo.keys["OLD"].ReplacedBy)
+
+		_, err = rotator.Rotate(ctx, "OLD")
+		assert.ErrorContains(t, err, "already replaced by "+pair.AccessKey)
+	})
+
+	t.Run("rotate accepts keys from any source", func(t *testing.T) {
+		rotator, repo, path := setup(t)
+
+		pair, err := rotator.Rotate(ctx, "BASE")
+		require.NoError(t, err)
+		assert.Contains(t, fileKeys(t, path), pair.AccessKey)
+		assert.Equal(t, stowry.AccessKeyDeprecated, repo.keys["BASE"].Status())
+	})
+
+	t.Run("rotate of an unknown key", func(t *testing.T) {
+		rotator, _, _ := setup(t)
+
+		_, err := rotator.Rotate(ctx, "NOPE")
+		assert.ErrorIs(t, err, keybackend.ErrKeyNotFound)
+	})
+
+	t.Run("failed rotate leaves the file as it was", func(t *testing.T) {
+		rotator, repo, path := setup(t)
+		before, err := os.ReadFile(path)
+		require.NoError(t, err)
+		repo.rotateErr = errors.New("db down")
+
+		_, err = rotator.Rotate(ctx, "OLD")
+		require.Error(t, err)
+		after, err := os.ReadFile(path)
+		require.NoError(t, err)
+		assert.JSONEq(t, string(before), string(after))
+	})
+
+	t.Run("revoke refuses a key used within the window", func(t *testing.T) {
+		rotator, repo, path := setup(t)
+		now := time.Now()
+		require.NoError(t, repo.RecordAccessKeyUse(ctx, []stowry.AccessKeyUse{{AccessKey: "OLD", Count: 1, FirstUsedAt: now, LastUsedAt: now}}))
+
+		err := rotator.Revoke(ctx, "OLD", false)
+		assert.ErrorIs(t, err, keybackend.ErrKeyRecentlyUsed)
+		assert.Contains(t, fileKeys(t, path), "OLD")
+
+		require.NoError(t, rotator.Revoke(ctx, "OLD", true))
+		assert.Empty(t, fileKeys(t, path))
+		assert.Equal(t, stowry.AccessKeyRevoked, repo.keys["OLD"].Status())
+	})
+
+	t.Run("revoke of a key unused within the window", func(t *testing.T) {
+		rotator, repo, path := setup(t)
+		old := time.Now().Add(-2 * time.Hour)
+		require.NoError(t, repo.RecordAccessKeyUse(ctx, []stowry.AccessKeyUse{{AccessKey: "OLD", Count: 1, FirstUsedAt: old, LastUsedAt: old}}))
+
+		require.NoError(t, rotator.Revoke(ctx, "OLD", false))
+		assert.Empty(t, fileKeys(t, path))
+	})
+
+	t.Run("revoke of a key outside the extra keys file", func(t *testing.T) {
+		rotator, repo, _ := setup(t)
+
+		err := rotator.Revoke(ctx, "BASE", false)
+		assert.ErrorIs(t, err, keybackend.ErrKeyNotFound)
+		assert.Empty(t, repo.keys)
+	})
+}
@@ -1,6 +1,10 @@
 package keybackend
 
 import (
+	"errors"
+	"io/fs"
+	"maps"
+
 	"github.com/sagarc03/stowry"
 )
 
@@ -8,13 +12,41 @@ import (
 type KeysConfig struct {
 	Inline []KeyPair `mapstructure:"inline"` // Inline key pairs from config
 	File   string    `mapstructure:"file"`   // Path to JSON file containing key pairs
+	// ExtraFile is a JSON keys file, like File, that is watched while the
+	// server runs so keys can be added and removed without a restart.
+	ExtraFile         string `mapstructure:"extra_file"`
+	ExtraFileInterval int    `mapstructure:"extra_file_interval" validate:"min=0"` // Seconds between checks of ExtraFile (default: 2)
+	// TrackUsage records when each access key was last used and how many
+	// requests it signed, flushed to the metadata every UsageFlushInterval.
+	TrackUsage         bool `mapstructure:"track_usage"`
+	UsageFlushInterval int  `mapstructure:"usage_flush_interval" validate:"min=0"` // Seconds (default: 5)
+	// RevokeWindow is how recently a key may have been used and still be
+	// revoked without --force, in seconds.
+	RevokeWindow int `mapstructure:"revoke_window" validate:"min=0"`
 }
 
 // NewSecretStore creates a SecretStore from the given configuration.
-// It loads keys from both inline config and file (if specified),
-// merging them into a single store. File keys take precedence over inline keys
-// if there are duplicates.
+// It loads keys from inline config, the keys file and the extra keys file
+// (if specified), merging them into a single store. Keys from later sources
+// take precedence over earlier ones if there are duplicates. A missing extra
+// keys file holds no keys. The extra keys file is read once; use
+// NewKeysWatcher to follow its changes.
 func NewSecretStore(cfg KeysConfig) (stowry.SecretStore, error) {
+	base, err := loadBaseKeys(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	extra, err := loadExtraKeys(cfg.ExtraFile)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewMapSecretStore(mergeKeys(base, extra)), nil
+}
+
+// loadBaseKeys loads the inline keys and the keys file.
+func loadBaseKeys(cfg KeysConfig) (map[string]string, error) {
 	keys := make(map[string]string)
 
 	// Load inline keys
@@ -30,10 +62,28 @@ func NewSecretStore(cfg KeysConfig) (stowry.SecretStore, error) {
 		if err != nil {
 			return nil, err
 		}
-		for k, v := range fileKeys {
-			keys[k] = v
-		}
+		maps.Copy(keys, fileKeys)
 	}
 
-	return NewMapSecretStore(keys), nil
+	return keys, nil
+}
+
+// loadExtraKeys loads the extra keys file, which may not exist yet.
+func loadExtraKeys(path string) (map[string]string, error) {
+	if path == "" {
+		return map[string]string{}, nil
+	}
+	keys, err := LoadKeysFromFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return map[string]string{}, nil
+	}
+	return keys, err
+}
+
+// mergeKeys returns a new map of base overlaid with extra.
+func mergeKeys(base, extra map[string]string) map[string]string {
+	keys := make(map[string]string, len(base)+len(extra))
+	maps.Copy(keys, base)
+	maps.Copy(keys, extra)
+	return keys
 }
@@ -196,3 +196,35 @@ func writeKeysFile(t *testing.T, content string) string {
 
 	return path
 }
+
+func TestNewSecretStore_ExtraFile(t *testing.T) {
+	t.Parallel()
+
+	cfg := keybackend.KeysConfig{
+		Inline:    []keybackend.KeyPair{{AccessKey: "KEY1", SecretKey: "inline"}},
+		ExtraFile: writeKeysFile(t, `[{"access_key": "KEY1", "secret_key": "extra"}, {"access_key": "KEY2", "secret_key": "secret2"}]`),
+	}
+
+	store, err := keybackend.NewSecretStore(cfg)
+	require.NoError(t, err)
+
+	secret, err := store.Lookup("KEY1")
+	require.NoError(t, err)
+	assert.Equal(t, "extra", secret, "extra file keys take precedence")
+	_, err = store.Lookup("KEY2")
+	require.NoError(t, err)
+
+	t.Run("missing extra file holds no keys", func(t *testing.T) {
+		t.Parallel()
+
+		cfg := keybackend.KeysConfig{
+			Inline:    []keybackend.KeyPair{{AccessKey: "KEY1", SecretKey: "inline"}},
+			ExtraFile: filepath.Join(t.TempDir(), "pending.json"),
+		}
+		store, err := keybackend.NewSecretStore(cfg)
+		require.NoError(t, err)
+
+		_, err = store.Lookup("KEY1")
+		require.NoError(t, err)
+	})
+}
@@ -0,0 +1,174 @@
+package keybackend
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"slices"
+	"sync"
+	"time"
+)
+
+// DefaultExtraFileInterval is how often a KeysWatcher checks the extra keys
+// file when KeysConfig.ExtraFileInterval is not set.
+const DefaultExtraFileInterval = 2 * time.Second
+
+// KeysDelta describes how a reload of the extra keys file changed the keys.
+// It holds access key IDs only, never secrets.
+type KeysDelta struct {
+	Added   []string // Access keys that were not in the file before
+	Removed []string // Access keys no longer in the file
+	Changed []string // Access keys whose secret changed
+}
+
+// Empty reports whether the reload changed no key.
+func (d KeysDelta) Empty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Changed) == 0
+}
+
+// KeysWatcher keeps a MapSecretStore in step with KeysConfig.ExtraFile, so
+// keys can be rotated without a restart: add the new key to the file, move
+// clients over, then remove the old key. The inline keys and the keys file are
+// loaded once.
+//
+// Every change replaces the store's keys in one step. A file that cannot be
+// parsed is logged and ignored, keeping the last good keys; a missing file
+// holds no keys.
+type KeysWatcher struct {
+	store    *MapSecretStore
+	base     map[string]string
+	path     string
+	interval time.Duration
+
+	mu    sync.Mutex
+	data  []byte            // Content of the file last read, nil when missing
+	extra map[string]string // Keys from the file in effect
+}
+
+// NewKeysWatcher loads the keys of cfg into a new MapSecretStore and returns
+// a watcher for cfg.ExtraFile, which must be set. Unlike later reloads, it
+// fails if the extra keys file cannot be parsed.
+func NewKeysWatcher(cfg KeysConfig) (*KeysWatcher, error) {
+	if cfg.ExtraFile == "" {
+		return nil, errors.New("watch keys: extra keys file is not set")
+	}
+
+	base, err := loadBaseKeys(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	w := &KeysWatcher{
+		base:     base,
+		path:     cfg.ExtraFile,
+		interval: time.Duration(cfg.ExtraFileInterval) * time.Second,
+		extra:    map[string]string{},
+	}
+	if w.interval <= 0 {
+		w.interval = DefaultExtraFileInterval
+	}
+
+	data, extra, err := w.read()
+	if err != nil {
+		return nil, err
+	}
+	w.data, w.extra = data, extra
+	w.store = NewMapSecretStore(mergeKeys(base, extra))
+
+	return w, nil
+}
+
+// Store returns the store the watcher keeps up to date.
+func (w *KeysWatcher) Store() *MapSecretStore {
+	return w.store
+}
+
+// Run checks the extra keys file for changes until ctx is done.
+func (w *KeysWatcher) Run(ctx context.Context) {
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			_, _ = w.Reload()
+		}
+	}
+}
+
+// Reload reads the extra keys file and, if its keys changed, replaces the
+// store's keys and logs the delta. On error the store keeps its keys.
+func (w *KeysWatcher) Reload() (KeysDelta, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	data, extra, err := w.read()
+	if err != nil {
+		// Only log a broken file once, not on every check
+		if data == nil || !bytes.Equal(data, w.data) {
+			slog.Error("extra keys file is invalid; keeping the previous keys", "path", w.path, "error", err)
+		}
+		w.data = data
+		return KeysDelta{}, err
+	}
+	w.data = data
+
+	delta := diffKeys(w.extra, extra)
+	if delta.Empty() {
+		return delta, nil
+	}
+
+	w.extra = extra
+	w.store.Replace(mergeKeys(w.base, extra))
+	slog.Info("extra keys reloaded", "path", w.path,
+		"added", delta.Added, "removed", delta.Removed, "changed", delta.Changed, "keys", w.store.Len())
+
+	return delta, nil
+}
+
+// read returns the content and keys of the extra keys file. A missing file
+// has nil content and no keys.
+func (w *KeysWatcher) read() ([]byte, map[string]string, error) {
+	data, err := os.ReadFile(w.path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, map[string]string{}, nil
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("read keys file: %w", err)
+	}
+
+	keys, err := parseKeys(data)
+	if err != nil {
+		return data, nil, err
+	}
+	return data, keys, nil
+}
+
+// diffKeys compares the keys before and after a reload.
+func diffKeys(before, after map[string]string) KeysDelta {
+	var delta KeysDelta
+	for accessKey, secretKey := range after {
+		old, ok := before[accessKey]
+		switch {
+		case !ok:
+			delta.Added = append(delta.Added, accessKey)
+		case old != secretKey:
+			delta.Changed = append(delta.Changed, accessKey)
+		}
+	}
+	for accessKey := range before {
+		if _, ok := after[accessKey]; !ok {
+			delta.Removed = append(delta.Removed, accessKey)
+		}
+	}
+	slices.Sort(delta.Added)
+	slices.Sort(delta.Removed)
+	slices.Sort(delta.Changed)
+	return delta
+}
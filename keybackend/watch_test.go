@@ -0,0 +1,139 @@
+package keybackend_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/sagarc03/stowry/keybackend"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// replaceFile writes content to path the way a deploy tool should, by
+// renaming a temporary file over it.
+func replaceFile(t *testing.T, path, content string) {
+	t.Helper()
+
+	tmp := path + ".tmp"
+	require.NoError(t, os.WriteFile(tmp, []byte(content), 0o600))
+	require.NoError(t, os.Rename(tmp, path))
+}
+
+func TestKeysWatcher_Reload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "pending.json")
+	replaceFile(t, path, `[{"access_key": "OLD", "secret_key": "old_secret"}, {"access_key": "SAME", "secret_key": "same"}]`)
+
+	watcher, err := keybackend.NewKeysWatcher(keybackend.KeysConfig{
+		Inline:    []keybackend.KeyPair{{AccessKey: "BASE", SecretKey: "base"}},
+		ExtraFile: path,
+	})
+	require.NoError(t, err)
+	store := watcher.Store()
+	assert.Equal(t, 3, store.Len())
+
+	t.Run("unchanged file changes nothing", func(t *testing.T) {
+		delta, err := watcher.Reload()
+		require.NoError(t, err)
+		assert.True(t, delta.Empty())
+	})
+
+	t.Run("additions and removals take effect", func(t *testing.T) {
+		replaceFile(t, path, `[{"access_key": "NEW", "secret_key": "new_secret"}, {"access_key": "SAME", "secret_key": "rotated"}]`)
+
+		delta, err := watcher.Reload()
+		require.NoError(t, err)
+		assert.Equal(t, keybackend.KeysDelta{Added: []string{"NEW"}, Removed: []string{"OLD"}, Changed: []string{"SAME"}}, delta)
+
+		_, err = store.Lookup("OLD")
+		require.ErrorIs(t, err, keybackend.ErrKeyNotFound)
+		secret, err := store.Lookup("NEW")
+		require.NoError(t, err)
+		assert.Equal(t, "new_secret", secret)
+		secret, err = store.Lookup("SAME")
+		require.NoError(t, err)
+		assert.Equal(t, "rotated", secret)
+		_, err = store.Lookup("BASE")
+		require.NoError(t, err, "base keys are kept")
+	})
+
+	t.Run("malformed file keeps the last good keys", func(t *testing.T) {
+		replaceFile(t, path, `[{"access_key": "BROKEN"`)
+
+		_, err := watcher.Reload()
+		require.ErrorContains(t, err, "parse keys file")
+
+		_, err = store.Lookup("NEW")
+		require.NoError(t, err)
+		_, err = store.Lookup("BROKEN")
+		require.ErrorIs(t, err, keybackend.ErrKeyNotFound)
+	})
+
+	t.Run("removed file drops the extra keys", func(t *testing.T) {
+		require.NoError(t, os.Remove(path))
+
+		delta, err := watcher.Reload()
+		require.NoError(t, err)
+		assert.Equal(t, []string{"NEW", "SAME"}, delta.Removed)
+		assert.Equal(t, 1, store.Len())
+	})
+}
+
+func TestNewKeysWatcher_Errors(t *testing.T) {
+	_, err := keybackend.NewKeysWatcher(keybackend.KeysConfig{})
+	require.Error(t, err)
+
+	_, err = keybackend.NewKeysWatcher(keybackend.KeysConfig{ExtraFile: writeKeysFile(t, "not json")})
+	require.ErrorContains(t, err, "parse keys file")
+}
+
+func TestKeysWatcher_Run(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "pending.json")
+
+	watcher, err := keybackend.NewKeysWatcher(keybackend.KeysConfig{ExtraFile: path, ExtraFileInterval: 1})
+	require.NoError(t, err)
+	store := watcher.Store()
+
+	ctx, cancel := context.WithCancel(t.Context())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		watcher.Run(ctx)
+	}()
+
+	// Look keys up while the file is edited, as requests would
+	var wg sync.WaitGroup
+	var unexpected atomic.Int32
+	defer func() {
+		cancel()
+		wg.Wait()
+		<-done
+	}()
+	for range 4 {
+		wg.Go(func() {
+			for ctx.Err() == nil {
+				if secret, err := store.Lookup("KEY"); err == nil && secret != "first" && secret != "second" {
+					unexpected.Add(1)
+				}
+				time.Sleep(time.Millisecond)
+			}
+		})
+	}
+
+	replaceFile(t, path, `[{"access_key": "KEY", "secret_key": "first"}]`)
+	assert.Eventually(t, func() bool {
+		secret, err := store.Lookup("KEY")
+		return err == nil && secret == "first"
+	}, 5*time.Second, 50*time.Millisecond)
+
+	replaceFile(t, path, `[{"access_key": "KEY", "secret_key": "second"}]`)
+	assert.Eventually(t, func() bool {
+		secret, err := store.Lookup("KEY")
+		return err == nil && secret == "second"
+	}, 5*time.Second, 50*time.Millisecond)
+	assert.Zero(t, unexpected.Load())
+}
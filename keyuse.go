@@ -0,0 +1,303 @@
+package stowry
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"log/slog"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Access key statuses reported by AccessKeyInfo.Status.
+const (
+	AccessKeyActive     = "active"
+	AccessKeyDeprecated = "deprecated" // Replaced by a rotation, still valid
+	AccessKeyRevoked    = "revoked"
+)
+
+// AccessKeyInfo is what the metadata records about one access key: its use,
+// and where it stands in a rotation. It never holds the secret key.
+type AccessKeyInfo struct {
+	AccessKey string `json:"access_key"`
+	// CreatedAt is when a rotation created the key, or when it was first
+	// seen signing a request. nil for a configured key never seen.
+	CreatedAt    *time.Time `json:"created_at,omitempty"`
+	LastUsedAt   *time.Time `json:"last_used_at,omitempty"`
+	RequestCount int64      `json:"request_count"`
+	// DeprecatedAt is when a rotation replaced the key with ReplacedBy. A
+	// deprecated key still authenticates, and each use logs a warning.
+	DeprecatedAt *time.Time `json:"deprecated_at,omitempty"`
+	ReplacedBy   string     `json:"replaced_by,omitempty"`
+	// RevokedAt is when the key was revoked and removed from the keys file.
+	RevokedAt *time.Time `json:"revoked_at,omitempty"`
+}
+
+// Status returns AccessKeyActive, AccessKeyDeprecated or AccessKeyRevoked.
+func (k AccessKeyInfo) Status() string {
+	switch {
+	case k.RevokedAt != nil:
+		return AccessKeyRevoked
+	case k.DeprecatedAt != nil:
+		return AccessKeyDeprecated
+	default:
+		return AccessKeyActive
+	}
+}
+
+// UsedWithin reports whether the key was last used less than window before
+// now.
+func (k AccessKeyInfo) UsedWithin(window time.Duration, now time.Time) bool {
+	return k.LastUsedAt != nil && now.Sub(*k.LastUsedAt) < window
+}
+
+// AccessKeyUse counts the requests one access key signed between two
+// flushes of a KeyUseTracker.
+type AccessKeyUse struct {
+	AccessKey   string
+	Count       int64
+	FirstUsedAt time.Time
+	LastUsedAt  time.Time
+}
+
+// AccessKeyRepo is an optional MetaDataRepo extension that records the use
+// and rotation of access keys, so an old key can be revoked once nothing
+// uses it. Callers check for it with a type assertion.
+type AccessKeyRepo interface {
+	// RecordAccessKeyUse adds each use's count to the key's request count
+	// and moves its last used time forward, recording keys it has not seen
+	// with FirstUsedAt as their creation time.
+	//
+	// Returns:
+	//   - error: Any database error; no use is recorded then
+	RecordAccessKeyUse(ctx context.Context, uses []AccessKeyUse) error
+
+	// ListAccessKeys returns every access key recorded, in access key
+	// order.
+	//
+	// Returns:
+	//   - []AccessKeyInfo: The keys; empty when there are none
+	//   - error: Any database error
+	ListAccessKeys(ctx context.Context) ([]AccessKeyInfo, error)
+
+	// RotateAccessKey records newKey, created at at, as the replacement of
+	// oldKey, which is deprecated at at.
+	//
+	// Returns:
+	//   - error: Any database error; nothing is recorded then
+	RotateAccessKey(ctx context.Context, oldKey, newKey string, at time.Time) error
+
+	// RevokeAccessKey records accessKey as revoked at at.
+	//
+	// Returns:
+	//   - error: Any database error
+	RevokeAccessKey(ctx context.Context, accessKey string, at time.Time) error
+}
+
+// MergeAccessKeys adds the configured access keys that keys does not hold,
+// as active keys never seen, and returns the result in access key order.
+func MergeAccessKeys(keys []AccessKeyInfo, configured []string) []AccessKeyInfo {
+	merged := slices.Clone(keys)
+	known := make(map[string]bool, len(keys))
+	for _, k := range keys {
+		known[k.AccessKey] = true
+	}
+	for _, accessKey := range configured {
+		if !known[accessKey] {
+			known[accessKey] = true
+			merged = append(merged, AccessKeyInfo{AccessKey: accessKey})
+		}
+	}
+	slices.SortFunc(merged, func(a, b AccessKeyInfo) int { return cmp.Compare(a.AccessKey, b.AccessKey) })
+	return merged
+}
+
+// KeyUseTracker counts the requests each access key signs in memory and
+// periodically flushes the counts to an AccessKeyRepo, keeping database
+// writes off the request path, as AccessTracker does for downloads. Each
+// flush also reloads which keys are deprecated, so their use is logged.
+//
+// Counts recorded since the last flush are lost if the process crashes.
+type KeyUseTracker struct {
+	repo       AccessKeyRepo
+	configured func() []string
+	interval   time.Duration
+
+	mu      sync.Mutex
+	pending map[string]*AccessKeyUse
+
+	// deprecated maps each deprecated key to its replacement
+	deprecated atomic.Pointer[map[string]string]
+}
+
+// NewKeyUseTracker creates a KeyUseTracker that flushes to repo every
+// interval. configured, when not nil, returns the access keys the server
+// accepts, so AccessKeys lists those never used too. A non-positive
+// interval defaults to 5 seconds.
+func NewKeyUseTracker(repo AccessKeyRepo, configured func() []string, interval time.Duration) *KeyUseTracker {
+	if interval <= 0 {
+		interval = 5 * time.Second
+	}
+	t := &KeyUseTracker{
+		repo:       repo,
+		configured: configured,
+		interval:   interval,
+		pending:    make(map[string]*AccessKeyUse),
+	}
+	t.deprecated.Store(&map[string]string{})
+	return t
+}
+
+// Record counts one request signed with accessKey. It never blocks on the
+// database, and logs a warning when the key is deprecated.
+func (t *KeyUseTracker) Record(accessKey string) {
+	now := time.Now().UTC()
+
+	if replacement, ok := (*t.deprecated.Load())[accessKey]; ok {
+		slog.Warn("deprecated access key used", "access_key", accessKey, "replaced_by", replacement)
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	use, ok := t.pending[accessKey]
+	if !ok {
+		use = &AccessKeyUse{AccessKey: accessKey, FirstUsedAt: now}
+		t.pending[accessKey] = use
+	}
+	use.Count++
+	use.LastUsedAt = now
+}
+
+// Flush writes all pending counts to the repository, then reloads the
+// deprecated keys. On failure the counts are merged back so the next flush
+// retries them.
+func (t *KeyUseTracker) Flush(ctx context.Context) error {
+	t.mu.Lock()
+	batch := t.pending
+	t.pending = make(map[string]*AccessKeyUse, len(batch))
+	t.mu.Unlock()
+
+	if len(batch) > 0 {
+		uses := make([]AccessKeyUse, 0, len(batch))
+		for _, use := range batch {
+			uses = append(uses, *use)
+		}
+
+		if err := t.repo.RecordAccessKeyUse(ctx, uses); err != nil {
+			t.requeue(uses)
+			return fmt.Errorf("flush access key use: %w", err)
+		}
+	}
+
+	return t.reload(ctx)
+}
+
+func (t *KeyUseTracker) requeue(uses []AccessKeyUse) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for _, u := range uses {
+		use, ok := t.pending[u.AccessKey]
+		if !ok {
+			use = &AccessKeyUse{AccessKey: u.AccessKey, FirstUsedAt: u.FirstUsedAt}
+			t.pending[u.AccessKey] = use
+		}
+		use.Count += u.Count
+		if u.FirstUsedAt.Before(use.FirstUsedAt) {
+			use.FirstUsedAt = u.FirstUsedAt
+		}
+		if u.LastUsedAt.After(use.LastUsedAt) {
+			use.LastUsedAt = u.LastUsedAt
+		}
+	}
+}
+
+// reload reads which keys are deprecated from the repository.
+func (t *KeyUseTracker) reload(ctx context.Context) error {
+	keys, err := t.repo.ListAccessKeys(ctx)
+	if err != nil {
+		return fmt.Errorf("reload access keys: %w", err)
+	}
+
+	deprecated := make(map[string]string)
+	for _, k := range keys {
+		if k.Status() == AccessKeyDeprecated {
+			deprecated[k.AccessKey] = k.ReplacedBy
+		}
+	}
+	t.deprecated.Store(&deprecated)
+	return nil
+}
+
+// AccessKeys returns the recorded access keys with the counts not yet
+// flushed added in, and the configured keys never used, in access key
+// order.
+func (t *KeyUseTracker) AccessKeys(ctx context.Context) ([]AccessKeyInfo, error) {
+	keys, err := t.repo.ListAccessKeys(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("list access keys: %w", err)
+	}
+
+	t.mu.Lock()
+	pending := make(map[string]AccessKeyUse, len(t.pending))
+	for accessKey, use := range t.pending {
+		pending[accessKey] = *use
+	}
+	t.mu.Unlock()
+
+	for i := range keys {
+		use, ok := pending[keys[i].AccessKey]
+		if !ok {
+			continue
+		}
+		delete(pending, keys[i].AccessKey)
+		keys[i].RequestCount += use.Count
+		if keys[i].LastUsedAt == nil || use.LastUsedAt.After(*keys[i].LastUsedAt) {
+			keys[i].LastUsedAt = &use.LastUsedAt
+		}
+	}
+	for _, use := range pending {
+		keys = append(keys, AccessKeyInfo{
+			AccessKey:    use.AccessKey,
+			CreatedAt:    &use.FirstUsedAt,
+			LastUsedAt:   &use.LastUsedAt,
+			RequestCount: use.Count,
+		})
+	}
+
+	var configured []string
+	if t.configured != nil {
+		configured = t.configured()
+	}
+	return MergeAccessKeys(keys, configured), nil
+}
+
+// Run loads the deprecated keys, then flushes pending counts every interval
+// until ctx is cancelled, and performs a final flush bounded by timeout.
+func (t *KeyUseTracker) Run(ctx context.Context, timeout time.Duration) {
+	if err := t.reload(ctx); err != nil {
+		slog.Warn("access key reload failed", "err", err)
+	}
+
+	ticker := time.NewTicker(t.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			flushCtx, cancel := context.WithTimeout(context.Background(), timeout)
+			if err := t.Flush(flushCtx); err != nil {
+				slog.Error("final access key flush failed", "err", err)
+			}
+			cancel()
+			return
+		case <-ticker.C:
+			if err := t.Flush(ctx); err != nil {
+				slog.Warn("access key flush failed", "err", err)
+			}
+		}
+	}
+}
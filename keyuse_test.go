@@ -0,0 +1,152 @@
+package stowry_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sagarc03/stowry"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeAccessKeyRepo records flushed uses and lists fixed keys.
+type fakeAccessKeyRepo struct {
+	mu      sync.Mutex
+	keys    []stowry.AccessKeyInfo
+	flushed [][]stowry.AccessKeyUse
+	err     error
+}
+
+func (f *fakeAccessKeyRepo) RecordAccessKeyUse(_ context.Context, uses []stowry.AccessKeyUse) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.err != nil {
+		return f.err
+	}
+	f.flushed = append(f.flushed, uses)
+	return nil
+}
+
+func (f *fakeAccessKeyRepo) ListAccessKeys(context.Context) ([]stowry.AccessKeyInfo, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]stowry.AccessKeyInfo{}, f.keys...), nil
+}
+
+func (f *fakeAccessKeyRepo) RotateAccessKey(context.Context, string, string, time.Time) error {
+	return errors.New("not implemented")
+}
+
+func (f *fakeAccessKeyRepo) RevokeAccessKey(context.Context, string, time.Time) error {
+	return errors.New("not implemented")
+}
+
+func usesByKey(uses []stowry.AccessKeyUse) map[string]int64 {
+	counts := make(map[string]int64, len(uses))
+	for _, u := range uses {
+		counts[u.AccessKey] = u.Count
+	}
+	return counts
+}
+
+func TestKeyUseTracker_Flush(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("batches counts per key", func(t *testing.T) {
+		repo := &fakeAccessKeyRepo{}
+		tracker := stowry.NewKeyUseTracker(repo, nil, time.Hour)
+
+		tracker.Record("A")
+		tracker.Record("A")
+		tracker.Record("B")
+		assert.Empty(t, repo.flushed)
+
+		require.NoError(t, tracker.Flush(ctx))
+		require.Len(t, repo.flushed, 1)
+		assert.Equal(t, map[string]int64{"A": 2, "B": 1}, usesByKey(repo.flushed[0]))
+
+		require.NoError(t, tracker.Flush(ctx))
+		assert.Len(t, repo.flushed, 1, "empty flush skips the write")
+	})
+
+	t.Run("failed flush is retried", func(t *testing.T) {
+		repo := &fakeAccessKeyRepo{err: errors.New("db down")}
+		tracker := stowry.NewKeyUseTracker(repo, nil, time.Hour)
+
+		tracker.Record("A")
+		require.Error(t, tracker.Flush(ctx))
+		tracker.Record("A")
+
+		repo.err = nil
+		require.NoError(t, tracker.Flush(ctx))
+		require.Len(t, repo.flushed, 1)
+		assert.Equal(t, map[string]int64{"A": 2}, usesByKey(repo.flushed[0]))
+	})
+}
+
+func TestKeyUseTracker_AccessKeys(t *testing.T) {
+	last := time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC)
+	repo := &fakeAccessKeyRepo{keys: []stowry.AccessKeyInfo{
+		{AccessKey: "A", CreatedAt: &last, LastUsedAt: &last, RequestCount: 5},
+	}}
+	tracker := stowry.NewKeyUseTracker(repo, func() []string { return []string{"C", "A"} }, time.Hour)
+
+	tracker.Record("A")
+	tracker.Record("B")
+
+	keys, err := tracker.AccessKeys(context.Background())
+	require.NoError(t, err)
+	require.Len(t, keys, 3)
+
+	assert.Equal(t, "A", keys[0].AccessKey)
+	assert.Equal(t, int64(6), keys[0].RequestCount, "unflushed use is counted")
+	assert.True(t, keys[0].LastUsedAt.After(last))
+
+	assert.Equal(t, "B", keys[1].AccessKey)
+	assert.Equal(t, int64(1), keys[1].RequestCount)
+	assert.NotNil(t, keys[1].CreatedAt)
+
+	assert.Equal(t, "C", keys[2].AccessKey, "a configured key never used is listed")
+	assert.Nil(t, keys[2].CreatedAt)
+	assert.Nil(t, keys[2].LastUsedAt)
+	assert.Equal(t, stowry.AccessKeyActive, keys[2].Status())
+}
+
+func TestKeyUseTracker_DeprecatedWarning(t *testing.T) {
+	var logs bytes.Buffer
+	previous := slog.Default()
+	slog.SetDefault(slog.New(slog.NewTextHandler(&logs, nil)))
+	t.Cleanup(func() { slog.SetDefault(previous) })
+
+	at := time.Now()
+	repo := &fakeAccessKeyRepo{keys: []stowry.AccessKeyInfo{
+		{AccessKey: "OLD", CreatedAt: &at, DeprecatedAt: &at, ReplacedBy: "NEW"},
+		{AccessKey: "NEW", CreatedAt: &at},
+	}}
+	tracker := stowry.NewKeyUseTracker(repo, nil, time.Hour)
+
+	tracker.Record("OLD")
+	assert.Empty(t, logs.String(), "deprecated keys are loaded by a flush")
+
+	require.NoError(t, tracker.Flush(context.Background()))
+	tracker.Record("NEW")
+	assert.Empty(t, logs.String())
+	tracker.Record("OLD")
+	tracker.Record("OLD")
+	assert.Equal(t, 2, bytes.Count(logs.Bytes(), []byte("deprecated access key used")), "one warning per use")
+	assert.Contains(t, logs.String(), "replaced_by=NEW")
+}
+
+func TestAccessKeyInfo_UsedWithin(t *testing.T) {
+	now := time.Now()
+	recent := now.Add(-time.Minute)
+
+	assert.False(t, stowry.AccessKeyInfo{}.UsedWithin(time.Hour, now), "never used")
+	assert.True(t, stowry.AccessKeyInfo{LastUsedAt: &recent}.UsedWithin(time.Hour, now))
+	assert.False(t, stowry.AccessKeyInfo{LastUsedAt: &recent}.UsedWithin(time.Second, now))
+}
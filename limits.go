@@ -0,0 +1,335 @@
+package stowry
+
+import (
+	"context"
+	"errors"
+	"expvar"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+)
+
+// objectLimitVars publishes per-prefix counters under the expvar name
+// "stowry_object_limits", keyed by prefix.
+var objectLimitVars = expvar.NewMap("stowry_object_limits")
+
+// DefaultLimitReconcileInterval is how often an ObjectLimiter recounts its
+// prefixes when no interval is given.
+const DefaultLimitReconcileInterval = 5 * time.Minute
+
+// LimitAction selects what happens when a create would take a prefix past its
+// ObjectLimit.
+type LimitAction string
+
+const (
+	// LimitActionReject fails the create with an *ObjectLimitError.
+	LimitActionReject LimitAction = "reject"
+	// LimitActionWarn accepts the create, logs a warning and counts it in expvar.
+	LimitActionWarn LimitAction = "warn"
+)
+
+// ObjectLimit caps the number of active objects whose path starts with Prefix.
+// An empty Prefix applies to every object.
+type ObjectLimit struct {
+	Prefix     string      `mapstructure:"prefix"`
+	MaxObjects int64       `mapstructure:"max_objects"`
+	Action     LimitAction `mapstructure:"action"` // Empty means reject
+}
+
+// ObjectLimitError reports a create rejected by an ObjectLimit. It wraps
+// ErrObjectLimitExceeded.
+type ObjectLimitError struct {
+	Prefix     string
+	MaxObjects int64
+	Count      int64 // Active objects under Prefix when the create was checked
+}
+
+func (e *ObjectLimitError) Error() string {
+	return fmt.Sprintf("%s: prefix %q holds %d of %d objects", ErrObjectLimitExceeded, e.Prefix, e.Count, e.MaxObjects)
+}
+
+func (e *ObjectLimitError) Unwrap() error {
+	return ErrObjectLimitExceeded
+}
+
+// ObjectLimiter enforces ObjectLimits on the create path without a COUNT
+// query per upload. It caches the active object count of each limited prefix,
+// adjusts it as objects are created and deleted, and periodically replaces it
+// with a fresh count from the repository to correct drift, such as objects
+// restored by re-uploading a soft-deleted path or writes by other processes.
+//
+// Counts start unknown and are loaded from the repository on first use, so a
+// restarted server enforces limits against the stored state. Checks and
+// writes are not atomic: concurrent creates can overshoot a limit by at most
+// the number of uploads in flight.
+type ObjectLimiter struct {
+	repo     MetaDataRepo
+	interval time.Duration
+
+	mu     sync.Mutex
+	limits []*limitState
+}
+
+type limitState struct {
+	ObjectLimit
+	count    int64
+	known    bool // false forces a recount before the next check
+	warned   time.Time
+	warnings int64
+	rejected int64
+}
+
+// NewObjectLimiter validates limits and creates a limiter that recounts them
+// every interval when Run. A non-positive interval defaults to
+// DefaultLimitReconcileInterval. It returns nil when limits is empty, which
+// disables limiting. A later limiter with the same prefix replaces the
+// published expvar counters.
+func NewObjectLimiter(repo MetaDataRepo, limits []ObjectLimit, interval time.Duration) (*ObjectLimiter, error) {
+	if len(limits) == 0 {
+		return nil, nil
+	}
+	if interval <= 0 {
+		interval = DefaultLimitReconcileInterval
+	}
+
+	normalized, err := normalizeObjectLimits(limits)
+	if err != nil {
+		return nil, err
+	}
+
+	l := &ObjectLimiter{repo: repo, interval: interval}
+	for _, limit := range normalized {
+		l.limits = append(l.limits, &limitState{ObjectLimit: limit})
+	}
+
+	for _, st := range l.limits {
+		objectLimitVars.Set(st.Prefix, expvar.Func(func() any {
+			l.mu.Lock()
+			defer l.mu.Unlock()
+			return map[string]any{
+				"max_objects": st.MaxObjects,
+				"count":       st.count,
+				"warnings":    st.warnings,
+				"rejected":    st.rejected,
+			}
+		}))
+	}
+
+	return l, nil
+}
+
+// ValidateObjectLimits reports the first invalid limit: max_objects below 1,
+// an unknown action, or a prefix listed twice.
+func ValidateObjectLimits(limits []ObjectLimit) error {
+	_, err := normalizeObjectLimits(limits)
+	return err
+}
+
+// normalizeObjectLimits validates limits and fills in the default action.
+func normalizeObjectLimits(limits []ObjectLimit) ([]ObjectLimit, error) {
+	normalized := make([]ObjectLimit, 0, len(limits))
+	seen := make(map[string]bool, len(limits))
+	for i, limit := range limits {
+		switch {
+		case limit.MaxObjects < 1:
+			return nil, fmt.Errorf("object limit %d (%s): max_objects must be at least 1", i+1, limit.Prefix)
+		case seen[limit.Prefix]:
+			return nil, fmt.Errorf("object limit %d (%s): duplicate prefix", i+1, limit.Prefix)
+		}
+		switch limit.Action {
+		case "":
+			limit.Action = LimitActionReject
+		case LimitActionReject, LimitActionWarn:
+		default:
+			return nil, fmt.Errorf("object limit %d (%s): invalid action %q (want reject or warn)", i+1, limit.Prefix, limit.Action)
+		}
+		seen[limit.Prefix] = true
+		normalized = append(normalized, limit)
+	}
+	return normalized, nil
+}
+
+// Counts returns the cached count of every prefix whose count is loaded.
+func (l *ObjectLimiter) Counts() map[string]int64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	counts := make(map[string]int64, len(l.limits))
+	for _, st := range l.limits {
+		if st.known {
+			counts[st.Prefix] = st.count
+		}
+	}
+	return counts
+}
+
+// Reconcile replaces every cached count with a fresh count from the
+// repository. Prefixes whose count fails are left as they were.
+func (l *ObjectLimiter) Reconcile(ctx context.Context) error {
+	var errs []error
+	for _, st := range l.limits {
+		count, err := l.repo.CountPrefix(ctx, st.Prefix)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", st.Prefix, err))
+			continue
+		}
+
+		l.mu.Lock()
+		st.count, st.known = count, true
+		l.mu.Unlock()
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("reconcile object limits: %w", errors.Join(errs...))
+	}
+	return nil
+}
+
+// Run reconciles the counts immediately and then every interval until ctx is
+// cancelled.
+func (l *ObjectLimiter) Run(ctx context.Context) {
+	ticker := time.NewTicker(l.interval)
+	defer ticker.Stop()
+
+	for {
+		if err := l.Reconcile(ctx); err != nil && ctx.Err() == nil {
+			slog.Warn("object limit reconcile failed", "err", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// admit checks whether creating paths keeps every matching limit within
+// bounds. Only when the cached count says a limit is nearly reached are the
+// paths looked up, so that overwrites of existing objects are not counted as
+// new ones; below that the repository is not queried.
+func (l *ObjectLimiter) admit(ctx context.Context, paths []string) error {
+	for _, st := range l.limits {
+		matching := matchingPaths(paths, st.Prefix)
+		if len(matching) == 0 {
+			continue
+		}
+
+		count, err := l.count(ctx, st)
+		if err != nil {
+			return err
+		}
+		if count+int64(len(matching)) <= st.MaxObjects {
+			continue
+		}
+
+		// Near the limit: overwrites do not add objects
+		added, err := l.newPaths(ctx, matching)
+		if err != nil {
+			return err
+		}
+		if count+added <= st.MaxObjects {
+			continue
+		}
+
+		l.mu.Lock()
+		if st.Action == LimitActionWarn {
+			st.warnings++
+			logWarning := time.Since(st.warned) >= l.interval
+			if logWarning {
+				st.warned = time.Now()
+			}
+			l.mu.Unlock()
+
+			if logWarning {
+				slog.Warn("object limit exceeded", "prefix", st.Prefix, "count", count+added, "max_objects", st.MaxObjects)
+			}
+			continue
+		}
+		st.rejected++
+		l.mu.Unlock()
+
+		return &ObjectLimitError{Prefix: st.Prefix, MaxObjects: st.MaxObjects, Count: count}
+	}
+	return nil
+}
+
+// count returns the cached count of st, loading it from the repository when
+// it is unknown.
+func (l *ObjectLimiter) count(ctx context.Context, st *limitState) (int64, error) {
+	l.mu.Lock()
+	if st.known {
+		defer l.mu.Unlock()
+		return st.count, nil
+	}
+	l.mu.Unlock()
+
+	count, err := l.repo.CountPrefix(ctx, st.Prefix)
+	if err != nil {
+		return 0, fmt.Errorf("count objects under %q: %w", st.Prefix, err)
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if !st.known {
+		st.count, st.known = count, true
+	}
+	return st.count, nil
+}
+
+// newPaths counts the distinct paths that have no active object.
+func (l *ObjectLimiter) newPaths(ctx context.Context, paths []string) (int64, error) {
+	seen := make(map[string]bool, len(paths))
+	var added int64
+	for _, path := range paths {
+		if seen[path] {
+			continue
+		}
+		seen[path] = true
+
+		_, err := l.repo.Get(ctx, path)
+		switch {
+		case errors.Is(err, ErrNotFound):
+			added++
+		case err != nil:
+			return 0, fmt.Errorf("check %s: %w", path, err)
+		}
+	}
+	return added, nil
+}
+
+// adjust adds delta to the cached count of every loaded limit matching path.
+func (l *ObjectLimiter) adjust(path string, delta int64) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for _, st := range l.limits {
+		if st.known && strings.HasPrefix(path, st.Prefix) {
+			st.count = max(st.count+delta, 0)
+		}
+	}
+}
+
+// invalidate forgets the counts of limits matching any of paths, so they are
+// reloaded before the next check.
+func (l *ObjectLimiter) invalidate(paths []string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for _, st := range l.limits {
+		if len(matchingPaths(paths, st.Prefix)) > 0 {
+			st.known = false
+		}
+	}
+}
+
+func matchingPaths(paths []string, prefix string) []string {
+	var matching []string
+	for _, path := range paths {
+		if strings.HasPrefix(path, prefix) {
+			matching = append(matching, path)
+		}
+	}
+	return matching
+}
@@ -0,0 +1,267 @@
+package stowry_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/sagarc03/stowry"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func newLimitedService(t *testing.T, limits ...stowry.ObjectLimit) (*stowry.StowryService, *stowry.ObjectLimiter, *SpyMetaDataRepo, *SpyFileStorage) {
+	t.Helper()
+	repo := new(SpyMetaDataRepo)
+	storage := new(SpyFileStorage)
+
+	limiter, err := stowry.NewObjectLimiter(repo, limits, time.Hour)
+	require.NoError(t, err)
+
+	service, err := stowry.NewStowryService(repo, storage, stowry.ServiceConfig{Mode: stowry.ModeStore, ObjectLimiter: limiter})
+	require.NoError(t, err)
+	return service, limiter, repo, storage
+}
+
+// expectWrite lets one Create of path through storage and the repo.
+func expectWrite(repo *SpyMetaDataRepo, storage *SpyFileStorage, path string, created bool) {
+	var previous *stowry.MetaData
+	if !created {
+		previous = &stowry.MetaData{Path: path}
+	}
+	storage.On("Write", mock.Anything, path, mock.Anything).Return(stowry.SaveResult{BytesWritten: 1, Etag: "etag"}, nil).Once()
+	repo.On("Upsert", mock.Anything, mock.MatchedBy(func(e stowry.ObjectEntry) bool { return e.Path == path })).
+		Return(stowry.MetaData{Path: path}, previous, nil).Once()
+}
+
+func createText(service *stowry.StowryService, path string) error {
+	_, err := service.Create(context.Background(), stowry.CreateObject{Path: path, ContentType: "text/plain"}, strings.NewReader("x"))
+	return err
+}
+
+func TestNewObjectLimiter(t *testing.T) {
+	t.Run("no limits disables limiting", func(t *testing.T) {
+		limiter, err := stowry.NewObjectLimiter(new(SpyMetaDataRepo), nil, 0)
+		require.NoError(t, err)
+		assert.Nil(t, limiter)
+	})
+
+	invalid := []struct {
+		name    string
+		limits  []stowry.ObjectLimit
+		wantErr string
+	}{
+		{name: "zero max", limits: []stowry.ObjectLimit{{Prefix: "a/"}}, wantErr: "max_objects must be at least 1"},
+		{name: "unknown action", limits: []stowry.ObjectLimit{{Prefix: "a/", MaxObjects: 1, Action: "drop"}}, wantErr: `invalid action "drop"`},
+		{name: "duplicate prefix", limits: []stowry.ObjectLimit{{Prefix: "a/", MaxObjects: 1}, {Prefix: "a/", MaxObjects: 2}}, wantErr: "duplicate prefix"},
+	}
+	for _, tt := range invalid {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := stowry.NewObjectLimiter(new(SpyMetaDataRepo), tt.limits, 0)
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.wantErr)
+			assert.Equal(t, err, stowry.ValidateObjectLimits(tt.limits))
+		})
+	}
+}
+
+func TestObjectLimiter_Create(t *testing.T) {
+	t.Run("counts are cached and follow creates and deletes", func(t *testing.T) {
+		service, limiter, repo, storage := newLimitedService(t, stowry.ObjectLimit{Prefix: "cache/", MaxObjects: 10})
+
+		repo.On("CountPrefix", mock.Anything, "cache/").Return(int64(3), nil).Once()
+		expectWrite(repo, storage, "cache/a.txt", true)
+		expectWrite(repo, storage, "cache/b.txt", true)
+		expectWrite(repo, storage, "cache/a.txt", false)
+		repo.On("Delete", mock.Anything, "cache/b.txt").Return(nil).Once()
+
+		require.NoError(t, createText(service, "cache/a.txt"))
+		require.NoError(t, createText(service, "cache/b.txt"))
+		require.NoError(t, createText(service, "cache/a.txt"), "overwrite")
+		assert.Equal(t, map[string]int64{"cache/": 5}, limiter.Counts())
+
+		require.NoError(t, service.Delete(context.Background(), "cache/b.txt"))
+		assert.Equal(t, map[string]int64{"cache/": 4}, limiter.Counts())
+
+		repo.AssertExpectations(t)
+		repo.AssertNumberOfCalls(t, "CountPrefix", 1)
+	})
+
+	t.Run("paths outside every prefix are not counted", func(t *testing.T) {
+		service, limiter, repo, storage := newLimitedService(t, stowry.ObjectLimit{Prefix: "cache/", MaxObjects: 1})
+
+		expectWrite(repo, storage, "other/a.txt", true)
+
+		require.NoError(t, createText(service, "other/a.txt"))
+		assert.Empty(t, limiter.Counts())
+		repo.AssertNotCalled(t, "CountPrefix", mock.Anything, mock.Anything)
+	})
+
+	t.Run("reject at the limit", func(t *testing.T) {
+		service, _, repo, storage := newLimitedService(t, stowry.ObjectLimit{Prefix: "cache/", MaxObjects: 2, Action: stowry.LimitActionReject})
+
+		repo.On("CountPrefix", mock.Anything, "cache/").Return(int64(2), nil).Once()
+		repo.On("Get", mock.Anything, "cache/new.txt").Return(stowry.MetaData{}, stowry.ErrNotFound).Once()
+
+		err := createText(service, "cache/new.txt")
+
+		require.ErrorIs(t, err, stowry.ErrObjectLimitExceeded)
+		var limitErr *stowry.ObjectLimitError
+		require.ErrorAs(t, err, &limitErr)
+		assert.Equal(t, stowry.ObjectLimitError{Prefix: "cache/", MaxObjects: 2, Count: 2}, *limitErr)
+		storage.AssertNotCalled(t, "Write", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("overwrite at the limit is allowed", func(t *testing.T) {
+		service, limiter, repo, storage := newLimitedService(t, stowry.ObjectLimit{Prefix: "cache/", MaxObjects: 2})
+
+		repo.On("CountPrefix", mock.Anything, "cache/").Return(int64(2), nil).Once()
+		repo.On("Get", mock.Anything, "cache/old.txt").Return(stowry.MetaData{Path: "cache/old.txt"}, nil).Once()
+		expectWrite(repo, storage, "cache/old.txt", false)
+
+		require.NoError(t, createText(service, "cache/old.txt"))
+		assert.Equal(t, map[string]int64{"cache/": 2}, limiter.Counts())
+	})
+
+	t.Run("warn accepts the create", func(t *testing.T) {
+		service, limiter, repo, storage := newLimitedService(t, stowry.ObjectLimit{Prefix: "logs/", MaxObjects: 1, Action: stowry.LimitActionWarn})
+
+		repo.On("CountPrefix", mock.Anything, "logs/").Return(int64(1), nil).Once()
+		repo.On("Get", mock.Anything, "logs/b.txt").Return(stowry.MetaData{}, stowry.ErrNotFound).Once()
+		expectWrite(repo, storage, "logs/b.txt", true)
+
+		require.NoError(t, createText(service, "logs/b.txt"))
+		assert.Equal(t, map[string]int64{"logs/": 2}, limiter.Counts())
+	})
+
+	t.Run("nested limits all apply", func(t *testing.T) {
+		service, _, repo, _ := newLimitedService(t,
+			stowry.ObjectLimit{Prefix: "cache/", MaxObjects: 100},
+			stowry.ObjectLimit{Prefix: "cache/img/", MaxObjects: 1},
+		)
+
+		repo.On("CountPrefix", mock.Anything, "cache/").Return(int64(5), nil).Once()
+		repo.On("CountPrefix", mock.Anything, "cache/img/").Return(int64(1), nil).Once()
+		repo.On("Get", mock.Anything, "cache/img/b.png").Return(stowry.MetaData{}, stowry.ErrNotFound).Once()
+
+		err := createText(service, "cache/img/b.png")
+
+		var limitErr *stowry.ObjectLimitError
+		require.ErrorAs(t, err, &limitErr)
+		assert.Equal(t, "cache/img/", limitErr.Prefix)
+	})
+
+	t.Run("count failure fails the create", func(t *testing.T) {
+		service, _, repo, storage := newLimitedService(t, stowry.ObjectLimit{Prefix: "cache/", MaxObjects: 2})
+
+		repo.On("CountPrefix", mock.Anything, "cache/").Return(int64(0), errors.New("db down")).Once()
+
+		err := createText(service, "cache/a.txt")
+
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "db down")
+		storage.AssertNotCalled(t, "Write", mock.Anything, mock.Anything, mock.Anything)
+	})
+}
+
+func TestObjectLimiter_CreateMany(t *testing.T) {
+	open := func(stowry.CreateObject) (io.ReadCloser, error) { return io.NopCloser(strings.NewReader("x")), nil }
+	objs := []stowry.CreateObject{
+		{Path: "cache/a.txt", ContentType: "text/plain"},
+		{Path: "cache/b.txt", ContentType: "text/plain"},
+	}
+
+	t.Run("rejects a batch that would pass the limit", func(t *testing.T) {
+		service, _, repo, storage := newLimitedService(t, stowry.ObjectLimit{Prefix: "cache/", MaxObjects: 2})
+
+		repo.On("CountPrefix", mock.Anything, "cache/").Return(int64(1), nil).Once()
+		repo.On("Get", mock.Anything, mock.Anything).Return(stowry.MetaData{}, stowry.ErrNotFound)
+
+		_, err := service.CreateMany(context.Background(), objs, open)
+
+		require.ErrorIs(t, err, stowry.ErrObjectLimitExceeded)
+		storage.AssertNotCalled(t, "Write", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("recounts after a batch", func(t *testing.T) {
+		service, limiter, repo, storage := newLimitedService(t, stowry.ObjectLimit{Prefix: "cache/", MaxObjects: 10})
+
+		repo.On("CountPrefix", mock.Anything, "cache/").Return(int64(1), nil).Once()
+		repo.On("ExistingPaths", mock.Anything, mock.Anything).Return([]string{}, nil).Once()
+		storage.On("Write", mock.Anything, mock.Anything, mock.Anything).Return(stowry.SaveResult{BytesWritten: 1, Etag: "etag"}, nil)
+		repo.On("UpsertBatch", mock.Anything, mock.Anything).Return(make([]stowry.MetaData, 2), nil).Once()
+
+		_, err := service.CreateMany(context.Background(), objs, open)
+		require.NoError(t, err)
+		assert.Empty(t, limiter.Counts(), "count is reloaded before the next check")
+
+		repo.On("CountPrefix", mock.Anything, "cache/").Return(int64(3), nil).Once()
+		expectWrite(repo, storage, "cache/c.txt", true)
+		require.NoError(t, createText(service, "cache/c.txt"))
+		assert.Equal(t, map[string]int64{"cache/": 4}, limiter.Counts())
+	})
+}
+
+func TestObjectLimiter_Reconcile(t *testing.T) {
+	t.Run("replaces drifted counts", func(t *testing.T) {
+		service, limiter, repo, storage := newLimitedService(t,
+			stowry.ObjectLimit{Prefix: "a/", MaxObjects: 10},
+			stowry.ObjectLimit{Prefix: "b/", MaxObjects: 10},
+		)
+		ctx := context.Background()
+
+		repo.On("CountPrefix", mock.Anything, "a/").Return(int64(3), nil).Once()
+		expectWrite(repo, storage, "a/x.txt", true)
+		require.NoError(t, createText(service, "a/x.txt"))
+		assert.Equal(t, map[string]int64{"a/": 4}, limiter.Counts())
+
+		repo.On("CountPrefix", ctx, "a/").Return(int64(7), nil).Once()
+		repo.On("CountPrefix", ctx, "b/").Return(int64(2), nil).Once()
+		require.NoError(t, limiter.Reconcile(ctx))
+		assert.Equal(t, map[string]int64{"a/": 7, "b/": 2}, limiter.Counts())
+	})
+
+	t.Run("keeps counts that fail to reload", func(t *testing.T) {
+		repo := new(SpyMetaDataRepo)
+		limiter, err := stowry.NewObjectLimiter(repo, []stowry.ObjectLimit{{Prefix: "a/", MaxObjects: 10}, {Prefix: "b/", MaxObjects: 10}}, time.Hour)
+		require.NoError(t, err)
+		ctx := context.Background()
+
+		repo.On("CountPrefix", ctx, "a/").Return(int64(1), nil).Once()
+		repo.On("CountPrefix", ctx, "b/").Return(int64(0), errors.New("db down")).Once()
+
+		err = limiter.Reconcile(ctx)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "b/: db down")
+		assert.Equal(t, map[string]int64{"a/": 1}, limiter.Counts())
+	})
+
+	t.Run("run reconciles until cancelled", func(t *testing.T) {
+		repo := new(SpyMetaDataRepo)
+		limiter, err := stowry.NewObjectLimiter(repo, []stowry.ObjectLimit{{Prefix: "a/", MaxObjects: 10}}, 10*time.Millisecond)
+		require.NoError(t, err)
+
+		var calls atomic.Int32
+		repo.On("CountPrefix", mock.Anything, "a/").Return(int64(4), nil).Run(func(mock.Arguments) { calls.Add(1) })
+
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			limiter.Run(ctx)
+		}()
+
+		require.Eventually(t, func() bool {
+			return calls.Load() >= 2
+		}, time.Second, 5*time.Millisecond)
+		cancel()
+		<-done
+
+		assert.Equal(t, map[string]int64{"a/": 4}, limiter.Counts())
+	})
+}
@@ -0,0 +1,58 @@
+package stowry
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// cursorOverheadBytes is what a backend may add to the path a list cursor
+// encodes, such as a timestamp and separator, before base64 encoding it.
+const cursorOverheadBytes = 64
+
+// ListQueryError reports a ListQuery parameter that no object path could
+// need. It wraps ErrInvalidInput.
+type ListQueryError struct {
+	Param  string // "prefix" or "cursor", as named by the list API
+	Reason string
+}
+
+func (e *ListQueryError) Error() string {
+	return fmt.Sprintf("%s: %s %s", ErrInvalidInput, e.Param, e.Reason)
+}
+
+func (e *ListQueryError) Unwrap() error {
+	return ErrInvalidInput
+}
+
+// MaxCursorBytes is the longest list cursor a backend produces for paths
+// within limits, with zero fields of limits taken as their defaults.
+func MaxCursorBytes(limits PathLimits) int {
+	return base64.URLEncoding.EncodedLen(limits.WithDefaults().MaxKeyBytes + cursorOverheadBytes)
+}
+
+// Validate reports a PathPrefix or Cursor that could not come from an
+// object path within limits: a prefix longer than MaxKeyBytes or holding a
+// NUL byte or invalid UTF-8, or a cursor longer than MaxCursorBytes. Such
+// values match nothing, and rejecting them keeps pathological input out of
+// database queries.
+//
+// Returns:
+//   - error: a *ListQueryError naming the parameter, which matches
+//     ErrInvalidInput, or nil
+func (q ListQuery) Validate(limits PathLimits) error {
+	limits = limits.WithDefaults()
+
+	switch {
+	case len(q.PathPrefix) > limits.MaxKeyBytes:
+		return &ListQueryError{Param: "prefix", Reason: fmt.Sprintf("is %d bytes, %s is %d", len(q.PathPrefix), LimitMaxKeyBytes, limits.MaxKeyBytes)}
+	case strings.IndexByte(q.PathPrefix, 0) >= 0:
+		return &ListQueryError{Param: "prefix", Reason: "contains a NUL byte"}
+	case !utf8.ValidString(q.PathPrefix):
+		return &ListQueryError{Param: "prefix", Reason: "is not valid UTF-8"}
+	case len(q.Cursor) > MaxCursorBytes(limits):
+		return &ListQueryError{Param: "cursor", Reason: fmt.Sprintf("is %d bytes, at most %d are accepted", len(q.Cursor), MaxCursorBytes(limits))}
+	}
+	return nil
+}
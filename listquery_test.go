@@ -0,0 +1,71 @@
+package stowry_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/sagarc03/stowry"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestListQuery_Validate(t *testing.T) {
+	limits := stowry.DefaultPathLimits()
+
+	valid := []stowry.ListQuery{
+		{},
+		{PathPrefix: "docs/"},
+		{PathPrefix: strings.Repeat("a", stowry.DefaultMaxKeyBytes)},
+		{PathPrefix: strings.Repeat("é", stowry.DefaultMaxKeyBytes/2)},
+		{Cursor: strings.Repeat("A", stowry.MaxCursorBytes(limits))},
+	}
+	for _, q := range valid {
+		assert.NoError(t, q.Validate(limits), "prefix of %d bytes", len(q.PathPrefix))
+	}
+
+	invalid := []struct {
+		name  string
+		q     stowry.ListQuery
+		param string
+	}{
+		{name: "long prefix", q: stowry.ListQuery{PathPrefix: strings.Repeat("a", 200_000)}, param: "prefix"},
+		{name: "NUL byte", q: stowry.ListQuery{PathPrefix: "docs/\x00"}, param: "prefix"},
+		{name: "invalid UTF-8", q: stowry.ListQuery{PathPrefix: "docs/\xff"}, param: "prefix"},
+		{name: "long cursor", q: stowry.ListQuery{Cursor: strings.Repeat("A", stowry.MaxCursorBytes(limits)+1)}, param: "cursor"},
+	}
+	for _, tt := range invalid {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.q.Validate(limits)
+			require.ErrorIs(t, err, stowry.ErrInvalidInput)
+			var queryErr *stowry.ListQueryError
+			require.ErrorAs(t, err, &queryErr)
+			assert.Equal(t, tt.param, queryErr.Param)
+		})
+	}
+
+	t.Run("follows max_key_bytes", func(t *testing.T) {
+		q := stowry.ListQuery{PathPrefix: strings.Repeat("a", 2000)}
+		assert.Error(t, q.Validate(limits))
+		assert.NoError(t, q.Validate(stowry.PathLimits{MaxKeyBytes: 4096}))
+	})
+}
+
+func TestStowryService_List_InvalidQuery(t *testing.T) {
+	service, repo, _ := NewStowryService(t)
+	ctx := context.Background()
+	q := stowry.ListQuery{PathPrefix: strings.Repeat("a", 200_000)}
+
+	_, err := service.List(ctx, q)
+	assert.ErrorIs(t, err, stowry.ErrInvalidInput)
+
+	_, err = service.TombstoneWithReport(ctx, stowry.TombstoneOptions{Query: q})
+	assert.ErrorIs(t, err, stowry.ErrInvalidInput)
+
+	_, err = service.Verify(ctx, stowry.VerifyOptions{Query: q})
+	assert.ErrorIs(t, err, stowry.ErrInvalidInput)
+
+	repo.AssertNotCalled(t, "List", mock.Anything, mock.Anything)
+	repo.AssertNotCalled(t, "ListPendingCleanup", mock.Anything, mock.Anything)
+}
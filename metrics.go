@@ -0,0 +1,55 @@
+package stowry
+
+import (
+	"context"
+	"io"
+	"time"
+)
+
+// ServiceMetrics receives measurements of the work a StowryService does, for
+// export to a monitoring system. Implementations must be safe for concurrent
+// use.
+type ServiceMetrics interface {
+	// ObserveStorage records one FileStorage call. op is "get", "write",
+	// "delete" or "list". A write includes the time spent reading its
+	// content, so it is as slow as the client sending it.
+	ObserveStorage(op string, elapsed time.Duration, err error)
+
+	// ObserveTombstone records a TombstoneWithReport run that was not a dry
+	// run, including one that stopped on an error.
+	ObserveTombstone(report TombstoneReport)
+}
+
+func (s *StowryService) observeStorage(op string, start time.Time, err error) {
+	if s.metrics != nil {
+		s.metrics.ObserveStorage(op, time.Since(start), err)
+	}
+}
+
+func (s *StowryService) storageGet(ctx context.Context, path string) (io.ReadSeekCloser, error) {
+	start := time.Now()
+	f, err := s.storage.Get(ctx, path)
+	s.observeStorage("get", start, err)
+	return f, err
+}
+
+func (s *StowryService) storageWrite(ctx context.Context, path string, content io.Reader) (SaveResult, error) {
+	start := time.Now()
+	res, err := s.storage.Write(ctx, path, content)
+	s.observeStorage("write", start, err)
+	return res, err
+}
+
+func (s *StowryService) storageDelete(ctx context.Context, path string) error {
+	start := time.Now()
+	err := s.storage.Delete(ctx, path)
+	s.observeStorage("delete", start, err)
+	return err
+}
+
+func (s *StowryService) storageList(ctx context.Context) ([]ObjectEntry, error) {
+	start := time.Now()
+	entries, err := s.storage.List(ctx)
+	s.observeStorage("list", start, err)
+	return entries, err
+}
@@ -0,0 +1,98 @@
+package stowry_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sagarc03/stowry"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// recordingMetrics is a stowry.ServiceMetrics that keeps what it is told.
+type recordingMetrics struct {
+	mu         sync.Mutex
+	storage    []string // op and "ok" or the error
+	tombstones []stowry.TombstoneReport
+}
+
+func (m *recordingMetrics) ObserveStorage(op string, _ time.Duration, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	result := "ok"
+	if err != nil {
+		result = err.Error()
+	}
+	m.storage = append(m.storage, op+" "+result)
+}
+
+func (m *recordingMetrics) ObserveTombstone(report stowry.TombstoneReport) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.tombstones = append(m.tombstones, report)
+}
+
+func newMeasuredService(t *testing.T) (*stowry.StowryService, *SpyMetaDataRepo, *SpyFileStorage, *recordingMetrics) {
+	t.Helper()
+	repo := new(SpyMetaDataRepo)
+	storage := new(SpyFileStorage)
+	metrics := new(recordingMetrics)
+	s, err := stowry.NewStowryService(repo, storage, stowry.ServiceConfig{Mode: stowry.ModeStore, Metrics: metrics})
+	require.NoError(t, err)
+	return s, repo, storage, metrics
+}
+
+func TestStowryService_Metrics(t *testing.T) {
+	t.Run("storage calls", func(t *testing.T) {
+		service, repo, storage, metrics := newMeasuredService(t)
+		ctx := context.Background()
+
+		repo.On("Get", ctx, "a.txt").Return(stowry.MetaData{Path: "a.txt"}, nil)
+		storage.On("Get", ctx, "a.txt").Return(&mockReadSeekCloser{content: []byte("a")}, nil)
+		repo.On("Get", ctx, "gone.txt").Return(stowry.MetaData{Path: "gone.txt"}, nil)
+		storage.On("Get", ctx, "gone.txt").Return((*mockReadSeekCloser)(nil), stowry.ErrNotFound)
+
+		_, _, err := service.Get(ctx, "a.txt")
+		require.NoError(t, err)
+		_, _, err = service.Get(ctx, "gone.txt")
+		require.ErrorIs(t, err, stowry.ErrNotFound)
+
+		assert.Equal(t, []string{"get ok", "get " + stowry.ErrNotFound.Error()}, metrics.storage)
+	})
+
+	t.Run("tombstone run", func(t *testing.T) {
+		service, repo, storage, metrics := newMeasuredService(t)
+		ctx := context.Background()
+		id := uuid.New()
+		query := stowry.ListQuery{Limit: 10}
+
+		repo.On("ListPendingCleanup", ctx, query).Return(stowry.ListResult{
+			Items: []stowry.MetaData{{ID: id, Path: "old.txt", FileSizeBytes: 10}},
+		}, nil)
+		storage.On("Delete", ctx, "old.txt").Return(nil)
+		repo.On("MarkCleanedUp", ctx, id).Return(nil)
+
+		_, err := service.TombstoneWithReport(ctx, stowry.TombstoneOptions{Query: query})
+		require.NoError(t, err)
+
+		require.Len(t, metrics.tombstones, 1)
+		assert.Equal(t, 1, metrics.tombstones[0].Cleaned)
+		assert.Equal(t, int64(10), metrics.tombstones[0].BytesReclaimed)
+		assert.Equal(t, []string{"delete ok"}, metrics.storage)
+	})
+
+	t.Run("dry run is not recorded", func(t *testing.T) {
+		service, repo, _, metrics := newMeasuredService(t)
+		ctx := context.Background()
+		query := stowry.ListQuery{Limit: 10}
+
+		repo.On("ListPendingCleanup", ctx, query).Return(stowry.ListResult{}, nil)
+
+		_, err := service.TombstoneWithReport(ctx, stowry.TombstoneOptions{Query: query, DryRun: true})
+		require.NoError(t, err)
+		assert.Empty(t, metrics.tombstones)
+	})
+}
@@ -0,0 +1,121 @@
+package stowry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// RenameRepo is an optional MetaDataRepo extension for moving an object to
+// another path. The service checks for it with a type assertion.
+type RenameRepo interface {
+	// Rename moves the active entry at oldPath to newPath in a single
+	// transaction, keeping its ID, content and creation time, and records a
+	// delete of oldPath and a create of newPath in the change feed. A
+	// soft-deleted entry at newPath is dropped, as an upload to newPath
+	// would replace it. The entry records backend as its StorageBackend,
+	// since the file follows the routing of newPath.
+	//
+	// Returns:
+	//   - MetaData: The entry at newPath
+	//   - error: ErrNotFound if oldPath has no active entry, ErrAlreadyExists
+	//     if newPath has one, or other database errors
+	Rename(ctx context.Context, oldPath, newPath, backend string) (MetaData, error)
+}
+
+// Move renames the active object at srcPath to dstPath. The stored file is
+// moved to wherever dstPath routes, across backends if need be, and the
+// metadata then switches over
+// in a single repository operation, so the object is never listed at both
+// paths or at neither. If the metadata update fails, the file is moved back.
+//
+// dstPath is validated as for Create, including the content type rules, but
+// must not hold an active object; a soft-deleted object there is replaced.
+//
+// Parameters:
+//   - ctx: Context for cancellation and timeout
+//   - srcPath: Path of the object to move, used as is without the static and
+//     SPA fallbacks
+//   - dstPath: Path to move it to
+//
+// Returns:
+//   - MetaData: The object at dstPath
+//   - error: ErrNotFound if no active object exists at srcPath,
+//     ErrAlreadyExists if one exists at dstPath, ErrInvalidInput for invalid
+//     paths or equal ones, ErrNotSupported when the repository or storage
+//     cannot rename, or storage and database errors
+func (s *StowryService) Move(ctx context.Context, srcPath, dstPath string) (MetaData, error) {
+	if err := ctx.Err(); err != nil {
+		return MetaData{}, fmt.Errorf("move object: %w", err)
+	}
+	if srcPath == "" || !IsValidPath(srcPath) {
+		return MetaData{}, fmt.Errorf("move object %s: %w: invalid source path", srcPath, ErrInvalidInput)
+	}
+	if srcPath == dstPath {
+		return MetaData{}, fmt.Errorf("move object %s: %w: source and destination are the same", srcPath, ErrInvalidInput)
+	}
+
+	repo, ok := s.repo.(RenameRepo)
+	if !ok {
+		return MetaData{}, fmt.Errorf("move object: metadata repository does not support renaming: %w", ErrNotSupported)
+	}
+
+	src, err := s.repo.Get(ctx, srcPath)
+	if err != nil {
+		return MetaData{}, fmt.Errorf("move object %s: %w", srcPath, err)
+	}
+	if _, err := s.prepareCreateObject(CreateObject{
+		Path:            dstPath,
+		ContentType:     src.ContentType,
+		ContentEncoding: src.ContentEncoding,
+		Filename:        src.Filename,
+		UserMetadata:    src.UserMetadata,
+		CacheControl:    src.CacheControl,
+	}); err != nil {
+		return MetaData{}, fmt.Errorf("move object: %w", err)
+	}
+
+	// Reads of dstPath go through the routed storage, so the file must too
+	mover, ok := s.storage.(StorageMover)
+	if !ok {
+		return MetaData{}, fmt.Errorf("move object: storage does not support moving: %w", ErrNotSupported)
+	}
+
+	// Renaming the file would replace the file of a live object at dstPath
+	switch _, err := s.repo.Get(ctx, dstPath); {
+	case err == nil:
+		return MetaData{}, fmt.Errorf("move object %s: %w", dstPath, ErrAlreadyExists)
+	case !errors.Is(err, ErrNotFound):
+		return MetaData{}, fmt.Errorf("move object %s: %w", dstPath, err)
+	}
+	if s.objectLimiter != nil {
+		if err := s.objectLimiter.admit(ctx, []string{dstPath}); err != nil {
+			return MetaData{}, fmt.Errorf("move object %s: %w", dstPath, err)
+		}
+	}
+
+	if err := mover.Move(ctx, srcPath, dstPath); err != nil {
+		return MetaData{}, fmt.Errorf("move object %s: %w", srcPath, err)
+	}
+
+	moved, err := repo.Rename(ctx, srcPath, dstPath, s.storageBackend(dstPath))
+	if err != nil {
+		// Use background context for cleanup since original context may be cancelled
+		cleanupCtx, cancel := context.WithTimeout(context.Background(), s.cleanupTimeout)
+		defer cancel()
+
+		if undoErr := mover.Move(cleanupCtx, dstPath, srcPath); undoErr != nil {
+			return MetaData{}, fmt.Errorf("move object %s: %w and rollback failed: %w", srcPath, err, undoErr)
+		}
+		return MetaData{}, fmt.Errorf("move object %s: %w", srcPath, err)
+	}
+
+	if s.objectLimiter != nil {
+		s.objectLimiter.adjust(srcPath, -1)
+		s.objectLimiter.adjust(dstPath, 1)
+	}
+	s.index.invalidate(srcPath, dstPath)
+	s.changes.notify()
+
+	return moved, nil
+}
@@ -0,0 +1,151 @@
+package stowry_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/sagarc03/stowry"
+	"github.com/sagarc03/stowry/database/sqlite"
+	"github.com/sagarc03/stowry/filesystem"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// failingRenameRepo is a MetaDataRepo whose Rename always fails.
+type failingRenameRepo struct {
+	stowry.MetaDataRepo
+}
+
+func (failingRenameRepo) Rename(context.Context, string, string, string) (stowry.MetaData, error) {
+	return stowry.MetaData{}, errors.New("db down")
+}
+
+func TestStowryService_Move(t *testing.T) {
+	ctx := context.Background()
+	create := func(t *testing.T, service *stowry.StowryService, path, content string) stowry.MetaData {
+		t.Helper()
+		res, err := service.Create(ctx, stowry.CreateObject{Path: path, ContentType: "text/plain"}, strings.NewReader(content))
+		require.NoError(t, err)
+		return res.MetaData
+	}
+
+	t.Run("renames the file and metadata", func(t *testing.T) {
+		root := newStorageRoot(t)
+		service := newCopyService(t, filesystem.NewFileStorage(root))
+		src := create(t, service, "uploads/tmp/foo.txt", "foo")
+
+		moved, err := service.Move(ctx, "uploads/tmp/foo.txt", "images/foo.txt")
+		require.NoError(t, err)
+
+		assert.Equal(t, src.ID, moved.ID, "the object keeps its ID")
+		assert.Equal(t, "images/foo.txt", moved.Path)
+		assert.Equal(t, src.Etag, moved.Etag)
+		assert.Equal(t, "foo", readObject(t, service, "images/foo.txt"))
+		_, err = service.Info(ctx, "uploads/tmp/foo.txt")
+		assert.ErrorIs(t, err, stowry.ErrNotFound)
+		_, err = root.Stat("uploads/tmp/foo.txt")
+		assert.ErrorIs(t, err, os.ErrNotExist)
+	})
+
+	t.Run("file follows the routing of the destination", func(t *testing.T) {
+		def, fast := newMemStorage(), newMemStorage()
+		routed, err := stowry.NewRoutedStorage(def, []stowry.StorageRoute{{Prefix: "fast/", Storage: fast}})
+		require.NoError(t, err)
+		service := newCopyService(t, routed)
+		create(t, service, "fast/a.txt", "a")
+
+		moved, err := service.Move(ctx, "fast/a.txt", "b.txt")
+		require.NoError(t, err)
+		assert.Empty(t, moved.StorageBackend)
+		assert.Equal(t, "a", readObject(t, service, "b.txt"))
+		assert.Equal(t, []string{"b.txt"}, def.paths())
+		assert.Empty(t, fast.paths())
+
+		moved, err = service.Move(ctx, "b.txt", "fast/c.txt")
+		require.NoError(t, err)
+		assert.Equal(t, "fast/", moved.StorageBackend)
+		assert.Equal(t, "a", readObject(t, service, "fast/c.txt"))
+		assert.Equal(t, []string{"fast/c.txt"}, fast.paths())
+	})
+
+	t.Run("destination exists", func(t *testing.T) {
+		service := newCopyService(t, newMemStorage())
+		create(t, service, "a.txt", "a")
+		create(t, service, "b.txt", "b")
+
+		_, err := service.Move(ctx, "a.txt", "b.txt")
+		assert.ErrorIs(t, err, stowry.ErrAlreadyExists)
+		assert.Equal(t, "a", readObject(t, service, "a.txt"))
+		assert.Equal(t, "b", readObject(t, service, "b.txt"))
+	})
+
+	t.Run("soft-deleted destination is replaced", func(t *testing.T) {
+		service := newCopyService(t, newMemStorage())
+		create(t, service, "a.txt", "a")
+		create(t, service, "b.txt", "b")
+		require.NoError(t, service.Delete(ctx, "b.txt"))
+
+		_, err := service.Move(ctx, "a.txt", "b.txt")
+		require.NoError(t, err)
+		assert.Equal(t, "a", readObject(t, service, "b.txt"))
+	})
+
+	t.Run("missing or deleted source", func(t *testing.T) {
+		service := newCopyService(t, newMemStorage())
+		create(t, service, "a.txt", "a")
+		require.NoError(t, service.Delete(ctx, "a.txt"))
+
+		_, err := service.Move(ctx, "a.txt", "b.txt")
+		assert.ErrorIs(t, err, stowry.ErrNotFound)
+		_, err = service.Move(ctx, "missing.txt", "b.txt")
+		assert.ErrorIs(t, err, stowry.ErrNotFound)
+	})
+
+	t.Run("invalid paths", func(t *testing.T) {
+		service := newCopyService(t, newMemStorage())
+		create(t, service, "a.txt", "a")
+
+		for name, paths := range map[string][2]string{
+			"invalid source":      {"../a.txt", "b.txt"},
+			"invalid destination": {"a.txt", "../b.txt"},
+			"same path":           {"a.txt", "a.txt"},
+			"reserved":            {"a.txt", stowry.StagePrefix + "b.txt"},
+		} {
+			_, err := service.Move(ctx, paths[0], paths[1])
+			assert.ErrorIs(t, err, stowry.ErrInvalidInput, name)
+		}
+	})
+
+	t.Run("file is moved back when the rename fails", func(t *testing.T) {
+		db, err := sqlite.Connect(ctx, filepath.Join(t.TempDir(), "stowry.db"), stowry.Tables{MetaData: "stowry_metadata"})
+		require.NoError(t, err)
+		t.Cleanup(func() { _ = db.Close() })
+		require.NoError(t, db.Migrate(ctx))
+		root := newStorageRoot(t)
+		service, err := stowry.NewStowryService(failingRenameRepo{db.GetRepo()}, filesystem.NewFileStorage(root), stowry.ServiceConfig{Mode: stowry.ModeStore})
+		require.NoError(t, err)
+		create(t, service, "a.txt", "a")
+
+		_, err = service.Move(ctx, "a.txt", "b.txt")
+		assert.ErrorContains(t, err, "db down")
+		assert.Equal(t, "a", readObject(t, service, "a.txt"))
+		_, err = root.Stat("b.txt")
+		assert.ErrorIs(t, err, os.ErrNotExist)
+	})
+
+	t.Run("not supported without RenameRepo", func(t *testing.T) {
+		db, err := sqlite.Connect(ctx, filepath.Join(t.TempDir(), "stowry.db"), stowry.Tables{MetaData: "stowry_metadata"})
+		require.NoError(t, err)
+		t.Cleanup(func() { _ = db.Close() })
+		require.NoError(t, db.Migrate(ctx))
+		service, err := stowry.NewStowryService(struct{ stowry.MetaDataRepo }{db.GetRepo()}, newMemStorage(), stowry.ServiceConfig{Mode: stowry.ModeStore})
+		require.NoError(t, err)
+
+		_, err = service.Move(ctx, "a.txt", "b.txt")
+		assert.ErrorIs(t, err, stowry.ErrNotSupported)
+	})
+}
@@ -0,0 +1,426 @@
+package stowry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Multipart upload limits, as in S3.
+const (
+	MaxMultipartParts = 10000 // Highest part number
+)
+
+// DefaultMultipartExpiry is how long an incomplete multipart upload is kept
+// before Tombstone aborts it, when TombstoneOptions.MultipartExpiry is not set.
+const DefaultMultipartExpiry = 7 * 24 * time.Hour
+
+// MultipartUpload is an object being uploaded in parts. It stays invisible
+// until CompleteMultipart joins the parts into the object at Path.
+type MultipartUpload struct {
+	ID              uuid.UUID         `json:"id"`
+	Path            string            `json:"path"`
+	ContentType     string            `json:"content_type"`
+	ContentEncoding string            `json:"content_encoding,omitempty"`
+	Filename        string            `json:"filename,omitempty"`
+	UserMetadata    map[string]string `json:"user_metadata,omitempty"`
+	CacheControl    string            `json:"cache_control,omitempty"`
+	CreatedAt       time.Time         `json:"created_at"`
+}
+
+// MultipartPart is an uploaded part of a MultipartUpload.
+type MultipartPart struct {
+	Number     int       `json:"part_number"`
+	ETag       string    `json:"etag"` // SHA-256 of the part content
+	Size       int64     `json:"size_bytes"`
+	UploadedAt time.Time `json:"uploaded_at"`
+}
+
+// CompletedPart names a part to join by CompleteMultipart, with the ETag
+// UploadPart returned for it.
+type CompletedPart struct {
+	Number int
+	ETag   string
+}
+
+// MultipartPartPath returns the storage path of part number of upload id.
+// Parts live under StagePrefix, so they are never listed or served as
+// objects.
+func MultipartPartPath(id uuid.UUID, number int) string {
+	return fmt.Sprintf("%smultipart/%s/%05d", StagePrefix, id, number)
+}
+
+// MultipartRepo is an optional MetaDataRepo extension recording multipart
+// uploads and their parts. The service checks for it with a type assertion.
+type MultipartRepo interface {
+	// CreateMultipartUpload records upload with a fresh ID and CreatedAt.
+	//
+	// Returns:
+	//   - MultipartUpload: The recorded upload
+	//   - error: Any database error
+	CreateMultipartUpload(ctx context.Context, upload MultipartUpload) (MultipartUpload, error)
+
+	// GetMultipartUpload returns an upload with its parts, ordered by number.
+	//
+	// Returns:
+	//   - MultipartUpload: The upload
+	//   - []MultipartPart: Its parts uploaded so far
+	//   - error: ErrNotFound if the upload does not exist, or other database errors
+	GetMultipartUpload(ctx context.Context, id uuid.UUID) (MultipartUpload, []MultipartPart, error)
+
+	// AddMultipartPart records part, replacing an earlier part with the same
+	// number.
+	//
+	// Returns:
+	//   - error: ErrNotFound if the upload does not exist, or other database errors
+	AddMultipartPart(ctx context.Context, id uuid.UUID, part MultipartPart) error
+
+	// DeleteMultipartUpload removes an upload and its parts.
+	//
+	// Returns:
+	//   - error: ErrNotFound if the upload does not exist, or other database errors
+	DeleteMultipartUpload(ctx context.Context, id uuid.UUID) error
+
+	// StaleMultipartUploads returns up to limit uploads created before
+	// before, oldest first, for Tombstone to abort. A limit of 0 returns all.
+	StaleMultipartUploads(ctx context.Context, before time.Time, limit int) ([]MultipartUpload, error)
+}
+
+// multipartRepo returns the repository's multipart extension.
+func (s *StowryService) multipartRepo() (MultipartRepo, error) {
+	if s.mode != ModeStore {
+		return nil, fmt.Errorf("multipart uploads are only available in store mode: %w", ErrNotSupported)
+	}
+	repo, ok := s.repo.(MultipartRepo)
+	if !ok {
+		return nil, fmt.Errorf("metadata repository does not support multipart uploads: %w", ErrNotSupported)
+	}
+	return repo, nil
+}
+
+// multipartUpload returns upload id with its parts, or ErrNotFound when it
+// does not exist or belongs to another path.
+func (s *StowryService) multipartUpload(ctx context.Context, repo MultipartRepo, path string, id uuid.UUID) (MultipartUpload, []MultipartPart, error) {
+	upload, parts, err := repo.GetMultipartUpload(ctx, id)
+	if err != nil {
+		return MultipartUpload{}, nil, err
+	}
+	if upload.Path != path {
+		return MultipartUpload{}, nil, fmt.Errorf("upload %s: %w", id, ErrNotFound)
+	}
+	return upload, parts, nil
+}
+
+// InitiateMultipart starts an upload of obj in parts. The object is
+// validated like Create; its content is sent with UploadPart.
+//
+// Returns:
+//   - MultipartUpload: The new upload
+//   - error: ErrInvalidInput for an invalid object, ErrNotSupported, or any
+//     database error
+func (s *StowryService) InitiateMultipart(ctx context.Context, obj CreateObject) (MultipartUpload, error) {
+	if err := ctx.Err(); err != nil {
+		return MultipartUpload{}, fmt.Errorf("initiate multipart: %w", err)
+	}
+
+	repo, err := s.multipartRepo()
+	if err != nil {
+		return MultipartUpload{}, fmt.Errorf("initiate multipart: %w", err)
+	}
+	obj, err = s.prepareCreateObject(obj)
+	if err != nil {
+		return MultipartUpload{}, fmt.Errorf("initiate multipart: %w", err)
+	}
+
+	upload, err := repo.CreateMultipartUpload(ctx, MultipartUpload{
+		Path:            obj.Path,
+		ContentType:     obj.ContentType,
+		ContentEncoding: obj.ContentEncoding,
+		Filename:        obj.Filename,
+		UserMetadata:    obj.UserMetadata,
+		CacheControl:    obj.CacheControl,
+	})
+	if err != nil {
+		return MultipartUpload{}, fmt.Errorf("initiate multipart %s: %w", obj.Path, err)
+	}
+	return upload, nil
+}
+
+// UploadPart stores part number of upload id of the object at path.
+// Uploading a part number again replaces the earlier part.
+//
+// Returns:
+//   - MultipartPart: The stored part with the SHA-256 of its content
+//   - error: ErrNotFound for an unknown upload or one of another path,
+//     ErrInvalidPart for a part number outside 1 to MaxMultipartParts,
+//     ErrNotSupported, or storage and database errors
+func (s *StowryService) UploadPart(ctx context.Context, path string, id uuid.UUID, number int, content io.Reader) (MultipartPart, error) {
+	if err := ctx.Err(); err != nil {
+		return MultipartPart{}, fmt.Errorf("upload part: %w", err)
+	}
+
+	repo, err := s.multipartRepo()
+	if err != nil {
+		return MultipartPart{}, fmt.Errorf("upload part: %w", err)
+	}
+	if number < 1 || number > MaxMultipartParts {
+		return MultipartPart{}, fmt.Errorf("upload part: %w: part number must be between 1 and %d", ErrInvalidPart, MaxMultipartParts)
+	}
+	// Fail before writing anything for an upload that does not exist
+	if _, _, err := s.multipartUpload(ctx, repo, path, id); err != nil {
+		return MultipartPart{}, fmt.Errorf("upload part: %w", err)
+	}
+
+	partPath := MultipartPartPath(id, number)
+	saved, err := s.storageWrite(ctx, partPath, content)
+	if err != nil {
+		return MultipartPart{}, fmt.Errorf("upload part %d: %w", number, err)
+	}
+
+	part := MultipartPart{Number: number, ETag: saved.Etag, Size: saved.BytesWritten, UploadedAt: time.Now().UTC()}
+	if err := repo.AddMultipartPart(ctx, id, part); err != nil {
+		cleanupCtx, cancel := context.WithTimeout(context.Background(), s.cleanupTimeout)
+		defer cancel()
+		if delErr := s.storageDelete(cleanupCtx, partPath); delErr != nil {
+			return MultipartPart{}, fmt.Errorf("upload part %d: %w (cleanup failed: %w)", number, err, delErr)
+		}
+		return MultipartPart{}, fmt.Errorf("upload part %d: %w", number, err)
+	}
+	return part, nil
+}
+
+// CompleteMultipart joins the listed parts of upload id, in order, into the
+// object at path and removes the upload. The object is written like Create,
+// atomically and with the SHA-256 of the joined content as its ETag, so it
+// replaces an existing object only once it is whole. Parts adding up to more
+// than maxSize bytes are refused and the upload is kept, so it can still be
+// aborted. Each part is checked as it is uploaded, but only the completion
+// sees their total. Zero or less sets no limit.
+//
+// Returns:
+//   - CreateResult: The created object and the object it replaced, if any
+//   - error: ErrNotFound for an unknown upload or one of another path,
+//     ErrInvalidPart when parts is empty, out of order, or names a part
+//     that was not uploaded or whose ETag differs, ErrObjectTooLarge,
+//     ErrNotSupported, or the errors of Create
+func (s *StowryService) CompleteMultipart(ctx context.Context, path string, id uuid.UUID, parts []CompletedPart, maxSize int64) (CreateResult, error) {
+	if err := ctx.Err(); err != nil {
+		return CreateResult{}, fmt.Errorf("complete multipart: %w", err)
+	}
+
+	repo, err := s.multipartRepo()
+	if err != nil {
+		return CreateResult{}, fmt.Errorf("complete multipart: %w", err)
+	}
+	upload, uploaded, err := s.multipartUpload(ctx, repo, path, id)
+	if err != nil {
+		return CreateResult{}, fmt.Errorf("complete multipart: %w", err)
+	}
+
+	joined, err := selectParts(uploaded, parts)
+	if err != nil {
+		return CreateResult{}, fmt.Errorf("complete multipart %s: %w", path, err)
+	}
+
+	obj := CreateObject{
+		Path:            upload.Path,
+		ContentType:     upload.ContentType,
+		ContentEncoding: upload.ContentEncoding,
+		Filename:        upload.Filename,
+		UserMetadata:    upload.UserMetadata,
+		CacheControl:    upload.CacheControl,
+	}
+	for _, part := range joined {
+		obj.Size += part.Size
+	}
+	if maxSize > 0 && obj.Size > maxSize {
+		return CreateResult{}, fmt.Errorf("complete multipart %s: %w: %d bytes (max %d)", path, ErrObjectTooLarge, obj.Size, maxSize)
+	}
+
+	content := &partsReader{ctx: ctx, storage: s.storage, id: id, parts: joined}
+	res, err := s.Create(ctx, obj, content)
+	_ = content.Close()
+	if err != nil {
+		return res, fmt.Errorf("complete multipart: %w", err)
+	}
+
+	// The object is in place; what is left is only cleanup
+	if err := s.removeMultipart(ctx, repo, id, uploaded); err != nil {
+		slog.Warn("complete multipart: remove parts", "path", path, "upload_id", id, "error", err)
+	}
+	return res, nil
+}
+
+// selectParts returns the uploaded parts named by parts, which must be in
+// ascending order and match the uploaded ETags.
+func selectParts(uploaded []MultipartPart, parts []CompletedPart) ([]MultipartPart, error) {
+	if len(parts) == 0 {
+		return nil, fmt.Errorf("%w: no parts to complete", ErrInvalidPart)
+	}
+
+	byNumber := make(map[int]MultipartPart, len(uploaded))
+	for _, part := range uploaded {
+		byNumber[part.Number] = part
+	}
+
+	joined := make([]MultipartPart, 0, len(parts))
+	for i, p := range parts {
+		if i > 0 && p.Number <= parts[i-1].Number {
+			return nil, fmt.Errorf("%w: parts must be listed in ascending order", ErrInvalidPart)
+		}
+		part, ok := byNumber[p.Number]
+		if !ok {
+			return nil, fmt.Errorf("%w: part %d was not uploaded", ErrInvalidPart, p.Number)
+		}
+		if etag := strings.Trim(p.ETag, `"`); etag != "" && etag != part.ETag {
+			return nil, fmt.Errorf("%w: part %d has etag %s, not %s", ErrInvalidPart, p.Number, part.ETag, etag)
+		}
+		joined = append(joined, part)
+	}
+	return joined, nil
+}
+
+// AbortMultipart removes upload id of the object at path and its parts.
+//
+// Returns:
+//   - error: ErrNotFound for an unknown upload or one of another path,
+//     ErrNotSupported, or storage and database errors
+func (s *StowryService) AbortMultipart(ctx context.Context, path string, id uuid.UUID) error {
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("abort multipart: %w", err)
+	}
+
+	repo, err := s.multipartRepo()
+	if err != nil {
+		return fmt.Errorf("abort multipart: %w", err)
+	}
+	_, parts, err := s.multipartUpload(ctx, repo, path, id)
+	if err != nil {
+		return fmt.Errorf("abort multipart: %w", err)
+	}
+
+	if err := s.removeMultipart(ctx, repo, id, parts); err != nil {
+		return fmt.Errorf("abort multipart: %w", err)
+	}
+	return nil
+}
+
+// removeMultipart deletes the part files of upload id, then its record. A
+// record whose files could not all be deleted is kept, so Tombstone retries.
+func (s *StowryService) removeMultipart(ctx context.Context, repo MultipartRepo, id uuid.UUID, parts []MultipartPart) error {
+	for _, part := range parts {
+		if err := s.storageDelete(ctx, MultipartPartPath(id, part.Number)); err != nil && !errors.Is(err, ErrNotFound) {
+			return fmt.Errorf("delete part %d: %w", part.Number, err)
+		}
+	}
+	return repo.DeleteMultipartUpload(ctx, id)
+}
+
+// reclaimMultipart aborts the multipart uploads under opts.Query.PathPrefix
+// created longer than opts.MultipartExpiry ago, and records them in report.
+// Repositories without multipart uploads have none to reclaim.
+func (s *StowryService) reclaimMultipart(ctx context.Context, opts TombstoneOptions, report *TombstoneReport) error {
+	repo, ok := s.repo.(MultipartRepo)
+	if !ok {
+		return nil
+	}
+	expiry := opts.MultipartExpiry
+	if expiry <= 0 {
+		expiry = DefaultMultipartExpiry
+	}
+
+	uploads, err := repo.StaleMultipartUploads(ctx, time.Now().Add(-expiry), 0)
+	if errors.Is(err, ErrNotSupported) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	for _, upload := range uploads {
+		if !strings.HasPrefix(upload.Path, opts.Query.PathPrefix) {
+			continue
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		_, parts, err := repo.GetMultipartUpload(ctx, upload.ID)
+		if errors.Is(err, ErrNotFound) {
+			// Completed or aborted meanwhile
+			continue
+		}
+		if err == nil && !opts.DryRun {
+			err = s.removeMultipart(ctx, repo, upload.ID, parts)
+		}
+		if err != nil && !errors.Is(err, ErrNotFound) {
+			report.Errors = append(report.Errors, TombstoneError{Path: upload.Path, Err: fmt.Errorf("multipart upload %s: %w", upload.ID, err)})
+			report.Failed++
+			if !opts.ContinueOnError {
+				return fmt.Errorf("multipart upload %s of %s: %w", upload.ID, upload.Path, err)
+			}
+			slog.Warn("tombstone: abort multipart upload failed, continuing", "path", upload.Path, "upload_id", upload.ID, "error", err)
+			continue
+		}
+
+		report.AbortedUploads++
+		for _, part := range parts {
+			report.BytesReclaimed += part.Size
+		}
+	}
+	return nil
+}
+
+// partsReader reads the stored parts of a multipart upload one after the
+// other, opening each only when the previous one is exhausted.
+type partsReader struct {
+	ctx     context.Context
+	storage FileStorage
+	id      uuid.UUID
+	parts   []MultipartPart
+	current io.ReadCloser
+}
+
+func (r *partsReader) Read(p []byte) (int, error) {
+	for {
+		if r.current == nil {
+			if len(r.parts) == 0 {
+				return 0, io.EOF
+			}
+			part := r.parts[0]
+			r.parts = r.parts[1:]
+			f, err := r.storage.Get(r.ctx, MultipartPartPath(r.id, part.Number))
+			if err != nil {
+				return 0, fmt.Errorf("open part %d: %w", part.Number, err)
+			}
+			r.current = f
+		}
+
+		n, err := r.current.Read(p)
+		if errors.Is(err, io.EOF) {
+			_ = r.current.Close()
+			r.current = nil
+			if n == 0 {
+				continue
+			}
+			return n, nil
+		}
+		return n, err
+	}
+}
+
+// Close closes the part being read, if any.
+func (r *partsReader) Close() error {
+	if r.current == nil {
+		return nil
+	}
+	err := r.current.Close()
+	r.current = nil
+	return err
+}
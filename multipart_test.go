@@ -0,0 +1,267 @@
+package stowry_test
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sagarc03/stowry"
+	"github.com/sagarc03/stowry/database/sqlite"
+	"github.com/sagarc03/stowry/filesystem"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type multipartMetaDataRepo interface {
+	stowry.MetaDataRepo
+	stowry.MultipartRepo
+}
+
+// newMultipartService returns a store-mode service over SQLite and a storage
+// directory, with the repository and the storage root.
+func newMultipartService(t *testing.T) (*stowry.StowryService, multipartMetaDataRepo, *os.Root) {
+	t.Helper()
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	db, err := sqlite.Connect(ctx, filepath.Join(dir, "stowry.db"), stowry.Tables{MetaData: "stowry_metadata"})
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = db.Close() })
+	require.NoError(t, db.Migrate(ctx))
+
+	require.NoError(t, os.Mkdir(filepath.Join(dir, "storage"), 0o755))
+	root, err := os.OpenRoot(filepath.Join(dir, "storage"))
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = root.Close() })
+
+	repo := db.GetRepo().(multipartMetaDataRepo)
+	service, err := stowry.NewStowryService(repo, filesystem.NewFileStorage(root), stowry.ServiceConfig{Mode: stowry.ModeStore})
+	require.NoError(t, err)
+	return service, repo, root
+}
+
+// uploadParts uploads each of parts as the part numbered by its position
+// from 1, in reverse order, and returns the parts to complete.
+func uploadParts(t *testing.T, service *stowry.StowryService, path string, id uuid.UUID, parts ...string) []stowry.CompletedPart {
+	t.Helper()
+	completed := make([]stowry.CompletedPart, len(parts))
+	for i := len(parts) - 1; i >= 0; i-- {
+		part, err := service.UploadPart(context.Background(), path, id, i+1, strings.NewReader(parts[i]))
+		require.NoError(t, err)
+		assert.Equal(t, int64(len(parts[i])), part.Size)
+		completed[i] = stowry.CompletedPart{Number: i + 1, ETag: `"` + part.ETag + `"`}
+	}
+	return completed
+}
+
+func partFileExists(root *os.Root, id uuid.UUID, number int) bool {
+	_, err := root.Stat(stowry.MultipartPartPath(id, number))
+	return err == nil
+}
+
+func TestStowryService_Multipart(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("complete joins the parts in order", func(t *testing.T) {
+		service, repo, root := newMultipartService(t)
+
+		upload, err := service.InitiateMultipart(ctx, stowry.CreateObject{Path: "big.bin", ContentType: "application/octet-stream"})
+		require.NoError(t, err)
+		parts := uploadParts(t, service, "big.bin", upload.ID, "first-", "second-", "third")
+
+		_, err = service.Info(ctx, "big.bin")
+		assert.ErrorIs(t, err, stowry.ErrNotFound, "the object is invisible until completed")
+
+		res, err := service.CompleteMultipart(ctx, "big.bin", upload.ID, parts, 0)
+		require.NoError(t, err)
+		sum := sha256.Sum256([]byte("first-second-third"))
+		assert.Equal(t, hex.EncodeToString(sum[:]), res.Etag)
+		assert.Equal(t, int64(len("first-second-third")), res.FileSizeBytes)
+		assert.Equal(t, "application/octet-stream", res.ContentType)
+
+		_, content, err := service.Get(ctx, "big.bin")
+		require.NoError(t, err)
+		data, err := io.ReadAll(content)
+		_ = content.Close()
+		require.NoError(t, err)
+		assert.Equal(t, "first-second-third", string(data))
+
+		_, _, err = repo.GetMultipartUpload(ctx, upload.ID)
+		assert.ErrorIs(t, err, stowry.ErrNotFound, "the upload is removed")
+		for n := 1; n <= 3; n++ {
+			assert.False(t, partFileExists(root, upload.ID, n), "part %d is removed", n)
+		}
+	})
+
+	t.Run("complete uses only the listed parts", func(t *testing.T) {
+		service, _, root := newMultipartService(t)
+
+		upload, err := service.InitiateMultipart(ctx, stowry.CreateObject{Path: "a.txt", ContentType: "text/plain"})
+		require.NoError(t, err)
+		parts := uploadParts(t, service, "a.txt", upload.ID, "a", "b", "c")
+
+		res, err := service.CompleteMultipart(ctx, "a.txt", upload.ID, []stowry.CompletedPart{parts[0], parts[2]}, 0)
+		require.NoError(t, err)
+		assert.Equal(t, int64(2), res.FileSizeBytes)
+		assert.False(t, partFileExists(root, upload.ID, 2), "unlisted parts are removed too")
+	})
+
+	t.Run("invalid completions", func(t *testing.T) {
+		service, _, _ := newMultipartService(t)
+
+		upload, err := service.InitiateMultipart(ctx, stowry.CreateObject{Path: "a.txt", ContentType: "text/plain"})
+		require.NoError(t, err)
+		parts := uploadParts(t, service, "a.txt", upload.ID, "a", "b")
+
+		for name, listed := range map[string][]stowry.CompletedPart{
+			"no parts":      nil,
+			"out of order":  {parts[1], parts[0]},
+			"duplicate":     {parts[0], parts[0]},
+			"not uploaded":  {parts[0], {Number: 3}},
+			"etag mismatch": {{Number: 1, ETag: "abc"}},
+		} {
+			_, err := service.CompleteMultipart(ctx, "a.txt", upload.ID, listed, 0)
+			assert.ErrorIs(t, err, stowry.ErrInvalidPart, name)
+		}
+
+		// The upload is intact after failed completions
+		_, err = service.CompleteMultipart(ctx, "a.txt", upload.ID, parts, 0)
+		require.NoError(t, err)
+	})
+
+	t.Run("complete limits the total size", func(t *testing.T) {
+		service, _, root := newMultipartService(t)
+
+		upload, err := service.InitiateMultipart(ctx, stowry.CreateObject{Path: "a.txt", ContentType: "text/plain"})
+		require.NoError(t, err)
+		parts := uploadParts(t, service, "a.txt", upload.ID, "abc", "def")
+
+		_, err = service.CompleteMultipart(ctx, "a.txt", upload.ID, parts, 5)
+		require.ErrorIs(t, err, stowry.ErrObjectTooLarge)
+		_, err = service.Info(ctx, "a.txt")
+		assert.ErrorIs(t, err, stowry.ErrNotFound)
+		assert.True(t, partFileExists(root, upload.ID, 1), "the upload is kept")
+
+		res, err := service.CompleteMultipart(ctx, "a.txt", upload.ID, parts, 6)
+		require.NoError(t, err)
+		assert.Equal(t, int64(6), res.FileSizeBytes)
+	})
+
+	t.Run("unknown upload or another path", func(t *testing.T) {
+		service, _, root := newMultipartService(t)
+
+		upload, err := service.InitiateMultipart(ctx, stowry.CreateObject{Path: "a.txt", ContentType: "text/plain"})
+		require.NoError(t, err)
+
+		_, err = service.UploadPart(ctx, "a.txt", uuid.New(), 1, strings.NewReader("a"))
+		assert.ErrorIs(t, err, stowry.ErrNotFound)
+		_, err = service.UploadPart(ctx, "b.txt", upload.ID, 1, strings.NewReader("a"))
+		assert.ErrorIs(t, err, stowry.ErrNotFound)
+		assert.False(t, partFileExists(root, upload.ID, 1), "nothing is written for an unknown upload")
+
+		_, err = service.CompleteMultipart(ctx, "b.txt", upload.ID, []stowry.CompletedPart{{Number: 1}}, 0)
+		assert.ErrorIs(t, err, stowry.ErrNotFound)
+		assert.ErrorIs(t, service.AbortMultipart(ctx, "b.txt", upload.ID), stowry.ErrNotFound)
+	})
+
+	t.Run("part numbers are bounded", func(t *testing.T) {
+		service, _, _ := newMultipartService(t)
+
+		upload, err := service.InitiateMultipart(ctx, stowry.CreateObject{Path: "a.txt", ContentType: "text/plain"})
+		require.NoError(t, err)
+		for _, n := range []int{0, stowry.MaxMultipartParts + 1} {
+			_, err = service.UploadPart(ctx, "a.txt", upload.ID, n, strings.NewReader("a"))
+			assert.ErrorIs(t, err, stowry.ErrInvalidPart, "part %d", n)
+		}
+	})
+
+	t.Run("initiate validates the object", func(t *testing.T) {
+		service, _, _ := newMultipartService(t)
+
+		_, err := service.InitiateMultipart(ctx, stowry.CreateObject{Path: "../a.txt", ContentType: "text/plain"})
+		assert.ErrorIs(t, err, stowry.ErrInvalidInput)
+		_, err = service.InitiateMultipart(ctx, stowry.CreateObject{Path: stowry.StagePrefix + "a.txt", ContentType: "text/plain"})
+		assert.ErrorIs(t, err, stowry.ErrInvalidInput)
+	})
+
+	t.Run("abort removes the parts", func(t *testing.T) {
+		service, repo, root := newMultipartService(t)
+
+		upload, err := service.InitiateMultipart(ctx, stowry.CreateObject{Path: "a.txt", ContentType: "text/plain"})
+		require.NoError(t, err)
+		uploadParts(t, service, "a.txt", upload.ID, "a", "b")
+
+		require.NoError(t, service.AbortMultipart(ctx, "a.txt", upload.ID))
+		assert.False(t, partFileExists(root, upload.ID, 1))
+		assert.False(t, partFileExists(root, upload.ID, 2))
+		_, _, err = repo.GetMultipartUpload(ctx, upload.ID)
+		assert.ErrorIs(t, err, stowry.ErrNotFound)
+		assert.ErrorIs(t, service.AbortMultipart(ctx, "a.txt", upload.ID), stowry.ErrNotFound)
+	})
+}
+
+func TestStowryService_Multipart_Tombstone(t *testing.T) {
+	ctx := context.Background()
+	service, repo, root := newMultipartService(t)
+
+	stale, err := service.InitiateMultipart(ctx, stowry.CreateObject{Path: "logs/a.txt", ContentType: "text/plain"})
+	require.NoError(t, err)
+	uploadParts(t, service, "logs/a.txt", stale.ID, "abc", "de")
+	other, err := service.InitiateMultipart(ctx, stowry.CreateObject{Path: "data/b.txt", ContentType: "text/plain"})
+	require.NoError(t, err)
+	time.Sleep(10 * time.Millisecond)
+
+	report, err := service.TombstoneWithReport(ctx, stowry.TombstoneOptions{MultipartExpiry: time.Hour})
+	require.NoError(t, err)
+	assert.Zero(t, report.AbortedUploads, "recent uploads are kept")
+
+	report, err = service.TombstoneWithReport(ctx, stowry.TombstoneOptions{
+		Query: stowry.ListQuery{PathPrefix: "logs/"}, MultipartExpiry: time.Millisecond, DryRun: true,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 1, report.AbortedUploads)
+	assert.Equal(t, int64(5), report.BytesReclaimed)
+	assert.True(t, partFileExists(root, stale.ID, 1), "a dry run removes nothing")
+
+	report, err = service.TombstoneWithReport(ctx, stowry.TombstoneOptions{
+		Query: stowry.ListQuery{PathPrefix: "logs/"}, MultipartExpiry: time.Millisecond,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 1, report.AbortedUploads)
+	assert.Equal(t, int64(5), report.BytesReclaimed)
+	assert.False(t, partFileExists(root, stale.ID, 1))
+	_, _, err = repo.GetMultipartUpload(ctx, stale.ID)
+	assert.ErrorIs(t, err, stowry.ErrNotFound)
+
+	_, _, err = repo.GetMultipartUpload(ctx, other.ID)
+	assert.NoError(t, err, "uploads outside the prefix are kept")
+}
+
+func TestStowryService_Multipart_Unsupported(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("repository without multipart uploads", func(t *testing.T) {
+		service, err := stowry.NewStowryService(new(SpyMetaDataRepo), newMemStorage(), stowry.ServiceConfig{Mode: stowry.ModeStore})
+		require.NoError(t, err)
+		_, err = service.InitiateMultipart(ctx, stowry.CreateObject{Path: "a.txt", ContentType: "text/plain"})
+		assert.ErrorIs(t, err, stowry.ErrNotSupported)
+		_, err = service.UploadPart(ctx, "a.txt", uuid.New(), 1, bytes.NewReader(nil))
+		assert.ErrorIs(t, err, stowry.ErrNotSupported)
+	})
+
+	t.Run("static mode", func(t *testing.T) {
+		_, repo, root := newMultipartService(t)
+		service, err := stowry.NewStowryService(repo, filesystem.NewFileStorage(root), stowry.ServiceConfig{Mode: stowry.ModeStatic})
+		require.NoError(t, err)
+		_, err = service.InitiateMultipart(ctx, stowry.CreateObject{Path: "a.txt", ContentType: "text/plain"})
+		assert.ErrorIs(t, err, stowry.ErrNotSupported)
+	})
+}
@@ -0,0 +1,124 @@
+package stowry
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	// DefaultMaxSegmentBytes is the longest path segment accepted when
+	// PathLimits.MaxSegmentBytes is not set. It matches the 255-byte file name
+	// limit of common filesystems.
+	DefaultMaxSegmentBytes = 255
+
+	// DefaultMaxKeyBytes is the longest object path accepted when
+	// PathLimits.MaxKeyBytes is not set.
+	DefaultMaxKeyBytes = 1024
+
+	// DefaultMaxDepth is the most segments an object path may have when
+	// PathLimits.MaxDepth is not set.
+	DefaultMaxDepth = 64
+)
+
+// Names of the PathLimits, as reported by PathLimitError.Limit.
+const (
+	LimitMaxSegmentBytes = "max_segment_bytes"
+	LimitMaxKeyBytes     = "max_key_bytes"
+	LimitMaxDepth        = "max_depth"
+)
+
+// PathLimitError reports an object path that exceeds one of its PathLimits.
+// It wraps ErrPathLimitExceeded.
+type PathLimitError struct {
+	Limit   string // LimitMaxSegmentBytes, LimitMaxKeyBytes or LimitMaxDepth
+	Max     int
+	Actual  int
+	Segment int // 1-based index of the long segment for LimitMaxSegmentBytes
+}
+
+func (e *PathLimitError) Error() string {
+	switch e.Limit {
+	case LimitMaxSegmentBytes:
+		return fmt.Sprintf("%s: segment %d is %d bytes, %s is %d", ErrPathLimitExceeded, e.Segment, e.Actual, e.Limit, e.Max)
+	case LimitMaxDepth:
+		return fmt.Sprintf("%s: path has %d segments, %s is %d", ErrPathLimitExceeded, e.Actual, e.Limit, e.Max)
+	default:
+		return fmt.Sprintf("%s: path is %d bytes, %s is %d", ErrPathLimitExceeded, e.Actual, e.Limit, e.Max)
+	}
+}
+
+func (e *PathLimitError) Unwrap() error {
+	return ErrPathLimitExceeded
+}
+
+// PathLimits bound the shape of object paths so that they fit the storage
+// backend. Paths are checked before any content is read; a zero field uses
+// its default.
+type PathLimits struct {
+	MaxSegmentBytes int `mapstructure:"max_segment_bytes" json:"max_segment_bytes"` // Bytes per path segment (default: DefaultMaxSegmentBytes)
+	MaxKeyBytes     int `mapstructure:"max_key_bytes" json:"max_key_bytes"`         // Bytes in the whole path (default: DefaultMaxKeyBytes)
+	MaxDepth        int `mapstructure:"max_depth" json:"max_depth"`                 // Segments in the path, the file name included (default: DefaultMaxDepth)
+}
+
+// DefaultPathLimits returns the limits used when none are configured.
+func DefaultPathLimits() PathLimits {
+	return PathLimits{
+		MaxSegmentBytes: DefaultMaxSegmentBytes,
+		MaxKeyBytes:     DefaultMaxKeyBytes,
+		MaxDepth:        DefaultMaxDepth,
+	}
+}
+
+// WithDefaults returns l with every zero field set to its default.
+func (l PathLimits) WithDefaults() PathLimits {
+	if l.MaxSegmentBytes == 0 {
+		l.MaxSegmentBytes = DefaultMaxSegmentBytes
+	}
+	if l.MaxKeyBytes == 0 {
+		l.MaxKeyBytes = DefaultMaxKeyBytes
+	}
+	if l.MaxDepth == 0 {
+		l.MaxDepth = DefaultMaxDepth
+	}
+	return l
+}
+
+// Validate reports a negative limit.
+func (l PathLimits) Validate() error {
+	switch {
+	case l.MaxSegmentBytes < 0:
+		return fmt.Errorf("path limits: %s must not be negative, got %d", LimitMaxSegmentBytes, l.MaxSegmentBytes)
+	case l.MaxKeyBytes < 0:
+		return fmt.Errorf("path limits: %s must not be negative, got %d", LimitMaxKeyBytes, l.MaxKeyBytes)
+	case l.MaxDepth < 0:
+		return fmt.Errorf("path limits: %s must not be negative, got %d", LimitMaxDepth, l.MaxDepth)
+	}
+	return nil
+}
+
+// Check reports the first limit path exceeds, with zero fields of l taken as
+// their defaults.
+//
+// Returns:
+//   - error: a *PathLimitError naming the violated limit, which matches
+//     ErrPathLimitExceeded and ErrInvalidInput, or nil
+func (l PathLimits) Check(path string) error {
+	l = l.WithDefaults()
+
+	if len(path) > l.MaxKeyBytes {
+		return &PathLimitError{Limit: LimitMaxKeyBytes, Max: l.MaxKeyBytes, Actual: len(path)}
+	}
+
+	segments := strings.Split(path, "/")
+	if len(segments) > l.MaxDepth {
+		return &PathLimitError{Limit: LimitMaxDepth, Max: l.MaxDepth, Actual: len(segments)}
+	}
+
+	for i, segment := range segments {
+		if len(segment) > l.MaxSegmentBytes {
+			return &PathLimitError{Limit: LimitMaxSegmentBytes, Max: l.MaxSegmentBytes, Actual: len(segment), Segment: i + 1}
+		}
+	}
+
+	return nil
+}
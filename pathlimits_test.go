@@ -0,0 +1,143 @@
+package stowry_test
+
+import (
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/sagarc03/stowry"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// countingReader counts the bytes read from r.
+type countingReader struct {
+	r io.Reader
+	n int
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += n
+	return n, err
+}
+
+// depthPath returns a path of n one-byte segments.
+func depthPath(n int) string {
+	return strings.TrimSuffix(strings.Repeat("a/", n), "/")
+}
+
+func TestPathLimits_Check(t *testing.T) {
+	segment := func(n int) string { return strings.Repeat("s", n) }
+	// keyPath returns a path of exactly n bytes made of 200-byte segments
+	keyPath := func(n int) string {
+		var b strings.Builder
+		for b.Len() < n {
+			if b.Len() > 0 {
+				b.WriteByte('/')
+			}
+			b.WriteString(segment(min(200, n-b.Len())))
+		}
+		return b.String()
+	}
+
+	tests := []struct {
+		name      string
+		limits    stowry.PathLimits
+		path      string
+		wantLimit string
+		wantMax   int
+	}{
+		{name: "segment at default limit", path: "dir/" + segment(255)},
+		{name: "segment over default limit", path: "dir/" + segment(256), wantLimit: stowry.LimitMaxSegmentBytes, wantMax: 255},
+		{name: "key at default limit", path: keyPath(1024)},
+		{name: "key over default limit", path: keyPath(1025), wantLimit: stowry.LimitMaxKeyBytes, wantMax: 1024},
+		{name: "depth at default limit", path: depthPath(64)},
+		{name: "depth over default limit", path: depthPath(65), wantLimit: stowry.LimitMaxDepth, wantMax: 64},
+		{name: "custom segment limit", limits: stowry.PathLimits{MaxSegmentBytes: 8}, path: "a/" + segment(9), wantLimit: stowry.LimitMaxSegmentBytes, wantMax: 8},
+		{name: "custom key limit", limits: stowry.PathLimits{MaxKeyBytes: 10}, path: "abcde/fghij", wantLimit: stowry.LimitMaxKeyBytes, wantMax: 10},
+		{name: "custom depth limit", limits: stowry.PathLimits{MaxDepth: 2}, path: "a/b/c", wantLimit: stowry.LimitMaxDepth, wantMax: 2},
+		{name: "raised segment limit", limits: stowry.PathLimits{MaxSegmentBytes: 300}, path: segment(300)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.limits.Check(tt.path)
+			if tt.wantLimit == "" {
+				assert.NoError(t, err)
+				return
+			}
+
+			var limitErr *stowry.PathLimitError
+			require.ErrorAs(t, err, &limitErr)
+			assert.Equal(t, tt.wantLimit, limitErr.Limit)
+			assert.Equal(t, tt.wantMax, limitErr.Max)
+			assert.ErrorIs(t, err, stowry.ErrPathLimitExceeded)
+			assert.ErrorIs(t, err, stowry.ErrInvalidInput)
+			assert.Contains(t, err.Error(), tt.wantLimit)
+		})
+	}
+
+	t.Run("names the long segment", func(t *testing.T) {
+		err := stowry.PathLimits{}.Check("a/b/" + segment(256) + "/c")
+
+		var limitErr *stowry.PathLimitError
+		require.ErrorAs(t, err, &limitErr)
+		assert.Equal(t, 3, limitErr.Segment)
+		assert.Equal(t, 256, limitErr.Actual)
+	})
+}
+
+func TestPathLimits_Validate(t *testing.T) {
+	assert.NoError(t, stowry.PathLimits{}.Validate())
+	assert.NoError(t, stowry.DefaultPathLimits().Validate())
+
+	tests := []struct {
+		limits  stowry.PathLimits
+		wantErr string
+	}{
+		{limits: stowry.PathLimits{MaxSegmentBytes: -1}, wantErr: stowry.LimitMaxSegmentBytes},
+		{limits: stowry.PathLimits{MaxKeyBytes: -1}, wantErr: stowry.LimitMaxKeyBytes},
+		{limits: stowry.PathLimits{MaxDepth: -1}, wantErr: stowry.LimitMaxDepth},
+	}
+	for _, tt := range tests {
+		t.Run(tt.wantErr, func(t *testing.T) {
+			assert.ErrorContains(t, tt.limits.Validate(), tt.wantErr)
+		})
+	}
+}
+
+func TestStowryService_Create_PathLimits(t *testing.T) {
+	repo := new(SpyMetaDataRepo)
+	storage := new(SpyFileStorage)
+	service, err := stowry.NewStowryService(repo, storage, stowry.ServiceConfig{
+		Mode:       stowry.ModeStore,
+		PathLimits: stowry.PathLimits{MaxDepth: 3},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, stowry.PathLimits{MaxSegmentBytes: 255, MaxKeyBytes: 1024, MaxDepth: 3}, service.PathLimits())
+
+	content := &countingReader{r: strings.NewReader("data")}
+	_, err = service.Create(t.Context(), stowry.CreateObject{Path: "a/b/c/d.txt", ContentType: "text/plain"}, content)
+
+	var limitErr *stowry.PathLimitError
+	require.ErrorAs(t, err, &limitErr)
+	assert.Equal(t, stowry.LimitMaxDepth, limitErr.Limit)
+	assert.Zero(t, content.n, "content must not be read")
+	storage.AssertNotCalled(t, "Write")
+	repo.AssertNotCalled(t, "Upsert")
+
+	_, err = service.CreateMany(t.Context(), []stowry.CreateObject{{Path: depthPath(4), ContentType: "text/plain"}},
+		func(stowry.CreateObject) (io.ReadCloser, error) {
+			return io.NopCloser(strings.NewReader("data")), nil
+		})
+	assert.ErrorIs(t, err, stowry.ErrPathLimitExceeded)
+	storage.AssertNotCalled(t, "Write")
+
+	t.Run("negative limits are rejected", func(t *testing.T) {
+		_, err := stowry.NewStowryService(repo, storage, stowry.ServiceConfig{
+			Mode:       stowry.ModeStore,
+			PathLimits: stowry.PathLimits{MaxKeyBytes: -1},
+		})
+		assert.ErrorContains(t, err, stowry.LimitMaxKeyBytes)
+	})
+}
@@ -0,0 +1,68 @@
+package stowry
+
+import (
+	"context"
+	"fmt"
+	"io"
+)
+
+// ETagPrefix is the reserved path prefix of permalinks: the object path
+// ETagPrefix + etag names the active object whose content has that ETag,
+// wherever it is stored. No object can be created under it.
+const ETagPrefix = "_by-etag/"
+
+// Permalink returns the URL path of the permalink of content with etag,
+// such as /_by-etag/2c26b46b...
+func Permalink(etag string) string {
+	return "/" + ETagPrefix + etag
+}
+
+// IsValidETag reports whether etag is an ETag as storage computes it: a
+// SHA-256 digest in 64 lowercase hex characters.
+func IsValidETag(etag string) bool {
+	if len(etag) != 64 {
+		return false
+	}
+	for i := range len(etag) {
+		c := etag[i]
+		if (c < '0' || c > '9') && (c < 'a' || c > 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+// GetByETag opens the active object whose content has etag, for serving it
+// at its permalink. When several objects share the content, the most
+// recently updated one is served, and of those the first by path. The read
+// is counted as a download of that object.
+//
+// Parameters:
+//   - ctx: Context for cancellation and timeout
+//   - etag: The ETag to look up, without quotes
+//
+// Returns:
+//   - MetaData: The metadata of the object served
+//   - io.ReadSeekCloser: Its content; the caller must close it
+//   - error: ErrInvalidETag for an etag that is not a SHA-256 digest,
+//     ErrNotFound when no active object has the ETag, or other errors
+func (s *StowryService) GetByETag(ctx context.Context, etag string) (MetaData, io.ReadSeekCloser, error) {
+	if err := ctx.Err(); err != nil {
+		return MetaData{}, nil, fmt.Errorf("get object by etag: %w", err)
+	}
+	if !IsValidETag(etag) {
+		return MetaData{}, nil, fmt.Errorf("get object by etag %q: %w", etag, ErrInvalidETag)
+	}
+
+	m, err := s.repo.GetByETag(ctx, etag)
+	if err != nil {
+		return MetaData{}, nil, fmt.Errorf("get object by etag: %w", err)
+	}
+
+	f, err := s.storageGet(ctx, m.Path)
+	if err != nil {
+		return MetaData{}, nil, fmt.Errorf("get object by etag: %w", err)
+	}
+
+	return m, s.verifyRead(ctx, m, f), nil
+}
@@ -0,0 +1,71 @@
+package stowry_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/sagarc03/stowry"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIsValidETag(t *testing.T) {
+	assert.True(t, stowry.IsValidETag("e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"))
+	for _, etag := range []string{
+		"",
+		"abc123",
+		"E3B0C44298FC1C149AFBF4C8996FB92427AE41E4649B934CA495991B7852B855",
+		strings.Repeat("g", 64),
+		strings.Repeat("a", 65),
+	} {
+		assert.False(t, stowry.IsValidETag(etag), etag)
+	}
+	assert.Equal(t, "/_by-etag/abc", stowry.Permalink("abc"))
+}
+
+func TestStowryService_GetByETag(t *testing.T) {
+	etag := strings.Repeat("a", 64)
+
+	t.Run("opens the object found", func(t *testing.T) {
+		service, repo, storage := NewStowryServiceWithMode(t, stowry.ModeStore)
+		ctx := context.Background()
+		obj := stowry.MetaData{Path: "docs/a.txt", Etag: etag, FileSizeBytes: 5}
+		file := &mockReadSeekCloser{content: []byte("hello")}
+		repo.On("GetByETag", ctx, etag).Return(obj, nil)
+		storage.On("Get", ctx, "docs/a.txt").Return(file, nil)
+
+		got, content, err := service.GetByETag(ctx, etag)
+		require.NoError(t, err)
+		assert.Equal(t, obj, got)
+		assert.Same(t, file, content)
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		service, repo, storage := NewStowryServiceWithMode(t, stowry.ModeStore)
+		repo.On("GetByETag", mock.Anything, etag).Return(stowry.MetaData{}, stowry.ErrNotFound)
+
+		_, _, err := service.GetByETag(context.Background(), etag)
+		assert.ErrorIs(t, err, stowry.ErrNotFound)
+		storage.AssertNotCalled(t, "Get", mock.Anything, mock.Anything)
+	})
+
+	t.Run("invalid etag", func(t *testing.T) {
+		service, repo, _ := NewStowryServiceWithMode(t, stowry.ModeStore)
+
+		_, _, err := service.GetByETag(context.Background(), "abc123")
+		assert.ErrorIs(t, err, stowry.ErrInvalidETag)
+		assert.ErrorIs(t, err, stowry.ErrInvalidInput)
+		repo.AssertNotCalled(t, "GetByETag", mock.Anything, mock.Anything)
+	})
+
+	t.Run("the prefix is reserved", func(t *testing.T) {
+		service, repo, storage := NewStowryServiceWithMode(t, stowry.ModeStore)
+
+		_, err := service.Create(context.Background(), stowry.CreateObject{Path: stowry.ETagPrefix + etag, ContentType: "text/plain"}, strings.NewReader("x"))
+		assert.ErrorIs(t, err, stowry.ErrInvalidInput)
+		repo.AssertNotCalled(t, "Upsert", mock.Anything, mock.Anything)
+		storage.AssertNotCalled(t, "Write", mock.Anything, mock.Anything, mock.Anything)
+	})
+}
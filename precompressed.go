@@ -0,0 +1,154 @@
+package stowry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// precompressedExtensions maps the content-codings GetNegotiated serves
+// pre-compressed variants for to the suffix of the variant's path.
+var precompressedExtensions = map[string]string{
+	"br":   ".br",
+	"gzip": ".gz",
+}
+
+// PrecompressedEncodings returns the content-codings GetNegotiated looks for
+// pre-compressed variants of, in the order it prefers them.
+func PrecompressedEncodings() []string {
+	return []string{"br", "gzip"}
+}
+
+// GetNegotiated is Get for a client accepting encodings, in the order it
+// prefers them. When an object stored next to the resolved one under its
+// path plus ".br" or ".gz" matches an accepted coding, that variant's content
+// is returned: the metadata is the resolved object's, with the variant's ETag,
+// size and update time, ContentEncoding set to the coding and
+// DecodedSizeBytes to the resolved object's size. The first accepted coding
+// with a variant wins; without one, the resolved object is returned as by Get.
+//
+// Static and SPA fallbacks apply first, so a fallback to index.html serves
+// index.html.br. An object stored with its own ContentEncoding is returned as
+// is. Only the metadata of the variants is consulted, so a variant must be
+// uploaded again whenever the object it compresses changes.
+//
+// Parameters:
+//   - ctx: Context for cancellation and timeout
+//   - path: The requested path, resolved as by Get
+//   - encodings: Accepted content-codings in preference order; codings
+//     without a known variant suffix are ignored
+//
+// Returns:
+//   - MetaData: The metadata of the representation served
+//   - io.ReadSeekCloser: Its content; the caller must close it
+//   - error: As for Get
+func (s *StowryService) GetNegotiated(ctx context.Context, path string, encodings []string) (MetaData, io.ReadSeekCloser, error) {
+	if err := ctx.Err(); err != nil {
+		return MetaData{}, nil, fmt.Errorf("get object: %w", err)
+	}
+
+	m, err := s.resolveMetadata(ctx, path)
+	if err != nil {
+		return MetaData{}, nil, fmt.Errorf("get object: %w", err)
+	}
+
+	variants, err := s.precompressedVariants(ctx, m, encodings)
+	if err != nil {
+		return MetaData{}, nil, fmt.Errorf("get object: %w", err)
+	}
+	for _, v := range variants {
+		f, err := s.storageGet(ctx, v.stored.Path)
+		if errors.Is(err, ErrNotFound) {
+			// The file is gone; the next variant or the object itself serves
+			continue
+		}
+		if err != nil {
+			return MetaData{}, nil, fmt.Errorf("get object: %w", err)
+		}
+		return v.served, s.verifyRead(ctx, v.stored, f), nil
+	}
+
+	f, err := s.storageGet(ctx, m.Path)
+	if err != nil {
+		return MetaData{}, nil, fmt.Errorf("get object: %w", err)
+	}
+	return m, s.verifyRead(ctx, m, f), nil
+}
+
+// InfoNegotiated is Info for a client accepting encodings, describing the
+// representation GetNegotiated would return.
+func (s *StowryService) InfoNegotiated(ctx context.Context, path string, encodings []string) (MetaData, error) {
+	if err := ctx.Err(); err != nil {
+		return MetaData{}, fmt.Errorf("info object: %w", err)
+	}
+
+	m, err := s.resolveMetadata(ctx, path)
+	if err != nil {
+		return MetaData{}, fmt.Errorf("info object: %w", err)
+	}
+
+	variants, err := s.precompressedVariants(ctx, m, encodings)
+	if err != nil {
+		return MetaData{}, fmt.Errorf("info object: %w", err)
+	}
+	if len(variants) > 0 {
+		return variants[0].served, nil
+	}
+	return m, nil
+}
+
+// precompressedVariant is a pre-compressed variant of an object: stored is
+// the variant's own metadata and served what GetNegotiated returns for it.
+type precompressedVariant struct {
+	stored MetaData
+	served MetaData
+}
+
+// precompressedVariants returns the variants of m for the accepted
+// encodings, in their order, looked up in one query.
+func (s *StowryService) precompressedVariants(ctx context.Context, m MetaData, encodings []string) ([]precompressedVariant, error) {
+	if m.ContentEncoding != "" {
+		return nil, nil
+	}
+
+	var paths []string
+	for _, encoding := range encodings {
+		if ext, ok := precompressedExtensions[encoding]; ok {
+			paths = append(paths, m.Path+ext)
+		}
+	}
+	if len(paths) == 0 {
+		return nil, nil
+	}
+
+	found, err := s.repo.GetMany(ctx, paths)
+	if err != nil {
+		return nil, fmt.Errorf("precompressed variants of %s: %w", m.Path, err)
+	}
+	byPath := make(map[string]MetaData, len(found))
+	for _, v := range found {
+		byPath[v.Path] = v
+	}
+
+	var variants []precompressedVariant
+	for _, encoding := range encodings {
+		ext, ok := precompressedExtensions[encoding]
+		if !ok {
+			continue
+		}
+		v, ok := byPath[m.Path+ext]
+		if !ok {
+			continue
+		}
+		decodedSize := m.FileSizeBytes
+		served := m
+		served.Etag = v.Etag
+		served.FileSizeBytes = v.FileSizeBytes
+		served.UpdatedAt = v.UpdatedAt
+		served.ContentEncoding = encoding
+		served.DecodedSizeBytes = &decodedSize
+		variants = append(variants, precompressedVariant{stored: v, served: served})
+	}
+	return variants, nil
+}
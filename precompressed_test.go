@@ -0,0 +1,142 @@
+package stowry_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/sagarc03/stowry"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStowryService_GetNegotiated(t *testing.T) {
+	updated := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	original := stowry.MetaData{Path: "app.js", ContentType: "text/javascript", Etag: "orig", FileSizeBytes: 1000}
+	br := stowry.MetaData{Path: "app.js.br", ContentType: "application/octet-stream", Etag: "br-etag", FileSizeBytes: 200, UpdatedAt: updated}
+	gz := stowry.MetaData{Path: "app.js.gz", ContentType: "application/gzip", Etag: "gz-etag", FileSizeBytes: 300, UpdatedAt: updated}
+
+	t.Run("serves the first accepted variant", func(t *testing.T) {
+		service, repo, storage := NewStowryServiceWithMode(t, stowry.ModeStatic)
+		ctx := context.Background()
+		file := &mockReadSeekCloser{content: []byte("gz")}
+
+		repo.On("Get", ctx, "app.js").Return(original, nil)
+		repo.On("GetMany", ctx, []string{"app.js.gz", "app.js.br"}).Return([]stowry.MetaData{br, gz}, nil)
+		storage.On("Get", ctx, "app.js.gz").Return(file, nil)
+
+		m, content, err := service.GetNegotiated(ctx, "app.js", []string{"gzip", "br"})
+		require.NoError(t, err)
+		assert.Same(t, file, content)
+		assert.Equal(t, "app.js", m.Path)
+		assert.Equal(t, "text/javascript", m.ContentType)
+		assert.Equal(t, "gzip", m.ContentEncoding)
+		assert.Equal(t, "gz-etag", m.Etag)
+		assert.Equal(t, int64(300), m.FileSizeBytes)
+		assert.Equal(t, updated, m.UpdatedAt)
+		require.NotNil(t, m.DecodedSizeBytes)
+		assert.Equal(t, int64(1000), *m.DecodedSizeBytes)
+
+		repo.AssertExpectations(t)
+		storage.AssertExpectations(t)
+	})
+
+	t.Run("skips a variant missing from storage", func(t *testing.T) {
+		service, repo, storage := NewStowryServiceWithMode(t, stowry.ModeStatic)
+		ctx := context.Background()
+		file := &mockReadSeekCloser{content: []byte("gz")}
+
+		repo.On("Get", ctx, "app.js").Return(original, nil)
+		repo.On("GetMany", ctx, []string{"app.js.br", "app.js.gz"}).Return([]stowry.MetaData{br, gz}, nil)
+		storage.On("Get", ctx, "app.js.br").Return((*mockReadSeekCloser)(nil), stowry.ErrNotFound)
+		storage.On("Get", ctx, "app.js.gz").Return(file, nil)
+
+		m, _, err := service.GetNegotiated(ctx, "app.js", []string{"br", "gzip"})
+		require.NoError(t, err)
+		assert.Equal(t, "gzip", m.ContentEncoding)
+	})
+
+	t.Run("serves the object without a variant", func(t *testing.T) {
+		service, repo, storage := NewStowryServiceWithMode(t, stowry.ModeStatic)
+		ctx := context.Background()
+		file := &mockReadSeekCloser{content: []byte("js")}
+
+		repo.On("Get", ctx, "app.js").Return(original, nil)
+		repo.On("GetMany", ctx, []string{"app.js.br"}).Return([]stowry.MetaData{}, nil)
+		storage.On("Get", ctx, "app.js").Return(file, nil)
+
+		m, content, err := service.GetNegotiated(ctx, "app.js", []string{"br"})
+		require.NoError(t, err)
+		assert.Same(t, file, content)
+		assert.Equal(t, original, m)
+	})
+
+	t.Run("no accepted encodings skips the lookup", func(t *testing.T) {
+		service, repo, storage := NewStowryServiceWithMode(t, stowry.ModeStatic)
+		ctx := context.Background()
+		file := &mockReadSeekCloser{content: []byte("js")}
+
+		repo.On("Get", ctx, "app.js").Return(original, nil)
+		storage.On("Get", ctx, "app.js").Return(file, nil)
+
+		m, _, err := service.GetNegotiated(ctx, "app.js", []string{"deflate"})
+		require.NoError(t, err)
+		assert.Equal(t, original, m)
+		repo.AssertNotCalled(t, "GetMany")
+	})
+
+	t.Run("already encoded object is served as is", func(t *testing.T) {
+		service, repo, storage := NewStowryServiceWithMode(t, stowry.ModeStatic)
+		ctx := context.Background()
+		encoded := original
+		encoded.ContentEncoding = "gzip"
+		file := &mockReadSeekCloser{content: []byte("js")}
+
+		repo.On("Get", ctx, "app.js").Return(encoded, nil)
+		storage.On("Get", ctx, "app.js").Return(file, nil)
+
+		m, _, err := service.GetNegotiated(ctx, "app.js", []string{"br"})
+		require.NoError(t, err)
+		assert.Equal(t, encoded, m)
+		repo.AssertNotCalled(t, "GetMany")
+	})
+
+	t.Run("fallback to index.html uses its variant", func(t *testing.T) {
+		service, repo, storage := NewStowryServiceWithMode(t, stowry.ModeStatic)
+		ctx := context.Background()
+		index := stowry.MetaData{Path: "docs/index.html", ContentType: "text/html", Etag: "idx", FileSizeBytes: 100}
+		indexBr := stowry.MetaData{Path: "docs/index.html.br", Etag: "idx-br", FileSizeBytes: 40}
+		file := &mockReadSeekCloser{content: []byte("br")}
+
+		repo.On("Get", ctx, "docs").Return(stowry.MetaData{}, stowry.ErrNotFound)
+		repo.On("GetMany", ctx, []string{"docs.html", "docs/index.html"}).Return([]stowry.MetaData{index}, nil)
+		repo.On("GetMany", ctx, []string{"docs/index.html.br"}).Return([]stowry.MetaData{indexBr}, nil)
+		storage.On("Get", ctx, "docs/index.html.br").Return(file, nil)
+
+		m, _, err := service.GetNegotiated(ctx, "docs", []string{"br"})
+		require.NoError(t, err)
+		assert.Equal(t, "docs/index.html", m.Path)
+		assert.Equal(t, "text/html", m.ContentType)
+		assert.Equal(t, "br", m.ContentEncoding)
+		assert.Equal(t, "idx-br", m.Etag)
+	})
+}
+
+func TestStowryService_InfoNegotiated(t *testing.T) {
+	original := stowry.MetaData{Path: "app.js", ContentType: "text/javascript", Etag: "orig", FileSizeBytes: 1000}
+
+	service, repo, _ := NewStowryServiceWithMode(t, stowry.ModeStatic)
+	ctx := context.Background()
+
+	repo.On("Get", ctx, "app.js").Return(original, nil)
+	repo.On("GetMany", ctx, []string{"app.js.br", "app.js.gz"}).Return([]stowry.MetaData{
+		{Path: "app.js.gz", Etag: "gz-etag", FileSizeBytes: 300},
+	}, nil)
+
+	m, err := service.InfoNegotiated(ctx, "app.js", []string{"br", "gzip"})
+	require.NoError(t, err)
+	assert.Equal(t, "gzip", m.ContentEncoding)
+	assert.Equal(t, "gz-etag", m.Etag)
+	assert.Equal(t, int64(300), m.FileSizeBytes)
+	assert.Equal(t, "text/javascript", m.ContentType)
+}
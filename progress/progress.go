@@ -0,0 +1,147 @@
+// Package progress reports how far long-running operations have got.
+//
+// An operation creates a Tracker with the caller's Func and records each item
+// it finishes. The Tracker throttles events to a few per second and always
+// sends a final one, so callers only decide how to render them: Terminal for
+// a live status line, Logger for periodic log lines, JSON for ndjson.
+package progress
+
+import (
+	"sync"
+	"time"
+)
+
+// DefaultInterval is the minimum time between two events from a Tracker.
+const DefaultInterval = 250 * time.Millisecond
+
+// Event is a snapshot of an operation's progress.
+type Event struct {
+	Operation string `json:"operation"`
+	Processed int64  `json:"processed"`
+	// Total is the number of items the operation expects to process, or 0
+	// while it is not known.
+	Total   int64         `json:"total,omitempty"`
+	Bytes   int64         `json:"bytes"`
+	Path    string        `json:"path,omitempty"` // last item processed
+	Errors  int           `json:"errors"`
+	Elapsed time.Duration `json:"elapsed_ns"`
+	Done    bool          `json:"done,omitempty"` // final event of the operation
+}
+
+// Rate returns the items processed per second so far.
+func (e Event) Rate() float64 {
+	if e.Elapsed <= 0 {
+		return 0
+	}
+	return float64(e.Processed) / e.Elapsed.Seconds()
+}
+
+// Remaining estimates the time left from the average rate so far. It returns
+// 0 when Total is unknown or nothing has been processed yet.
+func (e Event) Remaining() time.Duration {
+	if e.Total <= 0 || e.Processed <= 0 || e.Processed >= e.Total {
+		return 0
+	}
+	perItem := e.Elapsed / time.Duration(e.Processed)
+	return perItem * time.Duration(e.Total-e.Processed)
+}
+
+// Func receives progress events. It is called synchronously from the
+// operation, so it should return quickly.
+type Func func(Event)
+
+// Tracker counts an operation's progress and passes throttled events to a
+// Func. It is safe for concurrent use. A nil *Tracker, as returned by New for
+// a nil Func, ignores every call, so operations need not check whether
+// progress was requested.
+type Tracker struct {
+	fn       Func
+	interval time.Duration
+	now      func() time.Time
+
+	mu       sync.Mutex
+	start    time.Time
+	lastSent time.Time
+	event    Event
+	finished bool
+}
+
+// New returns a Tracker for operation that sends events to fn, or nil if fn
+// is nil.
+func New(operation string, fn Func) *Tracker {
+	if fn == nil {
+		return nil
+	}
+	t := &Tracker{fn: fn, interval: DefaultInterval, now: time.Now}
+	t.start = t.now()
+	t.event.Operation = operation
+	return t
+}
+
+// SetTotal records how many items the operation expects to process.
+func (t *Tracker) SetTotal(total int64) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.event.Total = total
+}
+
+// Add records a processed item of size bytes.
+func (t *Tracker) Add(path string, bytes int64) {
+	t.record(path, bytes, false)
+}
+
+// Fail records an item that could not be processed.
+func (t *Tracker) Fail(path string) {
+	t.record(path, 0, true)
+}
+
+func (t *Tracker) record(path string, bytes int64, failed bool) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.finished {
+		return
+	}
+
+	t.event.Processed++
+	t.event.Bytes += bytes
+	t.event.Path = path
+	if failed {
+		t.event.Errors++
+	}
+
+	now := t.now()
+	if now.Sub(t.lastSent) < t.interval {
+		return
+	}
+	t.lastSent = now
+	t.send(now)
+}
+
+// Finish sends the final event. Later calls to the Tracker are ignored.
+func (t *Tracker) Finish() {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.finished {
+		return
+	}
+	t.finished = true
+	t.event.Done = true
+	t.send(t.now())
+}
+
+// send passes the current event to fn. The lock is held so events arrive in
+// order.
+func (t *Tracker) send(now time.Time) {
+	e := t.event
+	e.Elapsed = now.Sub(t.start)
+	t.fn(e)
+}
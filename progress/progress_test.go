@@ -0,0 +1,185 @@
+package progress
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeClock is a manually advanced clock for Tracker.now.
+type fakeClock struct{ t time.Time }
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{t: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+}
+
+func (c *fakeClock) now() time.Time          { return c.t }
+func (c *fakeClock) advance(d time.Duration) { c.t = c.t.Add(d) }
+
+// tracker returns a Tracker that reads the fake clock.
+func (c *fakeClock) tracker(fn Func) *Tracker {
+	t := New("op", fn)
+	t.now, t.start = c.now, c.t
+	return t
+}
+
+func collect(events *[]Event) Func {
+	return func(e Event) { *events = append(*events, e) }
+}
+
+func processed(events []Event) []int64 {
+	n := make([]int64, len(events))
+	for i, e := range events {
+		n[i] = e.Processed
+	}
+	return n
+}
+
+func TestTracker_Throttles(t *testing.T) {
+	clock := newFakeClock()
+	var events []Event
+	tr := clock.tracker(collect(&events))
+
+	tr.Add("a", 1) // first item is always reported
+	clock.advance(100 * time.Millisecond)
+	tr.Add("b", 1)
+	clock.advance(100 * time.Millisecond)
+	tr.Add("c", 1)
+	clock.advance(100 * time.Millisecond)
+	tr.Add("d", 1) // 300ms since the last event
+	tr.Fail("e")
+	tr.Finish()
+
+	assert.Equal(t, []int64{1, 4, 5}, processed(events))
+
+	last := events[len(events)-1]
+	assert.True(t, last.Done)
+	assert.Equal(t, "op", last.Operation)
+	assert.Equal(t, int64(4), last.Bytes)
+	assert.Equal(t, 1, last.Errors)
+	assert.Equal(t, "e", last.Path)
+	assert.Equal(t, 300*time.Millisecond, last.Elapsed)
+}
+
+func TestTracker_FinishOnce(t *testing.T) {
+	var events []Event
+	tr := New("op", collect(&events))
+
+	tr.Finish()
+	tr.Finish()
+	tr.Add("late", 1)
+
+	require.Len(t, events, 1)
+	assert.Zero(t, events[0].Processed)
+}
+
+func TestTracker_Nil(t *testing.T) {
+	tr := New("op", nil)
+	assert.Nil(t, tr)
+
+	// A nil Tracker ignores every call
+	tr.SetTotal(3)
+	tr.Add("a", 1)
+	tr.Fail("b")
+	tr.Finish()
+}
+
+func TestTracker_Concurrent(t *testing.T) {
+	var mu sync.Mutex
+	var last Event
+	tr := New("op", func(e Event) {
+		mu.Lock()
+		defer mu.Unlock()
+		last = e
+	})
+
+	var wg sync.WaitGroup
+	for range 8 {
+		wg.Go(func() {
+			for range 100 {
+				tr.Add("x", 2)
+			}
+		})
+	}
+	wg.Wait()
+	tr.Finish()
+
+	assert.Equal(t, int64(800), last.Processed)
+	assert.Equal(t, int64(1600), last.Bytes)
+}
+
+func TestEvent_Remaining(t *testing.T) {
+	tests := []struct {
+		name  string
+		event Event
+		want  time.Duration
+	}{
+		{name: "unknown total", event: Event{Processed: 10, Elapsed: time.Second}},
+		{name: "nothing processed", event: Event{Total: 10, Elapsed: time.Second}},
+		{name: "complete", event: Event{Processed: 10, Total: 10, Elapsed: time.Second}},
+		{name: "quarter done", event: Event{Processed: 25, Total: 100, Elapsed: 10 * time.Second}, want: 30 * time.Second},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, tt.event.Remaining())
+		})
+	}
+}
+
+func TestFormat(t *testing.T) {
+	e := Event{Operation: "populate", Processed: 1200, Total: 5000, Bytes: 3 << 20, Errors: 2, Path: "docs/a.txt", Elapsed: 19 * time.Second}
+	assert.Equal(t, "populate: 1200/5000 (24%) 3.0 MiB, 2 errors, 1m0s left - docs/a.txt", Format(e))
+
+	e.Done = true
+	assert.Equal(t, "populate: 1200/5000 (24%) 3.0 MiB, 2 errors in 19s", Format(e))
+
+	assert.Equal(t, "cleanup: 3", Format(Event{Operation: "cleanup", Processed: 3}))
+}
+
+func TestTerminal(t *testing.T) {
+	var buf bytes.Buffer
+	fn := Terminal(&buf)
+
+	fn(Event{Operation: "gc", Processed: 1})
+	fn(Event{Operation: "gc", Processed: 2, Done: true})
+
+	assert.Equal(t, "\r\x1b[Kgc: 1\r\x1b[Kgc: 2 in 0s\n", buf.String())
+}
+
+func TestLogger(t *testing.T) {
+	var buf bytes.Buffer
+	fn := Logger(slog.New(slog.NewTextHandler(&buf, nil)), time.Second)
+
+	fn(Event{Operation: "gc", Processed: 1})
+	fn(Event{Operation: "gc", Processed: 2, Elapsed: 500 * time.Millisecond})
+	fn(Event{Operation: "gc", Processed: 3, Elapsed: 1500 * time.Millisecond})
+	fn(Event{Operation: "gc", Processed: 4, Elapsed: 1600 * time.Millisecond, Done: true})
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	require.Len(t, lines, 3)
+	assert.Contains(t, lines[0], "processed=1")
+	assert.Contains(t, lines[1], "processed=3")
+	assert.Contains(t, lines[2], `msg="progress done"`)
+}
+
+func TestJSON(t *testing.T) {
+	var buf bytes.Buffer
+	fn := JSON(&buf)
+
+	fn(Event{Operation: "export", Processed: 1, Bytes: 10})
+	fn(Event{Operation: "export", Processed: 2, Bytes: 20, Done: true})
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	require.Len(t, lines, 2)
+	var e Event
+	require.NoError(t, json.Unmarshal([]byte(lines[1]), &e))
+	assert.Equal(t, Event{Operation: "export", Processed: 2, Bytes: 20, Done: true}, e)
+}
@@ -0,0 +1,116 @@
+package progress
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"time"
+)
+
+// DefaultLogInterval is the minimum time between two log lines from Logger.
+const DefaultLogInterval = 5 * time.Second
+
+// Terminal renders events as a single status line on w, redrawn in place,
+// and ends the line after the final event. w should be a terminal; see
+// IsTerminal.
+func Terminal(w io.Writer) Func {
+	return func(e Event) {
+		end := ""
+		if e.Done {
+			end = "\n"
+		}
+		_, _ = fmt.Fprintf(w, "\r\x1b[K%s%s", Format(e), end)
+	}
+}
+
+// Logger logs events at info level, at most once per interval plus the final
+// event. interval <= 0 uses DefaultLogInterval.
+func Logger(logger *slog.Logger, interval time.Duration) Func {
+	if interval <= 0 {
+		interval = DefaultLogInterval
+	}
+	var last time.Duration
+	logged := false
+	return func(e Event) {
+		if !e.Done && logged && e.Elapsed-last < interval {
+			return
+		}
+		last, logged = e.Elapsed, true
+
+		attrs := []any{
+			"operation", e.Operation,
+			"processed", e.Processed,
+			"bytes", e.Bytes,
+			"errors", e.Errors,
+			"elapsed", e.Elapsed.Round(time.Millisecond),
+		}
+		if e.Total > 0 {
+			attrs = append(attrs, "total", e.Total)
+			if remaining := e.Remaining(); remaining > 0 {
+				attrs = append(attrs, "remaining", remaining.Round(time.Second))
+			}
+		}
+		msg := "progress"
+		if e.Done {
+			msg = "progress done"
+		}
+		logger.Info(msg, attrs...)
+	}
+}
+
+// JSON writes each event to w as one line of JSON.
+func JSON(w io.Writer) Func {
+	enc := json.NewEncoder(w)
+	return func(e Event) {
+		_ = enc.Encode(e)
+	}
+}
+
+// IsTerminal reports whether f is a character device, such as a terminal.
+func IsTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// Format renders e on one line, for example:
+//
+//	populate: 1200/5000 (24%) 3.1 MiB, 2 errors, 1m05s left - docs/a.txt
+func Format(e Event) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s: %d", e.Operation, e.Processed)
+	if e.Total > 0 {
+		fmt.Fprintf(&b, "/%d (%d%%)", e.Total, min(e.Processed*100/e.Total, 100))
+	}
+	if e.Bytes > 0 {
+		fmt.Fprintf(&b, " %s", formatBytes(e.Bytes))
+	}
+	if e.Errors > 0 {
+		fmt.Fprintf(&b, ", %d errors", e.Errors)
+	}
+	switch remaining := e.Remaining(); {
+	case e.Done:
+		fmt.Fprintf(&b, " in %s", e.Elapsed.Round(time.Millisecond))
+	case remaining > 0:
+		fmt.Fprintf(&b, ", %s left", remaining.Round(time.Second))
+	}
+	if e.Path != "" && !e.Done {
+		fmt.Fprintf(&b, " - %s", e.Path)
+	}
+	return b.String()
+}
+
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
@@ -0,0 +1,83 @@
+package stowry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// PurgeRepo is an optional MetaDataRepo extension for removing an entry
+// outright instead of soft-deleting it. The service checks for it with a
+// type assertion.
+type PurgeRepo interface {
+	// DeleteHard removes the entry at path, whether it is active,
+	// soft-deleted or already cleaned up, so the path keeps no trace of it
+	// in the metadata table. Removing an active entry records a delete in
+	// the change feed.
+	//
+	// Returns:
+	//   - MetaData: The entry as it was before removal; DeletedAt is set
+	//     when it was soft-deleted
+	//   - error: ErrNotFound if path has no entry, or other database errors
+	DeleteHard(ctx context.Context, path string) (MetaData, error)
+}
+
+// Purge removes the object at path at once: its file is deleted from storage
+// and its metadata entry removed, without waiting for a cleanup run and
+// without leaving a soft-deleted entry behind. A soft-deleted object that was
+// not yet cleaned up is purged the same way.
+//
+// The file goes first, so a purge whose metadata removal fails has already
+// removed the content and can be retried.
+//
+// Parameters:
+//   - ctx: Context for cancellation and timeout
+//   - path: Path of the object, used as is without the static and SPA
+//     fallbacks
+//
+// Returns:
+//   - MetaData: The entry removed, for audit records
+//   - error: ErrNotFound if path has no active or soft-deleted object,
+//     ErrInvalidInput for an invalid path, ErrNotSupported when the
+//     repository cannot remove entries, or storage and database errors
+func (s *StowryService) Purge(ctx context.Context, path string) (MetaData, error) {
+	if err := ctx.Err(); err != nil {
+		return MetaData{}, fmt.Errorf("purge object: %w", err)
+	}
+	if path == "" || !IsValidPath(path) {
+		return MetaData{}, fmt.Errorf("purge object %s: %w: invalid path", path, ErrInvalidInput)
+	}
+
+	repo, ok := s.repo.(PurgeRepo)
+	if !ok {
+		return MetaData{}, fmt.Errorf("purge object: metadata repository does not support purging: %w", ErrNotSupported)
+	}
+
+	// Unknown paths are reported before storage is touched
+	if _, err := s.repo.Get(ctx, path); errors.Is(err, ErrNotFound) {
+		if _, err := s.repo.GetDeleted(ctx, path); err != nil && !errors.Is(err, ErrGone) {
+			return MetaData{}, fmt.Errorf("purge object %s: %w", path, err)
+		}
+	} else if err != nil {
+		return MetaData{}, fmt.Errorf("purge object %s: %w", path, err)
+	}
+
+	if err := s.storageDelete(ctx, path); err != nil && !errors.Is(err, ErrNotFound) {
+		return MetaData{}, fmt.Errorf("purge object %s: %w", path, err)
+	}
+
+	m, err := repo.DeleteHard(ctx, path)
+	if err != nil {
+		return MetaData{}, fmt.Errorf("purge object %s: %w", path, err)
+	}
+
+	if m.DeletedAt == nil {
+		if s.objectLimiter != nil {
+			s.objectLimiter.adjust(path, -1)
+		}
+		s.changes.notify()
+	}
+	s.index.invalidate(path)
+
+	return m, nil
+}
@@ -0,0 +1,95 @@
+package stowry_test
+
+import (
+	"context"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/sagarc03/stowry"
+	"github.com/sagarc03/stowry/filesystem"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStowryService_Purge(t *testing.T) {
+	ctx := context.Background()
+	create := func(t *testing.T, service *stowry.StowryService, path, content string) stowry.MetaData {
+		t.Helper()
+		res, err := service.Create(ctx, stowry.CreateObject{Path: path, ContentType: "text/plain"}, strings.NewReader(content))
+		require.NoError(t, err)
+		return res.MetaData
+	}
+
+	t.Run("removes the file and metadata of an active object", func(t *testing.T) {
+		root := newStorageRoot(t)
+		service := newCopyService(t, filesystem.NewFileStorage(root))
+		created := create(t, service, "legal/hold.txt", "secret")
+
+		purged, err := service.Purge(ctx, "legal/hold.txt")
+		require.NoError(t, err)
+		assert.Equal(t, created.ID, purged.ID)
+		assert.Equal(t, created.Etag, purged.Etag)
+		assert.Nil(t, purged.DeletedAt)
+
+		_, err = root.Stat("legal/hold.txt")
+		assert.ErrorIs(t, err, os.ErrNotExist)
+		_, err = service.Info(ctx, "legal/hold.txt")
+		assert.ErrorIs(t, err, stowry.ErrNotFound)
+		_, _, err = service.GetDeleted(ctx, "legal/hold.txt")
+		assert.ErrorIs(t, err, stowry.ErrNotFound, "no soft-deleted entry is left behind")
+	})
+
+	t.Run("removes a soft-deleted object before cleanup", func(t *testing.T) {
+		root := newStorageRoot(t)
+		service := newCopyService(t, filesystem.NewFileStorage(root))
+		create(t, service, "a.txt", "a")
+		require.NoError(t, service.Delete(ctx, "a.txt"))
+
+		purged, err := service.Purge(ctx, "a.txt")
+		require.NoError(t, err)
+		assert.NotNil(t, purged.DeletedAt)
+
+		_, err = root.Stat("a.txt")
+		assert.ErrorIs(t, err, os.ErrNotExist)
+		report, err := service.TombstoneWithReport(ctx, stowry.TombstoneOptions{Query: stowry.ListQuery{Limit: 10}})
+		require.NoError(t, err)
+		assert.Zero(t, report.Cleaned)
+	})
+
+	t.Run("path can be reused", func(t *testing.T) {
+		service := newCopyService(t, newMemStorage())
+		create(t, service, "a.txt", "old")
+
+		_, err := service.Purge(ctx, "a.txt")
+		require.NoError(t, err)
+
+		create(t, service, "a.txt", "new")
+		assert.Equal(t, "new", readObject(t, service, "a.txt"))
+	})
+
+	t.Run("unknown path", func(t *testing.T) {
+		storage := newMemStorage("orphan.txt")
+		service := newCopyService(t, storage)
+
+		_, err := service.Purge(ctx, "orphan.txt")
+		assert.ErrorIs(t, err, stowry.ErrNotFound)
+		assert.Equal(t, []string{"orphan.txt"}, storage.paths(), "storage is not touched")
+	})
+
+	t.Run("invalid path", func(t *testing.T) {
+		service := newCopyService(t, newMemStorage())
+
+		_, err := service.Purge(ctx, "")
+		assert.ErrorIs(t, err, stowry.ErrInvalidInput)
+		_, err = service.Purge(ctx, "../etc/passwd")
+		assert.ErrorIs(t, err, stowry.ErrInvalidInput)
+	})
+
+	t.Run("repository without purge", func(t *testing.T) {
+		service, _, _ := NewStowryService(t)
+
+		_, err := service.Purge(ctx, "a.txt")
+		assert.ErrorIs(t, err, stowry.ErrNotSupported)
+	})
+}
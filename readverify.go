@@ -0,0 +1,179 @@
+package stowry
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"expvar"
+	"fmt"
+	"hash"
+	"io"
+	"log/slog"
+	"math/rand/v2"
+)
+
+// readVerifyVars publishes download verification counters under the expvar
+// name "stowry_read_verify": downloads verified, found corrupted, and
+// quarantined.
+var readVerifyVars = expvar.NewMap("stowry_read_verify")
+
+// DefaultReadVerifySampleRate is the fraction of downloads checked in
+// ReadVerifySample mode when ReadVerifyConfig.SampleRate is not set.
+const DefaultReadVerifySampleRate = 0.01
+
+// ReadVerifyMode selects which downloads are checked against their ETag.
+type ReadVerifyMode string
+
+const (
+	ReadVerifyOff    ReadVerifyMode = "off"    // No download is checked
+	ReadVerifySample ReadVerifyMode = "sample" // A random SampleRate of downloads is checked
+	ReadVerifyAlways ReadVerifyMode = "always" // Every download is checked
+)
+
+// ReadVerifyConfig controls the checking of downloads against their ETag.
+//
+// A checked download hashes the content as it is sent and compares the
+// SHA-256 with the object's ETag once the whole object was read. The client
+// has received the bytes by then, so this detects corruption in storage; it
+// does not keep corrupted content from being served. Ranged and incomplete
+// downloads are not checked.
+type ReadVerifyConfig struct {
+	Mode       ReadVerifyMode // Empty is ReadVerifyOff
+	SampleRate float64        // Fraction of downloads checked in ReadVerifySample mode, in (0, 1] (default: 0.01)
+	// Quarantine soft-deletes a corrupted object and flags it, so it is no
+	// longer served and cleanup keeps its file for inspection.
+	Quarantine bool
+}
+
+// Validate reports an unknown mode or a sample rate outside [0, 1].
+func (c ReadVerifyConfig) Validate() error {
+	switch c.Mode {
+	case "", ReadVerifyOff, ReadVerifySample, ReadVerifyAlways:
+	default:
+		return fmt.Errorf("read verify: unknown mode %q (want off, sample or always)", c.Mode)
+	}
+	if c.SampleRate < 0 || c.SampleRate > 1 {
+		return fmt.Errorf("read verify: sample rate %v must be between 0 and 1", c.SampleRate)
+	}
+	return nil
+}
+
+// withDefaults returns c with the default sample rate filled in.
+func (c ReadVerifyConfig) withDefaults() ReadVerifyConfig {
+	if c.SampleRate == 0 {
+		c.SampleRate = DefaultReadVerifySampleRate
+	}
+	return c
+}
+
+// verifyRead returns content wrapped to be checked against obj when this
+// download is selected for verification, and content itself otherwise.
+func (s *StowryService) verifyRead(ctx context.Context, obj MetaData, content io.ReadSeekCloser) io.ReadSeekCloser {
+	switch s.readVerify.Mode {
+	case ReadVerifyAlways:
+	case ReadVerifySample:
+		if rand.Float64() >= s.readVerify.SampleRate { //nolint:gosec // Sampling needs no secure randomness
+			return content
+		}
+	default:
+		return content
+	}
+
+	return &verifyingReader{
+		ReadSeekCloser: content,
+		ctx:            context.WithoutCancel(ctx),
+		service:        s,
+		obj:            obj,
+		hash:           sha256.New(),
+		active:         true,
+	}
+}
+
+// verifyingReader hashes what is read from an object's content and checks it
+// on Close. A seek anywhere but the start stops the check until the next seek
+// to the start, so ranged reads are not checked; http.ServeContent seeks to
+// the end and back before a full read.
+type verifyingReader struct {
+	io.ReadSeekCloser
+	ctx     context.Context
+	service *StowryService
+	obj     MetaData
+	hash    hash.Hash
+	read    int64
+	active  bool
+}
+
+func (r *verifyingReader) Read(p []byte) (int, error) {
+	n, err := r.ReadSeekCloser.Read(p)
+	if r.active {
+		r.hash.Write(p[:n])
+		r.read += int64(n)
+	}
+	return n, err
+}
+
+func (r *verifyingReader) Seek(offset int64, whence int) (int64, error) {
+	pos, err := r.ReadSeekCloser.Seek(offset, whence)
+	if err != nil {
+		r.active = false
+		return pos, err
+	}
+	r.active = pos == 0
+	r.hash.Reset()
+	r.read = 0
+	return pos, nil
+}
+
+func (r *verifyingReader) Close() error {
+	err := r.ReadSeekCloser.Close()
+	if r.active && r.read == r.obj.FileSizeBytes {
+		r.service.checkRead(r.ctx, r.obj, r.hash)
+	}
+	return err
+}
+
+// checkRead compares the hash of a whole download of obj with its ETag, and
+// logs, counts and, if configured, quarantines a mismatch.
+func (s *StowryService) checkRead(ctx context.Context, obj MetaData, h hash.Hash) {
+	readVerifyVars.Add("verified", 1)
+	if digestMatches(h, obj.Etag) {
+		return
+	}
+
+	readVerifyVars.Add("corrupted", 1)
+	slog.Error("download does not match its etag; the stored file is corrupted",
+		"path", obj.Path, "etag", obj.Etag, "actual", hex.EncodeToString(h.Sum(nil)), "quarantine", s.readVerify.Quarantine)
+	if !s.readVerify.Quarantine {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, s.cleanupTimeout)
+	defer cancel()
+	if err := s.quarantine(ctx, obj); err != nil {
+		slog.Error("quarantine corrupted object", "path", obj.Path, "error", err)
+		return
+	}
+	readVerifyVars.Add("quarantined", 1)
+}
+
+// quarantine soft-deletes obj and flags it as quarantined, unless it was
+// replaced since obj was read.
+func (s *StowryService) quarantine(ctx context.Context, obj MetaData) error {
+	if err := s.repo.Quarantine(ctx, obj.Path, obj.Etag); err != nil {
+		return fmt.Errorf("quarantine %s: %w", obj.Path, err)
+	}
+
+	if s.objectLimiter != nil {
+		s.objectLimiter.adjust(obj.Path, -1)
+	}
+	s.index.invalidate(obj.Path)
+	s.changes.notify()
+
+	slog.Warn("object quarantined", "path", obj.Path, "etag", obj.Etag)
+	return nil
+}
+
+// digestMatches reports whether the SHA-256 in h is the object ETag etag.
+func digestMatches(h hash.Hash, etag string) bool {
+	return hex.EncodeToString(h.Sum(nil)) == etag
+}
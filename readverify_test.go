@@ -0,0 +1,165 @@
+package stowry_test
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/sagarc03/stowry"
+	"github.com/sagarc03/stowry/filesystem"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// corruptingStorage serves the files of a FileStorage with their first byte
+// flipped, like a failing disk.
+type corruptingStorage struct {
+	stowry.FileStorage
+}
+
+func (s corruptingStorage) Get(ctx context.Context, path string) (io.ReadSeekCloser, error) {
+	f, err := s.FileStorage.Get(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = f.Close() }()
+
+	content, err := io.ReadAll(f)
+	if err != nil {
+		return nil, err
+	}
+	if len(content) > 0 {
+		content[0] ^= 0xff
+	}
+	return &mockReadSeekCloser{content: content}, nil
+}
+
+func TestStowryService_Get_ReadVerify(t *testing.T) {
+	const content = "stored content"
+	sum := sha256.Sum256([]byte(content))
+	obj := stowry.MetaData{Path: "a.txt", Etag: hex.EncodeToString(sum[:]), FileSizeBytes: int64(len(content))}
+
+	newService := func(t *testing.T, cfg stowry.ReadVerifyConfig, corrupt bool) (*stowry.StowryService, *SpyMetaDataRepo) {
+		t.Helper()
+		root, err := os.OpenRoot(t.TempDir())
+		require.NoError(t, err)
+		t.Cleanup(func() { _ = root.Close() })
+
+		var storage stowry.FileStorage = filesystem.NewFileStorage(root)
+		_, err = storage.Write(t.Context(), obj.Path, strings.NewReader(content))
+		require.NoError(t, err)
+		if corrupt {
+			storage = corruptingStorage{storage}
+		}
+
+		repo := new(SpyMetaDataRepo)
+		repo.On("Get", mock.Anything, obj.Path).Return(obj, nil)
+		service, err := stowry.NewStowryService(repo, storage, stowry.ServiceConfig{Mode: stowry.ModeStore, ReadVerify: cfg})
+		require.NoError(t, err)
+		return service, repo
+	}
+
+	// serve downloads the object the way the HTTP handler does
+	serve := func(t *testing.T, service *stowry.StowryService, rangeHeader string) *httptest.ResponseRecorder {
+		t.Helper()
+		m, f, err := service.Get(t.Context(), obj.Path)
+		require.NoError(t, err)
+
+		req := httptest.NewRequest(http.MethodGet, "/"+obj.Path, nil)
+		if rangeHeader != "" {
+			req.Header.Set("Range", rangeHeader)
+		}
+		rec := httptest.NewRecorder()
+		http.ServeContent(rec, req, m.Path, m.UpdatedAt, f)
+		require.NoError(t, f.Close())
+		return rec
+	}
+
+	always := stowry.ReadVerifyConfig{Mode: stowry.ReadVerifyAlways, Quarantine: true}
+
+	t.Run("intact download passes", func(t *testing.T) {
+		service, repo := newService(t, always, false)
+
+		rec := serve(t, service, "")
+
+		assert.Equal(t, content, rec.Body.String())
+		repo.AssertNotCalled(t, "Quarantine", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("corrupted download is quarantined after it was served", func(t *testing.T) {
+		service, repo := newService(t, always, true)
+		repo.On("Quarantine", mock.Anything, obj.Path, obj.Etag).Return(nil)
+
+		rec := serve(t, service, "")
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.NotEqual(t, content, rec.Body.String(), "detection, not prevention")
+		repo.AssertCalled(t, "Quarantine", mock.Anything, obj.Path, obj.Etag)
+	})
+
+	t.Run("corrupted download without quarantine is only reported", func(t *testing.T) {
+		service, repo := newService(t, stowry.ReadVerifyConfig{Mode: stowry.ReadVerifyAlways}, true)
+
+		serve(t, service, "")
+
+		repo.AssertNotCalled(t, "Quarantine", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("ranged download is not checked", func(t *testing.T) {
+		service, repo := newService(t, always, true)
+
+		rec := serve(t, service, "bytes=2-")
+
+		assert.Equal(t, http.StatusPartialContent, rec.Code)
+		repo.AssertNotCalled(t, "Quarantine", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("incomplete download is not checked", func(t *testing.T) {
+		service, repo := newService(t, always, true)
+
+		_, f, err := service.Get(t.Context(), obj.Path)
+		require.NoError(t, err)
+		_, err = io.ReadFull(f, make([]byte, 4))
+		require.NoError(t, err)
+		require.NoError(t, f.Close())
+
+		repo.AssertNotCalled(t, "Quarantine", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("off checks nothing", func(t *testing.T) {
+		service, repo := newService(t, stowry.ReadVerifyConfig{Mode: stowry.ReadVerifyOff, Quarantine: true}, true)
+
+		serve(t, service, "")
+
+		repo.AssertNotCalled(t, "Quarantine", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("sample rate of one checks every download", func(t *testing.T) {
+		service, repo := newService(t, stowry.ReadVerifyConfig{Mode: stowry.ReadVerifySample, SampleRate: 1, Quarantine: true}, true)
+		repo.On("Quarantine", mock.Anything, obj.Path, obj.Etag).Return(nil)
+
+		serve(t, service, "")
+
+		repo.AssertNumberOfCalls(t, "Quarantine", 1)
+	})
+}
+
+func TestReadVerifyConfig_Validate(t *testing.T) {
+	assert.NoError(t, stowry.ReadVerifyConfig{}.Validate())
+	assert.NoError(t, stowry.ReadVerifyConfig{Mode: stowry.ReadVerifySample, SampleRate: 0.5}.Validate())
+	assert.ErrorContains(t, stowry.ReadVerifyConfig{Mode: "often"}.Validate(), "unknown mode")
+	assert.ErrorContains(t, stowry.ReadVerifyConfig{Mode: stowry.ReadVerifySample, SampleRate: 2}.Validate(), "sample rate")
+
+	_, err := stowry.NewStowryService(new(SpyMetaDataRepo), new(SpyFileStorage), stowry.ServiceConfig{
+		Mode:       stowry.ModeStore,
+		ReadVerify: stowry.ReadVerifyConfig{Mode: "often"},
+	})
+	assert.ErrorContains(t, err, "unknown mode")
+}
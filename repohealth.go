@@ -0,0 +1,78 @@
+package stowry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// DefaultRepoCheckSize is the upload size from which Create checks that the
+// metadata repository is reachable before storing content, when
+// ServiceConfig.RepoCheckSize is not set.
+const DefaultRepoCheckSize = 10 << 20
+
+const (
+	// RepoHealthTTL is how long a successful repository ping is reused, so
+	// a burst of large uploads costs one ping.
+	RepoHealthTTL = time.Second
+	// repoPingTimeout bounds a ping. A repository that cannot answer a ping
+	// in this time would not commit an upload either.
+	repoPingTimeout = 5 * time.Second
+)
+
+// Pinger is an optional MetaDataRepo extension reporting whether the
+// database is reachable. The service checks for it with a type assertion.
+type Pinger interface {
+	// Ping returns an error when the database cannot be reached.
+	Ping(ctx context.Context) error
+}
+
+// repoHealth caches successful repository pings for RepoHealthTTL. The
+// zero value is ready to use.
+type repoHealth struct {
+	mu        sync.Mutex
+	healthyAt time.Time
+}
+
+// check pings the repository unless a ping within RepoHealthTTL succeeded.
+// Failures are not cached, so the first upload after the repository
+// recovers goes through. Concurrent callers share one ping. A repository
+// without Ping is always healthy, and pings are not cut short by ctx, so a
+// cancelled request cannot mark a healthy repository down.
+func (h *repoHealth) check(ctx context.Context, repo MetaDataRepo) error {
+	pinger, ok := repo.(Pinger)
+	if !ok {
+		return nil
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if time.Since(h.healthyAt) < RepoHealthTTL {
+		return nil
+	}
+
+	pingCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), repoPingTimeout)
+	defer cancel()
+	if err := pinger.Ping(pingCtx); err != nil && !errors.Is(err, ErrNotSupported) {
+		return err
+	}
+	h.healthyAt = time.Now()
+	return nil
+}
+
+// checkRepo fails fast with ErrUnavailable when an upload of size bytes
+// would only find out after storing its content that the metadata
+// repository is down. Uploads of unknown size, and those below the
+// configured threshold, are not checked.
+func (s *StowryService) checkRepo(ctx context.Context, size int64) error {
+	if s.repoCheckSize < 0 || size < s.repoCheckSize || size <= 0 {
+		return nil
+	}
+	if err := s.health.check(ctx, s.repo); err != nil {
+		return fmt.Errorf("metadata repository: %w: %w", ErrUnavailable, err)
+	}
+	return nil
+}
@@ -0,0 +1,107 @@
+package stowry_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/sagarc03/stowry"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// pingingRepo is a SpyMetaDataRepo whose database can be taken down.
+type pingingRepo struct {
+	SpyMetaDataRepo
+	down  atomic.Bool
+	pings atomic.Int32
+}
+
+func (r *pingingRepo) Ping(context.Context) error {
+	r.pings.Add(1)
+	if r.down.Load() {
+		return errors.New("connection refused")
+	}
+	return nil
+}
+
+func newHealthCheckedService(t *testing.T, checkSize int64) (*stowry.StowryService, *pingingRepo, *SpyFileStorage) {
+	t.Helper()
+	repo := new(pingingRepo)
+	storage := new(SpyFileStorage)
+	service, err := stowry.NewStowryService(repo, storage, stowry.ServiceConfig{Mode: stowry.ModeStore, RepoCheckSize: checkSize})
+	require.NoError(t, err)
+	return service, repo, storage
+}
+
+func createSized(service *stowry.StowryService, path string, size int64) error {
+	_, err := service.Create(context.Background(), stowry.CreateObject{Path: path, ContentType: "text/plain", Size: size}, strings.NewReader("x"))
+	return err
+}
+
+func TestStowryService_Create_RepoHealth(t *testing.T) {
+	t.Run("large upload fails before storing when the repo is down", func(t *testing.T) {
+		service, repo, storage := newHealthCheckedService(t, 100)
+		repo.down.Store(true)
+
+		err := createSized(service, "big.bin", 100)
+		require.ErrorIs(t, err, stowry.ErrUnavailable)
+		assert.Contains(t, err.Error(), "connection refused")
+
+		storage.AssertNotCalled(t, "Write", mock.Anything, mock.Anything, mock.Anything)
+		repo.AssertNotCalled(t, "Upsert", mock.Anything, mock.Anything)
+	})
+
+	t.Run("small and unknown-size uploads are not checked", func(t *testing.T) {
+		service, repo, storage := newHealthCheckedService(t, 100)
+		repo.down.Store(true)
+		expectWrite(&repo.SpyMetaDataRepo, storage, "small.txt", true)
+		expectWrite(&repo.SpyMetaDataRepo, storage, "stream.txt", true)
+
+		require.NoError(t, createSized(service, "small.txt", 99))
+		require.NoError(t, createSized(service, "stream.txt", 0))
+		assert.Zero(t, repo.pings.Load())
+	})
+
+	t.Run("healthy pings are reused", func(t *testing.T) {
+		service, repo, storage := newHealthCheckedService(t, 100)
+		expectWrite(&repo.SpyMetaDataRepo, storage, "a.bin", true)
+		expectWrite(&repo.SpyMetaDataRepo, storage, "b.bin", true)
+
+		require.NoError(t, createSized(service, "a.bin", 100))
+		require.NoError(t, createSized(service, "b.bin", 100))
+		assert.Equal(t, int32(1), repo.pings.Load())
+	})
+
+	t.Run("failures are not cached", func(t *testing.T) {
+		service, repo, storage := newHealthCheckedService(t, 100)
+		repo.down.Store(true)
+		require.ErrorIs(t, createSized(service, "a.bin", 100), stowry.ErrUnavailable)
+
+		repo.down.Store(false)
+		expectWrite(&repo.SpyMetaDataRepo, storage, "a.bin", true)
+		require.NoError(t, createSized(service, "a.bin", 100))
+		assert.Equal(t, int32(2), repo.pings.Load())
+	})
+
+	t.Run("negative threshold disables the check", func(t *testing.T) {
+		service, repo, storage := newHealthCheckedService(t, -1)
+		repo.down.Store(true)
+		expectWrite(&repo.SpyMetaDataRepo, storage, "big.bin", true)
+
+		require.NoError(t, createSized(service, "big.bin", 1<<40))
+		assert.Zero(t, repo.pings.Load())
+	})
+
+	t.Run("zero threshold uses the default", func(t *testing.T) {
+		service, repo, storage := newHealthCheckedService(t, 0)
+		repo.down.Store(true)
+		expectWrite(&repo.SpyMetaDataRepo, storage, "below.bin", true)
+
+		require.NoError(t, createSized(service, "below.bin", stowry.DefaultRepoCheckSize-1))
+		require.ErrorIs(t, createSized(service, "at.bin", stowry.DefaultRepoCheckSize), stowry.ErrUnavailable)
+	})
+}
@@ -0,0 +1,100 @@
+package stowry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Restore undoes the soft delete of the object at path, making it active
+// again with its content, metadata and ID as they were. Only objects that
+// were not cleaned up or quarantined can be restored; ListDeleted lists them.
+//
+// Parameters:
+//   - ctx: Context for cancellation and timeout
+//   - path: Path of the object, used as is without the static and SPA
+//     fallbacks
+//
+// Returns:
+//   - MetaData: The restored object
+//   - error: ErrNotFound if path has no restorable object, ErrGone if it was
+//     cleaned up or its file is missing, ErrAlreadyExists if path holds an
+//     active object again, ErrInvalidInput for an invalid or reserved path,
+//     ErrObjectLimitExceeded when the prefix is full, or storage and
+//     database errors
+func (s *StowryService) Restore(ctx context.Context, path string) (MetaData, error) {
+	if err := ctx.Err(); err != nil {
+		return MetaData{}, fmt.Errorf("restore object: %w", err)
+	}
+	if path == "" || !IsValidPath(path) {
+		return MetaData{}, fmt.Errorf("restore object %s: %w: invalid path", path, ErrInvalidInput)
+	}
+	if err := checkReservedPath(path); err != nil {
+		return MetaData{}, fmt.Errorf("restore object %s: %w", path, err)
+	}
+
+	deleted, err := s.repo.GetDeleted(ctx, path)
+	if errors.Is(err, ErrNotFound) {
+		// The path may hold an active object instead
+		if _, getErr := s.repo.Get(ctx, path); getErr == nil {
+			return MetaData{}, fmt.Errorf("restore object %s: %w", path, ErrAlreadyExists)
+		}
+	}
+	if err != nil {
+		return MetaData{}, fmt.Errorf("restore object %s: %w", path, err)
+	}
+
+	f, err := s.storageGet(ctx, deleted.Path)
+	if errors.Is(err, ErrNotFound) {
+		return MetaData{}, fmt.Errorf("restore object %s: %w: file is missing", path, ErrGone)
+	}
+	if err != nil {
+		return MetaData{}, fmt.Errorf("restore object %s: %w", path, err)
+	}
+	_ = f.Close()
+
+	if s.objectLimiter != nil {
+		if err := s.objectLimiter.admit(ctx, []string{path}); err != nil {
+			return MetaData{}, fmt.Errorf("restore object %s: %w", path, err)
+		}
+	}
+
+	m, err := s.repo.Restore(ctx, path)
+	if err != nil {
+		return MetaData{}, fmt.Errorf("restore object %s: %w", path, err)
+	}
+
+	if s.objectLimiter != nil {
+		s.objectLimiter.adjust(path, 1)
+	}
+	s.index.invalidate(path)
+	s.changes.notify()
+
+	return m, nil
+}
+
+// ListDeleted lists the soft-deleted objects that can still be restored,
+// with DeletedAt set, in the same order and pages as List. The rows of
+// replaced files kept under StagePrefix are left out, so a page may hold
+// fewer items than the limit.
+func (s *StowryService) ListDeleted(ctx context.Context, q ListQuery) (ListResult, error) {
+	if err := ctx.Err(); err != nil {
+		return ListResult{}, fmt.Errorf("list deleted objects: %w", err)
+	}
+
+	if err := q.Validate(s.pathLimits); err != nil {
+		return ListResult{}, fmt.Errorf("list deleted objects: %w", err)
+	}
+
+	result, err := s.repo.ListPendingCleanup(ctx, q)
+	if err != nil {
+		return ListResult{}, fmt.Errorf("list deleted objects: %w", err)
+	}
+	result.Items = slices.DeleteFunc(result.Items, func(m MetaData) bool {
+		return strings.HasPrefix(m.Path, StagePrefix)
+	})
+
+	return result, nil
+}
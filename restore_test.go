@@ -0,0 +1,160 @@
+package stowry_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/sagarc03/stowry"
+	"github.com/sagarc03/stowry/filesystem"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStowryService_Restore(t *testing.T) {
+	ctx := context.Background()
+	create := func(t *testing.T, service *stowry.StowryService, path, content string) stowry.MetaData {
+		t.Helper()
+		res, err := service.Create(ctx, stowry.CreateObject{Path: path, ContentType: "text/plain"}, strings.NewReader(content))
+		require.NoError(t, err)
+		return res.MetaData
+	}
+
+	t.Run("brings back a soft-deleted object", func(t *testing.T) {
+		service := newCopyService(t, newMemStorage())
+		created := create(t, service, "docs/report.txt", "quarterly")
+		require.NoError(t, service.Delete(ctx, "docs/report.txt"))
+
+		restored, err := service.Restore(ctx, "docs/report.txt")
+		require.NoError(t, err)
+		assert.Equal(t, created.ID, restored.ID)
+		assert.Equal(t, created.Etag, restored.Etag)
+		assert.Nil(t, restored.DeletedAt)
+
+		assert.Equal(t, "quarterly", readObject(t, service, "docs/report.txt"))
+		_, _, err = service.GetDeleted(ctx, "docs/report.txt")
+		assert.ErrorIs(t, err, stowry.ErrNotFound)
+	})
+
+	t.Run("active object", func(t *testing.T) {
+		service := newCopyService(t, newMemStorage())
+		create(t, service, "a.txt", "a")
+
+		_, err := service.Restore(ctx, "a.txt")
+		assert.ErrorIs(t, err, stowry.ErrAlreadyExists)
+	})
+
+	t.Run("path written again after the delete", func(t *testing.T) {
+		service := newCopyService(t, newMemStorage())
+		create(t, service, "a.txt", "old")
+		require.NoError(t, service.Delete(ctx, "a.txt"))
+		create(t, service, "a.txt", "new")
+
+		_, err := service.Restore(ctx, "a.txt")
+		assert.ErrorIs(t, err, stowry.ErrAlreadyExists)
+		assert.Equal(t, "new", readObject(t, service, "a.txt"))
+	})
+
+	t.Run("unknown path", func(t *testing.T) {
+		service := newCopyService(t, newMemStorage())
+
+		_, err := service.Restore(ctx, "missing.txt")
+		assert.ErrorIs(t, err, stowry.ErrNotFound)
+	})
+
+	t.Run("cleaned up object", func(t *testing.T) {
+		service := newCopyService(t, newMemStorage())
+		create(t, service, "a.txt", "a")
+		require.NoError(t, service.Delete(ctx, "a.txt"))
+		_, err := service.Tombstone(ctx, stowry.ListQuery{Limit: 10})
+		require.NoError(t, err)
+
+		_, err = service.Restore(ctx, "a.txt")
+		assert.ErrorIs(t, err, stowry.ErrGone)
+		_, err = service.Info(ctx, "a.txt")
+		assert.ErrorIs(t, err, stowry.ErrNotFound)
+	})
+
+	t.Run("file missing from storage", func(t *testing.T) {
+		root := newStorageRoot(t)
+		service := newCopyService(t, filesystem.NewFileStorage(root))
+		create(t, service, "a.txt", "a")
+		require.NoError(t, service.Delete(ctx, "a.txt"))
+		require.NoError(t, root.Remove("a.txt"))
+
+		_, err := service.Restore(ctx, "a.txt")
+		assert.ErrorIs(t, err, stowry.ErrGone)
+		_, err = service.Info(ctx, "a.txt")
+		assert.ErrorIs(t, err, stowry.ErrNotFound)
+	})
+
+	t.Run("invalid path", func(t *testing.T) {
+		service := newCopyService(t, newMemStorage())
+
+		_, err := service.Restore(ctx, "")
+		assert.ErrorIs(t, err, stowry.ErrInvalidInput)
+		_, err = service.Restore(ctx, "../etc/passwd")
+		assert.ErrorIs(t, err, stowry.ErrInvalidInput)
+	})
+
+	t.Run("reserved path", func(t *testing.T) {
+		service := newCopyService(t, newMemStorage())
+		stage, err := service.CreateStage(ctx)
+		require.NoError(t, err)
+
+		for _, path := range []string{
+			stowry.ReplacedPath(stage.ID, "a.txt"),
+			stowry.ETagPrefix + "abc",
+			stowry.DefaultQuarantinePrefix + "a.txt",
+			stowry.InstanceFile,
+		} {
+			_, err := service.Restore(ctx, path)
+			assert.ErrorIs(t, err, stowry.ErrInvalidInput, path)
+		}
+	})
+}
+
+func TestStowryService_ListDeleted(t *testing.T) {
+	ctx := context.Background()
+	service := newCopyService(t, newMemStorage())
+	for _, path := range []string{"docs/a.txt", "docs/b.txt", "img/c.png"} {
+		_, err := service.Create(ctx, stowry.CreateObject{Path: path, ContentType: "text/plain"}, strings.NewReader(path))
+		require.NoError(t, err)
+	}
+	require.NoError(t, service.Delete(ctx, "docs/a.txt"))
+	require.NoError(t, service.Delete(ctx, "img/c.png"))
+
+	result, err := service.ListDeleted(ctx, stowry.ListQuery{PathPrefix: "docs/", Limit: 10})
+	require.NoError(t, err)
+	require.Len(t, result.Items, 1)
+	assert.Equal(t, "docs/a.txt", result.Items[0].Path)
+	assert.NotNil(t, result.Items[0].DeletedAt)
+
+	_, err = service.Restore(ctx, "docs/a.txt")
+	require.NoError(t, err)
+
+	result, err = service.ListDeleted(ctx, stowry.ListQuery{Limit: 10})
+	require.NoError(t, err)
+	require.Len(t, result.Items, 1)
+	assert.Equal(t, "img/c.png", result.Items[0].Path)
+
+	_, err = service.ListDeleted(ctx, stowry.ListQuery{PathPrefix: "bad\x00", Limit: 10})
+	assert.ErrorIs(t, err, stowry.ErrInvalidInput)
+
+	t.Run("leaves out files replaced by a stage", func(t *testing.T) {
+		service := newCopyService(t, newMemStorage())
+		_, err := service.Create(ctx, stowry.CreateObject{Path: "site/index.html", ContentType: "text/html"}, strings.NewReader("old"))
+		require.NoError(t, err)
+
+		stage, err := service.CreateStage(ctx)
+		require.NoError(t, err)
+		_, err = service.StageObject(ctx, stage.ID, stowry.CreateObject{Path: "site/index.html", ContentType: "text/html"}, strings.NewReader("new"))
+		require.NoError(t, err)
+		_, err = service.CommitStage(ctx, stage.ID)
+		require.NoError(t, err)
+
+		result, err := service.ListDeleted(ctx, stowry.ListQuery{Limit: 10})
+		require.NoError(t, err)
+		assert.Empty(t, result.Items)
+	})
+}
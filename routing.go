@@ -0,0 +1,260 @@
+package stowry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+)
+
+// StorageRoute sends every object whose path starts with Prefix to Storage.
+// The default route has an empty Prefix.
+type StorageRoute struct {
+	Prefix  string
+	Storage FileStorage
+}
+
+// StorageLocator is an optional FileStorage extension for storage spread
+// over several backends. The service records the backend every object is
+// written to in MetaData.StorageBackend, and Verify checks each object on
+// that backend rather than wherever its path routes now.
+type StorageLocator interface {
+	// Backend returns the name of the backend path is written to, empty for
+	// the default backend.
+	Backend(path string) string
+	// BackendStorage returns the backend named name, false when none is
+	// configured under that name.
+	BackendStorage(name string) (FileStorage, bool)
+}
+
+// RoutedStorage is a FileStorage that spreads objects over several backends
+// by path prefix, such as recent uploads on a fast disk and archives on a
+// large slow one. Each path belongs to exactly one backend, chosen by Route:
+// the longest matching prefix wins and paths matching no prefix go to the
+// default backend.
+//
+// List and Walk cover every backend but only report files stored on the
+// backend their path routes to. A file left behind on another backend, for
+// example after a route was added, is unreachable through RoutedStorage until
+// it is moved; Routes exposes the backends for tools that do that.
+//
+// RoutedStorage implements StorageWalker, StorageMover and PathSpaceChecker
+// by delegating to the backends, which must support them for the call to
+// succeed, StorageStater through StatFile, which works on any backend, and
+// StorageLocator, naming each backend by its route prefix.
+type RoutedStorage struct {
+	routes []StorageRoute // longest prefix first; the default route is last
+}
+
+// NewRoutedStorage creates a RoutedStorage sending paths that match no route
+// to defaultStorage.
+//
+// Parameters:
+//   - defaultStorage: Backend for paths that match no route
+//   - routes: Prefix routes in any order. Prefixes must be non-empty, must
+//     not start with "/" and must be unique.
+//
+// Returns:
+//   - *RoutedStorage: The composite storage
+//   - error: ErrInvalidInput for a missing backend or an invalid or duplicate prefix
+func NewRoutedStorage(defaultStorage FileStorage, routes []StorageRoute) (*RoutedStorage, error) {
+	if defaultStorage == nil {
+		return nil, fmt.Errorf("new routed storage: default storage is required: %w", ErrInvalidInput)
+	}
+
+	seen := make(map[string]bool, len(routes))
+	sorted := make([]StorageRoute, 0, len(routes)+1)
+	for i, route := range routes {
+		switch {
+		case route.Prefix == "":
+			return nil, fmt.Errorf("new routed storage: route %d: prefix is required: %w", i+1, ErrInvalidInput)
+		case strings.HasPrefix(route.Prefix, "/"):
+			return nil, fmt.Errorf("new routed storage: route %d (%s): prefix must not start with /: %w", i+1, route.Prefix, ErrInvalidInput)
+		case seen[route.Prefix]:
+			return nil, fmt.Errorf("new routed storage: route %d (%s): duplicate prefix: %w", i+1, route.Prefix, ErrInvalidInput)
+		case route.Storage == nil:
+			return nil, fmt.Errorf("new routed storage: route %d (%s): storage is required: %w", i+1, route.Prefix, ErrInvalidInput)
+		}
+		seen[route.Prefix] = true
+		sorted = append(sorted, route)
+	}
+
+	slices.SortStableFunc(sorted, func(a, b StorageRoute) int {
+		return len(b.Prefix) - len(a.Prefix)
+	})
+	sorted = append(sorted, StorageRoute{Storage: defaultStorage})
+
+	return &RoutedStorage{routes: sorted}, nil
+}
+
+// Route returns the route that stores path: the route with the longest
+// prefix of path, or the default route, whose Prefix is empty.
+func (s *RoutedStorage) Route(path string) StorageRoute {
+	return s.routes[s.index(path)]
+}
+
+// Routes returns every route in match order, longest prefix first, with the
+// default route last.
+func (s *RoutedStorage) Routes() []StorageRoute {
+	return slices.Clone(s.routes)
+}
+
+// Backend returns the prefix of the route that stores path, empty for the
+// default route.
+func (s *RoutedStorage) Backend(path string) string {
+	return s.Route(path).Prefix
+}
+
+// BackendStorage returns the storage of the route whose prefix is name; the
+// empty name is the default route.
+func (s *RoutedStorage) BackendStorage(name string) (FileStorage, bool) {
+	for _, route := range s.routes {
+		if route.Prefix == name {
+			return route.Storage, true
+		}
+	}
+	return nil, false
+}
+
+func (s *RoutedStorage) index(path string) int {
+	for i, route := range s.routes {
+		if strings.HasPrefix(path, route.Prefix) {
+			return i
+		}
+	}
+	// Unreachable: the default route's empty prefix matches every path
+	return len(s.routes) - 1
+}
+
+// Get reads path from the backend it routes to.
+func (s *RoutedStorage) Get(ctx context.Context, path string) (io.ReadSeekCloser, error) {
+	return s.Route(path).Storage.Get(ctx, path)
+}
+
+// Write stores content on the backend path routes to.
+func (s *RoutedStorage) Write(ctx context.Context, path string, content io.Reader) (SaveResult, error) {
+	return s.Route(path).Storage.Write(ctx, path, content)
+}
+
+// Delete removes path from the backend it routes to.
+func (s *RoutedStorage) Delete(ctx context.Context, path string) error {
+	return s.Route(path).Storage.Delete(ctx, path)
+}
+
+// List returns the files of every backend that route to it, sorted by path,
+// each with the StorageBackend it is on.
+func (s *RoutedStorage) List(ctx context.Context) ([]ObjectEntry, error) {
+	entries := []ObjectEntry{}
+	for i, route := range s.routes {
+		listed, err := route.Storage.List(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("list %s: %w", routeName(route), err)
+		}
+		for _, entry := range listed {
+			if s.index(entry.Path) == i {
+				entry.StorageBackend = route.Prefix
+				entries = append(entries, entry)
+			}
+		}
+	}
+
+	slices.SortFunc(entries, func(a, b ObjectEntry) int {
+		return strings.Compare(a.Path, b.Path)
+	})
+	return entries, nil
+}
+
+// Walk calls fn for the files of every backend that route to it, one
+// backend at a time. Every backend must implement StorageWalker.
+func (s *RoutedStorage) Walk(ctx context.Context, fn func(StoredFile) error) error {
+	for i, route := range s.routes {
+		walker, ok := route.Storage.(StorageWalker)
+		if !ok {
+			return fmt.Errorf("walk %s: storage does not support walking: %w", routeName(route), ErrInvalidInput)
+		}
+		err := walker.Walk(ctx, func(f StoredFile) error {
+			if s.index(f.Path) != i {
+				return nil
+			}
+			return fn(f)
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Stat describes path on the backend it routes to.
+func (s *RoutedStorage) Stat(ctx context.Context, path string) (StoredFile, error) {
+	return StatFile(ctx, s.Route(path).Storage, path)
+}
+
+// Move renames from to to. Within one backend it uses the backend's
+// StorageMover; across backends it copies the file and then deletes the
+// source.
+func (s *RoutedStorage) Move(ctx context.Context, from, to string) error {
+	src, dst := s.Route(from), s.Route(to)
+	if src.Prefix == dst.Prefix {
+		mover, ok := src.Storage.(StorageMover)
+		if !ok {
+			return fmt.Errorf("move %s: storage does not support moving: %w", from, ErrInvalidInput)
+		}
+		return mover.Move(ctx, from, to)
+	}
+
+	content, err := src.Storage.Get(ctx, from)
+	if err != nil {
+		return fmt.Errorf("move %s: %w", from, err)
+	}
+	_, err = dst.Storage.Write(ctx, to, content)
+	closeErr := content.Close()
+	if err = errors.Join(err, closeErr); err != nil {
+		return fmt.Errorf("move %s: copy to %s: %w", from, routeName(dst), err)
+	}
+
+	if err := src.Storage.Delete(ctx, from); err != nil {
+		return fmt.Errorf("move %s: remove source: %w", from, err)
+	}
+	return nil
+}
+
+// Copy copies from to to. Within one backend it uses the backend's
+// StorageCopier, if it has one; otherwise it reads the file from its backend
+// and writes it to the backend of to.
+func (s *RoutedStorage) Copy(ctx context.Context, from, to string) (SaveResult, error) {
+	src, dst := s.Route(from), s.Route(to)
+	if copier, ok := src.Storage.(StorageCopier); ok && src.Prefix == dst.Prefix {
+		return copier.Copy(ctx, from, to)
+	}
+
+	content, err := src.Storage.Get(ctx, from)
+	if err != nil {
+		return SaveResult{}, fmt.Errorf("copy %s: %w", from, err)
+	}
+	defer func() { _ = content.Close() }()
+
+	result, err := dst.Storage.Write(ctx, to, content)
+	if err != nil {
+		return SaveResult{}, fmt.Errorf("copy %s: write to %s: %w", from, routeName(dst), err)
+	}
+	return result, nil
+}
+
+// CheckSpaceFor checks free space on the backend path routes to, when that
+// backend implements SpaceChecker.
+func (s *RoutedStorage) CheckSpaceFor(ctx context.Context, path string, size int64) error {
+	if checker, ok := s.Route(path).Storage.(SpaceChecker); ok {
+		return checker.CheckSpace(ctx, size)
+	}
+	return nil
+}
+
+func routeName(route StorageRoute) string {
+	if route.Prefix == "" {
+		return "default storage"
+	}
+	return fmt.Sprintf("storage for %q", route.Prefix)
+}
@@ -0,0 +1,391 @@
+package stowry_test
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sagarc03/stowry"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// memStorage is an in-memory FileStorage with every optional extension.
+type memStorage struct {
+	mu        sync.Mutex
+	files     map[string][]byte
+	available int64 // bytes reported by CheckSpace; 0 means unlimited
+}
+
+func newMemStorage(paths ...string) *memStorage {
+	m := &memStorage{files: map[string][]byte{}}
+	for _, p := range paths {
+		m.files[p] = []byte(p)
+	}
+	return m
+}
+
+func (m *memStorage) paths() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return slices.Sorted(maps.Keys(m.files))
+}
+
+func (m *memStorage) Get(_ context.Context, path string) (io.ReadSeekCloser, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	content, ok := m.files[path]
+	if !ok {
+		return nil, stowry.ErrNotFound
+	}
+	return &mockReadSeekCloser{content: content}, nil
+}
+
+func (m *memStorage) Write(_ context.Context, path string, content io.Reader) (stowry.SaveResult, error) {
+	data, err := io.ReadAll(content)
+	if err != nil {
+		return stowry.SaveResult{}, err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.files[path] = data
+	sum := sha256.Sum256(data)
+	return stowry.SaveResult{BytesWritten: int64(len(data)), Etag: hex.EncodeToString(sum[:])}, nil
+}
+
+func (m *memStorage) Delete(_ context.Context, path string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.files[path]; !ok {
+		return stowry.ErrNotFound
+	}
+	delete(m.files, path)
+	return nil
+}
+
+func (m *memStorage) List(context.Context) ([]stowry.ObjectEntry, error) {
+	var entries []stowry.ObjectEntry
+	for _, p := range m.paths() {
+		entries = append(entries, stowry.ObjectEntry{Path: p, Size: int64(len(p)), ContentType: "text/plain"})
+	}
+	return entries, nil
+}
+
+func (m *memStorage) Walk(_ context.Context, fn func(stowry.StoredFile) error) error {
+	for _, p := range m.paths() {
+		if err := fn(stowry.StoredFile{Path: p, Size: int64(len(p))}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m *memStorage) Move(_ context.Context, from, to string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	content, ok := m.files[from]
+	if !ok {
+		return stowry.ErrNotFound
+	}
+	delete(m.files, from)
+	m.files[to] = content
+	return nil
+}
+
+func (m *memStorage) Copy(_ context.Context, from, to string) (stowry.SaveResult, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	content, ok := m.files[from]
+	if !ok {
+		return stowry.SaveResult{}, stowry.ErrNotFound
+	}
+	m.files[to] = content
+	sum := sha256.Sum256(content)
+	return stowry.SaveResult{BytesWritten: int64(len(content)), Etag: hex.EncodeToString(sum[:])}, nil
+}
+
+func (m *memStorage) CheckSpace(_ context.Context, size int64) error {
+	if m.available > 0 && size > m.available {
+		return fmt.Errorf("%w: %d bytes needed", stowry.ErrInsufficientStorage, size)
+	}
+	return nil
+}
+
+func TestRoutedStorage_Route(t *testing.T) {
+	def, archive, hot, arch, uploads := newMemStorage(), newMemStorage(), newMemStorage(), newMemStorage(), newMemStorage()
+	routed, err := stowry.NewRoutedStorage(def, []stowry.StorageRoute{
+		{Prefix: "archive/", Storage: archive},
+		{Prefix: "uploads/", Storage: uploads},
+		{Prefix: "arch", Storage: arch},
+		{Prefix: "archive/hot/", Storage: hot},
+	})
+	require.NoError(t, err)
+
+	tests := []struct {
+		path       string
+		wantPrefix string
+		want       stowry.FileStorage
+	}{
+		{path: "archive/2020/report.pdf", wantPrefix: "archive/", want: archive},
+		{path: "archive/hot/today.log", wantPrefix: "archive/hot/", want: hot},
+		{path: "archive/hotter.log", wantPrefix: "archive/", want: archive},
+		{path: "archived.txt", wantPrefix: "arch", want: arch},
+		{path: "arch", wantPrefix: "arch", want: arch},
+		{path: "uploads/a.png", wantPrefix: "uploads/", want: uploads},
+		{path: "uploads", wantPrefix: "", want: def},
+		{path: "readme.md", wantPrefix: "", want: def},
+		{path: "", wantPrefix: "", want: def},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			route := routed.Route(tt.path)
+			assert.Equal(t, tt.wantPrefix, route.Prefix)
+			assert.Same(t, tt.want, route.Storage)
+		})
+	}
+
+	t.Run("routes are listed in match order", func(t *testing.T) {
+		var prefixes []string
+		for _, route := range routed.Routes() {
+			prefixes = append(prefixes, route.Prefix)
+		}
+		assert.Equal(t, []string{"archive/hot/", "archive/", "uploads/", "arch", ""}, prefixes)
+	})
+}
+
+func TestNewRoutedStorage_Invalid(t *testing.T) {
+	tests := []struct {
+		name    string
+		def     stowry.FileStorage
+		routes  []stowry.StorageRoute
+		wantErr string
+	}{
+		{name: "no default", routes: nil, wantErr: "default storage is required"},
+		{name: "empty prefix", def: newMemStorage(), routes: []stowry.StorageRoute{{Storage: newMemStorage()}}, wantErr: "route 1: prefix is required"},
+		{name: "leading slash", def: newMemStorage(), routes: []stowry.StorageRoute{{Prefix: "/archive/", Storage: newMemStorage()}}, wantErr: "must not start with /"},
+		{
+			name:    "duplicate prefix",
+			def:     newMemStorage(),
+			routes:  []stowry.StorageRoute{{Prefix: "a/", Storage: newMemStorage()}, {Prefix: "a/", Storage: newMemStorage()}},
+			wantErr: "route 2 (a/): duplicate prefix",
+		},
+		{name: "no storage", def: newMemStorage(), routes: []stowry.StorageRoute{{Prefix: "a/"}}, wantErr: "storage is required"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := stowry.NewRoutedStorage(tt.def, tt.routes)
+			require.ErrorIs(t, err, stowry.ErrInvalidInput)
+			assert.Contains(t, err.Error(), tt.wantErr)
+		})
+	}
+}
+
+func TestRoutedStorage_Operations(t *testing.T) {
+	ctx := context.Background()
+
+	newRouted := func(t *testing.T) (*stowry.RoutedStorage, *memStorage, *memStorage) {
+		t.Helper()
+		// "archive/stray.txt" on the default backend is unreachable
+		def := newMemStorage("index.html", "archive/stray.txt")
+		archive := newMemStorage("archive/2020.tar", "misplaced.txt")
+		routed, err := stowry.NewRoutedStorage(def, []stowry.StorageRoute{{Prefix: "archive/", Storage: archive}})
+		require.NoError(t, err)
+		return routed, def, archive
+	}
+
+	t.Run("write, get and delete use the routed backend", func(t *testing.T) {
+		routed, def, archive := newRouted(t)
+
+		_, err := routed.Write(ctx, "archive/2021.tar", bytes.NewReader([]byte("x")))
+		require.NoError(t, err)
+		assert.Contains(t, archive.paths(), "archive/2021.tar")
+		assert.NotContains(t, def.paths(), "archive/2021.tar")
+
+		_, err = routed.Get(ctx, "archive/stray.txt")
+		assert.ErrorIs(t, err, stowry.ErrNotFound)
+
+		require.NoError(t, routed.Delete(ctx, "index.html"))
+		assert.NotContains(t, def.paths(), "index.html")
+		assert.ErrorIs(t, routed.Delete(ctx, "misplaced.txt"), stowry.ErrNotFound)
+	})
+
+	t.Run("list merges backends and skips unreachable files", func(t *testing.T) {
+		routed, _, _ := newRouted(t)
+
+		entries, err := routed.List(ctx)
+		require.NoError(t, err)
+
+		var paths, backends []string
+		for _, e := range entries {
+			paths = append(paths, e.Path)
+			backends = append(backends, e.StorageBackend)
+		}
+		assert.Equal(t, []string{"archive/2020.tar", "index.html"}, paths)
+		assert.Equal(t, []string{"archive/", ""}, backends)
+	})
+
+	t.Run("backends are named by route prefix", func(t *testing.T) {
+		routed, def, archive := newRouted(t)
+
+		assert.Equal(t, "archive/", routed.Backend("archive/2020.tar"))
+		assert.Empty(t, routed.Backend("index.html"))
+
+		backend, ok := routed.BackendStorage("archive/")
+		assert.True(t, ok)
+		assert.Same(t, archive, backend)
+		backend, ok = routed.BackendStorage("")
+		assert.True(t, ok)
+		assert.Same(t, def, backend)
+		_, ok = routed.BackendStorage("media/")
+		assert.False(t, ok)
+	})
+
+	t.Run("walk covers every backend", func(t *testing.T) {
+		routed, _, _ := newRouted(t)
+
+		var paths []string
+		err := routed.Walk(ctx, func(f stowry.StoredFile) error {
+			paths = append(paths, f.Path)
+			return nil
+		})
+		require.NoError(t, err)
+		sort.Strings(paths)
+		assert.Equal(t, []string{"archive/2020.tar", "index.html"}, paths)
+	})
+
+	t.Run("walk requires walking backends", func(t *testing.T) {
+		routed, err := stowry.NewRoutedStorage(newMemStorage(), []stowry.StorageRoute{{Prefix: "a/", Storage: new(SpyFileStorage)}})
+		require.NoError(t, err)
+
+		err = routed.Walk(ctx, func(stowry.StoredFile) error { return nil })
+		assert.ErrorIs(t, err, stowry.ErrInvalidInput)
+	})
+
+	t.Run("move within and across backends", func(t *testing.T) {
+		routed, def, archive := newRouted(t)
+
+		require.NoError(t, routed.Move(ctx, "archive/2020.tar", "archive/old/2020.tar"))
+		assert.Equal(t, []string{"archive/old/2020.tar", "misplaced.txt"}, archive.paths())
+
+		require.NoError(t, routed.Move(ctx, "index.html", "archive/index.html"))
+		assert.Equal(t, []string{"archive/stray.txt"}, def.paths())
+		assert.Contains(t, archive.paths(), "archive/index.html")
+
+		assert.ErrorIs(t, routed.Move(ctx, "missing.txt", "archive/missing.txt"), stowry.ErrNotFound)
+	})
+
+	t.Run("copy within and across backends", func(t *testing.T) {
+		routed, def, archive := newRouted(t)
+
+		_, err := routed.Copy(ctx, "archive/2020.tar", "archive/copy.tar")
+		require.NoError(t, err)
+		assert.Equal(t, []string{"archive/2020.tar", "archive/copy.tar", "misplaced.txt"}, archive.paths())
+
+		result, err := routed.Copy(ctx, "index.html", "archive/index.html")
+		require.NoError(t, err)
+		assert.Equal(t, int64(len("index.html")), result.BytesWritten)
+		assert.Contains(t, def.paths(), "index.html")
+		assert.Contains(t, archive.paths(), "archive/index.html")
+
+		_, err = routed.Copy(ctx, "missing.txt", "archive/missing.txt")
+		assert.ErrorIs(t, err, stowry.ErrNotFound)
+	})
+
+	t.Run("space is checked on the routed backend", func(t *testing.T) {
+		routed, _, archive := newRouted(t)
+		archive.available = 10
+
+		assert.NoError(t, routed.CheckSpaceFor(ctx, "big.iso", 100))
+		assert.ErrorIs(t, routed.CheckSpaceFor(ctx, "archive/big.iso", 100), stowry.ErrInsufficientStorage)
+	})
+}
+
+func TestStowryService_RoutedStorage(t *testing.T) {
+	ctx := context.Background()
+	def := newMemStorage("index.html")
+	archive := newMemStorage("archive/2020.tar")
+	archive.available = 10
+	routed, err := stowry.NewRoutedStorage(def, []stowry.StorageRoute{{Prefix: "archive/", Storage: archive}})
+	require.NoError(t, err)
+
+	repo := new(SpyMetaDataRepo)
+	service, err := stowry.NewStowryService(repo, routed, stowry.ServiceConfig{Mode: stowry.ModeStore})
+	require.NoError(t, err)
+
+	t.Run("populate reads every backend", func(t *testing.T) {
+		repo.On("UpsertBatch", ctx, mock.MatchedBy(func(entries []stowry.ObjectEntry) bool {
+			return len(entries) == 2 && entries[0].Path == "archive/2020.tar" && entries[1].Path == "index.html"
+		})).Return([]stowry.MetaData{}, nil).Once()
+
+		require.NoError(t, service.Populate(ctx))
+		repo.AssertExpectations(t)
+	})
+
+	t.Run("orphans are collected from every backend", func(t *testing.T) {
+		repo.On("ExistingPaths", ctx, mock.Anything).Return([]string{}, nil).Once()
+
+		report, err := service.CollectOrphans(ctx, stowry.OrphanOptions{MinAge: -time.Hour})
+		require.NoError(t, err)
+		assert.ElementsMatch(t, []string{"archive/2020.tar", "index.html"}, report.Paths)
+	})
+
+	t.Run("create records the backend", func(t *testing.T) {
+		repo.On("Upsert", ctx, mock.MatchedBy(func(entry stowry.ObjectEntry) bool {
+			return entry.Path == "archive/2021.tar" && entry.StorageBackend == "archive/"
+		})).Return(stowry.MetaData{Path: "archive/2021.tar"}, nil, nil).Once()
+
+		_, err := service.Create(ctx, stowry.CreateObject{Path: "archive/2021.tar", ContentType: "application/x-tar", Size: 1}, bytes.NewReader([]byte("x")))
+		require.NoError(t, err)
+		repo.AssertExpectations(t)
+	})
+
+	t.Run("verify checks the recorded backend", func(t *testing.T) {
+		// Written to archive/ under a route that has since been removed
+		archive.files["reports/q3.pdf"] = []byte("q3")
+		repo.On("List", ctx, mock.Anything).Return(stowry.ListResult{Items: []stowry.MetaData{
+			{Path: "reports/q3.pdf", FileSizeBytes: 2, StorageBackend: "archive/"},
+			{Path: "index.html", FileSizeBytes: 10},
+		}}, nil).Once()
+
+		report, err := service.Verify(ctx, stowry.VerifyOptions{})
+		require.NoError(t, err)
+		assert.Equal(t, 2, report.Checked)
+		assert.Empty(t, report.Missing)
+	})
+
+	t.Run("tombstone deletes from the recorded backend", func(t *testing.T) {
+		// Written to archive/ under a route that has since been removed, and
+		// a file of the same path on the backend the path routes to now
+		archive.files["reports/q2.pdf"] = []byte("q2")
+		def.files["reports/q2.pdf"] = []byte("q2")
+		id := uuid.New()
+		repo.On("ListPendingCleanup", ctx, mock.Anything).Return(stowry.ListResult{Items: []stowry.MetaData{
+			{ID: id, Path: "reports/q2.pdf", FileSizeBytes: 2, StorageBackend: "archive/"},
+		}}, nil).Once()
+		repo.On("MarkCleanedUp", ctx, id).Return(nil).Once()
+
+		report, err := service.TombstoneWithReport(ctx, stowry.TombstoneOptions{Query: stowry.ListQuery{Limit: 10}})
+		require.NoError(t, err)
+		assert.Equal(t, 1, report.Cleaned)
+		assert.NotContains(t, archive.paths(), "reports/q2.pdf")
+		assert.Contains(t, def.paths(), "reports/q2.pdf")
+		repo.AssertExpectations(t)
+	})
+
+	t.Run("create checks space on the routed backend", func(t *testing.T) {
+		_, err := service.Create(ctx, stowry.CreateObject{Path: "archive/big.tar", ContentType: "application/x-tar", Size: 100}, bytes.NewReader(nil))
+		assert.ErrorIs(t, err, stowry.ErrInsufficientStorage)
+		assert.NotContains(t, archive.paths(), "archive/big.tar")
+	})
+}
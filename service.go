@@ -2,14 +2,18 @@ package stowry
 
 import (
 	"context"
+	"crypto/sha256"
 	"errors"
 	"fmt"
 	"io"
-	"path/filepath"
+	"log/slog"
+	"slices"
 	"strings"
 	"time"
 
 	"github.com/google/uuid"
+
+	"github.com/sagarc03/stowry/progress"
 )
 
 // MetaDataRepo defines the interface for managing object metadata persistence.
@@ -29,19 +33,82 @@ type MetaDataRepo interface {
 	//   - error: ErrNotFound if path doesn't exist, or other database errors
 	Get(ctx context.Context, path string) (MetaData, error)
 
+	// GetDeleted retrieves the soft-deleted metadata entry for a path, with
+	// DeletedAt set. An active object at the path is not returned.
+	//
+	// Parameters:
+	//   - ctx: Context for cancellation and timeout
+	//   - path: The object path to look up
+	//
+	// Returns:
+	//   - MetaData: The soft-deleted entry, not yet cleaned up
+	//   - error: ErrNotFound if the path has no soft-deleted entry, ErrGone if
+	//     the entry was already cleaned up, or other database errors
+	GetDeleted(ctx context.Context, path string) (MetaData, error)
+
+	// GetMany retrieves metadata for the active objects among paths.
+	//
+	// Parameters:
+	//   - ctx: Context for cancellation and timeout
+	//   - paths: Paths to look up; implementations query them in batches
+	//
+	// Returns:
+	//   - []MetaData: One entry per path that has an active object, in no
+	//     particular order; other paths are left out
+	//   - error: Any database error
+	GetMany(ctx context.Context, paths []string) ([]MetaData, error)
+
+	// GetByETag retrieves metadata for an active object whose content has the
+	// given ETag. When several objects share the content, the most recently
+	// updated one is returned, and of those the first by path.
+	//
+	// Parameters:
+	//   - ctx: Context for cancellation and timeout
+	//   - etag: The ETag to look up, without quotes
+	//
+	// Returns:
+	//   - MetaData: The metadata entry picked
+	//   - error: ErrNotFound if no active object has the ETag, or other
+	//     database errors
+	GetByETag(ctx context.Context, etag string) (MetaData, error)
+
 	// Upsert creates or updates metadata for an object.
 	// If an entry with the same path exists, it updates the existing entry.
 	// If no entry exists, it creates a new one.
 	//
+	// The active entry being replaced is read in the same transaction as the
+	// write, so it is exactly the entry the upsert overwrote even under
+	// concurrent writers. Its ETag is recorded as the PreviousETag of the
+	// change.
+	//
 	// Parameters:
 	//   - ctx: Context for cancellation and timeout
 	//   - entry: ObjectEntry containing path, size, ETag, and content type
 	//
 	// Returns:
 	//   - MetaData: The created or updated metadata entry with ID and timestamps
-	//   - bool: true if a new entry was created, false if existing entry was updated
+	//   - *MetaData: The active entry the upsert replaced; nil if the path had
+	//     no active object, including when a soft-deleted entry was restored
+	//   - error: Any database or validation error
+	Upsert(ctx context.Context, entry ObjectEntry) (MetaData, *MetaData, error)
+
+	// UpsertBatch creates or updates metadata for many objects. The result is
+	// identical to calling Upsert for each entry in order: existing paths keep
+	// their ID and created_at, soft-deleted paths are restored, and when a path
+	// appears more than once the last entry wins.
+	//
+	// Implementations split large batches into chunks and write each chunk
+	// atomically. If an error is returned, earlier chunks may already be
+	// committed; re-running the batch is safe.
+	//
+	// Parameters:
+	//   - ctx: Context for cancellation and timeout
+	//   - entries: ObjectEntries to create or update
+	//
+	// Returns:
+	//   - []MetaData: One entry per input entry, in input order
 	//   - error: Any database or validation error
-	Upsert(ctx context.Context, entry ObjectEntry) (MetaData, bool, error)
+	UpsertBatch(ctx context.Context, entries []ObjectEntry) ([]MetaData, error)
 
 	// Delete removes metadata for a specific object by its path.
 	//
@@ -53,6 +120,36 @@ type MetaDataRepo interface {
 	//   - error: ErrNotFound if path doesn't exist, or other database errors
 	Delete(ctx context.Context, path string) error
 
+	// Restore brings back the soft-deleted entry at path by clearing its
+	// DeletedAt, as long as it was not cleaned up or quarantined. The restore
+	// is recorded as a create in the change feed.
+	//
+	// Parameters:
+	//   - ctx: Context for cancellation and timeout
+	//   - path: The object path to restore
+	//
+	// Returns:
+	//   - MetaData: The restored entry
+	//   - error: ErrNotFound if path has no restorable entry, including one
+	//     already cleaned up, ErrAlreadyExists if path holds an active
+	//     object, or other database errors
+	Restore(ctx context.Context, path string) (MetaData, error)
+
+	// Quarantine soft-deletes the object at path, like Delete, and flags it as
+	// quarantined so ListPendingCleanup skips it and its file is kept for
+	// inspection. Only an object whose ETag is still etag is quarantined, so
+	// a replacement written meanwhile is left alone. Upserting the path again
+	// clears the flag.
+	//
+	// Parameters:
+	//   - ctx: Context for cancellation and timeout
+	//   - path: The object path to quarantine
+	//   - etag: The ETag the object must still have
+	//
+	// Returns:
+	//   - error: ErrNotFound if no active object at path has etag, or other database errors
+	Quarantine(ctx context.Context, path, etag string) error
+
 	// List retrieves a paginated list of metadata entries matching the query criteria.
 	//
 	// Parameters:
@@ -65,11 +162,13 @@ type MetaDataRepo interface {
 	List(ctx context.Context, q ListQuery) (ListResult, error)
 
 	// ListPendingCleanup retrieves a paginated list of soft-deleted metadata entries
-	// that have not yet been cleaned up (deleted_at IS NOT NULL AND cleaned_up_at IS NULL).
+	// that have not yet been cleaned up (deleted_at IS NOT NULL AND cleaned_up_at IS NULL),
+	// leaving out quarantined entries.
 	//
 	// Parameters:
 	//   - ctx: Context for cancellation and timeout
-	//   - q: ListQuery with optional path prefix filter, limit, and cursor for pagination
+	//   - q: ListQuery with optional path prefix filter, limit, and cursor for
+	//     pagination; a non-zero DeletedBefore selects entries deleted before it
 	//
 	// Returns:
 	//   - ListResult: Contains matching metadata items and cursor for next page
@@ -86,6 +185,59 @@ type MetaDataRepo interface {
 	// Returns:
 	//   - error: ErrNotFound if entry doesn't exist or isn't pending cleanup, or other database errors
 	MarkCleanedUp(ctx context.Context, id uuid.UUID) error
+
+	// RecordAccess adds batched download counts to active objects and advances
+	// their last accessed time. Paths that no longer exist are skipped.
+	//
+	// Parameters:
+	//   - ctx: Context for cancellation and timeout
+	//   - records: Per-path download counts accumulated since the last flush
+	//
+	// Returns:
+	//   - error: Any database error
+	RecordAccess(ctx context.Context, records []AccessRecord) error
+
+	// ExistingPaths reports which of the given paths still own their file:
+	// paths with an active row or a soft-deleted row not yet cleaned up.
+	// Rows already cleaned up do not count.
+	//
+	// Parameters:
+	//   - ctx: Context for cancellation and timeout
+	//   - paths: Paths to look up; implementations query them in batches
+	//
+	// Returns:
+	//   - []string: The subset of paths that have such a row, in no particular order
+	//   - error: Any database error
+	ExistingPaths(ctx context.Context, paths []string) ([]string, error)
+
+	// CountPrefix counts active (not soft-deleted) objects whose path starts
+	// with prefix. An empty prefix counts every active object.
+	//
+	// Parameters:
+	//   - ctx: Context for cancellation and timeout
+	//   - prefix: Path prefix, matched literally
+	//
+	// Returns:
+	//   - int64: Number of matching active objects
+	//   - error: Any database error
+	CountPrefix(ctx context.Context, prefix string) (int64, error)
+}
+
+// DefaultCleanupRetryDelay is how long TombstoneWithReport defers an item
+// whose cleanup failed when TombstoneOptions.RetryDelay is 0.
+const DefaultCleanupRetryDelay = time.Hour
+
+// CleanupDeferrer is an optional MetaDataRepo extension that postpones the
+// cleanup of a soft-deleted entry after it failed, so a path that keeps
+// failing is not retried on every run. ListPendingCleanup still returns a
+// deferred entry, with CleanupRetryAt set.
+type CleanupDeferrer interface {
+	// DeferCleanup sets the CleanupRetryAt of the entry pending cleanup with
+	// id to until.
+	//
+	// Returns:
+	//   - error: ErrNotFound if entry doesn't exist or isn't pending cleanup, or other database errors
+	DeferCleanup(ctx context.Context, id uuid.UUID, until time.Time) error
 }
 
 // FileStorage defines the interface for physical file storage operations.
@@ -168,19 +320,125 @@ type FileStorage interface {
 	List(ctx context.Context) ([]ObjectEntry, error)
 }
 
+// SpaceChecker is an optional FileStorage extension that reports whether an
+// object of a known size fits before any content is read.
+type SpaceChecker interface {
+	// CheckSpace returns an error wrapping ErrInsufficientStorage when fewer
+	// than size bytes are available. Implementations that cannot determine free
+	// space should return nil.
+	CheckSpace(ctx context.Context, size int64) error
+}
+
+// PathSpaceChecker is an optional FileStorage extension for storage whose
+// free space depends on where an object goes, such as RoutedStorage. The
+// service prefers it over SpaceChecker.
+type PathSpaceChecker interface {
+	// CheckSpaceFor returns an error wrapping ErrInsufficientStorage when the
+	// storage that would hold path has fewer than size bytes available.
+	CheckSpaceFor(ctx context.Context, path string, size int64) error
+}
+
+// StorageWalker is an optional FileStorage extension that streams stored
+// files without reading their content. CollectOrphans requires it.
+type StorageWalker interface {
+	// Walk calls fn for every stored file. Returning an error from fn stops
+	// the walk and returns that error.
+	Walk(ctx context.Context, fn func(StoredFile) error) error
+}
+
+// StorageMover is an optional FileStorage extension that renames a stored
+// file, creating parent directories as needed. CollectOrphans uses it to
+// quarantine orphans.
+type StorageMover interface {
+	// Move renames from to to, replacing any file at to. Returns ErrNotFound
+	// if from does not exist.
+	Move(ctx context.Context, from, to string) error
+}
+
+// StorageCopier is an optional FileStorage extension that copies a stored
+// file without the content passing through the service, such as by linking
+// it. Copy uses it when the storage implements it.
+type StorageCopier interface {
+	// Copy copies the file at from to to, replacing any file at to, and
+	// reports the size and ETag of the copy as Write does. Returns
+	// ErrNotFound if from does not exist.
+	Copy(ctx context.Context, from, to string) (SaveResult, error)
+}
+
+// StorageStater is an optional FileStorage extension that describes one
+// stored file without reading its content. TombstoneWithReport uses it to
+// check that a file still matches the deleted object before removing it.
+type StorageStater interface {
+	// Stat returns the size and modification time of the file at path.
+	// Returns ErrNotFound if there is no file at path.
+	Stat(ctx context.Context, path string) (StoredFile, error)
+}
+
+// StatFile describes the file at path with the StorageStater of storage, or,
+// when storage does not implement it, by opening the file and seeking to its
+// end. ModTime is zero in the second case.
+func StatFile(ctx context.Context, storage FileStorage, path string) (StoredFile, error) {
+	if stater, ok := storage.(StorageStater); ok {
+		return stater.Stat(ctx, path)
+	}
+
+	content, err := storage.Get(ctx, path)
+	if err != nil {
+		return StoredFile{}, err
+	}
+	defer func() { _ = content.Close() }()
+
+	size, err := content.Seek(0, io.SeekEnd)
+	if err != nil {
+		return StoredFile{}, fmt.Errorf("stat %s: %w", path, err)
+	}
+	return StoredFile{Path: path, Size: size}, nil
+}
+
 type StowryService struct {
 	repo           MetaDataRepo
 	storage        FileStorage
 	mode           ServerMode
 	cleanupTimeout time.Duration
+	objectLimiter  *ObjectLimiter
+	contentTypes   *ContentTypePolicy
+	pathLimits     PathLimits
+	readVerify     ReadVerifyConfig
+	batchSize      int
+	changes        changeSignal
+	index          indexCache
+	indexFiles     []string
+	writeIntents   bool
+	repoCheckSize  int64
+	health         repoHealth
+	metrics        ServiceMetrics
 }
 
 // ServiceConfig holds configuration options for StowryService.
 type ServiceConfig struct {
 	Mode           ServerMode
-	CleanupTimeout time.Duration // Timeout for cleanup operations (default: 30s)
+	CleanupTimeout time.Duration      // Timeout for cleanup operations (default: 30s)
+	ObjectLimiter  *ObjectLimiter     // Enforces per-prefix object counts on create. nil disables limits.
+	ContentTypes   *ContentTypePolicy // Restricts upload content types per prefix. nil allows all.
+	BatchSize      int                // Entries per UpsertBatch call in Populate (default: 500)
+	PathLimits     PathLimits         // Bounds object path length and depth. Zero fields use the defaults.
+	ReadVerify     ReadVerifyConfig   // Checks downloads against their ETag. The zero value checks none.
+	IndexFiles     []string           // Directory index documents static mode tries, in order (default: SPAIndexPath)
+	SPAIndex       string             // Object unknown routes fall back to in SPA mode (default: SPAIndexPath)
+	WriteIntents   bool               // Brackets Create with a write intent; the repository must be an IntentRepo
+	// RepoCheckSize is the upload size from which Create pings the
+	// repository before storing content (default: DefaultRepoCheckSize).
+	// Negative disables the check.
+	RepoCheckSize int64
+	// Metrics receives storage call durations and tombstone outcomes. nil
+	// records nothing.
+	Metrics ServiceMetrics
 }
 
+// DefaultBatchSize is the number of entries Populate hands to a single
+// UpsertBatch call when ServiceConfig.BatchSize is not set.
+const DefaultBatchSize = 500
+
 func NewStowryService(repo MetaDataRepo, storage FileStorage, cfg ServiceConfig) (*StowryService, error) {
 	if !cfg.Mode.IsValid() {
 		return nil, fmt.Errorf("new stowry service: invalid mode: %s", cfg.Mode)
@@ -189,11 +447,52 @@ func NewStowryService(repo MetaDataRepo, storage FileStorage, cfg ServiceConfig)
 	if cleanupTimeout <= 0 {
 		cleanupTimeout = 30 * time.Second
 	}
+	batchSize := cfg.BatchSize
+	if batchSize <= 0 {
+		batchSize = DefaultBatchSize
+	}
+	if err := cfg.PathLimits.Validate(); err != nil {
+		return nil, fmt.Errorf("new stowry service: %w", err)
+	}
+	if err := cfg.ReadVerify.Validate(); err != nil {
+		return nil, fmt.Errorf("new stowry service: %w", err)
+	}
+	indexFiles := cfg.IndexFiles
+	if len(indexFiles) == 0 {
+		indexFiles = []string{SPAIndexPath}
+	}
+	if err := ValidateIndexFiles(indexFiles); err != nil {
+		return nil, fmt.Errorf("new stowry service: %w", err)
+	}
+	repoCheckSize := cfg.RepoCheckSize
+	if repoCheckSize == 0 {
+		repoCheckSize = DefaultRepoCheckSize
+	}
+	spaIndex := cfg.SPAIndex
+	if spaIndex == "" {
+		spaIndex = SPAIndexPath
+	}
+	if err := ValidateIndexFile(spaIndex); err != nil {
+		return nil, fmt.Errorf("new stowry service: spa index: %w", err)
+	}
+	if _, ok := repo.(IntentRepo); cfg.WriteIntents && !ok {
+		return nil, fmt.Errorf("new stowry service: write intents: metadata repository has no intent log: %w", ErrNotSupported)
+	}
 	return &StowryService{
 		repo:           repo,
 		storage:        storage,
 		mode:           cfg.Mode,
 		cleanupTimeout: cleanupTimeout,
+		objectLimiter:  cfg.ObjectLimiter,
+		contentTypes:   cfg.ContentTypes,
+		pathLimits:     cfg.PathLimits.WithDefaults(),
+		readVerify:     cfg.ReadVerify.withDefaults(),
+		batchSize:      batchSize,
+		index:          indexCache{path: spaIndex},
+		indexFiles:     slices.Clone(indexFiles),
+		writeIntents:   cfg.WriteIntents,
+		repoCheckSize:  repoCheckSize,
+		metrics:        cfg.Metrics,
 	}, nil
 }
 
@@ -201,8 +500,8 @@ func NewStowryService(repo MetaDataRepo, storage FileStorage, cfg ServiceConfig)
 // It lists all files in storage and creates or updates their corresponding metadata entries.
 //
 // This method is typically used during initialization or recovery to ensure the metadata
-// repository is in sync with actual files in storage. It processes all files sequentially
-// and stops at the first error encountered.
+// repository is in sync with actual files in storage. It upserts files in batches of
+// ServiceConfig.BatchSize and stops at the first error encountered.
 //
 // Returns an error if:
 //   - Storage listing fails
@@ -212,21 +511,42 @@ func NewStowryService(repo MetaDataRepo, storage FileStorage, cfg ServiceConfig)
 // Note: This operation is not atomic. If it fails partway through, some files may have
 // been processed while others remain unprocessed.
 func (s *StowryService) Populate(ctx context.Context) error {
+	return s.PopulateWithOptions(ctx, PopulateOptions{})
+}
+
+// PopulateWithOptions is Populate with progress reporting. See Populate.
+func (s *StowryService) PopulateWithOptions(ctx context.Context, opts PopulateOptions) error {
 	if err := ctx.Err(); err != nil {
 		return fmt.Errorf("populate: %w", err)
 	}
 
-	files, listErr := s.storage.List(ctx)
+	files, listErr := s.storageList(ctx)
 	if listErr != nil {
 		return fmt.Errorf("populate: %w", listErr)
 	}
 
-	for _, file := range files {
-		_, _, upsertErr := s.repo.Upsert(ctx, file)
-		if upsertErr != nil {
-			return fmt.Errorf("populate '%s': %w", file.Path, upsertErr)
+	// Staged uploads are not objects until their stage is committed, and the
+	// instance file and quarantined orphans are never one
+	files = slices.DeleteFunc(files, func(f ObjectEntry) bool {
+		return strings.HasPrefix(f.Path, StagePrefix) || strings.HasPrefix(f.Path, DefaultQuarantinePrefix) || f.Path == InstanceFile
+	})
+
+	tracker := progress.New("populate", opts.Progress)
+	defer tracker.Finish()
+	tracker.SetTotal(int64(len(files)))
+
+	for batch := range slices.Chunk(files, s.batchSize) {
+		if err := ctx.Err(); err != nil {
+			return fmt.Errorf("populate: %w", err)
+		}
+		if _, upsertErr := s.repo.UpsertBatch(ctx, batch); upsertErr != nil {
+			return fmt.Errorf("populate: %w", upsertErr)
+		}
+		for _, f := range batch {
+			tracker.Add(f.Path, f.Size)
 		}
 	}
+	s.index.invalidate(s.index.path)
 
 	return nil
 }
@@ -239,10 +559,26 @@ func (s *StowryService) Populate(ctx context.Context) error {
 // The method performs the following steps:
 //  1. Validates context is not cancelled
 //  2. Validates input parameters (path, content type)
-//  3. Validates path using IsValidPath (prevents path traversal attacks)
-//  4. Writes content to storage and computes ETag
-//  5. Creates metadata entry
-//  6. On metadata failure, automatically deletes the stored file
+//  3. Validates path using IsValidPath (prevents path traversal attacks) and
+//     checks it against the path limits
+//  4. For uploads of at least ServiceConfig.RepoCheckSize bytes, checks the
+//     repository answers a ping, so a large upload is not stored only to fail
+//     at step 7; checks per-prefix object limits, when configured
+//  5. Normalizes the content type and checks it against the content type rules
+//  6. Writes content to storage and computes ETag. Encoded content (e.g. gzip)
+//     is stored as uploaded, so the ETag is the SHA-256 of the encoded bytes;
+//     it is decoded alongside to check it and record the decoded size.
+//  7. Creates metadata entry
+//  8. On metadata failure, automatically deletes the stored file
+//
+// With ServiceConfig.WriteIntents set and an IntentRepo, a write intent is
+// recorded before step 6 and cleared after step 7, so a write interrupted by
+// a crash between the two is reconciled by RecoverIntents.
+//
+// With obj.ID set, the object gets that ID instead of one generated by the
+// repository. Overwriting a live object requires its ID, checked before the
+// content is written; an ID held by another path fails when the metadata is
+// committed, and the stored file is deleted.
 //
 // Parameters:
 //   - ctx: Context for cancellation and timeout. If cancelled during storage write,
@@ -251,105 +587,521 @@ func (s *StowryService) Populate(ctx context.Context) error {
 //   - content: io.Reader providing the object data to store
 //
 // Returns:
-//   - MetaData: The created metadata entry with ID, timestamps, and computed ETag
+//   - CreateResult: The created metadata entry with ID, timestamps, and computed
+//     ETag, and the metadata of the object it overwrote, if any
 //   - error: Any error encountered, including validation, storage, or metadata errors
 //
 // Error types returned:
 //   - ErrInvalidInput: Empty path or content type
 //   - ErrInvalidInput: Path fails validation (contains .., //, invalid chars, etc.)
+//   - ErrPathLimitExceeded: Path is longer or deeper than the path limits (*PathLimitError)
+//   - ErrObjectLimitExceeded: A reject-action ObjectLimit is full (*ObjectLimitError)
+//   - ErrInvalidContentType: Content type is not a valid media type
+//   - ErrContentTypeNotAllowed: A ContentTypeRule forbids the content type (*ContentTypeError)
+//   - ErrUnsupportedContentEncoding: Content encoding is not gzip
+//   - ErrInvalidEncodedContent: Content does not decode with its content encoding
+//   - ErrIDConflict: obj.ID differs from the ID of the object at the path, or
+//     belongs to another path
+//   - ErrUnavailable: The repository failed its ping; nothing was stored
 //   - context.Canceled or context.DeadlineExceeded: Context was cancelled
 //   - Wrapped storage errors: Issues writing to storage
 //   - Wrapped metadata errors: Issues creating metadata entry
+//   - ErrConflict: A concurrent Create of the same new path won the insert race.
+//     The winner's metadata is returned along with the error and the stored file
+//     is left in place. Previous is nil.
 //
-// Concurrency safety: Safe for concurrent calls with different paths.
+// Concurrency safety: Safe for concurrent calls. Concurrent first writes to the
+// same path resolve to one winner; the others receive ErrConflict.
 // Data consistency: If metadata creation fails, the stored file is automatically deleted
 // using a background context with the configured cleanup timeout to ensure cleanup completes
 // even if the original context is cancelled.
-func (s *StowryService) Create(ctx context.Context, obj CreateObject, content io.Reader) (MetaData, error) {
+func (s *StowryService) Create(ctx context.Context, obj CreateObject, content io.Reader) (CreateResult, error) {
 	// Early context check - fail fast before expensive operations
 	if err := ctx.Err(); err != nil {
-		return MetaData{}, fmt.Errorf("create object: %w", err)
+		return CreateResult{}, fmt.Errorf("create object: %w", err)
 	}
 
-	// Input validation
-	if obj.Path == "" {
-		return MetaData{}, fmt.Errorf("create object: %w: path cannot be empty", ErrInvalidInput)
+	if err := s.validateCreateObject(obj); err != nil {
+		return CreateResult{}, err
 	}
 
-	if obj.ContentType == "" {
-		return MetaData{}, fmt.Errorf("create object: %w: content type cannot be empty", ErrInvalidInput)
+	if err := s.checkRepo(ctx, obj.Size); err != nil {
+		return CreateResult{}, fmt.Errorf("create object %s: %w", obj.Path, err)
 	}
 
-	// Path validation using IsValidPath
-	if !IsValidPath(obj.Path) {
-		return MetaData{}, fmt.Errorf("create object %s: %w", obj.Path, ErrInvalidInput)
+	if s.objectLimiter != nil {
+		if err := s.objectLimiter.admit(ctx, []string{obj.Path}); err != nil {
+			return CreateResult{}, fmt.Errorf("create object %s: %w", obj.Path, err)
+		}
 	}
 
-	// Write to storage
-	saveResult, writeErr := s.storage.Write(ctx, obj.Path, content)
-	if writeErr != nil {
-		return MetaData{}, fmt.Errorf("create object %s: write failed: %w", obj.Path, writeErr)
+	return s.createObject(ctx, obj, content)
+}
+
+// commitObject records metadata for a file writeObject stored, deleting the
+// file again if that fails.
+func (s *StowryService) commitObject(ctx context.Context, oe ObjectEntry) (CreateResult, error) {
+	metaData, previous, upsertErr := s.repo.Upsert(ctx, oe)
+	if upsertErr != nil {
+		// A concurrent writer won the insert race. Both wrote to the same final
+		// path, so deleting the file here would delete the winner's content.
+		if errors.Is(upsertErr, ErrConflict) {
+			return s.conflictWinner(ctx, oe.Path, upsertErr)
+		}
+
+		// Use background context for cleanup since original context may be cancelled
+		cleanupCtx, cancel := context.WithTimeout(context.Background(), s.cleanupTimeout)
+		defer cancel()
+
+		if delErr := s.storageDelete(cleanupCtx, oe.Path); delErr != nil {
+			return CreateResult{}, fmt.Errorf("create object %s: metadata upsert failed (%w) and cleanup failed: %w", oe.Path, upsertErr, delErr)
+		}
+		return CreateResult{}, fmt.Errorf("create object %s: metadata upsert failed: %w", oe.Path, upsertErr)
 	}
 
-	// Create metadata entry
-	oe := ObjectEntry{
-		Path:        obj.Path,
-		Size:        saveResult.BytesWritten,
-		ETag:        saveResult.Etag,
-		ContentType: obj.ContentType,
+	if previous == nil && s.objectLimiter != nil {
+		s.objectLimiter.adjust(oe.Path, 1)
+	}
+	s.index.invalidate(oe.Path)
+	s.changes.notify()
+
+	return CreateResult{MetaData: metaData, Previous: previous}, nil
+}
+
+// conflictWinner re-reads the metadata that won an insert race for path.
+func (s *StowryService) conflictWinner(ctx context.Context, path string, conflictErr error) (CreateResult, error) {
+	winner, getErr := s.repo.Get(ctx, path)
+	if getErr != nil {
+		return CreateResult{}, fmt.Errorf("create object %s: %w (reading winner failed: %w)", path, conflictErr, getErr)
+	}
+	return CreateResult{MetaData: winner}, fmt.Errorf("create object %s: %w", path, conflictErr)
+}
+
+// CreateMany stores several objects and registers their metadata with a single
+// UpsertBatch call, which is much faster than Create for bulk imports. Every
+// object is validated and written to storage first. If any write or the batch
+// upsert fails, the objects new to this call are deleted again. Objects that
+// existed before are never deleted; on storage that implements StorageMover
+// they also keep their previous content.
+//
+// Parameters:
+//   - ctx: Context for cancellation and timeout
+//   - objs: Objects to create, each with path and content type
+//   - open: Opens the content for an object. Each reader is closed after writing.
+//
+// Returns:
+//   - []MetaData: The created metadata entries, in the order of objs
+//   - error: Any validation, storage, or metadata error
+func (s *StowryService) CreateMany(ctx context.Context, objs []CreateObject, open func(CreateObject) (io.ReadCloser, error)) ([]MetaData, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, fmt.Errorf("create many: %w", err)
+	}
+
+	paths := make([]string, 0, len(objs))
+	for _, obj := range objs {
+		if err := s.validateCreateObject(obj); err != nil {
+			return nil, fmt.Errorf("create many: %w", err)
+		}
+		paths = append(paths, obj.Path)
+	}
+	if s.objectLimiter != nil {
+		if err := s.objectLimiter.admit(ctx, paths); err != nil {
+			return nil, fmt.Errorf("create many: %w", err)
+		}
+	}
+
+	if mover, ok := s.storage.(StorageMover); ok {
+		return s.createManyStaged(ctx, mover, objs, open)
 	}
 
-	metaData, _, upsertErr := s.repo.Upsert(ctx, oe)
+	// Without renames, files are written in place; only paths that did not
+	// own a file before this call may be removed again on failure
+	existingPaths, err := s.repo.ExistingPaths(ctx, paths)
+	if err != nil {
+		return nil, fmt.Errorf("create many: %w", err)
+	}
+	existing := make(map[string]bool, len(existingPaths))
+	for _, p := range existingPaths {
+		existing[p] = true
+	}
+
+	entries := make([]ObjectEntry, 0, len(objs))
+	written := make([]string, 0, len(objs))
+
+	fail := func(err error) ([]MetaData, error) {
+		cleanupCtx, cancel := context.WithTimeout(context.Background(), s.cleanupTimeout)
+		defer cancel()
+
+		var cleanupErrs []error
+		for _, path := range written {
+			if existing[path] {
+				continue
+			}
+			// A path listed twice is already gone on the second attempt
+			if delErr := s.storageDelete(cleanupCtx, path); delErr != nil && !errors.Is(delErr, ErrNotFound) {
+				cleanupErrs = append(cleanupErrs, fmt.Errorf("%s: %w", path, delErr))
+			}
+		}
+		if len(cleanupErrs) > 0 {
+			return nil, fmt.Errorf("create many: %w and cleanup failed: %w", err, errors.Join(cleanupErrs...))
+		}
+		return nil, fmt.Errorf("create many: %w", err)
+	}
+
+	for _, obj := range objs {
+		content, openErr := open(obj)
+		if openErr != nil {
+			return fail(fmt.Errorf("open %s: %w", obj.Path, openErr))
+		}
+
+		oe, writeErr := s.writeObject(ctx, obj, content)
+		_ = content.Close()
+		if writeErr != nil {
+			return fail(writeErr)
+		}
+
+		entries = append(entries, oe)
+		written = append(written, oe.Path)
+	}
+
+	metaData, upsertErr := s.repo.UpsertBatch(ctx, entries)
+	if s.objectLimiter != nil {
+		// UpsertBatch does not report which paths were new; recount instead
+		s.objectLimiter.invalidate(written)
+	}
 	if upsertErr != nil {
-		// Use background context for cleanup since original context may be cancelled
+		// Files may now belong to a concurrent writer; leave them in place
+		if errors.Is(upsertErr, ErrConflict) {
+			return nil, fmt.Errorf("create many: %w", upsertErr)
+		}
+		return fail(fmt.Errorf("metadata upsert failed: %w", upsertErr))
+	}
+	s.index.invalidate(written...)
+	s.changes.notify()
+
+	return metaData, nil
+}
+
+// createManyStaged is CreateMany for storage that can rename files. Every
+// object is written below a batch stage first, so a failed open or write
+// leaves live objects untouched. The staged files are then promoted the way
+// CommitStage does it, and the promotion is undone if the upsert fails.
+func (s *StowryService) createManyStaged(ctx context.Context, mover StorageMover, objs []CreateObject, open func(CreateObject) (io.ReadCloser, error)) ([]MetaData, error) {
+	batch := uuid.New()
+
+	entries := make([]ObjectEntry, 0, len(objs))
+	var staged []string
+
+	discard := func(err error) ([]MetaData, error) {
 		cleanupCtx, cancel := context.WithTimeout(context.Background(), s.cleanupTimeout)
 		defer cancel()
 
-		if delErr := s.storage.Delete(cleanupCtx, obj.Path); delErr != nil {
-			return MetaData{}, fmt.Errorf("create object %s: metadata upsert failed (%w) and cleanup failed: %w", obj.Path, upsertErr, delErr)
+		var cleanupErrs []error
+		for _, path := range staged {
+			if delErr := s.storageDelete(cleanupCtx, StagedPath(batch, path)); delErr != nil && !errors.Is(delErr, ErrNotFound) {
+				cleanupErrs = append(cleanupErrs, fmt.Errorf("%s: %w", path, delErr))
+			}
 		}
-		return MetaData{}, fmt.Errorf("create object %s: metadata upsert failed: %w", obj.Path, upsertErr)
+		if len(cleanupErrs) > 0 {
+			return nil, fmt.Errorf("create many: %w and cleanup failed: %w", err, errors.Join(cleanupErrs...))
+		}
+		return nil, fmt.Errorf("create many: %w", err)
 	}
 
+	seen := make(map[string]bool, len(objs))
+	for _, obj := range objs {
+		obj, err := s.prepareCreateObject(obj)
+		if err != nil {
+			return discard(err)
+		}
+
+		content, openErr := open(obj)
+		if openErr != nil {
+			return discard(fmt.Errorf("open %s: %w", obj.Path, openErr))
+		}
+
+		oe, writeErr := s.storeObject(ctx, StagedPath(batch, obj.Path), obj, content)
+		_ = content.Close()
+		if writeErr != nil {
+			return discard(writeErr)
+		}
+
+		entries = append(entries, oe)
+		// A path listed twice shares one staged file holding the last content
+		if !seen[obj.Path] {
+			seen[obj.Path] = true
+			staged = append(staged, obj.Path)
+		}
+	}
+
+	var done []stageMove
+	var replaced []string
+	move := func(from, to string) error {
+		if err := mover.Move(ctx, from, to); err != nil {
+			return err
+		}
+		done = append(done, stageMove{from: from, to: to})
+		return nil
+	}
+	undo := func(err error) ([]MetaData, error) {
+		err = s.rollbackStage(mover, done, err)
+		return discard(err)
+	}
+
+	for _, path := range staged {
+		// Paths without a current file have nothing to set aside
+		err := move(path, ReplacedPath(batch, path))
+		switch {
+		case err == nil:
+			replaced = append(replaced, path)
+		case !errors.Is(err, ErrNotFound):
+			return undo(fmt.Errorf("set aside %s: %w", path, err))
+		}
+		if err := move(StagedPath(batch, path), path); err != nil {
+			return undo(fmt.Errorf("promote %s: %w", path, err))
+		}
+	}
+
+	metaData, upsertErr := s.repo.UpsertBatch(ctx, entries)
+	if s.objectLimiter != nil {
+		// UpsertBatch does not report which paths were new; recount instead
+		s.objectLimiter.invalidate(staged)
+	}
+	// Files may now belong to a concurrent writer on a conflict; leave them in place
+	if upsertErr != nil && !errors.Is(upsertErr, ErrConflict) {
+		return undo(fmt.Errorf("metadata upsert failed: %w", upsertErr))
+	}
+
+	// The replaced files are no longer referenced by any row
+	for _, path := range replaced {
+		if delErr := s.storageDelete(ctx, ReplacedPath(batch, path)); delErr != nil && !errors.Is(delErr, ErrNotFound) {
+			slog.Warn("create many: remove replaced file", "path", path, "error", delErr)
+		}
+	}
+
+	if upsertErr != nil {
+		return nil, fmt.Errorf("create many: %w", upsertErr)
+	}
+	s.index.invalidate(staged...)
+	s.changes.notify()
+
 	return metaData, nil
 }
 
+// validateCreateObject checks the path and content type of obj.
+func (s *StowryService) validateCreateObject(obj CreateObject) error {
+	if obj.Path == "" {
+		return fmt.Errorf("create object: %w: path cannot be empty", ErrInvalidInput)
+	}
+
+	if obj.ContentType == "" {
+		return fmt.Errorf("create object: %w: content type cannot be empty", ErrInvalidInput)
+	}
+
+	// Path validation using IsValidPath
+	if !IsValidPath(obj.Path) {
+		return fmt.Errorf("create object %s: %w", obj.Path, ErrInvalidInput)
+	}
+
+	if err := checkReservedPath(obj.Path); err != nil {
+		return fmt.Errorf("create object %s: %w", obj.Path, err)
+	}
+
+	if err := s.pathLimits.Check(obj.Path); err != nil {
+		return fmt.Errorf("create object: %w", err)
+	}
+
+	return nil
+}
+
+// checkReservedPath returns ErrInvalidInput if path lies under a prefix
+// stowry keeps for itself or is the InstanceFile, so no object can be put
+// there.
+func checkReservedPath(path string) error {
+	if strings.HasPrefix(path, StagePrefix) {
+		return fmt.Errorf("%w: %s is reserved for staged uploads", ErrInvalidInput, StagePrefix)
+	}
+
+	if strings.HasPrefix(path, ETagPrefix) {
+		return fmt.Errorf("%w: %s is reserved for permalinks", ErrInvalidInput, ETagPrefix)
+	}
+
+	if strings.HasPrefix(path, DefaultQuarantinePrefix) {
+		return fmt.Errorf("%w: %s is reserved for quarantined orphans", ErrInvalidInput, DefaultQuarantinePrefix)
+	}
+
+	if path == InstanceFile {
+		return fmt.Errorf("%w: reserved for the instance ID", ErrInvalidInput)
+	}
+
+	return nil
+}
+
+// PathLimits returns the limits object paths are checked against, with
+// defaults filled in.
+func (s *StowryService) PathLimits() PathLimits {
+	return s.pathLimits
+}
+
+// prepareCreateObject validates obj, normalizes its content type and content
+// encoding, sanitizes its filename, checks its user metadata and
+// Cache-Control, and checks the content type against the content type rules.
+func (s *StowryService) prepareCreateObject(obj CreateObject) (CreateObject, error) {
+	if err := s.validateCreateObject(obj); err != nil {
+		return CreateObject{}, err
+	}
+
+	contentType, err := NormalizeContentType(obj.ContentType)
+	if err != nil {
+		return CreateObject{}, fmt.Errorf("create object %s: %w", obj.Path, err)
+	}
+	obj.ContentType = contentType
+
+	contentEncoding, err := NormalizeContentEncoding(obj.ContentEncoding)
+	if err != nil {
+		return CreateObject{}, fmt.Errorf("create object %s: %w", obj.Path, err)
+	}
+	obj.ContentEncoding = contentEncoding
+
+	filename, err := SanitizeFilename(obj.Filename)
+	if err != nil {
+		return CreateObject{}, fmt.Errorf("create object %s: %w", obj.Path, err)
+	}
+	obj.Filename = filename
+
+	userMetadata, err := NormalizeUserMetadata(obj.UserMetadata)
+	if err != nil {
+		return CreateObject{}, fmt.Errorf("create object %s: %w", obj.Path, err)
+	}
+	obj.UserMetadata = userMetadata
+
+	cacheControl, err := NormalizeCacheControl(obj.CacheControl)
+	if err != nil {
+		return CreateObject{}, fmt.Errorf("create object %s: %w", obj.Path, err)
+	}
+	obj.CacheControl = cacheControl
+
+	if s.contentTypes != nil {
+		if err := s.contentTypes.Check(obj.Path, obj.ContentType); err != nil {
+			return CreateObject{}, fmt.Errorf("create object %s: %w", obj.Path, err)
+		}
+	}
+	return obj, nil
+}
+
+// writeObject validates obj and writes its content to storage.
+func (s *StowryService) writeObject(ctx context.Context, obj CreateObject, content io.Reader) (ObjectEntry, error) {
+	obj, err := s.prepareCreateObject(obj)
+	if err != nil {
+		return ObjectEntry{}, err
+	}
+	return s.storeObject(ctx, obj.Path, obj, content)
+}
+
+// storeObject writes the content of obj to storagePath, which differs from
+// obj.Path for staged uploads. The returned entry carries obj.Path.
+func (s *StowryService) storeObject(ctx context.Context, storagePath string, obj CreateObject, content io.Reader) (ObjectEntry, error) {
+	// Reject uploads that cannot fit before reading the body
+	if obj.Size > 0 {
+		var err error
+		switch checker := s.storage.(type) {
+		case PathSpaceChecker:
+			err = checker.CheckSpaceFor(ctx, storagePath, obj.Size)
+		case SpaceChecker:
+			err = checker.CheckSpace(ctx, obj.Size)
+		}
+		if err != nil {
+			return ObjectEntry{}, fmt.Errorf("create object %s: %w", obj.Path, err)
+		}
+	}
+
+	// Encoded content is decoded as it is written; content that does not
+	// decode fails the write before it replaces anything
+	var decoder *decodingReader
+	if obj.ContentEncoding != "" {
+		decoder = newDecodingReader(content)
+		defer decoder.close()
+		content = decoder
+	}
+
+	// Write to storage
+	saveResult, writeErr := s.storageWrite(ctx, storagePath, content)
+	if writeErr != nil {
+		return ObjectEntry{}, fmt.Errorf("create object %s: write failed: %w", obj.Path, writeErr)
+	}
+
+	oe := ObjectEntry{
+		ID:              obj.ID,
+		Path:            obj.Path,
+		Size:            saveResult.BytesWritten,
+		ETag:            saveResult.Etag,
+		ContentType:     obj.ContentType,
+		ContentEncoding: obj.ContentEncoding,
+		Filename:        obj.Filename,
+		UserMetadata:    obj.UserMetadata,
+		CacheControl:    obj.CacheControl,
+		StorageBackend:  s.storageBackend(obj.Path),
+	}
+	if decoder != nil {
+		decodedSize := decoder.decodedSize()
+		oe.DecodedSize = &decodedSize
+	}
+	return oe, nil
+}
+
 // resolveMetadata resolves the metadata for a path, applying mode-based fallback logic.
 // In store mode, empty paths return ErrNotFound and no fallback is attempted.
-// In static mode (S3+CloudFront behavior):
-//   - Trailing slash (/foo/): tries {path}index.html
-//   - No trailing slash (/foo): tries exact → {path}.html → {path}/index.html
+// In static mode (S3+CloudFront behavior), where each index document of
+// ServiceConfig.IndexFiles is tried in order:
+//   - Empty path (/): tries each index document
+//   - Otherwise (/foo or /foo/): tries exact → {path}.html → {path}/{index}
+//
+// The fallbacks after the exact path are looked up in one query.
 //
-// In SPA mode, it falls back to /index.html.
+// In SPA mode, it falls back to ServiceConfig.SPAIndex, whose lookup is
+// cached briefly.
 func (s *StowryService) resolveMetadata(ctx context.Context, path string) (MetaData, error) {
+	if strings.HasSuffix(path, "/") {
+		if s.mode == ModeStore {
+			return MetaData{}, fmt.Errorf("%w: object keys cannot end with /", ErrInvalidInput)
+		}
+		// Keys never end in "/", so /docs and /docs/ resolve identically
+		path = strings.TrimSuffix(path, "/")
+	}
+
 	if path == "" {
 		switch s.mode {
 		case ModeStore:
 			return MetaData{}, ErrNotFound
-		case ModeStatic, ModeSPA:
-			path = "index.html"
+		case ModeStatic:
+			return firstIndexMatch(ctx, s.repo, s.indexFiles)
+		case ModeSPA:
+			path = s.index.path
 		}
 	}
 
-	m, err := s.repo.Get(ctx, path)
+	var m MetaData
+	var err error
+	if s.mode == ModeSPA && path == s.index.path {
+		m, err = s.index.get(ctx, s.repo)
+	} else {
+		m, err = s.repo.Get(ctx, path)
+	}
 
 	if errors.Is(err, ErrNotFound) {
 		switch s.mode {
 		case ModeStore:
 			// No fallback in store mode
 		case ModeStatic:
-			if strings.HasSuffix(path, "/") {
-				// Trailing slash: only try directory index
-				m, err = s.repo.Get(ctx, path+"index.html")
-			} else {
-				// Clean URL chain: foo.html → foo/index.html
-				m, err = s.repo.Get(ctx, path+".html")
-				if errors.Is(err, ErrNotFound) {
-					m, err = s.repo.Get(ctx, filepath.Join(path, "index.html"))
-				}
+			// Clean URL chain: foo → foo.html → foo/index.html → ...
+			candidates := make([]string, 0, len(s.indexFiles)+1)
+			candidates = append(candidates, path+".html")
+			for _, name := range s.indexFiles {
+				candidates = append(candidates, path+"/"+name)
 			}
+			m, err = firstIndexMatch(ctx, s.repo, candidates)
 		case ModeSPA:
-			m, err = s.repo.Get(ctx, "index.html")
+			m, err = s.index.get(ctx, s.repo)
 		}
 	}
 
@@ -366,12 +1118,12 @@ func (s *StowryService) Get(ctx context.Context, path string) (MetaData, io.Read
 		return MetaData{}, nil, fmt.Errorf("get object: %w", err)
 	}
 
-	f, err := s.storage.Get(ctx, m.Path)
+	f, err := s.storageGet(ctx, m.Path)
 	if err != nil {
 		return MetaData{}, nil, fmt.Errorf("get object: %w", err)
 	}
 
-	return m, f, nil
+	return m, s.verifyRead(ctx, m, f), nil
 }
 
 func (s *StowryService) Info(ctx context.Context, path string) (MetaData, error) {
@@ -387,6 +1139,35 @@ func (s *StowryService) Info(ctx context.Context, path string) (MetaData, error)
 	return m, nil
 }
 
+// GetDeleted opens the content of a soft-deleted object so it can be
+// inspected before it is restored. Paths are used as is, without the
+// static and SPA fallbacks, and the read is not counted as a download.
+// It returns ErrGone when the object was cleaned up or its file is missing.
+func (s *StowryService) GetDeleted(ctx context.Context, path string) (MetaData, io.ReadSeekCloser, error) {
+	if err := ctx.Err(); err != nil {
+		return MetaData{}, nil, fmt.Errorf("get deleted object: %w", err)
+	}
+
+	if path == "" || strings.HasSuffix(path, "/") {
+		return MetaData{}, nil, fmt.Errorf("get deleted object %s: %w: not an object key", path, ErrInvalidInput)
+	}
+
+	m, err := s.repo.GetDeleted(ctx, path)
+	if err != nil {
+		return MetaData{}, nil, fmt.Errorf("get deleted object: %w", err)
+	}
+
+	f, err := s.storageGet(ctx, m.Path)
+	if errors.Is(err, ErrNotFound) {
+		return MetaData{}, nil, fmt.Errorf("get deleted object %s: %w: file is missing", path, ErrGone)
+	}
+	if err != nil {
+		return MetaData{}, nil, fmt.Errorf("get deleted object: %w", err)
+	}
+
+	return m, f, nil
+}
+
 func (s *StowryService) Delete(ctx context.Context, path string) error {
 	if err := ctx.Err(); err != nil {
 		return fmt.Errorf("delete object: %w", err)
@@ -396,19 +1177,36 @@ func (s *StowryService) Delete(ctx context.Context, path string) error {
 		return fmt.Errorf("delete object: %w: path cannot be empty", ErrInvalidInput)
 	}
 
+	if strings.HasSuffix(path, "/") {
+		return fmt.Errorf("delete object %s: %w: object keys cannot end with /", path, ErrInvalidInput)
+	}
+
 	err := s.repo.Delete(ctx, path)
 	if err != nil {
 		return fmt.Errorf("delete object: %w", err)
 	}
 
+	if s.objectLimiter != nil {
+		s.objectLimiter.adjust(path, -1)
+	}
+	s.index.invalidate(path)
+	s.changes.notify()
+
 	return nil
 }
 
+// List returns a page of active objects. A query that fails
+// ListQuery.Validate against the path limits returns a *ListQueryError
+// without querying the repository.
 func (s *StowryService) List(ctx context.Context, q ListQuery) (ListResult, error) {
 	if err := ctx.Err(); err != nil {
 		return ListResult{}, fmt.Errorf("list object: %w", err)
 	}
 
+	if err := q.Validate(s.pathLimits); err != nil {
+		return ListResult{}, fmt.Errorf("list object: %w", err)
+	}
+
 	result, err := s.repo.List(ctx, q)
 	if err != nil {
 		return ListResult{}, fmt.Errorf("list object: %w", err)
@@ -418,7 +1216,23 @@ func (s *StowryService) List(ctx context.Context, q ListQuery) (ListResult, erro
 }
 
 // Tombstone permanently removes all soft-deleted files from storage and marks them as cleaned up.
-// It processes all pending cleanup items by paginating through until none remain.
+// It is a thin wrapper around TombstoneWithReport that returns only the cleaned count.
+//
+// Parameters:
+//   - ctx: Context for cancellation and timeout
+//   - q: ListQuery with optional path prefix filter and limit (cursor is managed internally)
+//
+// Returns:
+//   - int: Total number of items cleaned up
+//   - error: Any error encountered during cleanup
+func (s *StowryService) Tombstone(ctx context.Context, q ListQuery) (int, error) {
+	report, err := s.TombstoneWithReport(ctx, TombstoneOptions{Query: q})
+	return report.Cleaned, err
+}
+
+// TombstoneWithReport permanently removes all soft-deleted files from storage, marks
+// them as cleaned up, and reports what was processed. It paginates through pending
+// cleanup items until none remain.
 //
 // The method performs the following for each soft-deleted file:
 //  1. Deletes the physical file from storage
@@ -426,56 +1240,98 @@ func (s *StowryService) List(ctx context.Context, q ListQuery) (ListResult, erro
 //
 // If a file has already been deleted from storage (ErrNotFound), the method continues
 // and marks it as cleaned up anyway - this handles the case where a previous cleanup
-// attempt deleted the file but failed to mark the metadata.
+// attempt deleted the file but failed to mark the metadata. Such items count as
+// Skipped and do not add to BytesReclaimed.
+//
+// Any other failure stops the run with the item recorded in the report's
+// Errors, unless opts.ContinueOnError is set: then the item counts as Failed,
+// is deferred for opts.RetryDelay when the repository implements
+// CleanupDeferrer, and the run moves on. Items deferred by an earlier run
+// count as Deferred and are left alone until their CleanupRetryAt, unless
+// opts.RetryDeferred is set.
+//
+// Before deleting, the file is checked against the deleted object, since the
+// path may hold a newer object written after the delete: its size must match
+// FileSizeBytes when storage implements StorageStater, and with opts.VerifyHash
+// its SHA-256 must match the ETag. A file that does not match is kept, logged
+// and counted as Mismatched; its item stays pending unless opts.Force marks it
+// cleaned up.
+//
+// Multipart uploads under the path prefix that were started longer than
+// opts.MultipartExpiry ago and never completed are aborted afterwards, their
+// parts counting towards BytesReclaimed, when the repository implements
+// MultipartRepo.
+//
+// With opts.DryRun set, nothing is deleted or marked; the report describes what a
+// real run would clean up.
 //
 // Parameters:
 //   - ctx: Context for cancellation and timeout
-//   - q: ListQuery with optional path prefix filter and limit (cursor is managed internally)
+//   - opts: Query with optional path prefix filter and limit, the dry-run,
+//     verify-hash, force and continue-on-error flags, and progress callback
 //
 // Returns:
-//   - int: Total number of items cleaned up
+//   - TombstoneReport: Items processed so far, also on error
 //   - error: Any error encountered during cleanup
-func (s *StowryService) Tombstone(ctx context.Context, q ListQuery) (int, error) {
+func (s *StowryService) TombstoneWithReport(ctx context.Context, opts TombstoneOptions) (TombstoneReport, error) {
+	var report TombstoneReport
+
 	if err := ctx.Err(); err != nil {
-		return 0, fmt.Errorf("tombstone: %w", err)
+		return report, fmt.Errorf("tombstone: %w", err)
+	}
+
+	if s.metrics != nil && !opts.DryRun {
+		defer func() { s.metrics.ObserveTombstone(report) }()
+	}
+
+	if err := opts.Query.Validate(s.pathLimits); err != nil {
+		return report, fmt.Errorf("tombstone: %w", err)
 	}
 
-	totalCleaned := 0
-	cursor := q.Cursor
+	tracker := progress.New("tombstone", opts.Progress)
+	defer tracker.Finish()
+
+	cursor := opts.Query.Cursor
 
 	for {
 		if err := ctx.Err(); err != nil {
-			return totalCleaned, fmt.Errorf("tombstone: %w", err)
+			return report, fmt.Errorf("tombstone: %w", err)
 		}
 
 		query := ListQuery{
-			PathPrefix: q.PathPrefix,
-			Limit:      q.Limit,
-			Cursor:     cursor,
+			PathPrefix:    opts.Query.PathPrefix,
+			Limit:         opts.Query.Limit,
+			Cursor:        cursor,
+			DeletedBefore: opts.Query.DeletedBefore,
 		}
 
 		result, listErr := s.repo.ListPendingCleanup(ctx, query)
 		if listErr != nil {
-			return totalCleaned, fmt.Errorf("tombstone: %w", listErr)
+			return report, fmt.Errorf("tombstone: %w", listErr)
 		}
 
 		if len(result.Items) == 0 {
 			break
 		}
 
+		now := time.Now()
 		for _, file := range result.Items {
-			deleteErr := s.storage.Delete(ctx, file.Path)
-			// Ignore ErrNotFound - file may have been deleted already
-			if deleteErr != nil && !errors.Is(deleteErr, ErrNotFound) {
-				return totalCleaned, fmt.Errorf("tombstone '%s': %w", file.Path, deleteErr)
+			if !opts.RetryDeferred && file.CleanupRetryAt != nil && file.CleanupRetryAt.After(now) {
+				report.Deferred++
+				continue
 			}
-
-			updateErr := s.repo.MarkCleanedUp(ctx, file.ID)
-			if updateErr != nil {
-				return totalCleaned, fmt.Errorf("tombstone '%s': %w", file.Path, updateErr)
+			if err := s.tombstoneItem(ctx, file, opts, &report); err != nil {
+				report.Errors = append(report.Errors, TombstoneError{Path: file.Path, Err: err})
+				report.Failed++
+				tracker.Fail(file.Path)
+				if !opts.ContinueOnError || ctx.Err() != nil {
+					return report, fmt.Errorf("tombstone '%s': %w", file.Path, err)
+				}
+				slog.Warn("tombstone: cleanup failed, continuing", "path", file.Path, "error", err)
+				s.deferCleanup(ctx, file, opts)
+				continue
 			}
-
-			totalCleaned++
+			tracker.Add(file.Path, file.FileSizeBytes)
 		}
 
 		if result.NextCursor == "" {
@@ -484,5 +1340,282 @@ func (s *StowryService) Tombstone(ctx context.Context, q ListQuery) (int, error)
 		cursor = result.NextCursor
 	}
 
-	return totalCleaned, nil
+	if err := s.reclaimMultipart(ctx, opts, &report); err != nil {
+		return report, fmt.Errorf("tombstone: %w", err)
+	}
+
+	return report, nil
+}
+
+// deferCleanup postpones the next cleanup of file after it failed, when the
+// repository supports it. A failure to defer is logged: the item is retried
+// on the next run instead.
+func (s *StowryService) deferCleanup(ctx context.Context, file MetaData, opts TombstoneOptions) {
+	deferrer, ok := s.repo.(CleanupDeferrer)
+	if !ok || opts.DryRun {
+		return
+	}
+	delay := opts.RetryDelay
+	if delay <= 0 {
+		delay = DefaultCleanupRetryDelay
+	}
+	err := deferrer.DeferCleanup(ctx, file.ID, time.Now().Add(delay))
+	if err != nil && !errors.Is(err, ErrNotSupported) {
+		slog.Warn("tombstone: defer cleanup failed", "path", file.Path, "error", err)
+	}
+}
+
+// tombstoneItem deletes one soft-deleted file, unless it no longer matches
+// the deleted object, and records it in report.
+func (s *StowryService) tombstoneItem(ctx context.Context, file MetaData, opts TombstoneOptions, report *TombstoneReport) error {
+	matches, err := s.storedFileMatches(ctx, file, opts.VerifyHash)
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		return err
+	}
+
+	if err == nil && !matches {
+		report.Mismatched++
+		slog.Warn("tombstone: stored file does not match the deleted object, keeping it",
+			"path", file.Path, "etag", file.Etag, "size", file.FileSizeBytes, "force", opts.Force)
+		if !opts.Force {
+			return nil
+		}
+		if !opts.DryRun {
+			if err := s.repo.MarkCleanedUp(ctx, file.ID); err != nil {
+				return err
+			}
+		}
+		report.Cleaned++
+		return nil
+	}
+
+	if opts.DryRun {
+		report.Cleaned++
+		report.BytesReclaimed += file.FileSizeBytes
+		return nil
+	}
+
+	// Delete from the backend checked above, which the routes may no longer
+	// pick for file.Path
+	start := time.Now()
+	deleteErr := s.storageOf(file).Delete(ctx, file.Path)
+	s.observeStorage("delete", start, deleteErr)
+	// Ignore ErrNotFound - file may have been deleted already
+	if deleteErr != nil && !errors.Is(deleteErr, ErrNotFound) {
+		return deleteErr
+	}
+
+	if err := s.repo.MarkCleanedUp(ctx, file.ID); err != nil {
+		return err
+	}
+
+	report.Cleaned++
+	if deleteErr != nil {
+		report.Skipped++
+	} else {
+		report.BytesReclaimed += file.FileSizeBytes
+	}
+	return nil
+}
+
+// storedFileMatches reports whether the file at file.Path, on the backend
+// storageOf picks, is still the one file describes: of the same size, when
+// storage implements StorageStater, and with verifyHash of the same
+// SHA-256. Returns ErrNotFound if the file is missing.
+func (s *StowryService) storedFileMatches(ctx context.Context, file MetaData, verifyHash bool) (bool, error) {
+	storage := s.storageOf(file)
+	if _, ok := s.storage.(StorageStater); ok {
+		info, err := StatFile(ctx, storage, file.Path)
+		if err != nil {
+			return false, err
+		}
+		if info.Size != file.FileSizeBytes {
+			return false, nil
+		}
+	}
+
+	if !verifyHash {
+		return true, nil
+	}
+
+	content, err := storage.Get(ctx, file.Path)
+	if err != nil {
+		return false, err
+	}
+	defer func() { _ = content.Close() }()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, content); err != nil {
+		return false, fmt.Errorf("hash stored file: %w", err)
+	}
+	return digestMatches(h, file.Etag), nil
+}
+
+// storageBackend returns the backend path is written to when storage is a
+// StorageLocator, empty otherwise.
+func (s *StowryService) storageBackend(path string) string {
+	if locator, ok := s.storage.(StorageLocator); ok {
+		return locator.Backend(path)
+	}
+	return ""
+}
+
+// storageOf returns the storage holding the file of m: the backend recorded
+// in m.StorageBackend when it is still configured, otherwise storage, which
+// looks where the path routes now. Objects recorded before backends were
+// tracked have no backend and are looked up as before.
+func (s *StowryService) storageOf(m MetaData) FileStorage {
+	locator, ok := s.storage.(StorageLocator)
+	if !ok || m.StorageBackend == "" {
+		return s.storage
+	}
+	if backend, ok := locator.BackendStorage(m.StorageBackend); ok {
+		return backend
+	}
+	return s.storage
+}
+
+// CollectOrphans finds files in storage that no metadata row references, live
+// or soft-deleted, and reports, quarantines, or deletes them. Files cleaned up
+// by Tombstone no longer count as referenced.
+//
+// Files modified within opts.MinAge are skipped so uploads that have been
+// written but not yet recorded are never flagged. Files already under the
+// quarantine prefix or StagePrefix, and the InstanceFile, are ignored. Storage must implement StorageWalker, and
+// StorageMover for OrphanActionQuarantine.
+//
+// A file that cannot be moved or deleted is recorded in the report's Errors
+// and the run continues.
+//
+// Parameters:
+//   - ctx: Context for cancellation and timeout
+//   - opts: Action, age threshold, quarantine prefix and progress callback
+//
+// Returns:
+//   - OrphanReport: Files processed so far, also on error
+//   - error: Storage walk, metadata lookup, or context errors
+func (s *StowryService) CollectOrphans(ctx context.Context, opts OrphanOptions) (OrphanReport, error) {
+	var report OrphanReport
+
+	if err := ctx.Err(); err != nil {
+		return report, fmt.Errorf("collect orphans: %w", err)
+	}
+
+	walker, ok := s.storage.(StorageWalker)
+	if !ok {
+		return report, fmt.Errorf("collect orphans: storage does not support walking: %w", ErrInvalidInput)
+	}
+
+	action := opts.Action
+	if action == "" {
+		action = OrphanActionReport
+	}
+	var mover StorageMover
+	switch action {
+	case OrphanActionReport, OrphanActionDelete:
+	case OrphanActionQuarantine:
+		if mover, ok = s.storage.(StorageMover); !ok {
+			return report, fmt.Errorf("collect orphans: storage does not support moving: %w", ErrInvalidInput)
+		}
+	default:
+		return report, fmt.Errorf("collect orphans: unknown action %q: %w", action, ErrInvalidInput)
+	}
+
+	quarantine := opts.QuarantinePrefix
+	if quarantine == "" {
+		quarantine = DefaultQuarantinePrefix
+	}
+	quarantine = strings.TrimSuffix(quarantine, "/") + "/"
+
+	tracker := progress.New("collect orphans", opts.Progress)
+	defer tracker.Finish()
+
+	cutoff := time.Now().Add(-opts.MinAge)
+	batch := make([]StoredFile, 0, s.batchSize)
+
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		if err := s.collectOrphanBatch(ctx, batch, action, mover, quarantine, &report, tracker); err != nil {
+			return err
+		}
+		batch = batch[:0]
+		return nil
+	}
+
+	err := walker.Walk(ctx, func(f StoredFile) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if strings.HasPrefix(f.Path, quarantine) || strings.HasPrefix(f.Path, StagePrefix) || f.Path == InstanceFile {
+			return nil
+		}
+		report.Scanned++
+		if f.ModTime.After(cutoff) {
+			report.TooRecent++
+			tracker.Add(f.Path, f.Size)
+			return nil
+		}
+		batch = append(batch, f)
+		if len(batch) < s.batchSize {
+			return nil
+		}
+		return flush()
+	})
+	if err == nil {
+		err = flush()
+	}
+	if err != nil {
+		return report, fmt.Errorf("collect orphans: %w", err)
+	}
+
+	return report, nil
+}
+
+// collectOrphanBatch looks up batch in the repository and handles every file
+// without metadata according to action.
+func (s *StowryService) collectOrphanBatch(ctx context.Context, batch []StoredFile, action OrphanAction, mover StorageMover, quarantine string, report *OrphanReport, tracker *progress.Tracker) error {
+	paths := make([]string, len(batch))
+	for i, f := range batch {
+		paths[i] = f.Path
+	}
+
+	existing, err := s.repo.ExistingPaths(ctx, paths)
+	if err != nil {
+		return err
+	}
+	known := make(map[string]struct{}, len(existing))
+	for _, p := range existing {
+		known[p] = struct{}{}
+	}
+
+	for _, f := range batch {
+		if _, ok := known[f.Path]; ok {
+			tracker.Add(f.Path, f.Size)
+			continue
+		}
+		report.Orphans++
+		report.Bytes += f.Size
+		report.Paths = append(report.Paths, f.Path)
+
+		var handleErr error
+		switch action {
+		case OrphanActionDelete:
+			handleErr = s.storageDelete(ctx, f.Path)
+		case OrphanActionQuarantine:
+			handleErr = mover.Move(ctx, f.Path, quarantine+f.Path)
+		default:
+			tracker.Add(f.Path, f.Size)
+			continue
+		}
+		if handleErr != nil {
+			report.Errors = append(report.Errors, OrphanError{Path: f.Path, Err: handleErr})
+			tracker.Fail(f.Path)
+			continue
+		}
+		report.Handled++
+		tracker.Add(f.Path, f.Size)
+	}
+	return nil
 }
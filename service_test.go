@@ -4,13 +4,24 @@ import (
 	"bytes"
 	"context"
 	"errors"
+	"fmt"
 	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/sagarc03/stowry"
+	"github.com/sagarc03/stowry/database/sqlite"
+	"github.com/sagarc03/stowry/filesystem"
+	"github.com/sagarc03/stowry/progress"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
 )
 
 type SpyMetaDataRepo struct {
@@ -22,9 +33,32 @@ func (s *SpyMetaDataRepo) Get(ctx context.Context, path string) (stowry.MetaData
 	return args.Get(0).(stowry.MetaData), args.Error(1)
 }
 
-func (s *SpyMetaDataRepo) Upsert(ctx context.Context, entry stowry.ObjectEntry) (stowry.MetaData, bool, error) {
+func (s *SpyMetaDataRepo) GetDeleted(ctx context.Context, path string) (stowry.MetaData, error) {
+	args := s.Called(ctx, path)
+	return args.Get(0).(stowry.MetaData), args.Error(1)
+}
+
+func (s *SpyMetaDataRepo) GetMany(ctx context.Context, paths []string) ([]stowry.MetaData, error) {
+	args := s.Called(ctx, paths)
+	return args.Get(0).([]stowry.MetaData), args.Error(1)
+}
+
+func (s *SpyMetaDataRepo) GetByETag(ctx context.Context, etag string) (stowry.MetaData, error) {
+	args := s.Called(ctx, etag)
+	return args.Get(0).(stowry.MetaData), args.Error(1)
+}
+
+func (s *SpyMetaDataRepo) Upsert(ctx context.Context, entry stowry.ObjectEntry) (stowry.MetaData, *stowry.MetaData, error) {
 	args := s.Called(ctx, entry)
-	return args.Get(0).(stowry.MetaData), args.Bool(1), args.Error(2)
+	if args.Get(1) == nil {
+		return args.Get(0).(stowry.MetaData), nil, args.Error(2)
+	}
+	return args.Get(0).(stowry.MetaData), args.Get(1).(*stowry.MetaData), args.Error(2)
+}
+
+func (s *SpyMetaDataRepo) UpsertBatch(ctx context.Context, entries []stowry.ObjectEntry) ([]stowry.MetaData, error) {
+	args := s.Called(ctx, entries)
+	return args.Get(0).([]stowry.MetaData), args.Error(1)
 }
 
 func (s *SpyMetaDataRepo) Delete(ctx context.Context, path string) error {
@@ -32,6 +66,16 @@ func (s *SpyMetaDataRepo) Delete(ctx context.Context, path string) error {
 	return args.Error(0)
 }
 
+func (s *SpyMetaDataRepo) Restore(ctx context.Context, path string) (stowry.MetaData, error) {
+	args := s.Called(ctx, path)
+	return args.Get(0).(stowry.MetaData), args.Error(1)
+}
+
+func (s *SpyMetaDataRepo) Quarantine(ctx context.Context, path, etag string) error {
+	args := s.Called(ctx, path, etag)
+	return args.Error(0)
+}
+
 func (s *SpyMetaDataRepo) List(ctx context.Context, q stowry.ListQuery) (stowry.ListResult, error) {
 	args := s.Called(ctx, q)
 	return args.Get(0).(stowry.ListResult), args.Error(1)
@@ -47,6 +91,21 @@ func (s *SpyMetaDataRepo) MarkCleanedUp(ctx context.Context, id uuid.UUID) error
 	return args.Error(0)
 }
 
+func (s *SpyMetaDataRepo) RecordAccess(ctx context.Context, records []stowry.AccessRecord) error {
+	args := s.Called(ctx, records)
+	return args.Error(0)
+}
+
+func (s *SpyMetaDataRepo) ExistingPaths(ctx context.Context, paths []string) ([]string, error) {
+	args := s.Called(ctx, paths)
+	return args.Get(0).([]string), args.Error(1)
+}
+
+func (s *SpyMetaDataRepo) CountPrefix(ctx context.Context, prefix string) (int64, error) {
+	args := s.Called(ctx, prefix)
+	return args.Get(0).(int64), args.Error(1)
+}
+
 type SpyFileStorage struct {
 	mock.Mock
 }
@@ -93,9 +152,7 @@ func TestStowryService_Populate(t *testing.T) {
 		}
 
 		storage.On("List", ctx).Return(files, nil)
-		repo.On("Upsert", ctx, files[0]).Return(stowry.MetaData{}, false, nil)
-		repo.On("Upsert", ctx, files[1]).Return(stowry.MetaData{}, false, nil)
-		repo.On("Upsert", ctx, files[2]).Return(stowry.MetaData{}, false, nil)
+		repo.On("UpsertBatch", ctx, files).Return(make([]stowry.MetaData, 3), nil)
 
 		err := service.Populate(ctx)
 		assert.NoError(t, err)
@@ -104,68 +161,89 @@ func TestStowryService_Populate(t *testing.T) {
 		repo.AssertExpectations(t)
 	})
 
-	t.Run("success with empty list", func(t *testing.T) {
+	t.Run("splits into batches", func(t *testing.T) {
+		spyRepo := new(SpyMetaDataRepo)
+		spyStorage := new(SpyFileStorage)
+		service, err := stowry.NewStowryService(spyRepo, spyStorage, stowry.ServiceConfig{Mode: stowry.ModeStore, BatchSize: 2})
+		require.NoError(t, err)
+		ctx := context.Background()
+
+		files := []stowry.ObjectEntry{
+			{Path: "file1.txt", ContentType: "text/plain", Size: 100, ETag: "etag1"},
+			{Path: "file2.jpg", ContentType: "image/jpeg", Size: 200, ETag: "etag2"},
+			{Path: "file3.pdf", ContentType: "application/pdf", Size: 300, ETag: "etag3"},
+		}
+
+		spyStorage.On("List", ctx).Return(files, nil)
+		spyRepo.On("UpsertBatch", ctx, files[:2]).Return(make([]stowry.MetaData, 2), nil).Once()
+		spyRepo.On("UpsertBatch", ctx, files[2:]).Return(make([]stowry.MetaData, 1), nil).Once()
+
+		err = service.Populate(ctx)
+		assert.NoError(t, err)
+
+		spyRepo.AssertExpectations(t)
+	})
+
+	t.Run("skips staged uploads, quarantined orphans and the instance file", func(t *testing.T) {
 		service, repo, storage := NewStowryService(t)
 		ctx := context.Background()
 
-		storage.On("List", ctx).Return([]stowry.ObjectEntry{}, nil)
+		file := stowry.ObjectEntry{Path: "file1.txt", ContentType: "text/plain", Size: 100, ETag: "etag1"}
+		storage.On("List", ctx).Return([]stowry.ObjectEntry{
+			file,
+			{Path: stowry.StagePrefix + "id/objects/a.txt", ContentType: "text/plain", Size: 1, ETag: "etag2"},
+			{Path: stowry.DefaultQuarantinePrefix + "orphan.txt", ContentType: "text/plain", Size: 1, ETag: "etag4"},
+			{Path: stowry.InstanceFile, ContentType: "application/octet-stream", Size: 37, ETag: "etag3"},
+		}, nil)
+		repo.On("UpsertBatch", ctx, []stowry.ObjectEntry{file}).Return(make([]stowry.MetaData, 1), nil)
 
 		err := service.Populate(ctx)
 		assert.NoError(t, err)
 
-		storage.AssertExpectations(t)
-		repo.AssertNotCalled(t, "Upsert")
+		repo.AssertExpectations(t)
 	})
 
-	t.Run("storage list error", func(t *testing.T) {
+	t.Run("success with empty list", func(t *testing.T) {
 		service, repo, storage := NewStowryService(t)
 		ctx := context.Background()
 
-		storageErr := io.ErrUnexpectedEOF
-		storage.On("List", ctx).Return([]stowry.ObjectEntry{}, storageErr)
+		storage.On("List", ctx).Return([]stowry.ObjectEntry{}, nil)
 
 		err := service.Populate(ctx)
-		assert.Error(t, err)
+		assert.NoError(t, err)
 
 		storage.AssertExpectations(t)
-		repo.AssertNotCalled(t, "Upsert")
+		repo.AssertNotCalled(t, "UpsertBatch")
 	})
 
-	t.Run("upsert error on first file", func(t *testing.T) {
+	t.Run("storage list error", func(t *testing.T) {
 		service, repo, storage := NewStowryService(t)
 		ctx := context.Background()
 
-		files := []stowry.ObjectEntry{
-			{Path: "file1.txt", ContentType: "text/plain", Size: 100, ETag: "etag1"},
-		}
-
-		upsertErr := io.ErrClosedPipe
-		storage.On("List", ctx).Return(files, nil)
-		repo.On("Upsert", ctx, files[0]).Return(stowry.MetaData{}, false, upsertErr)
+		storageErr := io.ErrUnexpectedEOF
+		storage.On("List", ctx).Return([]stowry.ObjectEntry{}, storageErr)
 
 		err := service.Populate(ctx)
 		assert.Error(t, err)
 
 		storage.AssertExpectations(t)
-		repo.AssertExpectations(t)
+		repo.AssertNotCalled(t, "UpsertBatch")
 	})
 
-	t.Run("upsert error on second file", func(t *testing.T) {
+	t.Run("upsert batch error", func(t *testing.T) {
 		service, repo, storage := NewStowryService(t)
 		ctx := context.Background()
 
 		files := []stowry.ObjectEntry{
 			{Path: "file1.txt", ContentType: "text/plain", Size: 100, ETag: "etag1"},
-			{Path: "file2.jpg", ContentType: "image/jpeg", Size: 200, ETag: "etag2"},
 		}
 
 		upsertErr := io.ErrClosedPipe
 		storage.On("List", ctx).Return(files, nil)
-		repo.On("Upsert", ctx, files[0]).Return(stowry.MetaData{}, false, nil)
-		repo.On("Upsert", ctx, files[1]).Return(stowry.MetaData{}, false, upsertErr)
+		repo.On("UpsertBatch", ctx, files).Return([]stowry.MetaData(nil), upsertErr)
 
 		err := service.Populate(ctx)
-		assert.Error(t, err)
+		assert.ErrorIs(t, err, upsertErr)
 
 		storage.AssertExpectations(t)
 		repo.AssertExpectations(t)
@@ -181,7 +259,7 @@ func TestStowryService_Populate(t *testing.T) {
 		assert.ErrorIs(t, err, context.Canceled)
 
 		storage.AssertNotCalled(t, "List")
-		repo.AssertNotCalled(t, "Upsert")
+		repo.AssertNotCalled(t, "UpsertBatch")
 	})
 
 	t.Run("context cancelled during list", func(t *testing.T) {
@@ -194,7 +272,7 @@ func TestStowryService_Populate(t *testing.T) {
 		assert.Error(t, err)
 
 		storage.AssertExpectations(t)
-		repo.AssertNotCalled(t, "Upsert")
+		repo.AssertNotCalled(t, "UpsertBatch")
 	})
 
 	t.Run("context cancelled during upsert", func(t *testing.T) {
@@ -207,15 +285,71 @@ func TestStowryService_Populate(t *testing.T) {
 		}
 
 		storage.On("List", ctx).Return(files, nil)
-		repo.On("Upsert", ctx, files[0]).Return(stowry.MetaData{}, false, nil)
-		repo.On("Upsert", ctx, files[1]).Return(stowry.MetaData{}, false, context.Canceled)
+		repo.On("UpsertBatch", ctx, files).Return([]stowry.MetaData(nil), context.Canceled)
 
 		err := service.Populate(ctx)
-		assert.Error(t, err)
+		assert.ErrorIs(t, err, context.Canceled)
 
 		storage.AssertExpectations(t)
 		repo.AssertExpectations(t)
 	})
+
+	t.Run("reports progress", func(t *testing.T) {
+		spyRepo := new(SpyMetaDataRepo)
+		spyStorage := new(SpyFileStorage)
+		service, err := stowry.NewStowryService(spyRepo, spyStorage, stowry.ServiceConfig{Mode: stowry.ModeStore, BatchSize: 2})
+		require.NoError(t, err)
+		ctx := context.Background()
+
+		files := []stowry.ObjectEntry{
+			{Path: "file1.txt", Size: 100},
+			{Path: "file2.jpg", Size: 200},
+			{Path: "file3.pdf", Size: 300},
+		}
+		spyStorage.On("List", ctx).Return(files, nil)
+		spyRepo.On("UpsertBatch", ctx, mock.Anything).Return([]stowry.MetaData(nil), nil)
+
+		var events []progress.Event
+		err = service.PopulateWithOptions(ctx, stowry.PopulateOptions{
+			Progress: func(e progress.Event) { events = append(events, e) },
+		})
+		require.NoError(t, err)
+
+		require.NotEmpty(t, events)
+		assert.Equal(t, int64(1), events[0].Processed, "first file is reported at once")
+		last := events[len(events)-1]
+		assert.True(t, last.Done)
+		assert.Equal(t, "populate", last.Operation)
+		assert.Equal(t, int64(3), last.Processed)
+		assert.Equal(t, int64(3), last.Total)
+		assert.Equal(t, int64(600), last.Bytes)
+	})
+
+	t.Run("stops when cancelled from progress", func(t *testing.T) {
+		spyRepo := new(SpyMetaDataRepo)
+		spyStorage := new(SpyFileStorage)
+		service, err := stowry.NewStowryService(spyRepo, spyStorage, stowry.ServiceConfig{Mode: stowry.ModeStore, BatchSize: 1})
+		require.NoError(t, err)
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		files := []stowry.ObjectEntry{{Path: "file1.txt"}, {Path: "file2.txt"}, {Path: "file3.txt"}}
+		spyStorage.On("List", ctx).Return(files, nil)
+		spyRepo.On("UpsertBatch", ctx, mock.Anything).Return([]stowry.MetaData(nil), nil)
+
+		var last progress.Event
+		err = service.PopulateWithOptions(ctx, stowry.PopulateOptions{
+			Progress: func(e progress.Event) {
+				last = e
+				cancel()
+			},
+		})
+
+		assert.ErrorIs(t, err, context.Canceled)
+		spyRepo.AssertNumberOfCalls(t, "UpsertBatch", 1)
+		assert.True(t, last.Done)
+		assert.Equal(t, int64(1), last.Processed)
+	})
 }
 
 func TestStowryService_Create(t *testing.T) {
@@ -247,16 +381,32 @@ func TestStowryService_Create(t *testing.T) {
 				entry.ContentType == "text/plain" &&
 				entry.Size == 12 &&
 				entry.ETag == "abc123"
-		})).Return(expectedMetadata, true, nil)
+		})).Return(expectedMetadata, nil, nil)
 
 		result, err := service.Create(ctx, obj, content)
 		assert.NoError(t, err)
 		assert.Equal(t, "documents/test.txt", result.Path)
+		assert.Nil(t, result.Previous)
 
 		storage.AssertExpectations(t)
 		repo.AssertExpectations(t)
 	})
 
+	t.Run("success - overwrite reports the previous object", func(t *testing.T) {
+		service, repo, storage := NewStowryService(t)
+		ctx := context.Background()
+
+		previous := stowry.MetaData{Path: "documents/test.txt", Etag: "old", FileSizeBytes: 3}
+		storage.On("Write", ctx, "documents/test.txt", mock.Anything).Return(stowry.SaveResult{BytesWritten: 3, Etag: "new"}, nil)
+		repo.On("Upsert", ctx, mock.Anything).Return(stowry.MetaData{Path: "documents/test.txt", Etag: "new", FileSizeBytes: 3}, &previous, nil)
+
+		result, err := service.Create(ctx, stowry.CreateObject{Path: "documents/test.txt", ContentType: "text/plain"}, bytes.NewBufferString("new"))
+		require.NoError(t, err)
+		assert.Equal(t, "new", result.Etag)
+		require.NotNil(t, result.Previous)
+		assert.Equal(t, "old", result.Previous.Etag)
+	})
+
 	t.Run("error - context cancelled before operation", func(t *testing.T) {
 		service, repo, storage := NewStowryService(t)
 		ctx, cancel := context.WithCancel(context.Background())
@@ -385,7 +535,7 @@ func TestStowryService_Create(t *testing.T) {
 
 		upsertErr := errors.New("database error")
 		storage.On("Write", ctx, "test.txt", content).Return(saveResult, nil)
-		repo.On("Upsert", ctx, mock.Anything).Return(stowry.MetaData{}, false, upsertErr)
+		repo.On("Upsert", ctx, mock.Anything).Return(stowry.MetaData{}, nil, upsertErr)
 		storage.On("Delete", mock.Anything, "test.txt").Return(nil)
 
 		_, err := service.Create(ctx, obj, content)
@@ -414,7 +564,7 @@ func TestStowryService_Create(t *testing.T) {
 		upsertErr := errors.New("database error")
 		deleteErr := errors.New("delete failed")
 		storage.On("Write", ctx, "test.txt", content).Return(saveResult, nil)
-		repo.On("Upsert", ctx, mock.Anything).Return(stowry.MetaData{}, false, upsertErr)
+		repo.On("Upsert", ctx, mock.Anything).Return(stowry.MetaData{}, nil, upsertErr)
 		storage.On("Delete", mock.Anything, "test.txt").Return(deleteErr)
 
 		_, err := service.Create(ctx, obj, content)
@@ -443,6 +593,79 @@ func TestStowryService_Create(t *testing.T) {
 		storage.AssertExpectations(t)
 		repo.AssertNotCalled(t, "Upsert")
 	})
+
+	t.Run("error - insufficient space rejected before write", func(t *testing.T) {
+		repo := new(SpyMetaDataRepo)
+		storage := &SpySpaceCheckingStorage{}
+		service, err := stowry.NewStowryService(repo, storage, stowry.ServiceConfig{Mode: stowry.ModeStore})
+		assert.NoError(t, err)
+		ctx := context.Background()
+
+		obj := stowry.CreateObject{
+			Path:        "big.bin",
+			ContentType: "application/octet-stream",
+			Size:        1 << 40,
+		}
+
+		storage.On("CheckSpace", ctx, int64(1<<40)).Return(stowry.ErrInsufficientStorage)
+
+		_, err = service.Create(ctx, obj, bytes.NewBufferString("data"))
+		assert.ErrorIs(t, err, stowry.ErrInsufficientStorage)
+
+		storage.AssertExpectations(t)
+		storage.AssertNotCalled(t, "Write")
+		repo.AssertNotCalled(t, "Upsert")
+	})
+
+	t.Run("success - unknown size skips space check", func(t *testing.T) {
+		repo := new(SpyMetaDataRepo)
+		storage := &SpySpaceCheckingStorage{}
+		service, err := stowry.NewStowryService(repo, storage, stowry.ServiceConfig{Mode: stowry.ModeStore})
+		assert.NoError(t, err)
+		ctx := context.Background()
+
+		obj := stowry.CreateObject{Path: "small.txt", ContentType: "text/plain"}
+		content := bytes.NewBufferString("data")
+
+		storage.On("Write", ctx, "small.txt", content).Return(stowry.SaveResult{BytesWritten: 4, Etag: "e"}, nil)
+		repo.On("Upsert", ctx, mock.Anything).Return(stowry.MetaData{Path: "small.txt"}, nil, nil)
+
+		_, err = service.Create(ctx, obj, content)
+		assert.NoError(t, err)
+
+		storage.AssertNotCalled(t, "CheckSpace")
+	})
+}
+
+// SpySpaceCheckingStorage is a SpyFileStorage that also implements stowry.SpaceChecker.
+type SpySpaceCheckingStorage struct {
+	SpyFileStorage
+}
+
+func (s *SpySpaceCheckingStorage) CheckSpace(ctx context.Context, size int64) error {
+	args := s.Called(ctx, size)
+	return args.Error(0)
+}
+
+// SpyWalkingStorage is a SpyFileStorage that also implements stowry.StorageWalker
+// and stowry.StorageMover.
+type SpyWalkingStorage struct {
+	SpyFileStorage
+	files []stowry.StoredFile
+}
+
+func (s *SpyWalkingStorage) Walk(ctx context.Context, fn func(stowry.StoredFile) error) error {
+	for _, f := range s.files {
+		if err := fn(f); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *SpyWalkingStorage) Move(ctx context.Context, from, to string) error {
+	args := s.Called(ctx, from, to)
+	return args.Error(0)
 }
 
 func NewStowryServiceWithMode(t *testing.T, mode stowry.ServerMode) (*stowry.StowryService, *SpyMetaDataRepo, *SpyFileStorage) {
@@ -455,6 +678,236 @@ func NewStowryServiceWithMode(t *testing.T, mode stowry.ServerMode) (*stowry.Sto
 	return s, spyRepo, spyStorage
 }
 
+func TestStowryService_Create_ConcurrentFirstWrite(t *testing.T) {
+	service, repo, storage := NewStowryService(t)
+	ctx := context.Background()
+
+	// Both writers must finish writing before either upserts, which is the
+	// window where the insert race happens.
+	var written sync.WaitGroup
+	written.Add(2)
+	storage.On("Write", ctx, "race.txt", mock.Anything).Run(func(mock.Arguments) {
+		written.Done()
+		written.Wait()
+	}).Return(stowry.SaveResult{BytesWritten: 4, Etag: "same"}, nil)
+
+	winner := stowry.MetaData{ID: uuid.New(), Path: "race.txt", Etag: "same", ContentType: "text/plain", FileSizeBytes: 4}
+	repo.On("Upsert", ctx, mock.Anything).Return(winner, nil, nil).Once()
+	repo.On("Upsert", ctx, mock.Anything).Return(stowry.MetaData{}, nil, fmt.Errorf("upsert: %w", stowry.ErrConflict)).Once()
+	repo.On("Get", ctx, "race.txt").Return(winner, nil)
+
+	type result struct {
+		meta stowry.MetaData
+		err  error
+	}
+	results := make(chan result, 2)
+	for range 2 {
+		go func() {
+			m, err := service.Create(ctx, stowry.CreateObject{Path: "race.txt", ContentType: "text/plain"}, bytes.NewBufferString("data"))
+			results <- result{m.MetaData, err}
+		}()
+	}
+
+	var succeeded, conflicted int
+	for range 2 {
+		r := <-results
+		switch {
+		case r.err == nil:
+			succeeded++
+		case errors.Is(r.err, stowry.ErrConflict):
+			conflicted++
+		default:
+			t.Fatalf("unexpected error: %v", r.err)
+		}
+		assert.Equal(t, winner.ID, r.meta.ID, "both callers see the winning metadata")
+	}
+
+	assert.Equal(t, 1, succeeded)
+	assert.Equal(t, 1, conflicted)
+	storage.AssertNotCalled(t, "Delete", mock.Anything, mock.Anything)
+	repo.AssertExpectations(t)
+}
+
+func TestStowryService_Create_ConflictWinnerUnreadable(t *testing.T) {
+	service, repo, storage := NewStowryService(t)
+	ctx := context.Background()
+
+	storage.On("Write", ctx, "race.txt", mock.Anything).Return(stowry.SaveResult{BytesWritten: 4, Etag: "e"}, nil)
+	repo.On("Upsert", ctx, mock.Anything).Return(stowry.MetaData{}, nil, stowry.ErrConflict)
+	repo.On("Get", ctx, "race.txt").Return(stowry.MetaData{}, io.ErrClosedPipe)
+
+	_, err := service.Create(ctx, stowry.CreateObject{Path: "race.txt", ContentType: "text/plain"}, bytes.NewBufferString("data"))
+	assert.ErrorIs(t, err, stowry.ErrConflict)
+	assert.ErrorIs(t, err, io.ErrClosedPipe)
+	storage.AssertNotCalled(t, "Delete", mock.Anything, mock.Anything)
+}
+
+func TestStowryService_CreateMany(t *testing.T) {
+	objs := []stowry.CreateObject{
+		{Path: "a.txt", ContentType: "text/plain"},
+		{Path: "b.txt", ContentType: "text/plain"},
+	}
+	open := func(obj stowry.CreateObject) (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewBufferString(obj.Path)), nil
+	}
+
+	t.Run("success - single batch upsert", func(t *testing.T) {
+		service, repo, storage := NewStowryService(t)
+		ctx := context.Background()
+		repo.On("ExistingPaths", ctx, mock.Anything).Return([]string{}, nil)
+
+		storage.On("Write", ctx, "a.txt", mock.Anything).Return(stowry.SaveResult{BytesWritten: 5, Etag: "ea"}, nil)
+		storage.On("Write", ctx, "b.txt", mock.Anything).Return(stowry.SaveResult{BytesWritten: 5, Etag: "eb"}, nil)
+		repo.On("UpsertBatch", ctx, []stowry.ObjectEntry{
+			{Path: "a.txt", Size: 5, ETag: "ea", ContentType: "text/plain"},
+			{Path: "b.txt", Size: 5, ETag: "eb", ContentType: "text/plain"},
+		}).Return([]stowry.MetaData{{Path: "a.txt"}, {Path: "b.txt"}}, nil)
+
+		result, err := service.CreateMany(ctx, objs, open)
+		require.NoError(t, err)
+		assert.Len(t, result, 2)
+
+		storage.AssertExpectations(t)
+		repo.AssertExpectations(t)
+		repo.AssertNotCalled(t, "Upsert")
+	})
+
+	t.Run("error - upsert failure removes written files", func(t *testing.T) {
+		service, repo, storage := NewStowryService(t)
+		ctx := context.Background()
+		repo.On("ExistingPaths", ctx, mock.Anything).Return([]string{}, nil)
+
+		storage.On("Write", ctx, mock.Anything, mock.Anything).Return(stowry.SaveResult{BytesWritten: 5, Etag: "e"}, nil)
+		repo.On("UpsertBatch", ctx, mock.Anything).Return([]stowry.MetaData(nil), io.ErrClosedPipe)
+		storage.On("Delete", mock.Anything, "a.txt").Return(nil)
+		storage.On("Delete", mock.Anything, "b.txt").Return(nil)
+
+		_, err := service.CreateMany(ctx, objs, open)
+		assert.ErrorIs(t, err, io.ErrClosedPipe)
+
+		storage.AssertExpectations(t)
+	})
+
+	t.Run("error - invalid object fails before any write", func(t *testing.T) {
+		service, repo, storage := NewStowryService(t)
+		ctx := context.Background()
+
+		invalid := []stowry.CreateObject{objs[0], {Path: "../escape.txt", ContentType: "text/plain"}}
+
+		_, err := service.CreateMany(ctx, invalid, open)
+		assert.ErrorIs(t, err, stowry.ErrInvalidInput)
+
+		storage.AssertNotCalled(t, "Write", mock.Anything, mock.Anything, mock.Anything)
+		repo.AssertNotCalled(t, "UpsertBatch")
+	})
+
+	t.Run("error - failure keeps files of existing objects", func(t *testing.T) {
+		service, repo, storage := NewStowryService(t)
+		ctx := context.Background()
+		repo.On("ExistingPaths", ctx, []string{"a.txt", "b.txt"}).Return([]string{"a.txt"}, nil)
+
+		storage.On("Write", ctx, mock.Anything, mock.Anything).Return(stowry.SaveResult{BytesWritten: 5, Etag: "e"}, nil)
+		repo.On("UpsertBatch", ctx, mock.Anything).Return([]stowry.MetaData(nil), io.ErrClosedPipe)
+		storage.On("Delete", mock.Anything, "b.txt").Return(nil)
+
+		_, err := service.CreateMany(ctx, objs, open)
+		assert.ErrorIs(t, err, io.ErrClosedPipe)
+
+		storage.AssertExpectations(t)
+		storage.AssertNotCalled(t, "Delete", mock.Anything, "a.txt")
+	})
+}
+
+// failingBatchRepo fails every UpsertBatch, after the files are in place.
+type failingBatchRepo struct{ stowry.MetaDataRepo }
+
+func (failingBatchRepo) UpsertBatch(context.Context, []stowry.ObjectEntry) ([]stowry.MetaData, error) {
+	return nil, io.ErrClosedPipe
+}
+
+func TestStowryService_CreateMany_ExistingObject(t *testing.T) {
+	ctx := context.Background()
+	objs := []stowry.CreateObject{
+		{Path: "keep.txt", ContentType: "text/plain"},
+		{Path: "x.txt", ContentType: "text/plain"},
+	}
+	openErr := errors.New("unreadable")
+	failingOpen := func(obj stowry.CreateObject) (io.ReadCloser, error) {
+		if obj.Path == "x.txt" {
+			return nil, openErr
+		}
+		return io.NopCloser(strings.NewReader("replacement")), nil
+	}
+
+	tests := []struct {
+		name       string
+		newStorage func(t *testing.T) stowry.FileStorage
+		// Without renames the existing file is overwritten in place
+		wantContent string
+	}{
+		{
+			name: "filesystem",
+			newStorage: func(t *testing.T) stowry.FileStorage {
+				return filesystem.NewFileStorage(newStorageRoot(t))
+			},
+			wantContent: "original",
+		},
+		{
+			// Embedding hides memStorage's Move
+			name:        "without mover",
+			newStorage:  func(*testing.T) stowry.FileStorage { return struct{ stowry.FileStorage }{newMemStorage()} },
+			wantContent: "replacement",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service := newCopyService(t, tt.newStorage(t))
+			_, err := service.Create(ctx, stowry.CreateObject{Path: "keep.txt", ContentType: "text/plain"}, strings.NewReader("original"))
+			require.NoError(t, err)
+
+			_, err = service.CreateMany(ctx, objs, failingOpen)
+			require.ErrorIs(t, err, openErr)
+
+			assert.Equal(t, tt.wantContent, readObject(t, service, "keep.txt"))
+			_, _, err = service.Get(ctx, "x.txt")
+			assert.ErrorIs(t, err, stowry.ErrNotFound)
+		})
+	}
+
+	t.Run("upsert failure restores replaced files", func(t *testing.T) {
+		db, err := sqlite.Connect(ctx, filepath.Join(t.TempDir(), "stowry.db"), stowry.Tables{MetaData: "stowry_metadata"})
+		require.NoError(t, err)
+		t.Cleanup(func() { _ = db.Close() })
+		require.NoError(t, db.Migrate(ctx))
+
+		root := newStorageRoot(t)
+		storage := filesystem.NewFileStorage(root)
+		service, err := stowry.NewStowryService(db.GetRepo(), storage, stowry.ServiceConfig{Mode: stowry.ModeStore})
+		require.NoError(t, err)
+		_, err = service.Create(ctx, stowry.CreateObject{Path: "keep.txt", ContentType: "text/plain"}, strings.NewReader("original"))
+		require.NoError(t, err)
+
+		failing, err := stowry.NewStowryService(failingBatchRepo{db.GetRepo()}, storage, stowry.ServiceConfig{Mode: stowry.ModeStore})
+		require.NoError(t, err)
+		_, err = failing.CreateMany(ctx, objs, func(stowry.CreateObject) (io.ReadCloser, error) {
+			return io.NopCloser(strings.NewReader("replacement")), nil
+		})
+		require.ErrorIs(t, err, io.ErrClosedPipe)
+
+		assert.Equal(t, "original", readObject(t, service, "keep.txt"))
+		_, err = root.Stat("x.txt")
+		assert.ErrorIs(t, err, os.ErrNotExist)
+		err = fs.WalkDir(root.FS(), ".", func(path string, d fs.DirEntry, err error) error {
+			if err == nil && !d.IsDir() {
+				assert.False(t, strings.HasPrefix(path, stowry.StagePrefix), "stage file left behind: %s", path)
+			}
+			return err
+		})
+		require.NoError(t, err)
+	})
+}
+
 func TestStowryService_Get(t *testing.T) {
 	t.Run("success - get object in store mode", func(t *testing.T) {
 		service, repo, storage := NewStowryServiceWithMode(t, stowry.ModeStore)
@@ -495,8 +948,7 @@ func TestStowryService_Get(t *testing.T) {
 		mockFile := &mockReadSeekCloser{content: []byte("<html></html>")}
 
 		repo.On("Get", ctx, "documents").Return(stowry.MetaData{}, stowry.ErrNotFound)
-		repo.On("Get", ctx, "documents.html").Return(stowry.MetaData{}, stowry.ErrNotFound)
-		repo.On("Get", ctx, "documents/index.html").Return(indexMetadata, nil)
+		repo.On("GetMany", ctx, []string{"documents.html", "documents/index.html"}).Return([]stowry.MetaData{indexMetadata}, nil)
 		storage.On("Get", ctx, "documents/index.html").Return(mockFile, nil)
 
 		metadata, file, err := service.Get(ctx, "documents")
@@ -566,8 +1018,7 @@ func TestStowryService_Get(t *testing.T) {
 		ctx := context.Background()
 
 		repo.On("Get", ctx, "documents").Return(stowry.MetaData{}, stowry.ErrNotFound)
-		repo.On("Get", ctx, "documents.html").Return(stowry.MetaData{}, stowry.ErrNotFound)
-		repo.On("Get", ctx, "documents/index.html").Return(stowry.MetaData{}, stowry.ErrNotFound)
+		repo.On("GetMany", ctx, []string{"documents.html", "documents/index.html"}).Return([]stowry.MetaData{}, nil)
 
 		_, _, err := service.Get(ctx, "documents")
 		assert.Error(t, err)
@@ -744,12 +1195,8 @@ func TestStowryService_Get(t *testing.T) {
 		service, repo, storage := NewStowryServiceWithMode(t, stowry.ModeStatic)
 		ctx := context.Background()
 
-		// First call: index.html (from empty path conversion)
+		// The empty path tries only the index documents
 		repo.On("Get", ctx, "index.html").Return(stowry.MetaData{}, stowry.ErrNotFound).Once()
-		// Clean URL fallback: index.html.html
-		repo.On("Get", ctx, "index.html.html").Return(stowry.MetaData{}, stowry.ErrNotFound).Once()
-		// Directory index fallback: index.html/index.html
-		repo.On("Get", ctx, "index.html/index.html").Return(stowry.MetaData{}, stowry.ErrNotFound).Once()
 
 		_, _, err := service.Get(ctx, "")
 		assert.Error(t, err)
@@ -763,9 +1210,9 @@ func TestStowryService_Get(t *testing.T) {
 		service, repo, storage := NewStowryServiceWithMode(t, stowry.ModeSPA)
 		ctx := context.Background()
 
-		// First call: index.html (from empty path conversion)
-		// SPA fallback also tries index.html, so expect two calls
-		repo.On("Get", ctx, "index.html").Return(stowry.MetaData{}, stowry.ErrNotFound).Twice()
+		// The empty path resolves to index.html; the SPA fallback reuses
+		// that cached lookup instead of querying again
+		repo.On("Get", ctx, "index.html").Return(stowry.MetaData{}, stowry.ErrNotFound).Once()
 
 		_, _, err := service.Get(ctx, "")
 		assert.Error(t, err)
@@ -789,7 +1236,7 @@ func TestStowryService_Get(t *testing.T) {
 		mockFile := &mockReadSeekCloser{content: []byte("<html>About</html>")}
 
 		repo.On("Get", ctx, "about").Return(stowry.MetaData{}, stowry.ErrNotFound)
-		repo.On("Get", ctx, "about.html").Return(htmlMetadata, nil)
+		repo.On("GetMany", ctx, []string{"about.html", "about/index.html"}).Return([]stowry.MetaData{htmlMetadata}, nil)
 		storage.On("Get", ctx, "about.html").Return(mockFile, nil)
 
 		metadata, file, err := service.Get(ctx, "about")
@@ -814,8 +1261,8 @@ func TestStowryService_Get(t *testing.T) {
 
 		mockFile := &mockReadSeekCloser{content: []byte("<html>Docs</html>")}
 
-		repo.On("Get", ctx, "docs/").Return(stowry.MetaData{}, stowry.ErrNotFound)
-		repo.On("Get", ctx, "docs/index.html").Return(indexMetadata, nil)
+		repo.On("Get", ctx, "docs").Return(stowry.MetaData{}, stowry.ErrNotFound)
+		repo.On("GetMany", ctx, []string{"docs.html", "docs/index.html"}).Return([]stowry.MetaData{indexMetadata}, nil)
 		storage.On("Get", ctx, "docs/index.html").Return(mockFile, nil)
 
 		metadata, file, err := service.Get(ctx, "docs/")
@@ -831,8 +1278,8 @@ func TestStowryService_Get(t *testing.T) {
 		service, repo, storage := NewStowryServiceWithMode(t, stowry.ModeStatic)
 		ctx := context.Background()
 
-		repo.On("Get", ctx, "docs/").Return(stowry.MetaData{}, stowry.ErrNotFound)
-		repo.On("Get", ctx, "docs/index.html").Return(stowry.MetaData{}, stowry.ErrNotFound)
+		repo.On("Get", ctx, "docs").Return(stowry.MetaData{}, stowry.ErrNotFound)
+		repo.On("GetMany", ctx, []string{"docs.html", "docs/index.html"}).Return([]stowry.MetaData{}, nil)
 
 		_, _, err := service.Get(ctx, "docs/")
 		assert.Error(t, err)
@@ -842,6 +1289,33 @@ func TestStowryService_Get(t *testing.T) {
 		storage.AssertNotCalled(t, "Get")
 	})
 
+	t.Run("success - static mode trailing slash resolves a file like its clean URL", func(t *testing.T) {
+		service, repo, storage := NewStowryServiceWithMode(t, stowry.ModeStatic)
+		ctx := context.Background()
+
+		htmlMetadata := stowry.MetaData{Path: "about.html", ContentType: "text/html", Etag: "abc"}
+		mockFile := &mockReadSeekCloser{content: []byte("<html>About</html>")}
+
+		repo.On("Get", ctx, "about").Return(stowry.MetaData{}, stowry.ErrNotFound)
+		repo.On("GetMany", ctx, []string{"about.html", "about/index.html"}).Return([]stowry.MetaData{htmlMetadata}, nil)
+		storage.On("Get", ctx, "about.html").Return(mockFile, nil)
+
+		metadata, _, err := service.Get(ctx, "about/")
+		assert.NoError(t, err)
+		assert.Equal(t, "about.html", metadata.Path)
+	})
+
+	t.Run("error - store mode rejects trailing slash", func(t *testing.T) {
+		service, repo, storage := NewStowryServiceWithMode(t, stowry.ModeStore)
+		ctx := context.Background()
+
+		_, _, err := service.Get(ctx, "docs/")
+		assert.ErrorIs(t, err, stowry.ErrInvalidInput)
+
+		repo.AssertNotCalled(t, "Get")
+		storage.AssertNotCalled(t, "Get")
+	})
+
 	t.Run("success - static mode exact match takes priority over html fallback", func(t *testing.T) {
 		service, repo, storage := NewStowryServiceWithMode(t, stowry.ModeStatic)
 		ctx := context.Background()
@@ -905,6 +1379,61 @@ func (m *mockReadSeekCloser) Close() error {
 	return nil
 }
 
+func TestStowryService_GetDeleted(t *testing.T) {
+	deletedAt := time.Now()
+	deleted := stowry.MetaData{Path: "docs/a.txt", Etag: "abc123", DeletedAt: &deletedAt}
+
+	t.Run("success - opens the deleted file without fallbacks", func(t *testing.T) {
+		service, repo, storage := NewStowryServiceWithMode(t, stowry.ModeStatic)
+		ctx := context.Background()
+		mockFile := &mockReadSeekCloser{content: []byte("old")}
+
+		repo.On("GetDeleted", ctx, "docs/a.txt").Return(deleted, nil)
+		storage.On("Get", ctx, "docs/a.txt").Return(mockFile, nil)
+
+		metadata, file, err := service.GetDeleted(ctx, "docs/a.txt")
+		require.NoError(t, err)
+		assert.Equal(t, deleted, metadata)
+		assert.Same(t, mockFile, file)
+
+		repo.AssertNotCalled(t, "Get", mock.Anything, mock.Anything)
+		storage.AssertExpectations(t)
+	})
+
+	t.Run("error - gone when the file is missing", func(t *testing.T) {
+		service, repo, storage := NewStowryService(t)
+		ctx := context.Background()
+
+		repo.On("GetDeleted", ctx, "docs/a.txt").Return(deleted, nil)
+		storage.On("Get", ctx, "docs/a.txt").Return((*mockReadSeekCloser)(nil), stowry.ErrNotFound)
+
+		_, _, err := service.GetDeleted(ctx, "docs/a.txt")
+		assert.ErrorIs(t, err, stowry.ErrGone)
+	})
+
+	t.Run("error - passes repo errors through", func(t *testing.T) {
+		service, repo, _ := NewStowryService(t)
+		ctx := context.Background()
+
+		repo.On("GetDeleted", ctx, "docs/a.txt").Return(stowry.MetaData{}, stowry.ErrGone)
+		repo.On("GetDeleted", ctx, "docs/b.txt").Return(stowry.MetaData{}, stowry.ErrNotFound)
+
+		_, _, err := service.GetDeleted(ctx, "docs/a.txt")
+		assert.ErrorIs(t, err, stowry.ErrGone)
+		_, _, err = service.GetDeleted(ctx, "docs/b.txt")
+		assert.ErrorIs(t, err, stowry.ErrNotFound)
+	})
+
+	t.Run("error - rejects directory paths", func(t *testing.T) {
+		service, _, _ := NewStowryService(t)
+
+		_, _, err := service.GetDeleted(context.Background(), "docs/")
+		assert.ErrorIs(t, err, stowry.ErrInvalidInput)
+		_, _, err = service.GetDeleted(context.Background(), "")
+		assert.ErrorIs(t, err, stowry.ErrInvalidInput)
+	})
+}
+
 func TestStowryService_Delete(t *testing.T) {
 	t.Run("success - delete object", func(t *testing.T) {
 		service, repo, storage := NewStowryService(t)
@@ -944,6 +1473,16 @@ func TestStowryService_Delete(t *testing.T) {
 		storage.AssertNotCalled(t, "Delete")
 	})
 
+	t.Run("error - trailing slash", func(t *testing.T) {
+		service, repo, _ := NewStowryService(t)
+		ctx := context.Background()
+
+		err := service.Delete(ctx, "docs/")
+		assert.ErrorIs(t, err, stowry.ErrInvalidInput)
+
+		repo.AssertNotCalled(t, "Delete")
+	})
+
 	t.Run("error - repository delete fails", func(t *testing.T) {
 		service, repo, storage := NewStowryService(t)
 		ctx := context.Background()
@@ -1154,8 +1693,7 @@ func TestStowryService_Info(t *testing.T) {
 		}
 
 		repo.On("Get", ctx, "documents").Return(stowry.MetaData{}, stowry.ErrNotFound)
-		repo.On("Get", ctx, "documents.html").Return(stowry.MetaData{}, stowry.ErrNotFound)
-		repo.On("Get", ctx, "documents/index.html").Return(indexMetadata, nil)
+		repo.On("GetMany", ctx, []string{"documents.html", "documents/index.html"}).Return([]stowry.MetaData{indexMetadata}, nil)
 
 		metadata, err := service.Info(ctx, "documents")
 		assert.NoError(t, err)
@@ -1293,6 +1831,29 @@ func TestStowryService_Info(t *testing.T) {
 		repo.AssertExpectations(t)
 		storage.AssertNotCalled(t, "Get")
 	})
+
+	t.Run("spa mode caches the index.html lookup until it is written", func(t *testing.T) {
+		service, repo, _ := NewStowryServiceWithMode(t, stowry.ModeSPA)
+		ctx := context.Background()
+
+		repo.On("Get", ctx, "route-a").Return(stowry.MetaData{}, stowry.ErrNotFound)
+		repo.On("Get", ctx, "route-b").Return(stowry.MetaData{}, stowry.ErrNotFound)
+		repo.On("Get", ctx, "index.html").Return(stowry.MetaData{}, stowry.ErrNotFound).Once()
+
+		_, err := service.Info(ctx, "route-a")
+		assert.ErrorIs(t, err, stowry.ErrNotFound)
+		_, err = service.Info(ctx, "route-b")
+		assert.ErrorIs(t, err, stowry.ErrNotFound)
+		repo.AssertNumberOfCalls(t, "Get", 3)
+
+		repo.On("Delete", ctx, "index.html").Return(nil)
+		require.NoError(t, service.Delete(ctx, "index.html"))
+		repo.On("Get", ctx, "index.html").Return(stowry.MetaData{}, stowry.ErrNotFound).Once()
+
+		_, err = service.Info(ctx, "route-a")
+		assert.ErrorIs(t, err, stowry.ErrNotFound)
+		repo.AssertNumberOfCalls(t, "Get", 5)
+	})
 }
 
 func TestStowryService_Tombstone(t *testing.T) {
@@ -1649,3 +2210,505 @@ func TestStowryService_Tombstone(t *testing.T) {
 		storage.AssertNotCalled(t, "Delete")
 	})
 }
+
+func TestStowryService_TombstoneWithReport(t *testing.T) {
+	id1 := uuid.New()
+	id2 := uuid.New()
+	id3 := uuid.New()
+	query := stowry.ListQuery{Limit: 10}
+	pending := stowry.ListResult{
+		Items: []stowry.MetaData{
+			{ID: id1, Path: "a.txt", FileSizeBytes: 100},
+			{ID: id2, Path: "b.txt", FileSizeBytes: 200},
+			{ID: id3, Path: "c.txt", FileSizeBytes: 300},
+		},
+	}
+
+	t.Run("report counts bytes and skipped files", func(t *testing.T) {
+		service, repo, storage := NewStowryService(t)
+		ctx := context.Background()
+
+		repo.On("ListPendingCleanup", ctx, query).Return(pending, nil)
+		storage.On("Delete", ctx, "a.txt").Return(nil)
+		storage.On("Delete", ctx, "b.txt").Return(stowry.ErrNotFound)
+		storage.On("Delete", ctx, "c.txt").Return(nil)
+		repo.On("MarkCleanedUp", ctx, mock.Anything).Return(nil)
+
+		report, err := service.TombstoneWithReport(ctx, stowry.TombstoneOptions{Query: query})
+		assert.NoError(t, err)
+		assert.Equal(t, stowry.TombstoneReport{Cleaned: 3, BytesReclaimed: 400, Skipped: 1}, report)
+	})
+
+	t.Run("dry run deletes nothing", func(t *testing.T) {
+		service, repo, storage := NewStowryService(t)
+		ctx := context.Background()
+
+		repo.On("ListPendingCleanup", ctx, query).Return(pending, nil)
+
+		report, err := service.TombstoneWithReport(ctx, stowry.TombstoneOptions{Query: query, DryRun: true})
+		assert.NoError(t, err)
+		assert.Equal(t, stowry.TombstoneReport{Cleaned: 3, BytesReclaimed: 600}, report)
+
+		storage.AssertNotCalled(t, "Delete", mock.Anything, mock.Anything)
+		repo.AssertNotCalled(t, "MarkCleanedUp", mock.Anything, mock.Anything)
+	})
+
+	t.Run("error is recorded with partial report", func(t *testing.T) {
+		service, repo, storage := NewStowryService(t)
+		ctx := context.Background()
+
+		repo.On("ListPendingCleanup", ctx, query).Return(pending, nil)
+		storage.On("Delete", ctx, "a.txt").Return(nil)
+		storage.On("Delete", ctx, "b.txt").Return(io.ErrClosedPipe)
+		repo.On("MarkCleanedUp", ctx, id1).Return(nil)
+
+		var last progress.Event
+		report, err := service.TombstoneWithReport(ctx, stowry.TombstoneOptions{
+			Query:    query,
+			Progress: func(e progress.Event) { last = e },
+		})
+		assert.ErrorIs(t, err, io.ErrClosedPipe)
+		assert.Equal(t, 1, report.Cleaned)
+		assert.Equal(t, int64(100), report.BytesReclaimed)
+		require.Len(t, report.Errors, 1)
+		assert.Equal(t, "b.txt", report.Errors[0].Path)
+		assert.ErrorIs(t, report.Errors[0].Err, io.ErrClosedPipe)
+
+		assert.True(t, last.Done)
+		assert.Equal(t, "tombstone", last.Operation)
+		assert.Equal(t, int64(2), last.Processed)
+		assert.Equal(t, 1, last.Errors)
+		assert.Equal(t, int64(100), last.Bytes)
+	})
+}
+
+// deferringRepo is a SpyMetaDataRepo that also implements
+// stowry.CleanupDeferrer.
+type deferringRepo struct {
+	SpyMetaDataRepo
+}
+
+func (r *deferringRepo) DeferCleanup(ctx context.Context, id uuid.UUID, until time.Time) error {
+	return r.Called(ctx, id, until).Error(0)
+}
+
+func TestStowryService_Tombstone_ContinueOnError(t *testing.T) {
+	id1 := uuid.New()
+	id2 := uuid.New()
+	id3 := uuid.New()
+	query := stowry.ListQuery{Limit: 10}
+
+	newService := func(t *testing.T) (*stowry.StowryService, *deferringRepo, *SpyFileStorage) {
+		t.Helper()
+		repo := new(deferringRepo)
+		storage := new(SpyFileStorage)
+		s, err := stowry.NewStowryService(repo, storage, stowry.ServiceConfig{Mode: stowry.ModeStore})
+		require.NoError(t, err)
+		return s, repo, storage
+	}
+	pendingWith := func(retryAt *time.Time) stowry.ListResult {
+		return stowry.ListResult{Items: []stowry.MetaData{
+			{ID: id1, Path: "a.txt", FileSizeBytes: 100},
+			{ID: id2, Path: "b.txt", FileSizeBytes: 200, CleanupRetryAt: retryAt},
+			{ID: id3, Path: "c.txt", FileSizeBytes: 300},
+		}}
+	}
+
+	t.Run("failures are recorded, deferred and skipped", func(t *testing.T) {
+		service, repo, storage := newService(t)
+		ctx := context.Background()
+
+		repo.On("ListPendingCleanup", ctx, query).Return(pendingWith(nil), nil)
+		storage.On("Delete", ctx, "a.txt").Return(nil)
+		storage.On("Delete", ctx, "b.txt").Return(io.ErrClosedPipe)
+		storage.On("Delete", ctx, "c.txt").Return(nil)
+		repo.On("MarkCleanedUp", ctx, id1).Return(nil)
+		repo.On("MarkCleanedUp", ctx, id3).Return(errors.New("database is locked"))
+		repo.On("DeferCleanup", ctx, mock.Anything, mock.Anything).Return(nil)
+
+		start := time.Now()
+		report, err := service.TombstoneWithReport(ctx, stowry.TombstoneOptions{
+			Query:           query,
+			ContinueOnError: true,
+			RetryDelay:      10 * time.Minute,
+		})
+		require.NoError(t, err)
+		assert.Equal(t, 1, report.Cleaned)
+		assert.Equal(t, int64(100), report.BytesReclaimed)
+		assert.Equal(t, 2, report.Failed)
+		require.Len(t, report.Errors, 2)
+		assert.Equal(t, "b.txt", report.Errors[0].Path)
+		assert.ErrorIs(t, report.Errors[0].Err, io.ErrClosedPipe)
+		assert.Equal(t, "c.txt", report.Errors[1].Path)
+
+		repo.AssertNotCalled(t, "DeferCleanup", ctx, id1, mock.Anything)
+		for _, id := range []uuid.UUID{id2, id3} {
+			repo.AssertCalled(t, "DeferCleanup", ctx, id, mock.MatchedBy(func(until time.Time) bool {
+				return !until.Before(start.Add(10*time.Minute)) && until.Before(time.Now().Add(10*time.Minute+time.Second))
+			}))
+		}
+	})
+
+	t.Run("deferred item is left alone until its retry time", func(t *testing.T) {
+		service, repo, storage := newService(t)
+		ctx := context.Background()
+		later := time.Now().Add(time.Hour)
+
+		repo.On("ListPendingCleanup", ctx, query).Return(pendingWith(&later), nil)
+		storage.On("Delete", ctx, "a.txt").Return(nil)
+		storage.On("Delete", ctx, "c.txt").Return(nil)
+		repo.On("MarkCleanedUp", ctx, mock.Anything).Return(nil)
+
+		report, err := service.TombstoneWithReport(ctx, stowry.TombstoneOptions{Query: query, ContinueOnError: true})
+		require.NoError(t, err)
+		assert.Equal(t, stowry.TombstoneReport{Cleaned: 2, BytesReclaimed: 400, Deferred: 1}, report)
+		storage.AssertNotCalled(t, "Delete", ctx, "b.txt")
+	})
+
+	t.Run("retry deferred processes it early", func(t *testing.T) {
+		service, repo, storage := newService(t)
+		ctx := context.Background()
+		later := time.Now().Add(time.Hour)
+
+		repo.On("ListPendingCleanup", ctx, query).Return(pendingWith(&later), nil)
+		storage.On("Delete", ctx, mock.Anything).Return(nil)
+		repo.On("MarkCleanedUp", ctx, mock.Anything).Return(nil)
+
+		report, err := service.TombstoneWithReport(ctx, stowry.TombstoneOptions{Query: query, ContinueOnError: true, RetryDeferred: true})
+		require.NoError(t, err)
+		assert.Equal(t, stowry.TombstoneReport{Cleaned: 3, BytesReclaimed: 600}, report)
+	})
+
+	t.Run("succeeds once the retry time passed and the problem is fixed", func(t *testing.T) {
+		service, repo, storage := newService(t)
+		ctx := context.Background()
+		earlier := time.Now().Add(-time.Minute)
+
+		repo.On("ListPendingCleanup", ctx, query).Return(pendingWith(&earlier), nil)
+		storage.On("Delete", ctx, mock.Anything).Return(nil)
+		repo.On("MarkCleanedUp", ctx, mock.Anything).Return(nil)
+
+		report, err := service.TombstoneWithReport(ctx, stowry.TombstoneOptions{Query: query, ContinueOnError: true})
+		require.NoError(t, err)
+		assert.Equal(t, stowry.TombstoneReport{Cleaned: 3, BytesReclaimed: 600}, report)
+		repo.AssertNotCalled(t, "DeferCleanup", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("strict run stops at the first failure without deferring", func(t *testing.T) {
+		service, repo, storage := newService(t)
+		ctx := context.Background()
+
+		repo.On("ListPendingCleanup", ctx, query).Return(pendingWith(nil), nil)
+		storage.On("Delete", ctx, "a.txt").Return(nil)
+		storage.On("Delete", ctx, "b.txt").Return(io.ErrClosedPipe)
+		repo.On("MarkCleanedUp", ctx, id1).Return(nil)
+
+		report, err := service.TombstoneWithReport(ctx, stowry.TombstoneOptions{Query: query})
+		assert.ErrorIs(t, err, io.ErrClosedPipe)
+		assert.Equal(t, 1, report.Cleaned)
+		assert.Equal(t, 1, report.Failed)
+		storage.AssertNotCalled(t, "Delete", ctx, "c.txt")
+		repo.AssertNotCalled(t, "DeferCleanup", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("continues without a deferrer", func(t *testing.T) {
+		service, repo, storage := NewStowryService(t)
+		ctx := context.Background()
+
+		repo.On("ListPendingCleanup", ctx, query).Return(pendingWith(nil), nil)
+		storage.On("Delete", ctx, "a.txt").Return(nil)
+		storage.On("Delete", ctx, "b.txt").Return(io.ErrClosedPipe)
+		storage.On("Delete", ctx, "c.txt").Return(nil)
+		repo.On("MarkCleanedUp", ctx, mock.Anything).Return(nil)
+
+		report, err := service.TombstoneWithReport(ctx, stowry.TombstoneOptions{Query: query, ContinueOnError: true})
+		require.NoError(t, err)
+		assert.Equal(t, 2, report.Cleaned)
+		assert.Equal(t, 1, report.Failed)
+	})
+}
+
+// SpyStatStorage is a SpyFileStorage that also implements stowry.StorageStater.
+type SpyStatStorage struct {
+	SpyFileStorage
+}
+
+func (s *SpyStatStorage) Stat(ctx context.Context, path string) (stowry.StoredFile, error) {
+	args := s.Called(ctx, path)
+	return args.Get(0).(stowry.StoredFile), args.Error(1)
+}
+
+func TestStowryService_Tombstone_VerifiesFile(t *testing.T) {
+	id := uuid.New()
+	query := stowry.ListQuery{Limit: 10}
+	deleted := stowry.MetaData{
+		ID:            id,
+		Path:          "a.txt",
+		FileSizeBytes: 5,
+		Etag:          "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824", // sha256("hello")
+	}
+	pending := stowry.ListResult{Items: []stowry.MetaData{deleted}}
+
+	newService := func(t *testing.T) (*stowry.StowryService, *SpyMetaDataRepo, *SpyStatStorage) {
+		t.Helper()
+		repo := new(SpyMetaDataRepo)
+		storage := new(SpyStatStorage)
+		s, err := stowry.NewStowryService(repo, storage, stowry.ServiceConfig{Mode: stowry.ModeStore})
+		require.NoError(t, err)
+		repo.On("ListPendingCleanup", mock.Anything, query).Return(pending, nil)
+		return s, repo, storage
+	}
+
+	t.Run("matching size is deleted", func(t *testing.T) {
+		service, repo, storage := newService(t)
+		ctx := context.Background()
+
+		storage.On("Stat", ctx, "a.txt").Return(stowry.StoredFile{Path: "a.txt", Size: 5}, nil)
+		storage.On("Delete", ctx, "a.txt").Return(nil)
+		repo.On("MarkCleanedUp", ctx, id).Return(nil)
+
+		report, err := service.TombstoneWithReport(ctx, stowry.TombstoneOptions{Query: query})
+		assert.NoError(t, err)
+		assert.Equal(t, stowry.TombstoneReport{Cleaned: 1, BytesReclaimed: 5}, report)
+		storage.AssertExpectations(t)
+	})
+
+	t.Run("size mismatch keeps the file and the pending item", func(t *testing.T) {
+		service, repo, storage := newService(t)
+		ctx := context.Background()
+
+		storage.On("Stat", ctx, "a.txt").Return(stowry.StoredFile{Path: "a.txt", Size: 42}, nil)
+
+		report, err := service.TombstoneWithReport(ctx, stowry.TombstoneOptions{Query: query})
+		assert.NoError(t, err)
+		assert.Equal(t, stowry.TombstoneReport{Mismatched: 1}, report)
+		storage.AssertNotCalled(t, "Delete", mock.Anything, mock.Anything)
+		repo.AssertNotCalled(t, "MarkCleanedUp", mock.Anything, mock.Anything)
+	})
+
+	t.Run("force marks a mismatch cleaned up but keeps the file", func(t *testing.T) {
+		service, repo, storage := newService(t)
+		ctx := context.Background()
+
+		storage.On("Stat", ctx, "a.txt").Return(stowry.StoredFile{Path: "a.txt", Size: 42}, nil)
+		repo.On("MarkCleanedUp", ctx, id).Return(nil)
+
+		report, err := service.TombstoneWithReport(ctx, stowry.TombstoneOptions{Query: query, Force: true})
+		assert.NoError(t, err)
+		assert.Equal(t, stowry.TombstoneReport{Cleaned: 1, Mismatched: 1}, report)
+		storage.AssertNotCalled(t, "Delete", mock.Anything, mock.Anything)
+		repo.AssertExpectations(t)
+	})
+
+	t.Run("dry run reports mismatches", func(t *testing.T) {
+		service, repo, storage := newService(t)
+		ctx := context.Background()
+
+		storage.On("Stat", ctx, "a.txt").Return(stowry.StoredFile{Path: "a.txt", Size: 42}, nil)
+
+		report, err := service.TombstoneWithReport(ctx, stowry.TombstoneOptions{Query: query, DryRun: true})
+		assert.NoError(t, err)
+		assert.Equal(t, stowry.TombstoneReport{Mismatched: 1}, report)
+		repo.AssertNotCalled(t, "MarkCleanedUp", mock.Anything, mock.Anything)
+	})
+
+	t.Run("verify hash catches same-size content", func(t *testing.T) {
+		service, repo, storage := newService(t)
+		ctx := context.Background()
+
+		storage.On("Stat", ctx, "a.txt").Return(stowry.StoredFile{Path: "a.txt", Size: 5}, nil)
+		storage.On("Get", ctx, "a.txt").Return(&mockReadSeekCloser{content: []byte("world")}, nil)
+
+		report, err := service.TombstoneWithReport(ctx, stowry.TombstoneOptions{Query: query, VerifyHash: true})
+		assert.NoError(t, err)
+		assert.Equal(t, stowry.TombstoneReport{Mismatched: 1}, report)
+		storage.AssertNotCalled(t, "Delete", mock.Anything, mock.Anything)
+		repo.AssertNotCalled(t, "MarkCleanedUp", mock.Anything, mock.Anything)
+	})
+
+	t.Run("verify hash deletes matching content", func(t *testing.T) {
+		service, repo, storage := newService(t)
+		ctx := context.Background()
+
+		storage.On("Stat", ctx, "a.txt").Return(stowry.StoredFile{Path: "a.txt", Size: 5}, nil)
+		storage.On("Get", ctx, "a.txt").Return(&mockReadSeekCloser{content: []byte("hello")}, nil)
+		storage.On("Delete", ctx, "a.txt").Return(nil)
+		repo.On("MarkCleanedUp", ctx, id).Return(nil)
+
+		report, err := service.TombstoneWithReport(ctx, stowry.TombstoneOptions{Query: query, VerifyHash: true})
+		assert.NoError(t, err)
+		assert.Equal(t, stowry.TombstoneReport{Cleaned: 1, BytesReclaimed: 5}, report)
+	})
+
+	t.Run("missing file is still marked cleaned up", func(t *testing.T) {
+		service, repo, storage := newService(t)
+		ctx := context.Background()
+
+		storage.On("Stat", ctx, "a.txt").Return(stowry.StoredFile{}, stowry.ErrNotFound)
+		storage.On("Delete", ctx, "a.txt").Return(stowry.ErrNotFound)
+		repo.On("MarkCleanedUp", ctx, id).Return(nil)
+
+		report, err := service.TombstoneWithReport(ctx, stowry.TombstoneOptions{Query: query})
+		assert.NoError(t, err)
+		assert.Equal(t, stowry.TombstoneReport{Cleaned: 1, Skipped: 1}, report)
+	})
+
+	t.Run("re-created file on disk survives", func(t *testing.T) {
+		root, err := os.OpenRoot(t.TempDir())
+		require.NoError(t, err)
+		t.Cleanup(func() { _ = root.Close() })
+		storage := filesystem.NewFileStorage(root)
+		repo := new(SpyMetaDataRepo)
+		service, err := stowry.NewStowryService(repo, storage, stowry.ServiceConfig{Mode: stowry.ModeStore})
+		require.NoError(t, err)
+		ctx := context.Background()
+
+		// The path was written again after the delete
+		_, err = storage.Write(ctx, "a.txt", strings.NewReader("live content"))
+		require.NoError(t, err)
+		repo.On("ListPendingCleanup", ctx, query).Return(pending, nil)
+
+		report, err := service.TombstoneWithReport(ctx, stowry.TombstoneOptions{Query: query})
+		assert.NoError(t, err)
+		assert.Equal(t, 1, report.Mismatched)
+
+		content, err := root.ReadFile("a.txt")
+		require.NoError(t, err)
+		assert.Equal(t, "live content", string(content))
+		repo.AssertNotCalled(t, "MarkCleanedUp", mock.Anything, mock.Anything)
+	})
+}
+
+func newOrphanService(t *testing.T, files []stowry.StoredFile, batchSize int) (*stowry.StowryService, *SpyMetaDataRepo, *SpyWalkingStorage) {
+	t.Helper()
+	repo := new(SpyMetaDataRepo)
+	storage := &SpyWalkingStorage{files: files}
+	s, err := stowry.NewStowryService(repo, storage, stowry.ServiceConfig{Mode: stowry.ModeStore, BatchSize: batchSize})
+	require.NoError(t, err)
+	return s, repo, storage
+}
+
+func TestStowryService_CollectOrphans(t *testing.T) {
+	old := time.Now().Add(-48 * time.Hour)
+	files := []stowry.StoredFile{
+		{Path: "a.txt", Size: 10, ModTime: old},
+		{Path: "b.txt", Size: 20, ModTime: old},
+		{Path: "c.txt", Size: 30, ModTime: old},
+		{Path: "fresh.txt", Size: 40, ModTime: time.Now()},
+		{Path: ".stowry-quarantine/old.txt", Size: 50, ModTime: old},
+		{Path: stowry.InstanceFile, Size: 37, ModTime: old},
+	}
+
+	t.Run("report only", func(t *testing.T) {
+		service, repo, storage := newOrphanService(t, files, 2)
+		ctx := context.Background()
+		repo.On("ExistingPaths", ctx, []string{"a.txt", "b.txt"}).Return([]string{"a.txt"}, nil)
+		repo.On("ExistingPaths", ctx, []string{"c.txt"}).Return([]string{}, nil)
+
+		var last progress.Event
+		report, err := service.CollectOrphans(ctx, stowry.OrphanOptions{
+			MinAge:   time.Hour,
+			Progress: func(e progress.Event) { last = e },
+		})
+
+		require.NoError(t, err)
+		assert.Equal(t, 4, report.Scanned)
+		assert.Equal(t, 1, report.TooRecent)
+		assert.Equal(t, 2, report.Orphans)
+		assert.Equal(t, int64(50), report.Bytes)
+		assert.Equal(t, 0, report.Handled)
+		assert.Equal(t, []string{"b.txt", "c.txt"}, report.Paths)
+		assert.True(t, last.Done)
+		assert.Equal(t, int64(4), last.Processed, "every scanned file is reported once")
+		assert.Equal(t, int64(100), last.Bytes)
+		storage.AssertNotCalled(t, "Delete", mock.Anything, mock.Anything)
+	})
+
+	t.Run("delete continues past errors", func(t *testing.T) {
+		service, repo, storage := newOrphanService(t, files, 0)
+		ctx := context.Background()
+		repo.On("ExistingPaths", ctx, []string{"a.txt", "b.txt", "c.txt"}).Return([]string{"a.txt"}, nil)
+		storage.On("Delete", ctx, "b.txt").Return(io.ErrClosedPipe)
+		storage.On("Delete", ctx, "c.txt").Return(nil)
+
+		report, err := service.CollectOrphans(ctx, stowry.OrphanOptions{MinAge: time.Hour, Action: stowry.OrphanActionDelete})
+
+		require.NoError(t, err)
+		assert.Equal(t, 2, report.Orphans)
+		assert.Equal(t, 1, report.Handled)
+		require.Len(t, report.Errors, 1)
+		assert.Equal(t, "b.txt", report.Errors[0].Path)
+		storage.AssertExpectations(t)
+	})
+
+	t.Run("quarantine", func(t *testing.T) {
+		service, repo, storage := newOrphanService(t, files, 0)
+		ctx := context.Background()
+		repo.On("ExistingPaths", ctx, mock.Anything).Return([]string{"a.txt", "b.txt", ".stowry-quarantine/old.txt"}, nil)
+		storage.On("Move", ctx, "c.txt", "trash/c.txt").Return(nil)
+
+		report, err := service.CollectOrphans(ctx, stowry.OrphanOptions{
+			MinAge:           time.Hour,
+			Action:           stowry.OrphanActionQuarantine,
+			QuarantinePrefix: "trash",
+		})
+
+		require.NoError(t, err)
+		assert.Equal(t, 1, report.Handled)
+		storage.AssertExpectations(t)
+	})
+
+	t.Run("repo error", func(t *testing.T) {
+		service, repo, _ := newOrphanService(t, files, 0)
+		ctx := context.Background()
+		repo.On("ExistingPaths", ctx, mock.Anything).Return([]string(nil), io.ErrUnexpectedEOF)
+
+		_, err := service.CollectOrphans(ctx, stowry.OrphanOptions{})
+
+		assert.ErrorIs(t, err, io.ErrUnexpectedEOF)
+	})
+
+	t.Run("unknown action", func(t *testing.T) {
+		service, _, _ := newOrphanService(t, files, 0)
+
+		_, err := service.CollectOrphans(context.Background(), stowry.OrphanOptions{Action: "shred"})
+
+		assert.ErrorIs(t, err, stowry.ErrInvalidInput)
+	})
+
+	t.Run("storage without walker", func(t *testing.T) {
+		service, _, _ := NewStowryService(t)
+
+		_, err := service.CollectOrphans(context.Background(), stowry.OrphanOptions{})
+
+		assert.ErrorIs(t, err, stowry.ErrInvalidInput)
+	})
+
+	t.Run("populate does not resurrect quarantined files", func(t *testing.T) {
+		ctx := context.Background()
+		db, err := sqlite.Connect(ctx, filepath.Join(t.TempDir(), "stowry.db"), stowry.Tables{MetaData: "stowry_metadata"})
+		require.NoError(t, err)
+		t.Cleanup(func() { _ = db.Close() })
+		require.NoError(t, db.Migrate(ctx))
+
+		root := newStorageRoot(t)
+		service, err := stowry.NewStowryService(db.GetRepo(), filesystem.NewFileStorage(root), stowry.ServiceConfig{Mode: stowry.ModeStore})
+		require.NoError(t, err)
+		_, err = service.Create(ctx, stowry.CreateObject{Path: "keep.txt", ContentType: "text/plain"}, strings.NewReader("keep"))
+		require.NoError(t, err)
+		require.NoError(t, root.WriteFile("orphan.txt", []byte("orphan"), 0o644))
+
+		report, err := service.CollectOrphans(ctx, stowry.OrphanOptions{Action: stowry.OrphanActionQuarantine})
+		require.NoError(t, err)
+		require.Equal(t, []string{"orphan.txt"}, report.Paths)
+		_, err = root.Stat(stowry.DefaultQuarantinePrefix + "orphan.txt")
+		require.NoError(t, err)
+
+		require.NoError(t, service.Populate(ctx))
+
+		list, err := service.List(ctx, stowry.ListQuery{Limit: 10})
+		require.NoError(t, err)
+		require.Len(t, list.Items, 1)
+		assert.Equal(t, "keep.txt", list.Items[0].Path)
+	})
+}
@@ -29,8 +29,113 @@ const (
 	AWSExpiresParam       = "X-Amz-Expires"
 	AWSSignedHeadersParam = "X-Amz-SignedHeaders"
 	AWSSignatureParam     = "X-Amz-Signature"
+
+	// Upload constraints carried by a Stowry native presigned URL. When
+	// present they are part of the signature.
+	StowryContentTypeParam = "X-Stowry-Content-Type"
+	StowryMaxSizeParam     = "X-Stowry-Max-Size"
 )
 
+// PresignConstraints restrict the upload a presigned PUT URL allows.
+type PresignConstraints struct {
+	ContentType string // Required Content-Type of the upload. Empty allows any.
+	MaxSize     int64  // Maximum body size in bytes. 0 allows any size.
+}
+
+// IsZero reports whether c places no restriction on the upload.
+func (c PresignConstraints) IsZero() bool {
+	return c.ContentType == "" && c.MaxSize == 0
+}
+
+// ParsePresignConstraints reads the upload constraints from a presigned URL
+// query. A URL without constraint parameters yields the zero value.
+func ParsePresignConstraints(query url.Values) (PresignConstraints, error) {
+	c := PresignConstraints{ContentType: query.Get(StowryContentTypeParam)}
+
+	if sizeStr := query.Get(StowryMaxSizeParam); sizeStr != "" {
+		size, err := strconv.ParseInt(sizeStr, 10, 64)
+		if err != nil || size <= 0 {
+			return PresignConstraints{}, fmt.Errorf("invalid %s: must be a positive integer", StowryMaxSizeParam)
+		}
+		c.MaxSize = size
+	}
+
+	return c, nil
+}
+
+// operationParams are the query parameters, by method and in alphabetical
+// order, that select what a request does. A Stowry native signature covers
+// those a URL carries, so a URL presigned for one operation cannot be
+// replayed as another by adding or changing them, such as a soft delete as a
+// purge or a multipart abort, a prefix delete dry run as a real one, a
+// restore as the completion of an upload, or a move to a different
+// destination.
+var operationParams = map[string][]string{
+	http.MethodDelete: {"dry_run", "force", "prefix", "purge", "uploadId"},
+	http.MethodPost:   {"rename-to", "restore", "uploadId", "uploads"},
+	http.MethodPut:    {"partNumber", "uploadId"},
+}
+
+// OperationParams returns the query parameters of a request with method that
+// a Stowry native signature covers. The result is nil when there are none.
+func OperationParams(method string, query url.Values) url.Values {
+	var op url.Values
+	for _, name := range operationParams[method] {
+		if values, ok := query[name]; ok {
+			if op == nil {
+				op = url.Values{}
+			}
+			op[name] = values
+		}
+	}
+	return op
+}
+
+// SignStowry computes a Stowry native signature that also covers upload
+// constraints and operation parameters. The string to sign extends the
+// stowry-go format with one line per constraint and then one line per value
+// of each operation parameter, parameters in alphabetical order:
+//
+//	{METHOD}\n{PATH}\n{TIMESTAMP}\n{EXPIRES}\ncontent-type:{TYPE}\nmax-size:{BYTES}
+//	{METHOD}\n{PATH}\n{TIMESTAMP}\n{EXPIRES}\n{NAME}:{VALUE}
+//
+// Without constraints and operation parameters the result equals
+// stowrysign.Sign, so URLs minted by the SDKs verify unchanged.
+func SignStowry(secretKey, method, path string, timestamp, expires int64, c PresignConstraints, op url.Values) string {
+	if c.IsZero() && len(op) == 0 {
+		return stowrysign.Sign(secretKey, method, path, timestamp, expires)
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s\n%s\n%d\n%d", method, path, timestamp, expires)
+	if !c.IsZero() {
+		fmt.Fprintf(&b, "\ncontent-type:%s\nmax-size:%d", c.ContentType, c.MaxSize)
+	}
+	for _, name := range operationParams[method] {
+		for _, value := range op[name] {
+			fmt.Fprintf(&b, "\n%s:%s", name, value)
+		}
+	}
+	return hex.EncodeToString(hmacSHA256([]byte(secretKey), []byte(b.String())))
+}
+
+// PresignStowryQuery returns the query parameters of a Stowry native
+// presigned URL for method and path, valid for expires seconds from timestamp.
+func PresignStowryQuery(accessKey, secretKey, method, path string, timestamp, expires int64, c PresignConstraints) url.Values {
+	query := url.Values{}
+	query.Set(stowrysign.StowryCredentialParam, accessKey)
+	query.Set(stowrysign.StowryDateParam, strconv.FormatInt(timestamp, 10))
+	query.Set(stowrysign.StowryExpiresParam, strconv.FormatInt(expires, 10))
+	if c.ContentType != "" {
+		query.Set(StowryContentTypeParam, c.ContentType)
+	}
+	if c.MaxSize > 0 {
+		query.Set(StowryMaxSizeParam, strconv.FormatInt(c.MaxSize, 10))
+	}
+	query.Set(stowrysign.StowrySignatureParam, SignStowry(secretKey, method, path, timestamp, expires, c, nil))
+	return query
+}
+
 // SecretStore provides access key lookup for signature verification.
 // Implementations can retrieve keys from various sources (local files, Vault, SSM, etc.).
 type SecretStore interface {
@@ -70,6 +175,20 @@ func NewSignatureVerifier(cfg AuthConfig, store SecretStore) *SignatureVerifier
 	}
 }
 
+// Validate reports configuration that would reject every request: an AWS
+// region without a service or the other way round, or a store that can
+// report its size (such as keybackend.MapSecretStore) holding no keys.
+func (v *SignatureVerifier) Validate() error {
+	var errs []error
+	if (v.awsVerifier.Region == "") != (v.awsVerifier.Service == "") {
+		errs = append(errs, errors.New("aws region and service must be set together"))
+	}
+	if counter, ok := v.stowryVerifier.store.(interface{ Len() int }); ok && counter.Len() == 0 {
+		errs = append(errs, errors.New("no access keys configured"))
+	}
+	return errors.Join(errs...)
+}
+
 // Verify verifies a signed HTTP request using the appropriate signing scheme.
 // It detects the scheme from query parameters:
 //   - X-Stowry-Signature: Uses native Stowry verification
@@ -137,12 +256,17 @@ func (v *StowrySignatureVerifier) Verify(r *http.Request) error {
 		return errors.New("signature expired")
 	}
 
+	constraints, err := ParsePresignConstraints(query)
+	if err != nil {
+		return err
+	}
+
 	secretKey, err := v.store.Lookup(credential)
 	if err != nil {
 		return fmt.Errorf("lookup access key: %w", err)
 	}
 
-	expectedSignature := stowrysign.Sign(secretKey, r.Method, r.URL.Path, timestamp, expires)
+	expectedSignature := SignStowry(secretKey, r.Method, r.URL.Path, timestamp, expires, constraints, OperationParams(r.Method, query))
 
 	if !hmac.Equal([]byte(expectedSignature), []byte(signature)) {
 		return errors.New("signature mismatch")
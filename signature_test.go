@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"net/http"
 	"net/url"
+	"strconv"
 	"testing"
 	"time"
 
@@ -11,6 +12,7 @@ import (
 	stowrysign "github.com/sagarc03/stowry-go"
 	"github.com/sagarc03/stowry/keybackend"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestAWSSignatureVerifier_Verify(t *testing.T) {
@@ -241,6 +243,41 @@ func TestNewSignatureVerifier(t *testing.T) {
 	assert.NotNil(t, verifier)
 }
 
+func TestSignatureVerifier_Validate(t *testing.T) {
+	store := keybackend.NewMapSecretStore(map[string]string{"test": "secret"})
+
+	tests := []struct {
+		name    string
+		cfg     stowry.AuthConfig
+		store   stowry.SecretStore
+		wantErr []string
+	}{
+		{name: "aws configured", cfg: stowry.AuthConfig{AWS: stowry.AWSConfig{Region: "us-east-1", Service: "s3"}}, store: store},
+		{name: "stowry signing only", store: store},
+		{name: "region without service", cfg: stowry.AuthConfig{AWS: stowry.AWSConfig{Region: "us-east-1"}}, store: store, wantErr: []string{"aws region and service must be set together"}},
+		{name: "service without region", cfg: stowry.AuthConfig{AWS: stowry.AWSConfig{Service: "s3"}}, store: store, wantErr: []string{"aws region and service must be set together"}},
+		{
+			name:    "every problem is reported",
+			cfg:     stowry.AuthConfig{AWS: stowry.AWSConfig{Service: "s3"}},
+			store:   keybackend.NewMapSecretStore(map[string]string{}),
+			wantErr: []string{"aws region and service must be set together", "no access keys configured"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := stowry.NewSignatureVerifier(tt.cfg, tt.store).Validate()
+			if len(tt.wantErr) == 0 {
+				assert.NoError(t, err)
+				return
+			}
+			require.Error(t, err)
+			for _, want := range tt.wantErr {
+				assert.Contains(t, err.Error(), want)
+			}
+		})
+	}
+}
+
 func TestStowrySignatureVerifier_Verify(t *testing.T) {
 	const (
 		accessKey = "STOWRYTEST"
@@ -531,3 +568,167 @@ func TestSignatureVerifier_Verify(t *testing.T) {
 		assert.NoError(t, err)
 	})
 }
+
+func TestSignStowry(t *testing.T) {
+	t.Run("without constraints matches the SDK", func(t *testing.T) {
+		got := stowry.SignStowry("secret", "PUT", "/a.txt", 1700000000, 900, stowry.PresignConstraints{}, nil)
+		assert.Equal(t, stowrysign.Sign("secret", "PUT", "/a.txt", 1700000000, 900), got)
+	})
+
+	t.Run("constraints change the signature", func(t *testing.T) {
+		base := stowry.SignStowry("secret", "PUT", "/a.txt", 1700000000, 900, stowry.PresignConstraints{}, nil)
+		typed := stowry.SignStowry("secret", "PUT", "/a.txt", 1700000000, 900, stowry.PresignConstraints{ContentType: "image/png"}, nil)
+		sized := stowry.SignStowry("secret", "PUT", "/a.txt", 1700000000, 900, stowry.PresignConstraints{MaxSize: 10}, nil)
+
+		assert.NotEqual(t, base, typed)
+		assert.NotEqual(t, base, sized)
+		assert.NotEqual(t, typed, sized)
+	})
+}
+
+func TestStowrySignatureVerifier_VerifyConstraints(t *testing.T) {
+	store := keybackend.NewMapSecretStore(map[string]string{"key": "secret"})
+	verifier := stowry.NewStowrySignatureVerifier(store)
+	constraints := stowry.PresignConstraints{ContentType: "image/png", MaxSize: 1024}
+	query := stowry.PresignStowryQuery("key", "secret", "PUT", "/a.png", time.Now().Unix(), 900, constraints)
+
+	request := func(q url.Values) *http.Request {
+		return &http.Request{Method: "PUT", URL: &url.URL{Path: "/a.png", RawQuery: q.Encode()}}
+	}
+
+	assert.NoError(t, verifier.Verify(request(query)))
+
+	parsed, err := stowry.ParsePresignConstraints(query)
+	assert.NoError(t, err)
+	assert.Equal(t, constraints, parsed)
+
+	tests := []struct {
+		name      string
+		tamper    func(q url.Values)
+		wantError string
+	}{
+		{name: "raised max size", tamper: func(q url.Values) { q.Set("X-Stowry-Max-Size", "4096") }, wantError: "signature mismatch"},
+		{name: "removed max size", tamper: func(q url.Values) { q.Del("X-Stowry-Max-Size") }, wantError: "signature mismatch"},
+		{name: "changed content type", tamper: func(q url.Values) { q.Set("X-Stowry-Content-Type", "text/html") }, wantError: "signature mismatch"},
+		{name: "malformed max size", tamper: func(q url.Values) { q.Set("X-Stowry-Max-Size", "big") }, wantError: "invalid X-Stowry-Max-Size"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q := url.Values{}
+			for k, v := range query {
+				q[k] = append([]string(nil), v...)
+			}
+			tt.tamper(q)
+
+			err := verifier.Verify(request(q))
+			assert.ErrorContains(t, err, tt.wantError)
+		})
+	}
+}
+
+func TestStowrySignatureVerifier_VerifyOperationParams(t *testing.T) {
+	store := keybackend.NewMapSecretStore(map[string]string{"key": "secret"})
+	verifier := stowry.NewStowrySignatureVerifier(store)
+	now := time.Now().Unix()
+
+	// presign mirrors a client signing method, path and the operation
+	// parameters in op
+	presign := func(method string, op url.Values) url.Values {
+		q := url.Values{}
+		for k, v := range op {
+			q[k] = v
+		}
+		q.Set(stowrysign.StowryCredentialParam, "key")
+		q.Set(stowrysign.StowryDateParam, strconv.FormatInt(now, 10))
+		q.Set(stowrysign.StowryExpiresParam, "900")
+		q.Set(stowrysign.StowrySignatureParam, stowry.SignStowry("secret", method, "/a.txt", now, 900, stowry.PresignConstraints{}, op))
+		return q
+	}
+	request := func(method string, q url.Values) *http.Request {
+		return &http.Request{Method: method, URL: &url.URL{Path: "/a.txt", RawQuery: q.Encode()}}
+	}
+
+	t.Run("signed purge verifies", func(t *testing.T) {
+		assert.NoError(t, verifier.Verify(request(http.MethodDelete, presign(http.MethodDelete, url.Values{"purge": {"1"}}))))
+	})
+
+	t.Run("purge added to a soft delete", func(t *testing.T) {
+		q := presign(http.MethodDelete, nil)
+		require.NoError(t, verifier.Verify(request(http.MethodDelete, q)))
+
+		q.Set("purge", "")
+		assert.ErrorContains(t, verifier.Verify(request(http.MethodDelete, q)), "signature mismatch")
+	})
+
+	t.Run("purge removed", func(t *testing.T) {
+		q := presign(http.MethodDelete, url.Values{"purge": {"1"}})
+		q.Del("purge")
+		assert.ErrorContains(t, verifier.Verify(request(http.MethodDelete, q)), "signature mismatch")
+	})
+
+	t.Run("prefix delete parameters changed", func(t *testing.T) {
+		signed := url.Values{"prefix": {"tmp/"}, "dry_run": {"true"}}
+		require.NoError(t, verifier.Verify(request(http.MethodDelete, presign(http.MethodDelete, signed))))
+
+		q := presign(http.MethodDelete, signed)
+		q.Del("dry_run")
+		assert.ErrorContains(t, verifier.Verify(request(http.MethodDelete, q)), "signature mismatch")
+
+		q = presign(http.MethodDelete, signed)
+		q.Set("prefix", "")
+		q.Set("force", "true")
+		assert.ErrorContains(t, verifier.Verify(request(http.MethodDelete, q)), "signature mismatch")
+	})
+
+	t.Run("abort added to a delete", func(t *testing.T) {
+		q := presign(http.MethodDelete, nil)
+		q.Set("uploadId", "3f1c2a4e-8b7d-4c6e-9a1f-2b3c4d5e6f70")
+		assert.ErrorContains(t, verifier.Verify(request(http.MethodDelete, q)), "signature mismatch")
+	})
+
+	t.Run("signed multipart parameters verify", func(t *testing.T) {
+		assert.NoError(t, verifier.Verify(request(http.MethodPost, presign(http.MethodPost, url.Values{"uploads": {""}}))))
+		assert.NoError(t, verifier.Verify(request(http.MethodPut, presign(http.MethodPut, url.Values{"partNumber": {"1"}, "uploadId": {"abc"}}))))
+	})
+
+	t.Run("post switched to another operation", func(t *testing.T) {
+		signed := url.Values{"restore": {""}}
+		require.NoError(t, verifier.Verify(request(http.MethodPost, presign(http.MethodPost, signed))))
+
+		for _, param := range []string{"uploads", "uploadId"} {
+			q := presign(http.MethodPost, signed)
+			q.Del("restore")
+			q.Set(param, "abc")
+			assert.ErrorContains(t, verifier.Verify(request(http.MethodPost, q)), "signature mismatch", param)
+		}
+	})
+
+	t.Run("put switched to a part upload", func(t *testing.T) {
+		q := presign(http.MethodPut, nil)
+		q.Set("partNumber", "1")
+		q.Set("uploadId", "abc")
+		assert.ErrorContains(t, verifier.Verify(request(http.MethodPut, q)), "signature mismatch")
+	})
+
+	t.Run("signed destination verifies", func(t *testing.T) {
+		assert.NoError(t, verifier.Verify(request(http.MethodPost, presign(http.MethodPost, url.Values{"rename-to": {"b.txt"}}))))
+	})
+
+	t.Run("destination changed", func(t *testing.T) {
+		q := presign(http.MethodPost, url.Values{"rename-to": {"b.txt"}})
+		q.Set("rename-to", "c.txt")
+		assert.ErrorContains(t, verifier.Verify(request(http.MethodPost, q)), "signature mismatch")
+	})
+
+	t.Run("destination added to another post", func(t *testing.T) {
+		q := presign(http.MethodPost, nil)
+		q.Set("rename-to", "b.txt")
+		assert.ErrorContains(t, verifier.Verify(request(http.MethodPost, q)), "signature mismatch")
+	})
+
+	t.Run("parameters of other methods are not covered", func(t *testing.T) {
+		q := presign(http.MethodGet, nil)
+		q.Set("purge", "")
+		assert.NoError(t, verifier.Verify(request(http.MethodGet, q)))
+	})
+}
@@ -0,0 +1,106 @@
+package stowry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SPAIndexPath is the default index document: the object every unknown route
+// falls back to in SPA mode, and the one static mode serves for a directory.
+const SPAIndexPath = "index.html"
+
+// ValidateIndexFiles reports an empty list of index documents, or one that
+// is not a plain file name.
+func ValidateIndexFiles(names []string) error {
+	if len(names) == 0 {
+		return errors.New("index files: at least one is required")
+	}
+	for _, name := range names {
+		if err := ValidateIndexFile(name); err != nil {
+			return fmt.Errorf("index files: %w", err)
+		}
+	}
+	return nil
+}
+
+// ValidateIndexFile reports an index document name that is not a plain file
+// name, such as one containing a slash.
+func ValidateIndexFile(name string) error {
+	if name == "" || name == "." || name == ".." || strings.Contains(name, "/") {
+		return fmt.Errorf("%q is not a file name", name)
+	}
+	return nil
+}
+
+// indexCacheTTL is how long the lookup of the SPA index is reused. Writes
+// through the service clear it at once; writes by other processes sharing
+// the database are seen after at most this long.
+const indexCacheTTL = time.Second
+
+// indexCache remembers the last lookup of the SPA index at path, found or
+// not, so the SPA fallback does not query the repository for every unknown
+// route.
+type indexCache struct {
+	path    string
+	mu      sync.Mutex
+	meta    MetaData
+	err     error // nil or ErrNotFound
+	expires time.Time
+}
+
+// get returns the metadata of the SPA index, from the cache while it is
+// fresh. Only a found object or ErrNotFound is cached.
+func (c *indexCache) get(ctx context.Context, repo MetaDataRepo) (MetaData, error) {
+	c.mu.Lock()
+	if time.Now().Before(c.expires) {
+		meta, err := c.meta, c.err
+		c.mu.Unlock()
+		return meta, err
+	}
+	c.mu.Unlock()
+
+	meta, err := repo.Get(ctx, c.path)
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		return meta, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.meta, c.err, c.expires = meta, err, time.Now().Add(indexCacheTTL)
+	return meta, err
+}
+
+// invalidate drops the cached lookup when paths include the SPA index.
+func (c *indexCache) invalidate(paths ...string) {
+	if !slices.Contains(paths, c.path) {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.expires = time.Time{}
+}
+
+// firstIndexMatch returns the metadata of the first of candidates that
+// exists, looking them all up in one query, or ErrNotFound.
+func firstIndexMatch(ctx context.Context, repo MetaDataRepo, candidates []string) (MetaData, error) {
+	if len(candidates) == 1 {
+		return repo.Get(ctx, candidates[0])
+	}
+	found, err := repo.GetMany(ctx, candidates)
+	if err != nil {
+		return MetaData{}, err
+	}
+	for _, candidate := range candidates {
+		for _, m := range found {
+			if m.Path == candidate {
+				return m, nil
+			}
+		}
+	}
+	return MetaData{}, ErrNotFound
+}
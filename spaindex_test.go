@@ -0,0 +1,103 @@
+package stowry_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/sagarc03/stowry"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newIndexService(t *testing.T, cfg stowry.ServiceConfig) (*stowry.StowryService, *SpyMetaDataRepo) {
+	t.Helper()
+	repo := new(SpyMetaDataRepo)
+	service, err := stowry.NewStowryService(repo, new(SpyFileStorage), cfg)
+	require.NoError(t, err)
+	return service, repo
+}
+
+func TestStowryService_IndexFiles(t *testing.T) {
+	ctx := context.Background()
+	cfg := stowry.ServiceConfig{Mode: stowry.ModeStatic, IndexFiles: []string{"index.html", "index.htm", "default.html"}}
+
+	t.Run("directory tries each index document in one query", func(t *testing.T) {
+		service, repo := newIndexService(t, cfg)
+		repo.On("Get", ctx, "docs").Return(stowry.MetaData{}, stowry.ErrNotFound)
+		repo.On("GetMany", ctx, []string{"docs.html", "docs/index.html", "docs/index.htm", "docs/default.html"}).
+			Return([]stowry.MetaData{{Path: "docs/default.html"}, {Path: "docs/index.htm"}}, nil).Once()
+
+		m, err := service.Info(ctx, "docs/")
+		require.NoError(t, err)
+		assert.Equal(t, "docs/index.htm", m.Path, "the earlier index document wins")
+		repo.AssertExpectations(t)
+	})
+
+	t.Run("clean URL wins over a directory index", func(t *testing.T) {
+		service, repo := newIndexService(t, cfg)
+		repo.On("Get", ctx, "about").Return(stowry.MetaData{}, stowry.ErrNotFound)
+		repo.On("GetMany", ctx, []string{"about.html", "about/index.html", "about/index.htm", "about/default.html"}).
+			Return([]stowry.MetaData{{Path: "about/index.html"}, {Path: "about.html"}}, nil)
+
+		m, err := service.Info(ctx, "about")
+		require.NoError(t, err)
+		assert.Equal(t, "about.html", m.Path)
+	})
+
+	t.Run("root uses the same list", func(t *testing.T) {
+		service, repo := newIndexService(t, cfg)
+		repo.On("GetMany", ctx, []string{"index.html", "index.htm", "default.html"}).
+			Return([]stowry.MetaData{{Path: "default.html"}}, nil)
+
+		m, err := service.Info(ctx, "")
+		require.NoError(t, err)
+		assert.Equal(t, "default.html", m.Path)
+		repo.AssertNotCalled(t, "Get")
+	})
+
+	t.Run("no index document", func(t *testing.T) {
+		service, repo := newIndexService(t, cfg)
+		repo.On("GetMany", ctx, []string{"index.html", "index.htm", "default.html"}).Return([]stowry.MetaData{}, nil)
+
+		_, err := service.Info(ctx, "")
+		assert.ErrorIs(t, err, stowry.ErrNotFound)
+	})
+
+	t.Run("invalid lists", func(t *testing.T) {
+		for _, names := range [][]string{{""}, {"docs/index.html"}, {"index.html", ".."}} {
+			_, err := stowry.NewStowryService(new(SpyMetaDataRepo), new(SpyFileStorage), stowry.ServiceConfig{Mode: stowry.ModeStatic, IndexFiles: names})
+			assert.Error(t, err, "%q", names)
+		}
+	})
+}
+
+func TestStowryService_SPAIndex(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("unknown routes fall back to the configured index", func(t *testing.T) {
+		service, repo := newIndexService(t, stowry.ServiceConfig{Mode: stowry.ModeSPA, SPAIndex: "app.html"})
+		repo.On("Get", ctx, "settings").Return(stowry.MetaData{}, stowry.ErrNotFound)
+		repo.On("Get", ctx, "app.html").Return(stowry.MetaData{Path: "app.html"}, nil).Once()
+
+		m, err := service.Info(ctx, "settings")
+		require.NoError(t, err)
+		assert.Equal(t, "app.html", m.Path)
+
+		m, err = service.Info(ctx, "")
+		require.NoError(t, err)
+		assert.Equal(t, "app.html", m.Path, "the root reuses the cached lookup")
+		repo.AssertExpectations(t)
+	})
+
+	t.Run("rejects a path", func(t *testing.T) {
+		_, err := stowry.NewStowryService(new(SpyMetaDataRepo), new(SpyFileStorage), stowry.ServiceConfig{Mode: stowry.ModeSPA, SPAIndex: "dist/app.html"})
+		assert.Error(t, err)
+	})
+}
+
+func TestValidateIndexFiles(t *testing.T) {
+	assert.NoError(t, stowry.ValidateIndexFiles([]string{"index.html", "index.htm"}))
+	assert.Error(t, stowry.ValidateIndexFiles(nil))
+	assert.Error(t, stowry.ValidateIndexFiles([]string{}))
+	assert.Error(t, stowry.ValidateIndexFiles([]string{"a/index.html"}))
+}
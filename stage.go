@@ -0,0 +1,285 @@
+package stowry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// StagePrefix is the storage prefix holding staged uploads and the files a
+// stage commit replaced. Objects cannot be created under it directly.
+const StagePrefix = ".stowry-stage/"
+
+// Stage is an open staging session. Objects uploaded into a stage stay
+// invisible until CommitStage promotes all of them at once.
+type Stage struct {
+	ID        uuid.UUID `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// StagedPath returns the storage path of path staged in stage id.
+func StagedPath(id uuid.UUID, path string) string {
+	return StagePrefix + id.String() + "/objects/" + path
+}
+
+// ReplacedPath returns where a commit of stage id moves the object that was
+// stored at path. The replaced object keeps a soft-deleted metadata row at
+// this path, so Tombstone removes the file like any other deleted object.
+func ReplacedPath(id uuid.UUID, path string) string {
+	return StagePrefix + id.String() + "/replaced/" + path
+}
+
+// StageRepo is an optional MetaDataRepo extension for staged multi-object
+// commits. The service checks for it with a type assertion.
+type StageRepo interface {
+	// CreateStage records a new, empty stage.
+	//
+	// Returns:
+	//   - Stage: The new stage with a fresh ID
+	//   - error: Any database error
+	CreateStage(ctx context.Context) (Stage, error)
+
+	// AddStagedObject records entry in the stage, replacing an earlier entry
+	// for the same path.
+	//
+	// Returns:
+	//   - error: ErrNotFound if the stage does not exist, or other database errors
+	AddStagedObject(ctx context.Context, id uuid.UUID, entry ObjectEntry) error
+
+	// StagedObjects returns the entries of a stage, ordered by path.
+	//
+	// Returns:
+	//   - []ObjectEntry: The staged entries
+	//   - error: ErrNotFound if the stage does not exist, or other database errors
+	StagedObjects(ctx context.Context, id uuid.UUID) ([]ObjectEntry, error)
+
+	// CommitStage promotes every staged entry to its path in a single
+	// transaction and removes the stage. A row already at the path, live or
+	// soft-deleted, is renamed to ReplacedPath and soft-deleted.
+	//
+	// Returns:
+	//   - []MetaData: The committed metadata, ordered by path
+	//   - error: ErrNotFound if the stage does not exist, or other database errors
+	CommitStage(ctx context.Context, id uuid.UUID) ([]MetaData, error)
+
+	// DeleteStage removes a stage and its entries.
+	//
+	// Returns:
+	//   - error: ErrNotFound if the stage does not exist, or other database errors
+	DeleteStage(ctx context.Context, id uuid.UUID) error
+}
+
+// stageBackends returns the repository and storage extensions staging needs.
+func (s *StowryService) stageBackends() (StageRepo, StorageMover, error) {
+	if s.mode != ModeStore {
+		return nil, nil, fmt.Errorf("staging is only available in store mode: %w", ErrNotSupported)
+	}
+	repo, ok := s.repo.(StageRepo)
+	if !ok {
+		return nil, nil, fmt.Errorf("metadata repository does not support staging: %w", ErrNotSupported)
+	}
+	mover, ok := s.storage.(StorageMover)
+	if !ok {
+		return nil, nil, fmt.Errorf("storage does not support moving: %w", ErrNotSupported)
+	}
+	return repo, mover, nil
+}
+
+// CreateStage opens a staging session for an all-or-nothing upload of
+// several objects.
+//
+// Returns:
+//   - Stage: The new stage
+//   - error: ErrNotSupported when the repository or storage cannot stage, or
+//     any database error
+func (s *StowryService) CreateStage(ctx context.Context) (Stage, error) {
+	repo, _, err := s.stageBackends()
+	if err != nil {
+		return Stage{}, fmt.Errorf("create stage: %w", err)
+	}
+
+	stage, err := repo.CreateStage(ctx)
+	if err != nil {
+		return Stage{}, fmt.Errorf("create stage: %w", err)
+	}
+	return stage, nil
+}
+
+// StageObject writes an object into a stage. It is validated like Create but
+// stays invisible until the stage is committed. Staging the same path again
+// replaces the earlier upload.
+//
+// Parameters:
+//   - ctx: Context for cancellation and timeout
+//   - id: The stage to add to
+//   - obj: Final path and content type of the object
+//   - content: The object data
+//
+// Returns:
+//   - ObjectEntry: The staged object under its final path
+//   - error: ErrNotFound for an unknown stage, ErrInvalidInput for an invalid
+//     object, ErrNotSupported, or storage and database errors
+func (s *StowryService) StageObject(ctx context.Context, id uuid.UUID, obj CreateObject, content io.Reader) (ObjectEntry, error) {
+	if err := ctx.Err(); err != nil {
+		return ObjectEntry{}, fmt.Errorf("stage object: %w", err)
+	}
+
+	repo, _, err := s.stageBackends()
+	if err != nil {
+		return ObjectEntry{}, fmt.Errorf("stage object: %w", err)
+	}
+	obj, err = s.prepareCreateObject(obj)
+	if err != nil {
+		return ObjectEntry{}, fmt.Errorf("stage object: %w", err)
+	}
+	// Fail before writing anything for a stage that does not exist
+	if _, err := repo.StagedObjects(ctx, id); err != nil {
+		return ObjectEntry{}, fmt.Errorf("stage object: %w", err)
+	}
+
+	stagedPath := StagedPath(id, obj.Path)
+	oe, err := s.storeObject(ctx, stagedPath, obj, content)
+	if err != nil {
+		return ObjectEntry{}, fmt.Errorf("stage object: %w", err)
+	}
+
+	if err := repo.AddStagedObject(ctx, id, oe); err != nil {
+		cleanupCtx, cancel := context.WithTimeout(context.Background(), s.cleanupTimeout)
+		defer cancel()
+		if delErr := s.storageDelete(cleanupCtx, stagedPath); delErr != nil {
+			return ObjectEntry{}, fmt.Errorf("stage object %s: %w (cleanup failed: %w)", obj.Path, err, delErr)
+		}
+		return ObjectEntry{}, fmt.Errorf("stage object %s: %w", obj.Path, err)
+	}
+	return oe, nil
+}
+
+// stageMove is a storage rename done by CommitStage, undone on failure.
+type stageMove struct {
+	from, to string
+}
+
+// CommitStage atomically promotes every object in a stage to its final path.
+// Storage is updated first: each object currently at a final path is moved
+// to ReplacedPath and the staged file takes its place. The metadata then
+// switches over in a single transaction, which also soft-deletes the replaced
+// objects so Tombstone cleans them up. If the transaction fails, the renames
+// are undone and the stage is left as it was.
+//
+// Parameters:
+//   - ctx: Context for cancellation and timeout
+//   - id: The stage to commit
+//
+// Returns:
+//   - []MetaData: The committed objects, ordered by path
+//   - error: ErrNotFound for an unknown stage, ErrObjectLimitExceeded,
+//     ErrNotSupported, or storage and database errors
+func (s *StowryService) CommitStage(ctx context.Context, id uuid.UUID) ([]MetaData, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, fmt.Errorf("commit stage: %w", err)
+	}
+
+	repo, mover, err := s.stageBackends()
+	if err != nil {
+		return nil, fmt.Errorf("commit stage: %w", err)
+	}
+
+	entries, err := repo.StagedObjects(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("commit stage: %w", err)
+	}
+
+	paths := make([]string, len(entries))
+	for i, e := range entries {
+		paths[i] = e.Path
+	}
+	if s.objectLimiter != nil {
+		if err := s.objectLimiter.admit(ctx, paths); err != nil {
+			return nil, fmt.Errorf("commit stage: %w", err)
+		}
+	}
+
+	var done []stageMove
+	move := func(from, to string) error {
+		if err := mover.Move(ctx, from, to); err != nil {
+			return err
+		}
+		done = append(done, stageMove{from: from, to: to})
+		return nil
+	}
+
+	for _, e := range entries {
+		// Paths without a current file have nothing to set aside
+		if err := move(e.Path, ReplacedPath(id, e.Path)); err != nil && !errors.Is(err, ErrNotFound) {
+			return nil, s.rollbackStage(mover, done, fmt.Errorf("commit stage: set aside %s: %w", e.Path, err))
+		}
+		if err := move(StagedPath(id, e.Path), e.Path); err != nil {
+			return nil, s.rollbackStage(mover, done, fmt.Errorf("commit stage: promote %s: %w", e.Path, err))
+		}
+	}
+
+	committed, err := repo.CommitStage(ctx, id)
+	if s.objectLimiter != nil {
+		s.objectLimiter.invalidate(paths)
+	}
+	if err != nil {
+		return nil, s.rollbackStage(mover, done, fmt.Errorf("commit stage: %w", err))
+	}
+	s.index.invalidate(paths...)
+	s.changes.notify()
+	return committed, nil
+}
+
+// rollbackStage undoes the renames of a failed commit in reverse order and
+// returns err, joined with any rename that could not be undone.
+func (s *StowryService) rollbackStage(mover StorageMover, done []stageMove, err error) error {
+	// Use background context for cleanup since original context may be cancelled
+	cleanupCtx, cancel := context.WithTimeout(context.Background(), s.cleanupTimeout)
+	defer cancel()
+
+	var undoErrs []error
+	for i := len(done) - 1; i >= 0; i-- {
+		if undoErr := mover.Move(cleanupCtx, done[i].to, done[i].from); undoErr != nil {
+			undoErrs = append(undoErrs, fmt.Errorf("%s: %w", done[i].to, undoErr))
+		}
+	}
+	if len(undoErrs) > 0 {
+		return fmt.Errorf("%w and rollback failed: %w", err, errors.Join(undoErrs...))
+	}
+	return err
+}
+
+// DiscardStage deletes a stage and its staged files without committing.
+//
+// Returns:
+//   - error: ErrNotFound for an unknown stage, ErrNotSupported, or storage
+//     and database errors
+func (s *StowryService) DiscardStage(ctx context.Context, id uuid.UUID) error {
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("discard stage: %w", err)
+	}
+
+	repo, _, err := s.stageBackends()
+	if err != nil {
+		return fmt.Errorf("discard stage: %w", err)
+	}
+
+	entries, err := repo.StagedObjects(ctx, id)
+	if err != nil {
+		return fmt.Errorf("discard stage: %w", err)
+	}
+	for _, e := range entries {
+		if err := s.storageDelete(ctx, StagedPath(id, e.Path)); err != nil && !errors.Is(err, ErrNotFound) {
+			return fmt.Errorf("discard stage: %s: %w", e.Path, err)
+		}
+	}
+
+	if err := repo.DeleteStage(ctx, id); err != nil {
+		return fmt.Errorf("discard stage: %w", err)
+	}
+	return nil
+}
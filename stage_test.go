@@ -0,0 +1,247 @@
+package stowry_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sagarc03/stowry"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// memStageRepo keeps stages in memory. Everything else is left to the spy.
+type memStageRepo struct {
+	*SpyMetaDataRepo
+	mu        sync.Mutex
+	stages    map[uuid.UUID]map[string]stowry.ObjectEntry
+	commitErr error
+}
+
+func newMemStageRepo() *memStageRepo {
+	return &memStageRepo{SpyMetaDataRepo: new(SpyMetaDataRepo), stages: map[uuid.UUID]map[string]stowry.ObjectEntry{}}
+}
+
+func (m *memStageRepo) CreateStage(context.Context) (stowry.Stage, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	stage := stowry.Stage{ID: uuid.New(), CreatedAt: time.Now()}
+	m.stages[stage.ID] = map[string]stowry.ObjectEntry{}
+	return stage, nil
+}
+
+func (m *memStageRepo) AddStagedObject(_ context.Context, id uuid.UUID, entry stowry.ObjectEntry) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	objects, ok := m.stages[id]
+	if !ok {
+		return stowry.ErrNotFound
+	}
+	objects[entry.Path] = entry
+	return nil
+}
+
+func (m *memStageRepo) StagedObjects(_ context.Context, id uuid.UUID) ([]stowry.ObjectEntry, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	objects, ok := m.stages[id]
+	if !ok {
+		return nil, stowry.ErrNotFound
+	}
+	entries := []stowry.ObjectEntry{}
+	for _, path := range slices.Sorted(maps.Keys(objects)) {
+		entries = append(entries, objects[path])
+	}
+	return entries, nil
+}
+
+func (m *memStageRepo) CommitStage(ctx context.Context, id uuid.UUID) ([]stowry.MetaData, error) {
+	if m.commitErr != nil {
+		return nil, m.commitErr
+	}
+	entries, err := m.StagedObjects(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	committed := make([]stowry.MetaData, len(entries))
+	for i, e := range entries {
+		committed[i] = stowry.MetaData{ID: uuid.New(), Path: e.Path, ContentType: e.ContentType, Etag: e.ETag, FileSizeBytes: e.Size}
+	}
+	return committed, m.DeleteStage(ctx, id)
+}
+
+func (m *memStageRepo) DeleteStage(_ context.Context, id uuid.UUID) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.stages[id]; !ok {
+		return stowry.ErrNotFound
+	}
+	delete(m.stages, id)
+	return nil
+}
+
+func stageFiles(t *testing.T, service *stowry.StowryService, stage stowry.Stage, files map[string]string) {
+	t.Helper()
+	for _, path := range slices.Sorted(maps.Keys(files)) {
+		entry, err := service.StageObject(context.Background(), stage.ID,
+			stowry.CreateObject{Path: path, ContentType: "text/plain"}, strings.NewReader(files[path]))
+		require.NoError(t, err)
+		assert.Equal(t, path, entry.Path)
+	}
+}
+
+func TestStowryService_Stage(t *testing.T) {
+	ctx := context.Background()
+
+	setup := func(t *testing.T) (*stowry.StowryService, *memStageRepo, *memStorage) {
+		t.Helper()
+		repo := newMemStageRepo()
+		storage := newMemStorage("site/index.html")
+		service, err := stowry.NewStowryService(repo, storage, stowry.ServiceConfig{Mode: stowry.ModeStore})
+		require.NoError(t, err)
+		return service, repo, storage
+	}
+
+	t.Run("commit promotes staged files and sets replaced ones aside", func(t *testing.T) {
+		service, _, storage := setup(t)
+
+		stage, err := service.CreateStage(ctx)
+		require.NoError(t, err)
+		stageFiles(t, service, stage, map[string]string{"site/index.html": "new index", "site/app.js": "app"})
+
+		assert.Equal(t, "site/index.html", string(storage.files["site/index.html"]), "nothing changes before the commit")
+
+		committed, err := service.CommitStage(ctx, stage.ID)
+		require.NoError(t, err)
+		require.Len(t, committed, 2)
+		assert.Equal(t, "site/app.js", committed[0].Path)
+
+		assert.Equal(t, []string{
+			stowry.ReplacedPath(stage.ID, "site/index.html"),
+			"site/app.js",
+			"site/index.html",
+		}, storage.paths())
+		assert.Equal(t, "new index", string(storage.files["site/index.html"]))
+		assert.Equal(t, "site/index.html", string(storage.files[stowry.ReplacedPath(stage.ID, "site/index.html")]))
+	})
+
+	t.Run("failed metadata commit undoes the renames", func(t *testing.T) {
+		service, repo, storage := setup(t)
+		repo.commitErr = errors.New("database is locked")
+
+		stage, err := service.CreateStage(ctx)
+		require.NoError(t, err)
+		stageFiles(t, service, stage, map[string]string{"site/index.html": "new index", "site/app.js": "app"})
+		before := storage.paths()
+
+		_, err = service.CommitStage(ctx, stage.ID)
+		require.ErrorContains(t, err, "database is locked")
+
+		assert.Equal(t, before, storage.paths())
+		assert.Equal(t, "site/index.html", string(storage.files["site/index.html"]))
+
+		// The stage is intact and can be committed once the database recovers
+		repo.commitErr = nil
+		_, err = service.CommitStage(ctx, stage.ID)
+		require.NoError(t, err)
+		assert.Equal(t, "new index", string(storage.files["site/index.html"]))
+	})
+
+	t.Run("discard removes staged files", func(t *testing.T) {
+		service, _, storage := setup(t)
+
+		stage, err := service.CreateStage(ctx)
+		require.NoError(t, err)
+		stageFiles(t, service, stage, map[string]string{"site/app.js": "app"})
+
+		require.NoError(t, service.DiscardStage(ctx, stage.ID))
+		assert.Equal(t, []string{"site/index.html"}, storage.paths())
+		assert.ErrorIs(t, service.DiscardStage(ctx, stage.ID), stowry.ErrNotFound)
+	})
+
+	t.Run("unknown stage", func(t *testing.T) {
+		service, _, storage := setup(t)
+		id := uuid.New()
+
+		_, err := service.StageObject(ctx, id, stowry.CreateObject{Path: "a.txt", ContentType: "text/plain"}, bytes.NewReader(nil))
+		assert.ErrorIs(t, err, stowry.ErrNotFound)
+		assert.Equal(t, []string{"site/index.html"}, storage.paths())
+
+		_, err = service.CommitStage(ctx, id)
+		assert.ErrorIs(t, err, stowry.ErrNotFound)
+	})
+
+	t.Run("invalid staged object", func(t *testing.T) {
+		service, _, _ := setup(t)
+
+		stage, err := service.CreateStage(ctx)
+		require.NoError(t, err)
+		_, err = service.StageObject(ctx, stage.ID, stowry.CreateObject{Path: "../a.txt", ContentType: "text/plain"}, bytes.NewReader(nil))
+		assert.ErrorIs(t, err, stowry.ErrInvalidInput)
+	})
+
+	t.Run("populate and orphan collection skip staged files", func(t *testing.T) {
+		service, repo, storage := setup(t)
+
+		stage, err := service.CreateStage(ctx)
+		require.NoError(t, err)
+		stageFiles(t, service, stage, map[string]string{"site/app.js": "app"})
+
+		repo.On("UpsertBatch", ctx, []stowry.ObjectEntry{{Path: "site/index.html", Size: 15, ContentType: "text/plain"}}).
+			Return([]stowry.MetaData{}, nil).Once()
+		require.NoError(t, service.Populate(ctx))
+
+		repo.On("ExistingPaths", ctx, []string{"site/index.html"}).Return([]string{"site/index.html"}, nil).Once()
+		report, err := service.CollectOrphans(ctx, stowry.OrphanOptions{MinAge: -time.Hour})
+		require.NoError(t, err)
+		assert.Equal(t, 1, report.Scanned)
+		assert.Len(t, storage.paths(), 2)
+		repo.AssertExpectations(t)
+	})
+}
+
+func TestStowryService_Stage_Unsupported(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("repository without staging", func(t *testing.T) {
+		service, err := stowry.NewStowryService(new(SpyMetaDataRepo), newMemStorage(), stowry.ServiceConfig{Mode: stowry.ModeStore})
+		require.NoError(t, err)
+		_, err = service.CreateStage(ctx)
+		assert.ErrorIs(t, err, stowry.ErrNotSupported)
+	})
+
+	t.Run("storage without moves", func(t *testing.T) {
+		service, err := stowry.NewStowryService(newMemStageRepo(), new(SpyFileStorage), stowry.ServiceConfig{Mode: stowry.ModeStore})
+		require.NoError(t, err)
+		_, err = service.CreateStage(ctx)
+		assert.ErrorIs(t, err, stowry.ErrNotSupported)
+	})
+
+	t.Run("static mode", func(t *testing.T) {
+		service, err := stowry.NewStowryService(newMemStageRepo(), newMemStorage(), stowry.ServiceConfig{Mode: stowry.ModeStatic})
+		require.NoError(t, err)
+		_, err = service.CreateStage(ctx)
+		assert.ErrorIs(t, err, stowry.ErrNotSupported)
+	})
+}
+
+func TestStowryService_Create_StagePrefixReserved(t *testing.T) {
+	service, _, _ := NewStowryService(t)
+
+	_, err := service.Create(context.Background(), stowry.CreateObject{Path: stowry.StagePrefix + "x/objects/a.txt", ContentType: "text/plain"}, bytes.NewReader(nil))
+	assert.ErrorIs(t, err, stowry.ErrInvalidInput)
+}
+
+func TestStowryService_Create_QuarantinePrefixReserved(t *testing.T) {
+	service, _, _ := NewStowryService(t)
+
+	_, err := service.Create(context.Background(), stowry.CreateObject{Path: stowry.DefaultQuarantinePrefix + "a.txt", ContentType: "text/plain"}, bytes.NewReader(nil))
+	assert.ErrorIs(t, err, stowry.ErrInvalidInput)
+}
@@ -0,0 +1,60 @@
+package stowry
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// MaxStatPaths is the most paths StatMany looks up in one call.
+const MaxStatPaths = 1000
+
+// StatMany returns the metadata of the active objects among paths, keyed by
+// path, in one metadata query instead of one Info call per path. Paths are
+// used as is, without the static and SPA fallbacks; a leading "/" is
+// ignored. Paths without an object, including paths that cannot name one,
+// are left out of the result.
+//
+// Parameters:
+//   - ctx: Context for cancellation and timeout
+//   - paths: Up to MaxStatPaths object paths; duplicates are looked up once
+//
+// Returns:
+//   - map[string]MetaData: Metadata by path, as given, for the paths found
+//   - error: ErrInvalidInput for more than MaxStatPaths paths, or database
+//     errors
+func (s *StowryService) StatMany(ctx context.Context, paths []string) (map[string]MetaData, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, fmt.Errorf("stat objects: %w", err)
+	}
+	if len(paths) > MaxStatPaths {
+		return nil, fmt.Errorf("stat objects: %w: %d paths, at most %d per call", ErrInvalidInput, len(paths), MaxStatPaths)
+	}
+
+	// requested maps each key looked up to the paths that asked for it
+	requested := make(map[string][]string, len(paths))
+	keys := make([]string, 0, len(paths))
+	for _, p := range paths {
+		key := strings.TrimPrefix(p, "/")
+		if !IsValidPath(key) {
+			continue
+		}
+		if _, seen := requested[key]; !seen {
+			keys = append(keys, key)
+		}
+		requested[key] = append(requested[key], p)
+	}
+
+	found, err := s.repo.GetMany(ctx, keys)
+	if err != nil {
+		return nil, fmt.Errorf("stat objects: %w", err)
+	}
+
+	result := make(map[string]MetaData, len(found))
+	for _, m := range found {
+		for _, p := range requested[m.Path] {
+			result[p] = m
+		}
+	}
+	return result, nil
+}
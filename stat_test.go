@@ -0,0 +1,61 @@
+package stowry_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/sagarc03/stowry"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStowryService_StatMany(t *testing.T) {
+	newService := func(t *testing.T) (*stowry.StowryService, *SpyMetaDataRepo) {
+		t.Helper()
+		repo := new(SpyMetaDataRepo)
+		service, err := stowry.NewStowryService(repo, new(SpyFileStorage), stowry.ServiceConfig{Mode: stowry.ModeStore})
+		require.NoError(t, err)
+		return service, repo
+	}
+
+	t.Run("returns found paths as requested", func(t *testing.T) {
+		service, repo := newService(t)
+		ctx := context.Background()
+		a := stowry.MetaData{Path: "a.txt", Etag: "ea"}
+		c := stowry.MetaData{Path: "dir/c.txt", Etag: "ec"}
+		repo.On("GetMany", ctx, []string{"a.txt", "b.txt", "dir/c.txt"}).Return([]stowry.MetaData{c, a}, nil).Once()
+
+		got, err := service.StatMany(ctx, []string{"a.txt", "b.txt", "/dir/c.txt", "../x", "a.txt"})
+		require.NoError(t, err)
+		assert.Equal(t, map[string]stowry.MetaData{"a.txt": a, "/dir/c.txt": c}, got)
+		repo.AssertExpectations(t)
+	})
+
+	t.Run("too many paths", func(t *testing.T) {
+		service, repo := newService(t)
+
+		_, err := service.StatMany(context.Background(), make([]string, stowry.MaxStatPaths+1))
+		assert.ErrorIs(t, err, stowry.ErrInvalidInput)
+		repo.AssertNotCalled(t, "GetMany", mock.Anything, mock.Anything)
+	})
+
+	t.Run("repo error", func(t *testing.T) {
+		service, repo := newService(t)
+		repo.On("GetMany", mock.Anything, mock.Anything).Return([]stowry.MetaData(nil), errors.New("db down"))
+
+		_, err := service.StatMany(context.Background(), []string{"a.txt"})
+		assert.ErrorContains(t, err, "db down")
+	})
+
+	t.Run("canceled context", func(t *testing.T) {
+		service, repo := newService(t)
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		_, err := service.StatMany(ctx, []string{"a.txt"})
+		assert.ErrorIs(t, err, context.Canceled)
+		repo.AssertNotCalled(t, "GetMany", mock.Anything, mock.Anything)
+	})
+}
@@ -0,0 +1,177 @@
+package stowrytest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"sync"
+	"time"
+
+	"github.com/sagarc03/stowry"
+	"github.com/sagarc03/stowry/filesystem"
+)
+
+// ErrInjectedFault is returned by calls that Faults makes fail when
+// Faults.Err is nil. The server answers them with 500 Internal Server Error.
+var ErrInjectedFault = errors.New("stowrytest: injected fault")
+
+// Faults describes the latency and failures injected into storage or
+// metadata calls. The zero value injects nothing.
+//
+// Storage faults apply to reading, writing and deleting files. Metadata
+// faults apply to looking up, listing, storing and deleting metadata.
+type Faults struct {
+	Latency     time.Duration // Delay before every call; cut short by context cancellation
+	FailureRate float64       // Fraction of calls that fail, from 0 to 1
+	Err         error         // Returned by failing calls; ErrInjectedFault when nil
+}
+
+// faultRand is the generator shared by the injectors of one server, so the
+// sequence of failures depends only on the seed and the order of calls.
+type faultRand struct {
+	mu  sync.Mutex
+	rnd *rand.Rand
+}
+
+func newFaultRand(seed uint64) *faultRand {
+	return &faultRand{rnd: rand.New(rand.NewPCG(seed, seed))}
+}
+
+func (r *faultRand) float64() float64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.rnd.Float64()
+}
+
+// injector applies one Faults to the calls of a wrapper.
+type injector struct {
+	faults Faults
+	rnd    *faultRand
+}
+
+func newInjector(f Faults, rnd *faultRand) injector {
+	if f.Err == nil {
+		f.Err = ErrInjectedFault
+	}
+	return injector{faults: f, rnd: rnd}
+}
+
+// inject waits out the latency and returns the injected error for a call to
+// op that fails, or nil.
+func (i injector) inject(ctx context.Context, op string) error {
+	if i.faults.Latency > 0 {
+		timer := time.NewTimer(i.faults.Latency)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		}
+	}
+	if i.faults.FailureRate > 0 && i.rnd.float64() < i.faults.FailureRate {
+		return fmt.Errorf("%s: %w", op, i.faults.Err)
+	}
+	return nil
+}
+
+// faultStorage injects faults into the file calls of a filesystem store. The
+// store's optional extensions are promoted unchanged.
+type faultStorage struct {
+	*filesystem.Store
+	faults injector
+}
+
+func (s faultStorage) Get(ctx context.Context, path string) (io.ReadSeekCloser, error) {
+	if err := s.faults.inject(ctx, "get file"); err != nil {
+		return nil, err
+	}
+	return s.Store.Get(ctx, path)
+}
+
+func (s faultStorage) Write(ctx context.Context, path string, content io.Reader) (stowry.SaveResult, error) {
+	if err := s.faults.inject(ctx, "write file"); err != nil {
+		return stowry.SaveResult{}, err
+	}
+	return s.Store.Write(ctx, path, content)
+}
+
+func (s faultStorage) Delete(ctx context.Context, path string) error {
+	if err := s.faults.inject(ctx, "delete file"); err != nil {
+		return err
+	}
+	return s.Store.Delete(ctx, path)
+}
+
+// faultableRepo is the metadata repository of the test server's database.
+type faultableRepo interface {
+	stowry.MetaDataRepo
+	stowry.StageRepo
+	stowry.ChangeFeed
+	stowry.MultipartRepo
+	stowry.RenameRepo
+}
+
+// faultRepo injects faults into the object calls of a repository. Stage,
+// change feed, multipart and maintenance calls are forwarded unchanged.
+type faultRepo struct {
+	faultableRepo
+	faults injector
+}
+
+func (r faultRepo) Get(ctx context.Context, path string) (stowry.MetaData, error) {
+	if err := r.faults.inject(ctx, "get metadata"); err != nil {
+		return stowry.MetaData{}, err
+	}
+	return r.faultableRepo.Get(ctx, path)
+}
+
+func (r faultRepo) GetMany(ctx context.Context, paths []string) ([]stowry.MetaData, error) {
+	if err := r.faults.inject(ctx, "get metadata"); err != nil {
+		return nil, err
+	}
+	return r.faultableRepo.GetMany(ctx, paths)
+}
+
+func (r faultRepo) GetByETag(ctx context.Context, etag string) (stowry.MetaData, error) {
+	if err := r.faults.inject(ctx, "get metadata"); err != nil {
+		return stowry.MetaData{}, err
+	}
+	return r.faultableRepo.GetByETag(ctx, etag)
+}
+
+func (r faultRepo) List(ctx context.Context, q stowry.ListQuery) (stowry.ListResult, error) {
+	if err := r.faults.inject(ctx, "list metadata"); err != nil {
+		return stowry.ListResult{}, err
+	}
+	return r.faultableRepo.List(ctx, q)
+}
+
+func (r faultRepo) Upsert(ctx context.Context, entry stowry.ObjectEntry) (stowry.MetaData, *stowry.MetaData, error) {
+	if err := r.faults.inject(ctx, "upsert metadata"); err != nil {
+		return stowry.MetaData{}, nil, err
+	}
+	return r.faultableRepo.Upsert(ctx, entry)
+}
+
+func (r faultRepo) UpsertBatch(ctx context.Context, entries []stowry.ObjectEntry) ([]stowry.MetaData, error) {
+	if err := r.faults.inject(ctx, "upsert metadata"); err != nil {
+		return nil, err
+	}
+	return r.faultableRepo.UpsertBatch(ctx, entries)
+}
+
+func (r faultRepo) Delete(ctx context.Context, path string) error {
+	if err := r.faults.inject(ctx, "delete metadata"); err != nil {
+		return err
+	}
+	return r.faultableRepo.Delete(ctx, path)
+}
+
+func (r faultRepo) Rename(ctx context.Context, oldPath, newPath, backend string) (stowry.MetaData, error) {
+	if err := r.faults.inject(ctx, "rename metadata"); err != nil {
+		return stowry.MetaData{}, err
+	}
+	return r.faultableRepo.Rename(ctx, oldPath, newPath, backend)
+}
@@ -0,0 +1,309 @@
+// Package stowrytest runs a complete Stowry server inside a test, for
+// integration tests of code that talks to Stowry.
+//
+// NewTestServer serves the full HTTP stack on an ephemeral localhost port,
+// backed by a SQLite database and a storage directory under t.TempDir(), and
+// tears everything down with t.Cleanup:
+//
+//	srv := stowrytest.NewTestServer(t, stowrytest.Options{
+//		Seed: []stowrytest.SeedObject{{Path: "config/app.json", Content: []byte(`{}`)}},
+//	})
+//	resp, err := http.Get(srv.URL + "/config/app.json")
+//
+// Options.StorageFaults and Options.RepoFaults add latency and failures to
+// the storage and metadata calls behind the HTTP API, so tests can exercise
+// timeouts and retries. Failures are drawn from a generator seeded with
+// Options.FaultSeed, so a test that sends its requests one at a time sees the
+// same failures on every run.
+package stowrytest
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"mime"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/sagarc03/stowry"
+	"github.com/sagarc03/stowry/clientcli"
+	"github.com/sagarc03/stowry/database/sqlite"
+	"github.com/sagarc03/stowry/filesystem"
+	stowryhttp "github.com/sagarc03/stowry/http"
+	"github.com/sagarc03/stowry/keybackend"
+)
+
+// Access key pair used when Options.Keys is empty.
+const (
+	DefaultAccessKey = "STOWRYTESTACCESSKEY0"
+	DefaultSecretKey = "stowrytest-secret-key-do-not-use-in-production"
+)
+
+// Auth settings for Options.AuthRead and Options.AuthWrite, as in the server
+// configuration.
+const (
+	AuthPublic  = "public"
+	AuthPrivate = "private"
+)
+
+// Key is an access key pair the server accepts.
+type Key struct {
+	AccessKey string
+	SecretKey string
+}
+
+// SeedObject is an object stored before the server accepts requests.
+type SeedObject struct {
+	Path        string
+	ContentType string // Detected from the path extension when empty
+	Content     []byte
+}
+
+// Options configures NewTestServer. The zero value is a public store-mode
+// server.
+type Options struct {
+	Mode      stowry.ServerMode // Defaults to stowry.ModeStore
+	AuthRead  string            // AuthPublic (default) or AuthPrivate
+	AuthWrite string            // AuthPublic (default) or AuthPrivate
+	// Keys are the accepted access keys. The first one configures
+	// Server.Client. Empty means DefaultAccessKey and DefaultSecretKey.
+	Keys []Key
+	Seed []SeedObject
+
+	// StorageFaults and RepoFaults are injected into the storage and
+	// metadata calls made for HTTP requests. Seeding and the Server
+	// inspection helpers bypass them.
+	StorageFaults Faults
+	RepoFaults    Faults
+	// FaultSeed seeds the generator that decides which calls fail.
+	FaultSeed uint64
+
+	// Handler, when not nil, adjusts the HTTP handler configuration before
+	// the server starts, e.g. to enable CORS or set MaxUploadSize.
+	Handler func(*stowryhttp.HandlerConfig)
+	// Service, when not nil, adjusts the configuration of the serving
+	// service, e.g. to set IndexFiles. Mode is already set.
+	Service func(*stowry.ServiceConfig)
+}
+
+// Server is a running test server.
+type Server struct {
+	// URL is the base URL of the server, e.g. http://127.0.0.1:50123,
+	// without a trailing slash.
+	URL string
+	// Client talks to the server with the first access key of Options.Keys.
+	Client *clientcli.Client
+	// Key is the access key pair Client signs with.
+	Key Key
+
+	service *stowry.StowryService
+	repo    stowry.MetaDataRepo
+	storage *filesystem.Store
+}
+
+// NewTestServer starts a server configured by opts and stops it when the
+// test ends. Any setup error fails the test immediately.
+func NewTestServer(t testing.TB, opts Options) *Server {
+	t.Helper()
+
+	mode := opts.Mode
+	if mode == "" {
+		mode = stowry.ModeStore
+	}
+	keys := opts.Keys
+	if len(keys) == 0 {
+		keys = []Key{{AccessKey: DefaultAccessKey, SecretKey: DefaultSecretKey}}
+	}
+
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	db, err := sqlite.Connect(ctx, filepath.Join(dir, "stowry.db"), stowry.Tables{MetaData: "stowry_metadata"})
+	if err != nil {
+		t.Fatalf("stowrytest: connect database: %v", err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+	if err := db.Migrate(ctx); err != nil {
+		t.Fatalf("stowrytest: migrate database: %v", err)
+	}
+
+	storageDir := filepath.Join(dir, "data")
+	if err := os.Mkdir(storageDir, 0o700); err != nil {
+		t.Fatalf("stowrytest: create storage directory: %v", err)
+	}
+	root, err := os.OpenRoot(storageDir)
+	if err != nil {
+		t.Fatalf("stowrytest: open storage root: %v", err)
+	}
+	t.Cleanup(func() { _ = root.Close() })
+
+	s := &Server{
+		repo:    db.GetRepo(),
+		storage: filesystem.NewFileStorage(root),
+		Key:     keys[0],
+	}
+
+	// Seeding and inspection use their own service, untouched by faults and
+	// by the serving mode
+	s.service, err = stowry.NewStowryService(s.repo, s.storage, stowry.ServiceConfig{Mode: stowry.ModeStore})
+	if err != nil {
+		t.Fatalf("stowrytest: create service: %v", err)
+	}
+	for _, obj := range opts.Seed {
+		s.Seed(t, obj)
+	}
+
+	rnd := newFaultRand(opts.FaultSeed)
+	repo, ok := s.repo.(faultableRepo)
+	if !ok {
+		t.Fatalf("stowrytest: %T does not support stages and changes", s.repo)
+	}
+	serviceCfg := stowry.ServiceConfig{Mode: mode}
+	if opts.Service != nil {
+		opts.Service(&serviceCfg)
+	}
+	service, err := stowry.NewStowryService(
+		faultRepo{faultableRepo: repo, faults: newInjector(opts.RepoFaults, rnd)},
+		faultStorage{Store: s.storage, faults: newInjector(opts.StorageFaults, rnd)},
+		serviceCfg,
+	)
+	if err != nil {
+		t.Fatalf("stowrytest: create service: %v", err)
+	}
+
+	handler, err := stowryhttp.New(handlerConfig(mode, opts, keys), service)
+	if err != nil {
+		t.Fatalf("stowrytest: create handler: %v", err)
+	}
+
+	server := httptest.NewServer(handler.Router())
+	t.Cleanup(server.Close)
+	s.URL = server.URL
+
+	s.Client, err = clientcli.New(&clientcli.Config{
+		Endpoint:  s.URL,
+		AccessKey: s.Key.AccessKey,
+		SecretKey: s.Key.SecretKey,
+	})
+	if err != nil {
+		t.Fatalf("stowrytest: create client: %v", err)
+	}
+	return s
+}
+
+// handlerConfig mirrors what stowry serve builds from the configuration
+// defaults, with the auth settings of opts.
+func handlerConfig(mode stowry.ServerMode, opts Options, keys []Key) *stowryhttp.HandlerConfig {
+	secrets := make(map[string]string, len(keys))
+	for _, key := range keys {
+		secrets[key.AccessKey] = key.SecretKey
+	}
+	verifier := stowry.NewSignatureVerifier(stowry.AuthConfig{
+		AWS: stowry.AWSConfig{Region: "us-east-1", Service: "s3"},
+	}, keybackend.NewMapSecretStore(secrets))
+
+	cfg := &stowryhttp.HandlerConfig{
+		Mode: mode,
+		SecurityHeaders: stowryhttp.SecurityHeadersConfig{
+			NoSniff:        true,
+			ReferrerPolicy: stowryhttp.DefaultReferrerPolicy,
+			FrameAncestors: []string{"'self'"},
+		},
+		SPA:                stowryhttp.SPAConfig{MissingIndex: stowryhttp.MissingIndexError, Wait: stowryhttp.DefaultSPAWait},
+		ListExportMaxRows:  100000,
+		RedirectsFile:      stowryhttp.DefaultRedirectsFile,
+		MaxWatchTimeout:    60 * time.Second,
+		ContentDisposition: stowryhttp.DispositionInline,
+	}
+	if mode == stowry.ModeStore {
+		if opts.AuthRead == AuthPrivate {
+			cfg.ReadVerifier = verifier
+		}
+		if opts.AuthWrite == AuthPrivate {
+			cfg.WriteVerifier = verifier
+		}
+	}
+	if opts.Handler != nil {
+		opts.Handler(cfg)
+	}
+	return cfg
+}
+
+// Seed stores obj directly, without going through HTTP or fault injection.
+func (s *Server) Seed(t testing.TB, obj SeedObject) stowry.MetaData {
+	t.Helper()
+
+	contentType := obj.ContentType
+	if contentType == "" {
+		contentType = detectContentType(obj.Path)
+	}
+	res, err := s.service.Create(context.Background(), stowry.CreateObject{
+		Path:        obj.Path,
+		ContentType: contentType,
+	}, bytes.NewReader(obj.Content))
+	if err != nil {
+		t.Fatalf("stowrytest: seed %s: %v", obj.Path, err)
+	}
+	return res.MetaData
+}
+
+// Object returns the metadata and content stored at path, read directly
+// without fault injection. It fails the test when there is no such object.
+func (s *Server) Object(t testing.TB, path string) (stowry.MetaData, []byte) {
+	t.Helper()
+
+	m, rc, err := s.service.Get(context.Background(), path)
+	if err != nil {
+		t.Fatalf("stowrytest: get %s: %v", path, err)
+	}
+	defer func() { _ = rc.Close() }()
+
+	content, err := io.ReadAll(rc)
+	if err != nil {
+		t.Fatalf("stowrytest: read %s: %v", path, err)
+	}
+	return m, content
+}
+
+// Exists reports whether an active object is stored at path.
+func (s *Server) Exists(t testing.TB, path string) bool {
+	t.Helper()
+
+	found, err := s.repo.ExistingPaths(context.Background(), []string{path})
+	if err != nil {
+		t.Fatalf("stowrytest: check %s: %v", path, err)
+	}
+	return len(found) > 0
+}
+
+// Paths returns the paths of all active objects under prefix, in order.
+func (s *Server) Paths(t testing.TB, prefix string) []string {
+	t.Helper()
+
+	var paths []string
+	q := stowry.ListQuery{PathPrefix: prefix, Limit: 1000}
+	for {
+		res, err := s.repo.List(context.Background(), q)
+		if err != nil {
+			t.Fatalf("stowrytest: list %s: %v", prefix, err)
+		}
+		for _, item := range res.Items {
+			paths = append(paths, item.Path)
+		}
+		if res.NextCursor == "" {
+			return paths
+		}
+		q.Cursor = res.NextCursor
+	}
+}
+
+func detectContentType(path string) string {
+	contentType := mime.TypeByExtension(filepath.Ext(path))
+	if contentType == "" {
+		return "application/octet-stream"
+	}
+	return contentType
+}
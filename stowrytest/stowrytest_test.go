@@ -0,0 +1,172 @@
+package stowrytest_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/sagarc03/stowry"
+	"github.com/sagarc03/stowry/clientcli"
+	stowryhttp "github.com/sagarc03/stowry/http"
+	"github.com/sagarc03/stowry/stowrytest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func get(t *testing.T, url string) (int, string) {
+	t.Helper()
+	resp, err := http.Get(url)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	return resp.StatusCode, string(body)
+}
+
+func put(t *testing.T, url string, content []byte) int {
+	t.Helper()
+	req, err := http.NewRequest(http.MethodPut, url, bytes.NewReader(content))
+	require.NoError(t, err)
+	req.Header.Set("Content-Type", "application/octet-stream")
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	_ = resp.Body.Close()
+	return resp.StatusCode
+}
+
+func TestNewTestServer_Seed(t *testing.T) {
+	srv := stowrytest.NewTestServer(t, stowrytest.Options{
+		Seed: []stowrytest.SeedObject{
+			{Path: "config/app.json", Content: []byte(`{"debug":true}`)},
+			{Path: "notes/a", ContentType: "text/markdown", Content: []byte("# A")},
+		},
+	})
+
+	status, body := get(t, srv.URL+"/config/app.json")
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, `{"debug":true}`, body)
+
+	m, content := srv.Object(t, "notes/a")
+	assert.Equal(t, "text/markdown", m.ContentType)
+	assert.Equal(t, "# A", string(content))
+
+	assert.Equal(t, http.StatusOK, put(t, srv.URL+"/notes/b", []byte("# B")))
+	assert.True(t, srv.Exists(t, "notes/b"))
+	assert.False(t, srv.Exists(t, "notes/c"))
+	assert.Equal(t, []string{"notes/a", "notes/b"}, srv.Paths(t, "notes/"))
+}
+
+func TestNewTestServer_PrivateAuth(t *testing.T) {
+	srv := stowrytest.NewTestServer(t, stowrytest.Options{
+		AuthRead:  stowrytest.AuthPrivate,
+		AuthWrite: stowrytest.AuthPrivate,
+		Keys:      []stowrytest.Key{{AccessKey: "AKIATESTKEY123456789", SecretKey: "test-secret"}},
+		Seed:      []stowrytest.SeedObject{{Path: "secret.txt", Content: []byte("hidden")}},
+	})
+	assert.Equal(t, "AKIATESTKEY123456789", srv.Key.AccessKey)
+
+	status, _ := get(t, srv.URL+"/secret.txt")
+	assert.Equal(t, http.StatusUnauthorized, status)
+	assert.Equal(t, http.StatusUnauthorized, put(t, srv.URL+"/other.txt", []byte("x")))
+
+	ctx := context.Background()
+	localPath := filepath.Join(t.TempDir(), "upload.txt")
+	require.NoError(t, os.WriteFile(localPath, []byte("signed"), 0o600))
+	_, err := srv.Client.Upload(ctx, clientcli.UploadOptions{LocalPath: localPath, RemotePath: "other.txt"})
+	require.NoError(t, err)
+
+	_, rc, err := srv.Client.Download(ctx, clientcli.DownloadOptions{RemotePath: "secret.txt", LocalPath: "-"})
+	require.NoError(t, err)
+	defer rc.Close()
+	content, err := io.ReadAll(rc)
+	require.NoError(t, err)
+	assert.Equal(t, "hidden", string(content))
+}
+
+func TestNewTestServer_Mode(t *testing.T) {
+	srv := stowrytest.NewTestServer(t, stowrytest.Options{
+		Mode: stowry.ModeSPA,
+		Seed: []stowrytest.SeedObject{{Path: "index.html", Content: []byte("<html>app</html>")}},
+	})
+
+	status, body := get(t, srv.URL+"/some/client/route")
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, "<html>app</html>", body)
+}
+
+func TestNewTestServer_Handler(t *testing.T) {
+	srv := stowrytest.NewTestServer(t, stowrytest.Options{
+		Handler: func(cfg *stowryhttp.HandlerConfig) { cfg.MaxUploadSize = 4 },
+	})
+
+	assert.Equal(t, http.StatusRequestEntityTooLarge, put(t, srv.URL+"/big.bin", []byte("too large")))
+}
+
+func TestNewTestServer_Faults(t *testing.T) {
+	t.Run("every call fails", func(t *testing.T) {
+		srv := stowrytest.NewTestServer(t, stowrytest.Options{
+			Seed:          []stowrytest.SeedObject{{Path: "a.txt", Content: []byte("a")}},
+			StorageFaults: stowrytest.Faults{FailureRate: 1},
+		})
+
+		status, _ := get(t, srv.URL+"/a.txt")
+		assert.Equal(t, http.StatusInternalServerError, status)
+		assert.Equal(t, http.StatusInternalServerError, put(t, srv.URL+"/b.txt", []byte("b")))
+
+		_, content := srv.Object(t, "a.txt")
+		assert.Equal(t, "a", string(content), "inspection bypasses faults")
+	})
+
+	t.Run("custom error", func(t *testing.T) {
+		srv := stowrytest.NewTestServer(t, stowrytest.Options{
+			RepoFaults: stowrytest.Faults{FailureRate: 1, Err: stowry.ErrInsufficientStorage},
+		})
+
+		assert.Equal(t, http.StatusInsufficientStorage, put(t, srv.URL+"/b.txt", []byte("b")))
+	})
+
+	t.Run("same seed same failures", func(t *testing.T) {
+		statuses := func() []int {
+			srv := stowrytest.NewTestServer(t, stowrytest.Options{
+				Seed:       []stowrytest.SeedObject{{Path: "a.txt", Content: []byte("a")}},
+				RepoFaults: stowrytest.Faults{FailureRate: 0.5},
+				FaultSeed:  42,
+			})
+			var got []int
+			for range 20 {
+				status, _ := get(t, srv.URL+"/a.txt")
+				got = append(got, status)
+			}
+			return got
+		}
+
+		first := statuses()
+		assert.Equal(t, first, statuses())
+		assert.Contains(t, first, http.StatusOK)
+		assert.Contains(t, first, http.StatusInternalServerError)
+	})
+
+	t.Run("latency", func(t *testing.T) {
+		srv := stowrytest.NewTestServer(t, stowrytest.Options{
+			Seed:          []stowrytest.SeedObject{{Path: "a.txt", Content: []byte("a")}},
+			StorageFaults: stowrytest.Faults{Latency: 200 * time.Millisecond},
+		})
+
+		start := time.Now()
+		status, _ := get(t, srv.URL+"/a.txt")
+		assert.Equal(t, http.StatusOK, status)
+		assert.GreaterOrEqual(t, time.Since(start), 200*time.Millisecond)
+
+		client := &http.Client{Timeout: 50 * time.Millisecond}
+		_, err := client.Get(srv.URL + "/a.txt")
+		var netErr interface{ Timeout() bool }
+		require.True(t, errors.As(err, &netErr), "got %v", err)
+		assert.True(t, netErr.Timeout())
+	})
+}
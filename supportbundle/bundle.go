@@ -0,0 +1,183 @@
+// Package supportbundle writes support bundles: a gzipped tar of JSON files
+// describing a Stowry deployment, attached to bug reports.
+//
+// A bundle is built from Sections, each collected into one JSON file next
+// to manifest.json, which records the format version and what became of
+// every section. A section that fails or does not apply is listed in the
+// manifest with its error or reason instead of failing the bundle, since
+// bundles are most needed when something is broken. Every file passes
+// through a Redactor before it is written.
+package supportbundle
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+// FormatVersion is the layout version recorded in the manifest. It changes
+// when a file is renamed or a field changes meaning, so tooling can tell
+// which layout it is reading.
+const FormatVersion = 1
+
+// Dir is the directory inside the tarball that holds the bundle files.
+const Dir = "stowry-support"
+
+// ManifestFile is the name of the manifest inside Dir.
+const ManifestFile = "manifest.json"
+
+// Section is one file of a bundle, <Name>.json.
+type Section struct {
+	Name string
+	// Collect returns the value written as JSON. An error from Skip leaves
+	// the section out with its reason; any other error is recorded in the
+	// manifest.
+	Collect func(ctx context.Context) (any, error)
+}
+
+// Manifest is the content of ManifestFile.
+type Manifest struct {
+	FormatVersion int             `json:"format_version"`
+	CreatedAt     time.Time       `json:"created_at"`
+	Sections      []ManifestEntry `json:"sections"`
+}
+
+// ManifestEntry is what became of one section.
+type ManifestEntry struct {
+	Name    string `json:"name"`
+	File    string `json:"file,omitempty"`    // Path inside the tarball; empty when skipped or failed
+	Skipped string `json:"skipped,omitempty"` // Why the section does not apply
+	Error   string `json:"error,omitempty"`   // Why collecting the section failed
+}
+
+// skipError is a section that does not apply, returned by Skip.
+type skipError struct {
+	reason string
+}
+
+func (e *skipError) Error() string {
+	return "skipped: " + e.reason
+}
+
+// Skip returns the error a Section's Collect returns when the section does
+// not apply, such as recent errors outside the server.
+func Skip(reason string) error {
+	return &skipError{reason: reason}
+}
+
+// Write collects sections in order and writes the bundle to w as a gzipped
+// tar, passing every file through redactor. Sections are collected before
+// anything is written, so an error returned by Write comes from w.
+func Write(ctx context.Context, w io.Writer, redactor *Redactor, sections []Section) error {
+	manifest := Manifest{FormatVersion: FormatVersion, CreatedAt: time.Now().UTC()}
+	files := make(map[string][]byte, len(sections))
+
+	for _, section := range sections {
+		entry := ManifestEntry{Name: section.Name}
+		value, err := section.Collect(ctx)
+		var skip *skipError
+		switch {
+		case errors.As(err, &skip):
+			entry.Skipped = skip.reason
+		case err != nil:
+			entry.Error = string(redactor.Redact([]byte(err.Error())))
+		default:
+			data, err := marshal(value)
+			if err != nil {
+				entry.Error = fmt.Sprintf("encode: %v", err)
+				break
+			}
+			entry.File = Dir + "/" + section.Name + ".json"
+			files[entry.File] = redactor.Redact(data)
+		}
+		manifest.Sections = append(manifest.Sections, entry)
+	}
+
+	data, err := marshal(manifest)
+	if err != nil {
+		return fmt.Errorf("support bundle: encode manifest: %w", err)
+	}
+
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+	if err := writeFile(tw, Dir+"/"+ManifestFile, data, manifest.CreatedAt); err != nil {
+		return err
+	}
+	for _, entry := range manifest.Sections {
+		if entry.File == "" {
+			continue
+		}
+		if err := writeFile(tw, entry.File, files[entry.File], manifest.CreatedAt); err != nil {
+			return err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return fmt.Errorf("support bundle: %w", err)
+	}
+	if err := gz.Close(); err != nil {
+		return fmt.Errorf("support bundle: %w", err)
+	}
+	return nil
+}
+
+// marshal encodes v as indented JSON without HTML escaping, so secrets are
+// written as they are and the redactor finds them.
+func marshal(v any) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func writeFile(tw *tar.Writer, name string, data []byte, modTime time.Time) error {
+	header := &tar.Header{
+		Name:    name,
+		Mode:    0o600,
+		Size:    int64(len(data)),
+		ModTime: modTime,
+	}
+	if err := tw.WriteHeader(header); err != nil {
+		return fmt.Errorf("support bundle: write %s: %w", name, err)
+	}
+	if _, err := tw.Write(data); err != nil {
+		return fmt.Errorf("support bundle: write %s: %w", name, err)
+	}
+	return nil
+}
+
+// Read returns the files of a bundle written by Write, keyed by their path
+// inside the tarball.
+func Read(r io.Reader) (map[string][]byte, error) {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, fmt.Errorf("read support bundle: %w", err)
+	}
+	defer func() { _ = gz.Close() }()
+
+	files := make(map[string][]byte)
+	tr := tar.NewReader(gz)
+	for {
+		header, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return files, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("read support bundle: %w", err)
+		}
+		data, err := io.ReadAll(tr)
+		if err != nil {
+			return nil, fmt.Errorf("read support bundle: %s: %w", header.Name, err)
+		}
+		files[header.Name] = data
+	}
+}
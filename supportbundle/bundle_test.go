@@ -0,0 +1,84 @@
+package supportbundle_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sagarc03/stowry/supportbundle"
+)
+
+func TestWrite(t *testing.T) {
+	sections := []supportbundle.Section{
+		{Name: "version", Collect: func(context.Context) (any, error) {
+			return map[string]string{"version": "1.2.3"}, nil
+		}},
+		{Name: "errors", Collect: func(context.Context) (any, error) {
+			return nil, supportbundle.Skip("server only")
+		}},
+		{Name: "health", Collect: func(context.Context) (any, error) {
+			return nil, errors.New("connect database: password hunter2 rejected")
+		}},
+		{Name: "config", Collect: func(context.Context) (any, error) {
+			return map[string]string{"dsn": "postgres://app:hunter2@db/stowry", "note": "<a&b>"}, nil
+		}},
+	}
+
+	var buf bytes.Buffer
+	require.NoError(t, supportbundle.Write(context.Background(), &buf, supportbundle.NewRedactor("hunter2"), sections))
+
+	files, err := supportbundle.Read(&buf)
+	require.NoError(t, err)
+	assert.Len(t, files, 3, "manifest plus the two collected sections")
+
+	var manifest supportbundle.Manifest
+	require.NoError(t, json.Unmarshal(files["stowry-support/manifest.json"], &manifest))
+	assert.Equal(t, supportbundle.FormatVersion, manifest.FormatVersion)
+	assert.False(t, manifest.CreatedAt.IsZero())
+	assert.Equal(t, []supportbundle.ManifestEntry{
+		{Name: "version", File: "stowry-support/version.json"},
+		{Name: "errors", Skipped: "server only"},
+		{Name: "health", Error: "connect database: password [REDACTED] rejected"},
+		{Name: "config", File: "stowry-support/config.json"},
+	}, manifest.Sections)
+
+	var version map[string]string
+	require.NoError(t, json.Unmarshal(files["stowry-support/version.json"], &version))
+	assert.Equal(t, "1.2.3", version["version"])
+
+	var config map[string]string
+	require.NoError(t, json.Unmarshal(files["stowry-support/config.json"], &config))
+	assert.Equal(t, "postgres://app:[REDACTED]@db/stowry", config["dsn"])
+	assert.Equal(t, "<a&b>", config["note"], "written without HTML escaping")
+
+	for name, data := range files {
+		assert.NotContains(t, string(data), "hunter2", name)
+	}
+}
+
+func TestWrite_UnencodableSection(t *testing.T) {
+	sections := []supportbundle.Section{{Name: "bad", Collect: func(context.Context) (any, error) {
+		return make(chan int), nil
+	}}}
+
+	var buf bytes.Buffer
+	require.NoError(t, supportbundle.Write(context.Background(), &buf, nil, sections))
+
+	files, err := supportbundle.Read(&buf)
+	require.NoError(t, err)
+	var manifest supportbundle.Manifest
+	require.NoError(t, json.Unmarshal(files["stowry-support/manifest.json"], &manifest))
+	require.Len(t, manifest.Sections, 1)
+	assert.Empty(t, manifest.Sections[0].File)
+	assert.Contains(t, manifest.Sections[0].Error, "encode")
+}
+
+func TestRead_NotABundle(t *testing.T) {
+	_, err := supportbundle.Read(bytes.NewReader([]byte("not gzip")))
+	assert.Error(t, err)
+}
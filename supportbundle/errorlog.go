@@ -0,0 +1,143 @@
+package supportbundle
+
+import (
+	"context"
+	"log/slog"
+	"slices"
+	"sync"
+	"time"
+)
+
+// DefaultErrorLogSize is how many records an ErrorLog keeps when its size
+// is not positive.
+const DefaultErrorLogSize = 100
+
+// ErrorEntry is one record kept by an ErrorLog.
+type ErrorEntry struct {
+	Time    time.Time         `json:"time"`
+	Level   string            `json:"level"`
+	Message string            `json:"message"`
+	Attrs   map[string]string `json:"attrs,omitempty"` // Group members are keyed like group.key
+}
+
+// errorRing is the ring buffer shared by an ErrorLog and the handlers
+// derived from it.
+type errorRing struct {
+	mu      sync.Mutex
+	entries []ErrorEntry
+	next    int // Index the next entry is written to once the ring is full
+	size    int
+}
+
+func (r *errorRing) add(e ErrorEntry) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(r.entries) < r.size {
+		r.entries = append(r.entries, e)
+		return
+	}
+	r.entries[r.next] = e
+	r.next = (r.next + 1) % r.size
+}
+
+// ErrorLog is a slog.Handler that passes records to another handler and
+// keeps the most recent ones at slog.LevelError or above in memory, for
+// support bundles. Attributes whose names mark them as secrets, see
+// SensitiveKey, are kept as Redacted.
+type ErrorLog struct {
+	next   slog.Handler
+	ring   *errorRing
+	attrs  []slog.Attr // Added with WithAttrs, keys already qualified
+	prefix string      // Group prefix of attributes added later, "" or "a.b."
+}
+
+// NewErrorLog returns an ErrorLog that keeps the last size error records
+// and passes every record to next.
+func NewErrorLog(next slog.Handler, size int) *ErrorLog {
+	if size <= 0 {
+		size = DefaultErrorLogSize
+	}
+	return &ErrorLog{next: next, ring: &errorRing{size: size}}
+}
+
+// Enabled reports whether next handles level. Error records are always
+// enabled, so they are kept even when next drops them.
+func (l *ErrorLog) Enabled(ctx context.Context, level slog.Level) bool {
+	return level >= slog.LevelError || l.next.Enabled(ctx, level)
+}
+
+// Handle keeps r if it is an error and passes it to next if next handles
+// its level.
+func (l *ErrorLog) Handle(ctx context.Context, r slog.Record) error {
+	if r.Level >= slog.LevelError {
+		entry := ErrorEntry{Time: r.Time.UTC(), Level: r.Level.String(), Message: r.Message}
+		attrs := make(map[string]string)
+		for _, a := range l.attrs {
+			addAttr(attrs, "", a)
+		}
+		r.Attrs(func(a slog.Attr) bool {
+			addAttr(attrs, l.prefix, a)
+			return true
+		})
+		if len(attrs) > 0 {
+			entry.Attrs = attrs
+		}
+		l.ring.add(entry)
+	}
+
+	if !l.next.Enabled(ctx, r.Level) {
+		return nil
+	}
+	return l.next.Handle(ctx, r)
+}
+
+// WithAttrs returns an ErrorLog sharing l's ring whose records carry attrs.
+func (l *ErrorLog) WithAttrs(attrs []slog.Attr) slog.Handler {
+	qualified := slices.Clone(l.attrs)
+	for _, a := range attrs {
+		a.Key = l.prefix + a.Key
+		qualified = append(qualified, a)
+	}
+	return &ErrorLog{next: l.next.WithAttrs(attrs), ring: l.ring, attrs: qualified, prefix: l.prefix}
+}
+
+// WithGroup returns an ErrorLog sharing l's ring that qualifies later
+// attributes with name.
+func (l *ErrorLog) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return l
+	}
+	return &ErrorLog{next: l.next.WithGroup(name), ring: l.ring, attrs: l.attrs, prefix: l.prefix + name + "."}
+}
+
+// Entries returns the kept records, oldest first.
+func (l *ErrorLog) Entries() []ErrorEntry {
+	l.ring.mu.Lock()
+	defer l.ring.mu.Unlock()
+	entries := make([]ErrorEntry, 0, len(l.ring.entries))
+	entries = append(entries, l.ring.entries[l.ring.next:]...)
+	return append(entries, l.ring.entries[:l.ring.next]...)
+}
+
+// addAttr adds a to attrs under prefix, flattening groups.
+func addAttr(attrs map[string]string, prefix string, a slog.Attr) {
+	a.Value = a.Value.Resolve()
+	if a.Value.Kind() == slog.KindGroup {
+		group := prefix
+		if a.Key != "" {
+			group += a.Key + "."
+		}
+		for _, member := range a.Value.Group() {
+			addAttr(attrs, group, member)
+		}
+		return
+	}
+	if a.Key == "" {
+		return
+	}
+	value := a.Value.String()
+	if SensitiveKey(a.Key) {
+		value = Redacted
+	}
+	attrs[prefix+a.Key] = value
+}
@@ -0,0 +1,54 @@
+package supportbundle_test
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log/slog"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sagarc03/stowry/supportbundle"
+)
+
+func TestErrorLog(t *testing.T) {
+	var out bytes.Buffer
+	next := slog.NewTextHandler(&out, &slog.HandlerOptions{Level: slog.LevelInfo})
+	errorLog := supportbundle.NewErrorLog(next, 2)
+	logger := slog.New(errorLog)
+
+	logger.Info("started")
+	logger.Error("first")
+	logger.With("path", "a.txt").WithGroup("req").Error("second", "method", "PUT", "secret_key", "abc")
+	logger.Error("third", slog.Group("db", "password", "hunter2", "table", "meta"))
+
+	entries := errorLog.Entries()
+	require.Len(t, entries, 2, "the oldest error is dropped")
+	assert.Equal(t, "second", entries[0].Message)
+	assert.Equal(t, "ERROR", entries[0].Level)
+	assert.Equal(t, map[string]string{"path": "a.txt", "req.method": "PUT", "req.secret_key": supportbundle.Redacted}, entries[0].Attrs)
+	assert.Equal(t, "third", entries[1].Message)
+	assert.Equal(t, map[string]string{"db.password": supportbundle.Redacted, "db.table": "meta"}, entries[1].Attrs)
+
+	assert.Contains(t, out.String(), "msg=started", "records are passed on")
+	assert.Contains(t, out.String(), "msg=first")
+}
+
+func TestErrorLog_KeepsErrorsNextDrops(t *testing.T) {
+	var out bytes.Buffer
+	next := slog.NewTextHandler(&out, &slog.HandlerOptions{Level: slog.LevelError + 4})
+	errorLog := supportbundle.NewErrorLog(next, 0)
+	logger := slog.New(errorLog)
+
+	assert.False(t, errorLog.Enabled(context.Background(), slog.LevelWarn))
+	for i := range supportbundle.DefaultErrorLogSize + 1 {
+		logger.Error(fmt.Sprintf("error %d", i))
+	}
+
+	entries := errorLog.Entries()
+	require.Len(t, entries, supportbundle.DefaultErrorLogSize)
+	assert.Equal(t, "error 1", entries[0].Message)
+	assert.Empty(t, out.String())
+}
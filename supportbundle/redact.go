@@ -0,0 +1,74 @@
+package supportbundle
+
+import (
+	"bytes"
+	"encoding/json"
+	"slices"
+	"strings"
+)
+
+// Redacted replaces secrets in support bundles.
+const Redacted = "[REDACTED]"
+
+// Redactor replaces known secret values, such as access keys, secret keys
+// and database passwords, wherever they appear. Secrets that settings name
+// explicitly are best redacted where they are collected too; the Redactor
+// catches the copies quoted in error messages and logs.
+type Redactor struct {
+	secrets []string
+}
+
+// NewRedactor returns a Redactor for secrets. Empty values are ignored.
+func NewRedactor(secrets ...string) *Redactor {
+	var r Redactor
+	for _, secret := range secrets {
+		if secret == "" || secret == Redacted {
+			continue
+		}
+		r.secrets = append(r.secrets, secret)
+		// The form JSON writes it in when it holds quotes or control
+		// characters
+		if quoted, err := json.Marshal(secret); err == nil {
+			if escaped := string(quoted[1 : len(quoted)-1]); escaped != secret {
+				r.secrets = append(r.secrets, escaped)
+			}
+		}
+	}
+	// Longest first, so a secret that contains another is replaced whole
+	slices.SortFunc(r.secrets, func(a, b string) int {
+		if len(a) != len(b) {
+			return len(b) - len(a)
+		}
+		return strings.Compare(a, b)
+	})
+	r.secrets = slices.Compact(r.secrets)
+	return &r
+}
+
+// Redact returns data with every secret replaced by Redacted. A nil
+// Redactor returns data unchanged.
+func (r *Redactor) Redact(data []byte) []byte {
+	if r == nil {
+		return data
+	}
+	for _, secret := range r.secrets {
+		data = bytes.ReplaceAll(data, []byte(secret), []byte(Redacted))
+	}
+	return data
+}
+
+// sensitiveKeys are substrings of attribute and field names whose values are
+// always redacted.
+var sensitiveKeys = []string{"secret", "password", "token", "access_key", "signing_key", "authorization"}
+
+// SensitiveKey reports whether a log attribute or setting named key holds a
+// secret, such as secret_key or password.
+func SensitiveKey(key string) bool {
+	key = strings.ToLower(key)
+	for _, s := range sensitiveKeys {
+		if strings.Contains(key, s) {
+			return true
+		}
+	}
+	return false
+}
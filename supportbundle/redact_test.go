@@ -0,0 +1,31 @@
+package supportbundle_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/sagarc03/stowry/supportbundle"
+)
+
+func TestRedactor_Redact(t *testing.T) {
+	r := supportbundle.NewRedactor("", "abc", "abcdef", "abc", `pa"ss`)
+
+	assert.Equal(t, "key [REDACTED] and [REDACTED]", string(r.Redact([]byte("key abcdef and abc"))))
+	assert.Equal(t, `{"p":"[REDACTED]"}`, string(r.Redact([]byte(`{"p":"pa\"ss"}`))), "JSON-escaped form")
+	assert.Equal(t, "nothing secret", string(r.Redact([]byte("nothing secret"))))
+}
+
+func TestRedactor_Nil(t *testing.T) {
+	var r *supportbundle.Redactor
+	assert.Equal(t, "abc", string(r.Redact([]byte("abc"))))
+}
+
+func TestSensitiveKey(t *testing.T) {
+	for _, key := range []string{"secret_key", "Password", "db.password", "access_key", "signing_key", "session_token", "Authorization"} {
+		assert.True(t, supportbundle.SensitiveKey(key), key)
+	}
+	for _, key := range []string{"path", "error", "access", "method"} {
+		assert.False(t, supportbundle.SensitiveKey(key), key)
+	}
+}
@@ -0,0 +1,108 @@
+// Package tracing adds optional OpenTelemetry tracing to Stowry.
+//
+// Setup installs a global tracer provider that exports spans over OTLP/HTTP.
+// WrapService, WrapRepo and WrapStorage decorate the service, metadata
+// repository and file storage so every call becomes a child span of the
+// request span created by http.TracingMiddleware.
+//
+// Everything is compiled in; until Setup runs with tracing enabled the global
+// provider is a no-op and the wrappers only add a function call.
+//
+// Spans carry object paths, byte counts and row counts. Query strings,
+// signatures and keys are never recorded.
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// InstrumentationName identifies Stowry's spans.
+const InstrumentationName = "github.com/sagarc03/stowry"
+
+// Span attribute keys shared by the wrappers.
+const (
+	AttrPath  = attribute.Key("stowry.path")
+	AttrBytes = attribute.Key("stowry.bytes")
+	AttrRows  = attribute.Key("stowry.rows")
+	AttrCount = attribute.Key("stowry.count")
+)
+
+// Config holds tracing configuration.
+type Config struct {
+	Enabled bool `mapstructure:"enabled"`
+	// Endpoint is the OTLP/HTTP collector, as host:port or a full URL. Empty
+	// uses the OTEL_EXPORTER_OTLP_* environment variables, then localhost:4318.
+	Endpoint string `mapstructure:"endpoint"`
+	Insecure bool   `mapstructure:"insecure"` // Use plain HTTP for a host:port Endpoint
+	// SamplingRatio is the fraction of new traces recorded. Requests that
+	// carry a sampled traceparent are always recorded.
+	SamplingRatio float64 `mapstructure:"sampling_ratio" validate:"min=0,max=1"`
+	ServiceName   string  `mapstructure:"service_name"`
+}
+
+// Setup installs a global tracer provider and W3C trace-context propagator for
+// cfg. When tracing is disabled it changes nothing and returns a no-op
+// shutdown. Call the returned function before exit to flush pending spans.
+func Setup(ctx context.Context, cfg Config) (func(context.Context) error, error) {
+	if !cfg.Enabled {
+		return func(context.Context) error { return nil }, nil
+	}
+
+	var opts []otlptracehttp.Option
+	switch {
+	case strings.Contains(cfg.Endpoint, "://"):
+		opts = append(opts, otlptracehttp.WithEndpointURL(cfg.Endpoint))
+	case cfg.Endpoint != "":
+		opts = append(opts, otlptracehttp.WithEndpoint(cfg.Endpoint))
+		if cfg.Insecure {
+			opts = append(opts, otlptracehttp.WithInsecure())
+		}
+	}
+
+	exporter, err := otlptracehttp.New(ctx, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("create otlp exporter: %w", err)
+	}
+
+	serviceName := cfg.ServiceName
+	if serviceName == "" {
+		serviceName = "stowry"
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(cfg.SamplingRatio))),
+		sdktrace.WithResource(resource.NewSchemaless(semconv.ServiceName(serviceName))),
+	)
+
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
+	return provider.Shutdown, nil
+}
+
+// start begins a span named name using the global tracer provider.
+func start(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return otel.Tracer(InstrumentationName).Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// end records err on span, if any, and ends it.
+func end(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
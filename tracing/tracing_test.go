@@ -0,0 +1,518 @@
+package tracing_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sagarc03/stowry"
+	"github.com/sagarc03/stowry/database"
+	"github.com/sagarc03/stowry/filesystem"
+	stowryhttp "github.com/sagarc03/stowry/http"
+	"github.com/sagarc03/stowry/tracing"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// newRecorder installs a global tracer provider that records spans in memory.
+// Tests using it must not run in parallel.
+func newRecorder(t *testing.T) *tracetest.SpanRecorder {
+	t.Helper()
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+
+	prevProvider, prevPropagator := otel.GetTracerProvider(), otel.GetTextMapPropagator()
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	t.Cleanup(func() {
+		_ = provider.Shutdown(context.Background())
+		otel.SetTracerProvider(prevProvider)
+		otel.SetTextMapPropagator(prevPropagator)
+	})
+	return recorder
+}
+
+// newTracedRouter builds a store-mode router over a real sqlite repo and
+// filesystem store, with every layer traced.
+func newTracedRouter(t *testing.T) http.Handler {
+	t.Helper()
+	ctx := context.Background()
+
+	db, err := database.Connect(ctx, database.Config{
+		Type:   "sqlite",
+		DSN:    ":memory:",
+		Tables: stowry.Tables{MetaData: "stowry_metadata"},
+	})
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = db.Close() })
+	require.NoError(t, db.Migrate(ctx))
+
+	root, err := os.OpenRoot(t.TempDir())
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = root.Close() })
+
+	service, err := stowry.NewStowryService(
+		tracing.WrapRepo(db.GetRepo()),
+		tracing.WrapStorage(filesystem.NewFileStorage(root)),
+		stowry.ServiceConfig{Mode: stowry.ModeStore},
+	)
+	require.NoError(t, err)
+
+	config := &stowryhttp.HandlerConfig{Mode: stowry.ModeStore, Tracing: true}
+	return stowryhttp.NewHandler(config, tracing.WrapService(service)).Router()
+}
+
+func spansByName(spans []sdktrace.ReadOnlySpan) map[string]sdktrace.ReadOnlySpan {
+	byName := make(map[string]sdktrace.ReadOnlySpan, len(spans))
+	for _, s := range spans {
+		byName[s.Name()] = s
+	}
+	return byName
+}
+
+func attr(s sdktrace.ReadOnlySpan, key attribute.Key) attribute.Value {
+	for _, kv := range s.Attributes() {
+		if kv.Key == key {
+			return kv.Value
+		}
+	}
+	return attribute.Value{}
+}
+
+func TestTracing_PutAndGet(t *testing.T) {
+	recorder := newRecorder(t)
+	router := newTracedRouter(t)
+
+	t.Run("put", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodPut, "/docs/a.txt?X-Stowry-Signature=secret", strings.NewReader("hello"))
+		req.Header.Set("Content-Type", "text/plain")
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		require.Equal(t, http.StatusOK, rec.Code)
+
+		spans := spansByName(recorder.Ended())
+		server, ok := spans["PUT /*"]
+		require.True(t, ok, "server span")
+		assert.Equal(t, "PUT", attr(server, "http.request.method").AsString())
+		assert.Equal(t, "/docs/a.txt", attr(server, "url.path").AsString())
+		assert.Equal(t, int64(http.StatusOK), attr(server, "http.response.status_code").AsInt64())
+		for _, kv := range server.Attributes() {
+			assert.NotContains(t, kv.Value.Emit(), "secret", "query string must not be recorded")
+		}
+
+		create := spans["stowry.Service.Create"]
+		require.NotNil(t, create)
+		assert.Equal(t, server.SpanContext().SpanID(), create.Parent().SpanID())
+		assert.Equal(t, "docs/a.txt", attr(create, tracing.AttrPath).AsString())
+		assert.Equal(t, int64(5), attr(create, tracing.AttrBytes).AsInt64())
+
+		write := spans["stowry.FileStorage.Write"]
+		require.NotNil(t, write)
+		assert.Equal(t, create.SpanContext().SpanID(), write.Parent().SpanID())
+		assert.Equal(t, int64(5), attr(write, tracing.AttrBytes).AsInt64())
+
+		upsert := spans["stowry.MetaDataRepo.Upsert"]
+		require.NotNil(t, upsert)
+		assert.Equal(t, create.SpanContext().SpanID(), upsert.Parent().SpanID())
+		assert.Equal(t, server.SpanContext().TraceID(), upsert.SpanContext().TraceID())
+	})
+
+	t.Run("get", func(t *testing.T) {
+		before := len(recorder.Ended())
+
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/docs/a.txt", http.NoBody))
+		require.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "hello", rec.Body.String())
+
+		spans := spansByName(recorder.Ended()[before:])
+		server, ok := spans["GET /*"]
+		require.True(t, ok, "server span")
+
+		get := spans["stowry.Service.Get"]
+		require.NotNil(t, get)
+		assert.Equal(t, server.SpanContext().SpanID(), get.Parent().SpanID())
+
+		for _, name := range []string{"stowry.MetaDataRepo.Get", "stowry.FileStorage.Get"} {
+			child := spans[name]
+			require.NotNil(t, child, name)
+			assert.Equal(t, get.SpanContext().SpanID(), child.Parent().SpanID(), name)
+			assert.Equal(t, "docs/a.txt", attr(child, tracing.AttrPath).AsString(), name)
+		}
+	})
+
+	t.Run("not found is not a server error", func(t *testing.T) {
+		before := len(recorder.Ended())
+
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/missing.txt", http.NoBody))
+		require.Equal(t, http.StatusNotFound, rec.Code)
+
+		spans := spansByName(recorder.Ended()[before:])
+		assert.Equal(t, int64(http.StatusNotFound), attr(spans["GET /*"], "http.response.status_code").AsInt64())
+		assert.NotEqual(t, codes.Error, spans["GET /*"].Status().Code)
+		assert.Equal(t, codes.Error, spans["stowry.MetaDataRepo.Get"].Status().Code)
+	})
+}
+
+func TestTracing_ContinuesIncomingTrace(t *testing.T) {
+	recorder := newRecorder(t)
+	router := newTracedRouter(t)
+
+	const traceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+	req := httptest.NewRequest(http.MethodGet, "/", http.NoBody)
+	req.Header.Set("traceparent", "00-"+traceID+"-00f067aa0ba902b7-01")
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	spans := spansByName(recorder.Ended())
+	server, ok := spans["GET /"]
+	require.True(t, ok, "server span")
+	assert.Equal(t, traceID, server.SpanContext().TraceID().String())
+	assert.Equal(t, "00f067aa0ba902b7", server.Parent().SpanID().String())
+	assert.True(t, server.Parent().IsRemote())
+
+	list := spans["stowry.MetaDataRepo.List"]
+	require.NotNil(t, list)
+	assert.Equal(t, traceID, list.SpanContext().TraceID().String())
+}
+
+func TestWrapService_DeltaService(t *testing.T) {
+	service, err := stowry.NewStowryService(nil, nil, stowry.ServiceConfig{Mode: stowry.ModeStore})
+	require.NoError(t, err)
+
+	_, ok := tracing.WrapService(service).(stowryhttp.DeltaService)
+	assert.True(t, ok, "delta support is kept")
+
+	_, ok = tracing.WrapService(plainService{}).(stowryhttp.DeltaService)
+	assert.False(t, ok, "delta support is not invented")
+}
+
+func TestWrapService_StageService(t *testing.T) {
+	service, err := stowry.NewStowryService(nil, nil, stowry.ServiceConfig{Mode: stowry.ModeStore})
+	require.NoError(t, err)
+
+	wrapped := tracing.WrapService(service)
+	_, ok := wrapped.(stowryhttp.StageService)
+	assert.True(t, ok, "stage support is kept")
+	_, ok = wrapped.(stowryhttp.DeltaService)
+	assert.True(t, ok, "alongside delta support")
+
+	_, ok = tracing.WrapService(plainService{}).(stowryhttp.StageService)
+	assert.False(t, ok, "stage support is not invented")
+}
+
+func TestWrapRepo_StageRepo(t *testing.T) {
+	_, ok := tracing.WrapRepo(stageRepo{}).(stowry.StageRepo)
+	assert.True(t, ok, "stage support is kept")
+
+	_, ok = tracing.WrapRepo(plainRepo{}).(stowry.StageRepo)
+	assert.False(t, ok, "stage support is not invented")
+}
+
+func TestWrapRepo_IntentRepo(t *testing.T) {
+	_, ok := tracing.WrapRepo(intentRepo{}).(stowry.IntentRepo)
+	assert.True(t, ok, "intent support is kept")
+
+	wrapped := tracing.WrapRepo(stageIntentRepo{})
+	_, ok = wrapped.(stowry.IntentRepo)
+	assert.True(t, ok, "intent support is kept")
+	_, ok = wrapped.(stowry.StageRepo)
+	assert.True(t, ok, "alongside stage support")
+
+	_, ok = tracing.WrapRepo(stageRepo{}).(stowry.IntentRepo)
+	assert.False(t, ok, "intent support is not invented")
+}
+
+func TestWrap_UnsupportedChangeFeed(t *testing.T) {
+	ctx := context.Background()
+
+	_, err := tracing.WrapRepo(plainRepo{}).(stowry.ChangeFeed).Changes(ctx, stowry.ChangeQuery{})
+	assert.ErrorIs(t, err, stowry.ErrNotSupported)
+	_, err = tracing.WrapRepo(plainRepo{}).(stowry.ChangeFeed).PruneChanges(ctx, time.Now())
+	assert.ErrorIs(t, err, stowry.ErrNotSupported)
+
+	_, err = tracing.WrapRepo(plainRepo{}).(stowry.ChangeHistory).History(ctx, stowry.HistoryQuery{Path: "a.txt"})
+	assert.ErrorIs(t, err, stowry.ErrNotSupported)
+
+	_, err = tracing.WrapService(plainService{}).(stowryhttp.ChangeService).WatchChanges(ctx, stowry.ChangeQuery{}, 0)
+	assert.ErrorIs(t, err, stowry.ErrNotSupported)
+	_, err = tracing.WrapService(plainService{}).(stowryhttp.HistoryService).History(ctx, stowry.HistoryQuery{Path: "a.txt"})
+	assert.ErrorIs(t, err, stowry.ErrNotSupported)
+}
+
+func TestWrap_UnsupportedPing(t *testing.T) {
+	assert.NoError(t, tracing.WrapRepo(plainRepo{}).(stowry.Pinger).Ping(context.Background()))
+}
+
+func TestWrap_UnsupportedDeferCleanup(t *testing.T) {
+	err := tracing.WrapRepo(plainRepo{}).(stowry.CleanupDeferrer).DeferCleanup(context.Background(), uuid.New(), time.Now())
+	assert.ErrorIs(t, err, stowry.ErrNotSupported)
+}
+
+func TestWrap_UnsupportedGetDeleted(t *testing.T) {
+	_, _, err := tracing.WrapService(plainService{}).(stowryhttp.DeletedService).GetDeleted(context.Background(), "a.txt")
+	assert.ErrorIs(t, err, stowry.ErrNotSupported)
+}
+
+func TestWrap_UnsupportedMultipart(t *testing.T) {
+	ctx := context.Background()
+
+	_, err := tracing.WrapRepo(plainRepo{}).(stowry.MultipartRepo).CreateMultipartUpload(ctx, stowry.MultipartUpload{Path: "a.txt"})
+	assert.ErrorIs(t, err, stowry.ErrNotSupported)
+	_, err = tracing.WrapRepo(plainRepo{}).(stowry.MultipartRepo).StaleMultipartUploads(ctx, time.Now(), 0)
+	assert.ErrorIs(t, err, stowry.ErrNotSupported)
+
+	_, err = tracing.WrapService(plainService{}).(stowryhttp.MultipartService).InitiateMultipart(ctx, stowry.CreateObject{Path: "a.txt"})
+	assert.ErrorIs(t, err, stowry.ErrNotSupported)
+	err = tracing.WrapService(plainService{}).(stowryhttp.MultipartService).AbortMultipart(ctx, "a.txt", uuid.New())
+	assert.ErrorIs(t, err, stowry.ErrNotSupported)
+}
+
+func TestWrap_UnsupportedStatMany(t *testing.T) {
+	_, err := tracing.WrapService(plainService{}).(stowryhttp.StatService).StatMany(context.Background(), []string{"a.txt"})
+	assert.ErrorIs(t, err, stowry.ErrNotSupported)
+}
+
+func TestWrap_UnsupportedDeleteMany(t *testing.T) {
+	_, err := tracing.WrapService(plainService{}).(stowryhttp.DeleteManyService).DeleteMany(context.Background(), []string{"a.txt"})
+	assert.ErrorIs(t, err, stowry.ErrNotSupported)
+}
+
+func TestWrap_UnsupportedDeletePrefix(t *testing.T) {
+	_, err := tracing.WrapService(plainService{}).(stowryhttp.DeletePrefixService).DeletePrefix(context.Background(), "tmp/", false)
+	assert.ErrorIs(t, err, stowry.ErrNotSupported)
+}
+
+func TestWrap_UnsupportedCopy(t *testing.T) {
+	_, err := tracing.WrapService(plainService{}).(stowryhttp.CopyService).Copy(context.Background(), "a.txt", "b.txt")
+	assert.ErrorIs(t, err, stowry.ErrNotSupported)
+}
+
+func TestWrap_UnsupportedMove(t *testing.T) {
+	_, err := tracing.WrapService(plainService{}).(stowryhttp.MoveService).Move(context.Background(), "a.txt", "b.txt")
+	assert.ErrorIs(t, err, stowry.ErrNotSupported)
+}
+
+func TestWrap_UnsupportedPurge(t *testing.T) {
+	ctx := context.Background()
+
+	_, err := tracing.WrapService(plainService{}).(stowryhttp.PurgeService).Purge(ctx, "a.txt")
+	assert.ErrorIs(t, err, stowry.ErrNotSupported)
+
+	_, err = tracing.WrapRepo(plainRepo{}).(stowry.PurgeRepo).DeleteHard(ctx, "a.txt")
+	assert.ErrorIs(t, err, stowry.ErrNotSupported)
+}
+
+func TestWrap_UnsupportedRestore(t *testing.T) {
+	ctx := context.Background()
+	rs := tracing.WrapService(plainService{}).(stowryhttp.RestoreService)
+
+	_, err := rs.Restore(ctx, "a.txt")
+	assert.ErrorIs(t, err, stowry.ErrNotSupported)
+	_, err = rs.ListDeleted(ctx, stowry.ListQuery{Limit: 10})
+	assert.ErrorIs(t, err, stowry.ErrNotSupported)
+}
+
+func TestWrap_UnsupportedRepoExtensions(t *testing.T) {
+	ctx := context.Background()
+	repo := tracing.WrapRepo(plainRepo{})
+
+	_, err := repo.(stowry.RenameRepo).Rename(ctx, "a.txt", "b.txt", "")
+	assert.ErrorIs(t, err, stowry.ErrNotSupported)
+	_, err = repo.(stowry.UsageRepo).TakeUsageSnapshot(ctx)
+	assert.ErrorIs(t, err, stowry.ErrNotSupported)
+	_, err = repo.(stowry.BackendUsageRepo).BackendUsage(ctx)
+	assert.ErrorIs(t, err, stowry.ErrNotSupported)
+	_, err = repo.(stowry.InstanceRepo).InstanceID(ctx)
+	assert.ErrorIs(t, err, stowry.ErrNotSupported)
+	_, err = repo.(stowry.AccessKeyRepo).ListAccessKeys(ctx)
+	assert.ErrorIs(t, err, stowry.ErrNotSupported)
+
+	_, err = tracing.WrapService(plainService{}).(stowryhttp.UsageService).UsageReport(ctx, time.Now(), time.Now())
+	assert.ErrorIs(t, err, stowry.ErrNotSupported)
+	_, err = tracing.WrapService(plainService{}).(stowryhttp.BackendUsageService).BackendUsage(ctx)
+	assert.ErrorIs(t, err, stowry.ErrNotSupported)
+}
+
+// TestWrap_KeepsOptionalInterfaces checks that wrapping the real repository,
+// storage and service hides none of the optional interfaces they implement.
+func TestWrap_KeepsOptionalInterfaces(t *testing.T) {
+	ctx := context.Background()
+	db, err := database.Connect(ctx, database.Config{
+		Type:   "sqlite",
+		DSN:    ":memory:",
+		Tables: stowry.Tables{MetaData: "stowry_metadata"},
+	})
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = db.Close() })
+
+	root, err := os.OpenRoot(t.TempDir())
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = root.Close() })
+	store := filesystem.NewFileStorage(root)
+	routed, err := stowry.NewRoutedStorage(store, []stowry.StorageRoute{{Prefix: "fast/", Storage: store}})
+	require.NoError(t, err)
+
+	service, err := stowry.NewStowryService(db.GetRepo(), store, stowry.ServiceConfig{Mode: stowry.ModeStore})
+	require.NoError(t, err)
+
+	repoInterfaces := []reflect.Type{
+		reflect.TypeFor[stowry.AccessKeyRepo](),
+		reflect.TypeFor[stowry.BackendUsageRepo](),
+		reflect.TypeFor[stowry.ChangeFeed](),
+		reflect.TypeFor[stowry.ChangeHistory](),
+		reflect.TypeFor[stowry.CleanupDeferrer](),
+		reflect.TypeFor[stowry.InstanceRepo](),
+		reflect.TypeFor[stowry.IntentRepo](),
+		reflect.TypeFor[stowry.MultipartRepo](),
+		reflect.TypeFor[stowry.Pinger](),
+		reflect.TypeFor[stowry.PurgeRepo](),
+		reflect.TypeFor[stowry.RenameRepo](),
+		reflect.TypeFor[stowry.StageRepo](),
+		reflect.TypeFor[stowry.UsageRepo](),
+	}
+	storageInterfaces := []reflect.Type{
+		reflect.TypeFor[stowry.PathSpaceChecker](),
+		reflect.TypeFor[stowry.SpaceChecker](),
+		reflect.TypeFor[stowry.StorageCopier](),
+		reflect.TypeFor[stowry.StorageLocator](),
+		reflect.TypeFor[stowry.StorageMover](),
+		reflect.TypeFor[stowry.StorageStater](),
+		reflect.TypeFor[stowry.StorageWalker](),
+	}
+	serviceInterfaces := []reflect.Type{
+		reflect.TypeFor[stowryhttp.BackendUsageService](),
+		reflect.TypeFor[stowryhttp.ChangeService](),
+		reflect.TypeFor[stowryhttp.CopyService](),
+		reflect.TypeFor[stowryhttp.DeleteManyService](),
+		reflect.TypeFor[stowryhttp.DeletePrefixService](),
+		reflect.TypeFor[stowryhttp.DeletedService](),
+		reflect.TypeFor[stowryhttp.DeltaService](),
+		reflect.TypeFor[stowryhttp.HistoryService](),
+		reflect.TypeFor[stowryhttp.MoveService](),
+		reflect.TypeFor[stowryhttp.MultipartService](),
+		reflect.TypeFor[stowryhttp.PathLimitService](),
+		reflect.TypeFor[stowryhttp.PermalinkService](),
+		reflect.TypeFor[stowryhttp.PrecompressedService](),
+		reflect.TypeFor[stowryhttp.PurgeService](),
+		reflect.TypeFor[stowryhttp.RestoreService](),
+		reflect.TypeFor[stowryhttp.StageService](),
+		reflect.TypeFor[stowryhttp.StatService](),
+		reflect.TypeFor[stowryhttp.UsageService](),
+	}
+
+	tests := []struct {
+		name       string
+		value      any
+		wrapped    any
+		interfaces []reflect.Type
+	}{
+		{"repo", db.GetRepo(), tracing.WrapRepo(db.GetRepo()), repoInterfaces},
+		{"filesystem storage", store, tracing.WrapStorage(store), storageInterfaces},
+		{"routed storage", routed, tracing.WrapStorage(routed), storageInterfaces},
+		{"service", service, tracing.WrapService(service), serviceInterfaces},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, iface := range tt.interfaces {
+				if reflect.TypeOf(tt.value).Implements(iface) {
+					assert.True(t, reflect.TypeOf(tt.wrapped).Implements(iface), "%s is hidden", iface)
+				}
+			}
+		})
+	}
+}
+
+// plainRepo implements only stowry.MetaDataRepo.
+type plainRepo struct {
+	stowry.MetaDataRepo
+}
+
+// stageRepo implements stowry.MetaDataRepo and stowry.StageRepo.
+type stageRepo struct {
+	stowry.MetaDataRepo
+	stowry.StageRepo
+}
+
+// intentRepo implements stowry.MetaDataRepo and stowry.IntentRepo.
+type intentRepo struct {
+	stowry.MetaDataRepo
+	stowry.IntentRepo
+}
+
+// stageIntentRepo implements stowry.MetaDataRepo, stowry.StageRepo and
+// stowry.IntentRepo.
+type stageIntentRepo struct {
+	stowry.MetaDataRepo
+	stowry.StageRepo
+	stowry.IntentRepo
+}
+
+// plainService implements only stowryhttp.Service.
+type plainService struct {
+	stowryhttp.Service
+}
+
+func TestWrapStorage_UnsupportedExtensions(t *testing.T) {
+	storage := tracing.WrapStorage(plainStorage{})
+
+	assert.NoError(t, storage.(stowry.SpaceChecker).CheckSpace(context.Background(), 10))
+	err := storage.(stowry.StorageWalker).Walk(context.Background(), func(stowry.StoredFile) error { return nil })
+	assert.ErrorIs(t, err, stowry.ErrInvalidInput)
+	err = storage.(stowry.StorageMover).Move(context.Background(), "a", "b")
+	assert.ErrorIs(t, err, stowry.ErrInvalidInput)
+}
+
+// plainStorage implements only stowry.FileStorage.
+type plainStorage struct {
+	stowry.FileStorage
+}
+
+func TestSetup_Disabled(t *testing.T) {
+	prev := otel.GetTracerProvider()
+
+	shutdown, err := tracing.Setup(context.Background(), tracing.Config{Enabled: false, Endpoint: "collector:4318"})
+
+	require.NoError(t, err)
+	assert.NoError(t, shutdown(context.Background()))
+	assert.Equal(t, prev, otel.GetTracerProvider(), "disabled tracing must not install a provider")
+}
+
+func TestSetup_Enabled(t *testing.T) {
+	prev, prevPropagator := otel.GetTracerProvider(), otel.GetTextMapPropagator()
+	t.Cleanup(func() {
+		otel.SetTracerProvider(prev)
+		otel.SetTextMapPropagator(prevPropagator)
+	})
+
+	shutdown, err := tracing.Setup(context.Background(), tracing.Config{
+		Enabled:       true,
+		Endpoint:      "http://127.0.0.1:1/v1/traces",
+		SamplingRatio: 1,
+	})
+	require.NoError(t, err)
+	assert.NotEqual(t, prev, otel.GetTracerProvider())
+	assert.Contains(t, otel.GetTextMapPropagator().Fields(), "traceparent")
+
+	// Nothing was recorded, so shutdown does not contact the collector
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := shutdown(ctx); err != nil {
+		assert.True(t, errors.Is(err, context.Canceled), "unexpected shutdown error: %v", err)
+	}
+}
@@ -0,0 +1,1035 @@
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sagarc03/stowry"
+	stowryhttp "github.com/sagarc03/stowry/http"
+)
+
+// WrapService returns service with a span around every call. The result
+// implements stowryhttp.DeltaService and stowryhttp.StageService when service
+// does. It always implements stowryhttp.ChangeService,
+// stowryhttp.DeletedService, stowryhttp.HistoryService,
+// stowryhttp.StatService, stowryhttp.PermalinkService,
+// stowryhttp.MultipartService, stowryhttp.PurgeService,
+// stowryhttp.RestoreService, stowryhttp.UsageService and
+// stowryhttp.BackendUsageService; their methods return
+// stowry.ErrNotSupported when service does not implement them. It implements
+// stowryhttp.PathLimitService too, reporting the defaults when service does
+// not, and stowryhttp.PrecompressedService, falling back to Get and Info.
+func WrapService(service stowryhttp.Service) stowryhttp.Service {
+	s := tracedService{next: service}
+	ds, isDelta := service.(stowryhttp.DeltaService)
+	ss, isStage := service.(stowryhttp.StageService)
+	switch {
+	case isDelta && isStage:
+		return tracedDeltaStageService{tracedDeltaService{tracedService: s, delta: ds}, tracedStage{stage: ss}}
+	case isDelta:
+		return tracedDeltaService{tracedService: s, delta: ds}
+	case isStage:
+		return tracedStageService{tracedService: s, tracedStage: tracedStage{stage: ss}}
+	}
+	return s
+}
+
+type tracedService struct {
+	next stowryhttp.Service
+}
+
+func (s tracedService) WatchChanges(ctx context.Context, q stowry.ChangeQuery, wait time.Duration) (stowry.ChangeBatch, error) {
+	cs, ok := s.next.(stowryhttp.ChangeService)
+	if !ok {
+		return stowry.ChangeBatch{}, fmt.Errorf("watch changes: %w", stowry.ErrNotSupported)
+	}
+	ctx, span := start(ctx, "stowry.Service.WatchChanges", AttrPath.String(q.PathPrefix))
+	batch, err := cs.WatchChanges(ctx, q, wait)
+	span.SetAttributes(AttrCount.Int(len(batch.Changes)))
+	end(span, err)
+	return batch, err
+}
+
+func (s tracedService) PathLimits() stowry.PathLimits {
+	if pls, ok := s.next.(stowryhttp.PathLimitService); ok {
+		return pls.PathLimits()
+	}
+	return stowry.DefaultPathLimits()
+}
+
+func (s tracedService) GetNegotiated(ctx context.Context, path string, encodings []string) (stowry.MetaData, io.ReadSeekCloser, error) {
+	ps, ok := s.next.(stowryhttp.PrecompressedService)
+	if !ok {
+		return s.Get(ctx, path)
+	}
+	ctx, span := start(ctx, "stowry.Service.GetNegotiated", AttrPath.String(path))
+	m, rc, err := ps.GetNegotiated(ctx, path, encodings)
+	span.SetAttributes(AttrBytes.Int64(m.FileSizeBytes))
+	end(span, err)
+	return m, rc, err
+}
+
+func (s tracedService) InfoNegotiated(ctx context.Context, path string, encodings []string) (stowry.MetaData, error) {
+	ps, ok := s.next.(stowryhttp.PrecompressedService)
+	if !ok {
+		return s.Info(ctx, path)
+	}
+	ctx, span := start(ctx, "stowry.Service.InfoNegotiated", AttrPath.String(path))
+	m, err := ps.InfoNegotiated(ctx, path, encodings)
+	end(span, err)
+	return m, err
+}
+
+func (s tracedService) GetDeleted(ctx context.Context, path string) (stowry.MetaData, io.ReadSeekCloser, error) {
+	ds, ok := s.next.(stowryhttp.DeletedService)
+	if !ok {
+		return stowry.MetaData{}, nil, fmt.Errorf("get deleted: %w", stowry.ErrNotSupported)
+	}
+	ctx, span := start(ctx, "stowry.Service.GetDeleted", AttrPath.String(path))
+	m, rc, err := ds.GetDeleted(ctx, path)
+	span.SetAttributes(AttrBytes.Int64(m.FileSizeBytes))
+	end(span, err)
+	return m, rc, err
+}
+
+func (s tracedService) History(ctx context.Context, q stowry.HistoryQuery) (stowry.History, error) {
+	hs, ok := s.next.(stowryhttp.HistoryService)
+	if !ok {
+		return stowry.History{}, fmt.Errorf("history: %w", stowry.ErrNotSupported)
+	}
+	ctx, span := start(ctx, "stowry.Service.History", AttrPath.String(q.Path))
+	h, err := hs.History(ctx, q)
+	span.SetAttributes(AttrCount.Int(len(h.Changes)))
+	end(span, err)
+	return h, err
+}
+
+func (s tracedService) StatMany(ctx context.Context, paths []string) (map[string]stowry.MetaData, error) {
+	ss, ok := s.next.(stowryhttp.StatService)
+	if !ok {
+		return nil, fmt.Errorf("stat objects: %w", stowry.ErrNotSupported)
+	}
+	ctx, span := start(ctx, "stowry.Service.StatMany", AttrCount.Int(len(paths)))
+	found, err := ss.StatMany(ctx, paths)
+	span.SetAttributes(AttrRows.Int(len(found)))
+	end(span, err)
+	return found, err
+}
+
+func (s tracedService) DeleteMany(ctx context.Context, paths []string) ([]stowry.DeleteManyResult, error) {
+	ds, ok := s.next.(stowryhttp.DeleteManyService)
+	if !ok {
+		return nil, fmt.Errorf("delete objects: %w", stowry.ErrNotSupported)
+	}
+	ctx, span := start(ctx, "stowry.Service.DeleteMany", AttrCount.Int(len(paths)))
+	results, err := ds.DeleteMany(ctx, paths)
+	end(span, err)
+	return results, err
+}
+
+func (s tracedService) DeletePrefix(ctx context.Context, prefix string, dryRun bool) (stowry.DeletePrefixResult, error) {
+	ds, ok := s.next.(stowryhttp.DeletePrefixService)
+	if !ok {
+		return stowry.DeletePrefixResult{}, fmt.Errorf("delete prefix: %w", stowry.ErrNotSupported)
+	}
+	ctx, span := start(ctx, "stowry.Service.DeletePrefix", AttrPath.String(prefix))
+	result, err := ds.DeletePrefix(ctx, prefix, dryRun)
+	span.SetAttributes(AttrRows.Int(result.Deleted))
+	end(span, err)
+	return result, err
+}
+
+func (s tracedService) Copy(ctx context.Context, srcPath, dstPath string) (stowry.CreateResult, error) {
+	cs, ok := s.next.(stowryhttp.CopyService)
+	if !ok {
+		return stowry.CreateResult{}, fmt.Errorf("copy object: %w", stowry.ErrNotSupported)
+	}
+	ctx, span := start(ctx, "stowry.Service.Copy", AttrPath.String(dstPath))
+	res, err := cs.Copy(ctx, srcPath, dstPath)
+	span.SetAttributes(AttrBytes.Int64(res.FileSizeBytes))
+	end(span, err)
+	return res, err
+}
+
+func (s tracedService) Move(ctx context.Context, srcPath, dstPath string) (stowry.MetaData, error) {
+	ms, ok := s.next.(stowryhttp.MoveService)
+	if !ok {
+		return stowry.MetaData{}, fmt.Errorf("move object: %w", stowry.ErrNotSupported)
+	}
+	ctx, span := start(ctx, "stowry.Service.Move", AttrPath.String(dstPath))
+	m, err := ms.Move(ctx, srcPath, dstPath)
+	end(span, err)
+	return m, err
+}
+
+func (s tracedService) TakeUsageSnapshot(ctx context.Context) (stowry.UsageSnapshot, error) {
+	us, ok := s.next.(stowryhttp.UsageService)
+	if !ok {
+		return stowry.UsageSnapshot{}, fmt.Errorf("take usage snapshot: %w", stowry.ErrNotSupported)
+	}
+	ctx, span := start(ctx, "stowry.Service.TakeUsageSnapshot")
+	snapshot, err := us.TakeUsageSnapshot(ctx)
+	span.SetAttributes(AttrCount.Int(len(snapshot.Prefixes)))
+	end(span, err)
+	return snapshot, err
+}
+
+func (s tracedService) UsageSnapshots(ctx context.Context, from, to time.Time) ([]stowry.UsageSnapshot, error) {
+	us, ok := s.next.(stowryhttp.UsageService)
+	if !ok {
+		return nil, fmt.Errorf("usage snapshots: %w", stowry.ErrNotSupported)
+	}
+	ctx, span := start(ctx, "stowry.Service.UsageSnapshots")
+	snapshots, err := us.UsageSnapshots(ctx, from, to)
+	span.SetAttributes(AttrCount.Int(len(snapshots)))
+	end(span, err)
+	return snapshots, err
+}
+
+func (s tracedService) UsageSnapshotAt(ctx context.Context, at time.Time) (stowry.UsageSnapshot, error) {
+	us, ok := s.next.(stowryhttp.UsageService)
+	if !ok {
+		return stowry.UsageSnapshot{}, fmt.Errorf("usage snapshot at: %w", stowry.ErrNotSupported)
+	}
+	ctx, span := start(ctx, "stowry.Service.UsageSnapshotAt")
+	snapshot, err := us.UsageSnapshotAt(ctx, at)
+	end(span, err)
+	return snapshot, err
+}
+
+func (s tracedService) UsageReport(ctx context.Context, from, to time.Time) (stowry.UsageReport, error) {
+	us, ok := s.next.(stowryhttp.UsageService)
+	if !ok {
+		return stowry.UsageReport{}, fmt.Errorf("usage report: %w", stowry.ErrNotSupported)
+	}
+	ctx, span := start(ctx, "stowry.Service.UsageReport")
+	report, err := us.UsageReport(ctx, from, to)
+	span.SetAttributes(AttrCount.Int(len(report.Prefixes)))
+	end(span, err)
+	return report, err
+}
+
+func (s tracedService) BackendUsage(ctx context.Context) ([]stowry.BackendUsage, error) {
+	bs, ok := s.next.(stowryhttp.BackendUsageService)
+	if !ok {
+		return nil, fmt.Errorf("backend usage: %w", stowry.ErrNotSupported)
+	}
+	ctx, span := start(ctx, "stowry.Service.BackendUsage")
+	backends, err := bs.BackendUsage(ctx)
+	span.SetAttributes(AttrCount.Int(len(backends)))
+	end(span, err)
+	return backends, err
+}
+
+func (s tracedService) Purge(ctx context.Context, path string) (stowry.MetaData, error) {
+	ps, ok := s.next.(stowryhttp.PurgeService)
+	if !ok {
+		return stowry.MetaData{}, fmt.Errorf("purge object: %w", stowry.ErrNotSupported)
+	}
+	ctx, span := start(ctx, "stowry.Service.Purge", AttrPath.String(path))
+	m, err := ps.Purge(ctx, path)
+	end(span, err)
+	return m, err
+}
+
+func (s tracedService) Restore(ctx context.Context, path string) (stowry.MetaData, error) {
+	rs, ok := s.next.(stowryhttp.RestoreService)
+	if !ok {
+		return stowry.MetaData{}, fmt.Errorf("restore object: %w", stowry.ErrNotSupported)
+	}
+	ctx, span := start(ctx, "stowry.Service.Restore", AttrPath.String(path))
+	m, err := rs.Restore(ctx, path)
+	end(span, err)
+	return m, err
+}
+
+func (s tracedService) ListDeleted(ctx context.Context, q stowry.ListQuery) (stowry.ListResult, error) {
+	rs, ok := s.next.(stowryhttp.RestoreService)
+	if !ok {
+		return stowry.ListResult{}, fmt.Errorf("list deleted objects: %w", stowry.ErrNotSupported)
+	}
+	ctx, span := start(ctx, "stowry.Service.ListDeleted", AttrPath.String(q.PathPrefix))
+	res, err := rs.ListDeleted(ctx, q)
+	span.SetAttributes(AttrRows.Int(len(res.Items)))
+	end(span, err)
+	return res, err
+}
+
+func (s tracedService) GetByETag(ctx context.Context, etag string) (stowry.MetaData, io.ReadSeekCloser, error) {
+	ps, ok := s.next.(stowryhttp.PermalinkService)
+	if !ok {
+		return stowry.MetaData{}, nil, fmt.Errorf("get object by etag: %w", stowry.ErrNotSupported)
+	}
+	ctx, span := start(ctx, "stowry.Service.GetByETag")
+	m, rc, err := ps.GetByETag(ctx, etag)
+	if err == nil {
+		span.SetAttributes(AttrPath.String(m.Path), AttrBytes.Int64(m.FileSizeBytes))
+	}
+	end(span, err)
+	return m, rc, err
+}
+
+func (s tracedService) InitiateMultipart(ctx context.Context, obj stowry.CreateObject) (stowry.MultipartUpload, error) {
+	ms, ok := s.next.(stowryhttp.MultipartService)
+	if !ok {
+		return stowry.MultipartUpload{}, fmt.Errorf("initiate multipart: %w", stowry.ErrNotSupported)
+	}
+	ctx, span := start(ctx, "stowry.Service.InitiateMultipart", AttrPath.String(obj.Path))
+	upload, err := ms.InitiateMultipart(ctx, obj)
+	end(span, err)
+	return upload, err
+}
+
+func (s tracedService) UploadPart(ctx context.Context, path string, id uuid.UUID, number int, content io.Reader) (stowry.MultipartPart, error) {
+	ms, ok := s.next.(stowryhttp.MultipartService)
+	if !ok {
+		return stowry.MultipartPart{}, fmt.Errorf("upload part: %w", stowry.ErrNotSupported)
+	}
+	ctx, span := start(ctx, "stowry.Service.UploadPart", AttrPath.String(path))
+	part, err := ms.UploadPart(ctx, path, id, number, content)
+	span.SetAttributes(AttrBytes.Int64(part.Size))
+	end(span, err)
+	return part, err
+}
+
+func (s tracedService) CompleteMultipart(ctx context.Context, path string, id uuid.UUID, parts []stowry.CompletedPart, maxSize int64) (stowry.CreateResult, error) {
+	ms, ok := s.next.(stowryhttp.MultipartService)
+	if !ok {
+		return stowry.CreateResult{}, fmt.Errorf("complete multipart: %w", stowry.ErrNotSupported)
+	}
+	ctx, span := start(ctx, "stowry.Service.CompleteMultipart", AttrPath.String(path), AttrCount.Int(len(parts)))
+	res, err := ms.CompleteMultipart(ctx, path, id, parts, maxSize)
+	span.SetAttributes(AttrBytes.Int64(res.FileSizeBytes))
+	end(span, err)
+	return res, err
+}
+
+func (s tracedService) AbortMultipart(ctx context.Context, path string, id uuid.UUID) error {
+	ms, ok := s.next.(stowryhttp.MultipartService)
+	if !ok {
+		return fmt.Errorf("abort multipart: %w", stowry.ErrNotSupported)
+	}
+	ctx, span := start(ctx, "stowry.Service.AbortMultipart", AttrPath.String(path))
+	err := ms.AbortMultipart(ctx, path, id)
+	end(span, err)
+	return err
+}
+
+func (s tracedService) Get(ctx context.Context, path string) (stowry.MetaData, io.ReadSeekCloser, error) {
+	ctx, span := start(ctx, "stowry.Service.Get", AttrPath.String(path))
+	m, rc, err := s.next.Get(ctx, path)
+	span.SetAttributes(AttrBytes.Int64(m.FileSizeBytes))
+	end(span, err)
+	return m, rc, err
+}
+
+func (s tracedService) Info(ctx context.Context, path string) (stowry.MetaData, error) {
+	ctx, span := start(ctx, "stowry.Service.Info", AttrPath.String(path))
+	m, err := s.next.Info(ctx, path)
+	end(span, err)
+	return m, err
+}
+
+func (s tracedService) Create(ctx context.Context, obj stowry.CreateObject, content io.Reader) (stowry.CreateResult, error) {
+	ctx, span := start(ctx, "stowry.Service.Create", AttrPath.String(obj.Path))
+	res, err := s.next.Create(ctx, obj, content)
+	span.SetAttributes(AttrBytes.Int64(res.FileSizeBytes))
+	end(span, err)
+	return res, err
+}
+
+func (s tracedService) Delete(ctx context.Context, path string) error {
+	ctx, span := start(ctx, "stowry.Service.Delete", AttrPath.String(path))
+	err := s.next.Delete(ctx, path)
+	end(span, err)
+	return err
+}
+
+func (s tracedService) List(ctx context.Context, q stowry.ListQuery) (stowry.ListResult, error) {
+	ctx, span := start(ctx, "stowry.Service.List", AttrPath.String(q.PathPrefix))
+	res, err := s.next.List(ctx, q)
+	span.SetAttributes(AttrRows.Int(len(res.Items)))
+	end(span, err)
+	return res, err
+}
+
+type tracedDeltaService struct {
+	tracedService
+	delta stowryhttp.DeltaService
+}
+
+func (s tracedDeltaService) Signature(ctx context.Context, path string, blockSize int) (stowry.BlockSignature, error) {
+	ctx, span := start(ctx, "stowry.Service.Signature", AttrPath.String(path))
+	sig, err := s.delta.Signature(ctx, path, blockSize)
+	span.SetAttributes(AttrCount.Int(len(sig.Blocks)))
+	end(span, err)
+	return sig, err
+}
+
+func (s tracedDeltaService) ApplyDelta(ctx context.Context, path string, m stowry.DeltaManifest, data io.Reader) (stowry.MetaData, error) {
+	ctx, span := start(ctx, "stowry.Service.ApplyDelta", AttrPath.String(path), AttrBytes.Int64(m.DataSize()))
+	meta, err := s.delta.ApplyDelta(ctx, path, m, data)
+	end(span, err)
+	return meta, err
+}
+
+type tracedStageService struct {
+	tracedService
+	tracedStage
+}
+
+type tracedDeltaStageService struct {
+	tracedDeltaService
+	tracedStage
+}
+
+// tracedStage adds the stowryhttp.StageService methods to a traced service.
+type tracedStage struct {
+	stage stowryhttp.StageService
+}
+
+func (s tracedStage) CreateStage(ctx context.Context) (stowry.Stage, error) {
+	ctx, span := start(ctx, "stowry.Service.CreateStage")
+	stage, err := s.stage.CreateStage(ctx)
+	end(span, err)
+	return stage, err
+}
+
+func (s tracedStage) StageObject(ctx context.Context, id uuid.UUID, obj stowry.CreateObject, content io.Reader) (stowry.ObjectEntry, error) {
+	ctx, span := start(ctx, "stowry.Service.StageObject", AttrPath.String(obj.Path))
+	entry, err := s.stage.StageObject(ctx, id, obj, content)
+	span.SetAttributes(AttrBytes.Int64(entry.Size))
+	end(span, err)
+	return entry, err
+}
+
+func (s tracedStage) CommitStage(ctx context.Context, id uuid.UUID) ([]stowry.MetaData, error) {
+	ctx, span := start(ctx, "stowry.Service.CommitStage")
+	committed, err := s.stage.CommitStage(ctx, id)
+	span.SetAttributes(AttrCount.Int(len(committed)))
+	end(span, err)
+	return committed, err
+}
+
+func (s tracedStage) DiscardStage(ctx context.Context, id uuid.UUID) error {
+	ctx, span := start(ctx, "stowry.Service.DiscardStage")
+	err := s.stage.DiscardStage(ctx, id)
+	end(span, err)
+	return err
+}
+
+// WrapRepo returns repo with a span around every call. The result implements
+// stowry.StageRepo and stowry.IntentRepo when repo does. It always implements stowry.Pinger, which
+// returns nil when repo does not, and stowry.ChangeFeed,
+// stowry.ChangeHistory, stowry.CleanupDeferrer, stowry.MultipartRepo,
+// stowry.PurgeRepo, stowry.RenameRepo, stowry.UsageRepo,
+// stowry.BackendUsageRepo, stowry.InstanceRepo and stowry.AccessKeyRepo,
+// returning stowry.ErrNotSupported when repo does not.
+func WrapRepo(repo stowry.MetaDataRepo) stowry.MetaDataRepo {
+	r := tracedRepo{next: repo}
+	sr, isStage := repo.(stowry.StageRepo)
+	ir, isIntent := repo.(stowry.IntentRepo)
+	switch {
+	case isStage && isIntent:
+		return tracedStageIntentRepo{tracedStageRepo{tracedRepo: r, stage: sr}, tracedIntents{intents: ir}}
+	case isStage:
+		return tracedStageRepo{tracedRepo: r, stage: sr}
+	case isIntent:
+		return tracedIntentRepo{tracedRepo: r, tracedIntents: tracedIntents{intents: ir}}
+	}
+	return r
+}
+
+type tracedRepo struct {
+	next stowry.MetaDataRepo
+}
+
+func (r tracedRepo) Get(ctx context.Context, path string) (stowry.MetaData, error) {
+	ctx, span := start(ctx, "stowry.MetaDataRepo.Get", AttrPath.String(path))
+	m, err := r.next.Get(ctx, path)
+	end(span, err)
+	return m, err
+}
+
+func (r tracedRepo) GetDeleted(ctx context.Context, path string) (stowry.MetaData, error) {
+	ctx, span := start(ctx, "stowry.MetaDataRepo.GetDeleted", AttrPath.String(path))
+	m, err := r.next.GetDeleted(ctx, path)
+	end(span, err)
+	return m, err
+}
+
+func (r tracedRepo) Upsert(ctx context.Context, entry stowry.ObjectEntry) (stowry.MetaData, *stowry.MetaData, error) {
+	ctx, span := start(ctx, "stowry.MetaDataRepo.Upsert", AttrPath.String(entry.Path))
+	m, previous, err := r.next.Upsert(ctx, entry)
+	end(span, err)
+	return m, previous, err
+}
+
+func (r tracedRepo) GetByETag(ctx context.Context, etag string) (stowry.MetaData, error) {
+	ctx, span := start(ctx, "stowry.MetaDataRepo.GetByETag")
+	m, err := r.next.GetByETag(ctx, etag)
+	end(span, err)
+	return m, err
+}
+
+func (r tracedRepo) GetMany(ctx context.Context, paths []string) ([]stowry.MetaData, error) {
+	ctx, span := start(ctx, "stowry.MetaDataRepo.GetMany", AttrCount.Int(len(paths)))
+	items, err := r.next.GetMany(ctx, paths)
+	span.SetAttributes(AttrRows.Int(len(items)))
+	end(span, err)
+	return items, err
+}
+
+func (r tracedRepo) UpsertBatch(ctx context.Context, entries []stowry.ObjectEntry) ([]stowry.MetaData, error) {
+	ctx, span := start(ctx, "stowry.MetaDataRepo.UpsertBatch", AttrRows.Int(len(entries)))
+	m, err := r.next.UpsertBatch(ctx, entries)
+	end(span, err)
+	return m, err
+}
+
+func (r tracedRepo) Delete(ctx context.Context, path string) error {
+	ctx, span := start(ctx, "stowry.MetaDataRepo.Delete", AttrPath.String(path))
+	err := r.next.Delete(ctx, path)
+	end(span, err)
+	return err
+}
+
+func (r tracedRepo) Restore(ctx context.Context, path string) (stowry.MetaData, error) {
+	ctx, span := start(ctx, "stowry.MetaDataRepo.Restore", AttrPath.String(path))
+	m, err := r.next.Restore(ctx, path)
+	end(span, err)
+	return m, err
+}
+
+func (r tracedRepo) Quarantine(ctx context.Context, path, etag string) error {
+	ctx, span := start(ctx, "stowry.MetaDataRepo.Quarantine", AttrPath.String(path))
+	err := r.next.Quarantine(ctx, path, etag)
+	end(span, err)
+	return err
+}
+
+func (r tracedRepo) List(ctx context.Context, q stowry.ListQuery) (stowry.ListResult, error) {
+	ctx, span := start(ctx, "stowry.MetaDataRepo.List", AttrPath.String(q.PathPrefix))
+	res, err := r.next.List(ctx, q)
+	span.SetAttributes(AttrRows.Int(len(res.Items)))
+	end(span, err)
+	return res, err
+}
+
+func (r tracedRepo) ListPendingCleanup(ctx context.Context, q stowry.ListQuery) (stowry.ListResult, error) {
+	ctx, span := start(ctx, "stowry.MetaDataRepo.ListPendingCleanup", AttrPath.String(q.PathPrefix))
+	res, err := r.next.ListPendingCleanup(ctx, q)
+	span.SetAttributes(AttrRows.Int(len(res.Items)))
+	end(span, err)
+	return res, err
+}
+
+func (r tracedRepo) MarkCleanedUp(ctx context.Context, id uuid.UUID) error {
+	ctx, span := start(ctx, "stowry.MetaDataRepo.MarkCleanedUp")
+	err := r.next.MarkCleanedUp(ctx, id)
+	end(span, err)
+	return err
+}
+
+func (r tracedRepo) DeferCleanup(ctx context.Context, id uuid.UUID, until time.Time) error {
+	deferrer, ok := r.next.(stowry.CleanupDeferrer)
+	if !ok {
+		return fmt.Errorf("defer cleanup: %w", stowry.ErrNotSupported)
+	}
+	ctx, span := start(ctx, "stowry.MetaDataRepo.DeferCleanup")
+	err := deferrer.DeferCleanup(ctx, id, until)
+	end(span, err)
+	return err
+}
+
+func (r tracedRepo) DeleteHard(ctx context.Context, path string) (stowry.MetaData, error) {
+	pr, ok := r.next.(stowry.PurgeRepo)
+	if !ok {
+		return stowry.MetaData{}, fmt.Errorf("delete hard: %w", stowry.ErrNotSupported)
+	}
+	ctx, span := start(ctx, "stowry.MetaDataRepo.DeleteHard", AttrPath.String(path))
+	m, err := pr.DeleteHard(ctx, path)
+	end(span, err)
+	return m, err
+}
+
+func (r tracedRepo) RecordAccess(ctx context.Context, records []stowry.AccessRecord) error {
+	ctx, span := start(ctx, "stowry.MetaDataRepo.RecordAccess", AttrRows.Int(len(records)))
+	err := r.next.RecordAccess(ctx, records)
+	end(span, err)
+	return err
+}
+
+func (r tracedRepo) ExistingPaths(ctx context.Context, paths []string) ([]string, error) {
+	ctx, span := start(ctx, "stowry.MetaDataRepo.ExistingPaths", AttrCount.Int(len(paths)))
+	found, err := r.next.ExistingPaths(ctx, paths)
+	span.SetAttributes(AttrRows.Int(len(found)))
+	end(span, err)
+	return found, err
+}
+
+func (r tracedRepo) CountPrefix(ctx context.Context, prefix string) (int64, error) {
+	ctx, span := start(ctx, "stowry.MetaDataRepo.CountPrefix", AttrPath.String(prefix))
+	count, err := r.next.CountPrefix(ctx, prefix)
+	span.SetAttributes(AttrCount.Int64(count))
+	end(span, err)
+	return count, err
+}
+
+func (r tracedRepo) Changes(ctx context.Context, q stowry.ChangeQuery) (stowry.ChangeBatch, error) {
+	feed, ok := r.next.(stowry.ChangeFeed)
+	if !ok {
+		return stowry.ChangeBatch{}, fmt.Errorf("changes: %w", stowry.ErrNotSupported)
+	}
+	ctx, span := start(ctx, "stowry.MetaDataRepo.Changes", AttrPath.String(q.PathPrefix))
+	batch, err := feed.Changes(ctx, q)
+	span.SetAttributes(AttrRows.Int(len(batch.Changes)))
+	end(span, err)
+	return batch, err
+}
+
+func (r tracedRepo) PruneChanges(ctx context.Context, before time.Time) (int64, error) {
+	feed, ok := r.next.(stowry.ChangeFeed)
+	if !ok {
+		return 0, fmt.Errorf("prune changes: %w", stowry.ErrNotSupported)
+	}
+	ctx, span := start(ctx, "stowry.MetaDataRepo.PruneChanges")
+	n, err := feed.PruneChanges(ctx, before)
+	span.SetAttributes(AttrRows.Int64(n))
+	end(span, err)
+	return n, err
+}
+
+func (r tracedRepo) History(ctx context.Context, q stowry.HistoryQuery) (stowry.History, error) {
+	hist, ok := r.next.(stowry.ChangeHistory)
+	if !ok {
+		return stowry.History{}, fmt.Errorf("history: %w", stowry.ErrNotSupported)
+	}
+	ctx, span := start(ctx, "stowry.MetaDataRepo.History", AttrPath.String(q.Path))
+	h, err := hist.History(ctx, q)
+	span.SetAttributes(AttrRows.Int(len(h.Changes)))
+	end(span, err)
+	return h, err
+}
+
+func (r tracedRepo) CreateMultipartUpload(ctx context.Context, upload stowry.MultipartUpload) (stowry.MultipartUpload, error) {
+	mr, ok := r.next.(stowry.MultipartRepo)
+	if !ok {
+		return stowry.MultipartUpload{}, fmt.Errorf("create multipart upload: %w", stowry.ErrNotSupported)
+	}
+	ctx, span := start(ctx, "stowry.MetaDataRepo.CreateMultipartUpload", AttrPath.String(upload.Path))
+	upload, err := mr.CreateMultipartUpload(ctx, upload)
+	end(span, err)
+	return upload, err
+}
+
+func (r tracedRepo) GetMultipartUpload(ctx context.Context, id uuid.UUID) (stowry.MultipartUpload, []stowry.MultipartPart, error) {
+	mr, ok := r.next.(stowry.MultipartRepo)
+	if !ok {
+		return stowry.MultipartUpload{}, nil, fmt.Errorf("get multipart upload: %w", stowry.ErrNotSupported)
+	}
+	ctx, span := start(ctx, "stowry.MetaDataRepo.GetMultipartUpload")
+	upload, parts, err := mr.GetMultipartUpload(ctx, id)
+	span.SetAttributes(AttrPath.String(upload.Path), AttrRows.Int(len(parts)))
+	end(span, err)
+	return upload, parts, err
+}
+
+func (r tracedRepo) AddMultipartPart(ctx context.Context, id uuid.UUID, part stowry.MultipartPart) error {
+	mr, ok := r.next.(stowry.MultipartRepo)
+	if !ok {
+		return fmt.Errorf("add multipart part: %w", stowry.ErrNotSupported)
+	}
+	ctx, span := start(ctx, "stowry.MetaDataRepo.AddMultipartPart")
+	err := mr.AddMultipartPart(ctx, id, part)
+	end(span, err)
+	return err
+}
+
+func (r tracedRepo) DeleteMultipartUpload(ctx context.Context, id uuid.UUID) error {
+	mr, ok := r.next.(stowry.MultipartRepo)
+	if !ok {
+		return fmt.Errorf("delete multipart upload: %w", stowry.ErrNotSupported)
+	}
+	ctx, span := start(ctx, "stowry.MetaDataRepo.DeleteMultipartUpload")
+	err := mr.DeleteMultipartUpload(ctx, id)
+	end(span, err)
+	return err
+}
+
+func (r tracedRepo) StaleMultipartUploads(ctx context.Context, before time.Time, limit int) ([]stowry.MultipartUpload, error) {
+	mr, ok := r.next.(stowry.MultipartRepo)
+	if !ok {
+		return nil, fmt.Errorf("stale multipart uploads: %w", stowry.ErrNotSupported)
+	}
+	ctx, span := start(ctx, "stowry.MetaDataRepo.StaleMultipartUploads")
+	uploads, err := mr.StaleMultipartUploads(ctx, before, limit)
+	span.SetAttributes(AttrRows.Int(len(uploads)))
+	end(span, err)
+	return uploads, err
+}
+
+func (r tracedRepo) Rename(ctx context.Context, oldPath, newPath, backend string) (stowry.MetaData, error) {
+	rr, ok := r.next.(stowry.RenameRepo)
+	if !ok {
+		return stowry.MetaData{}, fmt.Errorf("rename: %w", stowry.ErrNotSupported)
+	}
+	ctx, span := start(ctx, "stowry.MetaDataRepo.Rename", AttrPath.String(oldPath))
+	m, err := rr.Rename(ctx, oldPath, newPath, backend)
+	end(span, err)
+	return m, err
+}
+
+func (r tracedRepo) TakeUsageSnapshot(ctx context.Context) (stowry.UsageSnapshot, error) {
+	ur, ok := r.next.(stowry.UsageRepo)
+	if !ok {
+		return stowry.UsageSnapshot{}, fmt.Errorf("take usage snapshot: %w", stowry.ErrNotSupported)
+	}
+	ctx, span := start(ctx, "stowry.MetaDataRepo.TakeUsageSnapshot")
+	snapshot, err := ur.TakeUsageSnapshot(ctx)
+	span.SetAttributes(AttrRows.Int(len(snapshot.Prefixes)))
+	end(span, err)
+	return snapshot, err
+}
+
+func (r tracedRepo) ListUsageSnapshots(ctx context.Context, from, to time.Time) ([]stowry.UsageSnapshot, error) {
+	ur, ok := r.next.(stowry.UsageRepo)
+	if !ok {
+		return nil, fmt.Errorf("list usage snapshots: %w", stowry.ErrNotSupported)
+	}
+	ctx, span := start(ctx, "stowry.MetaDataRepo.ListUsageSnapshots")
+	snapshots, err := ur.ListUsageSnapshots(ctx, from, to)
+	span.SetAttributes(AttrRows.Int(len(snapshots)))
+	end(span, err)
+	return snapshots, err
+}
+
+func (r tracedRepo) UsageSnapshotAt(ctx context.Context, at time.Time) (stowry.UsageSnapshot, error) {
+	ur, ok := r.next.(stowry.UsageRepo)
+	if !ok {
+		return stowry.UsageSnapshot{}, fmt.Errorf("usage snapshot at: %w", stowry.ErrNotSupported)
+	}
+	ctx, span := start(ctx, "stowry.MetaDataRepo.UsageSnapshotAt")
+	snapshot, err := ur.UsageSnapshotAt(ctx, at)
+	end(span, err)
+	return snapshot, err
+}
+
+func (r tracedRepo) BackendUsage(ctx context.Context) ([]stowry.BackendUsage, error) {
+	br, ok := r.next.(stowry.BackendUsageRepo)
+	if !ok {
+		return nil, fmt.Errorf("backend usage: %w", stowry.ErrNotSupported)
+	}
+	ctx, span := start(ctx, "stowry.MetaDataRepo.BackendUsage")
+	backends, err := br.BackendUsage(ctx)
+	span.SetAttributes(AttrRows.Int(len(backends)))
+	end(span, err)
+	return backends, err
+}
+
+func (r tracedRepo) InstanceID(ctx context.Context) (string, error) {
+	ir, ok := r.next.(stowry.InstanceRepo)
+	if !ok {
+		return "", fmt.Errorf("instance id: %w", stowry.ErrNotSupported)
+	}
+	ctx, span := start(ctx, "stowry.MetaDataRepo.InstanceID")
+	id, err := ir.InstanceID(ctx)
+	end(span, err)
+	return id, err
+}
+
+func (r tracedRepo) SetInstanceID(ctx context.Context, id string) error {
+	ir, ok := r.next.(stowry.InstanceRepo)
+	if !ok {
+		return fmt.Errorf("set instance id: %w", stowry.ErrNotSupported)
+	}
+	ctx, span := start(ctx, "stowry.MetaDataRepo.SetInstanceID")
+	err := ir.SetInstanceID(ctx, id)
+	end(span, err)
+	return err
+}
+
+func (r tracedRepo) RecordAccessKeyUse(ctx context.Context, uses []stowry.AccessKeyUse) error {
+	kr, ok := r.next.(stowry.AccessKeyRepo)
+	if !ok {
+		return fmt.Errorf("record access key use: %w", stowry.ErrNotSupported)
+	}
+	ctx, span := start(ctx, "stowry.MetaDataRepo.RecordAccessKeyUse", AttrRows.Int(len(uses)))
+	err := kr.RecordAccessKeyUse(ctx, uses)
+	end(span, err)
+	return err
+}
+
+func (r tracedRepo) ListAccessKeys(ctx context.Context) ([]stowry.AccessKeyInfo, error) {
+	kr, ok := r.next.(stowry.AccessKeyRepo)
+	if !ok {
+		return nil, fmt.Errorf("list access keys: %w", stowry.ErrNotSupported)
+	}
+	ctx, span := start(ctx, "stowry.MetaDataRepo.ListAccessKeys")
+	keys, err := kr.ListAccessKeys(ctx)
+	span.SetAttributes(AttrRows.Int(len(keys)))
+	end(span, err)
+	return keys, err
+}
+
+func (r tracedRepo) RotateAccessKey(ctx context.Context, oldKey, newKey string, at time.Time) error {
+	kr, ok := r.next.(stowry.AccessKeyRepo)
+	if !ok {
+		return fmt.Errorf("rotate access key: %w", stowry.ErrNotSupported)
+	}
+	ctx, span := start(ctx, "stowry.MetaDataRepo.RotateAccessKey")
+	err := kr.RotateAccessKey(ctx, oldKey, newKey, at)
+	end(span, err)
+	return err
+}
+
+func (r tracedRepo) RevokeAccessKey(ctx context.Context, accessKey string, at time.Time) error {
+	kr, ok := r.next.(stowry.AccessKeyRepo)
+	if !ok {
+		return fmt.Errorf("revoke access key: %w", stowry.ErrNotSupported)
+	}
+	ctx, span := start(ctx, "stowry.MetaDataRepo.RevokeAccessKey")
+	err := kr.RevokeAccessKey(ctx, accessKey, at)
+	end(span, err)
+	return err
+}
+
+func (r tracedRepo) Ping(ctx context.Context) error {
+	pinger, ok := r.next.(stowry.Pinger)
+	if !ok {
+		return nil
+	}
+	ctx, span := start(ctx, "stowry.MetaDataRepo.Ping")
+	err := pinger.Ping(ctx)
+	end(span, err)
+	return err
+}
+
+type tracedStageRepo struct {
+	tracedRepo
+	stage stowry.StageRepo
+}
+
+func (r tracedStageRepo) CreateStage(ctx context.Context) (stowry.Stage, error) {
+	ctx, span := start(ctx, "stowry.MetaDataRepo.CreateStage")
+	stage, err := r.stage.CreateStage(ctx)
+	end(span, err)
+	return stage, err
+}
+
+func (r tracedStageRepo) AddStagedObject(ctx context.Context, id uuid.UUID, entry stowry.ObjectEntry) error {
+	ctx, span := start(ctx, "stowry.MetaDataRepo.AddStagedObject", AttrPath.String(entry.Path))
+	err := r.stage.AddStagedObject(ctx, id, entry)
+	end(span, err)
+	return err
+}
+
+func (r tracedStageRepo) StagedObjects(ctx context.Context, id uuid.UUID) ([]stowry.ObjectEntry, error) {
+	ctx, span := start(ctx, "stowry.MetaDataRepo.StagedObjects")
+	entries, err := r.stage.StagedObjects(ctx, id)
+	span.SetAttributes(AttrRows.Int(len(entries)))
+	end(span, err)
+	return entries, err
+}
+
+func (r tracedStageRepo) CommitStage(ctx context.Context, id uuid.UUID) ([]stowry.MetaData, error) {
+	ctx, span := start(ctx, "stowry.MetaDataRepo.CommitStage")
+	committed, err := r.stage.CommitStage(ctx, id)
+	span.SetAttributes(AttrRows.Int(len(committed)))
+	end(span, err)
+	return committed, err
+}
+
+func (r tracedStageRepo) DeleteStage(ctx context.Context, id uuid.UUID) error {
+	ctx, span := start(ctx, "stowry.MetaDataRepo.DeleteStage")
+	err := r.stage.DeleteStage(ctx, id)
+	end(span, err)
+	return err
+}
+
+type tracedIntentRepo struct {
+	tracedRepo
+	tracedIntents
+}
+
+type tracedStageIntentRepo struct {
+	tracedStageRepo
+	tracedIntents
+}
+
+// tracedIntents forwards stowry.IntentRepo. The service only brackets writes
+// with intents when the repository has them, so it is never invented.
+type tracedIntents struct {
+	intents stowry.IntentRepo
+}
+
+func (r tracedIntents) RecordWriteIntent(ctx context.Context, intent stowry.WriteIntent) (stowry.WriteIntent, error) {
+	ctx, span := start(ctx, "stowry.MetaDataRepo.RecordWriteIntent", AttrPath.String(intent.Path))
+	recorded, err := r.intents.RecordWriteIntent(ctx, intent)
+	end(span, err)
+	return recorded, err
+}
+
+func (r tracedIntents) ClearWriteIntent(ctx context.Context, id uuid.UUID) error {
+	ctx, span := start(ctx, "stowry.MetaDataRepo.ClearWriteIntent")
+	err := r.intents.ClearWriteIntent(ctx, id)
+	end(span, err)
+	return err
+}
+
+func (r tracedIntents) WriteIntents(ctx context.Context, before time.Time) ([]stowry.WriteIntent, error) {
+	ctx, span := start(ctx, "stowry.MetaDataRepo.WriteIntents")
+	intents, err := r.intents.WriteIntents(ctx, before)
+	span.SetAttributes(AttrRows.Int(len(intents)))
+	end(span, err)
+	return intents, err
+}
+
+// WrapStorage returns storage with a span around every call. The optional
+// SpaceChecker, PathSpaceChecker, StorageWalker, StorageMover,
+// StorageStater, StorageCopier and StorageLocator extensions are forwarded:
+// an unsupported CheckSpace succeeds, CheckSpaceFor falls back to
+// CheckSpace, unsupported Walk and Move return an error wrapping
+// stowry.ErrInvalidInput, Stat falls back to stowry.StatFile, Copy reads and
+// writes the file, and without backends Backend reports the default one and
+// BackendStorage finds none.
+func WrapStorage(storage stowry.FileStorage) stowry.FileStorage {
+	return tracedStorage{next: storage}
+}
+
+type tracedStorage struct {
+	next stowry.FileStorage
+}
+
+func (s tracedStorage) Get(ctx context.Context, path string) (io.ReadSeekCloser, error) {
+	ctx, span := start(ctx, "stowry.FileStorage.Get", AttrPath.String(path))
+	rc, err := s.next.Get(ctx, path)
+	end(span, err)
+	return rc, err
+}
+
+func (s tracedStorage) Write(ctx context.Context, path string, content io.Reader) (stowry.SaveResult, error) {
+	ctx, span := start(ctx, "stowry.FileStorage.Write", AttrPath.String(path))
+	res, err := s.next.Write(ctx, path, content)
+	span.SetAttributes(AttrBytes.Int64(res.BytesWritten))
+	end(span, err)
+	return res, err
+}
+
+func (s tracedStorage) Delete(ctx context.Context, path string) error {
+	ctx, span := start(ctx, "stowry.FileStorage.Delete", AttrPath.String(path))
+	err := s.next.Delete(ctx, path)
+	end(span, err)
+	return err
+}
+
+func (s tracedStorage) List(ctx context.Context) ([]stowry.ObjectEntry, error) {
+	ctx, span := start(ctx, "stowry.FileStorage.List")
+	entries, err := s.next.List(ctx)
+	span.SetAttributes(AttrCount.Int(len(entries)))
+	end(span, err)
+	return entries, err
+}
+
+func (s tracedStorage) CheckSpace(ctx context.Context, size int64) error {
+	checker, ok := s.next.(stowry.SpaceChecker)
+	if !ok {
+		return nil
+	}
+	ctx, span := start(ctx, "stowry.FileStorage.CheckSpace", AttrBytes.Int64(size))
+	err := checker.CheckSpace(ctx, size)
+	end(span, err)
+	return err
+}
+
+func (s tracedStorage) Walk(ctx context.Context, fn func(stowry.StoredFile) error) error {
+	walker, ok := s.next.(stowry.StorageWalker)
+	if !ok {
+		return fmt.Errorf("walk: storage does not support walking: %w", stowry.ErrInvalidInput)
+	}
+	ctx, span := start(ctx, "stowry.FileStorage.Walk")
+	var count int
+	err := walker.Walk(ctx, func(f stowry.StoredFile) error {
+		count++
+		return fn(f)
+	})
+	span.SetAttributes(AttrCount.Int(count))
+	end(span, err)
+	return err
+}
+
+func (s tracedStorage) Stat(ctx context.Context, path string) (stowry.StoredFile, error) {
+	ctx, span := start(ctx, "stowry.FileStorage.Stat", AttrPath.String(path))
+	info, err := stowry.StatFile(ctx, s.next, path)
+	end(span, err)
+	return info, err
+}
+
+func (s tracedStorage) Move(ctx context.Context, from, to string) error {
+	mover, ok := s.next.(stowry.StorageMover)
+	if !ok {
+		return fmt.Errorf("move %s: storage does not support moving: %w", from, stowry.ErrInvalidInput)
+	}
+	ctx, span := start(ctx, "stowry.FileStorage.Move", AttrPath.String(from))
+	err := mover.Move(ctx, from, to)
+	end(span, err)
+	return err
+}
+
+func (s tracedStorage) CheckSpaceFor(ctx context.Context, path string, size int64) error {
+	checker, ok := s.next.(stowry.PathSpaceChecker)
+	if !ok {
+		return s.CheckSpace(ctx, size)
+	}
+	ctx, span := start(ctx, "stowry.FileStorage.CheckSpaceFor", AttrPath.String(path), AttrBytes.Int64(size))
+	err := checker.CheckSpaceFor(ctx, path, size)
+	end(span, err)
+	return err
+}
+
+func (s tracedStorage) Copy(ctx context.Context, from, to string) (stowry.SaveResult, error) {
+	ctx, span := start(ctx, "stowry.FileStorage.Copy", AttrPath.String(from))
+	var res stowry.SaveResult
+	var err error
+	if copier, ok := s.next.(stowry.StorageCopier); ok {
+		res, err = copier.Copy(ctx, from, to)
+	} else {
+		res, err = copyFile(ctx, s.next, from, to)
+	}
+	span.SetAttributes(AttrBytes.Int64(res.BytesWritten))
+	end(span, err)
+	return res, err
+}
+
+// copyFile copies from to to by reading and writing the file, for storage
+// without a StorageCopier.
+func copyFile(ctx context.Context, storage stowry.FileStorage, from, to string) (stowry.SaveResult, error) {
+	content, err := storage.Get(ctx, from)
+	if err != nil {
+		return stowry.SaveResult{}, fmt.Errorf("copy %s: %w", from, err)
+	}
+	defer func() { _ = content.Close() }()
+	return storage.Write(ctx, to, content)
+}
+
+func (s tracedStorage) Backend(path string) string {
+	if locator, ok := s.next.(stowry.StorageLocator); ok {
+		return locator.Backend(path)
+	}
+	return ""
+}
+
+func (s tracedStorage) BackendStorage(name string) (stowry.FileStorage, bool) {
+	locator, ok := s.next.(stowry.StorageLocator)
+	if !ok {
+		return nil, false
+	}
+	backend, ok := locator.BackendStorage(name)
+	if !ok {
+		return nil, false
+	}
+	return WrapStorage(backend), true
+}
@@ -7,6 +7,8 @@ import (
 	"time"
 
 	"github.com/google/uuid"
+
+	"github.com/sagarc03/stowry/progress"
 )
 
 type MetaData struct {
@@ -15,21 +17,82 @@ type MetaData struct {
 	ContentType   string    `json:"content_type"`
 	Etag          string    `json:"etag"`
 	FileSizeBytes int64     `json:"file_size_bytes"`
-	CreatedAt     time.Time `json:"created_at"`
-	UpdatedAt     time.Time `json:"updated_at"`
+	// ContentEncoding is the Content-Encoding the object was uploaded and is
+	// stored with; empty for unencoded objects. Etag and FileSizeBytes
+	// describe the stored, encoded bytes, and DecodedSizeBytes the content
+	// after decoding.
+	ContentEncoding  string `json:"content_encoding,omitempty"`
+	DecodedSizeBytes *int64 `json:"decoded_size_bytes,omitempty"`
+	// Filename is the name the client uploaded the object under, kept apart
+	// from Path; empty when the upload did not give one.
+	Filename string `json:"filename,omitempty"`
+	// UserMetadata is the key/value metadata the client attached to the
+	// object, with lowercase keys; nil when it gave none.
+	UserMetadata map[string]string `json:"user_metadata,omitempty"`
+	// CacheControl is the Cache-Control the object was uploaded with, sent
+	// on GET and HEAD ahead of any configured cache rule; empty when the
+	// upload did not give one.
+	CacheControl string `json:"cache_control,omitempty"`
+	// StorageBackend names the storage backend the object was written to,
+	// the prefix of its storage route; empty for the default backend and
+	// for objects recorded before backends were tracked. The HTTP handler
+	// only reports it when asked to.
+	StorageBackend string     `json:"storage_backend,omitempty"`
+	CreatedAt      time.Time  `json:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+	DownloadCount  int64      `json:"download_count,omitempty"`
+	LastAccessedAt *time.Time `json:"last_accessed_at,omitempty"`
+	// DeletedAt is set only on soft-deleted entries returned by
+	// MetaDataRepo.GetDeleted.
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
+	// QuarantinedAt is set on soft-deleted entries that were quarantined
+	// because their content no longer matched Etag.
+	QuarantinedAt *time.Time `json:"quarantined_at,omitempty"`
+	// CleanupRetryAt is set on entries returned by
+	// MetaDataRepo.ListPendingCleanup whose cleanup failed and was deferred
+	// until then; see CleanupDeferrer.
+	CleanupRetryAt *time.Time `json:"cleanup_retry_at,omitempty"`
+	// Permalink is the URL path serving this content, set by the HTTP
+	// handler in list and stat responses when permalinks are served.
+	Permalink string `json:"permalink,omitempty"`
 }
 
 type ObjectEntry struct {
-	Path        string
-	Size        int64
-	ETag        string
-	ContentType string
+	// ID is a client-supplied object ID; uuid.Nil lets the repository
+	// generate one. Only Upsert reads it: a live object at Path must already
+	// have this ID, a soft-deleted one takes it, and an ID held by another
+	// path fails with ErrIDConflict.
+	ID              uuid.UUID
+	Path            string
+	Size            int64
+	ETag            string
+	ContentType     string
+	ContentEncoding string            // Empty for unencoded objects
+	DecodedSize     *int64            // Decoded length of an encoded object
+	Filename        string            // Original client filename; empty when not given
+	UserMetadata    map[string]string // Client key/value metadata; nil when not given
+	CacheControl    string            // Client Cache-Control; empty when not given
+	StorageBackend  string            // Backend holding the file, see MetaData.StorageBackend
+}
+
+// AccessRecord is the number of downloads of one object since the last flush.
+type AccessRecord struct {
+	Path           string
+	Count          int64
+	LastAccessedAt time.Time
 }
 
 type ListQuery struct {
+	// PathPrefix selects the paths that start with it, byte for byte as
+	// strings.HasPrefix does: no wildcards and no case folding. Backends
+	// matching it in SQL build the predicate with EscapeLikePrefix or
+	// PrefixUpperBound from database/internal.
 	PathPrefix string
 	Limit      int
 	Cursor     string
+	// DeletedBefore, when not zero, limits ListPendingCleanup to items
+	// deleted before it. Other listings ignore it.
+	DeletedBefore time.Time
 }
 
 type ListResult struct {
@@ -37,6 +100,119 @@ type ListResult struct {
 	NextCursor string     `json:"next_cursor,omitempty"`
 }
 
+// PopulateOptions controls a PopulateWithOptions run.
+type PopulateOptions struct {
+	// Progress, if set, receives an event as files are recorded. The total is
+	// the number of files found in storage.
+	Progress progress.Func
+}
+
+// TombstoneOptions controls a TombstoneWithReport run.
+type TombstoneOptions struct {
+	Query  ListQuery // Path prefix filter, page size and DeletedBefore cutoff; the cursor is managed internally
+	DryRun bool      // Report what would be cleaned up without deleting anything
+	// VerifyHash also compares the SHA-256 of each file with the ETag of the
+	// deleted object before removing it, which reads the whole file.
+	VerifyHash bool
+	// Force marks items cleaned up even when their file no longer matches the
+	// deleted object. The file itself is still kept.
+	Force bool
+	// Progress, if set, receives an event as items are cleaned up.
+	Progress progress.Func
+	// ContinueOnError records an item that fails in the report's Errors and
+	// moves on to the next, instead of stopping the run at the first
+	// failure. The failed item is deferred for RetryDelay when the
+	// repository implements CleanupDeferrer.
+	ContinueOnError bool
+	// RetryDelay is how long a failed item is deferred. 0 uses
+	// DefaultCleanupRetryDelay.
+	RetryDelay time.Duration
+	// RetryDeferred also processes items deferred by an earlier failure
+	// whose retry time has not come yet.
+	RetryDeferred bool
+	// MultipartExpiry is how old an incomplete multipart upload must be to
+	// be aborted. 0 uses DefaultMultipartExpiry.
+	MultipartExpiry time.Duration
+}
+
+// TombstoneReport summarizes a TombstoneWithReport run.
+type TombstoneReport struct {
+	Cleaned        int              `json:"cleaned"`         // Items marked cleaned up (or that would be, in a dry run)
+	BytesReclaimed int64            `json:"bytes_reclaimed"` // Sum of FileSizeBytes of files removed from storage
+	Skipped        int              `json:"skipped"`         // Cleaned items whose file was already missing from storage
+	Mismatched     int              `json:"mismatched"`      // Items whose file no longer matched the deleted object and was kept
+	Failed         int              `json:"failed"`          // Items that could not be cleaned up, listed in Errors
+	Deferred       int              `json:"deferred"`        // Items left alone because an earlier failure deferred them
+	AbortedUploads int              `json:"aborted_uploads"` // Expired multipart uploads whose parts were removed
+	Errors         []TombstoneError `json:"errors,omitempty"`
+}
+
+// TombstoneError records an item that could not be cleaned up.
+type TombstoneError struct {
+	Path string `json:"path"`
+	Err  error  `json:"-"`
+}
+
+func (e TombstoneError) Error() string {
+	return e.Path + ": " + e.Err.Error()
+}
+
+// StoredFile describes a file in storage without reading its content.
+type StoredFile struct {
+	Path    string
+	Size    int64
+	ModTime time.Time
+}
+
+// OrphanAction is what CollectOrphans does with an orphaned file.
+type OrphanAction string
+
+const (
+	OrphanActionReport     OrphanAction = "report"     // Only report orphans
+	OrphanActionQuarantine OrphanAction = "quarantine" // Move orphans under OrphanOptions.QuarantinePrefix
+	OrphanActionDelete     OrphanAction = "delete"     // Delete orphans from storage
+)
+
+// DefaultQuarantinePrefix is where quarantined orphans are moved when
+// OrphanOptions.QuarantinePrefix is empty. It is reserved: Populate skips it
+// and no object can be created under it, so quarantined files never come
+// back as objects. A custom QuarantinePrefix is not reserved.
+const DefaultQuarantinePrefix = ".stowry-quarantine/"
+
+// OrphanOptions controls a CollectOrphans run.
+type OrphanOptions struct {
+	Action OrphanAction // Default: OrphanActionReport
+	// MinAge skips files modified more recently, so uploads still being
+	// finalized are never flagged.
+	MinAge           time.Duration
+	QuarantinePrefix string // Default: DefaultQuarantinePrefix
+
+	// Progress, if set, receives an event as files are checked. Bytes counts
+	// the size of every file checked, not only orphans.
+	Progress progress.Func
+}
+
+// OrphanReport summarizes a CollectOrphans run.
+type OrphanReport struct {
+	Scanned   int           `json:"scanned"`    // Files walked, excluding the quarantine prefix
+	TooRecent int           `json:"too_recent"` // Files skipped because they are younger than MinAge
+	Orphans   int           `json:"orphans"`    // Files without metadata
+	Bytes     int64         `json:"bytes"`      // Total size of orphans
+	Handled   int           `json:"handled"`    // Orphans moved or deleted (0 when only reporting)
+	Paths     []string      `json:"paths,omitempty"`
+	Errors    []OrphanError `json:"errors,omitempty"`
+}
+
+// OrphanError records an orphan that could not be moved or deleted.
+type OrphanError struct {
+	Path string `json:"path"`
+	Err  error  `json:"-"`
+}
+
+func (e OrphanError) Error() string {
+	return e.Path + ": " + e.Err.Error()
+}
+
 type SaveResult struct {
 	BytesWritten int64
 	Etag         string
@@ -45,6 +221,32 @@ type SaveResult struct {
 type CreateObject struct {
 	Path        string
 	ContentType string
+	Size        int64 // Expected content length in bytes. 0 means unknown.
+	// ContentEncoding is the coding the content was uploaded with, such as
+	// "gzip". The content is stored encoded and decoded only to check it.
+	ContentEncoding string
+	// Filename is the client's original filename, sanitized with
+	// SanitizeFilename before it is stored. Empty stores none.
+	Filename string
+	// UserMetadata is the client's key/value metadata, checked with
+	// NormalizeUserMetadata before it is stored. Nil stores none.
+	UserMetadata map[string]string
+	// CacheControl is the Cache-Control to serve the object with, checked
+	// with NormalizeCacheControl before it is stored. Empty stores none.
+	CacheControl string
+	// ID is the object ID the client chose, as parsed by ParseObjectID.
+	// uuid.Nil lets the repository generate one with its IDScheme. Only
+	// Create honors it; CreateMany and staged uploads always generate IDs.
+	ID uuid.UUID
+}
+
+// CreateResult is the outcome of StowryService.Create: the metadata of the
+// stored object and of the object it replaced.
+type CreateResult struct {
+	MetaData
+	// Previous is the active object the create overwrote; nil when the path
+	// had none, including when a soft-deleted object was restored.
+	Previous *MetaData `json:"-"`
 }
 
 type ServerMode string
@@ -95,5 +297,82 @@ func (t Tables) Validate() error {
 		return fmt.Errorf("validate tables: invalid metadata table name: %s (must match ^[a-z_][a-z0-9_]*$ and be <= 63 chars)", t.MetaData)
 	}
 
+	if !IsValidTableName(t.StagedObjects()) {
+		return fmt.Errorf("validate tables: metadata table name %s is too long for its staging tables (max %d chars)", t.MetaData, 63-len(stagedObjectsSuffix))
+	}
+
 	return nil
 }
+
+const (
+	stagesSuffix        = "_stages"
+	stagedObjectsSuffix = "_staged_objects"
+	changesSuffix       = "_changes"
+	settingsSuffix      = "_settings"
+	usageSuffix         = "_usage"
+	usagePrefixesSuffix = "_usage_prefixes"
+	accessKeysSuffix    = "_access_keys"
+	writeIntentsSuffix  = "_write_intents"
+	uploadsSuffix       = "_uploads"
+	uploadPartsSuffix   = "_upload_parts"
+)
+
+// Stages returns the name of the table holding open stages, derived from
+// the metadata table name.
+func (t Tables) Stages() string {
+	return t.MetaData + stagesSuffix
+}
+
+// StagedObjects returns the name of the table holding the objects uploaded
+// into open stages, derived from the metadata table name.
+func (t Tables) StagedObjects() string {
+	return t.MetaData + stagedObjectsSuffix
+}
+
+// Changes returns the name of the table holding the change feed, derived
+// from the metadata table name.
+func (t Tables) Changes() string {
+	return t.MetaData + changesSuffix
+}
+
+// Usage returns the name of the table holding usage snapshots, derived from
+// the metadata table name.
+func (t Tables) Usage() string {
+	return t.MetaData + usageSuffix
+}
+
+// UsagePrefixes returns the name of the table holding the per-prefix rows of
+// usage snapshots, derived from the metadata table name.
+func (t Tables) UsagePrefixes() string {
+	return t.MetaData + usagePrefixesSuffix
+}
+
+// AccessKeys returns the name of the table recording the use and rotation
+// of access keys, derived from the metadata table name.
+func (t Tables) AccessKeys() string {
+	return t.MetaData + accessKeysSuffix
+}
+
+// WriteIntents returns the name of the table holding the write intents of
+// uploads in progress, derived from the metadata table name.
+func (t Tables) WriteIntents() string {
+	return t.MetaData + writeIntentsSuffix
+}
+
+// Uploads returns the name of the table holding incomplete multipart uploads,
+// derived from the metadata table name.
+func (t Tables) Uploads() string {
+	return t.MetaData + uploadsSuffix
+}
+
+// UploadParts returns the name of the table holding the parts of incomplete
+// multipart uploads, derived from the metadata table name.
+func (t Tables) UploadParts() string {
+	return t.MetaData + uploadPartsSuffix
+}
+
+// Settings returns the name of the key/value table holding instance
+// settings such as the instance ID, derived from the metadata table name.
+func (t Tables) Settings() string {
+	return t.MetaData + settingsSuffix
+}
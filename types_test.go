@@ -1,6 +1,7 @@
 package stowry_test
 
 import (
+	"strings"
 	"testing"
 
 	"github.com/sagarc03/stowry"
@@ -144,3 +145,40 @@ func TestServerMode_Constants(t *testing.T) {
 		assert.True(t, stowry.ModeSPA.IsValid())
 	})
 }
+
+func TestTables_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		tables  stowry.Tables
+		wantErr string
+	}{
+		{name: "default", tables: stowry.Tables{MetaData: "stowry_metadata"}},
+		{name: "empty", tables: stowry.Tables{}, wantErr: "cannot be empty"},
+		{name: "invalid characters", tables: stowry.Tables{MetaData: "Meta-Data"}, wantErr: "invalid metadata table name"},
+		{name: "too long for staging tables", tables: stowry.Tables{MetaData: strings.Repeat("m", 50)}, wantErr: "too long for its staging tables"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.tables.Validate()
+			if tt.wantErr == "" {
+				assert.NoError(t, err)
+				return
+			}
+			assert.ErrorContains(t, err, tt.wantErr)
+		})
+	}
+
+	t.Run("derived table names", func(t *testing.T) {
+		tables := stowry.Tables{MetaData: "stowry_metadata"}
+		assert.Equal(t, "stowry_metadata_stages", tables.Stages())
+		assert.Equal(t, "stowry_metadata_staged_objects", tables.StagedObjects())
+		assert.Equal(t, "stowry_metadata_changes", tables.Changes())
+		assert.Equal(t, "stowry_metadata_settings", tables.Settings())
+		assert.Equal(t, "stowry_metadata_usage", tables.Usage())
+		assert.Equal(t, "stowry_metadata_usage_prefixes", tables.UsagePrefixes())
+		assert.Equal(t, "stowry_metadata_access_keys", tables.AccessKeys())
+		assert.Equal(t, "stowry_metadata_write_intents", tables.WriteIntents())
+		assert.Equal(t, "stowry_metadata_uploads", tables.Uploads())
+		assert.Equal(t, "stowry_metadata_upload_parts", tables.UploadParts())
+	})
+}
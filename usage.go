@@ -0,0 +1,330 @@
+package stowry
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// PrefixUsage is the usage of one top-level prefix in a UsageSnapshot.
+type PrefixUsage struct {
+	// Prefix is the first segment of the object paths with its trailing
+	// slash, such as "team-a/", or empty for objects at the root.
+	Prefix  string `json:"prefix"`
+	Objects int64  `json:"objects"` // Active objects when the snapshot was taken
+	Bytes   int64  `json:"bytes"`   // Their total size
+	// ObjectsAdded and BytesAdded count the creates and overwrites under
+	// the prefix since the first snapshot, read from the change feed. They
+	// only grow; subtract two snapshots' counters for a period.
+	ObjectsAdded int64 `json:"objects_added"`
+	BytesAdded   int64 `json:"bytes_added"`
+}
+
+// UsageSnapshot is the usage of every top-level prefix at one point in time.
+type UsageSnapshot struct {
+	ID      int64     `json:"id"`
+	TakenAt time.Time `json:"taken_at"`
+	// Complete is false when changes since the previous snapshot were
+	// pruned from the change feed before this snapshot read them, so its
+	// added counters miss them.
+	Complete bool `json:"complete"`
+	// Prefixes are in prefix order. A prefix keeps its row with zero
+	// objects once emptied, so its counters carry on.
+	Prefixes []PrefixUsage `json:"prefixes,omitempty"`
+}
+
+// UsageRepo is an optional MetaDataRepo extension that records usage
+// snapshots for chargeback. A snapshot counts the active objects under each
+// top-level prefix and reads the creates recorded in the change feed since
+// the previous snapshot, in one transaction, so both describe the same
+// moment. The service checks for it with a type assertion.
+type UsageRepo interface {
+	// TakeUsageSnapshot records the usage of every top-level prefix now.
+	//
+	// Returns:
+	//   - UsageSnapshot: The snapshot with its prefixes
+	//   - error: Any database error
+	TakeUsageSnapshot(ctx context.Context) (UsageSnapshot, error)
+
+	// ListUsageSnapshots returns the snapshots taken from from to to,
+	// inclusive, oldest first, without their prefixes.
+	//
+	// Returns:
+	//   - []UsageSnapshot: The snapshots; empty when there are none
+	//   - error: Any database error
+	ListUsageSnapshots(ctx context.Context, from, to time.Time) ([]UsageSnapshot, error)
+
+	// UsageSnapshotAt returns the latest snapshot taken at or before at,
+	// with its prefixes.
+	//
+	// Returns:
+	//   - UsageSnapshot: The snapshot with its prefixes
+	//   - error: ErrNotFound if no snapshot was taken by then, or other
+	//     database errors
+	UsageSnapshotAt(ctx context.Context, at time.Time) (UsageSnapshot, error)
+}
+
+// BackendUsage is the usage of one storage backend.
+type BackendUsage struct {
+	// Backend is the prefix of the storage route the objects were written
+	// to, or empty for the default backend and for objects recorded before
+	// backends were tracked.
+	Backend string `json:"backend"`
+	Objects int64  `json:"objects"` // Active objects on the backend
+	Bytes   int64  `json:"bytes"`   // Their total size
+}
+
+// BackendUsageRepo is an optional MetaDataRepo extension that counts the
+// active objects on each storage backend, as recorded in
+// MetaData.StorageBackend. The service checks for it with a type assertion.
+type BackendUsageRepo interface {
+	// BackendUsage returns the usage of every backend that holds active
+	// objects, in backend order.
+	//
+	// Returns:
+	//   - []BackendUsage: The backends; empty when there are no objects
+	//   - error: Any database error
+	BackendUsage(ctx context.Context) ([]BackendUsage, error)
+}
+
+// UsageDelta is one prefix of a UsageReport.
+type UsageDelta struct {
+	Prefix  string `json:"prefix"`
+	Objects int64  `json:"objects"` // Active objects at the end of the period
+	Bytes   int64  `json:"bytes"`
+	// ObjectsAdded and BytesAdded are the creates and overwrites during the
+	// period. ObjectsDeleted and BytesDeleted are derived as added minus the
+	// net change, so they include objects replaced by an overwrite.
+	ObjectsAdded   int64 `json:"objects_added"`
+	ObjectsDeleted int64 `json:"objects_deleted"`
+	BytesAdded     int64 `json:"bytes_added"`
+	BytesDeleted   int64 `json:"bytes_deleted"`
+}
+
+// UsageReport is the chargeback table for the period between two usage
+// snapshots.
+type UsageReport struct {
+	From time.Time `json:"from"` // When the starting snapshot was taken
+	To   time.Time `json:"to"`   // When the ending snapshot was taken
+	// Complete is false when a snapshot in the period missed pruned
+	// changes, so the added counts are too low and the deleted counts,
+	// derived from them, may be too.
+	Complete bool         `json:"complete"`
+	Prefixes []UsageDelta `json:"prefixes"`
+}
+
+// usageCSVHeader is the header row of UsageReport.WriteCSV.
+var usageCSVHeader = []string{"prefix", "objects", "bytes", "objects_added", "objects_deleted", "bytes_added", "bytes_deleted"}
+
+// WriteCSV writes the report as CSV with a header row, one row per prefix.
+func (r UsageReport) WriteCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(usageCSVHeader); err != nil {
+		return fmt.Errorf("write usage report: %w", err)
+	}
+	for _, p := range r.Prefixes {
+		row := []string{p.Prefix}
+		for _, n := range []int64{p.Objects, p.Bytes, p.ObjectsAdded, p.ObjectsDeleted, p.BytesAdded, p.BytesDeleted} {
+			row = append(row, strconv.FormatInt(n, 10))
+		}
+		if err := cw.Write(row); err != nil {
+			return fmt.Errorf("write usage report: %w", err)
+		}
+	}
+	cw.Flush()
+	if err := cw.Error(); err != nil {
+		return fmt.Errorf("write usage report: %w", err)
+	}
+	return nil
+}
+
+// UsagePrefix returns the top-level prefix that path is counted under.
+func UsagePrefix(path string) string {
+	if i := strings.IndexByte(path, '/'); i >= 0 {
+		return path[:i+1]
+	}
+	return ""
+}
+
+// usageRepo returns the repository's usage snapshots.
+func (s *StowryService) usageRepo() (UsageRepo, error) {
+	repo, ok := s.repo.(UsageRepo)
+	if !ok {
+		return nil, fmt.Errorf("metadata repository does not record usage: %w", ErrNotSupported)
+	}
+	return repo, nil
+}
+
+// TakeUsageSnapshot records the usage of every top-level prefix now.
+//
+// Returns:
+//   - UsageSnapshot: The snapshot with its prefixes
+//   - error: ErrNotSupported without usage snapshots, or any database error
+func (s *StowryService) TakeUsageSnapshot(ctx context.Context) (UsageSnapshot, error) {
+	repo, err := s.usageRepo()
+	if err != nil {
+		return UsageSnapshot{}, fmt.Errorf("take usage snapshot: %w", err)
+	}
+
+	snapshot, err := repo.TakeUsageSnapshot(ctx)
+	if err != nil {
+		return UsageSnapshot{}, fmt.Errorf("take usage snapshot: %w", err)
+	}
+	return snapshot, nil
+}
+
+// BackendUsage returns the number and total size of the active objects on
+// each storage backend, in backend order.
+//
+// Returns:
+//   - []BackendUsage: The backends; empty when there are no objects
+//   - error: ErrNotSupported when the repository does not count backends,
+//     or any database error
+func (s *StowryService) BackendUsage(ctx context.Context) ([]BackendUsage, error) {
+	repo, ok := s.repo.(BackendUsageRepo)
+	if !ok {
+		return nil, fmt.Errorf("backend usage: metadata repository does not count backends: %w", ErrNotSupported)
+	}
+
+	backends, err := repo.BackendUsage(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("backend usage: %w", err)
+	}
+	return backends, nil
+}
+
+// UsageSnapshots returns the snapshots taken from from to to, inclusive,
+// oldest first, without their prefixes.
+//
+// Returns:
+//   - []UsageSnapshot: The snapshots; empty when there are none
+//   - error: ErrNotSupported without usage snapshots, ErrInvalidInput if to
+//     is before from, or any database error
+func (s *StowryService) UsageSnapshots(ctx context.Context, from, to time.Time) ([]UsageSnapshot, error) {
+	repo, err := s.usageRepo()
+	if err != nil {
+		return nil, fmt.Errorf("list usage snapshots: %w", err)
+	}
+	if to.Before(from) {
+		return nil, fmt.Errorf("list usage snapshots: %w: to is before from", ErrInvalidInput)
+	}
+
+	snapshots, err := repo.ListUsageSnapshots(ctx, from, to)
+	if err != nil {
+		return nil, fmt.Errorf("list usage snapshots: %w", err)
+	}
+	return snapshots, nil
+}
+
+// UsageSnapshotAt returns the latest snapshot taken at or before at, with
+// its prefixes.
+//
+// Returns:
+//   - UsageSnapshot: The snapshot with its prefixes
+//   - error: ErrNotSupported without usage snapshots, ErrNotFound if no
+//     snapshot was taken by then, or any database error
+func (s *StowryService) UsageSnapshotAt(ctx context.Context, at time.Time) (UsageSnapshot, error) {
+	repo, err := s.usageRepo()
+	if err != nil {
+		return UsageSnapshot{}, fmt.Errorf("get usage snapshot: %w", err)
+	}
+
+	snapshot, err := repo.UsageSnapshotAt(ctx, at)
+	if err != nil {
+		return UsageSnapshot{}, fmt.Errorf("get usage snapshot: %w", err)
+	}
+	return snapshot, nil
+}
+
+// UsageReport compares the latest snapshots taken at or before from and
+// to, prefix by prefix. Added counts are the difference of the snapshots'
+// counters; deleted counts are added minus the net change in objects and
+// bytes, never below zero.
+//
+// Returns:
+//   - UsageReport: The report; nothing added or deleted when from and to
+//     fall between the same two snapshots
+//   - error: ErrNotSupported without usage snapshots, ErrInvalidInput if to
+//     is before from, ErrNotFound if no snapshot was taken by from, or any
+//     database error
+func (s *StowryService) UsageReport(ctx context.Context, from, to time.Time) (UsageReport, error) {
+	repo, err := s.usageRepo()
+	if err != nil {
+		return UsageReport{}, fmt.Errorf("usage report: %w", err)
+	}
+	if to.Before(from) {
+		return UsageReport{}, fmt.Errorf("usage report: %w: to is before from", ErrInvalidInput)
+	}
+
+	start, err := repo.UsageSnapshotAt(ctx, from)
+	if err != nil {
+		return UsageReport{}, fmt.Errorf("usage report: snapshot at %s: %w", from.Format(time.RFC3339), err)
+	}
+	end, err := repo.UsageSnapshotAt(ctx, to)
+	if err != nil {
+		return UsageReport{}, fmt.Errorf("usage report: snapshot at %s: %w", to.Format(time.RFC3339), err)
+	}
+
+	between, err := repo.ListUsageSnapshots(ctx, start.TakenAt, end.TakenAt)
+	if err != nil {
+		return UsageReport{}, fmt.Errorf("usage report: %w", err)
+	}
+	report := UsageReport{From: start.TakenAt, To: end.TakenAt, Complete: true, Prefixes: []UsageDelta{}}
+	for _, snapshot := range between {
+		if snapshot.ID > start.ID && !snapshot.Complete {
+			report.Complete = false
+		}
+	}
+
+	before := make(map[string]PrefixUsage, len(start.Prefixes))
+	for _, p := range start.Prefixes {
+		before[p.Prefix] = p
+	}
+	for _, p := range end.Prefixes {
+		b := before[p.Prefix]
+		d := UsageDelta{
+			Prefix:       p.Prefix,
+			Objects:      p.Objects,
+			Bytes:        p.Bytes,
+			ObjectsAdded: p.ObjectsAdded - b.ObjectsAdded,
+			BytesAdded:   p.BytesAdded - b.BytesAdded,
+		}
+		d.ObjectsDeleted = max(0, d.ObjectsAdded-(p.Objects-b.Objects))
+		d.BytesDeleted = max(0, d.BytesAdded-(p.Bytes-b.Bytes))
+		report.Prefixes = append(report.Prefixes, d)
+	}
+	return report, nil
+}
+
+// RunUsageSnapshots takes a usage snapshot every interval until ctx is
+// cancelled. Failures are logged and retried on the next tick.
+func (s *StowryService) RunUsageSnapshots(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		snapshot, err := s.TakeUsageSnapshot(ctx)
+		if err != nil {
+			if ctx.Err() == nil {
+				slog.Warn("usage snapshot failed", "err", err)
+			}
+			continue
+		}
+		if !snapshot.Complete {
+			slog.Warn("usage snapshot missed pruned changes; raise service.changes_retention above the snapshot interval",
+				"snapshot", snapshot.ID)
+		}
+		slog.Debug("usage snapshot taken", "snapshot", snapshot.ID, "prefixes", len(snapshot.Prefixes))
+	}
+}
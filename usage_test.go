@@ -0,0 +1,154 @@
+package stowry_test
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/sagarc03/stowry"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// SpyUsageRepo is a SpyMetaDataRepo that also implements stowry.UsageRepo.
+type SpyUsageRepo struct {
+	SpyMetaDataRepo
+}
+
+func (s *SpyUsageRepo) TakeUsageSnapshot(ctx context.Context) (stowry.UsageSnapshot, error) {
+	args := s.Called(ctx)
+	return args.Get(0).(stowry.UsageSnapshot), args.Error(1)
+}
+
+func (s *SpyUsageRepo) ListUsageSnapshots(ctx context.Context, from, to time.Time) ([]stowry.UsageSnapshot, error) {
+	args := s.Called(ctx, from, to)
+	snapshots, _ := args.Get(0).([]stowry.UsageSnapshot)
+	return snapshots, args.Error(1)
+}
+
+func (s *SpyUsageRepo) UsageSnapshotAt(ctx context.Context, at time.Time) (stowry.UsageSnapshot, error) {
+	args := s.Called(ctx, at)
+	return args.Get(0).(stowry.UsageSnapshot), args.Error(1)
+}
+
+func newUsageService(t *testing.T) (*stowry.StowryService, *SpyUsageRepo) {
+	t.Helper()
+	repo := new(SpyUsageRepo)
+	service, err := stowry.NewStowryService(repo, new(SpyFileStorage), stowry.ServiceConfig{Mode: stowry.ModeStore})
+	require.NoError(t, err)
+	return service, repo
+}
+
+func TestStowryService_UsageReport(t *testing.T) {
+	ctx := context.Background()
+	from := time.Date(2026, 9, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC)
+
+	start := stowry.UsageSnapshot{ID: 1, TakenAt: from, Complete: true, Prefixes: []stowry.PrefixUsage{
+		{Prefix: "", Objects: 1, Bytes: 5, ObjectsAdded: 1, BytesAdded: 5},
+		{Prefix: "team-a/", Objects: 2, Bytes: 20, ObjectsAdded: 2, BytesAdded: 20},
+	}}
+	end := stowry.UsageSnapshot{ID: 3, TakenAt: to, Complete: true, Prefixes: []stowry.PrefixUsage{
+		{Prefix: "", Objects: 1, Bytes: 5, ObjectsAdded: 1, BytesAdded: 5},
+		// Three uploads, one of them overwriting an object of 10 bytes with 12
+		{Prefix: "team-a/", Objects: 4, Bytes: 37, ObjectsAdded: 5, BytesAdded: 47},
+		{Prefix: "team-b/", Objects: 0, Bytes: 0, ObjectsAdded: 1, BytesAdded: 8},
+	}}
+
+	t.Run("compares the snapshots prefix by prefix", func(t *testing.T) {
+		service, repo := newUsageService(t)
+		repo.On("UsageSnapshotAt", mock.Anything, from).Return(start, nil)
+		repo.On("UsageSnapshotAt", mock.Anything, to).Return(end, nil)
+		repo.On("ListUsageSnapshots", mock.Anything, from, to).Return([]stowry.UsageSnapshot{
+			{ID: 1, TakenAt: from, Complete: true},
+			{ID: 2, TakenAt: from.Add(time.Hour), Complete: true},
+			{ID: 3, TakenAt: to, Complete: true},
+		}, nil)
+
+		report, err := service.UsageReport(ctx, from, to)
+		require.NoError(t, err)
+		assert.True(t, report.Complete)
+		assert.Equal(t, from, report.From)
+		assert.Equal(t, to, report.To)
+		assert.Equal(t, []stowry.UsageDelta{
+			{Prefix: "", Objects: 1, Bytes: 5},
+			{Prefix: "team-a/", Objects: 4, Bytes: 37, ObjectsAdded: 3, ObjectsDeleted: 1, BytesAdded: 27, BytesDeleted: 10},
+			{Prefix: "team-b/", ObjectsAdded: 1, ObjectsDeleted: 1, BytesAdded: 8, BytesDeleted: 8},
+		}, report.Prefixes)
+
+		var buf bytes.Buffer
+		require.NoError(t, report.WriteCSV(&buf))
+		assert.Equal(t, "prefix,objects,bytes,objects_added,objects_deleted,bytes_added,bytes_deleted\n"+
+			",1,5,0,0,0,0\n"+
+			"team-a/,4,37,3,1,27,10\n"+
+			"team-b/,0,0,1,1,8,8\n", buf.String())
+	})
+
+	t.Run("incomplete when a later snapshot missed changes", func(t *testing.T) {
+		service, repo := newUsageService(t)
+		repo.On("UsageSnapshotAt", mock.Anything, from).Return(start, nil)
+		repo.On("UsageSnapshotAt", mock.Anything, to).Return(end, nil)
+		repo.On("ListUsageSnapshots", mock.Anything, from, to).Return([]stowry.UsageSnapshot{
+			{ID: 1, TakenAt: from, Complete: false},
+			{ID: 2, TakenAt: from.Add(time.Hour), Complete: false},
+			{ID: 3, TakenAt: to, Complete: true},
+		}, nil)
+
+		report, err := service.UsageReport(ctx, from, to)
+		require.NoError(t, err)
+		assert.False(t, report.Complete)
+	})
+
+	t.Run("an incomplete starting snapshot does not matter", func(t *testing.T) {
+		service, repo := newUsageService(t)
+		repo.On("UsageSnapshotAt", mock.Anything, from).Return(start, nil)
+		repo.On("UsageSnapshotAt", mock.Anything, to).Return(end, nil)
+		repo.On("ListUsageSnapshots", mock.Anything, from, to).Return([]stowry.UsageSnapshot{
+			{ID: 1, TakenAt: from, Complete: false},
+			{ID: 3, TakenAt: to, Complete: true},
+		}, nil)
+
+		report, err := service.UsageReport(ctx, from, to)
+		require.NoError(t, err)
+		assert.True(t, report.Complete)
+	})
+
+	t.Run("no snapshot by the start", func(t *testing.T) {
+		service, repo := newUsageService(t)
+		repo.On("UsageSnapshotAt", mock.Anything, from).Return(stowry.UsageSnapshot{}, stowry.ErrNotFound)
+
+		_, err := service.UsageReport(ctx, from, to)
+		assert.ErrorIs(t, err, stowry.ErrNotFound)
+	})
+
+	t.Run("to before from", func(t *testing.T) {
+		service, _ := newUsageService(t)
+		_, err := service.UsageReport(ctx, to, from)
+		assert.ErrorIs(t, err, stowry.ErrInvalidInput)
+	})
+
+	t.Run("repository without usage snapshots", func(t *testing.T) {
+		service, err := stowry.NewStowryService(new(SpyMetaDataRepo), new(SpyFileStorage), stowry.ServiceConfig{Mode: stowry.ModeStore})
+		require.NoError(t, err)
+		_, err = service.UsageReport(ctx, from, to)
+		assert.ErrorIs(t, err, stowry.ErrNotSupported)
+	})
+}
+
+func TestUsagePrefix(t *testing.T) {
+	assert.Equal(t, "team-a/", stowry.UsagePrefix("team-a/reports/q3.csv"))
+	assert.Equal(t, "team-a/", stowry.UsagePrefix("team-a/"))
+	assert.Equal(t, "", stowry.UsagePrefix("readme.txt"))
+}
+
+func TestStowryService_BackendUsage(t *testing.T) {
+	ctx := context.Background()
+
+	service, err := stowry.NewStowryService(new(SpyMetaDataRepo), new(SpyFileStorage), stowry.ServiceConfig{Mode: stowry.ModeStore})
+	require.NoError(t, err)
+
+	_, err = service.BackendUsage(ctx)
+	assert.ErrorIs(t, err, stowry.ErrNotSupported)
+}
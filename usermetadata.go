@@ -0,0 +1,69 @@
+package stowry
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// MaxUserMetadataBytes is the total size, in bytes of keys and values, of
+// the user metadata stored with an object. It matches the limit S3 puts on
+// x-amz-meta-* headers.
+const MaxUserMetadataBytes = 2048
+
+// NormalizeUserMetadata returns the user metadata to store with an object.
+// Keys are lowercased, as HTTP header names are case-insensitive, and must be
+// non-empty header tokens. Values must be valid UTF-8 without control
+// characters, so they can be sent back as header values.
+//
+// Returns:
+//   - map[string]string: The normalized metadata, or nil for none
+//   - error: ErrInvalidUserMetadata for an invalid key or value, or
+//     ErrUserMetadataTooLarge when the keys and values take more than
+//     MaxUserMetadataBytes; both also match ErrInvalidInput
+func NormalizeUserMetadata(metadata map[string]string) (map[string]string, error) {
+	if len(metadata) == 0 {
+		return nil, nil
+	}
+
+	normalized := make(map[string]string, len(metadata))
+	size := 0
+	for key, value := range metadata {
+		key = strings.ToLower(key)
+		if !isHeaderToken(key) {
+			return nil, fmt.Errorf("%w: key %q", ErrInvalidUserMetadata, key)
+		}
+		if !utf8.ValidString(value) || strings.ContainsFunc(value, unicode.IsControl) {
+			return nil, fmt.Errorf("%w: value of %q", ErrInvalidUserMetadata, key)
+		}
+		if _, ok := normalized[key]; ok {
+			return nil, fmt.Errorf("%w: duplicate key %q", ErrInvalidUserMetadata, key)
+		}
+		normalized[key] = value
+		size += len(key) + len(value)
+	}
+
+	if size > MaxUserMetadataBytes {
+		return nil, fmt.Errorf("%w: %d bytes (max %d)", ErrUserMetadataTooLarge, size, MaxUserMetadataBytes)
+	}
+	return normalized, nil
+}
+
+// isHeaderToken reports whether s is a non-empty RFC 9110 token, the
+// characters allowed in a header name.
+func isHeaderToken(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case 'a' <= c && c <= 'z', 'A' <= c && c <= 'Z', '0' <= c && c <= '9':
+		case strings.IndexByte("!#$%&'*+-.^_`|~", c) >= 0:
+		default:
+			return false
+		}
+	}
+	return true
+}
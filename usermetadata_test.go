@@ -0,0 +1,80 @@
+package stowry_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/sagarc03/stowry"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNormalizeUserMetadata(t *testing.T) {
+	got, err := stowry.NormalizeUserMetadata(map[string]string{"Uploader": "u-42", "original_name": "résumé.pdf"})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"uploader": "u-42", "original_name": "résumé.pdf"}, got)
+
+	for _, empty := range []map[string]string{nil, {}} {
+		got, err := stowry.NormalizeUserMetadata(empty)
+		require.NoError(t, err)
+		assert.Nil(t, got)
+	}
+
+	at := map[string]string{"k": strings.Repeat("v", stowry.MaxUserMetadataBytes-1)}
+	_, err = stowry.NormalizeUserMetadata(at)
+	assert.NoError(t, err, "keys and values may take exactly the limit")
+
+	_, err = stowry.NormalizeUserMetadata(map[string]string{"k": strings.Repeat("v", stowry.MaxUserMetadataBytes)})
+	assert.ErrorIs(t, err, stowry.ErrUserMetadataTooLarge)
+	assert.ErrorIs(t, err, stowry.ErrInvalidInput)
+
+	for name, metadata := range map[string]map[string]string{
+		"empty key":           {"": "v"},
+		"key with space":      {"a b": "v"},
+		"key with colon":      {"a:b": "v"},
+		"newline in value":    {"k": "a\r\nSet-Cookie: x=1"},
+		"invalid utf-8":       {"k": "bad\xff"},
+		"keys differ in case": {"Key": "a", "key": "b"},
+	} {
+		_, err := stowry.NormalizeUserMetadata(metadata)
+		assert.ErrorIs(t, err, stowry.ErrInvalidUserMetadata, name)
+		assert.ErrorIs(t, err, stowry.ErrInvalidInput, name)
+	}
+}
+
+func TestStowryService_Create_UserMetadata(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("normalized metadata is recorded", func(t *testing.T) {
+		service, repo, storage := NewStowryService(t)
+
+		storage.On("Write", ctx, "a.txt", mock.Anything).Return(stowry.SaveResult{BytesWritten: 4, Etag: "e"}, nil)
+		repo.On("Upsert", ctx, mock.MatchedBy(func(entry stowry.ObjectEntry) bool {
+			return assert.ObjectsAreEqual(map[string]string{"uploader": "u-42"}, entry.UserMetadata)
+		})).Return(stowry.MetaData{Path: "a.txt", UserMetadata: map[string]string{"uploader": "u-42"}}, nil, nil)
+
+		m, err := service.Create(ctx, stowry.CreateObject{
+			Path:         "a.txt",
+			ContentType:  "text/plain",
+			UserMetadata: map[string]string{"Uploader": "u-42"},
+		}, strings.NewReader("data"))
+		require.NoError(t, err)
+		assert.Equal(t, map[string]string{"uploader": "u-42"}, m.UserMetadata)
+		repo.AssertExpectations(t)
+	})
+
+	t.Run("too large metadata is rejected before the write", func(t *testing.T) {
+		service, repo, storage := NewStowryService(t)
+
+		_, err := service.Create(ctx, stowry.CreateObject{
+			Path:         "a.txt",
+			ContentType:  "text/plain",
+			UserMetadata: map[string]string{"k": strings.Repeat("v", stowry.MaxUserMetadataBytes)},
+		}, strings.NewReader("data"))
+		assert.ErrorIs(t, err, stowry.ErrUserMetadataTooLarge)
+		storage.AssertNotCalled(t, "Write")
+		repo.AssertNotCalled(t, "Upsert")
+	})
+}
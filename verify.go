@@ -0,0 +1,138 @@
+package stowry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/sagarc03/stowry/progress"
+)
+
+// VerifyOptions controls a Verify run.
+type VerifyOptions struct {
+	Query ListQuery // Path prefix filter and page size (default: the service batch size); the cursor is managed internally
+	// VerifyHash also compares the SHA-256 of each file with the object's
+	// ETag, which reads the whole file.
+	VerifyHash bool
+	// Quarantine soft-deletes and flags each mismatched object, as
+	// ReadVerifyConfig.Quarantine does for downloads, so it is no longer
+	// served and cleanup keeps its file.
+	Quarantine bool
+	// Progress, if set, receives an event as objects are checked.
+	Progress progress.Func
+}
+
+// VerifyReport summarizes a Verify run.
+type VerifyReport struct {
+	Checked     int           `json:"checked"`               // Objects checked
+	Bytes       int64         `json:"bytes"`                 // Sum of FileSizeBytes of the objects checked
+	Missing     []string      `json:"missing,omitempty"`     // Objects whose file is missing from storage
+	Mismatched  []string      `json:"mismatched,omitempty"`  // Objects whose file differs in size, or in hash with VerifyHash
+	Quarantined []string      `json:"quarantined,omitempty"` // Mismatched objects quarantined with VerifyOptions.Quarantine
+	Errors      []VerifyError `json:"errors,omitempty"`
+}
+
+// OK reports whether every object checked had a matching file.
+func (r VerifyReport) OK() bool {
+	return len(r.Missing) == 0 && len(r.Mismatched) == 0 && len(r.Errors) == 0
+}
+
+// VerifyError records an object whose file could not be checked.
+type VerifyError struct {
+	Path string `json:"path"`
+	Err  error  `json:"-"`
+}
+
+func (e VerifyError) Error() string {
+	return e.Path + ": " + e.Err.Error()
+}
+
+// Verify checks that every active object has its file in storage: present
+// on the backend recorded in StorageBackend, of the recorded size when
+// storage implements StorageStater, and with opts.VerifyHash of the recorded
+// SHA-256. Nothing is changed unless
+// opts.Quarantine is set; problems are collected in the report.
+//
+// A file that cannot be read is recorded in the report's Errors and the run
+// continues.
+//
+// Parameters:
+//   - ctx: Context for cancellation and timeout
+//   - opts: Query with optional path prefix filter and page size, the
+//     verify-hash and quarantine flags and progress callback
+//
+// Returns:
+//   - VerifyReport: Objects checked so far, also on error
+//   - error: Metadata listing or context errors
+func (s *StowryService) Verify(ctx context.Context, opts VerifyOptions) (VerifyReport, error) {
+	var report VerifyReport
+
+	if err := ctx.Err(); err != nil {
+		return report, fmt.Errorf("verify: %w", err)
+	}
+
+	if err := opts.Query.Validate(s.pathLimits); err != nil {
+		return report, fmt.Errorf("verify: %w", err)
+	}
+
+	tracker := progress.New("verify", opts.Progress)
+	defer tracker.Finish()
+
+	limit := opts.Query.Limit
+	if limit <= 0 {
+		limit = s.batchSize
+	}
+	cursor := opts.Query.Cursor
+
+	for {
+		if err := ctx.Err(); err != nil {
+			return report, fmt.Errorf("verify: %w", err)
+		}
+
+		result, err := s.repo.List(ctx, ListQuery{
+			PathPrefix: opts.Query.PathPrefix,
+			Limit:      limit,
+			Cursor:     cursor,
+		})
+		if err != nil {
+			return report, fmt.Errorf("verify: %w", err)
+		}
+		if len(result.Items) == 0 {
+			break
+		}
+
+		for _, obj := range result.Items {
+			matches, err := s.storedFileMatches(ctx, obj, opts.VerifyHash)
+			switch {
+			case errors.Is(err, ErrNotFound):
+				report.Missing = append(report.Missing, obj.Path)
+			case err != nil:
+				if ctxErr := ctx.Err(); ctxErr != nil {
+					return report, fmt.Errorf("verify: %w", ctxErr)
+				}
+				report.Errors = append(report.Errors, VerifyError{Path: obj.Path, Err: err})
+				tracker.Fail(obj.Path)
+				continue
+			case !matches:
+				report.Mismatched = append(report.Mismatched, obj.Path)
+				if opts.Quarantine {
+					if err := s.quarantine(ctx, obj); err != nil {
+						report.Errors = append(report.Errors, VerifyError{Path: obj.Path, Err: err})
+					} else {
+						report.Quarantined = append(report.Quarantined, obj.Path)
+					}
+				}
+			}
+			report.Checked++
+			report.Bytes += obj.FileSizeBytes
+			tracker.Add(obj.Path, obj.FileSizeBytes)
+		}
+
+		if result.NextCursor == "" {
+			break
+		}
+		cursor = result.NextCursor
+	}
+
+	return report, nil
+}
@@ -0,0 +1,117 @@
+package stowry_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/sagarc03/stowry"
+	"github.com/sagarc03/stowry/progress"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStowryService_Verify(t *testing.T) {
+	helloEtag := "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824" // sha256("hello")
+	objects := []stowry.MetaData{
+		{Path: "docs/ok.txt", FileSizeBytes: 5, Etag: helloEtag},
+		{Path: "docs/missing.txt", FileSizeBytes: 3},
+		{Path: "docs/short.txt", FileSizeBytes: 9},
+		{Path: "docs/broken.txt", FileSizeBytes: 4},
+	}
+
+	newService := func(t *testing.T) (*stowry.StowryService, *SpyMetaDataRepo, *SpyStatStorage) {
+		t.Helper()
+		repo := new(SpyMetaDataRepo)
+		storage := new(SpyStatStorage)
+		s, err := stowry.NewStowryService(repo, storage, stowry.ServiceConfig{Mode: stowry.ModeStore})
+		require.NoError(t, err)
+
+		repo.On("List", mock.Anything, stowry.ListQuery{PathPrefix: "docs/", Limit: 2}).
+			Return(stowry.ListResult{Items: objects[:2], NextCursor: "page2"}, nil)
+		repo.On("List", mock.Anything, stowry.ListQuery{PathPrefix: "docs/", Limit: 2, Cursor: "page2"}).
+			Return(stowry.ListResult{Items: objects[2:]}, nil)
+
+		storage.On("Stat", mock.Anything, "docs/ok.txt").Return(stowry.StoredFile{Size: 5}, nil)
+		storage.On("Stat", mock.Anything, "docs/missing.txt").Return(stowry.StoredFile{}, stowry.ErrNotFound)
+		storage.On("Stat", mock.Anything, "docs/short.txt").Return(stowry.StoredFile{Size: 2}, nil)
+		storage.On("Stat", mock.Anything, "docs/broken.txt").Return(stowry.StoredFile{}, errors.New("permission denied"))
+		return s, repo, storage
+	}
+
+	t.Run("reports missing, mismatched and unreadable files", func(t *testing.T) {
+		service, repo, storage := newService(t)
+
+		var events []progress.Event
+		report, err := service.Verify(context.Background(), stowry.VerifyOptions{
+			Query:    stowry.ListQuery{PathPrefix: "docs/", Limit: 2},
+			Progress: func(e progress.Event) { events = append(events, e) },
+		})
+
+		require.NoError(t, err)
+		assert.Equal(t, 3, report.Checked)
+		assert.Equal(t, int64(17), report.Bytes)
+		assert.Equal(t, []string{"docs/missing.txt"}, report.Missing)
+		assert.Equal(t, []string{"docs/short.txt"}, report.Mismatched)
+		require.Len(t, report.Errors, 1)
+		assert.Equal(t, "docs/broken.txt", report.Errors[0].Path)
+		assert.False(t, report.OK())
+		require.NotEmpty(t, events)
+		assert.True(t, events[len(events)-1].Done)
+		storage.AssertNotCalled(t, "Get", mock.Anything, mock.Anything)
+		repo.AssertExpectations(t)
+	})
+
+	t.Run("verify hash reads matching files", func(t *testing.T) {
+		service, _, storage := newService(t)
+		storage.On("Get", mock.Anything, "docs/ok.txt").Return(&mockReadSeekCloser{content: []byte("hello")}, nil)
+
+		report, err := service.Verify(context.Background(), stowry.VerifyOptions{
+			Query:      stowry.ListQuery{PathPrefix: "docs/", Limit: 2},
+			VerifyHash: true,
+		})
+
+		require.NoError(t, err)
+		assert.Equal(t, []string{"docs/short.txt"}, report.Mismatched)
+		storage.AssertNumberOfCalls(t, "Get", 1)
+	})
+
+	t.Run("quarantine mismatched files", func(t *testing.T) {
+		service, repo, _ := newService(t)
+		repo.On("Quarantine", mock.Anything, "docs/short.txt", "").Return(nil)
+
+		report, err := service.Verify(context.Background(), stowry.VerifyOptions{
+			Query:      stowry.ListQuery{PathPrefix: "docs/", Limit: 2},
+			Quarantine: true,
+		})
+
+		require.NoError(t, err)
+		assert.Equal(t, []string{"docs/short.txt"}, report.Quarantined)
+		repo.AssertNumberOfCalls(t, "Quarantine", 1)
+	})
+
+	t.Run("all files present", func(t *testing.T) {
+		repo := new(SpyMetaDataRepo)
+		storage := new(SpyStatStorage)
+		service, err := stowry.NewStowryService(repo, storage, stowry.ServiceConfig{Mode: stowry.ModeStore})
+		require.NoError(t, err)
+		repo.On("List", mock.Anything, mock.Anything).Return(stowry.ListResult{Items: objects[:1]}, nil)
+		storage.On("Stat", mock.Anything, "docs/ok.txt").Return(stowry.StoredFile{Size: 5}, nil)
+
+		report, err := service.Verify(context.Background(), stowry.VerifyOptions{})
+
+		require.NoError(t, err)
+		assert.True(t, report.OK())
+		assert.Equal(t, 1, report.Checked)
+	})
+
+	t.Run("stops when canceled", func(t *testing.T) {
+		service, _, _ := newService(t)
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		_, err := service.Verify(ctx, stowry.VerifyOptions{Query: stowry.ListQuery{PathPrefix: "docs/", Limit: 2}})
+		assert.ErrorIs(t, err, context.Canceled)
+	})
+}